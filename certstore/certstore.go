@@ -0,0 +1,69 @@
+// Package certstore implements the import of certificates and private keys in
+// the Windows certificate store, so services like IIS or SQL Server can use
+// the certificates issued by step-ca. The keys are stored using the Microsoft
+// Software Key Storage Provider (CNG). It also implements the export of the
+// certificates in a store, like the trusted roots.
+package certstore
+
+import (
+	"crypto"
+	"crypto/x509"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Store locations.
+const (
+	CurrentUser  = "CurrentUser"
+	LocalMachine = "LocalMachine"
+)
+
+// Location is a certificate store in a store location, e.g. My/LocalMachine.
+type Location struct {
+	Store   string
+	Machine bool
+}
+
+// ParseLocation parses a store location in the form <store>[/<location>],
+// where location is CurrentUser or LocalMachine. By default the CurrentUser
+// location is used.
+func ParseLocation(s string) (*Location, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) > 2 || parts[0] == "" {
+		return nil, errors.Errorf("invalid certificate store '%s', it must be in the form <store>/<location>", s)
+	}
+	loc := &Location{Store: parts[0]}
+	if len(parts) == 2 {
+		switch {
+		case strings.EqualFold(parts[1], CurrentUser):
+		case strings.EqualFold(parts[1], LocalMachine):
+			loc.Machine = true
+		default:
+			return nil, errors.Errorf("invalid certificate store location '%s', options are %s or %s", parts[1], CurrentUser, LocalMachine)
+		}
+	}
+	return loc, nil
+}
+
+// String returns the location in the form <store>/<location>.
+func (l *Location) String() string {
+	if l.Machine {
+		return l.Store + "/" + LocalMachine
+	}
+	return l.Store + "/" + CurrentUser
+}
+
+// Install imports the certificate and its private key in the certificate
+// store. If replace is not nil, the replaced certificate and its key are
+// removed from the store.
+func Install(loc *Location, crt *x509.Certificate, key crypto.PrivateKey, replace *x509.Certificate) error {
+	return install(loc, crt, key, replace)
+}
+
+// Certificates returns the certificates in the certificate store, e.g. the
+// trusted roots in Root/LocalMachine. Certificates that cannot be parsed are
+// skipped.
+func Certificates(loc *Location) ([]*x509.Certificate, error) {
+	return certificates(loc)
+}
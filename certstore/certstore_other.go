@@ -0,0 +1,18 @@
+// +build !windows
+
+package certstore
+
+import (
+	"crypto"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+)
+
+func install(loc *Location, crt *x509.Certificate, key crypto.PrivateKey, replace *x509.Certificate) error {
+	return errors.New("the Windows certificate store is only available on Windows")
+}
+
+func certificates(loc *Location) ([]*x509.Certificate, error) {
+	return nil, errors.New("the Windows certificate store is only available on Windows")
+}
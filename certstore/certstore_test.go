@@ -0,0 +1,33 @@
+package certstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *Location
+	}{
+		{"My", &Location{Store: "My"}},
+		{"My/CurrentUser", &Location{Store: "My"}},
+		{"My/LocalMachine", &Location{Store: "My", Machine: true}},
+		{"WebHosting/localmachine", &Location{Store: "WebHosting", Machine: true}},
+	}
+	for _, tt := range tests {
+		loc, err := ParseLocation(tt.in)
+		require.NoError(t, err, tt.in)
+		require.Equal(t, tt.want, loc, tt.in)
+	}
+
+	for _, in := range []string{"", "/LocalMachine", "My/Users", "My/LocalMachine/Foo"} {
+		_, err := ParseLocation(in)
+		require.Error(t, err, in)
+	}
+
+	loc, err := ParseLocation("My/localmachine")
+	require.NoError(t, err)
+	require.Equal(t, "My/LocalMachine", loc.String())
+}
@@ -0,0 +1,332 @@
+package certstore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	certStoreProvSystem         = 10 // CERT_STORE_PROV_SYSTEM_W
+	certSystemStoreCurrentUser  = 1 << 16
+	certSystemStoreLocalMachine = 2 << 16
+	certStoreAddReplaceExisting = 3
+	certStoreReadOnlyFlag       = 0x8000
+	certKeyProvInfoPropID       = 2
+	certNCryptKeySpec           = 0xFFFFFFFF
+	encodingX509ASN             = 1
+	encodingPKCS7ASN            = 0x10000
+	ncryptMachineKeyFlag        = 0x20
+	ncryptOverwriteKeyFlag      = 0x80
+	ncryptDoNotFinalizeFlag     = 0x400
+	ncryptBufferPKCSKeyName     = 45
+	ecdsaPrivateP256Magic       = 0x32534345
+	ecdsaPrivateP384Magic       = 0x34534345
+	ecdsaPrivateP521Magic       = 0x36534345
+	rsaPrivateMagic             = 0x32415352
+	msKeyStorageProvider        = "Microsoft Software Key Storage Provider"
+	keyContainerPrefix          = "step-"
+	bcryptECCPrivateBlob        = "ECCPRIVATEBLOB"
+	bcryptRSAPrivateBlob        = "RSAPRIVATEBLOB"
+	errorSuccess                = 0
+)
+
+var (
+	ncrypt                                = windows.NewLazySystemDLL("ncrypt.dll")
+	procNCryptOpenStorageProvider         = ncrypt.NewProc("NCryptOpenStorageProvider")
+	procNCryptImportKey                   = ncrypt.NewProc("NCryptImportKey")
+	procNCryptFinalizeKey                 = ncrypt.NewProc("NCryptFinalizeKey")
+	procNCryptOpenKey                     = ncrypt.NewProc("NCryptOpenKey")
+	procNCryptDeleteKey                   = ncrypt.NewProc("NCryptDeleteKey")
+	procNCryptFreeObject                  = ncrypt.NewProc("NCryptFreeObject")
+	crypt32                               = windows.NewLazySystemDLL("crypt32.dll")
+	procCertSetCertificateContextProperty = crypt32.NewProc("CertSetCertificateContextProperty")
+	procCertDuplicateCertificateContext   = crypt32.NewProc("CertDuplicateCertificateContext")
+	procCertDeleteCertificateFromStore    = crypt32.NewProc("CertDeleteCertificateFromStore")
+)
+
+type ncryptBuffer struct {
+	cbBuffer   uint32
+	bufferType uint32
+	pvBuffer   uintptr
+}
+
+type ncryptBufferDesc struct {
+	version  uint32
+	cBuffers uint32
+	pBuffers *ncryptBuffer
+}
+
+type cryptKeyProvInfo struct {
+	containerName *uint16
+	provName      *uint16
+	provType      uint32
+	flags         uint32
+	cProvParam    uint32
+	rgProvParam   uintptr
+	keySpec       uint32
+}
+
+func install(loc *Location, crt *x509.Certificate, key crypto.PrivateKey, replace *x509.Certificate) error {
+	flags := uint32(certSystemStoreCurrentUser)
+	var keyFlags uint32
+	if loc.Machine {
+		flags = certSystemStoreLocalMachine
+		keyFlags = ncryptMachineKeyFlag
+	}
+
+	storeName, err := windows.UTF16PtrFromString(loc.Store)
+	if err != nil {
+		return errors.Wrapf(err, "invalid certificate store %s", loc)
+	}
+	store, err := windows.CertOpenStore(certStoreProvSystem, 0, 0, flags, uintptr(unsafe.Pointer(storeName)))
+	if err != nil {
+		return errors.Wrapf(err, "error opening certificate store %s", loc)
+	}
+	defer windows.CertCloseStore(store, 0)
+
+	// Import the key first, so the certificate is never added without key
+	containerName := keyContainerName(crt)
+	if err := importKey(containerName, key, keyFlags); err != nil {
+		return err
+	}
+
+	certContext, err := windows.CertCreateCertificateContext(encodingX509ASN|encodingPKCS7ASN, &crt.Raw[0], uint32(len(crt.Raw)))
+	if err != nil {
+		return errors.Wrap(err, "error creating certificate context")
+	}
+	defer windows.CertFreeCertificateContext(certContext)
+
+	container, err := windows.UTF16PtrFromString(containerName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	provider, err := windows.UTF16PtrFromString(msKeyStorageProvider)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	info := &cryptKeyProvInfo{
+		containerName: container,
+		provName:      provider,
+		flags:         keyFlags,
+		keySpec:       certNCryptKeySpec,
+	}
+	if r, _, err := procCertSetCertificateContextProperty.Call(uintptr(unsafe.Pointer(certContext)), certKeyProvInfoPropID, 0, uintptr(unsafe.Pointer(info))); r == 0 {
+		return errors.Wrap(err, "error linking the certificate with its key")
+	}
+	if err := windows.CertAddCertificateContextToStore(store, certContext, certStoreAddReplaceExisting, nil); err != nil {
+		return errors.Wrapf(err, "error adding the certificate to %s", loc)
+	}
+
+	if replace != nil && !bytes.Equal(replace.Raw, crt.Raw) {
+		if err := removeCertificate(store, replace); err != nil {
+			return err
+		}
+		if err := deleteKey(keyContainerName(replace), keyFlags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyContainerName returns the name of the key container of the certificate
+// using its SHA-1 thumbprint.
+func keyContainerName(crt *x509.Certificate) string {
+	sum := sha1.Sum(crt.Raw)
+	return keyContainerPrefix + hex.EncodeToString(sum[:])
+}
+
+func openProvider() (uintptr, error) {
+	provider, err := windows.UTF16PtrFromString(msKeyStorageProvider)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	var h uintptr
+	if r, _, _ := procNCryptOpenStorageProvider.Call(uintptr(unsafe.Pointer(&h)), uintptr(unsafe.Pointer(provider)), 0); r != errorSuccess {
+		return 0, errors.Errorf("error opening the key storage provider: 0x%x", r)
+	}
+	return h, nil
+}
+
+func importKey(containerName string, key crypto.PrivateKey, flags uint32) error {
+	blobType, blob, err := keyBlob(key)
+	if err != nil {
+		return err
+	}
+	typ, err := windows.UTF16PtrFromString(blobType)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	name, err := windows.UTF16FromString(containerName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	provider, err := openProvider()
+	if err != nil {
+		return err
+	}
+	defer procNCryptFreeObject.Call(provider)
+
+	buf := &ncryptBuffer{
+		cbBuffer:   uint32(len(name) * 2),
+		bufferType: ncryptBufferPKCSKeyName,
+		pvBuffer:   uintptr(unsafe.Pointer(&name[0])),
+	}
+	params := &ncryptBufferDesc{cBuffers: 1, pBuffers: buf}
+
+	var h uintptr
+	r, _, _ := procNCryptImportKey.Call(provider, 0, uintptr(unsafe.Pointer(typ)), uintptr(unsafe.Pointer(params)),
+		uintptr(unsafe.Pointer(&h)), uintptr(unsafe.Pointer(&blob[0])), uintptr(len(blob)),
+		uintptr(flags|ncryptOverwriteKeyFlag|ncryptDoNotFinalizeFlag))
+	if r != errorSuccess {
+		return errors.Errorf("error importing the private key: 0x%x", r)
+	}
+	defer procNCryptFreeObject.Call(h)
+
+	if r, _, _ := procNCryptFinalizeKey.Call(h, 0); r != errorSuccess {
+		return errors.Errorf("error storing the private key: 0x%x", r)
+	}
+	return nil
+}
+
+func deleteKey(containerName string, flags uint32) error {
+	name, err := windows.UTF16PtrFromString(containerName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	provider, err := openProvider()
+	if err != nil {
+		return err
+	}
+	defer procNCryptFreeObject.Call(provider)
+
+	var h uintptr
+	if r, _, _ := procNCryptOpenKey.Call(provider, uintptr(unsafe.Pointer(&h)), uintptr(unsafe.Pointer(name)), 0, uintptr(flags)); r != errorSuccess {
+		// The key of the replaced certificate might not have been imported by step
+		return nil
+	}
+	// NCryptDeleteKey frees the handle
+	if r, _, _ := procNCryptDeleteKey.Call(h, 0); r != errorSuccess {
+		return errors.Errorf("error deleting the private key %s: 0x%x", containerName, r)
+	}
+	return nil
+}
+
+func certificates(loc *Location) ([]*x509.Certificate, error) {
+	flags := uint32(certSystemStoreCurrentUser)
+	if loc.Machine {
+		flags = certSystemStoreLocalMachine
+	}
+	storeName, err := windows.UTF16PtrFromString(loc.Store)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid certificate store %s", loc)
+	}
+	store, err := windows.CertOpenStore(certStoreProvSystem, 0, 0, flags|certStoreReadOnlyFlag, uintptr(unsafe.Pointer(storeName)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening certificate store %s", loc)
+	}
+	defer windows.CertCloseStore(store, 0)
+
+	var certs []*x509.Certificate
+	var c *windows.CertContext
+	for {
+		if c, err = windows.CertEnumCertificatesInStore(store, c); err != nil || c == nil {
+			return certs, nil
+		}
+		raw := (*[1 << 20]byte)(unsafe.Pointer(c.EncodedCert))[:c.Length:c.Length]
+		if crt, err := x509.ParseCertificate(append([]byte(nil), raw...)); err == nil {
+			certs = append(certs, crt)
+		}
+	}
+}
+
+// removeCertificate removes all the copies of the given certificate in the
+// store.
+func removeCertificate(store windows.Handle, crt *x509.Certificate) error {
+	var c *windows.CertContext
+	for {
+		var err error
+		if c, err = windows.CertEnumCertificatesInStore(store, c); err != nil || c == nil {
+			return nil
+		}
+		raw := (*[1 << 20]byte)(unsafe.Pointer(c.EncodedCert))[:c.Length:c.Length]
+		if !bytes.Equal(raw, crt.Raw) {
+			continue
+		}
+		// CertDeleteCertificateFromStore frees the context, use a copy to
+		// continue the enumeration.
+		dup, _, _ := procCertDuplicateCertificateContext.Call(uintptr(unsafe.Pointer(c)))
+		if r, _, err := procCertDeleteCertificateFromStore.Call(dup); r == 0 {
+			windows.CertFreeCertificateContext(c)
+			return errors.Wrap(err, "error removing the replaced certificate")
+		}
+	}
+}
+
+// keyBlob returns the blob type and the BCRYPT_*_BLOB used to import the
+// given key.
+func keyBlob(key crypto.PrivateKey) (string, []byte, error) {
+	var buf bytes.Buffer
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		var magic uint32
+		switch k.Curve {
+		case elliptic.P256():
+			magic = ecdsaPrivateP256Magic
+		case elliptic.P384():
+			magic = ecdsaPrivateP384Magic
+		case elliptic.P521():
+			magic = ecdsaPrivateP521Magic
+		default:
+			return "", nil, errors.Errorf("unsupported curve %s", k.Curve.Params().Name)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		binary.Write(&buf, binary.LittleEndian, magic)
+		binary.Write(&buf, binary.LittleEndian, uint32(size))
+		buf.Write(padBytes(k.X, size))
+		buf.Write(padBytes(k.Y, size))
+		buf.Write(padBytes(k.D, size))
+		return bcryptECCPrivateBlob, buf.Bytes(), nil
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return "", nil, errors.New("unsupported RSA key with more than two primes")
+		}
+		e := big.NewInt(int64(k.E)).Bytes()
+		n := k.N.Bytes()
+		p := k.Primes[0].Bytes()
+		q := k.Primes[1].Bytes()
+		binary.Write(&buf, binary.LittleEndian, uint32(rsaPrivateMagic))
+		binary.Write(&buf, binary.LittleEndian, uint32(k.N.BitLen()))
+		binary.Write(&buf, binary.LittleEndian, uint32(len(e)))
+		binary.Write(&buf, binary.LittleEndian, uint32(len(n)))
+		binary.Write(&buf, binary.LittleEndian, uint32(len(p)))
+		binary.Write(&buf, binary.LittleEndian, uint32(len(q)))
+		buf.Write(e)
+		buf.Write(n)
+		buf.Write(p)
+		buf.Write(q)
+		return bcryptRSAPrivateBlob, buf.Bytes(), nil
+	default:
+		return "", nil, errors.Errorf("unsupported key type %T", key)
+	}
+}
+
+func padBytes(i *big.Int, size int) []byte {
+	b := i.Bytes()
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
@@ -0,0 +1,196 @@
+// Package clock checks the skew of the local clock against a time source
+// before creating or verifying time-sensitive artifacts like tokens and
+// certificates. A skewed clock is the usual cause of errors like "token used
+// before issued" or "certificate has expired or is not yet valid".
+//
+// The check is enabled with the global flag --check-clock, the time source
+// can be an NTP server or an HTTPS url, usually the CA, whose Date header is
+// used.
+package clock
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+)
+
+// DefaultSource is the time source used if none is configured.
+const DefaultSource = "ntp://pool.ntp.org"
+
+// DefaultMaxSkew is the maximum skew allowed by default.
+const DefaultMaxSkew = 30 * time.Second
+
+// timeout is the maximum time to wait for the time source.
+const timeout = 5 * time.Second
+
+// Options are the options of the clock check.
+type Options struct {
+	// Source is the time source, ntp://host[:port] or an https url. A host
+	// without scheme is an NTP server.
+	Source string
+	// MaxSkew is the maximum difference between the local clock and the
+	// time source.
+	MaxSkew time.Duration
+	// Fail makes the check fail if the skew is greater than MaxSkew or the
+	// time source cannot be queried. By default a warning is printed.
+	Fail bool
+	// Root is the file with the root certificate of the CA, it's trusted
+	// along with the system pool when the time source is an https url.
+	Root string
+}
+
+var (
+	mu      sync.Mutex
+	options *Options
+	checked bool
+	result  error
+)
+
+// Enable enables the clock check with the given options.
+func Enable(opts Options) {
+	if opts.Source == "" {
+		opts.Source = DefaultSource
+	}
+	if opts.MaxSkew <= 0 {
+		opts.MaxSkew = DefaultMaxSkew
+	}
+	mu.Lock()
+	options = &opts
+	checked, result = false, nil
+	mu.Unlock()
+}
+
+// Check checks the skew of the local clock if the check is enabled. The time
+// source is only queried once, the next calls return the same result.
+func Check() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if options == nil || checked {
+		return result
+	}
+	checked = true
+	result = check(options)
+	return result
+}
+
+func check(opts *Options) error {
+	skew, err := skew(opts.Source, opts.Root)
+	if err != nil {
+		if opts.Fail {
+			return errs.Network(errors.Wrap(err, "error checking the clock"))
+		}
+		ui.Warnf("cannot check the clock: %v", err)
+		return nil
+	}
+
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs <= opts.MaxSkew {
+		return nil
+	}
+	format := "the local clock is %s behind %s, tokens and certificates may be rejected as expired"
+	if skew > 0 {
+		format = "the local clock is %s ahead of %s, tokens and certificates may be rejected as not yet valid"
+	}
+	abs = abs.Round(time.Millisecond)
+	if opts.Fail {
+		return errors.Errorf(format, abs, opts.Source)
+	}
+	ui.Warnf(format, abs, opts.Source)
+	return nil
+}
+
+// Skew returns the difference between the local clock and the given time
+// source, it's positive if the local clock is ahead. The certificate of an
+// https source is verified using the system pool.
+func Skew(source string) (time.Duration, error) {
+	return skew(source, "")
+}
+
+func skew(source, root string) (time.Duration, error) {
+	if !strings.Contains(source, "://") {
+		source = "ntp://" + source
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error parsing time source '%s'", source)
+	}
+	switch u.Scheme {
+	case "ntp":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "123")
+		}
+		return ntpSkew(host)
+	case "https", "http":
+		return httpSkew(u.String(), root)
+	default:
+		return 0, errors.Errorf("unsupported time source '%s'", source)
+	}
+}
+
+// httpSkew returns the skew of the local clock using the Date header of the
+// given url. The Date header has a resolution of one second. The certificate
+// of the server is verified with the system pool and the given root file, if
+// any.
+func httpSkew(rawurl, root string) (time.Duration, error) {
+	pool, err := rootPool(root)
+	if err != nil {
+		return 0, err
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+	start := time.Now()
+	resp, err := client.Head(rawurl)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error requesting %s", rawurl)
+	}
+	resp.Body.Close()
+	end := time.Now()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, errors.Errorf("error requesting %s: invalid Date header", rawurl)
+	}
+	// The Date header is truncated to the second
+	remote := date.Add(500 * time.Millisecond)
+	local := start.Add(end.Sub(start) / 2)
+	return local.Sub(remote), nil
+}
+
+// rootPool returns the system pool with the certificates in the given root
+// file. It returns nil, the system pool, if root is empty.
+func rootPool(root string) (*x509.CertPool, error) {
+	if root == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(root)
+	if err != nil {
+		return nil, errs.FileError(err, root)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.Errorf("error parsing %s: no certificates found", root)
+	}
+	return pool, nil
+}
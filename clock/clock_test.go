@@ -0,0 +1,148 @@
+package clock
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ntpServer starts an SNTP server whose clock is offset from the local clock.
+// The servers are closed by closeServers.
+func ntpServer(t *testing.T, offset time.Duration) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	servers = append(servers, conn)
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := make([]byte, 48)
+			// LI = 0, VN = 4, Mode = 4 (server), stratum 1
+			resp[0], resp[1] = 0x24, 1
+			copy(resp[24:32], buf[40:48])
+			now := time.Now().Add(offset)
+			putNTPTime(resp[32:], now)
+			putNTPTime(resp[40:], now)
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+var servers []net.PacketConn
+
+func closeServers() {
+	for _, conn := range servers {
+		conn.Close()
+	}
+	servers = nil
+}
+
+func TestNTPTime(t *testing.T) {
+	now := time.Unix(1600000000, 123456789)
+	b := make([]byte, 8)
+	putNTPTime(b, now)
+	require.True(t, ntpTime(b).Sub(now) < time.Microsecond)
+	require.True(t, now.Sub(ntpTime(b)) < time.Microsecond)
+}
+
+func TestSkew(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-2*time.Hour).UTC().Format(http.TimeFormat))
+	}))
+	defer srv.Close()
+	defer closeServers()
+
+	tests := []struct {
+		name    string
+		source  string
+		want    time.Duration
+		delta   time.Duration
+		wantErr bool
+	}{
+		{"ntp/behind", "ntp://" + ntpServer(t, time.Hour), -time.Hour, time.Second, false},
+		{"ntp/ahead", ntpServer(t, -time.Minute), time.Minute, time.Second, false},
+		{"ntp/sync", ntpServer(t, 0), 0, time.Second, false},
+		{"http", srv.URL, 2 * time.Hour, 2 * time.Second, false},
+		{"fail/scheme", "ftp://example.com", 0, 0, true},
+		{"fail/http", "http://127.0.0.1:1", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Skew(tt.source)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, got > tt.want-tt.delta && got < tt.want+tt.delta, "skew %s, want %s", got, tt.want)
+		})
+	}
+}
+
+func TestSkewHTTPS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "step-clock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root_ca.crt")
+	require.NoError(t, ioutil.WriteFile(root, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: srv.Certificate().Raw,
+	}), 0600))
+
+	// The certificate of the server is not in the system pool
+	_, err = Skew(srv.URL)
+	require.Error(t, err)
+	_, err = skew(srv.URL, filepath.Join(dir, "missing.crt"))
+	require.Error(t, err)
+
+	got, err := skew(srv.URL, root)
+	require.NoError(t, err)
+	require.True(t, got > -time.Hour-2*time.Second && got < -time.Hour+2*time.Second, "skew %s, want -1h", got)
+}
+
+func TestCheck(t *testing.T) {
+	defer closeServers()
+	defer func() {
+		mu.Lock()
+		options = nil
+		mu.Unlock()
+	}()
+
+	// Disabled
+	require.NoError(t, Check())
+
+	Enable(Options{Source: ntpServer(t, 0), Fail: true})
+	require.NoError(t, Check())
+
+	Enable(Options{Source: ntpServer(t, time.Hour), Fail: true})
+	require.Error(t, Check())
+	// The result is cached
+	require.Error(t, Check())
+
+	// Warnings do not fail
+	Enable(Options{Source: ntpServer(t, time.Hour)})
+	require.NoError(t, Check())
+
+	Enable(Options{Source: ntpServer(t, time.Hour), MaxSkew: 2 * time.Hour, Fail: true})
+	require.NoError(t, Check())
+
+	Enable(Options{Source: "ftp://example.com", Fail: true})
+	require.Error(t, Check())
+}
@@ -0,0 +1,72 @@
+package clock
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch, 1900, and
+// the Unix epoch.
+const ntpEpochOffset = 2208988800
+
+// ntpSkew returns the skew of the local clock using the SNTP protocol defined
+// in RFC 4330.
+func ntpSkew(host string) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", host, timeout)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error connecting to %s", host)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	// LI = 0, VN = 4, Mode = 3 (client)
+	req := make([]byte, 48)
+	req[0] = 0x23
+	t1 := time.Now()
+	putNTPTime(req[40:], t1)
+	if _, err := conn.Write(req); err != nil {
+		return 0, errors.Wrapf(err, "error requesting %s", host)
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error requesting %s", host)
+	}
+	t4 := time.Now()
+	switch {
+	case n < 48:
+		return 0, errors.Errorf("error requesting %s: invalid response", host)
+	case resp[0]&0x07 != 4:
+		return 0, errors.Errorf("error requesting %s: invalid response mode", host)
+	case resp[1] == 0:
+		return 0, errors.Errorf("error requesting %s: the server is not synchronized", host)
+	}
+
+	t2 := ntpTime(resp[32:40])
+	t3 := ntpTime(resp[40:48])
+	// offset = ((t2 - t1) + (t3 - t4)) / 2 is the correction of the local
+	// clock, the skew is its opposite.
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	return -offset, nil
+}
+
+// ntpTime decodes an NTP timestamp.
+func ntpTime(b []byte) time.Time {
+	sec := int64(binary.BigEndian.Uint32(b[:4])) - ntpEpochOffset
+	frac := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(sec, (frac*1e9)>>32)
+}
+
+// putNTPTime encodes t as an NTP timestamp.
+func putNTPTime(b []byte, t time.Time) {
+	sec := uint32(t.Unix() + ntpEpochOffset)
+	frac := uint32((int64(t.Nanosecond()) << 32) / 1e9)
+	binary.BigEndian.PutUint32(b[:4], sec)
+	binary.BigEndian.PutUint32(b[4:8], frac)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/smallstep/cli/config"
+)
+
+// sensitiveFlags are the words in the name of the flags whose values are not
+// written in the crash reports.
+var sensitiveFlags = []string{"password", "passphrase", "secret", "token", "pin"}
+
+// writeCrashReport writes the panic value and stack trace in a crash report
+// under $STEPPATH/crash and returns its name.
+func writeCrashReport(r interface{}, stack []byte) (string, error) {
+	dir := filepath.Join(config.StepPath(), "crash")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	filename := filepath.Join(dir, fmt.Sprintf("step-%s-%d.log", now.Format("20060102T150405Z"), os.Getpid()))
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(f, "panic: %v\n\n", r)
+	fmt.Fprintf(f, "Version: %s\n", config.Version())
+	fmt.Fprintf(f, "Release Date: %s\n", config.ReleaseDate())
+	fmt.Fprintf(f, "Time: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(f, "Command: %s\n\n", strings.Join(redactArgs(os.Args), " "))
+	f.Write(stack)
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// redactArgs returns the given arguments with the values of the sensitive
+// flags replaced.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i := 0; i < len(redacted); i++ {
+		arg := redacted[i]
+		if !strings.HasPrefix(arg, "-") || !isSensitiveFlag(arg) {
+			continue
+		}
+		if j := strings.IndexByte(arg, '='); j >= 0 {
+			redacted[i] = arg[:j+1] + "<redacted>"
+		} else if i+1 < len(redacted) && !strings.HasPrefix(redacted[i+1], "-") {
+			i++
+			redacted[i] = "<redacted>"
+		}
+	}
+	return redacted
+}
+
+func isSensitiveFlag(arg string) bool {
+	name := strings.ToLower(strings.TrimLeft(arg, "-"))
+	if j := strings.IndexByte(name, '='); j >= 0 {
+		name = name[:j]
+	}
+	// Files with the values are not sensitive
+	if strings.HasSuffix(name, "-file") {
+		return false
+	}
+	for _, s := range sensitiveFlags {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,7 @@
+// +build !nogcs
+
+package main
+
+// Read files from gs:// URLs. Build with the tag nogcs to remove the Google
+// Cloud Storage backend.
+import _ "github.com/smallstep/cli/fileurl/gcs"
@@ -0,0 +1,7 @@
+// +build !nos3
+
+package main
+
+// Read files from s3:// URLs. Build with the tag nos3 to remove the AWS S3
+// backend.
+import _ "github.com/smallstep/cli/fileurl/s3"
@@ -8,24 +8,45 @@ import (
 	"os"
 	"reflect"
 	"regexp"
+	"runtime"
+	"runtime/debug"
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/urfave/cli"
 
+	"github.com/smallstep/cli/clock"
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/command/version"
 	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/fips"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/netconfig"
+	"github.com/smallstep/cli/plugin"
+	"github.com/smallstep/cli/timing"
+	"github.com/smallstep/cli/trace"
+	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/usage"
 
 	// Enabled commands
+	_ "github.com/smallstep/cli/command/acme"
+	_ "github.com/smallstep/cli/command/agent"
 	_ "github.com/smallstep/cli/command/base64"
+	_ "github.com/smallstep/cli/command/bundle"
 	_ "github.com/smallstep/cli/command/ca"
 	_ "github.com/smallstep/cli/command/certificate"
+	_ "github.com/smallstep/cli/command/config"
+	_ "github.com/smallstep/cli/command/credentials"
 	_ "github.com/smallstep/cli/command/crypto"
+	_ "github.com/smallstep/cli/command/doctor"
 	_ "github.com/smallstep/cli/command/fileserver"
+	_ "github.com/smallstep/cli/command/inventory"
 	_ "github.com/smallstep/cli/command/oauth"
 	_ "github.com/smallstep/cli/command/path"
+	_ "github.com/smallstep/cli/command/policy"
+	_ "github.com/smallstep/cli/command/tls"
+	_ "github.com/smallstep/cli/command/update"
 
 	// Profiling and debugging
 	_ "net/http/pprof"
@@ -39,6 +60,10 @@ var Version = "N/A"
 // the time of build
 var BuildTime = "N/A"
 
+// debugMode is set by the global flag --debug, it prints the timings of the
+// operations and the stack traces of the errors.
+var debugMode bool
+
 func init() {
 	config.Set("Smallstep CLI", Version, BuildTime)
 	rand.Seed(time.Now().UnixNano())
@@ -64,6 +89,7 @@ func main() {
 	app.Usage = "plumbing for distributed systems"
 	app.Version = config.Version()
 	app.Commands = command.Retrieve()
+	app.Commands = append(app.Commands, plugin.Commands(app.Commands, isHelp(os.Args[1:]))...)
 	app.Flags = append(app.Flags, cli.HelpFlag)
 	app.EnableBashCompletion = true
 	app.Copyright = "(c) 2019 Smallstep Labs, Inc."
@@ -74,6 +100,129 @@ func main() {
 		Usage: "path to the config file to use for CLI flags",
 	})
 
+	// Flags to control the prompts
+	app.Flags = append(app.Flags, cli.BoolFlag{
+		Name:   "non-interactive",
+		Usage:  "fail instead of prompting for any missing value",
+		EnvVar: ui.NonInteractiveEnv,
+	}, cli.StringFlag{
+		Name:   "non-interactive-password-file",
+		Usage:  "the <file> with the password to use instead of the password prompts in non-interactive mode",
+		EnvVar: ui.PasswordFileEnv,
+	}, cli.DurationFlag{
+		Name:   "prompt-timeout",
+		Usage:  "the maximum <duration> to wait for a prompt to be completed",
+		EnvVar: ui.PromptTimeoutEnv,
+	})
+
+	// Flags to trace the HTTP requests
+	app.Flags = append(app.Flags, cli.BoolFlag{
+		Name:  "trace",
+		Usage: "log the HTTP requests and responses to stderr, sensitive values are redacted",
+	}, cli.StringFlag{
+		Name:  "trace-file",
+		Usage: "the <file> where the HTTP requests and responses are logged, implies --trace",
+	}, cli.StringFlag{
+		Name: "record",
+		Usage: `the <file> where the HTTP requests and responses are recorded, sensitive
+values are redacted; the file can be used with --replay to reproduce an enrollment offline`,
+	}, cli.BoolFlag{
+		Name: "record-raw",
+		Usage: `record the response bodies that are not JSON, like certificates or CRLs, with
+--record; they cannot be redacted, by default only their size and digest are recorded`,
+	}, cli.StringFlag{
+		Name:  "replay",
+		Usage: "the <file> with the HTTP responses recorded with --record, no requests are sent",
+	}, cli.StringFlag{
+		Name:  "user-agent-comment",
+		Usage: "the <comment> appended to the User-Agent header sent to the CA",
+	})
+	// Flag to restrict the algorithms to the FIPS-approved ones
+	app.Flags = append(app.Flags, cli.BoolFlag{
+		Name:   "fips",
+		Usage:  "only allow FIPS-approved algorithms, fail with any other",
+		EnvVar: fips.Env,
+	})
+	// Flag to print diagnostics
+	app.Flags = append(app.Flags, cli.BoolFlag{
+		Name: "debug",
+		Usage: `print the time spent on key parsing, network calls and crypto operations,
+and the stack traces of the errors`,
+	})
+	// Flag to disable the colors in the output, NO_COLOR is also supported
+	app.Flags = append(app.Flags, cli.BoolFlag{
+		Name:  "no-color",
+		Usage: "disable the colors in the output, colors are also disabled if NO_COLOR is set",
+	})
+	// Flags to check the skew of the local clock
+	app.Flags = append(app.Flags, cli.BoolFlag{
+		Name:  "check-clock",
+		Usage: "check the skew of the local clock before creating or verifying tokens and certificates",
+	}, cli.StringFlag{
+		Name: "clock-source",
+		Usage: `the time <source> used by --check-clock, an NTP server like ntp://time.example.com
+or an https url like the CA url, whose Date header is used. The certificate of the
+https url is verified with the system pool and the root in the configuration`,
+		Value: clock.DefaultSource,
+	}, cli.DurationFlag{
+		Name:  "max-clock-skew",
+		Usage: "the maximum <duration> the local clock can differ from the time source",
+		Value: clock.DefaultMaxSkew,
+	}, cli.BoolFlag{
+		Name:  "fail-on-clock-skew",
+		Usage: "fail instead of printing a warning if the clock is skewed or cannot be checked",
+	})
+	// Flags to configure the connections to the CA
+	app.Flags = append(app.Flags, cli.BoolFlag{
+		Name:  "ipv4, 4",
+		Usage: "only connect to the CA using IPv4",
+	}, cli.BoolFlag{
+		Name:  "ipv6, 6",
+		Usage: "only connect to the CA using IPv6",
+	}, cli.StringFlag{
+		Name: "dns-server",
+		Usage: `the <address> of the DNS server used to resolve the CA, host[:port], for
+split-horizon DNS or lab environments where the name of the CA does not resolve normally`,
+	}, cli.StringFlag{
+		Name: "tls-server-name",
+		Usage: `the <name> used to verify the certificate of the CA and sent in the TLS SNI,
+instead of the host in the CA URL`,
+	})
+	app.Before = func(ctx *cli.Context) error {
+		if err := setupNetwork(ctx); err != nil {
+			return err
+		}
+		if ctx.GlobalBool("fips") {
+			fips.Enable()
+		}
+		if ctx.GlobalBool("no-color") {
+			ui.DisableColor()
+		}
+		if ctx.GlobalBool("debug") {
+			debugMode = true
+			timing.Enable(os.Stderr)
+			http.DefaultTransport = trace.Transport(http.DefaultTransport)
+		}
+		if ctx.GlobalBool("check-clock") {
+			opts := clock.Options{
+				Source:  ctx.GlobalString("clock-source"),
+				MaxSkew: ctx.GlobalDuration("max-clock-skew"),
+				Fail:    ctx.GlobalBool("fail-on-clock-skew"),
+			}
+			// Trust the root of the CA, the CA is usually the https source
+			if m, err := command.ReadConfigFile(command.ConfigFile(ctx)); err == nil {
+				if root, ok := m["root"].(string); ok {
+					opts.Root = command.ExpandEnv(root)
+				}
+			}
+			clock.Enable(opts)
+		}
+		ui.SetNonInteractive(ctx.GlobalBool("non-interactive"))
+		ui.SetPasswordFile(ctx.GlobalString("non-interactive-password-file"))
+		ui.SetPromptTimeout(ctx.GlobalDuration("prompt-timeout"))
+		return setupTrace(ctx)
+	}
+
 	// All non-successful output should be written to stderr
 	app.Writer = os.Stdout
 	app.ErrWriter = os.Stderr
@@ -87,14 +236,119 @@ func main() {
 		}()
 	}
 
-	if err := app.Run(os.Args); err != nil {
-		if os.Getenv("STEPDEBUG") == "1" {
+	err := app.Run(os.Args)
+	timing.Summary()
+	if err != nil {
+		switch {
+		case errs.IsJSONFormat():
+			b, jerr := errs.JSON(err)
+			if jerr != nil {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				fmt.Fprintln(os.Stderr, string(b))
+			}
+		case debugMode || os.Getenv("STEPDEBUG") == "1":
 			fmt.Fprintf(os.Stderr, "%+v\n", err)
-		} else {
+		default:
 			fmt.Fprintln(os.Stderr, err)
 		}
-		os.Exit(1)
+		if id := trace.FailedRequestID(); id != "" && !errs.IsJSONFormat() {
+			fmt.Fprintf(os.Stderr, "Request ID: %s\n", id)
+		}
+		os.Exit(errs.ExitCode(err))
+	}
+}
+
+// setupTrace sets the User-Agent of the HTTP requests, enables their recording
+// or replay with the --record and --replay flags, and enables the tracing of
+// them if the --trace or --trace-file flags are used, or STEPDEBUG=1.
+func setupTrace(ctx *cli.Context) error {
+	userAgent := fmt.Sprintf("step-cli/%s (%s/%s)", Version, runtime.GOOS, runtime.GOARCH)
+	if comment := strings.TrimSpace(ctx.GlobalString("user-agent-comment")); comment != "" {
+		userAgent += " " + comment
+	}
+	trace.SetUserAgent(userAgent)
+
+	record, replay := ctx.GlobalString("record"), ctx.GlobalString("replay")
+	switch {
+	case record != "" && replay != "":
+		return errs.IncompatibleFlagWithFlag(ctx, "record", "replay")
+	case record == "" && ctx.GlobalBool("record-raw"):
+		return errs.RequiredWithFlag(ctx, "record-raw", "record")
+	case record != "":
+		f, err := os.OpenFile(record, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return errs.FileError(err, record)
+		}
+		trace.Record(f, ctx.GlobalBool("record-raw"))
+	case replay != "":
+		f, err := os.Open(replay)
+		if err != nil {
+			return errs.FileError(err, replay)
+		}
+		defer f.Close()
+		if err := trace.Replay(f); err != nil {
+			return errors.Wrapf(err, "error reading %s", replay)
+		}
+	}
+
+	if filename := ctx.GlobalString("trace-file"); filename != "" {
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return errs.FileError(err, filename)
+		}
+		trace.Enable(f)
+		return nil
+	}
+	if ctx.GlobalBool("trace") || os.Getenv("STEPDEBUG") == "1" {
+		trace.Enable(os.Stderr)
+	}
+	return nil
+}
+
+// setupNetwork configures the connections to the CA with the flags -4, -6,
+// --dns-server and --tls-server-name. It must run before the
+// http.DefaultTransport is wrapped.
+func setupNetwork(ctx *cli.Context) error {
+	opts := netconfig.Options{
+		DNSServer:  ctx.GlobalString("dns-server"),
+		ServerName: ctx.GlobalString("tls-server-name"),
+	}
+	switch {
+	case ctx.GlobalBool("ipv4") && ctx.GlobalBool("ipv6"):
+		return errs.IncompatibleFlagWithFlag(ctx, "ipv4", "ipv6")
+	case ctx.GlobalBool("ipv4"):
+		opts.Network = "tcp4"
+	case ctx.GlobalBool("ipv6"):
+		opts.Network = "tcp6"
+	}
+	if opts == (netconfig.Options{}) {
+		return nil
+	}
+	if err := netconfig.Enable(opts); err != nil {
+		if errors.Cause(err) == netconfig.ErrInvalidDNSServer {
+			return errs.InvalidFlagValue(ctx, "dns-server", opts.DNSServer, "")
+		}
+		return errors.Wrap(err, "error configuring the network")
+	}
+	return nil
+}
+
+// isHelp returns true if the given arguments show the help of step, in that
+// case the plugins are asked for their usage. The help is shown without
+// arguments, or with an explicit help command or flag before any command;
+// other global flags like --version or --debug do not run the plugins.
+func isHelp(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "help", "h", "--help", "-h":
+			return true
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return false
+		}
 	}
+	return len(args) == 0
 }
 
 func panicHandler() {
@@ -105,9 +359,14 @@ func panicHandler() {
 			panic(r)
 		} else {
 			fmt.Fprintln(os.Stderr, "Something unexpected happened.")
-			fmt.Fprintln(os.Stderr, "If you want to help us debug the problem, please run:")
-			fmt.Fprintf(os.Stderr, "STEPDEBUG=1 %s\n", strings.Join(os.Args, " "))
-			fmt.Fprintln(os.Stderr, "and send the output to info@smallstep.com")
+			if filename, err := writeCrashReport(r, debug.Stack()); err == nil {
+				fmt.Fprintf(os.Stderr, "A crash report has been written to %s.\n", filename)
+				fmt.Fprintln(os.Stderr, "If you want to help us debug the problem, please review it and send it to info@smallstep.com")
+			} else {
+				fmt.Fprintln(os.Stderr, "If you want to help us debug the problem, please run:")
+				fmt.Fprintf(os.Stderr, "STEPDEBUG=1 %s\n", strings.Join(os.Args, " "))
+				fmt.Fprintln(os.Stderr, "and send the output to info@smallstep.com")
+			}
 			os.Exit(2)
 		}
 	}
@@ -0,0 +1,29 @@
+package acme
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	cmd := cli.Command{
+		Name:      "acme",
+		Usage:     "tools to work with the ACME protocol",
+		UsageText: "step acme <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step acme** command group provides tools to work with the ACME protocol
+(RFC 8555), the protocol used by Let's Encrypt and the ACME provisioner of
+step-ca.
+
+## EXAMPLES
+
+Start an ACME server to test an ACME client:
+'''
+$ step acme test-server --root root_ca.crt
+'''`,
+		Subcommands: cli.Commands{
+			testServerCommand(),
+		},
+	}
+
+	command.Register(cmd)
+}
@@ -0,0 +1,1018 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/randutil"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/jose"
+)
+
+// Status of the ACME objects.
+const (
+	statusPending     = "pending"
+	statusProcessing  = "processing"
+	statusReady       = "ready"
+	statusValid       = "valid"
+	statusInvalid     = "invalid"
+	statusDeactivated = "deactivated"
+)
+
+// Challenge types supported by the test server.
+const (
+	challengeHTTP01    = "http-01"
+	challengeDNS01     = "dns-01"
+	challengeTLSALPN01 = "tls-alpn-01"
+)
+
+// acmeTLS1Protocol is the ALPN protocol used in the tls-alpn-01 challenge.
+const acmeTLS1Protocol = "acme-tls/1"
+
+// idPeAcmeIdentifier is the OID of the acmeIdentifier extension used in the
+// tls-alpn-01 challenge.
+var idPeAcmeIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+// objectLifetime is the time that pending orders and authorizations are valid.
+const objectLifetime = time.Hour
+
+// acmeError is an ACME problem document as defined in RFC 8555, section 6.7.
+type acmeError struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func (e *acmeError) Error() string {
+	return e.Detail
+}
+
+func newACMEError(status int, typ, format string, args ...interface{}) *acmeError {
+	return &acmeError{
+		Type:   "urn:ietf:params:acme:error:" + typ,
+		Detail: fmt.Sprintf(format, args...),
+		Status: status,
+	}
+}
+
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type account struct {
+	ID         string           `json:"-"`
+	Key        *jose.JSONWebKey `json:"key"`
+	Thumbprint string           `json:"-"`
+	Status     string           `json:"status"`
+	Contact    []string         `json:"contact,omitempty"`
+	Orders     string           `json:"orders"`
+}
+
+type order struct {
+	ID             string       `json:"-"`
+	AccountID      string       `json:"-"`
+	Status         string       `json:"status"`
+	Expires        time.Time    `json:"expires"`
+	Identifiers    []identifier `json:"identifiers"`
+	NotBefore      *time.Time   `json:"notBefore,omitempty"`
+	NotAfter       *time.Time   `json:"notAfter,omitempty"`
+	Error          *acmeError   `json:"error,omitempty"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate,omitempty"`
+	authzIDs       []string
+	certID         string
+}
+
+type authorization struct {
+	ID         string       `json:"-"`
+	AccountID  string       `json:"-"`
+	Identifier identifier   `json:"identifier"`
+	Status     string       `json:"status"`
+	Expires    time.Time    `json:"expires"`
+	Challenges []*challenge `json:"challenges"`
+	Wildcard   bool         `json:"wildcard,omitempty"`
+}
+
+type challenge struct {
+	ID        string     `json:"-"`
+	Type      string     `json:"type"`
+	URL       string     `json:"url"`
+	Status    string     `json:"status"`
+	Token     string     `json:"token"`
+	Validated *time.Time `json:"validated,omitempty"`
+	Error     *acmeError `json:"error,omitempty"`
+	authz     *authorization
+}
+
+type issuedCertificate struct {
+	AccountID string
+	Chain     []*x509.Certificate
+	Revoked   bool
+}
+
+// serverOptions are the options of the ACME test server.
+type serverOptions struct {
+	// SkipValidation marks all the challenges as valid without validating
+	// them.
+	SkipValidation bool
+	// HTTPPort is the port used to validate http-01 challenges.
+	HTTPPort string
+	// TLSPort is the port used to validate tls-alpn-01 challenges.
+	TLSPort string
+	// DNSServer is the address of the DNS server used to validate dns-01
+	// challenges, the system resolver is used if empty.
+	DNSServer string
+	// CertDuration is the validity of the issued certificates.
+	CertDuration time.Duration
+	// Logger logs the validation of challenges and issued certificates.
+	Logger *log.Logger
+}
+
+// server is a minimal in-memory implementation of an ACME server (RFC 8555)
+// meant to test ACME clients and challenge solvers. All the state is lost when
+// the server stops.
+type server struct {
+	opts         serverOptions
+	root         *x509.Certificate
+	intermediate *x509.Certificate
+	signer       crypto.PrivateKey
+	resolver     *net.Resolver
+	httpClient   *http.Client
+
+	mu             sync.Mutex
+	nonces         map[string]bool
+	accounts       map[string]*account
+	orders         map[string]*order
+	authorizations map[string]*authorization
+	challenges     map[string]*challenge
+	certificates   map[string]*issuedCertificate
+}
+
+// newServer creates a new ACME test server with an ephemeral root and
+// intermediate certificate.
+func newServer(opts serverOptions) (*server, error) {
+	rootProfile, err := x509util.NewRootProfile("Step ACME Test Server Root CA")
+	if err != nil {
+		return nil, err
+	}
+	root, err := createCertificate(rootProfile)
+	if err != nil {
+		return nil, err
+	}
+	intProfile, err := x509util.NewIntermediateProfile("Step ACME Test Server Intermediate CA",
+		root, rootProfile.SubjectPrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	intermediate, err := createCertificate(intProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.HTTPPort == "" {
+		opts.HTTPPort = "80"
+	}
+	if opts.TLSPort == "" {
+		opts.TLSPort = "443"
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	resolver := net.DefaultResolver
+	if opts.DNSServer != "" {
+		addr := opts.DNSServer
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+	}
+
+	return &server{
+		opts:         opts,
+		root:         root,
+		intermediate: intermediate,
+		signer:       intProfile.SubjectPrivateKey(),
+		resolver:     resolver,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		nonces:         make(map[string]bool),
+		accounts:       make(map[string]*account),
+		orders:         make(map[string]*order),
+		authorizations: make(map[string]*authorization),
+		challenges:     make(map[string]*challenge),
+		certificates:   make(map[string]*issuedCertificate),
+	}, nil
+}
+
+func createCertificate(p x509util.Profile) (*x509.Certificate, error) {
+	b, err := p.CreateCertificate()
+	if err != nil {
+		return nil, err
+	}
+	crt, err := x509.ParseCertificate(b)
+	return crt, errors.Wrap(err, "error parsing certificate")
+}
+
+// Root returns the root certificate of the test server.
+func (s *server) Root() *x509.Certificate {
+	return s.root
+}
+
+// ServerCertificate returns a TLS certificate for the given hosts signed by
+// the intermediate of the test server.
+func (s *server) ServerCertificate(hosts []string) (*tls.Certificate, error) {
+	p, err := x509util.NewLeafProfile(hosts[0], s.intermediate, s.signer,
+		x509util.WithHosts(strings.Join(hosts, ",")),
+		x509util.WithNotBeforeAfterDuration(time.Time{}, time.Time{}, 10*365*24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	crt, err := createCertificate(p)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{
+		Certificate: [][]byte{crt.Raw, s.intermediate.Raw},
+		PrivateKey:  p.SubjectPrivateKey(),
+		Leaf:        crt,
+	}, nil
+}
+
+// Handler returns the http.Handler with the ACME endpoints.
+func (s *server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/directory", s.directory)
+	mux.HandleFunc("/new-nonce", s.newNonce)
+	mux.HandleFunc("/new-account", s.post(s.newAccount))
+	mux.HandleFunc("/new-order", s.post(s.newOrder))
+	mux.HandleFunc("/revoke-cert", s.post(s.revokeCert))
+	mux.HandleFunc("/account/", s.post(s.getAccount))
+	mux.HandleFunc("/order/", s.post(s.getOrder))
+	mux.HandleFunc("/authz/", s.post(s.getAuthorization))
+	mux.HandleFunc("/chall/", s.post(s.postChallenge))
+	mux.HandleFunc("/finalize/", s.post(s.finalizeOrder))
+	mux.HandleFunc("/cert/", s.post(s.getCertificate))
+	mux.HandleFunc("/roots/0", s.getRoot)
+	return mux
+}
+
+func baseURL(r *http.Request) string {
+	return "https://" + r.Host
+}
+
+func (s *server) directory(w http.ResponseWriter, r *http.Request) {
+	base := baseURL(r)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"newNonce":   base + "/new-nonce",
+		"newAccount": base + "/new-account",
+		"newOrder":   base + "/new-order",
+		"revokeCert": base + "/revoke-cert",
+		"meta": map[string]interface{}{
+			"externalAccountRequired": false,
+		},
+	})
+}
+
+func (s *server) newNonce(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", s.nonce())
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodGet {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *server) getRoot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: s.root.Raw})
+}
+
+func (s *server) nonce() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := newID()
+	s.nonces[n] = true
+	return n
+}
+
+func (s *server) useNonce(n string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nonces[n] {
+		delete(s.nonces, n)
+		return true
+	}
+	return false
+}
+
+func newID() string {
+	id, err := randutil.Alphanumeric(22)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// request is an authenticated ACME request.
+type request struct {
+	base    string
+	id      string
+	payload []byte
+	jwk     *jose.JSONWebKey
+	account *account
+}
+
+// postAsGet returns true if the request is a POST-as-GET request.
+func (req *request) postAsGet() bool {
+	return len(req.payload) == 0
+}
+
+type postHandler func(w http.ResponseWriter, req *request) error
+
+// post returns an http.HandlerFunc that verifies the JWS in the body of the
+// request and calls the given handler.
+func (s *server) post(fn postHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", s.nonce())
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Link", fmt.Sprintf(`<%s/directory>;rel="index"`, baseURL(r)))
+		if r.Method != http.MethodPost {
+			writeError(w, newACMEError(http.StatusMethodNotAllowed, "malformed", "method %s not allowed", r.Method))
+			return
+		}
+		req, err := s.parseRequest(r)
+		if err == nil {
+			err = fn(w, req)
+		}
+		if err != nil {
+			writeError(w, err)
+		}
+	}
+}
+
+func (s *server) parseRequest(r *http.Request) (*request, error) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/jose+json" {
+		return nil, newACMEError(http.StatusUnsupportedMediaType, "malformed", "unsupported content type %q", ct)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "error reading body: %v", err)
+	}
+	jws, err := jose.ParseJWS(string(body))
+	if err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "error parsing JWS: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "JWS must have one signature")
+	}
+	hdr := jws.Signatures[0].Protected
+	switch hdr.Algorithm {
+	case "", "none", jose.HS256, jose.HS384, jose.HS512:
+		return nil, newACMEError(http.StatusBadRequest, "badSignatureAlgorithm", "unsupported algorithm %q", hdr.Algorithm)
+	}
+	if !s.useNonce(hdr.Nonce) {
+		return nil, newACMEError(http.StatusBadRequest, "badNonce", "invalid anti-replay nonce %q", hdr.Nonce)
+	}
+
+	req := &request{
+		base: baseURL(r),
+	}
+	// The id is the path after the endpoint, e.g. /order/<id>.
+	if parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2); len(parts) == 2 {
+		req.id = parts[1]
+	}
+	if u, _ := hdr.ExtraHeaders["url"].(string); u != req.base+r.URL.Path {
+		return nil, newACMEError(http.StatusUnauthorized, "unauthorized", "invalid url %q in the JWS header", u)
+	}
+
+	switch {
+	case hdr.JSONWebKey != nil && hdr.KeyID != "":
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "JWS header cannot have both jwk and kid")
+	case hdr.JSONWebKey != nil:
+		if r.URL.Path != "/new-account" && r.URL.Path != "/revoke-cert" {
+			return nil, newACMEError(http.StatusBadRequest, "malformed", "JWS header must use kid")
+		}
+		if !hdr.JSONWebKey.Valid() || !hdr.JSONWebKey.IsPublic() {
+			return nil, newACMEError(http.StatusBadRequest, "badPublicKey", "invalid jwk in the JWS header")
+		}
+		req.jwk = hdr.JSONWebKey
+	case hdr.KeyID != "":
+		if r.URL.Path == "/new-account" {
+			return nil, newACMEError(http.StatusBadRequest, "malformed", "JWS header must use jwk")
+		}
+		prefix := req.base + "/account/"
+		s.mu.Lock()
+		acc, ok := s.accounts[strings.TrimPrefix(hdr.KeyID, prefix)]
+		s.mu.Unlock()
+		if !strings.HasPrefix(hdr.KeyID, prefix) || !ok {
+			return nil, newACMEError(http.StatusBadRequest, "accountDoesNotExist", "account %q not found", hdr.KeyID)
+		}
+		if acc.Status != statusValid {
+			return nil, newACMEError(http.StatusUnauthorized, "unauthorized", "account is %s", acc.Status)
+		}
+		req.jwk = acc.Key
+		req.account = acc
+	default:
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "JWS header must have jwk or kid")
+	}
+
+	if req.payload, err = jws.Verify(req.jwk); err != nil {
+		return nil, newACMEError(http.StatusBadRequest, "malformed", "error verifying JWS signature: %v", err)
+	}
+	return req, nil
+}
+
+func (s *server) accountURL(req *request, acc *account) string {
+	return req.base + "/account/" + acc.ID
+}
+
+func (s *server) newAccount(w http.ResponseWriter, req *request) error {
+	var payload struct {
+		Contact            []string `json:"contact"`
+		OnlyReturnExisting bool     `json:"onlyReturnExisting"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return newACMEError(http.StatusBadRequest, "malformed", "error parsing payload: %v", err)
+	}
+	thumbprint, err := jose.Thumbprint(req.jwk)
+	if err != nil {
+		return newACMEError(http.StatusBadRequest, "badPublicKey", "%v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, acc := range s.accounts {
+		if acc.Thumbprint == thumbprint {
+			w.Header().Set("Location", s.accountURL(req, acc))
+			writeJSON(w, http.StatusOK, acc)
+			return nil
+		}
+	}
+	if payload.OnlyReturnExisting {
+		return newACMEError(http.StatusBadRequest, "accountDoesNotExist", "account does not exist")
+	}
+
+	acc := &account{
+		ID:         newID(),
+		Key:        req.jwk,
+		Thumbprint: thumbprint,
+		Status:     statusValid,
+		Contact:    payload.Contact,
+	}
+	acc.Orders = s.accountURL(req, acc) + "/orders"
+	s.accounts[acc.ID] = acc
+	w.Header().Set("Location", s.accountURL(req, acc))
+	writeJSON(w, http.StatusCreated, acc)
+	return nil
+}
+
+func (s *server) getAccount(w http.ResponseWriter, req *request) error {
+	id := strings.TrimSuffix(req.id, "/orders")
+	if req.account == nil || req.account.ID != id {
+		return newACMEError(http.StatusUnauthorized, "unauthorized", "account does not match the JWS key")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.HasSuffix(req.id, "/orders") {
+		urls := []string{}
+		for _, o := range s.orders {
+			if o.AccountID == id {
+				urls = append(urls, req.base+"/order/"+o.ID)
+			}
+		}
+		sort.Strings(urls)
+		writeJSON(w, http.StatusOK, map[string][]string{"orders": urls})
+		return nil
+	}
+
+	if !req.postAsGet() {
+		var payload struct {
+			Status  string   `json:"status"`
+			Contact []string `json:"contact"`
+		}
+		if err := json.Unmarshal(req.payload, &payload); err != nil {
+			return newACMEError(http.StatusBadRequest, "malformed", "error parsing payload: %v", err)
+		}
+		switch payload.Status {
+		case "":
+		case statusDeactivated:
+			req.account.Status = statusDeactivated
+		default:
+			return newACMEError(http.StatusBadRequest, "malformed", "invalid status %q", payload.Status)
+		}
+		if payload.Contact != nil {
+			req.account.Contact = payload.Contact
+		}
+	}
+	writeJSON(w, http.StatusOK, req.account)
+	return nil
+}
+
+func (s *server) newOrder(w http.ResponseWriter, req *request) error {
+	var payload struct {
+		Identifiers []identifier `json:"identifiers"`
+		NotBefore   *time.Time   `json:"notBefore"`
+		NotAfter    *time.Time   `json:"notAfter"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return newACMEError(http.StatusBadRequest, "malformed", "error parsing payload: %v", err)
+	}
+	if len(payload.Identifiers) == 0 {
+		return newACMEError(http.StatusBadRequest, "malformed", "order must have at least one identifier")
+	}
+	for _, id := range payload.Identifiers {
+		switch id.Type {
+		case "dns":
+			name := strings.TrimPrefix(id.Value, "*.")
+			if name == "" || strings.Contains(name, "*") || net.ParseIP(name) != nil {
+				return newACMEError(http.StatusBadRequest, "rejectedIdentifier", "invalid dns identifier %q", id.Value)
+			}
+		case "ip":
+			if net.ParseIP(id.Value) == nil {
+				return newACMEError(http.StatusBadRequest, "rejectedIdentifier", "invalid ip identifier %q", id.Value)
+			}
+		default:
+			return newACMEError(http.StatusBadRequest, "unsupportedIdentifier", "unsupported identifier type %q", id.Type)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires := time.Now().Add(objectLifetime).UTC().Truncate(time.Second)
+	o := &order{
+		ID:          newID(),
+		AccountID:   req.account.ID,
+		Status:      statusPending,
+		Expires:     expires,
+		Identifiers: payload.Identifiers,
+		NotBefore:   payload.NotBefore,
+		NotAfter:    payload.NotAfter,
+	}
+	o.Finalize = req.base + "/finalize/" + o.ID
+	for _, id := range payload.Identifiers {
+		az := &authorization{
+			ID:         newID(),
+			AccountID:  req.account.ID,
+			Identifier: id,
+			Status:     statusPending,
+			Expires:    expires,
+		}
+		types := []string{challengeHTTP01, challengeDNS01, challengeTLSALPN01}
+		switch {
+		case strings.HasPrefix(id.Value, "*."):
+			// Wildcards can only be validated with dns-01.
+			az.Identifier.Value = id.Value[2:]
+			az.Wildcard = true
+			types = []string{challengeDNS01}
+		case id.Type == "ip":
+			types = []string{challengeHTTP01, challengeTLSALPN01}
+		}
+		token := newToken()
+		for _, typ := range types {
+			ch := &challenge{
+				ID:     newID(),
+				Type:   typ,
+				Status: statusPending,
+				Token:  token,
+				authz:  az,
+			}
+			ch.URL = req.base + "/chall/" + ch.ID
+			az.Challenges = append(az.Challenges, ch)
+			s.challenges[ch.ID] = ch
+		}
+		s.authorizations[az.ID] = az
+		o.authzIDs = append(o.authzIDs, az.ID)
+		o.Authorizations = append(o.Authorizations, req.base+"/authz/"+az.ID)
+	}
+	s.orders[o.ID] = o
+
+	w.Header().Set("Location", req.base+"/order/"+o.ID)
+	writeJSON(w, http.StatusCreated, o)
+	return nil
+}
+
+func newToken() string {
+	b, err := randutil.Salt(32)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// updateOrder updates the status of an order using the status of its
+// authorizations. It must be called with the lock held.
+func (s *server) updateOrder(o *order) {
+	if o.Status != statusPending {
+		return
+	}
+	if time.Now().After(o.Expires) {
+		o.Status = statusInvalid
+		return
+	}
+	ready := true
+	for _, id := range o.authzIDs {
+		switch s.authorizations[id].Status {
+		case statusValid:
+		case statusPending:
+			ready = false
+		default:
+			o.Status = statusInvalid
+			return
+		}
+	}
+	if ready {
+		o.Status = statusReady
+	}
+}
+
+func (s *server) getOrder(w http.ResponseWriter, req *request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[req.id]
+	if !ok || o.AccountID != req.account.ID {
+		return newACMEError(http.StatusNotFound, "malformed", "order %q not found", req.id)
+	}
+	s.updateOrder(o)
+	writeJSON(w, http.StatusOK, o)
+	return nil
+}
+
+func (s *server) getAuthorization(w http.ResponseWriter, req *request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	az, ok := s.authorizations[req.id]
+	if !ok || az.AccountID != req.account.ID {
+		return newACMEError(http.StatusNotFound, "malformed", "authorization %q not found", req.id)
+	}
+	if !req.postAsGet() {
+		var payload struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(req.payload, &payload); err != nil {
+			return newACMEError(http.StatusBadRequest, "malformed", "error parsing payload: %v", err)
+		}
+		if payload.Status != statusDeactivated {
+			return newACMEError(http.StatusBadRequest, "malformed", "invalid status %q", payload.Status)
+		}
+		az.Status = statusDeactivated
+	}
+	if az.Status == statusPending && time.Now().After(az.Expires) {
+		az.Status = statusInvalid
+	}
+	writeJSON(w, http.StatusOK, az)
+	return nil
+}
+
+func (s *server) postChallenge(w http.ResponseWriter, req *request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.challenges[req.id]
+	if !ok || ch.authz.AccountID != req.account.ID {
+		return newACMEError(http.StatusNotFound, "malformed", "challenge %q not found", req.id)
+	}
+	// An empty object starts the validation, POST-as-GET returns the
+	// challenge.
+	if !req.postAsGet() && ch.Status == statusPending && ch.authz.Status == statusPending {
+		ch.Status = statusProcessing
+		go s.validate(ch, req.account.Thumbprint)
+	}
+	w.Header().Add("Link", fmt.Sprintf(`<%s/authz/%s>;rel="up"`, req.base, ch.authz.ID))
+	writeJSON(w, http.StatusOK, ch)
+	return nil
+}
+
+// validate validates the challenge and updates its status and the status of
+// the authorization.
+func (s *server) validate(ch *challenge, thumbprint string) {
+	s.mu.Lock()
+	typ, token, id := ch.Type, ch.Token, ch.authz.Identifier
+	s.mu.Unlock()
+
+	keyAuth := token + "." + thumbprint
+	var err error
+	if !s.opts.SkipValidation {
+		switch typ {
+		case challengeHTTP01:
+			err = s.validateHTTP01(id, token, keyAuth)
+		case challengeDNS01:
+			err = s.validateDNS01(id, keyAuth)
+		case challengeTLSALPN01:
+			err = s.validateTLSALPN01(id, keyAuth)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.opts.Logger.Printf("%s challenge for %s %s failed: %v", typ, id.Type, id.Value, err)
+		ch.Status = statusInvalid
+		ch.Error = newACMEError(http.StatusForbidden, "unauthorized", "%v", err)
+		ch.authz.Status = statusInvalid
+		return
+	}
+	s.opts.Logger.Printf("%s challenge for %s %s is valid", typ, id.Type, id.Value)
+	now := time.Now().UTC().Truncate(time.Second)
+	ch.Status = statusValid
+	ch.Validated = &now
+	ch.authz.Status = statusValid
+}
+
+func (s *server) validateHTTP01(id identifier, token, keyAuth string) error {
+	u := "http://" + net.JoinHostPort(id.Value, s.opts.HTTPPort) + "/.well-known/acme-challenge/" + token
+	resp, err := s.httpClient.Get(u)
+	if err != nil {
+		return errors.Wrapf(err, "error fetching %s", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("error fetching %s: unexpected status %d", u, resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if err != nil {
+		return errors.Wrapf(err, "error reading %s", u)
+	}
+	if got := strings.TrimSpace(string(b)); got != keyAuth {
+		return errors.Errorf("key authorization from %s does not match: got %q, want %q", u, got, keyAuth)
+	}
+	return nil
+}
+
+func (s *server) validateDNS01(id identifier, keyAuth string) error {
+	name := "_acme-challenge." + id.Value
+	records, err := s.resolver.LookupTXT(context.Background(), name)
+	if err != nil {
+		return errors.Wrapf(err, "error looking up TXT records for %s", name)
+	}
+	sum := sha256.Sum256([]byte(keyAuth))
+	want := base64.RawURLEncoding.EncodeToString(sum[:])
+	for _, r := range records {
+		if r == want {
+			return nil
+		}
+	}
+	return errors.Errorf("TXT records for %s do not contain %q", name, want)
+}
+
+func (s *server) validateTLSALPN01(id identifier, keyAuth string) error {
+	addr := net.JoinHostPort(id.Value, s.opts.TLSPort)
+	config := &tls.Config{
+		NextProtos:         []string{acmeTLS1Protocol},
+		InsecureSkipVerify: true, // #nosec: the certificate is self-signed
+	}
+	if id.Type == "dns" {
+		config.ServerName = id.Value
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, config)
+	if err != nil {
+		return errors.Wrapf(err, "error connecting to %s", addr)
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	if state.NegotiatedProtocol != acmeTLS1Protocol {
+		return errors.Errorf("%s did not negotiate the %s protocol", addr, acmeTLS1Protocol)
+	}
+	if len(state.PeerCertificates) == 0 {
+		return errors.Errorf("%s did not present a certificate", addr)
+	}
+	crt := state.PeerCertificates[0]
+	if err := crt.VerifyHostname(id.Value); err != nil {
+		return errors.Wrapf(err, "invalid certificate from %s", addr)
+	}
+	sum := sha256.Sum256([]byte(keyAuth))
+	for _, ext := range crt.Extensions {
+		if !ext.Id.Equal(idPeAcmeIdentifier) {
+			continue
+		}
+		if !ext.Critical {
+			return errors.Errorf("acmeIdentifier extension from %s is not critical", addr)
+		}
+		var value []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &value); err != nil || len(rest) > 0 {
+			return errors.Errorf("error parsing acmeIdentifier extension from %s", addr)
+		}
+		if string(value) != string(sum[:]) {
+			return errors.Errorf("acmeIdentifier extension from %s does not match the key authorization", addr)
+		}
+		return nil
+	}
+	return errors.Errorf("certificate from %s does not have the acmeIdentifier extension", addr)
+}
+
+func (s *server) finalizeOrder(w http.ResponseWriter, req *request) error {
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return newACMEError(http.StatusBadRequest, "malformed", "error parsing payload: %v", err)
+	}
+	der, err := base64.RawURLEncoding.DecodeString(payload.CSR)
+	if err != nil {
+		return newACMEError(http.StatusBadRequest, "badCSR", "error decoding csr: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return newACMEError(http.StatusBadRequest, "badCSR", "error parsing csr: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return newACMEError(http.StatusBadRequest, "badCSR", "invalid csr signature: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[req.id]
+	if !ok || o.AccountID != req.account.ID {
+		return newACMEError(http.StatusNotFound, "malformed", "order %q not found", req.id)
+	}
+	s.updateOrder(o)
+	if o.Status != statusReady {
+		return newACMEError(http.StatusForbidden, "orderNotReady", "order is %s", o.Status)
+	}
+	if err := checkCSRIdentifiers(csr, o.Identifiers); err != nil {
+		return err
+	}
+
+	nb, na := time.Time{}, time.Time{}
+	if o.NotBefore != nil {
+		nb = *o.NotBefore
+	}
+	if o.NotAfter != nil {
+		na = *o.NotAfter
+	}
+	p, err := x509util.NewLeafProfileWithCSR(csr, s.intermediate, s.signer,
+		x509util.WithNotBeforeAfterDuration(nb, na, s.opts.CertDuration))
+	if err != nil {
+		return newACMEError(http.StatusInternalServerError, "serverInternal", "error creating certificate: %v", err)
+	}
+	crt, err := createCertificate(p)
+	if err != nil {
+		return newACMEError(http.StatusInternalServerError, "serverInternal", "error creating certificate: %v", err)
+	}
+
+	o.certID = newID()
+	o.Status = statusValid
+	o.Certificate = req.base + "/cert/" + o.certID
+	s.certificates[o.certID] = &issuedCertificate{
+		AccountID: req.account.ID,
+		Chain:     []*x509.Certificate{crt, s.intermediate},
+	}
+	s.opts.Logger.Printf("issued certificate %s for %s", crt.SerialNumber, strings.Join(identifierValues(o.Identifiers), ", "))
+
+	w.Header().Set("Location", req.base+"/order/"+o.ID)
+	writeJSON(w, http.StatusOK, o)
+	return nil
+}
+
+func identifierValues(ids []identifier) []string {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.Value
+	}
+	return values
+}
+
+// checkCSRIdentifiers checks that the names in the CSR are the identifiers of
+// the order.
+func checkCSRIdentifiers(csr *x509.CertificateRequest, ids []identifier) error {
+	want := make(map[string]bool)
+	for _, id := range ids {
+		if id.Type == "ip" {
+			want["ip:"+net.ParseIP(id.Value).String()] = true
+		} else {
+			want["dns:"+strings.ToLower(id.Value)] = true
+		}
+	}
+	got := make(map[string]bool)
+	for _, name := range csr.DNSNames {
+		got["dns:"+strings.ToLower(name)] = true
+	}
+	for _, ip := range csr.IPAddresses {
+		got["ip:"+ip.String()] = true
+	}
+	if cn := csr.Subject.CommonName; cn != "" {
+		if ip := net.ParseIP(cn); ip != nil {
+			got["ip:"+ip.String()] = true
+		} else {
+			got["dns:"+strings.ToLower(cn)] = true
+		}
+	}
+	if len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return newACMEError(http.StatusBadRequest, "badCSR", "csr cannot have email or URI names")
+	}
+	if len(got) != len(want) {
+		return newACMEError(http.StatusBadRequest, "badCSR", "csr names do not match the order identifiers")
+	}
+	for name := range got {
+		if !want[name] {
+			return newACMEError(http.StatusBadRequest, "badCSR", "csr name %q is not an order identifier", name[strings.Index(name, ":")+1:])
+		}
+	}
+	return nil
+}
+
+func (s *server) getCertificate(w http.ResponseWriter, req *request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.certificates[req.id]
+	if !ok || c.AccountID != req.account.ID {
+		return newACMEError(http.StatusNotFound, "malformed", "certificate %q not found", req.id)
+	}
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.WriteHeader(http.StatusOK)
+	for _, crt := range c.Chain {
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+	}
+	return nil
+}
+
+func (s *server) revokeCert(w http.ResponseWriter, req *request) error {
+	var payload struct {
+		Certificate string `json:"certificate"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return newACMEError(http.StatusBadRequest, "malformed", "error parsing payload: %v", err)
+	}
+	der, err := base64.RawURLEncoding.DecodeString(payload.Certificate)
+	if err != nil {
+		return newACMEError(http.StatusBadRequest, "malformed", "error decoding certificate: %v", err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		return newACMEError(http.StatusBadRequest, "malformed", "error parsing certificate: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.certificates {
+		if c.Chain[0].SerialNumber.Cmp(crt.SerialNumber) != 0 {
+			continue
+		}
+		// The certificate can be revoked by the account that requested it or
+		// using the certificate key.
+		authorized := req.account != nil && req.account.ID == c.AccountID
+		if req.account == nil {
+			if thumbprint, err := jose.Thumbprint(req.jwk); err == nil {
+				crtKey := &jose.JSONWebKey{Key: c.Chain[0].PublicKey}
+				crtThumbprint, err := jose.Thumbprint(crtKey)
+				authorized = err == nil && crtThumbprint == thumbprint
+			}
+		}
+		if !authorized {
+			return newACMEError(http.StatusForbidden, "unauthorized", "not authorized to revoke the certificate")
+		}
+		if c.Revoked {
+			return newACMEError(http.StatusBadRequest, "alreadyRevoked", "certificate is already revoked")
+		}
+		c.Revoked = true
+		s.opts.Logger.Printf("revoked certificate %s", crt.SerialNumber)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	return newACMEError(http.StatusNotFound, "malformed", "certificate not found")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*acmeError)
+	if !ok {
+		e = newACMEError(http.StatusInternalServerError, "serverInternal", "%v", err)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(e)
+}
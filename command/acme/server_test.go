@@ -0,0 +1,402 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/cli/jose"
+)
+
+// testClient is a minimal ACME client used to test the server.
+type testClient struct {
+	t      *testing.T
+	client *http.Client
+	dir    map[string]interface{}
+	key    *ecdsa.PrivateKey
+	kid    string
+}
+
+func newTestClient(t *testing.T, srv *server) (*testClient, *httptest.Server) {
+	crt, err := srv.ServerCertificate([]string{"127.0.0.1"})
+	assert.FatalError(t, err)
+	ts := httptest.NewUnstartedServer(srv.Handler())
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{*crt}}
+	ts.StartTLS()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Root())
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	c := &testClient{
+		t: t,
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+		key: key,
+	}
+	resp, err := c.client.Get(ts.URL + "/directory")
+	assert.FatalError(t, err)
+	defer resp.Body.Close()
+	assert.FatalError(t, json.NewDecoder(resp.Body).Decode(&c.dir))
+	return c, ts
+}
+
+func (c *testClient) url(name string) string {
+	return c.dir[name].(string)
+}
+
+func (c *testClient) nonce() string {
+	resp, err := c.client.Head(c.url("newNonce"))
+	assert.FatalError(c.t, err)
+	resp.Body.Close()
+	assert.Equals(c.t, http.StatusOK, resp.StatusCode)
+	return resp.Header.Get("Replay-Nonce")
+}
+
+func (c *testClient) sign(u, nonce string, payload interface{}) []byte {
+	var b []byte
+	if payload != nil {
+		var err error
+		b, err = json.Marshal(payload)
+		assert.FatalError(c.t, err)
+	}
+	opts := new(jose.SignerOptions).WithHeader("nonce", nonce).WithHeader("url", u)
+	key := jose.JSONWebKey{Key: c.key}
+	if c.kid == "" {
+		opts.EmbedJWK = true
+	} else {
+		key.KeyID = c.kid
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, opts)
+	assert.FatalError(c.t, err)
+	jws, err := signer.Sign(b)
+	assert.FatalError(c.t, err)
+	return []byte(jws.FullSerialize())
+}
+
+func (c *testClient) post(u string, payload interface{}) *http.Response {
+	resp, err := c.client.Post(u, "application/jose+json", bytes.NewReader(c.sign(u, c.nonce(), payload)))
+	assert.FatalError(c.t, err)
+	return resp
+}
+
+func (c *testClient) postJSON(u string, payload interface{}, status int, v interface{}) http.Header {
+	resp := c.post(u, payload)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.FatalError(c.t, err)
+	if resp.StatusCode != status {
+		c.t.Fatalf("POST %s: unexpected status %d, want %d: %s", u, resp.StatusCode, status, b)
+	}
+	if v != nil {
+		assert.FatalError(c.t, json.Unmarshal(b, v))
+	}
+	return resp.Header
+}
+
+func (c *testClient) newAccount() {
+	hdr := c.postJSON(c.url("newAccount"), map[string]interface{}{
+		"termsOfServiceAgreed": true,
+		"contact":              []string{"mailto:jane@example.com"},
+	}, http.StatusCreated, nil)
+	c.kid = hdr.Get("Location")
+	assert.True(c.t, c.kid != "")
+}
+
+// issue requests a certificate for the given identifiers using the given
+// challenge type, the solve function is called before responding to each
+// challenge.
+func (c *testClient) issue(ids []identifier, typ string, solve func(token, keyAuth string)) (*order, []*x509.Certificate) {
+	var o order
+	hdr := c.postJSON(c.url("newOrder"), map[string]interface{}{
+		"identifiers": ids,
+	}, http.StatusCreated, &o)
+	orderURL := hdr.Get("Location")
+	assert.Equals(c.t, statusPending, o.Status)
+	assert.Len(c.t, len(ids), o.Authorizations)
+
+	jwk := jose.JSONWebKey{Key: c.key.Public()}
+	thumbprint, err := jose.Thumbprint(&jwk)
+	assert.FatalError(c.t, err)
+
+	for _, u := range o.Authorizations {
+		var az authorization
+		c.postJSON(u, nil, http.StatusOK, &az)
+		var ch *challenge
+		for _, v := range az.Challenges {
+			if v.Type == typ {
+				ch = v
+			}
+		}
+		if ch == nil {
+			c.t.Fatalf("authorization does not have a %s challenge", typ)
+		}
+		if solve != nil {
+			solve(ch.Token, ch.Token+"."+thumbprint)
+		}
+		c.postJSON(ch.URL, struct{}{}, http.StatusOK, nil)
+		for i := 0; i < 50 && az.Status == statusPending; i++ {
+			time.Sleep(20 * time.Millisecond)
+			c.postJSON(u, nil, http.StatusOK, &az)
+		}
+		if az.Status != statusValid {
+			return &o, nil
+		}
+	}
+
+	c.postJSON(orderURL, nil, http.StatusOK, &o)
+	if o.Status != statusReady {
+		return &o, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(c.t, err)
+	tmpl := &x509.CertificateRequest{Subject: pkix.Name{CommonName: ids[0].Value}}
+	for _, id := range ids {
+		if id.Type == "ip" {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, net.ParseIP(id.Value))
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, id.Value)
+		}
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	assert.FatalError(c.t, err)
+	c.postJSON(o.Finalize, map[string]string{
+		"csr": base64.RawURLEncoding.EncodeToString(csr),
+	}, http.StatusOK, &o)
+	assert.Equals(c.t, statusValid, o.Status)
+
+	resp := c.post(o.Certificate, nil)
+	defer resp.Body.Close()
+	assert.Equals(c.t, http.StatusOK, resp.StatusCode)
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.FatalError(c.t, err)
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		crt, err := x509.ParseCertificate(block.Bytes)
+		assert.FatalError(c.t, err)
+		chain = append(chain, crt)
+	}
+	return &o, chain
+}
+
+func TestServer_skipValidation(t *testing.T) {
+	srv, err := newServer(serverOptions{SkipValidation: true, CertDuration: time.Hour})
+	assert.FatalError(t, err)
+	c, ts := newTestClient(t, srv)
+	defer ts.Close()
+
+	c.newAccount()
+	kid := c.kid
+
+	// The same key returns the existing account.
+	c.kid = ""
+	hdr := c.postJSON(c.url("newAccount"), map[string]interface{}{
+		"onlyReturnExisting": true,
+	}, http.StatusOK, nil)
+	assert.Equals(t, kid, hdr.Get("Location"))
+	c.kid = kid
+
+	ids := []identifier{{Type: "dns", Value: "example.com"}, {Type: "ip", Value: "10.0.0.1"}}
+	o, chain := c.issue(ids, challengeHTTP01, nil)
+	assert.Equals(t, statusValid, o.Status)
+	assert.Len(t, 2, chain)
+	assert.Equals(t, []string{"example.com"}, chain[0].DNSNames)
+	assert.Equals(t, "10.0.0.1", chain[0].IPAddresses[0].String())
+	assert.True(t, chain[0].NotAfter.Sub(chain[0].NotBefore) <= time.Hour)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Root())
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(chain[1])
+	_, err = chain[0].Verify(x509.VerifyOptions{
+		DNSName:       "example.com",
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	assert.FatalError(t, err)
+
+	// Revoke the certificate
+	resp := c.post(c.url("revokeCert"), map[string]string{
+		"certificate": base64.RawURLEncoding.EncodeToString(chain[0].Raw),
+	})
+	resp.Body.Close()
+	assert.Equals(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_http01(t *testing.T) {
+	var tokens sync.Map
+	solver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyAuth, ok := tokens.Load(strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(keyAuth.(string)))
+	}))
+	defer solver.Close()
+	u, err := url.Parse(solver.URL)
+	assert.FatalError(t, err)
+
+	srv, err := newServer(serverOptions{HTTPPort: u.Port()})
+	assert.FatalError(t, err)
+	c, ts := newTestClient(t, srv)
+	defer ts.Close()
+	c.newAccount()
+
+	ids := []identifier{{Type: "ip", Value: "127.0.0.1"}}
+	o, chain := c.issue(ids, challengeHTTP01, func(token, keyAuth string) {
+		tokens.Store(token, keyAuth)
+	})
+	assert.Equals(t, statusValid, o.Status)
+	assert.Len(t, 2, chain)
+
+	// Challenges without a solver fail.
+	o, chain = c.issue(ids, challengeHTTP01, nil)
+	assert.Equals(t, statusPending, o.Status)
+	assert.Nil(t, chain)
+
+	var az authorization
+	c.postJSON(o.Authorizations[0], nil, http.StatusOK, &az)
+	assert.Equals(t, statusInvalid, az.Status)
+	c.postJSON(o.Authorizations[0][:strings.LastIndex(o.Authorizations[0], "/")+1]+"x", nil, http.StatusNotFound, nil)
+}
+
+func TestServer_errors(t *testing.T) {
+	srv, err := newServer(serverOptions{SkipValidation: true})
+	assert.FatalError(t, err)
+	c, ts := newTestClient(t, srv)
+	defer ts.Close()
+
+	type problem struct {
+		Type   string `json:"type"`
+		Status int    `json:"status"`
+	}
+	post := func(u string, body []byte) problem {
+		resp, err := c.client.Post(u, "application/jose+json", bytes.NewReader(body))
+		assert.FatalError(t, err)
+		defer resp.Body.Close()
+		assert.Equals(t, "application/problem+json", resp.Header.Get("Content-Type"))
+		var p problem
+		assert.FatalError(t, json.NewDecoder(resp.Body).Decode(&p))
+		assert.Equals(t, resp.StatusCode, p.Status)
+		return p
+	}
+
+	newAccount := c.url("newAccount")
+	p := post(newAccount, c.sign(newAccount, "bad-nonce", struct{}{}))
+	assert.Equals(t, "urn:ietf:params:acme:error:badNonce", p.Type)
+
+	nonce := c.nonce()
+	p = post(newAccount, c.sign(c.url("newOrder"), nonce, struct{}{}))
+	assert.Equals(t, "urn:ietf:params:acme:error:unauthorized", p.Type)
+
+	// Nonces cannot be reused.
+	p = post(newAccount, c.sign(newAccount, nonce, struct{}{}))
+	assert.Equals(t, "urn:ietf:params:acme:error:badNonce", p.Type)
+
+	p = post(newAccount, c.sign(newAccount, c.nonce(), map[string]bool{"onlyReturnExisting": true}))
+	assert.Equals(t, "urn:ietf:params:acme:error:accountDoesNotExist", p.Type)
+
+	newOrder := c.url("newOrder")
+	p = post(newOrder, c.sign(newOrder, c.nonce(), struct{}{}))
+	assert.Equals(t, "urn:ietf:params:acme:error:malformed", p.Type)
+
+	c.newAccount()
+	p = post(newOrder, c.sign(newOrder, c.nonce(), map[string]interface{}{
+		"identifiers": []identifier{{Type: "email", Value: "jane@example.com"}},
+	}))
+	assert.Equals(t, "urn:ietf:params:acme:error:unsupportedIdentifier", p.Type)
+
+	var o order
+	c.postJSON(newOrder, map[string]interface{}{
+		"identifiers": []identifier{{Type: "dns", Value: "example.com"}},
+	}, http.StatusCreated, &o)
+	p = post(o.Finalize, c.sign(o.Finalize, c.nonce(), map[string]string{"csr": "x"}))
+	assert.Equals(t, "urn:ietf:params:acme:error:badCSR", p.Type)
+}
+
+func TestServer_wildcard(t *testing.T) {
+	srv, err := newServer(serverOptions{SkipValidation: true})
+	assert.FatalError(t, err)
+	c, ts := newTestClient(t, srv)
+	defer ts.Close()
+	c.newAccount()
+
+	var o order
+	c.postJSON(c.url("newOrder"), map[string]interface{}{
+		"identifiers": []identifier{{Type: "dns", Value: "*.example.com"}},
+	}, http.StatusCreated, &o)
+	var az authorization
+	c.postJSON(o.Authorizations[0], nil, http.StatusOK, &az)
+	assert.True(t, az.Wildcard)
+	assert.Equals(t, "example.com", az.Identifier.Value)
+	assert.Len(t, 1, az.Challenges)
+	assert.Equals(t, challengeDNS01, az.Challenges[0].Type)
+}
+
+func Test_checkCSRIdentifiers(t *testing.T) {
+	ids := []identifier{{Type: "dns", Value: "example.com"}, {Type: "ip", Value: "127.0.0.1"}}
+	tests := []struct {
+		name    string
+		csr     *x509.CertificateRequest
+		wantErr bool
+	}{
+		{"ok", &x509.CertificateRequest{
+			DNSNames:    []string{"example.com"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		}, false},
+		{"ok common name", &x509.CertificateRequest{
+			Subject:     pkix.Name{CommonName: "Example.com"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		}, false},
+		{"fail missing", &x509.CertificateRequest{
+			DNSNames: []string{"example.com"},
+		}, true},
+		{"fail extra", &x509.CertificateRequest{
+			DNSNames:    []string{"example.com", "www.example.com"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		}, true},
+		{"fail common name", &x509.CertificateRequest{
+			Subject:     pkix.Name{CommonName: "www.example.com"},
+			DNSNames:    []string{"example.com"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		}, true},
+		{"fail email", &x509.CertificateRequest{
+			DNSNames:       []string{"example.com"},
+			IPAddresses:    []net.IP{net.ParseIP("127.0.0.1")},
+			EmailAddresses: []string{"jane@example.com"},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkCSRIdentifiers(tt.csr, ids); (err != nil) != tt.wantErr {
+				t.Errorf("checkCSRIdentifiers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package acme
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func testServerCommand() cli.Command {
+	return cli.Command{
+		Name:   "test-server",
+		Action: command.ActionFunc(testServerAction),
+		Usage:  "start an ACME server for local integration testing",
+		UsageText: `**step acme test-server**
+[**--address**=<address>] [**--root**=<file>] [**--san**=<host>]
+[**--http-port**=<port>] [**--tls-port**=<port>] [**--dns-server**=<address>]
+[**--skip-validation**] [**--cert-duration**=<duration>]`,
+		Description: `**step acme test-server** command starts a small ACME server (RFC 8555) to
+test ACME clients and challenge solvers, e.g. in CI, without external
+dependencies.
+
+This command is only intended for test purposes. The server keeps all the
+accounts, orders and certificates in memory, and uses an ephemeral root and
+intermediate certificate that are created every time the server starts. Use the
+**--root** flag to write the root certificate to a file, so it can be trusted by
+the ACME client.
+
+The server supports the http-01, dns-01 and tls-alpn-01 challenges for DNS
+identifiers, and the http-01 and tls-alpn-01 challenges for IP identifiers.
+Wildcard identifiers can only be validated with dns-01. Challenges are
+validated once, when the client responds to them, using the ports in the
+**--http-port** and **--tls-port** flags and the DNS server in the
+**--dns-server** flag. Use **--skip-validation** to mark all the challenges as
+valid without validating them.
+
+The directory is served at https://<address>/directory.
+
+## EXAMPLES
+
+Start an ACME server on port 14000 and write the root certificate to a file:
+'''
+$ step acme test-server --root root_ca.crt
+'''
+
+Start an ACME server validating the http-01 challenges on port 5002, and get a
+certificate from it using certbot:
+'''
+$ step acme test-server --root root_ca.crt --http-port 5002 &
+$ REQUESTS_CA_BUNDLE=root_ca.crt certbot certonly --standalone --http-01-port 5002 \
+  --server https://localhost:14000/directory -d localhost
+'''
+
+Start an ACME server that marks all the challenges as valid and issues
+certificates valid for 1 hour:
+'''
+$ step acme test-server --skip-validation --cert-duration 1h
+'''
+
+Start an ACME server listening in all the interfaces, with a certificate valid
+for the name of a CI service, and validating dns-01 challenges with a local DNS
+server:
+'''
+$ step acme test-server --address :14000 --san acme-server \
+  --dns-server 127.0.0.1:8053
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "address",
+				Usage: "The TCP <address> to listen on (e.g. \":14000\").",
+				Value: ":14000",
+			},
+			cli.StringFlag{
+				Name:  "root",
+				Usage: "The <file> to write the root certificate of the ACME server.",
+			},
+			cli.StringSliceFlag{
+				Name: "san",
+				Usage: `Add a DNS name or IP address to the certificate of the ACME server. Use the
+'--san' flag multiple times to add multiple names. By default the certificate is
+valid for localhost, 127.0.0.1 and ::1.`,
+			},
+			cli.StringFlag{
+				Name:  "http-port",
+				Usage: "The <port> used to validate http-01 challenges.",
+				Value: "80",
+			},
+			cli.StringFlag{
+				Name:  "tls-port",
+				Usage: "The <port> used to validate tls-alpn-01 challenges.",
+				Value: "443",
+			},
+			cli.StringFlag{
+				Name: "dns-server",
+				Usage: `The <address> of the DNS server used to validate dns-01 challenges
+(e.g. "127.0.0.1:8053"). Defaults to the system resolver.`,
+			},
+			cli.BoolFlag{
+				Name:  "skip-validation",
+				Usage: "Mark all the challenges as valid without validating them.",
+			},
+			cli.DurationFlag{
+				Name:  "cert-duration",
+				Usage: "The <duration> of the certificates issued by the ACME server.",
+				Value: x509util.DefaultCertValidity,
+			},
+			flags.Force,
+		},
+	}
+}
+
+func testServerAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	address := ctx.String("address")
+	if address == "" {
+		return errs.RequiredFlag(ctx, "address")
+	}
+	if d := ctx.Duration("cert-duration"); d <= 0 {
+		return errs.InvalidFlagValue(ctx, "cert-duration", d.String(), "")
+	}
+
+	srv, err := newServer(serverOptions{
+		SkipValidation: ctx.Bool("skip-validation"),
+		HTTPPort:       ctx.String("http-port"),
+		TLSPort:        ctx.String("tls-port"),
+		DNSServer:      ctx.String("dns-server"),
+		CertDuration:   ctx.Duration("cert-duration"),
+		Logger:         log.New(os.Stderr, "", log.LstdFlags),
+	})
+	if err != nil {
+		return err
+	}
+
+	hosts := append([]string{"localhost", "127.0.0.1", "::1"}, ctx.StringSlice("san")...)
+	crt, err := srv.ServerCertificate(hosts)
+	if err != nil {
+		return err
+	}
+
+	if fn := ctx.String("root"); fn != "" {
+		b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Root().Raw})
+		if err := utils.WriteFile(fn, b, 0644); err != nil {
+			return err
+		}
+	}
+
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", address)
+	}
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() || ip.IsLoopback() {
+		host = "localhost"
+	}
+	fmt.Printf("ACME directory: https://%s/directory\n", net.JoinHostPort(host, port))
+	fmt.Printf("Root fingerprint: %s\n", x509util.Fingerprint(srv.Root()))
+	if ctx.Bool("skip-validation") {
+		fmt.Println("Challenges are not validated.")
+	}
+
+	server := &http.Server{
+		Handler: srv.Handler(),
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{*crt},
+			MinVersion:   tls.VersionTLS12,
+		},
+		ReadHeaderTimeout: 15 * time.Second,
+	}
+	if err := server.ServeTLS(l, "", ""); err != nil && err != http.ErrServerClosed {
+		return errors.Wrap(err, "ACME server failed")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/kms/stepagent"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	cmd := cli.Command{
+		Name:      "agent",
+		Usage:     "run and manage an agent that keeps decrypted keys in memory",
+		UsageText: "step agent <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step agent** command group provides commands to run and manage the step
+agent, a local process that holds decrypted private keys in memory and signs
+with them on behalf of other step commands over a unix socket. Keys are
+decrypted once, when the agent starts, avoiding the password prompts and the
+cost of the key derivation in every invocation. The private keys never leave
+the agent.
+
+The agent listens in <$STEPPATH/agent.sock> unless the environment variable
+STEP_AGENT_SOCK or the flag **--socket** are set.
+
+If the agent is running and has the key, **step crypto jwt sign** and **step ca
+token** use it instead of reading and decrypting the key passed with **--key**.
+**step ca token** also uses the provisioner keys loaded by key id instead of
+downloading them from the CA. Keys in the agent can be used anywhere a private
+key file is accepted with the URI 'step-agent:<name>', where <name> is the path
+of the key file or its key id.
+
+## EXAMPLES
+
+Start an agent with a JWK and a provisioner key:
+'''
+$ step agent start --kid ZNTOMF9r2Ww4Nx6s3QVv7dWBpnbEZGYtlkrhoFhBAbA jwk.json &
+'''
+
+Sign JWTs without password prompts:
+'''
+$ for i in $(seq 100); do
+  step crypto jwt sign --key jwk.json --iss joe --aud example.com --sub $i --exp $(date -v+1M +"%s")
+done
+'''
+
+List the keys in the agent:
+'''
+$ step agent list
+'''
+
+Stop the agent:
+'''
+$ step agent stop
+'''`,
+		Subcommands: cli.Commands{
+			startCommand(),
+			listCommand(),
+			stopCommand(),
+		},
+	}
+
+	command.Register(cmd)
+}
+
+var socketFlag = cli.StringFlag{
+	Name: "socket",
+	Usage: `The <path> of the unix socket of the agent. Defaults to STEP_AGENT_SOCK or
+<$STEPPATH/agent.sock>.`,
+}
+
+// socketPath returns the path of the agent socket from the --socket flag or
+// the default location.
+func socketPath(ctx *cli.Context) string {
+	if socket := ctx.String("socket"); socket != "" {
+		return socket
+	}
+	return stepagent.SocketPath()
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/kms/stepagent"
+	"github.com/urfave/cli"
+)
+
+func listCommand() cli.Command {
+	return cli.Command{
+		Name:      "list",
+		Action:    command.ActionFunc(listAction),
+		Usage:     "list the keys in the step agent",
+		UsageText: `**step agent list** [**--socket**=<path>]`,
+		Description: `**step agent list** command prints the name, key id and algorithm of the keys
+loaded in the step agent.
+
+## EXAMPLES
+
+List the keys in the agent:
+'''
+$ step agent list
+'''`,
+		Flags: []cli.Flag{
+			socketFlag,
+		},
+	}
+}
+
+func listAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	c, err := stepagent.Dial(socketPath(ctx))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	keys, err := c.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tKID\tALG")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", k.Name, k.KeyID, k.Algorithm)
+	}
+	return w.Flush()
+}
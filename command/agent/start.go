@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/securebuf"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/kms/stepagent"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func startCommand() cli.Command {
+	return cli.Command{
+		Name:   "start",
+		Action: command.ActionFunc(startAction),
+		Usage:  "start a step agent with the given keys",
+		UsageText: `**step agent start** [<key-file> ...] [**--kid**=<kid>]
+[**--ca-url**=<uri>] [**--root**=<file>] [**--socket**=<path>]
+[**--password-file**=<file>]`,
+		Description: `**step agent start** command decrypts the given keys and starts a step agent
+serving them in a unix socket. The agent runs in the foreground until it
+receives SIGINT or SIGTERM, or **step agent stop** is executed.
+
+Keys can be JWKs, JWEs with a JWK payload, or PEM private keys, and they are
+selected by the other commands using their absolute path or their key id.
+Provisioner keys can be downloaded from the CA and loaded using the **--kid**
+flag; **step ca token** will use them instead of downloading and decrypting
+them every time.
+
+The socket is only accessible by the current user, and on Linux, macOS and
+FreeBSD the connections from processes of other users are rejected.
+
+## POSITIONAL ARGUMENTS
+
+<key-file>
+:  The path to a private key to load in the agent.
+
+## EXAMPLES
+
+Start an agent with a JWK:
+'''
+$ step agent start jwk.json
+'''
+
+Start an agent in the background with the key of a provisioner:
+'''
+$ step agent start --kid ZNTOMF9r2Ww4Nx6s3QVv7dWBpnbEZGYtlkrhoFhBAbA \
+  --ca-url https://ca.smallstep.com --root root_ca.crt &
+'''
+
+Start an agent in a custom location:
+'''
+$ export STEP_AGENT_SOCK=/tmp/step-agent.sock
+$ step agent start --password-file password.txt key.pem
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringSliceFlag{
+				Name: "kid",
+				Usage: `The key id of a provisioner key to download from the CA and load in the
+agent. Use the flag multiple times to load multiple keys.`,
+			},
+			cli.StringFlag{
+				Name:  "ca-url",
+				Usage: "<URI> of the targeted Step Certificate Authority.",
+			},
+			cli.StringFlag{
+				Name:  "root",
+				Usage: "The path to the PEM <file> used as the root certificate authority.",
+			},
+			socketFlag,
+		}, flags.Password(cli.StringFlag{
+			Name:  "password-file",
+			Usage: `The path to the <file> containing the password to decrypt the keys.`,
+		})...),
+	}
+}
+
+func startAction(ctx *cli.Context) error {
+	kids := ctx.StringSlice("kid")
+	if ctx.NArg() == 0 && len(kids) == 0 {
+		return errs.MissingArguments(ctx, "key-file")
+	}
+	caURL := ctx.String("ca-url")
+	if len(kids) > 0 && caURL == "" {
+		return errs.RequiredWithFlag(ctx, "kid", "ca-url")
+	}
+
+	socket := socketPath(ctx)
+	if c, err := stepagent.Dial(socket); err == nil {
+		c.Close()
+		return errors.Errorf("step agent is already running in %s", socket)
+	}
+
+	var opts []jose.Option
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+	if len(password) > 0 {
+		opts = append(opts, jose.WithPassword(password))
+	}
+
+	srv := stepagent.NewServer()
+	for _, filename := range ctx.Args() {
+		name, err := filepath.Abs(filename)
+		if err != nil {
+			return errors.Wrapf(err, "error getting absolute path of %s", filename)
+		}
+		jwk, err := jose.ParseKey(filename, append([]jose.Option{jose.WithUse("sig")}, opts...)...)
+		if err != nil {
+			return err
+		}
+		if err := addKey(srv, name, jwk); err != nil {
+			return err
+		}
+	}
+	for _, kid := range kids {
+		encrypted, err := pki.GetProvisionerKey(caURL, ctx.String("root"), kid)
+		if err != nil {
+			return err
+		}
+		prompt := fmt.Sprintf("Please enter the password to decrypt the provisioner key %s", kid)
+		decrypted, err := jose.Decrypt(prompt, []byte(encrypted), opts...)
+		if err != nil {
+			return err
+		}
+		jwk := new(jose.JSONWebKey)
+		err = json.Unmarshal(decrypted, jwk)
+		securebuf.Zero(decrypted)
+		if err != nil {
+			return errors.Wrap(err, "error unmarshalling provisioning key")
+		}
+		if err := addKey(srv, kid, jwk); err != nil {
+			return err
+		}
+	}
+
+	// The directory of a new profile might not exist yet
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return errs.FileError(err, filepath.Dir(socket))
+	}
+	// Remove a socket left by an agent that didn't stop cleanly
+	if _, err := os.Stat(socket); err == nil {
+		if err := os.Remove(socket); err != nil {
+			return errors.Wrapf(err, "error removing %s", socket)
+		}
+	}
+	l, err := stepagent.Listen(socket)
+	if err != nil {
+		return errors.Wrapf(err, "error listening in %s", socket)
+	}
+	defer os.Remove(socket)
+
+	// Stop the agent on SIGINT or SIGTERM
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; ok {
+			srv.Close()
+		}
+	}()
+
+	ui.PrintSelected("Listening", socket)
+	return srv.Serve(l)
+}
+
+// addKey adds the private key in the given JWK to the agent.
+func addKey(srv *stepagent.Server, name string, jwk *jose.JSONWebKey) error {
+	signer, ok := jwk.Key.(crypto.Signer)
+	if !ok || jwk.IsPublic() {
+		return errors.Errorf("key %s cannot be used for signing", name)
+	}
+	return srv.Add(stepagent.Key{
+		Name:      name,
+		KeyID:     jwk.KeyID,
+		Algorithm: jwk.Algorithm,
+		Use:       jwk.Use,
+	}, signer)
+}
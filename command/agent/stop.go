@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/kms/stepagent"
+	"github.com/urfave/cli"
+)
+
+func stopCommand() cli.Command {
+	return cli.Command{
+		Name:      "stop",
+		Action:    command.ActionFunc(stopAction),
+		Usage:     "stop the step agent",
+		UsageText: `**step agent stop** [**--socket**=<path>]`,
+		Description: `**step agent stop** command stops the step agent, removing the decrypted keys
+from memory.
+
+## EXAMPLES
+
+Stop the agent:
+'''
+$ step agent stop
+'''`,
+		Flags: []cli.Flag{
+			socketFlag,
+		},
+	}
+}
+
+func stopAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	c, err := stepagent.Dial(socketPath(ctx))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.Stop()
+}
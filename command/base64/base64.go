@@ -2,24 +2,41 @@ package base64
 
 import (
 	"bytes"
-	"encoding/base64"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/chzyer/readline"
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
 )
 
 func init() {
 	cmd := cli.Command{
-		Name:      "base64",
-		Action:    command.ActionFunc(base64Action),
-		Usage:     "encodes and decodes using base64 representation",
-		UsageText: `**step base64** [**-d**|**--decode**] [**-r**|**--raw**] [**-u**|**--url**]`,
-		Description: `**step base64** implements base64 encoding as specified by RFC 4648.
+		Name:   "base64",
+		Action: command.ActionFunc(base64Action),
+		Usage:  "encodes and decodes using base64, base32, hex or base58 representation",
+		UsageText: `**step base64** [**-d**|**--decode**] [**-r**|**--raw**] [**-u**|**--url**]
+[**-e**|**--encoding**=<encoding>] [**--strict**] [**--buffer-size**=<size>]
+[<text>...]`,
+		Description: `**step base64** implements base64, base64url, base32 and base32hex encodings as
+specified by RFC 4648, as well as hex and base58 encodings.
+
+The text to encode or decode is read from the positional arguments or from
+STDIN. Input from a pipe, a file or a device is streamed with a buffer of the
+size in **--buffer-size**, so inputs of any size are encoded and decoded with
+constant memory, except with the base58 encoding, which is not done in blocks.
+
+By default the decoding is liberal: whitespace is ignored, base64 input can use
+the standard or the url alphabets with or without padding, base32 input can be
+lower case and without padding, and hex input can use colons or spaces to
+separate the bytes. The **--strict** flag, or the **--raw** and **--url** flags,
+enforce the exact format.
 
 ## Examples
 
@@ -59,6 +76,33 @@ $ echo YWJjMTIzJCVeJiooKV8rLT1-Cg== | step base64 -d
 abc123$%^&*()_+-=~
 $ echo YWJjMTIzJCVeJiooKV8rLT1-Cg== | step base64 -d -u
 abc123$%^&*()_+-=~
+'''
+
+Convert a base64url encoded JWT signature to hex:
+'''
+$ echo DlSkxICjk2h1LarwJgXPbXQe7DwpLMOCvWp3I4GMcBM | step base64 -d | step base64 -e hex
+0e54a4c480a39368752daaf02605cf6d741eec3c292cc382bd6a7723818c7013
+'''
+
+Decode a certificate fingerprint in hex with colons:
+'''
+$ echo 2C:5A:0E:8F:12 | step base64 -d -e hex | step base64
+LFoOjxI=
+'''
+
+Encode and decode using base32 and base58:
+'''
+$ step base64 -e base32 Hello World!
+JBSWY3DPEBLW64TMMQQQ====
+$ step base64 -e base58 Hello World!
+2NEpo7TZRRrLZSi2U
+$ echo 2NEpo7TZRRrLZSi2U | step base64 -d -e base58
+Hello World!
+'''
+
+Decode a base64 file enforcing the standard padded encoding:
+'''
+$ step base64 -d --strict < cert.b64 > cert.der
 '''`,
 		Flags: []cli.Flag{
 			cli.BoolFlag{
@@ -67,12 +111,42 @@ abc123$%^&*()_+-=~
 			},
 			cli.BoolFlag{
 				Name:  "r,raw",
-				Usage: "use the unpadded base64 encoding",
+				Usage: "use the unpadded base64 or base32 encoding",
 			},
 			cli.BoolFlag{
 				Name:  "u,url",
 				Usage: "use the encoding format typically used in URLs and file names",
 			},
+			cli.StringFlag{
+				Name:  "e,encoding",
+				Value: "base64",
+				Usage: `The <encoding> used to encode or decode the input.
+
+: <encoding> is a string and must be one of:
+
+    **base64**
+    :  The standard base64 encoding (default).
+
+    **base64url**
+    :  The base64 encoding with the URL and filename safe alphabet.
+
+    **base32**
+    :  The standard base32 encoding.
+
+    **base32hex**
+    :  The base32 encoding with the extended hex alphabet.
+
+    **hex**
+    :  The hexadecimal encoding.
+
+    **base58**
+    :  The base58 encoding with the bitcoin alphabet.`,
+			},
+			cli.BoolFlag{
+				Name:  "strict",
+				Usage: "decode only input in the exact format of the encoding",
+			},
+			flags.BufferSize,
 		},
 	}
 
@@ -80,59 +154,79 @@ abc123$%^&*()_+-=~
 }
 
 func base64Action(ctx *cli.Context) error {
-	var err error
-	var data []byte
 	isDecode := ctx.Bool("decode")
+	encoding := ctx.String("encoding")
+	raw := ctx.Bool("raw")
 
-	if ctx.NArg() > 0 {
-		data = []byte(strings.Join(ctx.Args(), " "))
-	} else {
-		var prompt string
-		if isDecode {
-			prompt = "Please enter text to decode"
-		} else {
-			prompt = "Please enter text to encode"
-		}
+	switch {
+	case ctx.Bool("url") && encoding == "base64":
+		encoding = "base64url"
+	case ctx.Bool("url") && encoding != "base64url":
+		return errs.IncompatibleFlagValue(ctx, "url", "encoding", encoding)
+	case raw && (encoding == "hex" || encoding == "base58"):
+		return errs.IncompatibleFlagValue(ctx, "raw", "encoding", encoding)
+	}
 
-		if data, err = utils.ReadInput(prompt); err != nil {
-			return err
-		}
+	// Liberal decoding is disabled if the format is enforced.
+	liberal := !ctx.Bool("strict") && !ctx.IsSet("raw") && !ctx.IsSet("url")
+	c, err := getCodec(encoding, raw, liberal)
+	if err != nil {
+		return errs.InvalidFlagValue(ctx, "encoding", encoding, "base64, base64url, base32, base32hex, hex, base58")
 	}
 
-	enc := getEncoder(ctx, data)
+	size, ok := flags.ParseBufferSize(ctx.String("buffer-size"))
+	if !ok {
+		return errs.InvalidFlagValue(ctx, "buffer-size", ctx.String("buffer-size"), "")
+	}
+	buf := make([]byte, size)
+
+	r, err := getInput(ctx, isDecode)
+	if err != nil {
+		return err
+	}
+	// Hide the ReadFrom and WriteTo methods of files so the copy uses the
+	// given buffer.
+	r = struct{ io.Reader }{r}
+
 	if isDecode {
-		b, err := enc.DecodeString(string(data))
-		if err != nil {
+		if _, err := io.CopyBuffer(struct{ io.Writer }{os.Stdout}, c.NewDecoder(r), buf); err != nil {
 			return errors.Wrap(err, "error decoding input")
 		}
-		os.Stdout.Write(b)
-	} else {
-		fmt.Println(enc.EncodeToString(data))
+		return nil
 	}
 
+	w := c.NewEncoder(os.Stdout)
+	if _, err := io.CopyBuffer(w, r, buf); err != nil {
+		return errors.Wrap(err, "error encoding input")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "error encoding input")
+	}
+	fmt.Println()
 	return nil
 }
 
-func getEncoder(ctx *cli.Context, data []byte) *base64.Encoding {
-	raw := ctx.Bool("raw")
-	url := ctx.Bool("url")
-	isDecode := ctx.Bool("decode")
+// getInput returns a reader with the positional arguments, or with the
+// contents of STDIN. If STDIN is a pipe, a file or a device it will be
+// streamed, if it is a terminal the user will be prompted for the input.
+func getInput(ctx *cli.Context, isDecode bool) (io.Reader, error) {
+	if ctx.NArg() > 0 {
+		return strings.NewReader(strings.Join(ctx.Args(), " ")), nil
+	}
 
-	// Detect encoding
-	if isDecode && !ctx.IsSet("raw") && !ctx.IsSet("url") {
-		raw = !bytes.HasSuffix(bytes.TrimSpace(data), []byte("="))
-		url = bytes.Contains(data, []byte("-")) || bytes.Contains(data, []byte("_"))
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		return os.Stdin, nil
 	}
 
-	if raw {
-		if url {
-			return base64.RawURLEncoding
-		}
-		return base64.RawStdEncoding
+	var prompt string
+	if isDecode {
+		prompt = "Please enter text to decode"
+	} else {
+		prompt = "Please enter text to encode"
 	}
-	if url {
-		return base64.URLEncoding
+	data, err := utils.ReadInput(prompt)
+	if err != nil {
+		return nil, err
 	}
-
-	return base64.StdEncoding
+	return bytes.NewReader(data), nil
 }
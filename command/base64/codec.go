@@ -0,0 +1,207 @@
+package base64
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/base58"
+)
+
+// codec encodes and decodes data using one of the supported encodings.
+type codec interface {
+	// NewEncoder returns a writer that encodes to w, the returned writer must
+	// be closed to flush any partial block.
+	NewEncoder(w io.Writer) io.WriteCloser
+	// NewDecoder returns a reader that decodes from r.
+	NewDecoder(r io.Reader) io.Reader
+}
+
+// base64Codec implements base64 and base64url codecs. In liberal mode the
+// decoder accepts both alphabets with or without padding.
+type base64Codec struct {
+	enc     *base64.Encoding
+	liberal bool
+}
+
+func (c *base64Codec) NewEncoder(w io.Writer) io.WriteCloser {
+	return base64.NewEncoder(c.enc, w)
+}
+
+func (c *base64Codec) NewDecoder(r io.Reader) io.Reader {
+	if !c.liberal {
+		return base64.NewDecoder(c.enc.Strict(), r)
+	}
+	return base64.NewDecoder(base64.RawStdEncoding, &filterReader{r: r, fn: func(b byte) (byte, bool) {
+		switch b {
+		case '-':
+			return '+', true
+		case '_':
+			return '/', true
+		case '=', ' ', '\t', '\r', '\n':
+			return 0, false
+		default:
+			return b, true
+		}
+	}})
+}
+
+// base32Codec implements the base32 codec. In liberal mode the decoder
+// accepts lower case letters and input with or without padding.
+type base32Codec struct {
+	enc     *base32.Encoding
+	liberal bool
+}
+
+func (c *base32Codec) NewEncoder(w io.Writer) io.WriteCloser {
+	return base32.NewEncoder(c.enc, w)
+}
+
+func (c *base32Codec) NewDecoder(r io.Reader) io.Reader {
+	if !c.liberal {
+		return base32.NewDecoder(c.enc, r)
+	}
+	return base32.NewDecoder(base32.StdEncoding.WithPadding(base32.NoPadding), &filterReader{r: r, fn: func(b byte) (byte, bool) {
+		switch {
+		case b >= 'a' && b <= 'z':
+			return b - 'a' + 'A', true
+		case b == '=', b == ' ', b == '\t', b == '\r', b == '\n':
+			return 0, false
+		default:
+			return b, true
+		}
+	}})
+}
+
+// hexCodec implements the hex codec. In liberal mode the decoder ignores the
+// colons and spaces used to separate bytes, e.g. in fingerprints.
+type hexCodec struct {
+	liberal bool
+}
+
+func (c *hexCodec) NewEncoder(w io.Writer) io.WriteCloser {
+	return nopCloser{hex.NewEncoder(w)}
+}
+
+func (c *hexCodec) NewDecoder(r io.Reader) io.Reader {
+	return hex.NewDecoder(&filterReader{r: r, fn: func(b byte) (byte, bool) {
+		switch b {
+		case '\r', '\n':
+			return 0, false
+		case ':', ' ', '\t':
+			return b, !c.liberal
+		default:
+			return b, true
+		}
+	}})
+}
+
+// base58Codec implements the base58 codec using the bitcoin alphabet. The
+// encoding is not done in blocks, so the input is buffered.
+type base58Codec struct{}
+
+func (c *base58Codec) NewEncoder(w io.Writer) io.WriteCloser {
+	return &base58Encoder{w: w}
+}
+
+func (c *base58Codec) NewDecoder(r io.Reader) io.Reader {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return &errReader{err: err}
+	}
+	data, err := base58.Decode(strings.TrimSpace(string(b)))
+	if err != nil {
+		return &errReader{err: err}
+	}
+	return bytes.NewReader(data)
+}
+
+type base58Encoder struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (e *base58Encoder) Write(p []byte) (int, error) {
+	return e.buf.Write(p)
+}
+
+func (e *base58Encoder) Close() error {
+	_, err := io.WriteString(e.w, base58.Encode(e.buf.Bytes()))
+	return err
+}
+
+// getCodec returns the codec for the given encoding name.
+func getCodec(name string, raw, liberal bool) (codec, error) {
+	switch name {
+	case "base64", "base64url":
+		var enc *base64.Encoding
+		if name == "base64url" {
+			enc = base64.URLEncoding
+		} else {
+			enc = base64.StdEncoding
+		}
+		if raw {
+			enc = enc.WithPadding(base64.NoPadding)
+		}
+		return &base64Codec{enc: enc, liberal: liberal}, nil
+	case "base32", "base32hex":
+		var enc *base32.Encoding
+		if name == "base32hex" {
+			enc = base32.HexEncoding
+		} else {
+			enc = base32.StdEncoding
+		}
+		if raw {
+			enc = enc.WithPadding(base32.NoPadding)
+		}
+		// The liberal decoder only supports the standard alphabet.
+		return &base32Codec{enc: enc, liberal: liberal && name == "base32"}, nil
+	case "hex":
+		return &hexCodec{liberal: liberal}, nil
+	case "base58":
+		return &base58Codec{}, nil
+	default:
+		return nil, errors.Errorf("unsupported encoding %s", name)
+	}
+}
+
+// filterReader is a reader that transforms or drops the bytes read from r.
+type filterReader struct {
+	r  io.Reader
+	fn func(byte) (byte, bool)
+}
+
+func (f *filterReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		j := 0
+		for i := 0; i < n; i++ {
+			if c, ok := f.fn(p[i]); ok {
+				p[j] = c
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,286 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/artifact"
+	"github.com/smallstep/cli/crypto/x509util"
+)
+
+// Names of the files in a bundle.
+const (
+	manifestName     = "manifest.json"
+	signatureName    = "manifest.json.p7s"
+	rootName         = "certs/root_ca.crt"
+	defaultsName     = "config/defaults.json"
+	provisionersName = "config/provisioners.json"
+	templatesDir     = "templates/"
+)
+
+// bundleVersion is the version of the bundle format.
+const bundleVersion = 1
+
+// maxBundleSize is the maximum size of the uncompressed content of a bundle.
+const maxBundleSize = 64 << 20
+
+// manifest is the signed description of the content of a bundle.
+type manifest struct {
+	Version     int            `json:"version"`
+	Created     time.Time      `json:"created"`
+	CAURL       string         `json:"ca-url"`
+	Fingerprint string         `json:"fingerprint"`
+	Files       []manifestFile `json:"files"`
+}
+
+// manifestFile is a file in the bundle and its SHA-256 digest.
+type manifestFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// bundle is the verified content of a bundle.
+type bundle struct {
+	Manifest *manifest
+	Root     *x509.Certificate
+	Signer   *x509.Certificate
+	// Files are the contents of the files in the manifest by name.
+	Files map[string][]byte
+}
+
+// signer signs the manifest of a bundle.
+type signer func(data []byte) ([]byte, error)
+
+// writeBundle returns a tar.gz archive with the given files, a manifest with
+// their digests, and the signature of the manifest.
+func writeBundle(caURL string, root *x509.Certificate, files map[string][]byte, sign signer) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if err := validateName(name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := &manifest{
+		Version:     bundleVersion,
+		Created:     time.Now().UTC().Truncate(time.Second),
+		CAURL:       caURL,
+		Fingerprint: x509util.Fingerprint(root),
+	}
+	for _, name := range names {
+		sum := sha256.Sum256(files[name])
+		m.Files = append(m.Files, manifestFile{
+			Name:   name,
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	mb, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling manifest")
+	}
+	sig, err := sign(mb)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	add := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(data)),
+			ModTime:  m.Created,
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return errors.Wrap(err, "error creating bundle")
+		}
+		_, err := tw.Write(data)
+		return errors.Wrap(err, "error creating bundle")
+	}
+	if err := add(manifestName, mb); err != nil {
+		return nil, err
+	}
+	if err := add(signatureName, sig); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := add(name, files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "error creating bundle")
+	}
+	if err := gz.Close(); err != nil {
+		return nil, errors.Wrap(err, "error creating bundle")
+	}
+	return buf.Bytes(), nil
+}
+
+// readBundle reads a bundle and verifies that its root has the given
+// fingerprint, that the manifest is signed by a code signing certificate
+// issued by the root, and that the files match the manifest.
+func readBundle(data []byte, fingerprint string) (*bundle, error) {
+	files, err := readArchive(data)
+	if err != nil {
+		return nil, err
+	}
+	mb, ok := files[manifestName]
+	if !ok {
+		return nil, errors.Errorf("error reading bundle: %s is missing", manifestName)
+	}
+	sig, ok := files[signatureName]
+	if !ok {
+		return nil, errors.Errorf("error reading bundle: %s is missing", signatureName)
+	}
+	rb, ok := files[rootName]
+	if !ok {
+		return nil, errors.Errorf("error reading bundle: %s is missing", rootName)
+	}
+
+	block, _ := pem.Decode(rb)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.Errorf("error reading bundle: %s is not a PEM certificate", rootName)
+	}
+	root, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading bundle root")
+	}
+	fingerprint = strings.ToLower(strings.Replace(fingerprint, ":", "", -1))
+	if fp := x509util.Fingerprint(root); fp != fingerprint {
+		return nil, errors.Errorf("the bundle root fingerprint %s does not match %s", fp, fingerprint)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(root)
+	s, err := artifact.Verify(mb, sig, artifact.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying bundle signature")
+	}
+	// A certificate without extended key usages is valid for any usage, the
+	// signer must be issued explicitly to sign code, not any leaf of the CA.
+	if !hasExtKeyUsage(s.Chain[0], x509.ExtKeyUsageCodeSigning) {
+		return nil, errors.Errorf("error verifying bundle signature: the certificate %s does not have the code signing extended key usage", s.Chain[0].Subject.CommonName)
+	}
+
+	m := new(manifest)
+	if err := json.Unmarshal(mb, m); err != nil {
+		return nil, errors.Wrap(err, "error parsing manifest")
+	}
+	switch {
+	case m.Version != bundleVersion:
+		return nil, errors.Errorf("unsupported bundle version %d", m.Version)
+	case m.Fingerprint != fingerprint:
+		return nil, errors.Errorf("the manifest fingerprint %s does not match %s", m.Fingerprint, fingerprint)
+	}
+
+	b := &bundle{
+		Manifest: m,
+		Root:     root,
+		Signer:   s.Chain[0],
+		Files:    make(map[string][]byte, len(m.Files)),
+	}
+	for _, f := range m.Files {
+		if err := validateName(f.Name); err != nil {
+			return nil, err
+		}
+		data, ok := files[f.Name]
+		if !ok {
+			return nil, errors.Errorf("error reading bundle: %s is missing", f.Name)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != f.SHA256 {
+			return nil, errors.Errorf("error reading bundle: the digest of %s does not match the manifest", f.Name)
+		}
+		b.Files[f.Name] = data
+	}
+	// Files not in the manifest are not signed
+	for name := range files {
+		if _, ok := b.Files[name]; !ok && name != manifestName && name != signatureName {
+			return nil, errors.Errorf("error reading bundle: %s is not in the manifest", name)
+		}
+	}
+	if _, ok := b.Files[rootName]; !ok {
+		return nil, errors.Errorf("error reading bundle: %s is not in the manifest", rootName)
+	}
+	return b, nil
+}
+
+// hasExtKeyUsage returns true if the certificate has the given extended key
+// usage.
+func hasExtKeyUsage(crt *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range crt.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// readArchive returns the regular files in the given tar.gz archive.
+func readArchive(data []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading bundle")
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	var size int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading bundle")
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, errors.Errorf("error reading bundle: %s is not a regular file", hdr.Name)
+		}
+		if _, ok := files[hdr.Name]; ok {
+			return nil, errors.Errorf("error reading bundle: %s is duplicated", hdr.Name)
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(tr, maxBundleSize-size+1))
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading bundle")
+		}
+		if size += int64(len(b)); size > maxBundleSize {
+			return nil, errors.New("error reading bundle: the bundle is too large")
+		}
+		files[hdr.Name] = b
+	}
+}
+
+// validateName checks that the given name is one of the files that a bundle
+// can contain.
+func validateName(name string) error {
+	switch name {
+	case rootName, defaultsName, provisionersName:
+		return nil
+	}
+	if strings.HasPrefix(name, templatesDir) && path.Clean(name) == name &&
+		!strings.Contains(name, "..") && len(name) > len(templatesDir) {
+		return nil
+	}
+	return errors.Errorf("invalid bundle file name %s", name)
+}
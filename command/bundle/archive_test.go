@@ -0,0 +1,187 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallstep/cli/crypto/artifact"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/stretchr/testify/require"
+)
+
+func newCertificate(t *testing.T, cn string, parent *x509.Certificate, parentKey crypto.Signer, ekus ...x509.ExtKeyUsage) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           ekus,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return crt, key
+}
+
+func pack(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestBundle(t *testing.T) {
+	root, rootKey := newCertificate(t, "Root CA", nil, nil)
+	leaf, leafKey := newCertificate(t, "bundle-signer", root, rootKey, x509.ExtKeyUsageCodeSigning)
+	serverLeaf, serverKey := newCertificate(t, "server", root, rootKey, x509.ExtKeyUsageServerAuth)
+	anyLeaf, anyKey := newCertificate(t, "any", root, rootKey)
+	otherRoot, otherKey := newCertificate(t, "Other Root CA", nil, nil)
+	otherLeaf, otherLeafKey := newCertificate(t, "other-signer", otherRoot, otherKey, x509.ExtKeyUsageCodeSigning)
+	fingerprint := x509util.Fingerprint(root)
+
+	signWith := func(crt *x509.Certificate, key crypto.Signer) signer {
+		return func(data []byte) ([]byte, error) {
+			return artifact.Sign(data, crt, key, artifact.SignOptions{})
+		}
+	}
+	files := map[string][]byte{
+		rootName:                   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}),
+		defaultsName:               []byte(`{"ca-url":"https://ca.example.com"}`),
+		provisionersName:           []byte(`[]`),
+		"templates/x509/leaf.tpl":  []byte(`{"subject": {{ toJson .Subject }}}`),
+		"templates/ssh/config.tpl": []byte(`Host *`),
+	}
+
+	data, err := writeBundle("https://ca.example.com", root, files, signWith(leaf, leafKey))
+	require.NoError(t, err)
+
+	t.Run("ok", func(t *testing.T) {
+		b, err := readBundle(data, fingerprint)
+		require.NoError(t, err)
+		require.Equal(t, files, b.Files)
+		require.Equal(t, "https://ca.example.com", b.Manifest.CAURL)
+		require.Equal(t, fingerprint, b.Manifest.Fingerprint)
+		require.Equal(t, "bundle-signer", b.Signer.Subject.CommonName)
+		require.True(t, root.Equal(b.Root))
+	})
+
+	t.Run("fail/fingerprint", func(t *testing.T) {
+		_, err := readBundle(data, x509util.Fingerprint(otherRoot))
+		require.Error(t, err)
+	})
+
+	t.Run("fail/signer", func(t *testing.T) {
+		b, err := writeBundle("https://ca.example.com", root, files, signWith(otherLeaf, otherLeafKey))
+		require.NoError(t, err)
+		_, err = readBundle(b, fingerprint)
+		require.Error(t, err)
+	})
+
+	t.Run("fail/key usage", func(t *testing.T) {
+		b, err := writeBundle("https://ca.example.com", root, files, signWith(serverLeaf, serverKey))
+		require.NoError(t, err)
+		_, err = readBundle(b, fingerprint)
+		require.Error(t, err)
+	})
+
+	t.Run("fail/no key usage", func(t *testing.T) {
+		b, err := writeBundle("https://ca.example.com", root, files, signWith(anyLeaf, anyKey))
+		require.NoError(t, err)
+		_, err = readBundle(b, fingerprint)
+		require.Error(t, err)
+	})
+
+	t.Run("fail/tampered", func(t *testing.T) {
+		content, err := readArchive(data)
+		require.NoError(t, err)
+		content[defaultsName] = []byte(`{"ca-url":"https://evil.example.com"}`)
+		_, err = readBundle(pack(t, content), fingerprint)
+		require.Error(t, err)
+	})
+
+	t.Run("fail/unsigned", func(t *testing.T) {
+		content, err := readArchive(data)
+		require.NoError(t, err)
+		content["templates/extra.tpl"] = []byte(`extra`)
+		_, err = readBundle(pack(t, content), fingerprint)
+		require.Error(t, err)
+	})
+
+	t.Run("fail/missing", func(t *testing.T) {
+		content, err := readArchive(data)
+		require.NoError(t, err)
+		delete(content, signatureName)
+		_, err = readBundle(pack(t, content), fingerprint)
+		require.Error(t, err)
+	})
+
+	t.Run("fail/archive", func(t *testing.T) {
+		_, err := readBundle([]byte("not a bundle"), fingerprint)
+		require.Error(t, err)
+	})
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{rootName, defaultsName, provisionersName, "templates/leaf.tpl", "templates/x509/leaf.tpl"} {
+		require.NoError(t, validateName(name), name)
+	}
+	for _, name := range []string{"", "templates/", "templates/../config/ca.json", "templates//leaf.tpl", "/etc/passwd", "secrets/root_ca_key"} {
+		require.Error(t, validateName(name), name)
+	}
+}
+
+func TestExportTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "step-bundle")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "x509"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "x509", "leaf.tpl"), []byte("leaf"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ssh.tpl"), []byte("ssh"), 0600))
+
+	files := make(map[string][]byte)
+	require.NoError(t, exportTemplates(dir, files))
+	require.Equal(t, map[string][]byte{
+		"templates/x509/leaf.tpl": []byte("leaf"),
+		"templates/ssh.tpl":       []byte("ssh"),
+	}, files)
+
+	files = make(map[string][]byte)
+	require.NoError(t, exportTemplates(filepath.Join(dir, "missing"), files))
+	require.Empty(t, files)
+}
@@ -0,0 +1,44 @@
+package bundle
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	cmd := cli.Command{
+		Name:      "bundle",
+		Usage:     "export and import signed configuration bundles for hosts without network access",
+		UsageText: "step bundle <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step bundle** command group provides commands to configure step on hosts
+that cannot connect to the CA, like ships or factory floors.
+
+A bundle is a tar.gz archive created with **step bundle export** on a host
+connected to the CA. It contains the root certificate, the default values of
+the flags, the public configuration of the provisioners, and the templates in
+<$STEPPATH/templates>, with a manifest signed with a certificate issued by the
+CA. **step bundle import** verifies the bundle with the fingerprint of the root
+certificate and writes its files on the target host, like **step ca bootstrap**
+does with network access.
+
+## EXAMPLES
+
+Export a bundle on a host connected to the CA:
+'''
+$ step ca certificate bundle-signer signer.crt signer.key
+$ step bundle export step.bundle --cert signer.crt --key signer.key
+'''
+
+Import the bundle on the target host:
+'''
+$ step bundle import step.bundle \
+  --fingerprint d9d0978692f1c7cc791f5c343ce98771900721405e834cd27b9502cc719f5097
+'''`,
+		Subcommands: cli.Commands{
+			exportCommand(),
+			importCommand(),
+		},
+	}
+
+	command.Register(cmd)
+}
@@ -0,0 +1,261 @@
+package bundle
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/artifact"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// secretProperties are the properties of the provisioners that are not
+// included in a bundle.
+var secretProperties = []string{"encryptedKey", "clientSecret"}
+
+func exportCommand() cli.Command {
+	return cli.Command{
+		Name:   "export",
+		Action: command.ActionFunc(exportAction),
+		Usage:  "create a signed bundle with the configuration of a CA",
+		UsageText: `**step bundle export** <file> **--cert**=<file> **--key**=<file>
+[**--ca-url**=<uri>] [**--root**=<file>] [**--templates**=<directory>]
+[**--password-file**=<file>] [**--force**]`,
+		Description: `**step bundle export** creates a bundle with the configuration required to use
+a CA on a host without network access, and signs it with a certificate issued
+by the CA. The certificate must have the code signing extended key usage, other
+certificates of the CA are rejected on import.
+
+The bundle contains:
+
+  * The root certificate of the CA
+  * The default values of the flags, with the CA url and the fingerprint of the
+    root; the path of the root is set when the bundle is imported
+  * The provisioners of the CA without their encrypted keys and client secrets
+  * The files in the **--templates** directory
+
+The provisioners are requested to the CA, so this command requires access to
+the CA.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The path where the bundle is written.
+
+## EXAMPLES
+
+Create a code signing certificate with the intermediate of the CA:
+'''
+$ step certificate create "Bundle Signer" signer.crt signer.key --profile code-signing \
+  --ca intermediate_ca.crt --ca-key intermediate_ca_key
+'''
+
+Export a bundle signed with a code signing certificate issued by the CA:
+'''
+$ step bundle export step.bundle --cert signer.crt --key signer.key
+'''
+
+Export a bundle with the templates in a different directory:
+'''
+$ step bundle export step.bundle --cert signer.crt --key signer.key \
+  --templates /etc/step/templates
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "cert",
+				Usage: "The path to the certificate <file> used to sign the bundle, it must be issued by the CA with the code signing extended key usage.",
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "The path to the private key <file> of the certificate.",
+			},
+			cli.StringFlag{
+				Name:  "ca-url",
+				Usage: "<URI> of the targeted Step Certificate Authority.",
+			},
+			cli.StringFlag{
+				Name:  "root",
+				Usage: "The path to the PEM <file> used as the root certificate authority.",
+			},
+			cli.StringFlag{
+				Name:  "templates",
+				Usage: "The <directory> with the templates to include in the bundle. Defaults to <$STEPPATH/templates>.",
+			},
+			flags.Force,
+		}, flags.Password(flags.PasswordFile)...),
+	}
+}
+
+func exportAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	crtFile, keyFile := ctx.String("cert"), ctx.String("key")
+	caURL := ctx.String("ca-url")
+	switch {
+	case crtFile == "":
+		return errs.RequiredFlag(ctx, "cert")
+	case keyFile == "":
+		return errs.RequiredFlag(ctx, "key")
+	case caURL == "":
+		return errs.RequiredFlag(ctx, "ca-url")
+	}
+	rootFile := ctx.String("root")
+	if rootFile == "" {
+		rootFile = pki.GetRootCAPath()
+	}
+	templates := ctx.String("templates")
+	if templates == "" {
+		templates = filepath.Join(config.StepPath(), "templates")
+	}
+
+	root, err := pemutil.ReadCertificate(rootFile)
+	if err != nil {
+		return err
+	}
+	fingerprint := x509util.Fingerprint(root)
+	files := map[string][]byte{
+		rootName: pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: root.Raw,
+		}),
+	}
+
+	if files[defaultsName], err = exportDefaults(command.ConfigFile(ctx), caURL, fingerprint); err != nil {
+		return err
+	}
+
+	provisioners, err := pki.GetProvisioners(caURL, rootFile)
+	if err != nil {
+		return errors.Wrap(err, "error getting the provisioners")
+	}
+	if files[provisionersName], err = exportProvisioners(provisioners); err != nil {
+		return err
+	}
+
+	if err := exportTemplates(templates, files); err != nil {
+		return err
+	}
+
+	var opts []pemutil.Options
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+	if len(password) > 0 {
+		opts = append(opts, pemutil.WithPassword(password))
+	}
+	identity, err := x509util.LoadIdentityFromDisk(crtFile, keyFile, opts...)
+	if err != nil {
+		return err
+	}
+	chain, err := pemutil.ReadCertificateBundle(crtFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := writeBundle(caURL, root, files, func(b []byte) ([]byte, error) {
+		return artifact.Sign(b, identity.Crt, identity.Key, artifact.SignOptions{
+			Format:        artifact.FormatCMS,
+			Intermediates: chain[1:],
+		})
+	})
+	if err != nil {
+		return err
+	}
+	// Fail now if the bundle cannot be imported
+	if _, err := readBundle(data, fingerprint); err != nil {
+		return err
+	}
+
+	filename := ctx.Args().Get(0)
+	if err := utils.WriteFile(filename, data, 0600); err != nil {
+		return err
+	}
+	ui.Printf("Your bundle has been saved in %s.\n", filename)
+	ui.Printf("Import it with: step bundle import %s --fingerprint %s\n", filename, fingerprint)
+	return nil
+}
+
+// exportDefaults returns the given configuration file with the CA url and
+// the fingerprint of the root. The root is removed because its path is set
+// on import.
+func exportDefaults(filename, caURL, fingerprint string) ([]byte, error) {
+	m, err := command.ReadConfigFile(filename)
+	switch {
+	case os.IsNotExist(errors.Cause(err)):
+		m = make(map[string]interface{})
+	case err != nil:
+		return nil, errs.FileError(err, filename)
+	}
+	delete(m, "root")
+	m["ca-url"] = caURL
+	m["fingerprint"] = fingerprint
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, "error marshaling %s", filename)
+	}
+	return append(b, '\n'), nil
+}
+
+// exportProvisioners returns the JSON representation of the provisioners
+// without their secret properties.
+func exportProvisioners(list provisioner.List) ([]byte, error) {
+	b, err := json.Marshal(list)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling provisioners")
+	}
+	var provisioners []map[string]interface{}
+	if err := json.Unmarshal(b, &provisioners); err != nil {
+		return nil, errors.Wrap(err, "error marshaling provisioners")
+	}
+	for _, p := range provisioners {
+		for _, name := range secretProperties {
+			delete(p, name)
+		}
+	}
+	if b, err = json.MarshalIndent(provisioners, "", "  "); err != nil {
+		return nil, errors.Wrap(err, "error marshaling provisioners")
+	}
+	return append(b, '\n'), nil
+}
+
+// exportTemplates adds the regular files in the given directory to files. A
+// missing directory is ignored.
+func exportTemplates(dir string, files map[string][]byte) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errs.FileError(err, path)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errs.FileError(err, path)
+		}
+		files[templatesDir+filepath.ToSlash(rel)] = b
+		return nil
+	})
+}
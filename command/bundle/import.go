@@ -0,0 +1,160 @@
+package bundle
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func importCommand() cli.Command {
+	return cli.Command{
+		Name:      "import",
+		Action:    command.ActionFunc(importAction),
+		Usage:     "verify a bundle and configure step with it",
+		UsageText: `**step bundle import** <file> **--fingerprint**=<fingerprint> [**--force**]`,
+		Description: `**step bundle import** verifies a bundle created with **step bundle export** and
+writes its files in the step path, configuring step to use the CA without
+network access.
+
+The bundle is only imported if the SHA-256 fingerprint of its root certificate
+is the **--fingerprint**, the manifest is signed with a certificate issued by
+that root with the code signing extended key usage, and the files match the
+digests in the manifest. The files are written in:
+
+  * <$STEPPATH/certs/root_ca.crt>: the root certificate
+  * <$STEPPATH/config/defaults.json>: the default values of the flags, with the
+    path of the root certificate
+  * <$STEPPATH/config/provisioners.json>: the public configuration of the
+    provisioners
+  * <$STEPPATH/templates>: the templates
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The path to the bundle.
+
+## EXAMPLES
+
+Import a bundle:
+'''
+$ step bundle import step.bundle \
+  --fingerprint d9d0978692f1c7cc791f5c343ce98771900721405e834cd27b9502cc719f5097
+'''
+
+Import a bundle overwriting the current configuration:
+'''
+$ step bundle import step.bundle --fingerprint d9d09786... --force
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "fingerprint",
+				Usage: "The <fingerprint> of the root certificate of the bundle.",
+			},
+			flags.Force,
+		},
+	}
+}
+
+func importAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	fingerprint := ctx.String("fingerprint")
+	if fingerprint == "" {
+		return errs.RequiredFlag(ctx, "fingerprint")
+	}
+
+	filename := ctx.Args().Get(0)
+	data, err := utils.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	b, err := readBundle(data, fingerprint)
+	if err != nil {
+		return errs.Crypto(err)
+	}
+
+	rootFile := pki.GetRootCAPath()
+	if err := writeFile(rootFile, b.Files[rootName]); err != nil {
+		return err
+	}
+	ui.Printf("The root certificate has been saved in %s.\n", rootFile)
+
+	defaults, err := importDefaults(b, rootFile)
+	if err != nil {
+		return err
+	}
+	configFile := filepath.Join(config.StepPath(), "config", "defaults.json")
+	if err := writeFile(configFile, defaults); err != nil {
+		return err
+	}
+	ui.Printf("The authority configuration has been saved in %s.\n", configFile)
+
+	if p, ok := b.Files[provisionersName]; ok {
+		provisionersFile := filepath.Join(pki.GetConfigPath(), "provisioners.json")
+		if err := writeFile(provisionersFile, p); err != nil {
+			return err
+		}
+		ui.Printf("The provisioners have been saved in %s.\n", provisionersFile)
+	}
+
+	var templates []string
+	for name := range b.Files {
+		if strings.HasPrefix(name, templatesDir) {
+			templates = append(templates, name)
+		}
+	}
+	sort.Strings(templates)
+	for _, name := range templates {
+		if err := writeFile(filepath.Join(config.StepPath(), filepath.FromSlash(name)), b.Files[name]); err != nil {
+			return err
+		}
+	}
+	if len(templates) > 0 {
+		ui.Printf("The templates have been saved in %s.\n", filepath.Join(config.StepPath(), "templates"))
+	}
+	return nil
+}
+
+// importDefaults returns the default values of the flags in the bundle with
+// the path of the root certificate. Bundles without defaults use the CA url
+// in the manifest.
+func importDefaults(b *bundle, rootFile string) ([]byte, error) {
+	m := make(map[string]interface{})
+	if data, ok := b.Files[defaultsName]; ok {
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s", defaultsName)
+		}
+	}
+	if _, ok := m["ca-url"]; !ok && b.Manifest.CAURL != "" {
+		m["ca-url"] = b.Manifest.CAURL
+	}
+	m["fingerprint"] = b.Manifest.Fingerprint
+	m["root"] = rootFile
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, "error marshaling %s", defaultsName)
+	}
+	return append(data, '\n'), nil
+}
+
+// writeFile writes a file of the bundle creating its directory.
+func writeFile(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return errs.FileError(err, filename)
+	}
+	return utils.WriteFile(filename, data, 0600)
+}
@@ -0,0 +1,324 @@
+package admin
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/randutil"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/token"
+	"github.com/smallstep/cli/trace"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// Command returns the admin subcommand.
+func Command() cli.Command {
+	return cli.Command{
+		Name:      "admin",
+		Usage:     "manage the certificate authority remotely using the admin API",
+		UsageText: "step ca admin <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Subcommands: cli.Commands{
+			listCommand(),
+			addCommand(),
+			removeCommand(),
+			provisionerCommand(),
+			policyCommand(),
+		},
+		Description: `The **step ca admin** command group provides facilities for managing the
+administrators, the provisioners and the policies of a certificate authority
+using its admin API, without access to the ca.json.
+
+The requests to the admin API are authenticated with a short-lived token signed
+with the key of an admin certificate, a certificate issued by the CA to one of
+its administrators. The certificate chain is added to the token in the x5c
+header. A token can also be passed directly using the **--admin-token** flag.
+
+## EXAMPLES
+
+List the administrators of the CA:
+'''
+$ step ca admin list --admin-cert admin.crt --admin-key admin.key
+'''
+
+Add a new super administrator:
+'''
+$ step ca admin add bob@example.com admin-jwk --super \
+  --admin-cert admin.crt --admin-key admin.key
+'''
+
+Add a provisioner:
+'''
+$ step ca admin provisioner add provisioner.json \
+  --admin-cert admin.crt --admin-key admin.key
+'''
+
+Set the policy of the CA:
+'''
+$ step ca admin policy set policy.json \
+  --admin-cert admin.crt --admin-key admin.key
+'''`,
+	}
+}
+
+// The flags used by all the admin commands.
+var (
+	caURLFlag = cli.StringFlag{
+		Name:  "ca-url",
+		Usage: "<URI> of the targeted Step Certificate Authority.",
+	}
+
+	rootFlag = cli.StringFlag{
+		Name:  "root",
+		Usage: "The path to the PEM <file> used as the root certificate authority.",
+	}
+
+	adminCertFlag = cli.StringFlag{
+		Name: "admin-cert",
+		Usage: `The <file> with the admin certificate, and optionally its intermediates, used
+to sign the admin token.`,
+	}
+
+	adminKeyFlag = cli.StringFlag{
+		Name: "admin-key",
+		Usage: `The private key <file> of the admin certificate. The key can also be a key URI,
+e.g. yubikey:9a.`,
+	}
+
+	adminProvisionerFlag = cli.StringFlag{
+		Name:  "admin-provisioner",
+		Usage: `The <name> of the provisioner of the admin, used as the issuer of the admin token.`,
+	}
+
+	adminSubjectFlag = cli.StringFlag{
+		Name: "admin-subject",
+		Usage: `The <subject> of the admin used in the admin token. Defaults to the common name
+of the admin certificate.`,
+	}
+
+	adminTokenFlag = cli.StringFlag{
+		Name:  "admin-token",
+		Usage: `The admin <token> used to authenticate the requests instead of the admin certificate.`,
+	}
+)
+
+// adminFlags returns the flags used to connect and authenticate to the admin
+// API, followed by the given ones.
+func adminFlags(extra ...cli.Flag) []cli.Flag {
+	fs := append([]cli.Flag{
+		caURLFlag,
+		rootFlag,
+		adminCertFlag,
+		adminKeyFlag,
+		adminProvisionerFlag,
+		adminSubjectFlag,
+		adminTokenFlag,
+	}, flags.Password(flags.PasswordFile)...)
+	return append(fs, extra...)
+}
+
+// adminClient is the client used to make requests to the admin API.
+type adminClient struct {
+	client *http.Client
+	caURL  *url.URL
+	token  string
+}
+
+// newAdminClient creates the client to the admin API using the ca-url, root
+// and admin flags.
+func newAdminClient(ctx *cli.Context) (*adminClient, error) {
+	caURL := ctx.String("ca-url")
+	if caURL == "" {
+		return nil, errs.RequiredFlag(ctx, "ca-url")
+	}
+	u, err := url.Parse(caURL)
+	if err != nil || u.Host == "" {
+		return nil, errs.InvalidFlagValue(ctx, "ca-url", caURL, "")
+	}
+	u.Scheme = "https"
+
+	root := ctx.String("root")
+	if root == "" {
+		root = pki.GetRootCAPath()
+		if !utils.FileExists(root) {
+			return nil, errs.RequiredFlag(ctx, "root")
+		}
+	}
+	pool, err := x509util.ReadCertPool(root)
+	if err != nil {
+		return nil, err
+	}
+
+	tok := ctx.String("admin-token")
+	if tok == "" {
+		if tok, err = newAdminToken(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+
+	return &adminClient{
+		client: &http.Client{
+			Transport: trace.Transport(&http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{
+					RootCAs:                  pool,
+					PreferServerCipherSuites: true,
+				},
+			}),
+		},
+		caURL: u,
+		token: tok,
+	}, nil
+}
+
+// newAdminToken creates the token used to authenticate to the admin API. The
+// token is signed with the key of the admin certificate and it includes the
+// certificate chain in the x5c header.
+func newAdminToken(ctx *cli.Context, caURL *url.URL) (string, error) {
+	certFile, keyFile := ctx.String("admin-cert"), ctx.String("admin-key")
+	switch {
+	case certFile == "":
+		return "", errs.RequiredUnlessFlag(ctx, "admin-cert", "admin-token")
+	case keyFile == "":
+		return "", errs.RequiredWithFlag(ctx, "admin-cert", "admin-key")
+	}
+
+	certs, err := pemutil.ReadCertificateBundle(certFile)
+	if err != nil {
+		return "", err
+	}
+	x5c := make([]string, len(certs))
+	for i, crt := range certs {
+		x5c[i] = base64.StdEncoding.EncodeToString(crt.Raw)
+	}
+
+	var opts []jose.Option
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(password) != 0 {
+		opts = append(opts, jose.WithPassword(password))
+	}
+	jwk, err := jose.ParseKey(keyFile, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	subject := ctx.String("admin-subject")
+	if subject == "" {
+		subject = certs[0].Subject.CommonName
+	}
+	tokOptions := []token.Options{
+		token.WithSubject(subject),
+		token.WithAudience(caURL.ResolveReference(&url.URL{Path: "/admin"}).String()),
+	}
+	if issuer := ctx.String("admin-provisioner"); issuer != "" {
+		tokOptions = append(tokOptions, token.WithIssuer(issuer))
+	}
+	jti, err := randutil.Hex(64)
+	if err != nil {
+		return "", err
+	}
+	tokOptions = append(tokOptions, token.WithJWTID(jti))
+	claims, err := token.NewClaims(tokOptions...)
+	if err != nil {
+		return "", err
+	}
+	claims.SetHeader("x5c", x5c)
+	return claims.Sign(jose.SignatureAlgorithm(jwk.Algorithm), jwk.Key)
+}
+
+// apiError is the error returned by the admin API.
+type apiError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// do sends a request with the given method to the path of the admin API. The
+// in value, if not nil, is sent as the JSON body, and the response is decoded
+// into the out value if it's not nil.
+func (c *adminClient) do(method, path string, in, out interface{}) error {
+	var body io.Reader = http.NoBody
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling request")
+		}
+		body = bytes.NewReader(b)
+	}
+
+	u := c.caURL.ResolveReference(&url.URL{Path: path})
+	if i := strings.Index(path, "?"); i >= 0 {
+		u = c.caURL.ResolveReference(&url.URL{Path: path[:i], RawQuery: path[i+1:]})
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return errors.Wrap(err, "error creating request")
+	}
+	req.Header.Set("Authorization", c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errs.Network(errors.Wrapf(err, "error requesting %s", u))
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errs.Network(errors.Wrapf(err, "error reading %s", u))
+	}
+
+	if resp.StatusCode >= 400 {
+		var e apiError
+		if json.Unmarshal(b, &e) == nil && e.Message != "" {
+			return errors.Errorf("error requesting %s: %s", u, e.Message)
+		}
+		return errors.Errorf("error requesting %s: %s", u, resp.Status)
+	}
+	if out != nil && len(b) > 0 {
+		if err := json.Unmarshal(b, out); err != nil {
+			return errors.Wrapf(err, "error parsing response from %s", u)
+		}
+	}
+	return nil
+}
+
+// printJSON prints the given value as indented JSON.
+func printJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "   ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling response")
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+// readJSONFile reads the JSON object in the given file.
+func readJSONFile(filename string) (json.RawMessage, error) {
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var v json.RawMessage
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	return v, nil
+}
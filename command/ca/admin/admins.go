@@ -0,0 +1,244 @@
+package admin
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+// Admin types.
+const (
+	typeAdmin      = "ADMIN"
+	typeSuperAdmin = "SUPER_ADMIN"
+)
+
+// adminInfo is an administrator of the CA.
+type adminInfo struct {
+	ID            string `json:"id"`
+	Subject       string `json:"subject"`
+	ProvisionerID string `json:"provisionerId,omitempty"`
+	Provisioner   string `json:"provisioner,omitempty"`
+	Type          string `json:"type"`
+}
+
+type adminsResponse struct {
+	Admins     []*adminInfo `json:"admins"`
+	NextCursor string       `json:"nextCursor"`
+}
+
+type addAdminRequest struct {
+	Subject     string `json:"subject"`
+	Provisioner string `json:"provisioner"`
+	Type        string `json:"type"`
+}
+
+func listCommand() cli.Command {
+	return cli.Command{
+		Name:   "list",
+		Action: command.ActionFunc(listAction),
+		Usage:  "list the administrators of the CA",
+		UsageText: `**step ca admin list** [**--subject**=<subject>] [**--provisioner**=<name>]
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin list** prints a JSON list with the administrators of the CA.
+
+## EXAMPLES
+
+List all the administrators:
+'''
+$ step ca admin list --admin-cert admin.crt --admin-key admin.key
+'''
+
+List the administrators of a provisioner:
+'''
+$ step ca admin list --provisioner admin-jwk --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(
+			cli.StringFlag{
+				Name:  "subject",
+				Usage: "Only list the administrators with the given <subject>.",
+			},
+			cli.StringFlag{
+				Name:  "provisioner",
+				Usage: "Only list the administrators of the provisioner with the given <name>.",
+			},
+		),
+	}
+}
+
+func listAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	admins, err := client.getAdmins(ctx.String("subject"), ctx.String("provisioner"))
+	if err != nil {
+		return err
+	}
+	return printJSON(admins)
+}
+
+func addCommand() cli.Command {
+	return cli.Command{
+		Name:   "add",
+		Action: command.ActionFunc(addAction),
+		Usage:  "add an administrator to the CA",
+		UsageText: `**step ca admin add** <subject> <provisioner> [**--super**]
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin add** adds an administrator to the CA. The administrator will
+be able to use the admin API with certificates with the given subject issued by
+the given provisioner.
+
+## POSITIONAL ARGUMENTS
+
+<subject>
+:  The subject of the administrator, usually an email address.
+
+<provisioner>
+:  The name of the provisioner that authenticates the administrator.
+
+## EXAMPLES
+
+Add an administrator:
+'''
+$ step ca admin add alice@example.com admin-jwk \
+  --admin-cert admin.crt --admin-key admin.key
+'''
+
+Add a super administrator, an administrator that can manage other administrators:
+'''
+$ step ca admin add bob@example.com admin-jwk --super \
+  --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(
+			cli.BoolFlag{
+				Name:  "super",
+				Usage: "Add a super administrator, that can also manage other administrators.",
+			},
+		),
+	}
+}
+
+func addAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 2); err != nil {
+		return err
+	}
+
+	args := ctx.Args()
+	req := &addAdminRequest{
+		Subject:     args.Get(0),
+		Provisioner: args.Get(1),
+		Type:        typeAdmin,
+	}
+	if ctx.Bool("super") {
+		req.Type = typeSuperAdmin
+	}
+
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	var adm adminInfo
+	if err := client.do("POST", "/admin/admins", req, &adm); err != nil {
+		return err
+	}
+	return printJSON(adm)
+}
+
+func removeCommand() cli.Command {
+	return cli.Command{
+		Name:   "remove",
+		Action: command.ActionFunc(removeAction),
+		Usage:  "remove an administrator from the CA",
+		UsageText: `**step ca admin remove** <subject> [**--provisioner**=<name>]
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin remove** removes an administrator from the CA.
+
+## POSITIONAL ARGUMENTS
+
+<subject>
+:  The subject of the administrator to remove.
+
+## EXAMPLES
+
+Remove an administrator:
+'''
+$ step ca admin remove alice@example.com --admin-cert admin.crt --admin-key admin.key
+'''
+
+Remove an administrator with the same subject in multiple provisioners:
+'''
+$ step ca admin remove alice@example.com --provisioner admin-jwk \
+  --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(
+			cli.StringFlag{
+				Name: "provisioner",
+				Usage: `The <name> of the provisioner of the administrator. Required if there are
+administrators with the same subject in multiple provisioners.`,
+			},
+		),
+	}
+}
+
+func removeAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	subject := ctx.Args().Get(0)
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	admins, err := client.getAdmins(subject, ctx.String("provisioner"))
+	if err != nil {
+		return err
+	}
+	switch len(admins) {
+	case 0:
+		return errors.Errorf("administrator '%s' not found", subject)
+	case 1:
+	default:
+		return errs.RequiredFlag(ctx, "provisioner")
+	}
+
+	if err := client.do("DELETE", "/admin/admins/"+url.PathEscape(admins[0].ID), nil, nil); err != nil {
+		return err
+	}
+	ui.Printf("The administrator %s has been removed.\n", subject)
+	return nil
+}
+
+// getAdmins returns all the administrators of the CA, optionally filtered by
+// subject and provisioner name.
+func (c *adminClient) getAdmins(subject, provisioner string) ([]*adminInfo, error) {
+	var admins []*adminInfo
+	q := url.Values{}
+	q.Set("limit", "100")
+	for {
+		var resp adminsResponse
+		if err := c.do("GET", "/admin/admins?"+q.Encode(), nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, adm := range resp.Admins {
+			if (subject == "" || adm.Subject == subject) && (provisioner == "" || adm.Provisioner == provisioner) {
+				admins = append(admins, adm)
+			}
+		}
+		if resp.NextCursor == "" {
+			return admins, nil
+		}
+		q.Set("cursor", resp.NextCursor)
+	}
+}
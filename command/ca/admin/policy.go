@@ -0,0 +1,188 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+var policyProvisionerFlag = cli.StringFlag{
+	Name: "provisioner",
+	Usage: `The <name> of the provisioner to manage. By default the policy of the CA is
+managed.`,
+}
+
+// policyPath returns the path of the authority policy or the policy of the
+// provisioner in the --provisioner flag.
+func policyPath(ctx *cli.Context) string {
+	if name := ctx.String("provisioner"); name != "" {
+		return "/admin/provisioners/" + url.PathEscape(name) + "/policy"
+	}
+	return "/admin/policy"
+}
+
+func policyCommand() cli.Command {
+	return cli.Command{
+		Name:      "policy",
+		Usage:     "manage the certificate issuance policies of the CA",
+		UsageText: "step ca admin policy <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Subcommands: cli.Commands{
+			policyGetCommand(),
+			policySetCommand(),
+			policyRemoveCommand(),
+		},
+		Description: `**step ca admin policy** command group provides facilities for managing the
+policies of the CA or of one of its provisioners. A policy defines the names
+allowed or denied in the certificates issued by the CA, for example:
+
+'''
+{
+  "x509": {
+    "allow": {"dns": ["*.internal.example.com"], "ips": ["10.0.0.0/8"]},
+    "deny": {"dns": ["db.internal.example.com"]}
+  }
+}
+'''
+
+## EXAMPLES
+
+Print the policy of the CA:
+'''
+$ step ca admin policy get --admin-cert admin.crt --admin-key admin.key
+'''
+
+Set the policy of a provisioner:
+'''
+$ step ca admin policy set policy.json --provisioner acme \
+  --admin-cert admin.crt --admin-key admin.key
+'''`,
+	}
+}
+
+func policyGetCommand() cli.Command {
+	return cli.Command{
+		Name:   "get",
+		Action: command.ActionFunc(policyGetAction),
+		Usage:  "print the policy of the CA or a provisioner",
+		UsageText: `**step ca admin policy get** [**--provisioner**=<name>]
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin policy get** prints the policy of the CA or a provisioner.
+
+## EXAMPLES
+
+Print the policy of the CA:
+'''
+$ step ca admin policy get --admin-cert admin.crt --admin-key admin.key
+'''
+
+Print the policy of a provisioner:
+'''
+$ step ca admin policy get --provisioner acme --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(policyProvisionerFlag),
+	}
+}
+
+func policyGetAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	var policy json.RawMessage
+	if err := client.do("GET", policyPath(ctx), nil, &policy); err != nil {
+		return err
+	}
+	return printJSON(policy)
+}
+
+func policySetCommand() cli.Command {
+	return cli.Command{
+		Name:   "set",
+		Action: command.ActionFunc(policySetAction),
+		Usage:  "set the policy of the CA or a provisioner",
+		UsageText: `**step ca admin policy set** <file> [**--provisioner**=<name>]
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin policy set** replaces the policy of the CA or a provisioner
+with the one in the given JSON file.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The JSON file with the policy.
+
+## EXAMPLES
+
+Set the policy of the CA:
+'''
+$ step ca admin policy set policy.json --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(policyProvisionerFlag),
+	}
+}
+
+func policySetAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	policy, err := readJSONFile(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	var resp json.RawMessage
+	if err := client.do("PUT", policyPath(ctx), policy, &resp); err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func policyRemoveCommand() cli.Command {
+	return cli.Command{
+		Name:   "remove",
+		Action: command.ActionFunc(policyRemoveAction),
+		Usage:  "remove the policy of the CA or a provisioner",
+		UsageText: `**step ca admin policy remove** [**--provisioner**=<name>]
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin policy remove** removes the policy of the CA or a provisioner.
+Without a policy all the names allowed by the provisioners can be issued.
+
+## EXAMPLES
+
+Remove the policy of a provisioner:
+'''
+$ step ca admin policy remove --provisioner acme --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(policyProvisionerFlag),
+	}
+}
+
+func policyRemoveAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.do("DELETE", policyPath(ctx), nil, nil); err != nil {
+		return err
+	}
+	ui.Printf("The policy has been removed.\n")
+	return nil
+}
@@ -0,0 +1,287 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+type provisionersResponse struct {
+	Provisioners []json.RawMessage `json:"provisioners"`
+	NextCursor   string            `json:"nextCursor"`
+}
+
+func provisionerCommand() cli.Command {
+	return cli.Command{
+		Name:      "provisioner",
+		Usage:     "manage the provisioners of the CA using the admin API",
+		UsageText: "step ca admin provisioner <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Subcommands: cli.Commands{
+			provisionerListCommand(),
+			provisionerGetCommand(),
+			provisionerAddCommand(),
+			provisionerUpdateCommand(),
+			provisionerRemoveCommand(),
+		},
+		Description: `**step ca admin provisioner** command group provides facilities for managing
+the provisioners of a CA using the admin API. The changes are applied by the CA
+without a restart.
+
+The provisioners are described using the same JSON objects used in the
+"provisioners" list of the ca.json.
+
+## EXAMPLES
+
+List the provisioners:
+'''
+$ step ca admin provisioner list --admin-cert admin.crt --admin-key admin.key
+'''
+
+Add a provisioner:
+'''
+$ cat acme.json
+{"type": "ACME", "name": "acme"}
+$ step ca admin provisioner add acme.json --admin-cert admin.crt --admin-key admin.key
+'''
+
+Remove a provisioner:
+'''
+$ step ca admin provisioner remove acme --admin-cert admin.crt --admin-key admin.key
+'''`,
+	}
+}
+
+func provisionerListCommand() cli.Command {
+	return cli.Command{
+		Name:   "list",
+		Action: command.ActionFunc(provisionerListAction),
+		Usage:  "list the provisioners of the CA",
+		UsageText: `**step ca admin provisioner list**
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin provisioner list** prints a JSON list with the provisioners of
+the CA, including the ones added with the admin API.
+
+## EXAMPLES
+
+List the provisioners:
+'''
+$ step ca admin provisioner list --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(),
+	}
+}
+
+func provisionerListAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	provisioners := []json.RawMessage{}
+	q := url.Values{}
+	q.Set("limit", "100")
+	for {
+		var resp provisionersResponse
+		if err := client.do("GET", "/admin/provisioners?"+q.Encode(), nil, &resp); err != nil {
+			return err
+		}
+		provisioners = append(provisioners, resp.Provisioners...)
+		if resp.NextCursor == "" {
+			return printJSON(provisioners)
+		}
+		q.Set("cursor", resp.NextCursor)
+	}
+}
+
+func provisionerGetCommand() cli.Command {
+	return cli.Command{
+		Name:   "get",
+		Action: command.ActionFunc(provisionerGetAction),
+		Usage:  "print a provisioner of the CA",
+		UsageText: `**step ca admin provisioner get** <name>
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin provisioner get** prints the provisioner with the given name.
+
+## POSITIONAL ARGUMENTS
+
+<name>
+:  The name of the provisioner.
+
+## EXAMPLES
+
+Print a provisioner:
+'''
+$ step ca admin provisioner get acme --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(),
+	}
+}
+
+func provisionerGetAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	var p json.RawMessage
+	if err := client.do("GET", "/admin/provisioners/"+url.PathEscape(ctx.Args().Get(0)), nil, &p); err != nil {
+		return err
+	}
+	return printJSON(p)
+}
+
+func provisionerAddCommand() cli.Command {
+	return cli.Command{
+		Name:   "add",
+		Action: command.ActionFunc(provisionerAddAction),
+		Usage:  "add a provisioner to the CA",
+		UsageText: `**step ca admin provisioner add** <file>
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin provisioner add** adds the provisioner described in a JSON
+file to the CA.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The JSON file with the provisioner.
+
+## EXAMPLES
+
+Add an ACME provisioner:
+'''
+$ cat acme.json
+{"type": "ACME", "name": "acme"}
+$ step ca admin provisioner add acme.json --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(),
+	}
+}
+
+func provisionerAddAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	p, err := readJSONFile(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	var resp json.RawMessage
+	if err := client.do("POST", "/admin/provisioners", p, &resp); err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func provisionerUpdateCommand() cli.Command {
+	return cli.Command{
+		Name:   "update",
+		Action: command.ActionFunc(provisionerUpdateAction),
+		Usage:  "update a provisioner of the CA",
+		UsageText: `**step ca admin provisioner update** <name> <file>
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin provisioner update** replaces the provisioner with the given
+name with the one described in a JSON file.
+
+## POSITIONAL ARGUMENTS
+
+<name>
+:  The name of the provisioner to update.
+
+<file>
+:  The JSON file with the new provisioner.
+
+## EXAMPLES
+
+Update a provisioner using the current one as a template:
+'''
+$ step ca admin provisioner get acme --admin-cert admin.crt --admin-key admin.key > acme.json
+$ vi acme.json
+$ step ca admin provisioner update acme acme.json --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(),
+	}
+}
+
+func provisionerUpdateAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 2); err != nil {
+		return err
+	}
+
+	args := ctx.Args()
+	p, err := readJSONFile(args.Get(1))
+	if err != nil {
+		return err
+	}
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	var resp json.RawMessage
+	if err := client.do("PUT", "/admin/provisioners/"+url.PathEscape(args.Get(0)), p, &resp); err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func provisionerRemoveCommand() cli.Command {
+	return cli.Command{
+		Name:   "remove",
+		Action: command.ActionFunc(provisionerRemoveAction),
+		Usage:  "remove a provisioner from the CA",
+		UsageText: `**step ca admin provisioner remove** <name>
+[**--ca-url**=<uri>] [**--root**=<file>] [**--admin-cert**=<file>] [**--admin-key**=<file>]
+[**--admin-provisioner**=<name>] [**--admin-subject**=<subject>] [**--admin-token**=<token>]`,
+		Description: `**step ca admin provisioner remove** removes the provisioner with the given
+name from the CA.
+
+## POSITIONAL ARGUMENTS
+
+<name>
+:  The name of the provisioner to remove.
+
+## EXAMPLES
+
+Remove a provisioner:
+'''
+$ step ca admin provisioner remove acme --admin-cert admin.crt --admin-key admin.key
+'''`,
+		Flags: adminFlags(),
+	}
+}
+
+func provisionerRemoveAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	name := ctx.Args().Get(0)
+	client, err := newAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.do("DELETE", "/admin/provisioners/"+url.PathEscape(name), nil, nil); err != nil {
+		return err
+	}
+	ui.Printf("The provisioner %s has been removed.\n", name)
+	return nil
+}
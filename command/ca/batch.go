@@ -0,0 +1,449 @@
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli/crypto/keys"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/step"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ed25519"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// defaultConcurrency is the default number of certificates issued at the same
+// time in batch mode.
+const defaultConcurrency = 8
+
+// batchEntry is an entry in the manifest used by step ca certificate
+// --manifest.
+type batchEntry struct {
+	Subject string   `json:"subject" yaml:"subject"`
+	SANs    []string `json:"sans" yaml:"sans"`
+	Crt     string   `json:"crt" yaml:"crt"`
+	Key     string   `json:"key" yaml:"key"`
+	Kty     string   `json:"kty" yaml:"kty"`
+	Curve   string   `json:"crv" yaml:"crv"`
+	Size    int      `json:"size" yaml:"size"`
+	CrtMode string   `json:"crtMode" yaml:"crtMode"`
+	KeyMode string   `json:"keyMode" yaml:"keyMode"`
+	Owner   string   `json:"owner" yaml:"owner"`
+	Group   string   `json:"group" yaml:"group"`
+}
+
+// batchStatus is the outcome of a batchEntry.
+type batchStatus int
+
+const (
+	batchIssued batchStatus = iota
+	batchSkipped
+	batchFailed
+)
+
+// batchResult is the result of the issuance of a batchEntry.
+type batchResult struct {
+	status batchStatus
+	crt    []byte
+	key    crypto.PrivateKey
+	err    error
+}
+
+// executeTemplates resolves the template variables in the subject, SANs and
+// files of the entry.
+func (e *batchEntry) executeTemplates(vars *templateVars) error {
+	var err error
+	if e.Subject, err = vars.Execute(e.Subject); err != nil {
+		return err
+	}
+	if e.SANs, err = vars.ExecuteAll(e.SANs); err != nil {
+		return err
+	}
+	if e.Crt, err = vars.Execute(e.Crt); err != nil {
+		return err
+	}
+	e.Key, err = vars.Execute(e.Key)
+	return err
+}
+
+// readBatchManifest reads and validates the given manifest. The manifest is a
+// list of batch entries in YAML or JSON format; files with the extension .yaml
+// or .yml are parsed as YAML. Template variables in the entries are resolved
+// before the validation.
+func readBatchManifest(ctx *cli.Context, filename string) ([]batchEntry, error) {
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var entries []batchEntry
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(b, &entries)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&entries)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", filename)
+	}
+	if len(entries) == 0 {
+		return nil, errors.Errorf("error reading %s: the manifest does not have any entries", filename)
+	}
+
+	var vars *templateVars
+	for i := range entries {
+		e := &entries[i]
+		if !hasTemplateVars(append([]string{e.Subject, e.Crt, e.Key}, e.SANs...)...) {
+			continue
+		}
+		if vars == nil {
+			if vars, err = newTemplateVars(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if err := e.executeTemplates(vars); err != nil {
+			return nil, errors.Wrapf(err, "error reading %s: entry %d", filename, i)
+		}
+	}
+
+	files := make(map[string]bool)
+	for i, e := range entries {
+		switch {
+		case e.Subject == "":
+			return nil, errors.Errorf("error reading %s: entry %d does not have a subject", filename, i)
+		case e.Crt == "":
+			return nil, errors.Errorf("error reading %s: entry %d does not have a crt file", filename, i)
+		case e.Key == "":
+			return nil, errors.Errorf("error reading %s: entry %d does not have a key file", filename, i)
+		}
+		for _, fn := range []string{e.Crt, e.Key} {
+			if files[fn] {
+				return nil, errors.Errorf("error reading %s: file %s is used more than once", filename, fn)
+			}
+			files[fn] = true
+		}
+		for _, mode := range []string{e.CrtMode, e.KeyMode} {
+			if mode == "" {
+				continue
+			}
+			if _, err := utils.ParseFileMode(mode); err != nil {
+				return nil, errors.Errorf("error reading %s: entry %d has an invalid mode %s", filename, i, mode)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// batchCertificateAction issues the certificates in the manifest passed in
+// the --manifest flag. Keys are generated and certificates are signed
+// concurrently, but the files are written in the order of the manifest.
+// Certificates that are still valid are not issued again.
+func batchCertificateAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+	for _, flag := range []string{"token", "kms", "san", "vault-path"} {
+		if ctx.IsSet(flag) {
+			return errs.IncompatibleFlagWithFlag(ctx, "manifest", flag)
+		}
+	}
+	concurrency := ctx.Int("concurrency")
+	if concurrency <= 0 {
+		return errs.InvalidFlagValue(ctx, "concurrency", ctx.String("concurrency"), "")
+	}
+	var expiresIn time.Duration
+	if s := ctx.String("expires-in"); len(s) > 0 {
+		var err error
+		if expiresIn, err = time.ParseDuration(s); err != nil {
+			return errs.InvalidFlagValue(ctx, "expires-in", s, "")
+		}
+	}
+
+	jitter, err := parseJitter(ctx)
+	if err != nil {
+		return err
+	}
+	limiter, err := newRateLimiter(ctx)
+	if err != nil {
+		return err
+	}
+
+	entries, err := readBatchManifest(ctx, ctx.String("manifest"))
+	if err != nil {
+		return err
+	}
+
+	notBefore, notAfter, err := parseTimeDuration(ctx)
+	if err != nil {
+		return err
+	}
+
+	flow, err := newCertificateFlow(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The provisioner key is decrypted only once, and the tokens are
+	// generated right before they are used, so they don't expire in large
+	// batches.
+	var client caClient
+	var generator *tokenGenerator
+	if flow.offline {
+		if generator, err = flow.offlineCA.TokenGenerator(ctx, signType); err != nil {
+			return err
+		}
+		client = flow.offlineCA
+	} else {
+		caURL := ctx.String("ca-url")
+		if len(caURL) == 0 {
+			return errs.RequiredFlag(ctx, "ca-url")
+		}
+		root := ctx.String("root")
+		if len(root) == 0 {
+			root = pki.GetRootCAPath()
+			if _, err := os.Stat(root); err != nil {
+				return errs.RequiredFlag(ctx, "root")
+			}
+		}
+		if generator, err = newTokenGenerator(ctx, signType, caURL, root); err != nil {
+			return err
+		}
+		ui.PrintSelected("CA", caURL)
+		opt, err := limiter.WithRootFile(root)
+		if err != nil {
+			return err
+		}
+		if client, err = ca.NewClient(caURL, opt); err != nil {
+			return err
+		}
+	}
+
+	results := make([]chan batchResult, len(entries))
+	for i := range results {
+		results[i] = make(chan batchResult, 1)
+	}
+
+	// The random delay is only applied before the first issuance, the
+	// certificates that are still valid do not need to wait.
+	wait := jitterOnce(jitter)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(entries); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				e := entries[i]
+				if validBatchCertificate(e, expiresIn) {
+					results[i] <- batchResult{status: batchSkipped}
+					continue
+				}
+				wait()
+				results[i] <- issueBatchEntry(flow, client, generator, e, notBefore, notAfter)
+			}
+		}()
+	}
+	go func() {
+		for i := range entries {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	// Files are written in the main goroutine, so overwrite prompts are
+	// never mixed.
+	var issued, skipped int
+	var failures []string
+	progress := ui.NewProgress("Issuing certificates", int64(len(entries)))
+	for i, e := range entries {
+		r := <-results[i]
+		if r.status == batchIssued {
+			r.err = writeBatchResult(e, r)
+		}
+		switch {
+		case r.err != nil:
+			failures = append(failures, fmt.Sprintf("%s: %v", e.Subject, r.err))
+		case r.status == batchSkipped:
+			skipped++
+		default:
+			issued++
+		}
+		progress.Add(1)
+	}
+	progress.Done()
+	wg.Wait()
+
+	ui.Printf("Issued %d, skipped %d (still valid), failed %d of %d certificates.\n",
+		issued, skipped, len(failures), len(entries))
+	for _, f := range failures {
+		ui.Printf("error issuing certificate for %s\n", f)
+	}
+	if len(failures) > 0 {
+		return errors.Errorf("%d of %d certificates could not be issued", len(failures), len(entries))
+	}
+	return nil
+}
+
+// issueBatchEntry generates the key of the given entry and requests its
+// certificate.
+func issueBatchEntry(flow *certificateFlow, client caClient, generator *tokenGenerator, e batchEntry, notBefore, notAfter api.TimeDuration) batchResult {
+	var pk crypto.PrivateKey
+	if e.Kty != "" {
+		key, err := keys.GenerateKey(e.Kty, e.Curve, e.Size)
+		if err != nil {
+			return batchResult{status: batchFailed, err: err}
+		}
+		pk = key
+	}
+
+	tok, err := generator.Token(signType, e.Subject, e.SANs)
+	if err != nil {
+		return batchResult{status: batchFailed, err: err}
+	}
+	req, pk, err := flow.CreateSignRequest(tok, e.Subject, e.SANs, pk)
+	if err != nil {
+		return batchResult{status: batchFailed, err: err}
+	}
+	crt, err := signCertificate(client, tok, req.CsrPEM, notBefore, notAfter)
+	if err != nil {
+		return batchResult{status: batchFailed, err: err}
+	}
+	return batchResult{status: batchIssued, crt: crt, key: pk}
+}
+
+// writeBatchResult writes the certificate and key of an entry. The
+// permissions and owners in the entry take precedence over the --mode, --owner
+// and --group flags.
+func writeBatchResult(e batchEntry, r batchResult) error {
+	if err := utils.WriteFile(e.Crt, r.crt, 0600); err != nil {
+		return err
+	}
+	if _, err := pemutil.Serialize(r.key, pemutil.ToFile(e.Key, 0600)); err != nil {
+		return err
+	}
+
+	uid, gid, err := utils.LookupOwner(e.Owner, e.Group)
+	if err != nil {
+		return err
+	}
+	for fn, mode := range map[string]string{e.Crt: e.CrtMode, e.Key: e.KeyMode} {
+		if mode != "" {
+			perm, _ := utils.ParseFileMode(mode)
+			if err := os.Chmod(fn, perm); err != nil {
+				return errs.FileError(err, fn)
+			}
+		}
+		if uid != -1 || gid != -1 {
+			if err := os.Chown(fn, uid, gid); err != nil {
+				return errs.FileError(err, fn)
+			}
+		}
+	}
+	return nil
+}
+
+// validBatchCertificate returns true if the files of the given entry contain
+// a certificate that matches the entry and does not need to be renewed. A
+// certificate needs to be renewed if it expires in less than expiresIn, or if
+// expiresIn is 0, after 2/3 of its validity period.
+func validBatchCertificate(e batchEntry, expiresIn time.Duration) bool {
+	crt, err := pemutil.ReadCertificate(e.Crt, pemutil.WithFirstBlock())
+	if err != nil {
+		return false
+	}
+	key, err := pemutil.Read(e.Key)
+	if err != nil {
+		return false
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return false
+	}
+	pub, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil || !bytes.Equal(pub, crt.RawSubjectPublicKeyInfo) {
+		return false
+	}
+
+	now := time.Now()
+	if expiresIn <= 0 {
+		expiresIn = crt.NotAfter.Sub(crt.NotBefore) / 3
+	}
+	if now.Before(crt.NotBefore) || crt.NotAfter.Sub(now) <= expiresIn {
+		return false
+	}
+
+	if !strings.EqualFold(crt.Subject.CommonName, e.Subject) || !matchKeyType(signer.Public(), e) {
+		return false
+	}
+	sans := e.SANs
+	if len(sans) == 0 {
+		sans = []string{e.Subject}
+	}
+	want := normalizeSANs(step.SplitSANs(sans))
+	got := normalizeSANs(crt.DNSNames, crt.IPAddresses)
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeSANs returns the sorted list of lowercase DNS names and IP
+// addresses.
+func normalizeSANs(dnsNames []string, ips []net.IP) []string {
+	var sans []string
+	for _, s := range dnsNames {
+		sans = append(sans, strings.ToLower(s))
+	}
+	for _, ip := range ips {
+		sans = append(sans, ip.String())
+	}
+	sort.Strings(sans)
+	return sans
+}
+
+// matchKeyType returns true if the public key has the key type and size or
+// curve of the entry.
+func matchKeyType(pub crypto.PublicKey, e batchEntry) bool {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch {
+		case e.Kty == "":
+			return k.Curve.Params().Name == "P-256"
+		case e.Kty != "EC":
+			return false
+		default:
+			return e.Curve == "" || e.Curve == k.Curve.Params().Name
+		}
+	case *rsa.PublicKey:
+		return e.Kty == "RSA" && (e.Size == 0 || e.Size == k.N.BitLen())
+	case ed25519.PublicKey:
+		return e.Kty == "OKP"
+	default:
+		return false
+	}
+}
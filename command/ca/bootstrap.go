@@ -1,18 +1,18 @@
 package ca
 
 import (
-	"encoding/json"
-	"os"
+	"net/http"
 	"path/filepath"
+	"strings"
 
-	"github.com/pkg/errors"
-	"github.com/smallstep/certificates/ca"
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/config"
-	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/credstore"
 	"github.com/smallstep/cli/crypto/pki"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/step"
+	"github.com/smallstep/cli/trace"
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	"github.com/smallstep/truststore"
@@ -21,19 +21,58 @@ import (
 
 func bootstrapCommand() cli.Command {
 	return cli.Command{
-		Name:      "bootstrap",
-		Action:    command.ActionFunc(bootstrapAction),
-		Usage:     "initialize the environment to use the CA commands",
-		UsageText: `**step ca bootstrap** [**--ca-url**=<uri>] [**--fingerprint**=<fingerprint>] [**--install**]`,
+		Name:   "bootstrap",
+		Action: command.ActionFunc(bootstrapAction),
+		Usage:  "initialize the environment to use the CA commands",
+		UsageText: `**step ca bootstrap** [**--ca-url**=<uri>] [**--fingerprint**=<fingerprint>] [**--install**]
+[**--proxy**=<uri>] [**--discovery-url**=<uri> **--discovery-key**=<file>] [**--dry-run**]`,
 		Description: `**step ca bootstrap** downloads the root certificate from the certificate
 authority and sets up the current environment to use it.
 
 Bootstrap will store the root certificate in <$STEPPATH/certs/root_ca.crt> and
 create a configuration file in <$STEPPATH/configs/defaults.json> with the CA
-url, the root certificate location and its fingerprint.
+url and the root certificate location.
+
+The root certificate is verified against the fingerprint before anything is
+written in $STEPPATH. The download is kept in a temporary file, so if it is
+interrupted it will be resumed on the next attempt, or on the next run of the
+command. Proxies are honored using the HTTPS_PROXY, HTTP_PROXY, and NO_PROXY
+environment variables, or the **--proxy** flag.
+
+The CA url and fingerprint can also be obtained from a discovery bundle, a JWS
+with a JSON payload with the "ca-url" and "fingerprint" properties, signed with
+the key in **--discovery-key**. The flags **--ca-url** and **--fingerprint**
+take precedence over the values in the bundle.
+
+The fingerprint of the root certificate is stored in the credential store of the
+operating system: the login keychain on macOS, the Secret Service on Linux, or
+encrypted with DPAPI on Windows. If the credential store is not available, or if
+the environment variable STEPCREDSTORE is set to "file", the fingerprint will be
+stored in <$STEPPATH/secrets/credentials/fingerprint>.
 
 After the bootstrap, ca commands do not need to specify the flags 
---ca-url, --root or --fingerprint if we want to use the same environment.`,
+--ca-url, --root or --fingerprint if we want to use the same environment.
+
+## EXAMPLES
+
+Bootstrap using a proxy:
+'''
+$ step ca bootstrap --ca-url https://ca.smallstep.com \
+  --fingerprint 0d7d3834cf187726cf331c40a31aa7ef6b29ba4df601416c9788f6ee01058cf3 \
+  --proxy http://proxy.example.com:3128
+'''
+
+Bootstrap using a discovery bundle:
+'''
+$ step ca bootstrap --discovery-url https://example.com/ca.jws --discovery-key discovery.pub
+'''
+
+Print the files that would be written without downloading anything:
+'''
+$ step ca bootstrap --ca-url https://ca.smallstep.com \
+  --fingerprint 0d7d3834cf187726cf331c40a31aa7ef6b29ba4df601416c9788f6ee01058cf3 \
+  --install --dry-run
+'''`,
 		Flags: []cli.Flag{
 			caURLFlag,
 			fingerprintFlag,
@@ -41,15 +80,27 @@ After the bootstrap, ca commands do not need to specify the flags
 				Name:  "install",
 				Usage: "Install the root certificate into the system truststore.",
 			},
+			cli.StringFlag{
+				Name: "proxy",
+				Usage: `The <uri> of the proxy used to connect to the CA. Defaults to the proxy in the
+HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables.`,
+			},
+			cli.StringFlag{
+				Name:  "discovery-url",
+				Usage: "The <uri> of a signed discovery bundle with the CA url and fingerprint.",
+			},
+			cli.StringFlag{
+				Name:  "discovery-key",
+				Usage: "The path to the <file> with the public key used to verify the discovery bundle.",
+			},
+			flags.DryRun,
 			flags.Force},
 	}
 }
 
-type bootstrapConfig struct {
-	CA          string `json:"ca-url"`
-	Fingerprint string `json:"fingerprint"`
-	Root        string `json:"root"`
-}
+// fingerprintCredential is the name of the credential used to store the root
+// fingerprint.
+const fingerprintCredential = "fingerprint"
 
 func bootstrapAction(ctx *cli.Context) error {
 	caURL := ctx.String("ca-url")
@@ -57,6 +108,34 @@ func bootstrapAction(ctx *cli.Context) error {
 	rootFile := pki.GetRootCAPath()
 	configFile := filepath.Join(config.StepPath(), "config", "defaults.json")
 
+	proxy, err := proxyFunc(ctx.String("proxy"))
+	if err != nil {
+		return errs.InvalidFlagValue(ctx, "proxy", ctx.String("proxy"), "")
+	}
+
+	dryRun := ctx.Bool("dry-run")
+	if discoveryURL := ctx.String("discovery-url"); discoveryURL != "" {
+		keyFile := ctx.String("discovery-key")
+		if keyFile == "" {
+			return errs.RequiredWithFlag(ctx, "discovery-url", "discovery-key")
+		}
+		bundle := &discoveryBundle{
+			CAURL:       "<ca-url in the discovery bundle>",
+			Fingerprint: "<fingerprint in the discovery bundle>",
+		}
+		if dryRun {
+			command.DryRunf("Would download the discovery bundle %s and verify it with %s", discoveryURL, keyFile)
+		} else if bundle, err = fetchDiscoveryBundle(trace.Transport(&http.Transport{Proxy: proxy}), discoveryURL, keyFile); err != nil {
+			return err
+		}
+		if caURL == "" {
+			caURL = bundle.CAURL
+		}
+		if fingerprint == "" {
+			fingerprint = bundle.Fingerprint
+		}
+	}
+
 	switch {
 	case len(caURL) == 0:
 		return errs.RequiredFlag(ctx, "ca-url")
@@ -64,53 +143,54 @@ func bootstrapAction(ctx *cli.Context) error {
 		return errs.RequiredFlag(ctx, "fingerprint")
 	}
 
-	tr := getInsecureTransport()
-	client, err := ca.NewClient(caURL, ca.WithTransport(tr))
-	if err != nil {
-		return err
-	}
-
-	// Root already validates the certificate
-	resp, err := client.Root(fingerprint)
-	if err != nil {
-		return errors.Wrap(err, "error downloading root certificate")
+	if dryRun {
+		command.DryRunf("Would download the root certificate from %s/root/%s and verify its fingerprint", strings.TrimSuffix(caURL, "/"), fingerprint)
+		command.DryRunf("Would write the root certificate to %s", rootFile)
+		command.DryRunf("Would store the root fingerprint in the credential store")
+		command.DryRunf("Would write %s with the CA url %s and the root %s", configFile, caURL, rootFile)
+		if ctx.Bool("install") {
+			command.DryRunf("Would install the root certificate in the system truststore")
+		}
+		return nil
 	}
 
-	if err := os.MkdirAll(filepath.Dir(rootFile), 0700); err != nil {
-		return errs.FileError(err, rootFile)
+	// Prompt before downloading so the spinner is not interrupted
+	if !ctx.Bool("force") {
+		for _, filename := range []string{rootFile, configFile} {
+			if err := utils.ConfirmOverwrite(filename); err != nil {
+				return err
+			}
+		}
 	}
 
-	if err := os.MkdirAll(filepath.Dir(configFile), 0700); err != nil {
-		return errs.FileError(err, configFile)
-	}
+	tr := getInsecureTransport()
+	tr.Proxy = proxy
 
-	// Serialize root
-	_, err = pemutil.Serialize(resp.RootPEM.Certificate, pemutil.ToFile(rootFile, 0600))
+	// The root is validated before writing anything
+	spinner := ui.NewSpinner("Downloading root certificate...").Start()
+	_, err = step.Bootstrap(&step.BootstrapOptions{
+		CAURL:       caURL,
+		Fingerprint: fingerprint,
+		RootFile:    rootFile,
+		ConfigFile:  configFile,
+		Transport:   trace.Transport(tr),
+		Force:       true,
+	})
+	spinner.Stop()
 	if err != nil {
 		return err
 	}
 	ui.Printf("The root certificate has been saved in %s.\n", rootFile)
 
-	// make sure to store the url with https
-	caURL, err = completeURL(caURL)
+	// Store the fingerprint in the credential store
+	store, err := credstore.New()
 	if err != nil {
 		return err
 	}
-
-	// Serialize defaults.json
-	b, err := json.MarshalIndent(bootstrapConfig{
-		CA:          caURL,
-		Fingerprint: fingerprint,
-		Root:        pki.GetRootCAPath(),
-	}, "", "  ")
-	if err != nil {
-		return errors.Wrap(err, "error marshaling defaults.json")
-	}
-
-	if err := utils.WriteFile(configFile, b, 0644); err != nil {
+	if err := store.Set(fingerprintCredential, []byte(fingerprint)); err != nil {
 		return err
 	}
-
+	ui.Printf("The root fingerprint has been saved in the %s credential store.\n", store.Name())
 	ui.Printf("Your configuration has been saved in %s.\n", configFile)
 
 	if ctx.Bool("install") {
@@ -1,13 +1,12 @@
 package ca
 
 import (
-	"net/url"
 	"path/filepath"
-	"strings"
 	"time"
 
-	"github.com/pkg/errors"
 	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/command/ca/admin"
+	"github.com/smallstep/cli/command/ca/db"
 	"github.com/smallstep/cli/command/ca/provisioner"
 	"github.com/smallstep/cli/config"
 	"github.com/smallstep/cli/errs"
@@ -45,7 +44,6 @@ $ step ca bootstrap \
 $ cat $STEPPATH/config/defaults.json
 {
   "ca-url": "https://ca.smallstep.com",
-  "fingerprint": "0d7d3834cf187726cf331c40a31aa7ef6b29ba4df601416c9788f6ee01058cf3",
   "root": "/home/user/.step/certs/root_ca.crt"
 }
 '''
@@ -83,10 +81,17 @@ $ step ca renew internal.crt internal.key \
 			renewCertificateCommand(),
 			revokeCertificateCommand(),
 			provisioner.Command(),
+			admin.Command(),
 			signCertificateCommand(),
 			rootComand(),
 			rootsCommand(),
 			federationCommand(),
+			policyCommand(),
+			serveCommand(),
+			ocspResponderCommand(),
+			db.Command(),
+			rotateIntermediateCommand(),
+			entrypointCommand(),
 		},
 	}
 
@@ -149,7 +154,7 @@ $STEPPATH/config/ca.json`,
 	}
 
 	provisionerKidFlag = cli.StringFlag{
-		Name:  "kid",
+		Name:  "kid,provisioner-kid",
 		Usage: "The provisioner <kid> to use.",
 	}
 
@@ -158,6 +163,12 @@ $STEPPATH/config/ca.json`,
 		Usage: "The provisioner <name> to use.",
 	}
 
+	provisionerTypeFlag = cli.StringFlag{
+		Name: "provisioner-type",
+		Usage: `The provisioner <type> to use. The types supported are JWK, OIDC, GCP, AWS and
+Azure.`,
+	}
+
 	passwordFileFlag = cli.StringFlag{
 		Name: "password-file",
 		Usage: `The path to the <file> containing the password to decrypt the one-time token
@@ -165,44 +176,6 @@ generating key.`,
 	}
 )
 
-// completeURL parses and validates the given URL. It supports general
-// URLs like https://ca.smallstep.com[:port][/path], and incomplete URLs like
-// ca.smallstep.com[:port][/path].
-func completeURL(rawurl string) (string, error) {
-	u, err := url.Parse(rawurl)
-	if err != nil {
-		return "", errors.Wrapf(err, "error parsing url '%s'", rawurl)
-	}
-
-	// URLs are generally parsed as:
-	// [scheme:][//[userinfo@]host][/]path[?query][#fragment]
-	// But URLs that do not start with a slash after the scheme are interpreted as
-	// scheme:opaque[?query][#fragment]
-	if u.Opaque == "" {
-		if u.Scheme == "" {
-			u.Scheme = "https"
-		}
-		if u.Host == "" {
-			// rawurl looks like ca.smallstep.com or ca.smallstep.com/1.0/sign
-			if u.Path != "" {
-				parts := strings.SplitN(u.Path, "/", 2)
-				u.Host = parts[0]
-				if len(parts) == 2 {
-					u.Path = parts[1]
-				} else {
-					u.Path = ""
-				}
-				return completeURL(u.String())
-			}
-			return "", errors.Errorf("error parsing url '%s'", rawurl)
-		}
-		return u.String(), nil
-	}
-	// scheme:opaque[?query][#fragment]
-	// rawurl looks like ca.smallstep.com:443 or ca.smallstep.com:443/1.0/sign
-	return completeURL("https://" + rawurl)
-}
-
 // parseValidity parses the not-before and not-after flags as times or durations.
 func parseValidity(ctx *cli.Context) (notBefore time.Time, notAfter time.Time, err error) {
 	var ok bool
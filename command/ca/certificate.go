@@ -2,26 +2,27 @@ package ca
 
 import (
 	"crypto"
-	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
-	"fmt"
-	"net"
+	"io/ioutil"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/api"
-	"github.com/smallstep/certificates/ca"
 	"github.com/smallstep/cli/command"
-	"github.com/smallstep/cli/crypto/keys"
 	"github.com/smallstep/cli/crypto/pemutil"
 	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/spiffe"
 	"github.com/smallstep/cli/crypto/x509util"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/kms"
+	"github.com/smallstep/cli/kms/vault"
+	"github.com/smallstep/cli/step"
 	"github.com/smallstep/cli/token"
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
@@ -34,11 +35,170 @@ func certificateCommand() cli.Command {
 		Action: command.ActionFunc(certificateAction),
 		Usage:  "generate a new private key and certificate signed by the root certificate",
 		UsageText: `**step ca certificate** <subject> <crt-file> <key-file>
-		[**--token**=<token>]  [**--issuer**=<name>] [**--ca-url**=<uri>] [**--root**=<file>]
+		[**--token**=<token>]  [**--issuer**=<name>] [**--kid**=<kid>] [**--provisioner-type**=<type>]
+		[**--ca-url**=<uri>] [**--root**=<file>]
 		[**--not-before**=<time|duration>] [**--not-after**=<time|duration>]
-		[**--san**=<SAN>]`,
+		[**--san**=<SAN>] [**--vault-path**=<path>] [**--output**=<format>]
+		[**--spiffe**=<uri>] [**--spiffe-trust-domain**=<domain>] [**--spiffe-allow-dns**]
+		[**--install-store**=<store>] [**--metadata**] [**--intended-use**=<description>]
+		[**--eku**=<usage>] [**--extension**=<oid[:critical]=hex>] [**--profile**=<profile>]
+
+**step ca certificate** <subject> <crt-file> **--kms**=<uri>
+		[**--token**=<token>]  [**--issuer**=<name>] [**--kid**=<kid>] [**--provisioner-type**=<type>]
+		[**--ca-url**=<uri>] [**--root**=<file>]
+		[**--not-before**=<time|duration>] [**--not-after**=<time|duration>]
+		[**--san**=<SAN>] [**--vault-path**=<path>] [**--output**=<format>]
+
+**step ca certificate** <subject> <crt-file> **--public-key**=<file>
+		[**--csr**=<file>] [**--signer-command**=<command>]
+		[**--token**=<token>]  [**--issuer**=<name>] [**--kid**=<kid>] [**--provisioner-type**=<type>]
+		[**--ca-url**=<uri>] [**--root**=<file>]
+		[**--not-before**=<time|duration>] [**--not-after**=<time|duration>]
+		[**--san**=<SAN>] [**--vault-path**=<path>] [**--output**=<format>]
+
+**step ca certificate** **--manifest**=<file> [**--concurrency**=<n>]
+		[**--expires-in**=<duration>] [**--issuer**=<name>] [**--ca-url**=<uri>] [**--root**=<file>]
+		[**--not-before**=<time|duration>] [**--not-after**=<time|duration>]
+		[**--jitter**=<duration>] [**--rate-limit**=<number>]`,
 		Description: `**step ca certificate** command generates a new certificate pair
 
+With the **--spiffe** flag the command requests an X509-SVID, a certificate
+with the given SPIFFE ID, spiffe://<trust-domain>/<path>, as its URI SAN. The
+SPIFFE ID is validated following the SPIFFE specification, and its trust domain
+must be the one in the **--spiffe-trust-domain** flag, if set, and must be
+allowed by the root certificate, matching the SPIFFE ID and the URI name
+constraints in it. DNS SANs are not allowed unless the **--spiffe-allow-dns**
+flag is used. The certificate returned by the CA is validated before it's
+written to disk: it must have the SPIFFE ID as its only URI SAN, it cannot be a
+CA, and it must have the digitalSignature key usage but not the keyCertSign or
+cRLSign ones.
+
+With the **--public-key** flag the certificate is issued for a public key
+generated elsewhere, like in a device that only exports its public key. The CA
+only issues certificates for a CSR signed by the private key, so the holder of
+the key must prove its possession in one of two ways:
+
+**--csr**=<file>
+:  A CSR created and signed by the holder of the key. Its public key must be the
+**--public-key**, and its subject and SANs are sent to the CA as they are.
+
+**--signer-command**=<command>
+:  A command that signs with the private key. The CSR is built around the
+public key with the <subject> and SANs of the request, and its digest is sent
+to the standard input of the command, which must write the signature to its
+standard output. The digest is SHA-256 for RSA and P-256 keys, SHA-384 for
+P-384 keys, and SHA-512 for P-521 keys; for Ed25519 keys the command receives the
+whole request. ECDSA signatures must be ASN.1 DER encoded, and RSA signatures
+must use PKCS #1 v1.5.
+
+In both cases the <key-file> argument must be omitted.
+
+With the **--eku** and **--extension** flags extended key usages and other
+extensions are added to the CSR. The CA only honors them if the provisioner is
+configured to respect the extensions in the CSR, so after the certificate is
+issued the command reports which of the requested extensions are in it, and if
+their values were changed. These flags cannot be used with **--csr**.
+
+With **--profile smime** the certificate is requested for S/MIME, to sign and
+encrypt email with **step crypto smime**: the CSR requests the email protection
+extended key usage, and the email addresses in the <subject> or the **--san**
+flags are added as email SANs. With **--profile code-signing** the CSR requests
+the code signing extended key usage, to sign executables and release artifacts.
+As with **--eku**, the CA must be configured to respect the extensions in the
+CSR, or use a template that adds the usage.
+
+With the **--output** flag the certificate and the private key are also printed
+to the standard output in a format ready to be used by a container orchestrator
+or a service proxy. The flag **--output-format** is an alias of **--output**:
+
+**k8s-secret[:name[/namespace]]**
+:  A Kubernetes Secret of type kubernetes.io/tls, with the certificate and the
+private key in the tls.crt and tls.key fields. It can be applied using
+**kubectl apply -f -**.
+
+**docker-secret[:name]**
+:  The **docker secret create** commands that create the secrets <name>.crt and
+<name>.key in a Docker Swarm.
+
+**grpc[:name]**
+:  The file_watcher certificate provider <name> of a gRPC xDS bootstrap file,
+with the paths of the certificate, the private key and the root certificate.
+
+**envoy-sds[:name]**
+:  An Envoy SDS response with the secret <name>, with the certificate chain and
+the private key inline.
+
+**haproxy**
+:  The certificate chain followed by the private key, the PEM layout used by
+the HAProxy **crt** option.
+
+By default the name of the secrets is the <subject>, lowercased and with the
+characters not allowed by Kubernetes replaced by '-'.
+
+With the **--manifest** flag multiple certificates are requested using a YAML
+or JSON manifest instead of the positional arguments. Manifests with the
+extension .yaml or .yml are parsed as YAML, and the rest as JSON. The manifest
+is a list of objects with the following properties:
+
+**subject**
+:  The subject of the certificate, required.
+
+**sans**
+:  The list of Subject Alternative Names, defaults to the subject.
+
+**crt**, **key**
+:  The files to write the certificate and the private key, required.
+
+**kty**, **crv**, **size**
+:  The type, curve and size of the key, e.g. "RSA" and 2048. Defaults to an EC
+P-256 key.
+
+**crtMode**, **keyMode**
+:  The octal permissions of the certificate and key files, e.g. "0644".
+Defaults to the **--mode** flag, or "0600".
+
+**owner**, **group**
+:  The user and group, names or ids, that will own the certificate and key
+files. Defaults to the **--owner** and **--group** flags, or the current user.
+
+Manifests can be run again safely: an entry is skipped if its files contain a
+certificate with the same subject, SANs and key type that is valid for longer
+than **--expires-in**, or, by default, that has not reached 2/3 of its
+lifetime. The progress is reported while the certificates are issued, followed
+by a summary and the errors of each failed entry.
+
+The provisioner key is decrypted only once. The keys are generated and the
+certificates are requested concurrently, using up to **--concurrency** workers,
+but the files are written and reported in the order of the manifest. An error
+in one entry does not stop the rest of the manifest. Only JWK provisioners can
+be used with a manifest.
+
+When the same manifest is used in many hosts at the same time, the **--jitter**
+flag adds a random delay before the first request, and the **--rate-limit** flag
+limits the number of requests per second sent to the CA. Requests rejected by
+the CA with a 429 Too Many Requests are sent again after the time in their
+Retry-After header.
+
+The <subject> and the **--san** flags, and the subject, SANs and files of the
+manifest entries, can use template variables that are resolved at issuance
+time, so the same command or manifest can be used in all the instances of an
+autoscaling group, e.g. "{{ .Hostname }}.internal". The variables are:
+
+**{{ .Hostname }}**, **{{ .ShortHostname }}**
+:  The host name, and the host name up to the first dot.
+
+**{{ .IP }}**, **{{ .IPs }}**
+:  The first non-loopback IP address of the host, IPv4 if available, and the
+list of all of them.
+
+**{{ .Env.NAME }}**
+:  The value of the environment variable NAME. It fails if it is not set.
+
+**{{ .Cloud.InstanceID }}**, **{{ .Cloud.Region }}**, **{{ .Cloud.Zone }}**, **{{ .Cloud.PrivateIP }}**, **{{ .Cloud.Hostname }}**, **{{ .Cloud.AccountID }}**, **{{ .Cloud.Provider }}**
+:  The metadata of the AWS, GCP or Azure instance, requested to the metadata
+service only if used. The **--metadata-url**, **--metadata-timeout** and
+**--aws-imds** flags configure the requests.
+
 ## POSITIONAL ARGUMENTS
 
 <subject>
@@ -50,7 +210,8 @@ are configured (via the --san flag) then the <subject> will be set as the only S
 :  File to write the certificate (PEM format)
 
 <key-file>
-:  File to write the private key (PEM format)
+:  File to write the private key (PEM format). It must not be used with the
+**--kms** or **--public-key** flags.
 
 ## EXAMPLES
 
@@ -86,10 +247,181 @@ $ step ca certificate --offline internal.example.com internal.crt internal.key
 Request a new certificate using an OIDC provisioner:
 '''
 $ step ca certificate --token $(step oauth --oidc --bare) joe@example.com joe.crt joe.key
+'''
+
+Request a new certificate and record the provisioner and what it is for in
+internal.crt.meta.json and internal.key.meta.json:
+'''
+$ step ca certificate --metadata --intended-use "internal API server" \
+  internal.example.com internal.crt internal.key
+'''
+
+Request a new certificate for the key in the slot 9a of a YubiKey, the key
+must have been generated with **step crypto piv generate**:
+'''
+$ step ca certificate --kms yubikey:9a joe@example.com joe.crt
+'''
+
+Request a new certificate and store it with its private key in Vault:
+'''
+$ export VAULT_ADDR=https://vault.example.com:8200 VAULT_TOKEN=s.XXXX
+$ step ca certificate --vault-path secret/data/step/internal \
+  internal.example.com internal.crt internal.key
+'''
+
+Request a new certificate and import it with its private key in the Windows
+certificate store of the computer, so it can be used by IIS:
+'''
+$ step ca certificate --install-store My/LocalMachine \
+  internal.example.com internal.crt internal.key
+'''
+
+Request a new certificate for a key in the Vault Transit secrets engine:
+'''
+$ step ca certificate --kms vault:transit/keys/internal internal.example.com internal.crt
+'''
+
+Request a new certificate for a public key exported by a device, proving the
+possession of the key with a CSR created by the device:
+'''
+$ step ca certificate --public-key device.pub --csr device.csr \
+  device-1234 device.crt
+'''
+
+Request a new certificate for a public key read from STDIN, signing the CSR
+with a command of the device:
+'''
+$ device-tool export-key | step ca certificate --public-key - \
+  --signer-command "openssl pkeyutl -sign -inkey device.key -pkeyopt digest:sha256" \
+  device-1234 device.crt
+'''
+
+Request a new certificate with the client authentication and a custom extended
+key usage, and a custom extension with a DER UTF8String value:
+'''
+$ step ca certificate --eku client-auth --eku 1.3.6.1.5.5.7.3.17 \
+  --extension 1.3.6.1.4.1.55555.1=0c03666f6f internal.example.com internal.crt internal.key
+'''
+
+Request an S/MIME certificate for an email address:
+'''
+$ step ca certificate --profile smime jane@example.com jane.crt jane.key
+'''
+
+Request a code signing certificate for the release pipeline:
+'''
+$ step ca certificate --profile code-signing "Example Inc" release.crt release.key
+'''
+
+Request an X509-SVID for a workload:
+'''
+$ step ca certificate --spiffe spiffe://example.org/ns/prod/sa/billing \
+  billing billing.crt billing.key
+'''
+
+Request an X509-SVID validating the trust domain and allowing a DNS SAN:
+'''
+$ step ca certificate --spiffe spiffe://example.org/billing \
+  --spiffe-trust-domain example.org --spiffe-allow-dns --san billing.example.org \
+  billing billing.crt billing.key
+'''
+
+Record the requests and responses of a failed enrollment, with the secrets
+redacted, to attach them to a bug report, and replay them later without a CA:
+'''
+$ step --record enroll.jsonl ca certificate --token $TOKEN foo.example.com foo.crt foo.key
+$ step --replay enroll.jsonl ca certificate --token $TOKEN foo.example.com foo.crt foo.key
+'''
+
+Request a new certificate and apply it as a Kubernetes TLS secret in the
+namespace web:
+'''
+$ step ca certificate --output k8s-secret:www-tls/web \
+  www.example.com www.crt www.key | kubectl apply -f -
+'''
+
+Request a new certificate and create the Docker secrets www.crt and www.key:
+'''
+$ step ca certificate --output docker-secret:www www.example.com www.crt www.key | sh
+'''
+
+Request a new certificate and write it as an Envoy SDS secret named www:
+'''
+$ step ca certificate --output-format envoy-sds:www \
+  www.example.com www.crt www.key > /etc/envoy/sds/www.json
+'''
+
+Request a new certificate and write the PEM used by HAProxy:
+'''
+$ step ca certificate --output-format haproxy \
+  www.example.com www.crt www.key > /etc/haproxy/certs/www.pem
+'''
+
+Request the certificates of multiple services, generating 16 RSA keys at a time:
+'''
+$ cat services.json
+[
+  {"subject": "svc1.internal", "crt": "svc1.crt", "key": "svc1.key", "kty": "RSA", "size": 2048},
+  {"subject": "svc2.internal", "sans": ["svc2.internal", "10.0.0.2"], "crt": "svc2.crt", "key": "svc2.key", "kty": "RSA", "size": 2048}
+]
+$ step ca certificate --manifest services.json --concurrency 16
+'''
+
+Request the certificates in a YAML manifest, setting the owner and permissions
+of the files, and renewing the ones that expire in less than 8 hours:
+'''
+$ cat certs.yaml
+- subject: web.internal
+  sans: [web.internal, www.internal]
+  crt: /etc/nginx/certs/web.crt
+  key: /etc/nginx/certs/web.key
+  crtMode: "0644"
+  keyMode: "0600"
+  owner: www-data
+  group: www-data
+- subject: db.internal
+  crt: /etc/postgresql/db.crt
+  key: /etc/postgresql/db.key
+  owner: postgres
+$ step ca certificate --manifest certs.yaml --expires-in 8h
+'''
+
+Request the certificates in a manifest in all the hosts of a deploy, after a
+random delay of up to 5 minutes and with at most 2 requests per second:
+'''
+$ step ca certificate --manifest certs.yaml --jitter 5m --rate-limit 2
+'''
+
+Request a certificate for the host name and IP of each instance of an
+autoscaling group, using the same command in all of them:
+'''
+$ step ca certificate '{{ .ShortHostname }}.internal' host.crt host.key \
+  --san '{{ .ShortHostname }}.internal' --san '{{ .IP }}'
+'''
+
+Use a manifest with the instance id and the zone of a cloud instance, and an
+environment variable:
+'''
+$ cat certs.yaml
+- subject: "{{ .Cloud.InstanceID }}.{{ .Cloud.Zone }}.example.internal"
+  sans:
+  - "{{ .Cloud.InstanceID }}.{{ .Cloud.Zone }}.example.internal"
+  - "{{ .Cloud.PrivateIP }}"
+  crt: /etc/ssl/{{ .Env.SERVICE }}.crt
+  key: /etc/ssl/{{ .Env.SERVICE }}.key
+$ step ca certificate --manifest certs.yaml
 '''`,
 		Flags: []cli.Flag{
 			tokenFlag,
 			provisionerIssuerFlag,
+			provisionerKidFlag,
+			provisionerTypeFlag,
+			metadataURLFlag,
+			metadataTimeoutFlag,
+			awsIMDSFlag,
+			gcpServiceAccountFlag,
+			gcpWorkloadIdentityFlag,
+			azureResourceIDFlag,
 			caURLFlag,
 			rootFlag,
 			notBeforeFlag,
@@ -101,25 +433,261 @@ authorized to request. A certificate signing request using this token must match
 the complete set of subjective alternative names in the token 1:1. Use the '--san'
 flag multiple times to configure multiple SANs. The '--san' flag and the '--token'
 flag are mutually exlusive.`,
+			},
+			flags.VerbatimSAN,
+			ekuFlag,
+			extensionFlag,
+			cli.StringFlag{
+				Name:  "profile",
+				Value: "leaf",
+				Usage: `The <profile> of the certificate, it sets the extensions requested in the CSR.
+
+: <profile> is a case-sensitive string and must be one of:
+
+    **leaf**
+    :  A certificate for a TLS client or server.
+
+    **smime**
+    :  A certificate to sign and encrypt email with S/MIME. It requests the email
+    protection extended key usage, and an email address in the <subject> or in
+    the **--san** flags is required.
+
+    **code-signing**
+    :  A certificate to sign executables, packages and other release artifacts.
+    It requests the code signing extended key usage.`,
+			},
+			cli.StringFlag{
+				Name: "kms",
+				Usage: `The <uri> of a key in a KMS or a hardware token to use instead of generating
+a new private key, e.g. yubikey:9a, awskms:alias/my-key or
+agent:joe@example.com for a key in the ssh-agent. If this flag is used the
+<key-file> argument must be omitted.`,
+			},
+			cli.StringFlag{
+				Name: "public-key",
+				Usage: `The PEM or DER <file> with a public key generated elsewhere to issue the
+certificate for, or '-' to read it from STDIN. It requires the **--csr** or the
+**--signer-command** flag to prove the possession of the private key. If this
+flag is used the <key-file> argument must be omitted.`,
+			},
+			cli.StringFlag{
+				Name: "csr",
+				Usage: `The CSR <file> signed by the private key of the **--public-key**, used as the
+proof of possession of the key.`,
+			},
+			cli.StringFlag{
+				Name: "signer-command",
+				Usage: `The <command> that signs the CSR built for the **--public-key**. It reads the
+digest to sign from STDIN and writes the signature to STDOUT.`,
+			},
+			cli.StringFlag{
+				Name: "vault-path",
+				Usage: `Store the certificate and the private key in the <path> of the Vault KV secrets
+engine, e.g. secret/data/step/internal, in the fields "certificate" and "key".
+The Vault server and token are configured using the VAULT_ADDR and VAULT_TOKEN
+environment variables.`,
+			},
+			installStoreFlag,
+			cli.StringFlag{
+				Name: "spiffe",
+				Usage: `Request an X509-SVID with the SPIFFE ID <uri>, e.g.
+spiffe://example.org/ns/prod/sa/billing, as its URI SAN.`,
+			},
+			cli.StringFlag{
+				Name: "spiffe-trust-domain",
+				Usage: `The SPIFFE trust <domain> of the CA. The trust domain of the **--spiffe** ID
+must match it. It is usually set in the defaults.json.`,
+			},
+			cli.BoolFlag{
+				Name:  "spiffe-allow-dns",
+				Usage: `Allow DNS SANs in the certificates requested with **--spiffe**.`,
+			},
+			cli.StringFlag{
+				Name: "output, output-format",
+				Usage: `Print the certificate and the private key using the given <format>. The
+formats are k8s-secret[:name[/namespace]], docker-secret[:name], grpc[:name],
+envoy-sds[:name] and haproxy. The grpc format prints the file_watcher
+certificate provider of a gRPC xDS bootstrap file, envoy-sds prints an Envoy SDS
+secret, and haproxy prints the certificate chain and the key in the same PEM.`,
+			},
+			cli.StringFlag{
+				Name: "manifest, batch",
+				Usage: `Issue the certificates described in the YAML or JSON manifest <file>. The
+format of the manifest is described above.`,
+			},
+			cli.IntFlag{
+				Name:  "concurrency",
+				Usage: `The number of certificates issued at the same time with **--manifest**.`,
+				Value: defaultConcurrency,
+			},
+			jitterFlag,
+			rateLimitFlag,
+			cli.StringFlag{
+				Name: "expires-in",
+				Usage: `The amount of time remaining before certificate expiration at which the
+certificates in the **--manifest** are issued again. By default a certificate is
+issued again after 2/3 of its lifetime. The <duration> is a sequence of decimal
+numbers, each with optional fraction and a unit suffix, such as "300ms", "1.5h"
+or "2h45m". Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".`,
 			},
 			offlineFlag,
 			caConfigFlag,
+			flags.Metadata,
+			flags.IntendedUse,
 			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
 		},
 	}
 }
 
 func certificateAction(ctx *cli.Context) error {
-	if err := errs.NumberOfArguments(ctx, 3); err != nil {
-		return err
+	if ctx.IsSet("manifest") {
+		for _, name := range []string{"output", "spiffe", "metadata", "eku", "extension", "profile"} {
+			if ctx.IsSet(name) {
+				return errs.IncompatibleFlagWithFlag(ctx, "manifest", name)
+			}
+		}
+		return batchCertificateAction(ctx)
+	}
+	for _, name := range []string{"jitter", "rate-limit"} {
+		if ctx.IsSet(name) {
+			return errs.RequiredWithFlag(ctx, name, "manifest")
+		}
+	}
+
+	if ctx.IsSet("intended-use") && !ctx.Bool("metadata") {
+		return errs.RequiredWithFlag(ctx, "intended-use", "metadata")
 	}
 
+	keyURI := ctx.String("kms")
+	publicKey, csrFile, signerCommand := ctx.String("public-key"), ctx.String("csr"), ctx.String("signer-command")
+	switch {
+	case keyURI != "" && publicKey != "":
+		return errs.IncompatibleFlagWithFlag(ctx, "kms", "public-key")
+	case keyURI != "":
+		if err := errs.NumberOfArguments(ctx, 2); err != nil {
+			return err
+		}
+		if !kms.IsKMS(keyURI) {
+			return errs.InvalidFlagValue(ctx, "kms", keyURI, "")
+		}
+	case publicKey != "":
+		if err := errs.NumberOfArguments(ctx, 2); err != nil {
+			return err
+		}
+		switch {
+		case csrFile == "" && signerCommand == "":
+			return errors.New("flag '--public-key' requires the '--csr' or the '--signer-command' flag")
+		case csrFile != "" && signerCommand != "":
+			return errs.IncompatibleFlagWithFlag(ctx, "csr", "signer-command")
+		}
+	default:
+		if csrFile != "" {
+			return errs.RequiredWithFlag(ctx, "csr", "public-key")
+		}
+		if signerCommand != "" {
+			return errs.RequiredWithFlag(ctx, "signer-command", "public-key")
+		}
+		if err := errs.NumberOfArguments(ctx, 3); err != nil {
+			return err
+		}
+	}
+	// The private key is not available with --kms or --public-key.
+	noKey := keyURI != "" || publicKey != ""
+
 	args := ctx.Args()
 	subject := args.Get(0)
 	crtFile, keyFile := args.Get(1), args.Get(2)
 	tok := ctx.String("token")
 	offline := ctx.Bool("offline")
-	sans := ctx.StringSlice("san")
+	sans, err := sanFlag(ctx)
+	if err != nil {
+		return err
+	}
+	if hasTemplateVars(append([]string{subject}, sans...)...) {
+		vars, err := newTemplateVars(ctx)
+		if err != nil {
+			return err
+		}
+		if subject, err = vars.Execute(subject); err != nil {
+			return err
+		}
+		if sans, err = vars.ExecuteAll(sans); err != nil {
+			return err
+		}
+	}
+	switch profile := ctx.String("profile"); profile {
+	case "leaf":
+	case "code-signing":
+		if csrFile != "" {
+			return errs.IncompatibleFlagWithFlag(ctx, "profile", "csr")
+		}
+	case "smime":
+		if csrFile != "" {
+			return errs.IncompatibleFlagWithFlag(ctx, "profile", "csr")
+		}
+		// The SANs in the token default to the subject.
+		names := sans
+		if len(names) == 0 {
+			names = []string{subject}
+		}
+		if _, emails := x509util.SplitEmails(names); len(emails) == 0 {
+			return errors.New("flag '--profile smime' requires an email address in <subject> or in the flag '--san'")
+		}
+	default:
+		return errs.InvalidFlagValue(ctx, "profile", profile, "leaf, smime, code-signing")
+	}
+	extensions, err := parseExtensionFlags(ctx)
+	if err != nil {
+		return err
+	}
+	if len(extensions) > 0 && csrFile != "" {
+		if ctx.IsSet("eku") {
+			return errs.IncompatibleFlagWithFlag(ctx, "eku", "csr")
+		}
+		return errs.IncompatibleFlagWithFlag(ctx, "extension", "csr")
+	}
+
+	var spiffeID *url.URL
+	if ctx.IsSet("spiffe") {
+		if spiffeID, err = spiffe.ParseID(ctx.String("spiffe")); err != nil {
+			return errors.Wrap(err, "error parsing flag '--spiffe'")
+		}
+		if err := validateSPIFFETrustDomain(ctx, spiffeID); err != nil {
+			return err
+		}
+		if sans, err = spiffeSANs(ctx, spiffeID, sans); err != nil {
+			return err
+		}
+	}
+
+	var output *secretOutput
+	if ctx.IsSet("output") {
+		if output, err = parseSecretOutput(ctx, subject); err != nil {
+			return err
+		}
+		// There is no key to add to the output.
+		if noKey && output.requiresKey() {
+			if keyURI != "" {
+				return errs.IncompatibleFlagValue(ctx, "kms", "output", ctx.String("output"))
+			}
+			return errs.IncompatibleFlagValue(ctx, "public-key", "output", ctx.String("output"))
+		}
+	}
+
+	storeLocation, err := parseInstallStore(ctx)
+	if err != nil {
+		return err
+	}
+	// The key in a KMS cannot be imported in the certificate store.
+	if keyURI != "" && storeLocation != nil {
+		return errs.IncompatibleFlagWithFlag(ctx, "kms", "install-store")
+	}
+	if publicKey != "" && storeLocation != nil {
+		return errs.IncompatibleFlagWithFlag(ctx, "public-key", "install-store")
+	}
 
 	// offline and token are incompatible because the token is generated before
 	// the start of the offline CA.
@@ -132,6 +700,7 @@ func certificateAction(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	flow.extensions = extensions
 
 	if len(tok) == 0 {
 		if tok, err = flow.GenerateToken(ctx, subject, sans); err != nil {
@@ -139,9 +708,34 @@ func certificateAction(ctx *cli.Context) error {
 		}
 	}
 
-	req, pk, err := flow.CreateSignRequest(tok, subject, sans)
-	if err != nil {
-		return err
+	var pk crypto.PrivateKey
+	var req *api.SignRequest
+	switch {
+	case keyURI != "":
+		if pk, err = kms.NewSigner(keyURI); err != nil {
+			return err
+		}
+	case publicKey != "":
+		pub, err := readPublicKey(publicKey)
+		if err != nil {
+			return err
+		}
+		// The CSR signed by the holder of the key is the proof of possession.
+		if csrFile != "" {
+			csr, err := readPublicKeyCSR(csrFile, pub)
+			if err != nil {
+				return err
+			}
+			req = &api.SignRequest{CsrPEM: csr, OTT: tok}
+		} else {
+			pk = newCommandSigner(pub, signerCommand)
+		}
+	}
+
+	if req == nil {
+		if req, pk, err = flow.CreateSignRequest(tok, subject, sans, pk); err != nil {
+			return err
+		}
 	}
 
 	jwt, err := token.ParseInsecure(tok)
@@ -171,23 +765,101 @@ func certificateAction(ctx *cli.Context) error {
 		return errors.New("token is not supported")
 	}
 
-	if err := flow.Sign(ctx, tok, req.CsrPEM, crtFile); err != nil {
+	data, err := flow.sign(ctx, tok, req.CsrPEM)
+	if err != nil {
 		return err
 	}
-
-	_, err = pemutil.Serialize(pk, pemutil.ToFile(keyFile, 0600))
-	if err != nil {
+	var leaf *x509.Certificate
+	if spiffeID != nil || storeLocation != nil || ctx.Bool("metadata") || len(extensions) > 0 {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return errors.New("error decoding certificate")
+		}
+		if leaf, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return errors.Wrap(err, "error parsing certificate")
+		}
+	}
+	if spiffeID != nil {
+		if err := validateSPIFFECertificate(leaf, spiffeID, ctx.Bool("spiffe-allow-dns")); err != nil {
+			return err
+		}
+	}
+	if err := utils.WriteFile(crtFile, data, 0600); err != nil {
 		return err
 	}
 
 	ui.PrintSelected("Certificate", crtFile)
-	ui.PrintSelected("Private Key", keyFile)
+	printHonoredExtensions(leaf, extensions)
+	if !noKey {
+		_, err = pemutil.Serialize(pk, pemutil.ToFile(keyFile, 0600))
+		if err != nil {
+			return err
+		}
+		ui.PrintSelected("Private Key", keyFile)
+	}
+
+	if ctx.Bool("metadata") {
+		md := utils.NewMetadata(ctx)
+		md.SetCertificate(leaf)
+		md.Provisioner = jwt.Payload.Issuer
+		md.Files = []string{crtFile}
+		if !noKey {
+			md.Files = append(md.Files, keyFile)
+		}
+		for _, fn := range md.Files {
+			if err := utils.WriteMetadata(fn, md); err != nil {
+				return errs.FileError(err, fn+utils.MetadataExt)
+			}
+		}
+	}
+
+	if vaultPath := ctx.String("vault-path"); vaultPath != "" {
+		if err := writeToVault(vaultPath, crtFile, pk, !noKey); err != nil {
+			return err
+		}
+		ui.PrintSelected("Vault", vaultPath)
+	}
+
+	if storeLocation != nil {
+		if err := installInStore(storeLocation, leaf, pk, nil); err != nil {
+			return err
+		}
+	}
+
+	if output != nil {
+		if noKey {
+			pk = nil
+		}
+		return output.writeSecret(os.Stdout, crtFile, keyFile, pk)
+	}
 	return nil
 }
 
+// writeToVault stores the certificate and optionally the private key in the
+// given path of the Vault KV secrets engine. The certificate is stored in the
+// field "certificate" and the key in the field "key", both in PEM format.
+func writeToVault(path, crtFile string, pk crypto.PrivateKey, withKey bool) error {
+	crt, err := ioutil.ReadFile(crtFile)
+	if err != nil {
+		return errs.FileError(err, crtFile)
+	}
+	data := map[string]interface{}{
+		"certificate": string(crt),
+	}
+	if withKey {
+		block, err := pemutil.Serialize(pk)
+		if err != nil {
+			return err
+		}
+		data["key"] = string(pem.EncodeToMemory(block))
+	}
+	return vault.WriteSecret(path, data)
+}
+
 type certificateFlow struct {
-	offlineCA *offlineCA
-	offline   bool
+	offlineCA  *offlineCA
+	offline    bool
+	extensions []pkix.Extension
 }
 
 func newCertificateFlow(ctx *cli.Context) (*certificateFlow, error) {
@@ -235,13 +907,11 @@ func (f *certificateFlow) getClient(ctx *cli.Context, subject, tok string) (caCl
 		}
 	}
 
-	// Prepare client for bootstrap or provisioning tokens
-	var options []ca.ClientOption
+	// Bootstrap tokens have the CA url and the fingerprint of the root
 	if len(jwt.Payload.SHA) > 0 && len(jwt.Payload.Audience) > 0 && strings.HasPrefix(strings.ToLower(jwt.Payload.Audience[0]), "http") {
 		if len(caURL) == 0 {
 			caURL = jwt.Payload.Audience[0]
 		}
-		options = append(options, ca.WithRootSHA256(jwt.Payload.SHA))
 	} else {
 		if len(caURL) == 0 {
 			return nil, errs.RequiredFlag(ctx, "ca-url")
@@ -252,11 +922,10 @@ func (f *certificateFlow) getClient(ctx *cli.Context, subject, tok string) (caCl
 				return nil, errs.RequiredFlag(ctx, "root")
 			}
 		}
-		options = append(options, ca.WithRootFile(root))
 	}
 
 	ui.PrintSelected("CA", caURL)
-	return ca.NewClient(caURL, options...)
+	return step.NewClient(tok, caURL, root)
 }
 
 // GenerateToken generates a token for immediate use (therefore only default
@@ -294,140 +963,68 @@ func (f *certificateFlow) GenerateToken(ctx *cli.Context, subject string, sans [
 
 // Sign signs the CSR using the online or the offline certificate authority.
 func (f *certificateFlow) Sign(ctx *cli.Context, token string, csr api.CertificateRequest, crtFile string) error {
-	client, err := f.getClient(ctx, csr.Subject.CommonName, token)
+	data, err := f.sign(ctx, token, csr)
 	if err != nil {
 		return err
 	}
+	return utils.WriteFile(crtFile, data, 0600)
+}
+
+// sign signs the CSR and returns the certificate and the intermediate in PEM
+// format.
+func (f *certificateFlow) sign(ctx *cli.Context, token string, csr api.CertificateRequest) ([]byte, error) {
+	client, err := f.getClient(ctx, csr.Subject.CommonName, token)
+	if err != nil {
+		return nil, err
+	}
 
 	// parse times or durations
 	notBefore, notAfter, err := parseTimeDuration(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req := &api.SignRequest{
+	return signCertificate(client, token, csr, notBefore, notAfter)
+}
+
+// signCertificate signs the CSR with the given client and returns the
+// certificate and the intermediate in PEM format.
+func signCertificate(client caClient, token string, csr api.CertificateRequest, notBefore, notAfter api.TimeDuration) ([]byte, error) {
+	crt, err := step.SignCertificate(client, &api.SignRequest{
 		CsrPEM:    csr,
 		OTT:       token,
 		NotBefore: notBefore,
 		NotAfter:  notAfter,
-	}
-
-	resp, err := client.Sign(req)
-	if err != nil {
-		return err
-	}
-
-	serverBlock, err := pemutil.Serialize(resp.ServerPEM.Certificate)
-	if err != nil {
-		return err
-	}
-	caBlock, err := pemutil.Serialize(resp.CaPEM.Certificate)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	data := append(pem.EncodeToMemory(serverBlock), pem.EncodeToMemory(caBlock)...)
-	return utils.WriteFile(crtFile, data, 0600)
+	return crt.PEM(), nil
 }
 
 // CreateSignRequest is a helper function that given an x509 OTT returns a
-// simple but secure sign request as well as the private key used.
-func (f *certificateFlow) CreateSignRequest(tok, subject string, sans []string) (*api.SignRequest, crypto.PrivateKey, error) {
-	jwt, err := token.ParseInsecure(tok)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	pk, err := keys.GenerateDefaultKey()
-	if err != nil {
-		return nil, nil, err
-	}
-
-	var emails []string
-	dnsNames, ips := splitSANs(sans, jwt.Payload.SANs)
-	if jwt.Payload.Email != "" {
-		emails = append(emails, jwt.Payload.Email)
-	}
-
-	switch jwt.Payload.Type() {
-	case token.AWS:
-		doc := jwt.Payload.Amazon.InstanceIdentityDocument
-		if len(ips) == 0 && len(dnsNames) == 0 {
-			defaultSANs := []string{
-				doc.PrivateIP,
-				fmt.Sprintf("ip-%s.%s.compute.internal", strings.Replace(doc.PrivateIP, ".", "-", -1), doc.Region),
-			}
-			if !sharedContext.DisableCustomSANs {
-				defaultSANs = append(defaultSANs, subject)
-			}
-			dnsNames, ips = splitSANs(defaultSANs)
-		}
-	case token.GCP:
-		ce := jwt.Payload.Google.ComputeEngine
-		if len(ips) == 0 && len(dnsNames) == 0 {
-			defaultSANs := []string{
-				fmt.Sprintf("%s.c.%s.internal", ce.InstanceName, ce.ProjectID),
-				fmt.Sprintf("%s.%s.c.%s.internal", ce.InstanceName, ce.Zone, ce.ProjectID),
-			}
-			if !sharedContext.DisableCustomSANs {
-				defaultSANs = append(defaultSANs, subject)
-			}
-			dnsNames, ips = splitSANs(defaultSANs)
-		}
-	case token.Azure:
-		if len(ips) == 0 && len(dnsNames) == 0 {
-			defaultSANs := []string{
-				jwt.Payload.Azure.VirtualMachine,
-			}
-			if !sharedContext.DisableCustomSANs {
-				defaultSANs = append(defaultSANs, subject)
-			}
-			dnsNames, ips = splitSANs(defaultSANs)
-		}
-	default: // Use common name in the token
-		subject = jwt.Payload.Subject
-	}
-
-	template := &x509.CertificateRequest{
-		Subject: pkix.Name{
-			CommonName: subject,
-		},
-		SignatureAlgorithm: keys.DefaultSignatureAlgorithm,
-		DNSNames:           dnsNames,
-		IPAddresses:        ips,
-		EmailAddresses:     emails,
-	}
-
-	csr, err := x509.CreateCertificateRequest(rand.Reader, template, pk)
-	if err != nil {
-		return nil, nil, errors.Wrap(err, "error creating certificate request")
-	}
-	cr, err := x509.ParseCertificateRequest(csr)
-	if err != nil {
-		return nil, nil, errors.Wrap(err, "error parsing certificate request")
-	}
-	if err := cr.CheckSignature(); err != nil {
-		return nil, nil, errors.Wrap(err, "error signing certificate request")
-	}
-	return &api.SignRequest{
-		CsrPEM: api.CertificateRequest{CertificateRequest: cr},
-		OTT:    tok,
-	}, pk, nil
+// simple but secure sign request as well as the private key used. If pk is
+// nil a new private key will be generated.
+func (f *certificateFlow) CreateSignRequest(tok, subject string, sans []string, pk crypto.PrivateKey) (*api.SignRequest, crypto.PrivateKey, error) {
+	return step.NewSignRequest(&step.CertificateOptions{
+		Token:             tok,
+		Subject:           subject,
+		SANs:              sans,
+		Key:               pk,
+		Extensions:        f.extensions,
+		DisableCustomSANs: sharedContext.DisableCustomSANs,
+	})
 }
 
-// splitSANs unifies the SAN collections passed as arguments and returns a list
-// of DNS names and a list of IP addresses.
-func splitSANs(args ...[]string) (dnsNames []string, ipAddresses []net.IP) {
-	m := make(map[string]bool)
-	var unique []string
-	for _, sans := range args {
-		for _, san := range sans {
-			if ok := m[san]; !ok {
-				m[san] = true
-				unique = append(unique, san)
-			}
-		}
+// sanFlag returns the values of the --san flag with the internationalized
+// domain names converted to their ASCII form, unless --verbatim-san is used.
+func sanFlag(ctx *cli.Context) ([]string, error) {
+	sans := ctx.StringSlice("san")
+	if ctx.Bool("verbatim-san") {
+		return sans, nil
 	}
-	return x509util.SplitSANs(unique)
+	sans, err := x509util.NormalizeSANs(sans)
+	return sans, errors.Wrap(err, "error parsing flag '--san'")
 }
 
 // parseTimeDuration parses the not-before and not-after flags as a timeDuration
@@ -0,0 +1,48 @@
+package ca
+
+import (
+	"crypto"
+	"crypto/x509"
+	"runtime"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/certstore"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+var installStoreFlag = cli.StringFlag{
+	Name: "install-store",
+	Usage: `Import the certificate and its private key in the Windows certificate <store>,
+in the form <name>/<location>, e.g. My/LocalMachine or My/CurrentUser. The key
+is stored using the Microsoft Software Key Storage Provider. Only available on
+Windows.`,
+}
+
+// parseInstallStore returns the location in the --install-store flag, or nil
+// if the flag is not set.
+func parseInstallStore(ctx *cli.Context) (*certstore.Location, error) {
+	s := ctx.String("install-store")
+	if s == "" {
+		return nil, nil
+	}
+	if runtime.GOOS != "windows" {
+		return nil, errors.New("flag '--install-store' is only supported on Windows")
+	}
+	loc, err := certstore.ParseLocation(s)
+	if err != nil {
+		return nil, errs.InvalidFlagValue(ctx, "install-store", s, "My/CurrentUser, My/LocalMachine")
+	}
+	return loc, nil
+}
+
+// installInStore imports the certificate and key in the certificate store,
+// replacing the previous certificate if it's not nil.
+func installInStore(loc *certstore.Location, crt *x509.Certificate, key crypto.PrivateKey, replace *x509.Certificate) error {
+	if err := certstore.Install(loc, crt, key, replace); err != nil {
+		return err
+	}
+	ui.PrintSelected("Certificate Store", loc.String())
+	return nil
+}
@@ -0,0 +1,322 @@
+package ca
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/jose"
+	"github.com/urfave/cli"
+)
+
+// Default metadata endpoints of the cloud providers.
+const (
+	awsMetadataURL   = "http://169.254.169.254"
+	gcpMetadataURL   = "http://metadata.google.internal"
+	azureMetadataURL = "http://169.254.169.254"
+
+	defaultMetadataTimeout = 5 * time.Second
+	azureDefaultAudience   = "https://management.azure.com/"
+	azureTokenAPIVersion   = "2018-02-01"
+	awsSessionTokenTTL     = "300"
+)
+
+var (
+	metadataURLFlag = cli.StringFlag{
+		Name: "metadata-url",
+		Usage: `The base <url> of the instance metadata service used to get the identity of
+the AWS, GCP and Azure provisioners, e.g. http://169.254.169.254. It can be used
+to access the metadata service through a proxy.`,
+	}
+
+	metadataTimeoutFlag = cli.DurationFlag{
+		Name: "metadata-timeout",
+		Usage: `The <duration> to wait for every request to the instance metadata service,
+e.g. 500ms or 10s.`,
+		Value: defaultMetadataTimeout,
+	}
+
+	awsIMDSFlag = cli.StringFlag{
+		Name: "aws-imds",
+		Usage: `The <version> of the AWS instance metadata service used to get the instance
+identity document. By default IMDSv2 is used, falling back to IMDSv1 if a session
+token cannot be created.
+
+: <version> is a case-sensitive string and must be one of:
+
+    **auto**
+    :  Use IMDSv2 session tokens if available (default).
+
+    **v1**
+    :  Use only IMDSv1 requests.
+
+    **v2**
+    :  Require IMDSv2 session tokens.`,
+		Value: "auto",
+	}
+
+	gcpServiceAccountFlag = cli.StringFlag{
+		Name: "gcp-service-account",
+		Usage: `The GCP service <account> email used to get the identity token. Defaults to
+the default service account of the instance.`,
+	}
+
+	gcpWorkloadIdentityFlag = cli.BoolFlag{
+		Name: "gcp-workload-identity",
+		Usage: `Request a standard GCP identity token, without the Compute Engine claims, as
+required when the token is issued by the GKE workload identity metadata server.`,
+	}
+
+	azureResourceIDFlag = cli.StringFlag{
+		Name: "azure-resource-id",
+		Usage: `The Azure resource <id> of the user-assigned managed identity used to get the
+identity token. Defaults to the system-assigned managed identity.`,
+	}
+)
+
+// metadataClient is an HTTP client for the instance metadata services.
+type metadataClient struct {
+	client  *http.Client
+	baseURL string
+	header  http.Header
+}
+
+func newMetadataClient(ctx *cli.Context, defaultURL string) (*metadataClient, error) {
+	baseURL := ctx.String("metadata-url")
+	if baseURL == "" {
+		baseURL = defaultURL
+	} else if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errs.InvalidFlagValue(ctx, "metadata-url", baseURL, "")
+	}
+	timeout := ctx.Duration("metadata-timeout")
+	if timeout <= 0 {
+		timeout = defaultMetadataTimeout
+	}
+	return &metadataClient{
+		client:  &http.Client{Timeout: timeout},
+		baseURL: strings.TrimRight(baseURL, "/"),
+		header:  make(http.Header),
+	}, nil
+}
+
+// do sends a request to the given path of the metadata service and returns
+// the body of the response.
+func (c *metadataClient) do(method, path string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errs.Network(errors.Wrapf(err, "error requesting %s", req.URL))
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, errs.Network(errors.Wrapf(err, "error reading %s", req.URL))
+	}
+	if resp.StatusCode >= 400 {
+		return nil, errs.Network(errors.Errorf("error requesting %s: %s", req.URL, resp.Status))
+	}
+	return b, nil
+}
+
+// cloudIdentityToken returns the token used with the AWS, GCP and Azure
+// provisioners, using the identity of the instance, or the workload, given by
+// the metadata service of the cloud provider.
+func cloudIdentityToken(ctx *cli.Context, p provisioner.Interface, subject, caURL string) (string, error) {
+	switch p := p.(type) {
+	case *provisioner.AWS:
+		sharedContext.DisableCustomSANs = p.DisableCustomSANs
+		return awsIdentityToken(ctx, p, subject, caURL)
+	case *provisioner.GCP:
+		sharedContext.DisableCustomSANs = p.DisableCustomSANs
+		return gcpIdentityToken(ctx, p, caURL)
+	case *provisioner.Azure:
+		sharedContext.DisableCustomSANs = p.DisableCustomSANs
+		return azureIdentityToken(ctx, p)
+	default:
+		return "", errors.Errorf("provisioner '%s' is not a cloud provisioner", p.GetName())
+	}
+}
+
+// signAudience returns the audience of the tokens used to sign certificates
+// with the given provisioner.
+func signAudience(caURL string, p provisioner.Interface) (string, error) {
+	u, err := url.Parse(caURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "error parsing %s", caURL)
+	}
+	return u.ResolveReference(&url.URL{Path: "/1.0/sign", Fragment: p.GetID()}).String(), nil
+}
+
+type awsIdentityPayload struct {
+	jose.Claims
+	Amazon awsAmazonPayload `json:"amazon"`
+}
+
+type awsAmazonPayload struct {
+	Document  []byte `json:"document"`
+	Signature []byte `json:"signature"`
+}
+
+// awsIdentityToken returns a token with the signed instance identity document
+// of an EC2 instance.
+func awsIdentityToken(ctx *cli.Context, p *provisioner.AWS, subject, caURL string) (string, error) {
+	c, err := newMetadataClient(ctx, awsMetadataURL)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := ctx.String("aws-imds"); v {
+	case "", "auto", "v2":
+		tok, err := c.do("PUT", "/latest/api/token", http.Header{
+			"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": []string{awsSessionTokenTTL},
+		})
+		switch {
+		case err == nil:
+			c.header.Set("X-Aws-Ec2-Metadata-Token", string(tok))
+		case v == "v2":
+			return "", errors.Wrap(err, "error creating IMDSv2 session token")
+		}
+	case "v1":
+	default:
+		return "", errs.InvalidFlagValue(ctx, "aws-imds", v, "auto, v1, v2")
+	}
+
+	doc, err := c.do("GET", "/latest/dynamic/instance-identity/document", nil)
+	if err != nil {
+		return "", err
+	}
+	var idoc struct {
+		InstanceID string `json:"instanceId"`
+	}
+	if err := json.Unmarshal(doc, &idoc); err != nil {
+		return "", errors.Wrap(err, "error unmarshaling instance identity document")
+	}
+	sig, err := c.do("GET", "/latest/dynamic/instance-identity/signature", nil)
+	if err != nil {
+		return "", err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return "", errors.Wrap(err, "error decoding instance identity signature")
+	}
+
+	audience, err := signAudience(caURL, p)
+	if err != nil {
+		return "", err
+	}
+
+	// The unique id allows the CA to trust only the first token of an
+	// instance.
+	sum := sha256.Sum256([]byte(p.GetID() + "." + idoc.InstanceID))
+	so := new(jose.SignerOptions)
+	so.WithType("JWT")
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.HS256,
+		Key:       signature,
+	}, so)
+	if err != nil {
+		return "", errors.Wrap(err, "error creating signer")
+	}
+
+	now := time.Now()
+	payload := awsIdentityPayload{
+		Claims: jose.Claims{
+			Issuer:    "ec2.amazonaws.com",
+			Subject:   subject,
+			Audience:  []string{audience},
+			Expiry:    jose.NewNumericDate(now.Add(5 * time.Minute)),
+			NotBefore: jose.NewNumericDate(now),
+			IssuedAt:  jose.NewNumericDate(now),
+			ID:        hex.EncodeToString(sum[:]),
+		},
+		Amazon: awsAmazonPayload{
+			Document:  doc,
+			Signature: signature,
+		},
+	}
+	tok, err := jose.Signed(signer).Claims(payload).CompactSerialize()
+	return tok, errors.Wrap(err, "error serializing token")
+}
+
+// gcpIdentityToken returns the identity token of a GCP service account.
+func gcpIdentityToken(ctx *cli.Context, p *provisioner.GCP, caURL string) (string, error) {
+	c, err := newMetadataClient(ctx, gcpMetadataURL)
+	if err != nil {
+		return "", err
+	}
+	c.header.Set("Metadata-Flavor", "Google")
+
+	audience, err := signAudience(caURL, p)
+	if err != nil {
+		return "", err
+	}
+	account := ctx.String("gcp-service-account")
+	if account == "" {
+		account = "default"
+	}
+
+	q := url.Values{}
+	q.Set("audience", audience)
+	if !ctx.Bool("gcp-workload-identity") {
+		q.Set("format", "full")
+		q.Set("licenses", "FALSE")
+	}
+	tok, err := c.do("GET", "/computeMetadata/v1/instance/service-accounts/"+url.PathEscape(account)+"/identity?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(tok)), nil
+}
+
+// azureIdentityToken returns the access token of an Azure managed identity.
+func azureIdentityToken(ctx *cli.Context, p *provisioner.Azure) (string, error) {
+	c, err := newMetadataClient(ctx, azureMetadataURL)
+	if err != nil {
+		return "", err
+	}
+	c.header.Set("Metadata", "true")
+
+	resource := p.Audience
+	if resource == "" {
+		resource = azureDefaultAudience
+	}
+	q := url.Values{}
+	q.Set("api-version", azureTokenAPIVersion)
+	q.Set("resource", resource)
+	if id := ctx.String("azure-resource-id"); id != "" {
+		q.Set("mi_res_id", id)
+	}
+	b, err := c.do("GET", "/metadata/identity/oauth2/token?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(b, &tok); err != nil {
+		return "", errors.Wrap(err, "error unmarshaling identity token")
+	}
+	if tok.AccessToken == "" {
+		return "", errors.New("error getting identity token: response does not have an access token")
+	}
+	return tok.AccessToken, nil
+}
@@ -0,0 +1,291 @@
+package db
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority"
+	authdb "github.com/smallstep/certificates/db"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/utils"
+	"github.com/smallstep/nosql/database"
+	"github.com/urfave/cli"
+)
+
+// Command returns the db subcommand.
+func Command() cli.Command {
+	return cli.Command{
+		Name:      "db",
+		Usage:     "inspect the certificates issued by the certificate authority",
+		UsageText: "step ca db <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Subcommands: cli.Commands{
+			listCommand(),
+			getCommand(),
+			exportCommand(),
+		},
+		Description: `The **step ca db** command group provides facilities for auditing the
+certificates issued by a certificate authority. The certificates and their
+revocation status are read from the database referenced by the ca.json.
+
+The database is opened by these commands, depending on the database type it
+might not be possible to run them at the same time as the CA.
+
+## EXAMPLES
+
+List the certificates issued by the CA:
+'''
+$ step ca db list
+'''
+
+Show a certificate issued by the CA:
+'''
+$ step ca db get 239578542353839442356729359838329403582
+'''
+
+Export the certificates issued by the CA to a CSV file:
+'''
+$ step ca db export --format csv --out certificates.csv
+'''`,
+	}
+}
+
+var caConfigFlag = cli.StringFlag{
+	Name: "ca-config, config",
+	Usage: `The <path> to the certificate authority configuration file. Defaults to
+$STEPPATH/config/ca.json`,
+	Value: filepath.Join(config.StepPath(), "config", "ca.json"),
+}
+
+// Tables of the CA database.
+var (
+	certsTable        = []byte("x509_certs")
+	revokedCertsTable = []byte("revoked_x509_certs")
+)
+
+// Certificate statuses.
+const (
+	statusValid   = "valid"
+	statusExpired = "expired"
+	statusRevoked = "revoked"
+)
+
+// stepOIDProvisioner is the OID of the extension with the provisioner used
+// to sign a certificate.
+var stepOIDProvisioner = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37476, 9000, 64, 1}
+
+// stepProvisionerASN1 is the value of the provisioner extension.
+type stepProvisionerASN1 struct {
+	Type          int
+	Name          []byte
+	CredentialID  []byte
+	KeyValuePairs []string `asn1:"optional,omitempty"`
+}
+
+// caDB is the part of the CA database used by these commands.
+type caDB interface {
+	Get(bucket, key []byte) ([]byte, error)
+	List(bucket []byte) ([]*database.Entry, error)
+	Shutdown() error
+}
+
+// record is a certificate issued by the CA and its status.
+type record struct {
+	Serial      string     `json:"serial"`
+	Subject     string     `json:"subject"`
+	SANs        []string   `json:"sans,omitempty"`
+	Provisioner string     `json:"provisioner,omitempty"`
+	NotBefore   time.Time  `json:"notBefore"`
+	NotAfter    time.Time  `json:"notAfter"`
+	Status      string     `json:"status"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+
+	certificate *x509.Certificate
+}
+
+// openDB opens the database of the CA configuration in the --ca-config flag.
+func openDB(ctx *cli.Context) (caDB, error) {
+	configFile := ctx.String("ca-config")
+	b, err := utils.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	var cfg authority.Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", configFile)
+	}
+	if cfg.DB == nil {
+		return nil, errors.Errorf("error parsing %s: the CA does not have a database", configFile)
+	}
+	db, err := authdb.New(cfg.DB)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening the CA database")
+	}
+	cdb, ok := db.(caDB)
+	if !ok {
+		db.Shutdown()
+		return nil, errors.Errorf("error parsing %s: the database type does not support listing certificates", configFile)
+	}
+	return cdb, nil
+}
+
+// listRecords returns all the certificates in the database sorted by the
+// start of their validity.
+func listRecords(db caDB, now time.Time) ([]*record, error) {
+	revoked, err := revokedCertificates(db)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := db.List(certsTable)
+	if err != nil && !database.IsErrNotFound(err) {
+		return nil, errors.Wrap(err, "error listing certificates")
+	}
+	var records []*record
+	for _, e := range entries {
+		crt, err := x509util.ParseCertificate(e.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing certificate %s", e.Key)
+		}
+		records = append(records, newRecord(crt, revoked[crt.SerialNumber.String()], now))
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].NotBefore.Before(records[j].NotBefore)
+	})
+	return records, nil
+}
+
+// getRecord returns the certificate with the given serial number.
+func getRecord(db caDB, serial string, now time.Time) (*record, error) {
+	sn, ok := new(big.Int).SetString(serial, 0)
+	if !ok {
+		return nil, errors.Errorf("invalid serial number %s", serial)
+	}
+	key := []byte(sn.String())
+	b, err := db.Get(certsTable, key)
+	if err != nil {
+		if database.IsErrNotFound(err) {
+			return nil, errors.Errorf("certificate %s not found", serial)
+		}
+		return nil, errors.Wrapf(err, "error reading certificate %s", serial)
+	}
+	crt, err := x509util.ParseCertificate(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing certificate %s", serial)
+	}
+
+	var info *authdb.RevokedCertificateInfo
+	if b, err := db.Get(revokedCertsTable, key); err == nil {
+		info = new(authdb.RevokedCertificateInfo)
+		if err := json.Unmarshal(b, info); err != nil {
+			return nil, errors.Wrapf(err, "error parsing revocation information of %s", serial)
+		}
+	} else if !database.IsErrNotFound(err) {
+		return nil, errors.Wrapf(err, "error reading revocation information of %s", serial)
+	}
+	return newRecord(crt, info, now), nil
+}
+
+// revokedCertificates returns the revocation information of the revoked
+// certificates indexed by serial number.
+func revokedCertificates(db caDB) (map[string]*authdb.RevokedCertificateInfo, error) {
+	entries, err := db.List(revokedCertsTable)
+	if err != nil && !database.IsErrNotFound(err) {
+		return nil, errors.Wrap(err, "error listing revoked certificates")
+	}
+	revoked := make(map[string]*authdb.RevokedCertificateInfo, len(entries))
+	for _, e := range entries {
+		info := new(authdb.RevokedCertificateInfo)
+		if err := json.Unmarshal(e.Value, info); err != nil {
+			return nil, errors.Wrapf(err, "error parsing revocation information of %s", e.Key)
+		}
+		revoked[string(e.Key)] = info
+	}
+	return revoked, nil
+}
+
+// newRecord returns the record of a certificate with the given revocation
+// information, info is nil if the certificate is not revoked.
+func newRecord(crt *x509.Certificate, info *authdb.RevokedCertificateInfo, now time.Time) *record {
+	r := &record{
+		Serial:      crt.SerialNumber.String(),
+		Subject:     crt.Subject.CommonName,
+		SANs:        subjectAlternativeNames(crt),
+		Provisioner: provisionerName(crt),
+		NotBefore:   crt.NotBefore.UTC(),
+		NotAfter:    crt.NotAfter.UTC(),
+		Status:      statusValid,
+		certificate: crt,
+	}
+	switch {
+	case info != nil:
+		revokedAt := info.RevokedAt.UTC()
+		r.Status = statusRevoked
+		r.RevokedAt = &revokedAt
+		r.Reason = info.Reason
+	case now.After(crt.NotAfter):
+		r.Status = statusExpired
+	}
+	return r
+}
+
+// subjectAlternativeNames returns all the SANs of the certificate.
+func subjectAlternativeNames(crt *x509.Certificate) []string {
+	sans := append([]string{}, crt.DNSNames...)
+	for _, ip := range crt.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, crt.EmailAddresses...)
+	for _, u := range crt.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
+
+// provisionerName returns the name of the provisioner that signed the
+// certificate, or an empty string if the certificate does not have the
+// provisioner extension.
+func provisionerName(crt *x509.Certificate) string {
+	for _, ext := range crt.Extensions {
+		if !ext.Id.Equal(stepOIDProvisioner) {
+			continue
+		}
+		var p stepProvisionerASN1
+		if _, err := asn1.Unmarshal(ext.Value, &p); err != nil {
+			return ""
+		}
+		return string(p.Name)
+	}
+	return ""
+}
+
+// filterRecords returns the records with the given status and provisioner,
+// empty values match all the records.
+func filterRecords(records []*record, status, provisioner string) []*record {
+	var ret []*record
+	for _, r := range records {
+		if (status == "" || r.Status == status) && (provisioner == "" || r.Provisioner == provisioner) {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+// expiresText returns the time left until the certificate expires.
+func expiresText(r *record, now time.Time) string {
+	d := r.NotAfter.Sub(now)
+	if d < 0 {
+		return "expired"
+	}
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("in %dd", d/(24*time.Hour))
+	}
+	return "in " + d.Round(time.Minute).String()
+}
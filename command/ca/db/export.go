@@ -0,0 +1,133 @@
+package db
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func exportCommand() cli.Command {
+	return cli.Command{
+		Name:   "export",
+		Action: command.ActionFunc(exportAction),
+		Usage:  "export the certificates issued by the certificate authority",
+		UsageText: `**step ca db export** [**--format**=<format>] [**--out**=<file>]
+[**--status**=<status>] [**--provisioner**=<name>] [**--ca-config**=<file>]`,
+		Description: `**step ca db export** exports the information of the certificates issued by the
+certificate authority, to be used in audits or imported into other tools.
+
+The exported columns are serial, subject, sans, provisioner, notBefore,
+notAfter, status, revokedAt and reason. In CSV format the SANs are separated by
+spaces and the times use the RFC 3339 format.
+
+## EXAMPLES
+
+Export all the certificates to a CSV file:
+'''
+$ step ca db export --format csv --out certificates.csv
+'''
+
+Export the valid certificates in JSON:
+'''
+$ step ca db export --status valid
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format",
+				Value: "json",
+				Usage: `The <format> of the exported certificates.
+
+: <format> is a case-sensitive string and must be one of:
+
+    **json**
+    :  A JSON array with an object per certificate.
+
+    **csv**
+    :  A CSV file with a header and a row per certificate.`,
+			},
+			cli.StringFlag{
+				Name:  "out",
+				Usage: `The <file> to write the certificates to. Defaults to STDOUT.`,
+			},
+			statusFlag,
+			provisionerFlag,
+			caConfigFlag,
+			flags.Force,
+		},
+	}
+}
+
+func exportAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+	format := ctx.String("format")
+	if format != "json" && format != "csv" {
+		return errs.InvalidFlagValue(ctx, "format", format, "json, csv")
+	}
+
+	records, err := readRecords(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if format == "csv" {
+		err = writeCSV(&buf, records)
+	} else {
+		err = writeJSON(&buf, records)
+	}
+	if err != nil {
+		return err
+	}
+
+	out := ctx.String("out")
+	if out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := utils.WriteFile(out, buf.Bytes(), 0600); err != nil {
+		return errs.FileError(err, out)
+	}
+	ui.Printf("Exported %d certificates to %s.\n", len(records), out)
+	return nil
+}
+
+func writeJSON(w io.Writer, records []*record) error {
+	if records == nil {
+		records = []*record{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(records), "error marshaling certificates")
+}
+
+func writeCSV(w io.Writer, records []*record) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"serial", "subject", "sans", "provisioner", "notBefore", "notAfter", "status", "revokedAt", "reason"})
+	for _, r := range records {
+		var revokedAt string
+		if r.RevokedAt != nil {
+			revokedAt = r.RevokedAt.Format(time.RFC3339)
+		}
+		cw.Write([]string{
+			r.Serial, r.Subject, strings.Join(r.SANs, " "), r.Provisioner,
+			r.NotBefore.Format(time.RFC3339), r.NotAfter.Format(time.RFC3339),
+			r.Status, revokedAt, r.Reason,
+		})
+	}
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "error writing CSV")
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func getCommand() cli.Command {
+	return cli.Command{
+		Name:   "get",
+		Action: command.ActionFunc(getAction),
+		Usage:  "show a certificate issued by the certificate authority",
+		UsageText: `**step ca db get** <serial-number>
+[**--ca-config**=<file>] [**--json**] [**--pem**]`,
+		Description: `**step ca db get** shows a certificate issued by the certificate authority and
+its status.
+
+## POSITIONAL ARGUMENTS
+
+<serial-number>
+:  The serial number of the certificate in decimal, or in hexadecimal with the
+0x prefix.
+
+## EXAMPLES
+
+Show a certificate:
+'''
+$ step ca db get 239578542353839442356729359838329403582
+'''
+
+Show a certificate using the hexadecimal serial number:
+'''
+$ step ca db get 0xb43c1d8b1b4ab2d7b0ad9a7c4e1bb1be
+'''
+
+Save a certificate issued by the CA:
+'''
+$ step ca db get 239578542353839442356729359838329403582 --pem > foo.crt
+'''`,
+		Flags: []cli.Flag{
+			caConfigFlag,
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: `Print the certificate information in JSON format.`,
+			},
+			cli.BoolFlag{
+				Name:  "pem",
+				Usage: `Print the certificate in PEM format.`,
+			},
+		},
+	}
+}
+
+func getAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+	if ctx.Bool("json") && ctx.Bool("pem") {
+		return errs.IncompatibleFlagWithFlag(ctx, "json", "pem")
+	}
+
+	db, err := openDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Shutdown()
+
+	now := time.Now()
+	r, err := getRecord(db, ctx.Args().Get(0), now)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case ctx.Bool("json"):
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling certificate")
+		}
+		fmt.Println(string(b))
+	case ctx.Bool("pem"):
+		return pem.Encode(os.Stdout, &pem.Block{Type: "CERTIFICATE", Bytes: r.certificate.Raw})
+	default:
+		fmt.Printf("Serial:      %s\n", r.Serial)
+		fmt.Printf("Subject:     %s\n", r.Subject)
+		if len(r.SANs) > 0 {
+			fmt.Printf("SANs:        %s\n", strings.Join(r.SANs, ", "))
+		}
+		if r.Provisioner != "" {
+			fmt.Printf("Provisioner: %s\n", r.Provisioner)
+		}
+		fmt.Printf("Not Before:  %s\n", r.NotBefore.Format(time.RFC3339))
+		fmt.Printf("Not After:   %s (%s)\n", r.NotAfter.Format(time.RFC3339), expiresText(r, now))
+		fmt.Printf("Status:      %s\n", r.Status)
+		if r.RevokedAt != nil {
+			fmt.Printf("Revoked At:  %s\n", r.RevokedAt.Format(time.RFC3339))
+		}
+		if r.Reason != "" {
+			fmt.Printf("Reason:      %s\n", r.Reason)
+		}
+	}
+	return nil
+}
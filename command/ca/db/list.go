@@ -0,0 +1,128 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func listCommand() cli.Command {
+	return cli.Command{
+		Name:   "list",
+		Action: command.ActionFunc(listAction),
+		Usage:  "list the certificates issued by the certificate authority",
+		UsageText: `**step ca db list** [**--status**=<status>] [**--provisioner**=<name>]
+[**--ca-config**=<file>] [**--json**]`,
+		Description: `**step ca db list** lists the certificates issued by the certificate authority
+with their serial number, subject, provisioner, expiration and status. The
+certificates are sorted by the start of their validity.
+
+## EXAMPLES
+
+List all the certificates:
+'''
+$ step ca db list
+'''
+
+List the revoked certificates:
+'''
+$ step ca db list --status revoked
+'''
+
+List the certificates issued by a provisioner in JSON:
+'''
+$ step ca db list --provisioner admin@example.com --json
+'''`,
+		Flags: []cli.Flag{
+			statusFlag,
+			provisionerFlag,
+			caConfigFlag,
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: `Print the certificates in JSON format.`,
+			},
+		},
+	}
+}
+
+var statusFlag = cli.StringFlag{
+	Name: "status",
+	Usage: `Only include the certificates with the given <status>.
+
+: <status> is a case-sensitive string and must be one of:
+
+    **valid**
+    :  Certificates not expired or revoked.
+
+    **expired**
+    :  Certificates past their expiration.
+
+    **revoked**
+    :  Revoked certificates.`,
+}
+
+var provisionerFlag = cli.StringFlag{
+	Name:  "provisioner",
+	Usage: `Only include the certificates issued by the provisioner with the given <name>.`,
+}
+
+// readRecords returns the certificates in the database filtered by the
+// --status and --provisioner flags.
+func readRecords(ctx *cli.Context, now time.Time) ([]*record, error) {
+	status := ctx.String("status")
+	switch status {
+	case "", statusValid, statusExpired, statusRevoked:
+	default:
+		return nil, errs.InvalidFlagValue(ctx, "status", status, "valid, expired, revoked")
+	}
+
+	db, err := openDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Shutdown()
+
+	records, err := listRecords(db, now)
+	if err != nil {
+		return nil, err
+	}
+	return filterRecords(records, status, ctx.String("provisioner")), nil
+}
+
+func listAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	records, err := readRecords(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("json") {
+		if records == nil {
+			records = []*record{}
+		}
+		b, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling certificates")
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SERIAL\tSUBJECT\tPROVISIONER\tEXPIRES\tSTATUS")
+	for _, r := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Serial, r.Subject, r.Provisioner, expiresText(r, now), r.Status)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,75 @@
+package ca
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/jose"
+)
+
+// maxDiscoverySize is the maximum size of a discovery bundle.
+const maxDiscoverySize = 1 << 20
+
+// proxyFunc returns the proxy function used by the transports. If proxy is
+// empty, the proxy is taken from the HTTPS_PROXY, HTTP_PROXY, and NO_PROXY
+// environment variables.
+func proxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("error parsing proxy url '%s'", proxy)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// discoveryBundle is the payload of a signed discovery bundle.
+type discoveryBundle struct {
+	CAURL       string `json:"ca-url"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// fetchDiscoveryBundle downloads the JWS in the given url, verifies it with
+// the public key in keyFile, and returns its payload.
+func fetchDiscoveryBundle(tr http.RoundTripper, rawurl, keyFile string) (*discoveryBundle, error) {
+	jwk, err := jose.ParseKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Transport: tr}
+	resp, err := client.Get(rawurl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error downloading %s", rawurl)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error downloading %s: unexpected status %s", rawurl, resp.Status)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDiscoverySize))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error downloading %s", rawurl)
+	}
+
+	jws, err := jose.ParseJWS(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing discovery bundle %s", rawurl)
+	}
+	pub := jwk.Public()
+	payload, err := jws.Verify(&pub)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error verifying discovery bundle %s", rawurl)
+	}
+
+	var bundle discoveryBundle
+	if err := json.Unmarshal(payload, &bundle); err != nil {
+		return nil, errors.Wrapf(err, "error parsing discovery bundle %s", rawurl)
+	}
+	return &bundle, nil
+}
@@ -0,0 +1,243 @@
+package ca
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/exec"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// Environment variables with the paths of the files that are passed to the
+// command run by step ca entrypoint.
+const (
+	entrypointCrtEnv  = "STEP_CRT"
+	entrypointKeyEnv  = "STEP_KEY"
+	entrypointRootEnv = "STEP_ROOT"
+)
+
+func entrypointCommand() cli.Command {
+	return cli.Command{
+		Name:   "entrypoint",
+		Action: command.ActionFunc(entrypointAction),
+		Usage:  "get a certificate, run a command and keep the certificate renewed",
+		UsageText: `**step ca entrypoint** <subject> <crt-file> <key-file> **--** <command> [<arguments>...]
+[**--token**=<token>] [**--token-file**=<file>] [**--ca-url**=<uri>] [**--root**=<file>]
+[**--expires-in**=<duration>] [**--renew-signal**=<number>]`,
+		Description: `**step ca entrypoint** is designed to be the entrypoint, or the init
+process, of a container. It gets a certificate for <subject> from the CA using a
+one-time token, writes it to <crt-file> and <key-file>, and then runs <command>
+with the environment variables STEP_CRT, STEP_KEY and STEP_ROOT set to the paths
+of the certificate, the key and the root certificate.
+
+While the command runs the certificate is renewed in the background, by default
+after 2/3 of its validity period, and the **--renew-signal** is sent to the
+command after each renewal so it can load the new certificate. All the signals
+sent to step are forwarded to the command, and step exits with the exit code of
+the command.
+
+The token is read from the **--token** flag, the STEP_TOKEN environment
+variable, or the file in the **--token-file** flag or the STEP_TOKEN_FILE
+environment variable, so it can be injected as a container secret.
+
+## POSITIONAL ARGUMENTS
+
+<subject>
+:  The Common Name, DNS Name, or IP address that will be set as the
+Subject Common Name for the certificate.
+
+<crt-file>
+:  File to write the certificate (PEM format)
+
+<key-file>
+:  File to write the private key (PEM format)
+
+<command>
+:  The command to run after getting the certificate.
+
+## EXAMPLES
+
+Use step as the entrypoint of a container running nginx, reloading nginx after
+each renewal:
+'''
+ENTRYPOINT ["step", "ca", "entrypoint", "www.example.com", "/run/tls/www.crt", "/run/tls/www.key", \
+  "--", "nginx", "-g", "daemon off;"]
+'''
+
+Run a command with a token mounted as a secret, and renew the certificate 1 hour
+before it expires:
+'''
+$ step ca entrypoint --token-file /run/secrets/step-token --expires-in 1h \
+  --ca-url https://ca.example.com --root /run/secrets/root_ca.crt \
+  api.example.com /run/tls/api.crt /run/tls/api.key -- /usr/local/bin/api
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "token",
+				Usage: `The one-time <token> used to authenticate with the CA in order to create the
+certificate.`,
+				EnvVar: "STEP_TOKEN",
+			},
+			cli.StringFlag{
+				Name:   "token-file",
+				Usage:  "The <file> containing the one-time token used to authenticate with the CA.",
+				EnvVar: "STEP_TOKEN_FILE",
+			},
+			cli.StringFlag{
+				Name:   "ca-url",
+				Usage:  "<URI> of the targeted Step Certificate Authority.",
+				EnvVar: "STEP_CA_URL",
+			},
+			cli.StringFlag{
+				Name:   "root",
+				Usage:  "The path to the PEM <file> used as the root certificate authority.",
+				EnvVar: "STEP_ROOT",
+			},
+			cli.StringFlag{
+				Name: "expires-in",
+				Usage: `The amount of time remaining before certificate expiration at which the
+certificate is renewed. By default the certificate is renewed after 2/3 of its
+validity period. The <duration> is a sequence of decimal numbers, each with
+optional fraction and a unit suffix, such as "300ms", "1.5h" or "2h45m". Valid
+time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".`,
+			},
+			cli.IntFlag{
+				Name: "renew-signal",
+				Usage: `The signal <number> sent to the command after the certificate has been
+renewed. Use 0 to not send any signal. Default value is SIGHUP (1).`,
+				Value: int(syscall.SIGHUP),
+			},
+			flags.Force,
+		},
+	}
+}
+
+func entrypointAction(ctx *cli.Context) error {
+	if ctx.NArg() < 4 {
+		return errs.NumberOfArguments(ctx, 4)
+	}
+	args := ctx.Args()
+	subject, crtFile, keyFile := args.Get(0), args.Get(1), args.Get(2)
+	name, cmdArgs := args.Get(3), []string(args[4:])
+
+	tok := ctx.String("token")
+	if filename := ctx.String("token-file"); filename != "" {
+		if tok != "" {
+			return errs.IncompatibleFlagWithFlag(ctx, "token", "token-file")
+		}
+		b, err := utils.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		tok = strings.TrimSpace(string(b))
+	}
+	if tok == "" {
+		return errs.RequiredFlag(ctx, "token")
+	}
+
+	caURL := ctx.String("ca-url")
+	if caURL == "" {
+		return errs.RequiredFlag(ctx, "ca-url")
+	}
+	rootFile := ctx.String("root")
+	if rootFile == "" {
+		rootFile = pki.GetRootCAPath()
+	}
+
+	var expiresIn time.Duration
+	if s := ctx.String("expires-in"); s != "" {
+		var err error
+		if expiresIn, err = time.ParseDuration(s); err != nil {
+			return errs.InvalidFlagValue(ctx, "expires-in", s, "")
+		}
+	}
+	signum := ctx.Int("renew-signal")
+	if signum < 0 {
+		return errs.InvalidFlagValue(ctx, "renew-signal", ctx.String("renew-signal"), "")
+	}
+
+	// Files from a previous run of the container are overwritten.
+	ctx.Set("force", "true")
+
+	// Get the certificate
+	flow, err := newCertificateFlow(ctx)
+	if err != nil {
+		return err
+	}
+	req, pk, err := flow.CreateSignRequest(tok, subject, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := flow.Sign(ctx, tok, req.CsrPEM, crtFile); err != nil {
+		return err
+	}
+	if _, err := pemutil.Serialize(pk, pemutil.ToFile(keyFile, 0600)); err != nil {
+		return err
+	}
+	leaf, err := pemutil.ReadCertificate(crtFile)
+	if err != nil {
+		return err
+	}
+
+	renewer, err := newRenewer(ctx, caURL, crtFile, keyFile, rootFile, new(rateLimiter))
+	if err != nil {
+		return err
+	}
+
+	// Run the command with the paths of the files in the environment.
+	env := make([]string, 0, 3)
+	for k, fn := range map[string]string{
+		entrypointCrtEnv:  crtFile,
+		entrypointKeyEnv:  keyFile,
+		entrypointRootEnv: rootFile,
+	} {
+		abs, err := filepath.Abs(fn)
+		if err != nil {
+			return errs.FileError(err, fn)
+		}
+		env = append(env, k+"="+abs)
+	}
+	proc, wait, err := exec.Start(env, name, cmdArgs...)
+	if err != nil {
+		return err
+	}
+
+	// Renew the certificate in the background.
+	go func() {
+		Info := log.New(os.Stderr, "INFO: ", log.LstdFlags)
+		Error := log.New(os.Stderr, "ERROR: ", log.LstdFlags)
+		next := nextRenewDuration(leaf, expiresIn, 0)
+		Info.Printf("first renewal in %s", next.Round(time.Second))
+		for {
+			time.Sleep(next)
+			n, err := renewer.RenewAndPrepareNext(context.Background(), crtFile, expiresIn, 0)
+			next = n
+			if err != nil {
+				Error.Println(err)
+				continue
+			}
+			Info.Printf("certificate renewed, next in %s", next.Round(time.Second))
+			if signum > 0 {
+				if err := proc.Signal(syscall.Signal(signum)); err != nil {
+					Error.Println(errors.Wrapf(err, "error sending signal %d to %s", signum, name))
+				}
+			}
+		}
+	}()
+
+	// Wait until the command finishes and exit with its exit code.
+	wait()
+	return nil
+}
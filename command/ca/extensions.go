@@ -0,0 +1,177 @@
+package ca
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+var oidExtensionExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+
+// extKeyUsageOIDs are the names accepted by the --eku flag.
+var extKeyUsageOIDs = map[string]asn1.ObjectIdentifier{
+	"server-auth":      {1, 3, 6, 1, 5, 5, 7, 3, 1},
+	"client-auth":      {1, 3, 6, 1, 5, 5, 7, 3, 2},
+	"code-signing":     {1, 3, 6, 1, 5, 5, 7, 3, 3},
+	"email-protection": {1, 3, 6, 1, 5, 5, 7, 3, 4},
+	"time-stamping":    {1, 3, 6, 1, 5, 5, 7, 3, 8},
+	"ocsp-signing":     {1, 3, 6, 1, 5, 5, 7, 3, 9},
+}
+
+// profileExtKeyUsages are the extended key usages requested by the profiles of
+// the --profile flag.
+var profileExtKeyUsages = map[string]string{
+	"smime":        "email-protection",
+	"code-signing": "code-signing",
+}
+
+var (
+	ekuFlag = cli.StringSliceFlag{
+		Name: "eku",
+		Usage: `Request the extended key <usage> in the CSR. Use the flag multiple times to
+request multiple usages. The CA only honors it if the provisioner is configured
+to respect the CSR extensions.
+
+: <usage> is an OID, e.g. 1.3.6.1.5.5.7.3.17, or one of:
+**server-auth**, **client-auth**, **code-signing**, **email-protection**,
+**time-stamping**, **ocsp-signing**`,
+	}
+
+	extensionFlag = cli.StringSliceFlag{
+		Name: "extension",
+		Usage: `Add the extension <oid[:critical]=hex> to the CSR, with the DER value encoded
+in hexadecimal, e.g. 1.2.3.4=0c03666f6f. The :critical suffix marks the
+extension as critical. Use the flag multiple times to add multiple extensions.
+The CA only honors it if the provisioner is configured to respect the CSR
+extensions.`,
+	}
+)
+
+// parseExtensionFlags returns the extensions in the --eku and --extension
+// flags.
+func parseExtensionFlags(ctx *cli.Context) ([]pkix.Extension, error) {
+	var exts []pkix.Extension
+	values := ctx.StringSlice("eku")
+	// The profiles of step ca certificate request their extended key usage.
+	if eku, ok := profileExtKeyUsages[ctx.String("profile")]; ok && !containsString(values, eku) {
+		values = append(values, eku)
+	}
+	if len(values) > 0 {
+		var oids []asn1.ObjectIdentifier
+		for _, v := range values {
+			oid, ok := extKeyUsageOIDs[v]
+			if !ok {
+				var err error
+				if oid, err = parseOID(v); err != nil {
+					names := make([]string, 0, len(extKeyUsageOIDs))
+					for name := range extKeyUsageOIDs {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+					return nil, errs.InvalidFlagValue(ctx, "eku", v, strings.Join(names, ", ")+", or an OID")
+				}
+			}
+			oids = append(oids, oid)
+		}
+		value, err := asn1.Marshal(oids)
+		if err != nil {
+			return nil, errors.Wrap(err, "error marshaling extended key usage")
+		}
+		exts = append(exts, pkix.Extension{Id: oidExtensionExtendedKeyUsage, Value: value})
+	}
+
+	for _, v := range ctx.StringSlice("extension") {
+		ext, err := parseExtension(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing flag '--extension'")
+		}
+		for _, e := range exts {
+			if e.Id.Equal(ext.Id) {
+				return nil, errors.Errorf("error parsing flag '--extension': extension %s is duplicated", ext.Id)
+			}
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// parseExtension parses an extension in the format oid[:critical]=hex.
+func parseExtension(s string) (pkix.Extension, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return pkix.Extension{}, errors.Errorf("extension %s is not in the format oid[:critical]=hex", s)
+	}
+	name, critical := parts[0], false
+	if strings.HasSuffix(name, ":critical") {
+		name, critical = strings.TrimSuffix(name, ":critical"), true
+	}
+	oid, err := parseOID(name)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	value, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return pkix.Extension{}, errors.Errorf("extension %s does not have a hexadecimal value", s)
+	}
+	var raw asn1.RawValue
+	if rest, err := asn1.Unmarshal(value, &raw); err != nil || len(rest) > 0 {
+		return pkix.Extension{}, errors.Errorf("extension %s does not have a DER value", s)
+	}
+	return pkix.Extension{Id: oid, Critical: critical, Value: value}, nil
+}
+
+// parseOID parses an OID in dotted notation.
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, errors.Errorf("%s is not a valid OID", s)
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, errors.Errorf("%s is not a valid OID", s)
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
+
+// printHonoredExtensions prints which of the requested extensions are in the
+// certificate issued by the CA.
+func printHonoredExtensions(crt *x509.Certificate, requested []pkix.Extension) {
+	for _, req := range requested {
+		status := "not honored"
+		for _, ext := range crt.Extensions {
+			if !ext.Id.Equal(req.Id) {
+				continue
+			}
+			if bytes.Equal(ext.Value, req.Value) && ext.Critical == req.Critical {
+				status = "honored"
+			} else {
+				status = "honored with a different value"
+			}
+			break
+		}
+		ui.Printf("Extension {{ \"%s\" | bold }}: %s\n", req.Id, status)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
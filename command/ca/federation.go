@@ -12,6 +12,7 @@ import (
 	"github.com/smallstep/cli/crypto/pki"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/step"
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
@@ -56,6 +57,9 @@ $ step ca roots roots.pem \
 			caURLFlag,
 			rootFlag,
 			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
 		},
 	}
 }
@@ -93,6 +97,9 @@ $ step ca federation federation.pem \
 			caURLFlag,
 			rootFlag,
 			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
 		},
 	}
 }
@@ -123,7 +130,7 @@ func rootsAndFederationFlow(ctx *cli.Context, typ flowType) error {
 		}
 	}
 
-	client, err := ca.NewClient(caURL, ca.WithRootFile(root))
+	client, err := ca.NewClient(caURL, step.WithRootFile(root))
 	if err != nil {
 		return err
 	}
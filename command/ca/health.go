@@ -7,6 +7,7 @@ import (
 	"github.com/smallstep/certificates/ca"
 	"github.com/smallstep/cli/crypto/pki"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/step"
 	"github.com/urfave/cli"
 )
 
@@ -67,7 +68,7 @@ func healthAction(ctx *cli.Context) error {
 			return errs.RequiredFlag(ctx, "root")
 		}
 	}
-	options = append(options, ca.WithRootFile(root))
+	options = append(options, step.WithRootFile(root))
 
 	client, err := ca.NewClient(caURL, options...)
 	if err != nil {
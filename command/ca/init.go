@@ -5,12 +5,16 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io"
+	"net/url"
+	"os"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/crypto/pemutil"
 	"github.com/smallstep/cli/crypto/pki"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
@@ -25,10 +29,29 @@ func initCommand() cli.Command {
 		[**--root**=<path>] [**--key**=<path>] [**--pki**] [**--name**=<name>]
 [**dns**=<dns>] [**address**=<address>] [**provisioner**=<name>]
 [**provisioner-password-file**=<path>] [**password-file**=<path>]
-[**with-ca-url**=<url>] [**no-db**]`,
+[**with-ca-url**=<url>] [**no-db**] [**--interactive**]`,
 		Description: `**step ca init** command initializes a public key infrastructure (PKI) to be
- used by the Certificate Authority`,
-		Flags: []cli.Flag{
+ used by the Certificate Authority
+
+With the **--interactive** flag the command runs a wizard that asks whether to
+create only the PKI, whether to use an existing root, and for the name, DNS
+names, address, provisioner, URL and database of the CA that were not passed.
+At the end it prints the equivalent non-interactive command. The passwords are
+always prompted and never included in the command.
+
+## EXAMPLES
+
+Initialize a PKI and a CA configuration:
+'''
+$ step ca init --name Smallstep --dns ca.smallstep.com --address :443 \
+  --provisioner you@smallstep.com
+'''
+
+Initialize a CA answering the questions of a wizard:
+'''
+$ step ca init --interactive
+'''`,
+		Flags: append([]cli.Flag{
 			cli.StringFlag{
 				Name:   "root",
 				Usage:  "The path of an existing PEM <file> to be used as the root certificate authority.",
@@ -59,10 +82,6 @@ func initCommand() cli.Command {
 				Name:  "provisioner",
 				Usage: "The <name> of the first provisioner.",
 			},
-			cli.StringFlag{
-				Name:  "password-file",
-				Usage: `The path to the <file> containing the password to encrypt the keys.`,
-			},
 			cli.StringFlag{
 				Name:  "provisioner-password-file",
 				Usage: `The path to the <file> containing the password to encrypt the provisioner key.`,
@@ -75,7 +94,11 @@ func initCommand() cli.Command {
 				Name:  "no-db",
 				Usage: `Generate a CA configuration without the DB stanza. No persistence layer.`,
 			},
-		},
+			flags.Interactive,
+		}, flags.Password(cli.StringFlag{
+			Name:  "password-file",
+			Usage: `The path to the <file> containing the password to encrypt the keys.`,
+		})...),
 	}
 }
 
@@ -84,6 +107,12 @@ func initAction(ctx *cli.Context) (err error) {
 		return err
 	}
 
+	if ctx.Bool("interactive") {
+		if err := initWizard(ctx); err != nil {
+			return err
+		}
+	}
+
 	var rootCrt *x509.Certificate
 	var rootKey interface{}
 
@@ -111,12 +140,9 @@ func initAction(ctx *cli.Context) (err error) {
 		return errs.IncompatibleFlagWithFlag(ctx, "pki", "no-db")
 	}
 
-	var password string
-	if passwordFile := ctx.String("password-file"); passwordFile != "" {
-		password, err = utils.ReadStringPasswordFromFile(passwordFile)
-		if err != nil {
-			return err
-		}
+	password, err := utils.ReadStringPasswordFromCLI(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Provisioner password will be equal to the certificate private keys if
@@ -234,6 +260,129 @@ func initAction(ctx *cli.Context) (err error) {
 	return p.Save(opts...)
 }
 
+// initWizard prompts for the flags of step ca init that have not been passed
+// and prints the equivalent non-interactive command. The rest of the command
+// uses the flags set by the wizard.
+func initWizard(ctx *cli.Context) error {
+	if ui.IsNonInteractive() {
+		return errors.New("flag '--interactive' cannot be used in non-interactive mode")
+	}
+
+	if !ctx.IsSet("pki") {
+		v, err := command.WizardSelect("What would you like to initialize?", "Deployment", []command.WizardOption{
+			{Name: "A PKI and the configuration of a step-ca server", Value: "ca"},
+			{Name: "Only the PKI, the root and intermediate certificates", Value: "pki"},
+		})
+		if err != nil {
+			return err
+		}
+		if v == "pki" {
+			if err := command.SetFlag(ctx, "pki", "true"); err != nil {
+				return err
+			}
+		}
+	}
+	configure := !ctx.Bool("pki")
+
+	if !ctx.IsSet("root") && !ctx.IsSet("key") {
+		v, err := command.WizardSelect("What root certificate would you like to use?", "Root", []command.WizardOption{
+			{Name: "Generate a new root certificate", Value: "new"},
+			{Name: "Use an existing root certificate and key", Value: "existing"},
+		})
+		if err != nil {
+			return err
+		}
+		if v == "existing" {
+			root, err := ui.Prompt("What is the path of the root certificate? (e.g. root_ca.crt)",
+				ui.WithValidateFunc(validateWizardFile))
+			if err != nil {
+				return err
+			}
+			key, err := ui.Prompt("What is the path of the root private key? (e.g. root_ca_key)",
+				ui.WithValidateFunc(validateWizardFile))
+			if err != nil {
+				return err
+			}
+			if err := command.SetFlag(ctx, "root", root); err != nil {
+				return err
+			}
+			if err := command.SetFlag(ctx, "key", key); err != nil {
+				return err
+			}
+		}
+	}
+
+	prompts := []struct {
+		flag     string
+		label    string
+		validate func(string) error
+		def      string
+		skip     bool
+	}{
+		{"name", "What would you like to name your new PKI? (e.g. Smallstep)", ui.NotEmpty(), "", false},
+		{"dns", "What DNS names or IP addresses would you like to add to your new CA? (e.g. ca.smallstep.com[,1.1.1.1,etc.])", ui.DNS(), "", !configure},
+		{"address", "What address will your new CA listen at? (e.g. :443)", ui.Address(), ":443", !configure},
+		{"provisioner", "What would you like to name the first provisioner for your new CA? (e.g. you@smallstep.com)", ui.NotEmpty(), "", !configure},
+		{"with-ca-url", "What URL will the clients use to reach your new CA? (e.g. https://ca.smallstep.com) [leave empty to skip]", validateWizardURL, "", !configure},
+	}
+	for _, p := range prompts {
+		if p.skip || ctx.IsSet(p.flag) {
+			continue
+		}
+		v, err := ui.Prompt(p.label, ui.WithValidateFunc(p.validate), ui.WithDefaultValue(p.def))
+		if err != nil {
+			return err
+		}
+		if v != "" {
+			if err := command.SetFlag(ctx, p.flag, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	if configure && !ctx.IsSet("no-db") {
+		v, err := command.WizardSelect("Would you like to persist the certificates in a database?", "Database", []command.WizardOption{
+			{Name: "Yes, use the default Badger database", Value: "db"},
+			{Name: "No, do not configure a database", Value: "no-db"},
+		})
+		if err != nil {
+			return err
+		}
+		if v == "no-db" {
+			if err := command.SetFlag(ctx, "no-db", "true"); err != nil {
+				return err
+			}
+		}
+	}
+
+	ui.Println()
+	ui.Println("The equivalent non-interactive command is:")
+	ui.Println("  " + command.CommandLine(ctx, nil))
+	ui.Println()
+	return nil
+}
+
+func validateWizardFile(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return errors.New("value is empty")
+	}
+	if _, err := os.Stat(s); err != nil {
+		return errors.Errorf("%s does not exist", s)
+	}
+	return nil
+}
+
+func validateWizardURL(s string) error {
+	if s == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.Errorf("%s is not an https URL", s)
+	}
+	return nil
+}
+
 // assertCrytoRand asserts that a cryptographically secure random number
 // generator is available, it will return an error otherwise.
 func assertCryptoRand() error {
@@ -0,0 +1,369 @@
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/smallstep/nosql/database"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ocsp"
+)
+
+const defaultOCSPAddress = "127.0.0.1:9001"
+
+// Tables of the CA database.
+var (
+	certsTable        = []byte("x509_certs")
+	revokedCertsTable = []byte("revoked_x509_certs")
+)
+
+var oidExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+func ocspResponderCommand() cli.Command {
+	return cli.Command{
+		Name:   "ocsp-responder",
+		Action: command.ActionFunc(ocspResponderAction),
+		Usage:  "serve the revocation status of the certificates issued by an offline CA",
+		UsageText: `**step ca ocsp-responder** [**--ca-config**=<file>] [**--password-file**=<file>]
+[**--address**=<address>] [**--validity**=<duration>] [**--crl**=<file>]`,
+		Description: `**step ca ocsp-responder** serves the revocation status of the certificates
+issued by the CA using OCSP (RFC 6960) and a CRL (RFC 5280), so offline or
+air-gapped CAs can provide revocation information. The revocation status is
+read from the database of the CA configuration, the same database used by
+**step ca revoke --offline**, and the responses and the CRL are signed with the
+intermediate key.
+
+The server answers OCSP requests sent with POST, or with GET to
+/<base64-request>, and serves the CRL in DER format at /crl. The responses are
+valid for the duration in **--validity**.
+
+With the **--crl** flag, the CRL is written to a file in PEM format and the
+command exits, so it can be published in a distribution point.
+
+The database is opened by this command, depending on the database type it might
+not be possible to run it at the same time as the CA.
+
+The server stops with SIGINT or SIGTERM.
+
+## EXAMPLES
+
+Serve the revocation status of the CA configured with **step ca init**:
+'''
+$ step ca ocsp-responder --password-file password.txt
+'''
+
+Serve the revocation status at a different address:
+'''
+$ step ca ocsp-responder --ca-config ./ca.json --address :8080
+'''
+
+Write a CRL valid for 7 days:
+'''
+$ step ca ocsp-responder --validity 168h --crl ca.crl
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name: "ca-config, config",
+				Usage: `The <path> to the certificate authority configuration file. Defaults to
+$STEPPATH/config/ca.json`,
+				Value: caConfigFlag.Value,
+			},
+			cli.StringFlag{
+				Name:  "address",
+				Usage: `The <address> the responder will listen at.`,
+				Value: defaultOCSPAddress,
+			},
+			cli.StringFlag{
+				Name:  "validity",
+				Usage: `The <duration> of the OCSP responses and the CRL, e.g. 24h.`,
+				Value: "24h",
+			},
+			cli.StringFlag{
+				Name:  "crl",
+				Usage: `Write the CRL to the PEM <file> and exit instead of running the responder.`,
+			},
+			flags.Force,
+		}, flags.Password(cli.StringFlag{
+			Name:  "password-file",
+			Usage: `The path to the <file> containing the password to decrypt the intermediate key.`,
+		})...),
+	}
+}
+
+func ocspResponderAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	configFile := ctx.String("ca-config")
+	if configFile == "" {
+		return errs.RequiredFlag(ctx, "ca-config")
+	}
+	validity, err := time.ParseDuration(ctx.String("validity"))
+	if err != nil || validity <= 0 {
+		return errs.InvalidFlagValue(ctx, "validity", ctx.String("validity"), "")
+	}
+	var opts []pemutil.Options
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+	if len(password) > 0 {
+		opts = append(opts, pemutil.WithPassword(password))
+	}
+
+	b, err := utils.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	var config authority.Config
+	if err := json.Unmarshal(b, &config); err != nil {
+		return errors.Wrapf(err, "error reading %s", configFile)
+	}
+	if config.DB == nil {
+		return errors.Errorf("error parsing %s: the CA does not have a database", configFile)
+	}
+
+	identity, err := x509util.LoadIdentityFromDisk(config.IntermediateCert, config.IntermediateKey, opts...)
+	if err != nil {
+		return err
+	}
+	signer, ok := identity.Key.(crypto.Signer)
+	if !ok {
+		return errors.Errorf("error parsing %s: key %s cannot be used for signing", configFile, config.IntermediateKey)
+	}
+
+	authDB, err := db.New(config.DB)
+	if err != nil {
+		return errors.Wrap(err, "error opening the CA database")
+	}
+	defer authDB.Shutdown()
+	revDB, ok := authDB.(revocationDB)
+	if !ok {
+		return errors.Errorf("error parsing %s: the database type does not support listing revoked certificates", configFile)
+	}
+
+	r := &revocationResponder{
+		issuer:   identity.Crt,
+		signer:   signer,
+		db:       revDB,
+		validity: validity,
+	}
+
+	if filename := ctx.String("crl"); filename != "" {
+		crl, err := r.createCRL(time.Now())
+		if err != nil {
+			return err
+		}
+		if err := utils.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0644); err != nil {
+			return errs.FileError(err, filename)
+		}
+		ui.PrintSelected("CRL", filename)
+		return nil
+	}
+
+	srv := &http.Server{
+		Addr:    ctx.String("address"),
+		Handler: r,
+	}
+
+	// Stop the server on SIGINT or SIGTERM
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; ok {
+			srv.Close()
+		}
+	}()
+
+	ui.PrintSelected("Listening", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return errors.Wrap(err, "error running the OCSP responder")
+	}
+	return nil
+}
+
+// revocationDB is the part of the CA database used to read the revocation
+// status of the certificates.
+type revocationDB interface {
+	Get(bucket, key []byte) ([]byte, error)
+	List(bucket []byte) ([]*database.Entry, error)
+}
+
+// revocationResponder serves OCSP responses and CRLs signed by the issuer.
+type revocationResponder struct {
+	issuer   *x509.Certificate
+	signer   crypto.Signer
+	db       revocationDB
+	validity time.Duration
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (r *revocationResponder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet && req.URL.Path == "/crl" {
+		crl, err := r.createCRL(time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(crl)
+		return
+	}
+
+	var body []byte
+	var err error
+	switch req.Method {
+	case http.MethodGet:
+		var s string
+		if s, err = url.PathUnescape(strings.TrimPrefix(req.URL.Path, "/")); err == nil {
+			body, err = base64.StdEncoding.DecodeString(s)
+		}
+	case http.MethodPost:
+		body, err = ioutil.ReadAll(http.MaxBytesReader(w, req.Body, 10000))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	resp := r.ocspResponse(body, time.Now())
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
+
+// ocspResponse returns the OCSP response to the given request.
+func (r *revocationResponder) ocspResponse(body []byte, now time.Time) []byte {
+	req, err := ocsp.ParseRequest(body)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse
+	}
+	if !r.isIssuer(req) {
+		return ocsp.UnauthorizedErrorResponse
+	}
+
+	template := ocsp.Response{
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now.Truncate(time.Minute),
+		NextUpdate:   now.Truncate(time.Minute).Add(r.validity),
+	}
+	sn := req.SerialNumber.String()
+	if info, err := r.revocationInfo(sn); err != nil {
+		return ocsp.InternalErrorErrorResponse
+	} else if info != nil {
+		template.Status = ocsp.Revoked
+		template.RevokedAt = info.RevokedAt
+		template.RevocationReason = info.ReasonCode
+	} else if _, err := r.db.Get(certsTable, []byte(sn)); err != nil {
+		template.Status = ocsp.Unknown
+	} else {
+		template.Status = ocsp.Good
+	}
+
+	resp, err := ocsp.CreateResponse(r.issuer, r.issuer, template, r.signer)
+	if err != nil {
+		return ocsp.InternalErrorErrorResponse
+	}
+	return resp
+}
+
+// isIssuer returns true if the OCSP request is for a certificate of the
+// issuer.
+func (r *revocationResponder) isIssuer(req *ocsp.Request) bool {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(r.issuer.RawSubjectPublicKeyInfo, &spki); err != nil || !req.HashAlgorithm.Available() {
+		return false
+	}
+	h := req.HashAlgorithm.New()
+	h.Write(spki.PublicKey.RightAlign())
+	return bytes.Equal(h.Sum(nil), req.IssuerKeyHash)
+}
+
+// revocationInfo returns the revocation information of a serial number, or
+// nil if it is not revoked.
+func (r *revocationResponder) revocationInfo(sn string) (*db.RevokedCertificateInfo, error) {
+	b, err := r.db.Get(revokedCertsTable, []byte(sn))
+	if err != nil {
+		if database.IsErrNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	info := new(db.RevokedCertificateInfo)
+	if err := json.Unmarshal(b, info); err != nil {
+		return nil, errors.Wrapf(err, "error parsing revocation information of %s", sn)
+	}
+	return info, nil
+}
+
+// parseSerialNumber parses a serial number stored in decimal in the database.
+func parseSerialNumber(s string) (*big.Int, bool) {
+	return new(big.Int).SetString(s, 10)
+}
+
+// createCRL returns a DER CRL with all the revoked certificates.
+func (r *revocationResponder) createCRL(now time.Time) ([]byte, error) {
+	entries, err := r.db.List(revokedCertsTable)
+	if err != nil && !database.IsErrNotFound(err) {
+		return nil, errors.Wrap(err, "error listing revoked certificates")
+	}
+	var revoked []pkix.RevokedCertificate
+	for _, e := range entries {
+		var info db.RevokedCertificateInfo
+		if err := json.Unmarshal(e.Value, &info); err != nil {
+			return nil, errors.Wrapf(err, "error parsing revocation information of %s", e.Key)
+		}
+		sn, ok := parseSerialNumber(info.Serial)
+		if !ok {
+			continue
+		}
+		rc := pkix.RevokedCertificate{
+			SerialNumber:   sn,
+			RevocationTime: info.RevokedAt.UTC(),
+		}
+		if info.ReasonCode > 0 {
+			value, err := asn1.Marshal(asn1.Enumerated(info.ReasonCode))
+			if err != nil {
+				return nil, errors.Wrap(err, "error marshaling reason code")
+			}
+			rc.Extensions = []pkix.Extension{{Id: oidExtensionReasonCode, Value: value}}
+		}
+		revoked = append(revoked, rc)
+	}
+
+	crl, err := r.issuer.CreateCRL(rand.Reader, r.signer, revoked, now, now.Add(r.validity))
+	return crl, errors.Wrap(err, "error creating CRL")
+}
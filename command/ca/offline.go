@@ -15,9 +15,12 @@ import (
 	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/provisioner"
 	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/securebuf"
+	"github.com/smallstep/cli/crypto/tlsutil"
 	"github.com/smallstep/cli/crypto/x509util"
 	"github.com/smallstep/cli/exec"
 	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/kms"
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
@@ -53,6 +56,11 @@ func newOfflineCA(configFile string) (*offlineCA, error) {
 		return nil, errors.Errorf("error parsing %s: no provisioners found", configFile)
 	}
 
+	// The authority loads the intermediate key from disk.
+	if kms.IsKMS(config.IntermediateKey) {
+		return nil, errors.Errorf("error parsing %s: key %s is not supported by the offline mode", configFile, config.IntermediateKey)
+	}
+
 	auth, err := authority.New(&config)
 	if err != nil {
 		return nil, err
@@ -65,18 +73,19 @@ func newOfflineCA(configFile string) (*offlineCA, error) {
 	}, nil
 }
 
-// VerifyClientCertificate verifies and validates the client cert/key pair
-// using the offline CA root and intermediate certificates.
-func (c *offlineCA) VerifyClientCert(certFile, keyFile string) error {
-	cert, err := pemutil.ReadCertificate(certFile, pemutil.WithFirstBlock())
-	if err != nil {
-		return err
+// verifyKeyPair validates that the certificate and key match.
+func verifyKeyPair(cert *x509.Certificate, certFile, keyFile string) error {
+	if kms.IsKMS(keyFile) {
+		if _, err := tlsutil.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return errors.Wrap(err, "error loading x509 key pair")
+		}
+		return nil
 	}
+
 	key, err := pemutil.Read(keyFile)
 	if err != nil {
 		return err
 	}
-
 	certPem, err := pemutil.Serialize(cert)
 	if err != nil {
 		return err
@@ -85,10 +94,22 @@ func (c *offlineCA) VerifyClientCert(certFile, keyFile string) error {
 	if err != nil {
 		return err
 	}
-	// Validate that the certificate and key match
 	if _, err := tls.X509KeyPair(pem.EncodeToMemory(certPem), pem.EncodeToMemory(keyPem)); err != nil {
 		return errors.Wrap(err, "error loading x509 key pair")
 	}
+	return nil
+}
+
+// VerifyClientCertificate verifies and validates the client cert/key pair
+// using the offline CA root and intermediate certificates.
+func (c *offlineCA) VerifyClientCert(certFile, keyFile string) error {
+	cert, err := pemutil.ReadCertificate(certFile, pemutil.WithFirstBlock())
+	if err != nil {
+		return err
+	}
+	if err := verifyKeyPair(cert, certFile, keyFile); err != nil {
+		return err
+	}
 
 	rootPool, err := x509util.ReadCertPool(c.Root())
 	if err != nil {
@@ -238,15 +259,8 @@ func (c *offlineCA) GenerateToken(ctx *cli.Context, typ int, subject string, san
 			return "", err
 		}
 		return strings.TrimSpace(string(out)), nil
-	case *provisioner.GCP: // Do the identity request to get the token
-		sharedContext.DisableCustomSANs = p.DisableCustomSANs
-		return p.GetIdentityToken(subject, c.CaURL())
-	case *provisioner.AWS: // Do the identity request to get the token
-		sharedContext.DisableCustomSANs = p.DisableCustomSANs
-		return p.GetIdentityToken(subject, c.CaURL())
-	case *provisioner.Azure: // Do the identity request to get the token
-		sharedContext.DisableCustomSANs = p.DisableCustomSANs
-		return p.GetIdentityToken(subject, c.CaURL())
+	case *provisioner.GCP, *provisioner.AWS, *provisioner.Azure: // Do the identity request to get the token
+		return cloudIdentityToken(ctx, p, subject, c.CaURL())
 	}
 
 	// JWK provisioner
@@ -255,31 +269,68 @@ func (c *offlineCA) GenerateToken(ctx *cli.Context, typ int, subject string, san
 		return "", errors.Errorf("unknown provisioner type %T", p)
 	}
 
-	kid := prov.Key.KeyID
-	issuer := prov.Name
-	encryptedKey := prov.EncryptedKey
+	jwk, err := decryptProvisionerKey(ctx, prov)
+	if err != nil {
+		return "", err
+	}
+
+	return generateToken(typ, subject, sans, prov.Key.KeyID, prov.Name, audience, root, notBefore, notAfter, jwk)
+}
+
+// TokenGenerator returns a tokenGenerator for the JWK provisioner selected by
+// the user.
+func (c *offlineCA) TokenGenerator(ctx *cli.Context, typ int) (*tokenGenerator, error) {
+	p, err := provisionerPrompt(ctx, c.Provisioners())
+	if err != nil {
+		return nil, err
+	}
+	prov, ok := p.(*provisioner.JWK)
+	if !ok {
+		return nil, errors.Errorf("provisioner '%s' cannot be used: only JWK provisioners are supported", p.GetName())
+	}
+
+	jwk, err := decryptProvisionerKey(ctx, prov)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenGenerator{
+		kid:      prov.Key.KeyID,
+		issuer:   prov.Name,
+		audience: c.Audience(typ),
+		root:     c.Root(),
+		jwk:      jwk,
+	}, nil
+}
 
-	// Decrypt encrypted key
+// decryptProvisionerKey decrypts the encrypted key of a JWK provisioner in
+// the ca.json.
+func decryptProvisionerKey(ctx *cli.Context, prov *provisioner.JWK) (*jose.JSONWebKey, error) {
 	opts := []jose.Option{
 		jose.WithUIOptions(ui.WithPromptTemplates(ui.PromptTemplates())),
 	}
-	if passwordFile := ctx.String("password-file"); len(passwordFile) != 0 {
-		opts = append(opts, jose.WithPasswordFile(passwordFile))
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(password) != 0 {
+		opts = append(opts, jose.WithPassword(password))
 	}
 
-	if len(encryptedKey) == 0 {
-		return "", errors.Errorf("provisioner '%s' does not have an 'encryptedKey' property", kid)
+	if len(prov.EncryptedKey) == 0 {
+		return nil, errors.Errorf("provisioner '%s' does not have an 'encryptedKey' property", prov.Key.KeyID)
 	}
 
-	decrypted, err := jose.Decrypt("Please enter the password to decrypt the provisioner key", []byte(encryptedKey), opts...)
+	decrypted, err := jose.Decrypt("Please enter the password to decrypt the provisioner key", []byte(prov.EncryptedKey), opts...)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	jwk := new(jose.JSONWebKey)
-	if err := json.Unmarshal(decrypted, jwk); err != nil {
-		return "", errors.Wrap(err, "error unmarshalling provisioning key")
+	err = json.Unmarshal(decrypted, jwk)
+	securebuf.Zero(decrypted)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling provisioning key")
 	}
-
-	return generateToken(typ, subject, sans, kid, issuer, audience, root, notBefore, notAfter, jwk)
+	return jwk, nil
 }
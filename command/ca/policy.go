@@ -0,0 +1,552 @@
+package ca
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// Default durations of the certificates used by the CA.
+const (
+	defaultMinTLSCertDuration = 5 * time.Minute
+	defaultMaxTLSCertDuration = 24 * time.Hour
+	defaultTLSCertDuration    = 24 * time.Hour
+)
+
+func policyCommand() cli.Command {
+	return cli.Command{
+		Name:      "policy",
+		Usage:     "preview the certificate issuance policies of the CA",
+		UsageText: "step ca policy <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step ca policy** command group provides facilities to preview if the CA
+would issue a certificate without requesting it.
+
+## EXAMPLES
+
+Check if a certificate for a name would be issued by the JWK provisioner
+"ci@example.com":
+'''
+$ step ca policy check --provisioner ci@example.com api.internal.example.com
+'''`,
+		Subcommands: cli.Commands{
+			policyCheckCommand(),
+		},
+	}
+}
+
+func policyCheckCommand() cli.Command {
+	return cli.Command{
+		Name:   "check",
+		Action: command.ActionFunc(policyCheckAction),
+		Usage:  "check if the CA policy allows the issuance of a certificate",
+		UsageText: `**step ca policy check** <subject> [**--san**=<SAN>]
+		[**--provisioner**=<name>] [**--provisioner-kid**=<kid>] [**--provisioner-type**=<type>]
+		[**--not-before**=<time|duration>] [**--not-after**=<time|duration>]
+		[**--policy**=<file>] [**--ca-url**=<uri>] [**--root**=<file>]
+		[**--offline**] [**--ca-config**=<file>]`,
+		Description: `**step ca policy check** reports if the CA would issue a certificate with the
+given subject and SANs using a provisioner, and the validity the certificate
+would have, without requesting it.
+
+The command checks:
+
+* The duration of the certificate against the minimum and maximum durations of
+  the provisioner. If **--not-after** is not set the default duration of the
+  provisioner is used.
+
+* The names of the certificate against the name constraints of the root and,
+  in offline mode, the intermediate certificate.
+
+* The names of the certificate against the CA policy, the "policy" object in
+  the ca.json, or the policy in the **--policy** file. A policy has the format
+  used by **step ca admin policy**, e.g.
+  {"x509": {"allow": {"dns": ["*.example.com"]}, "deny": {"ips": ["10.0.0.1"]}}}.
+
+In online mode the provisioners and their durations are obtained from the CA,
+but the global durations of the CA are unknown and the defaults are used. In
+offline mode all the information is read from the ca.json.
+
+The command fails if the certificate would not be issued.
+
+## POSITIONAL ARGUMENTS
+
+<subject>
+:  The Common Name of the certificate. When there are no SANs it is also used
+as the only SAN.
+
+## EXAMPLES
+
+Check the issuance of a certificate with multiple SANs:
+'''
+$ step ca policy check --provisioner ci@example.com \
+  --san api.example.com --san 10.0.0.10 api.example.com
+'''
+
+Check the issuance of a certificate for 30 days using the ca.json:
+'''
+$ step ca policy check --offline --provisioner ci@example.com \
+  --not-after 720h api.example.com
+'''
+
+Check a name against a new policy before applying it:
+'''
+$ step ca policy check --provisioner acme --policy policy.json api.example.com
+$ step ca admin policy set policy.json
+'''`,
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name: "san",
+				Usage: `Add DNS, IP, email or URI Subject Alternative Names (SANs) to the certificate.
+Use the '--san' flag multiple times to add multiple SANs.`,
+			},
+			provisionerIssuerFlag,
+			provisionerKidFlag,
+			provisionerTypeFlag,
+			cli.StringFlag{
+				Name:  "policy",
+				Usage: `The <file> with the CA policy to check instead of the one in the ca.json.`,
+			},
+			notBeforeFlag,
+			notAfterFlag,
+			caURLFlag,
+			rootFlag,
+			offlineFlag,
+			caConfigFlag,
+		},
+	}
+}
+
+// policyProvisioner is the part of a provisioner used to check the policy.
+type policyProvisioner struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	ClientID string `json:"clientID"`
+	Key      *struct {
+		KeyID string `json:"kid"`
+	} `json:"key"`
+	Claims *policyClaims `json:"claims"`
+}
+
+// policyClaims are the durations of the certificates of a provisioner or the
+// CA.
+type policyClaims struct {
+	MinTLSDur     *policyDuration `json:"minTLSCertDuration"`
+	MaxTLSDur     *policyDuration `json:"maxTLSCertDuration"`
+	DefaultTLSDur *policyDuration `json:"defaultTLSCertDuration"`
+}
+
+// policyDuration is a duration encoded in JSON as a string, e.g. "24h", or
+// as a number of nanoseconds.
+type policyDuration time.Duration
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *policyDuration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = policyDuration(v)
+	case string:
+		dur, err := time.ParseDuration(v)
+		if err != nil {
+			return errors.Wrapf(err, "error parsing duration %s", v)
+		}
+		*d = policyDuration(dur)
+	default:
+		return errors.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// certificatePolicy is the CA policy, the names allowed and denied in the
+// certificates.
+type certificatePolicy struct {
+	X509 *x509Policy `json:"x509"`
+}
+
+type x509Policy struct {
+	Allow *policyNames `json:"allow"`
+	Deny  *policyNames `json:"deny"`
+}
+
+type policyNames struct {
+	DNSDomains     []string `json:"dns"`
+	IPRanges       []string `json:"ips"`
+	EmailAddresses []string `json:"emails"`
+	URIDomains     []string `json:"uris"`
+}
+
+// policyCAConfig is the part of the ca.json used to check the policy.
+type policyCAConfig struct {
+	Root             json.RawMessage `json:"root"`
+	IntermediateCert string          `json:"crt"`
+	AuthorityConfig  struct {
+		Provisioners []*policyProvisioner `json:"provisioners"`
+		Claims       *policyClaims        `json:"claims"`
+		Policy       *certificatePolicy   `json:"policy"`
+	} `json:"authority"`
+}
+
+// policyCheck contains the information used to check the issuance of a
+// certificate.
+type policyCheck struct {
+	provisioners []*policyProvisioner
+	claims       *policyClaims
+	policy       *certificatePolicy
+	caCerts      []*x509.Certificate
+}
+
+func policyCheckAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	subject := ctx.Args().Get(0)
+	sans := ctx.StringSlice("san")
+	if len(sans) == 0 {
+		sans = []string{subject}
+	}
+
+	var pc *policyCheck
+	var err error
+	if ctx.Bool("offline") {
+		pc, err = newOfflinePolicyCheck(ctx)
+	} else {
+		pc, err = newOnlinePolicyCheck(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if filename := ctx.String("policy"); filename != "" {
+		b, err := utils.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		pc.policy = new(certificatePolicy)
+		if err := json.Unmarshal(b, pc.policy); err != nil {
+			return errors.Wrapf(err, "error parsing %s", filename)
+		}
+	}
+
+	p, err := pc.selectProvisioner(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Validity of the certificate
+	minDur, maxDur, defDur := pc.durations(p)
+	notBefore, ok := flags.ParseTimeOrDuration(ctx.String("not-before"))
+	if !ok {
+		return errs.InvalidFlagValue(ctx, "not-before", ctx.String("not-before"), "")
+	}
+	notAfter, ok := flags.ParseTimeOrDuration(ctx.String("not-after"))
+	if !ok {
+		return errs.InvalidFlagValue(ctx, "not-after", ctx.String("not-after"), "")
+	}
+	if notBefore.IsZero() {
+		notBefore = time.Now()
+	}
+	if notAfter.IsZero() {
+		notAfter = notBefore.Add(defDur)
+	}
+
+	var problems []string
+	duration := notAfter.Sub(notBefore)
+	switch {
+	case duration < minDur:
+		problems = append(problems, fmt.Sprintf("the duration %s is shorter than the minimum %s", duration, minDur))
+	case duration > maxDur:
+		problems = append(problems, fmt.Sprintf("the duration %s is longer than the maximum %s", duration, maxDur))
+	}
+
+	// Names of the certificate
+	crt := &x509.Certificate{
+		Subject: pkix.Name{CommonName: subject},
+	}
+	for _, san := range sans {
+		switch {
+		case strings.Contains(san, "://"):
+			u, err := url.Parse(san)
+			if err != nil {
+				return errs.InvalidFlagValue(ctx, "san", san, "")
+			}
+			crt.URIs = append(crt.URIs, u)
+		case net.ParseIP(san) != nil:
+			crt.IPAddresses = append(crt.IPAddresses, net.ParseIP(san))
+		case strings.Contains(san, "@"):
+			crt.EmailAddresses = append(crt.EmailAddresses, san)
+		default:
+			crt.DNSNames = append(crt.DNSNames, san)
+		}
+	}
+	for _, caCert := range pc.caCerts {
+		for _, reason := range x509util.CheckNameConstraints(caCert, crt) {
+			problems = append(problems, fmt.Sprintf("'%s' %s", x509util.CertificateName(caCert), reason))
+		}
+		if notAfter.After(caCert.NotAfter) {
+			problems = append(problems, fmt.Sprintf("the certificate would expire after '%s' on %s", x509util.CertificateName(caCert), caCert.NotAfter.Format(time.RFC3339)))
+		}
+	}
+	problems = append(problems, pc.policy.check(crt)...)
+
+	ui.Printf("{{ \"Provisioner\" | bold }}: %s (%s)\n", p.Name, p.Type)
+	ui.Printf("{{ \"Subject\" | bold }}: %s\n", subject)
+	ui.Printf("{{ \"SANs\" | bold }}: %s\n", strings.Join(sans, ", "))
+	ui.Printf("{{ \"Validity\" | bold }}: %s to %s (%s)\n", notBefore.UTC().Format(time.RFC3339), notAfter.UTC().Format(time.RFC3339), duration.Round(time.Second))
+	ui.Printf("{{ \"Durations\" | bold }}: min %s, max %s, default %s\n", minDur, maxDur, defDur)
+	if len(problems) == 0 {
+		ui.Printf("{{ \"Result\" | bold }}: {{ \"allowed\" | green }}\n")
+		return nil
+	}
+	ui.Printf("{{ \"Result\" | bold }}: {{ \"denied\" | red }}\n")
+	for _, s := range problems {
+		ui.Printf("  - %s\n", s)
+	}
+	return errs.Policy(errors.New("the certificate would not be issued"))
+}
+
+// newOnlinePolicyCheck gets the provisioners from the CA and uses the name
+// constraints of the root certificate.
+func newOnlinePolicyCheck(ctx *cli.Context) (*policyCheck, error) {
+	caURL := ctx.String("ca-url")
+	if caURL == "" {
+		return nil, errs.RequiredUnlessFlag(ctx, "ca-url", "offline")
+	}
+	root := ctx.String("root")
+	if root == "" {
+		root = pki.GetRootCAPath()
+		if !utils.FileExists(root) {
+			return nil, errs.RequiredUnlessFlag(ctx, "root", "offline")
+		}
+	}
+
+	list, err := pki.GetProvisioners(caURL, root)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the provisioners")
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling provisioners")
+	}
+	pc := new(policyCheck)
+	if err := json.Unmarshal(b, &pc.provisioners); err != nil {
+		return nil, errors.Wrap(err, "error parsing provisioners")
+	}
+	if pc.caCerts, err = pemutil.ReadCertificateBundle(root); err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
+
+// newOfflinePolicyCheck reads the provisioners, durations, policy, root and
+// intermediate certificates from the ca.json.
+func newOfflinePolicyCheck(ctx *cli.Context) (*policyCheck, error) {
+	caConfig := ctx.String("ca-config")
+	if caConfig == "" {
+		return nil, errs.InvalidFlagValue(ctx, "ca-config", "", "")
+	}
+	b, err := utils.ReadFile(caConfig)
+	if err != nil {
+		return nil, err
+	}
+	var config policyCAConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", caConfig)
+	}
+
+	var roots []string
+	if len(config.Root) > 0 {
+		var root string
+		if err := json.Unmarshal(config.Root, &root); err == nil {
+			roots = []string{root}
+		} else if err := json.Unmarshal(config.Root, &roots); err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s", caConfig)
+		}
+	}
+	if config.IntermediateCert != "" {
+		roots = append(roots, config.IntermediateCert)
+	}
+
+	pc := &policyCheck{
+		provisioners: config.AuthorityConfig.Provisioners,
+		claims:       config.AuthorityConfig.Claims,
+		policy:       config.AuthorityConfig.Policy,
+	}
+	for _, filename := range roots {
+		certs, err := pemutil.ReadCertificateBundle(filename)
+		if err != nil {
+			return nil, err
+		}
+		pc.caCerts = append(pc.caCerts, certs...)
+	}
+	return pc, nil
+}
+
+// selectProvisioner returns the provisioner selected by the --provisioner,
+// --provisioner-kid and --provisioner-type flags, prompting for one if
+// multiple provisioners match.
+func (pc *policyCheck) selectProvisioner(ctx *cli.Context) (*policyProvisioner, error) {
+	name, kid, typ := ctx.String("issuer"), ctx.String("kid"), ctx.String("provisioner-type")
+	var items []*provisionersSelect
+	var list []*policyProvisioner
+	for _, p := range pc.provisioners {
+		switch {
+		case name != "" && p.Name != name:
+		case typ != "" && !strings.EqualFold(p.Type, typ):
+		case kid != "" && p.ClientID != kid && (p.Key == nil || p.Key.KeyID != kid):
+		default:
+			items = append(items, &provisionersSelect{
+				Name: fmt.Sprintf("%s (%s)", p.Name, p.Type),
+			})
+			list = append(list, p)
+		}
+	}
+
+	switch {
+	case len(list) == 0:
+		return nil, errors.New("there are no provisioners matching the given flags")
+	case len(list) == 1:
+		return list[0], nil
+	case ui.IsNonInteractive():
+		names := make([]string, len(items))
+		for i, item := range items {
+			names[i] = item.Name
+		}
+		return nil, errs.Usage(errors.Errorf("cannot select a provisioner in non-interactive mode, %d provisioners match: %s; "+
+			"use the flags '--provisioner', '--provisioner-kid' or '--provisioner-type' to select one",
+			len(items), strings.Join(names, ", ")))
+	}
+
+	i, _, err := ui.Select("What provisioner do you want to check?", items, ui.WithSelectTemplates(ui.NamedSelectTemplates("Provisioner")))
+	if err != nil {
+		return nil, err
+	}
+	return list[i], nil
+}
+
+// durations returns the minimum, maximum and default durations of the
+// certificates issued by the given provisioner.
+func (pc *policyCheck) durations(p *policyProvisioner) (minDur, maxDur, defDur time.Duration) {
+	minDur, maxDur, defDur = defaultMinTLSCertDuration, defaultMaxTLSCertDuration, defaultTLSCertDuration
+	for _, c := range []*policyClaims{pc.claims, p.Claims} {
+		if c == nil {
+			continue
+		}
+		if c.MinTLSDur != nil {
+			minDur = time.Duration(*c.MinTLSDur)
+		}
+		if c.MaxTLSDur != nil {
+			maxDur = time.Duration(*c.MaxTLSDur)
+		}
+		if c.DefaultTLSDur != nil {
+			defDur = time.Duration(*c.DefaultTLSDur)
+		}
+	}
+	return
+}
+
+// check returns the reasons why the names of the certificate are not allowed
+// by the policy. Denied names always fail, and if the policy has any allowed
+// name all the names must be allowed.
+func (p *certificatePolicy) check(crt *x509.Certificate) []string {
+	if p == nil || p.X509 == nil {
+		return nil
+	}
+	allow, deny := p.X509.Allow, p.X509.Deny
+	if allow == nil {
+		allow = new(policyNames)
+	}
+	if deny == nil {
+		deny = new(policyNames)
+	}
+	hasAllow := len(allow.DNSDomains)+len(allow.IPRanges)+len(allow.EmailAddresses)+len(allow.URIDomains) > 0
+
+	var problems []string
+	checkName := func(kind, name string, match func([]string) bool, allowed, denied []string) {
+		switch {
+		case match(denied):
+			problems = append(problems, fmt.Sprintf("the CA policy denies the %s %s", kind, name))
+		case hasAllow && !match(allowed):
+			problems = append(problems, fmt.Sprintf("the CA policy does not allow the %s %s", kind, name))
+		}
+	}
+	for _, name := range crt.DNSNames {
+		checkName("DNS name", name, func(l []string) bool { return matchPolicyDomains(name, l) }, allow.DNSDomains, deny.DNSDomains)
+	}
+	for _, ip := range crt.IPAddresses {
+		checkName("IP address", ip.String(), func(l []string) bool { return matchPolicyIPs(ip, l) }, allow.IPRanges, deny.IPRanges)
+	}
+	for _, email := range crt.EmailAddresses {
+		checkName("email", email, func(l []string) bool { return matchPolicyEmails(email, l) }, allow.EmailAddresses, deny.EmailAddresses)
+	}
+	for _, u := range crt.URIs {
+		checkName("URI", u.String(), func(l []string) bool { return matchPolicyDomains(u.Hostname(), l) }, allow.URIDomains, deny.URIDomains)
+	}
+	return problems
+}
+
+// matchPolicyDomains returns if the name matches any of the domains, a domain
+// starting with "*." matches any subdomain.
+func matchPolicyDomains(name string, domains []string) bool {
+	name = strings.ToLower(name)
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		if strings.HasPrefix(d, "*.") {
+			if strings.HasSuffix(name, d[1:]) && len(name) > len(d)-1 {
+				return true
+			}
+		} else if name == d {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPolicyIPs returns if the IP is one of the IP addresses or ranges.
+func matchPolicyIPs(ip net.IP, ranges []string) bool {
+	for _, r := range ranges {
+		if _, ipNet, err := net.ParseCIDR(r); err == nil {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		} else if ip.Equal(net.ParseIP(r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPolicyEmails returns if the email is one of the emails, or if its
+// domain matches a constraint with the format "@domain" or "domain".
+func matchPolicyEmails(email string, emails []string) bool {
+	i := strings.LastIndex(email, "@")
+	for _, e := range emails {
+		switch {
+		case strings.EqualFold(email, e):
+			return true
+		case i >= 0 && strings.HasPrefix(e, "@") && strings.EqualFold(email[i:], e):
+			return true
+		case i >= 0 && !strings.Contains(e, "@") && matchPolicyDomains(email[i+1:], []string{e}):
+			return true
+		}
+	}
+	return false
+}
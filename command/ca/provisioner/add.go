@@ -1,12 +1,15 @@
 package provisioner
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/url"
 	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/jose"
@@ -22,6 +25,7 @@ func addCommand() cli.Command {
 		Usage:  "add one or more provisioners the CA configuration",
 		UsageText: `**step ca provisioner add** <name> <jwk-file> [<jwk-file> ...]
 **--ca-config**=<file> [**--type**=JWK]  [**--create**] [**--password-file**=<file>]
+[**--dry-run**]
 
 **step ca provisioner add** <name> **--type**=OIDC **--ca-config**=<file>
 [**--client-id**=<id>] [**--client-secret**=<secret>]
@@ -33,7 +37,7 @@ func addCommand() cli.Command {
 [**--gcp-service-account**=<name>] [**--gcp-project**=<name>]
 [**--azure-tenant**=<id>] [**--azure-resource-group**=<name>]
 [**--instance-age**=<duration>] [**--disable-custom-sans**] [**--disable-trust-on-first-use**]`,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			cli.StringFlag{
 				Name:  "ca-config",
 				Usage: "The <file> containing the CA configuration.",
@@ -87,7 +91,6 @@ will not have restrictions in the certificates to sign. Use the
 				Usage: `The <domain> used to validate the email claim in an OpenID Connect provisioner.
 Use the '--domain' flag multiple times to configure multiple domains.`,
 			},
-			flags.PasswordFile,
 			cli.StringSliceFlag{
 				Name: "aws-account",
 				Usage: `The AWS account <id> used to validate the identity documents.
@@ -130,10 +133,15 @@ By default it will accept any SAN in the CSR.`,
 with the same instance will be accepted. By default only the first request
 will be accepted.`,
 			},
-		},
+			flags.DryRun,
+		}, flags.Password(flags.PasswordFile)...),
 		Description: `**step ca provisioner add** adds one or more provisioners
 to the configuration and writes the new configuration back to the CA config.
 
+With the **--dry-run** flag the new provisioners are printed but the CA config
+is not modified. The keys of JWK provisioners are still read, or generated with
+**--create**, and encrypted.
+
 ## POSITIONAL ARGUMENTS
 
 <name>
@@ -211,6 +219,12 @@ document and will allow multiple certificates from the same instance:
 '''
 $ step ca provisioner add Amazon --type AWS --ca-config ca.json \
   --aws-account 123456789 --disable-custom-sans --disable-trust-on-first-use
+'''
+
+Print the provisioner that would be added without modifying ca.json:
+'''
+$ step ca provisioner add Amazon --type AWS --ca-config ca.json \
+  --aws-account 123456789 --dry-run
 '''`,
 	}
 }
@@ -263,17 +277,24 @@ func addAction(ctx *cli.Context) (err error) {
 		return err
 	}
 
+	if ctx.Bool("dry-run") {
+		b, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling provisioners")
+		}
+		command.DryRunf("Would add %d provisioner(s) to %s:", len(list), config)
+		fmt.Println(string(b))
+		return nil
+	}
+
 	c.AuthorityConfig.Provisioners = append(c.AuthorityConfig.Provisioners, list...)
 	return c.Save(config)
 }
 
 func addJWKProvisioner(ctx *cli.Context, name string, provMap map[string]bool) (list provisioner.List, err error) {
-	var password string
-	if passwordFile := ctx.String("password-file"); len(passwordFile) > 0 {
-		password, err = utils.ReadStringPasswordFromFile(passwordFile)
-		if err != nil {
-			return nil, err
-		}
+	password, err := utils.ReadStringPasswordFromCLI(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	if ctx.Bool("create") {
@@ -0,0 +1,118 @@
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/errs"
+)
+
+// readPublicKey reads a PEM or DER public key, or the public key of a
+// certificate or a CSR, from the given file, or from STDIN if the filename is
+// "-".
+func readPublicKey(filename string) (crypto.PublicKey, error) {
+	var b []byte
+	var err error
+	if filename == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+		filename = "STDIN"
+	} else {
+		b, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, errs.FileError(err, filename)
+	}
+
+	var key interface{}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN ")) {
+		key, err = pemutil.ParseKey(b, pemutil.WithFilename(filename))
+	} else {
+		key, err = pemutil.ParseDER(b)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading public key from %s", filename)
+	}
+	if signer, ok := key.(crypto.Signer); ok {
+		return signer.Public(), nil
+	}
+	return key, nil
+}
+
+// readPublicKeyCSR reads the CSR signed by the holder of the given public key,
+// checking its signature as the proof of possession of the private key.
+func readPublicKeyCSR(filename string, pub crypto.PublicKey) (api.CertificateRequest, error) {
+	csr, err := pemutil.Read(filename)
+	if err != nil {
+		return api.CertificateRequest{}, err
+	}
+	cr, ok := csr.(*x509.CertificateRequest)
+	if !ok {
+		return api.CertificateRequest{}, errors.Errorf("error reading %s: file is not a certificate request", filename)
+	}
+	if err := cr.CheckSignature(); err != nil {
+		return api.CertificateRequest{}, errors.Wrapf(err, "error verifying the signature of %s", filename)
+	}
+	if !publicKeyEqual(cr.PublicKey, pub) {
+		return api.CertificateRequest{}, errors.Errorf("the public key in %s does not match the flag '--public-key'", filename)
+	}
+	return api.CertificateRequest{CertificateRequest: cr}, nil
+}
+
+// commandSigner is a crypto.Signer for a private key that is only available
+// to an external command, like a tool of a hardware device. The command reads
+// the digest, or the message for Ed25519 keys, from STDIN and writes the
+// signature to STDOUT.
+type commandSigner struct {
+	pub     crypto.PublicKey
+	command string
+}
+
+func newCommandSigner(pub crypto.PublicKey, command string) *commandSigner {
+	return &commandSigner{
+		pub:     pub,
+		command: command,
+	}
+}
+
+// Public returns the public key of the signer.
+func (s *commandSigner) Public() crypto.PublicKey {
+	return s.pub
+}
+
+// Sign runs the signer command with the digest in its standard input.
+func (s *commandSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	parts := strings.Fields(s.command)
+	if len(parts) == 0 {
+		return nil, errors.New("signer command is empty")
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd.Stdin = bytes.NewReader(digest)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "error running '%s'", s.command)
+	}
+	return stdout.Bytes(), nil
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	ab, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	bb, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ab, bb)
+}
@@ -0,0 +1,271 @@
+package ca
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/trace"
+	"github.com/urfave/cli"
+)
+
+const (
+	// maxRetries is the maximum number of times a request rejected by the CA
+	// with a 429 Too Many Requests is sent again.
+	maxRetries = 3
+	// maxRetryAfter is the maximum time to wait before sending again a
+	// request rejected with a 429 Too Many Requests. If the CA asks to wait
+	// longer the error is returned.
+	maxRetryAfter = 5 * time.Minute
+	// defaultRetryAfter is the time to wait after the first 429 Too Many
+	// Requests without a valid Retry-After header, it is doubled on each
+	// retry.
+	defaultRetryAfter = 5 * time.Second
+)
+
+var (
+	jitterFlag = cli.StringFlag{
+		Name: "jitter",
+		Usage: `The maximum random <duration> to wait before sending the requests to the CA,
+so hosts running the same command at the same time, for example after a deploy,
+do not hit the CA at the same second. In daemon mode the random delay is added
+to every renewal. The <duration> is a sequence of decimal numbers, each with
+optional fraction and a unit suffix, such as "30s", "1.5m" or "1h". Valid time
+units are "ns", "us" (or "µs"), "ms", "s", "m", "h".`,
+	}
+
+	rateLimitFlag = cli.Float64Flag{
+		Name: "rate-limit",
+		Usage: `The maximum <number> of requests per second sent to the CA, e.g. 10 or 0.5.
+By default the requests are not limited.`,
+	}
+)
+
+// parseJitter returns the value of the --jitter flag.
+func parseJitter(ctx *cli.Context) (time.Duration, error) {
+	s := ctx.String("jitter")
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errs.InvalidFlagValue(ctx, "jitter", s, "")
+	}
+	return d, nil
+}
+
+// randomJitter returns a random duration between 0 and max.
+func randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// jitterOnce returns a function that waits a random time up to max the first
+// time it is called. Concurrent calls wait for the first one to finish, and
+// the following calls return immediately.
+func jitterOnce(max time.Duration) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			time.Sleep(randomJitter(max))
+		})
+	}
+}
+
+// rateLimiter limits the rate of the requests sent to the CA, and delays them
+// when the CA responds with a 429 Too Many Requests. A rateLimiter can be
+// shared by multiple clients and goroutines.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter returns a rateLimiter with the rate in the --rate-limit flag.
+// Without the flag the requests are only delayed by the 429 responses.
+func newRateLimiter(ctx *cli.Context) (*rateLimiter, error) {
+	l := new(rateLimiter)
+	if ctx.IsSet("rate-limit") {
+		rate := ctx.Float64("rate-limit")
+		if rate <= 0 {
+			return nil, errs.InvalidFlagValue(ctx, "rate-limit", ctx.String("rate-limit"), "")
+		}
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return l, nil
+}
+
+// Wait blocks until a new request can be sent or the context is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	t := l.next
+	if now := time.Now(); t.Before(now) {
+		t = now
+	}
+	l.next = t.Add(l.interval)
+	l.mu.Unlock()
+
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryAfter returns the time to wait before the CA accepts new requests, as
+// requested by the last 429 response.
+func (l *rateLimiter) RetryAfter() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if d := time.Until(l.next); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// delay delays all the requests until the given time.
+func (l *rateLimiter) delay(t time.Time) {
+	l.mu.Lock()
+	if t.After(l.next) {
+		l.next = t
+	}
+	l.mu.Unlock()
+}
+
+// Transport returns an http.RoundTripper that sends the requests to the given
+// one with the rate of the limiter, and sends again the requests rejected with
+// a 429 Too Many Requests after the time in their Retry-After header.
+func (l *rateLimiter) Transport(rt http.RoundTripper) http.RoundTripper {
+	return l.TransportWithContext(context.Background(), rt)
+}
+
+// TransportWithContext is like Transport, but the waits for the limiter are
+// also canceled when the given context is done, for example when the renew
+// daemon is stopped.
+func (l *rateLimiter) TransportWithContext(ctx context.Context, rt http.RoundTripper) http.RoundTripper {
+	return &rateLimitTransport{
+		ctx:     ctx,
+		limiter: l,
+		next:    rt,
+	}
+}
+
+// WithRootFile returns a ca.ClientOption like step.WithRootFile that sends the
+// requests through the limiter.
+func (l *rateLimiter) WithRootFile(root string) (ca.ClientOption, error) {
+	pool, err := x509util.ReadCertPool(root)
+	if err != nil {
+		return nil, err
+	}
+	return ca.WithTransport(l.Transport(trace.Transport(&http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{
+			RootCAs:                  pool,
+			PreferServerCipherSuites: true,
+		},
+	}))), nil
+}
+
+type rateLimitTransport struct {
+	ctx     context.Context
+	limiter *rateLimiter
+	next    http.RoundTripper
+}
+
+// wait waits for the limiter until the request context or the transport
+// context are done.
+func (t *rateLimitTransport) wait(req *http.Request) error {
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-t.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return t.limiter.Wait(ctx)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for i := 0; ; i++ {
+		if err := t.wait(req); err != nil {
+			return nil, err
+		}
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+
+		d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			d = defaultRetryAfter << uint(i)
+		}
+		// A CA asking to wait longer than maxRetryAfter only delays the next
+		// requests for maxRetryAfter, and this one is not sent again.
+		retry := d <= maxRetryAfter
+		if !retry {
+			d = maxRetryAfter
+		}
+		t.limiter.delay(time.Now().Add(d))
+		// Requests with a body can only be sent again if it can be read
+		// again.
+		if i >= maxRetries || !retry || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			// A RoundTripper must not modify the original request.
+			r := new(http.Request)
+			*r = *req
+			r.Body = body
+			req = r
+		}
+	}
+}
+
+// parseRetryAfter returns the duration in a Retry-After header, in seconds or
+// as an HTTP date.
+func parseRetryAfter(s string, now time.Time) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return time.Duration(n) * time.Second, true
+	}
+	t, err := http.ParseTime(s)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
@@ -0,0 +1,158 @@
+package ca
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"seconds", "120", 2 * time.Minute, true},
+		{"seconds with spaces", " 5 ", 5 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"date", now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{"past date", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"empty", "", 0, false},
+		{"negative", "-1", 0, false},
+		{"fraction", "1.5", 0, false},
+		{"invalid", "tomorrow", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// tooManyRequests returns a handler that responds with a 429 and the given
+// Retry-After header to the first n requests, and counts the requests.
+func tooManyRequests(n int32, retryAfter string, count *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(count, 1) <= n {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write(body)
+	}
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		rejections int32
+		retryAfter string
+		body       bool
+		wantStatus int
+		wantCount  int32
+	}{
+		{"ok", 0, "0", false, http.StatusOK, 1},
+		{"retry", 2, "0", false, http.StatusOK, 3},
+		{"retry with body", 2, "0", true, http.StatusOK, 3},
+		{"too many retries", 10, "0", false, http.StatusTooManyRequests, maxRetries + 1},
+		{"retry after too long", 10, "3600", false, http.StatusTooManyRequests, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var count int32
+			srv := httptest.NewServer(tooManyRequests(tt.rejections, tt.retryAfter, &count))
+			defer srv.Close()
+
+			limiter := new(rateLimiter)
+			client := &http.Client{Transport: limiter.Transport(http.DefaultTransport)}
+			var resp *http.Response
+			var err error
+			if tt.body {
+				resp, err = client.Post(srv.URL, "text/plain", bytes.NewReader([]byte("the body")))
+			} else {
+				resp, err = client.Get(srv.URL)
+			}
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			require.Equal(t, tt.wantCount, atomic.LoadInt32(&count))
+			if tt.body && resp.StatusCode == http.StatusOK {
+				b, err := ioutil.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Equal(t, []byte("the body"), b)
+			}
+		})
+	}
+}
+
+func TestRateLimitTransportMaxRetryAfter(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(tooManyRequests(1, "86400", &count))
+	defer srv.Close()
+
+	// The CA asks to wait a day, the next requests are only delayed by
+	// maxRetryAfter.
+	limiter := new(rateLimiter)
+	client := &http.Client{Transport: limiter.Transport(http.DefaultTransport)}
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	d := limiter.RetryAfter()
+	require.True(t, d <= maxRetryAfter, d)
+	require.True(t, d > maxRetryAfter-time.Minute, d)
+}
+
+func TestRateLimitTransportCancel(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(tooManyRequests(0, "0", &count))
+	defer srv.Close()
+
+	limiter := new(rateLimiter)
+	limiter.delay(time.Now().Add(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &http.Client{Transport: limiter.TransportWithContext(ctx, http.DefaultTransport)}
+
+	errc := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		errc <- err
+	}()
+	cancel()
+	select {
+	case err := <-errc:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request was not canceled")
+	}
+	require.Equal(t, int32(0), atomic.LoadInt32(&count))
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := &rateLimiter{interval: 50 * time.Millisecond}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, limiter.Wait(context.Background()))
+	}
+	require.True(t, time.Since(start) >= 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.delay(time.Now().Add(time.Hour))
+	require.Equal(t, context.Canceled, limiter.Wait(ctx))
+}
@@ -1,29 +1,34 @@
 package ca
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/pem"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/smallstep/certificates/api"
 	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli/certstore"
 	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
 	"github.com/smallstep/cli/crypto/pemutil"
 	"github.com/smallstep/cli/crypto/pki"
-	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/crypto/tlsutil"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/kms"
+	"github.com/smallstep/cli/step"
+	"github.com/smallstep/cli/trace"
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
@@ -36,7 +41,18 @@ func renewCertificateCommand() cli.Command {
 		Usage:  "renew a valid certificate",
 		UsageText: `**step ca renew** <crt-file> <key-file>
 		[**--ca-url**=<uri>] [**--root**=<file>]
-		[**--out**=<file>] [**--expires-in**=<duration>] [**--force**]`,
+		[**--out**=<file>] [**--expires-in**=<duration>] [**--force**]
+		[**--mode**=<mode>] [**--owner**=<user>] [**--group**=<group>]
+		[**--daemon**] [**--renew-period**=<duration>] [**--install-service**]
+		[**--service-name**=<name>] [**--service-user**=<user>]
+		[**--service-interval**=<duration>] [**--install-store**=<store>] [**--dry-run**]
+		[**--jitter**=<duration>] [**--rate-limit**=<number>]
+
+**step ca renew** **--renew-all** [<dir>]
+		[**--ca-url**=<uri>] [**--root**=<file>] [**--expires-in**=<duration>]
+		[**--concurrency**=<number>] [**--report**=<file>] [**--resume**]
+		[**--pid**=<pid>] [**--signal**=<number>] [**--exec**=<command>]
+		[**--jitter**=<duration>] [**--rate-limit**=<number>]`,
 		Description: `
 **step ca renew** command renews the given certificate (with a request to the
 certificate authority) and writes the new certificate to disk - either overwriting
@@ -49,16 +65,49 @@ instances running at the same time. The amount of time between renewal and
 certificate expiration can be configured using the **--expires-in** flag, or a
 fixed period can be set with the **--renew-period** flag.
 
+With the **--renew-all** flag the command will look for certificates and keys in
+<dir>, by default the current directory, and renew the ones that expire within
+the **--expires-in** duration, by default 1/3 of their validity period. A
+certificate <name>.crt or <name>.pem is paired with the key <name>.key or
+<name>_key. The certificates are renewed concurrently and a summary can be
+written with the **--report** flag. If some renewals fail, the same command with
+the **--resume** flag will only retry the certificates that failed.
+
 The **--daemon** flag can be combined with **--pid**, **--signal**, or **--exec**
 to provide certificate reloads on your services.
 
+In daemon mode the root certificate, and the CA URL and root defined in the
+defaults.json, are reloaded before each renewal if they have changed, so a root
+rotation does not require a restart of the daemon. Sending a SIGHUP signal to
+the daemon reloads them and renews the certificate immediately.
+
+To avoid a fleet of hosts renewing their certificates at the same second, for
+example after a deploy, the **--jitter** flag adds a random delay before the
+renewal requests, and the **--rate-limit** flag limits the number of requests
+per second sent with **--renew-all**. If the CA rejects a request with a 429
+Too Many Requests, the request is sent again after the time in its Retry-After
+header, and a daemon schedules the next attempt after it.
+
+On Windows, the **--install-store** flag imports the renewed certificate and its
+key in the Windows certificate store, replacing the previous certificate, so
+services like IIS or SQL Server can use it.
+
+With the **--install-service** flag, instead of renewing the certificate, the
+command writes and enables a systemd service and timer on Linux, or a launchd
+job on macOS, that runs **step ca renew** periodically with the same
+arguments. The certificate is renewed when it expires within the
+**--expires-in** duration, by default 1/3 of its validity period. When run as
+root the service is installed system-wide and it can run as another user with
+**--service-user**; otherwise it is installed for the current user.
+
 ## POSITIONAL ARGUMENTS
 
 <crt-file>
 :  The certificate in PEM format that we want to renew.
 
 <key-file>
-:  They key file of the certificate.
+:  They key file of the certificate. A key URI can also be used for keys that
+are not stored in a file, e.g. yubikey:9a or pkcs11:token=my-token;object=my-key?module-path=<path>.
 
 ## EXAMPLES
 
@@ -106,6 +155,17 @@ Renew the certificate every 16h:
 $ step ca renew --daemon --renew-period 16h internal.crt internal.key
 '''
 
+Renew all the certificates in a directory that expire in less than 24h, writing
+a summary to report.json:
+'''
+$ step ca renew --renew-all --expires-in 24h --report report.json /etc/certs
+'''
+
+Retry the certificates that failed in the previous run:
+'''
+$ step ca renew --renew-all --expires-in 24h --resume --report report.json /etc/certs
+'''
+
 Renew the certificate and reload nginx:
 '''
 $ step ca renew --daemon --exec "nginx -s reload" internal.crt internal.key
@@ -118,10 +178,35 @@ $ step ca renew --daemon --renew-period 16h \
   internal.crt internal.key
 '''
 
+Install a systemd timer, or a launchd job in macOS, that renews the
+certificate and reloads nginx:
+'''
+$ sudo step ca renew --install-service --service-user www-data \
+  --exec "nginx -s reload" internal.crt internal.key
+'''
+
+Renew a certificate and replace it in the Windows certificate store of the
+computer:
+'''
+$ step ca renew --daemon --install-store My/LocalMachine internal.crt internal.key
+'''
+
 Renew a certificate using the offline mode, requires the configuration
 files, certificates, and keys created with **step ca init**:
 '''
 $ step ca renew --offline internal.crt internal.key
+'''
+
+Renew the certificates in a directory with at most 5 requests per second,
+after a random delay of up to 10 minutes:
+'''
+$ step ca renew --renew-all --jitter 10m --rate-limit 5 /etc/certs
+'''
+
+Print when a renew daemon would renew the certificate, and what it would do
+after the renewal:
+'''
+$ step ca renew --daemon --exec "nginx -s reload" --dry-run internal.crt internal.key
 '''`,
 		Flags: []cli.Flag{
 			caURLFlag,
@@ -170,14 +255,65 @@ Requires the **--daemon** flag. The <duration> is a sequence of decimal numbers,
 each with optional fraction and a unit suffix, such as "300ms", "1.5h", or "2h45m".
 Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".`,
 			},
+			cli.BoolFlag{
+				Name: "install-service",
+				Usage: `Write and enable a systemd service and timer, or a launchd job in macOS, that
+renews the certificate periodically instead of renewing it now.`,
+			},
+			cli.StringFlag{
+				Name: "service-name",
+				Usage: `The <name> of the service installed with **--install-service**. Defaults to
+step-renew-<crt-file> without the extension.`,
+			},
+			cli.StringFlag{
+				Name: "service-user",
+				Usage: `The <user> that runs the service installed with **--install-service**. It
+requires to run the command as root.`,
+			},
+			cli.StringFlag{
+				Name: "service-interval",
+				Usage: `The <duration> between the runs of the service installed with
+**--install-service**. Defaults to 5m.`,
+			},
+			cli.BoolFlag{
+				Name: "renew-all",
+				Usage: `Renew all the certificates in the <dir> positional argument that expire within
+the **--expires-in** duration.`,
+			},
+			cli.IntFlag{
+				Name:  "concurrency",
+				Usage: "The maximum <number> of certificates renewed at the same time with **--renew-all**.",
+				Value: defaultConcurrency,
+			},
+			cli.StringFlag{
+				Name: "report",
+				Usage: `The <file> where **--renew-all** writes a JSON summary with the status of each
+certificate.`,
+			},
+			cli.BoolFlag{
+				Name: "resume",
+				Usage: `Only renew the certificates that failed in the **--report** file of a previous
+**--renew-all** run.`,
+			},
+			jitterFlag,
+			rateLimitFlag,
+			installStoreFlag,
 			offlineFlag,
 			caConfigFlag,
+			flags.DryRun,
 			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
 		},
 	}
 }
 
 func renewCertificateAction(ctx *cli.Context) error {
+	if ctx.Bool("renew-all") {
+		return renewAllAction(ctx)
+	}
+
 	err := errs.NumberOfArguments(ctx, 2)
 	if err != nil {
 		return err
@@ -221,6 +357,14 @@ func renewCertificateAction(ctx *cli.Context) error {
 	if renewPeriod > 0 && !isDaemon {
 		return errs.RequiredWithFlag(ctx, "renew-period", "daemon")
 	}
+	jitter, err := parseJitter(ctx)
+	if err != nil {
+		return err
+	}
+	limiter, err := newRateLimiter(ctx)
+	if err != nil {
+		return err
+	}
 
 	pid := ctx.Int("pid")
 	if ctx.IsSet("pid") && pid <= 0 {
@@ -232,7 +376,15 @@ func renewCertificateAction(ctx *cli.Context) error {
 		return errs.InvalidFlagValue(ctx, "signal", strconv.Itoa(signum), "")
 	}
 
-	cert, err := tls.LoadX509KeyPair(crtFile, keyFile)
+	storeLocation, err := parseInstallStore(ctx)
+	if err != nil {
+		return err
+	}
+	if storeLocation != nil && kms.IsKMS(keyFile) {
+		return errors.New("flag '--install-store' requires a private key file")
+	}
+
+	cert, err := tlsutil.LoadX509KeyPair(crtFile, keyFile)
 	if err != nil {
 		return errors.Wrap(err, "error loading certificates")
 	}
@@ -252,18 +404,78 @@ func renewCertificateAction(ctx *cli.Context) error {
 		return errors.Errorf("flag '--renew-period' must be within (lower than) the certificate "+
 			"validity period; renew-period=%v, cert-validity-period=%v", renewPeriod, cvp)
 	}
+	if jitter >= cvp/3 {
+		return errors.Errorf("flag '--jitter' must be lower than 1/3 of the certificate "+
+			"validity period; jitter=%v, cert-validity-period=%v", jitter, cvp)
+	}
 
-	renewer, err := newRenewer(ctx, caURL, crtFile, keyFile, rootFile)
+	if ctx.Bool("install-service") {
+		if isDaemon {
+			return errs.IncompatibleFlagWithFlag(ctx, "install-service", "daemon")
+		}
+		if expiresIn == 0 {
+			expiresIn = cvp / 3
+		}
+		return installRenewService(ctx, crtFile, keyFile, outFile, rootFile, caURL, expiresIn)
+	}
+
+	if ctx.Bool("dry-run") {
+		target := strings.TrimSuffix(caURL, "/") + "/renew"
+		if ctx.Bool("offline") {
+			target = "the offline CA configured in " + ctx.String("ca-config")
+		}
+		if isDaemon {
+			command.DryRunf("Would register the renew daemon in %s", config.DaemonsPath())
+			next := nextRenewDuration(leaf, expiresIn, renewPeriod)
+			command.DryRunf("Would renew the certificate %s in %s, and again before each new certificate expires", crtFile, next.Round(time.Second))
+		} else if d := time.Until(leaf.NotAfter); expiresIn > 0 && d > expiresIn {
+			command.DryRunf("Would not renew the certificate %s, it expires in %s", crtFile, d.Round(time.Second))
+			return nil
+		}
+		if jitter > 0 {
+			command.DryRunf("Would wait a random time of up to %s before each renewal", jitter)
+		}
+		command.DryRunf("Would send a renew request to %s authenticated with mTLS using %s and %s", target, crtFile, keyFile)
+		command.DryRunf("Would write the new certificate to %s", outFile)
+		if storeLocation != nil {
+			command.DryRunf("Would replace the certificate in the certificate store %s", ctx.String("install-store"))
+		}
+		if ctx.IsSet("pid") {
+			command.DryRunf("Would send the signal %d to the process %d", signum, pid)
+		}
+		if execCmd != "" {
+			command.DryRunf("Would run %q", execCmd)
+		}
+		return nil
+	}
+
+	renewer, err := newRenewer(ctx, caURL, crtFile, keyFile, rootFile, limiter)
 	if err != nil {
 		return err
 	}
 
 	afterRenew := getAfterRenewFunc(pid, signum, execCmd)
+	if storeLocation != nil {
+		key, err := pemutil.Read(keyFile)
+		if err != nil {
+			return err
+		}
+		afterRenew = getInstallInStoreFunc(storeLocation, outFile, leaf, key, afterRenew)
+	}
 	if isDaemon {
 		// Force is always enabled when daemon mode is used
 		ctx.Set("force", "true")
+		if err := renewer.watchConfig(ctx, caURL); err != nil {
+			return err
+		}
+		// Register the daemon so it can be listed with step inventory
+		if unregister, err := registerRenewDaemon(caURL, outFile, keyFile); err != nil {
+			ui.Warnf("cannot register the daemon: %v", err)
+		} else {
+			defer unregister()
+		}
 		next := nextRenewDuration(leaf, expiresIn, renewPeriod)
-		return renewer.Daemon(outFile, next, expiresIn, renewPeriod, afterRenew)
+		return renewer.Daemon(outFile, next+randomJitter(jitter), expiresIn, renewPeriod, jitter, afterRenew)
 	}
 
 	// Do not renew if (cert.notAfter - now) > (expiresIn + jitter)
@@ -275,7 +487,8 @@ func renewCertificateAction(ctx *cli.Context) error {
 		}
 	}
 
-	if _, err := renewer.Renew(outFile); err != nil {
+	time.Sleep(randomJitter(jitter))
+	if _, err := renewer.Renew(context.Background(), outFile); err != nil {
 		return err
 	}
 
@@ -283,6 +496,25 @@ func renewCertificateAction(ctx *cli.Context) error {
 	return afterRenew()
 }
 
+// registerRenewDaemon registers the renew daemon using the absolute paths of
+// the certificate and key.
+func registerRenewDaemon(caURL, crtFile, keyFile string) (func(), error) {
+	crtFile, err := filepath.Abs(crtFile)
+	if err != nil {
+		return nil, err
+	}
+	keyFile, err = filepath.Abs(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return config.RegisterDaemon(&config.Daemon{
+		Name:        "renew",
+		Certificate: crtFile,
+		Key:         keyFile,
+		CAURL:       caURL,
+	})
+}
+
 func nextRenewDuration(leaf *x509.Certificate, expiresIn, renewPeriod time.Duration) time.Duration {
 	if renewPeriod > 0 {
 		// Renew now if it will be expired in renewPeriod
@@ -315,6 +547,23 @@ func getAfterRenewFunc(pid, signum int, execCmd string) func() error {
 	}
 }
 
+// getInstallInStoreFunc returns a function that imports the renewed
+// certificate in the certificate store, replacing the previous one, and then
+// calls the next function.
+func getInstallInStoreFunc(loc *certstore.Location, crtFile string, old *x509.Certificate, key interface{}, next func() error) func() error {
+	return func() error {
+		crt, err := pemutil.ReadCertificate(crtFile)
+		if err != nil {
+			return err
+		}
+		if err := installInStore(loc, crt, key, old); err != nil {
+			return err
+		}
+		old = crt
+		return next()
+	}
+}
+
 func runKillPid(pid, signum int) error {
 	if pid == 0 {
 		return nil
@@ -343,30 +592,24 @@ type renewer struct {
 	transport *http.Transport
 	keyFile   string
 	offline   bool
+	ctx       *cli.Context
+	caURL     string
+	roots     *tlsutil.RootsReloader
+	limiter   *rateLimiter
 }
 
-func newRenewer(ctx *cli.Context, caURL, crtFile, keyFile, rootFile string) (*renewer, error) {
-	cert, err := tls.LoadX509KeyPair(crtFile, keyFile)
+// newRenewer returns a renewer for the given certificate and key. The
+// requests to the CA are sent through the given limiter.
+func newRenewer(ctx *cli.Context, caURL, crtFile, keyFile, rootFile string, limiter *rateLimiter) (*renewer, error) {
+	cert, err := tlsutil.LoadX509KeyPair(crtFile, keyFile)
 	if err != nil {
 		return nil, errors.Wrap(err, "error loading certificates")
 	}
-	if len(cert.Certificate) == 0 {
-		return nil, errors.New("error loading certificate: certificate chain is empty")
-	}
-
-	rootCAs, err := x509util.ReadCertPool(rootFile)
+	tr, err := step.RenewTransport(cert, rootFile)
 	if err != nil {
 		return nil, err
 	}
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			Certificates:             []tls.Certificate{cert},
-			RootCAs:                  rootCAs,
-			PreferServerCipherSuites: true,
-		},
-	}
-
 	var client caClient
 	offline := ctx.Bool("offline")
 	if offline {
@@ -379,7 +622,7 @@ func newRenewer(ctx *cli.Context, caURL, crtFile, keyFile, rootFile string) (*re
 			return nil, err
 		}
 	} else {
-		client, err = ca.NewClient(caURL, ca.WithTransport(tr))
+		client, err = ca.NewClient(caURL, ca.WithTransport(limiter.Transport(trace.Transport(tr))))
 		if err != nil {
 			return nil, err
 		}
@@ -390,41 +633,93 @@ func newRenewer(ctx *cli.Context, caURL, crtFile, keyFile, rootFile string) (*re
 		transport: tr,
 		keyFile:   keyFile,
 		offline:   offline,
+		limiter:   limiter,
 	}, nil
 }
 
-func (r *renewer) Renew(outFile string) (*api.SignResponse, error) {
-	resp, err := r.client.Renew(r.transport)
+// watchConfig enables the reload of the root certificates and the CA URL in
+// daemon mode, so the changes in the root file or in the defaults.json are
+// applied without a restart.
+func (r *renewer) watchConfig(ctx *cli.Context, caURL string) error {
+	roots, err := tlsutil.NewRootsReloader(func() (string, error) {
+		root, err := command.ReloadConfigValue(ctx, "root")
+		if err != nil || root != "" {
+			return root, err
+		}
+		return pki.GetRootCAPath(), nil
+	}, 0)
 	if err != nil {
-		return nil, errors.Wrap(err, "error renewing certificate")
+		return err
 	}
+	r.ctx = ctx
+	r.caURL = caURL
+	r.roots = roots
+	r.transport.TLSClientConfig.RootCAs = roots.Pool()
+	return nil
+}
 
-	serverBlock, err := pemutil.Serialize(resp.ServerPEM.Certificate)
+// reloadConfig applies the current root certificates and CA URL to the next
+// renewals.
+func (r *renewer) reloadConfig(Info *log.Logger) error {
+	if r.roots == nil || r.offline {
+		return nil
+	}
+	if pool := r.roots.Pool(); pool != r.transport.TLSClientConfig.RootCAs {
+		r.transport.TLSClientConfig.RootCAs = pool
+		r.transport.CloseIdleConnections()
+	}
+	caURL, err := command.ReloadConfigValue(r.ctx, "ca-url")
 	if err != nil {
-		return nil, err
+		return err
 	}
-	caBlock, err := pemutil.Serialize(resp.CaPEM.Certificate)
+	if caURL != "" && caURL != r.caURL {
+		client, err := ca.NewClient(caURL, ca.WithTransport(r.limiter.Transport(trace.Transport(r.transport))))
+		if err != nil {
+			return err
+		}
+		Info.Printf("CA URL changed from %s to %s", r.caURL, caURL)
+		r.client = client
+		r.caURL = caURL
+	}
+	return nil
+}
+
+// Renew renews the certificate and writes it to outFile. The waits for the
+// rate limiter are canceled when ctx is done.
+func (r *renewer) Renew(ctx context.Context, outFile string) (*step.Certificate, error) {
+	// The offline CA requires the *http.Transport
+	var tr http.RoundTripper = r.transport
+	if !r.offline {
+		tr = r.limiter.TransportWithContext(ctx, trace.Transport(tr))
+	}
+	crt, err := step.Renew(&step.RenewOptions{
+		Client:    r.client,
+		Transport: tr,
+	})
 	if err != nil {
 		return nil, err
 	}
-	data := append(pem.EncodeToMemory(serverBlock), pem.EncodeToMemory(caBlock)...)
 
-	if err := utils.WriteFile(outFile, data, 0600); err != nil {
+	if err := utils.WriteFile(outFile, crt.PEM(), 0600); err != nil {
 		return nil, errs.FileError(err, outFile)
 	}
 
-	return resp, nil
+	return crt, nil
 }
 
-func (r *renewer) RenewAndPrepareNext(outFile string, expiresIn, renewPeriod time.Duration) (time.Duration, error) {
+func (r *renewer) RenewAndPrepareNext(ctx context.Context, outFile string, expiresIn, renewPeriod time.Duration) (time.Duration, error) {
 	const durationOnErrors = 1 * time.Minute
 
-	resp, err := r.Renew(outFile)
+	crt, err := r.Renew(ctx, outFile)
 	if err != nil {
+		// Wait longer if the CA requested it
+		if d := r.limiter.RetryAfter(); d > durationOnErrors {
+			return d, err
+		}
 		return durationOnErrors, err
 	}
 
-	cert, err := tls.LoadX509KeyPair(outFile, r.keyFile)
+	cert, err := tlsutil.LoadX509KeyPair(outFile, r.keyFile)
 	if err != nil {
 		return durationOnErrors, errors.Wrap(err, "error loading certificates")
 	}
@@ -436,43 +731,91 @@ func (r *renewer) RenewAndPrepareNext(outFile string, expiresIn, renewPeriod tim
 	r.transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
 
 	// Get next renew duration
-	return nextRenewDuration(resp.ServerPEM.Certificate, expiresIn, renewPeriod), nil
+	return nextRenewDuration(crt.Leaf, expiresIn, renewPeriod), nil
 }
 
-func (r *renewer) Daemon(outFile string, next, expiresIn, renewPeriod time.Duration, afterRenew func() error) error {
+// Daemon renews the certificate after next, and then periodically, until it
+// receives a SIGINT or SIGTERM. A random jitter up to the given one is added
+// to each renewal.
+func (r *renewer) Daemon(outFile string, next, expiresIn, renewPeriod, jitter time.Duration, afterRenew func() error) error {
 	// Loggers
 	Info := log.New(os.Stdout, "INFO: ", log.LstdFlags)
 	Error := log.New(os.Stderr, "ERROR: ", log.LstdFlags)
 
-	// Daemon loop
+	if r.roots != nil {
+		r.roots.OnReload(func(file string, err error) {
+			if err != nil {
+				Error.Printf("error reloading root certificates: %v", err)
+			} else {
+				Info.Printf("root certificates %s reloaded", file)
+			}
+		})
+	}
+
+	// Daemon loop. The signals are handled in the background, so a SIGINT or
+	// SIGTERM also stops a renewal waiting for the CA.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reload := make(chan struct{}, 1)
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	defer signal.Stop(signals)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				switch sig {
+				case syscall.SIGHUP:
+					select {
+					case reload <- struct{}{}:
+					default:
+					}
+				case syscall.SIGINT, syscall.SIGTERM:
+					cancel()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
 	Info.Printf("first renewal in %s", next.Round(time.Second))
 	for {
 		select {
-		case sig := <-signals:
-			switch sig {
-			case syscall.SIGHUP:
-				if n, err := r.RenewAndPrepareNext(outFile, expiresIn, renewPeriod); err != nil {
+		case <-ctx.Done():
+			return nil
+		case <-reload:
+			if r.roots != nil {
+				r.roots.Reload()
+			}
+			if err := r.reloadConfig(Info); err != nil {
+				Error.Println(err)
+			}
+			if n, err := r.RenewAndPrepareNext(ctx, outFile, expiresIn, renewPeriod); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				Error.Println(err)
+			} else {
+				next = n + randomJitter(jitter)
+				Info.Printf("certificate renewed, next in %s", next.Round(time.Second))
+				if err := afterRenew(); err != nil {
 					Error.Println(err)
-				} else {
-					next = n
-					Info.Printf("certificate renewed, next in %s", next.Round(time.Second))
-					if err := afterRenew(); err != nil {
-						Error.Println(err)
-					}
 				}
-			case syscall.SIGINT, syscall.SIGTERM:
-				return nil
 			}
 		case <-time.After(next):
-			if n, err := r.RenewAndPrepareNext(outFile, expiresIn, renewPeriod); err != nil {
-				next = n
+			if err := r.reloadConfig(Info); err != nil {
+				Error.Println(err)
+			}
+			if n, err := r.RenewAndPrepareNext(ctx, outFile, expiresIn, renewPeriod); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				next = n + randomJitter(jitter)
 				Error.Println(err)
 			} else {
-				next = n
+				next = n + randomJitter(jitter)
 				Info.Printf("certificate renewed, next in %s", next.Round(time.Second))
 				if err := afterRenew(); err != nil {
 					Error.Println(err)
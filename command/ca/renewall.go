@@ -0,0 +1,297 @@
+package ca
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// Statuses of the certificates in a renew-all report.
+const (
+	renewAllRenewed = "renewed"
+	renewAllSkipped = "skipped"
+	renewAllFailed  = "failed"
+)
+
+// renewAllResult is the outcome of the renewal of a certificate and key pair.
+type renewAllResult struct {
+	Crt      string     `json:"crt"`
+	Key      string     `json:"key"`
+	Status   string     `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+}
+
+// renewAllReport is the summary written by step ca renew --renew-all.
+type renewAllReport struct {
+	Time    time.Time        `json:"time"`
+	Dir     string           `json:"dir"`
+	Results []renewAllResult `json:"results"`
+}
+
+// renewAllAction renews the certificates in the given directory that expire
+// within the --expires-in duration. Certificates are renewed concurrently and
+// the summary is optionally written to the --report file, with --resume only
+// the certificates that failed in the report are renewed again.
+func renewAllAction(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return errs.TooManyArguments(ctx)
+	}
+	for _, flag := range []string{"out", "daemon", "renew-period", "install-service", "install-store", "offline"} {
+		if ctx.IsSet(flag) {
+			return errs.IncompatibleFlagWithFlag(ctx, "renew-all", flag)
+		}
+	}
+	concurrency := ctx.Int("concurrency")
+	if concurrency <= 0 {
+		return errs.InvalidFlagValue(ctx, "concurrency", ctx.String("concurrency"), "")
+	}
+	var expiresIn time.Duration
+	if s := ctx.String("expires-in"); len(s) > 0 {
+		var err error
+		if expiresIn, err = time.ParseDuration(s); err != nil {
+			return errs.InvalidFlagValue(ctx, "expires-in", s, "")
+		}
+	}
+	jitter, err := parseJitter(ctx)
+	if err != nil {
+		return err
+	}
+	limiter, err := newRateLimiter(ctx)
+	if err != nil {
+		return err
+	}
+	reportFile := ctx.String("report")
+	if ctx.Bool("resume") && reportFile == "" {
+		return errs.RequiredWithFlag(ctx, "resume", "report")
+	}
+
+	pid := ctx.Int("pid")
+	if ctx.IsSet("pid") && pid <= 0 {
+		return errs.InvalidFlagValue(ctx, "pid", strconv.Itoa(pid), "")
+	}
+	signum := ctx.Int("signal")
+	if ctx.IsSet("signal") && signum <= 0 {
+		return errs.InvalidFlagValue(ctx, "signal", strconv.Itoa(signum), "")
+	}
+
+	caURL := ctx.String("ca-url")
+	if len(caURL) == 0 {
+		return errs.RequiredFlag(ctx, "ca-url")
+	}
+	rootFile := ctx.String("root")
+	if len(rootFile) == 0 {
+		rootFile = pki.GetRootCAPath()
+	}
+
+	dir := ctx.Args().Get(0)
+	if dir == "" {
+		dir = "."
+	}
+
+	var pairs []renewAllResult
+	if ctx.Bool("resume") {
+		pairs, err = readRenewAllFailures(reportFile)
+	} else {
+		pairs, err = findRenewPairs(dir)
+	}
+	if err != nil {
+		return err
+	}
+	if len(pairs) == 0 {
+		ui.Println("No certificates to renew.")
+		return nil
+	}
+
+	// Files are overwritten without prompts, prompts cannot be answered
+	// concurrently.
+	ctx.Set("force", "true")
+
+	// The random delay is only applied before the first renewal, the
+	// certificates that are not expiring do not need to wait.
+	wait := jitterOnce(jitter)
+	results := make([]renewAllResult, len(pairs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	progress := ui.NewProgress("Renewing certificates", int64(len(pairs)))
+	for w := 0; w < concurrency && w < len(pairs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = renewPair(ctx, caURL, rootFile, pairs[i], expiresIn, limiter, wait)
+				progress.Add(1)
+			}
+		}()
+	}
+	for i := range pairs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	progress.Done()
+
+	var renewed, skipped int
+	var failures []string
+	for _, r := range results {
+		switch r.Status {
+		case renewAllRenewed:
+			renewed++
+		case renewAllSkipped:
+			skipped++
+		default:
+			failures = append(failures, fmt.Sprintf("%s: %s", r.Crt, r.Error))
+		}
+	}
+
+	if reportFile != "" {
+		b, err := json.MarshalIndent(renewAllReport{
+			Time:    time.Now().UTC(),
+			Dir:     dir,
+			Results: results,
+		}, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling report")
+		}
+		if err := utils.WriteFile(reportFile, append(b, '\n'), 0600); err != nil {
+			return errs.FileError(err, reportFile)
+		}
+	}
+
+	ui.Printf("Renewed %d, skipped %d (not expiring), failed %d of %d certificates.\n",
+		renewed, skipped, len(failures), len(results))
+	for _, f := range failures {
+		ui.Printf("error renewing certificate %s\n", f)
+	}
+	if reportFile != "" {
+		ui.PrintSelected("Report", reportFile)
+	}
+
+	if renewed > 0 {
+		if err := getAfterRenewFunc(pid, signum, ctx.String("exec"))(); err != nil {
+			return err
+		}
+	}
+	if len(failures) > 0 {
+		if reportFile != "" {
+			return errors.Errorf("%d of %d certificates could not be renewed, use '--resume --report %s' to retry them",
+				len(failures), len(results), reportFile)
+		}
+		return errors.Errorf("%d of %d certificates could not be renewed", len(failures), len(results))
+	}
+	return nil
+}
+
+// renewPair renews the certificate in the given pair if it expires within
+// expiresIn, by default 1/3 of its validity. The wait function is called
+// before sending the request.
+func renewPair(ctx *cli.Context, caURL, rootFile string, pair renewAllResult, expiresIn time.Duration, limiter *rateLimiter, wait func()) renewAllResult {
+	result := renewAllResult{Crt: pair.Crt, Key: pair.Key}
+	fail := func(err error) renewAllResult {
+		result.Status = renewAllFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	leaf, err := pemutil.ReadCertificate(pair.Crt)
+	if err != nil {
+		return fail(err)
+	}
+	notAfter := leaf.NotAfter.UTC()
+	result.NotAfter = &notAfter
+	if leaf.NotAfter.Before(time.Now()) {
+		return fail(errors.New("cannot renew an expired certificate"))
+	}
+	threshold := expiresIn
+	if threshold == 0 {
+		threshold = leaf.NotAfter.Sub(leaf.NotBefore) / 3
+	}
+	if time.Until(leaf.NotAfter) > threshold {
+		result.Status = renewAllSkipped
+		return result
+	}
+
+	wait()
+	r, err := newRenewer(ctx, caURL, pair.Crt, pair.Key, rootFile, limiter)
+	if err != nil {
+		return fail(err)
+	}
+	crt, err := r.Renew(context.Background(), pair.Crt)
+	if err != nil {
+		return fail(err)
+	}
+	notAfter = crt.Leaf.NotAfter.UTC()
+	result.NotAfter = &notAfter
+	result.Status = renewAllRenewed
+	return result
+}
+
+// findRenewPairs returns the certificate and key pairs in the directory. A
+// certificate file, with the extension .crt or .pem, is paired with the key
+// with the same name and the extension .key, or with the suffix _key. CA
+// certificates and files without a key are ignored.
+func findRenewPairs(dir string) ([]renewAllResult, error) {
+	var pairs []renewAllResult
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".crt" && ext != ".pem" {
+			return nil
+		}
+		base := strings.TrimSuffix(path, filepath.Ext(path))
+		for _, key := range []string{base + ".key", base + "_key"} {
+			if _, err := os.Stat(key); err != nil {
+				continue
+			}
+			crt, err := pemutil.ReadCertificate(path)
+			if err != nil || crt.IsCA {
+				return nil
+			}
+			pairs = append(pairs, renewAllResult{Crt: path, Key: key})
+			return nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errs.FileError(err, dir)
+	}
+	return pairs, nil
+}
+
+// readRenewAllFailures returns the pairs that failed in a renew-all report.
+func readRenewAllFailures(filename string) ([]renewAllResult, error) {
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var report renewAllReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	var pairs []renewAllResult
+	for _, r := range report.Results {
+		if r.Status == renewAllFailed {
+			pairs = append(pairs, renewAllResult{Crt: r.Crt, Key: r.Key})
+		}
+	}
+	return pairs, nil
+}
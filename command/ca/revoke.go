@@ -14,9 +14,13 @@ import (
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/crypto/pemutil"
 	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/tlsutil"
 	"github.com/smallstep/cli/crypto/x509util"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/step"
+	"github.com/smallstep/cli/trace"
 	"github.com/smallstep/cli/ui"
 	"github.com/urfave/cli"
 	"golang.org/x/crypto/ocsp"
@@ -37,7 +41,8 @@ func revokeCertificateCommand() cli.Command {
 		UsageText: `**step ca revoke** <serial-number>
 [**--cert**=<path>] [**--key**=<path>] [**--token**=<ott>]
 [**--ca-url**=<uri>] [**--root**=<path>] [**--reason**=<string>]
-[**--reasonCode**=<code>] [**-offline**]`,
+[**--reasonCode**=<code>] [**-offline**] [**--issuer**=<name>] [**--kid**=<kid>]
+[**--provisioner-type**=<type>] [**--dry-run**]`,
 		Description: `
 **step ca revoke** command revokes a certificate with the given serial
 number.
@@ -105,6 +110,11 @@ Revoke a certificate in offline mode:
 $ step ca revoke --offline 308893286343609293989051180431574390766
 '''
 
+Print the revocation request that would be sent to the CA:
+'''
+$ step ca revoke --dry-run --reasonCode 1 308893286343609293989051180431574390766
+'''
+
 Revoke a certificate in offline mode using --cert and --key (the cert/key pair
 will be validated against the root and intermediate certifcates configured in
 the step CA):
@@ -176,15 +186,20 @@ attribute certificate have been compromised (reasonCode=10).
 				Usage: `The path to the <cert> that should be revoked.`,
 			},
 			cli.StringFlag{
-				Name:  "key",
-				Usage: `The <path> to the key corresponding to the cert that should be revoked.`,
+				Name: "key",
+				Usage: `The <path> to the key corresponding to the cert that should be revoked.
+The key can also be a key URI, e.g. yubikey:9a or awskms:alias/my-key.`,
 			},
 			tokenFlag,
+			provisionerIssuerFlag,
+			provisionerKidFlag,
+			provisionerTypeFlag,
 			notBeforeFlag,
 			caURLFlag,
 			rootFlag,
 			offlineFlag,
 			caConfigFlag,
+			flags.DryRun,
 		},
 	}
 }
@@ -195,6 +210,7 @@ func revokeCertificateAction(ctx *cli.Context) error {
 	certFile, keyFile := ctx.String("cert"), ctx.String("key")
 	token := ctx.String("token")
 	offline := ctx.Bool("offline")
+	dryRun := ctx.Bool("dry-run")
 
 	// Validate the reasonCode arg early in the flow.
 	if _, err := ReasonCodeToNum(ctx.String("reasonCode")); err != nil {
@@ -242,7 +258,7 @@ func revokeCertificateAction(ctx *cli.Context) error {
 		if err := errs.NumberOfArguments(ctx, 1); err != nil {
 			return err
 		}
-		if len(token) == 0 {
+		if len(token) == 0 && !dryRun {
 			// No token and no cert/key pair - so generate a token.
 			token, err = flow.GenerateToken(ctx, &serial)
 			if err != nil {
@@ -251,6 +267,10 @@ func revokeCertificateAction(ctx *cli.Context) error {
 		}
 	}
 
+	if dryRun {
+		return revokeDryRun(ctx, serial, token)
+	}
+
 	if err := flow.Revoke(ctx, serial, token); err != nil {
 		return err
 	}
@@ -259,6 +279,35 @@ func revokeCertificateAction(ctx *cli.Context) error {
 	return nil
 }
 
+// revokeDryRun prints the revocation request that would be sent to the CA.
+func revokeDryRun(ctx *cli.Context, serial, token string) error {
+	reasonCode, err := ReasonCodeToNum(ctx.String("reasonCode"))
+	if err != nil {
+		return err
+	}
+
+	var target, auth string
+	if ctx.Bool("offline") {
+		target = "the offline CA configured in " + ctx.String("ca-config")
+	} else if caURL := ctx.String("ca-url"); caURL != "" {
+		target = strings.TrimSuffix(caURL, "/") + "/revoke"
+	} else {
+		target = "the CA in the token audience"
+	}
+	switch {
+	case ctx.String("cert") != "":
+		auth = "authenticated with mTLS using the certificate " + ctx.String("cert")
+	case token != "":
+		auth = "authorized by the given token"
+	default:
+		auth = "authorized by a new token from a provisioner of the CA"
+	}
+
+	command.DryRunf("Would send a request to %s to passively revoke the certificate with serial number %s, %s", target, serial, auth)
+	command.DryRunf("Would use the reason code %d and the reason %q", reasonCode, ctx.String("reason"))
+	return nil
+}
+
 type revokeTokenClaims struct {
 	SHA string `json:"sha"`
 	jose.Claims
@@ -339,7 +388,7 @@ func (f *revokeFlow) getClient(ctx *cli.Context, serial, token string) (caClient
 			return nil, errs.RequiredFlag(ctx, "root")
 		}
 	}
-	options = append(options, ca.WithRootFile(rootFile))
+	options = append(options, step.WithRootFile(rootFile))
 
 	ui.PrintSelected("CA", caURL)
 	return ca.NewClient(caURL, options...)
@@ -396,7 +445,7 @@ func (f *revokeFlow) Revoke(ctx *cli.Context, serial, token string) error {
 		certFile, keyFile := ctx.String("cert"), ctx.String("key")
 
 		// If there is no token then we must be doing a Revoke over mTLS.
-		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		cert, err := tlsutil.LoadX509KeyPair(certFile, keyFile)
 		if err != nil {
 			return errors.Wrap(err, "error loading certificates")
 		}
@@ -421,6 +470,10 @@ func (f *revokeFlow) Revoke(ctx *cli.Context, serial, token string) error {
 				Certificates:             []tls.Certificate{cert},
 			},
 		}
+		// The offline CA requires the *http.Transport
+		if !ctx.Bool("offline") {
+			tr = trace.Transport(tr)
+		}
 	}
 
 	req := &api.RevokeRequest{
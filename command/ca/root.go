@@ -8,9 +8,11 @@ import (
 
 	"github.com/smallstep/certificates/ca"
 	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/credstore"
 	"github.com/smallstep/cli/crypto/pemutil"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/trace"
 	"github.com/smallstep/cli/ui"
 	"github.com/urfave/cli"
 )
@@ -54,6 +56,9 @@ $ step ca root root_ca.crt \
 			caURLFlag,
 			fingerprintFlag,
 			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
 		},
 	}
 }
@@ -67,6 +72,13 @@ func rootAction(ctx *cli.Context) error {
 	fingerprint := ctx.String("fingerprint")
 	rootFile := ctx.Args().Get(0)
 
+	// Use the fingerprint stored by step ca bootstrap
+	if len(fingerprint) == 0 {
+		if b, err := credstore.Get(fingerprintCredential); err == nil {
+			fingerprint = string(b)
+		}
+	}
+
 	switch {
 	case len(caURL) == 0:
 		return errs.RequiredFlag(ctx, "ca-url")
@@ -75,7 +87,7 @@ func rootAction(ctx *cli.Context) error {
 	}
 
 	tr := getInsecureTransport()
-	client, err := ca.NewClient(caURL, ca.WithTransport(tr))
+	client, err := ca.NewClient(caURL, ca.WithTransport(trace.Transport(tr)))
 	if err != nil {
 		return err
 	}
@@ -96,6 +108,7 @@ func rootAction(ctx *cli.Context) error {
 
 func getInsecureTransport() *http.Transport {
 	return &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
 }
@@ -0,0 +1,479 @@
+package ca
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/keys"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	stepx509 "github.com/smallstep/cli/pkg/x509"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func rotateIntermediateCommand() cli.Command {
+	return cli.Command{
+		Name:   "rotate-intermediate",
+		Action: command.ActionFunc(rotateIntermediateAction),
+		Usage:  "replace the intermediate certificate and key of the certificate authority",
+		UsageText: `**step ca rotate-intermediate** [**--root-key**=<file>] [**--root**=<file>]
+[**--name**=<name>] [**--ca-config**=<file>] [**--password-file**=<file>]
+[**--root-password-file**=<file>] [**--remove-old**]
+
+**step ca rotate-intermediate** **--csr**=<file> [**--name**=<name>] [**--ca-config**=<file>]
+[**--password-file**=<file>]
+
+**step ca rotate-intermediate** **--sign**=<file> **--root**=<file> **--root-key**=<file>
+**--out**=<file> [**--root-password-file**=<file>]
+
+**step ca rotate-intermediate** **--install**=<file> **--key**=<file> [**--root**=<file>]
+[**--ca-config**=<file>] [**--remove-old**]`,
+		Description: `**step ca rotate-intermediate** replaces the intermediate certificate and key
+used by the certificate authority to sign certificates.
+
+The new intermediate certificate and key are written next to the current ones,
+with the date of the rotation in their names, and the ca.json is updated
+atomically to use them. The CA must be restarted to start signing with the new
+intermediate. The previous intermediate certificate and key are kept unless
+**--remove-old** is used: the certificates it issued remain valid until they
+expire, and their chains still contain the previous intermediate.
+
+If the root key is available, the rotation is done with a single command using
+**--root-key**. If the root key is kept offline, the rotation is a ceremony in
+three steps:
+
+1. On the CA host, **--csr** generates the new key and a certificate signing
+request (CSR).
+
+2. On the offline host, **--sign** signs the CSR with the root key.
+
+3. On the CA host, **--install** verifies the signed certificate and updates the
+ca.json.
+
+## EXAMPLES
+
+Rotate the intermediate using the root key:
+'''
+$ step ca rotate-intermediate --root-key $(step path)/secrets/root_ca_key
+'''
+
+Generate the key and the CSR of the new intermediate:
+'''
+$ step ca rotate-intermediate --csr intermediate.csr
+'''
+
+Sign the CSR on the offline host:
+'''
+$ step ca rotate-intermediate --sign intermediate.csr \
+  --root root_ca.crt --root-key root_ca_key --out intermediate.crt
+'''
+
+Install the signed intermediate on the CA host:
+'''
+$ step ca rotate-intermediate --install intermediate.crt \
+  --key $(step path)/secrets/intermediate_ca_key_20200211T181439Z
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name: "ca-config, config",
+				Usage: `The <path> to the certificate authority configuration file. Defaults to
+$STEPPATH/config/ca.json`,
+				Value: caConfigFlag.Value,
+			},
+			cli.StringFlag{
+				Name: "root",
+				Usage: `The path to the root certificate <file>. Defaults to the root in the ca.json,
+required with **--sign**.`,
+			},
+			cli.StringFlag{
+				Name: "root-key",
+				Usage: `The path to the root private key <file> used to sign the new intermediate. A
+key stored in a KMS can be used with a key URI.`,
+			},
+			cli.StringFlag{
+				Name:  "name",
+				Usage: `The common <name> of the new intermediate. Defaults to the name of the current one.`,
+			},
+			cli.StringFlag{
+				Name:  "csr",
+				Usage: `Generate the new key and write a certificate signing request to the <file>.`,
+			},
+			cli.StringFlag{
+				Name:  "sign",
+				Usage: `Sign the certificate signing request in the <file> with the root key.`,
+			},
+			cli.StringFlag{
+				Name:  "out",
+				Usage: `The <file> to write the certificate signed with **--sign**.`,
+			},
+			cli.StringFlag{
+				Name:  "install",
+				Usage: `Install the signed intermediate certificate in the <file>.`,
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: `The private key <file> of the certificate installed with **--install**.`,
+			},
+			cli.StringFlag{
+				Name:  "root-password-file",
+				Usage: `The path to the <file> containing the password to decrypt the root key.`,
+			},
+			cli.BoolFlag{
+				Name:  "remove-old",
+				Usage: `Remove the previous intermediate key after updating the ca.json.`,
+			},
+			flags.Force,
+		}, flags.Password(cli.StringFlag{
+			Name:  "password-file",
+			Usage: `The path to the <file> containing the password to encrypt the new intermediate key.`,
+		})...),
+	}
+}
+
+func rotateIntermediateAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	var modes []string
+	for _, name := range []string{"csr", "sign", "install"} {
+		if ctx.String(name) != "" {
+			modes = append(modes, name)
+		}
+	}
+	if len(modes) > 1 {
+		return errs.IncompatibleFlagWithFlag(ctx, modes[0], modes[1])
+	}
+
+	switch {
+	case ctx.String("sign") != "":
+		return rotateSign(ctx)
+	case ctx.String("csr") != "":
+		return rotateCSR(ctx)
+	case ctx.String("install") != "":
+		return rotateInstall(ctx)
+	default:
+		return rotate(ctx)
+	}
+}
+
+// rotate generates a new intermediate signed by the root key and installs it.
+func rotate(ctx *cli.Context) error {
+	if ctx.String("root-key") == "" {
+		return errs.RequiredFlag(ctx, "root-key")
+	}
+	c, err := readRotationConfig(ctx)
+	if err != nil {
+		return err
+	}
+	root, err := loadRootIdentity(ctx, c.root)
+	if err != nil {
+		return err
+	}
+	name, err := c.intermediateName(ctx)
+	if err != nil {
+		return err
+	}
+	pass, err := readIntermediatePassword(ctx)
+	if err != nil {
+		return err
+	}
+
+	crtPath, keyPath := c.newPaths(time.Now())
+	profile, err := x509util.NewIntermediateProfile(name, root.Crt, root.Key)
+	if err != nil {
+		return err
+	}
+	if _, err := profile.CreateWriteCertificate(crtPath, keyPath, string(pass)); err != nil {
+		return err
+	}
+	return c.install(ctx, crtPath, keyPath)
+}
+
+// rotateCSR generates the new intermediate key and a CSR to be signed with
+// the root key.
+func rotateCSR(ctx *cli.Context) error {
+	c, err := readRotationConfig(ctx)
+	if err != nil {
+		return err
+	}
+	name, err := c.intermediateName(ctx)
+	if err != nil {
+		return err
+	}
+	pass, err := readIntermediatePassword(ctx)
+	if err != nil {
+		return err
+	}
+
+	priv, err := keys.GenerateDefaultKey()
+	if err != nil {
+		return err
+	}
+	// Only the common name is replaced, the rest of the subject is kept.
+	subject := c.intermediate.Subject
+	subject.CommonName = name
+	subject.Names = nil
+	csr, err := stepx509.CreateCertificateRequest(rand.Reader, &stepx509.CertificateRequest{
+		Subject: subject,
+	}, priv)
+	if err != nil {
+		return errors.Wrap(err, "error creating certificate request")
+	}
+
+	_, keyPath := c.newPaths(time.Now())
+	if _, err := pemutil.Serialize(priv, pemutil.WithPassword(pass), pemutil.ToFile(keyPath, 0600)); err != nil {
+		return err
+	}
+	csrFile := ctx.String("csr")
+	if err := utils.WriteFile(csrFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), 0644); err != nil {
+		return errs.FileError(err, csrFile)
+	}
+
+	ui.PrintSelected("Intermediate private key", keyPath)
+	ui.PrintSelected("Certificate signing request", csrFile)
+	ui.Println()
+	ui.Println("Sign the certificate signing request on the host with the root key:")
+	ui.Printf("  step ca rotate-intermediate --sign %s --root root_ca.crt --root-key root_ca_key --out intermediate.crt\n", filepath.Base(csrFile))
+	ui.Println("And install the signed certificate on this host:")
+	ui.Printf("  step ca rotate-intermediate --install intermediate.crt --key %s\n", keyPath)
+	return nil
+}
+
+// rotateSign signs the CSR of a new intermediate with the root key.
+func rotateSign(ctx *cli.Context) error {
+	switch {
+	case ctx.String("root") == "":
+		return errs.RequiredWithFlag(ctx, "sign", "root")
+	case ctx.String("root-key") == "":
+		return errs.RequiredWithFlag(ctx, "sign", "root-key")
+	case ctx.String("out") == "":
+		return errs.RequiredWithFlag(ctx, "sign", "out")
+	}
+
+	csrFile := ctx.String("sign")
+	v, err := pemutil.Read(csrFile, pemutil.WithStepCrypto())
+	if err != nil {
+		return err
+	}
+	csr, ok := v.(*stepx509.CertificateRequest)
+	if !ok {
+		return errors.Errorf("error parsing %s: file is not a certificate signing request", csrFile)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return errors.Wrapf(err, "error validating %s signature", csrFile)
+	}
+	root, err := loadRootIdentity(ctx, ctx.String("root"))
+	if err != nil {
+		return err
+	}
+
+	profile, err := x509util.NewIntermediateProfile(csr.Subject.CommonName, root.Crt, root.Key,
+		x509util.WithPublicKey(csr.PublicKey), x509util.WithSubject(csr.Subject))
+	if err != nil {
+		return err
+	}
+	crt, err := profile.CreateCertificate()
+	if err != nil {
+		return err
+	}
+	out := ctx.String("out")
+	if err := utils.WriteFile(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt}), 0644); err != nil {
+		return errs.FileError(err, out)
+	}
+	ui.PrintSelected("Intermediate certificate", out)
+	return nil
+}
+
+// rotateInstall verifies and installs an intermediate signed with --sign.
+func rotateInstall(ctx *cli.Context) error {
+	if ctx.String("key") == "" {
+		return errs.RequiredWithFlag(ctx, "install", "key")
+	}
+	c, err := readRotationConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	crtFile, keyPath := ctx.String("install"), ctx.String("key")
+	crt, err := pemutil.ReadCertificate(crtFile)
+	if err != nil {
+		return err
+	}
+	rootCrt, err := pemutil.ReadCertificate(c.root)
+	if err != nil {
+		return err
+	}
+	if err := crt.CheckSignatureFrom(rootCrt); err != nil {
+		return errors.Wrapf(err, "error validating %s: the certificate is not signed by %s", crtFile, c.root)
+	}
+	if !crt.IsCA {
+		return errors.Errorf("error validating %s: the certificate is not a CA certificate", crtFile)
+	}
+	if err := checkKeyMatch(crt, keyPath); err != nil {
+		return err
+	}
+
+	// Copy the certificate next to the current one.
+	crtPath, _ := c.newPaths(time.Now())
+	if err := utils.WriteFile(crtPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw}), 0600); err != nil {
+		return errs.FileError(err, crtPath)
+	}
+	return c.install(ctx, crtPath, keyPath)
+}
+
+// rotationConfig is the ca.json being rotated.
+type rotationConfig struct {
+	filename     string
+	config       map[string]interface{}
+	crt, key     string
+	root         string
+	intermediate *x509.Certificate
+}
+
+func readRotationConfig(ctx *cli.Context) (*rotationConfig, error) {
+	filename := ctx.String("ca-config")
+	if filename == "" {
+		return nil, errs.RequiredFlag(ctx, "ca-config")
+	}
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c := &rotationConfig{filename: filename}
+	if err := json.Unmarshal(b, &c.config); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	c.crt, _ = c.config["crt"].(string)
+	c.key, _ = c.config["key"].(string)
+	if c.crt == "" || c.key == "" {
+		return nil, errors.Errorf("error parsing %s: crt and key are required", filename)
+	}
+	switch v := c.config["root"].(type) {
+	case string:
+		c.root = v
+	case []interface{}:
+		if len(v) > 0 {
+			c.root, _ = v[0].(string)
+		}
+	}
+	if root := ctx.String("root"); root != "" {
+		c.root = root
+	}
+	if c.root == "" {
+		return nil, errors.Errorf("error parsing %s: root is required", filename)
+	}
+	if c.intermediate, err = pemutil.ReadCertificate(c.crt); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *rotationConfig) intermediateName(ctx *cli.Context) (string, error) {
+	if name := ctx.String("name"); name != "" {
+		return name, nil
+	}
+	if c.intermediate.Subject.CommonName == "" {
+		return "", errs.RequiredFlag(ctx, "name")
+	}
+	return c.intermediate.Subject.CommonName, nil
+}
+
+// newPaths returns the paths of the new intermediate certificate and key,
+// the current paths with the time of the rotation.
+func (c *rotationConfig) newPaths(now time.Time) (string, string) {
+	suffix := now.UTC().Format("20060102T150405Z")
+	return rotatedPath(c.crt, suffix), rotatedPath(c.key, suffix)
+}
+
+// rotatedPath adds the suffix to the name of the file before its extension.
+func rotatedPath(path, suffix string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "_" + suffix + ext
+}
+
+// install updates the ca.json atomically to use the new intermediate.
+func (c *rotationConfig) install(ctx *cli.Context, crtPath, keyPath string) error {
+	c.config["crt"] = crtPath
+	c.config["key"] = keyPath
+	b, err := json.MarshalIndent(c.config, "", "   ")
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling %s", c.filename)
+	}
+	perm := os.FileMode(0644)
+	if st, err := os.Stat(c.filename); err == nil {
+		perm = st.Mode().Perm()
+	}
+	if err := utils.WriteFileAtomic(c.filename, b, perm, "", ""); err != nil {
+		return errs.FileError(err, c.filename)
+	}
+
+	ui.PrintSelected("Intermediate certificate", crtPath)
+	ui.PrintSelected("Intermediate private key", keyPath)
+	ui.PrintSelected("CA configuration", c.filename)
+	ui.Printf("The previous intermediate signed certificates valid until %s.\n", c.intermediate.NotAfter.UTC().Format(time.RFC3339))
+
+	if ctx.Bool("remove-old") {
+		if err := os.Remove(c.key); err != nil && !os.IsNotExist(err) {
+			return errs.FileError(err, c.key)
+		}
+		ui.Printf("Removed the previous intermediate key %s.\n", c.key)
+	} else {
+		ui.Printf("The previous intermediate is kept in %s and %s.\n", c.crt, c.key)
+	}
+	ui.Println("Restart the CA to sign with the new intermediate.")
+	return nil
+}
+
+// loadRootIdentity reads the root certificate and the key in --root-key.
+func loadRootIdentity(ctx *cli.Context, root string) (*x509util.Identity, error) {
+	var opts []pemutil.Options
+	if filename := ctx.String("root-password-file"); filename != "" {
+		opts = append(opts, pemutil.WithPasswordFile(filename))
+	}
+	return x509util.LoadIdentityFromDisk(root, ctx.String("root-key"), opts...)
+}
+
+// readIntermediatePassword returns the password to encrypt the new
+// intermediate key.
+func readIntermediatePassword(ctx *cli.Context) ([]byte, error) {
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil || len(password) > 0 {
+		return password, err
+	}
+	return ui.PromptPassword("Please enter the password to encrypt the new intermediate key", ui.WithValidateNotEmpty())
+}
+
+// checkKeyMatch checks that the key in the file is the key of the
+// certificate.
+func checkKeyMatch(crt *x509.Certificate, keyPath string) error {
+	key, err := pemutil.Read(keyPath)
+	if err != nil {
+		return err
+	}
+	pub, err := keys.PublicKey(key)
+	if err != nil {
+		return err
+	}
+	b, err := stepx509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling public key")
+	}
+	if !bytes.Equal(b, crt.RawSubjectPublicKeyInfo) {
+		return errors.Errorf("error validating %s: the key does not match the certificate", keyPath)
+	}
+	return nil
+}
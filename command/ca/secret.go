@@ -0,0 +1,255 @@
+package ca
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Formats supported by the --output flag.
+const (
+	outputK8sSecret    = "k8s-secret"
+	outputDockerSecret = "docker-secret"
+	outputGRPC         = "grpc"
+	outputEnvoySDS     = "envoy-sds"
+	outputHAProxy      = "haproxy"
+)
+
+// secretOutput is the parsed value of the --output flag, with the format
+// <format>[:<name>[/<namespace>]].
+type secretOutput struct {
+	Format    string
+	Name      string
+	Namespace string
+	RootFile  string
+}
+
+var invalidSecretNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// parseSecretOutput parses the --output flag. If the name is not set the
+// subject is used.
+func parseSecretOutput(ctx *cli.Context, subject string) (*secretOutput, error) {
+	value := ctx.String("output")
+	format, name := value, ""
+	if i := strings.Index(value, ":"); i >= 0 {
+		format, name = value[:i], value[i+1:]
+	}
+
+	out := &secretOutput{Format: format}
+	switch format {
+	case outputK8sSecret:
+		if i := strings.Index(name, "/"); i >= 0 {
+			name, out.Namespace = name[:i], name[i+1:]
+			if out.Namespace == "" {
+				return nil, errs.InvalidFlagValue(ctx, "output", value, "")
+			}
+		}
+	case outputDockerSecret, outputGRPC, outputEnvoySDS:
+		if strings.Contains(name, "/") {
+			return nil, errs.InvalidFlagValue(ctx, "output", value, "")
+		}
+	case outputHAProxy:
+		if name != "" {
+			return nil, errs.InvalidFlagValue(ctx, "output", value, "")
+		}
+	default:
+		return nil, errs.InvalidFlagValue(ctx, "output", value,
+			"k8s-secret[:name[/namespace]], docker-secret[:name], grpc[:name], envoy-sds[:name], haproxy")
+	}
+	if format == outputGRPC {
+		out.RootFile = ctx.String("root")
+		if out.RootFile == "" {
+			out.RootFile = pki.GetRootCAPath()
+		}
+	}
+
+	if name == "" {
+		name = strings.Trim(invalidSecretNameChars.ReplaceAllString(strings.ToLower(subject), "-"), "-.")
+	}
+	if name == "" {
+		return nil, errs.InvalidFlagValue(ctx, "output", value, "")
+	}
+	out.Name = name
+	return out, nil
+}
+
+// k8sSecret is a Kubernetes Secret of type kubernetes.io/tls.
+type k8sSecret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sSecretMetadata `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sSecretMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// grpcCertificateProvider is the file_watcher certificate provider of the
+// gRPC xDS bootstrap file.
+type grpcCertificateProvider struct {
+	PluginName string                `json:"plugin_name"`
+	Config     grpcFileWatcherConfig `json:"config"`
+}
+
+type grpcFileWatcherConfig struct {
+	CertificateFile   string `json:"certificate_file"`
+	PrivateKeyFile    string `json:"private_key_file"`
+	CACertificateFile string `json:"ca_certificate_file"`
+	RefreshInterval   string `json:"refresh_interval"`
+}
+
+// envoySecret is an Envoy SDS secret with an inline TLS certificate.
+type envoySecret struct {
+	Type           string              `json:"@type"`
+	Name           string              `json:"name"`
+	TLSCertificate envoyTLSCertificate `json:"tls_certificate"`
+}
+
+type envoyTLSCertificate struct {
+	CertificateChain envoyDataSource `json:"certificate_chain"`
+	PrivateKey       envoyDataSource `json:"private_key"`
+}
+
+type envoyDataSource struct {
+	InlineString string `json:"inline_string"`
+}
+
+// requiresKey returns true if the format includes the private key.
+func (o *secretOutput) requiresKey() bool {
+	return o.Format != outputDockerSecret
+}
+
+// writeSecret writes to w the certificate and the private key in the output
+// format: a Kubernetes Secret manifest, the Docker commands that create the
+// secrets, a gRPC certificate provider, an Envoy SDS secret or a HAProxy PEM.
+// The key is not included in the Docker commands if it's nil.
+func (o *secretOutput) writeSecret(w io.Writer, crtFile, keyFile string, pk crypto.PrivateKey) error {
+	switch o.Format {
+	case outputK8sSecret:
+		crt, err := ioutil.ReadFile(crtFile)
+		if err != nil {
+			return errs.FileError(err, crtFile)
+		}
+		block, err := pemutil.Serialize(pk)
+		if err != nil {
+			return err
+		}
+		b, err := yaml.Marshal(k8sSecret{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata: k8sSecretMetadata{
+				Name:      o.Name,
+				Namespace: o.Namespace,
+			},
+			Type: "kubernetes.io/tls",
+			Data: map[string]string{
+				"tls.crt": base64.StdEncoding.EncodeToString(crt),
+				"tls.key": base64.StdEncoding.EncodeToString(pem.EncodeToMemory(block)),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case outputDockerSecret:
+		fmt.Fprintf(w, "docker secret create %s %s\n", shellQuote(o.Name+".crt"), shellQuote(crtFile))
+		if pk != nil {
+			fmt.Fprintf(w, "docker secret create %s %s\n", shellQuote(o.Name+".key"), shellQuote(keyFile))
+		}
+		return nil
+	case outputGRPC:
+		files := []string{crtFile, keyFile, o.RootFile}
+		for i, fn := range files {
+			abs, err := filepath.Abs(fn)
+			if err != nil {
+				return errs.FileError(err, fn)
+			}
+			files[i] = abs
+		}
+		return writeJSON(w, map[string]interface{}{
+			"certificate_providers": map[string]grpcCertificateProvider{
+				o.Name: {
+					PluginName: "file_watcher",
+					Config: grpcFileWatcherConfig{
+						CertificateFile:   files[0],
+						PrivateKeyFile:    files[1],
+						CACertificateFile: files[2],
+						RefreshInterval:   "600s",
+					},
+				},
+			},
+		})
+	case outputEnvoySDS:
+		crt, err := ioutil.ReadFile(crtFile)
+		if err != nil {
+			return errs.FileError(err, crtFile)
+		}
+		block, err := pemutil.Serialize(pk)
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, map[string]interface{}{
+			"resources": []envoySecret{{
+				Type: "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret",
+				Name: o.Name,
+				TLSCertificate: envoyTLSCertificate{
+					CertificateChain: envoyDataSource{InlineString: string(crt)},
+					PrivateKey:       envoyDataSource{InlineString: string(pem.EncodeToMemory(block))},
+				},
+			}},
+		})
+	case outputHAProxy:
+		// HAProxy expects the certificate chain and the key in the same file.
+		crt, err := ioutil.ReadFile(crtFile)
+		if err != nil {
+			return errs.FileError(err, crtFile)
+		}
+		block, err := pemutil.Serialize(pk)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(crt); err != nil {
+			return err
+		}
+		return pem.Encode(w, block)
+	default:
+		return fmt.Errorf("unsupported output format %s", o.Format)
+	}
+}
+
+// writeJSON writes the indented JSON encoding of v to w.
+func writeJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+var safeShellChars = regexp.MustCompile(`^[A-Za-z0-9_./:=@+-]+$`)
+
+// shellQuote quotes the given string to be used as a shell argument.
+func shellQuote(s string) string {
+	if safeShellChars.MatchString(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
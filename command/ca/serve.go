@@ -0,0 +1,211 @@
+package ca
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/randutil"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+const defaultServeAddress = "127.0.0.1:9000"
+
+func serveCommand() cli.Command {
+	return cli.Command{
+		Name:   "serve",
+		Action: command.ActionFunc(serveAction),
+		Usage:  "run a local certificate authority for development and testing",
+		UsageText: `**step ca serve** [**--ca-config**=<file>] [**--password-file**=<file>]
+[**--address**=<address>]
+
+**step ca serve** **--ephemeral** [**--address**=<address>] [**--provisioner**=<name>]`,
+		Description: `**step ca serve** runs the certificate authority API using the same
+authority embedded in the offline mode, so the client flows can be tested
+without installing step-ca. It is meant for development and testing, for
+production use step-ca.
+
+With the **--ephemeral** flag a new PKI is created in a temporary directory
+with a root and intermediate certificate and a JWK provisioner, and it is
+removed when the server stops. The command prints the root certificate, the
+password file of the provisioner and the CA URL to use with the other commands.
+
+The server stops with SIGINT or SIGTERM.
+
+## EXAMPLES
+
+Run the CA configured with **step ca init**:
+'''
+$ step ca serve --password-file password.txt
+'''
+
+Run a CA with a different configuration:
+'''
+$ step ca serve --config ./ca.json --address 127.0.0.1:8443
+'''
+
+Run an ephemeral CA and get a certificate from it in another terminal:
+'''
+$ step ca serve --ephemeral
+...
+$ step ca certificate --ca-url https://127.0.0.1:9000 --root /tmp/step-ca-123/certs/root_ca.crt \
+  --password-file /tmp/step-ca-123/secrets/password localhost localhost.crt localhost.key
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name: "ca-config, config",
+				Usage: `The <path> to the certificate authority configuration file. Defaults to
+$STEPPATH/config/ca.json`,
+				Value: caConfigFlag.Value,
+			},
+			cli.StringFlag{
+				Name: "address",
+				Usage: `The <address> the CA will listen at, e.g. 127.0.0.1:9000. Defaults to the
+address in the configuration, or 127.0.0.1:9000 with **--ephemeral**.`,
+			},
+			cli.BoolFlag{
+				Name:  "ephemeral",
+				Usage: `Serve a new temporary PKI that is removed when the server stops.`,
+			},
+			cli.StringFlag{
+				Name:  "provisioner",
+				Usage: `The <name> of the JWK provisioner of the **--ephemeral** PKI.`,
+				Value: "admin",
+			},
+		}, flags.Password(cli.StringFlag{
+			Name:  "password-file",
+			Usage: `The path to the <file> containing the password to decrypt the intermediate key.`,
+		})...),
+	}
+}
+
+func serveAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	var err error
+	var password []byte
+	configFile := ctx.String("ca-config")
+	if ctx.Bool("ephemeral") {
+		if ctx.IsSet("ca-config") {
+			return errs.IncompatibleFlagWithFlag(ctx, "ephemeral", "ca-config")
+		}
+		name, err := utils.GetPasswordFlag(ctx)
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			return errs.IncompatibleFlagWithFlag(ctx, "ephemeral", name)
+		}
+		dir, err := ioutil.TempDir("", "step-ca-")
+		if err != nil {
+			return errors.Wrap(err, "error creating temporary directory")
+		}
+		defer os.RemoveAll(dir)
+		if configFile, password, err = newEphemeralPKI(ctx, dir); err != nil {
+			return err
+		}
+	} else {
+		if configFile == "" {
+			return errs.RequiredFlag(ctx, "ca-config")
+		}
+		if password, err = utils.ReadPasswordFromCLI(ctx); err != nil {
+			return err
+		}
+	}
+
+	b, err := utils.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	var config authority.Config
+	if err := json.Unmarshal(b, &config); err != nil {
+		return errors.Wrapf(err, "error reading %s", configFile)
+	}
+	if address := ctx.String("address"); address != "" {
+		config.Address = address
+	}
+
+	srv, err := ca.New(&config, ca.WithConfigFile(configFile), ca.WithPassword(password))
+	if err != nil {
+		return err
+	}
+
+	// Stop the server on SIGINT or SIGTERM
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; ok {
+			srv.Stop()
+		}
+	}()
+
+	ui.PrintSelected("Listening", config.Address)
+	if err := srv.Run(); err != nil && errors.Cause(err) != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// newEphemeralPKI creates a new PKI in the given directory, and returns the
+// path of the CA configuration and the password of the intermediate key.
+func newEphemeralPKI(ctx *cli.Context, dir string) (string, []byte, error) {
+	p, err := pki.New(filepath.Join(dir, "certs"), filepath.Join(dir, "secrets"), filepath.Join(dir, "config"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	address := ctx.String("address")
+	if address == "" {
+		address = defaultServeAddress
+	}
+	p.SetAddress(address)
+	p.SetDNSNames([]string{"127.0.0.1", "localhost"})
+	p.SetProvisioner(ctx.String("provisioner"))
+
+	pass, err := randutil.ASCII(32)
+	if err != nil {
+		return "", nil, err
+	}
+	password := []byte(pass)
+	passwordFile := filepath.Join(dir, "secrets", "password")
+	if err := utils.WriteFile(passwordFile, password, 0600); err != nil {
+		return "", nil, err
+	}
+
+	if err := p.GenerateKeyPairs(password); err != nil {
+		return "", nil, err
+	}
+	rootCrt, rootKey, err := p.GenerateRootCertificate("Ephemeral Root CA", password)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := p.WriteRootCertificate(rootCrt, rootKey, password); err != nil {
+		return "", nil, err
+	}
+	if err := p.GenerateIntermediateCertificate("Ephemeral Intermediate CA", rootCrt, rootKey, password); err != nil {
+		return "", nil, err
+	}
+	if err := p.Save(pki.WithoutDB()); err != nil {
+		return "", nil, err
+	}
+
+	ui.PrintSelected("Provisioner", ctx.String("provisioner"))
+	ui.PrintSelected("Password file", passwordFile)
+	return filepath.Join(dir, "config", "ca.json"), password, nil
+}
@@ -0,0 +1,306 @@
+package ca
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+const defaultServiceInterval = 5 * time.Minute
+
+var systemdServiceTemplate = template.Must(template.New("service").Parse(`[Unit]
+Description=Renew the certificate {{ .Certificate }}
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=oneshot
+{{- if .User }}
+User={{ .User }}
+{{- end }}
+Environment="STEPPATH={{ .StepPath }}"
+ExecStart={{ .ExecStart }}
+`))
+
+var systemdTimerTemplate = template.Must(template.New("timer").Parse(`[Unit]
+Description=Renew the certificate {{ .Certificate }} periodically
+
+[Timer]
+OnBootSec={{ .Interval }}
+OnUnitActiveSec={{ .Interval }}
+
+[Install]
+WantedBy=timers.target
+`))
+
+var launchdTemplate = template.Must(template.New("plist").Funcs(template.FuncMap{
+	"xml": xmlEscape,
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Label</key>
+  <string>{{ xml .Name }}</string>
+{{- if .User }}
+  <key>UserName</key>
+  <string>{{ xml .User }}</string>
+{{- end }}
+  <key>EnvironmentVariables</key>
+  <dict>
+    <key>STEPPATH</key>
+    <string>{{ xml .StepPath }}</string>
+  </dict>
+  <key>ProgramArguments</key>
+  <array>
+{{- range .Args }}
+    <string>{{ xml . }}</string>
+{{- end }}
+  </array>
+  <key>StartInterval</key>
+  <integer>{{ .IntervalSeconds }}</integer>
+  <key>RunAtLoad</key>
+  <true/>
+</dict>
+</plist>
+`))
+
+// renewService contains the values used to write the service files.
+type renewService struct {
+	Name            string
+	Certificate     string
+	User            string
+	StepPath        string
+	Args            []string
+	ExecStart       string
+	Interval        string
+	IntervalSeconds int
+}
+
+// installRenewService writes and enables a systemd service and timer, or a
+// launchd job in macOS, that runs step ca renew periodically.
+func installRenewService(ctx *cli.Context, crtFile, keyFile, outFile, rootFile, caURL string, expiresIn time.Duration) error {
+	interval := defaultServiceInterval
+	if s := ctx.String("service-interval"); s != "" {
+		var err error
+		if interval, err = time.ParseDuration(s); err != nil || interval < time.Second {
+			return errs.InvalidFlagValue(ctx, "service-interval", s, "")
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "error getting the step executable")
+	}
+	abs := func(filename string) (string, error) {
+		s, err := filepath.Abs(filename)
+		return s, errors.Wrapf(err, "error getting the absolute path of %s", filename)
+	}
+	if crtFile, err = abs(crtFile); err != nil {
+		return err
+	}
+	// Key URIs are kept as they are
+	if !strings.Contains(keyFile, ":") {
+		if keyFile, err = abs(keyFile); err != nil {
+			return err
+		}
+	}
+
+	args := []string{exe, "ca", "renew", "--force", "--expires-in", expiresIn.String()}
+	if ctx.Bool("offline") {
+		caConfig, err := abs(ctx.String("ca-config"))
+		if err != nil {
+			return err
+		}
+		args = append(args, "--offline", "--ca-config", caConfig)
+	} else {
+		if rootFile, err = abs(rootFile); err != nil {
+			return err
+		}
+		args = append(args, "--ca-url", caURL, "--root", rootFile)
+	}
+	if outFile != crtFile {
+		if outFile, err = abs(outFile); err != nil {
+			return err
+		}
+		args = append(args, "--out", outFile)
+	}
+	if s := ctx.String("exec"); s != "" {
+		args = append(args, "--exec", s)
+	}
+	if s := ctx.String("install-store"); s != "" {
+		args = append(args, "--install-store", s)
+	}
+	if s := ctx.String("jitter"); s != "" {
+		args = append(args, "--jitter", s)
+	}
+	if ctx.IsSet("pid") {
+		args = append(args, "--pid", strconv.Itoa(ctx.Int("pid")), "--signal", strconv.Itoa(ctx.Int("signal")))
+	}
+	args = append(args, crtFile, keyFile)
+
+	name := ctx.String("service-name")
+	if name == "" {
+		base := filepath.Base(crtFile)
+		name = "step-renew-" + strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	svc := &renewService{
+		Name:            name,
+		Certificate:     crtFile,
+		User:            ctx.String("service-user"),
+		StepPath:        config.BasePath(),
+		Args:            args,
+		ExecStart:       systemdCommandLine(args),
+		Interval:        fmt.Sprintf("%ds", int(interval.Seconds())),
+		IntervalSeconds: int(interval.Seconds()),
+	}
+
+	isRoot := os.Geteuid() == 0
+	if svc.User != "" && !isRoot {
+		return errors.New("flag '--service-user' requires to run the command as root")
+	}
+
+	dryRun := ctx.Bool("dry-run")
+	switch runtime.GOOS {
+	case "linux":
+		return installSystemdService(svc, isRoot, dryRun)
+	case "darwin":
+		return installLaunchdService(svc, isRoot, dryRun)
+	default:
+		return errors.Errorf("flag '--install-service' is not supported on %s", runtime.GOOS)
+	}
+}
+
+func installSystemdService(svc *renewService, isRoot, dryRun bool) error {
+	dir := "/etc/systemd/system"
+	systemctl := []string{"systemctl"}
+	if !isRoot {
+		u, err := user.Current()
+		if err != nil {
+			return errors.Wrap(err, "error getting the current user")
+		}
+		dir = filepath.Join(u.HomeDir, ".config", "systemd", "user")
+		systemctl = append(systemctl, "--user")
+	}
+
+	serviceFile := filepath.Join(dir, svc.Name+".service")
+	timerFile := filepath.Join(dir, svc.Name+".timer")
+	if dryRun {
+		command.DryRunf("Would write the service %s running: %s", serviceFile, svc.ExecStart)
+		command.DryRunf("Would write the timer %s running the service every %s", timerFile, svc.Interval)
+		command.DryRunf("Would run %q", strings.Join(append(systemctl, "daemon-reload"), " "))
+		command.DryRunf("Would run %q", strings.Join(append(systemctl, "enable", "--now", svc.Name+".timer"), " "))
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errs.FileError(err, dir)
+	}
+	if err := writeTemplate(serviceFile, systemdServiceTemplate, svc); err != nil {
+		return err
+	}
+	if err := writeTemplate(timerFile, systemdTimerTemplate, svc); err != nil {
+		return err
+	}
+
+	if err := runServiceCommand(append(systemctl, "daemon-reload")...); err != nil {
+		return err
+	}
+	if err := runServiceCommand(append(systemctl, "enable", "--now", svc.Name+".timer")...); err != nil {
+		return err
+	}
+
+	ui.Printf("Your service has been saved in %s.\n", serviceFile)
+	ui.Printf("Your timer has been saved in %s and enabled.\n", timerFile)
+	return nil
+}
+
+func installLaunchdService(svc *renewService, isRoot, dryRun bool) error {
+	svc.Name = "com.smallstep." + svc.Name
+	dir := "/Library/LaunchDaemons"
+	if !isRoot {
+		u, err := user.Current()
+		if err != nil {
+			return errors.Wrap(err, "error getting the current user")
+		}
+		dir = filepath.Join(u.HomeDir, "Library", "LaunchAgents")
+	}
+
+	plistFile := filepath.Join(dir, svc.Name+".plist")
+	if dryRun {
+		command.DryRunf("Would write the launchd job %s running every %ds: %s", plistFile, svc.IntervalSeconds, strings.Join(svc.Args, " "))
+		command.DryRunf("Would run %q", "launchctl load -w "+plistFile)
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errs.FileError(err, dir)
+	}
+	if err := writeTemplate(plistFile, launchdTemplate, svc); err != nil {
+		return err
+	}
+	if err := runServiceCommand("launchctl", "load", "-w", plistFile); err != nil {
+		return err
+	}
+
+	ui.Printf("Your launchd job has been saved in %s and loaded.\n", plistFile)
+	return nil
+}
+
+func writeTemplate(filename string, tmpl *template.Template, svc *renewService) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, svc); err != nil {
+		return errors.Wrapf(err, "error generating %s", filename)
+	}
+	if err := utils.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return errs.FileError(err, filename)
+	}
+	return nil
+}
+
+func runServiceCommand(args ...string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Errorf("failed to execute \"%s\" failed with: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// systemdCommandLine returns the arguments quoted as required by the
+// ExecStart directive.
+func systemdCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\"'\\$%;") {
+			a = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`).Replace(a)
+			a = `"` + a + `"`
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
+
+func xmlEscape(s string) (string, error) {
+	var buf bytes.Buffer
+	if err := xml.EscapeText(&buf, []byte(s)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -19,7 +19,8 @@ func signCertificateCommand() cli.Command {
 		Action: command.ActionFunc(signCertificateAction),
 		Usage:  "generate a new certificate signing a certificate request",
 		UsageText: `**step ca sign** <csr-file> <crt-file>
-		[**--token**=<token>] [**--issuer**=<name>] [**--ca-url**=<uri>] [**--root**=<file>]
+		[**--token**=<token>] [**--issuer**=<name>] [**--kid**=<kid>] [**--provisioner-type**=<type>]
+		[**--ca-url**=<uri>] [**--root**=<file>]
 		[**--not-before**=<time|duration>] [**--not-after**=<time|duration>]`,
 		Description: `**step ca sign** command signs the given csr and generates a new certificate.
 
@@ -53,6 +54,8 @@ $ step ca sign --offline internal internal.csr internal.crt
 		Flags: []cli.Flag{
 			tokenFlag,
 			provisionerIssuerFlag,
+			provisionerKidFlag,
+			provisionerTypeFlag,
 			caURLFlag,
 			rootFlag,
 			notBeforeFlag,
@@ -60,6 +63,9 @@ $ step ca sign --offline internal internal.csr internal.crt
 			offlineFlag,
 			caConfigFlag,
 			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
 		},
 	}
 }
@@ -0,0 +1,97 @@
+package ca
+
+import (
+	"crypto/x509"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/step"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// validateSPIFFETrustDomain checks that the trust domain of the SPIFFE ID is
+// the one configured in the --spiffe-trust-domain flag, usually set in the
+// defaults.json, and that it is allowed by the root certificate. A root with
+// a SPIFFE ID identifies the trust domain, and the URI name constraints in the
+// root restrict the trust domains the CA can issue.
+func validateSPIFFETrustDomain(ctx *cli.Context, id *url.URL) error {
+	if td := ctx.String("spiffe-trust-domain"); td != "" && td != id.Host {
+		return errors.Errorf("SPIFFE ID trust domain '%s' does not match the configured trust domain '%s'", id.Host, td)
+	}
+
+	root := ctx.String("root")
+	if root == "" {
+		if root = pki.GetRootCAPath(); !utils.FileExists(root) {
+			return nil
+		}
+	}
+	crt, err := pemutil.ReadCertificate(root, pemutil.WithFirstBlock())
+	if err != nil {
+		return err
+	}
+	for _, u := range crt.URIs {
+		if u.Scheme == "spiffe" && u.Host != id.Host {
+			return errors.Errorf("SPIFFE ID trust domain '%s' does not match the root trust domain '%s'", id.Host, u.Host)
+		}
+	}
+	for _, domain := range crt.ExcludedURIDomains {
+		if matchURIDomain(id.Host, domain) {
+			return errors.Errorf("SPIFFE ID trust domain '%s' is excluded by the root certificate", id.Host)
+		}
+	}
+	if len(crt.PermittedURIDomains) > 0 {
+		for _, domain := range crt.PermittedURIDomains {
+			if matchURIDomain(id.Host, domain) {
+				return nil
+			}
+		}
+		return errors.Errorf("SPIFFE ID trust domain '%s' is not permitted by the root certificate", id.Host)
+	}
+	return nil
+}
+
+// matchURIDomain returns if the host matches the URI name constraint as
+// defined in RFC 5280, section 4.2.1.10.
+func matchURIDomain(host, constraint string) bool {
+	host, constraint = strings.ToLower(host), strings.ToLower(constraint)
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(host, constraint)
+	}
+	return host == constraint
+}
+
+// validateSPIFFECertificate validates that the certificate is an X509-SVID for
+// the given SPIFFE ID: a leaf certificate with the SPIFFE ID as its only URI
+// SAN, and the digital signature key usage but not the certificate or CRL
+// signing ones. DNS SANs are only allowed if allowDNS is true.
+func validateSPIFFECertificate(crt *x509.Certificate, id *url.URL, allowDNS bool) error {
+	switch {
+	case len(crt.URIs) != 1 || crt.URIs[0].String() != id.String():
+		return errors.Errorf("certificate must have %s as its only URI SAN", id)
+	case crt.IsCA:
+		return errors.New("SPIFFE certificate cannot be a CA")
+	case crt.KeyUsage&x509.KeyUsageDigitalSignature == 0:
+		return errors.New("SPIFFE certificate must have the digitalSignature key usage")
+	case crt.KeyUsage&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != 0:
+		return errors.New("SPIFFE certificate cannot have the keyCertSign or cRLSign key usages")
+	case len(crt.DNSNames) > 0 && !allowDNS:
+		return errors.Errorf("SPIFFE certificate cannot have the DNS SANs %s; use --spiffe-allow-dns to allow them", strings.Join(crt.DNSNames, ", "))
+	}
+	return nil
+}
+
+// spiffeSANs returns the SANs used in a SPIFFE certificate, the SPIFFE ID and
+// the given SANs. DNS SANs are only allowed with the --spiffe-allow-dns flag.
+func spiffeSANs(ctx *cli.Context, id *url.URL, sans []string) ([]string, error) {
+	if !ctx.Bool("spiffe-allow-dns") {
+		if dnsNames, _ := step.SplitSANs(sans); len(dnsNames) > 0 {
+			return nil, errs.RequiredWithFlagValue(ctx, "san", dnsNames[0], "spiffe-allow-dns")
+		}
+	}
+	return append([]string{id.String()}, sans...), nil
+}
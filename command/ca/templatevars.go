@@ -0,0 +1,284 @@
+package ca
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// templateVars are the variables available in the subject, SANs and files of
+// step ca certificate, e.g. "{{ .Hostname }}.internal". They are resolved at
+// issuance time so the same command or manifest can be used in all the
+// instances of an autoscaling group.
+type templateVars struct {
+	// Hostname is the host name reported by the kernel.
+	Hostname string
+	// ShortHostname is the host name up to the first dot.
+	ShortHostname string
+	// IP is the first non-loopback IP address of the host, IPv4 if
+	// available.
+	IP string
+	// IPs are all the non-loopback IP addresses of the host.
+	IPs []string
+	// Env are the environment variables.
+	Env map[string]string
+
+	ctx       *cli.Context
+	cloudOnce sync.Once
+	cloud     *cloudMetadata
+	cloudErr  error
+}
+
+// cloudMetadata is the information of the instance returned by the metadata
+// service of AWS, GCP or Azure.
+type cloudMetadata struct {
+	Provider   string
+	InstanceID string
+	Region     string
+	Zone       string
+	PrivateIP  string
+	Hostname   string
+	AccountID  string
+}
+
+// hasTemplateVars returns true if any of the given strings uses a variable.
+func hasTemplateVars(values ...string) bool {
+	for _, v := range values {
+		if strings.Contains(v, "{{") {
+			return true
+		}
+	}
+	return false
+}
+
+// newTemplateVars returns the variables of the host. The cloud metadata is
+// only requested if a template uses it.
+func newTemplateVars(ctx *cli.Context) (*templateVars, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting hostname")
+	}
+	v := &templateVars{
+		Hostname:      hostname,
+		ShortHostname: strings.SplitN(hostname, ".", 2)[0],
+		Env:           make(map[string]string),
+		ctx:           ctx,
+	}
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			v.Env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the IP addresses")
+	}
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		ip := ipNet.IP.String()
+		v.IPs = append(v.IPs, ip)
+		if v.IP == "" || (ipNet.IP.To4() != nil && net.ParseIP(v.IP).To4() == nil) {
+			v.IP = ip
+		}
+	}
+	return v, nil
+}
+
+// Cloud returns the metadata of the cloud instance. It is a method so the
+// metadata service is only used when a template has a {{ .Cloud }} variable.
+func (v *templateVars) Cloud() (*cloudMetadata, error) {
+	v.cloudOnce.Do(func() {
+		v.cloud, v.cloudErr = getCloudMetadata(v.ctx)
+	})
+	return v.cloud, v.cloudErr
+}
+
+// Execute resolves the variables in s.
+func (v *templateVars) Execute(s string) (string, error) {
+	if !hasTemplateVars(s) {
+		return s, nil
+	}
+	tmpl, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", errors.Wrapf(err, "error parsing template %q", s)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, v); err != nil {
+		return "", errors.Wrapf(err, "error executing template %q", s)
+	}
+	return buf.String(), nil
+}
+
+// ExecuteAll resolves the variables in all the given strings.
+func (v *templateVars) ExecuteAll(values []string) ([]string, error) {
+	res := make([]string, len(values))
+	for i, s := range values {
+		var err error
+		if res[i], err = v.Execute(s); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// getCloudMetadata returns the instance metadata of the first cloud provider
+// that responds, trying GCP, AWS and Azure.
+func getCloudMetadata(ctx *cli.Context) (*cloudMetadata, error) {
+	var errList []string
+	for _, fn := range []func(*cli.Context) (*cloudMetadata, error){
+		gcpCloudMetadata, awsCloudMetadata, azureCloudMetadata,
+	} {
+		m, err := fn(ctx)
+		if err == nil {
+			return m, nil
+		}
+		errList = append(errList, err.Error())
+	}
+	return nil, errors.Errorf("error getting cloud metadata:\n  %s", strings.Join(errList, "\n  "))
+}
+
+func gcpCloudMetadata(ctx *cli.Context) (*cloudMetadata, error) {
+	c, err := newMetadataClient(ctx, gcpMetadataURL)
+	if err != nil {
+		return nil, err
+	}
+	c.header.Set("Metadata-Flavor", "Google")
+	b, err := c.do("GET", "/computeMetadata/v1/instance/?recursive=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	var instance struct {
+		ID                json.Number `json:"id"`
+		Zone              string      `json:"zone"`
+		Hostname          string      `json:"hostname"`
+		NetworkInterfaces []struct {
+			IP string `json:"ip"`
+		} `json:"networkInterfaces"`
+	}
+	if err := json.Unmarshal(b, &instance); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling GCP instance metadata")
+	}
+	project, err := c.do("GET", "/computeMetadata/v1/project/project-id", nil)
+	if err != nil {
+		return nil, err
+	}
+	// The zone has the format projects/<number>/zones/<zone>.
+	zone := path.Base(instance.Zone)
+	m := &cloudMetadata{
+		Provider:   "gcp",
+		InstanceID: instance.ID.String(),
+		Zone:       zone,
+		Hostname:   instance.Hostname,
+		AccountID:  strings.TrimSpace(string(project)),
+	}
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		m.Region = zone[:i]
+	}
+	if len(instance.NetworkInterfaces) > 0 {
+		m.PrivateIP = instance.NetworkInterfaces[0].IP
+	}
+	return m, nil
+}
+
+func awsCloudMetadata(ctx *cli.Context) (*cloudMetadata, error) {
+	c, err := newMetadataClient(ctx, awsMetadataURL)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.String("aws-imds") != "v1" {
+		tok, err := c.do("PUT", "/latest/api/token", http.Header{
+			"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": []string{awsSessionTokenTTL},
+		})
+		switch {
+		case err == nil:
+			c.header.Set("X-Aws-Ec2-Metadata-Token", string(tok))
+		case ctx.String("aws-imds") == "v2":
+			return nil, errors.Wrap(err, "error creating IMDSv2 session token")
+		}
+	}
+	b, err := c.do("GET", "/latest/dynamic/instance-identity/document", nil)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		InstanceID       string `json:"instanceId"`
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		PrivateIP        string `json:"privateIp"`
+		AccountID        string `json:"accountId"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling instance identity document")
+	}
+	hostname, err := c.do("GET", "/latest/meta-data/local-hostname", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &cloudMetadata{
+		Provider:   "aws",
+		InstanceID: doc.InstanceID,
+		Region:     doc.Region,
+		Zone:       doc.AvailabilityZone,
+		PrivateIP:  doc.PrivateIP,
+		Hostname:   strings.TrimSpace(string(hostname)),
+		AccountID:  doc.AccountID,
+	}, nil
+}
+
+func azureCloudMetadata(ctx *cli.Context) (*cloudMetadata, error) {
+	c, err := newMetadataClient(ctx, azureMetadataURL)
+	if err != nil {
+		return nil, err
+	}
+	c.header.Set("Metadata", "true")
+	b, err := c.do("GET", "/metadata/instance?api-version=2021-02-01", nil)
+	if err != nil {
+		return nil, err
+	}
+	var instance struct {
+		Compute struct {
+			VMID           string `json:"vmId"`
+			Location       string `json:"location"`
+			Zone           string `json:"zone"`
+			Name           string `json:"name"`
+			SubscriptionID string `json:"subscriptionId"`
+		} `json:"compute"`
+		Network struct {
+			Interface []struct {
+				IPv4 struct {
+					IPAddress []struct {
+						PrivateIPAddress string `json:"privateIpAddress"`
+					} `json:"ipAddress"`
+				} `json:"ipv4"`
+			} `json:"interface"`
+		} `json:"network"`
+	}
+	if err := json.Unmarshal(b, &instance); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling Azure instance metadata")
+	}
+	m := &cloudMetadata{
+		Provider:   "azure",
+		InstanceID: instance.Compute.VMID,
+		Region:     instance.Compute.Location,
+		Zone:       instance.Compute.Zone,
+		Hostname:   instance.Compute.Name,
+		AccountID:  instance.Compute.SubscriptionID,
+	}
+	if ifaces := instance.Network.Interface; len(ifaces) > 0 && len(ifaces[0].IPv4.IPAddress) > 0 {
+		m.PrivateIP = ifaces[0].IPv4.IPAddress[0].PrivateIPAddress
+	}
+	return m, nil
+}
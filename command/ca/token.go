@@ -15,6 +15,7 @@ import (
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/crypto/pki"
 	"github.com/smallstep/cli/crypto/randutil"
+	"github.com/smallstep/cli/crypto/securebuf"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/exec"
 	"github.com/smallstep/cli/flags"
@@ -42,7 +43,11 @@ func tokenCommand() cli.Command {
 		Action: command.ActionFunc(tokenAction),
 		Usage:  "generate an OTT granting access to the CA",
 		UsageText: `**step ca token** <subject>
-		[--**kid**=<kid>] [--**issuer**=<name>] [**--ca-url**=<uri>] [**--root**=<file>]
+		[--**kid**=<kid>] [--**issuer**=<name>] [**--provisioner-type**=<type>]
+		[**--metadata-url**=<url>] [**--metadata-timeout**=<duration>] [**--aws-imds**=<version>]
+		[**--gcp-service-account**=<account>] [**--gcp-workload-identity**]
+		[**--azure-resource-id**=<id>]
+		[**--ca-url**=<uri>] [**--root**=<file>]
 		[**--not-before**=<time|duration>] [**--not-after**=<time|duration>]
 		[**--password-file**=<file>] [**--output-file**=<file>] [**--key**=<path>]
 		[**--san**=<SAN>] [**--offline**] [**--revoke**]`,
@@ -113,6 +118,38 @@ $ step ca token internal.example.com \
     --root /path/to/root_ca.crt
 '''
 
+Get a new token without prompting, using the only JWK provisioner with the
+given name. If more than one provisioner matches the **--provisioner**,
+**--provisioner-kid** and **--provisioner-type** flags, the command fails in
+non-interactive mode instead of asking for one:
+'''
+$ STEPNOINTERACTIVE=1 step ca token internal.example.com \
+    --provisioner you@example.com --provisioner-type JWK \
+    --password-file provisioner.pass
+'''
+
+Get a new token for an AWS provisioner requiring IMDSv2, with the metadata
+service behind a proxy:
+'''
+$ step ca token --provisioner-type AWS --aws-imds v2 \
+    --metadata-url http://metadata-proxy.internal:8080 --metadata-timeout 2s \
+    internal.example.com
+'''
+
+Get a new token for a GCP provisioner from a GKE pod using workload identity:
+'''
+$ step ca token --provisioner-type GCP --gcp-workload-identity \
+    --gcp-service-account app@my-project.iam.gserviceaccount.com \
+    internal.example.com
+'''
+
+Get a new token for an Azure provisioner using a user-assigned managed identity:
+'''
+$ step ca token --provisioner-type Azure \
+    --azure-resource-id /subscriptions/<id>/resourcegroups/<group>/providers/Microsoft.ManagedIdentity/userAssignedIdentities/<name> \
+    internal.example.com
+'''
+
 Get a new token using the simple offline mode, requires the configuration
 files, certificates, and keys created with **step ca init**:
 '''
@@ -140,9 +177,16 @@ Get a new token in offline mode for a 'Revoke' request:
 $ step ca token --offline --revoke 146103349666685108195655980390445292315
 '''
 `,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			provisionerKidFlag,
 			provisionerIssuerFlag,
+			provisionerTypeFlag,
+			metadataURLFlag,
+			metadataTimeoutFlag,
+			awsIMDSFlag,
+			gcpServiceAccountFlag,
+			gcpWorkloadIdentityFlag,
+			azureResourceIDFlag,
 			caURLFlag,
 			rootFlag,
 			notBeforeFlag,
@@ -154,12 +198,13 @@ authorized to request. A certificate signing request using this token must match
 the complete set of subjective alternative names in the token 1:1. Use the '--san'
 flag multiple times to configure multiple SANs.`,
 			},
+			flags.VerbatimSAN,
 			cli.StringFlag{
 				Name: "key",
 				Usage: `The private key <path> used to sign the JWT. This is usually downloaded from
-the certificate authority.`,
+the certificate authority. The key can also be a key URI, e.g. env:STEP_KEY,
+keychain:my-key or awskms:alias/my-key.`,
 			},
-			passwordFileFlag,
 			cli.StringFlag{
 				Name:  "output-file",
 				Usage: "The destination <file> of the generated one-time token.",
@@ -176,7 +221,7 @@ be invalid for any other API request.`,
 			},
 			caConfigFlag,
 			flags.Force,
-		},
+		}, flags.Password(passwordFileFlag)...),
 	}
 }
 
@@ -188,7 +233,10 @@ func tokenAction(ctx *cli.Context) error {
 	subject := ctx.Args().Get(0)
 	outputFile := ctx.String("output-file")
 	offline := ctx.Bool("offline")
-	sans := ctx.StringSlice("san")
+	sans, err := sanFlag(ctx)
+	if err != nil {
+		return err
+	}
 
 	// Default token type is always a 'Sign' token.
 	typ := signType
@@ -224,7 +272,6 @@ func tokenAction(ctx *cli.Context) error {
 		return errs.InvalidFlagValue(ctx, "not-after", ctx.String("not-after"), "")
 	}
 
-	var err error
 	var token string
 	if offline {
 		token, err = offlineTokenFlow(ctx, typ, subject, sans)
@@ -349,15 +396,8 @@ func newTokenFlow(ctx *cli.Context, typ int, subject string, sans []string, caUR
 			return "", err
 		}
 		return strings.TrimSpace(string(out)), nil
-	case *provisioner.GCP: // Do the identity request to get the token
-		sharedContext.DisableCustomSANs = p.DisableCustomSANs
-		return p.GetIdentityToken(subject, caURL)
-	case *provisioner.AWS: // Do the identity request to get the token
-		sharedContext.DisableCustomSANs = p.DisableCustomSANs
-		return p.GetIdentityToken(subject, caURL)
-	case *provisioner.Azure: // Do the identity request to get the token
-		sharedContext.DisableCustomSANs = p.DisableCustomSANs
-		return p.GetIdentityToken(subject, caURL)
+	case *provisioner.GCP, *provisioner.AWS, *provisioner.Azure: // Do the identity request to get the token
+		return cloudIdentityToken(ctx, p, subject, caURL)
 	}
 
 	// JWK provisioner
@@ -369,42 +409,109 @@ func newTokenFlow(ctx *cli.Context, typ int, subject string, sans []string, caUR
 	kid := prov.Key.KeyID
 	issuer := prov.Name
 
-	var opts []jose.Option
-	if passwordFile := ctx.String("password-file"); len(passwordFile) != 0 {
-		opts = append(opts, jose.WithPasswordFile(passwordFile))
+	jwk, err := provisionerKey(ctx, caURL, root, kid)
+	if err != nil {
+		return "", err
 	}
 
-	var jwk *jose.JSONWebKey
-	if keyFile := ctx.String("key"); len(keyFile) == 0 {
-		// Get private key from CA
-		encrypted, err := pki.GetProvisionerKey(caURL, root, kid)
-		if err != nil {
-			return "", err
-		}
+	return generateToken(typ, subject, sans, kid, issuer, audience, root, notBefore, notAfter, jwk)
+}
 
-		// Add template with check mark
-		opts = append(opts, jose.WithUIOptions(
-			ui.WithPromptTemplates(ui.PromptTemplates()),
-		))
+// provisionerKey returns the private key of a JWK provisioner. The key is read
+// from the --key flag if present, taken from the step agent if it has it, or
+// downloaded from the CA and decrypted.
+func provisionerKey(ctx *cli.Context, caURL, root, kid string) (*jose.JSONWebKey, error) {
+	opts := []jose.Option{jose.WithStepAgent(true)}
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(password) != 0 {
+		opts = append(opts, jose.WithPassword(password))
+	}
 
-		decrypted, err := jose.Decrypt("Please enter the password to decrypt the provisioner key", []byte(encrypted), opts...)
-		if err != nil {
-			return "", err
-		}
+	// Get private key from given key file
+	if keyFile := ctx.String("key"); len(keyFile) != 0 {
+		return jose.ParseKey(keyFile, opts...)
+	}
 
-		jwk = new(jose.JSONWebKey)
-		if err := json.Unmarshal(decrypted, jwk); err != nil {
-			return "", errors.Wrap(err, "error unmarshalling provisioning key")
-		}
-	} else {
-		// Get private key from given key file
-		jwk, err = jose.ParseKey(keyFile, opts...)
-		if err != nil {
-			return "", err
-		}
+	// Get private key from the step agent
+	if jwk := jose.StepAgentKey(kid); jwk != nil {
+		return jwk, nil
 	}
 
-	return generateToken(typ, subject, sans, kid, issuer, audience, root, notBefore, notAfter, jwk)
+	// Get private key from CA
+	encrypted, err := pki.GetProvisionerKey(caURL, root, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add template with check mark
+	opts = append(opts, jose.WithUIOptions(
+		ui.WithPromptTemplates(ui.PromptTemplates()),
+	))
+
+	decrypted, err := jose.Decrypt("Please enter the password to decrypt the provisioner key", []byte(encrypted), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk := new(jose.JSONWebKey)
+	err = json.Unmarshal(decrypted, jwk)
+	securebuf.Zero(decrypted)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling provisioning key")
+	}
+	return jwk, nil
+}
+
+// tokenGenerator generates tokens signed with the key of a JWK provisioner.
+// It's used to generate multiple tokens decrypting the provisioner key only
+// once. A tokenGenerator is safe for concurrent use.
+type tokenGenerator struct {
+	kid, issuer, audience, root string
+	jwk                         *jose.JSONWebKey
+}
+
+// Token generates a new token with the default validity.
+func (g *tokenGenerator) Token(typ int, subject string, sans []string) (string, error) {
+	return generateToken(typ, subject, sans, g.kid, g.issuer, g.audience, g.root, time.Time{}, time.Time{}, g.jwk)
+}
+
+// newTokenGenerator returns a tokenGenerator for the provisioner selected by
+// the user. Only JWK provisioners can be used.
+func newTokenGenerator(ctx *cli.Context, typ int, caURL, root string) (*tokenGenerator, error) {
+	audience, err := parseAudience(ctx, typ)
+	if err != nil {
+		return nil, err
+	}
+
+	provisioners, err := pki.GetProvisioners(caURL, root)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := provisionerPrompt(ctx, provisioners)
+	if err != nil {
+		return nil, err
+	}
+	prov, ok := p.(*provisioner.JWK)
+	if !ok {
+		return nil, errors.Errorf("provisioner '%s' cannot be used: only JWK provisioners are supported", p.GetName())
+	}
+
+	jwk, err := provisionerKey(ctx, caURL, root, prov.Key.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenGenerator{
+		kid:      prov.Key.KeyID,
+		issuer:   prov.Name,
+		audience: audience,
+		root:     root,
+		jwk:      jwk,
+	}, nil
 }
 
 // offlineTokenFlow generates a provisioning token using either
@@ -434,7 +541,6 @@ func offlineTokenFlow(ctx *cli.Context, typ int, subject string, sans []string)
 	kid := ctx.String("kid")
 	issuer := ctx.String("issuer")
 	keyFile := ctx.String("key")
-	passwordFile := ctx.String("password-file")
 
 	// Require issuer and keyFile if ca.json does not exists.
 	// kid can be passed or created using jwk.Thumbprint.
@@ -461,9 +567,13 @@ func offlineTokenFlow(ctx *cli.Context, typ int, subject string, sans []string)
 	}
 
 	// Parse key
-	var opts []jose.Option
-	if len(passwordFile) != 0 {
-		opts = append(opts, jose.WithPasswordFile(passwordFile))
+	opts := []jose.Option{jose.WithStepAgent(true)}
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(password) != 0 {
+		opts = append(opts, jose.WithPassword(password))
 	}
 	jwk, err := jose.ParseKey(keyFile, opts...)
 	if err != nil {
@@ -526,6 +636,16 @@ func provisionerPrompt(ctx *cli.Context, provisioners provisioner.List) (provisi
 		}
 	}
 
+	// Filter by type
+	if typ := ctx.String("provisioner-type"); len(typ) != 0 {
+		provisioners = provisionerFilter(provisioners, func(p provisioner.Interface) bool {
+			return strings.EqualFold(p.GetType().String(), typ)
+		})
+		if len(provisioners) == 0 {
+			return nil, errs.InvalidFlagValue(ctx, "provisioner-type", typ, "")
+		}
+	}
+
 	// Select provisioner
 	var items []*provisionersSelect
 	for _, prov := range provisioners {
@@ -567,6 +687,18 @@ func provisionerPrompt(ctx *cli.Context, provisioners provisioner.List) (provisi
 		return items[0].Provisioner, nil
 	}
 
+	// Do not prompt in non-interactive mode, the flags must select only one
+	// provisioner.
+	if ui.IsNonInteractive() {
+		names := make([]string, len(items))
+		for i, item := range items {
+			names[i] = item.Name
+		}
+		return nil, errs.Usage(errors.Errorf("cannot select a provisioner in non-interactive mode, %d provisioners match: %s; "+
+			"use the flags '--provisioner', '--provisioner-kid' or '--provisioner-type' to select one",
+			len(items), strings.Join(names, ", ")))
+	}
+
 	i, _, err := ui.Select("What provisioner key do you want to use?", items, ui.WithSelectTemplates(ui.NamedSelectTemplates("Provisioner")))
 	if err != nil {
 		return nil, err
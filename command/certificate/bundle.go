@@ -45,7 +45,7 @@ Bundle a certificate with the intermediate certificate authority (issuer):
 $ step certificate bundle foo.crt intermediate-ca.crt foo-bundle.crt
 '''
 `,
-		Flags: []cli.Flag{flags.Force},
+		Flags: []cli.Flag{flags.Force, flags.Mode, flags.Owner, flags.Group},
 	}
 }
 
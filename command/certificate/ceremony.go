@@ -0,0 +1,417 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/policy"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// Types and version of the ceremony bundles.
+const (
+	ceremonyRequestType  = "step-ceremony-request"
+	ceremonyResponseType = "step-ceremony-response"
+	ceremonyVersion      = 1
+)
+
+// ceremonyRequest is the signing bundle created in the connected machine and
+// signed in the air-gapped one.
+type ceremonyRequest struct {
+	Type      string    `json:"type"`
+	Version   int       `json:"version"`
+	Profile   string    `json:"profile"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	CSR       string    `json:"csr"`
+	SHA256    string    `json:"sha256"`
+}
+
+// ceremonyResponse is the bundle with the signed certificate created in the
+// air-gapped machine. RequestSHA256 is the hash of the request file.
+type ceremonyResponse struct {
+	Type          string    `json:"type"`
+	Version       int       `json:"version"`
+	RequestSHA256 string    `json:"requestSHA256"`
+	SignedAt      time.Time `json:"signedAt"`
+	Certificate   string    `json:"certificate"`
+	SHA256        string    `json:"sha256"`
+}
+
+func ceremonyRequestCommand() cli.Command {
+	return cli.Command{
+		Name:   "ceremony-request",
+		Action: command.ActionFunc(ceremonyRequestAction),
+		Usage:  "create a signing bundle for an air-gapped signing ceremony",
+		UsageText: `**step certificate ceremony-request** <csr_file> <bundle_file>
+[**--profile**=<profile>] [**--comment**=<text>]`,
+		Description: `**step certificate ceremony-request** creates a signing bundle with a
+certificate signing request (CSR) and its metadata, to be signed in an
+air-gapped machine.
+
+An air-gapped signing ceremony has three steps:
+
+1. On the connected machine, **step certificate ceremony-request** creates the
+signing bundle.
+
+2. On the air-gapped machine, **step certificate sign --ceremony** verifies the
+bundle, shows its summary and creates a response bundle with the signed
+certificate.
+
+3. On the connected machine, **step certificate ceremony-import** verifies that
+the response matches the request and writes the certificate.
+
+The bundles are JSON files with the SHA-256 of their contents, the response
+also contains the SHA-256 of the request file, so a bundle modified or mixed up
+when it is moved between the machines is detected.
+
+## POSITIONAL ARGUMENTS
+
+<csr_file>
+:  The path to the certificate signing request.
+
+<bundle_file>
+:  The path to write the signing bundle.
+
+## EXAMPLES
+
+Create a signing bundle for an intermediate certificate:
+'''
+$ step certificate ceremony-request intermediate.csr request.json \
+  --profile intermediate-ca --comment "Intermediate rotation 2020"
+'''
+
+Sign the bundle in the air-gapped machine:
+'''
+$ step certificate sign --ceremony request.json root_ca.crt root_ca_key > response.json
+'''
+
+Import the signed certificate:
+'''
+$ step certificate ceremony-import response.json request.json intermediate.crt
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "profile",
+				Value: "leaf",
+				Usage: `The certificate <profile> to request.
+
+: <profile> is a case-sensitive string and must be one of:
+
+    **leaf**
+    :  A leaf certificate, the default.
+
+    **intermediate-ca**
+    :  An intermediate CA certificate.`,
+			},
+			cli.StringFlag{
+				Name:  "comment",
+				Usage: `A <text> shown to the signer, e.g. the purpose of the certificate.`,
+			},
+			flags.Force,
+		},
+	}
+}
+
+func ceremonyImportCommand() cli.Command {
+	return cli.Command{
+		Name:      "ceremony-import",
+		Action:    command.ActionFunc(ceremonyImportAction),
+		Usage:     "import the certificate signed in an air-gapped signing ceremony",
+		UsageText: `**step certificate ceremony-import** <response_file> <bundle_file> <crt_file>`,
+		Description: `**step certificate ceremony-import** verifies the response bundle created by
+**step certificate sign --ceremony** and writes the signed certificate and its
+issuer to a PEM file.
+
+The command checks that the response was created for the given signing bundle,
+that the certificate was not modified, that it has the public key of the
+certificate signing request, and that it is signed by the issuer in the
+response. See **step certificate ceremony-request** for the full ceremony.
+
+## POSITIONAL ARGUMENTS
+
+<response_file>
+:  The path to the response bundle.
+
+<bundle_file>
+:  The path to the signing bundle created by **step certificate ceremony-request**.
+
+<crt_file>
+:  The path to write the certificate chain.
+
+## EXAMPLES
+
+Import the signed certificate:
+'''
+$ step certificate ceremony-import response.json request.json intermediate.crt
+'''`,
+		Flags: []cli.Flag{
+			flags.Force,
+		},
+	}
+}
+
+func ceremonyRequestAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 2); err != nil {
+		return err
+	}
+	csrFile, bundleFile := ctx.Args().Get(0), ctx.Args().Get(1)
+
+	profile := ctx.String("profile")
+	if profile != "leaf" && profile != "intermediate-ca" {
+		return errs.InvalidFlagValue(ctx, "profile", profile, "leaf, intermediate-ca")
+	}
+
+	b, err := ioutil.ReadFile(csrFile)
+	if err != nil {
+		return errs.FileError(err, csrFile)
+	}
+	csr, err := x509util.LoadCSRFromBytes(b)
+	if err != nil {
+		return errors.Wrapf(err, "error parsing %s", csrFile)
+	}
+	if err := x509util.CheckCertificateRequestSignature(csr); err != nil {
+		return errors.Wrapf(err, "error validating %s signature", csrFile)
+	}
+
+	req := newCeremonyRequest(csr, profile, ctx.String("comment"), time.Now())
+	out, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling signing bundle")
+	}
+	if err := utils.WriteFile(bundleFile, append(out, '\n'), 0644); err != nil {
+		return errs.FileError(err, bundleFile)
+	}
+
+	printCeremonyRequest(req, csr)
+	ui.PrintSelected("Signing bundle", bundleFile)
+	ui.PrintSelected("Signing bundle SHA256", sha256Hex(append(out, '\n')))
+	return nil
+}
+
+func ceremonyImportAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 3); err != nil {
+		return err
+	}
+	responseFile, bundleFile, crtFile := ctx.Args().Get(0), ctx.Args().Get(1), ctx.Args().Get(2)
+
+	respBytes, err := ioutil.ReadFile(responseFile)
+	if err != nil {
+		return errs.FileError(err, responseFile)
+	}
+	reqBytes, err := ioutil.ReadFile(bundleFile)
+	if err != nil {
+		return errs.FileError(err, bundleFile)
+	}
+	chain, err := verifyCeremonyResponse(respBytes, reqBytes)
+	if err != nil {
+		return errors.Wrapf(err, "error validating %s", responseFile)
+	}
+
+	var buf bytes.Buffer
+	for _, crt := range chain {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+	}
+	if err := utils.WriteFile(crtFile, buf.Bytes(), 0600); err != nil {
+		return errs.FileError(err, crtFile)
+	}
+
+	leaf := chain[0]
+	ui.PrintSelected("Subject", x509util.CertificateName(leaf))
+	ui.PrintSelected("Issuer", leaf.Issuer.CommonName)
+	ui.PrintSelected("Valid", fmt.Sprintf("%s to %s", leaf.NotBefore.UTC().Format(time.RFC3339), leaf.NotAfter.UTC().Format(time.RFC3339)))
+	ui.PrintSelected("Fingerprint", x509util.Fingerprint(leaf))
+	ui.PrintSelected("Certificate", crtFile)
+	return nil
+}
+
+// signCeremony signs a signing bundle and prints the response bundle.
+func signCeremony(ctx *cli.Context, bundleFile string, issuer *x509util.Identity) error {
+	reqBytes, err := ioutil.ReadFile(bundleFile)
+	if err != nil {
+		return errs.FileError(err, bundleFile)
+	}
+	req, csr, err := parseCeremonyRequest(reqBytes)
+	if err != nil {
+		return errors.Wrapf(err, "error validating %s", bundleFile)
+	}
+
+	printCeremonyRequest(req, csr)
+	ui.PrintSelected("Signing bundle SHA256", sha256Hex(reqBytes))
+	ui.PrintSelected("Issuer", x509util.CertificateName(issuer.Crt))
+	if !ctx.Bool("force") {
+		str, err := ui.Prompt("Would you like to sign this request [y/n]", ui.WithValidateYesNo())
+		if err != nil {
+			return err
+		}
+		if s := strings.ToLower(strings.TrimSpace(str)); s != "y" && s != "yes" {
+			return errors.New("the signing bundle was not signed")
+		}
+	}
+
+	isCA := req.Profile == "intermediate-ca"
+	var profile x509util.Profile
+	if isCA {
+		profile, err = x509util.NewIntermediateProfile(csr.Subject.CommonName, issuer.Crt, issuer.Key,
+			x509util.WithPublicKey(csr.PublicKey), x509util.WithSubject(csr.Subject))
+	} else {
+		profile, err = x509util.NewLeafProfileWithCSR(csr, issuer.Crt, issuer.Key)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := policy.CheckCertificate(profile.Subject(), isCA); err != nil {
+		return err
+	}
+	crtBytes, err := profile.CreateCertificate()
+	if err != nil {
+		return errors.Wrap(err, "error creating certificate")
+	}
+	crt, err := x509util.ParseCertificate(crtBytes)
+	if err != nil {
+		return err
+	}
+
+	resp := newCeremonyResponse(reqBytes, []*x509.Certificate{crt, issuer.Crt}, time.Now())
+	out, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling response bundle")
+	}
+	fmt.Println(string(out))
+	ui.PrintSelected("Fingerprint", x509util.Fingerprint(crt))
+	return nil
+}
+
+func newCeremonyRequest(csr *x509.CertificateRequest, profile, comment string, now time.Time) *ceremonyRequest {
+	return &ceremonyRequest{
+		Type:      ceremonyRequestType,
+		Version:   ceremonyVersion,
+		Profile:   profile,
+		Comment:   comment,
+		CreatedAt: now.UTC().Truncate(time.Second),
+		CSR:       string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw})),
+		SHA256:    sha256Hex(csr.Raw),
+	}
+}
+
+// parseCeremonyRequest parses a signing bundle and verifies its CSR.
+func parseCeremonyRequest(b []byte) (*ceremonyRequest, *x509.CertificateRequest, error) {
+	req := new(ceremonyRequest)
+	if err := json.Unmarshal(b, req); err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing signing bundle")
+	}
+	if req.Type != ceremonyRequestType || req.Version != ceremonyVersion {
+		return nil, nil, errors.Errorf("unsupported signing bundle type %s version %d", req.Type, req.Version)
+	}
+	if req.Profile != "leaf" && req.Profile != "intermediate-ca" {
+		return nil, nil, errors.Errorf("unsupported profile %s", req.Profile)
+	}
+	csr, err := x509util.LoadCSRFromBytes([]byte(req.CSR))
+	if err != nil {
+		return nil, nil, err
+	}
+	if sha256Hex(csr.Raw) != req.SHA256 {
+		return nil, nil, errors.New("the SHA256 of the certificate signing request does not match")
+	}
+	if err := x509util.CheckCertificateRequestSignature(csr); err != nil {
+		return nil, nil, errors.Wrap(err, "the certificate signing request has an invalid signature")
+	}
+	return req, csr, nil
+}
+
+func newCeremonyResponse(reqBytes []byte, chain []*x509.Certificate, now time.Time) *ceremonyResponse {
+	var buf bytes.Buffer
+	for _, crt := range chain {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+	}
+	return &ceremonyResponse{
+		Type:          ceremonyResponseType,
+		Version:       ceremonyVersion,
+		RequestSHA256: sha256Hex(reqBytes),
+		SignedAt:      now.UTC().Truncate(time.Second),
+		Certificate:   buf.String(),
+		SHA256:        sha256Hex(chain[0].Raw),
+	}
+}
+
+// verifyCeremonyResponse verifies that the response bundle was created for
+// the signing bundle and returns its certificate chain.
+func verifyCeremonyResponse(respBytes, reqBytes []byte) ([]*x509.Certificate, error) {
+	resp := new(ceremonyResponse)
+	if err := json.Unmarshal(respBytes, resp); err != nil {
+		return nil, errors.Wrap(err, "error parsing response bundle")
+	}
+	if resp.Type != ceremonyResponseType || resp.Version != ceremonyVersion {
+		return nil, errors.Errorf("unsupported response bundle type %s version %d", resp.Type, resp.Version)
+	}
+	if resp.RequestSHA256 != sha256Hex(reqBytes) {
+		return nil, errors.New("the response was not created for the given signing bundle")
+	}
+	_, csr, err := parseCeremonyRequest(reqBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := parseCertificates([]byte(resp.Certificate))
+	if len(chain) == 0 {
+		return nil, errors.New("the response bundle does not contain a certificate")
+	}
+	leaf := chain[0]
+	if sha256Hex(leaf.Raw) != resp.SHA256 {
+		return nil, errors.New("the SHA256 of the certificate does not match")
+	}
+	if !bytes.Equal(leaf.RawSubjectPublicKeyInfo, csr.RawSubjectPublicKeyInfo) {
+		return nil, errors.New("the certificate does not have the public key of the certificate signing request")
+	}
+	if len(chain) > 1 {
+		if err := leaf.CheckSignatureFrom(chain[1]); err != nil {
+			return nil, errors.Wrap(err, "the certificate is not signed by its issuer")
+		}
+	}
+	return chain, nil
+}
+
+// printCeremonyRequest prints a summary of the signing bundle.
+func printCeremonyRequest(req *ceremonyRequest, csr *x509.CertificateRequest) {
+	var sans []string
+	sans = append(sans, csr.DNSNames...)
+	for _, ip := range csr.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, csr.EmailAddresses...)
+	for _, u := range csr.URIs {
+		sans = append(sans, u.String())
+	}
+
+	ui.PrintSelected("Profile", req.Profile)
+	ui.PrintSelected("Subject", csr.Subject.String())
+	if len(sans) > 0 {
+		ui.PrintSelected("SANs", strings.Join(sans, ", "))
+	}
+	ui.PrintSelected("Public key", csr.PublicKeyAlgorithm.String())
+	if req.Comment != "" {
+		ui.PrintSelected("Comment", req.Comment)
+	}
+	ui.PrintSelected("Created", req.CreatedAt.Format(time.RFC3339))
+	ui.PrintSelected("CSR SHA256", req.SHA256)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,103 @@
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+)
+
+func TestCeremonyRoundTrip(t *testing.T) {
+	now := time.Date(2020, 2, 11, 18, 14, 39, 0, time.UTC)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Root CA"},
+		NotBefore:             now,
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	b, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, caKey.Public(), caKey)
+	assert.FatalError(t, err)
+	ca, err := x509.ParseCertificate(b)
+	assert.FatalError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	b, err = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "foo"},
+		DNSNames: []string{"foo.example.com"},
+	}, key)
+	assert.FatalError(t, err)
+	csr, err := x509.ParseCertificateRequest(b)
+	assert.FatalError(t, err)
+
+	sign := func(pub interface{}) *x509.Certificate {
+		b, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      csr.Subject,
+			NotBefore:    now,
+			NotAfter:     now.Add(time.Hour),
+		}, ca, pub, caKey)
+		assert.FatalError(t, err)
+		crt, err := x509.ParseCertificate(b)
+		assert.FatalError(t, err)
+		return crt
+	}
+
+	reqBytes, err := json.Marshal(newCeremonyRequest(csr, "leaf", "test", now))
+	assert.FatalError(t, err)
+	req, parsed, err := parseCeremonyRequest(reqBytes)
+	assert.FatalError(t, err)
+	assert.Equals(t, "leaf", req.Profile)
+	assert.Equals(t, csr.Raw, parsed.Raw)
+
+	crt := sign(key.Public())
+	respBytes, err := json.Marshal(newCeremonyResponse(reqBytes, []*x509.Certificate{crt, ca}, now))
+	assert.FatalError(t, err)
+	chain, err := verifyCeremonyResponse(respBytes, reqBytes)
+	assert.FatalError(t, err)
+	assert.Len(t, 2, chain)
+	assert.Equals(t, crt.Raw, chain[0].Raw)
+
+	// The response must match the signing bundle.
+	otherReq, err := json.Marshal(newCeremonyRequest(csr, "leaf", "other", now))
+	assert.FatalError(t, err)
+	_, err = verifyCeremonyResponse(respBytes, otherReq)
+	assert.Error(t, err)
+
+	// The certificate must have the public key of the CSR.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	respBytes, err = json.Marshal(newCeremonyResponse(reqBytes, []*x509.Certificate{sign(otherKey.Public()), ca}, now))
+	assert.FatalError(t, err)
+	_, err = verifyCeremonyResponse(respBytes, reqBytes)
+	assert.Error(t, err)
+
+	// The hash of the certificate must match.
+	resp := newCeremonyResponse(reqBytes, []*x509.Certificate{crt, ca}, now)
+	resp.SHA256 = sha256Hex([]byte("foo"))
+	respBytes, err = json.Marshal(resp)
+	assert.FatalError(t, err)
+	_, err = verifyCeremonyResponse(respBytes, reqBytes)
+	assert.Error(t, err)
+
+	// The hash of the CSR must match.
+	r := newCeremonyRequest(csr, "leaf", "", now)
+	r.SHA256 = sha256Hex([]byte("foo"))
+	b, err = json.Marshal(r)
+	assert.FatalError(t, err)
+	_, _, err = parseCeremonyRequest(b)
+	assert.Error(t, err)
+}
@@ -78,20 +78,32 @@ $ step certificate install root-ca.crt
 Uninstall a root certificate from the system truststore:
 '''
 $ step certificate uninstall root-ca.crt
+'''
+
+Create and trust a certificate for localhost for local development:
+'''
+$ step certificate dev
 '''`,
 
 		Subcommands: cli.Commands{
 			bundleCommand(),
 			createCommand(),
+			devCommand(),
 			formatCommand(),
 			inspectCommand(),
 			fingerprintCommand(),
+			diffCommand(),
 			lintCommand(),
 			signCommand(),
+			ceremonyRequestCommand(),
+			ceremonyImportCommand(),
 			verifyCommand(),
 			keyCommand(),
 			installCommand(),
+			exportTrustCommand(),
 			uninstallCommand(),
+			matterCommand(),
+			spiffeCommand(),
 		},
 	}
 
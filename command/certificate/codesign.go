@@ -0,0 +1,111 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/codesign"
+	"github.com/smallstep/cli/errs"
+	zx509 "github.com/smallstep/zcrypto/x509"
+	"github.com/urfave/cli"
+)
+
+type signedFileJSON struct {
+	Format    string               `json:"format"`
+	Chain     []*zx509.Certificate `json:"chain"`
+	Timestamp *signedFileTimestamp `json:"timestamp,omitempty"`
+}
+
+type signedFileTimestamp struct {
+	Time  time.Time            `json:"time"`
+	Chain []*zx509.Certificate `json:"chain"`
+}
+
+// inspectSignedFile prints the certificate chains of the Authenticode or
+// Mach-O code signatures embedded in an executable.
+func inspectSignedFile(ctx *cli.Context, filename string, b []byte) error {
+	sigs, err := codesign.Parse(b)
+	if err != nil {
+		return errors.Wrapf(err, "error inspecting %s", filename)
+	}
+
+	switch format := ctx.String("format"); format {
+	case "text":
+		for i, sig := range sigs {
+			msg := fmt.Sprintf("Signature %d of %d (%s): signed by '%s'", i+1, len(sigs), sig.Format, commonName(sig.Chain[0]))
+			if ts := sig.Timestamp; ts != nil {
+				msg += fmt.Sprintf(", timestamped at %s", ts.Time.UTC().Format(time.RFC3339))
+				if len(ts.Chain) > 0 {
+					msg += fmt.Sprintf(" by '%s'", commonName(ts.Chain[0]))
+				}
+			} else {
+				msg += ", not timestamped"
+			}
+			fmt.Println(msg)
+			if err := inspectCertificates(ctx, certificatesToBlocks(sig.Chain)); err != nil {
+				return err
+			}
+			if ts := sig.Timestamp; ts != nil && len(ts.Chain) > 0 {
+				fmt.Printf("Timestamp of signature %d of %d:\n", i+1, len(sigs))
+				if err := inspectCertificates(ctx, certificatesToBlocks(ts.Chain)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case "json":
+		v := make([]signedFileJSON, len(sigs))
+		for i, sig := range sigs {
+			v[i].Format = sig.Format
+			if v[i].Chain, err = zcertificates(sig.Chain); err != nil {
+				return err
+			}
+			if ts := sig.Timestamp; ts != nil {
+				v[i].Timestamp = &signedFileTimestamp{Time: ts.Time}
+				if v[i].Timestamp.Chain, err = zcertificates(ts.Chain); err != nil {
+					return err
+				}
+			}
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		os.Stdout.Write(b)
+		return nil
+	default:
+		return errs.InvalidFlagValue(ctx, "format", format, "text, json")
+	}
+}
+
+func certificatesToBlocks(crts []*x509.Certificate) []*pem.Block {
+	blocks := make([]*pem.Block, len(crts))
+	for i, crt := range crts {
+		blocks[i] = &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw}
+	}
+	return blocks
+}
+
+func zcertificates(crts []*x509.Certificate) ([]*zx509.Certificate, error) {
+	zcrts := make([]*zx509.Certificate, len(crts))
+	for i, crt := range crts {
+		zcrt, err := zx509.ParseCertificate(crt.Raw)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		zcrts[i] = zcrt
+	}
+	return zcrts, nil
+}
+
+func commonName(crt *x509.Certificate) string {
+	if crt.Subject.CommonName != "" {
+		return crt.Subject.CommonName
+	}
+	return crt.Subject.String()
+}
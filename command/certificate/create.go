@@ -1,14 +1,20 @@
 package certificate
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
+	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/crypto/keys"
 	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/policy"
+	"github.com/smallstep/cli/crypto/pqc"
 	"github.com/smallstep/cli/crypto/x509util"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/flags"
@@ -26,12 +32,45 @@ func createCommand() cli.Command {
 		UsageText: `**step certificate create** <subject> <crt_file> <key_file>
 [**ca**=<issuer-cert>] [**ca-key**=<issuer-key>] [**--csr**]
 [**--curve**=<curve>] [**no-password**] [**--profile**=<profile>]
-[**--size**=<size>] [**--type**=<type>] [**--san**=<SAN>]`,
+[**--size**=<size>] [**--type**=<type>] [**--san**=<SAN>] [**--sig-alg**=<algorithm>]
+[**--alt-key**=<file>] [**--alt-alg**=<algorithm>] [**--alt-ca-key**=<file>]
+[**--experimental**] [**--metadata**] [**--intended-use**=<description>]
+[**--matter-vid**=<id>] [**--matter-pid**=<id>]
+
+**step certificate create** **--interactive** [<subject>] [<crt_file>] [<key_file>]`,
 		Description: `**step certificate create** generates a certificate or a
 certificate signing requests (CSR) that can be signed later using 'step
 certificates sign' (or some other tool) to produce a certificate.
 
-This command creates x.509 certificates for use with TLS.
+This command creates x.509 certificates for use with TLS, and with the **smime**
+profile certificates to sign and encrypt email using S/MIME, see
+**step crypto smime**. The **code-signing** profile creates certificates to sign
+executables and other release artifacts. The **matter-paa**, **matter-pai** and
+**matter-dac** profiles create the device attestation chains of Matter devices,
+see **step certificate matter**.
+
+With the **--interactive** flag the command runs a wizard that asks for the
+subject, the profile, the issuer, the SANs, the key type, the validity and the
+output files that were not passed. At the end it prints the equivalent
+non-interactive command, so the same certificate can be created again in a
+script.
+
+The experimental flag **--alt-key** creates hybrid certificates, they contain a
+post-quantum ML-DSA public key and a ML-DSA signature of the issuer in the
+extensions defined in ITU-T X.509 (10/2019) section 9.8. Clients that do not
+understand those extensions will use the classical key and signature. The
+post-quantum keys require a step binary built with the **pqc** tag.
+
+Certificates with wildcard SANs, or with a validity longer than 398 days for
+leaf certificates or 10 years for CA certificates, require the **--subtle**
+flag, a warning with the reason is printed. A wildcard is valid for any name in
+its domain, and a long validity leaves a compromised key usable for a long time.
+Wildcards can be allowed, and the maximum validities changed, in the local policy
+file <$STEPPATH/policy.json>, e.g.
+{"allowWildcards": true, "maxLeafDuration": "2160h", "maxCADuration": "175200h"}.
+A maximum validity of "0s" disables its check. Wildcards that clients reject,
+like '*.*.example.com' or '*.com', always require **--subtle**. See **step policy**
+for the other rules of the local policy.
 
 ## POSITIONAL ARGUMENTS
 
@@ -113,6 +152,32 @@ $ step certificate create foo foo.crt foo.key --profile leaf \
   --not-before 24h --not-after 2160h
 '''
 
+Create an S/MIME certificate and key for an email address, with an RSA key so
+it can also be used to decrypt email:
+
+'''
+$ step certificate create jane@example.com jane.crt jane.key --profile smime \
+  --ca ./intermediate-ca.crt --ca-key ./intermediate-ca.key --kty RSA --size 3072
+'''
+
+Create a code signing certificate and key for a publisher:
+
+'''
+$ step certificate create "Example Inc" release.crt release.key --profile code-signing \
+  --ca ./intermediate-ca.crt --ca-key ./intermediate-ca.key --kty RSA --size 3072
+'''
+
+Create a Matter PAI for a vendor, and a DAC without expiration for one of its
+devices:
+
+'''
+$ step certificate create "Example PAI" pai.crt pai.key --profile matter-pai \
+  --matter-vid FFF1 --ca ./paa.crt --ca-key ./paa.key
+$ step certificate create "Example DAC" dac.crt dac.key --profile matter-dac \
+  --matter-pid 8000 --ca ./pai.crt --ca-key ./pai.key \
+  --not-after 9999-12-31T23:59:59Z --no-password --insecure
+'''
+
 Create a root certificate and key with underlying OKP Ed25519:
 
 '''
@@ -134,11 +199,57 @@ $ step certificate create foo foo.crt foo.key --profile leaf \
   --ca ./intermediate-ca.crt --ca-key ./intermediate-ca.key --kty RSA --size 2048
 '''
 
+Create an intermediate certificate signed with RSA-PSS by an RSA root, as
+required by some signature algorithm policies:
+
+'''
+$ step certificate create intermediate-ca intermediate-ca.crt intermediate-ca.key \
+  --profile intermediate-ca --ca ./root-ca.crt --ca-key ./root-ca.key \
+  --sig-alg SHA256-RSAPSS
+'''
+
+Create a leaf certificate for a wildcard SAN valid for two years:
+
+'''
+$ step certificate create '*.example.com' wildcard.crt wildcard.key --profile leaf \
+  --ca ./intermediate-ca.crt --ca-key ./intermediate-ca.key --not-after 17520h --subtle
+'''
+
 Create a CSR and key with underlying OKP Ed25519:
 
 '''
 $ step certificate create foo foo.csr foo.key --csr --kty OKP --curve Ed25519
 '''
+
+Create an experimental hybrid root certificate with an alternative ML-DSA-65 key:
+
+'''
+$ step certificate create root-ca root-ca.crt root-ca.key --profile root-ca \
+  --alt-key root-ca.mldsa.key --experimental
+'''
+
+Create an experimental hybrid leaf certificate signed by the hybrid root:
+
+'''
+$ step certificate create foo foo.crt foo.key --profile leaf \
+  --ca ./root-ca.crt --ca-key ./root-ca.key --alt-ca-key ./root-ca.mldsa.key \
+  --alt-key foo.mldsa.key --alt-alg ML-DSA-44 --experimental
+'''
+
+Create a certificate answering the questions of a wizard:
+
+'''
+$ step certificate create --interactive
+'''
+
+Create a leaf certificate and key and record what they are for in
+foo.crt.meta.json and foo.key.meta.json:
+
+'''
+$ step certificate create foo foo.crt foo.key --profile leaf \
+  --ca ./intermediate-ca.crt --ca-key ./intermediate-ca.key \
+  --metadata --intended-use "mTLS client for the billing service"
+'''
 `,
 		Flags: []cli.Flag{
 			cli.StringFlag{
@@ -146,8 +257,10 @@ $ step certificate create foo foo.csr foo.key --csr --kty OKP --curve Ed25519
 				Usage: `The certificate authority used to issue the new certificate (PEM file).`,
 			},
 			cli.StringFlag{
-				Name:  "ca-key",
-				Usage: `The certificate authority private key used to sign the new certificate (PEM file).`,
+				Name: "ca-key",
+				Usage: `The certificate authority private key used to sign the new certificate (PEM
+file). A key stored in a KMS can be used with a key URI, e.g.
+awskms:alias/my-key.`,
 			},
 			cli.BoolFlag{
 				Name:  "csr",
@@ -175,6 +288,27 @@ recommended. Requires **--insecure** flag.`,
     **leaf**
 	:  Generate a leaf x.509 certificate suitable for use with TLs.
 
+    **smime**
+    :  Generate a leaf x.509 certificate suitable for use with S/MIME, with the
+    email protection extended key usage and the email addresses in the subject
+    and the **--san** flags.
+
+    **code-signing**
+    :  Generate a leaf x.509 certificate suitable for signing code, with the code
+    signing extended key usage. The subject is not added as a SAN.
+
+    **matter-paa**
+    :  Generate a self-signed Matter Product Attestation Authority (PAA), with the
+    optional **--matter-vid**.
+
+    **matter-pai**
+    :  Generate a Matter Product Attestation Intermediate (PAI) for the vendor in
+    **--matter-vid**, and optionally a product in **--matter-pid**.
+
+    **matter-dac**
+    :  Generate a Matter Device Attestation Certificate (DAC) for the product in
+    **--matter-pid**. The vendor and product ids default to the ones in the PAI.
+
     **intermediate-ca**
     :  Generate a certificate that can be used to sign additional leaf or intermediate certificates.
 
@@ -224,6 +358,30 @@ unset, default is P-256 for EC keys and Ed25519 for OKP keys.
     **Ed25519**
     :  Ed25519 Curve
 `,
+			},
+			cli.StringFlag{
+				Name: "sig-alg",
+				Usage: `The signature <algorithm> used by the issuer to sign the certificate or
+certificate signing request. It must be compatible with the type of the issuer
+key. If unset, the default for the key type is used: SHA256-RSA for RSA keys,
+ECDSA-SHA256, ECDSA-SHA384 or ECDSA-SHA512 depending on the curve of EC keys, and
+Ed25519 for OKP keys.
+
+: <algorithm> is a case-insensitive string and must be one of:
+
+    **SHA256-RSA**, **SHA384-RSA**, **SHA512-RSA**
+    :  RSA PKCS #1 v1.5 with SHA-256, SHA-384 or SHA-512
+
+    **SHA256-RSAPSS**, **SHA384-RSAPSS**, **SHA512-RSAPSS**
+    :  RSA-PSS with SHA-256, SHA-384 or SHA-512
+
+    **ECDSA-SHA256**, **ECDSA-SHA384**, **ECDSA-SHA512**
+    :  ECDSA with SHA-256, SHA-384 or SHA-512
+
+    **Ed25519**
+    :  Ed25519
+
+: The names of the Go constants, e.g. SHA256WithRSAPSS, are also accepted.`,
 			},
 			cli.StringFlag{
 				Name: "not-before",
@@ -246,14 +404,77 @@ unit suffix, such as "300ms", "-1.5h" or "2h45m". Valid time units are "ns",
 				Usage: `Add DNS or IP Address Subjective Alternative Names (SANs). Use the '--san'
 flag multiple times to configure multiple SANs.`,
 			},
+			flags.VerbatimSAN,
+			matterVIDFlag,
+			matterPIDFlag,
+			cli.StringFlag{
+				Name: "alt-key",
+				Usage: `The <file> to write the alternative post-quantum private key of a hybrid
+certificate. A new key is generated and its public key is added to the
+certificate. Requires **--experimental**.`,
+			},
+			cli.StringFlag{
+				Name:  "alt-alg",
+				Value: pqc.DefaultAlgorithm,
+				Usage: `The <algorithm> of the alternative key of a hybrid certificate.
+
+: <algorithm> is a case-sensitive string and must be one of:
+
+    **ML-DSA-44**
+    :  ML-DSA with the security category 2
+
+    **ML-DSA-65**
+    :  ML-DSA with the security category 3 (default)
+
+    **ML-DSA-87**
+    :  ML-DSA with the security category 5`,
+			},
+			cli.StringFlag{
+				Name: "alt-ca-key",
+				Usage: `The alternative ML-DSA private key (PEM <file>) of the certificate authority
+used to add the alternative signature of a hybrid certificate. It is required
+with the 'leaf' and 'intermediate-ca' profiles and **--alt-key**.`,
+			},
+			cli.BoolFlag{
+				Name: "deterministic",
+				Usage: `Use deterministic nonces as defined in RFC 6979 to sign the certificate or
+certificate signing request with an ECDSA key. It is useful for reproducible
+test fixtures or environments without a reliable source of randomness, but a
+fault in the signing process can leak the key, and the serial number of a
+certificate is still random. Requires the **--subtle** flag.`,
+			},
+			cli.BoolFlag{
+				Name:   "subtle",
+				Hidden: true,
+			},
+			flags.Experimental,
+			flags.Metadata,
+			flags.IntendedUse,
+			flags.Interactive,
 			flags.Force,
+			flags.LegacyEncryption,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
 		},
 	}
 }
 
 func createAction(ctx *cli.Context) error {
-	if err := errs.NumberOfArguments(ctx, 3); err != nil {
-		return err
+	var args []string
+	if ctx.Bool("interactive") {
+		if ctx.NArg() > 3 {
+			return errs.TooManyArguments(ctx)
+		}
+		var err error
+		if args, err = createWizard(ctx); err != nil {
+			return err
+		}
+	} else {
+		if err := errs.NumberOfArguments(ctx, 3); err != nil {
+			return err
+		}
+		args = ctx.Args()
 	}
 
 	insecure := ctx.Bool("insecure")
@@ -261,10 +482,21 @@ func createAction(ctx *cli.Context) error {
 	if noPass && !insecure {
 		return errs.RequiredWithFlag(ctx, "insecure", "no-password")
 	}
+	if noPass {
+		if err := policy.CheckKeyEncryption(); err != nil {
+			return err
+		}
+	}
+
+	deterministic := ctx.Bool("deterministic")
+	if deterministic && !ctx.Bool("subtle") {
+		return errs.RequiredWithFlag(ctx, "deterministic", "subtle")
+	}
+	if ctx.IsSet("intended-use") && !ctx.Bool("metadata") {
+		return errs.RequiredWithFlag(ctx, "intended-use", "metadata")
+	}
 
-	subject := ctx.Args().Get(0)
-	crtFile := ctx.Args().Get(1)
-	keyFile := ctx.Args().Get(2)
+	subject, crtFile, keyFile := args[0], args[1], args[2]
 	if crtFile == keyFile {
 		return errs.EqualArguments(ctx, "CRT_FILE", "KEY_FILE")
 	}
@@ -292,15 +524,56 @@ func createAction(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	// Post-quantum keys can only be used as alternative keys.
+	if kty == pqc.KeyType {
+		return errs.InvalidFlagValue(ctx, "kty", kty, "RSA, EC, OKP")
+	}
+
+	altKeyFile := ctx.String("alt-key")
+	altCAKeyFile := ctx.String("alt-ca-key")
+	altAlg := ctx.String("alt-alg")
+	switch {
+	case altKeyFile == "" && altCAKeyFile != "":
+		return errs.RequiredWithFlag(ctx, "alt-ca-key", "alt-key")
+	case altKeyFile == "" && ctx.IsSet("alt-alg"):
+		return errs.RequiredWithFlag(ctx, "alt-alg", "alt-key")
+	case altKeyFile != "" && !ctx.Bool("experimental"):
+		return errs.RequiredExperimentalFlag(ctx, "alt-key")
+	case altKeyFile != "" && typ == "x509-csr":
+		return errs.IncompatibleFlagWithFlag(ctx, "alt-key", "csr")
+	case altKeyFile != "" && !pqc.IsSignatureAlgorithm(altAlg):
+		return errs.InvalidFlagValue(ctx, "alt-alg", altAlg, "ML-DSA-44, ML-DSA-65, ML-DSA-87")
+	}
+
+	sigAlg := x509.UnknownSignatureAlgorithm
+	if s := ctx.String("sig-alg"); s != "" {
+		if sigAlg, err = x509util.ParseSignatureAlgorithm(s); err != nil {
+			return errs.InvalidFlagValue(ctx, "sig-alg", s, strings.Join(x509util.SignatureAlgorithmNames(), ", "))
+		}
+	}
 
 	sans := ctx.StringSlice("san")
-	if len(sans) == 0 {
+	// Code signing and Matter certificates do not name a host.
+	if len(sans) == 0 && ctx.String("profile") != "code-signing" && !isMatterProfile(ctx.String("profile")) {
 		sans = []string{subject}
 	}
+	if !ctx.Bool("verbatim-san") {
+		if sans, err = x509util.NormalizeSANs(sans); err != nil {
+			return errors.Wrap(err, "error parsing flag '--san'")
+		}
+	}
+	// S/MIME certificates need the email addresses in their own SANs.
+	var emails []string
+	if !ctx.Bool("csr") && ctx.String("profile") == "smime" {
+		if sans, emails = x509util.SplitEmails(sans); len(emails) == 0 {
+			return errors.New("profile 'smime' requires an email address in <subject> or in the flag '--san'")
+		}
+	}
 	dnsNames, ips := x509util.SplitSANs(sans)
 
 	var (
 		priv       interface{}
+		altPriv    *pqc.PrivateKey
 		pubPEM     *pem.Block
 		outputType string
 	)
@@ -321,7 +594,19 @@ func createAction(ctx *cli.Context) error {
 			DNSNames:    dnsNames,
 			IPAddresses: ips,
 		}
-		csrBytes, err := stepx509.CreateCertificateRequest(rand.Reader, _csr, priv)
+		if sigAlg != x509.UnknownSignatureAlgorithm {
+			if err := checkSignatureAlgorithm(sigAlg, priv); err != nil {
+				return err
+			}
+			_csr.SignatureAlgorithm = stepx509.SignatureAlgorithm(sigAlg)
+		}
+		signer := priv
+		if deterministic {
+			if signer, err = deterministicSigner(priv); err != nil {
+				return err
+			}
+		}
+		csrBytes, err := stepx509.CreateCertificateRequest(rand.Reader, _csr, signer)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -334,14 +619,24 @@ func createAction(ctx *cli.Context) error {
 		outputType = "certificate signing request"
 	case "x509":
 		var (
-			err       error
-			prof      = ctx.String("profile")
-			caPath    = ctx.String("ca")
-			caKeyPath = ctx.String("ca-key")
-			profile   x509util.Profile
+			err         error
+			prof        = ctx.String("profile")
+			caPath      = ctx.String("ca")
+			caKeyPath   = ctx.String("ca-key")
+			profile     x509util.Profile
+			issIdentity *x509util.Identity
+			profileOpts = []x509util.WithOption{
+				x509util.GenerateKeyPair(kty, crv, size),
+				x509util.WithNotBeforeAfterDuration(notBefore, notAfter, 0),
+				x509util.WithDNSNames(dnsNames),
+				x509util.WithIPAddresses(ips),
+			}
 		)
+		if sigAlg != x509.UnknownSignatureAlgorithm {
+			profileOpts = append(profileOpts, x509util.WithSignatureAlgorithm(sigAlg))
+		}
 		switch prof {
-		case "leaf", "intermediate-ca":
+		case "leaf", "intermediate-ca", "smime", "code-signing", "matter-pai", "matter-dac":
 			if caPath == "" {
 				return errs.RequiredWithFlagValue(ctx, "profile", prof, "ca")
 			}
@@ -350,49 +645,116 @@ func createAction(ctx *cli.Context) error {
 			}
 			switch prof {
 			case "leaf":
-				issIdentity, err := loadIssuerIdentity(ctx, prof, caPath, caKeyPath)
+				issIdentity, err = loadIssuerIdentity(ctx, prof, caPath, caKeyPath)
 				if err != nil {
 					return errors.WithStack(err)
 				}
 				profile, err = x509util.NewLeafProfile(subject, issIdentity.Crt,
-					issIdentity.Key, x509util.GenerateKeyPair(kty, crv, size),
-					x509util.WithNotBeforeAfterDuration(notBefore, notAfter, 0),
-					x509util.WithDNSNames(dnsNames),
-					x509util.WithIPAddresses(ips))
+					issIdentity.Key, profileOpts...)
 				if err != nil {
 					return errors.WithStack(err)
 				}
 			case "intermediate-ca":
-				issIdentity, err := loadIssuerIdentity(ctx, prof, caPath, caKeyPath)
+				issIdentity, err = loadIssuerIdentity(ctx, prof, caPath, caKeyPath)
 				if err != nil {
 					return errors.WithStack(err)
 				}
+				profile, err = x509util.NewIntermediateProfile(subject,
+					issIdentity.Crt, issIdentity.Key, profileOpts...)
 				if err != nil {
 					return errors.WithStack(err)
 				}
-				profile, err = x509util.NewIntermediateProfile(subject,
-					issIdentity.Crt, issIdentity.Key,
-					x509util.GenerateKeyPair(kty, crv, size),
-					x509util.WithNotBeforeAfterDuration(notBefore, notAfter, 0),
-					x509util.WithDNSNames(dnsNames),
-					x509util.WithIPAddresses(ips))
+			case "smime":
+				issIdentity, err = loadIssuerIdentity(ctx, prof, caPath, caKeyPath)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				profileOpts = append(profileOpts, x509util.WithEmailAddresses(emails))
+				profile, err = x509util.NewSMIMEProfile(subject, issIdentity.Crt,
+					issIdentity.Key, profileOpts...)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+			case "code-signing":
+				issIdentity, err = loadIssuerIdentity(ctx, prof, caPath, caKeyPath)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				profile, err = x509util.NewCodeSigningProfile(subject, issIdentity.Crt,
+					issIdentity.Key, profileOpts...)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+			case "matter-pai", "matter-dac":
+				issIdentity, err = loadIssuerIdentity(ctx, prof, caPath, caKeyPath)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				vid, pid, err := matterIDsFromFlags(ctx, issIdentity.Crt)
+				if err != nil {
+					return err
+				}
+				if prof == "matter-pai" {
+					profile, err = x509util.NewMatterPAIProfile(subject, vid, pid,
+						issIdentity.Crt, issIdentity.Key, profileOpts...)
+				} else {
+					profile, err = x509util.NewMatterDACProfile(subject, vid, pid,
+						issIdentity.Crt, issIdentity.Key, profileOpts...)
+				}
 				if err != nil {
 					return errors.WithStack(err)
 				}
 			}
 		case "root-ca":
-			profile, err = x509util.NewRootProfile(subject,
-				x509util.GenerateKeyPair(kty, crv, size),
-				x509util.WithNotBeforeAfterDuration(notBefore, notAfter, 0),
-				x509util.WithDNSNames(dnsNames),
-				x509util.WithIPAddresses(ips))
+			profile, err = x509util.NewRootProfile(subject, profileOpts...)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		case "matter-paa":
+			if ctx.IsSet("matter-pid") {
+				return errs.IncompatibleFlagValue(ctx, "matter-pid", "profile", prof)
+			}
+			vid, _, err := matterIDsFromFlags(ctx, nil)
+			if err != nil {
+				return err
+			}
+			profile, err = x509util.NewMatterPAAProfile(subject, vid, profileOpts...)
 			if err != nil {
 				return errors.WithStack(err)
 			}
 		default:
-			return errs.InvalidFlagValue(ctx, "profile", prof, "leaf, intermediate-ca, root-ca")
+			return errs.InvalidFlagValue(ctx, "profile", prof, "leaf, intermediate-ca, root-ca, smime, code-signing, matter-paa, matter-pai, matter-dac")
+		}
+		if sigAlg != x509.UnknownSignatureAlgorithm {
+			issKey := profile.SubjectPrivateKey()
+			if issIdentity != nil {
+				issKey = issIdentity.Key
+			}
+			if err := checkSignatureAlgorithm(sigAlg, issKey); err != nil {
+				return err
+			}
+		}
+		if err := policy.CheckCertificate(profile.Subject(), prof == "intermediate-ca" || prof == "root-ca" ||
+			prof == "matter-paa" || prof == "matter-pai"); err != nil {
+			return err
+		}
+		if deterministic {
+			issKey := profile.SubjectPrivateKey()
+			if issIdentity != nil {
+				issKey = issIdentity.Key
+			}
+			signer, err := deterministicSigner(issKey)
+			if err != nil {
+				return err
+			}
+			profile.SetIssuerPrivateKey(signer)
+		}
+		var crtBytes []byte
+		if altKeyFile == "" {
+			crtBytes, err = profile.CreateCertificate()
+		} else {
+			crtBytes, altPriv, err = createHybridCertificate(ctx, profile, issIdentity, altAlg, altCAKeyFile)
 		}
-		crtBytes, err := profile.CreateCertificate()
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -411,29 +773,134 @@ func createAction(ctx *cli.Context) error {
 		return errs.FileError(err, crtFile)
 	}
 
-	if noPass {
-		_, err = pemutil.Serialize(priv, pemutil.ToFile(keyFile, 0600))
-		if err != nil {
-			return errors.WithStack(err)
-		}
-	} else {
+	opts := []pemutil.Options{
+		pemutil.WithLegacyEncryption(ctx.Bool("insecure-legacy-encryption")),
+	}
+	if !noPass {
 		pass, err := ui.PromptPassword("Please enter the password to encrypt the private key")
 		if err != nil {
 			return errors.Wrap(err, "error reading password")
 		}
-		_, err = pemutil.Serialize(priv, pemutil.WithPassword(pass),
-			pemutil.ToFile(keyFile, 0600))
+		opts = append(opts, pemutil.WithPassword(pass))
+	}
+	_, err = pemutil.Serialize(priv, append(opts, pemutil.ToFile(keyFile, 0600))...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// The alternative key uses the same password.
+	if altPriv != nil {
+		_, err = pemutil.Serialize(altPriv, append(opts, pemutil.ToFile(altKeyFile, 0600))...)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 	}
 
+	if ctx.Bool("metadata") {
+		if err := writeCreateMetadata(ctx, subject, pubPEM, priv, crtFile, keyFile, altKeyFile); err != nil {
+			return err
+		}
+	}
+
 	ui.Printf("Your %s has been saved in %s.\n", outputType, crtFile)
 	ui.Printf("Your private key has been saved in %s.\n", keyFile)
+	if altPriv != nil {
+		ui.Printf("Your alternative private key has been saved in %s.\n", altKeyFile)
+	}
 
 	return nil
 }
 
+// checkSignatureAlgorithm returns an error if the signature algorithm in the
+// --sig-alg flag cannot be used with the given signing key.
+func checkSignatureAlgorithm(alg x509.SignatureAlgorithm, key interface{}) error {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return errors.Errorf("unsupported signing key type %T", key)
+	}
+	if err := x509util.CheckSignatureAlgorithm(alg, signer.Public()); err != nil {
+		return errs.Usage(errors.Wrap(err, "invalid value for flag '--sig-alg'"))
+	}
+	return nil
+}
+
+// writeCreateMetadata writes the metadata files of the certificate or CSR, and
+// of the private keys.
+func writeCreateMetadata(ctx *cli.Context, subject string, pubPEM *pem.Block, priv interface{}, crtFile, keyFile, altKeyFile string) error {
+	md := utils.NewMetadata(ctx)
+	md.Subject = subject
+	md.Files = []string{crtFile, keyFile}
+	if altKeyFile != "" {
+		md.Files = append(md.Files, altKeyFile)
+	}
+	if pubPEM.Type == "CERTIFICATE" {
+		crt, err := x509.ParseCertificate(pubPEM.Bytes)
+		if err != nil {
+			return errors.Wrap(err, "error parsing certificate")
+		}
+		md.SetCertificate(crt)
+	} else if pub, err := keys.PublicKey(priv); err == nil {
+		md.SetPublicKey(pub)
+	}
+	// The fingerprints are the ones of the classical key.
+	for _, fn := range []string{crtFile, keyFile} {
+		if err := utils.WriteMetadata(fn, md); err != nil {
+			return errs.FileError(err, fn+utils.MetadataExt)
+		}
+	}
+	return nil
+}
+
+// createHybridCertificate generates a new alternative key and creates a hybrid
+// certificate signed with the alternative key of the issuer. Root certificates
+// are signed with the new alternative key.
+func createHybridCertificate(ctx *cli.Context, profile x509util.Profile, issIdentity *x509util.Identity, alg, caKeyFile string) ([]byte, *pqc.PrivateKey, error) {
+	var altIssuer *pqc.PrivateKey
+	if issIdentity == nil {
+		if caKeyFile != "" {
+			return nil, nil, errs.IncompatibleFlagValue(ctx, "alt-ca-key", "profile", ctx.String("profile"))
+		}
+	} else {
+		if caKeyFile == "" {
+			return nil, nil, errs.RequiredWithFlagValue(ctx, "profile", ctx.String("profile"), "alt-ca-key")
+		}
+		key, err := pemutil.Read(caKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		var ok bool
+		if altIssuer, ok = key.(*pqc.PrivateKey); !ok || !pqc.IsSignatureAlgorithm(altIssuer.Algorithm()) {
+			return nil, nil, errors.Errorf("error reading %s: the key is not an ML-DSA private key", caKeyFile)
+		}
+		// If the issuer is a hybrid certificate its alternative key must match.
+		if pub, err := x509util.AltPublicKey(issIdentity.Crt); err == nil && !pub.Equal(altIssuer.Public()) {
+			return nil, nil, errors.Errorf("error reading %s: the key does not match the alternative public key in %s", caKeyFile, ctx.String("ca"))
+		}
+	}
+
+	altPriv, err := pqc.GenerateKey(alg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if altIssuer == nil {
+		altIssuer = altPriv
+	}
+	crtBytes, err := x509util.CreateHybridCertificate(profile, altPriv.Public().(*pqc.PublicKey), altIssuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return crtBytes, altPriv, nil
+}
+
+// deterministicSigner returns a signer using RFC 6979 deterministic nonces
+// for the given ECDSA key.
+func deterministicSigner(key interface{}) (crypto.Signer, error) {
+	k, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("flag '--deterministic' requires an ECDSA key")
+	}
+	return keys.NewDeterministicSigner(k), nil
+}
+
 func loadIssuerIdentity(ctx *cli.Context, profile, caPath, caKeyPath string) (*x509util.Identity, error) {
 	if caPath == "" {
 		return nil, errs.RequiredWithFlagValue(ctx, "profile", profile, "ca")
@@ -0,0 +1,234 @@
+package certificate
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/keys"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/smallstep/truststore"
+	"github.com/urfave/cli"
+)
+
+const (
+	devRootName            = "Smallstep Development Root CA"
+	devCertificateDuration = 90 * 24 * time.Hour
+)
+
+// devSANs are the names that are always included in the development
+// certificate.
+var devSANs = []string{"localhost", "127.0.0.1", "::1"}
+
+func devCommand() cli.Command {
+	return cli.Command{
+		Name:   "dev",
+		Action: command.ActionFunc(devAction),
+		Usage:  "create and trust a certificate for local development",
+		UsageText: `**step certificate dev** [**--dir**=<path>] [**--san**=<SAN>]
+[**--prefix**=<name>] [**--all**] [**--java**] [**--firefox**] [**--no-system**]
+
+**step certificate dev** **--undo** [**--dir**=<path>]
+[**--all**] [**--java**] [**--firefox**] [**--no-system**]`,
+		Description: `**step certificate dev** creates a throwaway root certificate, installs it in
+the system truststore, and uses it to sign a certificate for localhost with the
+SANs localhost, 127.0.0.1 and ::1. The private key of the root is never written
+to disk, so it cannot be used to sign other certificates.
+
+The certificate and its unencrypted private key are written to
+<path>/localhost.crt and <path>/localhost.key, the names used by default by
+most development servers. The root certificate is kept in
+$STEPPATH/dev/root_ca.crt so it can be removed later.
+
+With the **--undo** flag the root certificate is removed from the
+truststores, and the root, the certificate and the key are deleted.
+
+## EXAMPLES
+
+Create a certificate for localhost in the current directory:
+'''
+$ step certificate dev
+'''
+
+Create a certificate with an extra name in the certs directory, and trust it
+in the system, NSS and Java truststores:
+'''
+$ step certificate dev --dir certs --san myapp.test --all
+'''
+
+Remove everything created by the previous command:
+'''
+$ step certificate dev --undo --dir certs --all
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "dir",
+				Usage: `The <path> of the directory where the certificate and key are written.`,
+				Value: ".",
+			},
+			cli.StringSliceFlag{
+				Name: "san",
+				Usage: `Add <dns|ip> Subject Alternative Names to the certificate, in addition to
+localhost, 127.0.0.1 and ::1. Use the '--san' flag multiple times to add
+multiple SANs.`,
+			},
+			cli.BoolFlag{
+				Name:  "undo",
+				Usage: `Uninstall the root certificate and remove all the files created before.`,
+			},
+			cli.StringFlag{
+				Name: "prefix",
+				Usage: `The prefix used to <name> the CA in the truststore. Defaults to the
+certificate common name.`,
+			},
+			cli.BoolFlag{
+				Name:  "java",
+				Usage: "install on the Java key store",
+			},
+			cli.BoolFlag{
+				Name:  "firefox, nss",
+				Usage: "install on the NSS security databases of Firefox and Chromium",
+			},
+			cli.BoolFlag{
+				Name:  "no-system",
+				Usage: "disables the install on the system truststore",
+			},
+			cli.BoolFlag{
+				Name:  "all",
+				Usage: "install on the system, NSS and Java truststores",
+			},
+		},
+	}
+}
+
+// devFiles returns the paths of the root certificate, certificate and key
+// used by step certificate dev.
+func devFiles(ctx *cli.Context) (rootFile, crtFile, keyFile string) {
+	dir := ctx.String("dir")
+	rootFile = filepath.Join(config.StepPath(), "dev", "root_ca.crt")
+	crtFile = filepath.Join(dir, "localhost.crt")
+	keyFile = filepath.Join(dir, "localhost.key")
+	return
+}
+
+func devAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+	if ctx.Bool("undo") {
+		if ctx.IsSet("san") {
+			return errs.IncompatibleFlagWithFlag(ctx, "undo", "san")
+		}
+		return devUndo(ctx)
+	}
+
+	rootFile, crtFile, keyFile := devFiles(ctx)
+	if _, err := os.Stat(rootFile); err == nil {
+		return errors.Errorf("a development root already exists in %s; use 'step certificate dev --undo' to remove it first", rootFile)
+	}
+
+	rootProfile, err := x509util.NewRootProfile(devRootName,
+		x509util.GenerateKeyPair(keys.DefaultKeyType, keys.DefaultKeyCurve, keys.DefaultKeySize))
+	if err != nil {
+		return err
+	}
+	rootBytes, err := rootProfile.CreateCertificate()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	sans := append(append([]string{}, devSANs...), ctx.StringSlice("san")...)
+	dnsNames, ips := x509util.SplitSANs(sans)
+	leafProfile, err := x509util.NewLeafProfile("localhost", rootProfile.Subject(), rootProfile.SubjectPrivateKey(),
+		x509util.GenerateKeyPair(keys.DefaultKeyType, keys.DefaultKeyCurve, keys.DefaultKeySize),
+		x509util.WithNotBeforeAfterDuration(time.Time{}, time.Time{}, devCertificateDuration),
+		x509util.WithDNSNames(dnsNames),
+		x509util.WithIPAddresses(ips))
+	if err != nil {
+		return err
+	}
+	crtBytes, err := leafProfile.CreateCertificate()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(rootFile), 0700); err != nil {
+		return errs.FileError(err, filepath.Dir(rootFile))
+	}
+	if err := utils.WriteFile(rootFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: rootBytes,
+	}), 0600); err != nil {
+		return errs.FileError(err, rootFile)
+	}
+	if err := utils.WriteFile(crtFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: crtBytes,
+	}), 0600); err != nil {
+		return errs.FileError(err, crtFile)
+	}
+	if _, err := pemutil.Serialize(leafProfile.SubjectPrivateKey(), pemutil.ToFile(keyFile, 0600)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	root, err := pemutil.ReadCertificate(rootFile)
+	if err != nil {
+		return err
+	}
+	if err := truststore.Install(root, truststoreOptions(ctx, root)...); err != nil {
+		switch err := err.(type) {
+		case *truststore.CmdError:
+			return errors.Errorf("failed to execute \"%s\" failed with: %s", strings.Join(err.Cmd().Args, " "), err.Err())
+		default:
+			return errors.Wrapf(err, "failed to install %s", rootFile)
+		}
+	}
+	if err := installNSS(ctx, rootFile); err != nil {
+		return err
+	}
+
+	ui.Printf("Your root certificate has been saved in %s and installed.\n", rootFile)
+	ui.Printf("Your certificate has been saved in %s.\n", crtFile)
+	ui.Printf("Your private key has been saved in %s.\n", keyFile)
+	ui.Printf("The certificate is valid for %s.\n", strings.Join(sans, ", "))
+	return nil
+}
+
+func devUndo(ctx *cli.Context) error {
+	rootFile, crtFile, keyFile := devFiles(ctx)
+
+	root, err := pemutil.ReadCertificate(rootFile)
+	if err != nil {
+		return err
+	}
+	if err := truststore.Uninstall(root, truststoreOptions(ctx, root)...); err != nil {
+		switch err := err.(type) {
+		case *truststore.CmdError:
+			return errors.Errorf("failed to execute \"%s\" failed with: %s", strings.Join(err.Cmd().Args, " "), err.Err())
+		default:
+			return errors.Wrapf(err, "failed to uninstall %s", rootFile)
+		}
+	}
+	if err := uninstallNSS(ctx, rootFile); err != nil {
+		return err
+	}
+
+	for _, filename := range []string{rootFile, crtFile, keyFile} {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return errs.FileError(err, filename)
+		}
+	}
+
+	fmt.Printf("Certificate %s has been removed.\n", rootFile)
+	return nil
+}
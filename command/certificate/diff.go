@@ -0,0 +1,354 @@
+package certificate
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func diffCommand() cli.Command {
+	return cli.Command{
+		Name:   "diff",
+		Action: command.ActionFunc(diffAction),
+		Usage:  "compare two certificates",
+		UsageText: `**step certificate diff** <crt_file_a> <crt_file_b>
+[**--ignore**=<field>] [**--format**=<format>] [**--roots**=<root-bundle>] [**--insecure**]`,
+		Description: `**step certificate diff** compares the fields of two certificates and prints the
+values removed from the first certificate and added in the second one. It is
+useful to check that a renewed certificate keeps the names, key usages and
+critical extensions of the previous one.
+
+The compared fields are: version, serial, issuer, subject, not-before,
+not-after, public-key, signature-algorithm, dns-names, ip-addresses,
+email-addresses, uris, key-usage, ext-key-usage, basic-constraints,
+subject-key-id, authority-key-id, ocsp-servers, issuing-certificate-urls,
+crl-distribution-points, policies, and extensions. The extensions field compares
+the OIDs of the extensions and if they are critical.
+
+Only the first certificate of a bundle is compared. The certificates can also be
+read from a remote server using an https URL.
+
+## POSITIONAL ARGUMENTS
+
+<crt_file_a>, <crt_file_b>
+:  The paths or https URLs of the certificates to compare.
+
+## EXIT CODES
+
+This command returns 0 if the certificates have the same values, 1 if they
+have differences, and \>1 if any error occurs.
+
+## EXAMPLES
+
+Compare a certificate with its renewal:
+'''
+$ step certificate diff foo.crt foo.renewed.crt
+serial:
+  - 209316913542546553473539493018052946127
+  + 339451826917380018240553432512373862212
+not-before:
+  - 2020-02-11T18:14:39Z
+  + 2020-02-12T18:14:39Z
+not-after:
+  - 2020-02-12T18:15:39Z
+  + 2020-02-13T18:15:39Z
+'''
+
+Check that only the serial number and the validity changed in a renewal:
+'''
+$ step certificate diff --ignore serial --ignore not-before --ignore not-after \
+  foo.crt foo.renewed.crt
+'''
+
+Compare the certificate of a server with a local one in JSON:
+'''
+$ step certificate diff --format json foo.crt https://foo.example.com
+'''`,
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name: "ignore",
+				Usage: `The <field> to exclude from the comparison. Use the flag multiple times to
+exclude multiple fields.`,
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Value: "text",
+				Usage: `The output format of the differences.
+
+: <format> is a string and must be one of:
+
+    **text**
+    :  Print output in unstructured text suitable for a human to read.
+
+    **json**
+    :  Print output in JSON format.`,
+			},
+			cli.StringFlag{
+				Name: "roots",
+				Usage: `Root certificate(s) that will be used to verify the authenticity of a remote
+server.`,
+			},
+			cli.BoolFlag{
+				Name: "insecure",
+				Usage: `Use an insecure client to retrieve a remote peer certificate. Useful for
+debugging invalid certificates remotely.`,
+			},
+		},
+	}
+}
+
+// certificateFields are the names of the compared fields in order.
+var certificateFields = []string{
+	"version", "serial", "issuer", "subject", "not-before", "not-after",
+	"public-key", "signature-algorithm", "dns-names", "ip-addresses",
+	"email-addresses", "uris", "key-usage", "ext-key-usage",
+	"basic-constraints", "subject-key-id", "authority-key-id", "ocsp-servers",
+	"issuing-certificate-urls", "crl-distribution-points", "policies",
+	"extensions",
+}
+
+// fieldDiff contains the values of a field that are only in one of the
+// certificates.
+type fieldDiff struct {
+	Field   string   `json:"field"`
+	Removed []string `json:"removed,omitempty"`
+	Added   []string `json:"added,omitempty"`
+}
+
+func diffAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 2); err != nil {
+		return err
+	}
+
+	fields := make(map[string]bool, len(certificateFields))
+	for _, f := range certificateFields {
+		fields[f] = true
+	}
+	ignore := make(map[string]bool)
+	for _, f := range ctx.StringSlice("ignore") {
+		if !fields[f] {
+			return errs.InvalidFlagValue(ctx, "ignore", f, strings.Join(certificateFields, ", "))
+		}
+		ignore[f] = true
+	}
+	format := ctx.String("format")
+	if format != "text" && format != "json" {
+		return errs.InvalidFlagValue(ctx, "format", format, "text, json")
+	}
+
+	a, err := readDiffCertificate(ctx, ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	b, err := readDiffCertificate(ctx, ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	diffs := diffCertificates(a, b, ignore)
+	if format == "json" {
+		if diffs == nil {
+			diffs = []fieldDiff{}
+		}
+		out, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling differences")
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, d := range diffs {
+			fmt.Printf("%s:\n", d.Field)
+			for _, v := range d.Removed {
+				fmt.Printf("  - %s\n", v)
+			}
+			for _, v := range d.Added {
+				fmt.Printf("  + %s\n", v)
+			}
+		}
+	}
+
+	if len(diffs) > 0 {
+		return errs.NewExitError(errors.Errorf("the certificates have %d different field(s)", len(diffs)), 1)
+	}
+	return nil
+}
+
+func readDiffCertificate(ctx *cli.Context, name string) (*x509.Certificate, error) {
+	if _, addr, isURL := trimURLPrefix(name); isURL {
+		certs, err := getPeerCertificates(addr, ctx.String("roots"), ctx.Bool("insecure"))
+		if err != nil {
+			return nil, err
+		}
+		return certs[0], nil
+	}
+	certs, err := pemutil.ReadCertificateBundle(name)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+// diffCertificates returns the fields with different values in the given
+// certificates.
+func diffCertificates(a, b *x509.Certificate, ignore map[string]bool) []fieldDiff {
+	va, vb := certificateValues(a), certificateValues(b)
+	var diffs []fieldDiff
+	for _, f := range certificateFields {
+		if ignore[f] {
+			continue
+		}
+		d := fieldDiff{
+			Field:   f,
+			Removed: difference(va[f], vb[f]),
+			Added:   difference(vb[f], va[f]),
+		}
+		if len(d.Removed) > 0 || len(d.Added) > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+// difference returns the values in a that are not in b.
+func difference(a, b []string) []string {
+	m := make(map[string]bool, len(b))
+	for _, s := range b {
+		m[s] = true
+	}
+	var ret []string
+	for _, s := range a {
+		if !m[s] {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digital-signature"},
+	{x509.KeyUsageContentCommitment, "content-commitment"},
+	{x509.KeyUsageKeyEncipherment, "key-encipherment"},
+	{x509.KeyUsageDataEncipherment, "data-encipherment"},
+	{x509.KeyUsageKeyAgreement, "key-agreement"},
+	{x509.KeyUsageCertSign, "cert-sign"},
+	{x509.KeyUsageCRLSign, "crl-sign"},
+	{x509.KeyUsageEncipherOnly, "encipher-only"},
+	{x509.KeyUsageDecipherOnly, "decipher-only"},
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:             "any",
+	x509.ExtKeyUsageServerAuth:      "server-auth",
+	x509.ExtKeyUsageClientAuth:      "client-auth",
+	x509.ExtKeyUsageCodeSigning:     "code-signing",
+	x509.ExtKeyUsageEmailProtection: "email-protection",
+	x509.ExtKeyUsageTimeStamping:    "time-stamping",
+	x509.ExtKeyUsageOCSPSigning:     "ocsp-signing",
+}
+
+var extensionNames = map[string]string{
+	"2.5.29.14":               "Subject Key Identifier",
+	"2.5.29.15":               "Key Usage",
+	"2.5.29.17":               "Subject Alternative Name",
+	"2.5.29.19":               "Basic Constraints",
+	"2.5.29.30":               "Name Constraints",
+	"2.5.29.31":               "CRL Distribution Points",
+	"2.5.29.32":               "Certificate Policies",
+	"2.5.29.35":               "Authority Key Identifier",
+	"2.5.29.37":               "Extended Key Usage",
+	"1.3.6.1.5.5.7.1.1":       "Authority Information Access",
+	"1.3.6.1.4.1.11129.2.4.2": "Signed Certificate Timestamps",
+}
+
+// certificateValues returns the values of the compared fields of a
+// certificate.
+func certificateValues(crt *x509.Certificate) map[string][]string {
+	v := map[string][]string{
+		"version":                  {strconv.Itoa(crt.Version)},
+		"issuer":                   {crt.Issuer.String()},
+		"subject":                  {crt.Subject.String()},
+		"not-before":               {crt.NotBefore.UTC().Format(time.RFC3339)},
+		"not-after":                {crt.NotAfter.UTC().Format(time.RFC3339)},
+		"signature-algorithm":      {crt.SignatureAlgorithm.String()},
+		"dns-names":                crt.DNSNames,
+		"email-addresses":          crt.EmailAddresses,
+		"subject-key-id":           nil,
+		"authority-key-id":         nil,
+		"ocsp-servers":             crt.OCSPServer,
+		"issuing-certificate-urls": crt.IssuingCertificateURL,
+		"crl-distribution-points":  crt.CRLDistributionPoints,
+	}
+
+	if crt.SerialNumber != nil {
+		v["serial"] = []string{crt.SerialNumber.String()}
+	}
+	if len(crt.RawSubjectPublicKeyInfo) > 0 {
+		sum := sha256.Sum256(crt.RawSubjectPublicKeyInfo)
+		v["public-key"] = []string{fmt.Sprintf("%s SHA256:%s", crt.PublicKeyAlgorithm, hex.EncodeToString(sum[:]))}
+	}
+	if len(crt.SubjectKeyId) > 0 {
+		v["subject-key-id"] = []string{hex.EncodeToString(crt.SubjectKeyId)}
+	}
+	if len(crt.AuthorityKeyId) > 0 {
+		v["authority-key-id"] = []string{hex.EncodeToString(crt.AuthorityKeyId)}
+	}
+	for _, ip := range crt.IPAddresses {
+		v["ip-addresses"] = append(v["ip-addresses"], ip.String())
+	}
+	for _, u := range crt.URIs {
+		v["uris"] = append(v["uris"], u.String())
+	}
+	for _, ku := range keyUsageNames {
+		if crt.KeyUsage&ku.usage != 0 {
+			v["key-usage"] = append(v["key-usage"], ku.name)
+		}
+	}
+	for _, eku := range crt.ExtKeyUsage {
+		name, ok := extKeyUsageNames[eku]
+		if !ok {
+			name = fmt.Sprintf("unknown(%d)", eku)
+		}
+		v["ext-key-usage"] = append(v["ext-key-usage"], name)
+	}
+	for _, oid := range crt.UnknownExtKeyUsage {
+		v["ext-key-usage"] = append(v["ext-key-usage"], oid.String())
+	}
+	if crt.BasicConstraintsValid {
+		bc := fmt.Sprintf("CA:%t", crt.IsCA)
+		if crt.IsCA && (crt.MaxPathLen > 0 || crt.MaxPathLenZero) {
+			bc += fmt.Sprintf(" pathlen:%d", crt.MaxPathLen)
+		}
+		v["basic-constraints"] = []string{bc}
+	} else {
+		v["basic-constraints"] = nil
+	}
+	for _, oid := range crt.PolicyIdentifiers {
+		v["policies"] = append(v["policies"], oid.String())
+	}
+	v["extensions"] = []string{}
+	for _, ext := range crt.Extensions {
+		s := ext.Id.String()
+		if name, ok := extensionNames[s]; ok {
+			s = name + " (" + s + ")"
+		}
+		if ext.Critical {
+			s += " critical"
+		}
+		v["extensions"] = append(v["extensions"], s)
+	}
+	return v
+}
@@ -0,0 +1,58 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+)
+
+func TestDiffCertificates(t *testing.T) {
+	now := time.Date(2020, 2, 11, 18, 14, 39, 0, time.UTC)
+	a := &x509.Certificate{
+		Version:      3,
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "foo"},
+		NotBefore:    now,
+		NotAfter:     now.Add(24 * time.Hour),
+		DNSNames:     []string{"foo.example.com", "bar.example.com"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		Extensions: []pkix.Extension{
+			{Id: []int{2, 5, 29, 15}, Critical: true},
+		},
+	}
+	b := &x509.Certificate{
+		Version:      3,
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "foo"},
+		NotBefore:    now.Add(time.Hour),
+		NotAfter:     now.Add(25 * time.Hour),
+		DNSNames:     []string{"bar.example.com", "foo.example.com", "baz.example.com"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		Extensions: []pkix.Extension{
+			{Id: []int{2, 5, 29, 15}, Critical: false},
+		},
+	}
+
+	assert.Len(t, 0, diffCertificates(a, a, nil))
+	assert.Equals(t, []fieldDiff{
+		{Field: "serial", Removed: []string{"1"}, Added: []string{"2"}},
+		{Field: "not-before", Removed: []string{"2020-02-11T18:14:39Z"}, Added: []string{"2020-02-11T19:14:39Z"}},
+		{Field: "not-after", Removed: []string{"2020-02-12T18:14:39Z"}, Added: []string{"2020-02-12T19:14:39Z"}},
+		{Field: "dns-names", Added: []string{"baz.example.com"}},
+		{Field: "ext-key-usage", Removed: []string{"client-auth"}},
+		{Field: "extensions", Removed: []string{"Key Usage (2.5.29.15) critical"}, Added: []string{"Key Usage (2.5.29.15)"}},
+	}, diffCertificates(a, b, nil))
+
+	ignore := map[string]bool{"serial": true, "not-before": true, "not-after": true}
+	assert.Equals(t, []fieldDiff{
+		{Field: "dns-names", Added: []string{"baz.example.com"}},
+		{Field: "ext-key-usage", Removed: []string{"client-auth"}},
+		{Field: "extensions", Removed: []string{"Key Usage (2.5.29.15) critical"}, Added: []string{"Key Usage (2.5.29.15)"}},
+	}, diffCertificates(a, b, ignore))
+}
@@ -0,0 +1,339 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/certstore"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// defaultJKSPassword is the default password of the Java truststores.
+const defaultJKSPassword = "changeit"
+
+// systemBundles are the files with the system roots in the different Linux and
+// BSD distributions.
+var systemBundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",                // Debian/Ubuntu/Gentoo etc.
+	"/etc/pki/tls/certs/ca-bundle.crt",                  // Fedora/RHEL 6
+	"/etc/ssl/ca-bundle.pem",                            // OpenSUSE
+	"/etc/pki/tls/cacert.pem",                           // OpenELEC
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", // CentOS/RHEL 7
+	"/etc/ssl/cert.pem",                                 // Alpine, FreeBSD, OpenBSD
+	"/usr/local/share/certs/ca-root-nss.crt",            // FreeBSD
+}
+
+// macOSKeychains are the keychains with the trusted roots on macOS.
+var macOSKeychains = []string{
+	"/System/Library/Keychains/SystemRootCertificates.keychain",
+	"/Library/Keychains/System.keychain",
+}
+
+func exportTrustCommand() cli.Command {
+	return cli.Command{
+		Name:   "export-trust",
+		Action: command.ActionFunc(exportTrustAction),
+		Usage:  "export the system trust store or the step roots",
+		UsageText: `**step certificate export-trust** [**--source**=<source>] [**--format**=<format>]
+[**--out**=<path>] [**--password-file**=<file>]`,
+		Description: `**step certificate export-trust** exports the trusted root certificates of the
+system, or the root certificates used by step, in a format that can be copied
+into containers and appliances.
+
+The system roots are read from the Root/LocalMachine certificate store on
+Windows, from the system keychains on macOS, and from the CA bundle of the
+distribution on Linux and BSD. The environment variable SSL_CERT_FILE overrides
+the CA bundle.
+
+## EXAMPLES
+
+Export the system roots to a PEM bundle:
+'''
+$ step certificate export-trust --out ca-certificates.crt
+'''
+
+Export the system roots and the step roots to a Java truststore:
+'''
+$ step certificate export-trust --source system --source step \
+  --format jks --out cacerts
+'''
+
+Export the step roots as DER files in a directory:
+'''
+$ step certificate export-trust --source step --format der --out certs/
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringSliceFlag{
+				Name: "source",
+				Usage: `The <source> of the certificates. Use the flag multiple times to export the
+certificates of multiple sources. Defaults to system.
+
+: <source> is a case-sensitive string and must be one of:
+
+    **system**
+    :  The trusted roots of the operating system.
+
+    **step**
+    :  The root certificates in $STEPPATH/certs/root_ca.crt.`,
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Value: "pem",
+				Usage: `The <format> of the exported certificates.
+
+: <format> is a case-sensitive string and must be one of:
+
+    **pem**
+    :  A PEM bundle with all the certificates.
+
+    **jks**
+    :  A Java KeyStore with a trusted certificate entry per certificate. The
+    password is "changeit" unless a password flag is used.
+
+    **der**
+    :  A directory with a DER file per certificate.`,
+			},
+			cli.StringFlag{
+				Name: "out",
+				Usage: `The <path> of the exported file, or the directory with the der format. The pem
+format is printed to STDOUT by default.`,
+			},
+			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
+		}, flags.Password(flags.PasswordFile)...),
+	}
+}
+
+func exportTrustAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	format, out := ctx.String("format"), ctx.String("out")
+	switch format {
+	case "pem":
+	case "jks", "der":
+		if out == "" {
+			return errs.RequiredWithFlagValue(ctx, "format", format, "out")
+		}
+	default:
+		return errs.InvalidFlagValue(ctx, "format", format, "pem, jks, der")
+	}
+
+	sources := ctx.StringSlice("source")
+	if len(sources) == 0 {
+		sources = []string{"system"}
+	}
+	var certs []*x509.Certificate
+	for _, source := range sources {
+		var list []*x509.Certificate
+		var err error
+		switch source {
+		case "system":
+			list, err = systemRoots()
+		case "step":
+			list, err = readCertificates(pki.GetRootCAPath())
+		default:
+			return errs.InvalidFlagValue(ctx, "source", source, "system, step")
+		}
+		if err != nil {
+			return err
+		}
+		certs = appendUniqueCertificates(certs, list...)
+	}
+	if len(certs) == 0 {
+		return errors.New("no certificates found")
+	}
+
+	switch format {
+	case "pem":
+		var buf bytes.Buffer
+		for _, crt := range certs {
+			fmt.Fprintf(&buf, "# %s\n", x509util.CertificateName(crt))
+			pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+		}
+		if out == "" {
+			_, err := os.Stdout.Write(buf.Bytes())
+			return err
+		}
+		if err := utils.WriteFile(out, buf.Bytes(), 0644); err != nil {
+			return errs.FileError(err, out)
+		}
+	case "jks":
+		password, err := utils.ReadPasswordFromCLI(ctx)
+		if err != nil {
+			return err
+		}
+		if password == nil {
+			password = []byte(defaultJKSPassword)
+		}
+		b, err := encodeJKS(certs, string(password), time.Now())
+		if err != nil {
+			return err
+		}
+		if err := utils.WriteFile(out, b, 0644); err != nil {
+			return errs.FileError(err, out)
+		}
+	case "der":
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return errs.FileError(err, out)
+		}
+		for _, crt := range certs {
+			name := filepath.Join(out, certificateAlias(crt)+".der")
+			if err := utils.WriteFile(name, crt.Raw, 0644); err != nil {
+				return errs.FileError(err, name)
+			}
+		}
+	}
+
+	ui.Printf("Exported %d certificates to %s.\n", len(certs), out)
+	return nil
+}
+
+// systemRoots returns the trusted roots of the operating system.
+func systemRoots() ([]*x509.Certificate, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return certstore.Certificates(&certstore.Location{Store: "Root", Machine: true})
+	case "darwin":
+		args := append([]string{"find-certificate", "-a", "-p"}, macOSKeychains...)
+		b, err := exec.Command("security", args...).Output()
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading the system keychains")
+		}
+		return parseCertificates(b), nil
+	default:
+		bundles := systemBundles
+		if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+			bundles = []string{f}
+		}
+		for _, f := range bundles {
+			if _, err := os.Stat(f); err == nil {
+				return readCertificates(f)
+			}
+		}
+		return nil, errors.Errorf("cannot find the system roots on %s", runtime.GOOS)
+	}
+}
+
+// readCertificates returns the certificates in a PEM file.
+func readCertificates(filename string) ([]*x509.Certificate, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errs.FileError(err, filename)
+	}
+	return parseCertificates(b), nil
+}
+
+// parseCertificates returns the certificates in PEM data, the certificates
+// that cannot be parsed are skipped.
+func parseCertificates(b []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for len(b) > 0 {
+		var block *pem.Block
+		if block, b = pem.Decode(b); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if crt, err := x509util.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, crt)
+		}
+	}
+	return certs
+}
+
+func appendUniqueCertificates(certs []*x509.Certificate, list ...*x509.Certificate) []*x509.Certificate {
+	for _, crt := range list {
+		if !inCertificates(certs, crt) {
+			certs = append(certs, crt)
+		}
+	}
+	return certs
+}
+
+func inCertificates(certs []*x509.Certificate, crt *x509.Certificate) bool {
+	for _, c := range certs {
+		if bytes.Equal(c.Raw, crt.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+var aliasReplacer = regexp.MustCompile(`[^a-z0-9]+`)
+
+// certificateAlias returns the name of a certificate in a keystore or
+// directory: its name in lower case followed by the start of its fingerprint.
+func certificateAlias(crt *x509.Certificate) string {
+	name := strings.Trim(aliasReplacer.ReplaceAllString(strings.ToLower(x509util.CertificateName(crt)), "-"), "-")
+	return name + "-" + x509util.Fingerprint(crt)[:8]
+}
+
+// encodeJKS encodes the certificates in a Java KeyStore with a trusted
+// certificate entry per certificate.
+func encodeJKS(certs []*x509.Certificate, password string, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	writeUint32 := func(v uint32) { binary.Write(&buf, binary.BigEndian, v) }
+	writeUTF := func(s string) error {
+		if len(s) > 0xffff {
+			return errors.Errorf("string %s is too long", s)
+		}
+		binary.Write(&buf, binary.BigEndian, uint16(len(s)))
+		buf.WriteString(s)
+		return nil
+	}
+
+	writeUint32(0xFEEDFEED) // magic
+	writeUint32(2)          // version
+	writeUint32(uint32(len(certs)))
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+	for _, crt := range certs {
+		writeUint32(2) // trusted certificate entry
+		// Aliases are ASCII, so Java's modified UTF-8 is the same.
+		if err := writeUTF(certificateAlias(crt)); err != nil {
+			return nil, err
+		}
+		binary.Write(&buf, binary.BigEndian, timestamp)
+		if err := writeUTF("X.509"); err != nil {
+			return nil, err
+		}
+		writeUint32(uint32(len(crt.Raw)))
+		buf.Write(crt.Raw)
+	}
+
+	// The integrity check is the SHA-1 of the UTF-16 password, the string
+	// "Mighty Aphrodite", and the keystore.
+	h := sha1.New()
+	for _, c := range utf16.Encode([]rune(password)) {
+		h.Write([]byte{byte(c >> 8), byte(c)})
+	}
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(buf.Bytes())
+	buf.Write(h.Sum(nil))
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,57 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+)
+
+func TestEncodeJKS(t *testing.T) {
+	certs := []*x509.Certificate{
+		{Raw: []byte{1, 2, 3}, Subject: pkix.Name{CommonName: "Smallstep Root CA"}},
+		{Raw: []byte{4, 5}, Subject: pkix.Name{Organization: []string{"ACME, Inc."}}},
+	}
+	now := time.Unix(1577836800, 0)
+	b, err := encodeJKS(certs, "changeit", now)
+	assert.FatalError(t, err)
+
+	// Header
+	assert.Equals(t, uint32(0xFEEDFEED), binary.BigEndian.Uint32(b[0:]))
+	assert.Equals(t, uint32(2), binary.BigEndian.Uint32(b[4:]))
+	assert.Equals(t, uint32(2), binary.BigEndian.Uint32(b[8:]))
+
+	// First entry
+	r := bytes.NewReader(b[12:])
+	var tag uint32
+	var n uint16
+	var ts int64
+	assert.FatalError(t, binary.Read(r, binary.BigEndian, &tag))
+	assert.Equals(t, uint32(2), tag)
+	assert.FatalError(t, binary.Read(r, binary.BigEndian, &n))
+	alias := make([]byte, n)
+	r.Read(alias)
+	assert.Equals(t, certificateAlias(certs[0]), string(alias))
+	assert.FatalError(t, binary.Read(r, binary.BigEndian, &ts))
+	assert.Equals(t, int64(1577836800000), ts)
+
+	// Integrity check
+	body, sum := b[:len(b)-sha1.Size], b[len(b)-sha1.Size:]
+	h := sha1.New()
+	h.Write([]byte{0, 'c', 0, 'h', 0, 'a', 0, 'n', 0, 'g', 0, 'e', 0, 'i', 0, 't'})
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(body)
+	assert.Equals(t, h.Sum(nil), sum)
+}
+
+func TestCertificateAlias(t *testing.T) {
+	crt := &x509.Certificate{Raw: []byte{1, 2, 3}, Subject: pkix.Name{CommonName: "Smallstep Root CA"}}
+	assert.Equals(t, "smallstep-root-ca-039058c6", certificateAlias(crt))
+	crt = &x509.Certificate{Raw: []byte{1, 2, 3}, Subject: pkix.Name{Organization: []string{"ACME, Inc."}}}
+	assert.Equals(t, "o-acme-inc-039058c6", certificateAlias(crt))
+}
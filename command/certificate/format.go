@@ -59,6 +59,9 @@ $ step certificate format foo.pem --out foo.der
 				Usage: `Path to write the reformatted result.`,
 			},
 			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
 		},
 	}
 }
@@ -10,8 +10,10 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certinfo"
+	"github.com/smallstep/cli/crypto/codesign"
 	"github.com/smallstep/cli/errs"
 	stepx509 "github.com/smallstep/cli/pkg/x509"
+	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	zx509 "github.com/smallstep/zcrypto/x509"
 	"github.com/urfave/cli"
@@ -30,14 +32,23 @@ STDERR instead of STDOUT unless. This is an intentional barrier to accidental
 misuse: scripts should never rely on the contents of an unvalidated certificate.
 For scripting purposes, use **step certificate verify**.
 
-If crt_file contains multiple certificates (i.e., it is a certificate "bundle")
-the first certificate in the bundle will be output. Pass the --bundle option to
-print all certificates in the order in which they appear in the bundle.
+If crt_file contains multiple certificates (i.e., it is a certificate "bundle"),
+or a remote server sends a chain, the text output prints all the certificates
+in the order in which they appear. Each one is preceded by its position in the
+chain, its role (leaf, intermediate or root), and if its signature is valid
+using the key of the next certificate, or its own key for roots. The json output
+prints only the first certificate unless the --bundle option is used.
+
+If crt_file is a signed executable, a Windows PE file with an Authenticode
+signature or a macOS Mach-O file, the command prints the certificate chain of
+each signature embedded in it and the chain of its timestamp, if any, and when
+it was timestamped. The signatures are not verified, use the tools of each
+platform to verify them.
 
 ## POSITIONAL ARGUMENTS
 
 <crt_file>
-:  Path to a certificate or certificate signing request (CSR) to inspect. A hyphen ("-") indicates STDIN as <crt_file>.
+:  Path to a certificate, a certificate signing request (CSR), or a signed PE or Mach-O executable to inspect. A hyphen ("-") indicates STDIN as <crt_file>.
 
 ## EXIT CODES
 
@@ -54,7 +65,13 @@ $ step certificate inspect ./certificate.crt
 Inspect a local certificate bundle (default to text format):
 
 '''
-$ step certificate inspect ./certificate-bundle.crt --bundle
+$ step certificate inspect ./certificate-bundle.crt
+Certificate 1 of 2 (leaf): signed by certificate 2, signature valid
+Certificate:
+...
+Certificate 2 of 2 (intermediate): issued by 'Smallstep Root CA', not in the bundle
+Certificate:
+...
 '''
 
 Inspect a local certificate in json format:
@@ -115,6 +132,24 @@ $ step certificate inspect https://google.com --format json \
 --roots "./path/to/root/certificates/" --bundle
 '''
 
+Inspect the certificate chain and timestamp of a signed Windows executable:
+
+'''
+$ step certificate inspect --short release.exe
+Signature 1 of 1 (PE): signed by 'Example Inc', timestamped at 2021-04-20T02:07:55Z by 'Example TSA'
+Certificate 1 of 2 (leaf): signed by certificate 2, signature valid
+...
+Timestamp of signature 1 of 1:
+Certificate 1 of 2 (leaf): signed by certificate 2, signature valid
+...
+'''
+
+Inspect the signatures of a macOS universal binary in json format:
+
+'''
+$ step certificate inspect ./bin/step --format json
+'''
+
 Inspect a local CSR in text format (default):
 
 '''
@@ -216,6 +251,10 @@ func inspectAction(ctx *cli.Context) error {
 		if err != nil {
 			return errs.FileError(err, crtFile)
 		}
+		// Executables print the chains of their embedded signatures.
+		if codesign.IsExecutable(crtBytes) {
+			return inspectSignedFile(ctx, crtFile, crtBytes)
+		}
 		if bytes.HasPrefix(crtBytes, []byte("-----BEGIN ")) {
 			for len(crtBytes) > 0 {
 				block, crtBytes = pem.Decode(crtBytes)
@@ -236,9 +275,14 @@ func inspectAction(ctx *cli.Context) error {
 		}
 	}
 
-	// Keep the first one if !bundle
+	// Keep the first one if !bundle, except in the text output of
+	// certificates that prints the whole chain.
 	if !bundle {
-		blocks = []*pem.Block{blocks[0]}
+		if format == "text" && blocks[0].Type == "CERTIFICATE" {
+			blocks = certificateBlocks(blocks)
+		} else {
+			blocks = []*pem.Block{blocks[0]}
+		}
 	}
 
 	switch blocks[0].Type {
@@ -256,11 +300,23 @@ func inspectCertificates(ctx *cli.Context, blocks []*pem.Block) error {
 	switch format {
 	case "text":
 		var text string
-		for _, block := range blocks {
+		crts := make([]*stepx509.Certificate, len(blocks))
+		progress := ui.NewProgress("Parsing certificates", int64(len(blocks)), ui.WithQuiet(len(blocks) == 1))
+		for i, block := range blocks {
 			crt, err := stepx509.ParseCertificate(block.Bytes)
 			if err != nil {
+				progress.Done()
 				return errors.WithStack(err)
 			}
+			crts[i] = crt
+			progress.Add(1)
+		}
+		progress.Done()
+		for i, crt := range crts {
+			var err error
+			if len(crts) > 1 {
+				fmt.Println(chainAnnotation(crts, i))
+			}
 			if short {
 				if text, err = certinfo.CertificateShortText(crt); err != nil {
 					return err
@@ -270,7 +326,7 @@ func inspectCertificates(ctx *cli.Context, blocks []*pem.Block) error {
 					return err
 				}
 			}
-			fmt.Print(text)
+			fmt.Print(ui.EmphasizeLabels(os.Stdout, text))
 		}
 		return nil
 	case "json":
@@ -324,7 +380,7 @@ func inspectCertificateRequest(ctx *cli.Context, block *pem.Block) error {
 				return err
 			}
 		}
-		fmt.Print(text)
+		fmt.Print(ui.EmphasizeLabels(os.Stdout, text))
 		return nil
 	case "json":
 		zcsr, err := zx509.ParseCertificateRequest(block.Bytes)
@@ -356,3 +412,61 @@ func derToPemBlock(b []byte) *pem.Block {
 	}
 	return nil
 }
+
+// certificateBlocks returns the blocks of type CERTIFICATE.
+func certificateBlocks(blocks []*pem.Block) []*pem.Block {
+	var ret []*pem.Block
+	for _, block := range blocks {
+		if block.Type == "CERTIFICATE" {
+			ret = append(ret, block)
+		}
+	}
+	return ret
+}
+
+// chainAnnotation describes the role of the certificate in the position i of a
+// chain, and its relationship with the next certificate.
+func chainAnnotation(crts []*stepx509.Certificate, i int) string {
+	crt := crts[i]
+	prefix := fmt.Sprintf("Certificate %d of %d", i+1, len(crts))
+
+	selfIssued := bytes.Equal(crt.RawSubject, crt.RawIssuer)
+	if selfIssued && checkCertificateSignature(crt, crt) == nil {
+		return prefix + " (root): self-signed, signature valid"
+	}
+
+	role := "leaf"
+	if crt.IsCA {
+		role = "intermediate"
+	}
+	prefix = fmt.Sprintf("%s (%s)", prefix, role)
+
+	if i == len(crts)-1 {
+		if selfIssued {
+			return prefix + ": self-issued, signature INVALID"
+		}
+		return fmt.Sprintf("%s: issued by '%s', not in the bundle", prefix, issuerCommonName(crt))
+	}
+	parent := crts[i+1]
+	if !bytes.Equal(crt.RawIssuer, parent.RawSubject) {
+		return fmt.Sprintf("%s: not issued by certificate %d, the issuer is '%s'", prefix, i+2, issuerCommonName(crt))
+	}
+	if err := checkCertificateSignature(crt, parent); err != nil {
+		return fmt.Sprintf("%s: signed by certificate %d, signature INVALID: %v", prefix, i+2, err)
+	}
+	return fmt.Sprintf("%s: signed by certificate %d, signature valid", prefix, i+2)
+}
+
+// checkCertificateSignature verifies the signature of crt using the key of
+// parent. Unlike CheckSignatureFrom it does not check the basic constraints of
+// parent.
+func checkCertificateSignature(crt, parent *stepx509.Certificate) error {
+	return parent.CheckSignature(crt.SignatureAlgorithm, crt.RawTBSCertificate, crt.Signature)
+}
+
+func issuerCommonName(crt *stepx509.Certificate) string {
+	if crt.Issuer.CommonName != "" {
+		return crt.Issuer.CommonName
+	}
+	return crt.Issuer.String()
+}
@@ -1,6 +1,7 @@
 package certificate
 
 import (
+	"crypto/x509"
 	"fmt"
 	"strings"
 
@@ -9,6 +10,9 @@ import (
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/crypto/pemutil"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/nssdb"
+	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/truststore"
 	"github.com/urfave/cli"
 )
@@ -20,11 +24,14 @@ func installCommand() cli.Command {
 		Usage:  "install a root certificate in the system truststore",
 		UsageText: `**step certificate install** <crt-file>
 		[**--prefix**=<name>] [**--all**]
-		[**--java**] [**--firefox**] [**--no-system**]`,
+		[**--java**] [**--firefox**] [**--no-system**] [**--dry-run**]`,
 		Description: `**step certificate install** installs a root certificate in the system
 truststore.
 
-Java and Firefox truststores are also supported via the respective flags.
+Java and NSS truststores are also supported via the respective flags. The NSS
+databases of Firefox and Chromium are detected in the home directory of the
+current user, including the snap and flatpak profile locations, and updated
+without the certutil tool.
 
 ## POSITIONAL ARGUMENTS
 
@@ -56,6 +63,11 @@ $ step certificate install --java root-ca.pem
 Install a certificate in Firefox, Java, but not in the system trustore:
 '''
 $ step certificate install --firefox --java --no-system root-ca.pem
+'''
+
+Print the truststores that would be modified:
+'''
+$ step certificate install --all --dry-run root-ca.pem
 '''`,
 		Flags: []cli.Flag{
 			cli.StringFlag{
@@ -68,8 +80,8 @@ certificate common name.`,
 				Usage: "install on the Java key store",
 			},
 			cli.BoolFlag{
-				Name:  "firefox",
-				Usage: "install on the Firefox NSS security database",
+				Name:  "firefox, nss",
+				Usage: "install on the NSS security databases of Firefox and Chromium",
 			},
 			cli.BoolFlag{
 				Name:  "no-system",
@@ -77,8 +89,9 @@ certificate common name.`,
 			},
 			cli.BoolFlag{
 				Name:  "all",
-				Usage: "install on the system, Firefox and Java truststores",
+				Usage: "install on the system, NSS and Java truststores",
 			},
+			flags.DryRun,
 		},
 	}
 }
@@ -94,7 +107,10 @@ func uninstallCommand() cli.Command {
 		Description: `**step certificate install** uninstalls a root certificate from the system
 truststore.
 
-Java and Firefox truststores are also supported via the respective flags.
+Java and NSS truststores are also supported via the respective flags. The NSS
+databases of Firefox and Chromium are detected in the home directory of the
+current user, including the snap and flatpak profile locations, and updated
+without the certutil tool.
 
 ## POSITIONAL ARGUMENTS
 
@@ -138,8 +154,8 @@ certificate common name.`,
 				Usage: "uninstall from the Java key store",
 			},
 			cli.BoolFlag{
-				Name:  "firefox",
-				Usage: "uninstall from the Firefox NSS security database",
+				Name:  "firefox, nss",
+				Usage: "uninstall from the NSS security databases of Firefox and Chromium",
 			},
 			cli.BoolFlag{
 				Name:  "no-system",
@@ -147,7 +163,7 @@ certificate common name.`,
 			},
 			cli.BoolFlag{
 				Name:  "all",
-				Usage: "uninstall from the system, Firefox and Java truststores",
+				Usage: "uninstall from the system, NSS and Java truststores",
 			},
 		},
 	}
@@ -164,6 +180,10 @@ func installAction(ctx *cli.Context) error {
 		return err
 	}
 
+	if ctx.Bool("dry-run") {
+		return installDryRun(ctx, filename)
+	}
+
 	if err := truststore.InstallFile(filename, opts...); err != nil {
 		switch err := err.(type) {
 		case *truststore.CmdError:
@@ -172,6 +192,9 @@ func installAction(ctx *cli.Context) error {
 			return errors.Wrapf(err, "failed to install %s", filename)
 		}
 	}
+	if err := installNSS(ctx, filename); err != nil {
+		return err
+	}
 
 	fmt.Printf("Certificate %s has been installed.\n", filename)
 	// Print certificate info (ignore errors)
@@ -203,6 +226,9 @@ func uninstallAction(ctx *cli.Context) error {
 			return errors.Wrapf(err, "failed to uninstall %s", filename)
 		}
 	}
+	if err := uninstallNSS(ctx, filename); err != nil {
+		return err
+	}
 
 	fmt.Printf("Certificate %s has been removed.\n", filename)
 	// Print certificate info (ignore errors)
@@ -215,6 +241,32 @@ func uninstallAction(ctx *cli.Context) error {
 	return nil
 }
 
+// installDryRun prints the truststores that would be modified by step
+// certificate install.
+func installDryRun(ctx *cli.Context, filename string) error {
+	cert, err := pemutil.ReadCertificate(filename)
+	if err != nil {
+		return err
+	}
+	label := truststorePrefix(ctx, cert) + cert.SerialNumber.String()
+	if !ctx.Bool("no-system") {
+		command.DryRunf("Would install %s in the system truststore", filename)
+	}
+	if ctx.Bool("all") || ctx.Bool("java") {
+		command.DryRunf("Would install %s in the Java key store", filename)
+	}
+	if ctx.Bool("all") || ctx.Bool("firefox") {
+		dirs := nssdb.Profiles()
+		if len(dirs) == 0 {
+			command.DryRunf("Would not install %s in any NSS database, none were found", filename)
+		}
+		for _, dir := range dirs {
+			command.DryRunf("Would install %s in the NSS database %s as %q", filename, dir, label)
+		}
+	}
+	return nil
+}
+
 func getTruststoreOptions(ctx *cli.Context) ([]truststore.Option, error) {
 	cert, err := pemutil.ReadCertificate(ctx.Args().Get(0))
 	if err != nil {
@@ -225,31 +277,94 @@ func getTruststoreOptions(ctx *cli.Context) ([]truststore.Option, error) {
 		return nil, errors.Errorf("certificate %s is not a root CA", ctx.Args().Get(0))
 	}
 
-	prefix := ctx.String("prefix")
-	if prefix == "" {
-		if len(cert.Subject.CommonName) > 0 {
-			prefix = cert.Subject.CommonName + " "
-		} else {
-			prefix = "Smallstep Development CA "
-		}
-	}
+	return truststoreOptions(ctx, cert), nil
+}
 
+// truststoreOptions returns the truststore options for the given root
+// certificate using the flags prefix, java, no-system and all. The NSS
+// databases are managed with installNSS and uninstallNSS.
+func truststoreOptions(ctx *cli.Context, cert *x509.Certificate) []truststore.Option {
 	opts := []truststore.Option{
-		truststore.WithPrefix(prefix),
+		truststore.WithPrefix(truststorePrefix(ctx, cert)),
 	}
 
-	if ctx.Bool("all") {
-		opts = append(opts, truststore.WithJava(), truststore.WithFirefox())
-	} else {
-		if ctx.Bool("java") {
-			opts = append(opts, truststore.WithJava())
-		}
-		if ctx.Bool("firefox") {
-			opts = append(opts, truststore.WithFirefox())
-		}
+	if ctx.Bool("all") || ctx.Bool("java") {
+		opts = append(opts, truststore.WithJava())
 	}
 	if ctx.Bool("no-system") {
 		opts = append(opts, truststore.WithNoSystem())
 	}
-	return opts, nil
+	return opts
+}
+
+// truststorePrefix returns the prefix used to name the certificate in the
+// truststores.
+func truststorePrefix(ctx *cli.Context, cert *x509.Certificate) string {
+	if prefix := ctx.String("prefix"); prefix != "" {
+		return prefix
+	}
+	if len(cert.Subject.CommonName) > 0 {
+		return cert.Subject.CommonName + " "
+	}
+	return "Smallstep Development CA "
+}
+
+// installNSS installs the root certificate in the NSS databases of the
+// current user if the flags firefox or all are set.
+func installNSS(ctx *cli.Context, filename string) error {
+	if !ctx.Bool("all") && !ctx.Bool("firefox") {
+		return nil
+	}
+	cert, err := pemutil.ReadCertificate(filename)
+	if err != nil {
+		return err
+	}
+	label := truststorePrefix(ctx, cert) + cert.SerialNumber.String()
+	return forEachNSSDB(func(db *nssdb.DB) error {
+		if err := db.Install(cert, label); err != nil {
+			return errors.Wrapf(err, "failed to install %s in %s", filename, db.Dir())
+		}
+		fmt.Printf("Certificate %s has been installed in %s.\n", filename, db.Dir())
+		return nil
+	})
+}
+
+// uninstallNSS removes the root certificate from the NSS databases of the
+// current user if the flags firefox or all are set.
+func uninstallNSS(ctx *cli.Context, filename string) error {
+	if !ctx.Bool("all") && !ctx.Bool("firefox") {
+		return nil
+	}
+	cert, err := pemutil.ReadCertificate(filename)
+	if err != nil {
+		return err
+	}
+	return forEachNSSDB(func(db *nssdb.DB) error {
+		if err := db.Uninstall(cert); err != nil {
+			return errors.Wrapf(err, "failed to uninstall %s from %s", filename, db.Dir())
+		}
+		fmt.Printf("Certificate %s has been removed from %s.\n", filename, db.Dir())
+		return nil
+	})
+}
+
+func forEachNSSDB(fn func(db *nssdb.DB) error) error {
+	dirs := nssdb.Profiles()
+	if len(dirs) == 0 {
+		ui.Printf("No NSS databases found.\n")
+		return nil
+	}
+	for _, dir := range dirs {
+		db, err := nssdb.Open(dir)
+		if err != nil {
+			ui.Warnf("%v", err)
+			continue
+		}
+		err = fn(db)
+		db.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
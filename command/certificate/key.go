@@ -53,6 +53,9 @@ $ step certificate key certificate.csr --out key.pem
 				Usage: "The destination <file> of the public key.",
 			},
 			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
 		},
 	}
 }
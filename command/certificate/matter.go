@@ -0,0 +1,449 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var matterVIDFlag = cli.StringFlag{
+	Name: "matter-vid",
+	Usage: `The Matter vendor <id> of a **matter-paa**, **matter-pai** or **matter-dac**
+certificate, a 16-bit hexadecimal number, e.g. FFF1. It defaults to the vendor
+id of the issuer.`,
+}
+
+var matterPIDFlag = cli.StringFlag{
+	Name: "matter-pid",
+	Usage: `The Matter product <id> of a **matter-pai** or **matter-dac** certificate, a
+16-bit hexadecimal number, e.g. 8000. It defaults to the product id of the
+issuer.`,
+}
+
+// isMatterProfile returns true if the profile is one of the Matter device
+// attestation profiles.
+func isMatterProfile(profile string) bool {
+	return strings.HasPrefix(profile, "matter-")
+}
+
+// matterIDsFromFlags returns the Matter vendor and product ids in the
+// --matter-vid and --matter-pid flags, or the ones in the issuer if the flags
+// are not set.
+func matterIDsFromFlags(ctx *cli.Context, iss *x509.Certificate) (vid, pid uint16, err error) {
+	if iss != nil {
+		if vid, pid, err = x509util.MatterIDs(iss); err != nil {
+			return 0, 0, errors.Wrap(err, "error reading the Matter ids of the issuer")
+		}
+	}
+	if s := ctx.String("matter-vid"); s != "" {
+		if vid, err = x509util.ParseMatterID(s); err != nil {
+			return 0, 0, errs.InvalidFlagValue(ctx, "matter-vid", s, "")
+		}
+	}
+	if s := ctx.String("matter-pid"); s != "" {
+		if pid, err = x509util.ParseMatterID(s); err != nil {
+			return 0, 0, errs.InvalidFlagValue(ctx, "matter-pid", s, "")
+		}
+	}
+	return vid, pid, nil
+}
+
+func matterCommand() cli.Command {
+	return cli.Command{
+		Name:      "matter",
+		Usage:     "verify and issue Matter device attestation certificates",
+		UsageText: "**step certificate matter** <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step certificate matter** command group provides facilities to work with
+the device attestation certificates of Matter devices.
+
+A Matter device attestation chain has three certificates: the Product
+Attestation Authority (PAA), a self-signed root, the Product Attestation
+Intermediate (PAI) of a vendor, and the Device Attestation Certificate (DAC) of
+each device. The certificates use EC P-256 keys and ECDSA with SHA-256
+signatures, and encode the vendor id (VID) and product id (PID) in their
+subjects. Use the **matter-paa**, **matter-pai** and **matter-dac** profiles of
+**step certificate create** to create each of them.
+
+## EXAMPLES
+
+Verify the attestation chain of a device:
+'''
+$ step certificate matter verify dac.crt --pai pai.crt --paa paa.crt
+'''
+
+Issue the DACs of the devices in a manifest:
+'''
+$ step certificate matter batch devices.yaml --ca pai.crt --ca-key pai.key
+'''`,
+		Subcommands: cli.Commands{
+			matterVerifyCommand(),
+			matterBatchCommand(),
+		},
+	}
+}
+
+func matterVerifyCommand() cli.Command {
+	return cli.Command{
+		Name:   "verify",
+		Action: command.ActionFunc(matterVerifyAction),
+		Usage:  "verify a Matter device attestation chain",
+		UsageText: `**step certificate matter verify** <dac_file>
+**--pai**=<file> **--paa**=<file>`,
+		Description: `**step certificate matter verify** verifies that a Device Attestation
+Certificate (DAC), its PAI and a PAA are a valid Matter device attestation
+chain, and prints the vendor and product ids of the device.
+
+Besides the signatures and the validity of the chain, it checks the encoding of
+the keys, signatures and extensions of each certificate, and that the vendor
+and product ids of the DAC match the ones of the PAI and PAA.
+
+## POSITIONAL ARGUMENTS
+
+<dac_file>
+:  The path to the DAC to verify.
+
+## EXIT CODES
+
+This command returns 0 on success and \>0 if any error occurs.
+
+## EXAMPLES
+
+Verify the attestation chain of a device:
+'''
+$ step certificate matter verify dac.crt --pai pai.crt --paa paa.crt
+Vendor ID: FFF1
+Product ID: 8000
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "pai",
+				Usage: `The path to the PAI <file> that signed the DAC.`,
+			},
+			cli.StringFlag{
+				Name:  "paa",
+				Usage: `The path to the PAA <file> that signed the PAI.`,
+			},
+		},
+	}
+}
+
+func matterVerifyAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+	for _, name := range []string{"pai", "paa"} {
+		if ctx.String(name) == "" {
+			return errs.RequiredFlag(ctx, name)
+		}
+	}
+
+	dac, err := pemutil.ReadCertificate(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	pai, err := pemutil.ReadCertificate(ctx.String("pai"))
+	if err != nil {
+		return err
+	}
+	paa, err := pemutil.ReadCertificate(ctx.String("paa"))
+	if err != nil {
+		return err
+	}
+	if err := x509util.VerifyMatterChain(dac, pai, paa, time.Now()); err != nil {
+		return errors.Wrapf(err, "failed to verify %s", ctx.Args().First())
+	}
+
+	vid, pid, err := x509util.MatterIDs(dac)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Vendor ID: %04X\n", vid)
+	fmt.Printf("Product ID: %04X\n", pid)
+	return nil
+}
+
+// matterBatchEntry is an entry in the manifest used by step certificate matter
+// batch.
+type matterBatchEntry struct {
+	Subject string `json:"subject" yaml:"subject"`
+	PID     string `json:"pid" yaml:"pid"`
+	CSR     string `json:"csr" yaml:"csr"`
+	Crt     string `json:"crt" yaml:"crt"`
+	Key     string `json:"key" yaml:"key"`
+}
+
+func matterBatchCommand() cli.Command {
+	return cli.Command{
+		Name:   "batch",
+		Action: command.ActionFunc(matterBatchAction),
+		Usage:  "issue the Matter DACs of the devices in a manifest",
+		UsageText: `**step certificate matter batch** <manifest_file>
+**--ca**=<file> **--ca-key**=<file> [**--paa**=<file>] [**--matter-pid**=<id>]
+[**--not-before**=<time|duration>] [**--not-after**=<time|duration>]`,
+		Description: `**step certificate matter batch** issues the Device Attestation
+Certificates (DAC) of the devices in a manifest, signed by a PAI.
+
+The manifest is a YAML or JSON file, depending on its extension, with a list of
+entries with the following properties:
+
+**subject**
+:  The common name of the DAC. Required.
+
+**pid**
+:  The Matter product id of the device. It defaults to the flag
+**--matter-pid** or to the product id of the PAI.
+
+**csr**
+:  The path to a certificate signing request of the device. If it is set the
+DAC uses the key of the request, and no key is written. The request must use an
+EC P-256 key.
+
+**crt**
+:  The path to write the DAC to. It defaults to <subject>.crt in the directory
+of the manifest.
+
+**key**
+:  The path to write the new private key to, if there is no **csr**. It
+defaults to <subject>.key in the directory of the manifest.
+
+The vendor id of the DACs is the one of the PAI. The private keys are written
+without encryption, so they can be provisioned in the devices.
+
+An entry that fails does not stop the issuance of the rest of them, but the
+command fails if any of them failed.
+
+## POSITIONAL ARGUMENTS
+
+<manifest_file>
+:  The path to the manifest.
+
+## EXIT CODES
+
+This command returns 0 on success and \>0 if any error occurs.
+
+## EXAMPLES
+
+Issue the DACs of the devices in a manifest and verify them with the PAA:
+'''
+$ cat devices.yaml
+- subject: ACME Light 0001
+  pid: "8000"
+- subject: ACME Switch 0001
+  pid: "8001"
+  csr: switch-0001.csr
+$ step certificate matter batch devices.yaml --ca pai.crt --ca-key pai.key \
+  --paa paa.crt --not-after 9999-12-31T23:59:59Z
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "ca",
+				Usage: `The path to the PAI certificate <file> that signs the DACs.`,
+			},
+			cli.StringFlag{
+				Name:  "ca-key",
+				Usage: `The path to the private key <file> of the PAI.`,
+			},
+			cli.StringFlag{
+				Name:  "paa",
+				Usage: `The path to the PAA <file> used to verify each DAC after its issuance.`,
+			},
+			cli.StringFlag{
+				Name: "matter-pid",
+				Usage: `The Matter product <id> of the entries without a **pid**, a 16-bit
+hexadecimal number, e.g. 8000.`,
+			},
+			cli.StringFlag{
+				Name: "not-before",
+				Usage: `The <time|duration> set in the NotBefore property of the DACs. If a
+<time> is used it is expected to be in RFC 3339 format. If a <duration> is
+used, it is a sequence of decimal numbers, each with optional fraction and a
+unit suffix, such as "300ms", "-1.5h" or "2h45m". Valid time units are "ns",
+"us" (or "µs"), "ms", "s", "m", "h".`,
+			},
+			cli.StringFlag{
+				Name: "not-after",
+				Usage: `The <time|duration> set in the NotAfter property of the DACs. If a
+<time> is used it is expected to be in RFC 3339 format. If a <duration> is
+used, it is a sequence of decimal numbers, each with optional fraction and a
+unit suffix, such as "300ms", "-1.5h" or "2h45m". Valid time units are "ns",
+"us" (or "µs"), "ms", "s", "m", "h". Use 9999-12-31T23:59:59Z for DACs without
+a well-defined expiration.`,
+			},
+			flags.Force,
+		},
+	}
+}
+
+func matterBatchAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+	for _, name := range []string{"ca", "ca-key"} {
+		if ctx.String(name) == "" {
+			return errs.RequiredFlag(ctx, name)
+		}
+	}
+
+	notBefore, ok := flags.ParseTimeOrDuration(ctx.String("not-before"))
+	if !ok {
+		return errs.InvalidFlagValue(ctx, "not-before", ctx.String("not-before"), "")
+	}
+	notAfter, ok := flags.ParseTimeOrDuration(ctx.String("not-after"))
+	if !ok {
+		return errs.InvalidFlagValue(ctx, "not-after", ctx.String("not-after"), "")
+	}
+	if !notAfter.IsZero() && !notBefore.IsZero() && notBefore.After(notAfter) {
+		return errs.IncompatibleFlagValues(ctx, "not-before", ctx.String("not-before"), "not-after", ctx.String("not-after"))
+	}
+
+	pai, err := x509util.LoadIdentityFromDisk(ctx.String("ca"), ctx.String("ca-key"))
+	if err != nil {
+		return err
+	}
+	vid, pid, err := matterIDsFromFlags(ctx, pai.Crt)
+	if err != nil {
+		return err
+	}
+	if vid == 0 {
+		return errors.Errorf("%s does not have a Matter vendor id", ctx.String("ca"))
+	}
+	var paa *x509.Certificate
+	if ctx.String("paa") != "" {
+		if paa, err = pemutil.ReadCertificate(ctx.String("paa")); err != nil {
+			return err
+		}
+	}
+
+	filename := ctx.Args().First()
+	entries, err := readMatterManifest(filename)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for i := range entries {
+		e := &entries[i]
+		if err := e.issue(pai, paa, vid, pid, notBefore, notAfter); err != nil {
+			failed++
+			ui.Printf("%s: %v\n", e.Subject, err)
+			continue
+		}
+		ui.Printf("%s: your certificate has been saved in %s.\n", e.Subject, e.Crt)
+	}
+	if failed > 0 {
+		return errors.Errorf("failed to issue %d of %d certificates", failed, len(entries))
+	}
+	return nil
+}
+
+// readMatterManifest reads the entries in a YAML or JSON manifest and sets the
+// default paths of their files.
+func readMatterManifest(filename string) ([]matterBatchEntry, error) {
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var entries []matterBatchEntry
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(b, &entries)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&entries)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", filename)
+	}
+	if len(entries) == 0 {
+		return nil, errors.Errorf("error reading %s: the manifest does not have any entries", filename)
+	}
+
+	dir := filepath.Dir(filename)
+	for i := range entries {
+		e := &entries[i]
+		if e.Subject == "" {
+			return nil, errors.Errorf("error reading %s: entry %d does not have a subject", filename, i+1)
+		}
+		if e.Crt == "" {
+			e.Crt = filepath.Join(dir, e.Subject+".crt")
+		}
+		if e.Key == "" && e.CSR == "" {
+			e.Key = filepath.Join(dir, e.Subject+".key")
+		}
+	}
+	return entries, nil
+}
+
+// issue creates the DAC of the entry, and its key if the entry does not have a
+// CSR, and writes them to disk.
+func (e *matterBatchEntry) issue(pai *x509util.Identity, paa *x509.Certificate, vid, pid uint16, notBefore, notAfter time.Time) error {
+	var err error
+	if e.PID != "" {
+		if pid, err = x509util.ParseMatterID(e.PID); err != nil {
+			return err
+		}
+	}
+
+	opts := []x509util.WithOption{
+		x509util.WithNotBeforeAfterDuration(notBefore, notAfter, 0),
+	}
+	if e.CSR != "" {
+		b, err := utils.ReadFile(e.CSR)
+		if err != nil {
+			return err
+		}
+		csr, err := x509util.LoadCSRFromBytes(b)
+		if err != nil {
+			return err
+		}
+		if err := x509util.CheckCertificateRequestSignature(csr); err != nil {
+			return errors.Wrapf(err, "invalid signature in %s", e.CSR)
+		}
+		opts = append(opts, x509util.WithPublicKey(csr.PublicKey))
+	}
+
+	profile, err := x509util.NewMatterDACProfile(e.Subject, vid, pid, pai.Crt, pai.Key, opts...)
+	if err != nil {
+		return err
+	}
+	der, err := profile.CreateCertificate()
+	if err != nil {
+		return err
+	}
+	if paa != nil {
+		dac, err := x509.ParseCertificate(der)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := x509util.VerifyMatterChain(dac, pai.Crt, paa, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	if e.CSR == "" {
+		if _, err := pemutil.Serialize(profile.SubjectPrivateKey(), pemutil.ToFile(e.Key, 0600)); err != nil {
+			return err
+		}
+	}
+	return utils.WriteFile(e.Crt, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: der,
+	}), 0600)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
 )
 
 var urlPrefixes = []string{"https://", "tcp://", "tls://"}
@@ -29,7 +30,7 @@ func getPeerCertificates(addr, roots string, insecure bool) ([]*x509.Certificate
 	if roots != "" {
 		rootCAs, err = x509util.ReadCertPool(roots)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failure to load root certificate pool from input path '%s'", roots)
+			return nil, errs.IO(errors.Wrapf(err, "failure to load root certificate pool from input path '%s'", roots))
 		}
 	}
 	if !strings.Contains(addr, ":") {
@@ -41,7 +42,7 @@ func getPeerCertificates(addr, roots string, insecure bool) ([]*x509.Certificate
 	}
 	conn, err := tls.Dial("tcp", addr, tlsConfig)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to connect")
+		return nil, errs.Network(errors.Wrapf(err, "failed to connect"))
 	}
 	conn.Close()
 	return conn.ConnectionState().PeerCertificates, nil
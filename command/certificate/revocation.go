@@ -0,0 +1,171 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/httpcache"
+	"golang.org/x/crypto/ocsp"
+)
+
+// maxAIADepth is the maximum number of intermediates downloaded using the
+// Authority Information Access extension.
+const maxAIADepth = 5
+
+// fetchIntermediates downloads the issuers of the given certificate using the
+// CA Issuers URLs in the Authority Information Access extension, adds them to
+// the pool of intermediates, and returns them.
+func fetchIntermediates(client *httpcache.Client, cert *x509.Certificate, pool *x509.CertPool) ([]*x509.Certificate, error) {
+	var issuers []*x509.Certificate
+	for i := 0; i < maxAIADepth && len(cert.IssuingCertificateURL) > 0; i++ {
+		issuer, err := fetchIssuer(client, cert.IssuingCertificateURL)
+		if err != nil {
+			return issuers, err
+		}
+		pool.AddCert(issuer)
+		issuers = append(issuers, issuer)
+		// Stop on self-signed certificates.
+		if bytes.Equal(issuer.RawIssuer, issuer.RawSubject) {
+			break
+		}
+		cert = issuer
+	}
+	return issuers, nil
+}
+
+// fetchIssuer downloads concurrently the given URLs and returns the first
+// certificate that can be parsed. Certificates can be in DER or PEM format.
+func fetchIssuer(client *httpcache.Client, urls []string) (*x509.Certificate, error) {
+	var lastErr error
+	bodies, fetchErrs := client.GetAll(urls)
+	for i, b := range bodies {
+		if fetchErrs[i] != nil {
+			lastErr = errs.Network(fetchErrs[i])
+			continue
+		}
+		if block, _ := pem.Decode(b); block != nil && block.Type == "CERTIFICATE" {
+			b = block.Bytes
+		}
+		cert, err := x509.ParseCertificate(b)
+		if err != nil {
+			lastErr = errs.Crypto(errors.Wrapf(err, "error parsing certificate from %s", urls[i]))
+			continue
+		}
+		return cert, nil
+	}
+	return nil, lastErr
+}
+
+// checkRevocation checks concurrently the revocation status of the
+// certificates in the given chain, except the root, using OCSP and CRLs. The
+// leaf must include an OCSP server or a CRL distribution point for the
+// requested methods, intermediates without them are skipped.
+func checkRevocation(client *httpcache.Client, chain []*x509.Certificate, useOCSP, useCRL bool) error {
+	if len(chain) < 2 {
+		return nil
+	}
+	leaf := chain[0]
+	if useOCSP && len(leaf.OCSPServer) == 0 {
+		return errs.Policy(errors.New("failed to verify certificate: the certificate does not have an OCSP server"))
+	}
+	if useCRL && len(leaf.CRLDistributionPoints) == 0 {
+		return errs.Policy(errors.New("failed to verify certificate: the certificate does not have a CRL distribution point"))
+	}
+
+	var wg sync.WaitGroup
+	results := make([]error, 2*(len(chain)-1))
+	for i := 0; i < len(chain)-1; i++ {
+		cert, issuer := chain[i], chain[i+1]
+		if useOCSP && len(cert.OCSPServer) > 0 {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[2*i] = checkOCSP(client, cert, issuer)
+			}(i)
+		}
+		if useCRL && len(cert.CRLDistributionPoints) > 0 {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[2*i+1] = checkCRL(client, cert, issuer)
+			}(i)
+		}
+	}
+	wg.Wait()
+
+	for _, err := range results {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOCSP checks the revocation status of cert using the first OCSP server
+// in the certificate.
+func checkOCSP(client *httpcache.Client, cert, issuer *x509.Certificate) error {
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return errs.Crypto(errors.Wrap(err, "error creating OCSP request"))
+	}
+	server := cert.OCSPServer[0]
+	b, err := client.Post(server, "application/ocsp-request", req)
+	if err != nil {
+		return errs.Network(err)
+	}
+	resp, err := ocsp.ParseResponseForCert(b, cert, issuer)
+	if err != nil {
+		return errs.Crypto(errors.Wrapf(err, "error parsing OCSP response from %s", server))
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		return nil
+	case ocsp.Revoked:
+		return errs.Policy(errors.Errorf("failed to verify certificate: certificate %s was revoked on %s",
+			cert.SerialNumber, resp.RevokedAt.Format(time.RFC3339)))
+	default:
+		return errs.Policy(errors.Errorf("failed to verify certificate: OCSP server %s does not know certificate %s",
+			server, cert.SerialNumber))
+	}
+}
+
+// checkCRL checks the revocation status of cert using the CRL distribution
+// points in the certificate. The CRLs are downloaded concurrently, and the
+// first valid CRL is used.
+func checkCRL(client *httpcache.Client, cert, issuer *x509.Certificate) error {
+	var lastErr error
+	urls := cert.CRLDistributionPoints
+	bodies, fetchErrs := client.GetAll(urls)
+	for i, b := range bodies {
+		if fetchErrs[i] != nil {
+			lastErr = errs.Network(fetchErrs[i])
+			continue
+		}
+		crl, err := x509.ParseCRL(b)
+		if err != nil {
+			lastErr = errs.Crypto(errors.Wrapf(err, "error parsing CRL from %s", urls[i]))
+			continue
+		}
+		if err := issuer.CheckCRLSignature(crl); err != nil {
+			lastErr = errs.Crypto(errors.Wrapf(err, "error validating CRL from %s", urls[i]))
+			continue
+		}
+		if crl.HasExpired(time.Now()) {
+			lastErr = errs.Policy(errors.Errorf("failed to verify certificate: CRL from %s has expired", urls[i]))
+			continue
+		}
+		for _, rc := range crl.TBSCertList.RevokedCertificates {
+			if rc.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return errs.Policy(errors.Errorf("failed to verify certificate: certificate %s was revoked on %s",
+					cert.SerialNumber, rc.RevocationTime.Format(time.RFC3339)))
+			}
+		}
+		return nil
+	}
+	return lastErr
+}
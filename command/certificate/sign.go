@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/policy"
 	"github.com/smallstep/cli/crypto/x509util"
 	"github.com/smallstep/cli/errs"
 	"github.com/urfave/cli"
@@ -13,23 +15,26 @@ import (
 
 func signCommand() cli.Command {
 	return cli.Command{
-		Name:      "sign",
-		Action:    cli.ActionFunc(signAction),
-		Usage:     "sign a certificate signing request (CSR)",
-		UsageText: `**step certificate sign** <csr_file> <crt_file> <key_file>`,
+		Name:   "sign",
+		Action: command.ActionFunc(signAction),
+		Usage:  "sign a certificate signing request (CSR)",
+		UsageText: `**step certificate sign** <csr_file> <crt_file> <key_file> [**--subtle**]
+[**--ceremony**] [**--force**]`,
 		Description: `**step certificate sign** generates a signed
 certificate from a certificate signing request (CSR).
 
 ## POSITIONAL ARGUMENTS
 
 <csr_file>
-: The path to a certificate signing request (CSR) to be signed.
+: The path to a certificate signing request (CSR) to be signed, or to a signing
+bundle with **--ceremony**.
 
 <crt_file>
 : The path to an issuing certificate.
 
 <key_file>
-: The path to a private key for signing the CSR.
+: The path to a private key for signing the CSR. A key stored in a KMS can be
+used with a key URI, e.g. awskms:alias/my-key or env:STEP_KEY.
 
 ## EXIT CODES
 
@@ -43,7 +48,31 @@ Sign a certificate signing request:
 $ step certificate sign ./certificate-signing-request.csr \
 ./issuer-certificate.crt ./issuer-private-key.priv
 '''
+
+Sign a signing bundle in an air-gapped machine, see **step certificate
+ceremony-request**:
+
+'''
+$ step certificate sign --ceremony request.json root_ca.crt root_ca_key > response.json
+'''
 `,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name: "subtle",
+				Usage: `Sign the certificate even if it does not follow the local policy, e.g. if it
+has wildcard SANs. See **step certificate create** for the local policy.`,
+			},
+			cli.BoolFlag{
+				Name: "ceremony",
+				Usage: `Sign the signing bundle created by **step certificate ceremony-request** and
+print a response bundle for **step certificate ceremony-import**. A summary of
+the request is shown before signing it.`,
+			},
+			cli.BoolFlag{
+				Name:  "force",
+				Usage: `Sign the signing bundle without asking for confirmation.`,
+			},
+		},
 	}
 }
 
@@ -56,6 +85,14 @@ func signAction(ctx *cli.Context) error {
 	crtFile := ctx.Args().Get(1)
 	keyFile := ctx.Args().Get(2)
 
+	if ctx.Bool("ceremony") {
+		issuerIdentity, err := x509util.LoadIdentityFromDisk(crtFile, keyFile)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return signCeremony(ctx, csrFile, issuerIdentity)
+	}
+
 	csrBytes, err := ioutil.ReadFile(csrFile)
 	if err != nil {
 		return errors.WithStack(err)
@@ -79,6 +116,10 @@ func signAction(ctx *cli.Context) error {
 		return errors.WithStack(err)
 	}
 
+	if err := policy.CheckCertificate(leafProfile.Subject(), false); err != nil {
+		return err
+	}
+
 	crtBytes, err := leafProfile.CreateCertificate()
 	if err != nil {
 		return errors.Wrapf(err, "failure creating new leaf certificate from input csr")
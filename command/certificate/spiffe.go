@@ -0,0 +1,319 @@
+package certificate
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/spiffe"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func spiffeCommand() cli.Command {
+	return cli.Command{
+		Name:      "spiffe",
+		Usage:     "verify X.509-SVIDs and convert SPIFFE bundles",
+		UsageText: "**step certificate spiffe** <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step certificate spiffe** command group provides facilities to work with
+X.509-SVIDs and SPIFFE bundles, the JWK sets used to federate SPIFFE trust
+domains, like the ones served by the bundle endpoint of SPIRE.
+
+## EXAMPLES
+
+Verify an X.509-SVID with the bundle of its trust domain:
+'''
+$ step certificate spiffe verify svid.crt --bundle example.org.json
+'''
+
+Create a SPIFFE bundle with the root certificate of the CA:
+'''
+$ step certificate spiffe bundle $(step path)/certs/root_ca.crt > bundle.json
+'''
+
+Extract the root certificates of a SPIFFE bundle:
+'''
+$ step certificate spiffe roots bundle.json > roots.crt
+'''`,
+		Subcommands: cli.Commands{
+			spiffeVerifyCommand(),
+			spiffeBundleCommand(),
+			spiffeRootsCommand(),
+		},
+	}
+}
+
+func spiffeVerifyCommand() cli.Command {
+	return cli.Command{
+		Name:   "verify",
+		Action: command.ActionFunc(spiffeVerifyAction),
+		Usage:  "verify an X.509-SVID with a SPIFFE bundle",
+		UsageText: `**step certificate spiffe verify** <svid_file>
+**--bundle**=<file> [**--trust-domain**=<domain>] [**--id**=<uri>]`,
+		Description: `**step certificate spiffe verify** verifies an X.509-SVID with the X.509
+authorities of a SPIFFE bundle, and prints its SPIFFE ID.
+
+The X.509-SVID must follow the X.509-SVID specification: it must have a valid
+SPIFFE ID as its only URI SAN, it cannot be a CA, it must have the
+digitalSignature key usage and not the keyCertSign or cRLSign ones, and it must
+chain to one of the X.509 authorities in the bundle. A root with a SPIFFE ID
+must identify the same trust domain as the X.509-SVID.
+
+## POSITIONAL ARGUMENTS
+
+<svid_file>
+:  The path to the X.509-SVID, a PEM file with the leaf certificate followed by
+its intermediates.
+
+## EXIT CODES
+
+This command returns 0 on success and \>0 if any error occurs.
+
+## EXAMPLES
+
+Verify an X.509-SVID:
+'''
+$ step certificate spiffe verify svid.crt --bundle example.org.json
+spiffe://example.org/ns/prod/sa/billing
+'''
+
+Verify an X.509-SVID of a federated trust domain and a given workload:
+'''
+$ step certificate spiffe verify svid.crt --bundle partner.com.json \
+  --trust-domain partner.com --id spiffe://partner.com/payments
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "bundle",
+				Usage: `The path to the SPIFFE bundle <file> of the trust domain.`,
+			},
+			cli.StringFlag{
+				Name:  "trust-domain",
+				Usage: `The trust <domain> of the bundle. The X.509-SVID must belong to it.`,
+			},
+			cli.StringFlag{
+				Name:  "id",
+				Usage: `The SPIFFE ID <uri> the X.509-SVID must have.`,
+			},
+		},
+	}
+}
+
+func spiffeVerifyAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+	bundleFile := ctx.String("bundle")
+	if bundleFile == "" {
+		return errs.RequiredFlag(ctx, "bundle")
+	}
+	trustDomain := ctx.String("trust-domain")
+	if trustDomain != "" {
+		if err := spiffe.ValidateTrustDomain(trustDomain); err != nil {
+			return errs.InvalidFlagValue(ctx, "trust-domain", trustDomain, "")
+		}
+	}
+	if s := ctx.String("id"); s != "" {
+		if _, err := spiffe.ParseID(s); err != nil {
+			return errs.InvalidFlagValue(ctx, "id", s, "")
+		}
+	}
+
+	chain, err := pemutil.ReadCertificateBundle(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	b, err := utils.ReadFile(bundleFile)
+	if err != nil {
+		return err
+	}
+	bundle, err := spiffe.ParseBundle(b)
+	if err != nil {
+		return errors.Wrapf(err, "error reading %s", bundleFile)
+	}
+
+	id, err := spiffe.VerifyX509SVID(chain, bundle, trustDomain, time.Now())
+	if err != nil {
+		return errors.Wrapf(err, "failed to verify %s", ctx.Args().First())
+	}
+	if s := ctx.String("id"); s != "" && id.String() != s {
+		return errors.Errorf("failed to verify %s: SPIFFE ID %s does not match %s", ctx.Args().First(), id, s)
+	}
+	fmt.Println(id)
+	return nil
+}
+
+func spiffeBundleCommand() cli.Command {
+	return cli.Command{
+		Name:   "bundle",
+		Action: command.ActionFunc(spiffeBundleAction),
+		Usage:  "create a SPIFFE bundle with root certificates",
+		UsageText: `**step certificate spiffe bundle** [<roots_file>]
+[**--sequence**=<number>] [**--refresh-hint**=<duration>] [**--out**=<file>]`,
+		Description: `**step certificate spiffe bundle** creates a SPIFFE bundle with the root
+certificates in a PEM file as its X.509 authorities. The bundle can be used to
+federate the trust domain of the CA with a SPIRE deployment.
+
+## POSITIONAL ARGUMENTS
+
+<roots_file>
+:  The path to the PEM file with the root certificates. It defaults to the root
+certificate of the CA in $STEPPATH/certs/root_ca.crt.
+
+## EXIT CODES
+
+This command returns 0 on success and \>0 if any error occurs.
+
+## EXAMPLES
+
+Create a SPIFFE bundle with the root certificate of the CA:
+'''
+$ step certificate spiffe bundle --out bundle.json
+'''
+
+Create a SPIFFE bundle with the roots of the CA, with a sequence number and a
+refresh hint:
+'''
+$ step ca roots roots.crt
+$ step certificate spiffe bundle roots.crt --sequence 2 --refresh-hint 5m
+'''`,
+		Flags: []cli.Flag{
+			cli.Uint64Flag{
+				Name:  "sequence",
+				Usage: `The sequence <number> of the bundle.`,
+			},
+			cli.DurationFlag{
+				Name: "refresh-hint",
+				Usage: `The <duration> after which the consumers of the bundle should check for
+updates, e.g. "5m" or "1h".`,
+			},
+			cli.StringFlag{
+				Name:  "out,output-file",
+				Usage: `The <file> to write the bundle to. It defaults to STDOUT.`,
+			},
+			flags.Force,
+		},
+	}
+}
+
+func spiffeBundleAction(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return errs.TooManyArguments(ctx)
+	}
+	rootsFile := ctx.Args().First()
+	if rootsFile == "" {
+		rootsFile = pki.GetRootCAPath()
+	}
+	if d := ctx.Duration("refresh-hint"); d < 0 || d%time.Second != 0 {
+		return errs.InvalidFlagValue(ctx, "refresh-hint", d.String(), "")
+	}
+
+	roots, err := pemutil.ReadCertificateBundle(rootsFile)
+	if err != nil {
+		return err
+	}
+	for _, crt := range roots {
+		if !crt.IsCA {
+			return errors.Errorf("%s is not a CA certificate", crt.Subject)
+		}
+	}
+	b, err := json.MarshalIndent(&spiffe.Bundle{
+		Sequence:        ctx.Uint64("sequence"),
+		RefreshHint:     ctx.Duration("refresh-hint"),
+		X509Authorities: roots,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if out := ctx.String("out"); out != "" {
+		if err := utils.WriteFile(out, b, 0644); err != nil {
+			return err
+		}
+		ui.Printf("Your SPIFFE bundle has been saved in %s.\n", out)
+		return nil
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+func spiffeRootsCommand() cli.Command {
+	return cli.Command{
+		Name:      "roots",
+		Action:    command.ActionFunc(spiffeRootsAction),
+		Usage:     "extract the root certificates of a SPIFFE bundle",
+		UsageText: `**step certificate spiffe roots** <bundle_file> [**--out**=<file>]`,
+		Description: `**step certificate spiffe roots** extracts the X.509 authorities of a
+SPIFFE bundle, like the ones served by the bundle endpoint of SPIRE, as PEM
+certificates. The certificates can be used as the roots of the trust domain in
+step commands, e.g. with the **--roots** flag of **step certificate verify**.
+
+## POSITIONAL ARGUMENTS
+
+<bundle_file>
+:  The path to the SPIFFE bundle.
+
+## EXIT CODES
+
+This command returns 0 on success and \>0 if any error occurs.
+
+## EXAMPLES
+
+Extract the roots of a federated trust domain:
+'''
+$ step certificate spiffe roots partner.com.json --out partner.com.crt
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "out,output-file",
+				Usage: `The <file> to write the certificates to. It defaults to STDOUT.`,
+			},
+			flags.Force,
+		},
+	}
+}
+
+func spiffeRootsAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+	filename := ctx.Args().First()
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	bundle, err := spiffe.ParseBundle(b)
+	if err != nil {
+		return errors.Wrapf(err, "error reading %s", filename)
+	}
+	if len(bundle.X509Authorities) == 0 {
+		return errors.Errorf("%s does not have any X.509 authorities", filename)
+	}
+
+	var out []byte
+	for _, crt := range bundle.X509Authorities {
+		out = append(out, pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: crt.Raw,
+		})...)
+	}
+	if outFile := ctx.String("out"); outFile != "" {
+		if err := utils.WriteFile(outFile, out, 0644); err != nil {
+			return err
+		}
+		ui.Printf("Your certificates have been saved in %s.\n", outFile)
+		return nil
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
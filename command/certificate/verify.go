@@ -3,11 +3,19 @@ package certificate
 import (
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/clock"
 	"github.com/smallstep/cli/crypto/x509util"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/httpcache"
 	"github.com/urfave/cli"
 )
 
@@ -17,12 +25,33 @@ func verifyCommand() cli.Command {
 		Action: cli.ActionFunc(verifyAction),
 		Usage:  `verify a certificate`,
 		UsageText: `**step certificate verify** <crt_file> [**--host**=<host>]
-		[**--roots**=<root-bundle>]`,
+		[**--ip**=<ip>] [**--purpose**=<purpose>] [**--at**=<time|duration>]
+		[**--roots**=<root-bundle>] [**--verify-ocsp**] [**--verify-crl**]
+		[**--no-cache**] [**--verbose**]`,
 		Description: `**step certificate verify** executes the certificate path
 validation algorithm for x.509 certificates defined in RFC 5280. If the
 certificate is valid this command will return '0'. If validation fails, or if
 an error occurs, this command will produce a non-zero return value.
 
+If the chain of trust cannot be built with the given certificates, the missing
+intermediates are downloaded using the CA Issuers URLs in the Authority
+Information Access extension of the certificates. The revocation status of the
+chain can be checked using OCSP and CRLs with the **--verify-ocsp** and
+**--verify-crl** flags; the requests are made concurrently. Downloaded
+certificates, CRLs and OCSP responses are cached in $STEPPATH/cache following
+the Cache-Control headers of the responses.
+
+The validation enforces the same rules as a TLS client: by default the chain
+must be valid for server authentication at the current time. The **--purpose**
+flag changes the extended key usage required in the chain, the **--host** and
+**--ip** flags check the names in the certificate, and the **--at** flag
+validates the chain at a different time.
+
+If the validation fails, the error explains which constraint is not satisfied,
+like an expired intermediate, a missing extended key usage, a name constraint
+violation or a weak signature algorithm. The **--verbose** flag prints all the
+chains attempted and their problems.
+
 ## POSITIONAL ARGUMENTS
 
 <crt_file>
@@ -30,7 +59,10 @@ an error occurs, this command will produce a non-zero return value.
 
 ## EXIT CODES
 
-This command returns 0 on success and \>0 if any error occurs.
+This command returns 0 on success and \>0 if any error occurs. A failure to
+read the certificate returns 4, a malformed certificate returns 5, a failure
+connecting to a remote server returns 6, and a certificate that does not pass
+the path validation returns 7.
 
 ## EXAMPLES
 
@@ -64,12 +96,90 @@ Verify a certificate using a custom directory of root certificates for path vali
 '''
 $ step certificate verify ./certificate.crt --roots "./path/to/root-certificates/"
 '''
+
+Verify a remote certificate and check that it's not revoked using OCSP:
+
+'''
+$ step certificate verify https://smallstep.com --verify-ocsp
+'''
+
+Verify a certificate checking the revocation status using fresh CRLs:
+
+'''
+$ step certificate verify ./certificate.crt --verify-crl --no-cache
+'''
+
+Verify that a certificate is valid for a host name and an IP address:
+
+'''
+$ step certificate verify ./certificate.crt --roots ./root-certificate.crt \
+--hostname example.com --ip 10.0.0.1
+'''
+
+Verify a client certificate:
+
+'''
+$ step certificate verify ./client.crt --roots ./root-certificate.crt --purpose clientAuth
+'''
+
+Verify that a certificate will still be valid in 30 days:
+
+'''
+$ step certificate verify ./certificate.crt --roots ./root-certificate.crt --at 720h
+'''
+
+Verify a certificate and print the chains attempted:
+
+'''
+$ step certificate verify ./certificate.crt --roots ./root-certificate.crt --verbose
+'''
+
+Verify a code signing certificate at the time the code was signed:
+
+'''
+$ step certificate verify ./signer.crt --roots ./root-certificate.crt \
+--purpose codeSigning --at 2020-01-02T15:04:05Z
+'''
 `,
 		Flags: []cli.Flag{
 			cli.StringFlag{
-				Name:  "host",
+				Name:  "host, hostname",
 				Usage: `Check whether the certificate is for the specified host.`,
 			},
+			cli.StringFlag{
+				Name:  "ip",
+				Usage: `Check whether the certificate is for the specified <ip> address.`,
+			},
+			cli.StringFlag{
+				Name: "purpose",
+				Usage: `The <purpose> the certificate chain must be valid for, it is checked against
+the extended key usage extension of every certificate in the chain.
+
+: <purpose> is a case-sensitive string and must be one of:
+
+    **serverAuth**
+    :  TLS server authentication, the default
+
+    **clientAuth**
+    :  TLS client authentication
+
+    **codeSigning**
+    :  Signing of executable code
+
+    **emailProtection**
+    :  Email protection (S/MIME)
+
+    **any**
+    :  Do not check the extended key usage`,
+			},
+			cli.StringFlag{
+				Name: "at",
+				Usage: `The <time|duration> used to validate the certificate chain instead of the
+current time. If a <time> is used it is expected to be in RFC 3339 format. If a
+<duration> is used, it is a sequence of decimal numbers, each with optional
+fraction and a unit suffix, such as "300ms", "-1.5h" or "2h45m". Valid time
+units are "ns", "us" (or "µs"), "ms", "s", "m", "h".`,
+			},
 			cli.StringFlag{
 				Name: "roots",
 				Usage: `Root certificate(s) that will be used to verify the
@@ -86,6 +196,21 @@ authenticity of the remote server.
     **directory**
 	:  Relative or full path to a directory. Every PEM encoded certificate from each file in the directory will be used for path validation.`,
 			},
+			cli.BoolFlag{
+				Name: "verify-ocsp",
+				Usage: `Check the revocation status of the certificate and its intermediates using
+the OCSP servers in the certificates.`,
+			},
+			cli.BoolFlag{
+				Name: "verify-crl",
+				Usage: `Check the revocation status of the certificate and its intermediates using
+the CRL distribution points in the certificates.`,
+			},
+			flags.NoCache,
+			cli.BoolFlag{
+				Name:  "verbose",
+				Usage: `Print the certificate chains attempted and the problems found in each one.`,
+			},
 		},
 	}
 }
@@ -99,20 +224,41 @@ func verifyAction(ctx *cli.Context) error {
 		err              error
 		crtFile          = ctx.Args().Get(0)
 		host             = ctx.String("host")
+		ip               = ctx.String("ip")
 		roots            = ctx.String("roots")
 		intermediatePool = x509.NewCertPool()
+		intermediates    []*x509.Certificate
 		rootPool         *x509.CertPool
 		cert             *x509.Certificate
 	)
 
+	if ip != "" && net.ParseIP(ip) == nil {
+		return errs.InvalidFlagValue(ctx, "ip", ip, "")
+	}
+	keyUsages, err := parsePurpose(ctx, ctx.String("purpose"))
+	if err != nil {
+		return err
+	}
+	currentTime, ok := flags.ParseTimeOrDuration(ctx.String("at"))
+	if !ok {
+		return errs.InvalidFlagValue(ctx, "at", ctx.String("at"), "")
+	}
+	// The clock only matters if the certificate is verified now
+	if currentTime.IsZero() {
+		if err := clock.Check(); err != nil {
+			return err
+		}
+	}
+
 	if _, addr, isURL := trimURLPrefix(crtFile); isURL {
 		peerCertificates, err := getPeerCertificates(addr, roots, false)
 		if err != nil {
 			return err
 		}
 		cert = peerCertificates[0]
-		for _, pc := range peerCertificates {
+		for _, pc := range peerCertificates[1:] {
 			intermediatePool.AddCert(pc)
+			intermediates = append(intermediates, pc)
 		}
 	} else {
 		crtBytes, err := ioutil.ReadFile(crtFile)
@@ -120,42 +266,41 @@ func verifyAction(ctx *cli.Context) error {
 			return errs.FileError(err, crtFile)
 		}
 
-		var (
-			ipems []byte
-			block *pem.Block
-		)
+		var block *pem.Block
 		// The first certificate PEM in the file is our leaf Certificate.
 		// Any certificate after the first is added to the list of Intermediate
 		// certificates used for path validation.
 		for len(crtBytes) > 0 {
 			block, crtBytes = pem.Decode(crtBytes)
 			if block == nil {
-				return errors.Errorf("%s contains an invalid PEM block", crtFile)
+				return errs.Crypto(errors.Errorf("%s contains an invalid PEM block", crtFile))
 			}
 			if block.Type != "CERTIFICATE" {
 				continue
 			}
-			if cert == nil {
-				cert, err = x509.ParseCertificate(block.Bytes)
-				if err != nil {
-					return errors.WithStack(err)
+			c, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				if cert == nil {
+					return errs.Crypto(errors.WithStack(err))
 				}
+				return errs.Crypto(errors.Errorf("failure creating intermediate list from certificate '%s'", crtFile))
+			}
+			if cert == nil {
+				cert = c
 			} else {
-				ipems = append(ipems, pem.EncodeToMemory(block)...)
+				intermediatePool.AddCert(c)
+				intermediates = append(intermediates, c)
 			}
 		}
 		if cert == nil {
-			return errors.Errorf("%s contains no PEM certificate blocks", crtFile)
-		}
-		if len(ipems) > 0 && !intermediatePool.AppendCertsFromPEM(ipems) {
-			return errors.Errorf("failure creating intermediate list from certificate '%s'", crtFile)
+			return errs.Crypto(errors.Errorf("%s contains no PEM certificate blocks", crtFile))
 		}
 	}
 
 	if roots != "" {
 		rootPool, err = x509util.ReadCertPool(roots)
 		if err != nil {
-			errors.Wrapf(err, "failure to load root certificate pool from input path '%s'", roots)
+			return errs.IO(errors.Wrapf(err, "failure to load root certificate pool from input path '%s'", roots))
 		}
 	}
 
@@ -163,11 +308,94 @@ func verifyAction(ctx *cli.Context) error {
 		DNSName:       host,
 		Roots:         rootPool,
 		Intermediates: intermediatePool,
+		KeyUsages:     keyUsages,
+		CurrentTime:   currentTime,
 	}
 
-	if _, err := cert.Verify(opts); err != nil {
-		return errors.Wrapf(err, "failed to verify certificate")
+	client := httpcache.New(ctx.Bool("no-cache"))
+	chains, err := cert.Verify(opts)
+	if _, ok := err.(x509.UnknownAuthorityError); ok && len(cert.IssuingCertificateURL) > 0 {
+		// Retry with the intermediates in the AIA extension.
+		issuers, err := fetchIntermediates(client, cert, intermediatePool)
+		if err != nil {
+			return err
+		}
+		intermediates = append(intermediates, issuers...)
+		chains, err = cert.Verify(opts)
+	}
+	if err == nil && ip != "" {
+		err = cert.VerifyHostname(ip)
+	}
+	if err != nil {
+		return errs.Policy(explainVerifyError(ctx, err, cert, intermediates, opts))
+	}
+	if ctx.Bool("verbose") {
+		for i, chain := range chains {
+			printChain(i+1, &x509util.ChainAttempt{Chain: chain, Trusted: true})
+		}
+	}
+
+	if useOCSP, useCRL := ctx.Bool("verify-ocsp"), ctx.Bool("verify-crl"); useOCSP || useCRL {
+		return checkRevocation(client, chains[0], useOCSP, useCRL)
 	}
 
 	return nil
 }
+
+// parsePurpose returns the extended key usages required by the given purpose.
+func parsePurpose(ctx *cli.Context, purpose string) ([]x509.ExtKeyUsage, error) {
+	switch purpose {
+	case "", "serverAuth":
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, nil
+	case "clientAuth":
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, nil
+	case "codeSigning":
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}, nil
+	case "emailProtection":
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}, nil
+	case "any":
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageAny}, nil
+	default:
+		return nil, errs.InvalidFlagValue(ctx, "purpose", purpose, "serverAuth, clientAuth, codeSigning, emailProtection, any")
+	}
+}
+
+// explainVerifyError builds all the possible chains and replaces the error
+// returned by the path validation with the problems of the best chain. If
+// --verbose is used, all the chains attempted are printed.
+func explainVerifyError(ctx *cli.Context, err error, cert *x509.Certificate, intermediates []*x509.Certificate, opts x509.VerifyOptions) error {
+	attempts := x509util.BuildChains(cert, intermediates, opts)
+	if ctx.Bool("verbose") {
+		for i, a := range attempts {
+			printChain(i+1, a)
+		}
+	}
+
+	if len(attempts) == 0 || len(attempts[0].Problems) == 0 {
+		return errors.Wrap(err, "failed to verify certificate")
+	}
+	problems := make([]string, len(attempts[0].Problems))
+	for i, p := range attempts[0].Problems {
+		problems[i] = p.Error()
+	}
+	return errors.Errorf("failed to verify certificate: %s", strings.Join(problems, "; "))
+}
+
+// printChain prints to stderr the certificates of a chain and its problems.
+func printChain(n int, a *x509util.ChainAttempt) {
+	status := "valid"
+	switch {
+	case !a.Trusted:
+		status = "untrusted"
+	case len(a.Problems) > 0:
+		status = "invalid"
+	}
+	fmt.Fprintf(os.Stderr, "Chain %d (%s):\n", n, status)
+	for i, c := range a.Chain {
+		fmt.Fprintf(os.Stderr, "  %d: %s [%s - %s]\n", i, x509util.CertificateName(c),
+			c.NotBefore.UTC().Format(time.RFC3339), c.NotAfter.UTC().Format(time.RFC3339))
+	}
+	for _, p := range a.Problems {
+		fmt.Fprintf(os.Stderr, "  - %s\n", p)
+	}
+}
@@ -0,0 +1,132 @@
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/urfave/cli"
+)
+
+func TestParsePurpose(t *testing.T) {
+	tests := []struct {
+		purpose string
+		want    []x509.ExtKeyUsage
+		wantErr bool
+	}{
+		{"", []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, false},
+		{"serverAuth", []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, false},
+		{"clientAuth", []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, false},
+		{"codeSigning", []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}, false},
+		{"emailProtection", []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}, false},
+		{"any", []x509.ExtKeyUsage{x509.ExtKeyUsageAny}, false},
+		{"ServerAuth", nil, true},
+		{"timeStamping", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.purpose, func(t *testing.T) {
+			got, err := parsePurpose(nil, tt.purpose)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.FatalError(t, err)
+			}
+			assert.Equals(t, tt.want, got)
+		})
+	}
+}
+
+// writeVerifyChain creates a root and a leaf certificate for 127.0.0.1,
+// valid during 2020, and returns the paths of their PEM files.
+func writeVerifyChain(t *testing.T, dir string) (string, string) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	root := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, root, root, rootKey.Public(), rootKey)
+	assert.FatalError(t, err)
+	root, err = x509.ParseCertificate(rootDER)
+	assert.FatalError(t, err)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, root, leafKey.Public(), rootKey)
+	assert.FatalError(t, err)
+
+	rootFile := filepath.Join(dir, "root_ca.crt")
+	leafFile := filepath.Join(dir, "leaf.crt")
+	assert.FatalError(t, ioutil.WriteFile(rootFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}), 0600))
+	assert.FatalError(t, ioutil.WriteFile(leafFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}), 0600))
+	return rootFile, leafFile
+}
+
+func TestVerifyAction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "step-verify")
+	assert.FatalError(t, err)
+	defer os.RemoveAll(dir)
+	rootFile, leafFile := writeVerifyChain(t, dir)
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"ok", []string{"--at", "2020-06-01T00:00:00Z"}, false},
+		{"ok ip", []string{"--at", "2020-06-01T00:00:00Z", "--ip", "127.0.0.1"}, false},
+		{"ok host and ip", []string{"--at", "2020-06-01T00:00:00Z", "--host", "localhost", "--ip", "127.0.0.1"}, false},
+		{"fail ip", []string{"--at", "2020-06-01T00:00:00Z", "--ip", "10.0.0.1"}, true},
+		{"fail bad ip", []string{"--at", "2020-06-01T00:00:00Z", "--ip", "foo"}, true},
+		{"fail expired", []string{"--at", "2021-06-01T00:00:00Z"}, true},
+		{"fail not yet valid", []string{"--at", "2019-06-01T00:00:00Z"}, true},
+		{"fail bad at", []string{"--at", "yesterday"}, true},
+		{"fail purpose", []string{"--at", "2020-06-01T00:00:00Z", "--purpose", "clientAuth"}, true},
+		{"fail unknown purpose", []string{"--at", "2020-06-01T00:00:00Z", "--purpose", "foo"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flag.NewFlagSet("verify", flag.ContinueOnError)
+			for _, f := range verifyCommand().Flags {
+				f.Apply(set)
+			}
+			args := append([]string{"--roots", rootFile}, tt.args...)
+			assert.FatalError(t, set.Parse(append(args, leafFile)))
+			ctx := cli.NewContext(cli.NewApp(), set, nil)
+
+			err := verifyAction(ctx)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
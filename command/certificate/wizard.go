@@ -0,0 +1,203 @@
+package certificate
+
+import (
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+// createWizard prompts for the arguments and the flags of step certificate
+// create that have not been passed, and prints the equivalent non-interactive
+// command. It returns the subject, the certificate file and the key file.
+func createWizard(ctx *cli.Context) ([]string, error) {
+	if ui.IsNonInteractive() {
+		return nil, errors.New("flag '--interactive' cannot be used in non-interactive mode")
+	}
+	args := ctx.Args()
+
+	subject, err := ui.Prompt("What is the subject of the certificate? (e.g. foo.example.com)",
+		ui.WithValidateNotEmpty(), ui.WithValue(args.Get(0)))
+	if err != nil {
+		return nil, err
+	}
+
+	if !ctx.IsSet("csr") && !ctx.IsSet("profile") {
+		typ, err := command.WizardSelect("What would you like to create?", "Type", []command.WizardOption{
+			{Name: "A leaf certificate for a TLS client or server", Value: "leaf"},
+			{Name: "An S/MIME certificate to sign and encrypt email", Value: "smime"},
+			{Name: "A code signing certificate to sign software releases", Value: "code-signing"},
+			{Name: "An intermediate CA certificate", Value: "intermediate-ca"},
+			{Name: "A self-signed root CA certificate", Value: "root-ca"},
+			{Name: "A certificate signing request (CSR) to be signed later", Value: "csr"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if typ == "csr" {
+			err = command.SetFlag(ctx, "csr", "true")
+		} else {
+			err = command.SetFlag(ctx, "profile", typ)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	csr := ctx.Bool("csr")
+
+	if !csr && ctx.String("profile") != "root-ca" {
+		if !ctx.IsSet("ca") {
+			ca, err := ui.Prompt("What is the certificate of the issuer? (e.g. intermediate-ca.crt)",
+				ui.WithValidateFunc(validateFile))
+			if err != nil {
+				return nil, err
+			}
+			if err := command.SetFlag(ctx, "ca", ca); err != nil {
+				return nil, err
+			}
+		}
+		if !ctx.IsSet("ca-key") {
+			caKey, err := ui.Prompt("What is the private key of the issuer? (e.g. intermediate-ca.key or awskms:alias/my-key)",
+				ui.WithValidateFunc(validateKeyFileOrURI))
+			if err != nil {
+				return nil, err
+			}
+			if err := command.SetFlag(ctx, "ca-key", caKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Code signing certificates do not have SANs by default.
+	if !ctx.IsSet("san") && ctx.String("profile") != "code-signing" {
+		msg := "What DNS names or IP addresses would you like to add? [leave empty to use the subject]"
+		if ctx.String("profile") == "smime" {
+			msg = "What email addresses would you like to add? [leave empty to use the subject]"
+		}
+		sans, err := ui.Prompt(msg, ui.WithValidateFunc(validateSANs))
+		if err != nil {
+			return nil, err
+		}
+		for _, san := range splitList(sans) {
+			if err := command.SetFlag(ctx, "san", san); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !ctx.IsSet("kty") && !command.IsFlagSet(ctx, "crv, curve") && !ctx.IsSet("size") {
+		key, err := command.WizardSelect("What type of key would you like to generate?", "Key", []command.WizardOption{
+			{Name: "EC P-256 (recommended)", Value: "EC:curve:P-256"},
+			{Name: "EC P-384", Value: "EC:curve:P-384"},
+			{Name: "EC P-521", Value: "EC:curve:P-521"},
+			{Name: "OKP Ed25519", Value: "OKP:curve:Ed25519"},
+			{Name: "RSA 2048", Value: "RSA:size:2048"},
+			{Name: "RSA 3072", Value: "RSA:size:3072"},
+			{Name: "RSA 4096", Value: "RSA:size:4096"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.SplitN(key, ":", 3)
+		if err := command.SetFlag(ctx, "kty", parts[0]); err != nil {
+			return nil, err
+		}
+		if err := command.SetFlag(ctx, parts[1], parts[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	if !csr && !ctx.IsSet("not-after") {
+		notAfter, err := ui.Prompt("How long should the certificate be valid? (e.g. 24h or 2160h) [leave empty for the default of the profile]",
+			ui.WithValidateFunc(validateTimeOrDuration))
+		if err != nil {
+			return nil, err
+		}
+		if notAfter != "" {
+			if err := command.SetFlag(ctx, "not-after", notAfter); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ext := ".crt"
+	if csr {
+		ext = ".csr"
+	}
+	crtFile, err := ui.Prompt("Where would you like to save the "+strings.TrimPrefix(ext, ".")+"?",
+		ui.WithValidateNotEmpty(), ui.WithDefaultValue(subject+ext), ui.WithValue(args.Get(1)))
+	if err != nil {
+		return nil, err
+	}
+	keyFile, err := ui.Prompt("Where would you like to save the private key?",
+		ui.WithValidateFunc(func(s string) error {
+			if strings.TrimSpace(s) == "" {
+				return errors.New("value is empty")
+			}
+			if s == crtFile {
+				return errors.Errorf("the key cannot be saved in %s", crtFile)
+			}
+			return nil
+		}), ui.WithDefaultValue(subject+".key"), ui.WithValue(args.Get(2)))
+	if err != nil {
+		return nil, err
+	}
+
+	args = []string{subject, crtFile, keyFile}
+	ui.Println()
+	ui.Println("The equivalent non-interactive command is:")
+	ui.Println("  " + command.CommandLine(ctx, args))
+	ui.Println()
+	return args, nil
+}
+
+// splitList splits a list of values separated by commas or spaces.
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+func validateFile(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return errors.New("value is empty")
+	}
+	if _, err := os.Stat(s); err != nil {
+		return errors.Errorf("%s does not exist", s)
+	}
+	return nil
+}
+
+func validateKeyFileOrURI(s string) error {
+	// Keys in a KMS use a URI, e.g. awskms:alias/my-key.
+	if strings.Contains(s, ":") {
+		return nil
+	}
+	return validateFile(s)
+}
+
+func validateSANs(s string) error {
+	dns := ui.DNS()
+	for _, san := range splitList(s) {
+		// Email addresses are used by the smime profile.
+		if net.ParseIP(san) != nil || strings.Contains(san, "@") {
+			continue
+		}
+		if err := dns(san); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateTimeOrDuration(s string) error {
+	if _, ok := flags.ParseTimeOrDuration(s); !ok {
+		return errors.Errorf("%s is not a time or a duration", s)
+	}
+	return nil
+}
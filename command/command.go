@@ -1,6 +1,7 @@
 package command
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -11,6 +12,8 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/policy"
+	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/usage"
 	"github.com/urfave/cli"
 )
@@ -22,6 +25,13 @@ const IgnoreEnvVar = "STEP_IGNORE_ENV_VAR"
 var cmds []cli.Command
 var currentContext *cli.Context
 
+// configPath and configFlags are the path of the running command and the
+// flags set from the configuration file, used by ReloadConfigValue.
+var (
+	configPath  []string
+	configFlags = make(map[string]bool)
+)
+
 func init() {
 	os.Unsetenv(IgnoreEnvVar)
 	cmds = []cli.Command{
@@ -32,7 +42,7 @@ func init() {
 // Register adds the given command to the global list of commands.
 // It sets recursively the command Flags environment variables.
 func Register(c cli.Command) {
-	setEnvVar(&c)
+	setEnvVar(&c, nil)
 	cmds = append(cmds, c)
 }
 
@@ -41,10 +51,12 @@ func Retrieve() []cli.Command {
 	return cmds
 }
 
-// ActionFunc returns a cli.ActionFunc that stores the context.
+// ActionFunc returns a cli.ActionFunc that stores the context. The --subtle
+// flag, if the command has it, relaxes the local policy.
 func ActionFunc(fn cli.ActionFunc) cli.ActionFunc {
 	return func(ctx *cli.Context) error {
 		currentContext = ctx
+		policy.SetSubtle(ctx.Bool("subtle"))
 		return fn(ctx)
 	}
 }
@@ -54,26 +66,164 @@ func IsForce() bool {
 	return currentContext != nil && currentContext.Bool("force")
 }
 
-// getConfigVars load the defaults.json file and sets the flags if they are not
-// already set or the EnvVar is set to IgnoreEnvVar.
-//
-// TODO(mariano): right now it only supports parameters at first level.
-func getConfigVars(ctx *cli.Context) error {
-	configFile := ctx.GlobalString("config")
-	if configFile == "" {
-		configFile = filepath.Join(config.StepPath(), "config", "defaults.json")
+// FileMode returns the value of the --mode flag, the octal permissions of the
+// files written by the command, or an empty string if it's not set.
+func FileMode() string {
+	if currentContext == nil {
+		return ""
+	}
+	return currentContext.String("mode")
+}
+
+// FileOwner returns the values of the --owner and --group flags, the user and
+// group of the files written by the command.
+func FileOwner() (owner, group string) {
+	if currentContext == nil {
+		return "", ""
+	}
+	return currentContext.String("owner"), currentContext.String("group")
+}
+
+// RootFile returns the value of the --root flag, the root certificate used by
+// the command, or an empty string if it's not set. It is also used to verify
+// the HTTPS URLs of the files read by the command.
+func RootFile() string {
+	if currentContext == nil {
+		return ""
+	}
+	return currentContext.String("root")
+}
+
+// ConfigFile returns the path of the configuration file used for the default
+// values of the flags. It is defined by the global flag --config or it
+// defaults to $STEPPATH/config/defaults.json.
+func ConfigFile(ctx *cli.Context) string {
+	if configFile := ctx.GlobalString("config"); configFile != "" {
+		return configFile
 	}
+	return filepath.Join(config.StepPath(), "config", "defaults.json")
+}
 
-	b, err := ioutil.ReadFile(configFile)
+// ReadConfigFile reads and parses the given configuration file. Numbers are
+// decoded as json.Number so they keep their original representation.
+func ReadConfigFile(filename string) (map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(filename)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	m := make(map[string]interface{})
-	if err := json.Unmarshal(b, &m); err != nil {
-		return errors.Wrapf(err, "error parsing %s", configFile)
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	return m, nil
+}
+
+// ExpandEnv replaces ${var} or $var in the string according to the values of
+// the current environment variables. The sequence $$ can be used to escape a
+// dollar sign.
+func ExpandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		return os.Getenv(name)
+	})
+}
+
+// configValues returns the values in the configuration that apply to the
+// command with the given path. Values at the first level apply to all
+// commands, and they can be overwritten by the values in the sections of a
+// command, for example a section {"crypto": {"jwt": {"sign": {...}}}} will
+// apply to "step crypto jwt sign".
+func configValues(m map[string]interface{}, path []string) map[string]interface{} {
+	values := make(map[string]interface{})
+	for i := 0; m != nil; i++ {
+		for k, v := range m {
+			if _, ok := v.(map[string]interface{}); !ok {
+				values[k] = v
+			}
+		}
+		if i == len(path) {
+			break
+		}
+		m, _ = m[path[i]].(map[string]interface{})
 	}
+	return values
+}
 
+// configValueStrings returns the string representation of a configuration
+// value with the environment variables expanded. Arrays will return one
+// element per value.
+func configValueStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{ExpandEnv(v)}
+	case []interface{}:
+		var values []string
+		for _, vv := range v {
+			values = append(values, configValueStrings(vv)...)
+		}
+		return values
+	case nil:
+		return nil
+	default:
+		return []string{fmt.Sprintf("%v", v)}
+	}
+}
+
+// getConfigVars returns a cli.BeforeFunc that loads the defaults.json file and
+// sets the flags of the command with the given path if they are not already
+// set or the EnvVar is set to IgnoreEnvVar.
+func getConfigVars(path []string) cli.BeforeFunc {
+	return func(ctx *cli.Context) error {
+		configPath = path
+		configFile := ConfigFile(ctx)
+		m, err := ReadConfigFile(configFile)
+		if err != nil {
+			if os.IsNotExist(errors.Cause(err)) {
+				return nil
+			}
+			return errs.FileError(err, configFile)
+		}
+		return setConfigVars(ctx, configValues(m, path))
+	}
+}
+
+// ReloadConfigValue reads the configuration file again and returns the value
+// of the given flag of the running command. If the flag was set in the
+// command line or with an environment variable its value is returned, and an
+// empty string is returned if the configuration does not have it. It is used
+// by long-running commands to apply the changes in the defaults.json without
+// a restart.
+func ReloadConfigValue(ctx *cli.Context, name string) (string, error) {
+	if ctx.IsSet(name) && !configFlags[name] {
+		return ctx.String(name), nil
+	}
+	for _, f := range ctx.Command.Flags {
+		if strings.Split(f.GetName(), ",")[0] == name && getFlagEnvVar(f) == IgnoreEnvVar {
+			return ctx.String(name), nil
+		}
+	}
+
+	configFile := ConfigFile(ctx)
+	m, err := ReadConfigFile(configFile)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return "", nil
+		}
+		return "", errs.FileError(err, configFile)
+	}
+	if values := configValueStrings(configValues(m, configPath)[name]); len(values) > 0 {
+		return values[0], nil
+	}
+	return "", nil
+}
+
+// setConfigVars sets the flags in the context with the given values.
+func setConfigVars(ctx *cli.Context, m map[string]interface{}) error {
 	flags := make(map[string]cli.Flag)
 	for _, f := range ctx.Command.Flags {
 		name := strings.Split(f.GetName(), ",")[0]
@@ -93,7 +243,12 @@ func getConfigVars(ctx *cli.Context) error {
 		}
 
 		if v, ok := m[name]; ok {
-			ctx.Set(name, fmt.Sprintf("%v", v))
+			for _, s := range configValueStrings(v) {
+				if err := ctx.Set(name, s); err != nil {
+					return errs.Usage(errors.Wrapf(err, "error setting flag '--%s' from the configuration", name))
+				}
+				configFlags[name] = true
+			}
 		}
 	}
 
@@ -123,15 +278,18 @@ func getFlagEnvVar(f cli.Flag) string {
 	return ""
 }
 
-// setEnvVar sets the the EnvVar element to each flag recursively.
-func setEnvVar(c *cli.Command) {
+// setEnvVar sets the the EnvVar element to each flag recursively. The parents
+// are the names of the commands that contain the given command.
+func setEnvVar(c *cli.Command, parents []string) {
 	if c == nil {
 		return
 	}
 
+	path := append(append([]string{}, parents...), c.Name)
+
 	// Enable getting the flags from a json file
 	if c.Before == nil && c.Action != nil {
-		c.Before = getConfigVars
+		c.Before = getConfigVars(path)
 	}
 
 	// Enable getting the flags from environment variables
@@ -207,6 +365,6 @@ func setEnvVar(c *cli.Command) {
 	}
 
 	for i := range c.Subcommands {
-		setEnvVar(&c.Subcommands[i])
+		setEnvVar(&c.Subcommands[i], path)
 	}
 }
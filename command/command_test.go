@@ -0,0 +1,88 @@
+package command
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("STEP_TEST_EXPAND", "foo")
+	defer os.Unsetenv("STEP_TEST_EXPAND")
+
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"bar", "bar"},
+		{"$STEP_TEST_EXPAND", "foo"},
+		{"${STEP_TEST_EXPAND}/bar", "foo/bar"},
+		{"$$STEP_TEST_EXPAND", "$STEP_TEST_EXPAND"},
+		{"$STEP_TEST_NOT_DEFINED", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			require.Equal(t, tc.expected, ExpandEnv(tc.value))
+		})
+	}
+}
+
+func TestConfigValues(t *testing.T) {
+	m := map[string]interface{}{
+		"ca-url": "https://ca.smallstep.com",
+		"kty":    "RSA",
+		"crypto": map[string]interface{}{
+			"kty": "OKP",
+			"jwt": map[string]interface{}{
+				"sign": map[string]interface{}{
+					"kty": "EC",
+					"aud": []interface{}{"foo", "bar"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		path     []string
+		expected map[string]interface{}
+	}{
+		{"top", []string{"ca", "token"}, map[string]interface{}{
+			"ca-url": "https://ca.smallstep.com",
+			"kty":    "RSA",
+		}},
+		{"section", []string{"crypto", "jwk", "create"}, map[string]interface{}{
+			"ca-url": "https://ca.smallstep.com",
+			"kty":    "OKP",
+		}},
+		{"subsection", []string{"crypto", "jwt", "sign"}, map[string]interface{}{
+			"ca-url": "https://ca.smallstep.com",
+			"kty":    "EC",
+			"aud":    []interface{}{"foo", "bar"},
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, configValues(m, tc.path))
+		})
+	}
+}
+
+func TestConfigValueStrings(t *testing.T) {
+	os.Setenv("STEP_TEST_EXPAND", "foo")
+	defer os.Unsetenv("STEP_TEST_EXPAND")
+
+	require.Equal(t, []string{"foo/bar"}, configValueStrings("${STEP_TEST_EXPAND}/bar"))
+	require.Equal(t, []string{"true"}, configValueStrings(true))
+	require.Equal(t, []string{"a", "foo"}, configValueStrings([]interface{}{"a", "$STEP_TEST_EXPAND"}))
+	require.Nil(t, configValueStrings(nil))
+}
+
+func TestShellQuote(t *testing.T) {
+	require.Equal(t, "''", shellQuote(""))
+	require.Equal(t, "foo.example.com", shellQuote("foo.example.com"))
+	require.Equal(t, "--not-after=2160h", shellQuote("--not-after=2160h"))
+	require.Equal(t, "'My CA'", shellQuote("My CA"))
+	require.Equal(t, `'it'\''s'`, shellQuote("it's"))
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	cmd := cli.Command{
+		Name:      "config",
+		Usage:     "manage the default values of the flags",
+		UsageText: "step config <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step config** command group provides commands to edit the configuration
+file with the default values of the flags, by default
+<$STEPPATH/config/defaults.json>. A different file can be used with the global
+flag **--config**.
+
+The keys at the first level of the configuration are used by all the commands
+with a flag of the same name. The keys of a command section are only used by
+that command and they take precedence over the first level ones. Sections are
+nested following the command names, and the keys of the editor commands use
+a dot to separate them, e.g. **crypto.jwt.sign.kty** is the flag **--kty** of
+**step crypto jwt sign**.
+
+String values can reference environment variables using $VAR or ${VAR}, they
+will be replaced when the configuration is loaded. Use $$ to write a literal
+dollar sign. Arrays can be used for flags that can be used multiple times.
+
+'''
+{
+  "ca-url": "https://ca.smallstep.com:9000",
+  "root": "${HOME}/.step/certs/root_ca.crt",
+  "crypto": {
+    "jwt": {
+      "sign": {
+        "kty": "EC",
+        "aud": ["https://example.com", "https://example.org"]
+      }
+    }
+  }
+}
+'''
+
+## EXAMPLES
+
+Set the default CA URL for all commands:
+'''
+$ step config set ca-url https://ca.smallstep.com:9000
+'''
+
+Set the default key type of **step crypto jwt sign**:
+'''
+$ step config set crypto.jwt.sign.kty EC
+'''
+
+Set multiple default audiences of **step crypto jwt sign**:
+'''
+$ step config set crypto.jwt.sign.aud https://example.com https://example.org
+'''
+
+Get the default CA URL:
+'''
+$ step config get ca-url
+https://ca.smallstep.com:9000
+'''
+
+Remove the default key type of **step crypto jwt sign**:
+'''
+$ step config unset crypto.jwt.sign.kty
+'''`,
+		Subcommands: cli.Commands{
+			setCommand(),
+			getCommand(),
+			unsetCommand(),
+		},
+	}
+
+	command.Register(cmd)
+}
+
+// readConfig reads the configuration file used by the context. It returns an
+// empty configuration if the file does not exist.
+func readConfig(ctx *cli.Context) (map[string]interface{}, string, error) {
+	filename := command.ConfigFile(ctx)
+	m, err := command.ReadConfigFile(filename)
+	switch {
+	case err == nil:
+		return m, filename, nil
+	case os.IsNotExist(errors.Cause(err)):
+		return make(map[string]interface{}), filename, nil
+	default:
+		return nil, filename, errs.FileError(err, filename)
+	}
+}
+
+// writeConfig writes the configuration to the given file.
+func writeConfig(filename string, m map[string]interface{}) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling %s", filename)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return errs.FileError(err, filename)
+	}
+	return errs.FileError(ioutil.WriteFile(filename, append(b, '\n'), 0644), filename)
+}
+
+// splitKey splits a key like crypto.jwt.sign.kty into the section names and
+// the flag name.
+func splitKey(key string) ([]string, string, error) {
+	parts := strings.Split(key, ".")
+	for _, p := range parts {
+		if p == "" {
+			return nil, "", errs.Usage(errors.Errorf("invalid key '%s'", key))
+		}
+	}
+	return parts[:len(parts)-1], parts[len(parts)-1], nil
+}
+
+// section returns the section of the configuration with the given path. If
+// create is true the missing sections will be created.
+func section(m map[string]interface{}, path []string, create bool) (map[string]interface{}, error) {
+	for i, name := range path {
+		switch v := m[name].(type) {
+		case map[string]interface{}:
+			m = v
+		case nil:
+			if !create {
+				return nil, nil
+			}
+			s := make(map[string]interface{})
+			m[name] = s
+			m = s
+		default:
+			return nil, errors.Errorf("key '%s' is not a section", strings.Join(path[:i+1], "."))
+		}
+	}
+	return m, nil
+}
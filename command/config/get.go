@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func getCommand() cli.Command {
+	return cli.Command{
+		Name:      "get",
+		Action:    command.ActionFunc(getAction),
+		Usage:     "print the default value of a flag",
+		UsageText: `**step config get** <key> [**--expand**]`,
+		Description: `**step config get** command prints the default value of a flag in the
+configuration file. Strings are printed as they are, and other values, like
+arrays or sections, are printed in JSON format.
+
+## POSITIONAL ARGUMENTS
+
+<key>
+:  The name of the flag, it can be prefixed by the names of the command and
+subcommands separated by dots, e.g. **crypto.jwt.sign.kty**.
+
+## EXIT CODES
+
+This command returns 0 on success and 1 if the key is not defined in the
+configuration file.
+
+## EXAMPLES
+
+Get the default CA URL:
+'''
+$ step config get ca-url
+https://ca.smallstep.com:9000
+'''
+
+Get the default root certificate with the environment variables expanded:
+'''
+$ step config get root --expand
+/home/user/.step/certs/root_ca.crt
+'''
+
+Get all the defaults of **step crypto jwt sign**:
+'''
+$ step config get crypto.jwt.sign
+{
+  "aud": [
+    "https://example.com",
+    "https://example.org"
+  ],
+  "kty": "EC"
+}
+'''`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "expand",
+				Usage: "Replace the environment variables in string values.",
+			},
+		},
+	}
+}
+
+func getAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	key := ctx.Args().Get(0)
+	path, name, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+
+	m, _, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := section(m, path, false)
+	if err != nil {
+		return err
+	}
+	v, ok := s[name]
+	if !ok {
+		return errors.Errorf("key '%s' is not defined", key)
+	}
+
+	if str, ok := v.(string); ok {
+		if ctx.Bool("expand") {
+			str = command.ExpandEnv(str)
+		}
+		fmt.Println(str)
+		return nil
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling %s", key)
+	}
+	fmt.Println(string(b))
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+func setCommand() cli.Command {
+	return cli.Command{
+		Name:      "set",
+		Action:    command.ActionFunc(setAction),
+		Usage:     "set the default value of a flag",
+		UsageText: `**step config set** <key> <value>...`,
+		Description: `**step config set** command sets the default value of a flag in the
+configuration file. If more than one value is passed, the value will be stored
+as an array, so it can be used with flags that can be used multiple times.
+
+## POSITIONAL ARGUMENTS
+
+<key>
+:  The name of the flag, it can be prefixed by the names of the command and
+subcommands separated by dots, e.g. **crypto.jwt.sign.kty**.
+
+<value>
+:  The default value of the flag. Environment variables in the form $VAR or
+${VAR} will be replaced when the configuration is loaded.
+
+## EXAMPLES
+
+Set the default CA URL for all commands:
+'''
+$ step config set ca-url https://ca.smallstep.com:9000
+'''
+
+Set the default root certificate using an environment variable:
+'''
+$ step config set root '${HOME}/.step/certs/root_ca.crt'
+'''
+
+Set the default audiences of **step crypto jwt sign**:
+'''
+$ step config set crypto.jwt.sign.aud https://example.com https://example.org
+'''`,
+	}
+}
+
+func setAction(ctx *cli.Context) error {
+	if ctx.NArg() < 2 {
+		return errs.TooFewArguments(ctx)
+	}
+
+	args := ctx.Args()
+	path, name, err := splitKey(args[0])
+	if err != nil {
+		return err
+	}
+
+	m, filename, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := section(m, path, true)
+	if err != nil {
+		return err
+	}
+	if _, ok := s[name].(map[string]interface{}); ok {
+		return errs.Usage(errors.Errorf("key '%s' is a section", args[0]))
+	}
+
+	if values := args[1:]; len(values) == 1 {
+		s[name] = values[0]
+	} else {
+		s[name] = values
+	}
+
+	if err := writeConfig(filename, m); err != nil {
+		return err
+	}
+	ui.Printf("The configuration has been saved in %s.\n", filename)
+	return nil
+}
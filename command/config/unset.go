@@ -0,0 +1,77 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+func unsetCommand() cli.Command {
+	return cli.Command{
+		Name:      "unset",
+		Action:    command.ActionFunc(unsetAction),
+		Usage:     "remove the default value of a flag",
+		UsageText: `**step config unset** <key>`,
+		Description: `**step config unset** command removes the default value of a flag, or a full
+section, from the configuration file. Sections that become empty are also
+removed.
+
+## POSITIONAL ARGUMENTS
+
+<key>
+:  The name of the flag, it can be prefixed by the names of the command and
+subcommands separated by dots, e.g. **crypto.jwt.sign.kty**.
+
+## EXAMPLES
+
+Remove the default key type of **step crypto jwt sign**:
+'''
+$ step config unset crypto.jwt.sign.kty
+'''
+
+Remove all the defaults of **step crypto jwt sign**:
+'''
+$ step config unset crypto.jwt.sign
+'''`,
+	}
+}
+
+func unsetAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	key := ctx.Args().Get(0)
+	path, name, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+
+	m, filename, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := section(m, path, false)
+	if err != nil {
+		return err
+	}
+	if _, ok := s[name]; !ok {
+		return errors.Errorf("key '%s' is not defined", key)
+	}
+	delete(s, name)
+
+	// Remove empty sections
+	for i := len(path); i > 0 && len(s) == 0; i-- {
+		parent, _ := section(m, path[:i-1], false)
+		delete(parent, path[i-1])
+		s = parent
+	}
+
+	if err := writeConfig(filename, m); err != nil {
+		return err
+	}
+	ui.Printf("The configuration has been saved in %s.\n", filename)
+	return nil
+}
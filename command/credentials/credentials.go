@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	cmd := cli.Command{
+		Name:      "credentials",
+		Usage:     "manage the credentials stored in the OS credential store",
+		UsageText: "step credentials <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step credentials** command group provides commands to manage the credentials,
+like the root fingerprint or private keys, stored in the credential store of the
+operating system.
+
+The credential store is the login keychain on macOS, the Secret Service on
+Linux, accessed with the tool secret-tool, or files encrypted with DPAPI on
+Windows. If the credential store is not available the credentials are stored in
+plaintext files in <$STEPPATH/secrets/credentials>. The environment variable
+STEPCREDSTORE can be set to "keychain" or "file" to select the backend.
+
+Credentials are scoped to the step path, and private keys stored in the
+credential store can be used anywhere a private key file is accepted using the
+URI 'keychain:<name>'.
+
+## EXAMPLES
+
+Store a private key in the credential store and use it to sign a JWT:
+'''
+$ step credentials set my-key key.pem
+$ step crypto jwt sign --key keychain:my-key --iss joe --aud example.com --sub joe --exp $(date -v+1M +"%s")
+'''
+
+Move the root fingerprint and the credentials stored in files to the keychain:
+'''
+$ step credentials migrate
+'''`,
+		Subcommands: cli.Commands{
+			setCommand(),
+			deleteCommand(),
+			migrateCommand(),
+		},
+	}
+
+	command.Register(cmd)
+}
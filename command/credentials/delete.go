@@ -0,0 +1,56 @@
+package credentials
+
+import (
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/credstore"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func deleteCommand() cli.Command {
+	return cli.Command{
+		Name:      "delete",
+		Action:    command.ActionFunc(deleteAction),
+		Usage:     "remove a credential from the credential store",
+		UsageText: `**step credentials delete** <name>`,
+		Description: `**step credentials delete** command removes a credential from the credential
+store.
+
+## POSITIONAL ARGUMENTS
+
+<name>
+:  The name of the credential.
+
+## EXAMPLES
+
+Remove a private key:
+'''
+$ step credentials delete my-key
+'''
+
+Remove the root fingerprint stored by **step ca bootstrap**:
+'''
+$ step credentials delete fingerprint
+'''`,
+	}
+}
+
+func deleteAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	name := ctx.Args().Get(0)
+	store, err := credstore.New()
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(name); err != nil {
+		if err == credstore.ErrNotFound {
+			return errs.Usage(errors.Errorf("credential '%s' not found", name))
+		}
+		return err
+	}
+	return nil
+}
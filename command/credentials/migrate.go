@@ -0,0 +1,85 @@
+package credentials
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/credstore"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+func migrateCommand() cli.Command {
+	return cli.Command{
+		Name:      "migrate",
+		Action:    command.ActionFunc(migrateAction),
+		Usage:     "move plaintext credentials to the credential store",
+		UsageText: `**step credentials migrate**`,
+		Description: `**step credentials migrate** command moves the credentials stored in plaintext
+to the credential store of the operating system:
+
+ * The root fingerprint in the configuration file, by default
+   <$STEPPATH/config/defaults.json>, written by previous versions of **step ca
+   bootstrap**.
+ * The credentials in <$STEPPATH/secrets/credentials>, stored when the
+   credential store was not available.
+
+The command does nothing if the credential store is not available, or if the
+file backend has been selected with the environment variable STEPCREDSTORE.
+
+## EXAMPLES
+
+Move the plaintext credentials to the keychain:
+'''
+$ step credentials migrate
+'''`,
+	}
+}
+
+func migrateAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	store, err := credstore.New()
+	if err != nil {
+		return err
+	}
+	if store.Name() == credstore.FileBackend {
+		ui.Println("The credential store is not available, nothing to migrate.")
+		return nil
+	}
+
+	// Move the fingerprint in the configuration file.
+	filename := command.ConfigFile(ctx)
+	m, err := command.ReadConfigFile(filename)
+	switch {
+	case err == nil:
+		if fp, ok := m["fingerprint"].(string); ok {
+			if err := store.Set("fingerprint", []byte(fp)); err != nil {
+				return err
+			}
+			delete(m, "fingerprint")
+			b, err := json.MarshalIndent(m, "", "  ")
+			if err != nil {
+				return errors.Wrapf(err, "error marshaling %s", filename)
+			}
+			if err := ioutil.WriteFile(filename, append(b, '\n'), 0644); err != nil {
+				return errs.FileError(err, filename)
+			}
+			ui.PrintSelected("Migrated", "fingerprint")
+		}
+	case !os.IsNotExist(errors.Cause(err)):
+		return errs.FileError(err, filename)
+	}
+
+	names, err := credstore.MigrateFiles(store)
+	for _, name := range names {
+		ui.PrintSelected("Migrated", name)
+	}
+	return err
+}
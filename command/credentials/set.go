@@ -0,0 +1,74 @@
+package credentials
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/credstore"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func setCommand() cli.Command {
+	return cli.Command{
+		Name:      "set",
+		Action:    command.ActionFunc(setAction),
+		Usage:     "store a credential in the credential store",
+		UsageText: `**step credentials set** <name> [<file>]`,
+		Description: `**step credentials set** command stores the contents of a file in the
+credential store with the given name. If the file is not provided the contents
+are read from the standard input. An existing credential with the same name is
+replaced.
+
+## POSITIONAL ARGUMENTS
+
+<name>
+:  The name of the credential. It can contain letters, digits, dots, dashes and
+underscores.
+
+<file>
+:  The file with the contents to store, e.g. a private key in PEM format.
+
+## EXAMPLES
+
+Store a private key:
+'''
+$ step credentials set my-key key.pem
+'''
+
+Store a secret read from the standard input:
+'''
+$ cat secret.txt | step credentials set my-secret
+'''`,
+	}
+}
+
+func setAction(ctx *cli.Context) error {
+	switch ctx.NArg() {
+	case 0:
+		return errs.MissingArguments(ctx, "name")
+	case 1, 2:
+	default:
+		return errs.TooManyArguments(ctx)
+	}
+
+	name := ctx.Args().Get(0)
+	filename := ctx.Args().Get(1)
+	if filename == "" {
+		filename = "-"
+	}
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	store, err := credstore.New()
+	if err != nil {
+		return err
+	}
+	if err := store.Set(name, b); err != nil {
+		return err
+	}
+	ui.PrintSelected("Credential", name)
+	return nil
+}
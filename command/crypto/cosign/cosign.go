@@ -0,0 +1,217 @@
+package cosign
+
+import (
+	"crypto"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/kms"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// Command returns the cli.Command for cosign and related subcommands.
+func Command() cli.Command {
+	return cli.Command{
+		Name:      "cosign",
+		Usage:     "create keys and signatures compatible with cosign",
+		UsageText: "step crypto cosign <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step crypto cosign** command group provides facilities to create and import
+keys in the format used by cosign, and to sign and verify container images
+using the cosign signature format, without installing cosign to manage the
+keys.
+
+Cosign private keys are PEM blocks with the type ENCRYPTED SIGSTORE PRIVATE KEY
+containing a PKCS#8 key encrypted with nacl/secretbox and a key derived from
+the password with scrypt. Any step command reading private keys can read them.
+Like cosign, the password can be set using the COSIGN_PASSWORD environment
+variable.
+
+The signatures are created over the cosign simple signing payload of an image
+digest. This command group does not access the registry, the signatures can be
+attached to the image using 'cosign attach signature', or the payload and
+signature of an image downloaded with 'cosign download signature' can be
+verified.
+
+## EXAMPLES
+
+Generate a new key pair in cosign.key and cosign.pub:
+'''
+$ step crypto cosign generate-key-pair
+'''
+
+Import a key created by step:
+'''
+$ step crypto cosign import-key-pair --key key.pem
+'''
+
+Sign an image and attach the signature using cosign:
+'''
+$ step crypto cosign sign registry.example.com/app@sha256:4f8c...1a2b \
+  --key cosign.key --output-payload payload.json --output-signature payload.sig
+$ cosign attach signature --payload payload.json --signature payload.sig \
+  registry.example.com/app@sha256:4f8c...1a2b
+'''
+
+Verify a signature of an image:
+'''
+$ step crypto cosign verify registry.example.com/app@sha256:4f8c...1a2b \
+  --key cosign.pub --payload payload.json --signature payload.sig
+'''`,
+		Subcommands: cli.Commands{
+			generateCommand(),
+			importCommand(),
+			signCommand(),
+			verifyCommand(),
+		},
+	}
+}
+
+// passwordEnv is the environment variable used by cosign to pass the password
+// of the private keys.
+const passwordEnv = "COSIGN_PASSWORD"
+
+var passwordFlags = flags.Password(flags.PasswordFile)
+
+// readPassword returns the password from the password flags or from the
+// COSIGN_PASSWORD environment variable. The second value is false if the
+// password has not been set.
+func readPassword(ctx *cli.Context) ([]byte, bool, error) {
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if password != nil {
+		return password, true, nil
+	}
+	if v, ok := os.LookupEnv(passwordEnv); ok {
+		return []byte(v), true, nil
+	}
+	return nil, false, nil
+}
+
+// readSigner returns a signer for a cosign, PEM or JWK private key file, or a
+// KMS key URI.
+func readSigner(ctx *cli.Context, name string) (crypto.Signer, error) {
+	if kms.IsKMS(name) {
+		b, err := kms.ReadKey(name)
+		if err != nil {
+			return nil, err
+		}
+		// The key is only available through a signer.
+		if b == nil {
+			return kms.NewSigner(name)
+		}
+		return parseSigner(ctx, name, b)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", name)
+	}
+	return parseSigner(ctx, name, b)
+}
+
+func parseSigner(ctx *cli.Context, name string, b []byte) (crypto.Signer, error) {
+	opts := []pemutil.Options{pemutil.WithFilename(name)}
+	password, ok, err := readPassword(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		opts = append(opts, pemutil.WithPassword(password))
+	}
+	key, err := pemutil.Parse(b, opts...)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("error reading %s: it is not a private key", name)
+	}
+	return signer, nil
+}
+
+// promptPassword returns the password used to encrypt a new cosign key.
+func promptPassword(ctx *cli.Context) ([]byte, error) {
+	password, ok, err := readPassword(ctx)
+	if err != nil || ok {
+		return password, err
+	}
+	return ui.PromptPassword("Please enter the password to encrypt the private key")
+}
+
+// simpleSigningType is the type of the cosign simple signing payload.
+const simpleSigningType = "cosign container image signature"
+
+// simpleSigning is the payload signed by cosign, a variant of the simple
+// signing format of containers/image.
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// parseImage splits an image reference with a digest, e.g.
+// registry.example.com/app@sha256:..., into the repository and the digest. A
+// tag in the reference is ignored.
+func parseImage(image string) (string, string, error) {
+	i := strings.LastIndex(image, "@")
+	if i < 0 {
+		return "", "", errors.Errorf("image %s does not have a digest, use <repository>@sha256:<digest>", image)
+	}
+	repo, digest := image[:i], image[i+1:]
+	if !digestRegexp.MatchString(digest) {
+		return "", "", errors.Errorf("image %s does not have a valid sha256 digest", image)
+	}
+	// Remove the tag, the port of the registry is followed by a slash.
+	if j := strings.LastIndex(repo, ":"); j > strings.LastIndex(repo, "/") {
+		repo = repo[:j]
+	}
+	if repo == "" {
+		return "", "", errors.Errorf("image %s does not have a repository", image)
+	}
+	return repo, digest, nil
+}
+
+// newPayload returns the simple signing payload of an image with the given
+// annotations.
+func newPayload(repo, digest string, annotations map[string]interface{}) ([]byte, error) {
+	var p simpleSigning
+	p.Critical.Identity.DockerReference = repo
+	p.Critical.Image.DockerManifestDigest = digest
+	p.Critical.Type = simpleSigningType
+	if len(annotations) > 0 {
+		p.Optional = annotations
+	}
+	b, err := json.Marshal(p)
+	return b, errors.Wrap(err, "error marshaling payload")
+}
+
+// parseAnnotations parses the values of the --annotation flag.
+func parseAnnotations(values []string) (map[string]interface{}, error) {
+	annotations := make(map[string]interface{})
+	for _, s := range values {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid annotation %s, use <key>=<value>", s)
+		}
+		annotations[parts[0]] = parts[1]
+	}
+	return annotations, nil
+}
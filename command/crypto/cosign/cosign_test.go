@@ -0,0 +1,73 @@
+package cosign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"golang.org/x/crypto/ed25519"
+)
+
+const testDigest = "sha256:4f8c2b1d0e9a8f7c6b5a4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d5e1a2b"
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		image   string
+		repo    string
+		wantErr bool
+	}{
+		{"registry.example.com/app@" + testDigest, "registry.example.com/app", false},
+		{"registry.example.com:5000/app:v1.0@" + testDigest, "registry.example.com:5000/app", false},
+		{"app@" + testDigest, "app", false},
+		{"registry.example.com/app:v1.0", "", true},
+		{"registry.example.com/app@sha256:1234", "", true},
+		{"@" + testDigest, "", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.image, func(t *testing.T) {
+			repo, digest, err := parseImage(tc.image)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.repo, repo)
+			assert.Equals(t, testDigest, digest)
+		})
+	}
+}
+
+func TestNewPayload(t *testing.T) {
+	b, err := newPayload("registry.example.com/app", testDigest, nil)
+	assert.FatalError(t, err)
+	assert.Equals(t, `{"critical":{"identity":{"docker-reference":"registry.example.com/app"},"image":{"docker-manifest-digest":"`+testDigest+`"},"type":"cosign container image signature"},"optional":null}`, string(b))
+
+	b, err = newPayload("registry.example.com/app", testDigest, map[string]interface{}{"commit": "5f3a2b1", "a": "b"})
+	assert.FatalError(t, err)
+	assert.Equals(t, `{"critical":{"identity":{"docker-reference":"registry.example.com/app"},"image":{"docker-manifest-digest":"`+testDigest+`"},"type":"cosign container image signature"},"optional":{"a":"b","commit":"5f3a2b1"}}`, string(b))
+}
+
+func TestSignPayload(t *testing.T) {
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	rs, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+
+	payload, err := newPayload("registry.example.com/app", testDigest, nil)
+	assert.FatalError(t, err)
+	for _, signer := range []crypto.Signer{ec, rs, ed} {
+		s, err := signPayload(signer, payload)
+		assert.FatalError(t, err)
+		sig, err := base64.StdEncoding.DecodeString(s)
+		assert.FatalError(t, err)
+		assert.NoError(t, verifyPayload(signer.Public(), payload, sig))
+		assert.Error(t, verifyPayload(signer.Public(), append(payload, ' '), sig))
+	}
+}
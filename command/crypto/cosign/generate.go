@@ -0,0 +1,86 @@
+package cosign
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/keys"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+func generateCommand() cli.Command {
+	return cli.Command{
+		Name:   "generate-key-pair",
+		Action: command.ActionFunc(generateAction),
+		Usage:  "generate a key pair in the cosign format",
+		UsageText: `**step crypto cosign generate-key-pair**
+[**--output-key-prefix**=<prefix>] [**--force**] [**--password-file**=<file>]`,
+		Description: `**step crypto cosign generate-key-pair** generates an EC P-256 key pair, the key
+type used by cosign, and writes the encrypted private key in <prefix>.key and
+the public key in <prefix>.pub.
+
+The password is read from the password flags or from the COSIGN_PASSWORD
+environment variable, otherwise it is prompted. Like cosign, an empty password
+is allowed.
+
+## EXAMPLES
+
+Generate cosign.key and cosign.pub:
+'''
+$ step crypto cosign generate-key-pair
+'''
+
+Generate release.key and release.pub in a script:
+'''
+$ COSIGN_PASSWORD=$(cat password.txt) step crypto cosign generate-key-pair --output-key-prefix release
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "output-key-prefix",
+				Value: "cosign",
+				Usage: `The <prefix> of the private and public key files.`,
+			},
+			flags.Force,
+		}, passwordFlags...),
+	}
+}
+
+func generateAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	prefix := ctx.String("output-key-prefix")
+	if prefix == "" {
+		return errs.RequiredFlag(ctx, "output-key-prefix")
+	}
+
+	pub, priv, err := keys.GenerateKeyPair("EC", "P-256", 0)
+	if err != nil {
+		return err
+	}
+	return writeKeyPair(ctx, prefix, pub, priv)
+}
+
+// writeKeyPair writes the private key in the cosign format in <prefix>.key
+// and the public key in <prefix>.pub.
+func writeKeyPair(ctx *cli.Context, prefix string, pub, priv interface{}) error {
+	keyFile, pubFile := prefix+".key", prefix+".pub"
+	password, err := promptPassword(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := pemutil.SerializeCosignPrivateKey(priv, pemutil.WithPassword(password),
+		pemutil.ToFile(keyFile, 0600)); err != nil {
+		return err
+	}
+	if _, err := pemutil.Serialize(pub, pemutil.ToFile(pubFile, 0644)); err != nil {
+		return err
+	}
+
+	ui.Printf("Your private key has been saved in %s.\n", keyFile)
+	ui.Printf("Your public key has been saved in %s.\n", pubFile)
+	return nil
+}
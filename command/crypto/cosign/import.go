@@ -0,0 +1,82 @@
+package cosign
+
+import (
+	"crypto"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/jose"
+	"github.com/urfave/cli"
+)
+
+func importCommand() cli.Command {
+	return cli.Command{
+		Name:   "import-key-pair",
+		Action: command.ActionFunc(importAction),
+		Usage:  "convert a private key to the cosign format",
+		UsageText: `**step crypto cosign import-key-pair** **--key**=<file>
+[**--output-key-prefix**=<prefix>] [**--force**] [**--password-file**=<file>]`,
+		Description: `**step crypto cosign import-key-pair** converts a PEM, OpenSSH or JWK private key,
+like the keys created by **step crypto keypair** or **step ca certificate**, to
+the cosign format, and writes the encrypted private key in <prefix>.key and the
+public key in <prefix>.pub. EC, RSA and Ed25519 keys are supported.
+
+If the key is encrypted, its password is prompted. The password of the new key
+is read from the password flags or from the COSIGN_PASSWORD environment
+variable, otherwise it is prompted.
+
+## EXAMPLES
+
+Import a key created by step:
+'''
+$ step crypto keypair key.pub key.pem
+$ step crypto cosign import-key-pair --key key.pem
+'''
+
+Import the key of a certificate in import.key and import.pub:
+'''
+$ step crypto cosign import-key-pair --key app.key --output-key-prefix import
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "key",
+				Usage: `The path to the PEM, OpenSSH or JWK private key <file> to import.`,
+			},
+			cli.StringFlag{
+				Name:  "output-key-prefix",
+				Value: "import-cosign",
+				Usage: `The <prefix> of the private and public key files.`,
+			},
+			flags.Force,
+		}, passwordFlags...),
+	}
+}
+
+func importAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	keyFile := ctx.String("key")
+	if keyFile == "" {
+		return errs.RequiredFlag(ctx, "key")
+	}
+	prefix := ctx.String("output-key-prefix")
+	if prefix == "" {
+		return errs.RequiredFlag(ctx, "output-key-prefix")
+	}
+
+	// The password flags are used for the new key, the imported key prompts
+	// for its password if it is encrypted.
+	jwk, err := jose.ParseKey(keyFile)
+	if err != nil {
+		return err
+	}
+	signer, ok := jwk.Key.(crypto.Signer)
+	if !ok {
+		return errors.Errorf("error reading %s: it is not a private key", keyFile)
+	}
+	return writeKeyPair(ctx, prefix, signer.Public(), signer)
+}
@@ -0,0 +1,145 @@
+package cosign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ed25519"
+)
+
+func signCommand() cli.Command {
+	return cli.Command{
+		Name:   "sign",
+		Action: command.ActionFunc(signAction),
+		Usage:  "sign a container image digest in the cosign format",
+		UsageText: `**step crypto cosign sign** <image> **--key**=<file>
+[**--annotation**=<key=value>] [**--output-payload**=<file>]
+[**--output-signature**=<file>] [**--password-file**=<file>]`,
+		Description: `**step crypto cosign sign** creates the cosign simple signing payload of a
+container image and signs it. The image must be referenced by its digest, the
+registry is not accessed.
+
+The signature is the base64 encoding of the signature of the payload, the same
+format created by 'cosign sign'. The payload and the signature can be attached
+to the image using 'cosign attach signature'.
+
+The key can be a cosign private key, any PEM, OpenSSH or JWK private key, or a
+KMS key URI. If the key is encrypted, the password is read from the password
+flags or from the COSIGN_PASSWORD environment variable, otherwise it is
+prompted.
+
+## POSITIONAL ARGUMENTS
+
+<image>
+:  The reference of the image with its digest, e.g.
+registry.example.com/app@sha256:<digest>.
+
+## EXAMPLES
+
+Sign an image and attach the signature using cosign:
+'''
+$ step crypto cosign sign registry.example.com/app@sha256:4f8c...1a2b \
+  --key cosign.key --output-payload payload.json --output-signature payload.sig
+$ cosign attach signature --payload payload.json --signature payload.sig \
+  registry.example.com/app@sha256:4f8c...1a2b
+'''
+
+Sign an image with annotations using a key in AWS KMS:
+'''
+$ step crypto cosign sign registry.example.com/app@sha256:4f8c...1a2b \
+  --key awskms:alias/cosign -a commit=5f3a2b1 -a pipeline=release \
+  --output-payload payload.json --output-signature payload.sig
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "key",
+				Usage: `The path to the private key <file> or the KMS key URI used to sign the image.`,
+			},
+			cli.StringSliceFlag{
+				Name: "a, annotation",
+				Usage: `An annotation <key=value> added to the optional section of the payload. Use the
+flag multiple times to add multiple annotations.`,
+			},
+			cli.StringFlag{
+				Name:  "output-payload",
+				Usage: `The <file> to write the payload to.`,
+			},
+			cli.StringFlag{
+				Name:  "output-signature",
+				Usage: `The <file> to write the signature to. Defaults to STDOUT.`,
+			},
+		}, passwordFlags...),
+	}
+}
+
+func signAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	keyFile := ctx.String("key")
+	if keyFile == "" {
+		return errs.RequiredFlag(ctx, "key")
+	}
+	repo, digest, err := parseImage(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	annotations, err := parseAnnotations(ctx.StringSlice("annotation"))
+	if err != nil {
+		return err
+	}
+
+	signer, err := readSigner(ctx, keyFile)
+	if err != nil {
+		return err
+	}
+	payload, err := newPayload(repo, digest, annotations)
+	if err != nil {
+		return err
+	}
+	sig, err := signPayload(signer, payload)
+	if err != nil {
+		return err
+	}
+
+	if out := ctx.String("output-payload"); out != "" {
+		if err := utils.WriteFile(out, payload, 0644); err != nil {
+			return errs.FileError(err, out)
+		}
+	}
+	if out := ctx.String("output-signature"); out != "" {
+		if err := utils.WriteFile(out, []byte(sig), 0644); err != nil {
+			return errs.FileError(err, out)
+		}
+		return nil
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// signPayload returns the base64 encoded signature of the payload, ECDSA and
+// RSA keys sign the SHA-256 digest of the payload.
+func signPayload(signer crypto.Signer, payload []byte) (string, error) {
+	var digest []byte
+	var opts crypto.SignerOpts
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		digest, opts = payload, crypto.Hash(0)
+	} else {
+		sum := sha256.Sum256(payload)
+		digest, opts = sum[:], crypto.SHA256
+	}
+	sig, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return "", errors.Wrap(err, "error signing payload")
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
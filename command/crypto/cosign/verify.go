@@ -0,0 +1,193 @@
+package cosign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ed25519"
+)
+
+func verifyCommand() cli.Command {
+	return cli.Command{
+		Name:   "verify",
+		Action: command.ActionFunc(verifyAction),
+		Usage:  "verify a cosign signature of a container image digest",
+		UsageText: `**step crypto cosign verify** <image> **--key**=<file> **--signature**=<file>
+[**--payload**=<file>] [**--annotation**=<key=value>]`,
+		Description: `**step crypto cosign verify** verifies a cosign signature of the simple signing
+payload of a container image. The image must be referenced by its digest, the
+registry is not accessed.
+
+For a signature to be verified successfully:
+
+  * The signature must be a valid signature of the payload using the key
+  * The payload must be a cosign container image signature of the image digest
+  * The payload must have the annotations in the **--annotation** flags
+
+If the **--payload** flag is not used, the payload created by
+**step crypto cosign sign** for the image and annotations is verified. The
+payload and signature of an image in a registry can be downloaded with
+'cosign download signature'.
+
+## POSITIONAL ARGUMENTS
+
+<image>
+:  The reference of the image with its digest, e.g.
+registry.example.com/app@sha256:<digest>.
+
+## EXAMPLES
+
+Verify the signature of an image:
+'''
+$ step crypto cosign verify registry.example.com/app@sha256:4f8c...1a2b \
+  --key cosign.pub --payload payload.json --signature payload.sig
+'''
+
+Verify the signature of an image and its annotations:
+'''
+$ step crypto cosign verify registry.example.com/app@sha256:4f8c...1a2b \
+  --key cosign.pub --signature payload.sig -a pipeline=release
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "key",
+				Usage: `The path to the public key <file>, or a certificate, used to verify the
+signature.`,
+			},
+			cli.StringFlag{
+				Name:  "signature",
+				Usage: `The path to the <file> with the base64 encoded signature.`,
+			},
+			cli.StringFlag{
+				Name:  "payload",
+				Usage: `The path to the payload <file> that was signed.`,
+			},
+			cli.StringSliceFlag{
+				Name: "a, annotation",
+				Usage: `An annotation <key=value> that must be in the optional section of the payload.
+Use the flag multiple times to require multiple annotations.`,
+			},
+		},
+	}
+}
+
+func verifyAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	keyFile, sigFile := ctx.String("key"), ctx.String("signature")
+	switch {
+	case keyFile == "":
+		return errs.RequiredFlag(ctx, "key")
+	case sigFile == "":
+		return errs.RequiredFlag(ctx, "signature")
+	}
+	repo, digest, err := parseImage(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	annotations, err := parseAnnotations(ctx.StringSlice("annotation"))
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return errs.FileError(err, keyFile)
+	}
+	pub, err := pemutil.ParseKey(b, pemutil.WithFilename(keyFile))
+	if err != nil {
+		return err
+	}
+
+	b, err = ioutil.ReadFile(sigFile)
+	if err != nil {
+		return errs.FileError(err, sigFile)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return errors.Wrapf(err, "error decoding %s", sigFile)
+	}
+
+	var payload []byte
+	if payloadFile := ctx.String("payload"); payloadFile != "" {
+		if payload, err = ioutil.ReadFile(payloadFile); err != nil {
+			return errs.FileError(err, payloadFile)
+		}
+	} else if payload, err = newPayload(repo, digest, annotations); err != nil {
+		return err
+	}
+
+	if err := verifyPayload(pub, payload, sig); err != nil {
+		return err
+	}
+
+	var p simpleSigning
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return errors.Wrap(err, "error parsing payload")
+	}
+	switch {
+	case p.Critical.Type != simpleSigningType:
+		return errors.Errorf("payload type %s is not %s", p.Critical.Type, simpleSigningType)
+	case p.Critical.Image.DockerManifestDigest != digest:
+		return errors.Errorf("payload digest %s does not match %s", p.Critical.Image.DockerManifestDigest, digest)
+	}
+	for k, v := range annotations {
+		if p.Optional[k] != v {
+			return errors.Errorf("payload annotation %s is %v, expected %v", k, p.Optional[k], v)
+		}
+	}
+
+	fmt.Printf("Verified signature of %s@%s\n", p.Critical.Identity.DockerReference, digest)
+	if len(p.Optional) > 0 {
+		b, err := json.MarshalIndent(p.Optional, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling annotations")
+		}
+		fmt.Printf("Annotations: %s\n", b)
+	}
+	return nil
+}
+
+// verifyPayload verifies the signature of the payload created by signPayload.
+func verifyPayload(pub interface{}, payload, sig []byte) error {
+	sum := sha256.Sum256(payload)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		var es struct {
+			R, S *big.Int
+		}
+		if rest, err := asn1.Unmarshal(sig, &es); err != nil || len(rest) > 0 {
+			return errors.New("invalid signature")
+		}
+		if !ecdsa.Verify(k, sum[:], es.R, es.S) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig); err != nil {
+			return errors.New("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, sig) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.Errorf("unsupported public key type %T", pub)
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package crypto
 
 import (
 	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/command/crypto/cosign"
+	"github.com/smallstep/cli/command/crypto/did"
 	"github.com/smallstep/cli/command/crypto/hash"
 	"github.com/smallstep/cli/command/crypto/jose"
 	"github.com/smallstep/cli/command/crypto/jwe"
@@ -12,6 +14,9 @@ import (
 	"github.com/smallstep/cli/command/crypto/key"
 	"github.com/smallstep/cli/command/crypto/nacl"
 	"github.com/smallstep/cli/command/crypto/otp"
+	"github.com/smallstep/cli/command/crypto/piv"
+	"github.com/smallstep/cli/command/crypto/smime"
+	"github.com/smallstep/cli/command/crypto/webauthn"
 	"github.com/urfave/cli"
 )
 
@@ -156,6 +161,10 @@ risks. That said, many of these factors are beyond the scope of this tool.
 		Subcommands: cli.Commands{
 			changePassCommand(),
 			createKeyPairCommand(),
+			cosign.Command(),
+			did.Command(),
+			httpSignCommand(),
+			httpVerifyCommand(),
 			jwk.Command(),
 			jwt.Command(),
 			jwe.Command(),
@@ -166,6 +175,11 @@ risks. That said, many of these factors are beyond the scope of this tool.
 			key.Command(),
 			nacl.Command(),
 			otp.Command(),
+			piv.Command(),
+			signFileCommand(),
+			smime.Command(),
+			verifyFileCommand(),
+			webauthn.Command(),
 		},
 	}
 
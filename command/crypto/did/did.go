@@ -0,0 +1,107 @@
+package did
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// Command returns the cli.Command for did and related subcommands.
+func Command() cli.Command {
+	return cli.Command{
+		Name:      "did",
+		Usage:     "create decentralized identifiers and sign and verify verifiable credentials",
+		UsageText: "step crypto did <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step crypto did** command group provides facilities to create decentralized
+identifiers (DIDs) from JSON Web Keys, and to sign and verify W3C Verifiable
+Credentials encoded as JWTs (VC-JWT).
+
+Two DID methods are supported. A did:key identifier encodes the public key,
+and it does not require any infrastructure. A did:web identifier is bound to a
+domain, and its DID document, with the public keys, is published at
+https://<domain>/.well-known/did.json.
+
+## EXAMPLES
+
+Create a did:key for an Ed25519 key:
+'''
+$ step crypto jwk create ed.pub.json ed.priv.json --kty OKP --crv Ed25519
+$ step crypto did key ed.pub.json
+did:key:z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp
+'''
+
+Create the DID document of did:web:example.com:
+'''
+$ step crypto did web example.com ed.pub.json > did.json
+'''
+
+Issue a verifiable credential signed by the did:key of a key:
+'''
+$ cat credential.json
+{
+  "@context": ["https://www.w3.org/2018/credentials/v1"],
+  "type": ["VerifiableCredential"],
+  "credentialSubject": {
+    "id": "did:example:joe",
+    "name": "Joe"
+  }
+}
+$ step crypto did sign credential.json --key ed.priv.json > credential.jwt
+'''
+
+Verify the credential and print it:
+'''
+$ step crypto did verify credential.jwt
+'''`,
+		Subcommands: cli.Commands{
+			keyCommand(),
+			webCommand(),
+			signCommand(),
+			verifyCommand(),
+		},
+	}
+}
+
+var passwordFileFlag = cli.StringFlag{
+	Name:  "password-file",
+	Usage: `The path to the <file> containing the password to decrypt the key.`,
+}
+
+var passwordFlags = flags.Password(passwordFileFlag)
+
+// readKey reads a signing key from a JWK or PEM file.
+func readKey(ctx *cli.Context, filename string) (*jose.JSONWebKey, error) {
+	options := []jose.Option{jose.WithUse("sig")}
+	if alg := ctx.String("alg"); alg != "" {
+		options = append(options, jose.WithAlg(alg))
+	}
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(password) > 0 {
+		options = append(options, jose.WithPassword(password))
+	}
+	jwk, err := jose.ParseKey(filename, options...)
+	if err != nil {
+		return nil, err
+	}
+	if jose.IsSymmetric(jwk) {
+		return nil, errors.Errorf("error reading %s: symmetric keys are not supported", filename)
+	}
+	return jwk, nil
+}
+
+func printJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling JSON")
+	}
+	fmt.Println(string(b))
+	return nil
+}
@@ -0,0 +1,71 @@
+package did
+
+import (
+	"fmt"
+
+	"github.com/smallstep/cli/crypto/did"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func keyCommand() cli.Command {
+	return cli.Command{
+		Name:   "key",
+		Action: cli.ActionFunc(keyAction),
+		Usage:  "create a did:key identifier",
+		UsageText: `**step crypto did key** <key-file> [**--document**]
+[**--password-file**=<file>]`,
+		Description: `**step crypto did key** prints the did:key identifier of the public key in a
+JWK or PEM file. Ed25519, ECDSA with P-256, P-384 and P-521, and RSA keys are
+supported.
+
+## POSITIONAL ARGUMENTS
+
+<key-file>
+:  The path to a public or private JWK or PEM file.
+
+## EXAMPLES
+
+Print the did:key of a key:
+'''
+$ step crypto did key p256.pub.json
+did:key:zDnaerDaTF5BXEavCrfRZEk316dpbLsfPDZ3WJ5hRTPFU2169
+'''
+
+Print the DID document of the did:key:
+'''
+$ step crypto did key p256.pub.json --document
+'''`,
+		Flags: append([]cli.Flag{
+			cli.BoolFlag{
+				Name:  "document",
+				Usage: "Print the DID document instead of the identifier.",
+			},
+		}, passwordFlags...),
+	}
+}
+
+func keyAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	jwk, err := readKey(ctx, ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	id, err := did.NewKey(jwk.Public().Key)
+	if err != nil {
+		return err
+	}
+
+	if !ctx.Bool("document") {
+		fmt.Println(id)
+		return nil
+	}
+	doc, err := did.NewDocument(id, jwk)
+	if err != nil {
+		return err
+	}
+	return printJSON(doc)
+}
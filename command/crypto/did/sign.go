@@ -0,0 +1,198 @@
+package did
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/did"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/jose"
+	"github.com/urfave/cli"
+)
+
+func signCommand() cli.Command {
+	return cli.Command{
+		Name:   "sign",
+		Action: cli.ActionFunc(signAction),
+		Usage:  "sign a verifiable credential",
+		UsageText: `**step crypto did sign** [- | <credential-file>] **--key**=<file>
+[**--did**=<did>] [**--alg**=<algorithm>] [**--password-file**=<file>]`,
+		Description: `**step crypto did sign** signs a W3C Verifiable Credential and prints it
+encoded as a JWT (VC-JWT). The credential is read from a JSON file or from
+STDIN.
+
+The issuer of the credential is the DID in the **--did** flag, or the did:key of
+the signing key if the flag is not used. The JWT claims are set from the
+credential properties: **"iss"** from the issuer, **"sub"** from the id of the
+credential subject, **"jti"** from the credential id, **"nbf"** from the
+issuance date, and **"exp"** from the expiration date. If the credential does
+not have an issuer or an issuance date, they are set to the DID and the current
+time. The **"kid"** header is the verification method of the key in the DID
+document.
+
+## POSITIONAL ARGUMENTS
+
+<credential-file>
+:  The path to the JSON file with the credential. Use '-' or omit it to read
+   it from STDIN.
+
+## EXAMPLES
+
+Sign a credential with the did:key of a key:
+'''
+$ step crypto did sign credential.json --key p256.priv.json
+'''
+
+Sign a credential issued by did:web:example.com:
+'''
+$ step crypto did sign credential.json --key p256.priv.json --did did:web:example.com
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "The path to the private JWK or PEM <file> used to sign the credential.",
+			},
+			cli.StringFlag{
+				Name: "did",
+				Usage: `The <did> of the issuer of the credential. Defaults to the did:key of the
+signing key.`,
+			},
+			cli.StringFlag{
+				Name: "alg, algorithm",
+				Usage: `The signature <algorithm> to use. If not specified, the "alg" member of the JWK
+is used, or the default algorithm for the key type.`,
+			},
+		}, passwordFlags...),
+	}
+}
+
+func signAction(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return errs.TooManyArguments(ctx)
+	}
+
+	keyFile := ctx.String("key")
+	if keyFile == "" {
+		return errs.RequiredFlag(ctx, "key")
+	}
+
+	// Read the credential
+	var b []byte
+	var err error
+	if filename := ctx.Args().Get(0); filename == "" || filename == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return errors.Wrap(err, "error reading credential")
+	}
+	var vc map[string]interface{}
+	if err := json.Unmarshal(b, &vc); err != nil {
+		return errors.Wrap(err, "error parsing credential")
+	}
+	if _, ok := vc["@context"]; !ok {
+		return errors.New("error parsing credential: @context is required")
+	}
+	if _, ok := vc["type"]; !ok {
+		return errors.New("error parsing credential: type is required")
+	}
+
+	jwk, err := readKey(ctx, keyFile)
+	if err != nil {
+		return err
+	}
+	if jwk.IsPublic() {
+		return errors.New("cannot use a public key for signing")
+	}
+	if jwk.Algorithm == "" {
+		return errors.New("flag '--alg' is required with the given key")
+	}
+
+	issuer := ctx.String("did")
+	if issuer == "" {
+		if issuer, err = did.NewKey(jwk.Public().Key); err != nil {
+			return err
+		}
+	}
+	kid, err := did.VerificationMethodID(issuer, jwk)
+	if err != nil {
+		return err
+	}
+
+	claims, err := credentialClaims(vc, issuer, time.Now())
+	if err != nil {
+		return err
+	}
+
+	so := new(jose.SignerOptions)
+	so.WithType("JWT")
+	so.WithHeader("kid", kid)
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.SignatureAlgorithm(jwk.Algorithm),
+		Key:       jwk.Key,
+	}, so)
+	if err != nil {
+		return errors.Wrap(err, "error creating JWT signer")
+	}
+
+	raw, err := jose.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		return errors.Wrap(err, "error serializing JWT")
+	}
+	fmt.Println(raw)
+	return nil
+}
+
+// credentialClaims returns the JWT claims of a credential as defined in the
+// JWT encoding of the Verifiable Credentials Data Model.
+func credentialClaims(vc map[string]interface{}, issuer string, now time.Time) (map[string]interface{}, error) {
+	claims := map[string]interface{}{
+		"iss": issuer,
+		"vc":  vc,
+	}
+
+	switch v := vc["issuer"].(type) {
+	case nil:
+		vc["issuer"] = issuer
+	case string:
+		if v != issuer {
+			return nil, errors.Errorf("credential issuer %s does not match %s", v, issuer)
+		}
+	case map[string]interface{}:
+		if v["id"] != issuer {
+			return nil, errors.Errorf("credential issuer %v does not match %s", v["id"], issuer)
+		}
+	}
+
+	if s, ok := vc["issuanceDate"].(string); ok {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing credential issuanceDate")
+		}
+		claims["nbf"] = t.Unix()
+	} else {
+		vc["issuanceDate"] = now.UTC().Format(time.RFC3339)
+		claims["nbf"] = now.Unix()
+	}
+	if s, ok := vc["expirationDate"].(string); ok {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing credential expirationDate")
+		}
+		claims["exp"] = t.Unix()
+	}
+	if s, ok := vc["id"].(string); ok {
+		claims["jti"] = s
+	}
+	if subject, ok := vc["credentialSubject"].(map[string]interface{}); ok {
+		if s, ok := subject["id"].(string); ok {
+			claims["sub"] = s
+		}
+	}
+	return claims, nil
+}
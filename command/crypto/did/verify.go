@@ -0,0 +1,141 @@
+package did
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/did"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func verifyCommand() cli.Command {
+	return cli.Command{
+		Name:   "verify",
+		Action: cli.ActionFunc(verifyAction),
+		Usage:  "verify a verifiable credential",
+		UsageText: `**step crypto did verify** [- | <token-file>] [**--key**=<file>]
+[**--password-file**=<file>]`,
+		Description: `**step crypto did verify** verifies a W3C Verifiable Credential encoded as a
+JWT (VC-JWT) and prints the credential on STDOUT. The JWT is read from a file
+or from STDIN.
+
+The key used to verify the JWT is the verification method in the **"kid"**
+header of the DID document of the issuer. The DID document of a did:key is
+derived from the identifier, and the document of a did:web is downloaded from
+its domain. The **--key** flag can be used to verify the credential with a
+given key without resolving the issuer DID.
+
+The credential must be valid at the current time, according to the **"nbf"** and
+**"exp"** claims.
+
+## POSITIONAL ARGUMENTS
+
+<token-file>
+:  The path to the file with the JWT. Use '-' or omit it to read it from STDIN.
+
+## EXAMPLES
+
+Verify a credential:
+'''
+$ step crypto did verify credential.jwt
+'''
+
+Verify a credential with a known key:
+'''
+$ cat credential.jwt | step crypto did verify --key p256.pub.json
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "The path to the JWK or PEM <file> used to verify the credential.",
+			},
+		}, passwordFlags...),
+	}
+}
+
+type credentialClaimSet struct {
+	jose.Claims
+	VC map[string]interface{} `json:"vc"`
+}
+
+func verifyAction(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return errs.TooManyArguments(ctx)
+	}
+
+	var token string
+	var err error
+	if filename := ctx.Args().Get(0); filename == "" || filename == "-" {
+		token, err = utils.ReadString(os.Stdin)
+	} else {
+		var b []byte
+		if b, err = utils.ReadFile(filename); err == nil {
+			token = strings.TrimSpace(string(b))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	tok, err := jose.ParseSigned(token)
+	if err != nil {
+		return errs.Crypto(errors.Wrap(jose.TrimPrefix(err), "error parsing token"))
+	}
+	if len(tok.Headers) != 1 {
+		return errs.Policy(errors.New("validation failed: multiple signatures are not supported"))
+	}
+
+	var unsafe credentialClaimSet
+	if err := tok.UnsafeClaimsWithoutVerification(&unsafe); err != nil {
+		return errs.Crypto(errors.Wrap(jose.TrimPrefix(err), "error parsing token"))
+	}
+	if unsafe.Issuer == "" {
+		return errs.Policy(errors.New("validation failed: missing issuer claim (iss)"))
+	}
+
+	// Get the key from the flag or from the DID document of the issuer.
+	var jwk *jose.JSONWebKey
+	if keyFile := ctx.String("key"); keyFile != "" {
+		if jwk, err = readKey(ctx, keyFile); err != nil {
+			return err
+		}
+	} else {
+		kid := tok.Headers[0].KeyID
+		if i := strings.IndexByte(kid, '#'); i > 0 && kid[:i] != unsafe.Issuer {
+			return errs.Policy(errors.Errorf("validation failed: kid %s does not belong to %s", kid, unsafe.Issuer))
+		}
+		doc, err := did.Resolve(&http.Client{Timeout: 15 * time.Second}, unsafe.Issuer)
+		if err != nil {
+			return err
+		}
+		if jwk, err = doc.Key(kid); err != nil {
+			return err
+		}
+	}
+
+	var claims credentialClaimSet
+	pub := jwk.Public()
+	if err := tok.Claims(pub.Key, &claims); err != nil {
+		if err == jose.ErrCryptoFailure {
+			return errs.Crypto(errors.New("validation failed: invalid signature"))
+		}
+		return errs.Crypto(errors.Wrap(err, "claim verify failed"))
+	}
+	if err := claims.ValidateWithLeeway(jose.Expected{
+		Issuer: unsafe.Issuer,
+		Time:   time.Now(),
+	}, time.Minute); err != nil {
+		return errs.Policy(errors.Wrap(jose.TrimPrefix(err), "validation failed"))
+	}
+	if claims.VC == nil {
+		return errs.Policy(errors.New("validation failed: missing credential claim (vc)"))
+	}
+
+	return printJSON(claims.VC)
+}
@@ -0,0 +1,74 @@
+package did
+
+import (
+	"github.com/smallstep/cli/crypto/did"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+func webCommand() cli.Command {
+	return cli.Command{
+		Name:   "web",
+		Action: cli.ActionFunc(webAction),
+		Usage:  "create a did:web document",
+		UsageText: `**step crypto did web** <domain> <key-file>
+[**--password-file**=<file>]`,
+		Description: `**step crypto did web** prints the DID document of the did:web identifier of
+a domain, with the public key in a JWK or PEM file as its verification method.
+The document must be published in the location printed on STDERR.
+
+## POSITIONAL ARGUMENTS
+
+<domain>
+:  The domain, with an optional port and path, of the identifier, e.g.
+   example.com or example.com/users/joe.
+
+<key-file>
+:  The path to a public or private JWK or PEM file.
+
+## EXAMPLES
+
+Create the DID document of did:web:example.com:
+'''
+$ step crypto did web example.com p256.pub.json > did.json
+The DID document of did:web:example.com must be published in https://example.com/.well-known/did.json.
+'''
+
+Create the DID document of did:web:example.com:users:joe:
+'''
+$ step crypto did web example.com/users/joe p256.pub.json > did.json
+The DID document of did:web:example.com:users:joe must be published in https://example.com/users/joe/did.json.
+'''`,
+		Flags: passwordFlags,
+	}
+}
+
+func webAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 2); err != nil {
+		return err
+	}
+
+	id, err := did.NewWeb(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	u, err := did.WebURL(id)
+	if err != nil {
+		return err
+	}
+	jwk, err := readKey(ctx, ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	doc, err := did.NewDocument(id, jwk)
+	if err != nil {
+		return err
+	}
+
+	if err := printJSON(doc); err != nil {
+		return err
+	}
+	ui.Printf("The DID document of %s must be published in %s.\n", id, u)
+	return nil
+}
@@ -17,7 +17,10 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/fips"
+	"github.com/smallstep/cli/crypto/policy"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
 	"github.com/urfave/cli"
 )
 
@@ -58,6 +61,12 @@ $ step crypto hash digest --alg md5 --insecure foo.crt
 a2c5dae8eae7d116019f0478e8b0a35a  foo.crt
 '''
 
+SHA-256 digest of the standard input and of a block device:
+'''
+$ curl -s https://example.com/large.iso | step crypto hash digest -
+$ sudo step crypto hash digest /dev/sda1
+'''
+
 SHA-512/256 of a list of files:
 '''
 $ find . -type f | xargs step crypto hash digest --alg sha512-256
@@ -82,17 +91,20 @@ func digestCommand() cli.Command {
 		Action: cli.ActionFunc(digestAction),
 		Usage:  "generate a hash digest of a file or directory",
 		UsageText: `**step crypto hash digest** <file-or-directory>...
-		[**--alg**=<algorithm>]`,
+		[**--alg**=<algorithm>] [**--buffer-size**=<size>]`,
 		Description: `**step crypto hash digest** generates a hash digest for a given file or
 directory. For a file, the output is the same as tools like 'shasum'. For
 directories, the tool computes a hash tree and outputs a single hash digest.
 
+Files are streamed, so block devices, named pipes or the standard input, using
+'-' as the file name, of any size can be hashed with constant memory.
+
 For examples, see **step help crypto hash**.
 
 ## POSITIONAL ARGUMENTS
 
 <file-or-directory>
-: The path to a file or directory to hash.`,
+: The path to a file or directory to hash, or '-' to hash the standard input.`,
 		Flags: []cli.Flag{
 			cli.StringFlag{
 				Name:  "alg",
@@ -125,6 +137,7 @@ For examples, see **step help crypto hash**.
     **md5** (requires --insecure)
     :  MD5 produces a 128-bit hash value`,
 			},
+			flags.BufferSize,
 			cli.BoolFlag{
 				Name:   "insecure",
 				Hidden: true,
@@ -139,7 +152,7 @@ func compareCommand() cli.Command {
 		Action: cli.ActionFunc(compareAction),
 		Usage:  "verify the hash digest for a file or directory matches an expected value",
 		UsageText: `**step crypto hash compare** <hash> <file-or-directory>
-		[--alg ALGORITHM]`,
+		[**--alg**=<algorithm>] [**--buffer-size**=<size>]`,
 		Description: `**step crypto hash compare** verifies that the expected hash value matches the
 computed hash value for a file or directory.
 
@@ -151,7 +164,7 @@ For examples, see **step help crypto hash**.
 : The expected hash digest
 
 <file-or-directory>
-: The path to a file or directory to hash.`,
+: The path to a file or directory to hash, or '-' to hash the standard input.`,
 		Flags: []cli.Flag{
 			cli.StringFlag{
 				Name:  "alg",
@@ -184,6 +197,7 @@ For examples, see **step help crypto hash**.
     **md5** (requires --insecure)
     :  MD5 produces a 128-bit hash value`,
 			},
+			flags.BufferSize,
 			cli.BoolFlag{
 				Name:   "insecure",
 				Hidden: true,
@@ -201,19 +215,13 @@ func digestAction(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	buf, err := getBuffer(ctx)
+	if err != nil {
+		return err
+	}
 
 	for _, filename := range ctx.Args() {
-		st, err := os.Stat(filename)
-		if err != nil {
-			return errs.FileError(err, filename)
-		}
-
-		var sum []byte
-		if st.IsDir() {
-			sum, err = hashDir(hc, filename)
-		} else {
-			sum, err = hashFile(hc(), filename)
-		}
+		sum, err := hashPath(hc, filename, buf)
 		if err != nil {
 			return err
 		}
@@ -233,6 +241,10 @@ func compareAction(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	buf, err := getBuffer(ctx)
+	if err != nil {
+		return err
+	}
 
 	hashStr := ctx.Args().Get(0)
 	hashBytes, err := hex.DecodeString(hashStr)
@@ -241,17 +253,7 @@ func compareAction(ctx *cli.Context) error {
 	}
 
 	filename := ctx.Args().Get(1)
-	st, err := os.Stat(filename)
-	if err != nil {
-		return errs.FileError(err, filename)
-	}
-
-	var sum []byte
-	if st.IsDir() {
-		sum, err = hashDir(hc, filename)
-	} else {
-		sum, err = hashFile(hc(), filename)
-	}
+	sum, err := hashPath(hc, filename, buf)
 	if err != nil {
 		return err
 	}
@@ -269,6 +271,13 @@ func compareAction(ctx *cli.Context) error {
 // getHash returns a new hash constructor for the given algorithm. MD5
 // algorithm can only be used if the insecure flag is passed.
 func getHash(ctx *cli.Context, alg string, insecure bool) (hashConstructor, error) {
+	name := strings.ToLower(alg)
+	if name == "sha" {
+		name = "sha1"
+	}
+	if err := policy.CheckAlgorithm(name); err != nil {
+		return nil, err
+	}
 	switch strings.ToLower(alg) {
 	case "sha", "sha1":
 		return func() hash.Hash { return sha1.New() }, nil
@@ -285,6 +294,9 @@ func getHash(ctx *cli.Context, alg string, insecure bool) (hashConstructor, erro
 	case "sha512-256":
 		return func() hash.Hash { return sha512.New512_256() }, nil
 	case "md5":
+		if err := fips.CheckHash(alg); err != nil {
+			return nil, err
+		}
 		if insecure {
 			return func() hash.Hash { return md5.New() }, nil
 		}
@@ -294,17 +306,51 @@ func getHash(ctx *cli.Context, alg string, insecure bool) (hashConstructor, erro
 	}
 }
 
+// getBuffer returns the buffer used to read the files, with the size in the
+// --buffer-size flag.
+func getBuffer(ctx *cli.Context) ([]byte, error) {
+	size, ok := flags.ParseBufferSize(ctx.String("buffer-size"))
+	if !ok {
+		return nil, errs.InvalidFlagValue(ctx, "buffer-size", ctx.String("buffer-size"), "")
+	}
+	return make([]byte, size), nil
+}
+
+// hashPath returns the hash of the given file or directory, or of the
+// standard input if the filename is '-'.
+func hashPath(hc hashConstructor, filename string, buf []byte) ([]byte, error) {
+	if filename == "-" {
+		return hashReader(hc(), os.Stdin, filename, buf)
+	}
+
+	st, err := os.Stat(filename)
+	if err != nil {
+		return nil, errs.FileError(err, filename)
+	}
+	if st.IsDir() {
+		return hashDir(hc, filename, buf)
+	}
+	return hashFile(hc(), filename, buf)
+}
+
 // hashFile returns the hash of the given file using the given hash function.
-func hashFile(h hash.Hash, filename string) ([]byte, error) {
+func hashFile(h hash.Hash, filename string, buf []byte) ([]byte, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, errs.FileError(err, filename)
 	}
+	defer f.Close()
 
-	if _, err := io.Copy(h, f); err != nil {
+	return hashReader(h, f, filename, buf)
+}
+
+// hashReader returns the hash of the contents of r using the given hash
+// function. The contents are read in chunks of the size of buf.
+func hashReader(h hash.Hash, r io.Reader, filename string, buf []byte) ([]byte, error) {
+	// Hide the WriteTo method of files so the copy uses the given buffer.
+	if _, err := io.CopyBuffer(h, struct{ io.Reader }{r}, buf); err != nil {
 		return nil, errs.FileError(err, filename)
 	}
-
 	return h.Sum(nil), nil
 }
 
@@ -315,7 +361,7 @@ func hashFile(h hash.Hash, filename string) ([]byte, error) {
 //     2.1 If file: add file mode bits and sum
 //     2.2 If directory: do hashDir and add sum
 //   3. return sum
-func hashDir(hc hashConstructor, dirname string) ([]byte, error) {
+func hashDir(hc hashConstructor, dirname string, buf []byte) ([]byte, error) {
 	// ReadDir returns the entries sorted by filename
 	files, err := ioutil.ReadDir(dirname)
 	if err != nil {
@@ -337,15 +383,15 @@ func hashDir(hc hashConstructor, dirname string) ([]byte, error) {
 		name := path.Join(dirname, fi.Name())
 		switch {
 		case fi.IsDir():
-			sum, err = hashDir(hc, name)
+			sum, err = hashDir(hc, name, buf)
 		case fi.Mode()&os.ModeSymlink != 0:
 			binary.LittleEndian.PutUint32(mode, uint32(fi.Mode()))
 			h.Write(mode)
-			sum, err = hashSymlink(hc, name)
+			sum, err = hashSymlink(hc, name, buf)
 		default:
 			binary.LittleEndian.PutUint32(mode, uint32(fi.Mode()))
 			h.Write(mode)
-			sum, err = hashFile(hc(), name)
+			sum, err = hashFile(hc(), name, buf)
 		}
 		if err != nil {
 			return nil, err
@@ -356,7 +402,7 @@ func hashDir(hc hashConstructor, dirname string) ([]byte, error) {
 	return h.Sum(nil), nil
 }
 
-func hashSymlink(hc hashConstructor, symname string) ([]byte, error) {
+func hashSymlink(hc hashConstructor, symname string, buf []byte) ([]byte, error) {
 	fullname, err := os.Readlink(symname)
 	if err != nil {
 		return nil, errs.FileError(err, symname)
@@ -373,10 +419,10 @@ func hashSymlink(hc hashConstructor, symname string) ([]byte, error) {
 	}
 	switch {
 	case st.Mode()&os.ModeSymlink != 0:
-		return hashSymlink(hc, fullname)
+		return hashSymlink(hc, fullname, buf)
 	case st.IsDir():
-		return hashDir(hc, fullname)
+		return hashDir(hc, fullname, buf)
 	default:
-		return hashFile(hc(), fullname)
+		return hashFile(hc(), fullname, buf)
 	}
 }
@@ -0,0 +1,492 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/httpsig"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/utils"
+)
+
+// httpRequestFlags are the flags used to describe the request to sign or
+// verify if a request file is not used.
+var httpRequestFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "method",
+		Value: "GET",
+		Usage: `The HTTP <method> of the request.`,
+	},
+	cli.StringFlag{
+		Name:  "url",
+		Usage: `The absolute <url> of the request.`,
+	},
+	cli.StringSliceFlag{
+		Name: "header",
+		Usage: `A header of the request in the format "Name: value". Use the '--header' flag
+multiple times to add multiple headers.`,
+	},
+	cli.StringFlag{
+		Name:  "body",
+		Usage: `The path to the <file> with the body of the request.`,
+	},
+}
+
+func httpSignCommand() cli.Command {
+	return cli.Command{
+		Name:   "http-sign",
+		Action: command.ActionFunc(httpSignAction),
+		Usage:  "sign an HTTP request using HTTP Message Signatures",
+		UsageText: `**step crypto http-sign** [<request-file>] **--key**=<file>
+[**--method**=<method>] [**--url**=<url>] [**--header**=<header>] [**--body**=<file>]
+[**--component**=<name>] [**--label**=<label>] [**--alg**=<algorithm>]
+[**--kid**=<kid>] [**--expires**=<duration>] [**--nonce**=<nonce>] [**--tag**=<tag>]
+[**--content-digest**] [**--password-file**=<file>]`,
+		Description: `**step crypto http-sign** signs an HTTP request using HTTP Message Signatures,
+defined in RFC 9421, and prints the **Signature-Input** and **Signature** headers
+to add to the request.
+
+The request is read from <request-file>, a raw HTTP/1.1 request, or from the
+flags **--method**, **--url**, **--header** and **--body**. Requests read from a file
+use the https scheme and the Host header to build the target URI.
+
+The signature covers the components given with the **--component** flag, they
+can be the names of headers or the derived components:
+
+**@method**
+:  The method of the request.
+
+**@target-uri**
+:  The full target URI of the request.
+
+**@authority**
+:  The host and port of the target URI.
+
+**@scheme**
+:  The scheme of the target URI.
+
+**@request-target**
+:  The path and query of the target URI.
+
+**@path**
+:  The path of the target URI.
+
+**@query**
+:  The query of the target URI, including the leading "?".
+
+The signature algorithm is derived from the key: **rsa-pss-sha512** for RSA keys,
+**ecdsa-p256-sha256** and **ecdsa-p384-sha384** for EC keys, **ed25519** for OKP
+keys and **hmac-sha256** for symmetric keys. Use **--alg** to select
+**rsa-v1_5-sha256** with RSA keys, the algorithm is then included in the
+signature parameters.
+
+## POSITIONAL ARGUMENTS
+
+<request-file>
+:  The path to a file with the raw HTTP request to sign.
+
+## EXAMPLES
+
+Sign a GET request with an EC key:
+'''
+$ step crypto http-sign --key priv.pem --kid my-key \
+  --url https://api.example.com/v1/orders?status=open
+'''
+
+Sign a webhook request read from a file, covering the body:
+'''
+$ cat webhook.http
+POST /hooks/orders HTTP/1.1
+Host: hooks.example.com
+Content-Type: application/json
+
+{"id": 42, "status": "paid"}
+$ step crypto http-sign webhook.http --key hmac.key --content-digest \
+  --component @method --component @target-uri \
+  --component content-type --component content-digest
+'''
+
+Sign a request with a JWK, valid for five minutes:
+'''
+$ step crypto http-sign --key priv.json --method POST \
+  --url https://api.example.com/v1/orders \
+  --header "Content-Type: application/json" --body order.json --content-digest \
+  --component @method --component @target-uri --component content-digest \
+  --expires 5m
+'''`,
+		Flags: append(append(httpRequestFlags,
+			cli.StringFlag{
+				Name:  "key",
+				Usage: `The path to the <file> with the signing key: a PEM or JWK private key, or a symmetric key.`,
+			},
+			cli.StringSliceFlag{
+				Name: "component",
+				Usage: `The <name> of a component covered by the signature, a header name or a derived
+component like @method. Use the '--component' flag multiple times to cover
+multiple components. Defaults to @method and @target-uri.`,
+			},
+			cli.StringFlag{
+				Name:  "label",
+				Value: httpsig.DefaultLabel,
+				Usage: `The <label> of the signature.`,
+			},
+			cli.StringFlag{
+				Name: "alg",
+				Usage: `The signature <algorithm>, one of rsa-pss-sha512, rsa-v1_5-sha256,
+ecdsa-p256-sha256, ecdsa-p384-sha384, ed25519 or hmac-sha256.`,
+			},
+			cli.StringFlag{
+				Name:  "kid",
+				Usage: `The <kid> included in the keyid parameter. Defaults to the kid of a JWK.`,
+			},
+			cli.DurationFlag{
+				Name:  "expires",
+				Usage: `The <duration> after which the signature expires (e.g. "5m").`,
+			},
+			cli.StringFlag{
+				Name:  "nonce",
+				Usage: `The <nonce> included in the nonce parameter.`,
+			},
+			cli.StringFlag{
+				Name:  "tag",
+				Usage: `The <tag> included in the tag parameter, identifying the application profile.`,
+			},
+			cli.BoolFlag{
+				Name: "content-digest",
+				Usage: `Add a Content-Digest header with the SHA-256 digest of the body. Cover it with
+'--component content-digest' to protect the body.`,
+			},
+		), flags.Password(flags.PasswordFile)...),
+	}
+}
+
+func httpVerifyCommand() cli.Command {
+	return cli.Command{
+		Name:   "http-verify",
+		Action: command.ActionFunc(httpVerifyAction),
+		Usage:  "verify an HTTP request signed using HTTP Message Signatures",
+		UsageText: `**step crypto http-verify** [<request-file>] **--key**=<file>
+[**--method**=<method>] [**--url**=<url>] [**--header**=<header>] [**--body**=<file>]
+[**--component**=<name>] [**--label**=<label>] [**--alg**=<algorithm>]
+[**--kid**=<kid>] [**--tag**=<tag>] [**--max-age**=<duration>] [**--content-digest**]`,
+		Description: `**step crypto http-verify** verifies a signature of an HTTP request defined in
+RFC 9421, HTTP Message Signatures, in the **Signature-Input** and **Signature**
+headers of the request.
+
+The request is read from <request-file>, a raw HTTP/1.1 request, or from the
+flags **--method**, **--url**, **--header** and **--body**. Requests read from a file
+use the https scheme and the Host header to build the target URI.
+
+For a signature to be verified successfully:
+
+  * The signature must be a valid signature of the covered components
+  * The signature must not be expired, and must not be created in the future
+  * The signature must cover all the components given with **--component**
+  * The keyid and tag parameters must match **--kid** and **--tag**, if given
+  * The signature must not be older than **--max-age**, if given
+  * With **--content-digest**, the Content-Digest header must match the body
+
+On success the label, algorithm and covered components of the signature are
+printed, and the command returns 0.
+
+## POSITIONAL ARGUMENTS
+
+<request-file>
+:  The path to a file with the raw HTTP request to verify.
+
+## EXAMPLES
+
+Verify a webhook request with a symmetric key, requiring the body to be signed:
+'''
+$ step crypto http-verify webhook.http --key hmac.key --content-digest \
+  --component @method --component @target-uri --component content-digest
+'''
+
+Verify a request with a public key, rejecting signatures older than a minute:
+'''
+$ step crypto http-verify --key pub.pem --max-age 1m \
+  --url https://api.example.com/v1/orders?status=open \
+  --header 'Signature-Input: sig1=("@method" "@target-uri");created=1618884473;keyid="my-key"' \
+  --header 'Signature: sig1=:MEUCIQ...:'
+'''`,
+		Flags: append(append(httpRequestFlags,
+			cli.StringFlag{
+				Name:  "key",
+				Usage: `The path to the <file> with the verification key: a PEM or JWK public key, or a symmetric key.`,
+			},
+			cli.StringSliceFlag{
+				Name: "component",
+				Usage: `The <name> of a component that must be covered by the signature. Use the
+'--component' flag multiple times to require multiple components.`,
+			},
+			cli.StringFlag{
+				Name:  "label",
+				Usage: `The <label> of the signature to verify. Required if the request has multiple signatures.`,
+			},
+			cli.StringFlag{
+				Name: "alg",
+				Usage: `The expected signature <algorithm>. Defaults to the alg parameter of the
+signature or the algorithm derived from the key.`,
+			},
+			cli.StringFlag{
+				Name:  "kid",
+				Usage: `The expected value of the keyid parameter.`,
+			},
+			cli.StringFlag{
+				Name:  "tag",
+				Usage: `The expected value of the tag parameter.`,
+			},
+			cli.DurationFlag{
+				Name:  "max-age",
+				Usage: `The maximum age of the signature, a <duration> like "5m".`,
+			},
+			cli.BoolFlag{
+				Name:  "content-digest",
+				Usage: `Verify that the Content-Digest header matches the body.`,
+			},
+		), flags.Password(flags.PasswordFile)...),
+	}
+}
+
+func httpSignAction(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return errs.TooManyArguments(ctx)
+	}
+	keyFile := ctx.String("key")
+	if keyFile == "" {
+		return errs.RequiredFlag(ctx, "key")
+	}
+	alg := ctx.String("alg")
+	if err := validateHTTPSigAlgorithm(ctx, alg); err != nil {
+		return err
+	}
+
+	r, body, err := readHTTPRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	var opts []jose.Option
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+	if len(password) > 0 {
+		opts = append(opts, jose.WithPassword(password))
+	}
+	jwk, err := jose.ParseKey(keyFile, opts...)
+	if err != nil {
+		return err
+	}
+	if jwk.IsPublic() {
+		return errors.Errorf("error parsing %s: the signing key must be a private key", keyFile)
+	}
+
+	var digest string
+	if ctx.Bool("content-digest") {
+		digest = httpsig.ContentDigest(body)
+		r.Header.Set("Content-Digest", digest)
+	}
+
+	kid := ctx.String("kid")
+	if kid == "" {
+		kid = jwk.KeyID
+	}
+	now := time.Now()
+	sopts := httpsig.SignOptions{
+		Label:      ctx.String("label"),
+		Components: ctx.StringSlice("component"),
+		Created:    now,
+		Nonce:      ctx.String("nonce"),
+		KeyID:      kid,
+		Tag:        ctx.String("tag"),
+		Algorithm:  alg,
+	}
+	if d := ctx.Duration("expires"); d > 0 {
+		sopts.Expires = now.Add(d)
+	}
+	input, sig, err := httpsig.Sign(r, jwk.Key, sopts)
+	if err != nil {
+		return err
+	}
+
+	if digest != "" {
+		fmt.Printf("Content-Digest: %s\n", digest)
+	}
+	fmt.Printf("Signature-Input: %s\n", input)
+	fmt.Printf("Signature: %s\n", sig)
+	return nil
+}
+
+func httpVerifyAction(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return errs.TooManyArguments(ctx)
+	}
+	keyFile := ctx.String("key")
+	if keyFile == "" {
+		return errs.RequiredFlag(ctx, "key")
+	}
+	alg := ctx.String("alg")
+	if err := validateHTTPSigAlgorithm(ctx, alg); err != nil {
+		return err
+	}
+
+	r, body, err := readHTTPRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	var opts []jose.Option
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+	if len(password) > 0 {
+		opts = append(opts, jose.WithPassword(password))
+	}
+	jwk, err := jose.ParseKey(keyFile, opts...)
+	if err != nil {
+		return err
+	}
+	components := ctx.StringSlice("component")
+	if ctx.Bool("content-digest") {
+		value := r.Header.Get("Content-Digest")
+		if value == "" {
+			return errs.Policy(errors.New("validation failed: request does not have a Content-Digest header"))
+		}
+		if err := httpsig.VerifyContentDigest(value, body); err != nil {
+			return errs.Policy(errors.Wrap(err, "validation failed"))
+		}
+		components = append(components, "content-digest")
+	}
+
+	s, err := httpsig.Verify(r, jwk.Key, httpsig.VerifyOptions{
+		Label:      ctx.String("label"),
+		Algorithm:  alg,
+		Components: components,
+		KeyID:      ctx.String("kid"),
+		Tag:        ctx.String("tag"),
+		MaxAge:     ctx.Duration("max-age"),
+	})
+	if err != nil {
+		return errs.Policy(errors.Wrap(err, "validation failed"))
+	}
+
+	fmt.Printf("Verified signature %s (%s)\n", s.Label, s.Algorithm)
+	fmt.Printf("Components: %s\n", strings.Join(s.Components, " "))
+	if s.KeyID != "" {
+		fmt.Printf("Key ID: %s\n", s.KeyID)
+	}
+	if !s.Created.IsZero() {
+		fmt.Printf("Created: %s\n", s.Created.UTC().Format(time.RFC3339))
+	}
+	if !s.Expires.IsZero() {
+		fmt.Printf("Expires: %s\n", s.Expires.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func validateHTTPSigAlgorithm(ctx *cli.Context, alg string) error {
+	switch alg {
+	case "", httpsig.RSAPSSSHA512, httpsig.RSAv15SHA256, httpsig.HMACSHA256,
+		httpsig.ECDSAP256SHA256, httpsig.ECDSAP384SHA384, httpsig.Ed25519:
+		return nil
+	default:
+		return errs.InvalidFlagValue(ctx, "alg", alg, "rsa-pss-sha512, rsa-v1_5-sha256, ecdsa-p256-sha256, ecdsa-p384-sha384, ed25519, hmac-sha256")
+	}
+}
+
+// readHTTPRequest returns the request and its body from the request file in
+// the first argument, or from the request flags.
+func readHTTPRequest(ctx *cli.Context) (*httpsig.Request, []byte, error) {
+	var (
+		r    *httpsig.Request
+		body []byte
+		err  error
+	)
+	if filename := ctx.Args().Get(0); filename != "" {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, nil, errs.FileError(err, filename)
+		}
+		if r, body, err = parseHTTPRequest(b); err != nil {
+			return nil, nil, errors.Wrapf(err, "error parsing %s", filename)
+		}
+	} else {
+		rawurl := ctx.String("url")
+		if rawurl == "" {
+			return nil, nil, errs.RequiredFlag(ctx, "url")
+		}
+		u, err := url.Parse(rawurl)
+		if err != nil || !u.IsAbs() {
+			return nil, nil, errs.InvalidFlagValue(ctx, "url", rawurl, "")
+		}
+		r = &httpsig.Request{
+			Method: strings.ToUpper(ctx.String("method")),
+			URL:    u,
+			Header: make(http.Header),
+		}
+		for _, h := range ctx.StringSlice("header") {
+			parts := strings.SplitN(h, ":", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				return nil, nil, errs.InvalidFlagValue(ctx, "header", h, "")
+			}
+			r.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+	}
+
+	if filename := ctx.String("body"); filename != "" {
+		if body, err = ioutil.ReadFile(filename); err != nil {
+			return nil, nil, errs.FileError(err, filename)
+		}
+	}
+	return r, body, nil
+}
+
+// parseHTTPRequest parses a raw HTTP/1.1 request. If the request does not
+// have a Content-Length header, the rest of the data is the body.
+func parseHTTPRequest(b []byte) (*httpsig.Request, []byte, error) {
+	br := bufio.NewReader(bytes.NewReader(b))
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.ContentLength <= 0 && len(req.TransferEncoding) == 0 {
+		if body, err = ioutil.ReadAll(br); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	u := req.URL
+	if !u.IsAbs() {
+		if req.Host == "" {
+			return nil, nil, errors.New("request does not have a Host header")
+		}
+		u.Scheme, u.Host = "https", req.Host
+	}
+	// ReadRequest moves the Host header to req.Host
+	if req.Host != "" {
+		req.Header.Set("Host", req.Host)
+	}
+	return &httpsig.Request{
+		Method: req.Method,
+		URL:    u,
+		Header: req.Header,
+	}, body, nil
+}
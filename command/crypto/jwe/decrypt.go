@@ -31,7 +31,8 @@ For examples, see **step help crypto jwe**.`,
 				Usage: `The <path> to the JWE recipient's private key. The argument should be the name of a file
 containing a private JWK (or a JWK encrypted as a JWE payload) or a PEM encoded
 private key (or a private key encrypted using the modes described on RFC 1423 or
-with PBES2+PBKDF2 described in RFC 2898).`,
+with PBES2+PBKDF2 described in RFC 2898). Keys in environment variables or
+Vault KV secrets can be used with a key URI, e.g. env:STEP_KEY.`,
 			},
 			cli.StringFlag{
 				Name: "jwks",
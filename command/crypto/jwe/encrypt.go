@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/jose"
 	"github.com/smallstep/cli/ui"
@@ -15,7 +16,7 @@ import (
 func encryptCommand() cli.Command {
 	return cli.Command{
 		Name:   "encrypt",
-		Action: cli.ActionFunc(encryptAction),
+		Action: command.ActionFunc(encryptAction),
 		Usage:  "encrypt a payload using JSON Web Encryption (JWE)",
 		UsageText: `**step crypto jwe encrypt**
 		[**--alg**=<key-enc-algorithm>] [**--enc**=<content-enc-algorithm>]
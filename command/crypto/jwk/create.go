@@ -9,10 +9,12 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/policy"
 	"github.com/smallstep/cli/crypto/randutil"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/kms"
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
@@ -33,7 +35,10 @@ func createCommand() cli.Command {
 		UsageText: `**step crypto jwk create** <public-jwk-file> <private-jwk-file>
     [**--kty**=<type>] [**--alg**=<algorithm>] [**--use**=<use>]
     [**--size**=<size>] [**--crv**=<curve>] [**--kid**=<kid>]
-    [**--from-pem**=<pem-file>] [**--password-file**=<file>]`,
+    [**--from-pem**=<pem-file>] [**--password-file**=<file>]
+
+**step crypto jwk create** <public-jwk-file> **--kms**=<uri>
+    [**--alg**=<algorithm>] [**--kid**=<kid>]`,
 		Description: `**step crypto jwk create** generates a new JWK (JSON Web Key) or constructs a
 JWK from an existing key. The generated JWK conforms to RFC7517 and can be used
 to sign and encrypt data using JWT, JWS, and JWE.
@@ -50,7 +55,8 @@ All flags are optional. Defaults are suitable for most use cases.
 :  Path to which the the public JWK should be written
 
 <private-jwk-file>
-:  Path to which the (JWE encrypted) private JWK should be written
+:  Path to which the (JWE encrypted) private JWK should be written. It is not
+used with the **--kms** flag, as the private key never leaves the KMS.
 
 ## EXIT CODES
 
@@ -154,8 +160,16 @@ Create a 192 bit symmetric encryption key for use with AES Key Wrap:
 $ step crypto jwk create kw.pub.json kw.json \
     --kty oct --size 192 --use enc --alg A192GCMKW
 '''
+
+Create the public JWK of a key stored in Google Cloud KMS, the same key can be
+used later to sign a JWT with **step crypto jwt sign --key**:
+
+'''
+$ step crypto jwk create kms.pub.json \
+    --kms cloudkms:projects/my-project/locations/global/keyRings/my-ring/cryptoKeys/my-key/cryptoKeyVersions/1
+'''
 `,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			cli.StringFlag{
 				Name:  "kty, type",
 				Value: "EC",
@@ -393,16 +407,46 @@ related.`,
 				Usage: `Create a JWK representing the key encoded in an
 existing <pem-file> instead of creating a new key.`,
 			},
-			flags.PasswordFile,
+			cli.StringFlag{
+				Name: "kms",
+				Usage: `Create the public JWK of the key stored in a KMS with the given <uri>
+instead of creating a new key. The supported URIs are:
+
+    **awskms:**<key-id>
+    :  A key in AWS KMS, e.g. awskms:alias/my-key.
+
+    **cloudkms:**<key-version>
+    :  A key version in Google Cloud KMS, e.g.
+    cloudkms:projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1.
+
+    **azurekms:**<vault>/<key>[/<version>]
+    :  A key in Azure Key Vault, e.g. azurekms:my-vault/my-key.
+
+    **yubikey:**<slot>
+    :  A key in a YubiKey PIV slot, e.g. yubikey:9a.
+
+    **agent:**<fingerprint-or-comment>
+    :  A key in the ssh-agent, e.g. agent:SHA256:ZNTOMF9r2Ww4Nx6s3QVv7dWBpnbEZGYtlkrhoFhBAbA.
+
+    **vault:**<path>
+    :  A key in the Vault Transit secrets engine, e.g. vault:transit/keys/my-key.`,
+			},
 			flags.NoPassword,
 			flags.Subtle,
 			flags.Insecure,
 			flags.Force,
-		},
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
+		}, flags.Password(flags.PasswordFile)...),
 	}
 }
 
 func createAction(ctx *cli.Context) (err error) {
+	if ctx.IsSet("kms") {
+		return createKMSAction(ctx)
+	}
+
 	// require public and private files
 	if err := errs.NumberOfArguments(ctx, 2); err != nil {
 		return err
@@ -410,16 +454,21 @@ func createAction(ctx *cli.Context) (err error) {
 
 	// Use password to protect private JWK by default
 	usePassword := true
-	passwordFile := ctx.String("password-file")
+	passwordFlag, err := utils.GetPasswordFlag(ctx)
+	if err != nil {
+		return err
+	}
 	if ctx.Bool("no-password") {
-		if len(passwordFile) > 0 {
-			return errs.IncompatibleFlag(ctx, "no-password", "password-file")
+		if len(passwordFlag) > 0 {
+			return errs.IncompatibleFlag(ctx, "no-password", passwordFlag)
 		}
-		if ctx.Bool("insecure") {
-			usePassword = false
-		} else {
+		if !ctx.Bool("insecure") {
 			return errs.RequiredInsecureFlag(ctx, "no-password")
 		}
+		if err := policy.CheckKeyEncryption(); err != nil {
+			return err
+		}
+		usePassword = false
 	}
 
 	pubFile := ctx.Args().Get(0)
@@ -429,12 +478,9 @@ func createAction(ctx *cli.Context) (err error) {
 	}
 
 	// Read password if necessary
-	var password string
-	if len(passwordFile) > 0 {
-		password, err = utils.ReadStringPasswordFromFile(passwordFile)
-		if err != nil {
-			return err
-		}
+	password, err := utils.ReadStringPasswordFromCLI(ctx)
+	if err != nil {
+		return err
 	}
 
 	kty := ctx.String("kty")
@@ -608,3 +654,66 @@ func createAction(ctx *cli.Context) (err error) {
 	ui.Printf("Your private key has been saved in %s.\n", privFile)
 	return nil
 }
+
+// createKMSAction writes the public JWK of a key stored in a KMS.
+func createKMSAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+	for _, name := range []string{"from-pem", "kty", "crv", "size"} {
+		if ctx.IsSet(name) {
+			return errs.IncompatibleFlagWithFlag(ctx, name, "kms")
+		}
+	}
+
+	uri := ctx.String("kms")
+	if !kms.IsKMS(uri) {
+		return errs.InvalidFlagValue(ctx, "kms", uri, "")
+	}
+	if use := ctx.String("use"); use != "" && use != "sig" {
+		return errs.InvalidFlagValue(ctx, "use", use, "sig")
+	}
+
+	pubFile := ctx.Args().Get(0)
+	var options []jose.Option
+	options = append(options, jose.WithUse("sig"))
+	if alg := ctx.String("alg"); alg != "" {
+		options = append(options, jose.WithAlg(alg))
+	}
+	if kid := ctx.String("kid"); kid != "" {
+		options = append(options, jose.WithKid(kid))
+	}
+	if ctx.Bool("subtle") {
+		options = append(options, jose.WithSubtle(true))
+	}
+
+	// The kid defaults to the thumbprint of the public key
+	jwk, err := jose.ParseKey(uri, options...)
+	if err != nil {
+		return err
+	}
+	if err := jose.ValidateJWK(jwk); err != nil {
+		return err
+	}
+
+	signer, ok := jwk.Key.(crypto.Signer)
+	if !ok {
+		return errors.Errorf("key %s is not a signing key", uri)
+	}
+	jwkPub := jose.JSONWebKey{
+		Key:       signer.Public(),
+		KeyID:     jwk.KeyID,
+		Algorithm: jwk.Algorithm,
+		Use:       jwk.Use,
+	}
+	b, err := json.MarshalIndent(jwkPub, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling JWK")
+	}
+	if err := utils.WriteFile(pubFile, b, 0600); err != nil {
+		return errs.FileError(err, pubFile)
+	}
+
+	ui.Printf("Your public key has been saved in %s.\n", pubFile)
+	return nil
+}
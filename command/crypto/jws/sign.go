@@ -1,6 +1,7 @@
 package jws
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -9,7 +10,10 @@ import (
 	"github.com/smallstep/cli/utils"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/keys"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/jose"
 	"github.com/urfave/cli"
 )
@@ -17,7 +21,7 @@ import (
 func signCommand() cli.Command {
 	return cli.Command{
 		Name:   "sign",
-		Action: cli.ActionFunc(signAction),
+		Action: command.ActionFunc(signAction),
 		Usage:  "create a signed JWS data structure",
 		UsageText: `**step crypto jws sign** [- | <filename>]
 		[**--alg**=<algorithm>] [**--jku**=<jwk-url>] [**--jwk**] [**--typ**=<type>]
@@ -131,7 +135,9 @@ performed by the JWS application. Use of <content-type> is optional.`,
 				Usage: `The <path> to the key with which to sign the JWS.
 JWSs can be signed using a private JWK (or a JWK encrypted as a JWE payload) or
 a PEM encoded private key (or a private key encrypted using the modes described
-on RFC 1423 or with PBES2+PBKDF2 described in RFC 2898).`,
+on RFC 1423 or with PBES2+PBKDF2 described in RFC 2898). Keys in environment
+variables, KMSs, YubiKeys, PKCS #11 tokens, an ssh-agent or Vault can be used
+with a key URI, e.g. env:STEP_KEY, awskms:alias/my-key or yubikey:9c.`,
 			},
 			cli.StringFlag{
 				Name: "jwks",
@@ -147,6 +153,15 @@ string. When used with '--jwk' the <kid> value must match the **"kid"** member
 of the JWK. When used with **--jwks** (a JWK Set) the <kid> value must match
 the **"kid"** member of one of the JWKs in the JWK Set.`,
 			},
+			cli.BoolFlag{
+				Name: "deterministic",
+				Usage: `Use deterministic nonces as defined in RFC 6979 for ECDSA signatures, the same
+payload signed with the same key always produces the same signature. It is
+useful for reproducible test fixtures or environments without a reliable source
+of randomness, but a fault in the signing process can leak the key. Requires the
+**--subtle** flag.`,
+			},
+			flags.Experimental,
 			cli.BoolFlag{
 				Name:   "subtle",
 				Hidden: true,
@@ -220,6 +235,9 @@ func signAction(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if jose.IsExperimental(jwk) && !ctx.Bool("experimental") {
+		return errs.RequiredExperimentalFlag(ctx, "key")
+	}
 
 	// Public keys cannot be used for signing
 	if jwk.IsPublic() {
@@ -260,6 +278,17 @@ func signAction(ctx *cli.Context) error {
 		so.WithHeader("jwk", jwk.Public())
 	}
 
+	if ctx.Bool("deterministic") {
+		if !isSubtle {
+			return errs.RequiredWithFlag(ctx, "deterministic", "subtle")
+		}
+		k, ok := jwk.Key.(*ecdsa.PrivateKey)
+		if !ok {
+			return errors.New("flag '--deterministic' requires an ECDSA key")
+		}
+		jwk.Key = keys.NewDeterministicSigner(k)
+	}
+
 	signer, err := jose.NewSigner(jose.SigningKey{
 		Algorithm: jose.SignatureAlgorithm(jwk.Algorithm),
 		Key:       jwk.Key,
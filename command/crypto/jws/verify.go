@@ -5,7 +5,9 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/jose"
 	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
@@ -14,10 +16,10 @@ import (
 func verifyCommand() cli.Command {
 	return cli.Command{
 		Name:   "verify",
-		Action: cli.ActionFunc(verifyAction),
+		Action: command.ActionFunc(verifyAction),
 		Usage:  "verify a signed JWS data structure and return the payload",
 		UsageText: `**step crypto jws verify**
-[**--alg**=<algorithm>] [**--key**=<path>] [**--jwks**=<jwks>] [**--kid**=<kid>]`,
+[**--alg**=<algorithm>] [**--key**=<path>] [**--jwks**=<jwks>] [**--kid**=<kid>] [**--no-cache**]`,
 		Description: `**step crypto jws verify** reads a JWS data structure from STDIN; checks that
 the algorithm are in agreement with expectations; verifies the digital
 signature or message authentication code as appropriate; and outputs the
@@ -55,10 +57,12 @@ in RFC 2898).`,
 			cli.StringFlag{
 				Name: "jwks",
 				Usage: `The JWK Set containing the key to use to verify the JWS. The <jwks> argument
-should be the name of a file. The file contents should be a JWK Set or a JWE
-with a JWK Set payload. The JWS being verified should have a "kid" member that
-matches the "kid" of one of the JWKs in the JWK Set. If the JWS does not have
-a "kid" member the '--kid' flag can be used.`,
+should be the name of a file or an https URL. The file contents should be a JWK
+Set or a JWE with a JWK Set payload. Remote JWK Sets are cached in
+$STEPPATH/cache following the Cache-Control headers of the response. The JWS
+being verified should have a "kid" member that matches the "kid" of one of the
+JWKs in the JWK Set. If the JWS does not have a "kid" member the '--kid' flag
+can be used.`,
 			},
 			cli.StringFlag{
 				Name: "kid",
@@ -71,6 +75,8 @@ member its value must match <kid> or verification will fail.`,
 				Usage: `Displays the header, payload and signature as a JSON object. The payload will
 be encoded using Base64.`,
 			},
+			flags.NoCache,
+			flags.Experimental,
 			cli.BoolFlag{
 				Name:   "subtle",
 				Hidden: true,
@@ -88,6 +94,10 @@ func publicKey(jwk *jose.JSONWebKey) interface{} {
 	if jose.IsSymmetric(jwk) {
 		return jwk.Key
 	}
+	// Keys not supported by go-jose are verified using an opaque verifier.
+	if v, ok := jose.NewOpaqueVerifier(jwk.Key); ok {
+		return v
+	}
 	return jwk.Public().Key
 }
 
@@ -136,6 +146,9 @@ func verifyAction(ctx *cli.Context) error {
 	if !ctx.Bool("insecure") {
 		options = append(options, jose.WithNoDefaults(true))
 	}
+	if ctx.Bool("no-cache") {
+		options = append(options, jose.WithNoCache(true))
+	}
 
 	// Read key from --key or --jwks
 	var jwk *jose.JSONWebKey
@@ -150,6 +163,9 @@ func verifyAction(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if jose.IsExperimental(jwk) && !ctx.Bool("experimental") {
+		return errs.RequiredExperimentalFlag(ctx, "key")
+	}
 
 	// At this moment jwk.Algorithm should have an alg from:
 	//  * alg parameter
@@ -53,6 +53,12 @@ func inspectAction(ctx *cli.Context) error {
 }
 
 func printToken(token string) error {
+	return printTokenWithPayload(token, nil)
+}
+
+// printTokenWithPayload prints the token replacing its payload with the given
+// one if it's not nil.
+func printTokenWithPayload(token string, payload []byte) error {
 	tok, err := jose.ParseJWS(token)
 	if err != nil {
 		return errors.Wrap(jose.TrimPrefix(err), "error parsing token")
@@ -73,9 +79,10 @@ func printToken(token string) error {
 		return errors.Wrapf(err, "error decoding token")
 	}
 
-	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
-	if err != nil {
-		return errors.Wrapf(err, "error decoding token")
+	if payload == nil {
+		if payload, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+			return errors.Wrapf(err, "error decoding token")
+		}
 	}
 
 	m := make(map[string]json.RawMessage)
@@ -0,0 +1,98 @@
+package jwt
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/jose"
+)
+
+// jtiStore is the content of the file used by the --jti-store flag. It
+// contains the ids of the verified tokens until they expire.
+type jtiStore struct {
+	Tokens []jtiEntry `json:"tokens"`
+}
+
+// jtiEntry is a verified token in a jtiStore.
+type jtiEntry struct {
+	Issuer string `json:"iss,omitempty"`
+	ID     string `json:"jti"`
+	Expiry int64  `json:"exp"`
+}
+
+// checkReplay records the id of the token with the given claims in the store,
+// and fails if it was already recorded. The expired tokens are removed from
+// the store. The store is locked while it's being read and modified, so
+// concurrent verifications of the same token are detected.
+func checkReplay(filename string, claims *jose.Claims, now time.Time) (err error) {
+	switch {
+	case claims.ID == "":
+		return errs.Policy(errors.New("validation failed: token does not have a jti claim, required by '--jti-store'"))
+	case claims.Expiry == nil:
+		return errs.Policy(errors.New("validation failed: token does not have an exp claim, required by '--jti-store'"))
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return errs.FileError(err, filename)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "error locking %s", filename)
+	}
+	defer func() {
+		if err1 := unlockFile(f); err1 != nil && err == nil {
+			err = errors.Wrapf(err1, "error unlocking %s", filename)
+		}
+		if err1 := f.Close(); err1 != nil && err == nil {
+			err = errors.Wrapf(err1, "error closing %s", filename)
+		}
+	}()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return errors.Wrapf(err, "error reading %s", filename)
+	}
+	var store jtiStore
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &store); err != nil {
+			return errors.Wrapf(err, "error reading %s", filename)
+		}
+	}
+
+	tokens := store.Tokens[:0]
+	for _, t := range store.Tokens {
+		if t.Expiry < now.Unix() {
+			continue
+		}
+		if t.Issuer == claims.Issuer && t.ID == claims.ID {
+			return errs.Policy(errors.Errorf("validation failed: token with jti %s has already been used", claims.ID))
+		}
+		tokens = append(tokens, t)
+	}
+	store.Tokens = append(tokens, jtiEntry{
+		Issuer: claims.Issuer,
+		ID:     claims.ID,
+		Expiry: claims.Expiry.Time().Unix(),
+	})
+
+	if b, err = json.MarshalIndent(store, "", "  "); err != nil {
+		return errors.Wrapf(err, "error marshaling %s", filename)
+	}
+	if err := f.Truncate(0); err != nil {
+		return errors.Wrapf(err, "error writing %s", filename)
+	}
+	n, err := f.WriteAt(b, 0)
+	switch {
+	case err != nil:
+		return errors.Wrapf(err, "error writing %s", filename)
+	case n < len(b):
+		return errors.Wrapf(io.ErrShortWrite, "error writing %s", filename)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+// +build !windows
+
+package jwt
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on the given file, waiting until it's
+// available.
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+// unlockFile releases the lock taken with lockFile.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
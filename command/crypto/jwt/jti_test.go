@@ -0,0 +1,56 @@
+package jwt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallstep/cli/jose"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "step-jti")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "jti.json")
+
+	now := time.Now()
+	claims := func(iss, jti string, exp time.Time) *jose.Claims {
+		return &jose.Claims{
+			Issuer: iss,
+			ID:     jti,
+			Expiry: jose.NewNumericDate(exp),
+		}
+	}
+
+	// First use
+	require.NoError(t, checkReplay(filename, claims("webhooks", "1", now.Add(time.Minute)), now))
+	require.NoError(t, checkReplay(filename, claims("webhooks", "2", now.Add(time.Minute)), now))
+	// Same jti with a different issuer
+	require.NoError(t, checkReplay(filename, claims("other", "1", now.Add(time.Minute)), now))
+
+	// Replays
+	require.Error(t, checkReplay(filename, claims("webhooks", "1", now.Add(time.Minute)), now))
+	require.Error(t, checkReplay(filename, claims("other", "1", now.Add(time.Minute)), now))
+
+	// Expired tokens are removed
+	later := now.Add(2 * time.Minute)
+	require.NoError(t, checkReplay(filename, claims("webhooks", "1", later.Add(time.Minute)), later))
+	var store jtiStore
+	b, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &store))
+	require.Equal(t, []jtiEntry{{Issuer: "webhooks", ID: "1", Expiry: later.Add(time.Minute).Unix()}}, store.Tokens)
+
+	// Required claims
+	require.Error(t, checkReplay(filename, &jose.Claims{ID: "3"}, now))
+	require.Error(t, checkReplay(filename, claims("webhooks", "", now.Add(time.Minute)), now))
+
+	// Invalid store
+	require.NoError(t, ioutil.WriteFile(filename, []byte("not json"), 0600))
+	require.Error(t, checkReplay(filename, claims("webhooks", "4", now.Add(time.Minute)), now))
+}
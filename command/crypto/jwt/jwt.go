@@ -77,6 +77,38 @@ $ echo $TOKEN | step crypto jwt verify --key p256.pub.json --iss "joe@example.co
 }
 '''
 
+Verify a token signed by either the old or the new key during a key rotation:
+'''
+$ echo $TOKEN | step crypto jwt verify --key old.pub.json --key new.pub.json \
+  --iss "joe@example.com" --aud "https://example.com"
+'''
+
+Verify the one-time-use token of a webhook, rejecting replays of a token that
+has already been verified:
+'''
+$ echo $TOKEN | step crypto jwt verify --key webhooks.pub.json \
+  --iss "https://hooks.example.com" --aud "https://example.com" \
+  --jti-store /var/lib/webhooks/jti.json
+'''
+
+Create a selective disclosure JWT (SD-JWT) where the email and the address can
+be disclosed independently, and verify it:
+'''
+$ echo '{"email":"joe@example.com","address":"1 Main St"}' | step crypto jwt sign \
+  --key p256.priv.json --iss "joe@example.com" --aud "https://example.com" \
+  --sub auth --exp $(date -v+1M +"%s") --sd email,address > token.sd
+$ cat token.sd | step crypto jwt verify --sd --key p256.pub.json \
+  --iss "joe@example.com" --aud "https://example.com"
+'''
+
+Create a reproducible token for a test fixture, signing the same claims twice
+produces the same ECDSA signature:
+'''
+$ step crypto jwt sign --key p256.priv.json --iss "joe@example.com" \
+  --aud "https://example.com" --sub auth --iat 1532564073 --nbf 1532564073 \
+  --exp 1535242472 --jti abc123 --deterministic --subtle
+'''
+
 Read the information in the previous token without verifying it:
 '''
 $ echo $TOKEN | step crypto jwt inspect --insecure
@@ -7,24 +7,29 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/smallstep/cli/crypto/randutil"
+	"github.com/smallstep/cli/clock"
+	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/step"
+	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
 )
 
 func signCommand() cli.Command {
 	return cli.Command{
 		Name:   "sign",
-		Action: cli.ActionFunc(signAction),
+		Action: command.ActionFunc(signAction),
 		Usage:  "create a signed JWT data structure",
 		UsageText: `**step crypto jwt sign** [- | <filename>]
 [**--alg**=<algorithm>] [**--aud**=<audience>] [**--iss**=<issuer>] [**--sub**=<sub>]
 [**--exp**=<expiration>] [**--iat**=<issued_at>] [**--nbf**=<not-before>] [**--key**=<path>]
-[**--jwks**=<jwks>] [**--kid**=<kid>] [**--jti**=<jti>]`,
+[**--jwks**=<jwks>] [**--kid**=<kid>] [**--jti**=<jti>] [**--sd**=<claims>]`,
 		Description: `**step crypto jwt sign** command generates a signed JSON Web Token (JWT) by
 computing a digital signature or message authentication code for a JSON
 payload. By default, the payload to sign is read from STDIN and the JWT will
@@ -55,8 +60,16 @@ A JWT signed using JWS has three parts:
     2. A base64 encoded JSON object representing the JWT Claims Set
     3. A base64 encoded digital signature of message authentication code
 
+With the **--sd** flag the command generates a selective disclosure JWT
+(SD-JWT). The given claims are replaced in the payload by the digests of their
+disclosures, and the disclosures are appended to the JWT separated by '~'. The
+holder of the SD-JWT can remove the disclosures of the claims that must not be
+revealed before presenting it. Only top-level claims can be selectively
+disclosed, and the registered claims required to validate the token, like
+**"iss"**, **"aud"**, **"exp"**, **"nbf"**, or **"iat"**, cannot.
+
 For examples, see **step help crypto jwt**.`,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			cli.StringFlag{
 				Name: "alg, algorithm",
 				Usage: `The signature or MAC algorithm to use. Algorithms are case-sensitive strings
@@ -169,7 +182,18 @@ with sufficient entropy to satisfy the collision-resistance criteria.`,
 				Usage: `The <path> to the key with which to sign the JWT.
 JWTs can be signed using a private JWK (or a JWK encrypted as a JWE payload) or
 a PEM encoded private key (or a private key encrypted using the modes described
-on RFC 1423 or with PBES2+PBKDF2 described in RFC 2898).`,
+on RFC 1423 or with PBES2+PBKDF2 described in RFC 2898). The key can also be
+read from an environment variable, e.g. env:STEP_KEY. Keys stored in a KMS, a
+YubiKey or a PKCS #11 token can be used with a key URI, e.g.
+awskms:alias/my-key, yubikey:9c or
+pkcs11:token=my-token;object=my-key?module-path=/usr/lib/softhsm/libsofthsm2.so.
+Keys in an ssh-agent can be selected using their fingerprint or
+comment, e.g. agent:SHA256:ZNTOMF9r2Ww4Nx6s3QVv7dWBpnbEZGYtlkrhoFhBAbA or
+agent:joe@example.com; the key material is never exposed to step. Keys in the
+Vault Transit secrets engine can be used with vault:transit/keys/<name>, and PEM
+keys stored in the Vault KV secrets engine with vault:<path>?field=<field>.
+If the key file is loaded in a running step agent, the agent signs the JWT and
+the key is not decrypted.`,
 			},
 			cli.StringFlag{
 				Name: "jwks",
@@ -185,9 +209,18 @@ string. When used with '--jwk' the <kid> value must match the **"kid"** member
 of the JWK. When used with **--jwks** (a JWK Set) the <kid> value must match
 the **"kid"** member of one of the JWKs in the JWK Set.`,
 			},
-			cli.StringFlag{
-				Name:  "password-file",
-				Usage: `The path to the <file> containing the password to decrypt the key.`,
+			cli.StringSliceFlag{
+				Name: "sd",
+				Usage: `The comma separated list of <claims> to selectively disclose, generating an
+SD-JWT. Use the '--sd' flag multiple times to add more claims.`,
+			},
+			cli.BoolFlag{
+				Name: "deterministic",
+				Usage: `Use deterministic nonces as defined in RFC 6979 for ECDSA signatures, the same
+payload signed with the same key always produces the same signature. It is
+useful for reproducible test fixtures or environments without a reliable source
+of randomness, but a fault in the signing process can leak the key. Requires the
+**--subtle** flag.`,
 			},
 			cli.BoolFlag{
 				Name:   "subtle",
@@ -197,13 +230,16 @@ the **"kid"** member of one of the JWKs in the JWK Set.`,
 				Name:   "no-kid",
 				Hidden: true,
 			},
-		},
+		}, flags.Password(cli.StringFlag{
+			Name:  "password-file",
+			Usage: `The path to the <file> containing the password to decrypt the key.`,
+		})...),
 	}
 }
 
 func signAction(ctx *cli.Context) error {
 	var err error
-	var payload interface{}
+	var payload map[string]interface{}
 
 	// Read payload if provided
 	args := ctx.Args()
@@ -240,7 +276,7 @@ func signAction(ctx *cli.Context) error {
 
 	// Add parse options
 	var options []jose.Option
-	options = append(options, jose.WithUse("sig"))
+	options = append(options, jose.WithUse("sig"), jose.WithStepAgent(true))
 	if len(alg) > 0 {
 		options = append(options, jose.WithAlg(alg))
 	}
@@ -250,8 +286,12 @@ func signAction(ctx *cli.Context) error {
 	if isSubtle {
 		options = append(options, jose.WithSubtle(true))
 	}
-	if passwordFile := ctx.String("password-file"); len(passwordFile) > 0 {
-		options = append(options, jose.WithPasswordFile(passwordFile))
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+	if len(password) > 0 {
+		options = append(options, jose.WithPassword(password))
 	}
 
 	// Read key from --key or --jwks
@@ -268,16 +308,6 @@ func signAction(ctx *cli.Context) error {
 		return err
 	}
 
-	// Public keys cannot be used for signing
-	if jwk.IsPublic() {
-		return errors.New("cannot use a public key for signing")
-	}
-
-	// Key "use" must be "sig" to use for signing
-	if jwk.Use != "sig" && jwk.Use != "" {
-		return errors.Errorf("invalid jwk use: found '%s', expecting 'sig' (signature)", jwk.Use)
-	}
-
 	// At this moment jwk.Algorithm should have an alg from:
 	//  * alg parameter
 	//  * jwk or jwkset
@@ -285,86 +315,79 @@ func signAction(ctx *cli.Context) error {
 	if jwk.Algorithm == "" {
 		return errors.New("flag '--alg' is required with the given key")
 	}
-	if err := jose.ValidateJWK(jwk); err != nil {
-		return err
-	}
 
 	// Validate exp
 	if !isSubtle && ctx.IsSet("exp") && jose.UnixNumericDate(ctx.Int64("exp")).Time().Before(time.Now()) {
 		return errors.New("flag '--exp' must be in the future unless the '--subtle' flag is provided")
 	}
 
-	// Add claims
-	c := &jose.Claims{
-		Issuer:    ctx.String("iss"),
-		Subject:   ctx.String("sub"),
-		Audience:  ctx.StringSlice("aud"),
-		Expiry:    jose.UnixNumericDate(ctx.Int64("exp")),
-		NotBefore: jose.UnixNumericDate(ctx.Int64("nbf")),
-		IssuedAt:  jose.UnixNumericDate(ctx.Int64("iat")),
-		ID:        ctx.String("jti"),
-	}
-	now := time.Now()
-	if c.NotBefore == nil {
-		c.NotBefore = jose.NewNumericDate(now)
-	}
-	if c.IssuedAt == nil {
-		c.IssuedAt = jose.NewNumericDate(now)
-	}
-	if c.ID == "" && ctx.IsSet("jti") {
-		if c.ID, err = randutil.Hex(40); err != nil {
-			return errors.Wrap(err, "error creating random jti")
-		}
-	}
-
 	// Validate recommended claims
 	if !isSubtle {
 		switch {
-		case len(c.Issuer) == 0:
+		case len(ctx.String("iss")) == 0:
 			return errors.New("flag '--iss' is required unless '--subtle' is used")
-		case len(c.Audience) == 0:
+		case len(ctx.StringSlice("aud")) == 0:
 			return errors.New("flag '--aud' is required unless '--subtle' is used")
-		case len(c.Subject) == 0:
+		case len(ctx.String("sub")) == 0:
 			return errors.New("flag '--sub' is required unless '--subtle' is used")
-		case c.Expiry == nil:
+		case ctx.Int64("exp") == 0:
 			return errors.New("flag '--exp' is required unless '--subtle' is used")
-		case c.Expiry.Time().Before(time.Now()):
-			return errors.New("flag '--exp' must be in the future unless '--subtle' is used")
 		}
 	}
-
-	// Sign
-	so := new(jose.SignerOptions)
-	so.WithType("JWT")
-	if !ctx.Bool("no-kid") && jwk.KeyID != "" {
-		so.WithHeader("kid", jwk.KeyID)
+	if ctx.Bool("deterministic") && !isSubtle {
+		return errs.RequiredWithFlag(ctx, "deterministic", "subtle")
 	}
-
-	signer, err := jose.NewSigner(jose.SigningKey{
-		Algorithm: jose.SignatureAlgorithm(jwk.Algorithm),
-		Key:       jwk.Key,
-	}, so)
-	if err != nil {
-		return errors.Wrapf(err, "error creating JWT signer")
-	}
-
-	// Some implementations only accept "aud" as a string.
-	// Using claim overwriting for this special case.
-	aud := make(map[string]interface{})
-	if len(c.Audience) == 1 {
-		aud["aud"] = c.Audience[0]
+	if err := clock.Check(); err != nil {
+		return err
 	}
 
-	raw, err := jose.Signed(signer).Claims(c).Claims(aud).Claims(payload).CompactSerialize()
+	raw, err := step.SignJWT(&step.JWTOptions{
+		Key:           jwk,
+		Issuer:        ctx.String("iss"),
+		Subject:       ctx.String("sub"),
+		Audience:      ctx.StringSlice("aud"),
+		Expiry:        unixTime(ctx.Int64("exp")),
+		NotBefore:     unixTime(ctx.Int64("nbf")),
+		IssuedAt:      unixTime(ctx.Int64("iat")),
+		ID:            ctx.String("jti"),
+		RandomID:      ctx.IsSet("jti"),
+		Payload:       payload,
+		Disclose:      sdClaimNames(ctx.StringSlice("sd")),
+		NoKeyID:       ctx.Bool("no-kid"),
+		Deterministic: ctx.Bool("deterministic"),
+		Subtle:        isSubtle,
+	})
 	if err != nil {
-		return errors.Wrapf(err, "error serializing JWT")
+		return err
 	}
 
 	fmt.Println(raw)
 	return nil
 }
 
-func readPayload(filename string) (interface{}, error) {
+// unixTime returns the time for the given seconds since the UNIX Epoch, or
+// the zero time if s is 0.
+func unixTime(s int64) time.Time {
+	if s == 0 {
+		return time.Time{}
+	}
+	return time.Unix(s, 0)
+}
+
+// sdClaimNames returns the claim names in the --sd flag values.
+func sdClaimNames(values []string) []string {
+	var names []string
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func readPayload(filename string) (map[string]interface{}, error) {
 	var r io.Reader
 	switch filename {
 	case "":
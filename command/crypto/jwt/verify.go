@@ -1,14 +1,19 @@
 package jwt
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/clock"
+	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
 )
@@ -16,11 +21,12 @@ import (
 func verifyCommand() cli.Command {
 	return cli.Command{
 		Name:   "verify",
-		Action: cli.ActionFunc(verifyAction),
+		Action: command.ActionFunc(verifyAction),
 		Usage:  "verify a signed JWT data structure and return the payload",
 		UsageText: `**step crypto jwt verify**
 		[**--aud**=<audience>] [**--iss**=<issuer>] [**--alg**=<algorithm>]
-		[**--key**=<path>] [**--jwks**=<jwks>] [**--kid**=<kid>]`,
+		[**--key**=<path>...] [**--jwks**=<jwks>...] [**--kid**=<kid>] [**--no-cache**]
+		[**--sd**] [**--jti-store**=<file>]`,
 		Description: `**step crypto jwt verify** reads a JWT data structure from STDIN; checks that
 the audience, issuer, and algorithm are in agreement with expectations;
 verifies the digital signature or message authentication code as appropriate;
@@ -39,8 +45,35 @@ For a JWT to be verified successfully:
     JWKs in JWKS
   * The JWT signature must be successfully verified
 
+The flags **--key** and **--jwks** can be used multiple times, and combined, to
+accept the JWT if it is signed by any of the given keys. This is useful during
+a key rotation, when tokens signed by the old and the new keys must be accepted
+for a period of time. If more than one key is given, the key that verified the
+JWT is reported on STDERR, and the keys that cannot be loaded, for example, a
+JWK Set without the requested <kid>, are skipped.
+
+With the **--sd** flag the input must be a selective disclosure JWT (SD-JWT),
+generated with **step crypto jwt sign --sd**. After verifying the signature of
+the JWT, every disclosure must match one of the digests in the **"_sd"** claim,
+and the disclosed claims are added to the payload printed on STDOUT. Key binding
+JWTs are not supported.
+
+With the **--jti-store** flag one-time-use tokens are protected against replays.
+The **"jti"** and **"iss"** claims of every verified token are recorded in the
+<file> until the token expires, and a token with a recorded **"jti"** is
+rejected. The token must have the **"jti"** and **"exp"** claims. The file is
+locked while it's being read and modified, so it can be shared by concurrent
+verifications, like a script that verifies the tokens of webhooks.
+
+## EXIT CODES
+
+This command returns 0 on success and \>0 if any error occurs. An invalid use
+of the command returns 3, a failure reading the token or the key returns 4, a
+malformed token or an invalid signature returns 5, and a token with claims
+that do not match the expectations returns 7.
+
 For examples, see **step help crypto jwt**.`,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			cli.StringFlag{
 				Name: "iss, issuer",
 				Usage: `The issuer of this JWT. The <issuer> must match the value of the **"iss"** claim in
@@ -61,21 +94,24 @@ algorithm for use with the key, then the **--alg** flag is required to prevent
 algorithm downgrade attacks. To disable this protection you can pass the
 **--insecure** flag and omit the **--alg** flag.`,
 			},
-			cli.StringFlag{
+			cli.StringSliceFlag{
 				Name: "key",
 				Usage: `The <path> to the key to use to verify the JWT.
 The contents of the file can be a public or private JWK (or a JWK
 encrypted as a JWE payload) or a public or private PEM (or a private key
 encrypted using the modes described on RFC 1423 or with PBES2+PBKDF2 described
-in RFC 2898).`,
+in RFC 2898). Use the '--key' flag multiple times to accept multiple keys.`,
 			},
-			cli.StringFlag{
+			cli.StringSliceFlag{
 				Name: "jwks",
 				Usage: `The JWK Set containing the key to use to verify the JWS. The <jwks> argument
-should be the name of a file. The file contents should be a JWK Set or a JWE
-with a JWK Set payload. The JWS being verified should have a "kid" member that
-matches the "kid" of one of the JWKs in the JWK Set. If the JWS does not have
-a "kid" member the '--kid' flag can be used.`,
+should be the name of a file or an https URL. The file contents should be a JWK
+Set or a JWE with a JWK Set payload. Remote JWK Sets are cached in
+$STEPPATH/cache following the Cache-Control headers of the response. The JWS
+being verified should have a "kid" member that matches the "kid" of one of the
+JWKs in the JWK Set. If the JWS does not have a "kid" member the '--kid' flag
+can be used. Use the '--jwks' flag multiple times to accept keys from multiple
+JWK Sets.`,
 			},
 			cli.StringFlag{
 				Name: "kid",
@@ -83,9 +119,16 @@ a "kid" member the '--kid' flag can be used.`,
 The KID argument is a case-sensitive string. If the input JWS has a "kid"
 member its value must match <kid> or verification will fail.`,
 			},
+			flags.NoCache,
+			cli.BoolFlag{
+				Name:  "sd",
+				Usage: `Verify a selective disclosure JWT (SD-JWT) and its disclosures.`,
+			},
 			cli.StringFlag{
-				Name:  "password-file",
-				Usage: `The path to the <file> containing the password to decrypt the key.`,
+				Name: "jti-store",
+				Usage: `The <file> where the ids of the verified tokens are recorded, a token with an
+id in the file is rejected as a replay. The file is created if it does not
+exist.`,
 			},
 			cli.BoolFlag{
 				Name:   "subtle",
@@ -99,7 +142,10 @@ member its value must match <kid> or verification will fail.`,
 				Name:   "insecure",
 				Hidden: true,
 			},
-		},
+		}, flags.Password(cli.StringFlag{
+			Name:  "password-file",
+			Usage: `The path to the <file> containing the password to decrypt the key.`,
+		})...),
 	}
 }
 
@@ -119,25 +165,34 @@ func publicKey(jwk *jose.JSONWebKey) interface{} {
 func verifyAction(ctx *cli.Context) error {
 	token, err := utils.ReadString(os.Stdin)
 	if err != nil {
-		return errors.Wrap(err, "error reading token")
+		return errs.IO(errors.Wrap(err, "error reading token"))
+	}
+	if err := clock.Check(); err != nil {
+		return err
+	}
+
+	// Split the issuer-signed JWT and the disclosures of an SD-JWT
+	var disclosures []string
+	if ctx.Bool("sd") {
+		if token, disclosures, err = jose.SplitSDJWT(token); err != nil {
+			return errs.Crypto(err)
+		}
 	}
 
 	tok, err := jose.ParseSigned(token)
 	if err != nil {
-		return errors.Errorf("error parsing token: %s", strings.TrimPrefix(err.Error(), "square/go-jose: "))
+		return errs.Crypto(errors.Errorf("error parsing token: %s", strings.TrimPrefix(err.Error(), "square/go-jose: ")))
 	}
 
 	// Validate key, jwks and kid
-	key := ctx.String("key")
-	jwks := ctx.String("jwks")
+	keys := ctx.StringSlice("key")
+	jwks := ctx.StringSlice("jwks")
 	kid := ctx.String("kid")
 	alg := ctx.String("alg")
 	switch {
-	case key == "" && jwks == "":
+	case len(keys) == 0 && len(jwks) == 0:
 		return errs.RequiredOrFlag(ctx, "key", "jwks")
-	case key != "" && jwks != "":
-		return errs.MutuallyExclusiveFlags(ctx, "key", "jwks")
-	case jwks != "" && kid == "":
+	case len(jwks) > 0 && kid == "":
 		if tok.Headers[0].KeyID == "" {
 			return errs.RequiredWithFlag(ctx, "kid", "jwks")
 		}
@@ -177,53 +232,49 @@ func verifyAction(ctx *cli.Context) error {
 	if !ctx.Bool("insecure") {
 		options = append(options, jose.WithNoDefaults(true))
 	}
-	if passwordFile := ctx.String("password-file"); len(passwordFile) > 0 {
-		options = append(options, jose.WithPasswordFile(passwordFile))
-	}
-
-	// Read key from --key or --jwks
-	var jwk *jose.JSONWebKey
-	switch {
-	case key != "":
-		jwk, err = jose.ParseKey(key, options...)
-	case jwks != "":
-		jwk, err = jose.ParseKeySet(jwks, options...)
-	default:
-		return errs.RequiredOrFlag(ctx, "key", "jwks")
+	if ctx.Bool("no-cache") {
+		options = append(options, jose.WithNoCache(true))
 	}
+	password, err := utils.ReadPasswordFromCLI(ctx)
 	if err != nil {
 		return err
 	}
-
-	// At this moment jwk.Algorithm should have an alg from:
-	//  * alg parameter
-	//  * jwk or jwkset
-	//  * guessed for ecdsa and ed25519 keys
-	if jwk.Algorithm == "" {
-		return errors.New("flag '--alg' is required with the given key")
+	if len(password) > 0 {
+		options = append(options, jose.WithPassword(password))
 	}
-	if err := jose.ValidateJWK(jwk); err != nil {
+
+	// Read keys from --key and --jwks
+	candidates, err := readVerifyKeys(keys, jwks, options)
+	if err != nil {
 		return err
 	}
 
 	// We don't support multiple signatures or any critical headers
 	if len(tok.Headers) > 1 {
-		return errors.New("validation failed: multiple signatures are not supported")
+		return errs.Policy(errors.New("validation failed: multiple signatures are not supported"))
 	}
 	if _, ok := tok.Headers[0].ExtraHeaders["crit"]; ok {
-		return errors.New("validation failed: unrecognized critical headers (crit)")
+		return errs.Policy(errors.New("validation failed: unrecognized critical headers (crit)"))
 	}
 	if !isSubtle && alg != "" && tok.Headers[0].Algorithm != "" && alg != tok.Headers[0].Algorithm {
-		return errors.Errorf("alg %s does not match the alg on JWT (%s)", alg, tok.Headers[0].Algorithm)
+		return errs.Policy(errors.Errorf("alg %s does not match the alg on JWT (%s)", alg, tok.Headers[0].Algorithm))
 	}
 
+	// Verify the signature with any of the keys
+	var matched *verifyKey
 	claims := jose.Claims{}
-	if err := tok.Claims(publicKey(jwk), &claims); err != nil {
+	for _, k := range candidates {
+		if err = tok.Claims(publicKey(k.jwk), &claims); err == nil {
+			matched = k
+			break
+		}
+	}
+	if matched == nil {
 		switch err {
 		case jose.ErrCryptoFailure:
-			return errors.New("validation failed: invalid signature")
+			return errs.Crypto(errors.New("validation failed: invalid signature"))
 		default:
-			return errors.Wrap(err, "claim verify failed")
+			return errs.Crypto(errors.Wrap(err, "claim verify failed"))
 		}
 	}
 
@@ -233,9 +284,9 @@ func verifyAction(ctx *cli.Context) error {
 	if err := tok.UnsafeClaimsWithoutVerification(&tClaims); err != nil {
 		switch err {
 		case jose.ErrCryptoFailure:
-			return errors.New("validation failed: invalid signature")
+			return errs.Crypto(errors.New("validation failed: invalid signature"))
 		default:
-			return errors.Wrap(err, "claim verify failed")
+			return errs.Crypto(errors.Wrap(err, "claim verify failed"))
 		}
 	}
 
@@ -251,32 +302,121 @@ func verifyAction(ctx *cli.Context) error {
 		return err
 	}
 
+	if len(keys)+len(jwks) > 1 {
+		if matched.jwk.KeyID != "" {
+			ui.Printf("The token was verified using %s (kid %s).\n", matched.source, matched.jwk.KeyID)
+		} else {
+			ui.Printf("The token was verified using %s.\n", matched.source)
+		}
+	}
+
+	var sdPayload []byte
+	if ctx.Bool("sd") {
+		var payload map[string]interface{}
+		if err := tok.UnsafeClaimsWithoutVerification(&payload); err != nil {
+			return errs.Crypto(errors.Wrap(err, "claim verify failed"))
+		}
+		disclosed, err := jose.ApplyDisclosures(payload, disclosures)
+		if err != nil {
+			return errs.Policy(errors.Wrap(err, "validation failed"))
+		}
+		if sdPayload, err = json.Marshal(disclosed); err != nil {
+			return errors.Wrap(err, "error marshaling payload")
+		}
+	}
+
+	// The token is only recorded once it's valid
+	if store := ctx.String("jti-store"); store != "" {
+		if err := checkReplay(store, &claims, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	if sdPayload != nil {
+		return printTokenWithPayload(token, sdPayload)
+	}
 	return printToken(token)
 }
 
+// verifyKey is a key that can be used to verify a token and the flag value
+// where it was read from.
+type verifyKey struct {
+	source string
+	jwk    *jose.JSONWebKey
+}
+
+// readVerifyKeys reads the keys in the given key files and JWK sets. If only
+// one source is given, any error is returned, with multiple sources, the ones
+// that cannot be used are skipped and an error is only returned if none of
+// them can be used.
+func readVerifyKeys(keys, jwks []string, options []jose.Option) ([]*verifyKey, error) {
+	var err error
+	var candidates []*verifyKey
+	single := len(keys)+len(jwks) == 1
+	sources := make([]string, 0, len(keys)+len(jwks))
+	sources = append(sources, keys...)
+	sources = append(sources, jwks...)
+	for i, source := range sources {
+		var jwk *jose.JSONWebKey
+		if i < len(keys) {
+			jwk, err = jose.ParseKey(source, options...)
+		} else {
+			jwk, err = jose.ParseKeySet(source, options...)
+		}
+		if err == nil {
+			err = validateVerifyKey(jwk)
+		}
+		if err != nil {
+			if single {
+				return nil, err
+			}
+			continue
+		}
+		candidates = append(candidates, &verifyKey{source: source, jwk: jwk})
+	}
+	if len(candidates) == 0 {
+		return nil, errors.Wrap(err, "none of the keys can be used to verify the token")
+	}
+	return candidates, nil
+}
+
+func validateVerifyKey(jwk *jose.JSONWebKey) error {
+	// At this moment jwk.Algorithm should have an alg from:
+	//  * alg parameter
+	//  * jwk or jwkset
+	//  * guessed for ecdsa and ed25519 keys
+	if jwk.Algorithm == "" {
+		return errs.Usage(errors.New("flag '--alg' is required with the given key"))
+	}
+	if err := jose.ValidateJWK(jwk); err != nil {
+		return errs.Crypto(err)
+	}
+	return nil
+}
+
 // validateClaimsWithLeeway is a custom implementation of go-jose
 // jwt.Claims.ValidateWithLeeway that returns all the errors found.
 func validateClaimsWithLeeway(ctx *cli.Context, c jose.Claims, e jose.Expected, t timeClaims, leeway time.Duration) error {
-	var errs []string
+	var failures []string
 
 	if e.Issuer != "" && e.Issuer != c.Issuer {
-		errs = append(errs, "invalid issuer claim (iss)")
+		failures = append(failures, "invalid issuer claim (iss)")
 	}
 
 	// we're not currently checking the subject
 	if e.Subject != "" && e.Subject != c.Subject {
-		errs = append(errs, "invalid subject subject (sub)")
+		failures = append(failures, "invalid subject subject (sub)")
 	}
 
 	// we're not currently checking the id
 	if e.ID != "" && e.ID != c.ID {
-		errs = append(errs, "invalid ID claim (jti)")
+		failures = append(failures, "invalid ID claim (jti)")
 	}
 
 	if len(e.Audience) != 0 {
 		for _, v := range e.Audience {
 			if !c.Audience.Contains(v) {
-				errs = append(errs, "invalid audience claim (aud)")
+				failures = append(failures, "invalid audience claim (aud)")
 				break
 			}
 		}
@@ -285,19 +425,19 @@ func validateClaimsWithLeeway(ctx *cli.Context, c jose.Claims, e jose.Expected,
 	// Only if nbf is defined, just in case is tested in time <0 :)
 	if t.NotBefore != nil {
 		if !e.Time.IsZero() && e.Time.Add(leeway).Before(c.NotBefore.Time()) {
-			errs = append(errs, "token not valid yet (nbf)")
+			failures = append(failures, "token not valid yet (nbf)")
 		}
 	}
 
 	// Only if exp is defined and no-exp-check is not used
 	if t.Expiry != nil && !ctx.Bool("no-exp-check") {
 		if !e.Time.IsZero() && e.Time.Add(-leeway).After(c.Expiry.Time()) {
-			errs = append(errs, fmt.Sprintf("token is expired by %s (exp)", e.Time.Sub(c.Expiry.Time()).Round(time.Millisecond)))
+			failures = append(failures, fmt.Sprintf("token is expired by %s (exp)", e.Time.Sub(c.Expiry.Time()).Round(time.Millisecond)))
 		}
 	}
 
-	if len(errs) > 0 {
-		return errors.Errorf("validation failed: %s", strings.Join(errs, ", "))
+	if len(failures) > 0 {
+		return errs.Policy(errors.Errorf("validation failed: %s", strings.Join(failures, ", ")))
 	}
 
 	return nil
@@ -2,11 +2,15 @@ package kdf
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/smallstep/cli/crypto/kdf"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/fips"
+	"github.com/smallstep/cli/crypto/policy"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	"github.com/urfave/cli"
 )
@@ -175,6 +179,12 @@ func hashAction(ctx *cli.Context) error {
 	default:
 		return errs.InvalidFlagValue(ctx, "alg", alg, "")
 	}
+	if err := fips.CheckKDF(ctx.String("alg")); err != nil {
+		return err
+	}
+	if err := policy.CheckAlgorithm(ctx.String("alg")); err != nil {
+		return err
+	}
 
 	// Grab input from terminal or arguments
 	switch ctx.NArg() {
@@ -193,7 +203,9 @@ func hashAction(ctx *cli.Context) error {
 	}
 
 	// Hash input
+	spinner := ui.NewSpinner("Hashing...").Start()
 	hash, err := f(input)
+	spinner.Stop()
 	if err != nil {
 		return err
 	}
@@ -267,7 +279,19 @@ func compareAction(ctx *cli.Context) error {
 		return errs.TooManyArguments(ctx)
 	}
 
+	// The PHC string format is $<id>$...
+	if parts := strings.SplitN(string(hash), "$", 3); len(parts) == 3 {
+		if err := fips.CheckKDF(parts[1]); err != nil {
+			return err
+		}
+		if err := policy.CheckAlgorithm(parts[1]); err != nil {
+			return err
+		}
+	}
+
+	spinner := ui.NewSpinner("Comparing...").Start()
 	ok, err := kdf.Compare(input, hash)
+	spinner.Stop()
 	if err != nil {
 		return err
 	}
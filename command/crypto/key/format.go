@@ -13,6 +13,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/policy"
+	"github.com/smallstep/cli/crypto/pqc"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/ui"
@@ -49,6 +51,11 @@ defaults. For example we can use **--pkcs8** to save a PKCS#1 RSA key to the
 PKCS#8 form. Or we can combine **--pem** and **--pkcs8** to convert to PKCS#8 a
 PEM file.
 
+Encrypted PEM private keys will use the PKCS#8 format with PBES2, PBKDF2 and
+AES-256-CBC. The flag **--insecure-legacy-encryption** can be used to encrypt
+PKCS#1 and SEC1 keys with the legacy encryption defined in RFC 1423. Both
+forms, and PKCS#8 keys using scrypt or AES-GCM, can be read.
+
 ## POSITIONAL ARGUMENTS
 
 <crt_file>
@@ -94,7 +101,7 @@ Convert an ASN.1 DER format to the DER-encoded PKCS#8 format:
 '''
 $ step crypto key format --der --pkcs8 key.der --out key-pkcs8.der
 '''`,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			cli.BoolFlag{
 				Name:  "pkcs8",
 				Usage: "Convert RSA and ECDSA private keys to PKCS#8 PEM/DER format.",
@@ -113,10 +120,6 @@ set it will always switch to the other format.`,
 				Name:  "out",
 				Usage: "Path to write the reformatted result.",
 			},
-			cli.StringFlag{
-				Name:  "password-file",
-				Usage: "Location of file containing passphrase to decrypt private key.",
-			},
 			cli.BoolFlag{
 				Name: "no-password",
 				Usage: `Do not ask for a password to encrypt a private key with PEM format. Sensitive
@@ -125,7 +128,14 @@ Requires **--insecure** flag.`,
 			},
 			flags.Insecure,
 			flags.Force,
-		},
+			flags.LegacyEncryption,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
+		}, flags.Password(cli.StringFlag{
+			Name:  "password-file",
+			Usage: "Location of file containing passphrase to decrypt private key.",
+		})...),
 	}
 }
 
@@ -154,6 +164,17 @@ func formatAction(ctx *cli.Context) error {
 	if noPassword && !insecure {
 		return errs.RequiredInsecureFlag(ctx, "no-password")
 	}
+	if noPassword {
+		if err := policy.CheckKeyEncryption(); err != nil {
+			return err
+		}
+	}
+
+	// The password is read only once, a file descriptor cannot be read twice.
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return err
+	}
 
 	b, err := ioutil.ReadFile(keyFile)
 	if err != nil {
@@ -163,8 +184,8 @@ func formatAction(ctx *cli.Context) error {
 	switch {
 	case bytes.HasPrefix(b, []byte("-----BEGIN ")): // PEM format:
 		opts := []pemutil.Options{pemutil.WithFilename(keyFile)}
-		if passFile := ctx.String("password-file"); passFile != "" {
-			opts = append(opts, pemutil.WithPasswordFile(passFile))
+		if len(password) > 0 {
+			opts = append(opts, pemutil.WithPassword(password))
 		}
 		if key, err = pemutil.Parse(b, opts...); err != nil {
 			return err
@@ -185,7 +206,7 @@ func formatAction(ctx *cli.Context) error {
 
 	switch {
 	case toPEM:
-		if ob, err = convertToPEM(ctx, key); err != nil {
+		if ob, err = convertToPEM(ctx, key, password); err != nil {
 			return err
 		}
 	case toDER:
@@ -212,14 +233,15 @@ func formatAction(ctx *cli.Context) error {
 	return nil
 }
 
-func convertToPEM(ctx *cli.Context, key interface{}) (b []byte, err error) {
+func convertToPEM(ctx *cli.Context, key interface{}, password []byte) (b []byte, err error) {
 	opts := []pemutil.Options{
 		pemutil.WithPKCS8(ctx.Bool("pkcs8")),
+		pemutil.WithLegacyEncryption(ctx.Bool("insecure-legacy-encryption")),
 	}
 	// Add password if necessary
 	if _, ok := key.(crypto.PrivateKey); ok && !ctx.Bool("no-password") {
-		if passFile := ctx.String("password-file"); passFile != "" {
-			opts = append(opts, pemutil.WithPasswordFile(passFile))
+		if len(password) > 0 {
+			opts = append(opts, pemutil.WithPassword(password))
 		} else {
 			opts = append(opts, pemutil.WithPasswordPrompt("Please enter the password to encrypt the private key"))
 		}
@@ -245,9 +267,9 @@ func convertToDER(ctx *cli.Context, key interface{}) (b []byte, err error) {
 		} else {
 			b, err = x509.MarshalECPrivateKey(k)
 		}
-	case ed25519.PrivateKey: // always PKCS#8
+	case ed25519.PrivateKey, *pqc.PrivateKey: // always PKCS#8
 		b, err = pemutil.MarshalPKCS8PrivateKey(key)
-	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey: // always PKIX
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey, *pqc.PublicKey: // always PKIX
 		b, err = pemutil.MarshalPKIXPublicKey(key)
 	default:
 		return nil, errors.Errorf("unsupoorted key type %T", key)
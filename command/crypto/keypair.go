@@ -5,6 +5,7 @@ import (
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/crypto/keys"
 	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/policy"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/flags"
 	"github.com/smallstep/cli/jose"
@@ -20,7 +21,8 @@ func createKeyPairCommand() cli.Command {
 		Usage:  "generate a public / private keypair in PEM format",
 		UsageText: `**step crypto keypair** <pub_file> <priv_file>
 [**--kty**=<key-type>] [**--curve**=<curve>] [**--size**=<size>]
-[**--password-file**=<file>] [**--no-password**]`,
+[**--password-file**=<file>] [**--no-password**] [**--experimental**]
+[**--metadata**] [**--intended-use**=<description>]`,
 		Description: `**step crypto keypair** generates a raw public /
 private keypair in PEM format. These keys can be used by other operations
 to sign and encrypt data, and the public key can be bound to an identity
@@ -74,8 +76,21 @@ Create an Octet Key Pair with curve Ed25519:
 '''
 $ step crypto keypair foo.pub foo.key --kty OKP --curve Ed25519
 '''
+
+Create an experimental post-quantum ML-DSA-65 key pair:
+
+'''
+$ step crypto keypair foo.pub foo.key --kty AKP --curve ML-DSA-65 --experimental
+'''
+
+Create a key pair and record what it is for in foo.pub.meta.json and
+foo.key.meta.json:
+
+'''
+$ step crypto keypair foo.pub foo.key --metadata --intended-use "release signing"
+'''
 `,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			cli.StringFlag{
 				Name:  "kty",
 				Value: "EC",
@@ -92,6 +107,11 @@ If unset, default is EC.
 
     **RSA**
     :  Create an **RSA** keypair
+
+    **AKP**
+    :  Create an experimental post-quantum keypair (for **"ML-DSA"** and
+    **"ML-KEM"** parameter sets). Requires **--experimental** and a step binary
+    built with the **pqc** tag.
 `,
 			},
 			cli.IntFlag{
@@ -118,6 +138,12 @@ unset, default is P-256 for EC keys and Ed25519 for OKP keys.
 
     **Ed25519**
     :  Ed25519 Curve
+
+    **ML-DSA-44**, **ML-DSA-65**, **ML-DSA-87**
+    :  ML-DSA parameter sets for AKP keys, ML-DSA-65 is the default
+
+    **ML-KEM-512**, **ML-KEM-768**, **ML-KEM-1024**
+    :  ML-KEM parameter sets for AKP keys
 `,
 			},
 			cli.StringFlag{
@@ -125,11 +151,17 @@ unset, default is P-256 for EC keys and Ed25519 for OKP keys.
 				Usage: `Create a PEM representing the key encoded in an
 existing <jwk-file> instead of creating a new key.`,
 			},
-			flags.PasswordFile,
 			flags.NoPassword,
 			flags.Insecure,
+			flags.Experimental,
+			flags.Metadata,
+			flags.IntendedUse,
 			flags.Force,
-		},
+			flags.LegacyEncryption,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
+		}, flags.Password(flags.PasswordFile)...),
 	}
 }
 
@@ -146,22 +178,30 @@ func createAction(ctx *cli.Context) (err error) {
 
 	insecure := ctx.Bool("insecure")
 	noPass := ctx.Bool("no-password")
-	passwordFile := ctx.String("password-file")
-	if noPass && len(passwordFile) > 0 {
-		return errs.IncompatibleFlag(ctx, "no-password", "password-file")
+	passwordFlag, err := utils.GetPasswordFlag(ctx)
+	if err != nil {
+		return err
+	}
+	if noPass && len(passwordFlag) > 0 {
+		return errs.IncompatibleFlag(ctx, "no-password", passwordFlag)
 	}
 	if noPass && !insecure {
 		return errs.RequiredWithFlag(ctx, "insecure", "no-password")
 	}
-
-	// Read password if necessary
-	var password string
-	if len(passwordFile) > 0 {
-		password, err = utils.ReadStringPasswordFromFile(passwordFile)
-		if err != nil {
+	if noPass {
+		if err := policy.CheckKeyEncryption(); err != nil {
 			return err
 		}
 	}
+	if ctx.IsSet("intended-use") && !ctx.Bool("metadata") {
+		return errs.RequiredWithFlag(ctx, "intended-use", "metadata")
+	}
+
+	// Read password if necessary
+	password, err := utils.ReadStringPasswordFromCLI(ctx)
+	if err != nil {
+		return err
+	}
 
 	var pub, priv interface{}
 	fromJWK := ctx.String("from-jwk")
@@ -204,6 +244,19 @@ func createAction(ctx *cli.Context) (err error) {
 		return err
 	}
 
+	var md *utils.Metadata
+	if ctx.Bool("metadata") {
+		md = utils.NewMetadata(ctx)
+		md.SetPublicKey(pub)
+		md.Files = []string{pubFile}
+		if priv != nil {
+			md.Files = append(md.Files, privFile)
+		}
+		if err := utils.WriteMetadata(pubFile, md); err != nil {
+			return errs.FileError(err, pubFile+utils.MetadataExt)
+		}
+	}
+
 	if priv == nil {
 		ui.Printf("Your public key has been saved in %s.\n", pubFile)
 		ui.Println("Only the public PEM was generated.")
@@ -222,12 +275,19 @@ func createAction(ctx *cli.Context) (err error) {
 			return errors.Wrap(err, "error reading password")
 		}
 		_, err = pemutil.Serialize(priv, pemutil.WithPassword(pass),
+			pemutil.WithLegacyEncryption(ctx.Bool("insecure-legacy-encryption")),
 			pemutil.ToFile(privFile, 0600))
 		if err != nil {
 			return err
 		}
 	}
 
+	if md != nil {
+		if err := utils.WriteMetadata(privFile, md); err != nil {
+			return errs.FileError(err, privFile+utils.MetadataExt)
+		}
+	}
+
 	ui.Printf("Your public key has been saved in %s.\n", pubFile)
 	ui.Printf("Your private key has been saved in %s.\n", privFile)
 	return nil
@@ -2,6 +2,7 @@ package nacl
 
 import (
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -84,6 +85,24 @@ private key and validates the message from Bob using his public key:
 '''
 $ echo 0oM0A6xIezA6iMYssZECmbMRQh77mzDt | step crypto nacl box open nonce bob.box.pub alice.box.priv
 message
+'''
+
+Bob encrypts a message for Alice in an envelope, a JSON object with a random
+nonce, the algorithm and the identifiers of the keys, so it can be opened
+without remembering the nonce:
+'''
+$ echo message | step crypto nacl box seal --envelope alice.box.pub bob.box.priv > message.json
+$ step crypto nacl box open --envelope bob.box.pub alice.box.priv < message.json
+message
+'''
+
+Encrypt a message that either Alice or Bob can open, without authenticating the
+sender:
+'''
+$ echo message | step crypto nacl box seal \
+  --recipient alice.box.pub --hint alice --recipient bob.box.pub --hint bob > message.json
+$ step crypto nacl box open --envelope bob.box.priv < message.json
+message
 '''`,
 		Subcommands: cli.Commands{
 			boxKeypairCommand(),
@@ -113,7 +132,7 @@ For examples, see **step help crypto nacl box**.
 
 <priv-file>
 :  The path to write the encrypted private key.`,
-		Flags: []cli.Flag{flags.Force},
+		Flags: []cli.Flag{flags.Force, flags.Mode, flags.Owner, flags.Group},
 	}
 }
 
@@ -123,11 +142,18 @@ func boxOpenCommand() cli.Command {
 		Action: cli.ActionFunc(boxOpenAction),
 		Usage:  "authenticate and decrypt a box produced by seal",
 		UsageText: `**step crypto nacl box open** <nonce> <sender-pub-key> <priv-key>
-		[--raw]`,
+		[--raw]
+
+**step crypto nacl box open** --envelope [<sender-pub-key>] <priv-key>`,
 		Description: `Authenticate and decrypt a box produced by seal using the specified KEY. If
 PRIV_KEY is encrypted you will be prompted for the password. The sealed box is
 read from STDIN and the decrypted plaintext is written to STDOUT.
 
+With the **--envelope** flag the input is an envelope produced by
+**step crypto nacl box seal --envelope**, and the nonce is read from it. The
+<sender-pub-key> is required for authenticated envelopes, and must not be
+given for envelopes sealed with the **--recipient** flag.
+
 This command uses an implementation of NaCl's crypto_box_open function.
 
 For examples, see **step help crypto nacl box**.
@@ -147,6 +173,10 @@ For examples, see **step help crypto nacl box**.
 				Name:  "raw",
 				Usage: "Indicates that input is not base64 encoded",
 			},
+			cli.BoolFlag{
+				Name:  "envelope",
+				Usage: "Indicates that input is a JSON envelope produced by seal",
+			},
 		},
 	}
 }
@@ -157,11 +187,27 @@ func boxSealCommand() cli.Command {
 		Action: cli.ActionFunc(boxSealAction),
 		Usage:  "produce an authenticated and encrypted ciphertext",
 		UsageText: `**step crypto nacl box seal** <nonce> <recipient-pub-key> <priv-key>
-		[--raw]`,
+		[--raw]
+
+**step crypto nacl box seal** --envelope <recipient-pub-key> <priv-key>
+		[--hint=<hint>]
+
+**step crypto nacl box seal** --recipient=<file> [--hint=<hint>]
+		[--recipient=<file> [--hint=<hint>] ...]`,
 		Description: `Reads plaintext from STDIN and writes an encrypted and authenticated
 ciphertext to STDOUT. The "box" can be open by the a recipient who has access
 to the private key corresponding to <recipient-pub-key>.
 
+With the **--envelope** flag the output is a JSON envelope with a version, the
+algorithm, a random nonce, the identifiers of the recipient and sender keys,
+and the ciphertext. The key identifiers are the hex encoding of the first 8
+bytes of the SHA-256 digest of the public keys.
+
+With the **--recipient** flag the message is encrypted in an envelope that any
+of the recipients can open. The message is encrypted with a random key in a
+secretbox, and that key is encrypted for each recipient using an ephemeral key,
+so the sender is not authenticated.
+
 This command uses an implementation of NaCl's crypto_box function.
 
 For examples, see **step help crypto nacl box**.
@@ -181,6 +227,21 @@ For examples, see **step help crypto nacl box**.
 				Name:  "raw",
 				Usage: "Do not base64 encode output",
 			},
+			cli.BoolFlag{
+				Name:  "envelope",
+				Usage: "Write the ciphertext in a JSON envelope with a random nonce",
+			},
+			cli.StringSliceFlag{
+				Name: "recipient",
+				Usage: `The path to the public key <file> of a recipient of an anonymous envelope.
+Use the flag multiple times to encrypt the message for multiple recipients.`,
+			},
+			cli.StringSliceFlag{
+				Name: "hint",
+				Usage: `A <hint> stored in the envelope to identify the recipient, e.g. a name. When
+used with **--recipient**, the hint is assigned to the recipient in the same
+position.`,
+			},
 		},
 	}
 }
@@ -215,6 +276,9 @@ func boxKeypairAction(ctx *cli.Context) error {
 }
 
 func boxOpenAction(ctx *cli.Context) error {
+	if ctx.Bool("envelope") {
+		return boxOpenEnvelopeAction(ctx)
+	}
 	if err := errs.NumberOfArguments(ctx, 3); err != nil {
 		return err
 	}
@@ -277,6 +341,12 @@ func boxOpenAction(ctx *cli.Context) error {
 }
 
 func boxSealAction(ctx *cli.Context) error {
+	if ctx.Bool("envelope") || len(ctx.StringSlice("recipient")) > 0 {
+		return boxSealEnvelopeAction(ctx)
+	}
+	if len(ctx.StringSlice("hint")) > 0 {
+		return errs.RequiredWithFlag(ctx, "hint", "envelope")
+	}
 	if err := errs.NumberOfArguments(ctx, 3); err != nil {
 		return err
 	}
@@ -323,3 +393,136 @@ func boxSealAction(ctx *cli.Context) error {
 
 	return nil
 }
+
+func boxOpenEnvelopeAction(ctx *cli.Context) error {
+	if ctx.Bool("raw") {
+		return errs.IncompatibleFlagWithFlag(ctx, "raw", "envelope")
+	}
+	switch ctx.NArg() {
+	case 0:
+		return errs.TooFewArguments(ctx)
+	case 1, 2:
+	default:
+		return errs.TooManyArguments(ctx)
+	}
+
+	args := ctx.Args()
+	var senderPub *[32]byte
+	privFile := args[len(args)-1]
+	if len(args) == 2 {
+		pub, err := readBoxKey(args[0], "public")
+		if err != nil {
+			return err
+		}
+		senderPub = pub
+	}
+	priv, err := readBoxKey(privFile, "private")
+	if err != nil {
+		return err
+	}
+
+	input, err := utils.ReadAll(os.Stdin)
+	if err != nil {
+		return errs.Wrap(err, "error reading input")
+	}
+	env, err := parseEnvelope(input)
+	if err != nil {
+		return err
+	}
+	if env.Algorithm == envelopeSealedBox && senderPub != nil {
+		return errors.New("the envelope is anonymous, the sender public key must not be given")
+	}
+
+	raw, err := env.openBox(senderPub, priv)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.Write(raw)
+	return nil
+}
+
+func boxSealEnvelopeAction(ctx *cli.Context) error {
+	if ctx.Bool("raw") {
+		return errs.IncompatibleFlagWithFlag(ctx, "raw", "envelope")
+	}
+
+	hints := ctx.StringSlice("hint")
+	recipients := ctx.StringSlice("recipient")
+	if len(recipients) > 0 {
+		if err := errs.NumberOfArguments(ctx, 0); err != nil {
+			return err
+		}
+		if len(hints) > len(recipients) {
+			return errors.New("flag '--hint' cannot be used more times than flag '--recipient'")
+		}
+	} else {
+		if err := errs.NumberOfArguments(ctx, 2); err != nil {
+			return err
+		}
+		if len(hints) > 1 {
+			return errors.New("flag '--hint' can only be used once without flag '--recipient'")
+		}
+	}
+
+	var keys []*[32]byte
+	for _, fn := range recipients {
+		pub, err := readBoxKey(fn, "public")
+		if err != nil {
+			return err
+		}
+		keys = append(keys, pub)
+	}
+
+	var priv *[32]byte
+	if len(recipients) == 0 {
+		args := ctx.Args()
+		pub, err := readBoxKey(args[0], "public")
+		if err != nil {
+			return err
+		}
+		if priv, err = readBoxKey(args[1], "private"); err != nil {
+			return err
+		}
+		keys = append(keys, pub)
+	}
+
+	input, err := utils.ReadInput("Please enter text to seal")
+	if err != nil {
+		return errors.Wrap(err, "error reading input")
+	}
+
+	var env *envelope
+	if priv != nil {
+		var hint string
+		if len(hints) == 1 {
+			hint = hints[0]
+		}
+		env, err = sealBoxEnvelope(input, keys[0], priv, hint)
+	} else {
+		env, err = sealRecipientsEnvelope(input, keys, hints)
+	}
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(env)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling envelope")
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+// readBoxKey reads a 32 byte box key from a file.
+func readBoxKey(filename, typ string) (*[32]byte, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errs.FileError(err, filename)
+	} else if len(b) != 32 {
+		return nil, errors.Errorf("invalid %s key: key size is not 32 bytes", typ)
+	}
+	var k [32]byte
+	copy(k[:], b)
+	return &k, nil
+}
@@ -0,0 +1,282 @@
+package nacl
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const envelopeVersion = 1
+
+// Algorithms used in the envelopes.
+const (
+	// envelopeBox is an authenticated box between a sender and a recipient.
+	envelopeBox = "curve25519-xsalsa20-poly1305"
+	// envelopeSecretbox is a secretbox using a shared secret key.
+	envelopeSecretbox = "xsalsa20-poly1305"
+	// envelopeSealedBox is an anonymous box for one or more recipients, the
+	// message is encrypted with a random content key in a secretbox, and the
+	// content key is encrypted for each recipient in a box using an ephemeral
+	// key.
+	envelopeSealedBox = "curve25519-xsalsa20-poly1305-sealed"
+)
+
+// envelope is the self-describing JSON representation of a box or secretbox
+// ciphertext. It contains everything but the keys required to open it.
+type envelope struct {
+	Version      int                  `json:"v"`
+	Algorithm    string               `json:"alg"`
+	Nonce        string               `json:"nonce"`
+	KeyID        string               `json:"kid,omitempty"`
+	SenderKeyID  string               `json:"skid,omitempty"`
+	Hint         string               `json:"hint,omitempty"`
+	EphemeralKey string               `json:"epk,omitempty"`
+	Recipients   []*envelopeRecipient `json:"recipients,omitempty"`
+	Ciphertext   string               `json:"ciphertext"`
+}
+
+// envelopeRecipient contains the content key of a sealed box encrypted for
+// one recipient.
+type envelopeRecipient struct {
+	KeyID        string `json:"kid"`
+	Hint         string `json:"hint,omitempty"`
+	Nonce        string `json:"nonce"`
+	EncryptedKey string `json:"encrypted_key"`
+}
+
+// keyID returns the identifier of a key, the hex encoding of the first 8
+// bytes of its SHA-256 digest.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// secretKeyID returns the identifier of a secretbox key. The digest is domain
+// separated so the identifier is not the one of a public key with the same
+// bytes.
+func secretKeyID(key []byte) string {
+	return keyID(append([]byte("nacl-secretbox:"), key...))
+}
+
+// publicKey returns the public key of a box private key.
+func publicKey(priv *[32]byte) *[32]byte {
+	var pub [32]byte
+	curve25519.ScalarBaseMult(&pub, priv)
+	return &pub
+}
+
+func randomNonce() (*[24]byte, error) {
+	var n [24]byte
+	if _, err := io.ReadFull(rand.Reader, n[:]); err != nil {
+		return nil, errors.Wrap(err, "error generating nonce")
+	}
+	return &n, nil
+}
+
+// decodeKey decodes a base64url encoded 32 byte value from an envelope.
+func decodeKey(s, name string) (*[32]byte, error) {
+	var k [32]byte
+	b, err := b64Encoder.DecodeString(s)
+	if err != nil || len(b) != len(k) {
+		return nil, errors.Errorf("invalid envelope: %s is not valid", name)
+	}
+	copy(k[:], b)
+	return &k, nil
+}
+
+func decodeNonce(s string) (*[24]byte, error) {
+	var n [24]byte
+	b, err := b64Encoder.DecodeString(s)
+	if err != nil || len(b) != len(n) {
+		return nil, errors.New("invalid envelope: nonce is not valid")
+	}
+	copy(n[:], b)
+	return &n, nil
+}
+
+// sealBoxEnvelope encrypts and authenticates the message for the recipient
+// using a random nonce.
+func sealBoxEnvelope(msg []byte, pub, priv *[32]byte, hint string) (*envelope, error) {
+	n, err := randomNonce()
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{
+		Version:     envelopeVersion,
+		Algorithm:   envelopeBox,
+		Nonce:       b64Encoder.EncodeToString(n[:]),
+		KeyID:       keyID(pub[:]),
+		SenderKeyID: keyID(publicKey(priv)[:]),
+		Hint:        hint,
+		Ciphertext:  b64Encoder.EncodeToString(box.Seal(nil, msg, n, pub, priv)),
+	}, nil
+}
+
+// sealSecretboxEnvelope encrypts and authenticates the message using a random
+// nonce.
+func sealSecretboxEnvelope(msg []byte, key *[32]byte) (*envelope, error) {
+	n, err := randomNonce()
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{
+		Version:    envelopeVersion,
+		Algorithm:  envelopeSecretbox,
+		Nonce:      b64Encoder.EncodeToString(n[:]),
+		KeyID:      secretKeyID(key[:]),
+		Ciphertext: b64Encoder.EncodeToString(secretbox.Seal(nil, msg, n, key)),
+	}, nil
+}
+
+// sealRecipientsEnvelope encrypts the message for all the recipients. The
+// hints, if given, are stored with the recipient in the same position.
+func sealRecipientsEnvelope(msg []byte, recipients []*[32]byte, hints []string) (*envelope, error) {
+	var cek [32]byte
+	if _, err := io.ReadFull(rand.Reader, cek[:]); err != nil {
+		return nil, errors.Wrap(err, "error generating content key")
+	}
+	epk, esk, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating ephemeral key")
+	}
+	n, err := randomNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envelope{
+		Version:      envelopeVersion,
+		Algorithm:    envelopeSealedBox,
+		Nonce:        b64Encoder.EncodeToString(n[:]),
+		EphemeralKey: b64Encoder.EncodeToString(epk[:]),
+		Ciphertext:   b64Encoder.EncodeToString(secretbox.Seal(nil, msg, n, &cek)),
+	}
+	for i, pub := range recipients {
+		rn, err := randomNonce()
+		if err != nil {
+			return nil, err
+		}
+		r := &envelopeRecipient{
+			KeyID:        keyID(pub[:]),
+			Nonce:        b64Encoder.EncodeToString(rn[:]),
+			EncryptedKey: b64Encoder.EncodeToString(box.Seal(nil, cek[:], rn, pub, esk)),
+		}
+		if i < len(hints) {
+			r.Hint = hints[i]
+		}
+		env.Recipients = append(env.Recipients, r)
+	}
+	return env, nil
+}
+
+// parseEnvelope parses and validates the common fields of an envelope.
+func parseEnvelope(b []byte) (*envelope, error) {
+	env := new(envelope)
+	if err := json.Unmarshal(b, env); err != nil {
+		return nil, errors.Wrap(err, "error parsing envelope")
+	}
+	if env.Version != envelopeVersion {
+		return nil, errors.Errorf("unsupported envelope version %d", env.Version)
+	}
+	switch env.Algorithm {
+	case envelopeBox, envelopeSecretbox, envelopeSealedBox:
+	case "":
+		return nil, errors.New("invalid envelope: alg is missing")
+	default:
+		return nil, errors.Errorf("unsupported envelope algorithm %s", env.Algorithm)
+	}
+	return env, nil
+}
+
+func (e *envelope) ciphertext() ([]byte, error) {
+	b, err := b64Encoder.DecodeString(e.Ciphertext)
+	if err != nil {
+		return nil, errors.New("invalid envelope: ciphertext is not valid")
+	}
+	return b, nil
+}
+
+// openBox authenticates and decrypts an authenticated box envelope. The
+// sender public key is only required for box envelopes, sealed boxes are
+// anonymous.
+func (e *envelope) openBox(senderPub, priv *[32]byte) ([]byte, error) {
+	pubID := keyID(publicKey(priv)[:])
+	switch e.Algorithm {
+	case envelopeBox:
+		if senderPub == nil {
+			return nil, errors.New("the envelope is authenticated, the sender public key is required")
+		}
+		if e.KeyID != "" && e.KeyID != pubID {
+			return nil, errors.Errorf("the envelope was sealed for the key %s, not %s", e.KeyID, pubID)
+		}
+		if e.SenderKeyID != "" && e.SenderKeyID != keyID(senderPub[:]) {
+			return nil, errors.Errorf("the envelope was sealed by the key %s, not %s", e.SenderKeyID, keyID(senderPub[:]))
+		}
+		n, err := decodeNonce(e.Nonce)
+		if err != nil {
+			return nil, err
+		}
+		ct, err := e.ciphertext()
+		if err != nil {
+			return nil, err
+		}
+		raw, ok := box.Open(nil, ct, n, senderPub, priv)
+		if !ok {
+			return nil, errors.New("error authenticating or decrypting input")
+		}
+		return raw, nil
+	case envelopeSealedBox:
+		epk, err := decodeKey(e.EphemeralKey, "epk")
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range e.Recipients {
+			if r.KeyID != pubID {
+				continue
+			}
+			rn, err := decodeNonce(r.Nonce)
+			if err != nil {
+				return nil, err
+			}
+			ek, err := b64Encoder.DecodeString(r.EncryptedKey)
+			if err != nil {
+				return nil, errors.New("invalid envelope: encrypted_key is not valid")
+			}
+			cek, ok := box.Open(nil, ek, rn, epk, priv)
+			if !ok || len(cek) != 32 {
+				return nil, errors.New("error authenticating or decrypting the content key")
+			}
+			var k [32]byte
+			copy(k[:], cek)
+			return e.openSecretbox(&k)
+		}
+		return nil, errors.Errorf("the envelope is not sealed for the key %s", pubID)
+	default:
+		return nil, errors.Errorf("the envelope algorithm %s cannot be opened with a box key", e.Algorithm)
+	}
+}
+
+// openSecretbox authenticates and decrypts the payload of a secretbox or
+// sealed box envelope with the given key.
+func (e *envelope) openSecretbox(key *[32]byte) ([]byte, error) {
+	n, err := decodeNonce(e.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := e.ciphertext()
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := secretbox.Open(nil, ct, n, key)
+	if !ok {
+		return nil, errors.New("error authenticating or decrypting input")
+	}
+	return raw, nil
+}
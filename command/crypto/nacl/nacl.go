@@ -3,6 +3,8 @@ package nacl
 import (
 	"encoding/base64"
 
+	"github.com/smallstep/cli/crypto/fips"
+	"github.com/smallstep/cli/crypto/policy"
 	"github.com/urfave/cli"
 )
 
@@ -32,6 +34,12 @@ JWT are not an issue, NaCl should be your default choice for cryptographic
 needs.
 
 For more information on NaCl visit https://nacl.cr.yp.to`,
+		Before: func(ctx *cli.Context) error {
+			if err := fips.Check("NaCl"); err != nil {
+				return err
+			}
+			return policy.CheckAlgorithm("NaCl")
+		},
 		Subcommands: cli.Commands{
 			authCommand(),
 			boxCommand(),
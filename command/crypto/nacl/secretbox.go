@@ -1,6 +1,7 @@
 package nacl
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -56,6 +57,14 @@ Decrypt and authenticate the message:
 '''
 $ echo o2NJTsIJsk0dl4epiBwS1mM4xFED7iE | step crypto nacl secretbox open nonce secretbox.key
 message
+'''
+
+Encrypt a message in an envelope with a random nonce and the key identifier,
+and decrypt it:
+'''
+$ cat message.txt | step crypto nacl secretbox seal --envelope secretbox.key > message.json
+$ step crypto nacl secretbox open --envelope secretbox.key < message.json
+message
 '''`,
 		Subcommands: cli.Commands{
 			secretboxOpenCommand(),
@@ -70,10 +79,15 @@ func secretboxOpenCommand() cli.Command {
 		Action: cli.ActionFunc(secretboxOpenAction),
 		Usage:  "authenticate and decrypt a box produced by seal",
 		UsageText: `**step crypto nacl secretbox open** <nonce> <key-file>
-		[--raw]`,
+		[--raw]
+
+**step crypto nacl secretbox open** --envelope <key-file>`,
 		Description: `**step crypto nacl secretbox open** verifies and decrypts a ciphertext using a
 secret key and a nonce.
 
+With the **--envelope** flag the input is an envelope produced by
+**step crypto nacl secretbox seal --envelope**, and the nonce is read from it.
+
 This command uses an implementation of NaCl's crypto_secretbox_open function.
 
 For examples, see **step help crypto nacl secretbox**.`,
@@ -82,6 +96,10 @@ For examples, see **step help crypto nacl secretbox**.`,
 				Name:  "raw",
 				Usage: "Indicates that input is not base64 encoded",
 			},
+			cli.BoolFlag{
+				Name:  "envelope",
+				Usage: "Indicates that input is a JSON envelope produced by seal",
+			},
 		},
 	}
 }
@@ -92,10 +110,17 @@ func secretboxSealCommand() cli.Command {
 		Action: cli.ActionFunc(secretboxSealAction),
 		Usage:  "produce an encrypted ciphertext",
 		UsageText: `**step crypto nacl secretbox seal** <nonce> <key-file>
-		[--raw]`,
+		[--raw]
+
+**step crypto nacl secretbox seal** --envelope <key-file>`,
 		Description: `**step crypto nacl secretbox seal** encrypts and authenticates a message using
 a secret key and a nonce.
 
+With the **--envelope** flag the output is a JSON envelope with a version, the
+algorithm, a random nonce, the key identifier and the ciphertext. The key
+identifier is derived from a SHA-256 digest of the key, so the envelope can be
+matched with its key without revealing it.
+
 This command uses an implementation of NaCl's crypto_secretbox function.
 
 For examples, see **step help crypto nacl secretbox**.`,
@@ -104,11 +129,18 @@ For examples, see **step help crypto nacl secretbox**.`,
 				Name:  "raw",
 				Usage: "Do not base64 encode output",
 			},
+			cli.BoolFlag{
+				Name:  "envelope",
+				Usage: "Write the ciphertext in a JSON envelope with a random nonce",
+			},
 		},
 	}
 }
 
 func secretboxOpenAction(ctx *cli.Context) error {
+	if ctx.Bool("envelope") {
+		return secretboxOpenEnvelopeAction(ctx)
+	}
 	if err := errs.NumberOfArguments(ctx, 2); err != nil {
 		return err
 	}
@@ -163,6 +195,9 @@ func secretboxOpenAction(ctx *cli.Context) error {
 }
 
 func secretboxSealAction(ctx *cli.Context) error {
+	if ctx.Bool("envelope") {
+		return secretboxSealEnvelopeAction(ctx)
+	}
 	if err := errs.NumberOfArguments(ctx, 2); err != nil {
 		return err
 	}
@@ -201,3 +236,83 @@ func secretboxSealAction(ctx *cli.Context) error {
 
 	return nil
 }
+
+func secretboxOpenEnvelopeAction(ctx *cli.Context) error {
+	if ctx.Bool("raw") {
+		return errs.IncompatibleFlagWithFlag(ctx, "raw", "envelope")
+	}
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	key, err := readSecretboxKey(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	input, err := utils.ReadAll(os.Stdin)
+	if err != nil {
+		return errors.Wrap(err, "error reading input")
+	}
+	env, err := parseEnvelope(input)
+	if err != nil {
+		return err
+	}
+	if env.Algorithm != envelopeSecretbox {
+		return errors.Errorf("the envelope algorithm %s cannot be opened with a secretbox key", env.Algorithm)
+	}
+	if kid := secretKeyID(key[:]); env.KeyID != "" && env.KeyID != kid {
+		return errors.Errorf("the envelope was sealed with the key %s, not %s", env.KeyID, kid)
+	}
+
+	raw, err := env.openSecretbox(key)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.Write(raw)
+	return nil
+}
+
+func secretboxSealEnvelopeAction(ctx *cli.Context) error {
+	if ctx.Bool("raw") {
+		return errs.IncompatibleFlagWithFlag(ctx, "raw", "envelope")
+	}
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	key, err := readSecretboxKey(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	input, err := utils.ReadInput("Please enter text to seal")
+	if err != nil {
+		return errors.Wrap(err, "error reading input")
+	}
+
+	env, err := sealSecretboxEnvelope(input, key)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(env)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling envelope")
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+// readSecretboxKey reads a 32 byte secretbox key from a file.
+func readSecretboxKey(filename string) (*[32]byte, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errs.FileError(err, filename)
+	} else if len(b) != 32 {
+		return nil, errors.New("invalid key: key size is not 32 bytes")
+	}
+	var k [32]byte
+	copy(k[:], b)
+	return &k, nil
+}
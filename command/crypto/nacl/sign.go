@@ -77,7 +77,7 @@ public key valid for verifying and signing messages.
 This command uses an implementation of NaCl's crypto_sign_keypair function.
 
 For examples, see **step help crypto nacl sign**.`,
-		Flags: []cli.Flag{flags.Force},
+		Flags: []cli.Flag{flags.Force, flags.Mode, flags.Owner, flags.Group},
 	}
 }
 
@@ -0,0 +1,149 @@
+package piv
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// COSE algorithms of the attestation statement.
+const (
+	coseES256 = -7
+	coseES384 = -35
+)
+
+// deviceAttestationPayload returns the payload of the response to an ACME
+// device-attest-01 challenge. The attestation object uses the "step" format:
+// the attestation chain of the key in x5c, and the signature of the key
+// authorization with the attested key in sig.
+func deviceAttestationPayload(chain []*x509.Certificate, signer crypto.Signer, keyAuthorization string) ([]byte, error) {
+	pub, ok := chain[0].PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("device-attest-01 requires an EC key")
+	}
+	var alg int64
+	var hash crypto.Hash
+	switch pub.Curve {
+	case elliptic.P256():
+		alg, hash = coseES256, crypto.SHA256
+	case elliptic.P384():
+		alg, hash = coseES384, crypto.SHA384
+	default:
+		return nil, errors.Errorf("unsupported elliptic curve %s", pub.Curve.Params().Name)
+	}
+
+	h := hash.New()
+	h.Write([]byte(keyAuthorization))
+	sig, err := signer.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing the key authorization")
+	}
+
+	x5c := make([]interface{}, len(chain))
+	for i, crt := range chain {
+		x5c[i] = crt.Raw
+	}
+	attObj, err := encodeCBOR(map[string]interface{}{
+		"fmt": "step",
+		"attStmt": map[string]interface{}{
+			"alg": alg,
+			"sig": sig,
+			"x5c": x5c,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(map[string]string{
+		"attObj": base64.RawURLEncoding.EncodeToString(attObj),
+	})
+	return b, errors.Wrap(err, "error marshaling payload")
+}
+
+// encodeCBOR encodes the given value using the canonical CBOR encoding
+// defined in RFC 7049 Section 3.9. It supports the types used by attestation
+// objects: maps with string keys, arrays, strings, byte strings and integers.
+func encodeCBOR(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCBOR(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCBOR(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case int64:
+		if v >= 0 {
+			writeCBORHead(buf, 0, uint64(v))
+		} else {
+			writeCBORHead(buf, 1, uint64(-1-v))
+		}
+	case []byte:
+		writeCBORHead(buf, 2, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		writeCBORHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		writeCBORHead(buf, 4, uint64(len(v)))
+		for _, e := range v {
+			if err := writeCBOR(buf, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// Canonical order: shorter keys first, then lexical order.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		writeCBORHead(buf, 5, uint64(len(v)))
+		for _, k := range keys {
+			writeCBORHead(buf, 3, uint64(len(k)))
+			buf.WriteString(k)
+			if err := writeCBOR(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.Errorf("unsupported CBOR type %T", v)
+	}
+	return nil
+}
+
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= 0xff:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= 0xffffffff:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
@@ -0,0 +1,181 @@
+package piv
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/go-piv/piv-go/piv"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func attestCommand() cli.Command {
+	return cli.Command{
+		Name:   "attest",
+		Action: command.ActionFunc(attestAction),
+		Usage:  "print the attestation certificate of a key in a PIV slot",
+		UsageText: `**step crypto piv attest** <slot>
+[**--serial**=<serial>] [**--out**=<file>]
+[**--acme-key-authorization**=<key-authorization>]`,
+		Description: `**step crypto piv attest** prints the attestation certificate of a key generated
+in a PIV slot, followed by the attestation certificate of the YubiKey (slot f9)
+that signs it. The chain can be verified using the Yubico PIV root CA, and
+proves that the key was generated in the YubiKey and cannot be exported.
+
+The attestation certificate also includes the serial number of the YubiKey, its
+firmware version, and the PIN and touch policies of the key. These values are
+printed to the standard error.
+
+Keys imported into a slot cannot be attested.
+
+With the **--acme-key-authorization** flag the command prints the payload of
+the response to an ACME device-attest-01 challenge instead. The payload
+contains an attestation object in the "step" format, with the attestation chain
+and the signature of the key authorization using the attested key, which must
+be an EC P-256 or P-384 key. The payload must be posted to the challenge URL
+using the ACME account key; this CLI does not include an ACME client. Only
+YubiKey attestations are supported.
+
+## POSITIONAL ARGUMENTS
+
+<slot>
+:  The PIV slot to attest, one of 9a, 9c, 9d or 9e.
+
+## EXAMPLES
+
+Print the attestation chain of the key in the slot 9a:
+'''
+$ step crypto piv attest 9a
+'''
+
+Save the attestation chain and verify it with the Yubico PIV root CA:
+'''
+$ step crypto piv attest --out attestation.crt 9a
+$ step certificate verify attestation.crt --roots yubico-piv-ca.crt
+'''
+
+Create the response to a device-attest-01 challenge with the token
+'evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA' for the key in the slot 9a, where
+the thumbprint of the ACME account key is 'LPJNul-wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ':
+'''
+$ step crypto piv attest 9a --out payload.json \
+  --acme-key-authorization evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA.LPJNul-wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ
+'''`,
+		Flags: []cli.Flag{
+			serialFlag,
+			cli.StringFlag{
+				Name:  "out",
+				Usage: `The <file> to write the attestation chain. Defaults to the standard output.`,
+			},
+			cli.StringFlag{
+				Name: "acme-key-authorization",
+				Usage: `The ACME <key-authorization> of a device-attest-01 challenge, the token of the
+challenge and the thumbprint of the account key joined by a dot. If set, the
+payload of the challenge response is printed instead of the attestation chain.`,
+			},
+			cli.StringFlag{
+				Name:  "pin",
+				Usage: `The YubiKey <pin> used to sign the key authorization. If not set it will be prompted when required.`,
+			},
+			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
+		},
+	}
+}
+
+var pinPolicyNames = map[piv.PINPolicy]string{
+	piv.PINPolicyNever:  "never",
+	piv.PINPolicyOnce:   "once",
+	piv.PINPolicyAlways: "always",
+}
+
+var touchPolicyNames = map[piv.TouchPolicy]string{
+	piv.TouchPolicyNever:  "never",
+	piv.TouchPolicyAlways: "always",
+	piv.TouchPolicyCached: "cached",
+}
+
+func attestAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	name := ctx.Args().First()
+	slot, err := parseSlot(name)
+	if err != nil {
+		return err
+	}
+
+	yk, err := openYubiKey(ctx)
+	if err != nil {
+		return err
+	}
+	defer yk.Close()
+
+	crt, err := yk.Attest(slot)
+	if err != nil {
+		return errors.Wrapf(err, "error attesting slot %s", name)
+	}
+	intermediate, err := yk.AttestationCertificate()
+	if err != nil {
+		return errors.Wrap(err, "error reading the attestation certificate")
+	}
+	attestation, err := piv.Verify(intermediate, crt)
+	if err != nil {
+		return errs.Crypto(errors.Wrapf(err, "error verifying the attestation of slot %s", name))
+	}
+
+	fmt.Fprintf(os.Stderr, "Serial: %d\n", attestation.Serial)
+	fmt.Fprintf(os.Stderr, "Version: %d.%d.%d\n", attestation.Version.Major, attestation.Version.Minor, attestation.Version.Patch)
+	fmt.Fprintf(os.Stderr, "PIN policy: %s\n", pinPolicyNames[attestation.PINPolicy])
+	fmt.Fprintf(os.Stderr, "Touch policy: %s\n", touchPolicyNames[attestation.TouchPolicy])
+
+	var buf bytes.Buffer
+	if keyAuthorization := ctx.String("acme-key-authorization"); keyAuthorization != "" {
+		auth := piv.KeyAuth{PIN: ctx.String("pin")}
+		if auth.PIN == "" {
+			auth.PINPrompt = func() (string, error) {
+				b, err := ui.PromptPassword("Please enter the YubiKey PIN")
+				return string(b), err
+			}
+		}
+		priv, err := yk.PrivateKey(slot, crt.PublicKey, auth)
+		if err != nil {
+			return errors.Wrapf(err, "error loading the private key in slot %s", name)
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return errors.Errorf("the key in slot %s cannot be used for signing", name)
+		}
+		payload, err := deviceAttestationPayload([]*x509.Certificate{crt, intermediate}, signer, keyAuthorization)
+		if err != nil {
+			return err
+		}
+		buf.Write(payload)
+		buf.WriteByte('\n')
+	} else {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: intermediate.Raw})
+	}
+
+	if out := ctx.String("out"); out != "" {
+		if err := utils.WriteFile(out, buf.Bytes(), 0644); err != nil {
+			return errs.FileError(err, out)
+		}
+		ui.PrintSelected("Attestation", out)
+		return nil
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,213 @@
+package piv
+
+import (
+	"encoding/pem"
+	"os"
+	"strings"
+
+	"github.com/go-piv/piv-go/piv"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func generateCommand() cli.Command {
+	return cli.Command{
+		Name:   "generate",
+		Action: command.ActionFunc(generateAction),
+		Usage:  "generate a new key in a PIV slot",
+		UsageText: `**step crypto piv generate** <slot>
+[**--kty**=<type>] [**--crv**=<curve>] [**--size**=<size>]
+[**--pin-policy**=<policy>] [**--touch-policy**=<policy>]
+[**--management-key**=<key>] [**--serial**=<serial>] [**--out**=<file>]`,
+		Description: `**step crypto piv generate** generates a new key in a PIV slot of a YubiKey and
+prints the public key in PEM format. The private key never leaves the YubiKey.
+
+If the slot already contains a key it will be replaced, the command will ask for
+confirmation unless the **--force** flag is used.
+
+## POSITIONAL ARGUMENTS
+
+<slot>
+:  The PIV slot to use, one of 9a, 9c, 9d or 9e.
+
+## EXAMPLES
+
+Generate a P-256 key in the slot 9a:
+'''
+$ step crypto piv generate 9a
+'''
+
+Generate an RSA key in the slot 9c that requires the PIN for every signature,
+and save the public key:
+'''
+$ step crypto piv generate --kty RSA --pin-policy always --out pub.pem 9c
+'''
+
+Generate a key that requires touching the YubiKey, using a custom management
+key:
+'''
+$ step crypto piv generate --touch-policy always \
+  --management-key 0123456789abcdef0123456789abcdef0123456789abcdef 9a
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "kty",
+				Value: "EC",
+				Usage: `The <type> of key to generate, EC or RSA.`,
+			},
+			cli.StringFlag{
+				Name:  "crv, curve",
+				Value: "P-256",
+				Usage: `The elliptic <curve> to use for EC keys, P-256 or P-384.`,
+			},
+			cli.IntFlag{
+				Name:  "size",
+				Value: 2048,
+				Usage: `The <size> in bits of RSA keys, 1024 or 2048.`,
+			},
+			cli.StringFlag{
+				Name:  "pin-policy",
+				Value: "once",
+				Usage: `The <policy> that defines when the PIN is required, never, once or always.`,
+			},
+			cli.StringFlag{
+				Name:  "touch-policy",
+				Value: "never",
+				Usage: `The <policy> that defines when the YubiKey must be touched, never, always or
+cached.`,
+			},
+			managementKeyFlag,
+			serialFlag,
+			cli.StringFlag{
+				Name:  "out",
+				Usage: `The <file> to write the public key. Defaults to the standard output.`,
+			},
+			flags.Force,
+			flags.Mode,
+			flags.Owner,
+			flags.Group,
+		},
+	}
+}
+
+var pinPolicies = map[string]piv.PINPolicy{
+	"never":  piv.PINPolicyNever,
+	"once":   piv.PINPolicyOnce,
+	"always": piv.PINPolicyAlways,
+}
+
+var touchPolicies = map[string]piv.TouchPolicy{
+	"never":  piv.TouchPolicyNever,
+	"always": piv.TouchPolicyAlways,
+	"cached": piv.TouchPolicyCached,
+}
+
+// keyAlgorithm returns the PIV algorithm for the --kty, --crv and --size
+// flags.
+func keyAlgorithm(ctx *cli.Context) (piv.Algorithm, error) {
+	switch kty := ctx.String("kty"); kty {
+	case "EC":
+		if ctx.IsSet("size") {
+			return 0, errs.IncompatibleFlagValue(ctx, "size", "kty", kty)
+		}
+		switch crv := ctx.String("crv"); crv {
+		case "P-256":
+			return piv.AlgorithmEC256, nil
+		case "P-384":
+			return piv.AlgorithmEC384, nil
+		default:
+			return 0, errs.InvalidFlagValue(ctx, "crv", crv, "P-256, P-384")
+		}
+	case "RSA":
+		if ctx.IsSet("crv") {
+			return 0, errs.IncompatibleFlagValue(ctx, "crv", "kty", kty)
+		}
+		switch size := ctx.Int("size"); size {
+		case 1024:
+			return piv.AlgorithmRSA1024, nil
+		case 2048:
+			return piv.AlgorithmRSA2048, nil
+		default:
+			return 0, errs.InvalidFlagValue(ctx, "size", ctx.String("size"), "1024, 2048")
+		}
+	default:
+		return 0, errs.InvalidFlagValue(ctx, "kty", kty, "EC, RSA")
+	}
+}
+
+func generateAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	name := ctx.Args().First()
+	slot, err := parseSlot(name)
+	if err != nil {
+		return err
+	}
+
+	alg, err := keyAlgorithm(ctx)
+	if err != nil {
+		return err
+	}
+	pinPolicy, ok := pinPolicies[ctx.String("pin-policy")]
+	if !ok {
+		return errs.InvalidFlagValue(ctx, "pin-policy", ctx.String("pin-policy"), "never, once, always")
+	}
+	touchPolicy, ok := touchPolicies[ctx.String("touch-policy")]
+	if !ok {
+		return errs.InvalidFlagValue(ctx, "touch-policy", ctx.String("touch-policy"), "never, always, cached")
+	}
+	managementKey, err := parseManagementKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	yk, err := openYubiKey(ctx)
+	if err != nil {
+		return err
+	}
+	defer yk.Close()
+
+	// Ask for confirmation before replacing an existing key.
+	if _, err := yk.Attest(slot); err == nil && !ctx.Bool("force") {
+		if ui.IsNonInteractive() {
+			return errs.Usage(errors.Errorf("slot %s already contains a key, use the '--force' flag to replace it", name))
+		}
+		str, err := ui.Prompt("The slot "+name+" already contains a key, would you like to replace it [y/n]", ui.WithValidateYesNo())
+		if err != nil {
+			return err
+		}
+		if s := strings.ToLower(strings.TrimSpace(str)); s != "y" && s != "yes" {
+			return errors.Errorf("slot %s already contains a key", name)
+		}
+	}
+
+	pub, err := yk.GenerateKey(managementKey, slot, piv.Key{
+		Algorithm:   alg,
+		PINPolicy:   pinPolicy,
+		TouchPolicy: touchPolicy,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error generating key in slot %s", name)
+	}
+
+	block, err := pemutil.Serialize(pub)
+	if err != nil {
+		return err
+	}
+	if out := ctx.String("out"); out != "" {
+		if err := utils.WriteFile(out, pem.EncodeToMemory(block), 0644); err != nil {
+			return errs.FileError(err, out)
+		}
+		ui.PrintSelected("Public Key", out)
+		return nil
+	}
+	return pem.Encode(os.Stdout, block)
+}
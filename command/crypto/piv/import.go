@@ -0,0 +1,93 @@
+package piv
+
+import (
+	"bytes"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func importCommand() cli.Command {
+	return cli.Command{
+		Name:   "import",
+		Action: command.ActionFunc(importAction),
+		Usage:  "store a certificate in a PIV slot",
+		UsageText: `**step crypto piv import** <slot> <crt-file>
+[**--management-key**=<key>] [**--serial**=<serial>]`,
+		Description: `**step crypto piv import** stores a certificate in a PIV slot of a YubiKey. The
+public key of the certificate must match the key in the slot.
+
+Applications that use the YubiKey as a smart card, like browsers or SSH clients
+using the PKCS#11 interface, read the certificate from the slot.
+
+## POSITIONAL ARGUMENTS
+
+<slot>
+:  The PIV slot to use, one of 9a, 9c, 9d or 9e.
+
+<crt-file>
+:  The certificate to store in the slot. If the file contains a chain only the
+first certificate will be stored.
+
+## EXAMPLES
+
+Request a certificate for the key in the slot 9a and store it in the YubiKey:
+'''
+$ step ca certificate --kms yubikey:9a joe@example.com joe.crt
+$ step crypto piv import 9a joe.crt
+'''`,
+		Flags: []cli.Flag{
+			managementKeyFlag,
+			serialFlag,
+		},
+	}
+}
+
+func importAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 2); err != nil {
+		return err
+	}
+
+	args := ctx.Args()
+	name, crtFile := args.Get(0), args.Get(1)
+	slot, err := parseSlot(name)
+	if err != nil {
+		return err
+	}
+	managementKey, err := parseManagementKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	crt, err := pemutil.ReadCertificate(crtFile)
+	if err != nil {
+		return err
+	}
+
+	yk, err := openYubiKey(ctx)
+	if err != nil {
+		return err
+	}
+	defer yk.Close()
+
+	// Keys imported into the slot cannot be attested, in that case the public
+	// key cannot be validated.
+	if attestation, err := yk.Attest(slot); err == nil {
+		pub, err := x509.MarshalPKIXPublicKey(attestation.PublicKey)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling public key")
+		}
+		if !bytes.Equal(pub, crt.RawSubjectPublicKeyInfo) {
+			return errs.Crypto(errors.Errorf("the public key in %s does not match the key in slot %s", crtFile, name))
+		}
+	}
+
+	if err := yk.SetCertificate(managementKey, slot, crt); err != nil {
+		return errors.Wrapf(err, "error storing certificate in slot %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package piv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-piv/piv-go/piv"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func listCommand() cli.Command {
+	return cli.Command{
+		Name:      "list",
+		Action:    command.ActionFunc(listAction),
+		Usage:     "list the YubiKeys available",
+		UsageText: `**step crypto piv list**`,
+		Description: `**step crypto piv list** prints the name, serial number and firmware version of
+the YubiKeys connected, and the slots with a key or a certificate.
+
+## EXAMPLES
+
+List the YubiKeys available:
+'''
+$ step crypto piv list
+Yubico YubiKey OTP+FIDO+CCID 00 00
+  Serial: 12345678
+  Version: 5.2.4
+  Slots: 9a, 9c
+'''`,
+	}
+}
+
+func listAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	cards, err := piv.Cards()
+	if err != nil {
+		return errors.Wrap(err, "error listing smart cards")
+	}
+
+	var found bool
+	for _, card := range cards {
+		if !strings.Contains(strings.ToLower(card), "yubikey") {
+			continue
+		}
+		found = true
+		yk, err := piv.Open(card)
+		if err != nil {
+			return errors.Wrapf(err, "error opening %s", card)
+		}
+		fmt.Println(card)
+		if serial, err := yk.Serial(); err == nil {
+			fmt.Printf("  Serial: %d\n", serial)
+		}
+		v := yk.Version()
+		fmt.Printf("  Version: %d.%d.%d\n", v.Major, v.Minor, v.Patch)
+		var used []string
+		for _, name := range []string{"9a", "9c", "9d", "9e"} {
+			slot, _ := parseSlot(name)
+			if _, err := yk.Attest(slot); err == nil {
+				used = append(used, name)
+			} else if _, err := yk.Certificate(slot); err == nil {
+				used = append(used, name)
+			}
+		}
+		if len(used) > 0 {
+			fmt.Printf("  Slots: %s\n", strings.Join(used, ", "))
+		}
+		yk.Close()
+	}
+
+	if !found {
+		return errors.New("no YubiKey found")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package piv
+
+import (
+	"encoding/hex"
+
+	"github.com/go-piv/piv-go/piv"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/kms/yubikey"
+	"github.com/urfave/cli"
+)
+
+// Command returns the cli.Command for piv and related subcommands.
+func Command() cli.Command {
+	return cli.Command{
+		Name:      "piv",
+		Usage:     "manage keys and certificates in YubiKey PIV slots",
+		UsageText: "step crypto piv <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step crypto piv** command group provides facilities to generate keys in the
+PIV slots of a YubiKey, to attest them and to import certificates.
+
+The keys in the PIV slots never leave the YubiKey, but they can be used anywhere
+a private key file is accepted using a URI like 'yubikey:9a'. The URI options
+'serial' and 'pin' can be used to select the YubiKey and to set the PIN, e.g.
+'yubikey:9a?serial=12345678&pin=123456'. If the PIN is required and not set it
+will be prompted.
+
+The supported slots are:
+
+**9a**
+:  Authentication, used for TLS client authentication.
+
+**9c**
+:  Digital signature, used to sign documents and tokens.
+
+**9d**
+:  Key management, used for key agreement.
+
+**9e**
+:  Card authentication, the PIN is never required to use this slot.
+
+## EXAMPLES
+
+Generate a new key in the slot 9a:
+'''
+$ step crypto piv generate 9a
+'''
+
+Request a certificate for the key in the slot 9a and store it in the YubiKey:
+'''
+$ step ca certificate --kms yubikey:9a joe@example.com joe.crt
+$ step crypto piv import 9a joe.crt
+'''
+
+Sign a JWT using the key in the slot 9c:
+'''
+$ step crypto jwt sign --key yubikey:9c --iss joe --aud example.com --sub joe --exp $(date -v+1M +"%s")
+'''
+
+Use the key in the slot 9a for TLS client authentication:
+'''
+$ step tls handshake --cert joe.crt --key yubikey:9a example.com:443
+'''`,
+		Subcommands: cli.Commands{
+			listCommand(),
+			generateCommand(),
+			attestCommand(),
+			importCommand(),
+		},
+	}
+}
+
+var serialFlag = cli.StringFlag{
+	Name: "serial",
+	Usage: `The <serial> number of the YubiKey to use. Defaults to the first YubiKey
+available.`,
+}
+
+var managementKeyFlag = cli.StringFlag{
+	Name: "management-key",
+	Usage: `The hex-encoded PIV management <key>. Defaults to the factory management key
+010203040506070801020304050607080102030405060708.`,
+}
+
+// openYubiKey opens the YubiKey selected by the --serial flag.
+func openYubiKey(ctx *cli.Context) (*piv.YubiKey, error) {
+	return yubikey.Open(ctx.String("serial"))
+}
+
+// parseManagementKey returns the management key in the --management-key flag
+// or the default management key if the flag is not set.
+func parseManagementKey(ctx *cli.Context) ([24]byte, error) {
+	key := piv.DefaultManagementKey
+	s := ctx.String("management-key")
+	if s == "" {
+		return key, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(key) {
+		return key, errs.InvalidFlagValue(ctx, "management-key", s, "")
+	}
+	copy(key[:], b)
+	return key, nil
+}
+
+// parseSlot returns the PIV slot in the given argument.
+func parseSlot(name string) (piv.Slot, error) {
+	slot, err := yubikey.ParseSlot(name)
+	return slot, errs.Usage(errors.Cause(err))
+}
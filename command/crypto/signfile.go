@@ -0,0 +1,297 @@
+package crypto
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/artifact"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/utils"
+)
+
+func signFileCommand() cli.Command {
+	return cli.Command{
+		Name:   "sign-file",
+		Action: command.ActionFunc(signFileAction),
+		Usage:  "create a detached signature of a file using a certificate",
+		UsageText: `**step crypto sign-file** <file> **--cert**=<file> **--key**=<file>
+[**--format**=<format>] [**--tsa**=<url>] [**--out**=<file>]
+[**--password-file**=<file>]`,
+		Description: `**step crypto sign-file** creates a detached signature of a file, like a release
+artifact, using a certificate and its private key. The signature includes the
+certificate and its intermediates, so it can be verified with
+**step crypto verify-file** knowing only the root certificate. By default
+**step crypto verify-file** requires the code signing extended key usage in the
+certificate.
+
+The signature can be encoded in two formats:
+
+**cms**
+:  A PEM encoded CMS (PKCS#7) signed data structure without the content. It can
+also be verified using OpenSSL:
+'''
+$ openssl cms -verify -binary -inform PEM -in file.p7s -content file -CAfile root_ca.crt
+'''
+
+**jws**
+:  A JWS in the flattened JSON serialization with a detached payload, defined
+in RFC 7515 Appendix F. The certificate chain is in the **"x5c"** header.
+
+With the **--tsa** flag the signature value is timestamped by an RFC 3161
+time-stamping authority, and the timestamp token is included in the signature.
+A timestamp proves that the signature was created while the certificate was
+valid, so the signature can be verified after the certificate expires.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The path to the file to sign.
+
+## EXAMPLES
+
+Sign a release using a code signing certificate issued by step-ca:
+'''
+$ step ca certificate release@example.com release.crt release.key --profile code-signing
+$ step crypto sign-file step_linux_amd64.tar.gz \
+  --cert release.crt --key release.key --out step_linux_amd64.tar.gz.p7s
+'''
+
+Sign a file in the jws format with a timestamp:
+'''
+$ step crypto sign-file step_linux_amd64.tar.gz \
+  --cert release.crt --key release.key --format jws \
+  --tsa http://timestamp.digicert.com --out step_linux_amd64.tar.gz.jws
+'''
+
+Sign a file with a key in a KMS:
+'''
+$ step crypto sign-file step_linux_amd64.tar.gz \
+  --cert release.crt --key awskms:alias/release --out step_linux_amd64.tar.gz.p7s
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name: "cert",
+				Usage: `The path to the signing certificate <file>. Any other certificate in the file is
+included in the signature as an intermediate.`,
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: `The path to the private key <file> of the certificate, or a KMS key URI.`,
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Value: artifact.FormatCMS,
+				Usage: `The <format> of the signature, **cms** or **jws**.`,
+			},
+			cli.StringFlag{
+				Name:  "tsa",
+				Usage: `The <url> of an RFC 3161 time-stamping authority used to timestamp the signature.`,
+			},
+			cli.StringFlag{
+				Name:  "out, output-file",
+				Usage: `The <file> to write the signature to. Defaults to STDOUT.`,
+			},
+		}, flags.Password(flags.PasswordFile)...),
+	}
+}
+
+func verifyFileCommand() cli.Command {
+	return cli.Command{
+		Name:   "verify-file",
+		Action: command.ActionFunc(verifyFileAction),
+		Usage:  "verify a detached signature of a file created with step crypto sign-file",
+		UsageText: `**step crypto verify-file** <file> <signature-file> **--roots**=<file>
+[**--purpose**=<purpose>] [**--tsa-roots**=<file>] [**--require-timestamp**]`,
+		Description: `**step crypto verify-file** verifies a detached signature of a file created with
+**step crypto sign-file**, in the cms or jws format.
+
+For a signature to be verified successfully:
+
+  * The signature must be a valid signature of the file
+  * The signing certificate must chain to one of the **--roots**
+  * The signing certificate must have the extended key usage in **--purpose**
+  * If the signature has a timestamp, the timestamp must be a valid signature of
+    the signature value by a time-stamping authority chaining to one of the
+    **--tsa-roots**, whose certificate has the time stamping extended key usage,
+    and the certificate chain is verified at the time of the timestamp;
+    otherwise it is verified at the current time. A signature with a timestamp
+    cannot be verified without **--tsa-roots**
+
+On success the subject of the signing certificate and the time of the timestamp
+are printed, and the command returns 0.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The path to the signed file.
+
+<signature-file>
+:  The path to the signature.
+
+## EXAMPLES
+
+Verify the signature of a release:
+'''
+$ step crypto verify-file step_linux_amd64.tar.gz step_linux_amd64.tar.gz.p7s \
+  --roots root_ca.crt
+'''
+
+Verify a signature requiring a timestamp:
+'''
+$ step crypto verify-file step_linux_amd64.tar.gz step_linux_amd64.tar.gz.jws \
+  --roots root_ca.crt --require-timestamp
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "roots",
+				Usage: `The path to the PEM <file> with the root certificates trusted to issue the
+signing certificate. Use a comma-separated list of files, or a directory, to
+use multiple roots.`,
+			},
+			cli.StringFlag{
+				Name:  "purpose",
+				Value: "codeSigning",
+				Usage: `The extended key usage required in the signing certificate. The <purpose> is
+one of **codeSigning**, **emailProtection**, **clientAuth** or **serverAuth**.`,
+			},
+			cli.StringFlag{
+				Name: "tsa-roots",
+				Usage: `The path to the PEM <file> with the root certificates trusted to issue the
+certificate of the time-stamping authority. It is required to verify signatures
+with a timestamp, the system roots are not used.`,
+			},
+			cli.BoolFlag{
+				Name:  "require-timestamp",
+				Usage: `Fail if the signature does not have a timestamp.`,
+			},
+		},
+	}
+}
+
+func signFileAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	crtFile, keyFile := ctx.String("cert"), ctx.String("key")
+	switch {
+	case crtFile == "":
+		return errs.RequiredFlag(ctx, "cert")
+	case keyFile == "":
+		return errs.RequiredFlag(ctx, "key")
+	}
+
+	format := ctx.String("format")
+	switch format {
+	case artifact.FormatCMS, artifact.FormatJWS:
+	default:
+		return errs.InvalidFlagValue(ctx, "format", format, "cms, jws")
+	}
+
+	filename := ctx.Args().Get(0)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errs.FileError(err, filename)
+	}
+
+	var opts []pemutil.Options
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return err
+	}
+	if len(password) > 0 {
+		opts = append(opts, pemutil.WithPassword(password))
+	}
+	identity, err := x509util.LoadIdentityFromDisk(crtFile, keyFile, opts...)
+	if err != nil {
+		return err
+	}
+	bundle, err := pemutil.ReadCertificateBundle(crtFile)
+	if err != nil {
+		return err
+	}
+
+	sig, err := artifact.Sign(data, identity.Crt, identity.Key, artifact.SignOptions{
+		Format:        format,
+		Intermediates: bundle[1:],
+		TSA:           ctx.String("tsa"),
+	})
+	if err != nil {
+		return err
+	}
+
+	if out := ctx.String("out"); out != "" {
+		return utils.WriteFile(out, sig, 0644)
+	}
+	fmt.Print(string(sig))
+	return nil
+}
+
+// signFilePurposes are the extended key usages accepted by the --purpose flag
+// of verify-file.
+var signFilePurposes = map[string]x509.ExtKeyUsage{
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+}
+
+func verifyFileAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 2); err != nil {
+		return err
+	}
+
+	roots := ctx.String("roots")
+	if roots == "" {
+		return errs.RequiredFlag(ctx, "roots")
+	}
+	purpose := ctx.String("purpose")
+	eku, ok := signFilePurposes[purpose]
+	if !ok {
+		return errs.InvalidFlagValue(ctx, "purpose", purpose, "codeSigning, emailProtection, clientAuth, serverAuth")
+	}
+	opts := artifact.VerifyOptions{
+		KeyUsages:        []x509.ExtKeyUsage{eku},
+		RequireTimestamp: ctx.Bool("require-timestamp"),
+	}
+	var err error
+	if opts.Roots, err = x509util.ReadCertPool(roots); err != nil {
+		return errors.Wrapf(err, "error reading roots from %s", roots)
+	}
+	if tsaRoots := ctx.String("tsa-roots"); tsaRoots != "" {
+		if opts.TSARoots, err = x509util.ReadCertPool(tsaRoots); err != nil {
+			return errors.Wrapf(err, "error reading roots from %s", tsaRoots)
+		}
+	}
+
+	filename, sigFile := ctx.Args().Get(0), ctx.Args().Get(1)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errs.FileError(err, filename)
+	}
+	sig, err := ioutil.ReadFile(sigFile)
+	if err != nil {
+		return errs.FileError(err, sigFile)
+	}
+
+	s, err := artifact.Verify(data, sig, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Verified %s signature of %s\n", s.Format, filename)
+	fmt.Printf("Signed by: %s\n", s.Chain[0].Subject)
+	if s.Timestamp != nil {
+		fmt.Printf("Timestamp: %s\n", s.Timestamp.Time.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
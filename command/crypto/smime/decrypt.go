@@ -0,0 +1,66 @@
+package smime
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/smime"
+	"github.com/urfave/cli"
+)
+
+func decryptCommand() cli.Command {
+	return cli.Command{
+		Name:   "decrypt",
+		Action: command.ActionFunc(decryptAction),
+		Usage:  "decrypt an email message encrypted using S/MIME",
+		UsageText: `**step crypto smime decrypt** [<file>] **--cert**=<file> **--key**=<file>
+[**--out**=<file>] [**--password-file**=<file>]`,
+		Description: `**step crypto smime decrypt** decrypts an S/MIME enveloped message using the
+certificate and private key of one of its recipients, and prints the decrypted
+MIME entity. If the decrypted message is signed, it can be verified with
+**step crypto smime verify**.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The path to the encrypted message. Defaults to STDIN.
+
+## EXAMPLES
+
+Decrypt a message:
+'''
+$ step crypto smime decrypt message.eml --cert john.crt --key john.key
+'''
+
+Decrypt a signed and encrypted message, and verify the signature:
+'''
+$ step crypto smime decrypt message.eml --cert john.crt --key john.key \
+  | step crypto smime verify --roots root_ca.crt --email jane@example.com
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "cert",
+				Usage: `The path to the certificate <file> of the recipient.`,
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: `The path to the private key <file> of the recipient.`,
+			},
+			outFlag,
+		}, passwordFlags...),
+	}
+}
+
+func decryptAction(ctx *cli.Context) error {
+	msg, err := readInput(ctx)
+	if err != nil {
+		return err
+	}
+	identity, _, err := loadIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	entity, err := smime.Decrypt(msg, identity.Crt, identity.Key)
+	if err != nil {
+		return err
+	}
+	return writeOutput(ctx, entity)
+}
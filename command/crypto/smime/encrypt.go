@@ -0,0 +1,89 @@
+package smime
+
+import (
+	"crypto/x509"
+
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/smime"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func encryptCommand() cli.Command {
+	return cli.Command{
+		Name:   "encrypt",
+		Action: command.ActionFunc(encryptAction),
+		Usage:  "encrypt an email message using S/MIME",
+		UsageText: `**step crypto smime encrypt** [<file>] **--recipient**=<file>
+[**--text**] [**--from**=<address>] [**--to**=<address>] [**--subject**=<subject>]
+[**--out**=<file>]`,
+		Description: `**step crypto smime encrypt** encrypts an email message, a MIME entity, for one
+or more recipients and prints the S/MIME enveloped message.
+
+The content is encrypted with AES-256-CBC, and the content key is encrypted
+with the public key of each recipient. The certificates of the recipients must
+have RSA keys and the email protection extended key usage. To sign and encrypt
+a message, sign it first and encrypt the signed message.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The path to the message to encrypt. Defaults to STDIN.
+
+## EXAMPLES
+
+Encrypt a plain text file for a recipient:
+'''
+$ step crypto smime encrypt notes.txt --text --recipient john.crt \
+  --from jane@example.com --to john@example.com --subject "Notes" | sendmail -t
+'''
+
+Sign and encrypt a message, the sender is also a recipient to be able to read
+it later:
+'''
+$ step crypto smime sign message.txt --cert jane.crt --key jane.key \
+  | step crypto smime encrypt --recipient john.crt --recipient jane.crt
+'''`,
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name: "recipient",
+				Usage: `The path to the certificate <file> of a recipient. Use the flag multiple times
+to encrypt the message for multiple recipients.`,
+			},
+			textFlag,
+			fromFlag,
+			toFlag,
+			subjectFlag,
+			outFlag,
+		},
+	}
+}
+
+func encryptAction(ctx *cli.Context) error {
+	files := ctx.StringSlice("recipient")
+	if len(files) == 0 {
+		return errs.RequiredFlag(ctx, "recipient")
+	}
+	entity, err := readInput(ctx)
+	if err != nil {
+		return err
+	}
+	headers, err := emailHeaders(ctx)
+	if err != nil {
+		return err
+	}
+
+	recipients := make([]*x509.Certificate, len(files))
+	for i, fn := range files {
+		if recipients[i], err = pemutil.ReadCertificate(fn); err != nil {
+			return err
+		}
+	}
+
+	msg, err := smime.Encrypt(entity, recipients)
+	if err != nil {
+		return err
+	}
+	return writeOutput(ctx, append(headers, msg...))
+}
@@ -0,0 +1,102 @@
+package smime
+
+import (
+	"crypto"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/smime"
+	"github.com/urfave/cli"
+)
+
+func signCommand() cli.Command {
+	return cli.Command{
+		Name:   "sign",
+		Action: command.ActionFunc(signAction),
+		Usage:  "sign an email message using S/MIME",
+		UsageText: `**step crypto smime sign** [<file>] **--cert**=<file> **--key**=<file>
+[**--opaque**] [**--text**] [**--from**=<address>] [**--to**=<address>]
+[**--subject**=<subject>] [**--out**=<file>] [**--password-file**=<file>]`,
+		Description: `**step crypto smime sign** signs an email message, a MIME entity, and prints the
+S/MIME signed message.
+
+By default the message is multipart/signed, with the original message in the
+first part and the detached CMS signature in the second one, so it can be read
+by clients without S/MIME support. With **--opaque** the message is an
+application/pkcs7-mime entity with the content inside the signature.
+
+The certificate must have the email protection extended key usage, like the
+certificates created with the **smime** profile. Any other certificate in the
+file is included in the signature as an intermediate.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The path to the message to sign. Defaults to STDIN.
+
+## EXAMPLES
+
+Sign a MIME message:
+'''
+$ cat message.txt
+Content-Type: text/plain; charset=utf-8
+
+Hello, World!
+$ step crypto smime sign message.txt --cert jane.crt --key jane.key
+'''
+
+Sign a plain text file and send it:
+'''
+$ step crypto smime sign notes.txt --text --cert jane.crt --key jane.key \
+  --from jane@example.com --to john@example.com --subject "Notes" | sendmail -t
+'''`,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name: "cert",
+				Usage: `The path to the signing certificate <file>. Any other certificate in the file is
+included in the signature as an intermediate.`,
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: `The path to the private key <file> of the certificate.`,
+			},
+			cli.BoolFlag{
+				Name:  "opaque",
+				Usage: `Create an application/pkcs7-mime message instead of a multipart/signed one.`,
+			},
+			textFlag,
+			fromFlag,
+			toFlag,
+			subjectFlag,
+			outFlag,
+		}, passwordFlags...),
+	}
+}
+
+func signAction(ctx *cli.Context) error {
+	entity, err := readInput(ctx)
+	if err != nil {
+		return err
+	}
+	headers, err := emailHeaders(ctx)
+	if err != nil {
+		return err
+	}
+	identity, intermediates, err := loadIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	signer, ok := identity.Key.(crypto.Signer)
+	if !ok {
+		return errors.Errorf("unsupported private key type %T", identity.Key)
+	}
+
+	msg, err := smime.Sign(entity, identity.Crt, signer, smime.SignOptions{
+		Intermediates: intermediates,
+		Opaque:        ctx.Bool("opaque"),
+	})
+	if err != nil {
+		return err
+	}
+	return writeOutput(ctx, append(headers, msg...))
+}
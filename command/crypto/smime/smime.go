@@ -0,0 +1,223 @@
+package smime
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// Command returns the cli.Command for smime and related subcommands.
+func Command() cli.Command {
+	return cli.Command{
+		Name:      "smime",
+		Usage:     "sign, verify, encrypt and decrypt email using S/MIME",
+		UsageText: "step crypto smime <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step crypto smime** command group provides facilities to sign, verify, encrypt
+and decrypt email messages using S/MIME 4.0, defined in RFC 8551, with
+certificates created with the **smime** profile of **step certificate create**
+or **step ca certificate**.
+
+The messages are MIME entities, a header with at least a Content-Type followed
+by an empty line and the body. Use the **--text** flag to sign or encrypt a
+plain text file. The signed and encrypted messages can be sent using any mail
+transfer agent, the flags **--from**, **--to** and **--subject** add the email
+headers to them.
+
+Signed messages are multipart/signed by default, so clients without S/MIME
+support can read them. Encrypted messages use AES-256-CBC for the content, and
+the RSA keys of the recipients to encrypt the content key, the only key
+transport supported by most email clients.
+
+## EXAMPLES
+
+Create an S/MIME certificate:
+'''
+$ step ca certificate --profile smime jane@example.com jane.crt jane.key
+'''
+
+Sign a plain text message and send it:
+'''
+$ step crypto smime sign message.txt --text --cert jane.crt --key jane.key \
+  --from jane@example.com --to john@example.com --subject "Hello" | sendmail -t
+'''
+
+Verify a signed message:
+'''
+$ step crypto smime verify message.eml --roots root_ca.crt
+'''
+
+Encrypt a message for two recipients:
+'''
+$ step crypto smime encrypt message.txt --text \
+  --recipient john.crt --recipient jane.crt --out message.eml
+'''
+
+Decrypt a message:
+'''
+$ step crypto smime decrypt message.eml --cert john.crt --key john.key
+'''`,
+		Subcommands: cli.Commands{
+			signCommand(),
+			verifyCommand(),
+			encryptCommand(),
+			decryptCommand(),
+		},
+	}
+}
+
+var (
+	textFlag = cli.BoolFlag{
+		Name:  "text",
+		Usage: `Add a text/plain Content-Type header to the input, a plain text file.`,
+	}
+
+	fromFlag = cli.StringFlag{
+		Name:  "from",
+		Usage: `The <address> added to the From header of the output.`,
+	}
+
+	toFlag = cli.StringSliceFlag{
+		Name: "to",
+		Usage: `The <address> added to the To header of the output. Use the flag multiple
+times to add multiple addresses.`,
+	}
+
+	subjectFlag = cli.StringFlag{
+		Name:  "subject",
+		Usage: `The <subject> added to the Subject header of the output.`,
+	}
+
+	outFlag = cli.StringFlag{
+		Name:  "out, output-file",
+		Usage: `The <file> to write the output to. Defaults to STDOUT.`,
+	}
+
+	passwordFlags = flags.Password(flags.PasswordFile)
+)
+
+// readInput reads the file in the first argument, or STDIN if it is not
+// given. With the --text flag a text/plain header is added to it.
+func readInput(ctx *cli.Context) ([]byte, error) {
+	if ctx.NArg() > 1 {
+		return nil, errs.TooManyArguments(ctx)
+	}
+	filename := ctx.Args().Get(0)
+	if filename == "" {
+		filename = "-"
+	}
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, errs.FileError(err, filename)
+	}
+	if ctx.Bool("text") {
+		b = append([]byte("Content-Type: text/plain; charset=utf-8\r\n\r\n"), b...)
+	}
+	return b, nil
+}
+
+// emailHeaders returns the From, To and Subject headers in the flags.
+func emailHeaders(ctx *cli.Context) ([]byte, error) {
+	var buf bytes.Buffer
+	if from := ctx.String("from"); from != "" {
+		addr, err := mail.ParseAddress(from)
+		if err != nil {
+			return nil, errs.InvalidFlagValue(ctx, "from", from, "")
+		}
+		buf.WriteString("From: " + addr.String() + "\r\n")
+	}
+	if to := ctx.StringSlice("to"); len(to) > 0 {
+		addrs := make([]string, len(to))
+		for i, s := range to {
+			addr, err := mail.ParseAddress(s)
+			if err != nil {
+				return nil, errs.InvalidFlagValue(ctx, "to", s, "")
+			}
+			addrs[i] = addr.String()
+		}
+		buf.WriteString("To: " + strings.Join(addrs, ", ") + "\r\n")
+	}
+	if subject := ctx.String("subject"); subject != "" {
+		buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// loadIdentity loads the certificate and private key in the --cert and --key
+// flags.
+func loadIdentity(ctx *cli.Context) (*x509util.Identity, []*x509.Certificate, error) {
+	crtFile, keyFile := ctx.String("cert"), ctx.String("key")
+	switch {
+	case crtFile == "":
+		return nil, nil, errs.RequiredFlag(ctx, "cert")
+	case keyFile == "":
+		return nil, nil, errs.RequiredFlag(ctx, "key")
+	}
+
+	var opts []pemutil.Options
+	password, err := utils.ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(password) > 0 {
+		opts = append(opts, pemutil.WithPassword(password))
+	}
+	identity, err := x509util.LoadIdentityFromDisk(crtFile, keyFile, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	bundle, err := pemutil.ReadCertificateBundle(crtFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return identity, bundle[1:], nil
+}
+
+// writeOutput writes b to the file in the --out flag or to STDOUT.
+func writeOutput(ctx *cli.Context, b []byte) error {
+	if out := ctx.String("out"); out != "" {
+		return utils.WriteFile(out, b, 0600)
+	}
+	if _, err := os.Stdout.Write(b); err != nil {
+		return errors.Wrap(err, "error writing output")
+	}
+	return nil
+}
+
+// senderAddress returns the address in the From header of the message, or an
+// empty string if it does not have one.
+func senderAddress(msg []byte) (string, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing message")
+	}
+	from := m.Header.Get("From")
+	if from == "" {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing From header")
+	}
+	return addr.Address, nil
+}
+
+// printCertificate prints the subject and the email addresses of the given
+// certificate.
+func printCertificate(label string, crt *x509.Certificate) {
+	fmt.Printf("%s: %s\n", label, crt.Subject)
+	if len(crt.EmailAddresses) > 0 {
+		fmt.Printf("Email: %s\n", strings.Join(crt.EmailAddresses, ", "))
+	}
+}
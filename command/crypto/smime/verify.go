@@ -0,0 +1,109 @@
+package smime
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/smime"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+func verifyCommand() cli.Command {
+	return cli.Command{
+		Name:   "verify",
+		Action: command.ActionFunc(verifyAction),
+		Usage:  "verify an email message signed using S/MIME",
+		UsageText: `**step crypto smime verify** [<file>] **--roots**=<file>
+[**--email**=<address>] [**--out**=<file>]`,
+		Description: `**step crypto smime verify** verifies an S/MIME signed message, multipart/signed
+or application/pkcs7-mime.
+
+For a signature to be verified successfully:
+
+  * The signature must be a valid signature of the content
+  * The signing certificate must chain to one of the **--roots**
+  * The signing certificate must have the email protection extended key usage
+  * The signing certificate must be valid for the **--email** address, that
+    defaults to the address in the From header of the message
+
+On success the subject and email addresses of the signing certificate are
+printed, and the command returns 0. The signed content can be written to a file
+using the **--out** flag.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The path to the signed message. Defaults to STDIN.
+
+## EXAMPLES
+
+Verify a signed message:
+'''
+$ step crypto smime verify message.eml --roots root_ca.crt
+'''
+
+Verify a message without a From header and extract the content:
+'''
+$ step crypto smime verify message.eml --roots root_ca.crt \
+  --email jane@example.com --out content.txt
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "roots",
+				Usage: `The path to the PEM <file> with the root certificates trusted to issue the
+signing certificate. Use a comma-separated list of files, or a directory, to
+use multiple roots.`,
+			},
+			cli.StringFlag{
+				Name: "email",
+				Usage: `The email <address> that must be in the signing certificate. Defaults to the
+address in the From header of the message.`,
+			},
+			cli.StringFlag{
+				Name:  "out, output-file",
+				Usage: `The <file> to write the signed content to.`,
+			},
+		},
+	}
+}
+
+func verifyAction(ctx *cli.Context) error {
+	roots := ctx.String("roots")
+	if roots == "" {
+		return errs.RequiredFlag(ctx, "roots")
+	}
+	msg, err := readInput(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts := smime.VerifyOptions{
+		Email: ctx.String("email"),
+	}
+	if opts.Roots, err = x509util.ReadCertPool(roots); err != nil {
+		return errors.Wrapf(err, "error reading roots from %s", roots)
+	}
+	if opts.Email == "" {
+		if opts.Email, err = senderAddress(msg); err != nil {
+			return err
+		}
+	}
+
+	sig, err := smime.Verify(msg, opts)
+	if err != nil {
+		return err
+	}
+
+	if out := ctx.String("out"); out != "" {
+		if err := utils.WriteFile(out, sig.Content, 0600); err != nil {
+			return err
+		}
+	}
+	fmt.Println("Verified S/MIME signature")
+	printCertificate("Signed by", sig.Certificate)
+	return nil
+}
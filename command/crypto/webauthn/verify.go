@@ -0,0 +1,334 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/webauthn"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ed25519"
+)
+
+func verifyAttestationCommand() cli.Command {
+	return cli.Command{
+		Name:   "verify-attestation",
+		Action: cli.ActionFunc(verifyAttestationAction),
+		Usage:  "verify the attestation of a WebAuthn registration",
+		UsageText: `**step crypto webauthn verify-attestation** <file>
+[**--client-data**=<file>] [**--roots**=<file>] [**--metadata**=<file>]
+[**--rp-id**=<id>] [**--origin**=<origin>] [**--format**=<format>]`,
+		Description: `**step crypto webauthn verify-attestation** parses the attestation object
+of a WebAuthn registration, verifies the attestation statement, and prints the
+details of the authenticator and the registered credential.
+
+The packed, tpm, apple, android-key and none attestation formats are supported.
+If the statement has a certificate chain, and trusted roots are given with the
+**--roots** or **--metadata** flags, the chain must validate to one of them.
+The attestation roots of the authenticators are not distributed with step, they
+can be obtained from the FIDO Metadata Service (https://mds3.fidoalliance.org)
+or from the authenticator vendor.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The path to the attestation object, in binary, base64 or base64url
+   encoding, or to the JSON registration response returned by
+   navigator.credentials.create(), with the attestationObject and
+   clientDataJSON in its response.
+
+## EXAMPLES
+
+Verify a registration response:
+'''
+$ step crypto webauthn verify-attestation registration.json --rp-id example.com
+'''
+
+Verify an attestation object using the FIDO metadata and print the result in
+JSON:
+'''
+$ step crypto webauthn verify-attestation attestation.b64 \
+  --client-data client-data.json --metadata blob.jwt --format json
+'''
+
+Verify an attestation object using the roots in a PEM bundle:
+'''
+$ step crypto webauthn verify-attestation attestation.cbor \
+  --client-data client-data.json --roots yubico-roots.pem
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "client-data",
+				Usage: `The path to the <file> with the clientDataJSON of the registration, in JSON,
+base64 or base64url encoding. It is required unless the positional argument is
+a registration response.`,
+			},
+			cli.StringFlag{
+				Name:  "roots",
+				Usage: "The path to the PEM <file> with the trusted attestation roots.",
+			},
+			cli.StringFlag{
+				Name: "metadata",
+				Usage: `The path to the <file> with the FIDO Metadata Service BLOB, its JSON payload,
+or a metadata statement. The signature of the BLOB is not verified.`,
+			},
+			cli.StringFlag{
+				Name:  "rp-id",
+				Usage: "The relying party <id> that must match the RP ID hash in the authenticator data.",
+			},
+			cli.StringFlag{
+				Name:  "origin",
+				Usage: "The <origin> that must match the origin in the client data.",
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Value: "text",
+				Usage: `The output <format> for printing the details.
+
+: <format> is a string and must be one of:
+
+    **text**
+    :  Print output in unstructured text suitable for a human to read.
+
+    **json**
+    :  Print output in JSON format.`,
+			},
+		},
+	}
+}
+
+// registrationResponse is the JSON serialization of a PublicKeyCredential
+// returned by navigator.credentials.create().
+type registrationResponse struct {
+	Response struct {
+		AttestationObject string `json:"attestationObject"`
+		ClientDataJSON    string `json:"clientDataJSON"`
+	} `json:"response"`
+}
+
+func verifyAttestationAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	format := ctx.String("format")
+	if format != "text" && format != "json" {
+		return errs.InvalidFlagValue(ctx, "format", format, "text, json")
+	}
+
+	filename := ctx.Args().Get(0)
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var attObj, clientDataJSON []byte
+	var reg registrationResponse
+	if json.Unmarshal(bytes.TrimSpace(b), &reg) == nil && reg.Response.AttestationObject != "" {
+		if attObj, err = decodeBase64(reg.Response.AttestationObject); err != nil {
+			return errors.Wrapf(err, "error decoding attestationObject in %s", filename)
+		}
+		if clientDataJSON, err = decodeBase64(reg.Response.ClientDataJSON); err != nil {
+			return errors.Wrapf(err, "error decoding clientDataJSON in %s", filename)
+		}
+	} else {
+		attObj = decodeInput(b)
+	}
+
+	if name := ctx.String("client-data"); name != "" {
+		b, err := utils.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '{' {
+			clientDataJSON = b
+		} else if clientDataJSON, err = decodeBase64(string(b)); err != nil {
+			return errors.Wrapf(err, "error decoding %s", name)
+		}
+	}
+	if len(clientDataJSON) == 0 {
+		return errs.RequiredFlag(ctx, "client-data")
+	}
+
+	clientData, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+	if clientData.Type != "webauthn.create" {
+		return errors.Errorf("client data type %q is not webauthn.create", clientData.Type)
+	}
+	if origin := ctx.String("origin"); origin != "" && origin != clientData.Origin {
+		return errors.Errorf("client data origin %s does not match %s", clientData.Origin, origin)
+	}
+
+	obj, err := webauthn.ParseAttestationObject(attObj)
+	if err != nil {
+		return err
+	}
+	if rpID := ctx.String("rp-id"); rpID != "" {
+		sum := sha256.Sum256([]byte(rpID))
+		if !bytes.Equal(sum[:], obj.AuthData.RPIDHash) {
+			return errors.Errorf("RP ID hash does not match %s", rpID)
+		}
+	}
+
+	var opts webauthn.VerifyOptions
+	if name := ctx.String("roots"); name != "" {
+		certs, err := pemutil.ReadCertificateBundle(name)
+		if err != nil {
+			return err
+		}
+		opts.Roots = x509.NewCertPool()
+		for _, crt := range certs {
+			opts.Roots.AddCert(crt)
+		}
+	}
+	if name := ctx.String("metadata"); name != "" {
+		b, err := utils.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if opts.Metadata, err = webauthn.ParseMetadata(b); err != nil {
+			return errors.Wrapf(err, "error reading %s", name)
+		}
+	}
+
+	res, err := obj.Verify(clientData.Hash(), opts)
+	if err != nil {
+		return err
+	}
+
+	out := newAttestationOutput(res, clientData)
+	if format == "json" {
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling JSON")
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	out.print()
+	return nil
+}
+
+type attestationOutput struct {
+	Format          string   `json:"format"`
+	Type            string   `json:"type"`
+	Trusted         bool     `json:"trusted"`
+	Description     string   `json:"description,omitempty"`
+	AAGUID          string   `json:"aaguid"`
+	Origin          string   `json:"origin"`
+	RPIDHash        string   `json:"rpIdHash"`
+	Flags           []string `json:"flags"`
+	SignCount       uint32   `json:"signCount"`
+	CredentialID    string   `json:"credentialId"`
+	PublicKey       string   `json:"publicKey"`
+	PublicKeyAlg    string   `json:"publicKeyAlgorithm"`
+	AttestationAlg  string   `json:"attestationAlgorithm,omitempty"`
+	Certificates    []string `json:"certificates,omitempty"`
+	hasCertificates bool
+}
+
+func newAttestationOutput(res *webauthn.Result, cd *webauthn.ClientData) *attestationOutput {
+	out := &attestationOutput{
+		Format:          res.Format,
+		Type:            res.Type,
+		Trusted:         res.Trusted,
+		AAGUID:          res.AuthData.AAGUIDString(),
+		Origin:          cd.Origin,
+		RPIDHash:        hex.EncodeToString(res.AuthData.RPIDHash),
+		Flags:           res.AuthData.FlagNames(),
+		SignCount:       res.AuthData.SignCount,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(res.AuthData.CredentialID),
+		PublicKey:       publicKeyType(res.AuthData.PublicKey),
+		PublicKeyAlg:    webauthn.AlgorithmName(res.AuthData.PublicKeyAlgorithm),
+		hasCertificates: len(res.Certificates) > 0,
+	}
+	if res.Statement != nil {
+		out.Description = res.Statement.Description
+	}
+	if res.Algorithm != 0 {
+		out.AttestationAlg = webauthn.AlgorithmName(res.Algorithm)
+	}
+	for _, crt := range res.Certificates {
+		subject := crt.Subject.String()
+		if subject == "" {
+			subject = "(empty)"
+		}
+		out.Certificates = append(out.Certificates, fmt.Sprintf("%s, issued by %s", subject, crt.Issuer.String()))
+	}
+	return out
+}
+
+func (o *attestationOutput) print() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Format:\t%s\n", o.Format)
+	fmt.Fprintf(w, "Attestation type:\t%s\n", o.Type)
+	if o.AttestationAlg != "" {
+		fmt.Fprintf(w, "Attestation algorithm:\t%s\n", o.AttestationAlg)
+	}
+	if o.Description != "" {
+		fmt.Fprintf(w, "Authenticator:\t%s\n", o.Description)
+	}
+	fmt.Fprintf(w, "AAGUID:\t%s\n", o.AAGUID)
+	fmt.Fprintf(w, "Origin:\t%s\n", o.Origin)
+	fmt.Fprintf(w, "RP ID hash:\t%s\n", o.RPIDHash)
+	fmt.Fprintf(w, "Flags:\t%s\n", strings.Join(o.Flags, ", "))
+	fmt.Fprintf(w, "Sign count:\t%d\n", o.SignCount)
+	fmt.Fprintf(w, "Credential ID:\t%s\n", o.CredentialID)
+	fmt.Fprintf(w, "Public key:\t%s (%s)\n", o.PublicKey, o.PublicKeyAlg)
+	for i, crt := range o.Certificates {
+		fmt.Fprintf(w, "Certificate %d:\t%s\n", i, crt)
+	}
+	if o.hasCertificates {
+		if o.Trusted {
+			fmt.Fprintf(w, "Chain:\tverified\n")
+		} else {
+			fmt.Fprintf(w, "Chain:\tnot verified, no trusted roots\n")
+		}
+	}
+	w.Flush()
+}
+
+func publicKeyType(pub interface{}) string {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
+
+// decodeInput returns the decoded data if b is base64 or base64url encoded,
+// or b if it is binary.
+func decodeInput(b []byte) []byte {
+	if v, err := decodeBase64(string(bytes.TrimSpace(b))); err == nil {
+		return v
+	}
+	return b
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
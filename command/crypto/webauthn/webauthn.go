@@ -0,0 +1,34 @@
+package webauthn
+
+import (
+	"github.com/urfave/cli"
+)
+
+// Command returns the cli.Command for webauthn and related subcommands.
+func Command() cli.Command {
+	return cli.Command{
+		Name:      "webauthn",
+		Usage:     "inspect and verify WebAuthn and passkey registrations",
+		UsageText: "step crypto webauthn <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step crypto webauthn** command group provides facilities to debug WebAuthn
+and passkey registrations, verifying the attestation statements created by the
+authenticators.
+
+## EXAMPLES
+
+Verify the attestation of a registration response, as sent by the browser to
+the relying party:
+'''
+$ step crypto webauthn verify-attestation registration.json
+'''
+
+Verify an attestation object and its client data using the FIDO metadata:
+'''
+$ step crypto webauthn verify-attestation attestation.cbor \
+  --client-data client-data.json --metadata blob.jwt
+'''`,
+		Subcommands: cli.Commands{
+			verifyAttestationCommand(),
+		},
+	}
+}
@@ -0,0 +1,432 @@
+package doctor
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/x509util"
+)
+
+const (
+	// maxFileSize is the size of the largest file inspected.
+	maxFileSize = 1 << 20
+	// expirationWarning is the time before the expiration of a certificate
+	// from which a warning is reported.
+	expirationWarning = 24 * time.Hour
+	// rootExpirationWarning is the time before the expiration of a root
+	// certificate from which a warning is reported.
+	rootExpirationWarning = 30 * 24 * time.Hour
+	// maxClockSkew is the maximum difference with the clock of the CA before
+	// a warning is reported.
+	maxClockSkew = time.Minute
+)
+
+// stepDefaults are the properties of defaults.json used by the checks.
+type stepDefaults struct {
+	CAURL string `json:"ca-url"`
+	Root  string `json:"root"`
+}
+
+// walk calls fn for every file in the step path, skipping the database.
+func walk(dir string, fn func(path string, info os.FileInfo) error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && path == filepath.Join(dir, "db") {
+			return filepath.SkipDir
+		}
+		return fn(path, info)
+	})
+}
+
+// checkPermissions checks that the step path is not writable by others, and
+// that the secrets and private keys are only accessible by their owner.
+func checkPermissions(dir string) []*finding {
+	var findings []*finding
+	chmod := func(path string, perm os.FileMode) func() error {
+		return func() error { return os.Chmod(path, perm) }
+	}
+
+	if info, err := os.Stat(dir); err != nil {
+		return []*finding{{
+			Check:    "permissions",
+			Severity: severityError,
+			Message:  fmt.Sprintf("cannot read the step path %s: %v", dir, err),
+		}}
+	} else if perm := info.Mode().Perm(); perm&0022 != 0 {
+		findings = append(findings, &finding{
+			Check:    "permissions",
+			Severity: severityError,
+			Message:  fmt.Sprintf("%s is writable by other users (%#o)", dir, perm),
+			Fix:      fmt.Sprintf("chmod go-w %s", dir),
+			fixFunc:  chmod(dir, perm&^0022),
+		})
+	}
+
+	secrets := pki.GetSecretsPath()
+	if info, err := os.Stat(secrets); err == nil && info.Mode().Perm()&0077 != 0 {
+		findings = append(findings, &finding{
+			Check:    "permissions",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("%s is accessible by other users (%#o)", secrets, info.Mode().Perm()),
+			Fix:      fmt.Sprintf("chmod 700 %s", secrets),
+			fixFunc:  chmod(secrets, 0700),
+		})
+	}
+
+	walk(dir, func(path string, info os.FileInfo) error {
+		if !info.Mode().IsRegular() || info.Size() > maxFileSize || info.Mode().Perm()&0077 == 0 {
+			return nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil || !bytes.Contains(b, []byte("PRIVATE KEY-----")) {
+			return nil
+		}
+		findings = append(findings, &finding{
+			Check:    "permissions",
+			Severity: severityError,
+			Message:  fmt.Sprintf("the private key %s is readable by other users (%#o)", path, info.Mode().Perm()),
+			Fix:      fmt.Sprintf("chmod 600 %s", path),
+			fixFunc:  chmod(path, 0600),
+		})
+		return nil
+	})
+
+	if len(findings) == 0 {
+		findings = append(findings, &finding{
+			Check:    "permissions",
+			Severity: severityOK,
+			Message:  fmt.Sprintf("the permissions of %s are correct", dir),
+		})
+	}
+	return findings
+}
+
+// checkCertificates checks that the certificates in the step path are not
+// expired or about to expire.
+func checkCertificates(dir string) []*finding {
+	var findings []*finding
+	walk(dir, func(path string, info os.FileInfo) error {
+		if !info.Mode().IsRegular() || info.Size() > maxFileSize {
+			return nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		block, _ := pem.Decode(b)
+		if block == nil || block.Type != "CERTIFICATE" {
+			return nil
+		}
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil
+		}
+
+		isRoot := crt.IsCA && bytes.Equal(crt.RawIssuer, crt.RawSubject)
+		warning, fix := expirationWarning, fmt.Sprintf("step ca renew %s <key-file>", path)
+		if isRoot {
+			warning, fix = rootExpirationWarning, "step ca bootstrap --force with the new root fingerprint"
+		} else if crt.IsCA {
+			warning, fix = rootExpirationWarning, "create a new intermediate certificate"
+		}
+
+		d := time.Until(crt.NotAfter)
+		switch {
+		case d <= 0:
+			findings = append(findings, &finding{
+				Check:    "certificates",
+				Severity: severityError,
+				Message:  fmt.Sprintf("%s (%s) expired on %s", path, crt.Subject.CommonName, crt.NotAfter.Format(time.RFC3339)),
+				Fix:      fix,
+			})
+		case d < warning:
+			findings = append(findings, &finding{
+				Check:    "certificates",
+				Severity: severityWarning,
+				Message:  fmt.Sprintf("%s (%s) expires in %s", path, crt.Subject.CommonName, d.Round(time.Minute)),
+				Fix:      fix,
+			})
+		}
+		return nil
+	})
+
+	if len(findings) == 0 {
+		findings = append(findings, &finding{
+			Check:    "certificates",
+			Severity: severityOK,
+			Message:  "no certificates are expired or about to expire",
+		})
+	}
+	return findings
+}
+
+// checkSymlinks checks that there are no broken symbolic links in the step
+// path.
+func checkSymlinks(dir string) []*finding {
+	var findings []*finding
+	walk(dir, func(path string, info os.FileInfo) error {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		target, _ := os.Readlink(path)
+		findings = append(findings, &finding{
+			Check:    "symlinks",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("%s points to %s, which does not exist", path, target),
+			Fix:      fmt.Sprintf("rm %s", path),
+			fixFunc:  func() error { return os.Remove(path) },
+		})
+		return nil
+	})
+	if len(findings) == 0 {
+		findings = append(findings, &finding{
+			Check:    "symlinks",
+			Severity: severityOK,
+			Message:  "there are no broken symbolic links",
+		})
+	}
+	return findings
+}
+
+// checkDefaults checks the configuration in defaults.json and returns the
+// properties used by the other checks. If defaults.json does not configure a
+// root, the root in the step path is used if it exists.
+func checkDefaults(filename string) (stepDefaults, []*finding) {
+	defaults, findings := readDefaults(filename)
+	if defaults.Root == "" {
+		if _, err := os.Stat(pki.GetRootCAPath()); err == nil {
+			defaults.Root = pki.GetRootCAPath()
+		}
+	}
+	return defaults, findings
+}
+
+func readDefaults(filename string) (stepDefaults, []*finding) {
+	var defaults stepDefaults
+	var findings []*finding
+
+	b, err := ioutil.ReadFile(filename)
+	switch {
+	case os.IsNotExist(err):
+		return defaults, []*finding{{
+			Check:    "defaults",
+			Severity: severityOK,
+			Message:  fmt.Sprintf("%s does not exist, the CA has not been bootstrapped", filename),
+		}}
+	case err != nil:
+		return defaults, []*finding{{
+			Check:    "defaults",
+			Severity: severityError,
+			Message:  fmt.Sprintf("cannot read %s: %v", filename, err),
+		}}
+	}
+	if err := json.Unmarshal(b, &defaults); err != nil {
+		return defaults, []*finding{{
+			Check:    "defaults",
+			Severity: severityError,
+			Message:  fmt.Sprintf("cannot parse %s: %v", filename, err),
+			Fix:      "fix the JSON syntax or run step ca bootstrap --force",
+		}}
+	}
+
+	if defaults.Root != "" {
+		if _, err := os.Stat(defaults.Root); err != nil {
+			findings = append(findings, &finding{
+				Check:    "defaults",
+				Severity: severityError,
+				Message:  fmt.Sprintf("the root %s in %s does not exist", defaults.Root, filename),
+				Fix:      "step ca bootstrap --force --ca-url <uri> --fingerprint <fingerprint>",
+			})
+			defaults.Root = ""
+		}
+	}
+	if defaults.CAURL != "" {
+		if u, err := url.Parse(defaults.CAURL); err != nil || u.Host == "" {
+			findings = append(findings, &finding{
+				Check:    "defaults",
+				Severity: severityError,
+				Message:  fmt.Sprintf("the ca-url %s in %s is not a valid url", defaults.CAURL, filename),
+				Fix:      "step config set ca-url https://<host>",
+			})
+			defaults.CAURL = ""
+		} else if u.Scheme != "https" {
+			findings = append(findings, &finding{
+				Check:    "defaults",
+				Severity: severityWarning,
+				Message:  fmt.Sprintf("the ca-url %s in %s does not use https", defaults.CAURL, filename),
+				Fix:      "step config set ca-url https://" + u.Host,
+			})
+		}
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, &finding{
+			Check:    "defaults",
+			Severity: severityOK,
+			Message:  fmt.Sprintf("the configuration in %s is correct", filename),
+		})
+	}
+	return defaults, findings
+}
+
+// checkDaemons checks that the registered daemons are still running.
+func checkDaemons() []*finding {
+	daemons, err := config.Daemons()
+	if err != nil {
+		return []*finding{{
+			Check:    "daemons",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("cannot read the daemons in %s: %v", config.DaemonsPath(), err),
+		}}
+	}
+
+	var findings []*finding
+	for _, d := range daemons {
+		if syscall.Kill(d.PID, 0) == nil {
+			continue
+		}
+		filename := filepath.Join(config.DaemonsPath(), fmt.Sprintf("%s-%d.json", d.Name, d.PID))
+		findings = append(findings, &finding{
+			Check:    "daemons",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("the %s daemon for %s (pid %d) is not running", d.Name, d.Certificate, d.PID),
+			Fix:      fmt.Sprintf("start it again, or remove the registration %s", filename),
+			fixFunc:  func() error { return os.Remove(filename) },
+		})
+	}
+	if len(findings) == 0 {
+		findings = append(findings, &finding{
+			Check:    "daemons",
+			Severity: severityOK,
+			Message:  fmt.Sprintf("%d registered daemon(s) running", len(daemons)),
+		})
+	}
+	return findings
+}
+
+// checkTruststore checks that the root certificate is installed in the system
+// truststore.
+func checkTruststore(rootFile string) []*finding {
+	if rootFile == "" {
+		return nil
+	}
+	root, err := readCertificate(rootFile)
+	if err != nil {
+		return []*finding{{
+			Check:    "truststore",
+			Severity: severityError,
+			Message:  fmt.Sprintf("cannot read the root %s: %v", rootFile, err),
+		}}
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return []*finding{{
+			Check:    "truststore",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("cannot read the system truststore: %v", err),
+		}}
+	}
+	if _, err := root.Verify(x509.VerifyOptions{Roots: pool}); err != nil {
+		return []*finding{{
+			Check:    "truststore",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("the root %s is not installed in the system truststore", rootFile),
+			Fix:      fmt.Sprintf("step certificate install %s", rootFile),
+		}}
+	}
+	return []*finding{{
+		Check:    "truststore",
+		Severity: severityOK,
+		Message:  fmt.Sprintf("the root %s is installed in the system truststore", rootFile),
+	}}
+}
+
+// checkClock compares the local clock with the Date header of a response of
+// the CA.
+func checkClock(caURL, rootFile string) *finding {
+	tr := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if rootFile != "" {
+		pool, err := x509util.ReadCertPool(rootFile)
+		if err != nil {
+			return &finding{
+				Check:    "clock",
+				Severity: severityWarning,
+				Message:  fmt.Sprintf("cannot read the root %s: %v", rootFile, err),
+			}
+		}
+		tr.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	client := &http.Client{Transport: tr, Timeout: 10 * time.Second}
+
+	start := time.Now()
+	resp, err := client.Get(strings.TrimSuffix(caURL, "/") + "/health")
+	if err != nil {
+		return &finding{
+			Check:    "clock",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("cannot connect to the CA %s: %v", caURL, err),
+			Fix:      "run with --offline to skip this check",
+		}
+	}
+	resp.Body.Close()
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return &finding{
+			Check:    "clock",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("the CA %s did not send a valid Date header", caURL),
+		}
+	}
+
+	// Use the middle of the request as the local time, the Date header has a
+	// resolution of one second.
+	local := start.Add(time.Since(start) / 2)
+	skew := local.Sub(date).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		return &finding{
+			Check:    "clock",
+			Severity: severityWarning,
+			Message:  fmt.Sprintf("the clock differs from the CA by %s, new certificates might not be valid yet", skew),
+			Fix:      "synchronize the clock using NTP",
+		}
+	}
+	return &finding{
+		Check:    "clock",
+		Severity: severityOK,
+		Message:  fmt.Sprintf("the clock differs from the CA by %s", skew),
+	}
+}
+
+func readCertificate(filename string) (*x509.Certificate, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s is not a PEM certificate", filename)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,179 @@
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	cmd := cli.Command{
+		Name:      "doctor",
+		Usage:     "check the step path and the environment for common problems",
+		Action:    command.ActionFunc(doctorAction),
+		UsageText: `**step doctor** [**--fix**] [**--offline**] [**--json**]`,
+		Description: `**step doctor** command checks the step path and the environment for common
+problems and suggests how to fix them. The checks are:
+
+**permissions**
+:  The step path is not writable by other users, and the secrets directory and
+the private keys are only readable by their owner.
+
+**certificates**
+:  The root certificates and identities in the step path are not expired or
+about to expire.
+
+**symlinks**
+:  There are no symbolic links in the step path pointing to missing files.
+
+**defaults**
+:  The configuration in $STEPPATH/config/defaults.json can be parsed, the root
+certificate exists, and the CA url uses https.
+
+**daemons**
+:  The registered renewal daemons are still running.
+
+**truststore**
+:  The root certificate is installed in the system truststore.
+
+**clock**
+:  The local clock does not differ from the clock of the CA. This check makes
+a request to the health endpoint of the CA in defaults.json, it is skipped with
+**--offline**.
+
+The command does not send any information anywhere other than the request to
+the CA you configured. With **--fix** the problems that have a safe fix are
+fixed: the permissions are restricted, the broken symbolic links are removed,
+and the registrations of the daemons that are no longer running are removed.
+
+The command exits with a status of 1 if any error is found.
+
+## EXAMPLES
+
+Check the step path:
+'''
+$ step doctor
+ok       permissions   the permissions of /home/jane/.step are correct
+warning  certificates  certs/internal.crt expires in 2h
+                       fix: step ca renew certs/internal.crt secrets/internal.key
+ok       clock         the clock differs from the CA by 0s
+'''
+
+Check the step path and fix the problems that can be fixed safely:
+'''
+$ step doctor --fix
+'''
+
+Print the results in JSON format without contacting the CA:
+'''
+$ step doctor --offline --json
+'''`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "fix",
+				Usage: "Fix the problems that have a safe fix.",
+			},
+			cli.BoolFlag{
+				Name:  "offline",
+				Usage: "Skip the checks that require a connection to the CA.",
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the results in JSON format.",
+			},
+		},
+	}
+
+	command.Register(cmd)
+}
+
+// Severities of the findings.
+const (
+	severityOK      = "ok"
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// finding is the result of a check.
+type finding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"`
+	Fixed    bool   `json:"fixed,omitempty"`
+
+	fixFunc func() error
+}
+
+func doctorAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	dir := config.StepPath()
+	findings := []*finding{}
+	findings = append(findings, checkPermissions(dir)...)
+	findings = append(findings, checkCertificates(dir)...)
+	findings = append(findings, checkSymlinks(dir)...)
+	defaults, fs := checkDefaults(command.ConfigFile(ctx))
+	findings = append(findings, fs...)
+	findings = append(findings, checkDaemons()...)
+	findings = append(findings, checkTruststore(defaults.Root)...)
+	if !ctx.Bool("offline") && defaults.CAURL != "" {
+		findings = append(findings, checkClock(defaults.CAURL, defaults.Root))
+	}
+
+	if ctx.Bool("fix") {
+		for _, f := range findings {
+			if f.fixFunc == nil {
+				continue
+			}
+			if err := f.fixFunc(); err != nil {
+				f.Message += fmt.Sprintf(" (fix failed: %v)", err)
+				continue
+			}
+			f.Fixed = true
+		}
+	}
+
+	var problems int
+	for _, f := range findings {
+		if f.Severity == severityError && !f.Fixed {
+			problems++
+		}
+	}
+
+	if ctx.Bool("json") {
+		b, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling results")
+		}
+		fmt.Println(string(b))
+	} else {
+		printFindings(findings)
+	}
+
+	if problems > 0 {
+		return errs.NewExitError(errors.Errorf("step doctor found %d error(s)", problems), 1)
+	}
+	return nil
+}
+
+func printFindings(findings []*finding) {
+	for _, f := range findings {
+		severity := f.Severity
+		if f.Fixed {
+			severity = "fixed"
+		}
+		fmt.Fprintf(os.Stdout, "%-8s %-13s %s\n", severity, f.Check, f.Message)
+		if f.Fix != "" && !f.Fixed {
+			fmt.Fprintf(os.Stdout, "%-8s %-13s fix: %s\n", "", "", f.Fix)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package command
+
+import (
+	"fmt"
+)
+
+// DryRunf prints an action that a command run with the --dry-run flag would
+// perform. The actions are printed to stdout so they can be reviewed or
+// saved.
+func DryRunf(format string, args ...interface{}) {
+	fmt.Printf("[dry-run] "+format+"\n", args...)
+}
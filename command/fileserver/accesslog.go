@@ -0,0 +1,85 @@
+package fileserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// accessLogEntry is the structure written for each request.
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Protocol   string    `json:"protocol"`
+	Status     int       `json:"status"`
+	Size       int64     `json:"size"`
+	Duration   float64   `json:"durationMs"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	ClientCert string    `json:"clientCertificate,omitempty"`
+}
+
+// accessLogHandler is an http.Handler that writes a JSON log line for each
+// request.
+type accessLogHandler struct {
+	next http.Handler
+	mu   sync.Mutex
+	enc  *json.Encoder
+}
+
+func newAccessLogHandler(w io.Writer, next http.Handler) http.Handler {
+	return &accessLogHandler{
+		next: next,
+		enc:  json.NewEncoder(w),
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *accessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+	h.next.ServeHTTP(rw, r)
+
+	entry := accessLogEntry{
+		Time:       start.UTC(),
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Path:       r.URL.RequestURI(),
+		Protocol:   r.Proto,
+		Status:     rw.status,
+		Size:       rw.size,
+		Duration:   float64(time.Since(start)) / float64(time.Millisecond),
+		UserAgent:  r.UserAgent(),
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		entry.ClientCert = r.TLS.PeerCertificates[0].Subject.String()
+	}
+
+	h.mu.Lock()
+	h.enc.Encode(entry)
+	h.mu.Unlock()
+}
+
+// responseWriter is an http.ResponseWriter that records the status and the
+// size of the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (w *responseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements the http.ResponseWriter interface.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
@@ -1,13 +1,19 @@
 package fileserver
 
 import (
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/pkg/errors"
 
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/tlsutil"
 	"github.com/smallstep/cli/errs"
 
 	"github.com/smallstep/cli/command"
@@ -21,12 +27,23 @@ func init() {
 		Action: command.ActionFunc(fileServerAction),
 		Usage:  "start an HTTP(S) server serving the contents of a path",
 		UsageText: `step fileserver <dir>
-		[--address=<address>] [--cert=<path>] [--key=<path>]`,
+		[--address=<address>] [--cert=<path>] [--key=<path>] [--mtls]
+		[--root=<path>] [--reload-interval=<duration>] [--access-log=<path>]`,
 		Description: `**step fileserver** command starts an HTTP(S) server serving the contents of a file
 system.
 
 This command is experimental and only intended for test purposes.
 
+When a certificate and key are used, the files are checked periodically and
+reloaded if they change, so a certificate renewed on disk, e.g. using **step ca
+renew**, is used without restarting the server. With **--mtls** the root
+certificates, and the root defined in the defaults.json, are reloaded too. A
+SIGHUP signal forces the reload of all of them.
+
+Each request is logged as a JSON object in a single line with the time, the
+remote address, the method, the path, the response status and size, the
+duration, and the subject of the client certificate if any.
+
 ## POSITIONAL ARGUMENTS
 
 <dir>
@@ -45,6 +62,14 @@ $ step ca certificate 127.0.0.1 localhost.crt localhost.key
 ...
 $ step fileserver --address 127.0.0.1:8443 \
   --cert localhost.crt --key localhost.key /path/to/root
+'''
+
+Start an HTTPS file server that requires client certificates signed by the
+bootstrapped root certificate and writes the access logs to a file.
+'''
+$ step fileserver --address 127.0.0.1:8443 --mtls \
+  --cert localhost.crt --key localhost.key \
+  --access-log /var/log/fileserver.log /path/to/root
 '''`,
 		Flags: []cli.Flag{
 			cli.StringFlag{
@@ -60,6 +85,27 @@ $ step fileserver --address 127.0.0.1:8443 \
 				Name:  "key",
 				Usage: `The <path> to the key corresponding to the certificate.`,
 			},
+			cli.BoolFlag{
+				Name: "mtls",
+				Usage: `Require and verify client certificates. Client certificates are verified
+using the root certificate defined by the **--root** flag.`,
+			},
+			cli.StringFlag{
+				Name: "root",
+				Usage: `The <path> to the PEM file with the root certificates used to verify client
+certificates. Defaults to the root certificate of the bootstrapped CA.`,
+			},
+			cli.DurationFlag{
+				Name: "reload-interval",
+				Usage: `The minimum <duration> between two checks for changes in the certificate,
+key and root files.`,
+				Value: tlsutil.DefaultReloadInterval,
+			},
+			cli.StringFlag{
+				Name: "access-log",
+				Usage: `The <path> of the file where the access logs are appended. Defaults to the
+standard error.`,
+			},
 		},
 	}
 	command.Register(cmd)
@@ -82,6 +128,7 @@ func fileServerAction(ctx *cli.Context) error {
 	address := ctx.String("address")
 	cert := ctx.String("cert")
 	key := ctx.String("key")
+	mtls := ctx.Bool("mtls")
 
 	switch {
 	case address == "":
@@ -90,6 +137,80 @@ func fileServerAction(ctx *cli.Context) error {
 		return errs.RequiredWithFlag(ctx, "cert", "key")
 	case key != "" && cert == "":
 		return errs.RequiredWithFlag(ctx, "key", "cert")
+	case mtls && cert == "":
+		return errs.RequiredWithFlag(ctx, "mtls", "cert")
+	case ctx.IsSet("root") && !mtls:
+		return errs.RequiredWithFlag(ctx, "root", "mtls")
+	}
+
+	var w io.Writer = os.Stderr
+	if fn := ctx.String("access-log"); fn != "" {
+		f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return errs.FileError(err, fn)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var tlsConfig *tls.Config
+	if cert != "" {
+		reloader, err := tlsutil.NewCertificateReloader(cert, key, ctx.Duration("reload-interval"))
+		if err != nil {
+			return err
+		}
+		reloader.OnReload(func(c *tls.Certificate, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error reloading certificate: %v\n", err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Certificate %s has been reloaded.\n", cert)
+			}
+		})
+		tlsConfig = &tls.Config{
+			GetCertificate: reloader.GetCertificate,
+		}
+		var roots *tlsutil.RootsReloader
+		if mtls {
+			roots, err = tlsutil.NewRootsReloader(func() (string, error) {
+				rootFile, err := command.ReloadConfigValue(ctx, "root")
+				if err != nil || rootFile != "" {
+					return rootFile, err
+				}
+				return pki.GetRootCAPath(), nil
+			}, ctx.Duration("reload-interval"))
+			if err != nil {
+				return err
+			}
+			roots.OnReload(func(file string, err error) {
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error reloading root certificates: %v\n", err)
+				} else {
+					fmt.Fprintf(os.Stderr, "Root certificates %s have been reloaded.\n", file)
+				}
+			})
+			// The client CAs are set on each handshake, so the current roots
+			// are always used.
+			tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				return &tls.Config{
+					GetCertificate: reloader.GetCertificate,
+					ClientAuth:     tls.RequireAndVerifyClientCert,
+					ClientCAs:      roots.Pool(),
+					NextProtos:     []string{"h2", "http/1.1"},
+				}, nil
+			}
+		}
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGHUP)
+		defer signal.Stop(signals)
+		go func() {
+			for range signals {
+				reloader.Reload()
+				if roots != nil {
+					roots.Reload()
+				}
+			}
+		}()
 	}
 
 	l, err := net.Listen("tcp", address)
@@ -97,13 +218,16 @@ func fileServerAction(ctx *cli.Context) error {
 		return errors.Wrapf(err, "failed to listen on at %s", address)
 	}
 
-	handler := http.FileServer(http.Dir(root))
-	if cert != "" && key != "" {
+	srv := &http.Server{
+		Handler:   newAccessLogHandler(w, http.FileServer(http.Dir(root))),
+		TLSConfig: tlsConfig,
+	}
+	if tlsConfig != nil {
 		fmt.Printf("Serving HTTPS at %s ...\n", l.Addr().String())
-		err = http.ServeTLS(l, handler, cert, key)
+		err = srv.ServeTLS(l, "", "")
 	} else {
 		fmt.Printf("Serving HTTP at %s...\n", l.Addr().String())
-		err = http.Serve(l, handler)
+		err = srv.Serve(l)
 	}
 	if err != nil && err != http.ErrServerClosed {
 		return errors.Wrap(err, "file server failed")
@@ -0,0 +1,242 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	cmd := cli.Command{
+		Name:      "inventory",
+		Usage:     "list the certificates, keys and daemons managed in the step path",
+		Action:    command.ActionFunc(inventoryAction),
+		UsageText: `**step inventory** [<dir>] [**--json**] [**--expires-in**=<duration>]`,
+		Description: `**step inventory** command lists the certificates, private keys, identities and
+SSH certificates stored in the step path, and the registered renewal daemons.
+
+An identity is a certificate with its private key also stored in the step path.
+For each certificate the command shows the subject, the key type, the
+provisioner used to sign it, if it was signed by step-ca, and the time until it
+expires. Encrypted keys are reported without a key type.
+
+Keys and certificates created with the **--metadata** flag have a
+<file>.meta.json next to them with their provenance: the creation time, the
+command, the host and user, the provisioner, the intended use, and the
+fingerprints. The intended use is shown in the USE column, and the full
+metadata in the JSON output. Encrypted keys with metadata are paired with their
+certificates using the public key fingerprint.
+
+Daemons started with **step ca renew --daemon** are registered in
+'$STEPPATH/daemons' while they are running.
+
+## POSITIONAL ARGUMENTS
+
+<dir>
+: The directory to scan instead of the step path, e.g. to find out what the
+keys in a server directory were created for.
+
+## EXAMPLES
+
+List the contents of the step path:
+'''
+$ step inventory
+TYPE          PATH                    SUBJECT              KEY          PROVISIONER       EXPIRES    USE
+certificate   certs/root_ca.crt       Smallstep Root CA    EC P-256                       in 3649d
+identity      certs/internal.crt      internal.example.com EC P-256     admin (JWK)       in 20h     internal API server
+key           secrets/root_ca_key                                                         -
+'''
+
+List the keys and certificates in another directory:
+'''
+$ step inventory /etc/nginx/certs
+'''
+
+List the certificates expiring in the next 8 hours:
+'''
+$ step inventory --expires-in 8h
+'''
+
+Print the inventory in JSON format:
+'''
+$ step inventory --json
+'''`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the inventory in JSON format.",
+			},
+			cli.StringFlag{
+				Name: "expires-in",
+				Usage: `Only list the certificates that expire within the given <duration>,
+e.g. 24h or 30m. Keys without a certificate are not listed.`,
+			},
+		},
+	}
+
+	command.Register(cmd)
+}
+
+// daemonInfo is a registered daemon with its status.
+type daemonInfo struct {
+	*config.Daemon
+	Running  bool       `json:"running"`
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+}
+
+type inventoryInfo struct {
+	Path    string        `json:"path"`
+	Items   []*item       `json:"items"`
+	Daemons []*daemonInfo `json:"daemons"`
+}
+
+func inventoryAction(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return errs.TooManyArguments(ctx)
+	}
+
+	var expiresIn time.Duration
+	if s := ctx.String("expires-in"); s != "" {
+		var err error
+		if expiresIn, err = time.ParseDuration(s); err != nil {
+			return errs.InvalidFlagValue(ctx, "expires-in", s, "")
+		}
+	}
+
+	dir := config.StepPath()
+	if ctx.NArg() == 1 {
+		dir = ctx.Args().Get(0)
+	}
+	items, err := scan(dir)
+	if err != nil {
+		return errs.FileError(err, dir)
+	}
+	daemons, err := config.Daemons()
+	if err != nil {
+		return errs.FileError(err, config.DaemonsPath())
+	}
+
+	info := inventoryInfo{
+		Path:    dir,
+		Items:   []*item{},
+		Daemons: []*daemonInfo{},
+	}
+	deadline := time.Now().Add(expiresIn)
+	for _, it := range items {
+		if expiresIn > 0 && (it.NotAfter == nil || it.NotAfter.After(deadline)) {
+			continue
+		}
+		info.Items = append(info.Items, it)
+	}
+	for _, d := range daemons {
+		di := &daemonInfo{
+			Daemon:  d,
+			Running: syscall.Kill(d.PID, 0) == nil,
+		}
+		if it := parseFile(d.Certificate); it != nil {
+			di.NotAfter = it.NotAfter
+		}
+		info.Daemons = append(info.Daemons, di)
+	}
+
+	if ctx.Bool("json") {
+		b, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling inventory")
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tPATH\tSUBJECT\tKEY\tPROVISIONER\tEXPIRES\tUSE")
+	for _, it := range info.Items {
+		keyType := it.KeyType
+		if it.Encrypted {
+			keyType = "encrypted"
+		}
+		subject, provisioner, use := it.Subject, it.Provisioner, ""
+		if md := it.Metadata; md != nil {
+			if subject == "" {
+				subject = md.Subject
+			}
+			if provisioner == "" {
+				provisioner = md.Provisioner
+			}
+			use = md.IntendedUse
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", it.Type, relPath(dir, it.Path),
+			subject, keyType, provisioner, expiresText(it.NotAfter), use)
+	}
+	if len(info.Daemons) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "DAEMON\tPID\tSTATUS\tCERTIFICATE\tCA URL\tEXPIRES")
+		for _, d := range info.Daemons {
+			status := "stopped"
+			if d.Running {
+				status = "running"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", d.Name, d.PID, status,
+				relPath(dir, d.Certificate), d.CAURL, expiresText(d.NotAfter))
+		}
+	}
+	return w.Flush()
+}
+
+// parseFile returns the item in the given file, or nil if it cannot be read
+// or it is not a certificate or key.
+func parseFile(filename string) *item {
+	fi, err := os.Stat(filename)
+	if err != nil || fi.Size() > maxFileSize {
+		return nil
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	return parseItem(filename, b)
+}
+
+// relPath returns the path relative to the step path if it is inside it.
+func relPath(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// expiresText returns a countdown until the given time.
+func expiresText(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	d := time.Until(*t)
+	if d < 0 {
+		return "expired " + humanDuration(-d) + " ago"
+	}
+	return "in " + humanDuration(d)
+}
+
+// humanDuration returns the duration in days, hours or minutes.
+func humanDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
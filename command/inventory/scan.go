@@ -0,0 +1,242 @@
+package inventory
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/keys"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/utils"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// maxFileSize is the size of the largest file inspected, bigger files are
+// not certificates or keys.
+const maxFileSize = 1 << 20
+
+// Item types.
+const (
+	typeCertificate    = "certificate"
+	typeIdentity       = "identity"
+	typeKey            = "key"
+	typeSSHCertificate = "ssh-certificate"
+)
+
+// stepOIDProvisioner is the OID of the extension with the provisioner used
+// to sign a certificate.
+var stepOIDProvisioner = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37476, 9000, 64, 1}
+
+// stepProvisionerASN1 is the value of the provisioner extension.
+type stepProvisionerASN1 struct {
+	Type          int
+	Name          []byte
+	CredentialID  []byte
+	KeyValuePairs []string `asn1:"optional,omitempty"`
+}
+
+var provisionerTypes = map[int]string{
+	1: "JWK",
+	2: "OIDC",
+	3: "GCP",
+	4: "AWS",
+	5: "Azure",
+	6: "ACME",
+	7: "X5C",
+	8: "K8sSA",
+	9: "SSHPOP",
+}
+
+// item is a certificate, key or identity found in the step path.
+type item struct {
+	Type        string     `json:"type"`
+	Path        string     `json:"path"`
+	Key         string     `json:"key,omitempty"`
+	Subject     string     `json:"subject,omitempty"`
+	Issuer      string     `json:"issuer,omitempty"`
+	KeyType     string     `json:"keyType,omitempty"`
+	Provisioner string     `json:"provisioner,omitempty"`
+	Encrypted   bool       `json:"encrypted,omitempty"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+	Expired     bool       `json:"expired,omitempty"`
+
+	Metadata *utils.Metadata `json:"metadata,omitempty"`
+
+	publicKey interface{}
+}
+
+// scan walks the given directory and returns the certificates, keys, and ssh
+// certificates found. Certificates with its private key in the same directory
+// tree are returned as identities.
+func scan(dir string) ([]*item, error) {
+	var items []*item
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			// Skip the database and the daemon registrations
+			if path == filepath.Join(dir, "db") || path == config.DaemonsPath() {
+				return filepath.SkipDir
+			}
+			return nil
+		case !info.Mode().IsRegular() || info.Size() > maxFileSize:
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		if it := parseItem(path, b); it != nil {
+			it.Metadata, _ = utils.ReadMetadata(path)
+			items = append(items, it)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Pair certificates and keys, encrypted keys are paired using the public
+	// key fingerprint in their metadata.
+	for _, crt := range items {
+		if crt.Type != typeCertificate || crt.publicKey == nil {
+			continue
+		}
+		fingerprint := utils.PublicKeyFingerprint(crt.publicKey)
+		for _, key := range items {
+			if key.Type != typeKey {
+				continue
+			}
+			if (key.publicKey != nil && reflect.DeepEqual(crt.publicKey, key.publicKey)) ||
+				(key.publicKey == nil && key.Metadata != nil && fingerprint != "" && key.Metadata.Fingerprints.PublicKey == fingerprint) {
+				crt.Type = typeIdentity
+				crt.Key = key.Path
+				break
+			}
+		}
+	}
+	return items, nil
+}
+
+// parseItem returns the item in the given file, or nil if the file is not a
+// certificate or a key.
+func parseItem(path string, b []byte) *item {
+	if bytes.Contains(b, []byte("-cert-v01@openssh.com")) {
+		return parseSSHCertificate(path, b)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil
+		}
+		return &item{
+			Type:        typeCertificate,
+			Path:        path,
+			Subject:     crt.Subject.CommonName,
+			Issuer:      crt.Issuer.CommonName,
+			KeyType:     keyType(crt.PublicKey),
+			Provisioner: provisionerName(crt),
+			NotAfter:    &crt.NotAfter,
+			Expired:     time.Now().After(crt.NotAfter),
+			publicKey:   crt.PublicKey,
+		}
+	case "RSA PRIVATE KEY", "EC PRIVATE KEY", "PRIVATE KEY", "ENCRYPTED PRIVATE KEY", "OPENSSH PRIVATE KEY":
+		it := &item{
+			Type: typeKey,
+			Path: path,
+		}
+		if block.Headers["Proc-Type"] == "4,ENCRYPTED" || block.Type == "ENCRYPTED PRIVATE KEY" {
+			it.Encrypted = true
+			return it
+		}
+		// Parse without prompting for a password
+		priv, err := pemutil.Parse(b, pemutil.WithFilename(path), pemutil.WithFirstBlock())
+		if err != nil {
+			return it
+		}
+		if pub, err := keys.PublicKey(priv); err == nil {
+			it.KeyType = keyType(pub)
+			it.publicKey = pub
+		}
+		return it
+	default:
+		return nil
+	}
+}
+
+func parseSSHCertificate(path string, b []byte) *item {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(b)
+	if err != nil {
+		return nil
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil
+	}
+	it := &item{
+		Type:    typeSSHCertificate,
+		Path:    path,
+		Subject: cert.KeyId,
+		KeyType: cert.Key.Type(),
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		notAfter := time.Unix(int64(cert.ValidBefore), 0)
+		it.NotAfter = &notAfter
+		it.Expired = time.Now().After(notAfter)
+	}
+	return it
+}
+
+// provisionerName returns the type and name of the provisioner that signed
+// the certificate, or an empty string if the certificate does not have the
+// provisioner extension.
+func provisionerName(crt *x509.Certificate) string {
+	for _, ext := range crt.Extensions {
+		if !ext.Id.Equal(stepOIDProvisioner) {
+			continue
+		}
+		var p stepProvisionerASN1
+		if _, err := asn1.Unmarshal(ext.Value, &p); err != nil {
+			return ""
+		}
+		if typ, ok := provisionerTypes[p.Type]; ok {
+			return fmt.Sprintf("%s (%s)", p.Name, typ)
+		}
+		return string(p.Name)
+	}
+	return ""
+}
+
+// keyType returns a short description of the public key.
+func keyType(pub interface{}) string {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		return "EC " + k.Curve.Params().Name
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case ed25519.PublicKey:
+		return "OKP Ed25519"
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"github.com/smallstep/cli/crypto/randutil"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/exec"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/httpcache"
 	"github.com/smallstep/cli/jose"
 	"github.com/urfave/cli"
 )
@@ -74,6 +76,17 @@ func init() {
   [**--scope**=<scope> ...] [**--bare** [**--oidc**]] [**--header** [**--oidc**]]
 
 **step oauth** **--account**=<account> **--jwt** [**--scope**=<scope> ...] [**--header**] [**-bare**]
+
+**step oauth** [**--provider**=<provider>] **--client-id**=<client-id> **--client-secret**=<client-secret>
+  [**--par**] [**--par-endpoint**=<par-endpoint>] [**--request-object-key**=<file>]
+  [**--request-object-password-file**=<file>] [**--scope**=<scope> ...] [**--bare** [**--oidc**]]
+
+**step oauth** [**--provider-preset**=<preset>] [**--provider-profile**=<name>]
+  [**--save-profile**=<name>] [**--client-id**=<client-id> **--client-secret**=<client-secret>]
+
+**step oauth introspect** <token> [**--provider**=<provider>] [**--client-id**=<client-id>]
+
+**step oauth revoke** <token> [**--provider**=<provider>] [**--client-id**=<client-id>]
 `,
 		Flags: []cli.Flag{
 			cli.StringFlag{
@@ -81,6 +94,21 @@ func init() {
 				Usage: "OAuth provider for authentication",
 				Value: "google",
 			},
+			cli.StringFlag{
+				Name: "provider-preset",
+				Usage: `The built-in <preset> with the settings of a well known provider, one of
+**google**, **github**, **azuread**, **okta**, **auth0** or **keycloak**. The okta,
+auth0 and keycloak presets require the **--provider** flag with the domain of the
+tenant, e.g. dev-123456.okta.com, or keycloak.example.com/realms/myrealm.`,
+			},
+			cli.StringFlag{
+				Name:  "provider-profile",
+				Usage: "The <name> of the provider profile stored in $STEPPATH/config/oauth-profiles.json",
+			},
+			cli.StringFlag{
+				Name:  "save-profile",
+				Usage: "Save the provider settings of the command as the profile with the given <name>",
+			},
 			cli.StringFlag{
 				Name:  "email, e",
 				Usage: "Email to authenticate",
@@ -129,6 +157,29 @@ func init() {
 				Name:  "jwt",
 				Usage: "Generate a JWT Auth token instead of an OAuth Token (only works with service accounts)",
 			},
+			cli.BoolFlag{
+				Name: "verify",
+				Usage: `Verify the ID token signature using the JWKS of the provider, and validate its
+issuer, audience, expiration, nonce and at_hash claims. The verified claims are
+added to the output.`,
+			},
+			cli.BoolFlag{
+				Name:  "par",
+				Usage: "Send the authorization request to the pushed authorization request endpoint (PAR)",
+			},
+			cli.StringFlag{
+				Name:  "par-endpoint",
+				Usage: "OAuth Pushed Authorization Request Endpoint, implies **--par**",
+			},
+			cli.StringFlag{
+				Name:  "request-object-key",
+				Usage: "The private key <file> used to sign the authorization request as a JWT request object (JAR)",
+			},
+			cli.StringFlag{
+				Name:  "request-object-password-file",
+				Usage: "The path to the <file> containing the password to decrypt the **--request-object-key**",
+			},
+			flags.NoCache,
 			cli.BoolFlag{
 				Name:   "implicit",
 				Usage:  "Uses the implicit flow to authenticate the user. Requires **--insecure** and **--client-id** flags.",
@@ -141,17 +192,26 @@ func init() {
 			},
 		},
 		Action: oauthCmd,
+		Subcommands: cli.Commands{
+			introspectCommand(),
+			revokeCommand(),
+		},
 	}
 
 	command.Register(cmd)
 }
 
 func oauthCmd(c *cli.Context) error {
+	if err := applyProviderSettings(c); err != nil {
+		return err
+	}
+
 	opts := &options{
 		Provider: c.String("provider"),
 		Email:    c.String("email"),
 		Console:  c.Bool("console"),
 		Implicit: c.Bool("implicit"),
+		NoCache:  c.Bool("no-cache"),
 	}
 	if err := opts.Validate(); err != nil {
 		return err
@@ -231,6 +291,34 @@ func oauthCmd(c *cli.Context) error {
 		return err
 	}
 
+	// Pushed authorization requests (PAR) and signed request objects (JAR)
+	if c.IsSet("par-endpoint") {
+		o.parEndpoint = c.String("par-endpoint")
+	}
+	o.usePAR = c.Bool("par") || c.IsSet("par-endpoint") || o.requirePAR
+	if o.usePAR && o.parEndpoint == "" {
+		return errors.New("the provider does not support pushed authorization requests: use the flag '--par-endpoint'")
+	}
+	if keyFile := c.String("request-object-key"); keyFile != "" {
+		var opts []jose.Option
+		if passFile := c.String("request-object-password-file"); passFile != "" {
+			opts = append(opts, jose.WithPasswordFile(passFile))
+		}
+		if o.requestKey, err = jose.ParseKey(keyFile, opts...); err != nil {
+			return err
+		}
+		if o.requestKey.IsPublic() {
+			return errors.Errorf("error parsing %s: the request object key must be a private key", keyFile)
+		}
+	} else {
+		if c.IsSet("request-object-password-file") {
+			return errs.RequiredWithFlag(c, "request-object-password-file", "request-object-key")
+		}
+		if o.requireJAR {
+			return errors.New("the provider requires signed request objects: use the flag '--request-object-key'")
+		}
+	}
+
 	var tok *token
 	if do2lo {
 		if c.Bool("jwt") {
@@ -248,6 +336,13 @@ func oauthCmd(c *cli.Context) error {
 		return err
 	}
 
+	var idTokenClaims map[string]interface{}
+	if c.Bool("verify") {
+		if idTokenClaims, err = o.VerifyIDToken(tok, opts.NoCache); err != nil {
+			return err
+		}
+	}
+
 	if c.Bool("header") {
 		if c.Bool("oidc") {
 			fmt.Println("Authorization: Bearer", tok.IDToken)
@@ -262,7 +357,11 @@ func oauthCmd(c *cli.Context) error {
 				fmt.Println(tok.AccessToken)
 			}
 		} else {
-			b, err := json.MarshalIndent(tok, "", "  ")
+			var v interface{} = tok
+			if idTokenClaims != nil {
+				v = verifiedToken{token: *tok, IDTokenClaims: idTokenClaims}
+			}
+			b, err := json.MarshalIndent(v, "", "  ")
 			if err != nil {
 				return errors.Wrapf(err, "error marshaling token data")
 			}
@@ -278,6 +377,7 @@ type options struct {
 	Email    string
 	Console  bool
 	Implicit bool
+	NoCache  bool
 }
 
 // Validate validates the options.
@@ -302,6 +402,13 @@ type oauth struct {
 	codeChallenge    string
 	nonce            string
 	implicit         bool
+	issuer           string
+	jwksURI          string
+	parEndpoint      string
+	requirePAR       bool
+	requireJAR       bool
+	usePAR           bool
+	requestKey       *jose.JSONWebKey
 	errCh            chan error
 	tokCh            chan *token
 }
@@ -332,6 +439,8 @@ func newOauth(provider, clientID, clientSecret, authzEp, tokenEp, scope string,
 			authzEndpoint:    "https://accounts.google.com/o/oauth2/v2/auth",
 			tokenEndpoint:    "https://www.googleapis.com/oauth2/v4/token",
 			userInfoEndpoint: "https://www.googleapis.com/oauth2/v3/userinfo",
+			issuer:           "https://accounts.google.com",
+			jwksURI:          "https://www.googleapis.com/oauth2/v3/certs",
 			loginHint:        opts.Email,
 			state:            state,
 			codeChallenge:    challenge,
@@ -341,9 +450,10 @@ func newOauth(provider, clientID, clientSecret, authzEp, tokenEp, scope string,
 			tokCh:            make(chan *token),
 		}, nil
 	default:
-		userinfoEp := ""
+		userinfoEp, issuer, jwksURI, parEp := "", provider, "", ""
+		var requirePAR, requireJAR bool
 		if authzEp == "" && tokenEp == "" {
-			d, err := disco(provider)
+			d, err := disco(provider, opts.NoCache)
 			if err != nil {
 				return nil, err
 			}
@@ -357,6 +467,13 @@ func newOauth(provider, clientID, clientSecret, authzEp, tokenEp, scope string,
 			authzEp = d["authorization_endpoint"].(string)
 			tokenEp = d["token_endpoint"].(string)
 			userinfoEp = d["token_endpoint"].(string)
+			if v, ok := d["issuer"].(string); ok {
+				issuer = v
+			}
+			jwksURI, _ = d["jwks_uri"].(string)
+			parEp, _ = d["pushed_authorization_request_endpoint"].(string)
+			requirePAR, _ = d["require_pushed_authorization_requests"].(bool)
+			requireJAR, _ = d["require_signed_request_object"].(bool)
 		}
 		return &oauth{
 			provider:         provider,
@@ -366,6 +483,11 @@ func newOauth(provider, clientID, clientSecret, authzEp, tokenEp, scope string,
 			authzEndpoint:    authzEp,
 			tokenEndpoint:    tokenEp,
 			userInfoEndpoint: userinfoEp,
+			issuer:           issuer,
+			jwksURI:          jwksURI,
+			parEndpoint:      parEp,
+			requirePAR:       requirePAR,
+			requireJAR:       requireJAR,
 			loginHint:        opts.Email,
 			state:            state,
 			codeChallenge:    challenge,
@@ -377,7 +499,9 @@ func newOauth(provider, clientID, clientSecret, authzEp, tokenEp, scope string,
 	}
 }
 
-func disco(provider string) (map[string]interface{}, error) {
+// disco returns the OpenID Connect discovery document of the provider. The
+// document is cached in $STEPPATH/cache unless noCache is true.
+func disco(provider string, noCache bool) (map[string]interface{}, error) {
 	url, err := url.Parse(provider)
 	if err != nil {
 		return nil, err
@@ -388,14 +512,9 @@ func disco(provider string) (map[string]interface{}, error) {
 	if strings.Index(url.Path, "/.well-known/openid-configuration") == -1 {
 		url.Path = path.Join(url.Path, "/.well-known/openid-configuration")
 	}
-	resp, err := http.Get(url.String())
+	b, err := httpcache.New(noCache).Get(url.String())
 	if err != nil {
-		return nil, errors.Wrapf(err, "error retrieving %s", url.String())
-	}
-	defer resp.Body.Close()
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrapf(err, "error retrieving %s", url.String())
+		return nil, err
 	}
 	details := make(map[string]interface{})
 	if err := json.Unmarshal(b, &details); err != nil {
@@ -713,6 +832,21 @@ func (o *oauth) Auth() (string, error) {
 	if o.loginHint != "" {
 		q.Add("login_hint", o.loginHint)
 	}
+	if o.requestKey != nil {
+		if q, err = o.requestObjectParams(q); err != nil {
+			return "", err
+		}
+	}
+	if o.usePAR {
+		requestURI, err := o.pushAuthorizationRequest(q)
+		if err != nil {
+			return "", err
+		}
+		q = url.Values{
+			"client_id":   []string{o.clientID},
+			"request_uri": []string{requestURI},
+		}
+	}
 	u.RawQuery = q.Encode()
 	return u.String(), nil
 }
@@ -727,7 +861,14 @@ func (o *oauth) Exchange(tokenEndpoint, code string) (*token, error) {
 	data.Set("grant_type", "authorization_code")
 	data.Set("code_verifier", o.codeChallenge)
 
-	resp, err := http.PostForm(tokenEndpoint, data)
+	req, err := http.NewRequest("POST", tokenEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Some providers, like GitHub, return a form-encoded response by default.
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
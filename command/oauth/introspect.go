@@ -0,0 +1,333 @@
+package oauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+var (
+	providerFlag = cli.StringFlag{
+		Name: "provider, idp",
+		Usage: `The OAuth <provider> URL used to discover the endpoints, or **google**.
+Defaults to **google**.`,
+		Value: "google",
+	}
+	clientIDFlag = cli.StringFlag{
+		Name:  "client-id",
+		Usage: "The OAuth <client-id> used to authenticate the request.",
+	}
+	clientSecretFlag = cli.StringFlag{
+		Name:  "client-secret",
+		Usage: "The OAuth <client-secret> used to authenticate the request.",
+	}
+	clientSecretFileFlag = cli.StringFlag{
+		Name:  "client-secret-file",
+		Usage: "The path to the <file> containing the OAuth client secret.",
+	}
+	clientAuthFlag = cli.StringFlag{
+		Name: "client-auth",
+		Usage: `The <method> used to authenticate the client.
+
+: <method> is a case-sensitive string and must be one of:
+
+    **basic**
+    :  Send the client credentials in the Authorization header (client_secret_basic).
+
+    **post**
+    :  Send the client credentials in the request body (client_secret_post).`,
+		Value: "basic",
+	}
+	tokenTypeHintFlag = cli.StringFlag{
+		Name: "token-type-hint",
+		Usage: `The <type> of the token, **access_token** or **refresh_token**, that helps the
+server to find the token.`,
+	}
+)
+
+func introspectCommand() cli.Command {
+	return cli.Command{
+		Name:   "introspect",
+		Action: command.ActionFunc(introspectAction),
+		Usage:  "get the status and claims of an OAuth token",
+		UsageText: `**step oauth introspect** <token>
+[**--provider**=<provider>] [**--introspection-endpoint**=<url>]
+[**--client-id**=<client-id>] [**--client-secret**=<client-secret>]
+[**--client-secret-file**=<file>] [**--client-auth**=<method>]
+[**--token-type-hint**=<type>] [**--no-cache**]`,
+		Description: `**step oauth introspect** sends the <token> to the introspection endpoint of
+the provider, as defined in RFC 7662, and prints the response with the active
+status of the token and its claims. The endpoint is discovered using the
+provider metadata unless the **--introspection-endpoint** flag is used.
+
+## POSITIONAL ARGUMENTS
+
+<token>
+:  The access or refresh token to introspect. Use '-' to read the token from
+STDIN.
+
+## EXAMPLES
+
+Introspect an access token:
+'''
+$ step oauth introspect --provider https://idp.example.com \
+  --client-id my-api --client-secret-file secret.txt $TOKEN
+{
+  "active": true,
+  "client_id": "my-app",
+  "exp": 1577836800,
+  "scope": "openid email",
+  "sub": "mariano@smallstep.com"
+}
+'''
+
+Introspect a refresh token using a specific endpoint and sending the client
+credentials in the request body:
+'''
+$ step oauth introspect --introspection-endpoint https://idp.example.com/introspect \
+  --client-id my-api --client-secret-file secret.txt --client-auth post \
+  --token-type-hint refresh_token $REFRESH_TOKEN
+'''`,
+		Flags: []cli.Flag{
+			providerFlag,
+			cli.StringFlag{
+				Name:  "introspection-endpoint",
+				Usage: "The OAuth token introspection endpoint <url>.",
+			},
+			clientIDFlag,
+			clientSecretFlag,
+			clientSecretFileFlag,
+			clientAuthFlag,
+			tokenTypeHintFlag,
+			flags.NoCache,
+		},
+	}
+}
+
+func revokeCommand() cli.Command {
+	return cli.Command{
+		Name:   "revoke",
+		Action: command.ActionFunc(revokeAction),
+		Usage:  "revoke an OAuth token",
+		UsageText: `**step oauth revoke** <token>
+[**--provider**=<provider>] [**--revocation-endpoint**=<url>]
+[**--client-id**=<client-id>] [**--client-secret**=<client-secret>]
+[**--client-secret-file**=<file>] [**--client-auth**=<method>]
+[**--token-type-hint**=<type>] [**--no-cache**]`,
+		Description: `**step oauth revoke** sends the <token> to the revocation endpoint of the
+provider, as defined in RFC 7009. The endpoint is discovered using the provider
+metadata unless the **--revocation-endpoint** flag is used.
+
+## POSITIONAL ARGUMENTS
+
+<token>
+:  The access or refresh token to revoke. Use '-' to read the token from STDIN.
+
+## EXAMPLES
+
+Revoke a Google token:
+'''
+$ step oauth revoke $TOKEN
+The token has been revoked.
+'''
+
+Revoke a refresh token:
+'''
+$ step oauth revoke --provider https://idp.example.com \
+  --client-id my-app --client-secret-file secret.txt \
+  --token-type-hint refresh_token $REFRESH_TOKEN
+'''`,
+		Flags: []cli.Flag{
+			providerFlag,
+			cli.StringFlag{
+				Name:  "revocation-endpoint",
+				Usage: "The OAuth token revocation endpoint <url>.",
+			},
+			clientIDFlag,
+			clientSecretFlag,
+			clientSecretFileFlag,
+			clientAuthFlag,
+			tokenTypeHintFlag,
+			flags.NoCache,
+		},
+	}
+}
+
+func introspectAction(ctx *cli.Context) error {
+	req, err := newTokenRequest(ctx, "introspection-endpoint", "introspection_endpoint")
+	if err != nil {
+		return err
+	}
+	resp, err := req.Do()
+	if err != nil {
+		return err
+	}
+
+	v := make(map[string]interface{})
+	if err := json.Unmarshal(resp, &v); err != nil {
+		return errors.Wrap(err, "error parsing introspection response")
+	}
+	if _, ok := v["active"].(bool); !ok {
+		return errors.New("error parsing introspection response: missing 'active' property")
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling introspection response")
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func revokeAction(ctx *cli.Context) error {
+	req, err := newTokenRequest(ctx, "revocation-endpoint", "revocation_endpoint")
+	if err != nil {
+		return err
+	}
+	if _, err := req.Do(); err != nil {
+		return err
+	}
+	fmt.Println("The token has been revoked.")
+	return nil
+}
+
+// tokenRequest is a request to the introspection or revocation endpoints.
+type tokenRequest struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	clientAuth   string
+	form         url.Values
+}
+
+// newTokenRequest creates the request with the token in the positional
+// argument. The endpoint is the value of the given flag, or the given property
+// in the provider metadata.
+func newTokenRequest(ctx *cli.Context, endpointFlag, metadataKey string) (*tokenRequest, error) {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	tok := ctx.Args().Get(0)
+	if tok == "-" {
+		b, err := utils.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		tok = strings.TrimSpace(string(b))
+	}
+
+	clientAuth := ctx.String("client-auth")
+	if clientAuth != "basic" && clientAuth != "post" {
+		return nil, errs.InvalidFlagValue(ctx, "client-auth", clientAuth, "basic, post")
+	}
+
+	clientID, clientSecret := ctx.String("client-id"), ctx.String("client-secret")
+	if filename := ctx.String("client-secret-file"); filename != "" {
+		if clientSecret != "" {
+			return nil, errs.IncompatibleFlagWithFlag(ctx, "client-secret", "client-secret-file")
+		}
+		b, err := utils.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		clientSecret = strings.TrimSpace(string(b))
+	}
+	if clientSecret != "" && clientID == "" {
+		return nil, errs.RequiredWithFlag(ctx, "client-secret", "client-id")
+	}
+
+	endpoint := ctx.String(endpointFlag)
+	if endpoint == "" {
+		provider := ctx.String("provider")
+		switch {
+		case provider == "google":
+			provider = "https://accounts.google.com"
+			if clientID == "" {
+				clientID, clientSecret = defaultClientID, defaultClientNotSoSecret
+			}
+		case !strings.HasPrefix(provider, "https://"):
+			return nil, errs.InvalidFlagValue(ctx, "provider", provider, "")
+		}
+		d, err := disco(provider, ctx.Bool("no-cache"))
+		if err != nil {
+			return nil, err
+		}
+		if endpoint, _ = d[metadataKey].(string); endpoint == "" {
+			return nil, errors.Errorf("missing '%s' in provider metadata, use the flag '--%s'", metadataKey, endpointFlag)
+		}
+	}
+
+	form := url.Values{}
+	form.Set("token", tok)
+	if hint := ctx.String("token-type-hint"); hint != "" {
+		form.Set("token_type_hint", hint)
+	}
+
+	return &tokenRequest{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		clientAuth:   clientAuth,
+		form:         form,
+	}, nil
+}
+
+// Do sends the request with the client authentication and returns the body of
+// the response.
+func (r *tokenRequest) Do() ([]byte, error) {
+	form := url.Values{}
+	for k, v := range r.form {
+		form[k] = v
+	}
+	useBasic := r.clientAuth == "basic" && r.clientSecret != ""
+	if !useBasic && r.clientID != "" {
+		form.Set("client_id", r.clientID)
+		if r.clientSecret != "" {
+			form.Set("client_secret", r.clientSecret)
+		}
+	}
+
+	req, err := http.NewRequest("POST", r.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating request to %s", r.endpoint)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasic {
+		// RFC 6749 section 2.3.1, the credentials are form-urlencoded.
+		req.SetBasicAuth(url.QueryEscape(r.clientID), url.QueryEscape(r.clientSecret))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error connecting to %s", r.endpoint)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading response from %s", r.endpoint)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var tok token
+		if err := json.Unmarshal(b, &tok); err == nil && tok.Err != "" {
+			if tok.ErrDesc != "" {
+				return nil, errors.Errorf("%s: %s", tok.Err, tok.ErrDesc)
+			}
+			return nil, errors.New(tok.Err)
+		}
+		return nil, errors.Errorf("error requesting %s: %s", r.endpoint, resp.Status)
+	}
+	return b, nil
+}
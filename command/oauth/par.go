@@ -0,0 +1,109 @@
+package oauth
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/randutil"
+	"github.com/smallstep/cli/jose"
+)
+
+// requestObjectType is the JWT type of a request object, RFC 9101.
+const requestObjectType = "oauth-authz-req+jwt"
+
+// requestObjectParams returns the authorization request parameters with the
+// given ones signed in a request object (JAR). The client_id, response_type
+// and scope parameters are kept outside of the request object, as required by
+// OpenID Connect.
+func (o *oauth) requestObjectParams(q url.Values) (url.Values, error) {
+	jti, err := randutil.Hex(64)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": o.clientID,
+		"aud": o.issuer,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+		"jti": jti,
+	}
+	for k := range q {
+		claims[k] = q.Get(k)
+	}
+
+	so := new(jose.SignerOptions)
+	so.WithType(requestObjectType)
+	if o.requestKey.KeyID != "" {
+		so.WithHeader("kid", o.requestKey.KeyID)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.SignatureAlgorithm(o.requestKey.Algorithm),
+		Key:       o.requestKey.Key,
+	}, so)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request object signer")
+	}
+	raw, err := jose.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing request object")
+	}
+
+	return url.Values{
+		"client_id":     []string{o.clientID},
+		"response_type": []string{q.Get("response_type")},
+		"scope":         []string{q.Get("scope")},
+		"request":       []string{raw},
+	}, nil
+}
+
+// pushAuthorizationRequest sends the authorization request parameters to the
+// pushed authorization request endpoint (PAR), RFC 9126, and returns the
+// request_uri that references them.
+func (o *oauth) pushAuthorizationRequest(q url.Values) (string, error) {
+	params := url.Values{}
+	for k, v := range q {
+		params[k] = v
+	}
+	params.Set("client_id", o.clientID)
+	if o.clientSecret != "" {
+		params.Set("client_secret", o.clientSecret)
+	}
+
+	resp, err := http.PostForm(o.parEndpoint, params)
+	if err != nil {
+		return "", errors.Wrapf(err, "error connecting to %s", o.parEndpoint)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading response from %s", o.parEndpoint)
+	}
+
+	var par struct {
+		RequestURI string `json:"request_uri"`
+		ExpiresIn  int    `json:"expires_in"`
+		Err        string `json:"error,omitempty"`
+		ErrDesc    string `json:"error_description,omitempty"`
+	}
+	if err := json.Unmarshal(b, &par); err != nil {
+		return "", errors.Wrapf(err, "error pushing authorization request: %s", resp.Status)
+	}
+	if par.Err != "" || par.ErrDesc != "" {
+		return "", errors.Errorf("Error pushing authorization request: %s. %s", par.Err, par.ErrDesc)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("error pushing authorization request: %s", resp.Status)
+	}
+	if par.RequestURI == "" {
+		return "", errors.New("error pushing authorization request: missing 'request_uri' in response")
+	}
+	return par.RequestURI, nil
+}
@@ -0,0 +1,217 @@
+package oauth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// domainPlaceholder is replaced in the provider of a preset with the value of
+// the --provider flag.
+const domainPlaceholder = "{domain}"
+
+// providerSettings are the settings of a provider preset or of a provider
+// profile stored in $STEPPATH/config/oauth-profiles.json.
+type providerSettings struct {
+	Preset                string   `json:"preset,omitempty"`
+	Provider              string   `json:"provider,omitempty"`
+	ClientID              string   `json:"clientID,omitempty"`
+	ClientSecret          string   `json:"clientSecret,omitempty"`
+	AuthorizationEndpoint string   `json:"authorizationEndpoint,omitempty"`
+	TokenEndpoint         string   `json:"tokenEndpoint,omitempty"`
+	Scopes                []string `json:"scopes,omitempty"`
+}
+
+// providerPresets are the built-in provider presets. The providers with the
+// {domain} placeholder require the --provider flag with the domain of the
+// tenant.
+var providerPresets = map[string]providerSettings{
+	"google": {
+		Provider: "google",
+		Scopes:   []string{"openid", "email"},
+	},
+	"github": {
+		AuthorizationEndpoint: "https://github.com/login/oauth/authorize",
+		TokenEndpoint:         "https://github.com/login/oauth/access_token",
+		Scopes:                []string{"read:user", "user:email"},
+	},
+	"azuread": {
+		Provider: "https://login.microsoftonline.com/common/v2.0",
+		Scopes:   []string{"openid", "email", "profile", "offline_access"},
+	},
+	"okta": {
+		Provider: "https://" + domainPlaceholder,
+		Scopes:   []string{"openid", "email", "profile", "offline_access"},
+	},
+	"auth0": {
+		Provider: "https://" + domainPlaceholder + "/",
+		Scopes:   []string{"openid", "email", "profile", "offline_access"},
+	},
+	"keycloak": {
+		Provider: "https://" + domainPlaceholder,
+		Scopes:   []string{"openid", "email", "profile"},
+	},
+}
+
+// presetNames returns the sorted names of the provider presets.
+func presetNames() []string {
+	names := make([]string, 0, len(providerPresets))
+	for name := range providerPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profilesPath returns the path of the file with the provider profiles.
+func profilesPath() string {
+	return filepath.Join(config.StepPath(), "config", "oauth-profiles.json")
+}
+
+// loadProfiles returns the provider profiles, if the file does not exist it
+// returns an empty map.
+func loadProfiles() (map[string]providerSettings, error) {
+	filename := profilesPath()
+	profiles := make(map[string]providerSettings)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return profiles, nil
+	}
+	b, err := utils.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &profiles); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	return profiles, nil
+}
+
+// saveProfile adds or replaces the provider profile with the given name.
+func saveProfile(name string, s providerSettings) error {
+	profiles, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	profiles[name] = s
+	b, err := json.MarshalIndent(profiles, "", "   ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling oauth profiles")
+	}
+	filename := profilesPath()
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return errs.FileError(err, filepath.Dir(filename))
+	}
+	// The profile can contain the client secret.
+	return utils.WriteFileAtomic(filename, append(b, '\n'), 0600, "", "")
+}
+
+// applyProviderSettings sets the flags that are not explicitly set using the
+// values of the provider profile in --provider-profile and the preset in
+// --provider-preset. Flags take precedence over profiles, and profiles over
+// presets. With --save-profile the resulting settings are stored as a new
+// profile.
+func applyProviderSettings(c *cli.Context) error {
+	var s providerSettings
+	if name := c.String("provider-profile"); name != "" {
+		profiles, err := loadProfiles()
+		if err != nil {
+			return err
+		}
+		p, ok := profiles[name]
+		if !ok {
+			return errors.Errorf("oauth profile '%s' not found in %s", name, profilesPath())
+		}
+		s = p
+	}
+
+	preset := c.String("provider-preset")
+	if preset == "" {
+		preset = s.Preset
+	}
+	if preset != "" {
+		p, ok := providerPresets[preset]
+		if !ok {
+			return errs.InvalidFlagValue(c, "provider-preset", preset, strings.Join(presetNames(), ", "))
+		}
+		s.Preset = preset
+		if s.Provider == "" || strings.Contains(p.Provider, domainPlaceholder) {
+			domain := s.Provider
+			if c.IsSet("provider") {
+				domain = c.String("provider")
+			}
+			switch {
+			case !strings.Contains(p.Provider, domainPlaceholder):
+				s.Provider = p.Provider
+			case domain == "":
+				return errs.RequiredWithFlag(c, "provider-preset", "provider")
+			case strings.HasPrefix(domain, "https://"):
+				s.Provider = domain
+			default:
+				s.Provider = strings.Replace(p.Provider, domainPlaceholder, strings.TrimSuffix(domain, "/"), 1)
+			}
+		}
+		if s.AuthorizationEndpoint == "" && s.TokenEndpoint == "" {
+			s.AuthorizationEndpoint, s.TokenEndpoint = p.AuthorizationEndpoint, p.TokenEndpoint
+		}
+		if len(s.Scopes) == 0 {
+			s.Scopes = p.Scopes
+		}
+	}
+
+	set := func(name, value string) error {
+		if value == "" || c.IsSet(name) {
+			return nil
+		}
+		return c.Set(name, value)
+	}
+	if err := set("provider", s.Provider); err != nil {
+		return err
+	}
+	if err := set("client-id", s.ClientID); err != nil {
+		return err
+	}
+	if err := set("client-secret", s.ClientSecret); err != nil {
+		return err
+	}
+	if err := set("authorization-endpoint", s.AuthorizationEndpoint); err != nil {
+		return err
+	}
+	if err := set("token-endpoint", s.TokenEndpoint); err != nil {
+		return err
+	}
+	if !c.IsSet("scope") {
+		for _, scope := range s.Scopes {
+			if err := c.Set("scope", scope); err != nil {
+				return err
+			}
+		}
+	}
+
+	if name := c.String("save-profile"); name != "" {
+		profile := providerSettings{
+			Preset:                s.Preset,
+			ClientID:              c.String("client-id"),
+			ClientSecret:          c.String("client-secret"),
+			AuthorizationEndpoint: c.String("authorization-endpoint"),
+			TokenEndpoint:         c.String("token-endpoint"),
+			Scopes:                c.StringSlice("scope"),
+		}
+		if c.IsSet("provider") {
+			profile.Provider = c.String("provider")
+		}
+		if err := saveProfile(name, profile); err != nil {
+			return err
+		}
+		ui.Printf("The oauth profile {{ \"%s\" | bold }} has been saved in %s.\n", name, profilesPath())
+	}
+	return nil
+}
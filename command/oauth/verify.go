@@ -0,0 +1,101 @@
+package oauth
+
+import (
+	"crypto"
+	_ "crypto/sha256" // Hashes used in the at_hash claim
+	_ "crypto/sha512"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/jose"
+)
+
+// idTokenLeeway is the clock skew allowed when validating an ID token.
+const idTokenLeeway = time.Minute
+
+// verifiedToken is the output of step oauth --verify, the tokens and the
+// verified claims of the ID token.
+type verifiedToken struct {
+	token
+	IDTokenClaims map[string]interface{} `json:"id_token_claims"`
+}
+
+// VerifyIDToken verifies the signature of the ID token using the JWKS of the
+// provider, validates the issuer, audience, expiration, nonce and the at_hash
+// claim, and returns the claims of the token.
+func (o *oauth) VerifyIDToken(tok *token, noCache bool) (map[string]interface{}, error) {
+	if tok.IDToken == "" {
+		return nil, errors.New("error verifying ID token: the provider did not return an ID token")
+	}
+	if o.jwksURI == "" || o.issuer == "" {
+		return nil, errors.New("error verifying ID token: the provider metadata does not have the 'issuer' and 'jwks_uri' properties")
+	}
+
+	jwt, err := jose.ParseSigned(tok.IDToken)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing ID token")
+	}
+	if len(jwt.Headers) != 1 {
+		return nil, errors.New("error verifying ID token: unexpected number of signatures")
+	}
+	hdr := jwt.Headers[0]
+	hash, ok := atHashAlgorithm(hdr.Algorithm)
+	if !ok {
+		return nil, errors.Errorf("error verifying ID token: unsupported algorithm %s", hdr.Algorithm)
+	}
+
+	jwk, err := jose.ParseKeySet(o.jwksURI, jose.WithKid(hdr.KeyID), jose.WithAlg(hdr.Algorithm), jose.WithNoCache(noCache))
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying ID token")
+	}
+
+	var claims jose.Claims
+	var all map[string]interface{}
+	if err := jwt.Claims(jwk, &claims, &all); err != nil {
+		return nil, errors.Wrap(err, "error verifying ID token signature")
+	}
+	if err := claims.ValidateWithLeeway(jose.Expected{
+		Issuer:   o.issuer,
+		Audience: jose.Audience{o.clientID},
+		Time:     time.Now(),
+	}, idTokenLeeway); err != nil {
+		return nil, errors.Wrap(err, "error verifying ID token")
+	}
+	if nonce, _ := all["nonce"].(string); nonce != o.nonce {
+		return nil, errors.New("error verifying ID token: invalid nonce")
+	}
+	if atHash, ok := all["at_hash"].(string); ok && tok.AccessToken != "" {
+		if atHash != accessTokenHash(hash, tok.AccessToken) {
+			return nil, errors.New("error verifying ID token: invalid at_hash")
+		}
+	}
+	return all, nil
+}
+
+// atHashAlgorithm returns the hash used in the at_hash claim for the given
+// signature algorithm. Symmetric algorithms are not supported.
+func atHashAlgorithm(alg string) (crypto.Hash, bool) {
+	switch {
+	case strings.HasSuffix(alg, "256") && alg != jose.HS256:
+		return crypto.SHA256, true
+	case strings.HasSuffix(alg, "384") && alg != jose.HS384:
+		return crypto.SHA384, true
+	case strings.HasSuffix(alg, "512") && alg != jose.HS512:
+		return crypto.SHA512, true
+	case alg == "EdDSA":
+		return crypto.SHA512, true
+	default:
+		return 0, false
+	}
+}
+
+// accessTokenHash returns the value of the at_hash claim for the given access
+// token, the base64url encoding of the left-most half of its hash.
+func accessTokenHash(hash crypto.Hash, accessToken string) string {
+	h := hash.New()
+	h.Write([]byte(accessToken))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
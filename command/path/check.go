@@ -0,0 +1,52 @@
+package path
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/errs"
+)
+
+// isPrivateKeyFile returns true if the given file is supposed to contain a
+// private key or other sensitive material.
+func isPrivateKeyFile(path string) bool {
+	if strings.HasPrefix(path, pki.GetSecretsPath()+string(filepath.Separator)) {
+		return true
+	}
+	name := filepath.Base(path)
+	return strings.HasSuffix(name, "_key") || strings.HasSuffix(name, ".key")
+}
+
+// checkPermissions walks the given directory and returns a list of warnings
+// for private keys readable by group or others and for world-writable files
+// and directories. Permissions are not validated on Windows.
+func checkPermissions(dir string) ([]string, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil
+	}
+
+	var warnings []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		mode := info.Mode()
+		switch {
+		case mode&os.ModeSymlink != 0:
+			return nil
+		case mode&0002 != 0:
+			warnings = append(warnings, fmt.Sprintf("%s is writable by others (mode %04o)", path, mode.Perm()))
+		case mode.IsRegular() && mode&0077 != 0 && isPrivateKeyFile(path):
+			warnings = append(warnings, fmt.Sprintf("%s is readable by group or others (mode %04o)", path, mode.Perm()))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errs.FileError(err, dir)
+	}
+	return warnings, nil
+}
@@ -0,0 +1,173 @@
+package path
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+// legacyDirs is the list of directories in the base step path that are moved
+// to a profile during a migration.
+var legacyDirs = []string{"config", "certs", "secrets", "db"}
+
+func migrateAction(ctx *cli.Context) error {
+	name := ctx.String("profile")
+	switch {
+	case name == "":
+		return errs.RequiredWithFlag(ctx, "migrate", "profile")
+	case strings.ContainsAny(name, `/\`) || name == "." || name == "..":
+		return errs.InvalidFlagValue(ctx, "profile", name, "")
+	case config.Profile() != "":
+		return errors.Errorf("step path %s is already using profiles", config.BasePath())
+	}
+
+	base := config.BasePath()
+	target := config.ProfilePath(name)
+	if _, err := os.Stat(target); err == nil {
+		return errors.Errorf("profile %s already exists", target)
+	}
+	if err := os.MkdirAll(target, 0700); err != nil {
+		return errs.FileError(err, target)
+	}
+
+	m := new(migration)
+	if err := m.run(base, target, name); err != nil {
+		if rerr := m.rollback(); rerr != nil {
+			return errors.Wrapf(rerr, "error restoring %s after %v", base, err)
+		}
+		os.Remove(target)
+		return err
+	}
+
+	for _, on := range m.renamed {
+		ui.Printf("Moved %s to %s.\n", on[0], on[1])
+	}
+	for _, f := range m.updated {
+		ui.Printf("Updated paths in %s.\n", f.name)
+	}
+	ui.Printf("The current profile is now %s.\n", name)
+	return nil
+}
+
+// migration moves the legacy directories in the base step path to a profile.
+// Every change is recorded so the migration can be rolled back if a step
+// fails.
+type migration struct {
+	renamed [][2]string
+	updated []updatedFile
+}
+
+// updatedFile is a configuration file with the paths replaced, and its
+// original contents.
+type updatedFile struct {
+	name string
+	data []byte
+	mode os.FileMode
+}
+
+// run moves the legacy directories to target, updates the paths in the
+// configuration files, and makes name the current profile.
+func (m *migration) run(base, target, name string) error {
+	replacer := new(pathReplacer)
+	for _, dir := range legacyDirs {
+		oldPath := filepath.Join(base, dir)
+		if _, err := os.Stat(oldPath); err != nil {
+			continue
+		}
+		newPath := filepath.Join(target, dir)
+		if err := os.Rename(oldPath, newPath); err != nil {
+			return errs.FileError(err, oldPath)
+		}
+		m.renamed = append(m.renamed, [2]string{oldPath, newPath})
+		replacer.add(oldPath, newPath)
+	}
+
+	// Update the paths in the configuration files.
+	files, err := filepath.Glob(filepath.Join(target, "config", "*.json"))
+	if err != nil {
+		return errors.Wrap(err, "error listing the configuration files")
+	}
+	for _, fn := range files {
+		if err := m.replaceFile(replacer, fn); err != nil {
+			return err
+		}
+	}
+
+	if err := config.SetCurrentProfile(name); err != nil {
+		return errs.FileError(err, filepath.Join(base, config.CurrentProfileFile))
+	}
+	return nil
+}
+
+// replaceFile replaces the old paths in the given file, keeping its original
+// contents.
+func (m *migration) replaceFile(r *pathReplacer, filename string) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return errs.FileError(err, filename)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errs.FileError(err, filename)
+	}
+	updated := r.replace(b)
+	if bytes.Equal(b, updated) {
+		return nil
+	}
+	// Record the file before writing it, a partial write is also restored.
+	m.updated = append(m.updated, updatedFile{name: filename, data: b, mode: info.Mode()})
+	if err := ioutil.WriteFile(filename, updated, info.Mode()); err != nil {
+		return errs.FileError(err, filename)
+	}
+	return nil
+}
+
+// rollback restores the original contents of the updated files and moves the
+// directories back to their original location.
+func (m *migration) rollback() error {
+	for i := len(m.updated) - 1; i >= 0; i-- {
+		f := m.updated[i]
+		if err := ioutil.WriteFile(f.name, f.data, f.mode); err != nil {
+			return errs.FileError(err, f.name)
+		}
+	}
+	for i := len(m.renamed) - 1; i >= 0; i-- {
+		on := m.renamed[i]
+		if err := os.Rename(on[1], on[0]); err != nil {
+			return errs.FileError(err, on[1])
+		}
+	}
+	return nil
+}
+
+// pathReplacer replaces the old paths with the new ones in JSON files.
+type pathReplacer struct {
+	oldnew [][2][]byte
+}
+
+// add adds a path to replace. Paths are JSON encoded so they match the
+// contents of the configuration files on all platforms.
+func (r *pathReplacer) add(oldPath, newPath string) {
+	o, _ := json.Marshal(oldPath)
+	n, _ := json.Marshal(newPath)
+	r.oldnew = append(r.oldnew, [2][]byte{
+		bytes.Trim(o, `"`), bytes.Trim(n, `"`),
+	})
+}
+
+// replace returns a copy of b with the old paths replaced.
+func (r *pathReplacer) replace(b []byte) []byte {
+	for _, on := range r.oldnew {
+		b = bytes.Replace(b, on[0], on[1], -1)
+	}
+	return b
+}
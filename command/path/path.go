@@ -1,24 +1,178 @@
 package path
 
 import (
+	"encoding/json"
 	"fmt"
+	"path/filepath"
 
+	"github.com/pkg/errors"
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
 	"github.com/urfave/cli"
 )
 
 func init() {
 	cmd := cli.Command{
-		Name:        "path",
-		Usage:       "print the configured step path and exit",
-		UsageText:   "step path",
-		Description: "**step ca** command prints the configured step path and exit",
-		Action: cli.ActionFunc(func(ctx *cli.Context) error {
-			fmt.Println(config.StepPath())
-			return nil
-		}),
+		Name:   "path",
+		Usage:  "print the configured step path and exit",
+		Action: command.ActionFunc(pathAction),
+		UsageText: `**step path** [**--base**] [**--profiles**] [**--check**] [**--json**]
+[**--migrate**] [**--profile**=<name>]`,
+		Description: `**step path** command prints the configured step path and exit.
+
+The step path is defined by the environment variable STEPPATH, or
+'$HOME/.step' if it is not set. If profiles are used, the configuration of
+each profile is stored in '$STEPPATH/profiles/<name>', and the current
+profile is defined by the environment variable STEPPROFILE or by the file
+'$STEPPATH/current-profile'.
+
+## EXAMPLES
+
+Print the step path of the current profile:
+'''
+$ step path
+/home/user/.step/profiles/default
+'''
+
+Print the base step path:
+'''
+$ step path --base
+/home/user/.step
+'''
+
+List the available profiles, the current one is marked with an asterisk:
+'''
+$ step path --profiles
+* default
+  staging
+'''
+
+Validate the permissions of the files in the step path:
+'''
+$ step path --check
+warning: /home/user/.step/secrets/root_ca_key is readable by group or others (mode 0644)
+'''
+
+Print all the resolved paths in JSON format:
+'''
+$ step path --json
+'''
+
+Migrate a step path without profiles to the profile structure:
+'''
+$ step path --migrate --profile default
+'''`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "base",
+				Usage: "Print the base step path instead of the path of the current profile.",
+			},
+			cli.BoolFlag{
+				Name:  "profiles",
+				Usage: "List the available profiles.",
+			},
+			cli.BoolFlag{
+				Name: "check",
+				Usage: `Validate the permissions of the files in the step path and warn about private
+keys readable by other users and world-writable files or directories.`,
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the resolved paths, profiles, and warnings in JSON format.",
+			},
+			cli.BoolFlag{
+				Name: "migrate",
+				Usage: `Migrate the configuration in the base step path to a profile. The name of
+the profile is defined with the **--profile** flag.`,
+			},
+			cli.StringFlag{
+				Name:  "profile",
+				Value: "default",
+				Usage: "The <name> of the profile to create when using **--migrate**.",
+			},
+		},
 	}
 
 	command.Register(cmd)
 }
+
+type pathInfo struct {
+	Base     string            `json:"base"`
+	Path     string            `json:"path"`
+	Profile  string            `json:"profile,omitempty"`
+	Profiles []string          `json:"profiles"`
+	Paths    map[string]string `json:"paths"`
+	Warnings []string          `json:"warnings"`
+}
+
+func pathAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	if ctx.Bool("migrate") {
+		return migrateAction(ctx)
+	}
+
+	profiles, err := config.Profiles()
+	if err != nil {
+		return errs.FileError(err, config.BasePath())
+	}
+
+	info := pathInfo{
+		Base:     config.BasePath(),
+		Path:     config.StepPath(),
+		Profile:  config.Profile(),
+		Profiles: profiles,
+		Paths: map[string]string{
+			"config":   pki.GetConfigPath(),
+			"certs":    pki.GetPublicPath(),
+			"secrets":  pki.GetSecretsPath(),
+			"db":       pki.GetDBPath(),
+			"root":     pki.GetRootCAPath(),
+			"defaults": filepath.Join(pki.GetConfigPath(), "defaults.json"),
+		},
+	}
+
+	if ctx.Bool("check") || ctx.Bool("json") {
+		if info.Warnings, err = checkPermissions(config.StepPath()); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case ctx.Bool("json"):
+		if info.Profiles == nil {
+			info.Profiles = []string{}
+		}
+		if info.Warnings == nil {
+			info.Warnings = []string{}
+		}
+		b, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling paths")
+		}
+		fmt.Println(string(b))
+	case ctx.Bool("profiles"):
+		for _, name := range info.Profiles {
+			if name == info.Profile {
+				fmt.Println("* " + name)
+			} else {
+				fmt.Println("  " + name)
+			}
+		}
+	case ctx.Bool("check"):
+		for _, w := range info.Warnings {
+			ui.Warnf("%s", w)
+		}
+	case ctx.Bool("base"):
+		fmt.Println(info.Base)
+	default:
+		fmt.Println(info.Path)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/policy"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func lintCommand() cli.Command {
+	return cli.Command{
+		Name:      "lint",
+		Action:    command.ActionFunc(lintAction),
+		Usage:     "validate a local policy file",
+		UsageText: `**step policy lint** [<file>]`,
+		Description: `**step policy lint** validates a local policy file and prints the resulting
+policy, with the default values of the keys that are not in the file. Unknown
+keys, unknown algorithm names and invalid values are reported as errors.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+:  The policy file to validate, <$STEPPATH/policy.json> by default.
+
+## EXIT CODES
+
+This command returns 0 on success and \>0 if the file is not valid.
+
+## EXAMPLES
+
+Validate the local policy:
+'''
+$ step policy lint
+{
+  "forbiddenAlgorithms": [
+    "sha1"
+  ],
+  "minRSASize": 3072,
+  "maxLeafDuration": "720h0m0s",
+  "maxCADuration": "87600h0m0s"
+}
+'''
+
+Validate a policy before installing it:
+'''
+$ step policy lint policy.json
+'''`,
+	}
+}
+
+func lintAction(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return errs.TooManyArguments(ctx)
+	}
+
+	filename := ctx.Args().First()
+	if filename == "" {
+		filename = policy.Path()
+	}
+
+	p, err := policy.Read(filename)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling policy")
+	}
+	fmt.Println(string(b))
+	return nil
+}
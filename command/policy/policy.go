@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	cmd := cli.Command{
+		Name:      "policy",
+		Usage:     "manage the local policy",
+		UsageText: "step policy <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step policy** command group provides commands to manage the local policy,
+the file <$STEPPATH/policy.json>. The policy restricts what all the step commands
+can do in this machine, so an organization can prevent the use of weak
+algorithms, long validities or unencrypted keys. If the file does not exist, the
+legacy file <$STEPPATH/config/certificate-policy.json> is used.
+
+A command that does not follow the policy fails with a description of the
+violation. Commands with the **--subtle** flag print the violations as warnings
+and continue, unless the policy is enforced.
+
+The policy is a JSON object with the following optional keys:
+
+**enforce**
+:  If true, the **--subtle** flag cannot be used to ignore the policy.
+
+**forbiddenAlgorithms**
+:  The list of names of the algorithms that cannot be used. It can include
+key types (e.g. "RSA", "OKP"), curves (e.g. "P-521", "Ed25519"), JOSE
+algorithms (e.g. "HS256", "RSA1_5"), hash functions (e.g. "md5", "sha1"), key
+derivation functions (e.g. "scrypt") and "NaCl".
+
+**minRSASize**
+:  The minimum size in bits of the RSA keys.
+
+**requireKeyEncryption**
+:  If true, private keys cannot be written without a password.
+
+**allowWildcards**
+:  If true, the certificates created locally can have wildcard SANs.
+
+**maxLeafDuration**
+:  The maximum validity of the leaf certificates created locally, 398 days by
+default. A value of "0s" disables the check.
+
+**maxCADuration**
+:  The maximum validity of the CA certificates created locally, 10 years by
+default. A value of "0s" disables the check.
+
+## EXAMPLES
+
+A policy that forbids RSA keys smaller than 3072 bits, SHA-1, MD5, RSA1_5 and
+unencrypted keys:
+'''
+{
+  "enforce": true,
+  "forbiddenAlgorithms": ["sha1", "md5", "RSA1_5"],
+  "minRSASize": 3072,
+  "requireKeyEncryption": true,
+  "maxLeafDuration": "720h"
+}
+'''
+
+Validate the local policy:
+'''
+$ step policy lint
+'''`,
+		Subcommands: cli.Commands{
+			lintCommand(),
+		},
+	}
+
+	command.Register(cmd)
+}
@@ -0,0 +1,383 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/tlsutil"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func handshakeCommand() cli.Command {
+	return cli.Command{
+		Name:   "handshake",
+		Action: command.ActionFunc(handshakeAction),
+		Usage:  "perform a TLS handshake and print the negotiated parameters",
+		UsageText: `**step tls handshake** <address>
+[**--servername**=<name>] [**--min-version**=<version>] [**--max-version**=<version>]
+[**--cipher-suite**=<name>...] [**--alpn**=<protocol>...] [**--cert**=<file>]
+[**--key**=<file>] [**--root**=<file>] [**--timeout**=<duration>] [**--format**=<format>]`,
+		Description: `**step tls handshake** command performs a TLS handshake with a server and
+prints the negotiated version, cipher suite and application protocol, the
+certificate chain presented by the server, and the result of the verification
+of the chain.
+
+The handshake is always completed, even if the certificate chain is not valid,
+so it is possible to inspect servers with invalid certificates. The chain is
+verified using the roots in **--root**, or the system roots if the flag is not
+used.
+
+## POSITIONAL ARGUMENTS
+
+<address>
+:  The address of the server in the form host:port. A URL like
+https://host[:port] can also be used. The port defaults to 443.
+
+## EXIT CODES
+
+This command returns 0 on success, 6 if the connection or the handshake fails,
+and 7 if the certificate chain presented by the server cannot be verified.
+
+## EXAMPLES
+
+Perform a handshake with a server:
+'''
+$ step tls handshake smallstep.com:443
+'''
+
+Perform a handshake using a root certificate and a client certificate:
+'''
+$ step tls handshake --root root_ca.crt --cert client.crt --key client.key \
+  ca.internal:9000
+'''
+
+Check if a server supports TLS 1.3 and HTTP/2:
+'''
+$ step tls handshake --min-version 1.3 --alpn h2 smallstep.com:443
+'''
+
+Check if a server accepts a specific cipher suite, printing the result in JSON:
+'''
+$ step tls handshake --max-version 1.2 \
+  --cipher-suite TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305 \
+  --format json smallstep.com:443
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "servername",
+				Usage: `The server <name> used for SNI and for the verification of the certificate.
+Defaults to the host of the address.`,
+			},
+			cli.StringFlag{
+				Name:  "min-version",
+				Usage: `The minimum TLS <version> to use. The options are 1.0, 1.1, 1.2 and 1.3.`,
+			},
+			cli.StringFlag{
+				Name:  "max-version",
+				Usage: `The maximum TLS <version> to use. The options are 1.0, 1.1, 1.2 and 1.3.`,
+			},
+			cli.StringSliceFlag{
+				Name: "cipher-suite",
+				Usage: `The <name> of a cipher suite to offer to the server, e.g.
+TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Use the flag multiple times to offer
+more than one cipher suite. Cipher suites are not configurable in TLS 1.3.`,
+			},
+			cli.StringSliceFlag{
+				Name: "alpn",
+				Usage: `The application <protocol> to offer to the server using ALPN, e.g. h2 or
+http/1.1. Use the flag multiple times to offer more than one protocol.`,
+			},
+			cli.StringFlag{
+				Name:  "cert",
+				Usage: `The <file> with the client certificate to use if requested by the server.`,
+			},
+			cli.StringFlag{
+				Name: "key",
+				Usage: `The <file> with the key corresponding to the client certificate. The key
+can also be a key URI, e.g. yubikey:9a or env:STEP_KEY.`,
+			},
+			cli.StringFlag{
+				Name: "root",
+				Usage: `The <file> with the root certificates used to verify the server certificate.
+Defaults to the system roots.`,
+			},
+			cli.DurationFlag{
+				Name:  "timeout",
+				Usage: `The maximum <duration> of the connection and the handshake.`,
+				Value: 10 * time.Second,
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Value: "text",
+				Usage: `The output <format>. The options are text and json.`,
+			},
+		},
+	}
+}
+
+// tlsVersions maps the version names used in the flags to the Go constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsVersionName returns the name of a TLS version.
+func tlsVersionName(v uint16) string {
+	for name, version := range tlsVersions {
+		if v == version {
+			return "TLS " + name
+		}
+	}
+	return fmt.Sprintf("0x%04X", v)
+}
+
+type handshakeCertificate struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serialNumber"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	DNSNames     []string  `json:"dnsNames,omitempty"`
+	IPAddresses  []string  `json:"ipAddresses,omitempty"`
+	Fingerprint  string    `json:"fingerprint"`
+}
+
+type handshakeResult struct {
+	Address                  string                 `json:"address"`
+	RemoteAddress            string                 `json:"remoteAddress"`
+	ServerName               string                 `json:"serverName"`
+	Version                  string                 `json:"version"`
+	CipherSuite              string                 `json:"cipherSuite"`
+	NegotiatedProtocol       string                 `json:"negotiatedProtocol,omitempty"`
+	ClientCertificateRequest bool                   `json:"clientCertificateRequested"`
+	ClientCertificateSent    bool                   `json:"clientCertificateSent"`
+	OCSPStapled              bool                   `json:"ocspStapled"`
+	Verified                 bool                   `json:"verified"`
+	VerificationError        string                 `json:"verificationError,omitempty"`
+	Certificates             []handshakeCertificate `json:"certificates"`
+}
+
+func handshakeAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 1); err != nil {
+		return err
+	}
+
+	address, host, err := parseHandshakeAddress(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	certFile, keyFile := ctx.String("cert"), ctx.String("key")
+	switch {
+	case certFile != "" && keyFile == "":
+		return errs.RequiredWithFlag(ctx, "cert", "key")
+	case keyFile != "" && certFile == "":
+		return errs.RequiredWithFlag(ctx, "key", "cert")
+	}
+
+	format := ctx.String("format")
+	if format != "text" && format != "json" {
+		return errs.InvalidFlagValue(ctx, "format", format, "text, json")
+	}
+
+	serverName := ctx.String("servername")
+	if serverName == "" && net.ParseIP(host) == nil {
+		serverName = host
+	}
+
+	var roots *x509.CertPool
+	if rootFile := ctx.String("root"); rootFile != "" {
+		if roots, err = x509util.ReadCertPool(rootFile); err != nil {
+			return errors.Wrapf(err, "error loading root certificates from %s", rootFile)
+		}
+	}
+
+	result := handshakeResult{
+		Address:    address,
+		ServerName: serverName,
+	}
+
+	// The verification is done after the handshake so the results can be
+	// printed even if the chain is not valid.
+	config := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		NextProtos:         ctx.StringSlice("alpn"),
+	}
+	if certFile != "" {
+		cert, err := tlsutil.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return errors.Wrap(err, "error loading client certificate")
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			result.ClientCertificateRequest = true
+			result.ClientCertificateSent = true
+			return &cert, nil
+		}
+	} else {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			result.ClientCertificateRequest = true
+			return &tls.Certificate{}, nil
+		}
+	}
+	if v := ctx.String("min-version"); v != "" {
+		if config.MinVersion = tlsVersions[v]; config.MinVersion == 0 {
+			return errs.InvalidFlagValue(ctx, "min-version", v, "1.0, 1.1, 1.2, 1.3")
+		}
+	}
+	if v := ctx.String("max-version"); v != "" {
+		if config.MaxVersion = tlsVersions[v]; config.MaxVersion == 0 {
+			return errs.InvalidFlagValue(ctx, "max-version", v, "1.0, 1.1, 1.2, 1.3")
+		}
+	}
+	if suites := x509util.CipherSuites(ctx.StringSlice("cipher-suite")); len(suites) > 0 {
+		if err := suites.Validate(); err != nil {
+			return errs.Usage(err)
+		}
+		config.CipherSuites = suites.Value()
+	}
+
+	dialer := &net.Dialer{Timeout: ctx.Duration("timeout")}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, config)
+	if err != nil {
+		return errs.Network(errors.Wrapf(err, "error performing handshake with %s", address))
+	}
+	defer conn.Close()
+
+	cs := conn.ConnectionState()
+	result.RemoteAddress = conn.RemoteAddr().String()
+	result.Version = tlsVersionName(cs.Version)
+	result.CipherSuite = x509util.CipherSuiteName(cs.CipherSuite)
+	result.NegotiatedProtocol = cs.NegotiatedProtocol
+	result.OCSPStapled = len(cs.OCSPResponse) > 0
+	result.Certificates = make([]handshakeCertificate, len(cs.PeerCertificates))
+	for i, crt := range cs.PeerCertificates {
+		result.Certificates[i] = newHandshakeCertificate(crt)
+	}
+
+	if err := verifyPeerCertificates(cs.PeerCertificates, roots, serverName, host); err != nil {
+		result.VerificationError = err.Error()
+	} else {
+		result.Verified = true
+	}
+
+	if format == "json" {
+		b, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling handshake")
+		}
+		fmt.Println(string(b))
+	} else {
+		printHandshake(result)
+	}
+
+	if !result.Verified {
+		return errs.Policy(errors.New("certificate verification failed"))
+	}
+	return nil
+}
+
+// parseHandshakeAddress parses an address or a URL and returns the address in
+// the form host:port and the host.
+func parseHandshakeAddress(s string) (string, string, error) {
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return "", "", errs.Usage(errors.Wrapf(err, "error parsing %s", s))
+		}
+		s = u.Host
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		host, port = strings.Trim(s, "[]"), "443"
+	}
+	if host == "" {
+		return "", "", errs.Usage(errors.Errorf("invalid address %s", s))
+	}
+	return net.JoinHostPort(host, port), host, nil
+}
+
+// verifyPeerCertificates verifies the chain presented by the server.
+func verifyPeerCertificates(certs []*x509.Certificate, roots *x509.CertPool, serverName, host string) error {
+	if len(certs) == 0 {
+		return errors.New("server did not present any certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		DNSName:       serverName,
+	}
+	if opts.DNSName == "" {
+		opts.DNSName = host
+	}
+	for _, crt := range certs[1:] {
+		opts.Intermediates.AddCert(crt)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+func newHandshakeCertificate(crt *x509.Certificate) handshakeCertificate {
+	c := handshakeCertificate{
+		Subject:      crt.Subject.String(),
+		Issuer:       crt.Issuer.String(),
+		SerialNumber: crt.SerialNumber.String(),
+		NotBefore:    crt.NotBefore.UTC(),
+		NotAfter:     crt.NotAfter.UTC(),
+		DNSNames:     crt.DNSNames,
+		Fingerprint:  x509util.Fingerprint(crt),
+	}
+	for _, ip := range crt.IPAddresses {
+		c.IPAddresses = append(c.IPAddresses, ip.String())
+	}
+	return c
+}
+
+func printHandshake(r handshakeResult) {
+	fmt.Printf("Address: %s (%s)\n", r.Address, r.RemoteAddress)
+	if r.ServerName != "" {
+		fmt.Printf("Server name: %s\n", r.ServerName)
+	}
+	fmt.Printf("Version: %s\n", r.Version)
+	fmt.Printf("Cipher suite: %s\n", r.CipherSuite)
+	if r.NegotiatedProtocol != "" {
+		fmt.Printf("Protocol: %s\n", r.NegotiatedProtocol)
+	}
+	switch {
+	case r.ClientCertificateSent:
+		fmt.Println("Client certificate: requested, sent")
+	case r.ClientCertificateRequest:
+		fmt.Println("Client certificate: requested, not sent")
+	default:
+		fmt.Println("Client certificate: not requested")
+	}
+	fmt.Printf("OCSP stapled: %t\n", r.OCSPStapled)
+	if r.Verified {
+		fmt.Println("Verification: ok")
+	} else {
+		fmt.Printf("Verification: failed: %s\n", r.VerificationError)
+	}
+	fmt.Println("Certificate chain:")
+	for i, c := range r.Certificates {
+		fmt.Printf("  %d Subject: %s\n", i, c.Subject)
+		fmt.Printf("    Issuer: %s\n", c.Issuer)
+		fmt.Printf("    Serial number: %s\n", c.SerialNumber)
+		fmt.Printf("    Validity: %s to %s\n", c.NotBefore.Format(time.RFC3339), c.NotAfter.Format(time.RFC3339))
+		if sans := append(append([]string{}, c.DNSNames...), c.IPAddresses...); len(sans) > 0 {
+			fmt.Printf("    SANs: %s\n", strings.Join(sans, ", "))
+		}
+		fmt.Printf("    Fingerprint: %s\n", c.Fingerprint)
+	}
+}
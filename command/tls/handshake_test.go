@@ -0,0 +1,36 @@
+package tls
+
+import (
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func TestParseHandshakeAddress(t *testing.T) {
+	type newTest struct {
+		input, address, host string
+		err                  bool
+	}
+	tests := map[string]newTest{
+		"host-port":    {"smallstep.com:8443", "smallstep.com:8443", "smallstep.com", false},
+		"host":         {"smallstep.com", "smallstep.com:443", "smallstep.com", false},
+		"url":          {"https://smallstep.com/foo", "smallstep.com:443", "smallstep.com", false},
+		"url-port":     {"https://smallstep.com:9000", "smallstep.com:9000", "smallstep.com", false},
+		"ipv6":         {"[::1]:8443", "[::1]:8443", "::1", false},
+		"ipv6-no-port": {"[::1]", "[::1]:443", "::1", false},
+		"empty":        {"", "", "", true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			address, host, err := parseHandshakeAddress(tc.input)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.address, address)
+			assert.Equals(t, tc.host, host)
+		})
+	}
+}
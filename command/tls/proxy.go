@@ -0,0 +1,336 @@
+package tls
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/tlsutil"
+	"github.com/smallstep/cli/errs"
+	"github.com/urfave/cli"
+)
+
+func proxyCommand() cli.Command {
+	return cli.Command{
+		Name:   "proxy",
+		Action: command.ActionFunc(proxyAction),
+		Usage:  "proxy connections between mutual TLS and a plaintext service",
+		UsageText: `**step tls proxy** **--listen**=<address> **--upstream**=<address>
+[**--mode**=<mode>] [**--cert**=<file>] [**--key**=<file>] [**--root**=<file>]
+[**--server-name**=<name>] [**--renew**] [**--ca-url**=<uri>]
+[**--reload-interval**=<duration>]`,
+		Description: `**step tls proxy** command accepts connections and forwards them to an
+upstream address, adding or removing a mutual TLS layer, so services that do
+not support TLS can use identities issued by the Step Certificate Authority
+without code changes.
+
+In **server** mode the proxy terminates mutual TLS: it accepts TLS connections,
+requires a client certificate signed by the root certificate, and forwards the
+plaintext stream to the upstream address.
+
+In **client** mode the proxy originates mutual TLS: it accepts plaintext
+connections, usually on a local address, and forwards them to the upstream
+address using TLS, presenting the given certificate as client certificate and
+verifying the upstream certificate using the root certificate.
+
+The certificate and key files are reloaded when they change on disk. With the
+**--renew** flag the proxy will also renew the certificate with the CA before
+2/3 of its validity period has elapsed, overwriting the certificate file.
+
+The root certificates are also reloaded when they change on disk, or when the
+root defined in the defaults.json changes, so a root rotation does not require
+a restart of the proxy. Sending a SIGHUP signal to the proxy forces the reload
+of the certificate, the key and the root certificates.
+
+## EXAMPLES
+
+Expose a plaintext service on port 8080 using mutual TLS on port 8443:
+'''
+$ step tls proxy --listen :8443 --upstream 127.0.0.1:8080 \
+  --cert internal.crt --key internal.key
+'''
+
+Allow a legacy client to connect to a mutual TLS service using a local port:
+'''
+$ step tls proxy --mode client --listen 127.0.0.1:5432 \
+  --upstream db.internal:5432 --cert client.crt --key client.key
+'''
+
+Terminate mutual TLS and renew the certificate automatically:
+'''
+$ step tls proxy --listen :8443 --upstream 127.0.0.1:8080 \
+  --cert internal.crt --key internal.key --renew \
+  --ca-url https://ca.smallstep.com:9000 --root root_ca.crt
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "listen",
+				Usage: `The TCP <address> to listen on (e.g. ":8443").`,
+			},
+			cli.StringFlag{
+				Name:  "upstream",
+				Usage: `The TCP <address> to forward the connections to (e.g. "127.0.0.1:8080").`,
+			},
+			cli.StringFlag{
+				Name:  "mode",
+				Value: "server",
+				Usage: `The <mode> of the proxy. The options are:
+
+    **server**
+    :  Accept mutual TLS connections and forward them in plaintext.
+
+    **client**
+    :  Accept plaintext connections and forward them using mutual TLS.`,
+			},
+			cli.StringFlag{
+				Name:  "cert",
+				Usage: `The <file> with the certificate presented by the proxy.`,
+			},
+			cli.StringFlag{
+				Name: "key",
+				Usage: `The <file> with the key corresponding to the certificate. The key can also
+be a key URI, e.g. yubikey:9a or env:STEP_KEY.`,
+			},
+			cli.StringFlag{
+				Name: "root",
+				Usage: `The <file> with the root certificates used to verify the peer certificates
+and the CA. Defaults to the root certificate of the bootstrapped CA.`,
+			},
+			cli.StringFlag{
+				Name: "server-name",
+				Usage: `The <name> used for SNI and to verify the upstream certificate in client
+mode. Defaults to the host of the upstream address.`,
+			},
+			cli.BoolFlag{
+				Name:  "renew",
+				Usage: `Renew the certificate with the CA before it expires.`,
+			},
+			cli.StringFlag{
+				Name:  "ca-url",
+				Usage: "<URI> of the targeted Step Certificate Authority.",
+			},
+			cli.DurationFlag{
+				Name: "reload-interval",
+				Usage: `The minimum <duration> between two checks for changes in the certificate,
+key and root files.`,
+				Value: tlsutil.DefaultReloadInterval,
+			},
+		},
+	}
+}
+
+func proxyAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	listen := ctx.String("listen")
+	upstream := ctx.String("upstream")
+	mode := ctx.String("mode")
+	certFile := ctx.String("cert")
+	keyFile := ctx.String("key")
+	serverName := ctx.String("server-name")
+
+	switch {
+	case listen == "":
+		return errs.RequiredFlag(ctx, "listen")
+	case upstream == "":
+		return errs.RequiredFlag(ctx, "upstream")
+	case mode != "server" && mode != "client":
+		return errs.InvalidFlagValue(ctx, "mode", mode, "server, client")
+	case certFile == "":
+		return errs.RequiredFlag(ctx, "cert")
+	case keyFile == "":
+		return errs.RequiredFlag(ctx, "key")
+	case ctx.Bool("renew") && ctx.String("ca-url") == "":
+		return errs.RequiredWithFlag(ctx, "renew", "ca-url")
+	case serverName != "" && mode != "client":
+		return errs.IncompatibleFlagValue(ctx, "server-name", "mode", mode)
+	}
+
+	roots, err := tlsutil.NewRootsReloader(func() (string, error) {
+		root, err := command.ReloadConfigValue(ctx, "root")
+		if err != nil || root != "" {
+			return root, err
+		}
+		return pki.GetRootCAPath(), nil
+	}, ctx.Duration("reload-interval"))
+	if err != nil {
+		return err
+	}
+
+	Info := log.New(os.Stdout, "INFO: ", log.LstdFlags)
+	Error := log.New(os.Stderr, "ERROR: ", log.LstdFlags)
+	roots.OnReload(func(file string, err error) {
+		if err != nil {
+			Error.Printf("error reloading root certificates: %v", err)
+		} else {
+			Info.Printf("root certificates %s reloaded", file)
+		}
+	})
+
+	reloader, err := tlsutil.NewCertificateReloader(certFile, keyFile, ctx.Duration("reload-interval"))
+	if err != nil {
+		return err
+	}
+	reloader.OnReload(func(c *tls.Certificate, err error) {
+		if err != nil {
+			Error.Printf("error reloading certificate: %v", err)
+		} else {
+			Info.Printf("certificate %s reloaded", certFile)
+		}
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	if ctx.Bool("renew") {
+		r, err := newAutoRenewer(ctx.String("ca-url"), certFile, roots, reloader)
+		if err != nil {
+			return err
+		}
+		go r.Run(stop, Info, Error)
+	}
+
+	var p *proxy
+	if mode == "server" {
+		p = &proxy{
+			upstream: upstream,
+			wrapIn: func(conn net.Conn) net.Conn {
+				return tls.Server(conn, &tls.Config{
+					GetCertificate: reloader.GetCertificate,
+					ClientAuth:     tls.RequireAndVerifyClientCert,
+					ClientCAs:      roots.Pool(),
+				})
+			},
+		}
+	} else {
+		if serverName == "" {
+			if serverName, _, err = net.SplitHostPort(upstream); err != nil {
+				return errs.InvalidFlagValue(ctx, "upstream", upstream, "")
+			}
+		}
+		p = &proxy{
+			upstream: upstream,
+			wrapOut: func(conn net.Conn) net.Conn {
+				return tls.Client(conn, &tls.Config{
+					GetClientCertificate: reloader.GetClientCertificate,
+					RootCAs:              roots.Pool(),
+					ServerName:           serverName,
+				})
+			},
+		}
+	}
+
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on at %s", listen)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				reloader.Reload()
+				roots.Reload()
+				continue
+			}
+			l.Close()
+			return
+		}
+	}()
+
+	Info.Printf("proxying %s at %s to %s", mode, l.Addr().String(), upstream)
+	return p.Serve(l, Info, Error)
+}
+
+// proxy forwards the connections accepted by a listener to an upstream
+// address. The wrapIn and wrapOut functions are used to add a TLS layer to the
+// accepted or the upstream connections.
+type proxy struct {
+	upstream string
+	wrapIn   func(net.Conn) net.Conn
+	wrapOut  func(net.Conn) net.Conn
+}
+
+// Serve accepts connections on the listener until it's closed.
+func (p *proxy) Serve(l net.Listener, Info, Error *log.Logger) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				Error.Println(err)
+				continue
+			}
+			// Closed listener
+			return nil
+		}
+		go p.handle(conn, Info, Error)
+	}
+}
+
+// handle forwards the given connection to the upstream address.
+func (p *proxy) handle(in net.Conn, Info, Error *log.Logger) {
+	defer in.Close()
+	remote := in.RemoteAddr().String()
+
+	if p.wrapIn != nil {
+		tc := p.wrapIn(in).(*tls.Conn)
+		if err := tc.Handshake(); err != nil {
+			Error.Printf("%s: handshake failed: %v", remote, err)
+			return
+		}
+		if certs := tc.ConnectionState().PeerCertificates; len(certs) > 0 {
+			Info.Printf("%s: accepted connection from %s", remote, certs[0].Subject)
+		}
+		in = tc
+	}
+
+	out, err := net.Dial("tcp", p.upstream)
+	if err != nil {
+		Error.Printf("%s: error connecting to %s: %v", remote, p.upstream, err)
+		return
+	}
+	defer out.Close()
+
+	if p.wrapOut != nil {
+		tc := p.wrapOut(out).(*tls.Conn)
+		if err := tc.Handshake(); err != nil {
+			Error.Printf("%s: handshake with %s failed: %v", remote, p.upstream, err)
+			return
+		}
+		out = tc
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(out, in)
+		closeWrite(out)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(in, out)
+		closeWrite(in)
+	}()
+	wg.Wait()
+}
+
+// closeWrite shuts down the writing side of the connection if possible.
+func closeWrite(conn net.Conn) {
+	if c, ok := conn.(interface {
+		CloseWrite() error
+	}); ok {
+		c.CloseWrite()
+	}
+}
@@ -0,0 +1,129 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/tlsutil"
+	"github.com/smallstep/cli/trace"
+	"github.com/smallstep/cli/utils"
+)
+
+// autoRenewer renews a certificate with the CA before it expires and reloads
+// it.
+type autoRenewer struct {
+	client    *ca.Client
+	transport *http.Transport
+	certFile  string
+	reloader  *tlsutil.CertificateReloader
+	roots     *tlsutil.RootsReloader
+}
+
+func newAutoRenewer(caURL, certFile string, roots *tlsutil.RootsReloader, reloader *tlsutil.CertificateReloader) (*autoRenewer, error) {
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			GetClientCertificate:     reloader.GetClientCertificate,
+			RootCAs:                  roots.Pool(),
+			PreferServerCipherSuites: true,
+		},
+	}
+	client, err := ca.NewClient(caURL, ca.WithTransport(trace.Transport(tr)))
+	if err != nil {
+		return nil, err
+	}
+	return &autoRenewer{
+		client:    client,
+		transport: tr,
+		certFile:  certFile,
+		reloader:  reloader,
+		roots:     roots,
+	}, nil
+}
+
+// Run renews the certificate periodically until stop is closed.
+func (r *autoRenewer) Run(stop chan struct{}, Info, Error *log.Logger) {
+	const durationOnErrors = 1 * time.Minute
+	for {
+		next, err := r.next()
+		if err != nil {
+			Error.Println(err)
+			next = durationOnErrors
+		}
+		Info.Printf("next certificate renewal in %s", next.Round(time.Second))
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(next):
+			if err := r.Renew(); err != nil {
+				Error.Println(err)
+			} else {
+				Info.Printf("certificate %s renewed", r.certFile)
+			}
+		}
+	}
+}
+
+// next returns the time to wait before the next renewal. Renewals happen
+// before 2/3 of the validity period, with a random jitter.
+func (r *autoRenewer) next() (time.Duration, error) {
+	cert := r.reloader.Certificate()
+	if cert == nil || len(cert.Certificate) == 0 {
+		return 0, errors.New("error loading certificate: certificate chain is empty")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return 0, errors.Wrap(err, "error parsing certificate")
+	}
+
+	period := leaf.NotAfter.Sub(leaf.NotBefore)
+	d := leaf.NotAfter.Sub(time.Now()) - period/3
+	if n := int64(period / 20); n > 0 {
+		d -= time.Duration(rand.Int63n(n))
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d, nil
+}
+
+// Renew renews the certificate, writes it to disk and reloads it.
+func (r *autoRenewer) Renew() error {
+	// Use the current roots, they might have been rotated.
+	if pool := r.roots.Pool(); pool != r.transport.TLSClientConfig.RootCAs {
+		r.transport.TLSClientConfig.RootCAs = pool
+		r.transport.CloseIdleConnections()
+	}
+
+	resp, err := r.client.Renew(trace.Transport(r.transport))
+	if err != nil {
+		return errors.Wrap(err, "error renewing certificate")
+	}
+
+	serverBlock, err := pemutil.Serialize(resp.ServerPEM.Certificate)
+	if err != nil {
+		return err
+	}
+	caBlock, err := pemutil.Serialize(resp.CaPEM.Certificate)
+	if err != nil {
+		return err
+	}
+	data := append(pem.EncodeToMemory(serverBlock), pem.EncodeToMemory(caBlock)...)
+	// The file is replaced atomically, so the reloader never reads a partial
+	// certificate.
+	if err := utils.WriteFileAtomic(r.certFile, data, 0600, "", ""); err != nil {
+		return err
+	}
+
+	// Idle connections use the previous certificate.
+	r.transport.CloseIdleConnections()
+	return r.reloader.Reload()
+}
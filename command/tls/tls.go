@@ -0,0 +1,36 @@
+package tls
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/urfave/cli"
+)
+
+// init creates and registers the tls command
+func init() {
+	cmd := cli.Command{
+		Name:      "tls",
+		Usage:     "proxy and debug TLS connections",
+		UsageText: "step tls <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step tls** command group provides facilities to secure and debug TLS
+connections using certificates issued by the Step Certificate Authority.
+
+## EXAMPLES
+
+Expose a plaintext service on port 8080 using mutual TLS on port 8443:
+'''
+$ step tls proxy --listen :8443 --upstream 127.0.0.1:8080 \
+  --cert internal.crt --key internal.key
+'''
+
+Debug the TLS configuration of a server:
+'''
+$ step tls handshake --root root_ca.crt ca.internal:9000
+'''`,
+		Subcommands: cli.Commands{
+			proxyCommand(),
+			handshakeCommand(),
+		},
+	}
+
+	command.Register(cmd)
+}
@@ -0,0 +1,375 @@
+package update
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/artifact"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/trace"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// defaultFeed is the url of the latest release of step.
+const defaultFeed = "https://api.github.com/repos/smallstep/cli/releases/latest"
+
+const (
+	// maxFeedSize is the maximum size of the release feed.
+	maxFeedSize = 1 << 20
+	// maxAssetSize is the maximum size of a release artifact.
+	maxAssetSize = 256 << 20
+)
+
+// signatureExtensions are the extensions of the detached signatures of the
+// release artifacts created with step crypto sign-file.
+var signatureExtensions = []string{".p7s", ".jws"}
+
+func checkCommand() cli.Command {
+	return cli.Command{
+		Name:   "check",
+		Action: command.ActionFunc(checkAction),
+		Usage:  "check if there is a new release of step",
+		UsageText: `**step update check** [**--feed**=<url>] [**--json**]
+[**--download** **--roots**=<file> [**--out**=<directory>]]`,
+		Description: `**step update check** reads the release feed and prints if there is a release
+newer than the current version.
+
+The feed is a JSON object in the format of the GitHub releases API, with the
+properties "tag_name", "html_url", and "assets", a list of objects with the
+properties "name" and "browser_download_url".
+
+With the **--download** flag the release artifact for the current platform,
+<step_<version>_<os>_<arch>.tar.gz>, is downloaded with its detached signature,
+created with **step crypto sign-file** and published as an artifact with the
+same name and the extension ".p7s" or ".jws". The artifact is only saved if
+the signature is valid and the signing certificate chains to one of the
+**--roots**. The binary is not installed, replacing it is left to the package
+manager or the user.
+
+## EXIT CODES
+
+This command returns 0 on success, including when a new release is available,
+and \>0 if the feed cannot be read or the signature of the artifact is not
+valid.
+
+## EXAMPLES
+
+Check if there is a new release:
+'''
+$ step update check
+A new release of step is available: 0.15.4 (current 0.15.3)
+https://github.com/smallstep/cli/releases/tag/v0.15.4
+'''
+
+Check using an internal mirror of the feed, and print the result in JSON:
+'''
+$ step update check --feed https://mirror.example.com/step/latest.json --json
+'''
+
+Download and verify the new release:
+'''
+$ step update check --download --roots release_root.crt --out /tmp
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "feed",
+				Usage: "The <url> of the release feed.",
+				Value: defaultFeed,
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the result in JSON.",
+			},
+			cli.BoolFlag{
+				Name:  "download",
+				Usage: "Download and verify the release artifact for the current platform if there is a new release.",
+			},
+			cli.StringFlag{
+				Name: "roots",
+				Usage: `The path to the PEM <file> with the root certificates trusted to sign the
+releases. Use a comma-separated list of files, or a directory, to use multiple
+roots.`,
+			},
+			cli.StringFlag{
+				Name:  "out",
+				Usage: "The <directory> where the release artifact is saved.",
+				Value: ".",
+			},
+			flags.Force,
+		},
+	}
+}
+
+// release is a release in the feed.
+type release struct {
+	TagName string  `json:"tag_name"`
+	HTMLURL string  `json:"html_url"`
+	Assets  []asset `json:"assets"`
+}
+
+// asset is an artifact of a release.
+type asset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+}
+
+// checkResult is the JSON output of step update check.
+type checkResult struct {
+	Current         string `json:"current"`
+	Latest          string `json:"latest"`
+	UpdateAvailable bool   `json:"updateAvailable"`
+	URL             string `json:"url,omitempty"`
+	Artifact        string `json:"artifact,omitempty"`
+	Signer          string `json:"signer,omitempty"`
+}
+
+func checkAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	feed := ctx.String("feed")
+	download := ctx.Bool("download")
+	roots := ctx.String("roots")
+	if download && roots == "" {
+		return errs.RequiredWithFlag(ctx, "download", "roots")
+	}
+
+	client := &http.Client{
+		Transport: trace.Transport(http.DefaultTransport),
+		Timeout:   5 * time.Minute,
+	}
+	rel, err := getRelease(client, feed)
+	if err != nil {
+		return err
+	}
+
+	current := config.BuildVersion()
+	latest := strings.TrimPrefix(rel.TagName, "v")
+	res := &checkResult{
+		Current: current,
+		Latest:  latest,
+		URL:     rel.HTMLURL,
+	}
+	if res.UpdateAvailable, err = isUpdateAvailable(latest, current); err != nil {
+		return err
+	}
+
+	if download && res.UpdateAvailable {
+		if err := downloadRelease(ctx, client, rel, latest, res); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Bool("json") {
+		b, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling result")
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	if !res.UpdateAvailable {
+		ui.Printf("step is up to date (%s).\n", current)
+		return nil
+	}
+	ui.Printf("A new release of step is available: %s (current %s)\n", latest, current)
+	if res.URL != "" {
+		fmt.Println(res.URL)
+	}
+	if res.Artifact != "" {
+		ui.Printf("The release has been verified and saved in %s, it was signed by %s.\n", res.Artifact, res.Signer)
+	}
+	return nil
+}
+
+// getRelease reads the release in the given feed.
+func getRelease(client *http.Client, feed string) (*release, error) {
+	b, err := get(client, feed, maxFeedSize)
+	if err != nil {
+		return nil, err
+	}
+	rel := new(release)
+	if err := json.Unmarshal(b, rel); err != nil {
+		return nil, errors.Wrapf(err, "error parsing release feed %s", feed)
+	}
+	if rel.TagName == "" {
+		return nil, errors.Errorf("error parsing release feed %s: tag_name is missing", feed)
+	}
+	return rel, nil
+}
+
+// downloadRelease downloads the artifact for the current platform of the
+// given release and its signature, and saves the artifact if the signature is
+// valid.
+func downloadRelease(ctx *cli.Context, client *http.Client, rel *release, version string, res *checkResult) error {
+	name := fmt.Sprintf("step_%s_%s_%s.tar.gz", version, runtime.GOOS, runtime.GOARCH)
+	art, sig := findAssets(rel.Assets, name)
+	switch {
+	case art == nil:
+		return errors.Errorf("release %s does not have the artifact %s", rel.TagName, name)
+	case sig == nil:
+		return errors.Errorf("release %s does not have a signature for %s", rel.TagName, name)
+	}
+
+	pool, err := x509util.ReadCertPool(ctx.String("roots"))
+	if err != nil {
+		return errors.Wrapf(err, "error reading roots from %s", ctx.String("roots"))
+	}
+
+	spinner := ui.NewSpinner("Downloading release...").Start()
+	data, err := get(client, art.DownloadURL, maxAssetSize)
+	if err != nil {
+		spinner.Stop()
+		return err
+	}
+	sigData, err := get(client, sig.DownloadURL, maxFeedSize)
+	spinner.Stop()
+	if err != nil {
+		return err
+	}
+
+	s, err := artifact.Verify(data, sigData, artifact.VerifyOptions{
+		Roots: pool,
+	})
+	if err != nil {
+		return errs.Crypto(errors.Wrapf(err, "error verifying the signature of %s", name))
+	}
+
+	filename := filepath.Join(ctx.String("out"), name)
+	if err := utils.WriteFile(filename, data, 0644); err != nil {
+		return err
+	}
+	res.Artifact = filename
+	res.Signer = s.Chain[0].Subject.CommonName
+	return nil
+}
+
+// findAssets returns the asset with the given name and its signature.
+func findAssets(assets []asset, name string) (art, sig *asset) {
+	art = findAsset(assets, name)
+	for _, ext := range signatureExtensions {
+		if sig = findAsset(assets, name+ext); sig != nil {
+			return art, sig
+		}
+	}
+	return art, nil
+}
+
+// findAsset returns the asset with the given name.
+func findAsset(assets []asset, name string) *asset {
+	for i := range assets {
+		if assets[i].Name == name {
+			return &assets[i]
+		}
+	}
+	return nil
+}
+
+// get returns the body of the given url, up to size bytes.
+func get(client *http.Client, rawurl string, size int64) ([]byte, error) {
+	resp, err := client.Get(rawurl)
+	if err != nil {
+		return nil, errs.Network(errors.Wrapf(err, "error downloading %s", rawurl))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errs.Network(errors.Errorf("error downloading %s: unexpected status %s", rawurl, resp.Status))
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, size+1))
+	if err != nil {
+		return nil, errs.Network(errors.Wrapf(err, "error downloading %s", rawurl))
+	}
+	if int64(len(b)) > size {
+		return nil, errors.Errorf("error downloading %s: the response is too large", rawurl)
+	}
+	return b, nil
+}
+
+// isUpdateAvailable returns true if the latest version is newer than the
+// current one. Development builds are always outdated, but the latest version
+// must be a valid semantic version.
+func isUpdateAvailable(latest, current string) (bool, error) {
+	if _, _, err := parseVersion(latest); err != nil {
+		return false, errors.Wrapf(err, "error parsing latest release %s", latest)
+	}
+	cmp, err := compareVersions(latest, current)
+	return err != nil || cmp > 0, nil
+}
+
+// compareVersions compares two semantic versions like 1.2.3 or 1.2.3-rc.1,
+// with an optional v prefix. It returns -1, 0 or 1 if a is lower, equal or
+// greater than b.
+func compareVersions(a, b string) (int, error) {
+	va, prea, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, preb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va {
+		switch {
+		case va[i] < vb[i]:
+			return -1, nil
+		case va[i] > vb[i]:
+			return 1, nil
+		}
+	}
+	// A pre-release is lower than the release
+	switch {
+	case prea == preb:
+		return 0, nil
+	case prea == "":
+		return 1, nil
+	case preb == "":
+		return -1, nil
+	case prea < preb:
+		return -1, nil
+	default:
+		return 1, nil
+	}
+}
+
+// parseVersion returns the major, minor and patch numbers and the pre-release
+// of a semantic version.
+func parseVersion(s string) ([3]int, string, error) {
+	var v [3]int
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	var pre string
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s, pre = s[:i], s[i+1:]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return v, "", errors.Errorf("invalid version '%s'", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, "", errors.Errorf("invalid version '%s'", s)
+		}
+		v[i] = n
+	}
+	return v, pre, nil
+}
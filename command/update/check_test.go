@@ -0,0 +1,118 @@
+package update
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		want    int
+		wantErr bool
+	}{
+		{"0.15.3", "0.15.3", 0, false},
+		{"v0.15.4", "0.15.3", 1, false},
+		{"0.15.3", "0.16.0", -1, false},
+		{"1.0.0", "0.99.99", 1, false},
+		{"0.15.3", "0.15.3-rc.1", 1, false},
+		{"0.15.3-rc.1", "0.15.3-rc.2", -1, false},
+		{"0.15.3+build.1", "0.15.3", 0, false},
+		{"0.15.3", "0000000-dev", 0, true},
+		{"0.15", "0.15.3", 0, true},
+		{"0.15.x", "0.15.3", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			got, err := compareVersions(tt.a, tt.b)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsUpdateAvailable(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+		wantErr         bool
+	}{
+		{"0.15.4", "0.15.3", true, false},
+		{"0.15.3", "0.15.3", false, false},
+		{"0.15.3", "0.16.0", false, false},
+		{"0.15.3", "0000000-dev", true, false},
+		{"nightly", "0.15.3", false, true},
+		{"", "0000000-dev", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.latest+"_"+tt.current, func(t *testing.T) {
+			got, err := isUpdateAvailable(tt.latest, tt.current)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFindAssets(t *testing.T) {
+	assets := []asset{
+		{Name: "step_0.15.4_linux_amd64.tar.gz.jws", DownloadURL: "jws"},
+		{Name: "step_0.15.4_linux_amd64.tar.gz", DownloadURL: "art"},
+		{Name: "step_0.15.4_linux_amd64.tar.gz.p7s", DownloadURL: "p7s"},
+		{Name: "step_0.15.4_darwin_amd64.tar.gz", DownloadURL: "darwin"},
+	}
+	art, sig := findAssets(assets, "step_0.15.4_linux_amd64.tar.gz")
+	require.Equal(t, "art", art.DownloadURL)
+	require.Equal(t, "p7s", sig.DownloadURL)
+
+	art, sig = findAssets(assets, "step_0.15.4_darwin_amd64.tar.gz")
+	require.Equal(t, "darwin", art.DownloadURL)
+	require.Nil(t, sig)
+
+	art, sig = findAssets(assets, "step_0.15.4_windows_amd64.tar.gz")
+	require.Nil(t, art)
+	require.Nil(t, sig)
+}
+
+func TestGetRelease(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			fmt.Fprint(w, `{"tag_name":"v0.15.4","html_url":"https://example.com/v0.15.4","assets":[{"name":"step_0.15.4_linux_amd64.tar.gz","browser_download_url":"https://example.com/step.tar.gz"}]}`)
+		case "/empty":
+			fmt.Fprint(w, `{}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	rel, err := getRelease(srv.Client(), srv.URL+"/latest")
+	require.NoError(t, err)
+	require.Equal(t, &release{
+		TagName: "v0.15.4",
+		HTMLURL: "https://example.com/v0.15.4",
+		Assets: []asset{
+			{Name: "step_0.15.4_linux_amd64.tar.gz", DownloadURL: "https://example.com/step.tar.gz"},
+		},
+	}, rel)
+
+	_, err = getRelease(srv.Client(), srv.URL+"/empty")
+	require.Error(t, err)
+	_, err = getRelease(srv.Client(), srv.URL+"/missing")
+	require.Error(t, err)
+
+	_, err = get(srv.Client(), srv.URL+"/latest", 10)
+	require.Error(t, err)
+}
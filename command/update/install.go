@@ -0,0 +1,399 @@
+package update
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/trace"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"github.com/urfave/cli"
+)
+
+// releasesFeed is the url of the list of releases of step, including the
+// pre-releases.
+const releasesFeed = "https://api.github.com/repos/smallstep/cli/releases"
+
+// maxBinarySize is the maximum size of the step binary in a release artifact.
+const maxBinarySize = 512 << 20
+
+// releaseKey is the base64-encoded DER public key used to sign the releases,
+// it is filled in during build by the Makefile.
+var releaseKey = ""
+
+func installCommand() cli.Command {
+	return cli.Command{
+		Name:   "install",
+		Action: command.ActionFunc(installAction),
+		Usage:  "install the latest release of step",
+		UsageText: `**step update install** [**--channel**=<name>] [**--feed**=<url>]
+[**--key**=<file>] [**--force**]`,
+		Description: `**step update install** downloads the latest release of step for the current
+platform and replaces the running binary with it.
+
+The release artifact, <step_<version>_<os>_<arch>.tar.gz>, must have a JWS
+signature published as an artifact with the same name and the extension
+".jws". The payload of the JWS is a manifest with the version, the platform and
+the SHA-256 checksum of the artifact:
+'''
+{"version":"0.15.4","os":"linux","arch":"amd64","sha256":"<checksum>"}
+'''
+
+The signature is verified with the release public key embedded in the binary,
+or with the key in **--key**. The binary is only replaced if the signature is
+valid, the checksum and the platform match the manifest, and the signed version
+is the version of the release and newer than the current one. The feed is not
+signed, so the version in the manifest is the one trusted. The manifest can be
+signed with **step crypto jws sign**:
+'''
+$ step crypto jws sign manifest.json --key release.key \
+  > step_0.15.4_linux_amd64.tar.gz.jws
+'''
+
+The binary is replaced atomically, a concurrent execution of step sees either
+the old or the new binary. If step was installed by a package manager, use the
+package manager to update it instead.
+
+## EXIT CODES
+
+This command returns 0 on success, including when step is already up to date,
+and \>0 if any error occurs.
+
+## EXAMPLES
+
+Install the latest stable release:
+'''
+$ step update install
+'''
+
+Install the latest release, including the pre-releases, without asking for
+confirmation:
+'''
+$ step update install --channel beta --force
+'''
+
+Install from an internal mirror, verifying the signature with its own key:
+'''
+$ step update install --feed https://mirror.example.com/step/latest.json \
+  --key mirror_pub.pem
+'''`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "channel",
+				Usage: `The release <name> to install. The options are:
+
+    **stable**
+    :  The latest stable release (default).
+
+    **beta**
+    :  The latest release, including the pre-releases.`,
+				Value: "stable",
+			},
+			cli.StringFlag{
+				Name: "feed",
+				Usage: `The <url> of the release feed. For the stable channel the feed is a release,
+for the beta channel a list of releases ordered by date. Defaults to the
+GitHub releases of step.`,
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "The path to the public key <file> used to verify the signature of the release, instead of the embedded key.",
+			},
+			cli.BoolFlag{
+				Name:  "force",
+				Usage: "Replace the binary without asking for confirmation.",
+			},
+		},
+	}
+}
+
+func installAction(ctx *cli.Context) error {
+	if err := errs.NumberOfArguments(ctx, 0); err != nil {
+		return err
+	}
+
+	feed := ctx.String("feed")
+	channel := ctx.String("channel")
+	switch channel {
+	case "stable":
+		if feed == "" {
+			feed = defaultFeed
+		}
+	case "beta":
+		if feed == "" {
+			feed = releasesFeed
+		}
+	default:
+		return errs.InvalidFlagValue(ctx, "channel", channel, "stable, beta")
+	}
+
+	pub, err := getReleaseKey(ctx.String("key"))
+	if err != nil {
+		return err
+	}
+
+	exe, err := executable()
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Transport: trace.Transport(http.DefaultTransport),
+		Timeout:   5 * time.Minute,
+	}
+	rel, err := getChannelRelease(client, feed, channel)
+	if err != nil {
+		return err
+	}
+
+	current := config.BuildVersion()
+	latest := strings.TrimPrefix(rel.TagName, "v")
+	// Development builds are always outdated
+	if cmp, err := compareVersions(latest, current); err == nil && cmp <= 0 {
+		ui.Printf("step is up to date (%s).\n", current)
+		return nil
+	}
+
+	name := fmt.Sprintf("step_%s_%s_%s.tar.gz", latest, runtime.GOOS, runtime.GOARCH)
+	art := findAsset(rel.Assets, name)
+	sig := findAsset(rel.Assets, name+".jws")
+	switch {
+	case art == nil:
+		return errors.Errorf("release %s does not have the artifact %s", rel.TagName, name)
+	case sig == nil:
+		return errors.Errorf("release %s does not have a signature for %s", rel.TagName, name)
+	}
+
+	spinner := ui.NewSpinner("Downloading release...").Start()
+	data, err := get(client, art.DownloadURL, maxAssetSize)
+	if err != nil {
+		spinner.Stop()
+		return err
+	}
+	sigData, err := get(client, sig.DownloadURL, maxFeedSize)
+	spinner.Stop()
+	if err != nil {
+		return err
+	}
+
+	m, err := verifyRelease(data, sigData, pub)
+	if err != nil {
+		return errs.Crypto(errors.Wrapf(err, "error verifying the signature of %s", name))
+	}
+	if m.Version != latest {
+		return errs.Crypto(errors.Errorf("error verifying the signature of %s: signed version %s does not match the release %s", name, m.Version, latest))
+	}
+	if err := checkNewer(m.Version, current); err != nil {
+		return err
+	}
+	bin, err := extractBinary(data)
+	if err != nil {
+		return errors.Wrapf(err, "error extracting %s", name)
+	}
+
+	if !ctx.Bool("force") {
+		str, err := ui.Prompt(fmt.Sprintf("Would you like to replace %s (%s) with %s [y/n]", exe, current, latest), ui.WithValidateYesNo())
+		if err != nil {
+			return err
+		}
+		if s := strings.ToLower(strings.TrimSpace(str)); s != "y" && s != "yes" {
+			return errors.New("step was not updated")
+		}
+	}
+
+	if err := replaceBinary(exe, bin); err != nil {
+		return err
+	}
+	ui.Printf("step has been updated to %s.\n", latest)
+	return nil
+}
+
+// getChannelRelease returns the latest release in the given feed and channel.
+// The beta channel feed can be a list of releases, the first one is the
+// latest.
+func getChannelRelease(client *http.Client, feed, channel string) (*release, error) {
+	if channel == "stable" {
+		return getRelease(client, feed)
+	}
+	b, err := get(client, feed, maxFeedSize)
+	if err != nil {
+		return nil, err
+	}
+	var rels []release
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		if err := json.Unmarshal(b, &rels); err != nil {
+			return nil, errors.Wrapf(err, "error parsing release feed %s", feed)
+		}
+	} else {
+		rels = make([]release, 1)
+		if err := json.Unmarshal(b, &rels[0]); err != nil {
+			return nil, errors.Wrapf(err, "error parsing release feed %s", feed)
+		}
+	}
+	if len(rels) == 0 {
+		return nil, errors.Errorf("error parsing release feed %s: there are no releases", feed)
+	}
+	if rels[0].TagName == "" {
+		return nil, errors.Errorf("error parsing release feed %s: tag_name is missing", feed)
+	}
+	return &rels[0], nil
+}
+
+// getReleaseKey returns the public key in the given file, or the embedded
+// release key if filename is empty.
+func getReleaseKey(filename string) (interface{}, error) {
+	if filename != "" {
+		b, err := utils.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return pemutil.ParseKey(b, pemutil.WithFilename(filename))
+	}
+	if releaseKey == "" {
+		return nil, errors.New("this build of step does not include a release key; use the '--key' flag")
+	}
+	der, err := base64.StdEncoding.DecodeString(releaseKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding the release key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing the release key")
+	}
+	return pub, nil
+}
+
+// releaseManifest is the signed payload of the signature of a release
+// artifact, it binds the artifact to a version and platform.
+type releaseManifest struct {
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	SHA256  string `json:"sha256"`
+}
+
+// verifyRelease verifies the JWS signature of data with the given public key
+// and returns the signed manifest. The checksum in the manifest must match data
+// and the platform must be the current one.
+func verifyRelease(data, sig []byte, pub interface{}) (*releaseManifest, error) {
+	jws, err := jose.ParseJWS(string(bytes.TrimSpace(sig)))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing signature")
+	}
+	payload, err := jws.Verify(pub)
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying signature")
+	}
+	m := new(releaseManifest)
+	if err := json.Unmarshal(payload, m); err != nil {
+		return nil, errors.Wrap(err, "error parsing release manifest")
+	}
+	sum := sha256.Sum256(data)
+	switch {
+	case m.Version == "":
+		return nil, errors.New("release manifest does not have a version")
+	case m.OS != runtime.GOOS || m.Arch != runtime.GOARCH:
+		return nil, errors.Errorf("release manifest is for %s/%s, not %s/%s", m.OS, m.Arch, runtime.GOOS, runtime.GOARCH)
+	case !strings.EqualFold(m.SHA256, hex.EncodeToString(sum[:])):
+		return nil, errors.New("release checksum does not match the manifest")
+	}
+	return m, nil
+}
+
+// checkNewer returns an error if the signed version is not newer than the
+// current one. Development builds can be replaced by any release.
+func checkNewer(version, current string) error {
+	if _, _, err := parseVersion(version); err != nil {
+		return errors.Wrapf(err, "error parsing signed version %s", version)
+	}
+	cmp, err := compareVersions(version, current)
+	if err == nil && cmp <= 0 {
+		return errors.Errorf("refusing to install %s: it is not newer than %s", version, current)
+	}
+	return nil
+}
+
+// extractBinary returns the step binary in the given tar.gz archive.
+func extractBinary(data []byte) ([]byte, error) {
+	bin := "step"
+	if runtime.GOOS == "windows" {
+		bin = "step.exe"
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.Errorf("the archive does not contain %s", bin)
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if hdr.Typeflag != tar.TypeReg || path.Base(hdr.Name) != bin {
+			continue
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(tr, maxBinarySize+1))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if len(b) > maxBinarySize {
+			return nil, errors.Errorf("%s is too large", hdr.Name)
+		}
+		return b, nil
+	}
+}
+
+// executable returns the path of the running binary.
+func executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", errors.Wrap(err, "error getting the path of step")
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", errors.Wrap(err, "error getting the path of step")
+	}
+	return exe, nil
+}
+
+// replaceBinary atomically replaces the binary in the given path, keeping its
+// permissions. Windows does not allow to replace a running binary, but it can
+// be renamed, so the old binary is moved to <exe>.old first.
+func replaceBinary(exe string, bin []byte) error {
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return errs.FileError(err, exe)
+	}
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return errors.Wrapf(err, "error replacing %s", exe)
+		}
+	}
+	return utils.WriteFileAtomic(exe, bin, fi.Mode().Perm(), "", "")
+}
@@ -0,0 +1,205 @@
+package update
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/smallstep/cli/jose"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetChannelRelease(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			fmt.Fprint(w, `{"tag_name":"v0.15.4"}`)
+		case "/releases":
+			fmt.Fprint(w, `[{"tag_name":"v0.16.0-rc.1"},{"tag_name":"v0.15.4"}]`)
+		case "/empty":
+			fmt.Fprint(w, `[]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		feed, channel string
+		want          string
+		wantErr       bool
+	}{
+		{"/latest", "stable", "v0.15.4", false},
+		{"/latest", "beta", "v0.15.4", false},
+		{"/releases", "beta", "v0.16.0-rc.1", false},
+		{"/releases", "stable", "", true},
+		{"/empty", "beta", "", true},
+		{"/missing", "beta", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel+tt.feed, func(t *testing.T) {
+			rel, err := getChannelRelease(srv.Client(), srv.URL+tt.feed, tt.channel)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, rel.TagName)
+		})
+	}
+}
+
+func TestVerifyRelease(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	data := []byte("the release")
+	sum := sha256.Sum256(data)
+	sign := func(m interface{}) []byte {
+		b, err := json.Marshal(m)
+		require.NoError(t, err)
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+		require.NoError(t, err)
+		jws, err := signer.Sign(b)
+		require.NoError(t, err)
+		raw, err := jws.CompactSerialize()
+		require.NoError(t, err)
+		return []byte(raw + "\n")
+	}
+	manifest := func(version, os, arch string, sum []byte) *releaseManifest {
+		return &releaseManifest{Version: version, OS: os, Arch: arch, SHA256: hex.EncodeToString(sum)}
+	}
+
+	sig := sign(manifest("0.15.4", runtime.GOOS, runtime.GOARCH, sum[:]))
+	m, err := verifyRelease(data, sig, key.Public())
+	require.NoError(t, err)
+	require.Equal(t, manifest("0.15.4", runtime.GOOS, runtime.GOARCH, sum[:]), m)
+
+	_, err = verifyRelease([]byte("other release"), sig, key.Public())
+	require.Error(t, err)
+	_, err = verifyRelease(data, sig, other.Public())
+	require.Error(t, err)
+	_, err = verifyRelease(data, []byte("not a signature"), key.Public())
+	require.Error(t, err)
+	_, err = verifyRelease(data, sign(manifest("", runtime.GOOS, runtime.GOARCH, sum[:])), key.Public())
+	require.Error(t, err)
+	_, err = verifyRelease(data, sign(manifest("0.15.4", "plan9", runtime.GOARCH, sum[:])), key.Public())
+	require.Error(t, err)
+	_, err = verifyRelease(data, sign(manifest("0.15.4", runtime.GOOS, "mips", sum[:])), key.Public())
+	require.Error(t, err)
+	_, err = verifyRelease(data, sign("not a manifest"), key.Public())
+	require.Error(t, err)
+
+	// A detached signature of the artifact is not enough
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+	require.NoError(t, err)
+	jws, err := signer.Sign(data)
+	require.NoError(t, err)
+	detached, err := jws.DetachedCompactSerialize()
+	require.NoError(t, err)
+	_, err = verifyRelease(data, []byte(detached), key.Public())
+	require.Error(t, err)
+}
+
+func TestCheckNewer(t *testing.T) {
+	tests := []struct {
+		version, current string
+		wantErr          bool
+	}{
+		{"0.15.4", "0.15.3", false},
+		{"0.15.4", "0.15.4-rc1", false},
+		{"0.15.4", "0000000-dev", false},
+		{"0.15.4", "0.15.4", true},
+		{"0.15.3", "0.15.4", true},
+		{"0.15.4-rc1", "0.15.4", true},
+		{"latest", "0.15.3", true},
+		{"latest", "0000000-dev", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version+"/"+tt.current, func(t *testing.T) {
+			err := checkNewer(tt.version, tt.current)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExtractBinary(t *testing.T) {
+	bin := "step"
+	if runtime.GOOS == "windows" {
+		bin = "step.exe"
+	}
+	archive := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for name, content := range files {
+			require.NoError(t, tw.WriteHeader(&tar.Header{
+				Name:     name,
+				Mode:     0755,
+				Size:     int64(len(content)),
+				Typeflag: tar.TypeReg,
+			}))
+			_, err := tw.Write([]byte(content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		require.NoError(t, gz.Close())
+		return buf.Bytes()
+	}
+
+	b, err := extractBinary(archive(map[string]string{
+		"step_0.15.4/README.md":  "readme",
+		"step_0.15.4/bin/" + bin: "binary",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, []byte("binary"), b)
+
+	_, err = extractBinary(archive(map[string]string{
+		"step_0.15.4/README.md": "readme",
+	}))
+	require.Error(t, err)
+
+	_, err = extractBinary([]byte("not an archive"))
+	require.Error(t, err)
+}
+
+func TestReplaceBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "step-update")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "step")
+	require.NoError(t, ioutil.WriteFile(exe, []byte("old"), 0755))
+	require.NoError(t, replaceBinary(exe, []byte("new")))
+
+	b, err := ioutil.ReadFile(exe)
+	require.NoError(t, err)
+	require.Equal(t, []byte("new"), b)
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(exe)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+	}
+
+	require.Error(t, replaceBinary(filepath.Join(dir, "missing"), []byte("new")))
+}
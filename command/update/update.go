@@ -0,0 +1,36 @@
+package update
+
+import (
+	"github.com/smallstep/cli/command"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	cmd := cli.Command{
+		Name:      "update",
+		Usage:     "check for and install new releases of step",
+		UsageText: "step update <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step update** command group provides commands to check for new releases of
+step, and to download and install them. step never checks for updates on its
+own, the commands in this group are the only ones that connect to the release
+feed.
+
+## EXAMPLES
+
+Check if there is a new release:
+'''
+$ step update check
+'''
+
+Install the latest beta release:
+'''
+$ step update install --channel beta
+'''`,
+		Subcommands: cli.Commands{
+			checkCommand(),
+			installCommand(),
+		},
+	}
+
+	command.Register(cmd)
+}
@@ -1,19 +1,56 @@
 package version
 
 import (
+	"encoding/json"
 	"fmt"
+	"runtime"
+	"runtime/debug"
 
+	"github.com/pkg/errors"
 	"github.com/urfave/cli"
 
 	"github.com/smallstep/cli/command"
 	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/credstore"
+	"github.com/smallstep/cli/crypto/fips"
+	"github.com/smallstep/cli/crypto/keys"
+	"github.com/smallstep/cli/fileurl"
+	"github.com/smallstep/cli/kms"
 )
 
 func init() {
 	cmd := cli.Command{
-		Name:   "version",
-		Usage:  "display the current version of the cli",
+		Name:      "version",
+		Usage:     "display the current version of the cli",
+		UsageText: "**step version** [**--json**]",
+		Description: `**step version** prints the version and release date of the cli.
+
+With the **--json** flag it prints a JSON object with the build provenance, the
+version, release date, Go version, platform and the Go modules used in the
+build, and the features supported by the binary: the key types, the key URI
+schemes of the KMS backends, the file URL schemes, and the credential store.
+
+## EXAMPLES
+
+Print the version:
+'''
+$ step version
+Smallstep CLI/0.15.3 (linux/amd64)
+Release Date: 2020-09-25 01:23 UTC
+'''
+
+Print the Go version used to build step:
+'''
+$ step version --json | jq -r .goVersion
+go1.15.2
+'''`,
 		Action: Command,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the version, build provenance and supported features in JSON.",
+			},
+		},
 	}
 
 	command.Register(cmd)
@@ -21,7 +58,94 @@ func init() {
 
 // Command prints out the current version of the tool
 func Command(c *cli.Context) error {
+	if c.Bool("json") {
+		b, err := json.MarshalIndent(getInfo(), "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error marshaling version")
+		}
+		fmt.Println(string(b))
+		return nil
+	}
 	fmt.Printf("%s\n", config.Version())
 	fmt.Printf("Release Date: %s\n", config.ReleaseDate())
 	return nil
 }
+
+// Info is the JSON representation of the version.
+type Info struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	ReleaseDate string    `json:"releaseDate"`
+	GoVersion   string    `json:"goVersion"`
+	OS          string    `json:"os"`
+	Arch        string    `json:"arch"`
+	FIPS        bool      `json:"fips"`
+	Build       *Build    `json:"build,omitempty"`
+	Features    *Features `json:"features"`
+}
+
+// Build contains the Go modules used to build the binary, as recorded by the
+// Go toolchain.
+type Build struct {
+	Path         string   `json:"path"`
+	Main         Module   `json:"main"`
+	Dependencies []Module `json:"dependencies,omitempty"`
+}
+
+// Module is a Go module with its version and checksum.
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Sum     string `json:"sum,omitempty"`
+	Replace string `json:"replace,omitempty"`
+}
+
+// Features contains the features supported by the binary.
+type Features struct {
+	KeyTypes        []string `json:"keyTypes"`
+	KMS             []string `json:"kms"`
+	FileURLSchemes  []string `json:"fileURLSchemes"`
+	CredentialStore string   `json:"credentialStore,omitempty"`
+}
+
+func getInfo() *Info {
+	info := &Info{
+		Name:        config.Name(),
+		Version:     config.BuildVersion(),
+		ReleaseDate: config.ReleaseDate(),
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		FIPS:        fips.Enabled(),
+		Features: &Features{
+			KeyTypes:       keys.KeyTypes(),
+			KMS:            kms.Schemes(),
+			FileURLSchemes: fileurl.Schemes(),
+		},
+	}
+	if store, err := credstore.New(); err == nil {
+		info.Features.CredentialStore = store.Name()
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Build = &Build{
+			Path: bi.Path,
+			Main: newModule(&bi.Main),
+		}
+		for _, dep := range bi.Deps {
+			info.Build.Dependencies = append(info.Build.Dependencies, newModule(dep))
+		}
+	}
+	return info
+}
+
+func newModule(m *debug.Module) Module {
+	mod := Module{
+		Path:    m.Path,
+		Version: m.Version,
+		Sum:     m.Sum,
+	}
+	if m.Replace != nil {
+		mod.Replace = m.Replace.Path + "@" + m.Replace.Version
+	}
+	return mod
+}
@@ -0,0 +1,111 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/ui"
+	"github.com/urfave/cli"
+)
+
+// WizardOption is one of the options presented by an interactive wizard.
+type WizardOption struct {
+	Name  string
+	Value string
+}
+
+// WizardSelect prompts the user to pick one of the options and returns its
+// value. The name is printed next to the selected option.
+func WizardSelect(label, name string, options []WizardOption) (string, error) {
+	i, _, err := ui.Select(label, options, ui.WithSelectTemplates(ui.NamedSelectTemplates(name)))
+	if err != nil {
+		return "", err
+	}
+	return options[i].Value, nil
+}
+
+// SetFlag sets the value of a flag of the current command, interactive
+// wizards use it so the rest of the command behaves as if the flag was passed.
+func SetFlag(ctx *cli.Context, name, value string) error {
+	if err := ctx.Set(name, value); err != nil {
+		return errors.Wrapf(err, "error setting flag '--%s'", name)
+	}
+	return nil
+}
+
+// IsFlagSet returns if any of the names of a flag, e.g. "crv, curve", is set.
+func IsFlagSet(ctx *cli.Context, name string) bool {
+	for _, n := range strings.Split(name, ",") {
+		if ctx.IsSet(strings.TrimSpace(n)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CommandLine returns the non-interactive command line equivalent to the
+// current command with the given arguments and the flags that are set. The
+// --interactive flag and the flags in skip are omitted.
+func CommandLine(ctx *cli.Context, args []string, skip ...string) string {
+	parts := []string{ctx.App.HelpName, ctx.Command.Name}
+	for _, arg := range args {
+		parts = append(parts, shellQuote(arg))
+	}
+
+	skip = append(skip, "interactive")
+	for _, f := range ctx.Command.Flags {
+		if !IsFlagSet(ctx, f.GetName()) {
+			continue
+		}
+		// Use the longest alias, e.g. --force instead of -f.
+		var name string
+		for _, n := range strings.Split(f.GetName(), ",") {
+			if n = strings.TrimSpace(n); len(n) > len(name) {
+				name = n
+			}
+		}
+		if containsString(skip, name) {
+			continue
+		}
+		switch f.(type) {
+		case cli.BoolFlag:
+			if ctx.Bool(name) {
+				parts = append(parts, "--"+name)
+			}
+		case cli.StringSliceFlag:
+			for _, v := range ctx.StringSlice(name) {
+				parts = append(parts, "--"+name+"="+shellQuote(v))
+			}
+		default:
+			parts = append(parts, "--"+name+"="+shellQuote(ctx.String(name)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes s so it can be safely pasted in a POSIX shell.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("@%+=:,./_-", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -2,11 +2,13 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/user"
 	"path"
 	"runtime"
+	"strings"
 	"time"
 )
 
@@ -21,16 +23,90 @@ var (
 // the default configuration path.
 const StepPathEnv = "STEPPATH"
 
-// stepPath will be populated in init() with the proper STEPPATH.
+// ProfileEnv defines the name of the environment variable that can overwrite
+// the current profile.
+const ProfileEnv = "STEPPROFILE"
+
+// ProfilesDir is the name of the directory under the base path where the
+// profiles are stored.
+const ProfilesDir = "profiles"
+
+// CurrentProfileFile is the name of the file under the base path that stores
+// the name of the current profile.
+const CurrentProfileFile = "current-profile"
+
+// basePath will be populated in init() with the proper STEPPATH.
+var basePath string
+
+// stepPath will be populated in init() with the path of the current profile,
+// or the base path if profiles are not used.
 var stepPath string
 
-// StepPath returns the path for the step configuration directory, this is
-// defined by the environment variable STEPPATH or if this is not set it will
-// default to '$HOME/.step'.
+// profile will be populated in init() with the name of the current profile.
+var profile string
+
+// StepPath returns the path for the step configuration directory. This is
+// the directory of the current profile if profiles are used, or the base path
+// otherwise. The base path is defined by the environment variable STEPPATH or
+// if this is not set it will default to '$HOME/.step'.
 func StepPath() string {
 	return stepPath
 }
 
+// BasePath returns the base path for the step configuration directory, this
+// is defined by the environment variable STEPPATH or if this is not set it
+// will default to '$HOME/.step'.
+func BasePath() string {
+	return basePath
+}
+
+// Profile returns the name of the current profile. It returns an empty string
+// if profiles are not used.
+func Profile() string {
+	return profile
+}
+
+// ProfilePath returns the directory for the profile with the given name.
+func ProfilePath(name string) string {
+	return path.Join(basePath, ProfilesDir, name)
+}
+
+// Profiles returns the list of the profiles available.
+func Profiles() ([]string, error) {
+	entries, err := ioutil.ReadDir(path.Join(basePath, ProfilesDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// SetCurrentProfile stores the given profile as the current one.
+func SetCurrentProfile(name string) error {
+	return ioutil.WriteFile(path.Join(basePath, CurrentProfileFile), []byte(name+"\n"), 0600)
+}
+
+// currentProfile returns the name of the current profile defined by the
+// environment variable STEPPROFILE or the current-profile file.
+func currentProfile() string {
+	if name := os.Getenv(ProfileEnv); name != "" {
+		return name
+	}
+	b, err := ioutil.ReadFile(path.Join(basePath, CurrentProfileFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
 func init() {
 	l := log.New(os.Stderr, "", 0)
 
@@ -58,6 +134,20 @@ func init() {
 	}
 	// cleanup
 	stepPath = path.Clean(stepPath)
+	basePath = stepPath
+
+	// Use the directory of the current profile if set.
+	if profile = currentProfile(); profile != "" {
+		if strings.ContainsAny(profile, `/\`) || profile == "." || profile == ".." {
+			l.Fatalf("Invalid profile name '%s'.", profile)
+		}
+		// The directory of the profile is created by the commands that write
+		// in it, when they need it.
+		stepPath = ProfilePath(profile)
+		if fi, err := os.Stat(stepPath); err == nil && !fi.IsDir() {
+			l.Fatalf("File '%s' is not a directory.", stepPath)
+		}
+	}
 }
 
 // Set updates the Version and ReleaseDate
@@ -67,15 +157,24 @@ func Set(n, v, t string) {
 	commit = v
 }
 
-// Version returns the current version of the binary
-func Version() string {
-	out := commit
+// Name returns the name of the binary.
+func Name() string {
+	return name
+}
+
+// BuildVersion returns the version embedded at build time, the git tag or
+// commit, or "0000000-dev" in development builds.
+func BuildVersion() string {
 	if commit == "N/A" {
-		out = "0000000-dev"
+		return "0000000-dev"
 	}
+	return commit
+}
 
+// Version returns the current version of the binary
+func Version() string {
 	return fmt.Sprintf("%s/%s (%s/%s)",
-		name, out, runtime.GOOS, runtime.GOARCH)
+		name, BuildVersion(), runtime.GOOS, runtime.GOARCH)
 }
 
 // ReleaseDate returns the time of when the binary was built
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DaemonsDir is the name of the directory under the step path where the
+// running daemons are registered.
+const DaemonsDir = "daemons"
+
+// Daemon is the registration of a daemon managing a certificate, like
+// 'step ca renew --daemon'.
+type Daemon struct {
+	Name        string    `json:"name"`
+	PID         int       `json:"pid"`
+	Certificate string    `json:"certificate"`
+	Key         string    `json:"key"`
+	CAURL       string    `json:"caUrl,omitempty"`
+	StartedAt   time.Time `json:"startedAt"`
+}
+
+// DaemonsPath returns the directory where the daemons are registered.
+func DaemonsPath() string {
+	return filepath.Join(StepPath(), DaemonsDir)
+}
+
+// RegisterDaemon writes the registration of the given daemon in the daemons
+// directory. The returned function removes the registration and should be
+// called when the daemon stops.
+func RegisterDaemon(d *Daemon) (func(), error) {
+	if d.PID == 0 {
+		d.PID = os.Getpid()
+	}
+	if d.StartedAt.IsZero() {
+		d.StartedAt = time.Now().UTC()
+	}
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(DaemonsPath(), 0700); err != nil {
+		return nil, err
+	}
+	filename := filepath.Join(DaemonsPath(), fmt.Sprintf("%s-%d.json", d.Name, d.PID))
+	if err := ioutil.WriteFile(filename, b, 0600); err != nil {
+		return nil, err
+	}
+	return func() {
+		os.Remove(filename)
+	}, nil
+}
+
+// Daemons returns the registered daemons sorted by start time. Registrations
+// that cannot be read are ignored.
+func Daemons() ([]*Daemon, error) {
+	files, err := ioutil.ReadDir(DaemonsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var daemons []*Daemon
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".json") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(DaemonsPath(), fi.Name()))
+		if err != nil {
+			continue
+		}
+		d := new(Daemon)
+		if err := json.Unmarshal(b, d); err != nil {
+			continue
+		}
+		daemons = append(daemons, d)
+	}
+	sort.Slice(daemons, func(i, j int) bool {
+		return daemons[i].StartedAt.Before(daemons[j].StartedAt)
+	})
+	return daemons, nil
+}
@@ -0,0 +1,128 @@
+// Package credstore implements the storage of credentials, like the CA
+// fingerprint or private keys, in the credential store of the operating
+// system: the login keychain on macOS, the Secret Service on Linux, and DPAPI
+// on Windows. If the credential store is not available the credentials are
+// stored in files under the step path.
+package credstore
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/config"
+)
+
+// BackendEnv defines the name of the environment variable that can be used to
+// select the credential store backend. Valid values are "keychain" and
+// "file". By default the keychain is used if it's available.
+const BackendEnv = "STEPCREDSTORE"
+
+// Backend names.
+const (
+	KeychainBackend = "keychain"
+	FileBackend     = "file"
+)
+
+// ErrNotFound is the error returned when a credential does not exist.
+var ErrNotFound = errors.New("credential not found")
+
+// Store is the interface implemented by the credential store backends.
+type Store interface {
+	// Name returns the name of the backend.
+	Name() string
+	// Get returns the credential with the given name or ErrNotFound.
+	Get(name string) ([]byte, error)
+	// Set creates or replaces the credential with the given name.
+	Set(name string, value []byte) error
+	// Delete removes the credential with the given name.
+	Delete(name string) error
+}
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// validateName checks that the name of a credential can be safely used as a
+// file name or a keychain attribute.
+func validateName(name string) error {
+	if !validName.MatchString(name) {
+		return errors.Errorf("invalid credential name '%s'", name)
+	}
+	return nil
+}
+
+// service returns the service name used in the keychain, credentials are
+// scoped to the current step path so different profiles don't share them.
+func service() string {
+	return "step:" + config.StepPath()
+}
+
+// New returns the credential store selected with the STEPCREDSTORE
+// environment variable, or the keychain if it's available and the file
+// backend otherwise.
+func New() (Store, error) {
+	switch backend := strings.ToLower(os.Getenv(BackendEnv)); backend {
+	case KeychainBackend:
+		if !keychainAvailable() {
+			return nil, errors.New("the keychain is not available on this system")
+		}
+		return NewKeychain(), nil
+	case FileBackend:
+		return NewFile(), nil
+	case "":
+		if keychainAvailable() {
+			return NewKeychain(), nil
+		}
+		return NewFile(), nil
+	default:
+		return nil, errors.Errorf("unsupported credential store '%s' in %s: valid values are keychain and file", backend, BackendEnv)
+	}
+}
+
+// Get is a helper that returns the credential with the given name from the
+// default credential store.
+func Get(name string) ([]byte, error) {
+	s, err := New()
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(name)
+}
+
+// Set is a helper that stores the credential with the given name in the
+// default credential store.
+func Set(name string, value []byte) error {
+	s, err := New()
+	if err != nil {
+		return err
+	}
+	return s.Set(name, value)
+}
+
+// MigrateFiles moves the credentials stored with the file backend to the given
+// store, and returns the names of the credentials moved.
+func MigrateFiles(dst Store) ([]string, error) {
+	src := NewFile().(*fileStore)
+	if dst.Name() == src.Name() {
+		return nil, nil
+	}
+	names, err := src.list()
+	if err != nil {
+		return nil, err
+	}
+	var migrated []string
+	for _, name := range names {
+		value, err := src.Get(name)
+		if err != nil {
+			return migrated, err
+		}
+		if err := dst.Set(name, value); err != nil {
+			return migrated, err
+		}
+		if err := src.Delete(name); err != nil {
+			return migrated, err
+		}
+		migrated = append(migrated, name)
+	}
+	return migrated, nil
+}
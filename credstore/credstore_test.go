@@ -0,0 +1,69 @@
+package credstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"fingerprint", "my-key", "my_key.pem", "Key1"} {
+		require.NoError(t, validateName(name), name)
+	}
+	for _, name := range []string{"", ".key", "../key", "my/key", "my key", "-key"} {
+		require.Error(t, validateName(name), name)
+	}
+}
+
+func TestNew(t *testing.T) {
+	defer os.Setenv(BackendEnv, os.Getenv(BackendEnv))
+
+	os.Setenv(BackendEnv, "file")
+	s, err := New()
+	require.NoError(t, err)
+	require.Equal(t, FileBackend, s.Name())
+
+	os.Setenv(BackendEnv, "foo")
+	_, err = New()
+	require.Error(t, err)
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := &fileStore{dir: filepath.Join(dir, "credentials")}
+
+	_, err = s.Get("my-key")
+	require.Equal(t, ErrNotFound, err)
+	names, err := s.list()
+	require.NoError(t, err)
+	require.Empty(t, names)
+
+	require.NoError(t, s.Set("my-key", []byte("secret")))
+	require.NoError(t, s.Set("fingerprint", []byte("abc")))
+	b, err := s.Get("my-key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), b)
+
+	st, err := os.Stat(filepath.Join(s.dir, "my-key"))
+	require.NoError(t, err)
+	if st.Mode().Perm()&0077 != 0 {
+		t.Errorf("unexpected file mode %v", st.Mode().Perm())
+	}
+
+	names, err = s.list()
+	require.NoError(t, err)
+	require.Equal(t, []string{"fingerprint", "my-key"}, names)
+
+	require.NoError(t, s.Delete("my-key"))
+	require.Equal(t, ErrNotFound, s.Delete("my-key"))
+	_, err = s.Get("my-key")
+	require.Equal(t, ErrNotFound, err)
+
+	require.Error(t, s.Set("../my-key", []byte("secret")))
+}
@@ -0,0 +1,86 @@
+package credstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/config"
+)
+
+// fileStore stores the credentials in files under
+// $STEPPATH/secrets/credentials.
+type fileStore struct {
+	dir string
+}
+
+// NewFile returns a Store that keeps the credentials in files under the step
+// path. The files are only readable by the current user.
+func NewFile() Store {
+	return &fileStore{
+		dir: filepath.Join(config.StepPath(), "secrets", "credentials"),
+	}
+}
+
+func (s *fileStore) Name() string {
+	return FileBackend
+}
+
+func (s *fileStore) Get(name string) ([]byte, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrapf(err, "error reading credential '%s'", name)
+	}
+	return b, nil
+}
+
+func (s *fileStore) Set(name string, value []byte) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return errors.Wrapf(err, "error creating %s", s.dir)
+	}
+	if err := ioutil.WriteFile(filepath.Join(s.dir, name), value, 0600); err != nil {
+		return errors.Wrapf(err, "error writing credential '%s'", name)
+	}
+	return nil
+}
+
+func (s *fileStore) Delete(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "error deleting credential '%s'", name)
+	}
+	return nil
+}
+
+// list returns the names of the credentials in the file store.
+func (s *fileStore) list() ([]string, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "error reading %s", s.dir)
+	}
+	var names []string
+	for _, f := range files {
+		if f.Mode().IsRegular() && validateName(f.Name()) == nil {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,115 @@
+// +build !windows
+
+package credstore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// keychainStore stores the credentials in the login keychain on macOS, using
+// the security command, or in the Secret Service on other unix systems, using
+// secret-tool. The values are base64 encoded so they can contain any byte.
+type keychainStore struct {
+	service string
+}
+
+// NewKeychain returns a Store that keeps the credentials in the keychain of
+// the operating system.
+func NewKeychain() Store {
+	return &keychainStore{service: service()}
+}
+
+// keychainAvailable returns true if the tool used to access the keychain is
+// available.
+func keychainAvailable() bool {
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = exec.LookPath("security")
+	case "linux", "freebsd", "netbsd", "openbsd":
+		_, err = exec.LookPath("secret-tool")
+	default:
+		return false
+	}
+	return err == nil
+}
+
+func (s *keychainStore) Name() string {
+	return KeychainBackend
+}
+
+func (s *keychainStore) Get(name string) ([]byte, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", s.service, "-a", name, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", s.service, "account", name)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		// Both tools return an error if the item does not exist.
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrapf(err, "error reading credential '%s' from the keychain", name)
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
+	b, err := base64.StdEncoding.DecodeString(string(out))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error decoding credential '%s'", name)
+	}
+	return b, nil
+}
+
+func (s *keychainStore) Set(name string, value []byte) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(value)
+
+	// The value is passed using the standard input so it's not visible in the
+	// list of processes.
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = bytes.NewBufferString(fmt.Sprintf("add-generic-password -U -s %q -a %q -w %q\n", s.service, name, encoded))
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label", "step "+name, "service", s.service, "account", name)
+		cmd.Stdin = bytes.NewBufferString(encoded)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "error writing credential '%s' to the keychain: %s", name, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (s *keychainStore) Delete(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if _, err := s.Get(name); err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "delete-generic-password", "-s", s.service, "-a", name)
+	} else {
+		cmd = exec.Command("secret-tool", "clear", "service", s.service, "account", name)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "error deleting credential '%s' from the keychain: %s", name, bytes.TrimSpace(out))
+	}
+	return nil
+}
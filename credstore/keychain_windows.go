@@ -0,0 +1,116 @@
+package credstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/config"
+	"golang.org/x/sys/windows"
+)
+
+// keychainStore stores the credentials in files encrypted with the Windows
+// Data Protection API (DPAPI), so they can only be decrypted by the current
+// user.
+type keychainStore struct {
+	dir     string
+	entropy []byte
+}
+
+// NewKeychain returns a Store that keeps the credentials encrypted with DPAPI.
+func NewKeychain() Store {
+	return &keychainStore{
+		dir:     filepath.Join(config.StepPath(), "secrets", "dpapi"),
+		entropy: []byte(service()),
+	}
+}
+
+// keychainAvailable returns true, DPAPI is always available on Windows.
+func keychainAvailable() bool {
+	return true
+}
+
+func (s *keychainStore) Name() string {
+	return KeychainBackend
+}
+
+func (s *keychainStore) Get(name string) ([]byte, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrapf(err, "error reading credential '%s'", name)
+	}
+	value, err := s.decrypt(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error decrypting credential '%s'", name)
+	}
+	return value, nil
+}
+
+func (s *keychainStore) Set(name string, value []byte) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	b, err := s.encrypt(value)
+	if err != nil {
+		return errors.Wrapf(err, "error encrypting credential '%s'", name)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return errors.Wrapf(err, "error creating %s", s.dir)
+	}
+	if err := ioutil.WriteFile(filepath.Join(s.dir, name), b, 0600); err != nil {
+		return errors.Wrapf(err, "error writing credential '%s'", name)
+	}
+	return nil
+}
+
+func (s *keychainStore) Delete(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "error deleting credential '%s'", name)
+	}
+	return nil
+}
+
+func newBlob(b []byte) *windows.DataBlob {
+	if len(b) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+}
+
+func blobBytes(b *windows.DataBlob) []byte {
+	out := make([]byte, b.Size)
+	copy(out, (*[1 << 30]byte)(unsafe.Pointer(b.Data))[:b.Size:b.Size])
+	return out
+}
+
+func (s *keychainStore) encrypt(value []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(newBlob(value), nil, newBlob(s.entropy), 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return blobBytes(&out), nil
+}
+
+func (s *keychainStore) decrypt(value []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(newBlob(value), nil, newBlob(s.entropy), 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return blobBytes(&out), nil
+}
@@ -0,0 +1,454 @@
+// Package artifact implements detached signatures of files using a
+// certificate and its private key, encoded as CMS (PKCS#7) or as JWS with the
+// certificate chain in the x5c header. Signatures can include an RFC 3161
+// timestamp that proves that the signature existed while the certificate was
+// still valid.
+package artifact
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/digitorus/timestamp"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/jose"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Supported signature formats.
+const (
+	FormatCMS = "cms"
+	FormatJWS = "jws"
+)
+
+// oidTimeStampToken is the id-aa-timeStampToken attribute defined in RFC 3161
+// Appendix A.
+var oidTimeStampToken = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+
+// Signature contains the properties of a verified signature.
+type Signature struct {
+	Format string
+	// Chain is the verified certificate chain, starting with the signer.
+	Chain []*x509.Certificate
+	// Timestamp is the RFC 3161 timestamp of the signature, if any.
+	Timestamp *timestamp.Timestamp
+}
+
+// SignOptions are the options used to sign a file.
+type SignOptions struct {
+	// Format is the encoding of the signature, cms or jws.
+	Format string
+	// Intermediates are included in the signature to build the chain.
+	Intermediates []*x509.Certificate
+	// TSA is the url of an RFC 3161 time-stamping authority. If it is not
+	// empty the signature is timestamped.
+	TSA string
+}
+
+// VerifyOptions are the options used to verify a signature.
+type VerifyOptions struct {
+	// Roots are the trusted roots of the signing certificate.
+	Roots *x509.CertPool
+	// KeyUsages are the extended key usages accepted in the signing
+	// certificate. Defaults to code signing.
+	KeyUsages []x509.ExtKeyUsage
+	// TSARoots are the trusted roots of the time-stamping authority. They are
+	// required to verify a signature with a timestamp, the system roots are
+	// never used because any certificate issued by them could backdate the
+	// signature.
+	TSARoots *x509.CertPool
+	// RequireTimestamp fails the verification if the signature does not have
+	// a timestamp.
+	RequireTimestamp bool
+}
+
+// Sign returns a detached signature of data using the given certificate and
+// key.
+func Sign(data []byte, crt *x509.Certificate, key crypto.PrivateKey, opts SignOptions) ([]byte, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("unsupported key type %T", key)
+	}
+	if !publicKeyEqual(crt.PublicKey, signer.Public()) {
+		return nil, errors.New("the private key does not match the certificate")
+	}
+
+	switch opts.Format {
+	case "", FormatCMS:
+		return signCMS(data, crt, signer, opts)
+	case FormatJWS:
+		return signJWS(data, crt, signer, opts)
+	default:
+		return nil, errors.Errorf("unsupported signature format %s", opts.Format)
+	}
+}
+
+// Verify verifies a detached signature of data, the chain of the signing
+// certificate and the timestamp if present. The format of the signature is
+// detected automatically.
+func Verify(data, sig []byte, opts VerifyOptions) (*Signature, error) {
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+	}
+	var s *Signature
+	var err error
+	if b := bytes.TrimSpace(sig); len(b) > 0 && b[0] == '{' {
+		s, err = verifyJWS(data, b, opts)
+	} else {
+		s, err = verifyCMS(data, sig, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.RequireTimestamp && s.Timestamp == nil {
+		return nil, errors.New("the signature does not have a timestamp")
+	}
+	return s, nil
+}
+
+func signCMS(data []byte, crt *x509.Certificate, signer crypto.Signer, opts SignOptions) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return nil, errors.New("Ed25519 keys are not supported by the cms format, use the jws format")
+	}
+	sd, err := pkcs7.NewSignedData(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating signature")
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSignerChain(crt, signer, opts.Intermediates, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, errors.Wrap(err, "error signing file")
+	}
+	sd.Detach()
+
+	if opts.TSA != "" {
+		info := sd.GetSignedData().SignerInfos[0]
+		ts, err := requestTimestamp(opts.TSA, info.EncryptedDigest)
+		if err != nil {
+			return nil, err
+		}
+		if err := info.SetUnauthenticatedAttributes([]pkcs7.Attribute{{
+			Type:  oidTimeStampToken,
+			Value: asn1.RawValue{FullBytes: ts.RawToken},
+		}}); err != nil {
+			return nil, errors.Wrap(err, "error adding timestamp")
+		}
+	}
+
+	der, err := sd.Finish()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating signature")
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PKCS7",
+		Bytes: der,
+	}), nil
+}
+
+func verifyCMS(data, sig []byte, opts VerifyOptions) (*Signature, error) {
+	if block, _ := pem.Decode(sig); block != nil {
+		sig = block.Bytes
+	}
+	p7, err := pkcs7.Parse(sig)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing signature")
+	}
+	if len(p7.Signers) != 1 {
+		return nil, errors.Errorf("the signature has %d signers, expected 1", len(p7.Signers))
+	}
+	p7.Content = data
+
+	var ts *timestamp.Timestamp
+	for _, attr := range p7.Signers[0].UnauthenticatedAttributes {
+		if attr.Type.Equal(oidTimeStampToken) {
+			if ts, err = verifyTimestamp(attr.Value.Bytes, p7.Signers[0].EncryptedDigest, opts.TSARoots); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// A valid timestamp proves the signature was created while the
+	// certificate was valid.
+	if ts != nil {
+		err = p7.VerifyWithChainAtTime(opts.Roots, ts.Time)
+	} else {
+		err = p7.VerifyWithChain(opts.Roots)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying signature")
+	}
+
+	signer := p7.GetOnlySigner()
+	chain, err := verifyChain(signer, p7.Certificates, opts.Roots, opts.KeyUsages, ts)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{
+		Format:    FormatCMS,
+		Chain:     chain,
+		Timestamp: ts,
+	}, nil
+}
+
+// jwsSignature is the flattened JWS JSON serialization of a detached
+// signature, as defined in RFC 7515 Section 7.2.2 and Appendix F.
+type jwsSignature struct {
+	Protected string          `json:"protected"`
+	Header    *jwsUnprotected `json:"header,omitempty"`
+	Signature string          `json:"signature"`
+}
+
+// jwsUnprotected is the unprotected header of a JWS signature, it contains
+// the timestamp token of the signature.
+type jwsUnprotected struct {
+	Timestamp string `json:"tst,omitempty"`
+}
+
+func signJWS(data []byte, crt *x509.Certificate, signer crypto.Signer, opts SignOptions) ([]byte, error) {
+	alg, err := jwsAlgorithm(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	x5c := []string{base64.StdEncoding.EncodeToString(crt.Raw)}
+	for _, c := range opts.Intermediates {
+		x5c = append(x5c, base64.StdEncoding.EncodeToString(c.Raw))
+	}
+
+	so := new(jose.SignerOptions)
+	so.WithHeader("x5c", x5c)
+	s, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: alg,
+		Key:       signer,
+	}, so)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating JWS signer")
+	}
+	jws, err := s.Sign(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing file")
+	}
+	raw, err := jws.DetachedCompactSerialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing JWS")
+	}
+	parts := strings.Split(raw, ".")
+	sig := jwsSignature{
+		Protected: parts[0],
+		Signature: parts[2],
+	}
+
+	if opts.TSA != "" {
+		ts, err := requestTimestamp(opts.TSA, jws.Signatures[0].Signature)
+		if err != nil {
+			return nil, err
+		}
+		sig.Header = &jwsUnprotected{
+			Timestamp: base64.RawURLEncoding.EncodeToString(ts.RawToken),
+		}
+	}
+
+	b, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling JWS")
+	}
+	return append(b, '\n'), nil
+}
+
+func verifyJWS(data, b []byte, opts VerifyOptions) (*Signature, error) {
+	var sig jwsSignature
+	if err := json.Unmarshal(b, &sig); err != nil {
+		return nil, errors.Wrap(err, "error parsing signature")
+	}
+	jws, err := jose.ParseJWS(sig.Protected + ".." + sig.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing signature")
+	}
+	signature := jws.Signatures[0]
+
+	var ts *timestamp.Timestamp
+	if sig.Header != nil && sig.Header.Timestamp != "" {
+		token, err := base64.RawURLEncoding.DecodeString(sig.Header.Timestamp)
+		if err != nil {
+			return nil, errors.Wrap(err, "error decoding timestamp")
+		}
+		if ts, err = verifyTimestamp(token, signature.Signature, opts.TSARoots); err != nil {
+			return nil, err
+		}
+	}
+
+	vo := x509.VerifyOptions{
+		Roots:     opts.Roots,
+		KeyUsages: opts.KeyUsages,
+	}
+	if ts != nil {
+		vo.CurrentTime = ts.Time
+	}
+	chains, err := signature.Protected.Certificates(vo)
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying certificate chain")
+	}
+	if err := jws.DetachedVerify(data, chains[0][0].PublicKey); err != nil {
+		return nil, errors.Wrap(err, "error verifying signature")
+	}
+	return &Signature{
+		Format:    FormatJWS,
+		Chain:     chains[0],
+		Timestamp: ts,
+	}, nil
+}
+
+// verifyChain returns the chain from the signer to one of the roots, the
+// signer must have one of the given extended key usages.
+func verifyChain(signer *x509.Certificate, certs []*x509.Certificate, roots *x509.CertPool, keyUsages []x509.ExtKeyUsage, ts *timestamp.Timestamp) ([]*x509.Certificate, error) {
+	if signer == nil {
+		return nil, errors.New("the signature does not contain the signing certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		if c != signer {
+			intermediates.AddCert(c)
+		}
+	}
+	vo := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     keyUsages,
+	}
+	if ts != nil {
+		vo.CurrentTime = ts.Time
+	}
+	chains, err := signer.Verify(vo)
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying certificate chain")
+	}
+	return chains[0], nil
+}
+
+// requestTimestamp requests an RFC 3161 timestamp of the given signature
+// value.
+func requestTimestamp(tsa string, signature []byte) (*timestamp.Timestamp, error) {
+	req, err := timestamp.CreateRequest(bytes.NewReader(signature), &timestamp.RequestOptions{
+		Hash:         crypto.SHA256,
+		Certificates: true,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating timestamp request")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(tsa, "application/timestamp-query", bytes.NewReader(req))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error requesting timestamp from %s", tsa)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading timestamp from %s", tsa)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error requesting timestamp from %s: %s", tsa, resp.Status)
+	}
+
+	ts, err := timestamp.ParseResponse(body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing timestamp from %s", tsa)
+	}
+	if err := checkTimestampDigest(ts, signature); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// verifyTimestamp parses an RFC 3161 timestamp token, and verifies that it
+// covers the given signature value and that it is signed by a time-stamping
+// authority trusted by roots. The certificate of the authority must have the
+// time stamping extended key usage, as required by RFC 3161 Section 2.3.
+func verifyTimestamp(token, signature []byte, roots *x509.CertPool) (*timestamp.Timestamp, error) {
+	if roots == nil {
+		return nil, errors.New("the signature has a timestamp, the roots of the time-stamping authority are required to verify it")
+	}
+	ts, err := timestamp.Parse(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing timestamp")
+	}
+	if err := checkTimestampDigest(ts, signature); err != nil {
+		return nil, err
+	}
+
+	p7, err := pkcs7.Parse(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing timestamp")
+	}
+	if err := p7.VerifyWithChainAtTime(roots, ts.Time); err != nil {
+		return nil, errors.Wrap(err, "error verifying timestamp")
+	}
+	tsa := p7.GetOnlySigner()
+	if tsa == nil {
+		return nil, errors.New("error verifying timestamp: the timestamp does not contain the signing certificate")
+	}
+	if len(tsa.ExtKeyUsage) != 1 || tsa.ExtKeyUsage[0] != x509.ExtKeyUsageTimeStamping {
+		return nil, errors.New("error verifying timestamp: the certificate of the time-stamping authority does not have the time stamping extended key usage")
+	}
+	if _, err := verifyChain(tsa, p7.Certificates, roots, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}, ts); err != nil {
+		return nil, errors.Wrap(err, "error verifying timestamp")
+	}
+	return ts, nil
+}
+
+func checkTimestampDigest(ts *timestamp.Timestamp, signature []byte) error {
+	if ts.HashAlgorithm != crypto.SHA256 {
+		return errors.Errorf("unsupported timestamp hash algorithm %s", ts.HashAlgorithm)
+	}
+	sum := sha256.Sum256(signature)
+	if !bytes.Equal(ts.HashedMessage, sum[:]) {
+		return errors.New("the timestamp does not match the signature")
+	}
+	return nil
+}
+
+func jwsAlgorithm(pub crypto.PublicKey) (jose.SignatureAlgorithm, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		default:
+			return "", errors.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+		}
+	case *rsa.PublicKey:
+		return jose.RS256, nil
+	case ed25519.PublicKey:
+		return jose.EdDSA, nil
+	default:
+		return "", errors.Errorf("unsupported key type %T", pub)
+	}
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	ab, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	bb, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ab, bb)
+}
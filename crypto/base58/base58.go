@@ -0,0 +1,54 @@
+// Package base58 implements the base58 encoding using the bitcoin alphabet, as
+// used in multibase (base58btc) and did:key identifiers.
+package base58
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var bigRadix = big.NewInt(58)
+
+// Encode encodes the given bytes using the bitcoin base58 alphabet.
+func Encode(b []byte) string {
+	x := new(big.Int).SetBytes(b)
+	mod := new(big.Int)
+	var out []byte
+	for x.Sign() > 0 {
+		x.DivMod(x, bigRadix, mod)
+		out = append(out, alphabet[mod.Int64()])
+	}
+	// Leading zeros are encoded as the first character of the alphabet.
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// Decode decodes a string encoded using the bitcoin base58 alphabet.
+func Decode(s string) ([]byte, error) {
+	x := new(big.Int)
+	for i := 0; i < len(s); i++ {
+		n := strings.IndexByte(alphabet, s[i])
+		if n < 0 {
+			return nil, errors.Errorf("invalid base58 character %q", s[i])
+		}
+		x.Mul(x, bigRadix)
+		x.Add(x, big.NewInt(int64(n)))
+	}
+	var zeros int
+	for zeros < len(s) && s[zeros] == alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), x.Bytes()...), nil
+}
@@ -0,0 +1,25 @@
+package base58
+
+import (
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func TestBase58(t *testing.T) {
+	tests := map[string][]byte{
+		"":                  {},
+		"1":                 {0},
+		"11":                {0, 0},
+		"2NEpo7TZRRrLZSi2U": []byte("Hello World!"),
+		"1112":              {0, 0, 0, 1},
+	}
+	for s, b := range tests {
+		assert.Equals(t, s, Encode(b))
+		got, err := Decode(s)
+		assert.FatalError(t, err)
+		assert.Equals(t, b, got)
+	}
+	_, err := Decode("0OIl")
+	assert.Error(t, err)
+}
@@ -0,0 +1,404 @@
+// Package codesign extracts the certificate chains of the signatures embedded
+// in executables: Authenticode signatures of Windows PE files, and code
+// signatures of macOS Mach-O files, including universal (fat) binaries.
+//
+// The signatures are parsed but not verified, it is only used to inspect who
+// signed a file and when, to verify them use the tools of each platform.
+package codesign
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/macho"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/digitorus/timestamp"
+	"github.com/pkg/errors"
+)
+
+var (
+	// oidTimeStampToken is the Microsoft attribute with an RFC 3161
+	// timestamp token.
+	oidTimeStampToken = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 3, 3, 1}
+	// oidCounterSignature is the PKCS #9 countersignature attribute used by
+	// legacy Authenticode timestamps.
+	oidCounterSignature = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	// oidSigningTime is the PKCS #9 signing time attribute.
+	oidSigningTime = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	// oidNestedSignature is the Microsoft attribute with additional
+	// signatures, used to dual-sign files.
+	oidNestedSignature = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 4, 1}
+)
+
+const (
+	// winCertTypePKCSSignedData is the WIN_CERTIFICATE type of an
+	// Authenticode signature.
+	winCertTypePKCSSignedData = 0x0002
+	// lcCodeSignature is the Mach-O LC_CODE_SIGNATURE load command.
+	lcCodeSignature = 0x1d
+	// csMagicEmbeddedSignature is the magic of the Mach-O code signature
+	// super blob.
+	csMagicEmbeddedSignature = 0xfade0cc0
+	// csMagicBlobWrapper is the magic of the blob with the CMS signature.
+	csMagicBlobWrapper = 0xfade0b01
+	// csSlotSignature is the index of the CMS signature in the super blob.
+	csSlotSignature = 0x10000
+)
+
+// Signature contains the certificates of a signature embedded in an
+// executable.
+type Signature struct {
+	// Format is the executable format, PE or Mach-O followed by the
+	// architecture.
+	Format string
+	// Chain is the chain of the signing certificate built with the
+	// certificates in the signature, starting with the signer. It is not
+	// verified against any root.
+	Chain []*x509.Certificate
+	// Timestamp is the timestamp of the signature, if any.
+	Timestamp *Timestamp
+}
+
+// Timestamp contains the time and the certificates of an Authenticode
+// timestamp, an RFC 3161 timestamp token or a legacy countersignature.
+type Timestamp struct {
+	Time time.Time
+	// Chain is the chain of the time-stamping authority certificate,
+	// starting with the signer.
+	Chain []*x509.Certificate
+}
+
+// IsExecutable returns true if the data looks like a PE or a Mach-O file.
+func IsExecutable(b []byte) bool {
+	if len(b) < 4 {
+		return false
+	}
+	if b[0] == 'M' && b[1] == 'Z' {
+		return true
+	}
+	switch binary.BigEndian.Uint32(b) {
+	case macho.Magic32, macho.Magic64, macho.MagicFat:
+		return true
+	}
+	switch binary.LittleEndian.Uint32(b) {
+	case macho.Magic32, macho.Magic64:
+		return true
+	}
+	return false
+}
+
+// Parse returns the signatures embedded in a PE or a Mach-O file. Universal
+// binaries return one signature for each signed architecture, and PE files
+// one for each Authenticode signature, including the nested ones.
+func Parse(b []byte) ([]*Signature, error) {
+	if !IsExecutable(b) {
+		return nil, errors.New("the file is not a PE or a Mach-O executable")
+	}
+	if b[0] == 'M' && b[1] == 'Z' {
+		return parsePE(b)
+	}
+	return parseMachO(b)
+}
+
+func parsePE(b []byte) ([]*Signature, error) {
+	f, err := pe.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing PE file")
+	}
+	defer f.Close()
+
+	// The security directory is the fifth data directory, its address is a
+	// file offset.
+	var dir pe.DataDirectory
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if h.NumberOfRvaAndSizes > 4 {
+			dir = h.DataDirectory[4]
+		}
+	case *pe.OptionalHeader64:
+		if h.NumberOfRvaAndSizes > 4 {
+			dir = h.DataDirectory[4]
+		}
+	}
+	if dir.Size == 0 {
+		return nil, errors.New("the PE file is not signed")
+	}
+	start, end := uint64(dir.VirtualAddress), uint64(dir.VirtualAddress)+uint64(dir.Size)
+	if end > uint64(len(b)) {
+		return nil, errors.New("error parsing PE file: the certificate table is out of bounds")
+	}
+
+	// The table is a list of WIN_CERTIFICATE structures aligned to 8 bytes.
+	var sigs []*Signature
+	table := b[start:end]
+	for len(table) >= 8 {
+		length := binary.LittleEndian.Uint32(table)
+		typ := binary.LittleEndian.Uint16(table[6:])
+		if length < 8 || uint64(length) > uint64(len(table)) {
+			return nil, errors.New("error parsing PE file: invalid certificate table entry")
+		}
+		if typ == winCertTypePKCSSignedData {
+			s, err := parseSignature("PE", table[8:length])
+			if err != nil {
+				return nil, err
+			}
+			sigs = append(sigs, s...)
+		}
+		if length = (length + 7) &^ 7; uint64(length) >= uint64(len(table)) {
+			break
+		}
+		table = table[length:]
+	}
+	if len(sigs) == 0 {
+		return nil, errors.New("the PE file does not have an Authenticode signature")
+	}
+	return sigs, nil
+}
+
+func parseMachO(b []byte) ([]*Signature, error) {
+	if binary.BigEndian.Uint32(b) != macho.MagicFat {
+		f, err := macho.NewFile(bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing Mach-O file")
+		}
+		defer f.Close()
+		sig, err := parseMachOSignature(f, b)
+		if err != nil {
+			return nil, err
+		}
+		if sig == nil {
+			return nil, errors.New("the Mach-O file is not signed")
+		}
+		return sig, nil
+	}
+
+	ff, err := macho.NewFatFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing Mach-O universal file")
+	}
+	defer ff.Close()
+	var sigs []*Signature
+	for _, arch := range ff.Arches {
+		end := uint64(arch.Offset) + uint64(arch.Size)
+		if end > uint64(len(b)) {
+			return nil, errors.New("error parsing Mach-O universal file: architecture out of bounds")
+		}
+		s, err := parseMachOSignature(arch.File, b[arch.Offset:end])
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, s...)
+	}
+	if len(sigs) == 0 {
+		return nil, errors.New("the Mach-O file is not signed")
+	}
+	return sigs, nil
+}
+
+// parseMachOSignature returns the signature in the LC_CODE_SIGNATURE load
+// command of f, b is the content of the file. It returns nil if the file is
+// not signed or if it is ad-hoc signed.
+func parseMachOSignature(f *macho.File, b []byte) ([]*Signature, error) {
+	for _, l := range f.Loads {
+		raw := l.Raw()
+		if len(raw) < 16 || f.ByteOrder.Uint32(raw) != lcCodeSignature {
+			continue
+		}
+		off, size := uint64(f.ByteOrder.Uint32(raw[8:])), uint64(f.ByteOrder.Uint32(raw[12:]))
+		if off+size > uint64(len(b)) {
+			return nil, errors.New("error parsing Mach-O file: the code signature is out of bounds")
+		}
+		cms, err := machOSignatureBlob(b[off : off+size])
+		if err != nil || len(cms) == 0 {
+			return nil, err
+		}
+		return parseSignature("Mach-O "+cpuName(f.Cpu), cms)
+	}
+	return nil, nil
+}
+
+// machOSignatureBlob returns the CMS signature in a code signature super
+// blob. The fields of the blobs are always big-endian.
+func machOSignatureBlob(b []byte) ([]byte, error) {
+	if len(b) < 12 || binary.BigEndian.Uint32(b) != csMagicEmbeddedSignature {
+		return nil, errors.New("error parsing Mach-O file: invalid code signature")
+	}
+	count := uint64(binary.BigEndian.Uint32(b[8:]))
+	if 12+count*8 > uint64(len(b)) {
+		return nil, errors.New("error parsing Mach-O file: invalid code signature")
+	}
+	for i := uint64(0); i < count; i++ {
+		index := b[12+i*8:]
+		if binary.BigEndian.Uint32(index) != csSlotSignature {
+			continue
+		}
+		off := uint64(binary.BigEndian.Uint32(index[4:]))
+		if off+8 > uint64(len(b)) || binary.BigEndian.Uint32(b[off:]) != csMagicBlobWrapper {
+			return nil, errors.New("error parsing Mach-O file: invalid signature blob")
+		}
+		length := uint64(binary.BigEndian.Uint32(b[off+4:]))
+		if length < 8 || off+length > uint64(len(b)) {
+			return nil, errors.New("error parsing Mach-O file: invalid signature blob")
+		}
+		return b[off+8 : off+length], nil
+	}
+	return nil, nil
+}
+
+// parseSignature parses a CMS signed data, and the nested signatures in it.
+func parseSignature(format string, der []byte) ([]*Signature, error) {
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing signature")
+	}
+	if len(p7.Signers) != 1 {
+		return nil, errors.Errorf("the signature has %d signers, expected 1", len(p7.Signers))
+	}
+	signer := p7.GetOnlySigner()
+	if signer == nil {
+		return nil, errors.New("the signature does not contain the signing certificate")
+	}
+
+	sig := &Signature{
+		Format: format,
+		Chain:  buildChain(signer, p7.Certificates),
+	}
+	sigs := []*Signature{sig}
+	for _, attr := range p7.Signers[0].UnauthenticatedAttributes {
+		switch {
+		case attr.Type.Equal(oidTimeStampToken):
+			if sig.Timestamp, err = parseTimeStampToken(attr.Value.Bytes); err != nil {
+				return nil, err
+			}
+		case attr.Type.Equal(oidCounterSignature):
+			if sig.Timestamp, err = parseCounterSignature(attr.Value.Bytes, p7.Certificates); err != nil {
+				return nil, err
+			}
+		case attr.Type.Equal(oidNestedSignature):
+			for rest := attr.Value.Bytes; len(rest) > 0; {
+				var ci asn1.RawValue
+				if rest, err = asn1.Unmarshal(rest, &ci); err != nil {
+					return nil, errors.Wrap(err, "error parsing nested signature")
+				}
+				nested, err := parseSignature(format, ci.FullBytes)
+				if err != nil {
+					return nil, err
+				}
+				sigs = append(sigs, nested...)
+			}
+		}
+	}
+	return sigs, nil
+}
+
+// parseTimeStampToken parses an RFC 3161 timestamp token.
+func parseTimeStampToken(der []byte) (*Timestamp, error) {
+	ts, err := timestamp.Parse(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing timestamp")
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing timestamp")
+	}
+	t := &Timestamp{Time: ts.Time}
+	if signer := p7.GetOnlySigner(); signer != nil {
+		t.Chain = buildChain(signer, p7.Certificates)
+	}
+	return t, nil
+}
+
+type attribute struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type issuerAndSerial struct {
+	IssuerName   asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// counterSignerInfo contains the first fields of the SignerInfo of a
+// countersignature, the rest are not needed to get the signing time.
+type counterSignerInfo struct {
+	Version                 int
+	IssuerAndSerialNumber   issuerAndSerial
+	DigestAlgorithm         pkix.AlgorithmIdentifier
+	AuthenticatedAttributes []attribute `asn1:"optional,tag:0"`
+}
+
+// parseCounterSignature parses the signer info of a legacy Authenticode
+// timestamp. The certificates of the time-stamping authority are in the
+// countersigned signature.
+func parseCounterSignature(der []byte, certs []*x509.Certificate) (*Timestamp, error) {
+	var info counterSignerInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, errors.Wrap(err, "error parsing countersignature")
+	}
+	t := new(Timestamp)
+	for _, attr := range info.AuthenticatedAttributes {
+		if attr.Type.Equal(oidSigningTime) {
+			if _, err := asn1.Unmarshal(attr.Value.Bytes, &t.Time); err != nil {
+				return nil, errors.Wrap(err, "error parsing countersignature signing time")
+			}
+		}
+	}
+	if t.Time.IsZero() {
+		return nil, errors.New("error parsing countersignature: signing time not found")
+	}
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, info.IssuerAndSerialNumber.IssuerName.FullBytes) &&
+			c.SerialNumber.Cmp(info.IssuerAndSerialNumber.SerialNumber) == 0 {
+			t.Chain = buildChain(c, certs)
+			break
+		}
+	}
+	return t, nil
+}
+
+// buildChain returns the chain of crt using the given certificates, ordered
+// from crt to the last issuer found. The basic constraints of the issuers are
+// not checked, the chain is only used for inspection.
+func buildChain(crt *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	chain := []*x509.Certificate{crt}
+	for len(chain) <= len(certs) {
+		last := chain[len(chain)-1]
+		if bytes.Equal(last.RawSubject, last.RawIssuer) {
+			break
+		}
+		var parent *x509.Certificate
+		for _, c := range certs {
+			if bytes.Equal(c.RawSubject, last.RawIssuer) && c.CheckSignature(last.SignatureAlgorithm, last.RawTBSCertificate, last.Signature) == nil {
+				parent = c
+				break
+			}
+		}
+		if parent == nil {
+			break
+		}
+		chain = append(chain, parent)
+	}
+	return chain
+}
+
+func cpuName(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.Cpu386:
+		return "i386"
+	case macho.CpuAmd64:
+		return "x86_64"
+	case macho.CpuArm:
+		return "arm"
+	case macho.CpuArm64:
+		return "arm64"
+	default:
+		return fmt.Sprintf("cpu %d", uint32(cpu))
+	}
+}
@@ -0,0 +1,253 @@
+package codesign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/macho"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/stretchr/testify/require"
+)
+
+type testIdentity struct {
+	crt *x509.Certificate
+	key crypto.Signer
+}
+
+func newTestIdentity(t *testing.T, serial int64, cn string, isCA bool, eku x509.ExtKeyUsage, issuer *testIdentity) *testIdentity {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{eku}
+	}
+	parent, parentKey := tmpl, crypto.Signer(key)
+	if issuer != nil {
+		parent, parentKey = issuer.crt, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testIdentity{crt: crt, key: key}
+}
+
+type testPKI struct {
+	root, intermediate, leaf, tsa *testIdentity
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	root := newTestIdentity(t, 1, "Test Root CA", true, 0, nil)
+	intermediate := newTestIdentity(t, 2, "Test Intermediate CA", true, 0, root)
+	return &testPKI{
+		root:         root,
+		intermediate: intermediate,
+		leaf:         newTestIdentity(t, 3, "Example Inc", false, x509.ExtKeyUsageCodeSigning, intermediate),
+		tsa:          newTestIdentity(t, 4, "Test TSA", false, x509.ExtKeyUsageTimeStamping, root),
+	}
+}
+
+// newTestSignature returns a CMS signature by the leaf, with a legacy
+// countersignature by the TSA if signingTime is not zero.
+func newTestSignature(t *testing.T, p *testPKI, signingTime time.Time) []byte {
+	sd, err := pkcs7.NewSignedData([]byte("executable digest"))
+	require.NoError(t, err)
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	require.NoError(t, sd.AddSignerChain(p.leaf.crt, p.leaf.key, []*x509.Certificate{p.intermediate.crt, p.root.crt}, pkcs7.SignerInfoConfig{}))
+
+	if !signingTime.IsZero() {
+		st, err := asn1.Marshal(signingTime)
+		require.NoError(t, err)
+		cs, err := asn1.Marshal(counterSignerInfo{
+			Version: 1,
+			IssuerAndSerialNumber: issuerAndSerial{
+				IssuerName:   asn1.RawValue{FullBytes: p.tsa.crt.RawIssuer},
+				SerialNumber: p.tsa.crt.SerialNumber,
+			},
+			DigestAlgorithm: pkix.AlgorithmIdentifier{Algorithm: pkcs7.OIDDigestAlgorithmSHA256},
+			AuthenticatedAttributes: []attribute{{
+				Type:  oidSigningTime,
+				Value: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: st},
+			}},
+		})
+		require.NoError(t, err)
+		sd.AddCertificate(p.tsa.crt)
+		require.NoError(t, sd.GetSignedData().SignerInfos[0].SetUnauthenticatedAttributes([]pkcs7.Attribute{{
+			Type:  oidCounterSignature,
+			Value: asn1.RawValue{FullBytes: cs},
+		}}))
+	}
+
+	der, err := sd.Finish()
+	require.NoError(t, err)
+	return der
+}
+
+// newTestPE returns a PE32+ file without sections with the given signature in
+// the certificate table.
+func newTestPE(t *testing.T, sig []byte) []byte {
+	var buf bytes.Buffer
+	dos := make([]byte, 0x40)
+	dos[0], dos[1] = 'M', 'Z'
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	buf.Write(dos)
+	buf.WriteString("PE\x00\x00")
+
+	oh := pe.OptionalHeader64{Magic: 0x20b, NumberOfRvaAndSizes: 16}
+	fh := pe.FileHeader{Machine: pe.IMAGE_FILE_MACHINE_AMD64, SizeOfOptionalHeader: uint16(binary.Size(oh))}
+	var table []byte
+	if sig != nil {
+		table = make([]byte, (8+len(sig)+7)&^7)
+		binary.LittleEndian.PutUint32(table, uint32(8+len(sig)))
+		binary.LittleEndian.PutUint16(table[4:], 0x0200)
+		binary.LittleEndian.PutUint16(table[6:], winCertTypePKCSSignedData)
+		copy(table[8:], sig)
+		oh.DataDirectory[4] = pe.DataDirectory{
+			VirtualAddress: uint32(buf.Len() + binary.Size(fh) + binary.Size(oh)),
+			Size:           uint32(len(table)),
+		}
+	}
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, fh))
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, oh))
+	buf.Write(table)
+	return buf.Bytes()
+}
+
+// newTestMachO returns a 64-bit Mach-O file with a code signature with the
+// given CMS signature, an empty one is an ad-hoc signature.
+func newTestMachO(t *testing.T, cpu macho.Cpu, cms []byte) []byte {
+	// Super blob with a dummy code directory and the signature.
+	blob := make([]byte, 28+8+len(cms))
+	binary.BigEndian.PutUint32(blob, csMagicEmbeddedSignature)
+	binary.BigEndian.PutUint32(blob[4:], uint32(len(blob)))
+	binary.BigEndian.PutUint32(blob[8:], 2)
+	binary.BigEndian.PutUint32(blob[12:], 0)
+	binary.BigEndian.PutUint32(blob[16:], 0)
+	binary.BigEndian.PutUint32(blob[20:], csSlotSignature)
+	binary.BigEndian.PutUint32(blob[24:], 28)
+	binary.BigEndian.PutUint32(blob[28:], csMagicBlobWrapper)
+	binary.BigEndian.PutUint32(blob[32:], uint32(8+len(cms)))
+	copy(blob[36:], cms)
+
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, macho.FileHeader{
+		Magic: macho.Magic64,
+		Cpu:   cpu,
+		Type:  macho.TypeExec,
+		Ncmd:  1,
+		Cmdsz: 16,
+	}))
+	buf.Write(make([]byte, 4))
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, []uint32{
+		lcCodeSignature, 16, uint32(buf.Len() + 16), uint32(len(blob)),
+	}))
+	buf.Write(blob)
+	return buf.Bytes()
+}
+
+// newTestFatMachO returns a universal binary with the given files.
+func newTestFatMachO(t *testing.T, cpus []macho.Cpu, files [][]byte) []byte {
+	const align = 4096
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.BigEndian, []uint32{macho.MagicFat, uint32(len(files))}))
+	offset := uint32(align)
+	for i, f := range files {
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, []uint32{
+			uint32(cpus[i]), 0, offset, uint32(len(f)), 12,
+		}))
+		offset += (uint32(len(f)) + align - 1) &^ (align - 1)
+	}
+	for _, f := range files {
+		buf.Write(make([]byte, (align-buf.Len()%align)%align))
+		buf.Write(f)
+	}
+	return buf.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	p := newTestPKI(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	signed := newTestSignature(t, p, time.Time{})
+	timestamped := newTestSignature(t, p, now)
+	chain := []*x509.Certificate{p.leaf.crt, p.intermediate.crt, p.root.crt}
+
+	tests := []struct {
+		name    string
+		file    []byte
+		want    []*Signature
+		wantErr bool
+	}{
+		{"pe", newTestPE(t, signed), []*Signature{
+			{Format: "PE", Chain: chain},
+		}, false},
+		{"pe timestamped", newTestPE(t, timestamped), []*Signature{
+			{Format: "PE", Chain: chain, Timestamp: &Timestamp{
+				Time:  now,
+				Chain: []*x509.Certificate{p.tsa.crt, p.root.crt},
+			}},
+		}, false},
+		{"macho", newTestMachO(t, macho.CpuArm64, signed), []*Signature{
+			{Format: "Mach-O arm64", Chain: chain},
+		}, false},
+		{"macho universal", newTestFatMachO(t, []macho.Cpu{macho.CpuAmd64, macho.CpuArm64}, [][]byte{
+			newTestMachO(t, macho.CpuAmd64, nil),
+			newTestMachO(t, macho.CpuArm64, signed),
+		}), []*Signature{
+			{Format: "Mach-O arm64", Chain: chain},
+		}, false},
+		{"fail pe not signed", newTestPE(t, nil), nil, true},
+		{"fail macho ad-hoc", newTestMachO(t, macho.CpuArm64, nil), nil, true},
+		{"fail macho bad signature", newTestMachO(t, macho.CpuArm64, []byte("foo")), nil, true},
+		{"fail not executable", []byte("#!/bin/sh\n"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.file)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, got, len(tt.want))
+			for i := range got {
+				require.Equal(t, tt.want[i].Format, got[i].Format)
+				require.Equal(t, tt.want[i].Chain, got[i].Chain)
+				if tt.want[i].Timestamp == nil {
+					require.Nil(t, got[i].Timestamp)
+				} else {
+					require.True(t, tt.want[i].Timestamp.Time.Equal(got[i].Timestamp.Time))
+					require.Equal(t, tt.want[i].Timestamp.Chain, got[i].Timestamp.Chain)
+				}
+			}
+		})
+	}
+}
+
+func TestIsExecutable(t *testing.T) {
+	require.True(t, IsExecutable(newTestPE(t, nil)))
+	require.True(t, IsExecutable(newTestMachO(t, macho.CpuAmd64, nil)))
+	require.True(t, IsExecutable([]byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 1}))
+	require.False(t, IsExecutable([]byte("-----BEGIN CERTIFICATE-----")))
+	require.False(t, IsExecutable([]byte("MZ")))
+}
@@ -0,0 +1,318 @@
+// Package did implements the did:key and did:web decentralized identifier
+// methods and the DID documents used to publish their keys.
+package did
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/base58"
+	"github.com/smallstep/cli/jose"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Method prefixes.
+const (
+	KeyPrefix = "did:key:"
+	WebPrefix = "did:web:"
+)
+
+// Contexts used in DID documents.
+const (
+	ContextDID     = "https://www.w3.org/ns/did/v1"
+	ContextJWS2020 = "https://w3id.org/security/suites/jws-2020/v1"
+)
+
+// maxDocumentSize is the maximum size of a did:web document.
+const maxDocumentSize = 1 << 20
+
+// Multicodec prefixes of the public keys, encoded as varints.
+var (
+	codecEd25519 = []byte{0xed, 0x01}
+	codecP256    = []byte{0x80, 0x24}
+	codecP384    = []byte{0x81, 0x24}
+	codecP521    = []byte{0x82, 0x24}
+	codecRSA     = []byte{0x85, 0x24}
+)
+
+// Document is a DID document with the verification methods of a DID.
+type Document struct {
+	Context            []string             `json:"@context"`
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod"`
+	Authentication     []string             `json:"authentication,omitempty"`
+	AssertionMethod    []string             `json:"assertionMethod,omitempty"`
+}
+
+// VerificationMethod is a public key in a DID document.
+type VerificationMethod struct {
+	ID           string           `json:"id"`
+	Type         string           `json:"type"`
+	Controller   string           `json:"controller"`
+	PublicKeyJwk *jose.JSONWebKey `json:"publicKeyJwk"`
+}
+
+// NewDocument returns a DID document for the given DID with the key as the
+// only verification method. The key can be used for authentication and to
+// issue credentials.
+func NewDocument(did string, jwk *jose.JSONWebKey) (*Document, error) {
+	pub := jwk.Public()
+	if !pub.Valid() {
+		return nil, errors.New("invalid key: symmetric keys cannot be used in DID documents")
+	}
+	id, err := VerificationMethodID(did, jwk)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		Context: []string{ContextDID, ContextJWS2020},
+		ID:      did,
+		VerificationMethod: []VerificationMethod{{
+			ID:           id,
+			Type:         "JsonWebKey2020",
+			Controller:   did,
+			PublicKeyJwk: &pub,
+		}},
+		Authentication:  []string{id},
+		AssertionMethod: []string{id},
+	}, nil
+}
+
+// VerificationMethodID returns the id of the verification method of the key
+// in the DID document. For did:key the fragment is the method specific
+// identifier, for other methods is the JWK thumbprint of the key.
+func VerificationMethodID(did string, jwk *jose.JSONWebKey) (string, error) {
+	if strings.HasPrefix(did, KeyPrefix) {
+		return did + "#" + strings.TrimPrefix(did, KeyPrefix), nil
+	}
+	pub := jwk.Public()
+	thumbprint, err := jose.Thumbprint(&pub)
+	if err != nil {
+		return "", err
+	}
+	return did + "#" + thumbprint, nil
+}
+
+// Key returns the verification method in the document with the given id. If
+// the id is empty the first verification method is returned.
+func (d *Document) Key(id string) (*jose.JSONWebKey, error) {
+	if strings.HasPrefix(id, "#") {
+		id = d.ID + id
+	}
+	for _, vm := range d.VerificationMethod {
+		if vm.PublicKeyJwk != nil && (id == "" || vm.ID == id) {
+			return vm.PublicKeyJwk, nil
+		}
+	}
+	if id == "" {
+		return nil, errors.Errorf("%s does not have any JSON Web Key", d.ID)
+	}
+	return nil, errors.Errorf("%s does not have the verification method %s", d.ID, id)
+}
+
+// NewKey returns the did:key identifier of the given public key. Ed25519,
+// ECDSA with P-256, P-384 and P-521, and RSA keys are supported.
+func NewKey(pub crypto.PublicKey) (string, error) {
+	var b []byte
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		b = append(codecEd25519, k...)
+	case *ecdsa.PublicKey:
+		var codec []byte
+		switch k.Curve {
+		case elliptic.P256():
+			codec = codecP256
+		case elliptic.P384():
+			codec = codecP384
+		case elliptic.P521():
+			codec = codecP521
+		default:
+			return "", errors.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+		}
+		b = append(codec, compressPoint(k)...)
+	case *rsa.PublicKey:
+		b = append(codecRSA, x509.MarshalPKCS1PublicKey(k)...)
+	default:
+		return "", errors.Errorf("unsupported public key type %T", pub)
+	}
+	// The method specific identifier is the multibase base58btc encoding.
+	return KeyPrefix + "z" + base58.Encode(b), nil
+}
+
+// ParseKey returns the public key in the given did:key identifier.
+func ParseKey(did string) (crypto.PublicKey, error) {
+	if !strings.HasPrefix(did, KeyPrefix) {
+		return nil, errors.Errorf("%s is not a did:key identifier", did)
+	}
+	id := strings.TrimPrefix(did, KeyPrefix)
+	if i := strings.IndexByte(id, '#'); i >= 0 {
+		id = id[:i]
+	}
+	if !strings.HasPrefix(id, "z") {
+		return nil, errors.Errorf("%s is not a valid did:key: unsupported multibase encoding", did)
+	}
+	b, err := base58.Decode(id[1:])
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s is not a valid did:key", did)
+	}
+
+	hasCodec := func(codec []byte) bool {
+		if len(b) > len(codec) && string(b[:len(codec)]) == string(codec) {
+			b = b[len(codec):]
+			return true
+		}
+		return false
+	}
+	switch {
+	case hasCodec(codecEd25519):
+		if len(b) != ed25519.PublicKeySize {
+			return nil, errors.Errorf("%s is not a valid did:key: invalid Ed25519 key", did)
+		}
+		return ed25519.PublicKey(b), nil
+	case hasCodec(codecP256):
+		return decompressPoint(did, elliptic.P256(), b)
+	case hasCodec(codecP384):
+		return decompressPoint(did, elliptic.P384(), b)
+	case hasCodec(codecP521):
+		return decompressPoint(did, elliptic.P521(), b)
+	case hasCodec(codecRSA):
+		pub, err := x509.ParsePKCS1PublicKey(b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s is not a valid did:key", did)
+		}
+		return pub, nil
+	default:
+		return nil, errors.Errorf("%s is not a valid did:key: unsupported key type", did)
+	}
+}
+
+// NewWeb returns the did:web identifier for the given domain and optional
+// path, e.g. example.com or example.com:8443/users/alice.
+func NewWeb(domain string) (string, error) {
+	u, err := url.Parse("https://" + strings.TrimPrefix(domain, "https://"))
+	if err != nil || u.Host == "" {
+		return "", errors.Errorf("invalid domain %s", domain)
+	}
+	parts := []string{strings.Replace(u.Host, ":", "%3A", 1)}
+	for _, p := range strings.Split(strings.Trim(u.Path, "/"), "/") {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return WebPrefix + strings.Join(parts, ":"), nil
+}
+
+// WebURL returns the URL of the DID document of the given did:web
+// identifier.
+func WebURL(did string) (string, error) {
+	if !strings.HasPrefix(did, WebPrefix) {
+		return "", errors.Errorf("%s is not a did:web identifier", did)
+	}
+	id := strings.TrimPrefix(did, WebPrefix)
+	if i := strings.IndexByte(id, '#'); i >= 0 {
+		id = id[:i]
+	}
+	parts := strings.Split(id, ":")
+	host, err := url.PathUnescape(parts[0])
+	if err != nil || host == "" {
+		return "", errors.Errorf("%s is not a valid did:web", did)
+	}
+	if len(parts) == 1 {
+		return "https://" + host + "/.well-known/did.json", nil
+	}
+	return "https://" + host + "/" + strings.Join(parts[1:], "/") + "/did.json", nil
+}
+
+// Resolve returns the DID document of the given DID. Documents of did:key
+// identifiers are generated from the key, and did:web documents are
+// downloaded using the given client.
+func Resolve(client *http.Client, did string) (*Document, error) {
+	if i := strings.IndexByte(did, '#'); i >= 0 {
+		did = did[:i]
+	}
+	switch {
+	case strings.HasPrefix(did, KeyPrefix):
+		pub, err := ParseKey(did)
+		if err != nil {
+			return nil, err
+		}
+		return NewDocument(did, &jose.JSONWebKey{Key: pub})
+	case strings.HasPrefix(did, WebPrefix):
+		rawurl, err := WebURL(did)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Get(rawurl)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error resolving %s", did)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("error resolving %s: %s returned %s", did, rawurl, resp.Status)
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+		if err != nil {
+			return nil, errors.Wrapf(err, "error resolving %s", did)
+		}
+		doc := new(Document)
+		if err := json.Unmarshal(b, doc); err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s", rawurl)
+		}
+		if doc.ID != did {
+			return nil, errors.Errorf("error resolving %s: document id %s does not match", did, doc.ID)
+		}
+		return doc, nil
+	default:
+		return nil, errors.Errorf("unsupported DID method in %s", did)
+	}
+}
+
+// compressPoint returns the compressed form of the point of the public key.
+func compressPoint(pub *ecdsa.PublicKey) []byte {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	b := make([]byte, 1+size)
+	b[0] = byte(2 + pub.Y.Bit(0))
+	x := pub.X.Bytes()
+	copy(b[1+size-len(x):], x)
+	return b
+}
+
+// decompressPoint returns the public key of a compressed point.
+func decompressPoint(did string, curve elliptic.Curve, b []byte) (*ecdsa.PublicKey, error) {
+	params := curve.Params()
+	size := (params.BitSize + 7) / 8
+	if len(b) != 1+size || (b[0] != 2 && b[0] != 3) {
+		return nil, errors.Errorf("%s is not a valid did:key: invalid compressed point", did)
+	}
+	x := new(big.Int).SetBytes(b[1:])
+
+	// y² = x³ - 3x + b
+	y := new(big.Int).Mul(x, x)
+	y.Mul(y, x)
+	threeX := new(big.Int).Lsh(x, 1)
+	threeX.Add(threeX, x)
+	y.Sub(y, threeX)
+	y.Add(y, params.B)
+	y.Mod(y, params.P)
+	if y.ModSqrt(y, params.P) == nil {
+		return nil, errors.Errorf("%s is not a valid did:key: invalid compressed point", did)
+	}
+	if y.Bit(0) != uint(b[0]&1) {
+		y.Sub(params.P, y)
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.Errorf("%s is not a valid did:key: invalid compressed point", did)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
@@ -0,0 +1,79 @@
+package did
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestNewKey(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.FatalError(t, err)
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	assert.FatalError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name   string
+		pub    interface{}
+		prefix string
+	}{
+		{"ed25519", edPub, "did:key:z6Mk"},
+		{"p256", &p256.PublicKey, "did:key:zDn"},
+		{"p384", &p384.PublicKey, "did:key:z82"},
+		{"p521", &p521.PublicKey, "did:key:z2J9"},
+		{"rsa", &rsaKey.PublicKey, "did:key:z4MX"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			did, err := NewKey(tc.pub)
+			assert.FatalError(t, err)
+			assert.True(t, strings.HasPrefix(did, tc.prefix), did)
+			pub, err := ParseKey(did)
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.pub, pub)
+			pub, err = ParseKey(did + "#fragment")
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.pub, pub)
+		})
+	}
+
+	_, err = NewKey([]byte("secret"))
+	assert.Error(t, err)
+	_, err = ParseKey("did:web:example.com")
+	assert.Error(t, err)
+	_, err = ParseKey("did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2do")
+	assert.Error(t, err)
+}
+
+func TestWeb(t *testing.T) {
+	tests := []struct {
+		domain, did, url string
+	}{
+		{"example.com", "did:web:example.com", "https://example.com/.well-known/did.json"},
+		{"https://example.com/", "did:web:example.com", "https://example.com/.well-known/did.json"},
+		{"example.com:8443", "did:web:example.com%3A8443", "https://example.com:8443/.well-known/did.json"},
+		{"example.com/users/alice", "did:web:example.com:users:alice", "https://example.com/users/alice/did.json"},
+	}
+	for _, tc := range tests {
+		did, err := NewWeb(tc.domain)
+		assert.FatalError(t, err)
+		assert.Equals(t, tc.did, did)
+		u, err := WebURL(did + "#key-1")
+		assert.FatalError(t, err)
+		assert.Equals(t, tc.url, u)
+	}
+	_, err := WebURL("did:key:z6Mk")
+	assert.Error(t, err)
+}
@@ -0,0 +1,6 @@
+// +build fips
+
+package fips
+
+// buildEnabled enables FIPS mode in the builds with the fips tag.
+const buildEnabled = true
@@ -0,0 +1,178 @@
+// Package fips implements the FIPS mode of step. In FIPS mode only the
+// algorithms approved by FIPS 186-4, FIPS 180-4 and SP 800-131A can be used:
+// RSA keys of at least 2048 bits, ECDSA keys with the NIST curves, the SHA-2
+// hash functions, HMAC, AES and PBKDF2. Other algorithms, like Ed25519, MD5,
+// scrypt, Argon2 or the NaCl constructions, fail with an error.
+//
+// FIPS mode is enabled with the --fips flag, the STEP_FIPS environment
+// variable, or building step with the fips build tag. It doesn't make step a
+// validated cryptographic module, it only restricts the algorithms it uses.
+package fips
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// Env is the environment variable that enables FIPS mode.
+const Env = "STEP_FIPS"
+
+// MinRSASize is the minimum size in bits of the RSA keys allowed in FIPS mode.
+const MinRSASize = 2048
+
+var enabled int32
+
+func init() {
+	if buildEnabled {
+		enabled = 1
+	}
+}
+
+// Enable enables FIPS mode. FIPS mode cannot be disabled once it's enabled.
+func Enable() {
+	atomic.StoreInt32(&enabled, 1)
+}
+
+// Enabled returns true if FIPS mode is enabled.
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+// notAllowed returns the error returned for the algorithms that are not
+// approved.
+func notAllowed(format string, args ...interface{}) error {
+	return errors.Errorf(format+" is not allowed in FIPS mode", args...)
+}
+
+// CheckKeyType returns an error in FIPS mode if keys of the given type (kty),
+// curve and size cannot be generated. An empty curve or a zero size select
+// the defaults, P-256 and 2048 bits.
+func CheckKeyType(kty, crv string, size int) error {
+	if !Enabled() {
+		return nil
+	}
+	switch kty {
+	case "EC":
+		switch crv {
+		case "", "P-256", "P-384", "P-521":
+			return nil
+		default:
+			return notAllowed("curve %s", crv)
+		}
+	case "RSA":
+		if size != 0 && size < MinRSASize {
+			return notAllowed("RSA key size %d", size)
+		}
+		return nil
+	case "oct":
+		return nil
+	case "OKP":
+		return notAllowed("key type OKP with curve %s", crv)
+	default:
+		return notAllowed("key type %s", kty)
+	}
+}
+
+// CheckKey returns an error in FIPS mode if the given public or private key
+// cannot be used. Keys in a KMS are checked using their public key.
+func CheckKey(key interface{}) error {
+	if !Enabled() {
+		return nil
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < MinRSASize {
+			return notAllowed("RSA key size %d", k.N.BitLen())
+		}
+		return nil
+	case *rsa.PrivateKey:
+		return CheckKey(&k.PublicKey)
+	case *ecdsa.PublicKey:
+		return CheckKeyType("EC", k.Curve.Params().Name, 0)
+	case *ecdsa.PrivateKey:
+		return CheckKey(&k.PublicKey)
+	case []byte:
+		return nil
+	case crypto.Signer:
+		switch pub := k.Public().(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			return CheckKey(pub)
+		default:
+			return notAllowed("key type %T", pub)
+		}
+	default:
+		return notAllowed("key type %T", key)
+	}
+}
+
+// CheckSignatureAlgorithm returns an error in FIPS mode if the given JOSE
+// signature algorithm cannot be used.
+func CheckSignatureAlgorithm(alg string) error {
+	if !Enabled() {
+		return nil
+	}
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512",
+		"ES256", "ES384", "ES512", "HS256", "HS384", "HS512":
+		return nil
+	default:
+		return notAllowed("signature algorithm %s", alg)
+	}
+}
+
+// CheckEncryptionAlgorithm returns an error in FIPS mode if the given JOSE
+// key management or content encryption algorithm cannot be used.
+func CheckEncryptionAlgorithm(alg string) error {
+	if !Enabled() {
+		return nil
+	}
+	switch {
+	case alg == "RSA1_5":
+		return notAllowed("encryption algorithm %s", alg)
+	case strings.HasPrefix(alg, "RSA-OAEP"), strings.HasPrefix(alg, "ECDH-ES"),
+		strings.HasPrefix(alg, "PBES2-"), strings.HasPrefix(alg, "A"), alg == "dir":
+		return nil
+	default:
+		return notAllowed("encryption algorithm %s", alg)
+	}
+}
+
+// CheckHash returns an error in FIPS mode if the hash function with the given
+// name cannot be used.
+func CheckHash(name string) error {
+	if !Enabled() {
+		return nil
+	}
+	switch strings.ToLower(name) {
+	case "sha", "sha1", "sha224", "sha256", "sha384", "sha512", "sha512-224", "sha512-256":
+		return nil
+	default:
+		return notAllowed("hash function %s", name)
+	}
+}
+
+// CheckKDF returns an error in FIPS mode if the key derivation function with
+// the given name cannot be used.
+func CheckKDF(name string) error {
+	if !Enabled() {
+		return nil
+	}
+	if strings.EqualFold(name, "pbkdf2") {
+		return nil
+	}
+	return notAllowed("key derivation function %s", name)
+}
+
+// Check returns an error in FIPS mode. It is used by the commands that only
+// use algorithms that are not approved.
+func Check(name string) error {
+	if !Enabled() {
+		return nil
+	}
+	return notAllowed("%s", name)
+}
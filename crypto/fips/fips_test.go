@@ -0,0 +1,158 @@
+package fips
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// setEnabled sets FIPS mode for a test and returns a function to restore it.
+func setEnabled(v bool) func() {
+	old := atomic.LoadInt32(&enabled)
+	if v {
+		atomic.StoreInt32(&enabled, 1)
+	} else {
+		atomic.StoreInt32(&enabled, 0)
+	}
+	return func() {
+		atomic.StoreInt32(&enabled, old)
+	}
+}
+
+func TestCheckKeyType(t *testing.T) {
+	tests := []struct {
+		kty, crv string
+		size     int
+		wantErr  bool
+	}{
+		{"EC", "P-256", 0, false},
+		{"EC", "P-384", 0, false},
+		{"EC", "P-521", 0, false},
+		{"EC", "", 0, false},
+		{"RSA", "", 2048, false},
+		{"RSA", "", 4096, false},
+		{"RSA", "", 0, false},
+		{"oct", "", 32, false},
+		{"RSA", "", 1024, true},
+		{"OKP", "Ed25519", 0, true},
+		{"EC", "secp256k1", 0, true},
+		{"ML-DSA", "ML-DSA-65", 0, true},
+	}
+
+	defer setEnabled(false)()
+	for _, tt := range tests {
+		if err := CheckKeyType(tt.kty, tt.crv, tt.size); err != nil {
+			t.Errorf("CheckKeyType(%s, %s, %d) error = %v with FIPS mode disabled", tt.kty, tt.crv, tt.size, err)
+		}
+	}
+
+	setEnabled(true)
+	for _, tt := range tests {
+		if err := CheckKeyType(tt.kty, tt.crv, tt.size); (err != nil) != tt.wantErr {
+			t.Errorf("CheckKeyType(%s, %s, %d) error = %v, wantErr %v", tt.kty, tt.crv, tt.size, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     interface{}
+		wantErr bool
+	}{
+		{"ecdsa", ecKey, false},
+		{"ecdsa public", &ecKey.PublicKey, false},
+		{"rsa", rsaKey, false},
+		{"rsa public", &rsaKey.PublicKey, false},
+		{"oct", []byte("a secret"), false},
+		{"rsa 1024", smallKey, true},
+		{"rsa 1024 public", &smallKey.PublicKey, true},
+		{"ed25519", edKey, true},
+		{"ed25519 public", edPub, true},
+	}
+
+	defer setEnabled(true)()
+	for _, tt := range tests {
+		if err := CheckKey(tt.key); (err != nil) != tt.wantErr {
+			t.Errorf("CheckKey(%s) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckAlgorithms(t *testing.T) {
+	defer setEnabled(true)()
+
+	for _, alg := range []string{"ES256", "RS384", "PS512", "HS256"} {
+		if err := CheckSignatureAlgorithm(alg); err != nil {
+			t.Errorf("CheckSignatureAlgorithm(%s) error = %v", alg, err)
+		}
+	}
+	for _, alg := range []string{"EdDSA", "ML-DSA-65", "none"} {
+		if err := CheckSignatureAlgorithm(alg); err == nil {
+			t.Errorf("CheckSignatureAlgorithm(%s) error = nil", alg)
+		}
+	}
+
+	for _, alg := range []string{"A256GCM", "A128CBC-HS256", "RSA-OAEP-256", "ECDH-ES+A128KW", "PBES2-HS256+A128KW", "dir"} {
+		if err := CheckEncryptionAlgorithm(alg); err != nil {
+			t.Errorf("CheckEncryptionAlgorithm(%s) error = %v", alg, err)
+		}
+	}
+	if err := CheckEncryptionAlgorithm("RSA1_5"); err == nil {
+		t.Error("CheckEncryptionAlgorithm(RSA1_5) error = nil")
+	}
+
+	if err := CheckHash("sha256"); err != nil {
+		t.Errorf("CheckHash(sha256) error = %v", err)
+	}
+	if err := CheckHash("md5"); err == nil {
+		t.Error("CheckHash(md5) error = nil")
+	}
+
+	if err := CheckKDF("pbkdf2"); err != nil {
+		t.Errorf("CheckKDF(pbkdf2) error = %v", err)
+	}
+	for _, name := range []string{"scrypt", "bcrypt", "argon2id"} {
+		if err := CheckKDF(name); err == nil {
+			t.Errorf("CheckKDF(%s) error = nil", name)
+		}
+	}
+
+	if err := Check("NaCl"); err == nil || err.Error() != "NaCl is not allowed in FIPS mode" {
+		t.Errorf("Check(NaCl) error = %v", err)
+	}
+}
+
+func TestEnable(t *testing.T) {
+	if Enabled() != buildEnabled {
+		t.Errorf("Enabled() = %v, want %v", Enabled(), buildEnabled)
+	}
+
+	defer setEnabled(false)()
+	Enable()
+	if !Enabled() {
+		t.Error("Enabled() = false after Enable()")
+	}
+}
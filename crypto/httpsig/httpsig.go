@@ -0,0 +1,579 @@
+// Package httpsig implements the signing and verification of HTTP requests
+// using HTTP Message Signatures, defined in RFC 9421.
+package httpsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Signature algorithms defined in RFC 9421 Section 3.3.
+const (
+	RSAPSSSHA512    = "rsa-pss-sha512"
+	RSAv15SHA256    = "rsa-v1_5-sha256"
+	HMACSHA256      = "hmac-sha256"
+	ECDSAP256SHA256 = "ecdsa-p256-sha256"
+	ECDSAP384SHA384 = "ecdsa-p384-sha384"
+	Ed25519         = "ed25519"
+)
+
+// DefaultLabel is the label of the signature if none is given.
+const DefaultLabel = "sig1"
+
+// DefaultComponents are the components covered by the signature if none are
+// given.
+var DefaultComponents = []string{"@method", "@target-uri"}
+
+// leeway is the difference allowed between the local clock and the clock of
+// the signer when verifying the creation time.
+const leeway = time.Minute
+
+// Request is the HTTP request to sign or verify.
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+}
+
+// SignOptions are the options used to sign a request.
+type SignOptions struct {
+	// Label is the label of the signature, defaults to sig1.
+	Label string
+	// Components are the names of the derived components, like @method, and
+	// of the header fields covered by the signature. Defaults to @method and
+	// @target-uri.
+	Components []string
+	// Created and Expires are the creation and expiration times of the
+	// signature, they are not included if zero.
+	Created time.Time
+	Expires time.Time
+	// Nonce, KeyID and Tag are the values of the parameters with the same
+	// name, they are not included if empty.
+	Nonce string
+	KeyID string
+	Tag   string
+	// Algorithm is the signature algorithm. If set it is included in the alg
+	// parameter; if not it's derived from the key.
+	Algorithm string
+}
+
+// VerifyOptions are the options used to verify a request.
+type VerifyOptions struct {
+	// Label is the label of the signature to verify. It can be empty if the
+	// request has only one signature.
+	Label string
+	// Algorithm is the expected signature algorithm. If empty it's taken
+	// from the alg parameter or derived from the key.
+	Algorithm string
+	// Components are the components that must be covered by the signature.
+	Components []string
+	// KeyID is the expected value of the keyid parameter.
+	KeyID string
+	// Tag is the expected value of the tag parameter.
+	Tag string
+	// Now is the time used to validate the creation and expiration times,
+	// defaults to the current time.
+	Now time.Time
+	// MaxAge is the maximum age of the signature. If set, the signature must
+	// have a creation time.
+	MaxAge time.Duration
+}
+
+// Signature contains the parameters of a verified signature.
+type Signature struct {
+	Label      string
+	Components []string
+	Created    time.Time
+	Expires    time.Time
+	Nonce      string
+	KeyID      string
+	Tag        string
+	Algorithm  string
+}
+
+// Sign signs the request with the given key and returns the values of the
+// Signature-Input and Signature fields. The key is a crypto.Signer or a []byte
+// for HMAC.
+func Sign(r *Request, key interface{}, opts SignOptions) (string, string, error) {
+	alg := opts.Algorithm
+	if alg == "" {
+		var err error
+		if alg, err = algorithmForKey(key); err != nil {
+			return "", "", err
+		}
+	}
+	label := opts.Label
+	if label == "" {
+		label = DefaultLabel
+	}
+	components := opts.Components
+	if len(components) == 0 {
+		components = DefaultComponents
+	}
+
+	params := &sfInnerList{Items: make([]string, len(components))}
+	for i, c := range components {
+		params.Items[i] = strings.ToLower(c)
+	}
+	if !opts.Created.IsZero() {
+		params.Params = append(params.Params, sfParam{Key: "created", Value: opts.Created.Unix()})
+	}
+	if !opts.Expires.IsZero() {
+		params.Params = append(params.Params, sfParam{Key: "expires", Value: opts.Expires.Unix()})
+	}
+	if opts.Nonce != "" {
+		params.Params = append(params.Params, sfParam{Key: "nonce", Value: opts.Nonce})
+	}
+	if opts.Algorithm != "" {
+		params.Params = append(params.Params, sfParam{Key: "alg", Value: opts.Algorithm})
+	}
+	if opts.KeyID != "" {
+		params.Params = append(params.Params, sfParam{Key: "keyid", Value: opts.KeyID})
+	}
+	if opts.Tag != "" {
+		params.Params = append(params.Params, sfParam{Key: "tag", Value: opts.Tag})
+	}
+
+	base, err := SignatureBase(r, params.Items, params.String())
+	if err != nil {
+		return "", "", err
+	}
+	sig, err := sign(alg, key, []byte(base))
+	if err != nil {
+		return "", "", err
+	}
+	return label + "=" + params.String(), label + "=" + sfBytes(sig), nil
+}
+
+// Verify verifies a signature in the Signature-Input and Signature fields of
+// the request with the given key. The key is a public key, a crypto.Signer or a
+// []byte for HMAC.
+func Verify(r *Request, key interface{}, opts VerifyOptions) (*Signature, error) {
+	inputs, err := parseDictionary(strings.Join(r.Header["Signature-Input"], ", "))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing Signature-Input")
+	}
+	signatures, err := parseDictionary(strings.Join(r.Header["Signature"], ", "))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing Signature")
+	}
+
+	label := opts.Label
+	if label == "" {
+		switch len(inputs) {
+		case 0:
+			return nil, errors.New("request does not have a signature")
+		case 1:
+			for k := range inputs {
+				label = k
+			}
+		default:
+			return nil, errors.New("request has multiple signatures, a label is required")
+		}
+	}
+	params, ok := inputs[label].(*sfInnerList)
+	if !ok {
+		return nil, errors.Errorf("request does not have a Signature-Input with label %s", label)
+	}
+	sig, ok := signatures[label].([]byte)
+	if !ok {
+		return nil, errors.Errorf("request does not have a Signature with label %s", label)
+	}
+
+	s := &Signature{
+		Label:      label,
+		Components: params.Items,
+	}
+	for _, p := range params.Params {
+		var ok bool
+		switch p.Key {
+		case "created", "expires":
+			var n int64
+			if n, ok = p.Value.(int64); ok {
+				if p.Key == "created" {
+					s.Created = time.Unix(n, 0)
+				} else {
+					s.Expires = time.Unix(n, 0)
+				}
+			}
+		case "nonce":
+			s.Nonce, ok = p.Value.(string)
+		case "alg":
+			s.Algorithm, ok = p.Value.(string)
+		case "keyid":
+			s.KeyID, ok = p.Value.(string)
+		case "tag":
+			s.Tag, ok = p.Value.(string)
+		default:
+			ok = true
+		}
+		if !ok {
+			return nil, errors.Errorf("invalid signature parameter %s", p.Key)
+		}
+	}
+
+	alg := s.Algorithm
+	switch {
+	case opts.Algorithm != "" && alg != "" && opts.Algorithm != alg:
+		return nil, errors.Errorf("signature algorithm %s does not match the expected %s", alg, opts.Algorithm)
+	case opts.Algorithm != "":
+		alg = opts.Algorithm
+	case alg == "":
+		if alg, err = algorithmForKey(key); err != nil {
+			return nil, err
+		}
+	}
+	s.Algorithm = alg
+
+	base, err := SignatureBase(r, params.Items, params.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(alg, key, []byte(base), sig); err != nil {
+		return nil, err
+	}
+
+	// Validate the parameters after the signature
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	switch {
+	case !s.Expires.IsZero() && now.After(s.Expires):
+		return nil, errors.Errorf("signature expired at %s", s.Expires.UTC().Format(time.RFC3339))
+	case !s.Created.IsZero() && s.Created.After(now.Add(leeway)):
+		return nil, errors.Errorf("signature created in the future, at %s", s.Created.UTC().Format(time.RFC3339))
+	case opts.MaxAge > 0 && s.Created.IsZero():
+		return nil, errors.New("signature does not have a creation time")
+	case opts.MaxAge > 0 && now.Sub(s.Created) > opts.MaxAge:
+		return nil, errors.Errorf("signature created at %s is older than %s", s.Created.UTC().Format(time.RFC3339), opts.MaxAge)
+	case opts.KeyID != "" && s.KeyID != opts.KeyID:
+		return nil, errors.Errorf("signature keyid '%s' does not match '%s'", s.KeyID, opts.KeyID)
+	case opts.Tag != "" && s.Tag != opts.Tag:
+		return nil, errors.Errorf("signature tag '%s' does not match '%s'", s.Tag, opts.Tag)
+	}
+	for _, c := range opts.Components {
+		if !contains(s.Components, strings.ToLower(c)) {
+			return nil, errors.Errorf("signature does not cover the component %s", c)
+		}
+	}
+	return s, nil
+}
+
+// SignatureBase returns the signature base of the request covering the given
+// components, defined in RFC 9421 Section 2.5. The signature parameters are
+// the serialized value of the @signature-params component.
+func SignatureBase(r *Request, components []string, params string) (string, error) {
+	var sb strings.Builder
+	seen := make(map[string]bool)
+	for _, name := range components {
+		if seen[name] {
+			return "", errors.Errorf("component %s is duplicated", name)
+		}
+		seen[name] = true
+		value, err := componentValue(r, name)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(sfString(name))
+		sb.WriteString(": ")
+		sb.WriteString(value)
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(`"@signature-params": `)
+	sb.WriteString(params)
+	return sb.String(), nil
+}
+
+// componentValue returns the value of a derived component or header field.
+func componentValue(r *Request, name string) (string, error) {
+	if !strings.HasPrefix(name, "@") {
+		values, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return "", errors.Errorf("request does not have the header %s", name)
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.TrimSpace(v)
+		}
+		return strings.Join(trimmed, ", "), nil
+	}
+
+	if r.URL == nil {
+		return "", errors.Errorf("request does not have a url, required by %s", name)
+	}
+	u := r.URL
+	switch name {
+	case "@method":
+		return r.Method, nil
+	case "@target-uri":
+		return u.String(), nil
+	case "@authority":
+		return authority(u), nil
+	case "@scheme":
+		return strings.ToLower(u.Scheme), nil
+	case "@request-target":
+		return u.RequestURI(), nil
+	case "@path":
+		if p := u.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + u.RawQuery, nil
+	default:
+		return "", errors.Errorf("unsupported component %s", name)
+	}
+}
+
+// authority returns the lowercased host of the url, with the port only if it
+// is not the default one of the scheme.
+func authority(u *url.URL) string {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	switch {
+	case port == "":
+	case port == "443" && strings.EqualFold(u.Scheme, "https"):
+	case port == "80" && strings.EqualFold(u.Scheme, "http"):
+	default:
+		return net.JoinHostPort(host, port)
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// algorithmForKey returns the default signature algorithm for the given key.
+func algorithmForKey(key interface{}) (string, error) {
+	if signer, ok := key.(crypto.Signer); ok {
+		key = signer.Public()
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return RSAPSSSHA512, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return ECDSAP256SHA256, nil
+		case elliptic.P384():
+			return ECDSAP384SHA384, nil
+		default:
+			return "", errors.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		return Ed25519, nil
+	case []byte:
+		return HMACSHA256, nil
+	default:
+		return "", errors.Errorf("unsupported key type %T", key)
+	}
+}
+
+// checkKey checks that the key can be used with the given algorithm, the key
+// is a public key or a []byte.
+func checkKey(alg string, key interface{}) error {
+	var ok bool
+	switch alg {
+	case RSAPSSSHA512, RSAv15SHA256:
+		_, ok = key.(*rsa.PublicKey)
+	case ECDSAP256SHA256:
+		k, isECDSA := key.(*ecdsa.PublicKey)
+		ok = isECDSA && k.Curve == elliptic.P256()
+	case ECDSAP384SHA384:
+		k, isECDSA := key.(*ecdsa.PublicKey)
+		ok = isECDSA && k.Curve == elliptic.P384()
+	case Ed25519:
+		_, ok = key.(ed25519.PublicKey)
+	case HMACSHA256:
+		_, ok = key.([]byte)
+	default:
+		return errors.Errorf("unsupported signature algorithm %s", alg)
+	}
+	if !ok {
+		return errors.Errorf("key of type %T cannot be used with the algorithm %s", key, alg)
+	}
+	return nil
+}
+
+func sign(alg string, key interface{}, base []byte) ([]byte, error) {
+	if b, ok := key.([]byte); ok {
+		if err := checkKey(alg, b); err != nil {
+			return nil, err
+		}
+		mac := hmac.New(sha256.New, b)
+		mac.Write(base)
+		return mac.Sum(nil), nil
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("key of type %T is not a private key", key)
+	}
+	if err := checkKey(alg, signer.Public()); err != nil {
+		return nil, err
+	}
+
+	var (
+		sig []byte
+		err error
+	)
+	switch alg {
+	case RSAPSSSHA512:
+		sum := sha512.Sum512(base)
+		sig, err = signer.Sign(rand.Reader, sum[:], &rsa.PSSOptions{
+			SaltLength: 64,
+			Hash:       crypto.SHA512,
+		})
+	case RSAv15SHA256:
+		sum := sha256.Sum256(base)
+		sig, err = signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+	case ECDSAP256SHA256:
+		sum := sha256.Sum256(base)
+		if sig, err = signer.Sign(rand.Reader, sum[:], crypto.SHA256); err == nil {
+			sig, err = concatRS(sig, 32)
+		}
+	case ECDSAP384SHA384:
+		sum := sha512.Sum384(base)
+		if sig, err = signer.Sign(rand.Reader, sum[:], crypto.SHA384); err == nil {
+			sig, err = concatRS(sig, 48)
+		}
+	case Ed25519:
+		sig, err = signer.Sign(rand.Reader, base, crypto.Hash(0))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing request")
+	}
+	return sig, nil
+}
+
+func verify(alg string, key interface{}, base, sig []byte) error {
+	if signer, ok := key.(crypto.Signer); ok {
+		key = signer.Public()
+	}
+	if err := checkKey(alg, key); err != nil {
+		return err
+	}
+
+	var ok bool
+	switch alg {
+	case RSAPSSSHA512:
+		sum := sha512.Sum512(base)
+		ok = rsa.VerifyPSS(key.(*rsa.PublicKey), crypto.SHA512, sum[:], sig, &rsa.PSSOptions{
+			SaltLength: 64,
+			Hash:       crypto.SHA512,
+		}) == nil
+	case RSAv15SHA256:
+		sum := sha256.Sum256(base)
+		ok = rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, sum[:], sig) == nil
+	case ECDSAP256SHA256:
+		sum := sha256.Sum256(base)
+		ok = verifyRS(key.(*ecdsa.PublicKey), sum[:], sig, 32)
+	case ECDSAP384SHA384:
+		sum := sha512.Sum384(base)
+		ok = verifyRS(key.(*ecdsa.PublicKey), sum[:], sig, 48)
+	case Ed25519:
+		ok = ed25519.Verify(key.(ed25519.PublicKey), base, sig)
+	case HMACSHA256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write(base)
+		ok = subtle.ConstantTimeCompare(mac.Sum(nil), sig) == 1
+	}
+	if !ok {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+// concatRS converts an ASN.1 ECDSA signature to the concatenation of r and s
+// used by RFC 9421.
+func concatRS(der []byte, size int) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, errors.Wrap(err, "error parsing ECDSA signature")
+	}
+	b := make([]byte, 2*size)
+	rb, sb := sig.R.Bytes(), sig.S.Bytes()
+	copy(b[size-len(rb):size], rb)
+	copy(b[2*size-len(sb):], sb)
+	return b, nil
+}
+
+func verifyRS(pub *ecdsa.PublicKey, sum, sig []byte, size int) bool {
+	if len(sig) != 2*size {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	return ecdsa.Verify(pub, sum, r, s)
+}
+
+// ContentDigest returns the value of the Content-Digest field, defined in
+// RFC 9530, for the given body using SHA-256.
+func ContentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=" + sfBytes(sum[:])
+}
+
+// VerifyContentDigest checks that the value of a Content-Digest field matches
+// the given body. The sha-256 and sha-512 algorithms are supported, others are
+// ignored, but at least one digest must be checked.
+func VerifyContentDigest(value string, body []byte) error {
+	digests, err := parseDictionary(value)
+	if err != nil {
+		return errors.Wrap(err, "error parsing Content-Digest")
+	}
+	var checked bool
+	for alg, v := range digests {
+		digest, ok := v.([]byte)
+		if !ok {
+			return errors.Errorf("error parsing Content-Digest: invalid %s digest", alg)
+		}
+		var sum []byte
+		switch alg {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
+		default:
+			continue
+		}
+		if subtle.ConstantTimeCompare(sum, digest) != 1 {
+			return errors.Errorf("content digest %s does not match the body", alg)
+		}
+		checked = true
+	}
+	if !checked {
+		return errors.New("error verifying Content-Digest: no supported digest algorithm")
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
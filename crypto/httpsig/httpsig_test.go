@@ -0,0 +1,260 @@
+package httpsig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// testRequest returns the request used in the examples of RFC 9421 Appendix
+// B.2.
+func testRequest(t *testing.T) *Request {
+	u, err := url.Parse("https://example.com/foo?param=Value&Pet=dog")
+	require.NoError(t, err)
+	return &Request{
+		Method: "POST",
+		URL:    u,
+		Header: http.Header{
+			"Host":           {"example.com"},
+			"Date":           {"Tue, 20 Apr 2021 02:07:55 GMT"},
+			"Content-Type":   {"application/json"},
+			"Content-Digest": {"sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:"},
+			"Content-Length": {"18"},
+		},
+	}
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestVerify_rfc9421(t *testing.T) {
+	// Test keys from RFC 9421 Appendix B.1
+	hmacKey := mustDecode(t, "uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ==")
+	edKey := ed25519.PublicKey(mustDecode(t, "MCowBQYDK2VwAyEAJrQLj5P/89iXES9+vFgrIy29clF9CC/oPPsw3c5D0bs=")[12:])
+	now := time.Unix(1618884473, 0)
+
+	tests := []struct {
+		name      string
+		key       interface{}
+		input     string
+		signature string
+		want      *Signature
+	}{
+		{"hmac", hmacKey,
+			`sig-b25=("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`,
+			`sig-b25=:pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:`,
+			&Signature{
+				Label:      "sig-b25",
+				Components: []string{"date", "@authority", "content-type"},
+				Created:    now,
+				KeyID:      "test-shared-secret",
+				Algorithm:  HMACSHA256,
+			}},
+		{"ed25519", edKey,
+			`sig-b26=("date" "@method" "@path" "@authority" "content-type" "content-length");created=1618884473;keyid="test-key-ed25519"`,
+			`sig-b26=:wqcAqbmYJ2ji2glfAMaRy4gruYYnx2nEFN2HN6jrnDnQCK1u02Gb04v9EDgwUPiu4A0w6vuQv5lIp5WPpBKRCw==:`,
+			&Signature{
+				Label:      "sig-b26",
+				Components: []string{"date", "@method", "@path", "@authority", "content-type", "content-length"},
+				Created:    now,
+				KeyID:      "test-key-ed25519",
+				Algorithm:  Ed25519,
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRequest(t)
+			r.Header.Set("Signature-Input", tt.input)
+			r.Header.Set("Signature", tt.signature)
+			got, err := Verify(r, tt.key, VerifyOptions{Now: now})
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+
+			// Modified request
+			r.Header.Set("Date", "Tue, 20 Apr 2021 02:07:56 GMT")
+			_, err = Verify(r, tt.key, VerifyOptions{Now: now})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hmacKey := make([]byte, 32)
+	_, err = rand.Read(hmacKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		key     interface{}
+		pub     interface{}
+		alg     string
+		wantAlg string
+	}{
+		{"rsa-pss", rsaKey, rsaKey.Public(), "", RSAPSSSHA512},
+		{"rsa-v1_5", rsaKey, rsaKey.Public(), RSAv15SHA256, RSAv15SHA256},
+		{"p256", p256Key, p256Key.Public(), "", ECDSAP256SHA256},
+		{"p384", p384Key, p384Key.Public(), ECDSAP384SHA384, ECDSAP384SHA384},
+		{"ed25519", edKey, edKey.Public(), "", Ed25519},
+		{"hmac", hmacKey, hmacKey, "", HMACSHA256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Truncate(time.Second)
+			r := testRequest(t)
+			input, sig, err := Sign(r, tt.key, SignOptions{
+				Components: []string{"@method", "@target-uri", "@authority", "@scheme", "@request-target", "@path", "@query", "Content-Digest"},
+				Created:    now,
+				Expires:    now.Add(5 * time.Minute),
+				Nonce:      "abc",
+				KeyID:      "test",
+				Tag:        "step",
+				Algorithm:  tt.alg,
+			})
+			require.NoError(t, err)
+			r.Header.Set("Signature-Input", input)
+			r.Header.Set("Signature", sig)
+
+			got, err := Verify(r, tt.pub, VerifyOptions{
+				Label:      DefaultLabel,
+				Components: []string{"@method", "content-digest"},
+				KeyID:      "test",
+				Tag:        "step",
+				MaxAge:     time.Minute,
+			})
+			require.NoError(t, err)
+			require.Equal(t, &Signature{
+				Label:      DefaultLabel,
+				Components: []string{"@method", "@target-uri", "@authority", "@scheme", "@request-target", "@path", "@query", "content-digest"},
+				Created:    now,
+				Expires:    now.Add(5 * time.Minute),
+				Nonce:      "abc",
+				KeyID:      "test",
+				Tag:        "step",
+				Algorithm:  tt.wantAlg,
+			}, got)
+
+			// Failed validations
+			_, err = Verify(r, tt.pub, VerifyOptions{Now: now.Add(10 * time.Minute)})
+			require.Error(t, err)
+			_, err = Verify(r, tt.pub, VerifyOptions{Now: now.Add(2 * time.Minute), MaxAge: time.Minute})
+			require.Error(t, err)
+			_, err = Verify(r, tt.pub, VerifyOptions{Now: now.Add(-2 * time.Minute)})
+			require.Error(t, err)
+			_, err = Verify(r, tt.pub, VerifyOptions{KeyID: "other"})
+			require.Error(t, err)
+			_, err = Verify(r, tt.pub, VerifyOptions{Components: []string{"date"}})
+			require.Error(t, err)
+			_, err = Verify(r, tt.pub, VerifyOptions{Label: "sig2"})
+			require.Error(t, err)
+			_, err = Verify(r, tt.pub, VerifyOptions{Algorithm: "hmac-sha512"})
+			require.Error(t, err)
+
+			// Modified request
+			r.URL.RawQuery = "param=Value&Pet=cat"
+			_, err = Verify(r, tt.pub, VerifyOptions{})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSign_errors(t *testing.T) {
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	r := testRequest(t)
+	_, _, err = Sign(r, p256Key, SignOptions{Components: []string{"date", "authorization"}})
+	require.Error(t, err)
+	_, _, err = Sign(r, p256Key, SignOptions{Components: []string{"date", "Date"}})
+	require.Error(t, err)
+	_, _, err = Sign(r, p256Key, SignOptions{Components: []string{"@status"}})
+	require.Error(t, err)
+	_, _, err = Sign(r, p256Key, SignOptions{Algorithm: ECDSAP384SHA384})
+	require.Error(t, err)
+	_, _, err = Sign(r, p256Key.Public(), SignOptions{})
+	require.Error(t, err)
+	_, _, err = Sign(r, []byte("secret"), SignOptions{Algorithm: Ed25519})
+	require.Error(t, err)
+}
+
+func TestSignatureBase(t *testing.T) {
+	u, err := url.Parse("http://WWW.Example.com:8080/path?a=b")
+	require.NoError(t, err)
+	r := &Request{
+		Method: "GET",
+		URL:    u,
+		Header: http.Header{"X-Multi": {" a ", "b"}},
+	}
+	base, err := SignatureBase(r, []string{"@authority", "@scheme", "@request-target", "@query", "x-multi"}, `("@authority");created=1`)
+	require.NoError(t, err)
+	require.Equal(t, `"@authority": www.example.com:8080
+"@scheme": http
+"@request-target": /path?a=b
+"@query": ?a=b
+"x-multi": a, b
+"@signature-params": ("@authority");created=1`, base)
+
+	u, err = url.Parse("https://example.com:443")
+	require.NoError(t, err)
+	r.URL = u
+	base, err = SignatureBase(r, []string{"@authority", "@path", "@query"}, `()`)
+	require.NoError(t, err)
+	require.Equal(t, `"@authority": example.com
+"@path": /
+"@query": ?
+"@signature-params": ()`, base)
+}
+
+func TestContentDigest(t *testing.T) {
+	body := []byte(`{"hello": "world"}`)
+	// Example from RFC 9530 Appendix D.1
+	require.Equal(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", ContentDigest(body))
+
+	require.NoError(t, VerifyContentDigest(ContentDigest(body), body))
+	require.NoError(t, VerifyContentDigest("sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:, md5=:AAAA:", body))
+	require.Error(t, VerifyContentDigest(ContentDigest(body), []byte(`{"hello": "there"}`)))
+	require.Error(t, VerifyContentDigest("md5=:AAAA:", body))
+	require.Error(t, VerifyContentDigest("sha-256", body))
+}
+
+func TestParseDictionary(t *testing.T) {
+	m, err := parseDictionary(`sig1=("@method" "a\"b");created=1;alg="ed25519";x=?0;t=tok, sig2=:AQI=:;p=1`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"sig1": &sfInnerList{
+			Items: []string{"@method", `a"b`},
+			Params: []sfParam{
+				{Key: "created", Value: int64(1)},
+				{Key: "alg", Value: "ed25519"},
+				{Key: "x", Value: false},
+				{Key: "t", Value: sfToken("tok")},
+			},
+		},
+		"sig2": []byte{1, 2},
+	}, m)
+	require.Equal(t, `("@method" "a\"b");created=1;alg="ed25519";x=?0;t=tok`, m["sig1"].(*sfInnerList).String())
+
+	for _, s := range []string{`sig1`, `Sig1=()`, `sig1=("a"`, `sig1=("a";x=1)`, `sig1=:AQI=`, `sig1=(), `, `sig1=() sig2=()`, `sig1=1`, `sig1=();created=1.5`} {
+		_, err := parseDictionary(s)
+		require.Error(t, err, s)
+	}
+}
@@ -0,0 +1,333 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements the subset of the structured field values of RFC 8941
+// used by the Signature-Input and Signature fields: dictionaries whose members
+// are inner lists of strings or byte sequences, with parameters.
+
+// sfToken is a structured field token, a value serialized without quotes.
+type sfToken string
+
+// sfParam is a parameter of a structured field. The value is a string, an
+// int64, a bool, an sfToken or a []byte.
+type sfParam struct {
+	Key   string
+	Value interface{}
+}
+
+// sfInnerList is an inner list of strings with parameters.
+type sfInnerList struct {
+	Items  []string
+	Params []sfParam
+}
+
+// String returns the serialization of the inner list.
+func (l *sfInnerList) String() string {
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for i, item := range l.Items {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(sfString(item))
+	}
+	sb.WriteByte(')')
+	for _, p := range l.Params {
+		sb.WriteByte(';')
+		sb.WriteString(p.Key)
+		switch v := p.Value.(type) {
+		case bool:
+			if !v {
+				sb.WriteString("=?0")
+			}
+		case int64:
+			sb.WriteByte('=')
+			sb.WriteString(strconv.FormatInt(v, 10))
+		case string:
+			sb.WriteByte('=')
+			sb.WriteString(sfString(v))
+		case sfToken:
+			sb.WriteByte('=')
+			sb.WriteString(string(v))
+		case []byte:
+			sb.WriteByte('=')
+			sb.WriteString(sfBytes(v))
+		}
+	}
+	return sb.String()
+}
+
+// param returns the value of the parameter with the given key.
+func (l *sfInnerList) param(key string) (interface{}, bool) {
+	for _, p := range l.Params {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return nil, false
+}
+
+// sfString returns the serialization of s as a string.
+func sfString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+// sfBytes returns the serialization of b as a byte sequence.
+func sfBytes(b []byte) string {
+	return ":" + base64.StdEncoding.EncodeToString(b) + ":"
+}
+
+// sfParser parses structured field values.
+type sfParser struct {
+	s string
+	i int
+}
+
+// parseDictionary parses a dictionary whose members are inner lists or byte
+// sequences. The values are *sfInnerList or []byte, the parameters of the
+// byte sequences are ignored.
+func parseDictionary(s string) (map[string]interface{}, error) {
+	p := &sfParser{s: s}
+	m := make(map[string]interface{})
+	p.skipSP()
+	for !p.eof() {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != '=' {
+			return nil, errors.Errorf("error parsing '%s': member %s does not have a value", s, key)
+		}
+		p.i++
+		var v interface{}
+		switch p.peek() {
+		case '(':
+			if v, err = p.parseInnerList(); err != nil {
+				return nil, err
+			}
+		case ':':
+			b, err := p.parseBytes()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.parseParams(); err != nil {
+				return nil, err
+			}
+			v = b
+		default:
+			return nil, errors.Errorf("error parsing '%s': unsupported value of member %s", s, key)
+		}
+		m[key] = v
+
+		p.skipOWS()
+		if p.eof() {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, errors.Errorf("error parsing '%s': expected ','", s)
+		}
+		p.i++
+		p.skipOWS()
+		if p.eof() {
+			return nil, errors.Errorf("error parsing '%s': trailing ','", s)
+		}
+	}
+	return m, nil
+}
+
+func (p *sfParser) eof() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *sfParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *sfParser) skipSP() {
+	for !p.eof() && p.s[p.i] == ' ' {
+		p.i++
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for !p.eof() && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+func (p *sfParser) errorf(msg string) error {
+	return errors.Errorf("error parsing '%s': %s at position %d", p.s, msg, p.i)
+}
+
+func (p *sfParser) parseKey() (string, error) {
+	start := p.i
+	if c := p.peek(); !(c >= 'a' && c <= 'z' || c == '*') {
+		return "", p.errorf("invalid key")
+	}
+	for !p.eof() {
+		c := p.s[p.i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.' || c == '*') {
+			break
+		}
+		p.i++
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *sfParser) parseInnerList() (*sfInnerList, error) {
+	l := new(sfInnerList)
+	p.i++ // (
+	for {
+		p.skipSP()
+		if p.eof() {
+			return nil, p.errorf("unterminated inner list")
+		}
+		if p.peek() == ')' {
+			p.i++
+			break
+		}
+		if p.peek() != '"' {
+			return nil, p.errorf("expected a string")
+		}
+		item, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		params, err := p.parseParams()
+		if err != nil {
+			return nil, err
+		}
+		if len(params) > 0 {
+			return nil, p.errorf("component parameters are not supported")
+		}
+		l.Items = append(l.Items, item)
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, p.errorf("expected ' ' or ')'")
+		}
+	}
+	params, err := p.parseParams()
+	if err != nil {
+		return nil, err
+	}
+	l.Params = params
+	return l, nil
+}
+
+func (p *sfParser) parseParams() ([]sfParam, error) {
+	var params []sfParam
+	for p.peek() == ';' {
+		p.i++
+		p.skipSP()
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var v interface{} = true
+		if p.peek() == '=' {
+			p.i++
+			if v, err = p.parseBareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params = append(params, sfParam{Key: key, Value: v})
+	}
+	return params, nil
+}
+
+func (p *sfParser) parseBareItem() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '-' || c >= '0' && c <= '9':
+		return p.parseInteger()
+	case c == '"':
+		return p.parseString()
+	case c == ':':
+		return p.parseBytes()
+	case c == '?':
+		p.i++
+		switch p.peek() {
+		case '0':
+			p.i++
+			return false, nil
+		case '1':
+			p.i++
+			return true, nil
+		}
+		return nil, p.errorf("invalid boolean")
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '*':
+		start := p.i
+		for !p.eof() && strings.IndexByte(" ;,()\"", p.s[p.i]) < 0 {
+			p.i++
+		}
+		return sfToken(p.s[start:p.i]), nil
+	default:
+		return nil, p.errorf("invalid value")
+	}
+}
+
+func (p *sfParser) parseInteger() (int64, error) {
+	start := p.i
+	if p.peek() == '-' {
+		p.i++
+	}
+	for !p.eof() && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+		p.i++
+	}
+	if p.peek() == '.' {
+		return 0, p.errorf("decimals are not supported")
+	}
+	n, err := strconv.ParseInt(p.s[start:p.i], 10, 64)
+	if err != nil || p.i-start > 16 {
+		return 0, p.errorf("invalid integer")
+	}
+	return n, nil
+}
+
+func (p *sfParser) parseString() (string, error) {
+	var sb strings.Builder
+	p.i++ // "
+	for !p.eof() {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == '\\':
+			if p.eof() || (p.s[p.i] != '"' && p.s[p.i] != '\\') {
+				return "", p.errorf("invalid escape")
+			}
+			sb.WriteByte(p.s[p.i])
+			p.i++
+		case c == '"':
+			return sb.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", p.errorf("invalid character")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *sfParser) parseBytes() ([]byte, error) {
+	p.i++ // :
+	end := strings.IndexByte(p.s[p.i:], ':')
+	if end < 0 {
+		return nil, p.errorf("unterminated byte sequence")
+	}
+	b, err := base64.StdEncoding.DecodeString(p.s[p.i : p.i+end])
+	if err != nil {
+		return nil, p.errorf("invalid byte sequence")
+	}
+	p.i += end + 1
+	return b, nil
+}
@@ -9,7 +9,10 @@ import (
 	"math/big"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/fips"
+	"github.com/smallstep/cli/crypto/policy"
 	stepx509 "github.com/smallstep/cli/pkg/x509"
+	"github.com/smallstep/cli/timing"
 	"golang.org/x/crypto/ed25519"
 )
 
@@ -37,6 +40,9 @@ func PublicKey(priv interface{}) (interface{}, error) {
 	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
 		return k, nil
 	default:
+		if pub, ok := registeredPublicKey(priv); ok {
+			return pub, nil
+		}
 		return nil, errors.Errorf("unrecognized key type: %T", priv)
 	}
 }
@@ -47,20 +53,21 @@ func GenerateDefaultKey() (interface{}, error) {
 	return GenerateKey(DefaultKeyType, DefaultKeyCurve, DefaultKeySize)
 }
 
-// GenerateKey generates a key of the given type (kty).
+// GenerateKey generates a key of the given type (kty). The key type must be
+// one of the registered ones.
 func GenerateKey(kty, crv string, size int) (interface{}, error) {
-	switch kty {
-	case "EC":
-		return generateECKey(crv)
-	case "RSA":
-		return generateRSAKey(size)
-	case "OKP":
-		return generateOKPKey(crv)
-	case "oct":
-		return generateOctKey(size)
-	default:
+	t, ok := Lookup(kty)
+	if !ok {
 		return nil, errors.Errorf("unrecognized key type: %s", kty)
 	}
+	if err := fips.CheckKeyType(kty, crv, size); err != nil {
+		return nil, err
+	}
+	if err := policy.CheckKeyType(kty, crv, size); err != nil {
+		return nil, err
+	}
+	defer timing.Start(timing.Crypto, "generate "+kty+" key")()
+	return t.Generate(crv, size)
 }
 
 // ExtractKey returns the given public or private key or extracts the public key
@@ -80,6 +87,9 @@ func ExtractKey(in interface{}) (interface{}, error) {
 	case *stepx509.CertificateRequest:
 		return k.PublicKey, nil
 	default:
+		if _, ok := registeredPublicKey(in); ok {
+			return in, nil
+		}
 		return nil, errors.Errorf("cannot extract the key from type '%T'", k)
 	}
 }
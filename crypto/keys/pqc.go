@@ -0,0 +1,35 @@
+// +build pqc
+
+package keys
+
+import (
+	"github.com/smallstep/cli/crypto/pqc"
+)
+
+func init() {
+	// The default parameter set goes first.
+	curves := []string{pqc.DefaultAlgorithm}
+	for _, name := range pqc.Algorithms() {
+		if name != pqc.DefaultAlgorithm {
+			curves = append(curves, name)
+		}
+	}
+
+	Register(pqc.KeyType, &KeyType{
+		Curves:       curves,
+		Experimental: true,
+		Generate: func(crv string, size int) (interface{}, error) {
+			return pqc.GenerateKey(crv)
+		},
+		Public: func(key interface{}) (interface{}, bool) {
+			switch k := key.(type) {
+			case *pqc.PrivateKey:
+				return k.Public(), true
+			case *pqc.PublicKey:
+				return k, true
+			default:
+				return nil, false
+			}
+		},
+	})
+}
@@ -0,0 +1,31 @@
+// +build pqc
+
+package keys
+
+import (
+	"testing"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/cli/crypto/pqc"
+)
+
+func TestKeyTypes_pqc(t *testing.T) {
+	kt, ok := Lookup("AKP")
+	assert.True(t, ok)
+	assert.True(t, kt.Experimental)
+	assert.Equals(t, pqc.DefaultAlgorithm, kt.Curves[0])
+}
+
+func TestGenerateKeyPair_registered(t *testing.T) {
+	pub, priv, err := GenerateKeyPair("AKP", pqc.MLDSA44, 0)
+	assert.FatalError(t, err)
+	assert.Type(t, &pqc.PrivateKey{}, priv)
+	assert.Equals(t, priv.(*pqc.PrivateKey).Public(), pub)
+
+	k, err := ExtractKey(pub)
+	assert.FatalError(t, err)
+	assert.Equals(t, pub, k)
+
+	_, err = GenerateKey("AKP", "P-256", 0)
+	assert.Error(t, err)
+}
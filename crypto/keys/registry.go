@@ -0,0 +1,96 @@
+package keys
+
+import (
+	"sort"
+	"sync"
+)
+
+// KeyType defines how the keys of a key type (kty) are generated and used.
+// New algorithms can be supported registering a KeyType with Register.
+type KeyType struct {
+	// Curves is the list of curves or parameter sets supported by the key
+	// type, the first one is the default. It is empty if the key type uses a
+	// size instead.
+	Curves []string
+	// Experimental indicates that the key type is not yet standardized and
+	// requires an explicit opt-in to be used.
+	Experimental bool
+	// Generate generates a new private key with the given curve or size.
+	Generate func(crv string, size int) (interface{}, error)
+	// Public returns the public key of the given private or public key, and
+	// false if the key is not of this key type. It can be nil if the key type
+	// is handled by PublicKey and ExtractKey.
+	Public func(key interface{}) (interface{}, bool)
+}
+
+var (
+	keyTypesMu sync.RWMutex
+	keyTypes   = make(map[string]*KeyType)
+)
+
+func init() {
+	Register("EC", &KeyType{
+		Curves:   []string{"P-256", "P-384", "P-521"},
+		Generate: func(crv string, size int) (interface{}, error) { return generateECKey(crv) },
+	})
+	Register("RSA", &KeyType{
+		Generate: func(crv string, size int) (interface{}, error) { return generateRSAKey(size) },
+	})
+	Register("OKP", &KeyType{
+		Curves:   []string{"Ed25519"},
+		Generate: func(crv string, size int) (interface{}, error) { return generateOKPKey(crv) },
+	})
+	Register("oct", &KeyType{
+		Generate: func(crv string, size int) (interface{}, error) { return generateOctKey(size) },
+	})
+}
+
+// Register adds a key type to the list of supported ones. It panics if the
+// key type is already registered.
+func Register(kty string, t *KeyType) {
+	keyTypesMu.Lock()
+	defer keyTypesMu.Unlock()
+	if t == nil || t.Generate == nil {
+		panic("keys: Register key type is nil")
+	}
+	if _, ok := keyTypes[kty]; ok {
+		panic("keys: Register called twice for key type " + kty)
+	}
+	keyTypes[kty] = t
+}
+
+// Lookup returns the registered key type with the given name.
+func Lookup(kty string) (*KeyType, bool) {
+	keyTypesMu.RLock()
+	defer keyTypesMu.RUnlock()
+	t, ok := keyTypes[kty]
+	return t, ok
+}
+
+// KeyTypes returns the sorted list of registered key types.
+func KeyTypes() []string {
+	keyTypesMu.RLock()
+	defer keyTypesMu.RUnlock()
+	names := make([]string, 0, len(keyTypes))
+	for kty := range keyTypes {
+		names = append(names, kty)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registeredPublicKey returns the public key of a key of a registered key
+// type.
+func registeredPublicKey(key interface{}) (interface{}, bool) {
+	keyTypesMu.RLock()
+	defer keyTypesMu.RUnlock()
+	for _, t := range keyTypes {
+		if t.Public == nil {
+			continue
+		}
+		if pub, ok := t.Public(key); ok {
+			return pub, true
+		}
+	}
+	return nil, false
+}
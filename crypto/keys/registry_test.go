@@ -0,0 +1,32 @@
+package keys
+
+import (
+	"testing"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/cli/crypto/pqc"
+)
+
+func TestKeyTypes(t *testing.T) {
+	want := []string{"EC", "OKP", "RSA", "oct"}
+	if pqc.Enabled {
+		want = append([]string{"AKP"}, want...)
+	}
+	assert.Equals(t, want, KeyTypes())
+
+	kt, ok := Lookup("EC")
+	assert.True(t, ok)
+	assert.False(t, kt.Experimental)
+
+	_, ok = Lookup("foo")
+	assert.False(t, ok)
+}
+
+func TestRegister(t *testing.T) {
+	defer func() {
+		assert.NotNil(t, recover())
+	}()
+	Register("EC", &KeyType{
+		Generate: func(crv string, size int) (interface{}, error) { return nil, nil },
+	})
+}
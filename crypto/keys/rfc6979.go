@@ -0,0 +1,174 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"encoding/asn1"
+	"io"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// deterministicSigner is a crypto.Signer that creates ECDSA signatures using
+// the deterministic nonces defined in RFC 6979.
+type deterministicSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewDeterministicSigner returns a crypto.Signer that signs with the given
+// ECDSA key using deterministic nonces as defined in RFC 6979. The same
+// message signed with the same key always produces the same signature.
+//
+// Deterministic signatures are useful to create reproducible test fixtures or
+// in environments without a reliable source of randomness, but they are
+// vulnerable to fault attacks, the random nonces of ecdsa.Sign should be
+// preferred.
+func NewDeterministicSigner(key *ecdsa.PrivateKey) crypto.Signer {
+	return &deterministicSigner{key: key}
+}
+
+// Public returns the public key of the signer.
+func (s *deterministicSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+// Sign signs the digest and returns the ASN.1 encoded signature. The given
+// rand is not used. The opts must contain the hash function used to create
+// the digest, the nonce is derived using HMAC with the same hash function.
+func (s *deterministicSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	h := opts.HashFunc()
+	if h == 0 || !h.Available() {
+		return nil, errors.New("deterministic ECDSA signatures require a hash function")
+	}
+	if len(digest) != h.Size() {
+		return nil, errors.Errorf("digest size %d does not match the hash function size %d", len(digest), h.Size())
+	}
+	r, ss, err := signRFC6979(s.key, h, digest)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{r, ss})
+}
+
+// signRFC6979 signs the digest using the nonce generation defined in RFC 6979
+// section 3.2.
+func signRFC6979(priv *ecdsa.PrivateKey, h crypto.Hash, digest []byte) (*big.Int, *big.Int, error) {
+	c := priv.Curve
+	n := c.Params().N
+	if n.Sign() == 0 || priv.D.Sign() <= 0 || priv.D.Cmp(n) >= 0 {
+		return nil, nil, errors.New("invalid ECDSA private key")
+	}
+
+	e := bits2int(digest, n)
+	kg := newNonceGenerator(h, priv.D, digest, n)
+	for {
+		k := kg.next()
+		r := nonceR(c, k)
+		if r.Sign() == 0 {
+			continue
+		}
+		kInv := new(big.Int).ModInverse(k, n)
+		s := new(big.Int).Mul(priv.D, r)
+		s.Add(s, e)
+		s.Mul(s, kInv)
+		s.Mod(s, n)
+		if s.Sign() != 0 {
+			return r, s, nil
+		}
+	}
+}
+
+func nonceR(c elliptic.Curve, k *big.Int) *big.Int {
+	x, _ := c.ScalarBaseMult(k.Bytes())
+	return x.Mod(x, c.Params().N)
+}
+
+// nonceGenerator implements the HMAC_DRBG based generation of k values
+// described in RFC 6979 section 3.2.
+type nonceGenerator struct {
+	hash crypto.Hash
+	n    *big.Int
+	k, v []byte
+}
+
+func newNonceGenerator(h crypto.Hash, x *big.Int, digest []byte, n *big.Int) *nonceGenerator {
+	rlen := (n.BitLen() + 7) / 8
+	g := &nonceGenerator{
+		hash: h,
+		n:    n,
+		k:    make([]byte, h.Size()),
+		v:    make([]byte, h.Size()),
+	}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+
+	// bits2octets(h1) = int2octets(bits2int(h1) mod q)
+	z := bits2int(digest, n)
+	if z.Cmp(n) >= 0 {
+		z.Sub(z, n)
+	}
+	seed := append(int2octets(x, rlen), int2octets(z, rlen)...)
+
+	g.k = g.mac(g.k, g.v, []byte{0x00}, seed)
+	g.v = g.mac(g.k, g.v)
+	g.k = g.mac(g.k, g.v, []byte{0x01}, seed)
+	g.v = g.mac(g.k, g.v)
+	return g
+}
+
+// next returns the next candidate k in the range [1, n-1].
+func (g *nonceGenerator) next() *big.Int {
+	rlen := (g.n.BitLen() + 7) / 8
+	for {
+		var t []byte
+		for len(t) < rlen {
+			g.v = g.mac(g.k, g.v)
+			t = append(t, g.v...)
+		}
+		k := bits2int(t[:rlen], g.n)
+
+		// Update the state so a new call returns a different value.
+		g.k = g.mac(g.k, g.v, []byte{0x00})
+		g.v = g.mac(g.k, g.v)
+
+		if k.Sign() > 0 && k.Cmp(g.n) < 0 {
+			return k
+		}
+	}
+}
+
+func (g *nonceGenerator) mac(key []byte, data ...[]byte) []byte {
+	m := hmac.New(g.hash.New, key)
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+// bits2int converts a bit string to an integer as defined in RFC 6979 section
+// 2.3.2, keeping the leftmost bits of the size of the group order.
+func bits2int(b []byte, n *big.Int) *big.Int {
+	z := new(big.Int).SetBytes(b)
+	if blen := len(b) * 8; blen > n.BitLen() {
+		z.Rsh(z, uint(blen-n.BitLen()))
+	}
+	return z
+}
+
+// int2octets converts an integer to a big-endian byte string of the given
+// length as defined in RFC 6979 section 2.3.3.
+func int2octets(x *big.Int, rlen int) []byte {
+	b := x.Bytes()
+	if len(b) >= rlen {
+		return b[len(b)-rlen:]
+	}
+	out := make([]byte, rlen)
+	copy(out[rlen-len(b):], b)
+	return out
+}
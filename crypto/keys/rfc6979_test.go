@@ -0,0 +1,73 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func mustBigInt(t *testing.T, s string) *big.Int {
+	b, err := hex.DecodeString(s)
+	assert.FatalError(t, err)
+	return new(big.Int).SetBytes(b)
+}
+
+// Test vectors from RFC 6979 appendix A.2.5.
+func TestSignRFC6979(t *testing.T) {
+	d := mustBigInt(t, "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")
+	priv := &ecdsa.PrivateKey{D: d}
+	priv.Curve = elliptic.P256()
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(d.Bytes())
+
+	tests := []struct {
+		msg  string
+		r, s string
+	}{
+		{"sample", "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716", "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8"},
+		{"test", "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367", "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) {
+			sum := sha256.Sum256([]byte(tc.msg))
+			r, s, err := signRFC6979(priv, crypto.SHA256, sum[:])
+			assert.FatalError(t, err)
+			assert.Equals(t, mustBigInt(t, tc.r), r)
+			assert.Equals(t, mustBigInt(t, tc.s), s)
+		})
+	}
+}
+
+func TestDeterministicSigner(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.FatalError(t, err)
+
+	signer := NewDeterministicSigner(priv)
+	assert.Equals(t, &priv.PublicKey, signer.Public())
+
+	digest := sha256.Sum256([]byte("message"))
+	sig1, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.FatalError(t, err)
+	sig2, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.FatalError(t, err)
+	assert.Equals(t, sig1, sig2)
+
+	var esig struct {
+		R, S *big.Int
+	}
+	_, err = asn1.Unmarshal(sig1, &esig)
+	assert.FatalError(t, err)
+	assert.True(t, ecdsa.Verify(&priv.PublicKey, digest[:], esig.R, esig.S))
+
+	_, err = signer.Sign(rand.Reader, digest[:], crypto.Hash(0))
+	assert.Error(t, err)
+	_, err = signer.Sign(rand.Reader, digest[:16], crypto.SHA256)
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package pemutil
+
+import (
+	"crypto/sha512"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blowfish"
+)
+
+// bcryptMagic is the plaintext encrypted by the bcrypt hash function used in
+// bcrypt_pbkdf.
+var bcryptMagic = []byte("OxychromaticBlowfishSwatDynamite")
+
+// bcryptPBKDF implements the bcrypt_pbkdf key derivation function used by
+// OpenSSH to encrypt private keys. See
+// https://github.com/openbsd/src/blob/master/lib/libutil/bcrypt_pbkdf.c
+func bcryptPBKDF(password, salt []byte, rounds, keyLen int) ([]byte, error) {
+	if rounds < 1 {
+		return nil, errors.New("bcrypt_pbkdf: number of rounds is too small")
+	}
+	if len(password) == 0 {
+		return nil, errors.New("bcrypt_pbkdf: empty password")
+	}
+	if len(salt) == 0 || len(salt) > 1<<20 {
+		return nil, errors.New("bcrypt_pbkdf: bad salt length")
+	}
+	if keyLen > 1024 {
+		return nil, errors.New("bcrypt_pbkdf: keyLen is too large")
+	}
+
+	numBlocks := (keyLen + 31) / 32
+	key := make([]byte, numBlocks*32)
+
+	h := sha512.New()
+	h.Write(password)
+	shapass := h.Sum(nil)
+
+	shasalt := make([]byte, 0, sha512.Size)
+	cnt, tmp := make([]byte, 4), make([]byte, 32)
+	for block := 1; block <= numBlocks; block++ {
+		h.Reset()
+		h.Write(salt)
+		cnt[0] = byte(block >> 24)
+		cnt[1] = byte(block >> 16)
+		cnt[2] = byte(block >> 8)
+		cnt[3] = byte(block)
+		h.Write(cnt)
+		if err := bcryptHash(tmp, shapass, h.Sum(shasalt)); err != nil {
+			return nil, err
+		}
+
+		out := make([]byte, 32)
+		copy(out, tmp)
+		for i := 2; i <= rounds; i++ {
+			h.Reset()
+			h.Write(tmp)
+			if err := bcryptHash(tmp, shapass, h.Sum(shasalt)); err != nil {
+				return nil, err
+			}
+			for j := range out {
+				out[j] ^= tmp[j]
+			}
+		}
+
+		// The output is interleaved to make the full key depend on all the
+		// blocks.
+		for i, v := range out {
+			key[i*numBlocks+(block-1)] = v
+		}
+	}
+	return key[:keyLen], nil
+}
+
+func bcryptHash(out, shapass, shasalt []byte) error {
+	c, err := blowfish.NewSaltedCipher(shapass, shasalt)
+	if err != nil {
+		return errors.Wrap(err, "bcrypt_pbkdf: error creating cipher")
+	}
+	for i := 0; i < 64; i++ {
+		blowfish.ExpandKey(shasalt, c)
+		blowfish.ExpandKey(shapass, c)
+	}
+	copy(out, bcryptMagic)
+	for i := 0; i < 32; i += 8 {
+		for j := 0; j < 64; j++ {
+			c.Encrypt(out[i:i+8], out[i:i+8])
+		}
+	}
+	// Swap the bytes of each 32-bit word, OpenBSD uses little endian words.
+	for i := 0; i < 32; i += 4 {
+		out[i+3], out[i+2], out[i+1], out[i] = out[i], out[i+1], out[i+2], out[i+3]
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package pemutil
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PEM types of the encrypted private keys used by cosign. Older versions of
+// cosign use the COSIGN type, newer versions use the SIGSTORE type, both
+// share the same encoding.
+const (
+	CosignPrivateKeyType       = "ENCRYPTED SIGSTORE PRIVATE KEY"
+	legacyCosignPrivateKeyType = "ENCRYPTED COSIGN PRIVATE KEY"
+)
+
+// CosignScryptN is the default scrypt cost parameter used to encrypt cosign
+// private keys, the same used by cosign.
+const CosignScryptN = 32768
+
+// cosignEncryptedKey is the JSON encoding of an encrypted cosign private key,
+// defined by the encrypted package of go-securesystemslib. The ciphertext is a
+// PKCS#8 private key encrypted with nacl/secretbox using a key derived with
+// scrypt.
+type cosignEncryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ParseCosignPrivateKey parses the PEM encoded cosign private key in the given
+// bytes. The password in the options will be used to decrypt it, or the user
+// will be prompted for it.
+func ParseCosignPrivateKey(b []byte, opts ...Options) (interface{}, error) {
+	ctx := newContext("PEM")
+	if err := ctx.apply(opts); err != nil {
+		return nil, err
+	}
+	defer ctx.destroy()
+	block, _ := pem.Decode(b)
+	if block == nil || (block.Type != CosignPrivateKeyType && block.Type != legacyCosignPrivateKeyType) {
+		return nil, errors.Errorf("error decoding %s: is not a cosign private key", ctx.filename)
+	}
+	return parseCosignPrivateKey(block.Bytes, ctx)
+}
+
+func parseCosignPrivateKey(b []byte, ctx *context) (interface{}, error) {
+	var enc cosignEncryptedKey
+	if err := json.Unmarshal(b, &enc); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", ctx.filename)
+	}
+	switch {
+	case enc.KDF.Name != "scrypt":
+		return nil, errors.Errorf("error parsing %s: unsupported kdf %s", ctx.filename, enc.KDF.Name)
+	case enc.Cipher.Name != "nacl/secretbox":
+		return nil, errors.Errorf("error parsing %s: unsupported cipher %s", ctx.filename, enc.Cipher.Name)
+	case len(enc.Cipher.Nonce) != 24:
+		return nil, errors.Errorf("error parsing %s: invalid nonce", ctx.filename)
+	}
+
+	// Cosign keys can be encrypted with an empty password, the user is only
+	// prompted if the key cannot be decrypted without one.
+	decrypt := func(pass []byte) ([]byte, error) {
+		k, err := scrypt.Key(pass, enc.KDF.Salt, enc.KDF.Params.N, enc.KDF.Params.R, enc.KDF.Params.P, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error decrypting %s", ctx.filename)
+		}
+		var key [32]byte
+		var nonce [24]byte
+		copy(key[:], k)
+		copy(nonce[:], enc.Cipher.Nonce)
+		data, ok := secretbox.Open(nil, enc.Ciphertext, &nonce, &key)
+		if !ok {
+			return nil, errors.Errorf("error decrypting %s: invalid password", ctx.filename)
+		}
+		return data, nil
+	}
+
+	data, err := decrypt(ctx.password)
+	if err != nil && len(ctx.password) == 0 {
+		var pass []byte
+		if pass, err = ui.PromptPassword(fmt.Sprintf("Please enter the password to decrypt %s", ctx.filename)); err != nil {
+			return nil, err
+		}
+		data, err = decrypt(pass)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := ParsePKCS8PrivateKey(data)
+	return priv, errors.Wrapf(err, "error parsing %s", ctx.filename)
+}
+
+// SerializeCosignPrivateKey returns a PEM block with the given private key in
+// the encrypted format used by cosign. The key is encrypted using the password
+// in the options, cosign allows an empty password. If a filename is given with
+// ToFile, the block will be written to disk.
+func SerializeCosignPrivateKey(key interface{}, opts ...Options) (*pem.Block, error) {
+	ctx := new(context)
+	if err := ctx.apply(opts); err != nil {
+		return nil, err
+	}
+	defer ctx.destroy()
+
+	data, err := MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var enc cosignEncryptedKey
+	enc.KDF.Name = "scrypt"
+	enc.KDF.Params.N = CosignScryptN
+	enc.KDF.Params.R = 8
+	enc.KDF.Params.P = 1
+	if ctx.iterations > 0 {
+		enc.KDF.Params.N = ctx.iterations
+	}
+	enc.KDF.Salt = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, enc.KDF.Salt); err != nil {
+		return nil, errors.Wrap(err, "error generating salt")
+	}
+	enc.Cipher.Name = "nacl/secretbox"
+	enc.Cipher.Nonce = make([]byte, 24)
+	if _, err := io.ReadFull(rand.Reader, enc.Cipher.Nonce); err != nil {
+		return nil, errors.Wrap(err, "error generating nonce")
+	}
+
+	k, err := scrypt.Key(ctx.password, enc.KDF.Salt, enc.KDF.Params.N, enc.KDF.Params.R, enc.KDF.Params.P, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encrypting key")
+	}
+	var secret [32]byte
+	var nonce [24]byte
+	copy(secret[:], k)
+	copy(nonce[:], enc.Cipher.Nonce)
+	enc.Ciphertext = secretbox.Seal(nil, data, &nonce, &secret)
+
+	b, err := json.Marshal(enc)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling key")
+	}
+	p := &pem.Block{
+		Type:  CosignPrivateKeyType,
+		Bytes: b,
+	}
+	if ctx.filename != "" {
+		if err := utils.WriteFile(ctx.filename, pem.EncodeToMemory(p), ctx.perm); err != nil {
+			return nil, errs.FileError(err, ctx.filename)
+		}
+	}
+	return p, nil
+}
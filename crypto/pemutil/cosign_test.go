@@ -0,0 +1,57 @@
+package pemutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSerializeCosignPrivateKey(t *testing.T) {
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name     string
+		key      interface{}
+		password []byte
+	}{
+		{"p256", ec, []byte("mypassword")},
+		{"p256 empty password", ec, nil},
+		{"ed25519", ed, []byte("mypassword")},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := SerializeCosignPrivateKey(tc.key, WithPassword(tc.password), WithKDFIterations(1024))
+			assert.FatalError(t, err)
+			assert.Equals(t, CosignPrivateKeyType, p.Type)
+
+			b := pem.EncodeToMemory(p)
+			key, err := ParseCosignPrivateKey(b, WithPassword(tc.password))
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.key, key)
+
+			// Parse also supports cosign keys
+			key, err = Parse(b, WithPassword(tc.password))
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.key, key)
+
+			// The legacy type uses the same encoding
+			p.Type = legacyCosignPrivateKeyType
+			key, err = ParseCosignPrivateKey(pem.EncodeToMemory(p), WithPassword(tc.password))
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.key, key)
+		})
+	}
+
+	p, err := SerializeCosignPrivateKey(ec, WithPassword([]byte("mypassword")), WithKDFIterations(1024))
+	assert.FatalError(t, err)
+	_, err = ParseCosignPrivateKey(pem.EncodeToMemory(p), WithPassword([]byte("badpassword")))
+	assert.Error(t, err)
+}
@@ -9,13 +9,17 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
-	"io/ioutil"
 	"os"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/crypto/keys"
+	"github.com/smallstep/cli/crypto/policy"
+	"github.com/smallstep/cli/crypto/pqc"
+	"github.com/smallstep/cli/crypto/securebuf"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/fileurl"
 	stepx509 "github.com/smallstep/cli/pkg/x509"
+	"github.com/smallstep/cli/timing"
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 	"golang.org/x/crypto/ed25519"
@@ -27,12 +31,15 @@ var DefaultEncCipher = x509.PEMCipherAES256
 
 // context add options to the pem methods.
 type context struct {
-	filename   string
-	perm       os.FileMode
-	password   []byte
-	pkcs8      bool
-	stepCrypto bool
-	firstBlock bool
+	filename       string
+	perm           os.FileMode
+	password       []byte
+	passwordBuffer *securebuf.Buffer
+	pkcs8          bool
+	stepCrypto     bool
+	firstBlock     bool
+	legacy         bool
+	iterations     int
 }
 
 // newContext initializes the context with a filename.
@@ -53,6 +60,15 @@ func (c *context) apply(opts []Options) error {
 	return nil
 }
 
+// destroy zeroes the password read with WithPasswordFile. The context must not
+// be used after calling it.
+func (c *context) destroy() {
+	if c.passwordBuffer != nil {
+		c.passwordBuffer.Destroy()
+		c.password, c.passwordBuffer = nil, nil
+	}
+}
+
 // Options is the type to add attributes to the context.
 type Options func(o *context) error
 
@@ -89,11 +105,12 @@ func WithPassword(pass []byte) Options {
 // WithPasswordFile is a method that adds the password in a file to the context.
 func WithPasswordFile(filename string) Options {
 	return func(ctx *context) error {
-		b, err := utils.ReadPasswordFromFile(filename)
+		buf, err := utils.ReadPasswordBufferFromFile(filename)
 		if err != nil {
 			return err
 		}
-		ctx.password = b
+		ctx.passwordBuffer.Destroy()
+		ctx.password, ctx.passwordBuffer = buf.Bytes(), buf
 		return nil
 	}
 }
@@ -120,6 +137,33 @@ func WithPKCS8(v bool) Options {
 	}
 }
 
+// WithLegacyEncryption with v set to true returns an option used in the
+// Serialize method to encrypt private keys using the insecure RFC 1423 PEM
+// encryption, with the Proc-Type and DEK-Info headers, instead of PKCS#8 with
+// PBES2. It's only intended for compatibility with software that cannot read
+// PKCS#8 keys. It has no effect on PKCS#8 and Ed25519 keys.
+func WithLegacyEncryption(v bool) Options {
+	return func(ctx *context) error {
+		ctx.legacy = v
+		return nil
+	}
+}
+
+// WithKDFIterations returns an option used in the Serialize method to set the
+// number of iterations of the key derivation function used to encrypt private
+// keys, the PBKDF2 iterations for PKCS#8 keys, or the bcrypt rounds for
+// OpenSSH keys. If not set the defaults PBKDF2Iterations and BcryptRounds are
+// used.
+func WithKDFIterations(n int) Options {
+	return func(ctx *context) error {
+		if n < 0 {
+			return errors.Errorf("invalid number of KDF iterations %d", n)
+		}
+		ctx.iterations = n
+		return nil
+	}
+}
+
 // WithStepCrypto returns cryptographic primitives of the modified step Crypto
 // library.
 func WithStepCrypto() Options {
@@ -141,14 +185,14 @@ func WithFirstBlock() Options {
 // ReadCertificate returns a *x509.Certificate from the given filename. It
 // supports certificates formats PEM and DER.
 func ReadCertificate(filename string, opts ...Options) (*x509.Certificate, error) {
-	b, err := ioutil.ReadFile(filename)
+	b, err := fileurl.ReadFile(filename)
 	if err != nil {
 		return nil, errs.FileError(err, filename)
 	}
 
 	// PEM format
 	if bytes.HasPrefix(b, []byte("-----BEGIN ")) {
-		crt, err := Read(filename, opts...)
+		crt, err := Parse(b, append(opts, WithFilename(filename))...)
 		if err != nil {
 			return nil, err
 		}
@@ -169,7 +213,7 @@ func ReadCertificate(filename string, opts ...Options) (*x509.Certificate, error
 // filename. It supports certificates formats PEM and DER. If a DER-formatted
 // file is given only one certificate will be returned.
 func ReadCertificateBundle(filename string) ([]*x509.Certificate, error) {
-	b, err := ioutil.ReadFile(filename)
+	b, err := fileurl.ReadFile(filename)
 	if err != nil {
 		return nil, errs.FileError(err, filename)
 	}
@@ -209,14 +253,14 @@ func ReadCertificateBundle(filename string) ([]*x509.Certificate, error) {
 // ReadStepCertificate returns a *x509.Certificate from the given filename. It
 // supports certificates formats PEM and DER.
 func ReadStepCertificate(filename string) (*stepx509.Certificate, error) {
-	b, err := ioutil.ReadFile(filename)
+	b, err := fileurl.ReadFile(filename)
 	if err != nil {
 		return nil, errs.FileError(err, filename)
 	}
 
 	// PEM format
 	if bytes.HasPrefix(b, []byte("-----BEGIN ")) {
-		crt, err := Read(filename, []Options{WithStepCrypto()}...)
+		crt, err := Parse(b, WithStepCrypto(), WithFilename(filename))
 		if err != nil {
 			return nil, err
 		}
@@ -240,6 +284,7 @@ func Parse(b []byte, opts ...Options) (interface{}, error) {
 	if err := ctx.apply(opts); err != nil {
 		return nil, err
 	}
+	defer ctx.destroy()
 
 	block, rest := pem.Decode(b)
 	switch {
@@ -252,23 +297,25 @@ func Parse(b []byte, opts ...Options) (interface{}, error) {
 	// PEM is encrypted: ask for password
 	if block.Headers["Proc-Type"] == "4,ENCRYPTED" || block.Type == "ENCRYPTED PRIVATE KEY" {
 		var err error
-		var pass []byte
-
 		if len(ctx.password) > 0 {
-			pass = ctx.password
+			block.Bytes, err = DecryptPEMBlock(block, ctx.password)
 		} else {
-			pass, err = ui.PromptPassword(fmt.Sprintf("Please enter the password to decrypt %s", ctx.filename))
+			var pass *securebuf.Buffer
+			pass, err = ui.PromptPasswordBuffer(fmt.Sprintf("Please enter the password to decrypt %s", ctx.filename))
 			if err != nil {
 				return nil, err
 			}
+			block.Bytes, err = DecryptPEMBlock(block, pass.Bytes())
+			pass.Destroy()
 		}
-
-		block.Bytes, err = DecryptPEMBlock(block, pass)
 		if err != nil {
 			return nil, errors.Wrapf(err, "error decrypting %s", ctx.filename)
 		}
+		// The parsed keys do not reference the decrypted DER
+		defer securebuf.Zero(block.Bytes)
 	}
 
+	defer timing.Start(timing.Keys, "parse "+ctx.filename)()
 	switch block.Type {
 	case "PUBLIC KEY":
 		pub, err := ParsePKIXPublicKey(block.Bytes)
@@ -279,7 +326,12 @@ func Parse(b []byte, opts ...Options) (interface{}, error) {
 	case "EC PRIVATE KEY":
 		priv, err := x509.ParseECPrivateKey(block.Bytes)
 		return priv, errors.Wrapf(err, "error parsing %s", ctx.filename)
-	case "PRIVATE KEY", "OPENSSH PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+	case "OPENSSH PRIVATE KEY":
+		priv, _, err := parseOpenSSHPrivateKey(block.Bytes, ctx)
+		return priv, err
+	case CosignPrivateKeyType, legacyCosignPrivateKeyType:
+		return parseCosignPrivateKey(block.Bytes, ctx)
+	case "PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
 		priv, err := ParsePKCS8PrivateKey(block.Bytes)
 		return priv, errors.Wrapf(err, "error parsing %s", ctx.filename)
 	case "CERTIFICATE":
@@ -317,9 +369,10 @@ func ParseKey(b []byte, opts ...Options) (interface{}, error) {
 //
 // Supported keys algorithms are RSA and EC. Supported standards for private
 // keys are PKCS#1, PKCS#8, RFC5915 for EC, and base64-encoded DER for
-// certificates and public keys.
+// certificates and public keys. Encrypted private keys can use the legacy
+// RFC 1423 encryption or PKCS#8 with PBES2.
 func Read(filename string, opts ...Options) (interface{}, error) {
-	b, err := ioutil.ReadFile(filename)
+	b, err := fileurl.ReadFile(filename)
 	if err != nil {
 		return nil, errs.FileError(err, filename)
 	}
@@ -336,9 +389,10 @@ func Serialize(in interface{}, opts ...Options) (p *pem.Block, err error) {
 	if err := ctx.apply(opts); err != nil {
 		return nil, err
 	}
+	defer ctx.destroy()
 
 	switch k := in.(type) {
-	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey, *pqc.PublicKey:
 		b, err := MarshalPKIXPublicKey(k)
 		if err != nil {
 			return nil, errors.WithStack(err)
@@ -383,7 +437,7 @@ func Serialize(in interface{}, opts ...Options) (p *pem.Block, err error) {
 				Bytes: b,
 			}
 		}
-	case ed25519.PrivateKey: // force the use of pkcs8
+	case ed25519.PrivateKey, *pqc.PrivateKey: // force the use of pkcs8
 		ctx.pkcs8 = true
 		b, err := MarshalPKCS8PrivateKey(k)
 		if err != nil {
@@ -417,10 +471,18 @@ func Serialize(in interface{}, opts ...Options) (p *pem.Block, err error) {
 		return nil, errors.Errorf("cannot serialize type '%T', value '%v'", k, k)
 	}
 
-	// Apply options on the PEM blocks.
+	// Apply options on the PEM blocks. Private keys are encrypted using
+	// PKCS#8 and PBES2 unless the legacy encryption is requested.
 	if ctx.password != nil {
-		if _, ok := in.(crypto.PrivateKey); ok && ctx.pkcs8 {
-			p, err = EncryptPKCS8PrivateKey(rand.Reader, p.Bytes, ctx.password, DefaultEncCipher)
+		stop := timing.Start(timing.Crypto, "encrypt "+p.Type)
+		_, isPrivateKey := in.(crypto.Signer)
+		if isPrivateKey && (ctx.pkcs8 || !ctx.legacy) {
+			if !ctx.pkcs8 {
+				if p.Bytes, err = MarshalPKCS8PrivateKey(in); err != nil {
+					return nil, err
+				}
+			}
+			p, err = encryptPKCS8PrivateKey(rand.Reader, p.Bytes, ctx.password, DefaultEncCipher, ctx.iterations)
 			if err != nil {
 				return nil, err
 			}
@@ -430,9 +492,16 @@ func Serialize(in interface{}, opts ...Options) (p *pem.Block, err error) {
 				return nil, errors.Wrap(err, "failed to serialze to PEM")
 			}
 		}
+		stop()
 	}
 
 	if ctx.filename != "" {
+		// The local policy can forbid writing unencrypted private keys.
+		if _, isPrivateKey := in.(crypto.Signer); isPrivateKey && ctx.password == nil {
+			if err := policy.CheckKeyEncryption(); err != nil {
+				return nil, err
+			}
+		}
 		if err := utils.WriteFile(ctx.filename, pem.EncodeToMemory(p), ctx.perm); err != nil {
 			return nil, errs.FileError(err, ctx.filename)
 		}
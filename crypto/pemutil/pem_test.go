@@ -63,38 +63,39 @@ type testdata struct {
 }
 
 var files = map[string]testdata{
-	"testdata/openssl.p256.pem":              {ecdsaPrivateKey, false, false},
-	"testdata/openssl.p256.pub.pem":          {ecdsaPublicKey, false, false},
-	"testdata/openssl.p256.enc.pem":          {ecdsaPrivateKey, true, false},
-	"testdata/openssl.p384.pem":              {ecdsaPrivateKey, false, false},
-	"testdata/openssl.p384.pub.pem":          {ecdsaPublicKey, false, false},
-	"testdata/openssl.p384.enc.pem":          {ecdsaPrivateKey, true, false},
-	"testdata/openssl.p521.pem":              {ecdsaPrivateKey, false, false},
-	"testdata/openssl.p521.pub.pem":          {ecdsaPublicKey, false, false},
-	"testdata/openssl.p521.enc.pem":          {ecdsaPrivateKey, true, false},
-	"testdata/openssl.rsa1024.pem":           {rsaPrivateKey, false, false},
-	"testdata/openssl.rsa1024.pub.pem":       {rsaPublicKey, false, false},
-	"testdata/openssl.rsa1024.enc.pem":       {rsaPrivateKey, true, false},
-	"testdata/openssl.rsa2048.pem":           {rsaPrivateKey, false, false},
-	"testdata/openssl.rsa2048.pub.pem":       {rsaPublicKey, false, false},
-	"testdata/openssl.rsa2048.enc.pem":       {rsaPrivateKey, true, false},
-	"testdata/pkcs8/openssl.ed25519.pem":     {ed25519PrivateKey, false, true},
-	"testdata/pkcs8/openssl.ed25519.pub.pem": {ed25519PublicKey, false, true},
-	"testdata/pkcs8/openssl.ed25519.enc.pem": {ed25519PrivateKey, true, true},
-	"testdata/pkcs8/openssl.p256.pem":        {ecdsaPrivateKey, false, true},
-	"testdata/pkcs8/openssl.p256.pub.pem":    {ecdsaPublicKey, false, true},
-	"testdata/pkcs8/openssl.p256.enc.pem":    {ecdsaPrivateKey, true, true},
-	"testdata/pkcs8/openssl.p384.pem":        {ecdsaPrivateKey, false, true},
-	"testdata/pkcs8/openssl.p384.pub.pem":    {ecdsaPublicKey, false, true},
-	"testdata/pkcs8/openssl.p384.enc.pem":    {ecdsaPrivateKey, true, true},
-	"testdata/pkcs8/openssl.p521.pem":        {ecdsaPrivateKey, false, true},
-	"testdata/pkcs8/openssl.p521.pub.pem":    {ecdsaPublicKey, false, true},
-	"testdata/pkcs8/openssl.p521.enc.pem":    {ecdsaPrivateKey, true, true},
-	"testdata/pkcs8/openssl.rsa2048.pem":     {rsaPrivateKey, false, true},
-	"testdata/pkcs8/openssl.rsa2048.pub.pem": {rsaPublicKey, false, true},
-	"testdata/pkcs8/openssl.rsa2048.enc.pem": {rsaPrivateKey, true, true},
-	"testdata/pkcs8/openssl.rsa4096.pem":     {rsaPrivateKey, false, true},
-	"testdata/pkcs8/openssl.rsa4096.pub.pem": {rsaPublicKey, false, true},
+	"testdata/openssl.p256.pem":                     {ecdsaPrivateKey, false, false},
+	"testdata/openssl.p256.pub.pem":                 {ecdsaPublicKey, false, false},
+	"testdata/openssl.p256.enc.pem":                 {ecdsaPrivateKey, true, false},
+	"testdata/openssl.p384.pem":                     {ecdsaPrivateKey, false, false},
+	"testdata/openssl.p384.pub.pem":                 {ecdsaPublicKey, false, false},
+	"testdata/openssl.p384.enc.pem":                 {ecdsaPrivateKey, true, false},
+	"testdata/openssl.p521.pem":                     {ecdsaPrivateKey, false, false},
+	"testdata/openssl.p521.pub.pem":                 {ecdsaPublicKey, false, false},
+	"testdata/openssl.p521.enc.pem":                 {ecdsaPrivateKey, true, false},
+	"testdata/openssl.rsa1024.pem":                  {rsaPrivateKey, false, false},
+	"testdata/openssl.rsa1024.pub.pem":              {rsaPublicKey, false, false},
+	"testdata/openssl.rsa1024.enc.pem":              {rsaPrivateKey, true, false},
+	"testdata/openssl.rsa2048.pem":                  {rsaPrivateKey, false, false},
+	"testdata/openssl.rsa2048.pub.pem":              {rsaPublicKey, false, false},
+	"testdata/openssl.rsa2048.enc.pem":              {rsaPrivateKey, true, false},
+	"testdata/pkcs8/openssl.ed25519.pem":            {ed25519PrivateKey, false, true},
+	"testdata/pkcs8/openssl.ed25519.pub.pem":        {ed25519PublicKey, false, true},
+	"testdata/pkcs8/openssl.ed25519.enc.pem":        {ed25519PrivateKey, true, true},
+	"testdata/pkcs8/openssl.p256.pem":               {ecdsaPrivateKey, false, true},
+	"testdata/pkcs8/openssl.p256.pub.pem":           {ecdsaPublicKey, false, true},
+	"testdata/pkcs8/openssl.p256.enc.pem":           {ecdsaPrivateKey, true, true},
+	"testdata/pkcs8/openssl.p384.pem":               {ecdsaPrivateKey, false, true},
+	"testdata/pkcs8/openssl.p384.pub.pem":           {ecdsaPublicKey, false, true},
+	"testdata/pkcs8/openssl.p384.enc.pem":           {ecdsaPrivateKey, true, true},
+	"testdata/pkcs8/openssl.p521.pem":               {ecdsaPrivateKey, false, true},
+	"testdata/pkcs8/openssl.p521.pub.pem":           {ecdsaPublicKey, false, true},
+	"testdata/pkcs8/openssl.p521.enc.pem":           {ecdsaPrivateKey, true, true},
+	"testdata/pkcs8/openssl.rsa2048.pem":            {rsaPrivateKey, false, true},
+	"testdata/pkcs8/openssl.rsa2048.pub.pem":        {rsaPublicKey, false, true},
+	"testdata/pkcs8/openssl.rsa2048.enc.pem":        {rsaPrivateKey, true, true},
+	"testdata/pkcs8/openssl.rsa4096.pem":            {rsaPrivateKey, false, true},
+	"testdata/pkcs8/openssl.rsa4096.pub.pem":        {rsaPublicKey, false, true},
+	"testdata/pkcs8/openssl.rsa4096.scrypt.enc.pem": {rsaPrivateKey, true, true},
 }
 
 func TestRead(t *testing.T) {
@@ -341,10 +342,11 @@ func TestParsePEM(t *testing.T) {
 
 func TestSerialize(t *testing.T) {
 	tests := map[string]struct {
-		in   func() (interface{}, error)
-		pass string
-		file string
-		err  error
+		in     func() (interface{}, error)
+		pass   string
+		legacy bool
+		file   string
+		err    error
 	}{
 		"unrecognized key type": {
 			in: func() (interface{}, error) {
@@ -374,6 +376,32 @@ func TestSerialize(t *testing.T) {
 			},
 			pass: "pass",
 		},
+		"EC Private Key success - legacy encryption": {
+			in: func() (interface{}, error) {
+				return keys.GenerateKey("EC", "P-256", 0)
+			},
+			pass:   "pass",
+			legacy: true,
+		},
+		"RSA Private Key success - encrypt input data": {
+			in: func() (interface{}, error) {
+				return keys.GenerateKey("RSA", "", 1024)
+			},
+			pass: "pass",
+		},
+		"RSA Private Key success - legacy encryption": {
+			in: func() (interface{}, error) {
+				return keys.GenerateKey("RSA", "", 1024)
+			},
+			pass:   "pass",
+			legacy: true,
+		},
+		"OKP Private Key success - encrypt input data": {
+			in: func() (interface{}, error) {
+				return keys.GenerateKey("OKP", "Ed25519", 0)
+			},
+			pass: "pass",
+		},
 		"EC Public Key success": {
 			in: func() (interface{}, error) {
 				pub, _, err := keys.GenerateKeyPair("EC", "P-256", 0)
@@ -422,16 +450,14 @@ func TestSerialize(t *testing.T) {
 		in, err := test.in()
 		assert.FatalError(t, err)
 
-		var p *pem.Block
-		if test.pass == "" && test.file == "" {
-			p, err = Serialize(in)
-		} else if test.pass != "" && test.file != "" {
-			p, err = Serialize(in, WithPassword([]byte(test.pass)), ToFile(test.file, 0600))
-		} else if test.pass != "" {
-			p, err = Serialize(in, WithPassword([]byte(test.pass)))
-		} else {
-			p, err = Serialize(in, ToFile(test.file, 0600))
+		var opts []Options
+		if test.pass != "" {
+			opts = append(opts, WithPassword([]byte(test.pass)), WithLegacyEncryption(test.legacy))
+		}
+		if test.file != "" {
+			opts = append(opts, ToFile(test.file, 0600))
 		}
+		p, err := Serialize(in, opts...)
 
 		if err != nil {
 			if assert.NotNil(t, test.err) {
@@ -441,11 +467,14 @@ func TestSerialize(t *testing.T) {
 			if assert.Nil(t, test.err) {
 				switch k := in.(type) {
 				case *rsa.PrivateKey:
-					if test.pass == "" {
+					switch {
+					case test.pass == "":
 						assert.False(t, x509.IsEncryptedPEMBlock(p))
 						assert.Equals(t, p.Type, "RSA PRIVATE KEY")
 						assert.Equals(t, p.Bytes, x509.MarshalPKCS1PrivateKey(k))
-					} else {
+					case !test.legacy:
+						assertEncryptedPKCS8(t, p, test.pass, k)
+					default:
 						assert.True(t, x509.IsEncryptedPEMBlock(p))
 						assert.Equals(t, p.Type, "RSA PRIVATE KEY")
 						assert.Equals(t, p.Headers["Proc-Type"], "4,ENCRYPTED")
@@ -462,6 +491,16 @@ func TestSerialize(t *testing.T) {
 					assert.FatalError(t, err)
 					assert.Equals(t, p.Bytes, b)
 				case *ecdsa.PrivateKey:
+					if test.pass != "" && !test.legacy {
+						assertEncryptedPKCS8(t, p, test.pass, k)
+						if test.file != "" {
+							keyFileBytes, err := ioutil.ReadFile(test.file)
+							assert.FatalError(t, err)
+							pemKey, _ := pem.Decode(keyFileBytes)
+							assertEncryptedPKCS8(t, pemKey, test.pass, k)
+						}
+						break
+					}
 					assert.Equals(t, p.Type, "EC PRIVATE KEY")
 					var actualBytes []byte
 					if test.pass == "" {
@@ -498,18 +537,13 @@ func TestSerialize(t *testing.T) {
 						assert.Equals(t, actualBytes, expectedBytes)
 					}
 				case ed25519.PrivateKey:
-					assert.Equals(t, p.Type, "PRIVATE KEY")
-					var actualBytes []byte
-					if test.pass == "" {
-						assert.False(t, x509.IsEncryptedPEMBlock(p))
-						actualBytes = p.Bytes
-					} else {
-						assert.True(t, x509.IsEncryptedPEMBlock(p))
-						assert.Equals(t, p.Headers["Proc-Type"], "4,ENCRYPTED")
-
-						actualBytes, err = x509.DecryptPEMBlock(p, []byte(test.pass))
-						assert.FatalError(t, err)
+					if test.pass != "" {
+						assertEncryptedPKCS8(t, p, test.pass, k)
+						break
 					}
+					assert.Equals(t, p.Type, "PRIVATE KEY")
+					assert.False(t, x509.IsEncryptedPEMBlock(p))
+					actualBytes := p.Bytes
 
 					var priv pkcs8
 					_, err = asn1.Unmarshal(actualBytes, &priv)
@@ -646,6 +680,24 @@ func TestParseKey(t *testing.T) {
 		})
 	}
 }
+func TestParseKey_passwordFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "pemutil-password")
+	assert.FatalError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("mypassword\n")
+	assert.FatalError(t, err)
+	assert.FatalError(t, f.Close())
+
+	data, err := ioutil.ReadFile("testdata/openssl.p256.enc.pem")
+	assert.FatalError(t, err)
+	key, err := ParseKey(data, WithPasswordFile(f.Name()))
+	assert.FatalError(t, err)
+	assert.Type(t, &ecdsa.PrivateKey{}, key)
+
+	_, err = ParseKey(data, WithPasswordFile(f.Name()+".missing"))
+	assert.Error(t, err)
+}
+
 func TestParseKey_x509(t *testing.T) {
 	b, _ := pem.Decode([]byte(testCRT))
 	cert, err := x509.ParseCertificate(b.Bytes)
@@ -661,3 +713,15 @@ func TestParseKey_x509(t *testing.T) {
 	assert.FatalError(t, err)
 	assert.Equals(t, csr.PublicKey, key)
 }
+
+func assertEncryptedPKCS8(t *testing.T, p *pem.Block, pass string, key interface{}) {
+	t.Helper()
+	assert.Equals(t, "ENCRYPTED PRIVATE KEY", p.Type)
+	assert.False(t, x509.IsEncryptedPEMBlock(p))
+
+	der, err := DecryptPKCS8PrivateKey(p.Bytes, []byte(pass))
+	assert.FatalError(t, err)
+	priv, err := ParsePKCS8PrivateKey(der)
+	assert.FatalError(t, err)
+	assert.Equals(t, key, priv)
+}
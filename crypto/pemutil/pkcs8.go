@@ -16,8 +16,11 @@ import (
 	"io"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pqc"
+	"github.com/smallstep/cli/timing"
 	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
 )
 
 // PBKDF2SaltSize is the default size of the salt for PBKDF2, 128-bit salt.
@@ -27,6 +30,14 @@ const PBKDF2SaltSize = 16
 // iterations. Nist recommends at least 10k, 1Passsword uses 100k.
 const PBKDF2Iterations = 100000
 
+// maxPBKDF2Iterations and maxScryptCost limit the work required to derive the
+// key of an encrypted PEM, so a crafted file cannot hang the process or
+// exhaust its memory. The scrypt cost is N·r, scrypt uses 128·N·r bytes, 512MiB.
+const (
+	maxPBKDF2Iterations = 10000000
+	maxScryptCost       = 1 << 22
+)
+
 // pkcs8 reflects an ASN.1, PKCS#8 PrivateKey. See
 // ftp://ftp.rsasecurity.com/pub/pkcs/pkcs-8/pkcs-8v1_2.asn
 // and RFC 5208.
@@ -54,31 +65,32 @@ type prfParam struct {
 type pbkdf2Params struct {
 	Salt           []byte
 	IterationCount int
+	KeyLength      int      `asn1:"optional"`
 	PrfParam       prfParam `asn1:"optional"`
 }
 
-type pbkdf2Algorithms struct {
-	Algo         asn1.ObjectIdentifier
-	PBKDF2Params pbkdf2Params
+// scryptParams reflects the scrypt parameters defined in RFC 7914.
+type scryptParams struct {
+	Salt                     []byte
+	CostParameter            int
+	BlockSize                int
+	ParallelizationParameter int
+	KeyLength                int `asn1:"optional"`
 }
 
-type pbkdf2Encs struct {
-	EncryAlgo asn1.ObjectIdentifier
-	IV        []byte
+// gcmParams reflects the AES-GCM parameters defined in RFC 5084.
+type gcmParams struct {
+	Nonce  []byte
+	ICVLen int `asn1:"default:12"`
 }
 
 type pbes2Params struct {
-	KeyDerivationFunc pbkdf2Algorithms
-	EncryptionScheme  pbkdf2Encs
-}
-
-type encryptedlAlgorithmIdentifier struct {
-	Algorithm  asn1.ObjectIdentifier
-	Parameters pbes2Params
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
 }
 
 type encryptedPrivateKeyInfo struct {
-	Algo       encryptedlAlgorithmIdentifier
+	Algo       pkix.AlgorithmIdentifier
 	PrivateKey []byte
 }
 
@@ -93,6 +105,7 @@ var (
 	oidPKCS5PBKDF2    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
 	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
 	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidScrypt         = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
 
 	// encryption
 	oidAES128CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
@@ -100,6 +113,9 @@ var (
 	oidAES256CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
 	oidDESCBC    = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 7}
 	oidD3DESCBC  = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128GCM = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 6}
+	oidAES192GCM = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 26}
+	oidAES256GCM = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 46}
 )
 
 // rfc1423Algo holds a method for enciphering a PEM block.
@@ -165,8 +181,8 @@ func cipherByKey(key x509.PEMCipher) *rfc1423Algo {
 // deriveKey uses a key derivation function to stretch the password into a key
 // with the number of bits our cipher requires. This algorithm was derived from
 // the OpenSSL source.
-func (c rfc1423Algo) deriveKey(password, salt []byte, h func() hash.Hash) []byte {
-	return pbkdf2.Key(password, salt, PBKDF2Iterations, c.keySize, h)
+func (c rfc1423Algo) deriveKey(password, salt []byte, iter int, h func() hash.Hash) []byte {
+	return pbkdf2.Key(password, salt, iter, c.keySize, h)
 }
 
 // ParsePKCS8PrivateKey parses an unencrypted, PKCS#8 private key. See RFC
@@ -189,6 +205,9 @@ func ParsePKCS8PrivateKey(der []byte) (key interface{}, err error) {
 		copy(seed, privKey.PrivateKey[2:])
 		key = ed25519.NewKeyFromSeed(seed)
 		return key, nil
+	case isPQCAlgorithm(privKey.Algo.Algorithm):
+		name, _ := pqc.AlgorithmByOID(privKey.Algo.Algorithm)
+		return pqc.ParsePrivateKey(name, privKey.PrivateKey)
 	// Proof of concept for key agreement algorithm X25519.
 	// A real implementation would use their own types.
 	//
@@ -206,6 +225,13 @@ func ParsePKCS8PrivateKey(der []byte) (key interface{}, err error) {
 	}
 }
 
+// isPQCAlgorithm returns true if the given object identifier is one of the
+// ML-DSA or ML-KEM parameter sets.
+func isPQCAlgorithm(oid asn1.ObjectIdentifier) bool {
+	_, ok := pqc.AlgorithmByOID(oid)
+	return ok
+}
+
 // ParsePKIXPublicKey parses a DER encoded public key. These values are
 // typically found in PEM blocks with "BEGIN PUBLIC KEY".
 //
@@ -226,6 +252,9 @@ func ParsePKIXPublicKey(derBytes []byte) (pub interface{}, err error) {
 	case pki.Algo.Algorithm.Equal(oidEd25519):
 		pub = ed25519.PublicKey(pki.PublicKey.Bytes)
 		return pub, nil
+	case isPQCAlgorithm(pki.Algo.Algorithm):
+		name, _ := pqc.AlgorithmByOID(pki.Algo.Algorithm)
+		return pqc.NewPublicKey(name, pki.PublicKey.Bytes)
 	// Prove of concept for key agreement algorithm X25519.
 	// A real implementation would use their own types.
 	//
@@ -253,6 +282,19 @@ func MarshalPKIXPublicKey(pub interface{}) ([]byte, error) {
 			BitLength: 8 * len(p),
 		}
 		return asn1.Marshal(pkix)
+	case *pqc.PublicKey:
+		oid, err := pqc.OID(p.Algorithm())
+		if err != nil {
+			return nil, err
+		}
+		b := p.Bytes()
+		var pkix publicKeyInfo
+		pkix.Algo.Algorithm = oid
+		pkix.PublicKey = asn1.BitString{
+			Bytes:     b,
+			BitLength: 8 * len(b),
+		}
+		return asn1.Marshal(pkix)
 	default:
 		return nil, errors.Errorf("x509: unknown public key type: %T", pub)
 	}
@@ -274,6 +316,18 @@ func MarshalPKCS8PrivateKey(key interface{}) ([]byte, error) {
 		}
 		b, err := asn1.Marshal(priv)
 		return b, errors.Wrap(err, "error marshalling PKCS#8")
+	case *pqc.PrivateKey:
+		oid, err := pqc.OID(k.Algorithm())
+		if err != nil {
+			return nil, err
+		}
+		var priv pkcs8
+		if priv.PrivateKey, err = pqc.MarshalPrivateKey(k); err != nil {
+			return nil, err
+		}
+		priv.Algo = pkix.AlgorithmIdentifier{Algorithm: oid}
+		b, err := asn1.Marshal(priv)
+		return b, errors.Wrap(err, "error marshalling PKCS#8")
 	default:
 		return nil, errors.Errorf("x509: unknown key type while marshalling PKCS#8: %T", key)
 	}
@@ -287,6 +341,7 @@ func MarshalPKCS8PrivateKey(key interface{}) ([]byte, error) {
 // block using AES-128-CBC, AES-192-CBC, AES-256-CBC, DES, or 3DES using the
 // key derived using PBKDF2 over the given password.
 func DecryptPEMBlock(block *pem.Block, password []byte) ([]byte, error) {
+	defer timing.Start(timing.Crypto, "decrypt "+block.Type)()
 	if block.Headers["Proc-Type"] == "4,ENCRYPTED" {
 		return x509.DecryptPEMBlock(block, password)
 	}
@@ -302,8 +357,9 @@ func DecryptPEMBlock(block *pem.Block, password []byte) ([]byte, error) {
 // DecryptPKCS8PrivateKey takes a password encrypted private key using the
 // PKCS#8 encoding and returns the decrypted data in PKCS#8 form.
 //
-// It supports AES-128-CBC, AES-192-CBC, AES-256-CBC, DES, or 3DES encrypted
-// data using the key derived with PBKDF2 over the given password.
+// It supports PBES2 encrypted data using AES-128-CBC, AES-192-CBC,
+// AES-256-CBC, AES-128-GCM, AES-192-GCM, AES-256-GCM, DES, or 3DES, with a
+// key derived from the given password using PBKDF2 or scrypt.
 func DecryptPKCS8PrivateKey(data, password []byte) ([]byte, error) {
 	var pki encryptedPrivateKeyInfo
 	if _, err := asn1.Unmarshal(data, &pki); err != nil {
@@ -314,62 +370,154 @@ func DecryptPKCS8PrivateKey(data, password []byte) ([]byte, error) {
 		return nil, errors.New("unsupported encrypted PEM: only PBES2 is supported")
 	}
 
-	if !pki.Algo.Parameters.KeyDerivationFunc.Algo.Equal(oidPKCS5PBKDF2) {
-		return nil, errors.New("unsupported encrypted PEM: only PBKDF2 is supported")
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(pki.Algo.Parameters.FullBytes, &params); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal PBES2 parameters")
 	}
 
-	encParam := pki.Algo.Parameters.EncryptionScheme
-	kdfParam := pki.Algo.Parameters.KeyDerivationFunc.PBKDF2Params
-
-	iv := encParam.IV
-	salt := kdfParam.Salt
-	iter := kdfParam.IterationCount
-
-	// pbkdf2 hash function
-	keyHash := sha1.New
-	if kdfParam.PrfParam.Algo.Equal(oidHMACWithSHA256) {
-		keyHash = sha256.New
-	}
-
-	encryptedKey := pki.PrivateKey
-	var symkey []byte
-	var block cipher.Block
-	var err error
+	encAlgo := params.EncryptionScheme.Algorithm
+	var keySize int
 	switch {
-	// AES-128-CBC, AES-192-CBC, AES-256-CBC
-	case encParam.EncryAlgo.Equal(oidAES128CBC):
-		symkey = pbkdf2.Key(password, salt, iter, 16, keyHash)
-		block, err = aes.NewCipher(symkey)
-	case encParam.EncryAlgo.Equal(oidAES196CBC):
-		symkey = pbkdf2.Key(password, salt, iter, 24, keyHash)
-		block, err = aes.NewCipher(symkey)
-	case encParam.EncryAlgo.Equal(oidAES256CBC):
-		symkey = pbkdf2.Key(password, salt, iter, 32, keyHash)
-		block, err = aes.NewCipher(symkey)
-	// DES, TripleDES
-	case encParam.EncryAlgo.Equal(oidDESCBC):
-		symkey = pbkdf2.Key(password, salt, iter, 8, keyHash)
-		block, err = des.NewCipher(symkey)
-	case encParam.EncryAlgo.Equal(oidD3DESCBC):
-		symkey = pbkdf2.Key(password, salt, iter, 24, keyHash)
-		block, err = des.NewTripleDESCipher(symkey)
+	case encAlgo.Equal(oidAES128CBC), encAlgo.Equal(oidAES128GCM):
+		keySize = 16
+	case encAlgo.Equal(oidAES196CBC), encAlgo.Equal(oidAES192GCM), encAlgo.Equal(oidD3DESCBC):
+		keySize = 24
+	case encAlgo.Equal(oidAES256CBC), encAlgo.Equal(oidAES256GCM):
+		keySize = 32
+	case encAlgo.Equal(oidDESCBC):
+		keySize = 8
 	default:
-		return nil, errors.Errorf("unsupported encrypted PEM: unknown algorithm %v", encParam.EncryAlgo)
+		return nil, errors.Errorf("unsupported encrypted PEM: unknown algorithm %v", encAlgo)
 	}
+
+	symkey, err := deriveKDFKey(params.KeyDerivationFunc, password, keySize)
 	if err != nil {
 		return nil, err
 	}
 
-	mode := cipher.NewCBCDecrypter(block, iv)
-	mode.CryptBlocks(encryptedKey, encryptedKey)
+	// Do not modify the given data.
+	encryptedKey := make([]byte, len(pki.PrivateKey))
+	copy(encryptedKey, pki.PrivateKey)
+	encParams := params.EncryptionScheme.Parameters.FullBytes
 
-	return encryptedKey, nil
+	switch {
+	// AES-128-GCM, AES-192-GCM, AES-256-GCM
+	case encAlgo.Equal(oidAES128GCM), encAlgo.Equal(oidAES192GCM), encAlgo.Equal(oidAES256GCM):
+		var gp gcmParams
+		if _, err := asn1.Unmarshal(encParams, &gp); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal AES-GCM parameters")
+		}
+		block, err := aes.NewCipher(symkey)
+		if err != nil {
+			return nil, err
+		}
+		if len(gp.Nonce) != 12 {
+			return nil, errors.New("unsupported encrypted PEM: invalid AES-GCM nonce size")
+		}
+		aead, err := cipher.NewGCMWithTagSize(block, gp.ICVLen)
+		if err != nil {
+			return nil, errors.Wrap(err, "unsupported encrypted PEM")
+		}
+		plaintext, err := aead.Open(encryptedKey[:0], gp.Nonce, encryptedKey, nil)
+		if err != nil {
+			return nil, x509.IncorrectPasswordError
+		}
+		return plaintext, nil
+	// AES-128-CBC, AES-192-CBC, AES-256-CBC, DES, TripleDES
+	default:
+		var iv []byte
+		if _, err := asn1.Unmarshal(encParams, &iv); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal IV")
+		}
+		var block cipher.Block
+		switch {
+		case encAlgo.Equal(oidDESCBC):
+			block, err = des.NewCipher(symkey)
+		case encAlgo.Equal(oidD3DESCBC):
+			block, err = des.NewTripleDESCipher(symkey)
+		default:
+			block, err = aes.NewCipher(symkey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		blockSize := block.BlockSize()
+		if len(iv) != blockSize || len(encryptedKey) == 0 || len(encryptedKey)%blockSize != 0 {
+			return nil, errors.New("unsupported encrypted PEM: invalid encrypted data")
+		}
+		mode := cipher.NewCBCDecrypter(block, iv)
+		mode.CryptBlocks(encryptedKey, encryptedKey)
+
+		// Remove and validate the padding. See RFC 8018, section 6.1.1.
+		pad := int(encryptedKey[len(encryptedKey)-1])
+		if pad == 0 || pad > blockSize {
+			return nil, x509.IncorrectPasswordError
+		}
+		for _, b := range encryptedKey[len(encryptedKey)-pad:] {
+			if int(b) != pad {
+				return nil, x509.IncorrectPasswordError
+			}
+		}
+		return encryptedKey[:len(encryptedKey)-pad], nil
+	}
+}
+
+// deriveKDFKey derives a key of the given size from the password using the
+// PBES2 key derivation function, PBKDF2 or scrypt.
+func deriveKDFKey(kdf pkix.AlgorithmIdentifier, password []byte, keySize int) ([]byte, error) {
+	switch {
+	case kdf.Algorithm.Equal(oidPKCS5PBKDF2):
+		var params pbkdf2Params
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal PBKDF2 parameters")
+		}
+		if params.KeyLength != 0 && params.KeyLength != keySize {
+			return nil, errors.New("unsupported encrypted PEM: invalid key length")
+		}
+		if params.IterationCount > maxPBKDF2Iterations {
+			return nil, errors.Errorf("unsupported encrypted PEM: PBKDF2 iterations cannot be greater than %d", maxPBKDF2Iterations)
+		}
+		// pbkdf2 hash function
+		keyHash := sha1.New
+		if params.PrfParam.Algo.Equal(oidHMACWithSHA256) {
+			keyHash = sha256.New
+		}
+		return pbkdf2.Key(password, params.Salt, params.IterationCount, keySize, keyHash), nil
+	case kdf.Algorithm.Equal(oidScrypt):
+		var params scryptParams
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal scrypt parameters")
+		}
+		if params.KeyLength != 0 && params.KeyLength != keySize {
+			return nil, errors.New("unsupported encrypted PEM: invalid key length")
+		}
+		if params.BlockSize > 0 && params.CostParameter > maxScryptCost/params.BlockSize {
+			return nil, errors.Errorf("unsupported encrypted PEM: scrypt cost N·r cannot be greater than %d", maxScryptCost)
+		}
+		key, err := scrypt.Key(password, params.Salt, params.CostParameter, params.BlockSize,
+			params.ParallelizationParameter, keySize)
+		return key, errors.Wrap(err, "unsupported encrypted PEM")
+	default:
+		return nil, errors.New("unsupported encrypted PEM: only PBKDF2 and scrypt are supported")
+	}
 }
 
 // EncryptPKCS8PrivateKey returns a PEM block holding the given PKCS#8 encroded
 // private key, encrypted with the specified algorithm and a PBKDF2 derived key
 // from the given password.
 func EncryptPKCS8PrivateKey(rand io.Reader, data, password []byte, alg x509.PEMCipher) (*pem.Block, error) {
+	return encryptPKCS8PrivateKey(rand, data, password, alg, PBKDF2Iterations)
+}
+
+// encryptPKCS8PrivateKey implements EncryptPKCS8PrivateKey with the given
+// number of PBKDF2 iterations.
+func encryptPKCS8PrivateKey(rand io.Reader, data, password []byte, alg x509.PEMCipher, iter int) (*pem.Block, error) {
+	switch {
+	case iter <= 0:
+		iter = PBKDF2Iterations
+	case iter > maxPBKDF2Iterations:
+		return nil, errors.Errorf("failed to encrypt PEM: PBKDF2 iterations cannot be greater than %d", maxPBKDF2Iterations)
+	}
 	ciph := cipherByKey(alg)
 	if ciph == nil {
 		return nil, errors.Errorf("failed to encrypt PEM: unknown algorithm %v", alg)
@@ -384,7 +532,7 @@ func EncryptPKCS8PrivateKey(rand io.Reader, data, password []byte, alg x509.PEMC
 		return nil, errors.Wrap(err, "failed to generate IV")
 	}
 
-	key := ciph.deriveKey(password, salt, sha256.New)
+	key := ciph.deriveKey(password, salt, iter, sha256.New)
 	block, err := ciph.cipherFunc(key)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create cipher")
@@ -403,25 +551,38 @@ func EncryptPKCS8PrivateKey(rand io.Reader, data, password []byte, alg x509.PEMC
 	enc.CryptBlocks(encrypted, encrypted)
 
 	// Build encrypted ans1 data
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: iter,
+		PrfParam: prfParam{
+			Algo:      oidHMACWithSHA256,
+			NullParam: asn1.NullRawValue,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling encrypted key")
+	}
+	encParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling encrypted key")
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPKCS5PBKDF2,
+			Parameters: asn1.RawValue{FullBytes: kdfParams},
+		},
+		EncryptionScheme: pkix.AlgorithmIdentifier{
+			Algorithm:  ciph.identifier,
+			Parameters: asn1.RawValue{FullBytes: encParams},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling encrypted key")
+	}
 	pki := encryptedPrivateKeyInfo{
-		Algo: encryptedlAlgorithmIdentifier{
-			Algorithm: oidPBES2,
-			Parameters: pbes2Params{
-				KeyDerivationFunc: pbkdf2Algorithms{
-					Algo: oidPKCS5PBKDF2,
-					PBKDF2Params: pbkdf2Params{
-						Salt:           salt,
-						IterationCount: PBKDF2Iterations,
-						PrfParam: prfParam{
-							Algo: oidHMACWithSHA256,
-						},
-					},
-				},
-				EncryptionScheme: pbkdf2Encs{
-					EncryAlgo: ciph.identifier,
-					IV:        iv,
-				},
-			},
+		Algo: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBES2,
+			Parameters: asn1.RawValue{FullBytes: params},
 		},
 		PrivateKey: encrypted,
 	}
@@ -1,14 +1,19 @@
 package pemutil
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"io/ioutil"
 	"reflect"
 	"testing"
 
 	"github.com/smallstep/assert"
 	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/scrypt"
 )
 
 func TestEncryptDecryptPKCS8(t *testing.T) {
@@ -148,3 +153,95 @@ func TestMarshalPKCS8PrivateKey(t *testing.T) {
 		})
 	}
 }
+
+func TestDecryptPKCS8PrivateKey_scryptGCM(t *testing.T) {
+	password := []byte("mypassword")
+	key, err := Read("testdata/pkcs8/openssl.p256.pem")
+	assert.FatalError(t, err)
+	data, err := MarshalPKCS8PrivateKey(key)
+	assert.FatalError(t, err)
+
+	salt := make([]byte, 16)
+	nonce := make([]byte, 12)
+	_, err = rand.Read(salt)
+	assert.FatalError(t, err)
+	_, err = rand.Read(nonce)
+	assert.FatalError(t, err)
+
+	symkey, err := scrypt.Key(password, salt, 1<<14, 8, 1, 32)
+	assert.FatalError(t, err)
+	block, err := aes.NewCipher(symkey)
+	assert.FatalError(t, err)
+	aead, err := cipher.NewGCM(block)
+	assert.FatalError(t, err)
+
+	marshal := func(v interface{}) asn1.RawValue {
+		b, err := asn1.Marshal(v)
+		assert.FatalError(t, err)
+		return asn1.RawValue{FullBytes: b}
+	}
+	params := marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm:  oidScrypt,
+			Parameters: marshal(scryptParams{Salt: salt, CostParameter: 1 << 14, BlockSize: 8, ParallelizationParameter: 1}),
+		},
+		EncryptionScheme: pkix.AlgorithmIdentifier{
+			Algorithm:  oidAES256GCM,
+			Parameters: marshal(gcmParams{Nonce: nonce, ICVLen: 16}),
+		},
+	})
+	enc, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algo:       pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: params},
+		PrivateKey: aead.Seal(nil, nonce, data, nil),
+	})
+	assert.FatalError(t, err)
+
+	der, err := DecryptPKCS8PrivateKey(enc, password)
+	assert.FatalError(t, err)
+	assert.Equals(t, data, der)
+
+	_, err = DecryptPKCS8PrivateKey(enc, []byte("badpassword"))
+	assert.Equals(t, x509.IncorrectPasswordError, err)
+}
+
+func TestDeriveKDFKey_limits(t *testing.T) {
+	marshal := func(v interface{}) asn1.RawValue {
+		b, err := asn1.Marshal(v)
+		assert.FatalError(t, err)
+		return asn1.RawValue{FullBytes: b}
+	}
+	pbkdf2KDF := func(iter int) pkix.AlgorithmIdentifier {
+		return pkix.AlgorithmIdentifier{
+			Algorithm:  oidPKCS5PBKDF2,
+			Parameters: marshal(pbkdf2Params{Salt: []byte("salt"), IterationCount: iter}),
+		}
+	}
+	scryptKDF := func(n, r int) pkix.AlgorithmIdentifier {
+		return pkix.AlgorithmIdentifier{
+			Algorithm:  oidScrypt,
+			Parameters: marshal(scryptParams{Salt: []byte("salt"), CostParameter: n, BlockSize: r, ParallelizationParameter: 1}),
+		}
+	}
+	tests := []struct {
+		name    string
+		kdf     pkix.AlgorithmIdentifier
+		wantErr bool
+	}{
+		{"ok pbkdf2", pbkdf2KDF(PBKDF2Iterations), false},
+		{"ok scrypt", scryptKDF(1<<14, 8), false},
+		{"fail pbkdf2 iterations", pbkdf2KDF(maxPBKDF2Iterations + 1), true},
+		{"fail scrypt cost", scryptKDF(1<<20, 8), true},
+		{"fail scrypt block size", scryptKDF(2, 1<<30), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := deriveKDFKey(tt.kdf, []byte("mypassword"), 32)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, 32, key)
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package pemutil
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"github.com/smallstep/cli/utils"
+	"golang.org/x/crypto/ed25519"
+)
+
+// BcryptRounds is the default number of rounds of bcrypt_pbkdf used to encrypt
+// OpenSSH private keys, the same used by ssh-keygen.
+const BcryptRounds = 16
+
+// opensshMagic is the header of the OpenSSH private key format defined in
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.key
+const opensshMagic = "openssh-key-v1\x00"
+
+// opensshCiphers are the supported ciphers of encrypted OpenSSH keys.
+var opensshCiphers = map[string]struct {
+	keySize int
+	ctr     bool
+}{
+	"aes128-ctr": {16, true},
+	"aes192-ctr": {24, true},
+	"aes256-ctr": {32, true},
+	"aes128-cbc": {16, false},
+	"aes192-cbc": {24, false},
+	"aes256-cbc": {32, false},
+}
+
+// ParseOpenSSHPrivateKey parses the PEM encoded OpenSSH private key in the
+// given bytes and returns the key and its comment. If the key is encrypted,
+// the password in the options will be used, or the user will be prompted for
+// it.
+func ParseOpenSSHPrivateKey(b []byte, opts ...Options) (interface{}, string, error) {
+	ctx := newContext("PEM")
+	if err := ctx.apply(opts); err != nil {
+		return nil, "", err
+	}
+	defer ctx.destroy()
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" {
+		return nil, "", errors.Errorf("error decoding %s: is not an OpenSSH private key", ctx.filename)
+	}
+	return parseOpenSSHPrivateKey(block.Bytes, ctx)
+}
+
+func parseOpenSSHPrivateKey(b []byte, ctx *context) (interface{}, string, error) {
+	if !bytes.HasPrefix(b, []byte(opensshMagic)) {
+		return nil, "", errors.Errorf("error parsing %s: invalid OpenSSH private key", ctx.filename)
+	}
+	r := &sshReader{b: b[len(opensshMagic):]}
+	cipherName := string(r.string())
+	kdfName := string(r.string())
+	kdfOptions := r.string()
+	numKeys := r.uint32()
+	r.string() // public key
+	data := r.string()
+	if r.err != nil {
+		return nil, "", errors.Wrapf(r.err, "error parsing %s", ctx.filename)
+	}
+	if numKeys != 1 {
+		return nil, "", errors.Errorf("error parsing %s: multiple keys are not supported", ctx.filename)
+	}
+
+	switch {
+	case cipherName == "none" && kdfName == "none":
+	case kdfName == "bcrypt":
+		c, ok := opensshCiphers[cipherName]
+		if !ok {
+			return nil, "", errors.Errorf("error parsing %s: unsupported cipher %s", ctx.filename, cipherName)
+		}
+		o := &sshReader{b: kdfOptions}
+		salt := o.string()
+		rounds := o.uint32()
+		if o.err != nil {
+			return nil, "", errors.Wrapf(o.err, "error parsing %s", ctx.filename)
+		}
+
+		pass := ctx.password
+		if len(pass) == 0 {
+			var err error
+			if pass, err = ui.PromptPassword(fmt.Sprintf("Please enter the password to decrypt %s", ctx.filename)); err != nil {
+				return nil, "", err
+			}
+		}
+		k, err := bcryptPBKDF(pass, salt, int(rounds), c.keySize+aes.BlockSize)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "error decrypting %s", ctx.filename)
+		}
+		block, err := aes.NewCipher(k[:c.keySize])
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "error decrypting %s", ctx.filename)
+		}
+		if len(data)%aes.BlockSize != 0 {
+			return nil, "", errors.Errorf("error decrypting %s: invalid data length", ctx.filename)
+		}
+		plain := make([]byte, len(data))
+		if c.ctr {
+			cipher.NewCTR(block, k[c.keySize:]).XORKeyStream(plain, data)
+		} else {
+			cipher.NewCBCDecrypter(block, k[c.keySize:]).CryptBlocks(plain, data)
+		}
+		data = plain
+	default:
+		return nil, "", errors.Errorf("error parsing %s: unsupported key derivation function %s", ctx.filename, kdfName)
+	}
+
+	r = &sshReader{b: data}
+	if check1, check2 := r.uint32(), r.uint32(); r.err != nil || check1 != check2 {
+		return nil, "", errors.Errorf("error decrypting %s: x509: decryption password incorrect", ctx.filename)
+	}
+
+	var key interface{}
+	switch keyType := string(r.string()); keyType {
+	case "ssh-ed25519":
+		r.string() // public key
+		priv := r.string()
+		if r.err == nil && len(priv) != ed25519.PrivateKeySize {
+			return nil, "", errors.Errorf("error parsing %s: invalid Ed25519 key", ctx.filename)
+		}
+		key = ed25519.PrivateKey(priv)
+	case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+		curve := opensshCurve(string(r.string()))
+		q := r.string()
+		d := r.mpint()
+		if r.err != nil {
+			break
+		}
+		if curve == nil {
+			return nil, "", errors.Errorf("error parsing %s: unsupported curve", ctx.filename)
+		}
+		x, y := elliptic.Unmarshal(curve, q)
+		if x == nil {
+			return nil, "", errors.Errorf("error parsing %s: invalid ECDSA key", ctx.filename)
+		}
+		key = &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y},
+			D:         d,
+		}
+	case "ssh-rsa":
+		n, e, d := r.mpint(), r.mpint(), r.mpint()
+		r.mpint() // iqmp
+		p, q := r.mpint(), r.mpint()
+		if r.err != nil {
+			break
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, "", errors.Errorf("error parsing %s: invalid RSA key", ctx.filename)
+		}
+		k := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		if err := k.Validate(); err != nil {
+			return nil, "", errors.Wrapf(err, "error parsing %s", ctx.filename)
+		}
+		k.Precompute()
+		key = k
+	default:
+		return nil, "", errors.Errorf("error parsing %s: unsupported key type %s", ctx.filename, keyType)
+	}
+	comment := string(r.string())
+	if r.err != nil {
+		return nil, "", errors.Wrapf(r.err, "error parsing %s", ctx.filename)
+	}
+	for i, c := range r.b {
+		if int(c) != i+1 {
+			return nil, "", errors.Errorf("error parsing %s: invalid padding", ctx.filename)
+		}
+	}
+	return key, comment, nil
+}
+
+// SerializeOpenSSHPrivateKey returns a PEM block with the given private key
+// and comment in the OpenSSH private key format. If a password is given the
+// key is encrypted using aes256-ctr and bcrypt_pbkdf with BcryptRounds, or the
+// rounds set using WithKDFIterations. If a filename is given with ToFile, the
+// block will be written to disk.
+func SerializeOpenSSHPrivateKey(key interface{}, comment string, opts ...Options) (*pem.Block, error) {
+	ctx := new(context)
+	if err := ctx.apply(opts); err != nil {
+		return nil, err
+	}
+	defer ctx.destroy()
+
+	var check [4]byte
+	if _, err := io.ReadFull(rand.Reader, check[:]); err != nil {
+		return nil, errors.Wrap(err, "error generating random bytes")
+	}
+
+	pub, priv := new(sshWriter), new(sshWriter)
+	priv.Write(check[:])
+	priv.Write(check[:])
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		pubKey := k.Public().(ed25519.PublicKey)
+		pub.string([]byte("ssh-ed25519"))
+		pub.string(pubKey)
+		priv.string([]byte("ssh-ed25519"))
+		priv.string(pubKey)
+		priv.string(k)
+	case *ecdsa.PrivateKey:
+		curveName := opensshCurveName(k.Curve)
+		if curveName == "" {
+			return nil, errors.Errorf("unsupported curve %s", k.Curve.Params().Name)
+		}
+		keyType := []byte("ecdsa-sha2-" + curveName)
+		q := elliptic.Marshal(k.Curve, k.X, k.Y)
+		pub.string(keyType)
+		pub.string([]byte(curveName))
+		pub.string(q)
+		priv.string(keyType)
+		priv.string([]byte(curveName))
+		priv.string(q)
+		priv.mpint(k.D)
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, errors.New("multi-prime RSA keys are not supported")
+		}
+		k.Precompute()
+		e := big.NewInt(int64(k.E))
+		pub.string([]byte("ssh-rsa"))
+		pub.mpint(e)
+		pub.mpint(k.N)
+		priv.string([]byte("ssh-rsa"))
+		priv.mpint(k.N)
+		priv.mpint(e)
+		priv.mpint(k.D)
+		priv.mpint(k.Precomputed.Qinv)
+		priv.mpint(k.Primes[0])
+		priv.mpint(k.Primes[1])
+	default:
+		return nil, errors.Errorf("cannot serialize type '%T' as an OpenSSH private key", key)
+	}
+	priv.string([]byte(comment))
+
+	cipherName, kdfName := "none", "none"
+	kdfOptions := new(sshWriter)
+	blockSize := 8
+	if ctx.password != nil {
+		cipherName, kdfName = "aes256-ctr", "bcrypt"
+		blockSize = aes.BlockSize
+	}
+	for i := 1; priv.Len()%blockSize != 0; i++ {
+		priv.WriteByte(byte(i))
+	}
+	data := priv.Bytes()
+
+	if ctx.password != nil {
+		rounds := ctx.iterations
+		if rounds == 0 {
+			rounds = BcryptRounds
+		}
+		salt := make([]byte, 16)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, errors.Wrap(err, "error generating salt")
+		}
+		kdfOptions.string(salt)
+		kdfOptions.uint32(uint32(rounds))
+
+		k, err := bcryptPBKDF(ctx.password, salt, rounds, 32+aes.BlockSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "error encrypting key")
+		}
+		block, err := aes.NewCipher(k[:32])
+		if err != nil {
+			return nil, errors.Wrap(err, "error encrypting key")
+		}
+		cipher.NewCTR(block, k[32:]).XORKeyStream(data, data)
+	}
+
+	w := new(sshWriter)
+	w.WriteString(opensshMagic)
+	w.string([]byte(cipherName))
+	w.string([]byte(kdfName))
+	w.string(kdfOptions.Bytes())
+	w.uint32(1)
+	w.string(pub.Bytes())
+	w.string(data)
+
+	p := &pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: w.Bytes(),
+	}
+	if ctx.filename != "" {
+		if err := utils.WriteFile(ctx.filename, pem.EncodeToMemory(p), ctx.perm); err != nil {
+			return nil, errs.FileError(err, ctx.filename)
+		}
+	}
+	return p, nil
+}
+
+func opensshCurve(name string) elliptic.Curve {
+	switch name {
+	case "nistp256":
+		return elliptic.P256()
+	case "nistp384":
+		return elliptic.P384()
+	case "nistp521":
+		return elliptic.P521()
+	default:
+		return nil
+	}
+}
+
+func opensshCurveName(c elliptic.Curve) string {
+	switch c {
+	case elliptic.P256():
+		return "nistp256"
+	case elliptic.P384():
+		return "nistp384"
+	case elliptic.P521():
+		return "nistp521"
+	default:
+		return ""
+	}
+}
+
+// sshReader reads the SSH wire encoding defined in RFC 4251. The first error
+// is stored and all the following reads return zero values.
+type sshReader struct {
+	b   []byte
+	err error
+}
+
+func (r *sshReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.b) < 4 {
+		r.err = errors.New("unexpected end of data")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *sshReader) string() []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+	if uint32(len(r.b)) < n {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *sshReader) mpint() *big.Int {
+	b := r.string()
+	if r.err != nil {
+		return nil
+	}
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		r.err = errors.New("negative integers are not supported")
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// sshWriter writes the SSH wire encoding defined in RFC 4251.
+type sshWriter struct {
+	bytes.Buffer
+}
+
+func (w *sshWriter) uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.Write(b[:])
+}
+
+func (w *sshWriter) string(b []byte) {
+	w.uint32(uint32(len(b)))
+	w.Write(b)
+}
+
+func (w *sshWriter) mpint(x *big.Int) {
+	b := x.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	w.string(b)
+}
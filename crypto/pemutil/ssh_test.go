@@ -0,0 +1,79 @@
+package pemutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/pem"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestParseOpenSSHPrivateKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		typ     interface{}
+	}{
+		{"openssh.ed25519", "ed25519@example", ed25519.PrivateKey{}},
+		{"openssh.p256", "p256@example", &ecdsa.PrivateKey{}},
+		{"openssh.rsa2048", "rsa2048@example", &rsa.PrivateKey{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := ioutil.ReadFile("testdata/openssh/" + tc.name)
+			assert.FatalError(t, err)
+			key, comment, err := ParseOpenSSHPrivateKey(b)
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.comment, comment)
+			assert.Equals(t, reflect.TypeOf(tc.typ), reflect.TypeOf(key))
+
+			b, err = ioutil.ReadFile("testdata/openssh/" + tc.name + ".enc")
+			assert.FatalError(t, err)
+			encKey, comment, err := ParseOpenSSHPrivateKey(b, WithPassword([]byte("mypassword")))
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.comment, comment)
+			assert.Equals(t, reflect.TypeOf(tc.typ), reflect.TypeOf(encKey))
+
+			_, _, err = ParseOpenSSHPrivateKey(b, WithPassword([]byte("badpassword")))
+			assert.Error(t, err)
+
+			// Parse also supports OpenSSH keys
+			k, err := Parse(b, WithPassword([]byte("mypassword")))
+			assert.FatalError(t, err)
+			assert.Equals(t, encKey, k)
+		})
+	}
+}
+
+func TestSerializeOpenSSHPrivateKey(t *testing.T) {
+	for _, name := range []string{"openssh.ed25519", "openssh.p256", "openssh.rsa2048"} {
+		t.Run(name, func(t *testing.T) {
+			b, err := ioutil.ReadFile("testdata/openssh/" + name)
+			assert.FatalError(t, err)
+			key, comment, err := ParseOpenSSHPrivateKey(b)
+			assert.FatalError(t, err)
+
+			p, err := SerializeOpenSSHPrivateKey(key, comment)
+			assert.FatalError(t, err)
+			assert.Equals(t, "OPENSSH PRIVATE KEY", p.Type)
+			k, c, err := ParseOpenSSHPrivateKey(pem.EncodeToMemory(p))
+			assert.FatalError(t, err)
+			assert.Equals(t, comment, c)
+			assert.Equals(t, key, k)
+
+			p, err = SerializeOpenSSHPrivateKey(key, comment, WithPassword([]byte("newpassword")), WithKDFIterations(4))
+			assert.FatalError(t, err)
+			k, c, err = ParseOpenSSHPrivateKey(pem.EncodeToMemory(p), WithPassword([]byte("newpassword")))
+			assert.FatalError(t, err)
+			assert.Equals(t, comment, c)
+			assert.Equals(t, key, k)
+
+			_, _, err = ParseOpenSSHPrivateKey(pem.EncodeToMemory(p), WithPassword([]byte("mypassword")))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -31,6 +31,7 @@ $OPENSSL pkey -outform PEM -in pkcs8/openssl.ed25519.pem -aes-192-cbc -passout p
 $OPENSSL pkey -outform PEM -in pkcs8/openssl.rsa2048.pem -aes-256-cbc -passout pass:mypassword  -out pkcs8/openssl.rsa2048.enc.pem
 $OPENSSL pkey -outform PEM -in pkcs8/openssl.p384.pem -des -passout pass:mypassword -out pkcs8/openssl.p384.enc.pem
 $OPENSSL pkey -outform PEM -in pkcs8/openssl.p521.pem -des3 -passout pass:mypassword -out pkcs8/openssl.p521.enc.pem
+$OPENSSL pkcs8 -topk8 -scrypt -in pkcs8/openssl.rsa4096.pem -passout pass:mypassword -out pkcs8/openssl.rsa4096.scrypt.enc.pem
 
 # Ed25519 DER
 $OPENSSL pkey -outform DER -in pkcs8/openssl.ed25519.pem -out pkcs8/openssl.ed25519.der
@@ -65,4 +66,14 @@ $OPENSSL ec -outform PEM -in openssl.p521.pem -pubout -out openssl.p521.pub.pem
 
 $OPENSSL ec -outform PEM -in openssl.p256.pem -aes-256-cbc -passout pass:mypassword -out openssl.p256.enc.pem
 $OPENSSL ec -outform PEM -in openssl.p384.pem -des -passout pass:mypassword -out openssl.p384.enc.pem
-$OPENSSL ec -outform PEM -in openssl.p521.pem -des3 -passout pass:mypassword -out openssl.p521.enc.pem
\ No newline at end of file
+$OPENSSL ec -outform PEM -in openssl.p521.pem -des3 -passout pass:mypassword -out openssl.p521.enc.pem
+#######################################
+# OpenSSH                             #
+#######################################
+
+ssh-keygen -q -t ed25519 -N "" -C "ed25519@example" -f openssh/openssh.ed25519
+ssh-keygen -q -t ecdsa -N "" -C "p256@example" -f openssh/openssh.p256
+ssh-keygen -q -t rsa -N "" -C "rsa2048@example" -f openssh/openssh.rsa2048
+ssh-keygen -q -t ed25519 -N "mypassword" -C "ed25519@example" -f openssh/openssh.ed25519.enc
+ssh-keygen -q -t ecdsa -N "mypassword" -C "p256@example" -f openssh/openssh.p256.enc
+ssh-keygen -q -t rsa -N "mypassword" -C "rsa2048@example" -f openssh/openssh.rsa2048.enc
@@ -2,12 +2,14 @@ package pki
 
 import (
 	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -23,6 +25,7 @@ import (
 	"github.com/smallstep/cli/crypto/x509util"
 	"github.com/smallstep/cli/errs"
 	"github.com/smallstep/cli/jose"
+	"github.com/smallstep/cli/trace"
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 )
@@ -78,12 +81,30 @@ func GetOTTKeyPath() string {
 	return filepath.Join(config.StepPath(), privatePath, "ott_key")
 }
 
+// withRootFile returns a ca.ClientOption that trusts the roots in the given
+// file and sends the requests through trace.Transport, so they are traced and
+// use the network options of the netconfig package.
+func withRootFile(rootFile string) ca.ClientOption {
+	pool, err := x509util.ReadCertPool(rootFile)
+	if err != nil {
+		// ca.NewClient will report the error
+		return ca.WithRootFile(rootFile)
+	}
+	return ca.WithTransport(trace.Transport(&http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{
+			RootCAs:                  pool,
+			PreferServerCipherSuites: true,
+		},
+	}))
+}
+
 // GetProvisioners returns the map of provisioners on the given CA.
 func GetProvisioners(caURL, rootFile string) (provisioner.List, error) {
 	if len(rootFile) == 0 {
 		rootFile = GetRootCAPath()
 	}
-	client, err := ca.NewClient(caURL, ca.WithRootFile(rootFile))
+	client, err := ca.NewClient(caURL, withRootFile(rootFile))
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +129,7 @@ func GetProvisionerKey(caURL, rootFile, kid string) (string, error) {
 	if len(rootFile) == 0 {
 		rootFile = GetRootCAPath()
 	}
-	client, err := ca.NewClient(caURL, ca.WithRootFile(rootFile))
+	client, err := ca.NewClient(caURL, withRootFile(rootFile))
 	if err != nil {
 		return "", err
 	}
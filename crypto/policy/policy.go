@@ -0,0 +1,394 @@
+// Package policy implements the local policy of step, the file
+// $STEPPATH/policy.json. The policy restricts what the commands can do in
+// this machine: the algorithms, key types and curves that can be used, the
+// minimum size of the RSA keys, the wildcard SANs and the validity of the
+// certificates created locally, and the output of private keys without
+// encryption.
+//
+// A command that does not follow the policy fails. Unless the policy is
+// enforced, the --subtle flag turns the failures into warnings.
+package policy
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/ui"
+	"golang.org/x/crypto/ed25519"
+)
+
+// FileName is the name of the policy file in the step path.
+const FileName = "policy.json"
+
+// legacyFileName is the name of the file in $STEPPATH/config with the policy
+// of the certificates, it's used if the policy file does not exist.
+const legacyFileName = "certificate-policy.json"
+
+// Default maximum validities of the certificates created locally. The leaf
+// maximum is the one allowed for publicly trusted TLS certificates.
+const (
+	DefaultMaxLeafDuration = 398 * 24 * time.Hour
+	DefaultMaxCADuration   = 10 * 365 * 24 * time.Hour
+)
+
+// defaultRSASize is the size used when an RSA key is generated without an
+// explicit size.
+const defaultRSASize = 2048
+
+// Policy is the local policy.
+type Policy struct {
+	// Enforce disables the --subtle flag, a command that does not follow the
+	// policy always fails.
+	Enforce bool `json:"enforce,omitempty"`
+	// ForbiddenAlgorithms is the list of algorithms, key types, curves, hash
+	// functions and key derivation functions that cannot be used, e.g. "RS256",
+	// "OKP", "P-521", "sha1" or "scrypt". The names are case insensitive.
+	ForbiddenAlgorithms []string `json:"forbiddenAlgorithms,omitempty"`
+	// MinRSASize is the minimum size in bits of the RSA keys.
+	MinRSASize int `json:"minRSASize,omitempty"`
+	// RequireKeyEncryption forbids writing private keys without a password.
+	RequireKeyEncryption bool `json:"requireKeyEncryption,omitempty"`
+	// AllowWildcards allows wildcard SANs in the certificates.
+	AllowWildcards bool `json:"allowWildcards,omitempty"`
+	// MaxLeafDuration and MaxCADuration are the maximum validities of the leaf
+	// and CA certificates. A zero duration disables the check.
+	MaxLeafDuration Duration `json:"maxLeafDuration"`
+	MaxCADuration   Duration `json:"maxCADuration"`
+}
+
+// Duration is a duration encoded in JSON as a string, e.g. "720h".
+type Duration time.Duration
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.Errorf("invalid duration %s", data)
+	}
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.Wrapf(err, "error parsing duration %s", s)
+	}
+	*d = Duration(dur)
+	return nil
+}
+
+// Default returns the policy used if there is no policy file.
+func Default() *Policy {
+	return &Policy{
+		MaxLeafDuration: Duration(DefaultMaxLeafDuration),
+		MaxCADuration:   Duration(DefaultMaxCADuration),
+	}
+}
+
+// Path returns the path of the policy file.
+func Path() string {
+	return filepath.Join(config.StepPath(), FileName)
+}
+
+// Parse parses and validates a policy. The fields that are not in the JSON
+// have the values of the default policy.
+func Parse(b []byte) (*Policy, error) {
+	p := Default()
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Read reads and validates the policy in the given file.
+func Read(filename string) (*Policy, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errs.FileError(err, filename)
+	}
+	p, err := Parse(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	return p, nil
+}
+
+// Validate returns an error if the policy has invalid values.
+func (p *Policy) Validate() error {
+	for _, name := range p.ForbiddenAlgorithms {
+		if !knownAlgorithm(name) {
+			return errors.Errorf("unknown algorithm %q in forbiddenAlgorithms", name)
+		}
+	}
+	switch {
+	case p.MinRSASize < 0:
+		return errors.Errorf("invalid minRSASize %d, it cannot be negative", p.MinRSASize)
+	case p.MaxLeafDuration < 0:
+		return errors.Errorf("invalid maxLeafDuration %s, it cannot be negative", time.Duration(p.MaxLeafDuration))
+	case p.MaxCADuration < 0:
+		return errors.Errorf("invalid maxCADuration %s, it cannot be negative", time.Duration(p.MaxCADuration))
+	}
+	return nil
+}
+
+var (
+	loadOnce   sync.Once
+	current    *Policy
+	currentErr error
+	subtle     int32
+)
+
+// Load returns the policy in the policy file, or in the legacy file
+// $STEPPATH/config/certificate-policy.json, or the default policy if none of
+// them exist. The policy is read only once.
+func Load() (*Policy, error) {
+	loadOnce.Do(func() {
+		for _, filename := range []string{Path(), filepath.Join(config.StepPath(), "config", legacyFileName)} {
+			if _, err := os.Stat(filename); err == nil {
+				current, currentErr = Read(filename)
+				return
+			}
+		}
+		current = Default()
+	})
+	return current, currentErr
+}
+
+// Set sets the policy used by the package functions instead of the one in the
+// policy file.
+func Set(p *Policy) {
+	loadOnce.Do(func() {})
+	current, currentErr = p, nil
+}
+
+// SetSubtle sets if the --subtle flag was passed. If the policy is not
+// enforced, the violations are printed as warnings instead of errors.
+func SetSubtle(v bool) {
+	if v {
+		atomic.StoreInt32(&subtle, 1)
+	} else {
+		atomic.StoreInt32(&subtle, 0)
+	}
+}
+
+func isSubtle() bool {
+	return atomic.LoadInt32(&subtle) == 1
+}
+
+// check returns an error with the given violations of the policy, or prints
+// them as warnings if the --subtle flag is used and the policy is not
+// enforced.
+func check(p *Policy, violations ...string) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	if isSubtle() && !p.Enforce {
+		for _, v := range violations {
+			ui.Warnf("%s", v)
+		}
+		return nil
+	}
+	msg := "local policy violation: " + strings.Join(violations, "; ")
+	if !p.Enforce {
+		msg += "; use the '--subtle' flag to ignore it"
+	}
+	return errs.Policy(errors.New(msg))
+}
+
+// CheckKeyType returns an error if keys of the given type (kty), curve and
+// size cannot be generated. A zero size is the default RSA size.
+func CheckKeyType(kty, crv string, size int) error {
+	p, err := Load()
+	if err != nil {
+		return err
+	}
+	return check(p, p.keyTypeViolations(kty, crv, size)...)
+}
+
+// CheckKey returns an error if the given public or private key cannot be
+// used.
+func CheckKey(key interface{}) error {
+	p, err := Load()
+	if err != nil {
+		return err
+	}
+	return check(p, p.keyViolations(key)...)
+}
+
+// CheckAlgorithm returns an error if the algorithm with the given name cannot
+// be used.
+func CheckAlgorithm(name string) error {
+	p, err := Load()
+	if err != nil {
+		return err
+	}
+	if p.forbidden(name) {
+		return check(p, fmt.Sprintf("the algorithm %s is forbidden", name))
+	}
+	return nil
+}
+
+// CheckKeyEncryption returns an error if private keys cannot be written
+// without a password.
+func CheckKeyEncryption() error {
+	p, err := Load()
+	if err != nil {
+		return err
+	}
+	if p.RequireKeyEncryption {
+		return check(p, "private keys must be encrypted with a password")
+	}
+	return nil
+}
+
+// CheckCertificate returns an error if the given certificate template cannot
+// be signed.
+func CheckCertificate(crt *x509.Certificate, isCA bool) error {
+	p, err := Load()
+	if err != nil {
+		return err
+	}
+	return check(p, p.CertificateViolations(crt, isCA)...)
+}
+
+// forbidden returns true if the algorithm with the given name is forbidden.
+func (p *Policy) forbidden(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, s := range p.ForbiddenAlgorithms {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyTypeViolations returns the reasons why keys of the given type, curve and
+// size cannot be used.
+func (p *Policy) keyTypeViolations(kty, crv string, size int) []string {
+	var violations []string
+	if p.forbidden(kty) {
+		violations = append(violations, fmt.Sprintf("the key type %s is forbidden", kty))
+	}
+	if p.forbidden(crv) {
+		violations = append(violations, fmt.Sprintf("the curve %s is forbidden", crv))
+	}
+	if kty == "RSA" {
+		if size == 0 {
+			size = defaultRSASize
+		}
+		if size < p.MinRSASize {
+			violations = append(violations, fmt.Sprintf("the RSA key size %d is smaller than %d", size, p.MinRSASize))
+		}
+	}
+	return violations
+}
+
+// keyViolations returns the reasons why the given key cannot be used.
+func (p *Policy) keyViolations(key interface{}) []string {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return p.keyTypeViolations("RSA", "", k.N.BitLen())
+	case *rsa.PrivateKey:
+		return p.keyViolations(&k.PublicKey)
+	case *ecdsa.PublicKey:
+		return p.keyTypeViolations("EC", k.Curve.Params().Name, 0)
+	case *ecdsa.PrivateKey:
+		return p.keyViolations(&k.PublicKey)
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return p.keyTypeViolations("OKP", "Ed25519", 0)
+	case []byte:
+		return p.keyTypeViolations("oct", "", 0)
+	case crypto.Signer:
+		return p.keyViolations(k.Public())
+	default:
+		return nil
+	}
+}
+
+// CertificateViolations returns the reasons why the certificate template does
+// not follow the policy.
+func (p *Policy) CertificateViolations(crt *x509.Certificate, isCA bool) []string {
+	var violations []string
+	for _, name := range crt.DNSNames {
+		if !strings.Contains(name, "*") {
+			continue
+		}
+		rest := strings.TrimPrefix(name, "*.")
+		switch {
+		case rest == name || strings.Contains(rest, "*"):
+			violations = append(violations, fmt.Sprintf("the SAN %s is not a valid wildcard, clients only accept '*' as the full leftmost label", name))
+		case !strings.Contains(rest, "."):
+			violations = append(violations, fmt.Sprintf("the wildcard SAN %s matches every name in the top-level domain %s", name, rest))
+		case !p.AllowWildcards:
+			violations = append(violations, fmt.Sprintf("the wildcard SAN %s matches every name in %s, a compromised key can impersonate all of them", name, rest))
+		}
+	}
+
+	max := time.Duration(p.MaxLeafDuration)
+	if isCA {
+		max = time.Duration(p.MaxCADuration)
+	}
+	if d := crt.NotAfter.Sub(crt.NotBefore); max > 0 && d > max {
+		violations = append(violations, fmt.Sprintf("the validity of the certificate, %s, is longer than %s", d, max))
+	}
+
+	if crt.PublicKey != nil {
+		violations = append(violations, p.keyViolations(crt.PublicKey)...)
+	}
+	return violations
+}
+
+// algorithms are the names that can be used in ForbiddenAlgorithms.
+var algorithms = []string{
+	// Key types and curves
+	"EC", "RSA", "OKP", "oct", "P-256", "P-384", "P-521", "Ed25519", "X25519",
+	// JOSE signature algorithms
+	"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "ES256", "ES384",
+	"ES512", "PS256", "PS384", "PS512", "EdDSA",
+	// JOSE key management and content encryption algorithms
+	"RSA1_5", "RSA-OAEP", "RSA-OAEP-256", "A128KW", "A192KW", "A256KW", "dir",
+	"ECDH-ES", "ECDH-ES+A128KW", "ECDH-ES+A192KW", "ECDH-ES+A256KW",
+	"A128GCMKW", "A192GCMKW", "A256GCMKW", "PBES2-HS256+A128KW",
+	"PBES2-HS384+A192KW", "PBES2-HS512+A256KW", "A128CBC-HS256",
+	"A192CBC-HS384", "A256CBC-HS512", "A128GCM", "A192GCM", "A256GCM",
+	// Hash functions
+	"md5", "sha1", "sha224", "sha256", "sha384", "sha512", "sha512-224",
+	"sha512-256",
+	// Key derivation functions
+	"scrypt", "bcrypt", "argon2i", "argon2id", "pbkdf2",
+	// NaCl constructions
+	"NaCl",
+}
+
+// knownAlgorithm returns true if the given name is a valid algorithm name.
+func knownAlgorithm(name string) bool {
+	for _, s := range algorithms {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	return false
+}
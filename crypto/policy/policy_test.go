@@ -0,0 +1,151 @@
+package policy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    *Policy
+		wantErr bool
+	}{
+		{"empty", `{}`, Default(), false},
+		{"full", `{"enforce":true,"forbiddenAlgorithms":["sha1","ed25519"],"minRSASize":3072,"requireKeyEncryption":true,"allowWildcards":true,"maxLeafDuration":"720h","maxCADuration":"0s"}`, &Policy{
+			Enforce:              true,
+			ForbiddenAlgorithms:  []string{"sha1", "ed25519"},
+			MinRSASize:           3072,
+			RequireKeyEncryption: true,
+			AllowWildcards:       true,
+			MaxLeafDuration:      Duration(720 * time.Hour),
+			MaxCADuration:        0,
+		}, false},
+		{"fail unknown key", `{"allowWildcard":true}`, nil, true},
+		{"fail unknown algorithm", `{"forbiddenAlgorithms":["rot13"]}`, nil, true},
+		{"fail negative size", `{"minRSASize":-1}`, nil, true},
+		{"fail negative duration", `{"maxLeafDuration":"-1h"}`, nil, true},
+		{"fail bad duration", `{"maxCADuration":"1y"}`, nil, true},
+		{"fail json", `{`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Enforce != tt.want.Enforce || got.MinRSASize != tt.want.MinRSASize ||
+				got.RequireKeyEncryption != tt.want.RequireKeyEncryption || got.AllowWildcards != tt.want.AllowWildcards ||
+				got.MaxLeafDuration != tt.want.MaxLeafDuration || got.MaxCADuration != tt.want.MaxCADuration ||
+				len(got.ForbiddenAlgorithms) != len(tt.want.ForbiddenAlgorithms) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_keyTypeViolations(t *testing.T) {
+	p := &Policy{
+		ForbiddenAlgorithms: []string{"OKP", "p-521"},
+		MinRSASize:          3072,
+	}
+	tests := []struct {
+		kty, crv string
+		size     int
+		want     int
+	}{
+		{"EC", "P-256", 0, 0},
+		{"EC", "P-521", 0, 1},
+		{"OKP", "Ed25519", 0, 1},
+		{"RSA", "", 4096, 0},
+		{"RSA", "", 3072, 0},
+		{"RSA", "", 2048, 1},
+		{"RSA", "", 0, 1},
+		{"oct", "", 32, 0},
+	}
+	for _, tt := range tests {
+		if got := p.keyTypeViolations(tt.kty, tt.crv, tt.size); len(got) != tt.want {
+			t.Errorf("keyTypeViolations(%q, %q, %d) = %v, want %d violations", tt.kty, tt.crv, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_CertificateViolations(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	day := 24 * time.Hour
+	tests := []struct {
+		name   string
+		policy *Policy
+		crt    *x509.Certificate
+		isCA   bool
+		want   int
+	}{
+		{"ok", Default(), &x509.Certificate{DNSNames: []string{"example.com"}, NotBefore: now, NotAfter: now.Add(day)}, false, 0},
+		{"ok ca", Default(), &x509.Certificate{NotBefore: now, NotAfter: now.Add(5 * 365 * day)}, true, 0},
+		{"ok wildcard", &Policy{AllowWildcards: true}, &x509.Certificate{DNSNames: []string{"*.example.com"}, NotBefore: now, NotAfter: now.Add(day)}, false, 0},
+		{"ok no max", &Policy{}, &x509.Certificate{NotBefore: now, NotAfter: now.Add(100 * 365 * day)}, false, 0},
+		{"wildcard", Default(), &x509.Certificate{DNSNames: []string{"*.example.com"}, NotBefore: now, NotAfter: now.Add(day)}, false, 1},
+		{"invalid wildcard", &Policy{AllowWildcards: true}, &x509.Certificate{DNSNames: []string{"*.*.example.com", "*.com"}, NotBefore: now, NotAfter: now.Add(day)}, false, 2},
+		{"long leaf", Default(), &x509.Certificate{NotBefore: now, NotAfter: now.Add(400 * day)}, false, 1},
+		{"long ca", Default(), &x509.Certificate{NotBefore: now, NotAfter: now.Add(11 * 365 * day)}, true, 1},
+		{"forbidden key", &Policy{ForbiddenAlgorithms: []string{"P-384"}}, &x509.Certificate{NotBefore: now, NotAfter: now.Add(day), PublicKey: key.Public()}, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.CertificateViolations(tt.crt, tt.isCA); len(got) != tt.want {
+				t.Errorf("Policy.CertificateViolations() = %v, want %d violations", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	defer SetSubtle(false)
+	tests := []struct {
+		name    string
+		policy  *Policy
+		subtle  bool
+		wantErr bool
+	}{
+		{"fail", &Policy{RequireKeyEncryption: true}, false, true},
+		{"fail enforced", &Policy{RequireKeyEncryption: true, Enforce: true}, true, true},
+		{"ok subtle", &Policy{RequireKeyEncryption: true}, true, false},
+		{"ok", &Policy{}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Set(tt.policy)
+			SetSubtle(tt.subtle)
+			if err := CheckKeyEncryption(); (err != nil) != tt.wantErr {
+				t.Errorf("CheckKeyEncryption() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckAlgorithm(t *testing.T) {
+	Set(&Policy{ForbiddenAlgorithms: []string{"HS256", "md5"}})
+	defer Set(Default())
+	for _, name := range []string{"hs256", "HS256", "MD5"} {
+		if err := CheckAlgorithm(name); err == nil {
+			t.Errorf("CheckAlgorithm(%q) error = nil, want an error", name)
+		}
+	}
+	for _, name := range []string{"ES256", "sha256", ""} {
+		if err := CheckAlgorithm(name); err != nil {
+			t.Errorf("CheckAlgorithm(%q) error = %v, want nil", name, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// +build pqc
+
+package pqc
+
+import (
+	"encoding/asn1"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem1024"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem512"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"github.com/pkg/errors"
+)
+
+// Enabled reports whether the post-quantum algorithms are supported, they
+// are enabled in the builds with the pqc tag.
+const Enabled = true
+
+type algorithm struct {
+	name string
+	oid  asn1.ObjectIdentifier
+	sig  sign.Scheme
+	kem  kem.Scheme
+}
+
+// algorithms is the list of supported parameter sets, the object identifiers
+// are defined in https://csrc.nist.gov/projects/computer-security-objects-register/algorithm-registration
+var algorithms = []*algorithm{
+	{name: MLDSA44, oid: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 17}, sig: mldsa44.Scheme()},
+	{name: MLDSA65, oid: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 18}, sig: mldsa65.Scheme()},
+	{name: MLDSA87, oid: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 19}, sig: mldsa87.Scheme()},
+	{name: MLKEM512, oid: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 4, 1}, kem: mlkem512.Scheme()},
+	{name: MLKEM768, oid: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 4, 2}, kem: mlkem768.Scheme()},
+	{name: MLKEM1024, oid: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 4, 3}, kem: mlkem1024.Scheme()},
+}
+
+func (a *algorithm) isSignature() bool {
+	return a.sig != nil
+}
+
+func (a *algorithm) seedSize() int {
+	if a.sig != nil {
+		return a.sig.SeedSize()
+	}
+	return a.kem.SeedSize()
+}
+
+func (a *algorithm) publicKeySize() int {
+	if a.sig != nil {
+		return a.sig.PublicKeySize()
+	}
+	return a.kem.PublicKeySize()
+}
+
+// deriveKey returns the encoded public key and the private key derived from
+// the seed.
+func (a *algorithm) deriveKey(seed []byte) ([]byte, interface{}, error) {
+	if a.sig != nil {
+		pk, sk := a.sig.DeriveKey(seed)
+		raw, err := pk.MarshalBinary()
+		return raw, sk, err
+	}
+	pk, sk := a.kem.DeriveKeyPair(seed)
+	raw, err := pk.MarshalBinary()
+	return raw, sk, err
+}
+
+func (a *algorithm) sign(sk interface{}, message []byte) []byte {
+	return a.sig.Sign(sk.(sign.PrivateKey), message, nil)
+}
+
+func (a *algorithm) verify(raw, message, sig []byte) bool {
+	pk, err := a.sig.UnmarshalBinaryPublicKey(raw)
+	if err != nil {
+		return false
+	}
+	return a.sig.Verify(pk, message, sig, nil)
+}
+
+func (a *algorithm) encapsulate(raw []byte) ([]byte, []byte, error) {
+	pk, err := a.kem.UnmarshalBinaryPublicKey(raw)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error parsing %s public key", a.name)
+	}
+	return a.kem.Encapsulate(pk)
+}
+
+func (a *algorithm) decapsulate(sk interface{}, ciphertext []byte) ([]byte, error) {
+	return a.kem.Decapsulate(sk.(kem.PrivateKey), ciphertext)
+}
@@ -0,0 +1,56 @@
+// +build !pqc
+
+package pqc
+
+import (
+	"encoding/asn1"
+
+	"github.com/pkg/errors"
+)
+
+// Enabled reports whether the post-quantum algorithms are supported, they
+// are enabled in the builds with the pqc tag.
+const Enabled = false
+
+type algorithm struct {
+	name string
+	oid  asn1.ObjectIdentifier
+}
+
+// algorithms is empty in the builds without the pqc tag, so the methods of
+// algorithm are never called.
+var algorithms []*algorithm
+
+var errDisabled = errors.New("post-quantum algorithms are not supported: step was built without the pqc tag")
+
+func (a *algorithm) isSignature() bool {
+	return false
+}
+
+func (a *algorithm) seedSize() int {
+	return 0
+}
+
+func (a *algorithm) publicKeySize() int {
+	return 0
+}
+
+func (a *algorithm) deriveKey(seed []byte) ([]byte, interface{}, error) {
+	return nil, nil, errDisabled
+}
+
+func (a *algorithm) sign(sk interface{}, message []byte) []byte {
+	return nil
+}
+
+func (a *algorithm) verify(raw, message, sig []byte) bool {
+	return false
+}
+
+func (a *algorithm) encapsulate(raw []byte) ([]byte, []byte, error) {
+	return nil, nil, errDisabled
+}
+
+func (a *algorithm) decapsulate(sk interface{}, ciphertext []byte) ([]byte, error) {
+	return nil, errDisabled
+}
@@ -0,0 +1,275 @@
+// Package pqc implements experimental support for the post-quantum algorithms
+// ML-DSA (FIPS 204) and ML-KEM (FIPS 203). The keys are represented using the
+// seed defined in the standards, and they are encoded in PKIX and PKCS#8 using
+// the object identifiers assigned by NIST.
+//
+// The implementation of the algorithms is provided by
+// github.com/cloudflare/circl, and it is only included in the builds with the
+// pqc tag, circl requires a newer Go than the rest of step. Without the tag no
+// algorithms are supported. The support is experimental and the formats might
+// change as the standards for its use in X.509 and JOSE are finalized.
+package pqc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/asn1"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// KeyType is the key type used for the post-quantum keys. It corresponds to
+// the "AKP" (Algorithm Key Pair) JWK key type, where the parameter set is
+// defined by the algorithm.
+const KeyType = "AKP"
+
+// Names of the supported parameter sets.
+const (
+	MLDSA44   = "ML-DSA-44"
+	MLDSA65   = "ML-DSA-65"
+	MLDSA87   = "ML-DSA-87"
+	MLKEM512  = "ML-KEM-512"
+	MLKEM768  = "ML-KEM-768"
+	MLKEM1024 = "ML-KEM-1024"
+)
+
+// DefaultAlgorithm is the default parameter set.
+const DefaultAlgorithm = MLDSA65
+
+func lookup(name string) (*algorithm, error) {
+	for _, a := range algorithms {
+		if a.name == name {
+			return a, nil
+		}
+	}
+	if !Enabled {
+		return nil, errors.Errorf("unsupported post-quantum algorithm %s: step was built without the pqc tag", name)
+	}
+	return nil, errors.Errorf("unsupported post-quantum algorithm %s", name)
+}
+
+// Algorithms returns the names of the supported parameter sets.
+func Algorithms() []string {
+	names := make([]string, len(algorithms))
+	for i, a := range algorithms {
+		names[i] = a.name
+	}
+	return names
+}
+
+// IsSignatureAlgorithm returns true if the given name is an ML-DSA parameter
+// set.
+func IsSignatureAlgorithm(name string) bool {
+	a, err := lookup(name)
+	return err == nil && a.isSignature()
+}
+
+// OID returns the object identifier of the given parameter set.
+func OID(name string) (asn1.ObjectIdentifier, error) {
+	a, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return a.oid, nil
+}
+
+// AlgorithmByOID returns the name of the parameter set with the given object
+// identifier.
+func AlgorithmByOID(oid asn1.ObjectIdentifier) (string, bool) {
+	for _, a := range algorithms {
+		if a.oid.Equal(oid) {
+			return a.name, true
+		}
+	}
+	return "", false
+}
+
+// PublicKey is an ML-DSA or ML-KEM public key.
+type PublicKey struct {
+	alg *algorithm
+	raw []byte
+}
+
+// NewPublicKey returns the public key of the given parameter set with the
+// given encoding.
+func NewPublicKey(name string, b []byte) (*PublicKey, error) {
+	a, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != a.publicKeySize() {
+		return nil, errors.Errorf("invalid %s public key: unexpected size", name)
+	}
+	return &PublicKey{alg: a, raw: append([]byte{}, b...)}, nil
+}
+
+// Algorithm returns the name of the parameter set of the key.
+func (p *PublicKey) Algorithm() string {
+	return p.alg.name
+}
+
+// Bytes returns the encoding of the public key.
+func (p *PublicKey) Bytes() []byte {
+	return append([]byte{}, p.raw...)
+}
+
+// Equal reports whether p and x have the same value.
+func (p *PublicKey) Equal(x crypto.PublicKey) bool {
+	xx, ok := x.(*PublicKey)
+	return ok && p.alg == xx.alg && bytes.Equal(p.raw, xx.raw)
+}
+
+// PrivateKey is an ML-DSA or ML-KEM private key. It implements crypto.Signer,
+// but only ML-DSA keys can sign.
+type PrivateKey struct {
+	pub  *PublicKey
+	seed []byte
+	sk   interface{}
+}
+
+// GenerateKey generates a new key of the given parameter set.
+func GenerateKey(name string) (*PrivateKey, error) {
+	a, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	seed := make([]byte, a.seedSize())
+	if _, err := io.ReadFull(rand.Reader, seed); err != nil {
+		return nil, errors.Wrapf(err, "error generating %s key", name)
+	}
+	return NewKeyFromSeed(name, seed)
+}
+
+// NewKeyFromSeed returns the private key of the given parameter set derived
+// from the seed. The seed is the 32 bytes ξ for ML-DSA, and the 64 bytes d||z
+// for ML-KEM.
+func NewKeyFromSeed(name string, seed []byte) (*PrivateKey, error) {
+	a, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != a.seedSize() {
+		return nil, errors.Errorf("invalid %s private key: unexpected seed size", name)
+	}
+
+	raw, sk, err := a.deriveKey(seed)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error marshaling %s public key", name)
+	}
+
+	return &PrivateKey{
+		pub:  &PublicKey{alg: a, raw: raw},
+		seed: append([]byte{}, seed...),
+		sk:   sk,
+	}, nil
+}
+
+// Algorithm returns the name of the parameter set of the key.
+func (k *PrivateKey) Algorithm() string {
+	return k.pub.alg.name
+}
+
+// Seed returns the seed of the private key.
+func (k *PrivateKey) Seed() []byte {
+	return append([]byte{}, k.seed...)
+}
+
+// Public returns the *PublicKey of the private key.
+func (k *PrivateKey) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Equal reports whether k and x have the same value.
+func (k *PrivateKey) Equal(x crypto.PrivateKey) bool {
+	xx, ok := x.(*PrivateKey)
+	return ok && k.pub.Equal(xx.pub) && bytes.Equal(k.seed, xx.seed)
+}
+
+// Sign signs the given message using ML-DSA with an empty context. The
+// message must not be hashed, and opts.HashFunc() must return 0.
+func (k *PrivateKey) Sign(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if !k.pub.alg.isSignature() {
+		return nil, errors.Errorf("%s keys cannot be used to sign", k.pub.alg.name)
+	}
+	if opts != nil && opts.HashFunc() != 0 {
+		return nil, errors.Errorf("%s cannot sign a pre-hashed message", k.pub.alg.name)
+	}
+	return k.pub.alg.sign(k.sk, message), nil
+}
+
+// Verify reports whether sig is a valid ML-DSA signature of message using an
+// empty context.
+func Verify(pub *PublicKey, message, sig []byte) bool {
+	if pub == nil || !pub.alg.isSignature() {
+		return false
+	}
+	return pub.alg.verify(pub.raw, message, sig)
+}
+
+// Encapsulate generates a shared secret and its ML-KEM ciphertext for the
+// given public key.
+func Encapsulate(pub *PublicKey) (ciphertext, sharedSecret []byte, err error) {
+	if pub == nil || pub.alg.isSignature() {
+		return nil, nil, errors.New("encapsulation requires an ML-KEM public key")
+	}
+	return pub.alg.encapsulate(pub.raw)
+}
+
+// Decapsulate returns the shared secret in the given ML-KEM ciphertext.
+func (k *PrivateKey) Decapsulate(ciphertext []byte) ([]byte, error) {
+	if k.pub.alg.isSignature() {
+		return nil, errors.Errorf("%s keys cannot be used to decapsulate", k.pub.alg.name)
+	}
+	return k.pub.alg.decapsulate(k.sk, ciphertext)
+}
+
+// bothPrivateKey is the "both" choice of the ML-DSA and ML-KEM private keys.
+type bothPrivateKey struct {
+	Seed        []byte
+	ExpandedKey []byte
+}
+
+// MarshalPrivateKey returns the encoding of the private key used in the
+// privateKey field of PKCS#8, using the seed choice:
+//
+//	ML-DSA-PrivateKey ::= CHOICE {
+//	  seed [0] OCTET STRING,
+//	  expandedKey OCTET STRING,
+//	  both SEQUENCE { seed OCTET STRING, expandedKey OCTET STRING } }
+func MarshalPrivateKey(k *PrivateKey) ([]byte, error) {
+	b, err := asn1.Marshal(asn1.RawValue{
+		Class: asn1.ClassContextSpecific,
+		Tag:   0,
+		Bytes: k.seed,
+	})
+	return b, errors.Wrapf(err, "error marshaling %s private key", k.pub.alg.name)
+}
+
+// ParsePrivateKey parses the PKCS#8 privateKey field of a key of the given
+// parameter set. The seed and both choices are supported, keys with only the
+// expanded key cannot be used.
+func ParsePrivateKey(name string, der []byte) (*PrivateKey, error) {
+	var v asn1.RawValue
+	if rest, err := asn1.Unmarshal(der, &v); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s private key", name)
+	} else if len(rest) != 0 {
+		return nil, errors.Errorf("error parsing %s private key: trailing data", name)
+	}
+
+	switch {
+	case v.Class == asn1.ClassContextSpecific && v.Tag == 0:
+		return NewKeyFromSeed(name, v.Bytes)
+	case v.Class == asn1.ClassUniversal && v.Tag == asn1.TagSequence:
+		var both bothPrivateKey
+		if _, err := asn1.Unmarshal(der, &both); err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s private key", name)
+		}
+		return NewKeyFromSeed(name, both.Seed)
+	case v.Class == asn1.ClassUniversal && v.Tag == asn1.TagOctetString:
+		return nil, errors.Errorf("error parsing %s private key: keys without a seed are not supported", name)
+	default:
+		return nil, errors.Errorf("error parsing %s private key: unknown format", name)
+	}
+}
@@ -0,0 +1,132 @@
+// +build pqc
+
+package pqc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func TestGenerateKey(t *testing.T) {
+	for _, name := range Algorithms() {
+		t.Run(name, func(t *testing.T) {
+			k, err := GenerateKey(name)
+			assert.FatalError(t, err)
+			assert.Equals(t, name, k.Algorithm())
+			assert.Equals(t, name, k.Public().(*PublicKey).Algorithm())
+
+			k2, err := NewKeyFromSeed(name, k.Seed())
+			assert.FatalError(t, err)
+			assert.True(t, k.Equal(k2))
+			assert.True(t, k.Public().(*PublicKey).Equal(k2.Public()))
+
+			pub, err := NewPublicKey(name, k.Public().(*PublicKey).Bytes())
+			assert.FatalError(t, err)
+			assert.True(t, pub.Equal(k.Public()))
+		})
+	}
+
+	_, err := GenerateKey("ML-DSA-1")
+	assert.Error(t, err)
+	_, err = NewKeyFromSeed(MLDSA65, []byte("short"))
+	assert.Error(t, err)
+	_, err = NewPublicKey(MLDSA65, []byte("short"))
+	assert.Error(t, err)
+}
+
+func TestPrivateKey_Sign(t *testing.T) {
+	msg := []byte("the-message")
+	for _, name := range []string{MLDSA44, MLDSA65, MLDSA87} {
+		t.Run(name, func(t *testing.T) {
+			k, err := GenerateKey(name)
+			assert.FatalError(t, err)
+			sig, err := k.Sign(rand.Reader, msg, crypto.Hash(0))
+			assert.FatalError(t, err)
+			assert.True(t, Verify(k.Public().(*PublicKey), msg, sig))
+			assert.False(t, Verify(k.Public().(*PublicKey), []byte("other-message"), sig))
+
+			_, err = k.Sign(rand.Reader, msg, crypto.SHA256)
+			assert.Error(t, err)
+		})
+	}
+
+	k, err := GenerateKey(MLKEM768)
+	assert.FatalError(t, err)
+	_, err = k.Sign(rand.Reader, msg, crypto.Hash(0))
+	assert.Error(t, err)
+}
+
+func TestEncapsulate(t *testing.T) {
+	for _, name := range []string{MLKEM512, MLKEM768, MLKEM1024} {
+		t.Run(name, func(t *testing.T) {
+			k, err := GenerateKey(name)
+			assert.FatalError(t, err)
+			ct, ss, err := Encapsulate(k.Public().(*PublicKey))
+			assert.FatalError(t, err)
+			got, err := k.Decapsulate(ct)
+			assert.FatalError(t, err)
+			assert.True(t, bytes.Equal(ss, got))
+		})
+	}
+
+	k, err := GenerateKey(MLDSA65)
+	assert.FatalError(t, err)
+	_, _, err = Encapsulate(k.Public().(*PublicKey))
+	assert.Error(t, err)
+	_, err = k.Decapsulate([]byte("ciphertext"))
+	assert.Error(t, err)
+}
+
+func TestOID(t *testing.T) {
+	for _, name := range Algorithms() {
+		oid, err := OID(name)
+		assert.FatalError(t, err)
+		got, ok := AlgorithmByOID(oid)
+		assert.True(t, ok)
+		assert.Equals(t, name, got)
+	}
+	_, ok := AlgorithmByOID(asn1.ObjectIdentifier{1, 3, 101, 112})
+	assert.False(t, ok)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	k, err := GenerateKey(MLDSA65)
+	assert.FatalError(t, err)
+
+	seed, err := MarshalPrivateKey(k)
+	assert.FatalError(t, err)
+	assert.Equals(t, byte(0x80), seed[0])
+	both, err := asn1.Marshal(bothPrivateKey{Seed: k.Seed(), ExpandedKey: []byte("expanded")})
+	assert.FatalError(t, err)
+	expanded, err := asn1.Marshal([]byte("expanded"))
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		der     []byte
+		wantErr bool
+	}{
+		{"seed", seed, false},
+		{"both", both, false},
+		{"expandedKey", expanded, true},
+		{"trailing", append(seed, 0), true},
+		{"garbage", []byte("garbage"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePrivateKey(MLDSA65, tt.der)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, got)
+			} else {
+				assert.FatalError(t, err)
+				assert.True(t, k.Equal(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Package securebuf implements buffers for secret material, like passwords
+// and decrypted private keys. The memory of a buffer is locked, where the
+// operating system supports it, so it is never written to swap, and it is
+// zeroed when the buffer is destroyed.
+package securebuf
+
+import (
+	"io"
+	"runtime"
+)
+
+// Buffer is a fixed size buffer of secret data. Buffers must be destroyed
+// with Destroy as soon as the data is not needed; a buffer that is not
+// referenced anymore is destroyed by the garbage collector.
+type Buffer struct {
+	b    []byte
+	free func([]byte)
+}
+
+// New returns a new buffer of the given size. If the memory cannot be locked,
+// e.g. because RLIMIT_MEMLOCK is exceeded, the buffer uses regular memory, and
+// it is still zeroed on Destroy.
+func New(size int) *Buffer {
+	b, free := alloc(size)
+	buf := &Buffer{b: b, free: free}
+	runtime.SetFinalizer(buf, (*Buffer).Destroy)
+	return buf
+}
+
+// FromBytes returns a new buffer with a copy of b, and zeroes b.
+func FromBytes(b []byte) *Buffer {
+	buf := New(len(b))
+	copy(buf.b, b)
+	Zero(b)
+	return buf
+}
+
+// ReadAll reads from r until EOF or an error and returns the data read in a
+// new buffer. The intermediate buffers are destroyed, so the data is never
+// copied to regular memory.
+func ReadAll(r io.Reader) (*Buffer, error) {
+	buf := New(512)
+	n := 0
+	for {
+		if n == len(buf.b) {
+			next := New(2 * len(buf.b))
+			copy(next.b, buf.b)
+			buf.Destroy()
+			buf = next
+		}
+		m, err := r.Read(buf.b[n:])
+		n += m
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			buf.Destroy()
+			return nil, err
+		}
+	}
+	defer buf.Destroy()
+	return FromBytes(buf.b[:n]), nil
+}
+
+// Bytes returns the contents of the buffer. The returned slice must not be
+// used after the buffer is destroyed.
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.b
+}
+
+// Len returns the size of the buffer.
+func (b *Buffer) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.b)
+}
+
+// Destroy zeroes and releases the memory of the buffer. It is safe to call
+// Destroy multiple times.
+func (b *Buffer) Destroy() {
+	if b == nil || b.b == nil {
+		return
+	}
+	Zero(b.b)
+	if b.free != nil {
+		b.free(b.b)
+	}
+	b.b, b.free = nil, nil
+	runtime.SetFinalizer(b, nil)
+}
+
+// Zero overwrites the given slice with zeros.
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
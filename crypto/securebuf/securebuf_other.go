@@ -0,0 +1,9 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package securebuf
+
+// alloc returns a slice of the given size. Memory locking is not supported in
+// this platform, the memory is only zeroed on Destroy.
+func alloc(size int) ([]byte, func([]byte)) {
+	return make([]byte, size), nil
+}
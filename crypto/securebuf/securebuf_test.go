@@ -0,0 +1,95 @@
+package securebuf
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNew(t *testing.T) {
+	for _, size := range []int{0, 1, 32, 4096, 10000} {
+		b := New(size)
+		if b.Len() != size || len(b.Bytes()) != size {
+			t.Errorf("New(%d) length = %d, want %d", size, b.Len(), size)
+		}
+		for i := range b.Bytes() {
+			b.Bytes()[i] = byte(i)
+		}
+		b.Destroy()
+		if b.Len() != 0 || b.Bytes() != nil {
+			t.Errorf("Destroy() did not release the buffer of size %d", size)
+		}
+		// Destroy is idempotent
+		b.Destroy()
+	}
+}
+
+func TestFromBytes(t *testing.T) {
+	secret := []byte("the secret password")
+	b := FromBytes(secret)
+	defer b.Destroy()
+
+	if !bytes.Equal(b.Bytes(), []byte("the secret password")) {
+		t.Errorf("FromBytes() = %q, want %q", b.Bytes(), "the secret password")
+	}
+	if !bytes.Equal(secret, make([]byte, len(secret))) {
+		t.Errorf("FromBytes() did not zero the input: %q", secret)
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	for _, size := range []int{0, 1, 511, 512, 513, 5000} {
+		data := strings.Repeat("x", size)
+		b, err := ReadAll(iotest.OneByteReader(strings.NewReader(data)))
+		if err != nil {
+			t.Fatalf("ReadAll() error = %v", err)
+		}
+		if string(b.Bytes()) != data {
+			t.Errorf("ReadAll() length = %d, want %d", b.Len(), size)
+		}
+		b.Destroy()
+	}
+
+	if _, err := ReadAll(iotest.TimeoutReader(strings.NewReader("secret"))); err == nil {
+		t.Error("ReadAll() error = nil, want error")
+	}
+	errRead := errors.New("read error")
+	if _, err := ReadAll(&errReader{errRead}); err != errRead {
+		t.Errorf("ReadAll() error = %v, want %v", err, errRead)
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestBuffer_Destroy(t *testing.T) {
+	// Use regular memory to be able to read it after Destroy.
+	data := []byte("the secret password")
+	b := &Buffer{b: data}
+	b.Destroy()
+	if !bytes.Equal(data, make([]byte, len(data))) {
+		t.Errorf("Destroy() did not zero the buffer: %q", data)
+	}
+
+	var nilBuf *Buffer
+	nilBuf.Destroy()
+	if nilBuf.Len() != 0 || nilBuf.Bytes() != nil {
+		t.Error("nil buffer is not empty")
+	}
+}
+
+func TestZero(t *testing.T) {
+	b := []byte("the secret password")
+	Zero(b)
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Errorf("Zero() = %q", b)
+	}
+	Zero(nil)
+}
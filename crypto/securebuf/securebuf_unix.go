@@ -0,0 +1,28 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package securebuf
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// alloc returns a slice of the given size in its own locked pages, and the
+// function to release them. It falls back to regular memory if the pages
+// cannot be mapped or locked.
+func alloc(size int) ([]byte, func([]byte)) {
+	if size == 0 {
+		return []byte{}, nil
+	}
+	b, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, size), nil
+	}
+	if err := unix.Mlock(b); err != nil {
+		unix.Munmap(b)
+		return make([]byte, size), nil
+	}
+	return b, func(b []byte) {
+		unix.Munlock(b)
+		unix.Munmap(b)
+	}
+}
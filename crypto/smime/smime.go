@@ -0,0 +1,391 @@
+// Package smime implements the signature, verification, encryption and
+// decryption of email messages using S/MIME 4.0, defined in RFC 8551.
+//
+// The messages are MIME entities, a header followed by a body. The signed and
+// encrypted messages are the entities to put in the body of an email, or to
+// prepend the email headers to.
+package smime
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"mime"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// SignOptions are the options used to sign a message.
+type SignOptions struct {
+	// Intermediates are the intermediate certificates included in the
+	// signature.
+	Intermediates []*x509.Certificate
+	// Opaque creates an application/pkcs7-mime message with the content
+	// inside the signature, instead of a multipart/signed message. Opaque
+	// messages can only be read by clients that support S/MIME.
+	Opaque bool
+}
+
+// VerifyOptions are the options used to verify a message.
+type VerifyOptions struct {
+	// Roots are the root certificates trusted to issue the signing
+	// certificate.
+	Roots *x509.CertPool
+	// Email is the address that must be in the signing certificate, usually
+	// the sender of the email.
+	Email string
+	// CurrentTime is the time used to verify the certificate chain, defaults
+	// to the current time.
+	CurrentTime time.Time
+}
+
+// Signature is a verified signature.
+type Signature struct {
+	// Certificate is the signing certificate.
+	Certificate *x509.Certificate
+	// Content is the signed MIME entity.
+	Content []byte
+}
+
+// Sign signs the MIME entity and returns an S/MIME signed message. Line
+// endings in the entity are converted to CRLF before signing.
+func Sign(entity []byte, crt *x509.Certificate, signer crypto.Signer, opts SignOptions) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return nil, errors.New("Ed25519 keys are not supported by S/MIME")
+	}
+	if !hasEmailProtection(crt) {
+		return nil, errors.New("the certificate cannot be used for S/MIME: it does not have the email protection extended key usage")
+	}
+	entity = canonicalize(entity)
+
+	sd, err := pkcs7.NewSignedData(entity)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating signature")
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSignerChain(crt, signer, opts.Intermediates, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, errors.Wrap(err, "error signing message")
+	}
+	if !opts.Opaque {
+		sd.Detach()
+	}
+	der, err := sd.Finish()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating signature")
+	}
+
+	var buf bytes.Buffer
+	if opts.Opaque {
+		writeApplicationPKCS7(&buf, "signed-data", der)
+		return buf.Bytes(), nil
+	}
+
+	boundary, err := newBoundary()
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: " + mime.FormatMediaType("multipart/signed", map[string]string{
+		"protocol": "application/pkcs7-signature",
+		"micalg":   "sha-256",
+		"boundary": boundary,
+	}) + "\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString("This is a cryptographically signed message in MIME format.\r\n")
+	buf.WriteString("\r\n--" + boundary + "\r\n")
+	buf.Write(entity)
+	buf.WriteString("\r\n--" + boundary + "\r\n")
+	buf.WriteString("Content-Type: application/pkcs7-signature; name=\"smime.p7s\"\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("Content-Disposition: attachment; filename=\"smime.p7s\"\r\n")
+	buf.WriteString("\r\n")
+	writeBase64(&buf, der)
+	buf.WriteString("\r\n--" + boundary + "--\r\n")
+	return buf.Bytes(), nil
+}
+
+// Verify verifies an S/MIME signed message, multipart/signed or
+// application/pkcs7-mime, and returns the signing certificate and the signed
+// entity.
+func Verify(msg []byte, opts VerifyOptions) (*Signature, error) {
+	header, body, err := parseEntity(msg)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing Content-Type")
+	}
+
+	var p7 *pkcs7.PKCS7
+	switch mediaType {
+	case "multipart/signed":
+		if proto := strings.ToLower(params["protocol"]); proto != "application/pkcs7-signature" && proto != "application/x-pkcs7-signature" {
+			return nil, errors.Errorf("unsupported multipart/signed protocol %s", params["protocol"])
+		}
+		parts, err := splitMultipart(body, params["boundary"])
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) != 2 {
+			return nil, errors.Errorf("multipart/signed message has %d parts, expected 2", len(parts))
+		}
+		sigHeader, sigBody, err := parseEntity(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		der, err := decodeBody(sigHeader, sigBody)
+		if err != nil {
+			return nil, err
+		}
+		if p7, err = parsePKCS7(der, pkcs7.OIDSignedData); err != nil {
+			return nil, err
+		}
+		p7.Content = parts[0]
+	case "application/pkcs7-mime", "application/x-pkcs7-mime":
+		der, err := decodeBody(header, body)
+		if err != nil {
+			return nil, err
+		}
+		if p7, err = parsePKCS7(der, pkcs7.OIDSignedData); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("message is not signed: unsupported Content-Type %s", mediaType)
+	}
+
+	if len(p7.Signers) != 1 {
+		return nil, errors.Errorf("the message has %d signers, expected 1", len(p7.Signers))
+	}
+	if opts.CurrentTime.IsZero() {
+		err = p7.VerifyWithChain(opts.Roots)
+	} else {
+		err = p7.VerifyWithChainAtTime(opts.Roots, opts.CurrentTime)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying signature")
+	}
+
+	crt := p7.GetOnlySigner()
+	if crt == nil {
+		return nil, errors.New("error verifying signature: signing certificate not found")
+	}
+	if !hasEmailProtection(crt) {
+		return nil, errors.New("error verifying signature: the signing certificate does not have the email protection extended key usage")
+	}
+	if opts.Email != "" && !hasEmail(crt, opts.Email) {
+		return nil, errors.Errorf("error verifying signature: the signing certificate is not valid for %s", opts.Email)
+	}
+	return &Signature{
+		Certificate: crt,
+		Content:     p7.Content,
+	}, nil
+}
+
+// Encrypt encrypts the MIME entity for the given recipients and returns an
+// S/MIME enveloped message. The content is encrypted with AES-256-CBC and the
+// key is encrypted with the RSA key of each recipient.
+func Encrypt(entity []byte, recipients []*x509.Certificate) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+	for _, crt := range recipients {
+		if !hasEmailProtection(crt) {
+			return nil, errors.Errorf("the certificate of %s cannot be used for S/MIME: it does not have the email protection extended key usage", crt.Subject)
+		}
+	}
+
+	pkcs7.ContentEncryptionAlgorithm = pkcs7.EncryptionAlgorithmAES256CBC
+	der, err := pkcs7.Encrypt(canonicalize(entity), recipients)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encrypting message")
+	}
+	var buf bytes.Buffer
+	writeApplicationPKCS7(&buf, "enveloped-data", der)
+	return buf.Bytes(), nil
+}
+
+// Decrypt decrypts an S/MIME enveloped message using the certificate and
+// private key of a recipient, and returns the MIME entity.
+func Decrypt(msg []byte, crt *x509.Certificate, key crypto.PrivateKey) ([]byte, error) {
+	header, body, err := parseEntity(msg)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing Content-Type")
+	}
+	if mediaType != "application/pkcs7-mime" && mediaType != "application/x-pkcs7-mime" {
+		return nil, errors.Errorf("message is not encrypted: unsupported Content-Type %s", mediaType)
+	}
+	der, err := decodeBody(header, body)
+	if err != nil {
+		return nil, err
+	}
+	p7, err := parsePKCS7(der, pkcs7.OIDEnvelopedData)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := p7.Decrypt(crt, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decrypting message")
+	}
+	return entity, nil
+}
+
+// parsePKCS7 parses a CMS content info and checks its content type.
+func parsePKCS7(der []byte, contentType asn1.ObjectIdentifier) (*pkcs7.PKCS7, error) {
+	var info struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+	}
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, errors.Wrap(err, "error parsing CMS message")
+	}
+	if !info.ContentType.Equal(contentType) {
+		switch {
+		case info.ContentType.Equal(pkcs7.OIDEnvelopedData):
+			return nil, errors.New("message is encrypted, not signed")
+		case info.ContentType.Equal(pkcs7.OIDSignedData):
+			return nil, errors.New("message is signed, not encrypted")
+		default:
+			return nil, errors.Errorf("unsupported CMS content type %s", info.ContentType)
+		}
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing CMS message")
+	}
+	return p7, nil
+}
+
+// parseEntity splits a MIME entity into its header and body. Line endings are
+// converted to CRLF first.
+func parseEntity(b []byte) (textproto.MIMEHeader, []byte, error) {
+	br := bufio.NewReader(bytes.NewReader(canonicalize(b)))
+	header, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing MIME header")
+	}
+	body, err := ioutil.ReadAll(br)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing MIME body")
+	}
+	return header, body, nil
+}
+
+// splitMultipart returns the raw parts of a multipart body, defined in RFC
+// 2046 Section 5.1.1. The CRLF before a delimiter belongs to the delimiter.
+func splitMultipart(body []byte, boundary string) ([][]byte, error) {
+	if boundary == "" {
+		return nil, errors.New("error parsing multipart message: boundary not found")
+	}
+	delimiter := []byte("\r\n--" + boundary)
+	chunks := bytes.Split(append([]byte("\r\n"), body...), delimiter)
+	if len(chunks) < 2 {
+		return nil, errors.New("error parsing multipart message: delimiter not found")
+	}
+	var parts [][]byte
+	// The first chunk is the preamble.
+	for _, chunk := range chunks[1:] {
+		if bytes.HasPrefix(chunk, []byte("--")) {
+			return parts, nil
+		}
+		// Skip the transport padding after the delimiter.
+		i := bytes.Index(chunk, []byte("\r\n"))
+		if i < 0 || len(bytes.TrimLeft(chunk[:i], " \t")) > 0 {
+			return nil, errors.New("error parsing multipart message: invalid delimiter")
+		}
+		parts = append(parts, chunk[i+2:])
+	}
+	return nil, errors.New("error parsing multipart message: close delimiter not found")
+}
+
+// decodeBody decodes the body of an entity using its Content-Transfer-Encoding.
+func decodeBody(header textproto.MIMEHeader, body []byte) ([]byte, error) {
+	switch enc := strings.ToLower(header.Get("Content-Transfer-Encoding")); enc {
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+		if err != nil {
+			return nil, errors.Wrap(err, "error decoding base64 body")
+		}
+		return b, nil
+	case "", "binary", "7bit", "8bit":
+		return body, nil
+	default:
+		return nil, errors.Errorf("unsupported Content-Transfer-Encoding %s", enc)
+	}
+}
+
+// writeApplicationPKCS7 writes an application/pkcs7-mime entity with the given
+// smime-type.
+func writeApplicationPKCS7(buf *bytes.Buffer, smimeType string, der []byte) {
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: application/pkcs7-mime; smime-type=" + smimeType + "; name=\"smime.p7m\"\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("Content-Disposition: attachment; filename=\"smime.p7m\"\r\n")
+	buf.WriteString("\r\n")
+	writeBase64(buf, der)
+}
+
+// writeBase64 writes the base64 encoding of b in lines of 76 characters.
+func writeBase64(buf *bytes.Buffer, b []byte) {
+	s := base64.StdEncoding.EncodeToString(b)
+	for len(s) > 76 {
+		buf.WriteString(s[:76] + "\r\n")
+		s = s[76:]
+	}
+	buf.WriteString(s + "\r\n")
+}
+
+// canonicalize converts the line endings to CRLF, the canonical form of MIME
+// entities.
+func canonicalize(b []byte) []byte {
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+	return bytes.Replace(b, []byte("\n"), []byte("\r\n"), -1)
+}
+
+func newBoundary() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "error generating boundary")
+	}
+	return "----step-" + hex.EncodeToString(b), nil
+}
+
+// hasEmailProtection returns true if the certificate can be used for S/MIME, a
+// certificate without extended key usages can be used for any purpose.
+func hasEmailProtection(crt *x509.Certificate) bool {
+	if len(crt.ExtKeyUsage) == 0 && len(crt.UnknownExtKeyUsage) == 0 {
+		return true
+	}
+	for _, eku := range crt.ExtKeyUsage {
+		if eku == x509.ExtKeyUsageEmailProtection || eku == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEmail returns true if the certificate has the given email address, in the
+// SANs or in the subject.
+func hasEmail(crt *x509.Certificate, email string) bool {
+	for _, e := range crt.EmailAddresses {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	return strings.EqualFold(crt.Subject.CommonName, email)
+}
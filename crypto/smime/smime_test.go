@@ -0,0 +1,177 @@
+package smime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testIdentity struct {
+	crt *x509.Certificate
+	key crypto.Signer
+}
+
+func newTestIdentity(t *testing.T, tmpl *x509.Certificate, key crypto.Signer, issuer *testIdentity) *testIdentity {
+	parent, parentKey := tmpl, key
+	if issuer != nil {
+		parent, parentKey = issuer.crt, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testIdentity{crt: crt, key: key}
+}
+
+func newTestPKI(t *testing.T) (*testIdentity, *testIdentity, *testIdentity) {
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ca := newTestIdentity(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, caKey, nil)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jane := newTestIdentity(t, &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		Subject:        pkix.Name{CommonName: "Jane Doe"},
+		EmailAddresses: []string{"jane@example.com"},
+		NotBefore:      now.Add(-time.Minute),
+		NotAfter:       now.Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}, rsaKey, ca)
+
+	tlsKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	server := newTestIdentity(t, &x509.Certificate{
+		SerialNumber:   big.NewInt(3),
+		Subject:        pkix.Name{CommonName: "john@example.com"},
+		EmailAddresses: []string{"john@example.com"},
+		NotBefore:      now.Add(-time.Minute),
+		NotAfter:       now.Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, tlsKey, ca)
+
+	return ca, jane, server
+}
+
+func TestSignVerify(t *testing.T) {
+	ca, jane, server := newTestPKI(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.crt)
+
+	entity := []byte("Content-Type: text/plain; charset=utf-8\n\nHello, World!\n")
+	canonical := []byte("Content-Type: text/plain; charset=utf-8\r\n\r\nHello, World!\r\n")
+
+	for _, opaque := range []bool{false, true} {
+		msg, err := Sign(entity, jane.crt, jane.key, SignOptions{Opaque: opaque})
+		require.NoError(t, err)
+
+		contentType := "multipart/signed"
+		if opaque {
+			contentType = "application/pkcs7-mime"
+		}
+		require.Contains(t, string(msg), "Content-Type: "+contentType)
+
+		sig, err := Verify(msg, VerifyOptions{Roots: roots, Email: "JANE@example.com"})
+		require.NoError(t, err)
+		require.Equal(t, jane.crt, sig.Certificate)
+		require.Equal(t, canonical, sig.Content)
+
+		// Messages with LF line endings
+		sig, err = Verify(bytes.Replace(msg, []byte("\r\n"), []byte("\n"), -1), VerifyOptions{Roots: roots})
+		require.NoError(t, err)
+		require.Equal(t, canonical, sig.Content)
+
+		// Wrong sender
+		_, err = Verify(msg, VerifyOptions{Roots: roots, Email: "john@example.com"})
+		require.Error(t, err)
+		// Untrusted root
+		_, err = Verify(msg, VerifyOptions{Roots: x509.NewCertPool()})
+		require.Error(t, err)
+		// Expired certificate
+		_, err = Verify(msg, VerifyOptions{Roots: roots, CurrentTime: time.Now().Add(2 * time.Hour)})
+		require.Error(t, err)
+	}
+
+	// Modified content
+	msg, err := Sign(entity, jane.crt, jane.key, SignOptions{})
+	require.NoError(t, err)
+	_, err = Verify(bytes.Replace(msg, []byte("Hello"), []byte("Bye"), 1), VerifyOptions{Roots: roots})
+	require.Error(t, err)
+
+	// Certificate without the email protection usage
+	_, err = Sign(entity, server.crt, server.key, SignOptions{})
+	require.Error(t, err)
+
+	// Not signed
+	_, err = Verify(entity, VerifyOptions{Roots: roots})
+	require.Error(t, err)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ca, jane, server := newTestPKI(t)
+	entity := []byte("Content-Type: text/plain\r\n\r\nsecret\r\n")
+
+	msg, err := Encrypt(entity, []*x509.Certificate{jane.crt})
+	require.NoError(t, err)
+	require.Contains(t, string(msg), "smime-type=enveloped-data")
+	require.NotContains(t, string(msg), "secret")
+
+	got, err := Decrypt(msg, jane.crt, jane.key)
+	require.NoError(t, err)
+	require.Equal(t, entity, got)
+
+	// Not a recipient
+	_, err = Decrypt(msg, ca.crt, ca.key)
+	require.Error(t, err)
+
+	// Signed messages cannot be decrypted
+	signed, err := Sign(entity, jane.crt, jane.key, SignOptions{Opaque: true})
+	require.NoError(t, err)
+	_, err = Decrypt(signed, jane.crt, jane.key)
+	require.Error(t, err)
+
+	// Invalid recipients
+	_, err = Encrypt(entity, nil)
+	require.Error(t, err)
+	_, err = Encrypt(entity, []*x509.Certificate{server.crt})
+	require.Error(t, err)
+}
+
+func TestSplitMultipart(t *testing.T) {
+	body := []byte("preamble\r\n--b\r\npart 1\r\n--b  \r\npart 2\r\n\r\n--b--\r\nepilogue")
+	parts, err := splitMultipart(body, "b")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("part 1"), []byte("part 2\r\n")}, parts)
+
+	parts, err = splitMultipart([]byte("--b\r\nfirst\r\n--b--"), "b")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("first")}, parts)
+
+	for _, body := range []string{"--b\r\nno close\r\n", "no delimiter", "--bx\r\npart\r\n--b--"} {
+		_, err := splitMultipart([]byte(body), "b")
+		require.Error(t, err, body)
+	}
+	_, err = splitMultipart(body, "")
+	require.Error(t, err)
+}
@@ -0,0 +1,235 @@
+// Package spiffe implements the SPIFFE IDs, the validation of X.509-SVIDs and
+// the SPIFFE bundle format used to federate trust domains.
+package spiffe
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/jose"
+)
+
+// MaxIDLength is the maximum length of a SPIFFE ID in bytes.
+const MaxIDLength = 2048
+
+// Uses of the keys in a SPIFFE bundle.
+const (
+	UseX509SVID = "x509-svid"
+	UseJWTSVID  = "jwt-svid"
+)
+
+// ParseID parses and validates a workload SPIFFE ID with the format
+// spiffe://<trust-domain>/<path>, as defined in the SPIFFE ID specification.
+func ParseID(s string) (*url.URL, error) {
+	if len(s) > MaxIDLength {
+		return nil, errors.Errorf("SPIFFE ID is longer than %d bytes", MaxIDLength)
+	}
+	if !strings.HasPrefix(s, "spiffe://") {
+		return nil, errors.New("SPIFFE ID must start with spiffe://")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing SPIFFE ID")
+	}
+	switch {
+	case u.Host == "":
+		return nil, errors.New("SPIFFE ID must have a trust domain")
+	case u.User != nil, u.Port() != "":
+		return nil, errors.New("SPIFFE ID trust domain cannot have a user info or port")
+	case u.RawQuery != "", u.ForceQuery, u.Fragment != "", strings.Contains(s, "#"):
+		return nil, errors.New("SPIFFE ID cannot have a query or a fragment")
+	case u.Path == "" || u.Path == "/":
+		return nil, errors.New("SPIFFE ID must have a path to identify the workload")
+	}
+	if err := ValidateTrustDomain(u.Host); err != nil {
+		return nil, err
+	}
+	for _, segment := range strings.Split(u.Path[1:], "/") {
+		switch segment {
+		case "":
+			return nil, errors.New("SPIFFE ID path cannot have empty segments or a trailing slash")
+		case ".", "..":
+			return nil, errors.New("SPIFFE ID path cannot have relative segments")
+		}
+		for _, c := range segment {
+			if !isIDChar(c) {
+				return nil, errors.Errorf("SPIFFE ID path segment '%s' must only contain letters, numbers, dots, dashes and underscores", segment)
+			}
+		}
+	}
+	return u, nil
+}
+
+// ValidateTrustDomain checks that the trust domain only contains the
+// characters allowed by the SPIFFE ID specification.
+func ValidateTrustDomain(td string) error {
+	if td == "" {
+		return errors.New("SPIFFE ID must have a trust domain")
+	}
+	for _, c := range td {
+		if !isIDChar(c) || (c >= 'A' && c <= 'Z') {
+			return errors.Errorf("SPIFFE ID trust domain '%s' must only contain lowercase letters, numbers, dots, dashes and underscores", td)
+		}
+	}
+	return nil
+}
+
+func isIDChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		c == '.' || c == '-' || c == '_'
+}
+
+// Bundle is a SPIFFE bundle, the keys used to validate the SVIDs of a trust
+// domain.
+type Bundle struct {
+	// Sequence is the sequence number of the bundle, 0 if it is not set.
+	Sequence uint64
+	// RefreshHint is how often the consumers should check for updates of the
+	// bundle, 0 if it is not set.
+	RefreshHint time.Duration
+	// X509Authorities are the root certificates of the X.509-SVIDs.
+	X509Authorities []*x509.Certificate
+	// JWTAuthorities are the JWKs used to validate the JWT-SVIDs, kept as
+	// they are.
+	JWTAuthorities []json.RawMessage
+}
+
+// bundleDocument is the JWK set with the SPIFFE parameters of a bundle.
+type bundleDocument struct {
+	Keys        []json.RawMessage `json:"keys"`
+	Sequence    uint64            `json:"spiffe_sequence,omitempty"`
+	RefreshHint int64             `json:"spiffe_refresh_hint,omitempty"`
+}
+
+// ParseBundle parses a SPIFFE bundle in the JWK set format defined in the
+// SPIFFE Trust Domain and Bundle specification. Keys with an unknown use are
+// ignored, as the specification requires.
+func ParseBundle(b []byte) (*Bundle, error) {
+	var doc bundleDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, errors.Wrap(err, "error parsing SPIFFE bundle")
+	}
+	if doc.Keys == nil {
+		return nil, errors.New("error parsing SPIFFE bundle: it does not have the keys parameter")
+	}
+	if doc.RefreshHint < 0 {
+		return nil, errors.New("error parsing SPIFFE bundle: spiffe_refresh_hint cannot be negative")
+	}
+
+	bundle := &Bundle{
+		Sequence:    doc.Sequence,
+		RefreshHint: time.Duration(doc.RefreshHint) * time.Second,
+	}
+	for i, raw := range doc.Keys {
+		var hdr struct {
+			Use string `json:"use"`
+		}
+		if err := json.Unmarshal(raw, &hdr); err != nil {
+			return nil, errors.Wrapf(err, "error parsing SPIFFE bundle key %d", i+1)
+		}
+		switch hdr.Use {
+		case UseX509SVID:
+			var jwk jose.JSONWebKey
+			if err := json.Unmarshal(raw, &jwk); err != nil {
+				return nil, errors.Wrapf(err, "error parsing SPIFFE bundle key %d", i+1)
+			}
+			if len(jwk.Certificates) != 1 {
+				return nil, errors.Errorf("error parsing SPIFFE bundle key %d: an x509-svid key must have exactly one certificate in x5c", i+1)
+			}
+			bundle.X509Authorities = append(bundle.X509Authorities, jwk.Certificates[0])
+		case UseJWTSVID:
+			bundle.JWTAuthorities = append(bundle.JWTAuthorities, raw)
+		}
+	}
+	return bundle, nil
+}
+
+// MarshalJSON returns the bundle in the JWK set format of the SPIFFE bundles.
+func (b *Bundle) MarshalJSON() ([]byte, error) {
+	doc := bundleDocument{
+		Keys:        []json.RawMessage{},
+		Sequence:    b.Sequence,
+		RefreshHint: int64(b.RefreshHint / time.Second),
+	}
+	for _, crt := range b.X509Authorities {
+		raw, err := json.Marshal(jose.JSONWebKey{
+			Key:          crt.PublicKey,
+			Certificates: []*x509.Certificate{crt},
+			Use:          UseX509SVID,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "error marshaling the key of %s", crt.Subject)
+		}
+		doc.Keys = append(doc.Keys, raw)
+	}
+	doc.Keys = append(doc.Keys, b.JWTAuthorities...)
+	return json.Marshal(doc)
+}
+
+// VerifyX509SVID verifies that the chain, the leaf first followed by its
+// intermediates, is a valid X.509-SVID for the trust domain of the bundle at
+// the given time, and returns its SPIFFE ID. If trustDomain is not empty the
+// SPIFFE ID must belong to it.
+func VerifyX509SVID(chain []*x509.Certificate, bundle *Bundle, trustDomain string, now time.Time) (*url.URL, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("X.509-SVID chain cannot be empty")
+	}
+	if len(bundle.X509Authorities) == 0 {
+		return nil, errors.New("SPIFFE bundle does not have any X.509 authorities")
+	}
+
+	leaf := chain[0]
+	if len(leaf.URIs) != 1 {
+		return nil, errors.Errorf("X.509-SVID must have exactly one URI SAN, it has %d", len(leaf.URIs))
+	}
+	id, err := ParseID(leaf.URIs[0].String())
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid X.509-SVID")
+	}
+	switch {
+	case trustDomain != "" && id.Host != trustDomain:
+		return nil, errors.Errorf("SPIFFE ID trust domain '%s' does not match the trust domain '%s'", id.Host, trustDomain)
+	case leaf.IsCA:
+		return nil, errors.New("X.509-SVID cannot be a CA")
+	case leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0:
+		return nil, errors.New("X.509-SVID must have the digitalSignature key usage")
+	case leaf.KeyUsage&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != 0:
+		return nil, errors.New("X.509-SVID cannot have the keyCertSign or cRLSign key usages")
+	}
+	for _, crt := range chain[1:] {
+		if !crt.IsCA || crt.KeyUsage&x509.KeyUsageCertSign == 0 {
+			return nil, errors.Errorf("X.509-SVID intermediate %s must be a CA with the keyCertSign key usage", crt.Subject)
+		}
+	}
+
+	roots := x509.NewCertPool()
+	for _, crt := range bundle.X509Authorities {
+		roots.AddCert(crt)
+	}
+	intermediates := x509.NewCertPool()
+	for _, crt := range chain[1:] {
+		intermediates.AddCert(crt)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying X.509-SVID")
+	}
+	// A SPIFFE ID in a signing certificate identifies its trust domain.
+	for _, crt := range chains[0][1:] {
+		for _, u := range crt.URIs {
+			if u.Scheme == "spiffe" && u.Host != id.Host {
+				return nil, errors.Errorf("SPIFFE ID trust domain '%s' does not match the trust domain '%s' of %s", id.Host, u.Host, crt.Subject)
+			}
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,172 @@
+package spiffe
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testIdentity struct {
+	crt *x509.Certificate
+	key crypto.Signer
+}
+
+func newTestIdentity(t *testing.T, cn string, isCA bool, uri string, issuer *testIdentity) *testIdentity {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+	if uri != "" {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		tmpl.URIs = []*url.URL{u}
+	}
+	parent, parentKey := tmpl, crypto.Signer(key)
+	if issuer != nil {
+		parent, parentKey = issuer.crt, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testIdentity{crt: crt, key: key}
+}
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantErr bool
+	}{
+		{"spiffe://example.org/ns/prod/sa/billing", false},
+		{"spiffe://example.org/Billing_v1.2-a", false},
+		{"https://example.org/billing", true},
+		{"spiffe:///billing", true},
+		{"spiffe://Example.org/billing", true},
+		{"spiffe://example.org:8443/billing", true},
+		{"spiffe://user@example.org/billing", true},
+		{"spiffe://example.org", true},
+		{"spiffe://example.org/", true},
+		{"spiffe://example.org/billing/", true},
+		{"spiffe://example.org/ns//billing", true},
+		{"spiffe://example.org/ns/../billing", true},
+		{"spiffe://example.org/billing?x=1", true},
+		{"spiffe://example.org/billing#x", true},
+		{"spiffe://example.org/bill%20ing", true},
+		{"spiffe://example.org/" + strings.Repeat("a", MaxIDLength), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			_, err := ParseID(tt.id)
+			require.Equal(t, tt.wantErr, err != nil, "ParseID() error = %v", err)
+		})
+	}
+}
+
+func TestBundle(t *testing.T) {
+	root1 := newTestIdentity(t, "Root 1", true, "", nil)
+	root2 := newTestIdentity(t, "Root 2", true, "spiffe://example.org", nil)
+	jwtKey := json.RawMessage(`{"kty":"EC","use":"jwt-svid","kid":"foo","crv":"P-256","x":"abc","y":"def"}`)
+
+	b, err := json.Marshal(&Bundle{
+		Sequence:        42,
+		RefreshHint:     5 * time.Minute,
+		X509Authorities: []*x509.Certificate{root1.crt, root2.crt},
+		JWTAuthorities:  []json.RawMessage{jwtKey},
+	})
+	require.NoError(t, err)
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &doc))
+	require.Equal(t, float64(42), doc["spiffe_sequence"])
+	require.Equal(t, float64(300), doc["spiffe_refresh_hint"])
+	require.Len(t, doc["keys"], 3)
+	require.Equal(t, "x509-svid", doc["keys"].([]interface{})[0].(map[string]interface{})["use"])
+
+	bundle, err := ParseBundle(b)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), bundle.Sequence)
+	require.Equal(t, 5*time.Minute, bundle.RefreshHint)
+	require.Len(t, bundle.X509Authorities, 2)
+	require.Equal(t, root1.crt.Raw, bundle.X509Authorities[0].Raw)
+	require.Equal(t, root2.crt.Raw, bundle.X509Authorities[1].Raw)
+	require.Len(t, bundle.JWTAuthorities, 1)
+	require.JSONEq(t, string(jwtKey), string(bundle.JWTAuthorities[0]))
+
+	// Keys with unknown uses are ignored.
+	bundle, err = ParseBundle([]byte(`{"keys":[{"kty":"oct","use":"foo","k":"YmFy"}]}`))
+	require.NoError(t, err)
+	require.Len(t, bundle.X509Authorities, 0)
+
+	for _, s := range []string{
+		`{}`,
+		`{"keys":[]`,
+		`{"keys":[],"spiffe_refresh_hint":-1}`,
+		`{"keys":[{"kty":"EC","use":"x509-svid"}]}`,
+	} {
+		_, err := ParseBundle([]byte(s))
+		require.Error(t, err, s)
+	}
+}
+
+func TestVerifyX509SVID(t *testing.T) {
+	root := newTestIdentity(t, "Root", true, "spiffe://example.org", nil)
+	intermediate := newTestIdentity(t, "Intermediate", true, "", root)
+	leaf := newTestIdentity(t, "Billing", false, "spiffe://example.org/billing", intermediate)
+	bundle := &Bundle{X509Authorities: []*x509.Certificate{root.crt}}
+	other := &Bundle{X509Authorities: []*x509.Certificate{newTestIdentity(t, "Other", true, "", nil).crt}}
+
+	tests := []struct {
+		name        string
+		chain       []*x509.Certificate
+		bundle      *Bundle
+		trustDomain string
+		now         time.Time
+		wantErr     string
+	}{
+		{"ok", []*x509.Certificate{leaf.crt, intermediate.crt}, bundle, "", time.Now(), ""},
+		{"ok trust domain", []*x509.Certificate{leaf.crt, intermediate.crt}, bundle, "example.org", time.Now(), ""},
+		{"fail trust domain", []*x509.Certificate{leaf.crt, intermediate.crt}, bundle, "example.com", time.Now(), "does not match the trust domain 'example.com'"},
+		{"fail root trust domain", []*x509.Certificate{newTestIdentity(t, "Billing", false, "spiffe://example.com/billing", root).crt}, bundle, "", time.Now(), "does not match the trust domain 'example.org' of CN=Root"},
+		{"fail no intermediate", []*x509.Certificate{leaf.crt}, bundle, "", time.Now(), "error verifying X.509-SVID"},
+		{"fail other bundle", []*x509.Certificate{leaf.crt, intermediate.crt}, other, "", time.Now(), "error verifying X.509-SVID"},
+		{"fail expired", []*x509.Certificate{leaf.crt, intermediate.crt}, bundle, "", time.Now().Add(2 * time.Hour), "error verifying X.509-SVID"},
+		{"fail empty bundle", []*x509.Certificate{leaf.crt}, &Bundle{}, "", time.Now(), "does not have any X.509 authorities"},
+		{"fail no URI", []*x509.Certificate{newTestIdentity(t, "Billing", false, "", root).crt}, bundle, "", time.Now(), "exactly one URI SAN"},
+		{"fail invalid ID", []*x509.Certificate{newTestIdentity(t, "Billing", false, "spiffe://example.org", root).crt}, bundle, "", time.Now(), "invalid X.509-SVID"},
+		{"fail CA", []*x509.Certificate{newTestIdentity(t, "Billing", true, "spiffe://example.org/billing", root).crt}, bundle, "", time.Now(), "cannot be a CA"},
+		{"fail intermediate", []*x509.Certificate{leaf.crt, leaf.crt}, bundle, "", time.Now(), "must be a CA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := VerifyX509SVID(tt.chain, tt.bundle, tt.trustDomain, tt.now)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "spiffe://example.org/billing", id.String())
+		})
+	}
+}
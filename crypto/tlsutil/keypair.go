@@ -0,0 +1,65 @@
+package tlsutil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms"
+)
+
+// LoadX509KeyPair reads and parses a public/private key pair from a pair of
+// files. It works like tls.LoadX509KeyPair but the key can also be a key URI,
+// e.g. env:STEP_KEY, yubikey:9a or awskms:alias/my-key.
+func LoadX509KeyPair(certFile, keyFile string) (tls.Certificate, error) {
+	if !kms.IsKMS(keyFile) {
+		return tls.LoadX509KeyPair(certFile, keyFile)
+	}
+	signer, err := kms.NewSigner(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return loadX509KeyPairWithSigner(certFile, signer)
+}
+
+// loadX509KeyPairWithSigner reads the certificate chain in certFile and
+// returns a tls.Certificate with the given signer as private key.
+func loadX509KeyPairWithSigner(certFile string, signer crypto.Signer) (tls.Certificate, error) {
+	var cert tls.Certificate
+	b, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return cert, errors.Wrapf(err, "error reading %s", certFile)
+	}
+	for {
+		var block *pem.Block
+		if block, b = pem.Decode(b); block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+	if len(cert.Certificate) == 0 {
+		return cert, errors.Errorf("error decoding %s: no certificates found", certFile)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return cert, errors.Wrapf(err, "error parsing %s", certFile)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return cert, errors.Wrap(err, "error marshaling public key")
+	}
+	if !bytes.Equal(pub, leaf.RawSubjectPublicKeyInfo) {
+		return cert, errors.Errorf("the public key in %s does not match the private key", certFile)
+	}
+
+	cert.PrivateKey = signer
+	cert.Leaf = leaf
+	return cert, nil
+}
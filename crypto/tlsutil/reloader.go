@@ -0,0 +1,153 @@
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms"
+)
+
+// DefaultReloadInterval is the default minimum time between two checks of the
+// certificate files.
+const DefaultReloadInterval = 30 * time.Second
+
+// CertificateReloader is a certificate provider for tls.Config that reloads a
+// certificate and key pair when they change on disk, for example after a
+// renewal. The key can also be a KMS key URI, in that case only the
+// certificate file will be reloaded.
+type CertificateReloader struct {
+	certFile  string
+	keyFile   string
+	signer    crypto.Signer
+	interval  time.Duration
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+	onReload  func(*tls.Certificate, error)
+}
+
+// NewCertificateReloader creates a new CertificateReloader that loads the
+// given certificate and key files. The files will be checked for changes at
+// most once per interval, if the interval is zero DefaultReloadInterval will
+// be used.
+func NewCertificateReloader(certFile, keyFile string, interval time.Duration) (*CertificateReloader, error) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OnReload sets a function that will be called after the certificate has been
+// reloaded or after a reload has failed.
+func (r *CertificateReloader) OnReload(fn func(*tls.Certificate, error)) {
+	r.mu.Lock()
+	r.onReload = fn
+	r.mu.Unlock()
+}
+
+// Certificate returns the current certificate, reloading it if the files have
+// changed. If a reload fails the previous certificate will be returned.
+func (r *CertificateReloader) Certificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := time.Now(); now.Sub(r.lastCheck) >= r.interval {
+		r.lastCheck = now
+		if r.modified() {
+			err := r.load()
+			if r.onReload != nil {
+				r.onReload(r.cert, err)
+			}
+		}
+	}
+	return r.cert
+}
+
+// Reload forces the reload of the certificate and key files.
+func (r *CertificateReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCheck = time.Now()
+	err := r.load()
+	if r.onReload != nil {
+		r.onReload(r.cert, err)
+	}
+	return err
+}
+
+// GetCertificate implements the GetCertificate method of tls.Config.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// GetClientCertificate implements the GetClientCertificate method of
+// tls.Config.
+func (r *CertificateReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// fileModTime returns the most recent modification time of the certificate and
+// key files.
+func (r *CertificateReloader) fileModTime() (time.Time, error) {
+	var t time.Time
+	files := []string{r.certFile}
+	if r.signer == nil {
+		files = append(files, r.keyFile)
+	}
+	for _, fn := range files {
+		st, err := os.Stat(fn)
+		if err != nil {
+			return t, err
+		}
+		if st.ModTime().After(t) {
+			t = st.ModTime()
+		}
+	}
+	return t, nil
+}
+
+// modified returns true if the files have been modified since the last load.
+func (r *CertificateReloader) modified() bool {
+	t, err := r.fileModTime()
+	return err == nil && !t.Equal(r.modTime)
+}
+
+// load reads the certificate and key files, it must be called with the lock.
+func (r *CertificateReloader) load() error {
+	if r.signer == nil && kms.IsKMS(r.keyFile) {
+		signer, err := kms.NewSigner(r.keyFile)
+		if err != nil {
+			return err
+		}
+		r.signer = signer
+	}
+	t, err := r.fileModTime()
+	if err != nil {
+		return errors.Wrap(err, "error reading certificate")
+	}
+	var cert tls.Certificate
+	if r.signer != nil {
+		cert, err = loadX509KeyPairWithSigner(r.certFile, r.signer)
+	} else {
+		cert, err = tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error loading certificate %s and key %s", r.certFile, r.keyFile)
+	}
+	r.cert = &cert
+	r.modTime = t
+	return nil
+}
@@ -0,0 +1,137 @@
+package tlsutil
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/x509util"
+)
+
+// RootsReloader is a provider of the root certificates used by long-running
+// commands that reloads them when they change on disk, for example after a
+// root rotation. The name of the file is resolved on every check, so it can
+// also change, for example if it is defined in the defaults.json.
+type RootsReloader struct {
+	filename  func() (string, error)
+	interval  time.Duration
+	mu        sync.Mutex
+	file      string
+	pool      *x509.CertPool
+	modTime   time.Time
+	lastCheck time.Time
+	onReload  func(string, error)
+}
+
+// NewRootsReloader creates a new RootsReloader that loads the root
+// certificates in the file returned by the given function. The file will be
+// checked for changes at most once per interval, if the interval is zero
+// DefaultReloadInterval will be used.
+func NewRootsReloader(filename func() (string, error), interval time.Duration) (*RootsReloader, error) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	r := &RootsReloader{
+		filename: filename,
+		interval: interval,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OnReload sets a function that will be called with the name of the file
+// after the root certificates have been reloaded or after a reload has failed.
+func (r *RootsReloader) OnReload(fn func(string, error)) {
+	r.mu.Lock()
+	r.onReload = fn
+	r.mu.Unlock()
+}
+
+// Pool returns the current root certificates, reloading them if the file has
+// changed. If a reload fails the previous certificates will be returned.
+func (r *RootsReloader) Pool() *x509.CertPool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := time.Now(); now.Sub(r.lastCheck) >= r.interval {
+		r.lastCheck = now
+		if r.modified() {
+			err := r.load()
+			if r.onReload != nil {
+				r.onReload(r.file, err)
+			}
+		}
+	}
+	return r.pool
+}
+
+// Reload forces the reload of the root certificates.
+func (r *RootsReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCheck = time.Now()
+	err := r.load()
+	if r.onReload != nil {
+		r.onReload(r.file, err)
+	}
+	return err
+}
+
+// modified returns true if the file name or its contents have changed since
+// the last load.
+func (r *RootsReloader) modified() bool {
+	file, err := r.filename()
+	if err != nil {
+		// Report the error on load
+		return true
+	}
+	return file != r.file || !rootsModTime(file).Equal(r.modTime)
+}
+
+// load reads the root certificates, it must be called with the lock.
+func (r *RootsReloader) load() error {
+	file, err := r.filename()
+	if err != nil {
+		return err
+	}
+	t := rootsModTime(file)
+	pool, err := x509util.ReadCertPool(file)
+	if err != nil {
+		return errors.Wrapf(err, "error loading root certificates from %s", file)
+	}
+	r.file = file
+	r.pool = pool
+	r.modTime = t
+	return nil
+}
+
+// rootsModTime returns the most recent modification time of the root files,
+// the path can be a file, a comma-separated list of files, or a directory, as
+// in x509util.ReadCertPool. The files that cannot be stat, like URLs, are
+// ignored.
+func rootsModTime(path string) time.Time {
+	var t time.Time
+	files := strings.Split(path, ",")
+	if st, err := os.Stat(path); err == nil && st.IsDir() {
+		t = st.ModTime()
+		files = nil
+		if infos, err := ioutil.ReadDir(path); err == nil {
+			for _, info := range infos {
+				files = append(files, filepath.Join(path, info.Name()))
+			}
+		}
+	}
+	for _, fn := range files {
+		if st, err := os.Stat(strings.TrimSpace(fn)); err == nil && st.ModTime().After(t) {
+			t = st.ModTime()
+		}
+	}
+	return t
+}
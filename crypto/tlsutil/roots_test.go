@@ -0,0 +1,91 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestRoot(t *testing.T, filename, cn string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: der,
+	}), 0600))
+	return crt
+}
+
+func TestRootsReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "roots")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rootFile := filepath.Join(dir, "root_ca.crt")
+	otherFile := filepath.Join(dir, "other_ca.crt")
+	root := writeTestRoot(t, rootFile, "Root CA")
+	other := writeTestRoot(t, otherFile, "Other CA")
+
+	file := rootFile
+	r, err := NewRootsReloader(func() (string, error) { return file, nil }, time.Nanosecond)
+	require.NoError(t, err)
+	var reloaded []string
+	r.OnReload(func(fn string, err error) {
+		reloaded = append(reloaded, fn)
+	})
+
+	pool := r.Pool()
+	require.Equal(t, [][]byte{root.RawSubject}, pool.Subjects())
+	// Not modified
+	require.True(t, pool == r.Pool())
+	require.Len(t, reloaded, 0)
+
+	// The file changes
+	time.Sleep(10 * time.Millisecond)
+	rotated := writeTestRoot(t, rootFile, "Rotated Root CA")
+	require.NoError(t, os.Chtimes(rootFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	require.Equal(t, [][]byte{rotated.RawSubject}, r.Pool().Subjects())
+	require.Equal(t, []string{rootFile}, reloaded)
+
+	// The name of the file changes
+	file = otherFile
+	require.Equal(t, [][]byte{other.RawSubject}, r.Pool().Subjects())
+	require.Equal(t, []string{rootFile, otherFile}, reloaded)
+
+	// A failed reload keeps the previous roots
+	file = filepath.Join(dir, "missing.crt")
+	require.Equal(t, [][]byte{other.RawSubject}, r.Pool().Subjects())
+	require.Error(t, r.Reload())
+
+	// A directory
+	file = dir
+	require.NoError(t, r.Reload())
+	require.Len(t, r.Pool().Subjects(), 2)
+
+	_, err = NewRootsReloader(func() (string, error) { return filepath.Join(dir, "missing.crt"), nil }, 0)
+	require.Error(t, err)
+}
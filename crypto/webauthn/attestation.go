@@ -0,0 +1,437 @@
+// Package webauthn implements the verification of WebAuthn attestation
+// objects, the statements created by the authenticators during the
+// registration of a credential. The packed, tpm, apple, android-key and none
+// attestation formats are supported.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Attestation formats.
+const (
+	FormatNone       = "none"
+	FormatPacked     = "packed"
+	FormatTPM        = "tpm"
+	FormatApple      = "apple"
+	FormatAndroidKey = "android-key"
+)
+
+// Attestation types.
+const (
+	TypeNone  = "none"
+	TypeSelf  = "self"
+	TypeBasic = "basic"
+)
+
+var (
+	oidFIDOAAGUID          = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+	oidAppleNonce          = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 2}
+	oidAndroidKey          = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 1, 17}
+	oidTCGKPAIKCertificate = asn1.ObjectIdentifier{2, 23, 133, 8, 3}
+	oidSubjectAltName      = asn1.ObjectIdentifier{2, 5, 29, 17}
+)
+
+// ClientData is the client data collected by the browser during the
+// registration.
+type ClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+	Raw       []byte `json:"-"`
+}
+
+// ParseClientData parses the clientDataJSON of a registration.
+func ParseClientData(b []byte) (*ClientData, error) {
+	cd := new(ClientData)
+	if err := json.Unmarshal(b, cd); err != nil {
+		return nil, errors.Wrap(err, "error parsing client data")
+	}
+	cd.Raw = b
+	return cd, nil
+}
+
+// Hash returns the SHA-256 hash of the client data.
+func (cd *ClientData) Hash() []byte {
+	sum := sha256.Sum256(cd.Raw)
+	return sum[:]
+}
+
+// AttestationObject is a WebAuthn attestation object.
+type AttestationObject struct {
+	Format   string
+	AttStmt  map[interface{}]interface{}
+	AuthData *AuthenticatorData
+}
+
+// ParseAttestationObject parses the CBOR encoded attestation object.
+func ParseAttestationObject(b []byte) (*AttestationObject, error) {
+	v, n, err := decodeCBOR(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing attestation object")
+	}
+	if n != len(b) {
+		return nil, errors.New("error parsing attestation object: unexpected trailing data")
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("error parsing attestation object: object must be a map")
+	}
+	format, _ := m["fmt"].(string)
+	attStmt, _ := m["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := m["authData"].([]byte)
+	if format == "" || attStmt == nil || rawAuthData == nil {
+		return nil, errors.New("error parsing attestation object: fmt, attStmt and authData are required")
+	}
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.PublicKey == nil {
+		return nil, errors.New("error parsing attestation object: authData does not have attested credential data")
+	}
+	return &AttestationObject{
+		Format:   format,
+		AttStmt:  attStmt,
+		AuthData: authData,
+	}, nil
+}
+
+// VerifyOptions are the options used to verify an attestation object.
+type VerifyOptions struct {
+	// Roots are the trusted attestation roots.
+	Roots *x509.CertPool
+	// Metadata is an optional metadata service blob with the attestation
+	// roots of the authenticators.
+	Metadata *Metadata
+	// CurrentTime is the time used to validate the certificate chain. If
+	// zero, the current time is used.
+	CurrentTime time.Time
+}
+
+// Result is the result of the verification of an attestation object.
+type Result struct {
+	Format       string
+	Type         string
+	Algorithm    int64
+	AuthData     *AuthenticatorData
+	Certificates []*x509.Certificate
+	// Statement is the metadata statement of the authenticator, if found.
+	Statement *MetadataStatement
+	// Trusted is true if the attestation certificate chains to one of the
+	// trusted roots.
+	Trusted bool
+}
+
+// Verify verifies the attestation statement using the hash of the client
+// data and returns the details of the authenticator. If the statement has a
+// certificate chain, and trusted roots are available, the chain must be
+// valid.
+func (o *AttestationObject) Verify(clientDataHash []byte, opts VerifyOptions) (*Result, error) {
+	res := &Result{
+		Format:   o.Format,
+		AuthData: o.AuthData,
+	}
+	attToBeSigned := append(append([]byte{}, o.AuthData.Raw...), clientDataHash...)
+
+	alg, _ := o.AttStmt["alg"].(int64)
+	sig, _ := o.AttStmt["sig"].([]byte)
+	certs, err := o.certificates()
+	if err != nil {
+		return nil, err
+	}
+	res.Algorithm = alg
+	res.Certificates = certs
+
+	switch o.Format {
+	case FormatNone:
+		if len(o.AttStmt) != 0 {
+			return nil, errors.New("error verifying none attestation: attStmt must be empty")
+		}
+		res.Type = TypeNone
+		return res, nil
+	case FormatPacked:
+		err = o.verifyPacked(res, attToBeSigned, alg, sig)
+	case FormatTPM:
+		err = o.verifyTPM(res, attToBeSigned, alg, sig)
+	case FormatApple:
+		err = o.verifyApple(res, attToBeSigned)
+	case FormatAndroidKey:
+		err = o.verifyAndroidKey(res, attToBeSigned, clientDataHash, alg, sig)
+	default:
+		return nil, errors.Errorf("unsupported attestation format %s", o.Format)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error verifying %s attestation", o.Format)
+	}
+
+	if res.Type == TypeSelf {
+		return res, nil
+	}
+	if err := verifyChain(res, opts); err != nil {
+		return nil, errors.Wrapf(err, "error verifying %s attestation", o.Format)
+	}
+	return res, nil
+}
+
+func (o *AttestationObject) certificates() ([]*x509.Certificate, error) {
+	x5c, ok := o.AttStmt["x5c"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	certs := make([]*x509.Certificate, len(x5c))
+	for i, v := range x5c {
+		der, ok := v.([]byte)
+		if !ok {
+			return nil, errors.New("error parsing attestation certificates: invalid x5c")
+		}
+		crt, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing attestation certificates")
+		}
+		certs[i] = crt
+	}
+	return certs, nil
+}
+
+func (o *AttestationObject) verifyPacked(res *Result, attToBeSigned []byte, alg int64, sig []byte) error {
+	if len(res.Certificates) == 0 {
+		// Self attestation
+		if alg != o.AuthData.PublicKeyAlgorithm {
+			return errors.New("alg does not match the credential public key algorithm")
+		}
+		if err := verifySignature(alg, o.AuthData.PublicKey, attToBeSigned, sig); err != nil {
+			return err
+		}
+		res.Type = TypeSelf
+		return nil
+	}
+
+	leaf := res.Certificates[0]
+	if err := verifySignature(alg, leaf.PublicKey, attToBeSigned, sig); err != nil {
+		return err
+	}
+	if leaf.Version != 3 || leaf.IsCA {
+		return errors.New("attestation certificate must be a version 3 end-entity certificate")
+	}
+	if err := verifyAAGUIDExtension(leaf, o.AuthData.AAGUID); err != nil {
+		return err
+	}
+	res.Type = TypeBasic
+	return nil
+}
+
+func (o *AttestationObject) verifyTPM(res *Result, attToBeSigned []byte, alg int64, sig []byte) error {
+	if ver, _ := o.AttStmt["ver"].(string); ver != "2.0" {
+		return errors.New("unsupported TPM version")
+	}
+	if len(res.Certificates) == 0 {
+		return errors.New("x5c is required, ECDAA is not supported")
+	}
+	certInfo, _ := o.AttStmt["certInfo"].([]byte)
+	pubArea, _ := o.AttStmt["pubArea"].([]byte)
+	if certInfo == nil || pubArea == nil {
+		return errors.New("certInfo and pubArea are required")
+	}
+
+	pub, _, err := parseTPMPublic(pubArea)
+	if err != nil {
+		return err
+	}
+	if !publicKeyEqual(pub, o.AuthData.PublicKey) {
+		return errors.New("pubArea key does not match the credential public key")
+	}
+	if err := verifyTPMCertifyInfo(certInfo, pubArea, alg, attToBeSigned); err != nil {
+		return err
+	}
+
+	aik := res.Certificates[0]
+	if err := verifySignature(alg, aik.PublicKey, certInfo, sig); err != nil {
+		return err
+	}
+	if aik.Version != 3 || aik.IsCA || len(aik.Subject.Names) > 0 {
+		return errors.New("AIK certificate must be a version 3 end-entity certificate with an empty subject")
+	}
+	var hasAIKUsage bool
+	for _, oid := range aik.UnknownExtKeyUsage {
+		if oid.Equal(oidTCGKPAIKCertificate) {
+			hasAIKUsage = true
+		}
+	}
+	if !hasAIKUsage {
+		return errors.New("AIK certificate does not have the tcg-kp-AIKCertificate extended key usage")
+	}
+	if err := verifyAAGUIDExtension(aik, o.AuthData.AAGUID); err != nil {
+		return err
+	}
+	res.Type = TypeBasic
+	return nil
+}
+
+func (o *AttestationObject) verifyApple(res *Result, attToBeSigned []byte) error {
+	if len(res.Certificates) == 0 {
+		return errors.New("x5c is required")
+	}
+	leaf := res.Certificates[0]
+	nonce := sha256.Sum256(attToBeSigned)
+
+	var found bool
+	for _, ext := range leaf.Extensions {
+		if !ext.Id.Equal(oidAppleNonce) {
+			continue
+		}
+		var v struct {
+			Nonce []byte `asn1:"tag:1,explicit"`
+		}
+		if _, err := asn1.Unmarshal(ext.Value, &v); err != nil {
+			return errors.Wrap(err, "error parsing nonce extension")
+		}
+		if !bytes.Equal(v.Nonce, nonce[:]) {
+			return errors.New("certificate nonce does not match the attested data")
+		}
+		found = true
+	}
+	if !found {
+		return errors.New("certificate does not have the nonce extension")
+	}
+	if !publicKeyEqual(leaf.PublicKey, o.AuthData.PublicKey) {
+		return errors.New("certificate key does not match the credential public key")
+	}
+	res.Type = TypeBasic
+	return nil
+}
+
+// androidKeyDescription is the beginning of the KeyDescription structure of
+// the Android key attestation extension.
+type androidKeyDescription struct {
+	AttestationVersion       int
+	AttestationSecurityLevel asn1.Enumerated
+	KeymasterVersion         int
+	KeymasterSecurityLevel   asn1.Enumerated
+	AttestationChallenge     []byte
+	UniqueID                 []byte
+	SoftwareEnforced         asn1.RawValue
+	TeeEnforced              asn1.RawValue
+}
+
+func (o *AttestationObject) verifyAndroidKey(res *Result, attToBeSigned, clientDataHash []byte, alg int64, sig []byte) error {
+	if len(res.Certificates) == 0 {
+		return errors.New("x5c is required")
+	}
+	leaf := res.Certificates[0]
+	if err := verifySignature(alg, leaf.PublicKey, attToBeSigned, sig); err != nil {
+		return err
+	}
+	if !publicKeyEqual(leaf.PublicKey, o.AuthData.PublicKey) {
+		return errors.New("certificate key does not match the credential public key")
+	}
+
+	var found bool
+	for _, ext := range leaf.Extensions {
+		if !ext.Id.Equal(oidAndroidKey) {
+			continue
+		}
+		var kd androidKeyDescription
+		if _, err := asn1.Unmarshal(ext.Value, &kd); err != nil {
+			return errors.Wrap(err, "error parsing key description extension")
+		}
+		if !bytes.Equal(kd.AttestationChallenge, clientDataHash) {
+			return errors.New("attestation challenge does not match the client data hash")
+		}
+		found = true
+	}
+	if !found {
+		return errors.New("certificate does not have the key description extension")
+	}
+	res.Type = TypeBasic
+	return nil
+}
+
+// verifyAAGUIDExtension checks that the AAGUID extension, if present,
+// matches the AAGUID in the authenticator data.
+func verifyAAGUIDExtension(crt *x509.Certificate, aaguid []byte) error {
+	for _, ext := range crt.Extensions {
+		if !ext.Id.Equal(oidFIDOAAGUID) {
+			continue
+		}
+		if ext.Critical {
+			return errors.New("AAGUID extension must not be critical")
+		}
+		var v []byte
+		if _, err := asn1.Unmarshal(ext.Value, &v); err != nil {
+			return errors.Wrap(err, "error parsing AAGUID extension")
+		}
+		if !bytes.Equal(v, aaguid) {
+			return errors.New("certificate AAGUID does not match the authenticator data")
+		}
+	}
+	return nil
+}
+
+// verifyChain validates the attestation certificate chain with the trusted
+// roots and the roots in the metadata of the authenticator.
+func verifyChain(res *Result, opts VerifyOptions) error {
+	leaf := res.Certificates[0]
+	var pools []*x509.CertPool
+	if opts.Roots != nil {
+		pools = append(pools, opts.Roots)
+	}
+	if opts.Metadata != nil {
+		if res.Statement = opts.Metadata.Lookup(res.AuthData.AAGUIDString()); res.Statement == nil {
+			res.Statement = opts.Metadata.LookupKeyIdentifier(hex.EncodeToString(leaf.SubjectKeyId))
+		}
+		if res.Statement != nil {
+			certs, err := res.Statement.Roots()
+			if err != nil {
+				return err
+			}
+			if len(certs) > 0 {
+				pool := x509.NewCertPool()
+				for _, crt := range certs {
+					pool.AddCert(crt)
+				}
+				pools = append(pools, pool)
+			}
+		}
+	}
+	if len(pools) == 0 {
+		return nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, crt := range res.Certificates[1:] {
+		intermediates.AddCert(crt)
+	}
+
+	// TPM AIK certificates have a critical subject alternative name with
+	// only a directory name, not handled by the x509 package.
+	var unhandled []asn1.ObjectIdentifier
+	for _, oid := range leaf.UnhandledCriticalExtensions {
+		if !(res.Format == FormatTPM && oid.Equal(oidSubjectAltName)) {
+			unhandled = append(unhandled, oid)
+		}
+	}
+	leaf.UnhandledCriticalExtensions = unhandled
+
+	var err error
+	for _, roots := range pools {
+		if _, err = leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   opts.CurrentTime,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err == nil {
+			res.Trusted = true
+			return nil
+		}
+	}
+	return errors.Wrap(err, "error validating attestation certificate chain")
+}
@@ -0,0 +1,389 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+)
+
+// encodeCBOR is a minimal CBOR encoder used to create test attestations.
+// Maps are encoded from a slice of key-value pairs to keep the order.
+func encodeCBOR(v interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			b := []byte{major<<5 | 25, 0, 0}
+			binary.BigEndian.PutUint16(b[1:], uint16(n))
+			return b
+		default:
+			b := []byte{major<<5 | 26, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(b[1:], uint32(n))
+			return b
+		}
+	}
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case []interface{}:
+		b := head(4, uint64(len(v)))
+		for _, e := range v {
+			b = append(b, encodeCBOR(e)...)
+		}
+		return b
+	case [][2]interface{}:
+		b := head(5, uint64(len(v)))
+		for _, kv := range v {
+			b = append(b, encodeCBOR(kv[0])...)
+			b = append(b, encodeCBOR(kv[1])...)
+		}
+		return b
+	default:
+		panic("unsupported type")
+	}
+}
+
+func coseEC2Key(pub *ecdsa.PublicKey) []byte {
+	return encodeCBOR([][2]interface{}{
+		{coseKty, coseKeyTypeEC2},
+		{coseAlg, AlgES256},
+		{coseCrv, coseCurveP256},
+		{coseX, pub.X.Bytes()},
+		{coseY, pub.Y.Bytes()},
+	})
+}
+
+func testAuthData(aaguid []byte, pub *ecdsa.PublicKey) []byte {
+	rpIDHash := sha256.Sum256([]byte("example.com"))
+	b := append([]byte{}, rpIDHash[:]...)
+	b = append(b, FlagUserPresent|FlagUserVerified|FlagAttestedCredentialData)
+	b = append(b, 0, 0, 0, 7)
+	b = append(b, aaguid...)
+	b = append(b, 0, 4, 1, 2, 3, 4)
+	return append(b, coseEC2Key(pub)...)
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	sum := sha256.Sum256(data)
+	sig, err := key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	assert.FatalError(t, err)
+	return sig
+}
+
+func testCertificate(t *testing.T, tmpl *x509.Certificate, pub crypto.PublicKey, parent *x509.Certificate, key crypto.Signer) *x509.Certificate {
+	if tmpl.SerialNumber == nil {
+		tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	}
+	tmpl.NotBefore = time.Now().Add(-time.Minute)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent = tmpl
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, key)
+	assert.FatalError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	assert.FatalError(t, err)
+	return crt
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want interface{}
+	}{
+		{[]byte{0x00}, int64(0)},
+		{[]byte{0x18, 0x64}, int64(100)},
+		{[]byte{0x39, 0x01, 0x00}, int64(-257)},
+		{[]byte{0x43, 1, 2, 3}, []byte{1, 2, 3}},
+		{[]byte{0x63, 'f', 'm', 't'}, "fmt"},
+		{[]byte{0x82, 0x01, 0x20}, []interface{}{int64(1), int64(-1)}},
+		{[]byte{0xa1, 0x01, 0xf5}, map[interface{}]interface{}{int64(1): true}},
+		{[]byte{0xf9, 0x3c, 0x00}, float64(1)},
+		{[]byte{0xc2, 0x41, 0x01}, []byte{1}},
+	}
+	for _, tc := range tests {
+		v, n, err := decodeCBOR(tc.in)
+		assert.FatalError(t, err)
+		assert.Equals(t, len(tc.in), n)
+		assert.Equals(t, tc.want, v)
+	}
+
+	for _, in := range [][]byte{{}, {0x43, 1}, {0x9f}, {0x82, 0x01}, {0xa1, 0x80, 0x01}} {
+		_, _, err := decodeCBOR(in)
+		assert.Error(t, err)
+	}
+}
+
+func TestVerifyPacked(t *testing.T) {
+	credKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	attKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	aaguid := bytes.Repeat([]byte{0xab}, 16)
+	aaguidExt, err := asn1.Marshal(aaguid)
+	assert.FatalError(t, err)
+	root := testCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, rootKey.Public(), nil, rootKey)
+	leaf := testCertificate(t, &x509.Certificate{
+		Subject: pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"Test"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Test Authenticator",
+		},
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{{Id: oidFIDOAAGUID, Value: aaguidExt}},
+	}, attKey.Public(), root, rootKey)
+
+	authData := testAuthData(aaguid, &credKey.PublicKey)
+	clientDataHash := sha256.Sum256([]byte(`{"type":"webauthn.create"}`))
+	attToBeSigned := append(append([]byte{}, authData...), clientDataHash[:]...)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	t.Run("basic", func(t *testing.T) {
+		obj, err := ParseAttestationObject(encodeCBOR([][2]interface{}{
+			{"fmt", "packed"},
+			{"attStmt", [][2]interface{}{
+				{"alg", AlgES256},
+				{"sig", sign(t, attKey, attToBeSigned)},
+				{"x5c", []interface{}{leaf.Raw}},
+			}},
+			{"authData", authData},
+		}))
+		assert.FatalError(t, err)
+		assert.Equals(t, "abababab-abab-abab-abab-abababababab", obj.AuthData.AAGUIDString())
+		assert.Equals(t, []byte{1, 2, 3, 4}, obj.AuthData.CredentialID)
+		assert.Equals(t, uint32(7), obj.AuthData.SignCount)
+		assert.Equals(t, []string{"UP", "UV", "AT"}, obj.AuthData.FlagNames())
+		assert.True(t, publicKeyEqual(&credKey.PublicKey, obj.AuthData.PublicKey))
+
+		res, err := obj.Verify(clientDataHash[:], VerifyOptions{})
+		assert.FatalError(t, err)
+		assert.Equals(t, TypeBasic, res.Type)
+		assert.False(t, res.Trusted)
+
+		res, err = obj.Verify(clientDataHash[:], VerifyOptions{Roots: roots})
+		assert.FatalError(t, err)
+		assert.True(t, res.Trusted)
+
+		_, err = obj.Verify(clientDataHash[:], VerifyOptions{Roots: x509.NewCertPool()})
+		assert.Error(t, err)
+		_, err = obj.Verify(make([]byte, 32), VerifyOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		obj, err := ParseAttestationObject(encodeCBOR([][2]interface{}{
+			{"fmt", "packed"},
+			{"attStmt", [][2]interface{}{
+				{"alg", AlgES256},
+				{"sig", sign(t, attKey, attToBeSigned)},
+				{"x5c", []interface{}{leaf.Raw}},
+			}},
+			{"authData", authData},
+		}))
+		assert.FatalError(t, err)
+		md, err := ParseMetadata([]byte(`{"entries":[{"aaguid":"abababab-abab-abab-abab-abababababab","metadataStatement":{"description":"Test Authenticator","attestationRootCertificates":["` +
+			base64.StdEncoding.EncodeToString(root.Raw) + `"]}}]}`))
+		assert.FatalError(t, err)
+		res, err := obj.Verify(clientDataHash[:], VerifyOptions{Metadata: md})
+		assert.FatalError(t, err)
+		assert.True(t, res.Trusted)
+		assert.Equals(t, "Test Authenticator", res.Statement.Description)
+	})
+
+	t.Run("self", func(t *testing.T) {
+		obj, err := ParseAttestationObject(encodeCBOR([][2]interface{}{
+			{"fmt", "packed"},
+			{"attStmt", [][2]interface{}{
+				{"alg", AlgES256},
+				{"sig", sign(t, credKey, attToBeSigned)},
+			}},
+			{"authData", authData},
+		}))
+		assert.FatalError(t, err)
+		res, err := obj.Verify(clientDataHash[:], VerifyOptions{Roots: roots})
+		assert.FatalError(t, err)
+		assert.Equals(t, TypeSelf, res.Type)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		obj, err := ParseAttestationObject(encodeCBOR([][2]interface{}{
+			{"fmt", "none"},
+			{"attStmt", [][2]interface{}{}},
+			{"authData", authData},
+		}))
+		assert.FatalError(t, err)
+		res, err := obj.Verify(clientDataHash[:], VerifyOptions{})
+		assert.FatalError(t, err)
+		assert.Equals(t, TypeNone, res.Type)
+	})
+}
+
+func TestVerifyAppleAndAndroidKey(t *testing.T) {
+	credKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	root := testCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, rootKey.Public(), nil, rootKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	authData := testAuthData(make([]byte, 16), &credKey.PublicKey)
+	clientDataHash := sha256.Sum256([]byte(`{"type":"webauthn.create"}`))
+	attToBeSigned := append(append([]byte{}, authData...), clientDataHash[:]...)
+
+	// Apple
+	nonce := sha256.Sum256(attToBeSigned)
+	nonceExt, err := asn1.Marshal(struct {
+		Nonce []byte `asn1:"tag:1,explicit"`
+	}{nonce[:]})
+	assert.FatalError(t, err)
+	leaf := testCertificate(t, &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "Apple"},
+		ExtraExtensions: []pkix.Extension{{Id: oidAppleNonce, Value: nonceExt}},
+	}, credKey.Public(), root, rootKey)
+	obj, err := ParseAttestationObject(encodeCBOR([][2]interface{}{
+		{"fmt", "apple"},
+		{"attStmt", [][2]interface{}{
+			{"x5c", []interface{}{leaf.Raw}},
+		}},
+		{"authData", authData},
+	}))
+	assert.FatalError(t, err)
+	res, err := obj.Verify(clientDataHash[:], VerifyOptions{Roots: roots})
+	assert.FatalError(t, err)
+	assert.True(t, res.Trusted)
+	_, err = obj.Verify(make([]byte, 32), VerifyOptions{Roots: roots})
+	assert.Error(t, err)
+
+	// Android key
+	kd, err := asn1.Marshal(androidKeyDescription{
+		AttestationVersion:   3,
+		KeymasterVersion:     4,
+		AttestationChallenge: clientDataHash[:],
+		UniqueID:             []byte{},
+		SoftwareEnforced:     asn1.RawValue{FullBytes: []byte{0x30, 0x00}},
+		TeeEnforced:          asn1.RawValue{FullBytes: []byte{0x30, 0x00}},
+	})
+	assert.FatalError(t, err)
+	leaf = testCertificate(t, &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "Android Keystore Key"},
+		ExtraExtensions: []pkix.Extension{{Id: oidAndroidKey, Value: kd}},
+	}, credKey.Public(), root, rootKey)
+	obj, err = ParseAttestationObject(encodeCBOR([][2]interface{}{
+		{"fmt", "android-key"},
+		{"attStmt", [][2]interface{}{
+			{"alg", AlgES256},
+			{"sig", sign(t, credKey, attToBeSigned)},
+			{"x5c", []interface{}{leaf.Raw}},
+		}},
+		{"authData", authData},
+	}))
+	assert.FatalError(t, err)
+	res, err = obj.Verify(clientDataHash[:], VerifyOptions{Roots: roots})
+	assert.FatalError(t, err)
+	assert.True(t, res.Trusted)
+}
+
+func TestVerifyTPM(t *testing.T) {
+	credKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	aikKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+
+	// TPMT_PUBLIC of an RSA key with SHA-256 as name algorithm
+	pubArea := []byte{0x00, 0x01, 0x00, 0x0b, 0x00, 0x06, 0x04, 0x72, 0x00, 0x00, 0x00, 0x10, 0x00, 0x10, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}
+	pubArea = append(pubArea, credKey.N.Bytes()...)
+
+	rpIDHash := sha256.Sum256([]byte("example.com"))
+	authData := append([]byte{}, rpIDHash[:]...)
+	authData = append(authData, FlagUserPresent|FlagAttestedCredentialData, 0, 0, 0, 0)
+	authData = append(authData, make([]byte, 16)...)
+	authData = append(authData, 0, 1, 9)
+	authData = append(authData, encodeCBOR([][2]interface{}{
+		{coseKty, coseKeyTypeRSA},
+		{coseAlg, AlgRS256},
+		{coseN, credKey.N.Bytes()},
+		{coseE, []byte{1, 0, 1}},
+	})...)
+	clientDataHash := sha256.Sum256([]byte(`{"type":"webauthn.create"}`))
+	extraData := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	// TPMS_ATTEST
+	tpm2b := func(b []byte) []byte {
+		return append([]byte{byte(len(b) >> 8), byte(len(b))}, b...)
+	}
+	certInfo := []byte{0xff, 0x54, 0x43, 0x47, 0x80, 0x17}
+	certInfo = append(certInfo, tpm2b([]byte{1, 2})...)
+	certInfo = append(certInfo, tpm2b(extraData[:])...)
+	certInfo = append(certInfo, make([]byte, 17+8)...)
+	certInfo = append(certInfo, tpm2b(tpmName(pubArea, crypto.SHA256))...)
+	certInfo = append(certInfo, tpm2b(nil)...)
+	sum := sha256.Sum256(certInfo)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, aikKey, crypto.SHA256, sum[:])
+	assert.FatalError(t, err)
+
+	aik := testCertificate(t, &x509.Certificate{
+		BasicConstraintsValid: true,
+		UnknownExtKeyUsage:    []asn1.ObjectIdentifier{oidTCGKPAIKCertificate},
+	}, aikKey.Public(), nil, aikKey)
+
+	obj, err := ParseAttestationObject(encodeCBOR([][2]interface{}{
+		{"fmt", "tpm"},
+		{"attStmt", [][2]interface{}{
+			{"ver", "2.0"},
+			{"alg", AlgRS256},
+			{"x5c", []interface{}{aik.Raw}},
+			{"sig", sig},
+			{"certInfo", certInfo},
+			{"pubArea", pubArea},
+		}},
+		{"authData", authData},
+	}))
+	assert.FatalError(t, err)
+	res, err := obj.Verify(clientDataHash[:], VerifyOptions{})
+	assert.FatalError(t, err)
+	assert.Equals(t, TypeBasic, res.Type)
+
+	_, err = obj.Verify(make([]byte, 32), VerifyOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package webauthn
+
+import (
+	"crypto"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Authenticator data flags.
+const (
+	FlagUserPresent            = 0x01
+	FlagUserVerified           = 0x04
+	FlagBackupEligible         = 0x08
+	FlagBackupState            = 0x10
+	FlagAttestedCredentialData = 0x40
+	FlagExtensionData          = 0x80
+)
+
+var flagNames = []struct {
+	flag byte
+	name string
+}{
+	{FlagUserPresent, "UP"},
+	{FlagUserVerified, "UV"},
+	{FlagBackupEligible, "BE"},
+	{FlagBackupState, "BS"},
+	{FlagAttestedCredentialData, "AT"},
+	{FlagExtensionData, "ED"},
+}
+
+// AuthenticatorData is the data returned by the authenticator, with the
+// credential created during a registration.
+type AuthenticatorData struct {
+	Raw          []byte
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	// CredentialPublicKey is the COSE encoded public key.
+	CredentialPublicKey []byte
+	// PublicKey is the decoded credential public key.
+	PublicKey crypto.PublicKey
+	// PublicKeyAlgorithm is the COSE algorithm of the credential public key.
+	PublicKeyAlgorithm int64
+	Extensions         map[interface{}]interface{}
+}
+
+// ParseAuthenticatorData parses the authenticator data.
+func ParseAuthenticatorData(b []byte) (*AuthenticatorData, error) {
+	if len(b) < 37 {
+		return nil, errors.New("error parsing authenticator data: data is too short")
+	}
+	ad := &AuthenticatorData{
+		Raw:       b,
+		RPIDHash:  b[:32],
+		Flags:     b[32],
+		SignCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	rest := b[37:]
+
+	if ad.Flags&FlagAttestedCredentialData != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("error parsing authenticator data: attested credential data is too short")
+		}
+		ad.AAGUID = rest[:16]
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < n {
+			return nil, errors.New("error parsing authenticator data: credential id is too short")
+		}
+		ad.CredentialID = rest[:n]
+		rest = rest[n:]
+
+		v, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing credential public key")
+		}
+		ad.CredentialPublicKey = rest[:n]
+		rest = rest[n:]
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			return nil, errors.New("error parsing credential public key: invalid COSE key")
+		}
+		if ad.PublicKey, ad.PublicKeyAlgorithm, err = parseCOSEKey(m); err != nil {
+			return nil, err
+		}
+	}
+
+	if ad.Flags&FlagExtensionData != 0 {
+		v, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing authenticator extensions")
+		}
+		if ad.Extensions, _ = v.(map[interface{}]interface{}); ad.Extensions == nil {
+			return nil, errors.New("error parsing authenticator extensions: extensions must be a map")
+		}
+		rest = rest[n:]
+	}
+
+	if len(rest) > 0 {
+		return nil, errors.New("error parsing authenticator data: unexpected trailing data")
+	}
+	return ad, nil
+}
+
+// FlagNames returns the names of the flags set, e.g. UP, UV or AT.
+func (ad *AuthenticatorData) FlagNames() []string {
+	var names []string
+	for _, f := range flagNames {
+		if ad.Flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// AAGUIDString returns the AAGUID of the authenticator in the UUID format.
+func (ad *AuthenticatorData) AAGUIDString() string {
+	return formatAAGUID(ad.AAGUID)
+}
+
+func formatAAGUID(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	s := hex.EncodeToString(b)
+	return strings.Join([]string{s[:8], s[8:12], s[12:16], s[16:20], s[20:]}, "-")
+}
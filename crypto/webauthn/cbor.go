@@ -0,0 +1,183 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// maxCBORDepth is the maximum nesting level of CBOR arrays and maps.
+const maxCBORDepth = 16
+
+// cborDecoder is a minimal CBOR (RFC 7049) decoder with the features used in
+// WebAuthn attestation objects. Unsigned and negative integers are decoded as
+// int64, byte strings as []byte, text strings as string, arrays as
+// []interface{}, maps as map[interface{}]interface{}, and floats as float64.
+// Tags are ignored and indefinite lengths are not supported.
+type cborDecoder struct {
+	b   []byte
+	off int
+}
+
+// decodeCBOR decodes the first CBOR item in b and returns it with the number
+// of bytes read.
+func decodeCBOR(b []byte) (interface{}, int, error) {
+	d := &cborDecoder{b: b}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.off, nil
+}
+
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)-d.off) {
+		return nil, errors.New("error decoding CBOR: unexpected end of data")
+	}
+	b := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+// head reads the initial byte and the argument of an item.
+func (d *cborDecoder) head() (byte, byte, uint64, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		b, err = d.read(1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(b[0]), nil
+	case info == 25:
+		b, err = d.read(2)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(binary.BigEndian.Uint16(b)), nil
+	case info == 26:
+		b, err = d.read(4)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(binary.BigEndian.Uint32(b)), nil
+	case info == 27:
+		b, err = d.read(8)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, binary.BigEndian.Uint64(b), nil
+	default:
+		return 0, 0, 0, errors.New("error decoding CBOR: indefinite lengths are not supported")
+	}
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, errors.New("error decoding CBOR: maximum depth exceeded")
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0: // unsigned integer
+		if arg > math.MaxInt64 {
+			return nil, errors.New("error decoding CBOR: integer overflow")
+		}
+		return int64(arg), nil
+	case 1: // negative integer
+		if arg > math.MaxInt64 {
+			return nil, errors.New("error decoding CBOR: integer overflow")
+		}
+		return -1 - int64(arg), nil
+	case 2: // byte string
+		return d.read(arg)
+	case 3: // text string
+		b, err := d.read(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 4: // array
+		if arg > uint64(len(d.b)-d.off) {
+			return nil, errors.New("error decoding CBOR: unexpected end of data")
+		}
+		v := make([]interface{}, arg)
+		for i := range v {
+			if v[i], err = d.decode(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case 5: // map
+		if arg > uint64(len(d.b)-d.off) {
+			return nil, errors.New("error decoding CBOR: unexpected end of data")
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, errors.New("error decoding CBOR: unsupported map key type")
+			}
+			if m[k], err = d.decode(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case 6: // tag
+		return d.decode(depth + 1)
+	default: // simple values and floats
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		case 25:
+			return float16(uint16(arg)), nil
+		case 26:
+			return float64(math.Float32frombits(uint32(arg))), nil
+		case 27:
+			return math.Float64frombits(arg), nil
+		default:
+			return nil, errors.Errorf("error decoding CBOR: unsupported simple value %d", arg)
+		}
+	}
+}
+
+// float16 converts an IEEE 754 half-precision float to a float64.
+func float16(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		v = -v
+	}
+	return v
+}
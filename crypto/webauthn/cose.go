@@ -0,0 +1,221 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"math/big"
+
+	// Register the hash functions used by the COSE algorithms.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// COSE key types, curves and algorithms.
+const (
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveP384    = 2
+	coseCurveP521    = 3
+	coseCurveEd25519 = 6
+
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgES384 = -35
+	AlgES512 = -36
+	AlgPS256 = -37
+	AlgPS384 = -38
+	AlgPS512 = -39
+	AlgRS256 = -257
+	AlgRS384 = -258
+	AlgRS512 = -259
+	AlgRS1   = -65535
+)
+
+// COSE key parameters.
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+)
+
+// AlgorithmName returns the name of a COSE algorithm.
+func AlgorithmName(alg int64) string {
+	switch alg {
+	case AlgES256:
+		return "ES256"
+	case AlgEdDSA:
+		return "EdDSA"
+	case AlgES384:
+		return "ES384"
+	case AlgES512:
+		return "ES512"
+	case AlgPS256:
+		return "PS256"
+	case AlgPS384:
+		return "PS384"
+	case AlgPS512:
+		return "PS512"
+	case AlgRS256:
+		return "RS256"
+	case AlgRS384:
+		return "RS384"
+	case AlgRS512:
+		return "RS512"
+	case AlgRS1:
+		return "RS1"
+	default:
+		return "unknown"
+	}
+}
+
+// algorithmHash returns the hash function of a COSE algorithm.
+func algorithmHash(alg int64) (crypto.Hash, error) {
+	switch alg {
+	case AlgES256, AlgPS256, AlgRS256:
+		return crypto.SHA256, nil
+	case AlgES384, AlgPS384, AlgRS384:
+		return crypto.SHA384, nil
+	case AlgES512, AlgPS512, AlgRS512:
+		return crypto.SHA512, nil
+	case AlgRS1:
+		return crypto.SHA1, nil
+	default:
+		return 0, errors.Errorf("unsupported COSE algorithm %d", alg)
+	}
+}
+
+func parseCOSEKey(m map[interface{}]interface{}) (crypto.PublicKey, int64, error) {
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, ok := m[int64(coseAlg)].(int64)
+	if !ok {
+		return nil, 0, errors.New("error parsing credential public key: missing algorithm")
+	}
+	bytesParam := func(k int64) []byte {
+		b, _ := m[k].([]byte)
+		return b
+	}
+
+	switch kty {
+	case coseKeyTypeEC2:
+		var curve elliptic.Curve
+		switch crv, _ := m[int64(coseCrv)].(int64); crv {
+		case coseCurveP256:
+			curve = elliptic.P256()
+		case coseCurveP384:
+			curve = elliptic.P384()
+		case coseCurveP521:
+			curve = elliptic.P521()
+		default:
+			return nil, 0, errors.Errorf("error parsing credential public key: unsupported curve %d", crv)
+		}
+		x, y := new(big.Int).SetBytes(bytesParam(coseX)), new(big.Int).SetBytes(bytesParam(coseY))
+		if !curve.IsOnCurve(x, y) {
+			return nil, 0, errors.New("error parsing credential public key: invalid point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, alg, nil
+	case coseKeyTypeRSA:
+		n, e := bytesParam(coseN), bytesParam(coseE)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("error parsing credential public key: invalid RSA key")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, alg, nil
+	case coseKeyTypeOKP:
+		if crv, _ := m[int64(coseCrv)].(int64); crv != coseCurveEd25519 {
+			return nil, 0, errors.Errorf("error parsing credential public key: unsupported curve %d", crv)
+		}
+		x := bytesParam(coseX)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("error parsing credential public key: invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	default:
+		return nil, 0, errors.Errorf("error parsing credential public key: unsupported key type %d", kty)
+	}
+}
+
+// verifySignature verifies the signature of data using the given COSE
+// algorithm and public key.
+func verifySignature(alg int64, pub crypto.PublicKey, data, sig []byte) error {
+	if alg == AlgEdDSA {
+		k, ok := pub.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(k, data, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	hash, err := algorithmHash(alg)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if alg != AlgES256 && alg != AlgES384 && alg != AlgES512 {
+			return errors.Errorf("algorithm %s cannot be used with an ECDSA key", AlgorithmName(alg))
+		}
+		var esig struct {
+			R, S *big.Int
+		}
+		if rest, err := asn1.Unmarshal(sig, &esig); err != nil || len(rest) > 0 {
+			return errors.New("invalid signature")
+		}
+		if !ecdsa.Verify(k, digest, esig.R, esig.S) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		switch alg {
+		case AlgPS256, AlgPS384, AlgPS512:
+			err = rsa.VerifyPSS(k, hash, digest, sig, nil)
+		case AlgRS256, AlgRS384, AlgRS512, AlgRS1:
+			err = rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		default:
+			return errors.Errorf("algorithm %s cannot be used with an RSA key", AlgorithmName(alg))
+		}
+		if err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return errors.Errorf("algorithm %s cannot be used with a %T key", AlgorithmName(alg), pub)
+	}
+}
+
+// publicKeyEqual returns true if both public keys are the same.
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	switch ka := a.(type) {
+	case *ecdsa.PublicKey:
+		kb, ok := b.(*ecdsa.PublicKey)
+		return ok && ka.Curve == kb.Curve && ka.X.Cmp(kb.X) == 0 && ka.Y.Cmp(kb.Y) == 0
+	case *rsa.PublicKey:
+		kb, ok := b.(*rsa.PublicKey)
+		return ok && ka.E == kb.E && ka.N.Cmp(kb.N) == 0
+	case ed25519.PublicKey:
+		kb, ok := b.(ed25519.PublicKey)
+		return ok && bytes.Equal(ka, kb)
+	default:
+		return false
+	}
+}
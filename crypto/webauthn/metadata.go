@@ -0,0 +1,129 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MetadataStatement is the subset of a FIDO metadata statement used to
+// verify attestations.
+type MetadataStatement struct {
+	AAGUID                               string   `json:"aaguid"`
+	Description                          string   `json:"description"`
+	AttestationCertificateKeyIdentifiers []string `json:"attestationCertificateKeyIdentifiers"`
+	AttestationRootCertificates          []string `json:"attestationRootCertificates"`
+}
+
+// Roots returns the attestation root certificates of the statement.
+func (s *MetadataStatement) Roots() ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0, len(s.AttestationRootCertificates))
+	for _, v := range s.AttestationRootCertificates {
+		der, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error decoding attestation root of %s", s.Description)
+		}
+		crt, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing attestation root of %s", s.Description)
+		}
+		certs = append(certs, crt)
+	}
+	return certs, nil
+}
+
+// Metadata is a collection of metadata statements.
+type Metadata struct {
+	Statements []*MetadataStatement
+}
+
+// ParseMetadata parses a FIDO Metadata Service (MDS3) BLOB, the JSON payload
+// of the BLOB, a single metadata statement, or an array of statements. The
+// signature of the BLOB is not verified, it must be obtained from a trusted
+// source.
+func ParseMetadata(b []byte) (*Metadata, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] != '{' && b[0] != '[' {
+		parts := strings.Split(string(b), ".")
+		if len(parts) != 3 {
+			return nil, errors.New("error parsing metadata: unsupported format")
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing metadata")
+		}
+		b = payload
+	}
+
+	if len(b) > 0 && b[0] == '[' {
+		md := new(Metadata)
+		if err := json.Unmarshal(b, &md.Statements); err != nil {
+			return nil, errors.Wrap(err, "error parsing metadata")
+		}
+		return md, nil
+	}
+
+	var v struct {
+		Entries []struct {
+			AAGUID                               string             `json:"aaguid"`
+			AttestationCertificateKeyIdentifiers []string           `json:"attestationCertificateKeyIdentifiers"`
+			MetadataStatement                    *MetadataStatement `json:"metadataStatement"`
+		} `json:"entries"`
+		MetadataStatement
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, errors.Wrap(err, "error parsing metadata")
+	}
+	if v.Entries == nil {
+		s := v.MetadataStatement
+		return &Metadata{Statements: []*MetadataStatement{&s}}, nil
+	}
+	md := new(Metadata)
+	for _, e := range v.Entries {
+		if e.MetadataStatement == nil {
+			continue
+		}
+		if e.MetadataStatement.AAGUID == "" {
+			e.MetadataStatement.AAGUID = e.AAGUID
+		}
+		if len(e.MetadataStatement.AttestationCertificateKeyIdentifiers) == 0 {
+			e.MetadataStatement.AttestationCertificateKeyIdentifiers = e.AttestationCertificateKeyIdentifiers
+		}
+		md.Statements = append(md.Statements, e.MetadataStatement)
+	}
+	return md, nil
+}
+
+// Lookup returns the statement of the authenticator with the given AAGUID.
+func (m *Metadata) Lookup(aaguid string) *MetadataStatement {
+	if aaguid == "" || aaguid == "00000000-0000-0000-0000-000000000000" {
+		return nil
+	}
+	for _, s := range m.Statements {
+		if strings.EqualFold(s.AAGUID, aaguid) {
+			return s
+		}
+	}
+	return nil
+}
+
+// LookupKeyIdentifier returns the statement of the authenticator with the
+// given attestation certificate key identifier, the hex encoded subject key
+// identifier of the attestation certificate.
+func (m *Metadata) LookupKeyIdentifier(keyID string) *MetadataStatement {
+	if keyID == "" {
+		return nil
+	}
+	for _, s := range m.Statements {
+		for _, id := range s.AttestationCertificateKeyIdentifiers {
+			if strings.EqualFold(id, keyID) {
+				return s
+			}
+		}
+	}
+	return nil
+}
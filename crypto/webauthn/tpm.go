@@ -0,0 +1,228 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// TPM 2.0 constants used in the tpm attestation format.
+const (
+	tpmGeneratedValue  = 0xff544347
+	tpmSTAttestCertify = 0x8017
+
+	tpmAlgRSA    = 0x0001
+	tpmAlgSHA1   = 0x0004
+	tpmAlgSHA256 = 0x000b
+	tpmAlgSHA384 = 0x000c
+	tpmAlgSHA512 = 0x000d
+	tpmAlgNull   = 0x0010
+	tpmAlgECC    = 0x0023
+
+	tpmECCNistP256 = 0x0003
+	tpmECCNistP384 = 0x0004
+	tpmECCNistP521 = 0x0005
+)
+
+// tpmReader reads TPM 2.0 structures, all integers are big endian.
+type tpmReader struct {
+	b   []byte
+	err error
+}
+
+func (r *tpmReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.b) {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *tpmReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *tpmReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// tpm2b reads a sized buffer.
+func (r *tpmReader) tpm2b() []byte {
+	return r.bytes(int(r.uint16()))
+}
+
+// tpmCertifyInfo is the relevant information of a TPMS_ATTEST structure of
+// type TPM_ST_ATTEST_CERTIFY.
+type tpmCertifyInfo struct {
+	ExtraData []byte
+	Name      []byte
+}
+
+func parseTPMCertifyInfo(b []byte) (*tpmCertifyInfo, error) {
+	r := &tpmReader{b: b}
+	magic := r.uint32()
+	typ := r.uint16()
+	r.tpm2b() // qualifiedSigner
+	info := &tpmCertifyInfo{
+		ExtraData: r.tpm2b(),
+	}
+	r.bytes(17) // clockInfo
+	r.bytes(8)  // firmwareVersion
+	info.Name = r.tpm2b()
+	r.tpm2b() // qualifiedName
+	switch {
+	case r.err != nil:
+		return nil, errors.Wrap(r.err, "error parsing certInfo")
+	case magic != tpmGeneratedValue:
+		return nil, errors.New("error parsing certInfo: invalid magic value")
+	case typ != tpmSTAttestCertify:
+		return nil, errors.New("error parsing certInfo: invalid attestation type")
+	case len(r.b) > 0:
+		return nil, errors.New("error parsing certInfo: unexpected trailing data")
+	}
+	return info, nil
+}
+
+// parseTPMPublic parses a TPMT_PUBLIC structure and returns its public key
+// and name algorithm.
+func parseTPMPublic(b []byte) (crypto.PublicKey, crypto.Hash, error) {
+	r := &tpmReader{b: b}
+	typ := r.uint16()
+	nameAlg := r.uint16()
+	r.uint32() // objectAttributes
+	r.tpm2b()  // authPolicy
+
+	// Symmetric and scheme are followed by their parameters if they are not
+	// TPM_ALG_NULL.
+	algParams := func() {
+		if alg := r.uint16(); alg != tpmAlgNull {
+			r.uint16()
+		}
+	}
+
+	var pub crypto.PublicKey
+	switch typ {
+	case tpmAlgRSA:
+		if sym := r.uint16(); sym != tpmAlgNull {
+			r.bytes(4) // keyBits and mode
+		}
+		algParams() // scheme
+		r.uint16()  // keyBits
+		exp := r.uint32()
+		if exp == 0 {
+			exp = 65537
+		}
+		n := r.tpm2b()
+		pub = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp)}
+	case tpmAlgECC:
+		if sym := r.uint16(); sym != tpmAlgNull {
+			r.bytes(4) // keyBits and mode
+		}
+		algParams() // scheme
+		curveID := r.uint16()
+		algParams() // kdf
+		x, y := r.tpm2b(), r.tpm2b()
+		var curve elliptic.Curve
+		switch curveID {
+		case tpmECCNistP256:
+			curve = elliptic.P256()
+		case tpmECCNistP384:
+			curve = elliptic.P384()
+		case tpmECCNistP521:
+			curve = elliptic.P521()
+		default:
+			return nil, 0, errors.Errorf("error parsing pubArea: unsupported curve %d", curveID)
+		}
+		pub = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	default:
+		return nil, 0, errors.Errorf("error parsing pubArea: unsupported key type %d", typ)
+	}
+	if r.err != nil {
+		return nil, 0, errors.Wrap(r.err, "error parsing pubArea")
+	}
+
+	hash, err := tpmHash(nameAlg)
+	if err != nil {
+		return nil, 0, err
+	}
+	return pub, hash, nil
+}
+
+func tpmHash(alg uint16) (crypto.Hash, error) {
+	switch alg {
+	case tpmAlgSHA1:
+		return crypto.SHA1, nil
+	case tpmAlgSHA256:
+		return crypto.SHA256, nil
+	case tpmAlgSHA384:
+		return crypto.SHA384, nil
+	case tpmAlgSHA512:
+		return crypto.SHA512, nil
+	default:
+		return 0, errors.Errorf("unsupported TPM hash algorithm %d", alg)
+	}
+}
+
+// tpmName returns the TPM name of the public area, the name algorithm
+// followed by the hash of the public area.
+func tpmName(pubArea []byte, nameAlg crypto.Hash) []byte {
+	h := nameAlg.New()
+	h.Write(pubArea)
+	var alg uint16
+	switch nameAlg {
+	case crypto.SHA1:
+		alg = tpmAlgSHA1
+	case crypto.SHA256:
+		alg = tpmAlgSHA256
+	case crypto.SHA384:
+		alg = tpmAlgSHA384
+	case crypto.SHA512:
+		alg = tpmAlgSHA512
+	}
+	name := make([]byte, 2)
+	binary.BigEndian.PutUint16(name, alg)
+	return h.Sum(name)
+}
+
+// verifyTPMCertifyInfo checks that certInfo certifies the key in pubArea and
+// that its extra data is the hash of the attested data.
+func verifyTPMCertifyInfo(certInfo, pubArea []byte, alg int64, attToBeSigned []byte) error {
+	info, err := parseTPMCertifyInfo(certInfo)
+	if err != nil {
+		return err
+	}
+	_, nameAlg, err := parseTPMPublic(pubArea)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(info.Name, tpmName(pubArea, nameAlg)) {
+		return errors.New("certInfo name does not match pubArea")
+	}
+	hash, err := algorithmHash(alg)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(attToBeSigned)
+	if !bytes.Equal(info.ExtraData, h.Sum(nil)) {
+		return errors.New("certInfo extraData does not match the attested data")
+	}
+	return nil
+}
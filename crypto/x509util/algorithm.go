@@ -0,0 +1,93 @@
+package x509util
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"strings"
+
+	"github.com/pkg/errors"
+	stepx509 "github.com/smallstep/cli/pkg/x509"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Ed25519SignatureAlgorithm is the signature algorithm of certificates signed
+// with Ed25519 keys.
+const Ed25519SignatureAlgorithm = x509.SignatureAlgorithm(stepx509.ED25519SIG)
+
+var signatureAlgorithms = []struct {
+	name  string
+	alias string
+	alg   x509.SignatureAlgorithm
+	kty   string
+}{
+	{"SHA256-RSA", "SHA256WithRSA", x509.SHA256WithRSA, "RSA"},
+	{"SHA384-RSA", "SHA384WithRSA", x509.SHA384WithRSA, "RSA"},
+	{"SHA512-RSA", "SHA512WithRSA", x509.SHA512WithRSA, "RSA"},
+	{"SHA256-RSAPSS", "SHA256WithRSAPSS", x509.SHA256WithRSAPSS, "RSA"},
+	{"SHA384-RSAPSS", "SHA384WithRSAPSS", x509.SHA384WithRSAPSS, "RSA"},
+	{"SHA512-RSAPSS", "SHA512WithRSAPSS", x509.SHA512WithRSAPSS, "RSA"},
+	{"ECDSA-SHA256", "ECDSAWithSHA256", x509.ECDSAWithSHA256, "EC"},
+	{"ECDSA-SHA384", "ECDSAWithSHA384", x509.ECDSAWithSHA384, "EC"},
+	{"ECDSA-SHA512", "ECDSAWithSHA512", x509.ECDSAWithSHA512, "EC"},
+	{"Ed25519", "PureEd25519", Ed25519SignatureAlgorithm, "OKP"},
+}
+
+// SignatureAlgorithmNames returns the names of the signature algorithms
+// supported by ParseSignatureAlgorithm.
+func SignatureAlgorithmNames() []string {
+	names := make([]string, len(signatureAlgorithms))
+	for i, s := range signatureAlgorithms {
+		names[i] = s.name
+	}
+	return names
+}
+
+// ParseSignatureAlgorithm returns the signature algorithm with the given
+// name. Names are case-insensitive and can use the format of Go, e.g.
+// SHA256-RSAPSS or ECDSA-SHA384, or the name of the Go constant, e.g.
+// SHA256WithRSAPSS or ECDSAWithSHA384. Algorithms using SHA-1 or MD5 are not
+// supported.
+func ParseSignatureAlgorithm(name string) (x509.SignatureAlgorithm, error) {
+	for _, s := range signatureAlgorithms {
+		if strings.EqualFold(name, s.name) || strings.EqualFold(name, s.alias) {
+			return s.alg, nil
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, errors.Errorf("unsupported signature algorithm '%s'", name)
+}
+
+// CheckSignatureAlgorithm returns an error if the signature algorithm cannot
+// be used to sign with the private key of the given public key.
+func CheckSignatureAlgorithm(alg x509.SignatureAlgorithm, pub interface{}) error {
+	var kty string
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		kty = "RSA"
+	case *ecdsa.PublicKey:
+		kty = "EC"
+	case ed25519.PublicKey:
+		kty = "OKP"
+	default:
+		return errors.Errorf("unsupported public key type %T", pub)
+	}
+	for _, s := range signatureAlgorithms {
+		if s.alg == alg {
+			if s.kty != kty {
+				return errors.Errorf("signature algorithm %s cannot be used with %s keys", s.name, kty)
+			}
+			return nil
+		}
+	}
+	return errors.Errorf("unsupported signature algorithm %s", alg)
+}
+
+// WithSignatureAlgorithm returns a Profile modifier that sets the signature
+// algorithm used by the issuer to sign the certificate.
+func WithSignatureAlgorithm(alg x509.SignatureAlgorithm) WithOption {
+	return func(p Profile) error {
+		crt := p.Subject()
+		crt.SignatureAlgorithm = alg
+		return nil
+	}
+}
@@ -0,0 +1,60 @@
+package x509util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestParseSignatureAlgorithm(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    x509.SignatureAlgorithm
+		wantErr bool
+	}{
+		{"SHA256-RSA", x509.SHA256WithRSA, false},
+		{"SHA256WithRSAPSS", x509.SHA256WithRSAPSS, false},
+		{"sha384-rsapss", x509.SHA384WithRSAPSS, false},
+		{"ECDSA-SHA384", x509.ECDSAWithSHA384, false},
+		{"ecdsawithsha512", x509.ECDSAWithSHA512, false},
+		{"Ed25519", Ed25519SignatureAlgorithm, false},
+		{"SHA1-RSA", x509.UnknownSignatureAlgorithm, true},
+		{"ECDSA-SHA1", x509.UnknownSignatureAlgorithm, true},
+		{"", x509.UnknownSignatureAlgorithm, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			alg, err := ParseSignatureAlgorithm(tc.name)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equals(t, tc.want, alg)
+		})
+	}
+}
+
+func TestCheckSignatureAlgorithm(t *testing.T) {
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	rs, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+	ed, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.FatalError(t, err)
+
+	assert.NoError(t, CheckSignatureAlgorithm(x509.SHA256WithRSAPSS, &rs.PublicKey))
+	assert.NoError(t, CheckSignatureAlgorithm(x509.SHA512WithRSA, &rs.PublicKey))
+	assert.NoError(t, CheckSignatureAlgorithm(x509.ECDSAWithSHA384, &ec.PublicKey))
+	assert.NoError(t, CheckSignatureAlgorithm(Ed25519SignatureAlgorithm, ed))
+	assert.Error(t, CheckSignatureAlgorithm(x509.ECDSAWithSHA256, &rs.PublicKey))
+	assert.Error(t, CheckSignatureAlgorithm(x509.SHA256WithRSAPSS, &ec.PublicKey))
+	assert.Error(t, CheckSignatureAlgorithm(x509.ECDSAWithSHA256, ed))
+	assert.Error(t, CheckSignatureAlgorithm(x509.SHA1WithRSA, &rs.PublicKey))
+}
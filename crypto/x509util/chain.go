@@ -0,0 +1,408 @@
+package x509util
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// maxChainDepth is the maximum number of certificates in the chains built by
+// BuildChains.
+const maxChainDepth = 10
+
+// ChainAttempt is one of the chains built from a certificate to a root, and
+// the problems that make it invalid.
+type ChainAttempt struct {
+	// Chain is the list of certificates, starting with the leaf.
+	Chain []*x509.Certificate
+	// Trusted indicates that the last certificate in the chain is a root.
+	Trusted bool
+	// Problems is the list of constraints that the chain does not satisfy.
+	Problems []error
+}
+
+// Valid returns true if the chain ends in a root and it does not have
+// problems.
+func (a *ChainAttempt) Valid() bool {
+	return a.Trusted && len(a.Problems) == 0
+}
+
+// ChainError describes a constraint that a certificate in a chain does not
+// satisfy.
+type ChainError struct {
+	Cert   *x509.Certificate
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("certificate '%s' %s", CertificateName(e.Cert), e.Reason)
+}
+
+// CertificateName returns a short name for the certificate, the common name,
+// or the full subject if it does not have one.
+func CertificateName(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	return cert.Subject.String()
+}
+
+// BuildChains builds all the chains from the given certificate to a root in
+// opts.Roots, or to the system roots if opts.Roots is nil, using the given
+// intermediates, and checks each chain against the constraints of the path
+// validation: validity period, signature algorithms, basic constraints, key
+// usage, extended key usage, name constraints and host names. Unlike
+// x509.Certificate.Verify, it returns every chain attempted with all the
+// problems found, so the reason of a failed validation can be explained.
+//
+// The returned attempts are sorted with the best candidates first.
+func BuildChains(cert *x509.Certificate, intermediates []*x509.Certificate, opts x509.VerifyOptions) []*ChainAttempt {
+	var attempts []*ChainAttempt
+	var build func(chain []*x509.Certificate)
+	build = func(chain []*x509.Certificate) {
+		last := chain[len(chain)-1]
+		if root := findRoot(last, opts); root != nil {
+			if !bytes.Equal(root.Raw, last.Raw) {
+				chain = append(chain, root)
+			}
+			attempts = append(attempts, &ChainAttempt{Chain: chain, Trusted: true})
+			return
+		}
+
+		var found bool
+		if len(chain) < maxChainDepth {
+			for _, parent := range intermediates {
+				if !bytes.Equal(parent.RawSubject, last.RawIssuer) || inChain(chain, parent) {
+					continue
+				}
+				found = true
+				next := append(chain[:len(chain):len(chain)], parent)
+				if err := checkSignature(last, parent); err != nil {
+					attempts = append(attempts, &ChainAttempt{
+						Chain:    next,
+						Problems: []error{err},
+					})
+					continue
+				}
+				build(next)
+			}
+		}
+		if !found {
+			attempts = append(attempts, &ChainAttempt{
+				Chain: chain,
+				Problems: []error{&ChainError{
+					Cert:   last,
+					Reason: fmt.Sprintf("is issued by '%s', an unknown authority", issuerName(last)),
+				}},
+			})
+		}
+	}
+	build([]*x509.Certificate{cert})
+
+	for _, a := range attempts {
+		a.Problems = append(a.Problems, CheckChain(a.Chain, opts)...)
+	}
+
+	// Sort trusted chains first, then by number of problems.
+	for i := 1; i < len(attempts); i++ {
+		for j := i; j > 0 && betterAttempt(attempts[j], attempts[j-1]); j-- {
+			attempts[j], attempts[j-1] = attempts[j-1], attempts[j]
+		}
+	}
+	return attempts
+}
+
+// CheckChain checks the constraints of the given chain, starting with the
+// leaf, and returns the list of problems found. The signatures and the
+// trust of the last certificate are not checked.
+func CheckChain(chain []*x509.Certificate, opts x509.VerifyOptions) []error {
+	var problems []error
+	add := func(cert *x509.Certificate, format string, args ...interface{}) {
+		problems = append(problems, &ChainError{Cert: cert, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	for i, cert := range chain {
+		// Validity period
+		switch {
+		case now.Before(cert.NotBefore):
+			add(cert, "is not valid until %s", cert.NotBefore.UTC().Format(time.RFC3339))
+		case now.After(cert.NotAfter):
+			add(cert, "expired at %s", cert.NotAfter.UTC().Format(time.RFC3339))
+		}
+
+		// Signature algorithm, the signature of the last certificate is not
+		// used.
+		if i < len(chain)-1 && isWeakSignatureAlgorithm(cert.SignatureAlgorithm) {
+			add(cert, "is signed using the weak algorithm %s", cert.SignatureAlgorithm)
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		// Basic constraints and key usage of issuers
+		if !cert.BasicConstraintsValid || !cert.IsCA {
+			add(cert, "is not a CA and cannot issue certificates")
+		}
+		if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+			add(cert, "does not have the key usage to sign certificates")
+		}
+		if cert.BasicConstraintsValid && (cert.MaxPathLen > 0 || cert.MaxPathLenZero) && i-1 > cert.MaxPathLen {
+			add(cert, "allows %d intermediate certificates below it but the chain has %d", cert.MaxPathLen, i-1)
+		}
+
+		// Name constraints apply to all certificates below the issuer.
+		for _, sub := range chain[:i] {
+			for _, reason := range CheckNameConstraints(cert, sub) {
+				add(cert, "%s", reason)
+			}
+		}
+	}
+
+	// Extended key usage, certificates without it can be used for any
+	// purpose.
+	usages := opts.KeyUsages
+	if len(usages) == 0 {
+		usages = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	for _, cert := range chain {
+		if missing := missingExtKeyUsages(cert, usages); len(missing) > 0 {
+			add(cert, "does not allow the extended key usage %s (it allows %s)",
+				strings.Join(missing, ", "), strings.Join(extKeyUsageNames(cert), ", "))
+		}
+	}
+
+	// Host name
+	if opts.DNSName != "" && len(chain) > 0 {
+		if err := chain[0].VerifyHostname(opts.DNSName); err != nil {
+			add(chain[0], "is not valid for %s (it is valid for %s)", opts.DNSName, strings.Join(certificateNames(chain[0]), ", "))
+		}
+	}
+
+	return problems
+}
+
+// findRoot returns the root certificate in the pool that issued the given
+// certificate, or the certificate itself if it is a root.
+func findRoot(cert *x509.Certificate, opts x509.VerifyOptions) *x509.Certificate {
+	// Only the trust is checked here, the time and the usages are checked
+	// later in CheckChain. The pool cannot be inspected, so the certificate is
+	// verified at different times to find roots that are expired now.
+	for _, t := range []time.Time{opts.CurrentTime, cert.NotBefore, cert.NotAfter} {
+		chains, err := cert.Verify(x509.VerifyOptions{
+			Roots:       opts.Roots,
+			CurrentTime: t,
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err == nil && len(chains) > 0 {
+			chain := chains[0]
+			return chain[len(chain)-1]
+		}
+	}
+	return nil
+}
+
+func checkSignature(cert, parent *x509.Certificate) error {
+	if err := parent.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		if _, ok := err.(x509.InsecureAlgorithmError); ok {
+			// It will be reported by CheckChain.
+			return nil
+		}
+		return &ChainError{
+			Cert:   cert,
+			Reason: fmt.Sprintf("has an invalid signature by '%s': %v", CertificateName(parent), err),
+		}
+	}
+	return nil
+}
+
+func betterAttempt(a, b *ChainAttempt) bool {
+	if a.Trusted != b.Trusted {
+		return a.Trusted
+	}
+	return len(a.Problems) < len(b.Problems)
+}
+
+func inChain(chain []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range chain {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+func issuerName(cert *x509.Certificate) string {
+	if cert.Issuer.CommonName != "" {
+		return cert.Issuer.CommonName
+	}
+	return cert.Issuer.String()
+}
+
+func isWeakSignatureAlgorithm(alg x509.SignatureAlgorithm) bool {
+	switch alg {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		return true
+	default:
+		return false
+	}
+}
+
+var extKeyUsageStrings = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:             "any",
+	x509.ExtKeyUsageServerAuth:      "serverAuth",
+	x509.ExtKeyUsageClientAuth:      "clientAuth",
+	x509.ExtKeyUsageCodeSigning:     "codeSigning",
+	x509.ExtKeyUsageEmailProtection: "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:  "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:     "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:       "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:    "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:     "OCSPSigning",
+}
+
+func extKeyUsageName(u x509.ExtKeyUsage) string {
+	if name, ok := extKeyUsageStrings[u]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", u)
+}
+
+func extKeyUsageNames(cert *x509.Certificate) []string {
+	var names []string
+	for _, u := range cert.ExtKeyUsage {
+		names = append(names, extKeyUsageName(u))
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		names = append(names, oid.String())
+	}
+	return names
+}
+
+// missingExtKeyUsages returns the names of the requested usages not allowed
+// by the certificate. The usage any does not require anything.
+func missingExtKeyUsages(cert *x509.Certificate, usages []x509.ExtKeyUsage) []string {
+	if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		return nil
+	}
+	var missing []string
+	for _, u := range usages {
+		if u == x509.ExtKeyUsageAny {
+			return nil
+		}
+		var ok bool
+		for _, eku := range cert.ExtKeyUsage {
+			if eku == u || eku == x509.ExtKeyUsageAny {
+				ok = true
+				break
+			}
+		}
+		if ok {
+			// Go accepts any of the requested usages.
+			return nil
+		}
+		missing = append(missing, extKeyUsageName(u))
+	}
+	return missing
+}
+
+func certificateNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) == 0 {
+		return []string{"no names"}
+	}
+	return names
+}
+
+// CheckNameConstraints returns the reasons why the names of cert are not
+// allowed by the name constraints of the issuer.
+func CheckNameConstraints(issuer, cert *x509.Certificate) []string {
+	var reasons []string
+	for _, name := range cert.DNSNames {
+		if len(issuer.PermittedDNSDomains) > 0 && !matchAnyDomain(name, issuer.PermittedDNSDomains) {
+			reasons = append(reasons, fmt.Sprintf("does not permit the DNS name %s in '%s'", name, CertificateName(cert)))
+		}
+		if matchAnyDomain(name, issuer.ExcludedDNSDomains) {
+			reasons = append(reasons, fmt.Sprintf("excludes the DNS name %s in '%s'", name, CertificateName(cert)))
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if len(issuer.PermittedIPRanges) > 0 && !matchAnyIPRange(ip, issuer.PermittedIPRanges) {
+			reasons = append(reasons, fmt.Sprintf("does not permit the IP address %s in '%s'", ip, CertificateName(cert)))
+		}
+		if matchAnyIPRange(ip, issuer.ExcludedIPRanges) {
+			reasons = append(reasons, fmt.Sprintf("excludes the IP address %s in '%s'", ip, CertificateName(cert)))
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if len(issuer.PermittedEmailAddresses) > 0 && !matchAnyEmail(email, issuer.PermittedEmailAddresses) {
+			reasons = append(reasons, fmt.Sprintf("does not permit the email %s in '%s'", email, CertificateName(cert)))
+		}
+		if matchAnyEmail(email, issuer.ExcludedEmailAddresses) {
+			reasons = append(reasons, fmt.Sprintf("excludes the email %s in '%s'", email, CertificateName(cert)))
+		}
+	}
+	return reasons
+}
+
+// matchDomain reports whether the domain matches the constraint. A constraint
+// starting with a period only matches subdomains, otherwise it matches the
+// domain and its subdomains.
+func matchDomain(domain, constraint string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "*."))
+	constraint = strings.ToLower(constraint)
+	if constraint == "" {
+		return true
+	}
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(domain, constraint)
+	}
+	return domain == constraint || strings.HasSuffix(domain, "."+constraint)
+}
+
+func matchAnyDomain(domain string, constraints []string) bool {
+	for _, c := range constraints {
+		if matchDomain(domain, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyIPRange(ip net.IP, ranges []*net.IPNet) bool {
+	for _, r := range ranges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyEmail(email string, constraints []string) bool {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return false
+	}
+	for _, c := range constraints {
+		if strings.Contains(c, "@") {
+			if strings.EqualFold(email, c) {
+				return true
+			}
+		} else if matchDomain(email[i+1:], c) {
+			return true
+		}
+	}
+	return false
+}
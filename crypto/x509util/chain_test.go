@@ -0,0 +1,171 @@
+package x509util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustCreateCertificate(t *testing.T, tmpl, parent *x509.Certificate, signer crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, signer = tmpl, key
+	}
+	sn, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = sn
+	if tmpl.NotBefore.IsZero() {
+		tmpl.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if tmpl.NotAfter.IsZero() {
+		tmpl.NotAfter = time.Now().Add(time.Hour)
+	}
+	b, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt, key
+}
+
+func caTemplate(cn string) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:               pkix.Name{CommonName: cn},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+}
+
+func leafTemplate(cn string) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:     pkix.Name{CommonName: cn},
+		DNSNames:    []string{cn},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+}
+
+func TestBuildChains(t *testing.T) {
+	rootTmpl := caTemplate("Root")
+	rootTmpl.NotBefore = time.Now().Add(-72 * time.Hour)
+	root, rootKey := mustCreateCertificate(t, rootTmpl, nil, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	inter, interKey := mustCreateCertificate(t, caTemplate("Intermediate"), root, rootKey)
+
+	expiredTmpl := caTemplate("Expired Intermediate")
+	expiredTmpl.NotBefore = time.Now().Add(-48 * time.Hour)
+	expiredTmpl.NotAfter = time.Now().Add(-24 * time.Hour)
+	expired, expiredKey := mustCreateCertificate(t, expiredTmpl, root, rootKey)
+
+	constrainedTmpl := caTemplate("Constrained Intermediate")
+	constrainedTmpl.PermittedDNSDomains = []string{"example.com"}
+	constrainedTmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	constrained, constrainedKey := mustCreateCertificate(t, constrainedTmpl, root, rootKey)
+
+	unknown, unknownKey := mustCreateCertificate(t, caTemplate("Unknown Root"), nil, nil)
+
+	leaf, _ := mustCreateCertificate(t, leafTemplate("foo.smallstep.com"), inter, interKey)
+	expiredLeaf, _ := mustCreateCertificate(t, leafTemplate("foo.smallstep.com"), expired, expiredKey)
+	constrainedLeaf, _ := mustCreateCertificate(t, leafTemplate("foo.smallstep.com"), constrained, constrainedKey)
+	unknownLeaf, _ := mustCreateCertificate(t, leafTemplate("foo.smallstep.com"), unknown, unknownKey)
+
+	tests := []struct {
+		name          string
+		cert          *x509.Certificate
+		intermediates []*x509.Certificate
+		opts          x509.VerifyOptions
+		wantTrusted   bool
+		wantProblems  []string
+	}{
+		{"ok", leaf, []*x509.Certificate{inter}, x509.VerifyOptions{Roots: roots}, true, nil},
+		{"ok host", leaf, []*x509.Certificate{inter}, x509.VerifyOptions{Roots: roots, DNSName: "foo.smallstep.com"}, true, nil},
+		{"ok multiple", leaf, []*x509.Certificate{expired, inter}, x509.VerifyOptions{Roots: roots}, true, nil},
+		{"fail host", leaf, []*x509.Certificate{inter}, x509.VerifyOptions{Roots: roots, DNSName: "bar.smallstep.com"}, true, []string{
+			"certificate 'foo.smallstep.com' is not valid for bar.smallstep.com (it is valid for foo.smallstep.com)",
+		}},
+		{"fail purpose", leaf, []*x509.Certificate{inter}, x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, true, []string{
+			"certificate 'foo.smallstep.com' does not allow the extended key usage clientAuth (it allows serverAuth)",
+		}},
+		{"fail time", leaf, []*x509.Certificate{inter}, x509.VerifyOptions{Roots: roots, CurrentTime: time.Now().Add(2 * time.Hour)}, true, []string{
+			"certificate 'foo.smallstep.com' expired at",
+			"certificate 'Intermediate' expired at",
+			"certificate 'Root' expired at",
+		}},
+		{"fail expired intermediate", expiredLeaf, []*x509.Certificate{expired}, x509.VerifyOptions{Roots: roots}, true, []string{
+			"certificate 'Expired Intermediate' expired at",
+		}},
+		{"fail constraints", constrainedLeaf, []*x509.Certificate{constrained}, x509.VerifyOptions{Roots: roots}, true, []string{
+			"certificate 'Constrained Intermediate' does not permit the DNS name foo.smallstep.com in 'foo.smallstep.com'",
+			"certificate 'Constrained Intermediate' does not allow the extended key usage serverAuth (it allows clientAuth)",
+		}},
+		{"fail unknown authority", unknownLeaf, []*x509.Certificate{unknown}, x509.VerifyOptions{Roots: roots}, false, []string{
+			"certificate 'Unknown Root' is issued by 'Unknown Root', an unknown authority",
+		}},
+		{"fail missing intermediate", leaf, nil, x509.VerifyOptions{Roots: roots}, false, []string{
+			"certificate 'foo.smallstep.com' is issued by 'Intermediate', an unknown authority",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := BuildChains(tt.cert, tt.intermediates, tt.opts)
+			if len(attempts) == 0 {
+				t.Fatal("BuildChains() returned no chains")
+			}
+			best := attempts[0]
+			if best.Trusted != tt.wantTrusted {
+				t.Errorf("BuildChains() trusted = %v, want %v", best.Trusted, tt.wantTrusted)
+			}
+			if len(best.Problems) != len(tt.wantProblems) {
+				t.Fatalf("BuildChains() problems = %v, want %v", best.Problems, tt.wantProblems)
+			}
+			for i, p := range best.Problems {
+				if !strings.HasPrefix(p.Error(), tt.wantProblems[i]) {
+					t.Errorf("BuildChains() problem = %q, want %q", p.Error(), tt.wantProblems[i])
+				}
+			}
+			if best.Valid() != (tt.wantTrusted && len(tt.wantProblems) == 0) {
+				t.Errorf("ChainAttempt.Valid() = %v", best.Valid())
+			}
+		})
+	}
+}
+
+func Test_matchDomain(t *testing.T) {
+	tests := []struct {
+		domain, constraint string
+		want               bool
+	}{
+		{"example.com", "example.com", true},
+		{"foo.example.com", "example.com", true},
+		{"*.example.com", "example.com", true},
+		{"fooexample.com", "example.com", false},
+		{"example.com", ".example.com", false},
+		{"foo.example.com", ".example.com", true},
+		{"Foo.Example.com", "example.COM", true},
+		{"example.org", "example.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchDomain(tt.domain, tt.constraint); got != tt.want {
+			t.Errorf("matchDomain(%q, %q) = %v, want %v", tt.domain, tt.constraint, got, tt.want)
+		}
+	}
+}
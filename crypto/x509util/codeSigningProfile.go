@@ -0,0 +1,39 @@
+package x509util
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"time"
+)
+
+// CodeSigning implements the Profile for a code signing certificate, a leaf
+// certificate used to sign executables, packages and other release artifacts.
+type CodeSigning struct {
+	base
+}
+
+// NewCodeSigningProfile returns a new code signing x509 Certificate profile.
+// The common name is usually the name of the publisher, the certificate does
+// not need any SAN. A new public/private key pair will be generated for the
+// Profile if not set in the `withOps` profile modifiers.
+func NewCodeSigningProfile(cn string, iss *x509.Certificate, issPriv crypto.PrivateKey, withOps ...WithOption) (Profile, error) {
+	sub := defaultCodeSigningTemplate(pkix.Name{CommonName: cn}, iss.Subject)
+	return newProfile(&CodeSigning{}, sub, iss, issPriv, withOps...)
+}
+
+func defaultCodeSigningTemplate(sub pkix.Name, iss pkix.Name) *x509.Certificate {
+	notBefore := time.Now()
+	return &x509.Certificate{
+		IsCA:      false,
+		NotBefore: notBefore,
+		NotAfter:  notBefore.Add(DefaultCertValidity),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageCodeSigning,
+		},
+		BasicConstraintsValid: false,
+		Issuer:                iss,
+		Subject:               sub,
+	}
+}
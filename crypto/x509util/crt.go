@@ -13,6 +13,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/fileurl"
 )
 
 // Fingerprint returns the SHA-256 fingerprint of the certificate.
@@ -41,8 +42,22 @@ func SplitSANs(sans []string) (dnsNames []string, ips []net.IP) {
 	return
 }
 
+// SplitEmails splits the email addresses, the names with an @, from a slice of
+// Subject Alternative Names. URIs with an @ are not email addresses.
+func SplitEmails(sans []string) (rest []string, emails []string) {
+	rest = []string{}
+	for _, san := range sans {
+		if strings.Contains(san, "@") && !strings.Contains(san, "://") {
+			emails = append(emails, san)
+		} else {
+			rest = append(rest, san)
+		}
+	}
+	return
+}
+
 // ReadCertPool loads a certificate pool from disk.
-// *path*: a file, a directory, or a comma-separated list of files.
+// *path*: a file, a directory, or a comma-separated list of files or URLs.
 func ReadCertPool(path string) (*x509.CertPool, error) {
 	info, err := os.Stat(path)
 	if err != nil && !os.IsNotExist(err) {
@@ -70,7 +85,7 @@ func ReadCertPool(path string) (*x509.CertPool, error) {
 
 	var pems []byte
 	for _, f := range files {
-		bytes, err := ioutil.ReadFile(f)
+		bytes, err := fileurl.ReadFile(f)
 		if err != nil {
 			return nil, errs.FileError(err, f)
 		}
@@ -4,6 +4,7 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"io/ioutil"
+	"reflect"
 	"testing"
 )
 
@@ -25,6 +26,30 @@ func TestFingerprint(t *testing.T) {
 	}
 }
 
+func TestSplitEmails(t *testing.T) {
+	tests := []struct {
+		name       string
+		sans       []string
+		wantRest   []string
+		wantEmails []string
+	}{
+		{"empty", nil, []string{}, nil},
+		{"mixed", []string{"jane@example.com", "example.com", "10.0.0.1", "https://jane@example.com", "john@example.com"},
+			[]string{"example.com", "10.0.0.1", "https://jane@example.com"}, []string{"jane@example.com", "john@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, emails := SplitEmails(tt.sans)
+			if !reflect.DeepEqual(rest, tt.wantRest) {
+				t.Errorf("SplitEmails() rest = %v, want %v", rest, tt.wantRest)
+			}
+			if !reflect.DeepEqual(emails, tt.wantEmails) {
+				t.Errorf("SplitEmails() emails = %v, want %v", emails, tt.wantEmails)
+			}
+		})
+	}
+}
+
 func mustParseCertificate(t *testing.T, filename string) *x509.Certificate {
 	pemData, err := ioutil.ReadFile(filename)
 	if err != nil {
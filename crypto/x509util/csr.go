@@ -1,9 +1,16 @@
 package x509util
 
 import (
+	"crypto"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
+	"fmt"
+	"io"
+
+	"github.com/smallstep/cli/crypto/fips"
+	"github.com/smallstep/cli/kms"
 )
 
 // LoadCSRFromBytes loads a CSR given the ASN.1 DER format.
@@ -14,3 +21,72 @@ func LoadCSRFromBytes(der []byte) (*x509.CertificateRequest, error) {
 	}
 	return ParseCertificateRequest(block.Bytes)
 }
+
+// CreateCertificateRequest creates a new certificate request like
+// x509.CreateCertificateRequest, but it also supports signers that need the
+// full message to sign, like the keys in an ssh-agent.
+func CreateCertificateRequest(rand io.Reader, template *x509.CertificateRequest, signer crypto.Signer) ([]byte, error) {
+	if err := fips.CheckKey(signer); err != nil {
+		return nil, err
+	}
+	ms, ok := signer.(kms.MessageSigner)
+	if !ok {
+		return x509.CreateCertificateRequest(rand, template, signer)
+	}
+
+	// Create the request with an empty signature to get the data to sign, and
+	// then replace the signature.
+	der, err := x509.CreateCertificateRequest(rand, template, placeholderSigner{signer})
+	if err != nil {
+		return nil, err
+	}
+	cr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := signatureHash(cr.SignatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var csr struct {
+		TBS                asn1.RawValue
+		SignatureAlgorithm asn1.RawValue
+		Signature          asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &csr); err != nil {
+		return nil, err
+	}
+	sig, err := ms.SignMessage(rand, csr.TBS.FullBytes, hash)
+	if err != nil {
+		return nil, err
+	}
+	csr.Signature = asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)}
+	return asn1.Marshal(csr)
+}
+
+// placeholderSigner is a crypto.Signer that returns an empty signature.
+type placeholderSigner struct {
+	crypto.Signer
+}
+
+func (placeholderSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// signatureHash returns the hash function of the given PKCS#1 v1.5 or ECDSA
+// signature algorithm.
+func signatureHash(alg x509.SignatureAlgorithm) (crypto.Hash, error) {
+	switch alg {
+	case x509.SHA1WithRSA, x509.ECDSAWithSHA1:
+		return crypto.SHA1, nil
+	case x509.SHA256WithRSA, x509.ECDSAWithSHA256:
+		return crypto.SHA256, nil
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384:
+		return crypto.SHA384, nil
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512:
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported signature algorithm %s", alg)
+	}
+}
@@ -1,10 +1,15 @@
 package x509util
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"io"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -76,3 +81,45 @@ func TestCSR_LoadCSRFromBytes(t *testing.T) {
 		})
 	}
 }
+
+// messageSigner implements kms.MessageSigner with a local key.
+type messageSigner struct {
+	crypto.Signer
+}
+
+func (s messageSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return nil, errors.New("digests are not supported")
+}
+
+func (s messageSigner) SignMessage(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	h := opts.HashFunc().New()
+	h.Write(message)
+	return s.Signer.Sign(rand, h.Sum(nil), opts)
+}
+
+func TestCreateCertificateRequest(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.FatalError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.FatalError(t, err)
+
+	tests := map[string]crypto.Signer{
+		"ecdsa":                ecKey,
+		"rsa":                  rsaKey,
+		"ecdsa message signer": messageSigner{ecKey},
+		"rsa message signer":   messageSigner{rsaKey},
+	}
+	for name, signer := range tests {
+		t.Run(name, func(t *testing.T) {
+			der, err := CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+				Subject:  pkix.Name{CommonName: "test.smallstep.com"},
+				DNSNames: []string{"test.smallstep.com"},
+			}, signer)
+			assert.FatalError(t, err)
+			cr, err := x509.ParseCertificateRequest(der)
+			assert.FatalError(t, err)
+			assert.NoError(t, cr.CheckSignature())
+			assert.Equals(t, "test.smallstep.com", cr.Subject.CommonName)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package x509util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pqc"
+)
+
+// Extensions used in hybrid certificates to add an alternative public key and
+// signature, as defined in ITU-T X.509 (10/2019) section 9.8.
+var (
+	oidExtensionSubjectAltPublicKeyInfo = asn1.ObjectIdentifier{2, 5, 29, 72}
+	oidExtensionAltSignatureAlgorithm   = asn1.ObjectIdentifier{2, 5, 29, 73}
+	oidExtensionAltSignatureValue       = asn1.ObjectIdentifier{2, 5, 29, 74}
+)
+
+// certificate reflects the ASN.1 structure of a certificate, only the
+// TBSCertificate is used.
+type certificate struct {
+	TBSCertificate     asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+// CreateHybridCertificate creates the certificate of the given profile adding
+// the subject's alternative public key and an alternative signature using the
+// issuer's alternative private key. The alternative keys must be ML-DSA keys,
+// and in a root certificate they must be the same.
+//
+// Hybrid certificates are experimental, they can be used by clients that
+// understand the alternative signature, and they are still valid for the
+// clients that don't.
+func CreateHybridCertificate(p Profile, altPub *pqc.PublicKey, altIssuer *pqc.PrivateKey) ([]byte, error) {
+	if !pqc.IsSignatureAlgorithm(altPub.Algorithm()) {
+		return nil, errors.Errorf("alternative key %s cannot be used to sign", altPub.Algorithm())
+	}
+	spki, err := pemutil.MarshalPKIXPublicKey(altPub)
+	if err != nil {
+		return nil, err
+	}
+	oid, err := pqc.OID(altIssuer.Algorithm())
+	if err != nil {
+		return nil, err
+	}
+	algo, err := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: oid})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling alternative signature algorithm")
+	}
+
+	sub := p.Subject()
+	extensions := sub.ExtraExtensions
+	sub.ExtraExtensions = append(extensions,
+		pkix.Extension{Id: oidExtensionSubjectAltPublicKeyInfo, Value: spki},
+		pkix.Extension{Id: oidExtensionAltSignatureAlgorithm, Value: algo})
+
+	// The alternative signature is calculated over the TBSCertificate without
+	// the signature field and without the altSignatureValue extension.
+	crtBytes, err := p.CreateCertificate()
+	if err != nil {
+		sub.ExtraExtensions = extensions
+		return nil, err
+	}
+	preTBS, err := preTBSCertificate(crtBytes)
+	if err != nil {
+		sub.ExtraExtensions = extensions
+		return nil, err
+	}
+	sig, err := altIssuer.Sign(rand.Reader, preTBS, crypto.Hash(0))
+	if err != nil {
+		sub.ExtraExtensions = extensions
+		return nil, errors.Wrap(err, "error creating alternative signature")
+	}
+	value, err := asn1.Marshal(asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)})
+	if err != nil {
+		sub.ExtraExtensions = extensions
+		return nil, errors.Wrap(err, "error marshaling alternative signature")
+	}
+
+	sub.ExtraExtensions = append(sub.ExtraExtensions,
+		pkix.Extension{Id: oidExtensionAltSignatureValue, Value: value})
+	crtBytes, err = p.CreateCertificate()
+	sub.ExtraExtensions = extensions
+	return crtBytes, err
+}
+
+// AltPublicKey returns the alternative public key in the
+// subjectAltPublicKeyInfo extension of a hybrid certificate.
+func AltPublicKey(crt *x509.Certificate) (*pqc.PublicKey, error) {
+	for _, ext := range crt.Extensions {
+		if ext.Id.Equal(oidExtensionSubjectAltPublicKeyInfo) {
+			pub, err := pemutil.ParsePKIXPublicKey(ext.Value)
+			if err != nil {
+				return nil, errors.Wrap(err, "error parsing alternative public key")
+			}
+			if k, ok := pub.(*pqc.PublicKey); ok {
+				return k, nil
+			}
+			return nil, errors.Errorf("unsupported alternative public key type %T", pub)
+		}
+	}
+	return nil, errors.New("certificate does not have an alternative public key")
+}
+
+// VerifyAltSignature verifies the alternative signature of a hybrid
+// certificate using the issuer's alternative public key.
+func VerifyAltSignature(crt *x509.Certificate, issuerAltPub *pqc.PublicKey) error {
+	var algo, value []byte
+	for _, ext := range crt.Extensions {
+		switch {
+		case ext.Id.Equal(oidExtensionAltSignatureAlgorithm):
+			algo = ext.Value
+		case ext.Id.Equal(oidExtensionAltSignatureValue):
+			value = ext.Value
+		}
+	}
+	if algo == nil || value == nil {
+		return errors.New("certificate does not have an alternative signature")
+	}
+
+	var ai pkix.AlgorithmIdentifier
+	if _, err := asn1.Unmarshal(algo, &ai); err != nil {
+		return errors.Wrap(err, "error parsing alternative signature algorithm")
+	}
+	if name, ok := pqc.AlgorithmByOID(ai.Algorithm); !ok || name != issuerAltPub.Algorithm() {
+		return errors.Errorf("alternative signature algorithm %s does not match the issuer key", ai.Algorithm)
+	}
+	var sig asn1.BitString
+	if _, err := asn1.Unmarshal(value, &sig); err != nil {
+		return errors.Wrap(err, "error parsing alternative signature")
+	}
+
+	preTBS, err := preTBSCertificate(crt.Raw)
+	if err != nil {
+		return err
+	}
+	if !pqc.Verify(issuerAltPub, preTBS, sig.RightAlign()) {
+		return errors.New("alternative signature verification failed")
+	}
+	return nil
+}
+
+// preTBSCertificate returns the TBSCertificate of the given certificate
+// without the signature field and without the altSignatureValue extension.
+func preTBSCertificate(crtBytes []byte) ([]byte, error) {
+	var crt certificate
+	if _, err := asn1.Unmarshal(crtBytes, &crt); err != nil {
+		return nil, errors.Wrap(err, "error parsing certificate")
+	}
+
+	// TBSCertificate ::= SEQUENCE {
+	//   version [0] EXPLICIT Version DEFAULT v1, serialNumber, signature,
+	//   issuer, validity, subject, subjectPublicKeyInfo,
+	//   issuerUniqueID [1], subjectUniqueID [2], extensions [3] EXPLICIT }
+	var fields []asn1.RawValue
+	for rest := crt.TBSCertificate.Bytes; len(rest) > 0; {
+		var v asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &v); err != nil {
+			return nil, errors.Wrap(err, "error parsing certificate")
+		}
+		fields = append(fields, v)
+	}
+	if len(fields) < 8 || fields[0].Class != asn1.ClassContextSpecific || fields[0].Tag != 0 {
+		return nil, errors.New("error parsing certificate: hybrid certificates must be v3")
+	}
+
+	var buf bytes.Buffer
+	for i, v := range fields {
+		switch {
+		case i == 2: // signature
+			continue
+		case v.Class == asn1.ClassContextSpecific && v.Tag == 3:
+			ext, err := removeAltSignatureValue(v)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(ext)
+		default:
+			buf.Write(v.FullBytes)
+		}
+	}
+
+	b, err := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSequence,
+		IsCompound: true,
+		Bytes:      buf.Bytes(),
+	})
+	return b, errors.Wrap(err, "error marshaling certificate")
+}
+
+// removeAltSignatureValue returns the extensions field without the
+// altSignatureValue extension.
+func removeAltSignatureValue(v asn1.RawValue) ([]byte, error) {
+	var extensions []pkix.Extension
+	if _, err := asn1.Unmarshal(v.Bytes, &extensions); err != nil {
+		return nil, errors.Wrap(err, "error parsing certificate extensions")
+	}
+	filtered := extensions[:0]
+	for _, ext := range extensions {
+		if !ext.Id.Equal(oidExtensionAltSignatureValue) {
+			filtered = append(filtered, ext)
+		}
+	}
+	if len(filtered) == len(extensions) {
+		return v.FullBytes, nil
+	}
+	b, err := asn1.Marshal(filtered)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling certificate extensions")
+	}
+	return asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        3,
+		IsCompound: true,
+		Bytes:      b,
+	})
+}
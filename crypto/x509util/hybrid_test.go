@@ -0,0 +1,81 @@
+// +build pqc
+
+package x509util
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/smallstep/cli/crypto/pqc"
+)
+
+func TestCreateHybridCertificate(t *testing.T) {
+	rootAlt, err := pqc.GenerateKey(pqc.MLDSA65)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootProfile("root-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootBytes, err := CreateHybridCertificate(root, rootAlt.Public().(*pqc.PublicKey), rootAlt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Subject().ExtraExtensions) != 0 {
+		t.Errorf("CreateHybridCertificate() modified the profile extensions")
+	}
+	rootCrt, err := x509.ParseCertificate(rootBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rootCrt.CheckSignatureFrom(rootCrt); err != nil {
+		t.Errorf("CheckSignatureFrom() error = %v", err)
+	}
+	if err := VerifyAltSignature(rootCrt, rootAlt.Public().(*pqc.PublicKey)); err != nil {
+		t.Errorf("VerifyAltSignature() error = %v", err)
+	}
+
+	leafAlt, err := pqc.GenerateKey(pqc.MLDSA44)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := NewLeafProfile("leaf", rootCrt, root.SubjectPrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafBytes, err := CreateHybridCertificate(leaf, leafAlt.Public().(*pqc.PublicKey), rootAlt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafCrt, err := x509.ParseCertificate(leafBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leafCrt.CheckSignatureFrom(rootCrt); err != nil {
+		t.Errorf("CheckSignatureFrom() error = %v", err)
+	}
+
+	issuerAlt, err := AltPublicKey(rootCrt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyAltSignature(leafCrt, issuerAlt); err != nil {
+		t.Errorf("VerifyAltSignature() error = %v", err)
+	}
+	if err := VerifyAltSignature(leafCrt, leafAlt.Public().(*pqc.PublicKey)); err == nil {
+		t.Errorf("VerifyAltSignature() error = nil, want error")
+	}
+	if pub, err := AltPublicKey(leafCrt); err != nil || !pub.Equal(leafAlt.Public()) {
+		t.Errorf("AltPublicKey() = %v, %v, want %v", pub, err, leafAlt.Public())
+	}
+
+	// Certificates without alternative signature
+	crt := mustParseCertificate(t, "test_files/ca.crt")
+	if _, err := AltPublicKey(crt); err == nil {
+		t.Errorf("AltPublicKey() error = nil, want error")
+	}
+	if err := VerifyAltSignature(crt, issuerAlt); err == nil {
+		t.Errorf("VerifyAltSignature() error = nil, want error")
+	}
+}
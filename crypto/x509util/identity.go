@@ -2,10 +2,11 @@ package x509util
 
 import (
 	"crypto/x509"
-	"io/ioutil"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/fileurl"
+	"github.com/smallstep/cli/kms"
 )
 
 // Identity contains a public/private x509 certificate/key pair.
@@ -23,15 +24,29 @@ func NewIdentity(c *x509.Certificate, k interface{}) *Identity {
 }
 
 // LoadIdentityFromDisk load a public certificate and private key (both in PEM
-// format) from disk.
+// format) from disk. The private key can also be a key URI, e.g. env:STEP_KEY
+// or awskms:alias/my-key.
 func LoadIdentityFromDisk(crtPath, keyPath string, pemOpts ...pemutil.Options) (*Identity, error) {
 	// Read using stepx509 to parse the PublicKey
 	crt, err := pemutil.ReadStepCertificate(crtPath)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	keyBytes, err := ioutil.ReadFile(keyPath)
-	if err != nil {
+
+	var keyBytes []byte
+	if kms.IsKMS(keyPath) {
+		if keyBytes, err = kms.ReadKey(keyPath); err != nil {
+			return nil, err
+		}
+		// The key is only available through a signer.
+		if keyBytes == nil {
+			signer, err := kms.NewSigner(keyPath)
+			if err != nil {
+				return nil, err
+			}
+			return NewIdentity(ToX509Certificate(crt), signer), nil
+		}
+	} else if keyBytes, err = fileurl.ReadFile(keyPath); err != nil {
 		return nil, errors.WithStack(err)
 	}
 	pemOpts = append(pemOpts, pemutil.WithFilename(keyPath))
@@ -0,0 +1,75 @@
+package x509util
+
+import (
+	"net"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/idna"
+)
+
+// NormalizeSANs converts the internationalized domain names in the given
+// Subject Alternative Names to their ASCII form as defined in RFC 5891, the
+// form required in certificates by RFC 5280. The domain of email addresses is
+// converted too, but internationalized local parts are not supported. Domain
+// names with invalid punycode labels, like "xn--", are rejected. IP addresses
+// and URIs are not modified.
+func NormalizeSANs(sans []string) ([]string, error) {
+	normalized := make([]string, len(sans))
+	for i, san := range sans {
+		var err error
+		switch {
+		case net.ParseIP(san) != nil, strings.Contains(san, "://"):
+			normalized[i] = san
+		case strings.Contains(san, "@"):
+			j := strings.LastIndex(san, "@")
+			if !isASCII(san[:j]) {
+				return nil, errors.Errorf("email address '%s' has an internationalized local part", san)
+			}
+			var domain string
+			if domain, err = normalizeDomain(san[j+1:]); err != nil {
+				return nil, err
+			}
+			normalized[i] = san[:j+1] + domain
+		default:
+			if normalized[i], err = normalizeDomain(san); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeDomain returns the ASCII form of a domain name, the wildcard label
+// is kept.
+func normalizeDomain(name string) (string, error) {
+	var prefix string
+	if strings.HasPrefix(name, "*.") {
+		prefix, name = "*.", name[2:]
+	}
+	if isASCII(name) {
+		// Only the domains with A-labels need to be validated.
+		if !strings.Contains(strings.ToLower(name), "xn--") {
+			return prefix + name, nil
+		}
+		if _, err := idna.Lookup.ToUnicode(name); err != nil {
+			return "", errors.Wrapf(err, "domain name '%s' is not valid", prefix+name)
+		}
+		return prefix + name, nil
+	}
+	ascii, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "domain name '%s' is not valid", prefix+name)
+	}
+	return prefix + ascii, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
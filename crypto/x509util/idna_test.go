@@ -0,0 +1,37 @@
+package x509util
+
+import (
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func TestNormalizeSANs(t *testing.T) {
+	tests := []struct {
+		name    string
+		sans    []string
+		want    []string
+		wantErr bool
+	}{
+		{"ascii", []string{"foo.example.com", "_acme.example.com", "10.0.0.1", "::1"}, []string{"foo.example.com", "_acme.example.com", "10.0.0.1", "::1"}, false},
+		{"idn", []string{"bücher.example", "*.münchen.de"}, []string{"xn--bcher-kva.example", "*.xn--mnchen-3ya.de"}, false},
+		{"uppercase idn", []string{"BÜCHER.example"}, []string{"xn--bcher-kva.example"}, false},
+		{"a-label", []string{"xn--bcher-kva.example"}, []string{"xn--bcher-kva.example"}, false},
+		{"email", []string{"jane@bücher.example", "joe@example.com"}, []string{"jane@xn--bcher-kva.example", "joe@example.com"}, false},
+		{"uri", []string{"spiffe://example.com/bücher"}, []string{"spiffe://example.com/bücher"}, false},
+		{"invalid a-label", []string{"xn--a.example"}, nil, true},
+		{"invalid idn", []string{"-bücher.example"}, nil, true},
+		{"idn local part", []string{"jürgen@example.com"}, nil, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NormalizeSANs(tc.sans)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.want, got)
+		})
+	}
+}
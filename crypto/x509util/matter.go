@@ -0,0 +1,184 @@
+package x509util
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/asn1"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// OIDMatterVendorID is the subject attribute with the Matter vendor id
+	// (VID) of a device attestation certificate.
+	OIDMatterVendorID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 2, 1}
+	// OIDMatterProductID is the subject attribute with the Matter product id
+	// (PID) of a device attestation certificate.
+	OIDMatterProductID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 2, 2}
+)
+
+// matterIDRegexp matches the legacy encoding of the ids in the common name,
+// e.g. "ACME Matter Devel DAC 5CDA9899 Mvid:FFF1 Mpid:8000".
+var matterIDRegexp = regexp.MustCompile(`\bM(vid|pid):([0-9A-F]{4})\b`)
+
+// ParseMatterID parses a Matter vendor or product id, a 16-bit hexadecimal
+// number with an optional 0x prefix, e.g. FFF1 or 0xFFF1.
+func ParseMatterID(s string) (uint16, error) {
+	v := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	id, err := strconv.ParseUint(v, 16, 16)
+	if err != nil || id == 0 {
+		return 0, errors.Errorf("invalid Matter id '%s': it must be a non-zero 16-bit hexadecimal number", s)
+	}
+	return uint16(id), nil
+}
+
+// MatterIDs returns the Matter vendor id and product id in the subject of a
+// certificate, 0 if an id is not present. The ids are read from their subject
+// attributes or, if there are none, from the common name.
+func MatterIDs(crt *x509.Certificate) (vid, pid uint16, err error) {
+	var found bool
+	for _, atv := range crt.Subject.Names {
+		var id *uint16
+		switch {
+		case atv.Type.Equal(OIDMatterVendorID):
+			id = &vid
+		case atv.Type.Equal(OIDMatterProductID):
+			id = &pid
+		default:
+			continue
+		}
+		s, ok := atv.Value.(string)
+		if !ok || len(s) != 4 || strings.ToUpper(s) != s {
+			return 0, 0, errors.Errorf("invalid Matter id %v: it must be 4 uppercase hexadecimal digits", atv.Value)
+		}
+		if *id != 0 {
+			return 0, 0, errors.Errorf("the subject has more than one Matter %s", matterIDName(atv.Type.Equal(OIDMatterVendorID)))
+		}
+		if *id, err = ParseMatterID(s); err != nil {
+			return 0, 0, err
+		}
+		found = true
+	}
+	if found {
+		return vid, pid, nil
+	}
+
+	for _, m := range matterIDRegexp.FindAllStringSubmatch(crt.Subject.CommonName, -1) {
+		id := &pid
+		if m[1] == "vid" {
+			id = &vid
+		}
+		if *id != 0 {
+			return 0, 0, errors.Errorf("the common name has more than one Matter %s", matterIDName(m[1] == "vid"))
+		}
+		if *id, err = ParseMatterID(m[2]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return vid, pid, nil
+}
+
+func matterIDName(vendor bool) string {
+	if vendor {
+		return "vendor id"
+	}
+	return "product id"
+}
+
+// VerifyMatterChain verifies that dac, pai and paa are a valid Matter device
+// attestation chain at the given time, following the rules of the Matter Core
+// Specification, section 6.2.2: the encoding of the certificates, their keys
+// and extensions, the vendor and product ids in each level, and the
+// signatures of the chain.
+func VerifyMatterChain(dac, pai, paa *x509.Certificate, now time.Time) error {
+	for _, c := range []struct {
+		name string
+		crt  *x509.Certificate
+		isCA bool
+	}{{"DAC", dac, false}, {"PAI", pai, true}, {"PAA", paa, true}} {
+		if err := checkMatterCertificate(c.crt, c.isCA); err != nil {
+			return errors.Wrapf(err, "invalid %s", c.name)
+		}
+	}
+	if !bytes.Equal(paa.RawSubject, paa.RawIssuer) {
+		return errors.New("invalid PAA: it is not self-signed")
+	}
+	if pai.MaxPathLen != 0 || !pai.MaxPathLenZero {
+		return errors.New("invalid PAI: it must have a path length constraint of 0")
+	}
+
+	dacVID, dacPID, err := MatterIDs(dac)
+	if err != nil {
+		return errors.Wrap(err, "invalid DAC")
+	}
+	paiVID, paiPID, err := MatterIDs(pai)
+	if err != nil {
+		return errors.Wrap(err, "invalid PAI")
+	}
+	paaVID, paaPID, err := MatterIDs(paa)
+	if err != nil {
+		return errors.Wrap(err, "invalid PAA")
+	}
+	switch {
+	case dacVID == 0 || dacPID == 0:
+		return errors.New("invalid DAC: it must have a Matter vendor id and product id")
+	case paiVID == 0:
+		return errors.New("invalid PAI: it must have a Matter vendor id")
+	case paaPID != 0:
+		return errors.New("invalid PAA: it must not have a Matter product id")
+	case dacVID != paiVID:
+		return errors.Errorf("the DAC vendor id %04X does not match the PAI vendor id %04X", dacVID, paiVID)
+	case paiPID != 0 && dacPID != paiPID:
+		return errors.Errorf("the DAC product id %04X does not match the PAI product id %04X", dacPID, paiPID)
+	case paaVID != 0 && paiVID != paaVID:
+		return errors.Errorf("the PAI vendor id %04X does not match the PAA vendor id %04X", paiVID, paaVID)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(paa)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(pai)
+	if _, err := dac.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return errors.Wrap(err, "error verifying the DAC chain")
+	}
+	return nil
+}
+
+// checkMatterCertificate checks the properties shared by all the certificates
+// in a Matter device attestation chain.
+func checkMatterCertificate(crt *x509.Certificate, isCA bool) error {
+	if crt.Version != 3 {
+		return errors.New("it must be an X.509 v3 certificate")
+	}
+	if k, ok := crt.PublicKey.(*ecdsa.PublicKey); !ok || k.Curve != elliptic.P256() {
+		return errors.New("it must have an EC P-256 key")
+	}
+	if crt.SignatureAlgorithm != x509.ECDSAWithSHA256 {
+		return errors.Errorf("it must be signed with %s, not %s", x509.ECDSAWithSHA256, crt.SignatureAlgorithm)
+	}
+	if len(crt.SubjectKeyId) == 0 || len(crt.AuthorityKeyId) == 0 {
+		return errors.New("it must have the subject and authority key identifier extensions")
+	}
+	if !crt.BasicConstraintsValid || crt.IsCA != isCA {
+		return errors.Errorf("it must have the basic constraints extension with cA %t", isCA)
+	}
+	if isCA {
+		if crt.KeyUsage&x509.KeyUsageCertSign == 0 || crt.KeyUsage&x509.KeyUsageCRLSign == 0 {
+			return errors.New("it must have the keyCertSign and cRLSign key usages")
+		}
+	} else if crt.KeyUsage&x509.KeyUsageDigitalSignature == 0 || crt.KeyUsage&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != 0 {
+		return errors.New("it must have the digitalSignature key usage and not the keyCertSign or cRLSign usages")
+	}
+	return nil
+}
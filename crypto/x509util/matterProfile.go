@@ -0,0 +1,160 @@
+package x509util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMatterDACValidity is the default validity of a Matter device
+// attestation certificate. Devices often use certificates without a
+// well-defined expiration, see MatterNoExpiration.
+var DefaultMatterDACValidity = time.Hour * 24 * 365 * 10
+
+// MatterNoExpiration is the NotAfter of a Matter certificate without a
+// well-defined expiration date, 99991231235959Z.
+var MatterNoExpiration = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// MatterPAA implements the Profile for a Matter Product Attestation Authority
+// (PAA), the self-signed root of the device attestation chains.
+type MatterPAA struct {
+	base
+}
+
+// DefaultDuration returns the default MatterPAA Certificate duration.
+func (p *MatterPAA) DefaultDuration() time.Duration {
+	return DefaultRootCertValidity
+}
+
+// MatterPAI implements the Profile for a Matter Product Attestation
+// Intermediate (PAI), the certificate that signs the device attestation
+// certificates of a vendor.
+type MatterPAI struct {
+	base
+}
+
+// DefaultDuration returns the default MatterPAI Certificate duration.
+func (p *MatterPAI) DefaultDuration() time.Duration {
+	return DefaultIntermediateCertValidity
+}
+
+// MatterDAC implements the Profile for a Matter Device Attestation
+// Certificate (DAC), the certificate of a device.
+type MatterDAC struct {
+	base
+}
+
+// DefaultDuration returns the default MatterDAC Certificate duration.
+func (p *MatterDAC) DefaultDuration() time.Duration {
+	return DefaultMatterDACValidity
+}
+
+// NewMatterPAAProfile returns a new Matter PAA x509 Certificate profile. The
+// vendor id is optional, 0 creates a PAA for any vendor.
+func NewMatterPAAProfile(cn string, vid uint16, withOps ...WithOption) (Profile, error) {
+	crt := defaultRootTemplate(cn)
+	crt.Subject = matterName(cn, vid, 0)
+	crt.Issuer = crt.Subject
+	crt.SignatureAlgorithm = x509.ECDSAWithSHA256
+	p, err := newProfile(&MatterPAA{}, crt, crt, nil, withOps...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := checkMatterKey(p.SubjectPublicKey()); err != nil {
+		return nil, err
+	}
+	// self-signed certificate, the authority key identifier is required
+	p.SetIssuerPrivateKey(p.SubjectPrivateKey())
+	crt.AuthorityKeyId = crt.SubjectKeyId
+	return p, nil
+}
+
+// NewMatterPAIProfile returns a new Matter PAI x509 Certificate profile. The
+// vendor id is required, the product id is optional and 0 creates a PAI for
+// all the products of the vendor.
+func NewMatterPAIProfile(cn string, vid, pid uint16, iss *x509.Certificate, issPriv crypto.PrivateKey, withOps ...WithOption) (Profile, error) {
+	if vid == 0 {
+		return nil, errors.New("a Matter PAI requires a vendor id")
+	}
+	sub := defaultIntermediateTemplate(cn)
+	sub.Subject = matterName(cn, vid, pid)
+	sub.SignatureAlgorithm = x509.ECDSAWithSHA256
+	p, err := newProfile(&MatterPAI{}, sub, iss, issPriv, withOps...)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMatterKey(p.SubjectPublicKey()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewMatterDACProfile returns a new Matter DAC x509 Certificate profile. The
+// vendor id and the product id are required.
+func NewMatterDACProfile(cn string, vid, pid uint16, iss *x509.Certificate, issPriv crypto.PrivateKey, withOps ...WithOption) (Profile, error) {
+	if vid == 0 || pid == 0 {
+		return nil, errors.New("a Matter DAC requires a vendor id and a product id")
+	}
+	sub := defaultMatterDACTemplate(matterName(cn, vid, pid), iss.Subject)
+	p, err := newProfile(&MatterDAC{}, sub, iss, issPriv, withOps...)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMatterKey(p.SubjectPublicKey()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func defaultMatterDACTemplate(sub pkix.Name, iss pkix.Name) *x509.Certificate {
+	notBefore := time.Now()
+	return &x509.Certificate{
+		IsCA:                  false,
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(DefaultMatterDACValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		SignatureAlgorithm:    x509.ECDSAWithSHA256,
+		Issuer:                iss,
+		Subject:               sub,
+	}
+}
+
+// matterName returns a subject with the common name and the Matter vendor and
+// product ids, the ids are not added if they are 0. Matter requires the ids
+// to be encoded as UTF8String.
+func matterName(cn string, vid, pid uint16) pkix.Name {
+	name := pkix.Name{CommonName: cn}
+	for _, id := range []struct {
+		oid   asn1.ObjectIdentifier
+		value uint16
+	}{{OIDMatterVendorID, vid}, {OIDMatterProductID, pid}} {
+		if id.value == 0 {
+			continue
+		}
+		name.ExtraNames = append(name.ExtraNames, pkix.AttributeTypeAndValue{
+			Type: id.oid,
+			Value: asn1.RawValue{
+				Tag:   asn1.TagUTF8String,
+				Bytes: []byte(fmt.Sprintf("%04X", id.value)),
+			},
+		})
+	}
+	return name
+}
+
+// checkMatterKey returns an error if the key is not an EC P-256 key, the only
+// key type allowed in Matter certificates.
+func checkMatterKey(pub interface{}) error {
+	if k, ok := pub.(*ecdsa.PublicKey); !ok || k.Curve != elliptic.P256() {
+		return errors.New("an EC P-256 key is required for Matter certificates")
+	}
+	return nil
+}
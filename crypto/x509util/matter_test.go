@@ -0,0 +1,212 @@
+package x509util
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"strings"
+	"testing"
+	"time"
+)
+
+type matterTestChain struct {
+	paa, pai, dac Profile
+}
+
+func (c *matterTestChain) certificates(t *testing.T) (dac, pai, paa *x509.Certificate) {
+	t.Helper()
+	var crts []*x509.Certificate
+	for _, p := range []Profile{c.dac, c.pai, c.paa} {
+		b, err := p.CreateCertificate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		crt, err := x509.ParseCertificate(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crts = append(crts, crt)
+	}
+	return crts[0], crts[1], crts[2]
+}
+
+func newMatterTestChain(t *testing.T, paaVID, paiVID, paiPID, dacVID, dacPID uint16) *matterTestChain {
+	t.Helper()
+	paa, err := NewMatterPAAProfile("Test PAA", paaVID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := paa.CreateCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	paaCrt, err := x509.ParseCertificate(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pai, err := NewMatterPAIProfile("Test PAI", paiVID, paiPID, paaCrt, paa.SubjectPrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = pai.CreateCertificate(); err != nil {
+		t.Fatal(err)
+	}
+	paiCrt, err := x509.ParseCertificate(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dac, err := NewMatterDACProfile("Test DAC", dacVID, dacPID, paiCrt, pai.SubjectPrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &matterTestChain{paa: paa, pai: pai, dac: dac}
+}
+
+func TestMatterProfiles(t *testing.T) {
+	dac, pai, paa := newMatterTestChain(t, 0xFFF1, 0xFFF1, 0x8000, 0xFFF1, 0x8000).certificates(t)
+	if err := VerifyMatterChain(dac, pai, paa, time.Now()); err != nil {
+		t.Fatalf("VerifyMatterChain() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		crt      *x509.Certificate
+		vid, pid uint16
+	}{{dac, 0xFFF1, 0x8000}, {pai, 0xFFF1, 0x8000}, {paa, 0xFFF1, 0}} {
+		vid, pid, err := MatterIDs(tt.crt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vid != tt.vid || pid != tt.pid {
+			t.Errorf("MatterIDs(%s) = %04X, %04X, want %04X, %04X", tt.crt.Subject.CommonName, vid, pid, tt.vid, tt.pid)
+		}
+	}
+
+	// The ids must be encoded as UTF8String.
+	if !strings.Contains(string(dac.RawSubject), "\x0c\x04FFF1") || !strings.Contains(string(dac.RawSubject), "\x0c\x048000") {
+		t.Errorf("DAC subject does not have the ids encoded as UTF8String")
+	}
+	if dac.IsCA || dac.KeyUsage != x509.KeyUsageDigitalSignature || len(dac.AuthorityKeyId) == 0 {
+		t.Errorf("unexpected DAC extensions")
+	}
+	if len(paa.AuthorityKeyId) == 0 {
+		t.Errorf("PAA does not have an authority key identifier")
+	}
+}
+
+func TestVerifyMatterChain(t *testing.T) {
+	tests := []struct {
+		name                                   string
+		paaVID, paiVID, paiPID, dacVID, dacPID uint16
+		now                                    time.Time
+		wantErr                                string
+	}{
+		{"ok PAI without PID", 0xFFF1, 0xFFF1, 0, 0xFFF1, 0x8001, time.Now(), ""},
+		{"ok PAA without VID", 0, 0xFFF1, 0x8000, 0xFFF1, 0x8000, time.Now(), ""},
+		{"fail DAC VID", 0, 0xFFF1, 0, 0xFFF2, 0x8000, time.Now(), "DAC vendor id FFF2 does not match"},
+		{"fail DAC PID", 0, 0xFFF1, 0x8000, 0xFFF1, 0x8001, time.Now(), "DAC product id 8001 does not match"},
+		{"fail PAA VID", 0xFFF2, 0xFFF1, 0, 0xFFF1, 0x8000, time.Now(), "PAI vendor id FFF1 does not match"},
+		{"fail expired", 0, 0xFFF1, 0, 0xFFF1, 0x8000, time.Now().Add(24 * 365 * 11 * time.Hour), "error verifying the DAC chain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dac, pai, paa := newMatterTestChain(t, tt.paaVID, tt.paiVID, tt.paiPID, tt.dacVID, tt.dacPID).certificates(t)
+			err := VerifyMatterChain(dac, pai, paa, tt.now)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("VerifyMatterChain() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("VerifyMatterChain() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The DAC is not signed by the PAI.
+	c1 := newMatterTestChain(t, 0, 0xFFF1, 0, 0xFFF1, 0x8000)
+	c2 := newMatterTestChain(t, 0, 0xFFF1, 0, 0xFFF1, 0x8000)
+	dac, _, _ := c1.certificates(t)
+	_, pai, paa := c2.certificates(t)
+	if err := VerifyMatterChain(dac, pai, paa, time.Now()); err == nil {
+		t.Error("VerifyMatterChain() error = nil, want error")
+	}
+	// A leaf certificate is not a DAC.
+	leaf, err := NewLeafProfile("leaf", pai, c2.pai.SubjectPrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := leaf.CreateCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leafCrt, err := x509.ParseCertificate(b); err != nil {
+		t.Fatal(err)
+	} else if err := VerifyMatterChain(leafCrt, pai, paa, time.Now()); err == nil {
+		t.Error("VerifyMatterChain() error = nil, want error")
+	}
+}
+
+func TestMatterProfiles_errors(t *testing.T) {
+	paa, err := NewMatterPAAProfile("Test PAA", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMatterPAAProfile("Test PAA", 0, GenerateKeyPair("EC", "P-384", 0)); err == nil {
+		t.Error("NewMatterPAAProfile() with a P-384 key error = nil, want error")
+	}
+	if _, err := NewMatterPAIProfile("Test PAI", 0, 0, paa.Subject(), paa.SubjectPrivateKey()); err == nil {
+		t.Error("NewMatterPAIProfile() without VID error = nil, want error")
+	}
+	if _, err := NewMatterDACProfile("Test DAC", 0xFFF1, 0, paa.Subject(), paa.SubjectPrivateKey()); err == nil {
+		t.Error("NewMatterDACProfile() without PID error = nil, want error")
+	}
+}
+
+func TestMatterIDs(t *testing.T) {
+	vid := func(s string) pkix.AttributeTypeAndValue {
+		return pkix.AttributeTypeAndValue{Type: OIDMatterVendorID, Value: s}
+	}
+	pid := func(s string) pkix.AttributeTypeAndValue {
+		return pkix.AttributeTypeAndValue{Type: OIDMatterProductID, Value: s}
+	}
+	tests := []struct {
+		name     string
+		cn       string
+		names    []pkix.AttributeTypeAndValue
+		vid, pid uint16
+		wantErr  bool
+	}{
+		{"attributes", "DAC", []pkix.AttributeTypeAndValue{vid("FFF1"), pid("8000")}, 0xFFF1, 0x8000, false},
+		{"attributes over common name", "DAC Mvid:FFF2", []pkix.AttributeTypeAndValue{vid("FFF1")}, 0xFFF1, 0, false},
+		{"common name", "ACME Matter Devel DAC 5CDA9899 Mvid:FFF1 Mpid:8000", nil, 0xFFF1, 0x8000, false},
+		{"common name vid", "ACME Matter PAI Mvid:FFF1", nil, 0xFFF1, 0, false},
+		{"none", "ACME", nil, 0, 0, false},
+		{"fail lowercase", "DAC", []pkix.AttributeTypeAndValue{vid("fff1")}, 0, 0, true},
+		{"fail length", "DAC", []pkix.AttributeTypeAndValue{vid("FFF")}, 0, 0, true},
+		{"fail zero", "DAC", []pkix.AttributeTypeAndValue{pid("0000")}, 0, 0, true},
+		{"fail duplicated", "DAC", []pkix.AttributeTypeAndValue{vid("FFF1"), vid("FFF1")}, 0, 0, true},
+		{"fail duplicated common name", "DAC Mvid:FFF1 Mvid:FFF2", nil, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crt := &x509.Certificate{Subject: pkix.Name{CommonName: tt.cn, Names: tt.names}}
+			vid, pid, err := MatterIDs(crt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatterIDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if vid != tt.vid || pid != tt.pid {
+				t.Errorf("MatterIDs() = %04X, %04X, want %04X, %04X", vid, pid, tt.vid, tt.pid)
+			}
+		})
+	}
+}
+
+func TestParseMatterID(t *testing.T) {
+	for s, want := range map[string]uint16{"FFF1": 0xFFF1, "0x8000": 0x8000, "fff1": 0xFFF1, "1": 1} {
+		if got, err := ParseMatterID(s); err != nil || got != want {
+			t.Errorf("ParseMatterID(%s) = %04X, %v, want %04X", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "0", "0000", "10000", "xyz"} {
+		if _, err := ParseMatterID(s); err == nil {
+			t.Errorf("ParseMatterID(%s) error = nil, want error", s)
+		}
+	}
+}
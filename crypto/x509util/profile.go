@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/clock"
+	"github.com/smallstep/cli/crypto/fips"
 	"github.com/smallstep/cli/crypto/keys"
 	"github.com/smallstep/cli/crypto/pemutil"
 	stepx509 "github.com/smallstep/cli/pkg/x509"
@@ -190,6 +192,16 @@ func WithIPAddresses(ips []net.IP) WithOption {
 	}
 }
 
+// WithEmailAddresses returns a Profile modifier which sets the email addresses
+// that will be bound to the subject alternative name extension of the Certificate.
+func WithEmailAddresses(emails []string) WithOption {
+	return func(p Profile) error {
+		crt := p.Subject()
+		crt.EmailAddresses = emails
+		return nil
+	}
+}
+
 // WithHosts returns a Profile modifier which sets the DNS Names and IP Addresses
 // that will be bound to the subject Certificate.
 //
@@ -336,6 +348,16 @@ func (b *base) CreateCertificate() ([]byte, error) {
 		return nil, errors.Errorf("Profile does not have issuer private key. Use setters to populate this field.")
 	}
 
+	if err := fips.CheckKey(b.SubjectPublicKey()); err != nil {
+		return nil, err
+	}
+	if err := fips.CheckKey(b.issPriv); err != nil {
+		return nil, err
+	}
+	if err := clock.Check(); err != nil {
+		return nil, err
+	}
+
 	sub := ToStepX509Certificate(b.Subject())
 	iss := ToStepX509Certificate(b.Issuer())
 
@@ -0,0 +1,48 @@
+package x509util
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"time"
+)
+
+// SMIME implements the Profile for an S/MIME certificate, a leaf certificate
+// used to sign and encrypt email.
+type SMIME struct {
+	base
+}
+
+// NewSMIMEProfile returns a new S/MIME x509 Certificate profile. The email
+// addresses of the certificate are set with the WithEmailAddresses modifier.
+// A new public/private key pair will be generated for the Profile if not set
+// in the `withOps` profile modifiers.
+func NewSMIMEProfile(cn string, iss *x509.Certificate, issPriv crypto.PrivateKey, withOps ...WithOption) (Profile, error) {
+	sub := defaultSMIMETemplate(pkix.Name{CommonName: cn}, iss.Subject)
+	p, err := newProfile(&SMIME{}, sub, iss, issPriv, withOps...)
+	if err != nil {
+		return nil, err
+	}
+	// Only RSA keys can be used for key transport, other keys only sign.
+	if _, ok := p.SubjectPublicKey().(*rsa.PublicKey); ok {
+		sub.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	return p, nil
+}
+
+func defaultSMIMETemplate(sub pkix.Name, iss pkix.Name) *x509.Certificate {
+	notBefore := time.Now()
+	return &x509.Certificate{
+		IsCA:      false,
+		NotBefore: notBefore,
+		NotAfter:  notBefore.Add(DefaultCertValidity),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageEmailProtection,
+		},
+		BasicConstraintsValid: false,
+		Issuer:                iss,
+		Subject:               sub,
+	}
+}
@@ -85,6 +85,26 @@ func (c CipherSuites) Value() []uint16 {
 	return values
 }
 
+// CipherSuiteName returns the name of the cipher suite with the given id. If
+// the cipher suite is not known it will return its hexadecimal value.
+func CipherSuiteName(id uint16) string {
+	for name, v := range cipherSuites {
+		if v == id {
+			return name
+		}
+	}
+	// TLS 1.3 cipher suites are not configurable.
+	switch id {
+	case tls.TLS_AES_128_GCM_SHA256:
+		return "TLS_AES_128_GCM_SHA256"
+	case tls.TLS_AES_256_GCM_SHA384:
+		return "TLS_AES_256_GCM_SHA384"
+	case tls.TLS_CHACHA20_POLY1305_SHA256:
+		return "TLS_CHACHA20_POLY1305_SHA256"
+	}
+	return fmt.Sprintf("0x%04X", id)
+}
+
 // cipherSuites has the list of supported cipher suites.
 var cipherSuites = map[string]uint16{
 	"TLS_RSA_WITH_RC4_128_SHA":                tls.TLS_RSA_WITH_RC4_128_SHA,
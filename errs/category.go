@@ -0,0 +1,197 @@
+package errs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Category is the type used to classify the errors returned by the commands.
+// Each category maps to a documented exit status and to a machine-readable
+// identifier.
+type Category int
+
+const (
+	// CategoryUnknown is the category of the errors that have not been
+	// classified.
+	CategoryUnknown Category = iota
+	// CategoryUsage is the category of errors produced by an invalid use of a
+	// command: missing or invalid flags or positional arguments.
+	CategoryUsage
+	// CategoryIO is the category of errors produced while reading or writing
+	// files, or reading from the standard input.
+	CategoryIO
+	// CategoryCrypto is the category of errors produced by cryptographic
+	// operations, like invalid signatures or malformed keys.
+	CategoryCrypto
+	// CategoryNetwork is the category of errors produced while connecting to a
+	// remote server.
+	CategoryNetwork
+	// CategoryPolicy is the category of errors produced when an input is well
+	// formed but it's rejected by a validation rule, like an expired token or
+	// an untrusted certificate.
+	CategoryPolicy
+)
+
+// Exit codes returned by step. The exit code 2 is reserved for unexpected
+// panics.
+const (
+	ExitCodeUnknown = 1
+	ExitCodeUsage   = 3
+	ExitCodeIO      = 4
+	ExitCodeCrypto  = 5
+	ExitCodeNetwork = 6
+	ExitCodePolicy  = 7
+)
+
+// ErrorFormatEnv defines the name of the environment variable used to change
+// the format of the errors printed by step. The only supported value is
+// "json".
+const ErrorFormatEnv = "STEPERRORFORMAT"
+
+// String returns the machine-readable identifier of the category.
+func (c Category) String() string {
+	switch c {
+	case CategoryUsage:
+		return "usage"
+	case CategoryIO:
+		return "io"
+	case CategoryCrypto:
+		return "crypto"
+	case CategoryNetwork:
+		return "network"
+	case CategoryPolicy:
+		return "policy"
+	default:
+		return "unknown"
+	}
+}
+
+// ExitCode returns the exit status associated with the category.
+func (c Category) ExitCode() int {
+	switch c {
+	case CategoryUsage:
+		return ExitCodeUsage
+	case CategoryIO:
+		return ExitCodeIO
+	case CategoryCrypto:
+		return ExitCodeCrypto
+	case CategoryNetwork:
+		return ExitCodeNetwork
+	case CategoryPolicy:
+		return ExitCodePolicy
+	default:
+		return ExitCodeUnknown
+	}
+}
+
+// Error is an error with a category.
+type Error struct {
+	Category Category
+	Err      error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Cause implements the causer interface of the github.com/pkg/errors package.
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Format implements fmt.Formatter, it makes "%+v" print the stack trace of the
+// wrapped error if available.
+func (e *Error) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('+') {
+		fmt.Fprintf(f, "%+v", e.Err)
+		return
+	}
+	fmt.Fprint(f, e.Err.Error())
+}
+
+// WithCategory returns an error that annotates err with the given category. If
+// err is nil, WithCategory returns nil.
+func WithCategory(err error, c Category) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Category: c, Err: err}
+}
+
+// Usage returns the given error annotated with the usage category.
+func Usage(err error) error {
+	return WithCategory(err, CategoryUsage)
+}
+
+// IO returns the given error annotated with the I/O category.
+func IO(err error) error {
+	return WithCategory(err, CategoryIO)
+}
+
+// Crypto returns the given error annotated with the crypto category.
+func Crypto(err error) error {
+	return WithCategory(err, CategoryCrypto)
+}
+
+// Network returns the given error annotated with the network category.
+func Network(err error) error {
+	return WithCategory(err, CategoryNetwork)
+}
+
+// Policy returns the given error annotated with the policy category.
+func Policy(err error) error {
+	return WithCategory(err, CategoryPolicy)
+}
+
+// CategoryOf returns the category of the first categorized error in the chain
+// of causes of err. It returns CategoryUnknown if there is none.
+func CategoryOf(err error) Category {
+	type causer interface {
+		Cause() error
+	}
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e.Category
+		}
+		c, ok := err.(causer)
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+	return CategoryUnknown
+}
+
+// ExitCode returns the exit status for the given error. It returns 0 if err is
+// nil.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return CategoryOf(err).ExitCode()
+}
+
+// IsJSONFormat returns true if the errors must be printed as JSON.
+func IsJSONFormat() bool {
+	return strings.EqualFold(os.Getenv(ErrorFormatEnv), "json")
+}
+
+// JSON returns the JSON representation of the given error. The representation
+// includes the error message, the machine-readable identifier of the category
+// and the exit code.
+func JSON(err error) ([]byte, error) {
+	c := CategoryOf(err)
+	b, e := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":     c.String(),
+			"exitCode": c.ExitCode(),
+			"message":  err.Error(),
+		},
+	})
+	return b, errors.Wrap(e, "error marshaling error")
+}
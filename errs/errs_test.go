@@ -7,6 +7,7 @@ import (
 
 	"errors"
 
+	pkgerrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -42,3 +43,40 @@ func TestFileError(t *testing.T) {
 		require.Contains(t, err.Error(), tt.expected)
 	}
 }
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		code     int
+		category string
+	}{
+		{"nil", nil, 0, "unknown"},
+		{"unknown", errors.New("an error"), ExitCodeUnknown, "unknown"},
+		{"usage", Usage(errors.New("an error")), ExitCodeUsage, "usage"},
+		{"io", FileError(os.NewSyscallError("open", errors.New("an error")), "myfile"), ExitCodeIO, "io"},
+		{"crypto", Crypto(errors.New("an error")), ExitCodeCrypto, "crypto"},
+		{"network", Network(errors.New("an error")), ExitCodeNetwork, "network"},
+		{"policy", Policy(errors.New("an error")), ExitCodePolicy, "policy"},
+		{"wrapped", Wrap(Policy(errors.New("an error")), "wrapped"), ExitCodePolicy, "policy"},
+		{"pkg/errors wrapped", pkgerrors.Wrap(Policy(errors.New("an error")), "wrapped"), ExitCodePolicy, "policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.code, ExitCode(tt.err))
+			require.Equal(t, tt.category, CategoryOf(tt.err).String())
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	b, err := JSON(Crypto(errors.New("invalid signature")))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"error":{"code":"crypto","exitCode":5,"message":"invalid signature"}}`, string(b))
+}
+
+func TestUsageHelpers(t *testing.T) {
+	require.Equal(t, CategoryUsage, CategoryOf(RequiredSubtleFlag(nil, "foo")))
+	require.Equal(t, CategoryUsage, CategoryOf(MutuallyExclusiveFlags(nil, "foo", "bar")))
+	require.Equal(t, "flag '--foo' and flag '--bar' are mutually exclusive", MutuallyExclusiveFlags(nil, "foo", "bar").Error())
+}
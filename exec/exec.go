@@ -33,7 +33,7 @@ func Exec(name string, arg ...string) {
 // same code. Run will also forward all the signals sent to step to the
 // command.
 func Run(name string, arg ...string) {
-	cmd, exitCh, err := run(name, arg...)
+	cmd, exitCh, err := run(nil, name, arg...)
 	if err != nil {
 		errorAndExit(name, err)
 	}
@@ -55,7 +55,7 @@ func RunWithPid(pidFile, name string, arg ...string) {
 	}
 
 	// Run process
-	cmd, exitCh, err := run(name, arg...)
+	cmd, exitCh, err := run(nil, name, arg...)
 	if err != nil {
 		f.Close()
 		os.Remove(f.Name())
@@ -77,6 +77,30 @@ func RunWithPid(pidFile, name string, arg ...string) {
 	exitCh <- 0
 }
 
+// Start starts the command with the current environment and the given extra
+// environment variables, forwarding all the signals sent to step to the
+// command. It returns the process of the command and a function that waits
+// until the command finishes and exits with the same code. A non-zero exit
+// code is not printed, the command reports its own errors. It is used when
+// step runs as the init process of a container, and to run the plugins.
+func Start(env []string, name string, arg ...string) (*os.Process, func(), error) {
+	cmd, exitCh, err := run(env, name, arg...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error running %s", name)
+	}
+	wait := func() {
+		if err := cmd.Wait(); err != nil {
+			if _, ok := err.(*exec.ExitError); !ok {
+				errorf(name, err)
+			}
+		}
+		// exit and wait until os.Exit
+		exitCh <- getExitStatus(cmd)
+		exitCh <- 0
+	}
+	return cmd.Process, wait, nil
+}
+
 // OpenInBrowser opens the given url on a web browser
 func OpenInBrowser(url string) error {
 	var cmd *exec.Cmd
@@ -119,8 +143,11 @@ func Command(name string, args ...string) ([]byte, error) {
 	return out, nil
 }
 
-func run(name string, arg ...string) (*exec.Cmd, chan int, error) {
+func run(env []string, name string, arg ...string) (*exec.Cmd, chan int, error) {
 	cmd := exec.Command(name, arg...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
@@ -0,0 +1,142 @@
+// Package fileurl implements the reading of certificates, keys, JWK sets and
+// other files from URLs, so they don't need to be downloaded before running a
+// command. HTTPS URLs are always supported, and other schemes, like s3:// or
+// gs://, are handled by backends that register themselves using Register.
+package fileurl
+
+import (
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MaxSize is the maximum size of a file read from a URL.
+const MaxSize = 10 << 20
+
+// Fetcher returns the contents of the files referenced by the URLs of a
+// scheme.
+type Fetcher interface {
+	Fetch(u *url.URL) ([]byte, error)
+}
+
+// FetcherFunc is an adapter to allow the use of ordinary functions as a
+// Fetcher.
+type FetcherFunc func(u *url.URL) ([]byte, error)
+
+// Fetch calls f(u).
+func (f FetcherFunc) Fetch(u *url.URL) ([]byte, error) {
+	return f(u)
+}
+
+var (
+	fetchersMu sync.RWMutex
+	fetchers   = make(map[string]Fetcher)
+)
+
+// Register makes a fetcher available for the given scheme. It panics if the
+// scheme is already registered or the fetcher is nil.
+func Register(scheme string, f Fetcher) {
+	scheme = strings.ToLower(scheme)
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+	if f == nil {
+		panic("fileurl: Register fetcher is nil")
+	}
+	if _, ok := fetchers[scheme]; ok {
+		panic("fileurl: Register called twice for scheme " + scheme)
+	}
+	fetchers[scheme] = f
+}
+
+// Schemes returns the sorted list of registered schemes.
+func Schemes() []string {
+	fetchersMu.RLock()
+	defer fetchersMu.RUnlock()
+	schemes := make([]string, 0, len(fetchers))
+	for scheme := range fetchers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// scheme returns the scheme of the given name if it has the form
+// scheme://..., or an empty string.
+func scheme(name string) string {
+	i := strings.Index(name, "://")
+	if i <= 0 {
+		return ""
+	}
+	return strings.ToLower(name[:i])
+}
+
+// IsURL returns true if the given name is a URL with a registered scheme.
+func IsURL(name string) bool {
+	s := scheme(name)
+	if s == "" {
+		return false
+	}
+	fetchersMu.RLock()
+	defer fetchersMu.RUnlock()
+	_, ok := fetchers[s]
+	return ok
+}
+
+// Read returns the contents of the file referenced by the given URL.
+func Read(rawurl string) ([]byte, error) {
+	s := scheme(rawurl)
+	fetchersMu.RLock()
+	f, ok := fetchers[s]
+	fetchersMu.RUnlock()
+	switch {
+	case s == "http":
+		return nil, errors.Errorf("error reading %s: http URLs are not supported, use https", rawurl)
+	case !ok:
+		return nil, errors.Errorf("error reading %s: unsupported URL scheme, use one of %s", rawurl, strings.Join(Schemes(), ", "))
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", rawurl)
+	}
+	b, err := f.Fetch(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", redact(u))
+	}
+	return b, nil
+}
+
+// ReadFile returns the contents of the given URL or file. Errors reading
+// files are returned unwrapped, so they can be checked with os.IsNotExist.
+func ReadFile(name string) ([]byte, error) {
+	if IsURL(name) || scheme(name) == "http" {
+		return Read(name)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// ReadAll reads from r until EOF, returning an error if the data is larger
+// than MaxSize. It is used by the fetchers to read the response bodies.
+func ReadAll(r io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > MaxSize {
+		return nil, errors.Errorf("file is larger than %d bytes", MaxSize)
+	}
+	return b, nil
+}
+
+// redact removes the user information and the query, that can contain
+// credentials, from the URL used in errors.
+func redact(u *url.URL) string {
+	v := *u
+	v.User = nil
+	v.RawQuery = ""
+	return v.String()
+}
@@ -0,0 +1,88 @@
+package fileurl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func init() {
+	Register("test", FetcherFunc(func(u *url.URL) ([]byte, error) {
+		return []byte(u.Host + u.Path), nil
+	}))
+}
+
+func TestIsURL(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"https://ca.smallstep.com/roots.pem", true},
+		{"HTTPS://ca.smallstep.com/roots.pem", true},
+		{"test://bucket/root_ca.crt", true},
+		{"http://ca.smallstep.com/roots.pem", false},
+		{"foo://bucket/root_ca.crt", false},
+		{"root_ca.crt", false},
+		{"/path/to/root_ca.crt", false},
+		{"://root_ca.crt", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equals(t, tt.want, IsURL(tt.name))
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileurl")
+	assert.FatalError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "root_ca.crt")
+	assert.FatalError(t, ioutil.WriteFile(filename, []byte("file contents"), 0600))
+
+	tests := []struct {
+		name    string
+		want    []byte
+		wantErr bool
+	}{
+		{filename, []byte("file contents"), false},
+		{"test://bucket/root_ca.crt", []byte("bucket/root_ca.crt"), false},
+		{filepath.Join(dir, "missing.crt"), nil, true},
+		{"http://ca.smallstep.com/roots.pem", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadFile(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadFile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equals(t, tt.want, got)
+		})
+	}
+}
+
+func TestRead_unsupported(t *testing.T) {
+	_, err := Read("foo://bucket/root_ca.crt")
+	assert.Error(t, err)
+}
+
+func TestReadAll(t *testing.T) {
+	b, err := ReadAll(bytes.NewReader(make([]byte, MaxSize)))
+	assert.FatalError(t, err)
+	assert.Equals(t, MaxSize, len(b))
+
+	_, err = ReadAll(bytes.NewReader(make([]byte, MaxSize+1)))
+	assert.Error(t, err)
+}
+
+func TestRedact(t *testing.T) {
+	u, err := url.Parse("s3://user:pass@bucket/root_ca.crt?profile=prod")
+	assert.FatalError(t, err)
+	assert.Equals(t, "s3://bucket/root_ca.crt", redact(u))
+}
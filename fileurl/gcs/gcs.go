@@ -0,0 +1,45 @@
+// Package gcs registers the gs:// URLs in the fileurl package, used to read
+// files stored in Google Cloud Storage, e.g. gs://my-bucket/path/to/root_ca.crt.
+package gcs
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/fileurl"
+)
+
+// Scheme is the scheme of the URLs of Cloud Storage objects.
+const Scheme = "gs"
+
+func init() {
+	fileurl.Register(Scheme, fileurl.FetcherFunc(Fetch))
+}
+
+// Fetch returns the contents of the Cloud Storage object in the given URL. The
+// credentials are the Application Default Credentials: the file in the
+// GOOGLE_APPLICATION_CREDENTIALS environment variable, the gcloud credentials
+// or the service account of the instance.
+func Fetch(u *url.URL) ([]byte, error) {
+	bucket, object := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || object == "" {
+		return nil, errors.New("the URL must have the format gs://<bucket>/<object>")
+	}
+
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating Cloud Storage client")
+	}
+	defer client.Close()
+
+	r, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return fileurl.ReadAll(r)
+}
@@ -0,0 +1,65 @@
+package fileurl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/trace"
+)
+
+// Timeout is the timeout of the requests to HTTPS URLs.
+var Timeout = 30 * time.Second
+
+func init() {
+	Register("https", FetcherFunc(fetchHTTPS))
+}
+
+// fetchHTTPS gets the file in the given HTTPS URL. If the command has the
+// --root flag with a local file, the server certificate must be signed by that
+// root instead of the system roots.
+func fetchHTTPS(u *url.URL) ([]byte, error) {
+	tr := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if root := command.RootFile(); root != "" && !IsURL(root) {
+		pool, err := readRootPool(root)
+		if err != nil {
+			return nil, err
+		}
+		tr.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	client := &http.Client{
+		Timeout:   Timeout,
+		Transport: trace.Transport(tr),
+	}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return ReadAll(resp.Body)
+}
+
+// readRootPool returns a pool with the PEM certificates in the given file.
+func readRootPool(filename string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", filename)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.Errorf("error reading %s: no PEM certificates found", filename)
+	}
+	return pool, nil
+}
@@ -0,0 +1,69 @@
+// Package s3 registers the s3:// URLs in the fileurl package, used to read
+// files stored in AWS S3, e.g. s3://my-bucket/path/to/root_ca.crt.
+package s3
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/fileurl"
+)
+
+// Scheme is the scheme of the URLs of S3 objects.
+const Scheme = "s3"
+
+// defaultRegion is the region used to find the region of a bucket.
+const defaultRegion = "us-east-1"
+
+func init() {
+	fileurl.Register(Scheme, fileurl.FetcherFunc(Fetch))
+}
+
+// Fetch returns the contents of the S3 object in the given URL. The
+// credentials are loaded using the standard AWS chain: environment variables,
+// shared configuration and credentials files, and instance roles. The URL
+// options region and profile can be used to overwrite them, e.g.
+// s3://my-bucket/root_ca.crt?region=us-west-2&profile=prod. If the region is
+// not configured it is discovered from the bucket.
+func Fetch(u *url.URL) ([]byte, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, errors.New("the URL must have the format s3://<bucket>/<key>")
+	}
+
+	q := u.Query()
+	opts := session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Profile:           q.Get("profile"),
+	}
+	if region := q.Get("region"); region != "" {
+		opts.Config.Region = aws.String(region)
+	}
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AWS session")
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		region, err := s3manager.GetBucketRegion(context.Background(), sess, bucket, defaultRegion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting the region of bucket %s", bucket)
+		}
+		sess.Config.Region = aws.String(region)
+	}
+
+	resp, err := s3.New(sess).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return fileurl.ReadAll(resp.Body)
+}
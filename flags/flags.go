@@ -1,6 +1,8 @@
 package flags
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/urfave/cli"
@@ -16,12 +18,55 @@ var Insecure = cli.BoolFlag{
 	Name: "insecure",
 }
 
+// Experimental is a cli.Flag used to enable experimental features, like the
+// post-quantum algorithms ML-DSA and ML-KEM. Their formats might change in
+// future versions.
+var Experimental = cli.BoolFlag{
+	Name: "experimental",
+	Usage: `Enables experimental features like the post-quantum algorithms ML-DSA and
+ML-KEM. Keys, signatures and certificates using them are meant only for
+interoperability testing.`,
+}
+
 // Force is a cli.Flag used to overwrite files.
 var Force = cli.BoolFlag{
 	Name:  "f,force",
 	Usage: "Force the overwrite of files without asking.",
 }
 
+// Mode is a cli.Flag used to set the permissions of the files written by a
+// command.
+var Mode = cli.StringFlag{
+	Name: "mode",
+	Usage: `The octal permissions, e.g. 0644, of the files written by the command. By
+default keys are written with 0600.`,
+}
+
+// Owner is a cli.Flag used to set the user that owns the files written by a
+// command.
+var Owner = cli.StringFlag{
+	Name: "owner",
+	Usage: `The user, name or id, that will own the files written by the command. Changing
+the owner usually requires running as root.`,
+}
+
+// Group is a cli.Flag used to set the group that owns the files written by a
+// command.
+var Group = cli.StringFlag{
+	Name:  "group",
+	Usage: `The group, name or id, that will own the files written by the command.`,
+}
+
+// LegacyEncryption is a cli.Flag used to encrypt private keys using the
+// legacy PEM encryption instead of PKCS#8.
+var LegacyEncryption = cli.BoolFlag{
+	Name: "insecure-legacy-encryption",
+	Usage: `Encrypt the private key using the legacy PEM encryption defined in RFC 1423,
+with the Proc-Type and DEK-Info headers, instead of PKCS#8 with PBES2. The legacy
+encryption uses a weak key derivation function and should only be used with
+software that cannot read encrypted PKCS#8 keys.`,
+}
+
 // PasswordFile is a cli.Flag used to pass a file to encrypt or decrypt a
 // private key.
 var PasswordFile = cli.StringFlag{
@@ -29,6 +74,52 @@ var PasswordFile = cli.StringFlag{
 	Usage: `The path to the <file> containing the password to encrypt or decrypt the private key.`,
 }
 
+// PasswordEnv is a cli.Flag used to pass the name of an environment variable
+// containing the password to encrypt or decrypt a private key.
+var PasswordEnv = cli.StringFlag{
+	Name: "password-env",
+	Usage: `The <name> of the environment variable containing the password to encrypt or
+decrypt the private key.`,
+}
+
+// PasswordFd is a cli.Flag used to pass a file descriptor to read the password
+// to encrypt or decrypt a private key.
+var PasswordFd = cli.IntFlag{
+	Name: "password-fd",
+	Usage: `The file descriptor <number> to read the password to encrypt or decrypt the
+private key from.`,
+}
+
+// PasswordKeychain is a cli.Flag used to pass the name of the item in the OS
+// keychain containing the password to encrypt or decrypt a private key.
+var PasswordKeychain = cli.StringFlag{
+	Name: "password-keychain",
+	Usage: `The <item> in the OS keychain containing the password to encrypt or decrypt
+the private key. On macOS the item is the service name of a generic password in
+the login keychain, on Linux it is the value of the "service" attribute of a
+secret in the Secret Service.`,
+}
+
+// PasswordVault is a cli.Flag used to pass the path of a secret in HashiCorp
+// Vault containing the password to encrypt or decrypt a private key.
+var PasswordVault = cli.StringFlag{
+	Name: "password-vault",
+	Usage: `The <path> of a secret in the Vault KV secrets engine containing the password to
+encrypt or decrypt the private key, e.g. secret/data/step?field=password. The
+field defaults to "password". The Vault server and token are configured using
+the VAULT_ADDR and VAULT_TOKEN environment variables.`,
+}
+
+// PasswordNames are the names of the flags returned by Password.
+var PasswordNames = []string{"password-file", "password-env", "password-fd", "password-keychain", "password-vault"}
+
+// Password returns the flags used to pass the password to encrypt or decrypt a
+// private key, starting with the given --password-file flag. Commands read the
+// password from them using utils.ReadPasswordFromCLI.
+func Password(passwordFile cli.StringFlag) []cli.Flag {
+	return []cli.Flag{passwordFile, PasswordEnv, PasswordFd, PasswordKeychain, PasswordVault}
+}
+
 // NoPassword is a cli.Flag used to avoid using a password to encrypt private
 // keys.
 var NoPassword = cli.BoolFlag{
@@ -37,6 +128,73 @@ var NoPassword = cli.BoolFlag{
 be written to disk unencrypted. This is not recommended. Requires **--insecure** flag.`,
 }
 
+// Metadata is a cli.Flag used to write a metadata file with the provenance of
+// the keys and certificates created by a command.
+var Metadata = cli.BoolFlag{
+	Name: "metadata",
+	Usage: `Write next to each key and certificate a <file>.meta.json with the creation
+time, the command, the host and user, the provisioner, the intended use, and the
+fingerprints. The metadata files are shown by **step inventory**.`,
+}
+
+// IntendedUse is a cli.Flag used to describe the purpose of the keys and
+// certificates in their metadata file.
+var IntendedUse = cli.StringFlag{
+	Name: "intended-use",
+	Usage: `A free-form <description> of what the key or certificate is for, stored in the
+metadata file. Requires **--metadata** flag.`,
+}
+
+// Interactive is a cli.Flag used to run a wizard that prompts for the
+// arguments and flags of a command.
+var Interactive = cli.BoolFlag{
+	Name: "interactive",
+	Usage: `Run a wizard that prompts for the arguments and flags not passed, validates
+them, and prints the equivalent non-interactive command to reuse in scripts.`,
+}
+
+// DryRun is a cli.Flag used to print the actions of a command without
+// performing them.
+var DryRun = cli.BoolFlag{
+	Name: "dry-run",
+	Usage: `Print the files that would be written, the trust stores that would be
+modified, and the requests that would be sent to the CA, without doing any of
+it.`,
+}
+
+// NoCache is a cli.Flag used to disable the cache of the documents fetched
+// from the network, like JWK Sets, CRLs or OCSP responses.
+var NoCache = cli.BoolFlag{
+	Name: "no-cache",
+	Usage: `Do not use the responses cached in $STEPPATH/cache. The documents fetched
+from the network will not be cached either.`,
+}
+
+// VerbatimSAN is a cli.Flag used to disable the conversion of internationalized
+// domain names in the --san flags to their ASCII form.
+var VerbatimSAN = cli.BoolFlag{
+	Name: "verbatim-san",
+	Usage: `Use the Subject Alternative Names exactly as they are passed. By default,
+internationalized domain names are converted to their ASCII (punycode) form and
+domain names with invalid punycode labels are rejected.`,
+}
+
+// BufferSize is a cli.Flag used to set the size of the buffer used to stream
+// the input of a command.
+var BufferSize = cli.StringFlag{
+	Name:  "buffer-size",
+	Value: "32KiB",
+	Usage: `The <size> of the buffer used to read the input, in bytes or with the
+suffixes KiB and MiB, e.g. 1MiB. The memory used is bounded by the buffer size,
+regardless of the size of the input.`,
+}
+
+// DefaultBufferSize is the default value of the --buffer-size flag in bytes.
+const DefaultBufferSize = 32 * 1024
+
+// maxBufferSize is the maximum value of the --buffer-size flag in bytes.
+const maxBufferSize = 1024 * 1024 * 1024
+
 // ParseTimeOrDuration is a helper that returns the time or the current time
 // with an extra duration. It's used in flags like --not-before, --not-after.
 func ParseTimeOrDuration(s string) (time.Time, bool) {
@@ -54,3 +212,24 @@ func ParseTimeOrDuration(s string) (time.Time, bool) {
 	}
 	return t, true
 }
+
+// ParseBufferSize is a helper that returns the number of bytes in a size like
+// 4096, 64KiB or 1MiB. It's used in the --buffer-size flag.
+func ParseBufferSize(s string) (int, bool) {
+	if s == "" {
+		return DefaultBufferSize, true
+	}
+
+	unit := 1
+	for suffix, u := range map[string]int{"KiB": 1024, "MiB": 1024 * 1024} {
+		if strings.HasSuffix(s, suffix) {
+			s, unit = strings.TrimSuffix(s, suffix), u
+			break
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n > maxBufferSize/unit {
+		return 0, false
+	}
+	return n * unit, true
+}
@@ -0,0 +1,254 @@
+// Package httpcache implements an HTTP client for the documents that step
+// fetches repeatedly, like JWK sets, OpenID Connect discovery documents,
+// certificates, CRLs or OCSP responses. The responses are stored in
+// $STEPPATH/cache, and reused while they are fresh according to the
+// Cache-Control or Expires headers. Stale responses are revalidated using the
+// ETag and Last-Modified headers.
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/config"
+)
+
+// MaxSize is the maximum size of a response.
+const MaxSize = 10 << 20
+
+// Client is an HTTP client that caches the responses on disk. A Client is safe
+// for concurrent use by multiple goroutines.
+type Client struct {
+	// Dir is the directory where the responses are stored. If empty the
+	// responses will not be cached.
+	Dir string
+	// HTTPClient is the client used to make the requests. If nil
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+	now        func() time.Time
+}
+
+// New returns a Client that stores the responses in $STEPPATH/cache. If
+// noCache is true the cache will not be used, this is used in commands with
+// the flag --no-cache.
+func New(noCache bool) *Client {
+	c := &Client{}
+	if !noCache {
+		c.Dir = Dir()
+	}
+	return c
+}
+
+// Dir returns the directory where the responses are stored by default.
+func Dir() string {
+	return filepath.Join(config.StepPath(), "cache")
+}
+
+// entry is the representation of a cached response.
+type entry struct {
+	URL          string    `json:"url"`
+	Expires      time.Time `json:"expires"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Body         []byte    `json:"body"`
+}
+
+// Get returns the body of the given URL, using the cached response if it's
+// still fresh.
+func (c *Client) Get(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating request for %s", url)
+	}
+	return c.do(req, url)
+}
+
+// Post sends a POST request with the given content type and body, and returns
+// the body of the response. The response is cached using the URL and request
+// body as the key, this is used for OCSP requests.
+func (c *Client) Post(url, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating request for %s", url)
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.do(req, url+"\n"+string(body))
+}
+
+// GetAll fetches the given URLs concurrently. The returned slices have the
+// same length as urls, and the body and error of each URL are in the same
+// position.
+func (c *Client) GetAll(urls []string) ([][]byte, []error) {
+	bodies := make([][]byte, len(urls))
+	errs := make([]error, len(urls))
+
+	var wg sync.WaitGroup
+	wg.Add(len(urls))
+	for i, u := range urls {
+		go func(i int, u string) {
+			defer wg.Done()
+			bodies[i], errs[i] = c.Get(u)
+		}(i, u)
+	}
+	wg.Wait()
+	return bodies, errs
+}
+
+func (c *Client) do(req *http.Request, key string) ([]byte, error) {
+	url := req.URL.String()
+	cached := c.load(key)
+	if cached != nil {
+		if c.timeNow().Before(cached.Expires) {
+			return cached.Body, nil
+		}
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving %s", url)
+	}
+	defer resp.Body.Close()
+
+	var body []byte
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		body = cached.Body
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		if body, err = ioutil.ReadAll(io.LimitReader(resp.Body, MaxSize)); err != nil {
+			return nil, errors.Wrapf(err, "error retrieving %s", url)
+		}
+	default:
+		return nil, errors.Errorf("error retrieving %s: %s", url, resp.Status)
+	}
+
+	if expires, ok := c.expiration(resp.Header); ok {
+		c.store(key, &entry{
+			URL:          url,
+			Expires:      expires,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Body:         body,
+		})
+	}
+	return body, nil
+}
+
+// expiration returns the time until the response is fresh. It returns false
+// if the response cannot be stored.
+func (c *Client) expiration(h http.Header) (time.Time, bool) {
+	now := c.timeNow()
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store":
+			return time.Time{}, false
+		case directive == "no-cache":
+			return now, true
+		case strings.HasPrefix(directive, "max-age="):
+			maxAge, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err != nil {
+				continue
+			}
+			if age, err := strconv.Atoi(h.Get("Age")); err == nil {
+				maxAge -= age
+			}
+			return now.Add(time.Duration(maxAge) * time.Second), true
+		}
+	}
+	if expires, err := http.ParseTime(h.Get("Expires")); err == nil {
+		return expires, true
+	}
+	// Responses without freshness information are stored only if they can
+	// be revalidated.
+	if h.Get("ETag") != "" || h.Get("Last-Modified") != "" {
+		return now, true
+	}
+	return time.Time{}, false
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) timeNow() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// filename returns the name of the file that stores the response with the
+// given key.
+func (c *Client) filename(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the cached response with the given key, or nil if it's not
+// available.
+func (c *Client) load(key string) *entry {
+	if c.Dir == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(c.filename(key))
+	if err != nil {
+		return nil
+	}
+	e := new(entry)
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil
+	}
+	return e
+}
+
+// store writes the response with the given key in the cache. Errors are
+// ignored, a failure to write the cache must not fail the command. The entry
+// is written in a temporary file and renamed so concurrent readers never see
+// a partial entry.
+func (c *Client) store(key string, e *entry) {
+	if c.Dir == "" {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(c.Dir, ".tmp")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.filename(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,136 @@
+package httpcache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*Client, func()) {
+	dir, err := ioutil.TempDir("", "httpcache")
+	require.NoError(t, err)
+	return &Client{Dir: dir}, func() { os.RemoveAll(dir) }
+}
+
+func TestClient_Get(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/error":
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "response %d", n)
+	}))
+	defer srv.Close()
+
+	c, cleanup := newTestClient(t)
+	defer cleanup()
+
+	tests := []struct {
+		path        string
+		first, next string
+	}{
+		{"/max-age", "response 1", "response 1"},
+		{"/no-store", "response 2", "response 3"},
+		{"/etag", "response 4", "response 4"},
+		{"/none", "response 6", "response 7"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			b, err := c.Get(srv.URL + tc.path)
+			require.NoError(t, err)
+			require.Equal(t, tc.first, string(b))
+			b, err = c.Get(srv.URL + tc.path)
+			require.NoError(t, err)
+			require.Equal(t, tc.next, string(b))
+		})
+	}
+
+	_, err := c.Get(srv.URL + "/error")
+	require.Error(t, err)
+
+	// Expired responses are fetched again.
+	c.now = func() time.Time { return time.Now().Add(time.Hour) }
+	b, err := c.Get(srv.URL + "/max-age")
+	require.NoError(t, err)
+	require.Equal(t, "response 9", string(b))
+
+	// Without a directory nothing is cached.
+	b, err = New(true).Get(srv.URL + "/max-age")
+	require.NoError(t, err)
+	require.Equal(t, "response 10", string(b))
+}
+
+func TestClient_GetAll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	c, cleanup := newTestClient(t)
+	defer cleanup()
+
+	urls := []string{srv.URL + "/a", srv.URL + "/error", srv.URL + "/b", srv.URL + "/a"}
+	bodies, errs := c.GetAll(urls)
+	require.Len(t, bodies, 4)
+	require.Len(t, errs, 4)
+	require.NoError(t, errs[0])
+	require.Equal(t, "/a", string(bodies[0]))
+	require.Error(t, errs[1])
+	require.NoError(t, errs[2])
+	require.Equal(t, "/b", string(bodies[2]))
+	require.NoError(t, errs[3])
+	require.Equal(t, "/a", string(bodies[3]))
+}
+
+func TestClient_expiration(t *testing.T) {
+	now := time.Now()
+	c := &Client{now: func() time.Time { return now }}
+	expires := now.Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Time
+		wantOK bool
+	}{
+		{"max-age", http.Header{"Cache-Control": {"max-age=300"}}, now.Add(5 * time.Minute), true},
+		{"max-age with age", http.Header{"Cache-Control": {"max-age=300"}, "Age": {"100"}}, now.Add(200 * time.Second), true},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache"}}, now, true},
+		{"no-store", http.Header{"Cache-Control": {"no-store, max-age=300"}}, time.Time{}, false},
+		{"expires", http.Header{"Expires": {expires.Format(http.TimeFormat)}}, expires, true},
+		{"etag", http.Header{"Etag": {`"v1"`}}, now, true},
+		{"none", http.Header{}, time.Time{}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := c.expiration(tc.header)
+			require.Equal(t, tc.wantOK, ok)
+			require.True(t, tc.want.Equal(got), "want %s, got %s", tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Package i18n implements the message catalogs used to localize the human
+// output of step: messages, prompts and warnings.
+//
+// A catalog is a JSON object that maps the English messages, as they appear
+// in the source code, to their translations. Format strings are translated
+// before formatting, so a translation must keep the same verbs in the same
+// order. The catalog for a language is read from <dir>/<language>.json, where
+// dir is, from lower to higher precedence:
+//
+//   - CatalogDir, the directory set at build time by a distribution.
+//   - $STEPPATH/locale.
+//   - The directory in the STEPLOCALEDIR environment variable.
+//
+// The language is taken from STEPLANG, LC_ALL, LC_MESSAGES or LANG. For a
+// language like es_ES the catalog es.json is read first, and then the
+// translations in es_ES.json overwrite it. Messages without a translation are
+// printed in English.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/smallstep/cli/config"
+)
+
+// LangEnv is the name of the environment variable that overwrites the
+// language of the messages.
+const LangEnv = "STEPLANG"
+
+// LocaleDirEnv is the name of the environment variable with an additional
+// directory with catalogs.
+const LocaleDirEnv = "STEPLOCALEDIR"
+
+// CatalogDir is the directory with the catalogs installed by a distribution.
+// It can be set at build time using:
+//   -ldflags "-X github.com/smallstep/cli/i18n.CatalogDir=/usr/share/step/locale"
+var CatalogDir = ""
+
+var (
+	mu         sync.RWMutex
+	once       sync.Once
+	messages   map[string]string
+	registered = make(map[string]map[string]string)
+)
+
+// Register adds a catalog for the given language. Registered catalogs have
+// the lowest precedence, they allow distributions to embed the catalogs in the
+// binary. It must be called before any message is translated, usually in an
+// init function.
+func Register(lang string, catalog map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	m, ok := registered[lang]
+	if !ok {
+		m = make(map[string]string)
+		registered[lang] = m
+	}
+	for k, v := range catalog {
+		m[k] = v
+	}
+}
+
+// T returns the translation of the given message, or the message itself if it
+// does not have one.
+func T(msg string) string {
+	once.Do(load)
+	mu.RLock()
+	defer mu.RUnlock()
+	if s, ok := messages[msg]; ok && s != "" {
+		return s
+	}
+	return msg
+}
+
+// Sprintf translates the given format and formats it with the given
+// arguments.
+func Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// Language returns the language of the messages, or an empty string if the
+// messages are not translated.
+func Language() string {
+	for _, env := range []string{LangEnv, "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			return normalize(v)
+		}
+	}
+	return ""
+}
+
+// normalize returns the language part of a locale like es_ES.UTF-8@euro.
+// The locales C and POSIX, and the English ones, return an empty string.
+func normalize(locale string) string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	locale = strings.Replace(locale, "-", "_", -1)
+	switch {
+	case locale == "C", locale == "POSIX":
+		return ""
+	case locale == "en", strings.HasPrefix(locale, "en_"):
+		return ""
+	default:
+		return locale
+	}
+}
+
+// candidates returns the names of the catalogs for the given language, from
+// the most generic to the most specific one.
+func candidates(lang string) []string {
+	if i := strings.IndexByte(lang, '_'); i > 0 {
+		return []string{lang[:i], lang}
+	}
+	return []string{lang}
+}
+
+// load loads the catalogs of the current language.
+func load() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	messages = make(map[string]string)
+	lang := Language()
+	if lang == "" || strings.ContainsAny(lang, `/\`) {
+		return
+	}
+
+	names := candidates(lang)
+	for _, name := range names {
+		for k, v := range registered[name] {
+			messages[k] = v
+		}
+	}
+
+	var dirs []string
+	if CatalogDir != "" {
+		dirs = append(dirs, CatalogDir)
+	}
+	dirs = append(dirs, filepath.Join(config.StepPath(), "locale"))
+	if dir := os.Getenv(LocaleDirEnv); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	for _, dir := range dirs {
+		for _, name := range names {
+			// Missing or invalid catalogs are ignored, the messages will
+			// be printed in English.
+			b, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+			if err != nil {
+				continue
+			}
+			catalog := make(map[string]string)
+			if err := json.Unmarshal(b, &catalog); err != nil {
+				continue
+			}
+			for k, v := range catalog {
+				messages[k] = v
+			}
+		}
+	}
+}
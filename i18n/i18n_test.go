@@ -0,0 +1,102 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// reset clears the loaded catalogs, they will be loaded again on the next
+// call to T.
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	once = sync.Once{}
+	messages = nil
+}
+
+func setenv(t *testing.T, key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	require.NoError(t, os.Setenv(key, value))
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"es_ES.UTF-8":      "es_ES",
+		"de_DE@euro":       "de_DE",
+		"pt-BR":            "pt_BR",
+		"fr":               "fr",
+		"C":                "",
+		"POSIX":            "",
+		"en_US.UTF-8":      "",
+		"en":               "",
+		"zh_Hant_TW.UTF-8": "zh_Hant_TW",
+	}
+	for locale, want := range tests {
+		require.Equal(t, want, normalize(locale), locale)
+	}
+}
+
+func TestT(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "es.json"), []byte(`{
+		"warning:": "aviso:",
+		"Your certificate has been saved in %s.\n": "Su certificado se ha guardado en %s.\n",
+		"Password": "Contraseña"
+	}`), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "es_AR.json"), []byte(`{
+		"Password": "Clave"
+	}`), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "fr.json"), []byte(`not json`), 0600))
+
+	Register("es", map[string]string{
+		"Downloading root certificate...": "Descargando el certificado raíz...",
+		"warning:":                        "advertencia:",
+	})
+
+	defer setenv(t, LocaleDirEnv, dir)()
+	defer reset()
+
+	tests := []struct {
+		lang string
+		msg  string
+		want string
+	}{
+		{"es_ES.UTF-8", "warning:", "aviso:"},
+		{"es_ES.UTF-8", "Password", "Contraseña"},
+		{"es_ES.UTF-8", "Downloading root certificate...", "Descargando el certificado raíz..."},
+		{"es_ES.UTF-8", "not translated", "not translated"},
+		{"es_AR", "Password", "Clave"},
+		{"es_AR", "warning:", "aviso:"},
+		{"fr_FR", "Password", "Password"},
+		{"en_US", "Password", "Password"},
+		{"C", "warning:", "warning:"},
+		{"../../etc/passwd", "Password", "Password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.msg, func(t *testing.T) {
+			defer setenv(t, LangEnv, tt.lang)()
+			reset()
+			require.Equal(t, tt.want, T(tt.msg))
+		})
+	}
+
+	defer setenv(t, LangEnv, "es")()
+	reset()
+	require.Equal(t, "Su certificado se ha guardado en foo.crt.\n", Sprintf("Your certificate has been saved in %s.\n", "foo.crt"))
+	require.Equal(t, "Not translated foo.crt", Sprintf("Not translated %s", "foo.crt"))
+}
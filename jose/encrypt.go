@@ -22,11 +22,25 @@ func Thumbprint(jwk *JSONWebKey) (string, error) {
 }
 
 // EncryptJWK returns the given JWK encrypted with the default encryption
-// algorithm (PBES2-HS256+A128KW).
-func EncryptJWK(jwk *JSONWebKey) (*JSONWebEncryption, error) {
-	key, err := ui.PromptPassword("Please enter the password to encrypt the private JWK")
+// algorithm (PBES2-HS256+A128KW). The password and the number of PBKDF2
+// iterations can be set using the WithPassword and WithPBES2Count options, if
+// no password is given the user will be prompted for one.
+func EncryptJWK(jwk *JSONWebKey, opts ...Option) (*JSONWebEncryption, error) {
+	ctx, err := new(context).apply(opts...)
 	if err != nil {
-		return nil, errors.Wrap(err, "error reading password")
+		return nil, err
+	}
+	defer ctx.destroy()
+
+	key := ctx.password
+	if len(key) == 0 {
+		if key, err = ui.PromptPassword("Please enter the password to encrypt the private JWK"); err != nil {
+			return nil, errors.Wrap(err, "error reading password")
+		}
+	}
+	count := ctx.pbes2Count
+	if count == 0 {
+		count = PBKDF2Iterations
 	}
 
 	salt, err := randutil.Salt(PBKDF2SaltSize)
@@ -43,14 +57,14 @@ func EncryptJWK(jwk *JSONWebKey) (*JSONWebEncryption, error) {
 	recipient := Recipient{
 		Algorithm:  PBES2_HS256_A128KW,
 		Key:        key,
-		PBES2Count: PBKDF2Iterations,
+		PBES2Count: count,
 		PBES2Salt:  salt,
 	}
 
-	opts := new(EncrypterOptions)
-	opts.WithContentType(ContentType("jwk+json"))
+	eo := new(EncrypterOptions)
+	eo.WithContentType(ContentType("jwk+json"))
 
-	encrypter, err := NewEncrypter(DefaultEncAlgorithm, recipient, opts)
+	encrypter, err := NewEncrypter(DefaultEncAlgorithm, recipient, eo)
 	if err != nil {
 		return nil, errs.Wrap(err, "error creating cipher")
 	}
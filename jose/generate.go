@@ -11,9 +11,12 @@ import (
 	"encoding/json"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/fips"
 	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/policy"
 	"github.com/smallstep/cli/crypto/randutil"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/timing"
 	"golang.org/x/crypto/ed25519"
 )
 
@@ -92,6 +95,16 @@ func GenerateDefaultKeyPair(pass []byte) (*JSONWebKey, *JSONWebEncryption, error
 // GenerateJWK generates a JWK given the key type, curve, alg, use, kid and
 // the size of the RSA or oct keys if necessary.
 func GenerateJWK(kty, crv, alg, use, kid string, size int) (jwk *JSONWebKey, err error) {
+	if err := fips.CheckKeyType(kty, crv, size); err != nil {
+		return nil, err
+	}
+	if err := policy.CheckKeyType(kty, crv, size); err != nil {
+		return nil, err
+	}
+	if err := policy.CheckAlgorithm(alg); err != nil {
+		return nil, err
+	}
+	defer timing.Start(timing.Crypto, "generate "+kty+" JWK")()
 	switch kty {
 	case "EC":
 		return generateECKey(crv, alg, use, kid)
@@ -0,0 +1,172 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"math/big"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pqc"
+	"github.com/smallstep/cli/kms"
+	"golang.org/x/crypto/ed25519"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// OpaqueSigner is an interface that supports signing payloads with opaque
+// private keys, like keys stored in a KMS.
+type OpaqueSigner = jose.OpaqueSigner
+
+// opaqueSigner implements the OpaqueSigner interface using a crypto.Signer.
+type opaqueSigner struct {
+	signer crypto.Signer
+}
+
+// NewOpaqueSigner returns an OpaqueSigner that signs using the given
+// crypto.Signer.
+func NewOpaqueSigner(signer crypto.Signer) OpaqueSigner {
+	return &opaqueSigner{signer: signer}
+}
+
+// isOpaqueKey returns true if the given key is a crypto.Signer not natively
+// supported by go-jose.
+func isOpaqueKey(key interface{}) bool {
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return false
+	case crypto.Signer:
+		return true
+	default:
+		return false
+	}
+}
+
+// Public returns the public key of the current signing key.
+func (o *opaqueSigner) Public() *JSONWebKey {
+	return &JSONWebKey{Key: o.signer.Public()}
+}
+
+// Algs returns a list of supported signing algorithms.
+func (o *opaqueSigner) Algs() []SignatureAlgorithm {
+	switch k := o.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		return []SignatureAlgorithm{SignatureAlgorithm(getECAlgorithm(k.Curve))}
+	case *rsa.PublicKey:
+		return []SignatureAlgorithm{RS256, RS384, RS512, PS256, PS384, PS512}
+	case ed25519.PublicKey:
+		return []SignatureAlgorithm{EdDSA}
+	case *pqc.PublicKey:
+		if pqc.IsSignatureAlgorithm(k.Algorithm()) {
+			return []SignatureAlgorithm{SignatureAlgorithm(k.Algorithm())}
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
+// SignPayload signs a payload with the current signing key using the given
+// algorithm.
+func (o *opaqueSigner) SignPayload(payload []byte, alg SignatureAlgorithm) ([]byte, error) {
+	var opts crypto.SignerOpts
+	switch alg {
+	case EdDSA, MLDSA44, MLDSA65, MLDSA87:
+		return o.signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	case ES256, RS256:
+		opts = crypto.SHA256
+	case ES384, RS384:
+		opts = crypto.SHA384
+	case ES512, RS512:
+		opts = crypto.SHA512
+	case PS256:
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	case PS384:
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}
+	case PS512:
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}
+	default:
+		return nil, errors.Errorf("unsupported algorithm %s", alg)
+	}
+
+	var sig []byte
+	var err error
+	if ms, ok := o.signer.(kms.MessageSigner); ok {
+		sig, err = ms.SignMessage(rand.Reader, payload, opts)
+	} else {
+		h := opts.HashFunc().New()
+		h.Write(payload)
+		sig, err = o.signer.Sign(rand.Reader, h.Sum(nil), opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// JWS uses the concatenation of r and s for ECDSA signatures.
+	if k, ok := o.signer.Public().(*ecdsa.PublicKey); ok {
+		return convertECDSASignature(k, sig)
+	}
+	return sig, nil
+}
+
+// OpaqueVerifier is an interface that supports verifying payloads with
+// public keys not natively supported by go-jose.
+type OpaqueVerifier = jose.OpaqueVerifier
+
+// opaqueVerifier implements the OpaqueVerifier interface for the
+// experimental ML-DSA keys.
+type opaqueVerifier struct {
+	pub *pqc.PublicKey
+}
+
+// NewOpaqueVerifier returns an OpaqueVerifier for the given key if the key is
+// not natively supported by go-jose. Only ML-DSA keys are supported.
+func NewOpaqueVerifier(key interface{}) (OpaqueVerifier, bool) {
+	var pub *pqc.PublicKey
+	switch k := key.(type) {
+	case *pqc.PrivateKey:
+		pub = k.Public().(*pqc.PublicKey)
+	case *pqc.PublicKey:
+		pub = k
+	default:
+		return nil, false
+	}
+	if !pqc.IsSignatureAlgorithm(pub.Algorithm()) {
+		return nil, false
+	}
+	return &opaqueVerifier{pub: pub}, true
+}
+
+// VerifyPayload verifies the signature of the payload using the given
+// algorithm.
+func (o *opaqueVerifier) VerifyPayload(payload []byte, signature []byte, alg SignatureAlgorithm) error {
+	if string(alg) != o.pub.Algorithm() {
+		return errors.Errorf("unsupported algorithm %s", alg)
+	}
+	if !pqc.Verify(o.pub, payload, signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// convertECDSASignature converts an ASN.1 ECDSA signature to the format used in
+// JWS.
+func convertECDSASignature(pub *ecdsa.PublicKey, sig []byte) ([]byte, error) {
+	var esig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(sig, &esig); err != nil {
+		return nil, errors.Wrap(err, "error parsing ECDSA signature")
+	}
+
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	rBytes, sBytes := esig.R.Bytes(), esig.S.Bytes()
+	if len(rBytes) > size || len(sBytes) > size {
+		return nil, errors.New("invalid ECDSA signature")
+	}
+	out := make([]byte, 2*size)
+	copy(out[size-len(rBytes):size], rBytes)
+	copy(out[2*size-len(sBytes):], sBytes)
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// testSigner hides the type of a key so it's used as an opaque key.
+type testSigner struct {
+	crypto.Signer
+}
+
+func TestOpaqueSigner(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+		alg  string
+	}{
+		{"ES384", ecKey, ES384},
+		{"RS256", rsaKey, RS256},
+		{"PS512", rsaKey, PS512},
+		{"EdDSA", edKey, EdDSA},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			key := testSigner{tc.key}
+			require.True(t, isOpaqueKey(key))
+
+			signer, err := NewSigner(SigningKey{
+				Algorithm: SignatureAlgorithm(tc.alg),
+				Key:       key,
+			}, nil)
+			require.NoError(t, err)
+
+			raw, err := Signed(signer).Claims(Claims{Subject: "foo"}).CompactSerialize()
+			require.NoError(t, err)
+
+			tok, err := ParseSigned(raw)
+			require.NoError(t, err)
+			var claims Claims
+			require.NoError(t, tok.Claims(tc.key.Public(), &claims))
+			require.Equal(t, "foo", claims.Subject)
+		})
+	}
+}
+
+func TestValidateOpaqueJWK(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	require.NoError(t, ValidateJWK(&JSONWebKey{Key: testSigner{ecKey}, Algorithm: ES256, Use: "sig"}))
+	require.Error(t, ValidateJWK(&JSONWebKey{Key: testSigner{ecKey}, Algorithm: ES384, Use: "sig"}))
+}
@@ -1,6 +1,8 @@
 package jose
 
 import (
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/securebuf"
 	"github.com/smallstep/cli/ui"
 	"github.com/smallstep/cli/utils"
 )
@@ -9,7 +11,11 @@ type context struct {
 	use, alg, kid    string
 	subtle, insecure bool
 	noDefaults       bool
+	noCache          bool
+	stepAgent        bool
 	password         []byte
+	passwordBuffer   *securebuf.Buffer
+	pbes2Count       int
 	uiOptions        []ui.Option
 }
 
@@ -24,6 +30,15 @@ func (ctx *context) apply(opts ...Option) (*context, error) {
 	return ctx, nil
 }
 
+// destroy zeroes the password read with WithPasswordFile. The context must not
+// be used after calling it.
+func (ctx *context) destroy() {
+	if ctx.passwordBuffer != nil {
+		ctx.passwordBuffer.Destroy()
+		ctx.password, ctx.passwordBuffer = nil, nil
+	}
+}
+
 // Option is the type used to add attributes to the context.
 type Option func(ctx *context) error
 
@@ -76,6 +91,23 @@ func WithNoDefaults(val bool) Option {
 	}
 }
 
+// WithNoCache disables the cache used to read remote JWK Sets.
+func WithNoCache(val bool) Option {
+	return func(ctx *context) error {
+		ctx.noCache = val
+		return nil
+	}
+}
+
+// WithStepAgent enables the use of the keys loaded in the step agent. If the
+// agent is running and has the key, the key is not read nor decrypted.
+func WithStepAgent(val bool) Option {
+	return func(ctx *context) error {
+		ctx.stepAgent = val
+		return nil
+	}
+}
+
 // WithPassword is a method that adds the given password to the context.
 func WithPassword(pass []byte) Option {
 	return func(ctx *context) error {
@@ -87,11 +119,24 @@ func WithPassword(pass []byte) Option {
 // WithPasswordFile is a method that adds the password in a file to the context.
 func WithPasswordFile(filename string) Option {
 	return func(ctx *context) error {
-		b, err := utils.ReadPasswordFromFile(filename)
+		buf, err := utils.ReadPasswordBufferFromFile(filename)
 		if err != nil {
 			return err
 		}
-		ctx.password = b
+		ctx.passwordBuffer.Destroy()
+		ctx.password, ctx.passwordBuffer = buf.Bytes(), buf
+		return nil
+	}
+}
+
+// WithPBES2Count sets the number of PBKDF2 iterations used to encrypt a JWK
+// with EncryptJWK.
+func WithPBES2Count(n int) Option {
+	return func(ctx *context) error {
+		if n < 0 {
+			return errors.Errorf("invalid PBES2 count %d", n)
+		}
+		ctx.pbes2Count = n
 		return nil
 	}
 }
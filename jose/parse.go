@@ -2,17 +2,24 @@ package jose
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rsa"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"path/filepath"
 	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/crypto/pqc"
+	"github.com/smallstep/cli/crypto/securebuf"
+	"github.com/smallstep/cli/fileurl"
+	"github.com/smallstep/cli/httpcache"
+	"github.com/smallstep/cli/kms"
+	"github.com/smallstep/cli/kms/stepagent"
+	"github.com/smallstep/cli/timing"
 	"github.com/smallstep/cli/ui"
 	"golang.org/x/crypto/ed25519"
 	jose "gopkg.in/square/go-jose.v2"
@@ -37,25 +44,26 @@ func Decrypt(prompt string, data []byte, opts ...Option) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer ctx.destroy()
 
 	enc, err := jose.ParseEncrypted(string(data))
 	if err != nil {
 		return data, nil
 	}
 
-	// Decrypt flow
-	var pass []byte
+	// Decrypt flow, prompted passwords are zeroed after each attempt
 	for i := 0; i < MaxDecryptTries; i++ {
 		if len(ctx.password) == 0 {
-			pass, err = ui.PromptPassword(prompt, ctx.uiOptions...)
-			if err != nil {
+			var pass *securebuf.Buffer
+			if pass, err = ui.PromptPasswordBuffer(prompt, ctx.uiOptions...); err != nil {
 				return nil, err
 			}
+			data, err = decryptJWE(enc, pass.Bytes())
+			pass.Destroy()
 		} else {
-			pass = ctx.password
+			data, err = decryptJWE(enc, ctx.password)
 		}
-
-		if data, err = enc.Decrypt(pass); err == nil {
+		if err == nil {
 			return data, nil
 		}
 	}
@@ -63,20 +71,83 @@ func Decrypt(prompt string, data []byte, opts ...Option) ([]byte, error) {
 	return nil, errors.New("failed to decrypt JWK: invalid password")
 }
 
+// decryptJWE decrypts the given JWE with a password.
+func decryptJWE(enc *jose.JSONWebEncryption, password []byte) ([]byte, error) {
+	defer timing.Start(timing.Crypto, "decrypt JWE")()
+	return enc.Decrypt(password)
+}
+
 // ParseKey returns a JSONWebKey from the given JWK file or a PEM file. For
-// password protected keys, it will ask the user for a password.
+// password protected keys, it will ask the user for a password. The filename
+// can also be a key URI, e.g. env:STEP_KEY or awskms:alias/my-key.
 // func ParseKey(filename, use, alg, kid string, subtle bool) (*JSONWebKey, error) {
 func ParseKey(filename string, opts ...Option) (*JSONWebKey, error) {
 	ctx, err := new(context).apply(opts...)
 	if err != nil {
 		return nil, err
 	}
+	defer ctx.destroy()
+
+	jwk := new(JSONWebKey)
+	if kms.IsKMS(filename) {
+		// Keys like files or environment variables are parsed as a regular
+		// file, the rest are only available through a signer.
+		b, err := kms.ReadKey(filename)
+		if err != nil {
+			return nil, err
+		}
+		if b != nil {
+			return parseKeyBytes(ctx, filename, b, opts)
+		}
+
+		signer, err := kms.NewSigner(filename)
+		if err != nil {
+			return nil, err
+		}
+		// Use the same kid as step crypto jwk create --kms
+		if ctx.kid == "" {
+			if jwk.KeyID, err = Thumbprint(&JSONWebKey{Key: signer.Public()}); err != nil {
+				return nil, err
+			}
+		}
+		jwk.Key = signer
+		return completeJWK(ctx, filename, jwk)
+	}
 
-	b, err := ioutil.ReadFile(filename)
+	if ctx.stepAgent {
+		if name, err := filepath.Abs(filename); err == nil {
+			if jwk := StepAgentKey(name); jwk != nil {
+				return completeJWK(ctx, filename, jwk)
+			}
+		}
+	}
+
+	b, err := fileurl.ReadFile(filename)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error reading %s", filename)
 	}
+	return parseKeyBytes(ctx, filename, b, opts)
+}
 
+// StepAgentKey returns the key with the given name or key id in the step
+// agent. It returns nil if the agent is not running or doesn't have the key.
+func StepAgentKey(name string) *JSONWebKey {
+	signer := stepagent.Lookup(name)
+	if signer == nil {
+		return nil
+	}
+	k := signer.Key()
+	return &JSONWebKey{
+		Key:       signer,
+		KeyID:     k.KeyID,
+		Algorithm: k.Algorithm,
+		Use:       k.Use,
+	}
+}
+
+// parseKeyBytes parses the JWK, PEM or symmetric key read from filename.
+func parseKeyBytes(ctx *context, filename string, b []byte, opts []Option) (*JSONWebKey, error) {
+	var err error
 	jwk := new(JSONWebKey)
 	switch guessKeyType(ctx, b) {
 	case jwkKeyType:
@@ -86,12 +157,15 @@ func ParseKey(filename string, opts ...Option) (*JSONWebKey, error) {
 			return nil, err
 		}
 
-		// Unmarshal the plain (or decrypted JWK)
-		if err := json.Unmarshal(b, jwk); err != nil {
+		// Unmarshal the plain (or decrypted JWK) and zero it
+		err = json.Unmarshal(b, jwk)
+		securebuf.Zero(b)
+		if err != nil {
 			return nil, errors.Errorf("error reading %s: unsupported format", filename)
 		}
 	case pemKeyType:
 		jwk.Key, err = pemutil.ParseKey(b, pemutil.WithFilename(filename), pemutil.WithPassword(ctx.password))
+		securebuf.Zero(b)
 		if err != nil {
 			return nil, err
 		}
@@ -99,6 +173,12 @@ func ParseKey(filename string, opts ...Option) (*JSONWebKey, error) {
 		jwk.Key = b
 	}
 
+	return completeJWK(ctx, filename, jwk)
+}
+
+// completeJWK validates the key id and algorithm of a parsed key and sets the
+// missing attributes using the context.
+func completeJWK(ctx *context, filename string, jwk *JSONWebKey) (*JSONWebKey, error) {
 	// Validate key id
 	if ctx.kid != "" && jwk.KeyID != "" && ctx.kid != jwk.KeyID {
 		return nil, errors.Errorf("kid %s does not match the kid on %s", ctx.kid, filename)
@@ -124,21 +204,19 @@ func ParseKey(filename string, opts ...Option) (*JSONWebKey, error) {
 	return jwk, nil
 }
 
-// ReadJWKSet reads a JWK Set from a URL or filename. URLs must start with "https://".
-func ReadJWKSet(filename string) ([]byte, error) {
+// ReadJWKSet reads a JWK Set from a URL or filename. Remote JWK Sets in
+// "https://" URLs are cached in $STEPPATH/cache unless the WithNoCache option
+// is used, other URLs supported by the fileurl package are not cached.
+func ReadJWKSet(filename string, opts ...Option) ([]byte, error) {
 	if strings.HasPrefix(filename, "https://") {
-		resp, err := http.Get(filename)
-		if err != nil {
-			return nil, errors.Wrapf(err, "error retrieving %s", filename)
-		}
-		defer resp.Body.Close()
-		b, err := ioutil.ReadAll(resp.Body)
+		ctx, err := new(context).apply(opts...)
 		if err != nil {
-			return nil, errors.Wrapf(err, "error retrieving %s", filename)
+			return nil, err
 		}
-		return b, nil
+		defer ctx.destroy()
+		return httpcache.New(ctx.noCache).Get(filename)
 	}
-	b, err := ioutil.ReadFile(filename)
+	b, err := fileurl.ReadFile(filename)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error reading %s", filename)
 	}
@@ -153,8 +231,9 @@ func ParseKeySet(filename string, opts ...Option) (*jose.JSONWebKey, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer ctx.destroy()
 
-	b, err := ReadJWKSet(filename)
+	b, err := ReadJWKSet(filename, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -264,6 +343,16 @@ func guessJWKAlgorithm(ctx *context, jwk *jose.JSONWebKey) {
 		// Ed25519 can only be used for signing operations
 		case ed25519.PrivateKey, ed25519.PublicKey:
 			jwk.Algorithm = EdDSA
+		// ML-DSA keys can only be used with its parameter set
+		case *pqc.PublicKey:
+			if pqc.IsSignatureAlgorithm(k.Algorithm()) {
+				jwk.Algorithm = k.Algorithm()
+			}
+		// Opaque keys use the algorithm of the public key
+		case crypto.Signer:
+			pub := &JSONWebKey{Key: k.Public(), Use: jwk.Use}
+			guessJWKAlgorithm(ctx, pub)
+			jwk.Algorithm = pub.Algorithm
 		}
 	}
 }
@@ -279,6 +368,14 @@ func guessKnownJWKAlgorithm(ctx *context, jwk *jose.JSONWebKey) {
 			jwk.Algorithm = getECAlgorithm(k.Curve)
 		case ed25519.PrivateKey, ed25519.PublicKey:
 			jwk.Algorithm = EdDSA
+		case *pqc.PrivateKey:
+			if pqc.IsSignatureAlgorithm(k.Algorithm()) {
+				jwk.Algorithm = k.Algorithm()
+			}
+		case *pqc.PublicKey:
+			if pqc.IsSignatureAlgorithm(k.Algorithm()) {
+				jwk.Algorithm = k.Algorithm()
+			}
 		}
 	}
 }
@@ -0,0 +1,182 @@
+package jose
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/randutil"
+)
+
+// Selective disclosure JWT (SD-JWT) constants, see
+// https://datatracker.ietf.org/doc/draft-ietf-oauth-selective-disclosure-jwt/
+const (
+	sdClaim     = "_sd"
+	sdAlgClaim  = "_sd_alg"
+	sdAlg       = "sha-256"
+	SDSeparator = "~"
+	sdSaltSize  = 16
+)
+
+// sdReservedClaims are the claims that cannot be selectively disclosed, the
+// ones used by SD-JWT and the ones required to validate the token.
+var sdReservedClaims = map[string]bool{
+	sdClaim:    true,
+	sdAlgClaim: true,
+	"...":      true,
+	"iss":      true,
+	"aud":      true,
+	"exp":      true,
+	"nbf":      true,
+	"iat":      true,
+	"cnf":      true,
+}
+
+// Disclosure is an SD-JWT disclosure, the base64url encoding of the JSON
+// array [salt, name, value].
+type Disclosure struct {
+	Name    string
+	Value   interface{}
+	Encoded string
+}
+
+// newDisclosure creates a disclosure for the given claim with a random salt.
+func newDisclosure(name string, value interface{}) (*Disclosure, error) {
+	salt, err := randutil.Salt(sdSaltSize)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal([]interface{}{
+		base64.RawURLEncoding.EncodeToString(salt), name, value,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling disclosure")
+	}
+	return &Disclosure{
+		Name:    name,
+		Value:   value,
+		Encoded: base64.RawURLEncoding.EncodeToString(b),
+	}, nil
+}
+
+// parseDisclosure decodes an encoded disclosure.
+func parseDisclosure(s string) (*Disclosure, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding disclosure")
+	}
+	var v []interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, errors.Wrap(err, "error decoding disclosure")
+	}
+	if len(v) != 3 {
+		return nil, errors.New("error decoding disclosure: disclosure must have three elements")
+	}
+	if _, ok := v[0].(string); !ok {
+		return nil, errors.New("error decoding disclosure: salt must be a string")
+	}
+	name, ok := v[1].(string)
+	if !ok {
+		return nil, errors.New("error decoding disclosure: claim name must be a string")
+	}
+	if sdReservedClaims[name] {
+		return nil, errors.Errorf("error decoding disclosure: claim %s cannot be disclosed", name)
+	}
+	return &Disclosure{
+		Name:    name,
+		Value:   v[2],
+		Encoded: s,
+	}, nil
+}
+
+// Digest returns the base64url encoded SHA-256 digest of the disclosure.
+func (d *Disclosure) Digest() string {
+	sum := sha256.Sum256([]byte(d.Encoded))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// MakeDisclosures removes the given claims from the claim set, and replaces
+// them with the digests of their disclosures in the "_sd" claim.
+func MakeDisclosures(claims map[string]interface{}, names []string) ([]*Disclosure, error) {
+	var disclosures []*Disclosure
+	var digests []string
+	for _, name := range names {
+		if sdReservedClaims[name] {
+			return nil, errors.Errorf("claim %s cannot be selectively disclosed", name)
+		}
+		value, ok := claims[name]
+		if !ok {
+			return nil, errors.Errorf("claim %s is not in the payload", name)
+		}
+		d, err := newDisclosure(name, value)
+		if err != nil {
+			return nil, err
+		}
+		delete(claims, name)
+		disclosures = append(disclosures, d)
+		digests = append(digests, d.Digest())
+	}
+	// Digests are sorted so their order does not reveal the original order of
+	// the claims.
+	sort.Strings(digests)
+	claims[sdClaim] = digests
+	claims[sdAlgClaim] = sdAlg
+	return disclosures, nil
+}
+
+// SplitSDJWT splits an SD-JWT into the issuer-signed JWT and the encoded
+// disclosures. Key binding JWTs are not supported.
+func SplitSDJWT(token string) (string, []string, error) {
+	parts := strings.Split(token, SDSeparator)
+	if len(parts) < 2 {
+		return "", nil, errors.New("error parsing token: SD-JWT must end with '~'")
+	}
+	if parts[len(parts)-1] != "" {
+		return "", nil, errors.New("error parsing token: key binding JWTs are not supported")
+	}
+	return parts[0], parts[1 : len(parts)-1], nil
+}
+
+// ApplyDisclosures validates the disclosures against the digests in the
+// claim set and returns the claim set with the disclosed claims. Only the
+// disclosures of top-level claims are supported.
+func ApplyDisclosures(claims map[string]interface{}, encoded []string) (map[string]interface{}, error) {
+	if alg, ok := claims[sdAlgClaim]; ok && alg != sdAlg {
+		return nil, errors.Errorf("unsupported SD-JWT algorithm %v", alg)
+	}
+	digests := make(map[string]bool)
+	if v, ok := claims[sdClaim].([]interface{}); ok {
+		for _, d := range v {
+			if s, ok := d.(string); ok {
+				digests[s] = true
+			}
+		}
+	}
+
+	disclosed := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		if k != sdClaim && k != sdAlgClaim {
+			disclosed[k] = v
+		}
+	}
+	for _, s := range encoded {
+		d, err := parseDisclosure(s)
+		if err != nil {
+			return nil, err
+		}
+		digest := d.Digest()
+		if !digests[digest] {
+			return nil, errors.Errorf("disclosure for claim %s does not match any digest in the token", d.Name)
+		}
+		// Each digest can only be used once.
+		delete(digests, digest)
+		if _, ok := disclosed[d.Name]; ok {
+			return nil, errors.Errorf("disclosure for claim %s overwrites an existing claim", d.Name)
+		}
+		disclosed[d.Name] = d.Value
+	}
+	return disclosed, nil
+}
@@ -0,0 +1,69 @@
+package jose
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/smallstep/assert"
+)
+
+func TestSDJWTDisclosures(t *testing.T) {
+	claims := map[string]interface{}{
+		"iss":   "joe@example.com",
+		"email": "joe@example.com",
+		"name":  "Joe",
+		"age":   42.0,
+	}
+	disclosures, err := MakeDisclosures(claims, []string{"email", "age"})
+	assert.FatalError(t, err)
+	assert.Len(t, 2, disclosures)
+	assert.Equals(t, sdAlg, claims[sdAlgClaim])
+	_, ok := claims["email"]
+	assert.False(t, ok)
+
+	// Simulate the payload after the JWT verification
+	b, err := json.Marshal(claims)
+	assert.FatalError(t, err)
+	var payload map[string]interface{}
+	assert.FatalError(t, json.Unmarshal(b, &payload))
+
+	jwt, encoded, err := SplitSDJWT("a.b.c~" + disclosures[0].Encoded + "~" + disclosures[1].Encoded + "~")
+	assert.FatalError(t, err)
+	assert.Equals(t, "a.b.c", jwt)
+
+	disclosed, err := ApplyDisclosures(payload, encoded)
+	assert.FatalError(t, err)
+	assert.Equals(t, map[string]interface{}{
+		"iss":   "joe@example.com",
+		"email": "joe@example.com",
+		"name":  "Joe",
+		"age":   42.0,
+	}, disclosed)
+
+	// Only the email
+	disclosed, err = ApplyDisclosures(payload, encoded[:1])
+	assert.FatalError(t, err)
+	assert.Equals(t, map[string]interface{}{
+		"iss":   "joe@example.com",
+		"email": "joe@example.com",
+		"name":  "Joe",
+	}, disclosed)
+
+	// Repeated and unknown disclosures
+	_, err = ApplyDisclosures(payload, []string{encoded[0], encoded[0]})
+	assert.Error(t, err)
+	d, err := newDisclosure("email", "mike@example.com")
+	assert.FatalError(t, err)
+	_, err = ApplyDisclosures(payload, []string{d.Encoded})
+	assert.Error(t, err)
+
+	// Reserved and missing claims
+	_, err = MakeDisclosures(map[string]interface{}{"iss": "joe"}, []string{"iss"})
+	assert.Error(t, err)
+	_, err = MakeDisclosures(map[string]interface{}{}, []string{"email"})
+	assert.Error(t, err)
+
+	// Key binding JWTs
+	_, _, err = SplitSDJWT("a.b.c~" + encoded[0] + "~d.e.f")
+	assert.Error(t, err)
+}
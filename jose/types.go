@@ -3,10 +3,14 @@
 package jose
 
 import (
+	"crypto"
 	"errors"
 	"strings"
 	"time"
 
+	"github.com/smallstep/cli/crypto/fips"
+	"github.com/smallstep/cli/crypto/policy"
+	"github.com/smallstep/cli/crypto/pqc"
 	jose "gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
 )
@@ -153,6 +157,10 @@ const (
 	PS384 = "PS384" // RSASSA-PSS using SHA384 and MGF1-SHA384
 	PS512 = "PS512" // RSASSA-PSS using SHA512 and MGF1-SHA512
 	EdDSA = "EdDSA" // Ed25591
+	// Experimental post-quantum algorithms
+	MLDSA44 = "ML-DSA-44" // ML-DSA-44 (FIPS 204)
+	MLDSA65 = "ML-DSA-65" // ML-DSA-65 (FIPS 204)
+	MLDSA87 = "ML-DSA-87" // ML-DSA-87 (FIPS 204)
 )
 
 // Content encryption algorithms
@@ -178,6 +186,7 @@ const (
 	RSA = "RSA" // RSA
 	OKP = "OKP" // Ed25519
 	OCT = "oct" // Octet sequence
+	AKP = "AKP" // Algorithm key pair (ML-DSA and ML-KEM)
 )
 
 // Ed25519 is the EdDSA signature scheme using SHA-512/256 and Curve25519
@@ -209,6 +218,18 @@ func ParseEncrypted(input string) (*JSONWebEncryption, error) {
 
 // NewEncrypter creates an appropriate encrypter based on the key type.
 func NewEncrypter(enc ContentEncryption, rcpt Recipient, opts *EncrypterOptions) (Encrypter, error) {
+	if err := fips.CheckEncryptionAlgorithm(string(enc)); err != nil {
+		return nil, err
+	}
+	if err := fips.CheckEncryptionAlgorithm(string(rcpt.Algorithm)); err != nil {
+		return nil, err
+	}
+	if err := policy.CheckAlgorithm(string(enc)); err != nil {
+		return nil, err
+	}
+	if err := policy.CheckAlgorithm(string(rcpt.Algorithm)); err != nil {
+		return nil, err
+	}
 	return jose.NewEncrypter(enc, rcpt, opts)
 }
 
@@ -229,6 +250,23 @@ func UnixNumericDate(s int64) *NumericDate {
 
 // NewSigner creates an appropriate signer based on the key type
 func NewSigner(sig SigningKey, opts *SignerOptions) (Signer, error) {
+	if err := fips.CheckSignatureAlgorithm(string(sig.Algorithm)); err != nil {
+		return nil, err
+	}
+	if err := policy.CheckAlgorithm(string(sig.Algorithm)); err != nil {
+		return nil, err
+	}
+	if k, ok := sig.Key.(crypto.Signer); ok {
+		if err := fips.CheckKey(k); err != nil {
+			return nil, err
+		}
+		if err := policy.CheckKey(k); err != nil {
+			return nil, err
+		}
+		if isOpaqueKey(k) {
+			sig.Key = NewOpaqueSigner(k)
+		}
+	}
 	return jose.NewSigner(sig, opts)
 }
 
@@ -247,6 +285,17 @@ func ParseJWS(s string) (*JSONWebSignature, error) {
 	return jose.ParseSigned(s)
 }
 
+// IsExperimental returns true if the key of the JSONWebKey uses one of the
+// experimental post-quantum algorithms.
+func IsExperimental(k *JSONWebKey) bool {
+	switch k.Key.(type) {
+	case *pqc.PrivateKey, *pqc.PublicKey:
+		return true
+	default:
+		return false
+	}
+}
+
 // Determine whether a JSONWebKey is symmetric
 func IsSymmetric(k *JSONWebKey) bool {
 	switch k.Key.(type) {
@@ -1,11 +1,13 @@
 package jose
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"fmt"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pqc"
 	"golang.org/x/crypto/ed25519"
 )
 
@@ -70,6 +72,18 @@ func validateSigJWK(jwk *JSONWebKey) error {
 			return nil
 		}
 		errctx = "kty 'OKP' and crv 'Ed25519'"
+	case *pqc.PublicKey:
+		if pqc.IsSignatureAlgorithm(k.Algorithm()) && jwk.Algorithm == k.Algorithm() {
+			return nil
+		}
+		errctx = fmt.Sprintf("kty 'AKP' and parameter set '%s'", k.Algorithm())
+	case crypto.Signer:
+		// Opaque keys are validated using the public key
+		return validateSigJWK(&JSONWebKey{
+			Key:       k.Public(),
+			Algorithm: jwk.Algorithm,
+			Use:       jwk.Use,
+		})
 	}
 
 	return errors.Errorf("alg '%s' is not compatible with %s", jwk.Algorithm, errctx)
@@ -117,6 +131,8 @@ func validateGeneric(jwk *JSONWebKey) error {
 		return nil
 	case ed25519.PrivateKey, ed25519.PublicKey:
 		return nil
+	case crypto.Signer:
+		return nil
 	}
 
 	return errors.Errorf("unsupported key type '%T'", jwk.Key)
@@ -0,0 +1,153 @@
+// Package awskms implements a crypto.Signer using keys stored in AWS Key
+// Management Service (KMS).
+package awskms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms/uri"
+)
+
+// Scheme is the scheme of the URIs of AWS KMS keys, e.g.
+// awskms:alias/my-key or awskms:arn:aws:kms:us-east-1:123456789012:key/<id>.
+const Scheme = "awskms"
+
+// Signer implements crypto.Signer using an asymmetric key in AWS KMS.
+type Signer struct {
+	client    kmsiface.KMSAPI
+	keyID     string
+	publicKey crypto.PublicKey
+}
+
+// NewSigner creates a new Signer for the key referenced by the given URI. The
+// region and credentials are loaded using the standard AWS chain: environment
+// variables, shared configuration and credentials files, and instance roles.
+// The URI options region and profile can be used to overwrite them, e.g.
+// awskms:alias/my-key?region=us-west-2&profile=prod.
+func NewSigner(rawuri string) (*Signer, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != Scheme || u.Name == "" {
+		return nil, errors.Errorf("invalid AWS KMS key %s", rawuri)
+	}
+
+	opts := session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Profile:           u.Get("profile"),
+	}
+	if region := u.Get("region"); region != "" {
+		opts.Config.Region = aws.String(region)
+	} else if region := arnRegion(u.Name); region != "" {
+		opts.Config.Region = aws.String(region)
+	}
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating AWS session")
+	}
+	return newSigner(kms.New(sess), u.Name)
+}
+
+func newSigner(client kmsiface.KMSAPI, keyID string) (*Signer, error) {
+	resp, err := client.GetPublicKey(&kms.GetPublicKeyInput{
+		KeyId: aws.String(keyID),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting public key of %s", keyID)
+	}
+	if aws.StringValue(resp.KeyUsage) != kms.KeyUsageTypeSignVerify {
+		return nil, errors.Errorf("key %s cannot be used for signing", keyID)
+	}
+	pub, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing public key of %s", keyID)
+	}
+	return &Signer{
+		client:    client,
+		keyID:     keyID,
+		publicKey: pub,
+	}, nil
+}
+
+// arnRegion returns the region of a key ARN, or an empty string if the key id
+// is not an ARN.
+func arnRegion(keyID string) string {
+	parts := strings.Split(keyID, ":")
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[3]
+}
+
+// Public returns the public key of the signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs the given digest using the KMS key. The signing algorithm is
+// selected using the type of the key and the hash function in opts.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	alg, err := signingAlgorithm(s.publicKey, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Sign(&kms.SignInput{
+		KeyId:            aws.String(s.keyID),
+		Message:          digest,
+		MessageType:      aws.String(kms.MessageTypeDigest),
+		SigningAlgorithm: aws.String(alg),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error signing with %s", s.keyID)
+	}
+	return resp.Signature, nil
+}
+
+// signingAlgorithm returns the KMS signing algorithm for the given key and
+// options.
+func signingAlgorithm(pub crypto.PublicKey, opts crypto.SignerOpts) (string, error) {
+	h := opts.HashFunc()
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		_, isPSS := opts.(*rsa.PSSOptions)
+		switch {
+		case h == crypto.SHA256 && isPSS:
+			return kms.SigningAlgorithmSpecRsassaPssSha256, nil
+		case h == crypto.SHA384 && isPSS:
+			return kms.SigningAlgorithmSpecRsassaPssSha384, nil
+		case h == crypto.SHA512 && isPSS:
+			return kms.SigningAlgorithmSpecRsassaPssSha512, nil
+		case h == crypto.SHA256:
+			return kms.SigningAlgorithmSpecRsassaPkcs1V15Sha256, nil
+		case h == crypto.SHA384:
+			return kms.SigningAlgorithmSpecRsassaPkcs1V15Sha384, nil
+		case h == crypto.SHA512:
+			return kms.SigningAlgorithmSpecRsassaPkcs1V15Sha512, nil
+		}
+	case *ecdsa.PublicKey:
+		switch h {
+		case crypto.SHA256:
+			return kms.SigningAlgorithmSpecEcdsaSha256, nil
+		case crypto.SHA384:
+			return kms.SigningAlgorithmSpecEcdsaSha384, nil
+		case crypto.SHA512:
+			return kms.SigningAlgorithmSpecEcdsaSha512, nil
+		}
+	default:
+		return "", errors.Errorf("unsupported public key type %T", pub)
+	}
+	return "", errors.Errorf("unsupported hash function %v for key type %T", h, pub)
+}
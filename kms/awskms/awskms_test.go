@@ -0,0 +1,111 @@
+package awskms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/stretchr/testify/require"
+)
+
+// mockClient implements the methods of kmsiface.KMSAPI used by the Signer
+// with a local key.
+type mockClient struct {
+	kmsiface.KMSAPI
+	key crypto.Signer
+	alg string
+}
+
+func (m *mockClient) GetPublicKey(input *kms.GetPublicKeyInput) (*kms.GetPublicKeyOutput, error) {
+	b, err := x509.MarshalPKIXPublicKey(m.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GetPublicKeyOutput{
+		KeyId:     input.KeyId,
+		KeyUsage:  aws.String(kms.KeyUsageTypeSignVerify),
+		PublicKey: b,
+	}, nil
+}
+
+func (m *mockClient) Sign(input *kms.SignInput) (*kms.SignOutput, error) {
+	m.alg = aws.StringValue(input.SigningAlgorithm)
+	sig, err := m.key.Sign(rand.Reader, input.Message, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.SignOutput{
+		KeyId:            input.KeyId,
+		Signature:        sig,
+		SigningAlgorithm: input.SigningAlgorithm,
+	}, nil
+}
+
+func TestSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	client := &mockClient{key: key}
+	signer, err := newSigner(client, "alias/my-key")
+	require.NoError(t, err)
+	require.Equal(t, key.Public(), signer.Public())
+
+	digest := sha256.Sum256([]byte("message"))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	require.Equal(t, kms.SigningAlgorithmSpecEcdsaSha256, client.alg)
+	var esig struct {
+		R, S *big.Int
+	}
+	_, err = asn1.Unmarshal(sig, &esig)
+	require.NoError(t, err)
+	require.True(t, ecdsa.Verify(&key.PublicKey, digest[:], esig.R, esig.S))
+}
+
+func TestSigningAlgorithm(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		pub  crypto.PublicKey
+		opts crypto.SignerOpts
+		want string
+		err  bool
+	}{
+		{"ES256", ecKey.Public(), crypto.SHA256, kms.SigningAlgorithmSpecEcdsaSha256, false},
+		{"ES384", ecKey.Public(), crypto.SHA384, kms.SigningAlgorithmSpecEcdsaSha384, false},
+		{"RS256", rsaKey.Public(), crypto.SHA256, kms.SigningAlgorithmSpecRsassaPkcs1V15Sha256, false},
+		{"PS512", rsaKey.Public(), &rsa.PSSOptions{Hash: crypto.SHA512}, kms.SigningAlgorithmSpecRsassaPssSha512, false},
+		{"fail hash", ecKey.Public(), crypto.SHA1, "", true},
+		{"fail key", []byte("foo"), crypto.SHA256, "", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := signingAlgorithm(tc.pub, tc.opts)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestARNRegion(t *testing.T) {
+	require.Equal(t, "us-east-1", arnRegion("arn:aws:kms:us-east-1:123456789012:key/1234"))
+	require.Equal(t, "", arnRegion("alias/my-key"))
+}
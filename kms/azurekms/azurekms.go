@@ -0,0 +1,226 @@
+// Package azurekms implements a crypto.Signer using keys stored in Azure Key
+// Vault.
+package azurekms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/keyvault/2016-10-01/keyvault"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms/uri"
+)
+
+// Scheme is the scheme of the URIs of Azure Key Vault keys, e.g.
+// azurekms:<vault>/<key> or azurekms:<vault>/<key>/<version>.
+const Scheme = "azurekms"
+
+// keyVaultResource is the resource used to authorize the requests to Key
+// Vault.
+const keyVaultResource = "https://vault.azure.net"
+
+// keyVaultClient is the subset of the Key Vault client used by the Signer.
+type keyVaultClient interface {
+	GetKey(ctx context.Context, vaultBaseURL, keyName, keyVersion string) (keyvault.KeyBundle, error)
+	Sign(ctx context.Context, vaultBaseURL, keyName, keyVersion string, parameters keyvault.KeySignParameters) (keyvault.KeyOperationResult, error)
+}
+
+// Signer implements crypto.Signer using a key in Azure Key Vault.
+type Signer struct {
+	client    keyVaultClient
+	vaultURL  string
+	name      string
+	version   string
+	publicKey crypto.PublicKey
+}
+
+// NewSigner creates a new Signer for the key referenced by the given URI.
+// Credentials are loaded from the environment, using a service principal if
+// the AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET variables are
+// set, or using a managed identity otherwise. The vault can be a name, e.g.
+// azurekms:my-vault/my-key, or a host name for other clouds, e.g.
+// azurekms:my-vault.vault.azure.cn/my-key.
+func NewSigner(rawuri string) (*Signer, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(u.Name, "/")
+	if u.Scheme != Scheme || len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf("invalid Azure Key Vault key %s", rawuri)
+	}
+
+	vaultURL := "https://" + parts[0] + "/"
+	if !strings.Contains(parts[0], ".") {
+		vaultURL = "https://" + parts[0] + ".vault.azure.net/"
+	}
+	var version string
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+
+	authorizer, err := auth.NewAuthorizerFromEnvironmentWithResource(keyVaultResource)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating Azure authorizer")
+	}
+	c := keyvault.New()
+	c.Authorizer = authorizer
+	return newSigner(c, vaultURL, parts[1], version)
+}
+
+func newSigner(c keyVaultClient, vaultURL, name, version string) (*Signer, error) {
+	resp, err := c.GetKey(context.Background(), vaultURL, name, version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting key %s", name)
+	}
+	if resp.Key == nil {
+		return nil, errors.Errorf("error getting key %s: key is empty", name)
+	}
+	pub, err := convertKey(resp.Key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing key %s", name)
+	}
+	return &Signer{
+		client:    c,
+		vaultURL:  vaultURL,
+		name:      name,
+		version:   version,
+		publicKey: pub,
+	}, nil
+}
+
+// Public returns the public key of the signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs the given digest using the Key Vault key. ECDSA signatures are
+// returned in ASN.1 format like the ones generated by crypto/ecdsa.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	alg, err := signingAlgorithm(s.publicKey, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(digest)
+	resp, err := s.client.Sign(context.Background(), s.vaultURL, s.name, s.version, keyvault.KeySignParameters{
+		Algorithm: alg,
+		Value:     &value,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error signing with %s", s.name)
+	}
+	if resp.Result == nil {
+		return nil, errors.Errorf("error signing with %s: signature is empty", s.name)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(*resp.Result)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding signature")
+	}
+
+	if _, ok := s.publicKey.(*ecdsa.PublicKey); ok {
+		if len(sig)%2 != 0 {
+			return nil, errors.New("invalid ECDSA signature")
+		}
+		n := len(sig) / 2
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{new(big.Int).SetBytes(sig[:n]), new(big.Int).SetBytes(sig[n:])})
+	}
+	return sig, nil
+}
+
+// signingAlgorithm returns the Key Vault algorithm for the given key and
+// options.
+func signingAlgorithm(pub crypto.PublicKey, opts crypto.SignerOpts) (keyvault.JSONWebKeySignatureAlgorithm, error) {
+	h := opts.HashFunc()
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		_, isPSS := opts.(*rsa.PSSOptions)
+		switch {
+		case h == crypto.SHA256 && isPSS:
+			return keyvault.PS256, nil
+		case h == crypto.SHA384 && isPSS:
+			return keyvault.PS384, nil
+		case h == crypto.SHA512 && isPSS:
+			return keyvault.PS512, nil
+		case h == crypto.SHA256:
+			return keyvault.RS256, nil
+		case h == crypto.SHA384:
+			return keyvault.RS384, nil
+		case h == crypto.SHA512:
+			return keyvault.RS512, nil
+		}
+	case *ecdsa.PublicKey:
+		switch h {
+		case crypto.SHA256:
+			return keyvault.ES256, nil
+		case crypto.SHA384:
+			return keyvault.ES384, nil
+		case crypto.SHA512:
+			return keyvault.ES512, nil
+		}
+	default:
+		return "", errors.Errorf("unsupported public key type %T", pub)
+	}
+	return "", errors.Errorf("unsupported hash function %v for key type %T", h, pub)
+}
+
+// convertKey converts a Key Vault JSON Web Key to a public key.
+func convertKey(key *keyvault.JSONWebKey) (crypto.PublicKey, error) {
+	decode := func(s *string) (*big.Int, error) {
+		if s == nil {
+			return nil, errors.New("missing key parameter")
+		}
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(*s, "="))
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch key.Kty {
+	case keyvault.EC, keyvault.ECHSM:
+		var curve elliptic.Curve
+		switch key.Crv {
+		case keyvault.P256:
+			curve = elliptic.P256()
+		case keyvault.P384:
+			curve = elliptic.P384()
+		case keyvault.P521:
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %s", key.Crv)
+		}
+		x, err := decode(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case keyvault.RSA, keyvault.RSAHSM:
+		n, err := decode(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(key.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	default:
+		return nil, errors.Errorf("unsupported key type %s", key.Kty)
+	}
+}
@@ -0,0 +1,80 @@
+package azurekms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/keyvault/2016-10-01/keyvault"
+	"github.com/stretchr/testify/require"
+)
+
+// mockClient implements keyVaultClient with a local key.
+type mockClient struct {
+	key *ecdsa.PrivateKey
+}
+
+func (m *mockClient) GetKey(ctx context.Context, vaultBaseURL, keyName, keyVersion string) (keyvault.KeyBundle, error) {
+	x := base64.RawURLEncoding.EncodeToString(m.key.X.Bytes())
+	y := base64.RawURLEncoding.EncodeToString(m.key.Y.Bytes())
+	return keyvault.KeyBundle{
+		Key: &keyvault.JSONWebKey{
+			Kty: keyvault.EC,
+			Crv: keyvault.P256,
+			X:   &x,
+			Y:   &y,
+		},
+	}, nil
+}
+
+func (m *mockClient) Sign(ctx context.Context, vaultBaseURL, keyName, keyVersion string, parameters keyvault.KeySignParameters) (keyvault.KeyOperationResult, error) {
+	digest, err := base64.RawURLEncoding.DecodeString(*parameters.Value)
+	if err != nil {
+		return keyvault.KeyOperationResult{}, err
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, m.key, digest)
+	if err != nil {
+		return keyvault.KeyOperationResult{}, err
+	}
+	// Key Vault returns the concatenation of r and s.
+	sig := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(sig[32-len(rb):32], rb)
+	copy(sig[64-len(sb):], sb)
+	result := base64.RawURLEncoding.EncodeToString(sig)
+	return keyvault.KeyOperationResult{Result: &result}, nil
+}
+
+func TestSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := newSigner(&mockClient{key: key}, "https://my-vault.vault.azure.net/", "my-key", "")
+	require.NoError(t, err)
+	require.Equal(t, key.Public(), signer.Public())
+
+	digest := sha256.Sum256([]byte("message"))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+
+	var esig struct {
+		R, S *big.Int
+	}
+	_, err = asn1.Unmarshal(sig, &esig)
+	require.NoError(t, err)
+	require.True(t, ecdsa.Verify(&key.PublicKey, digest[:], esig.R, esig.S))
+}
+
+func TestNewSignerInvalid(t *testing.T) {
+	for _, uri := range []string{"azurekms:my-vault", "azurekms:/my-key", "azurekms:a/b/c/d", "awskms:my-vault/my-key"} {
+		_, err := NewSigner(uri)
+		require.Error(t, err, uri)
+	}
+}
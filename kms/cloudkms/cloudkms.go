@@ -0,0 +1,160 @@
+// Package cloudkms implements a crypto.Signer using keys stored in Google
+// Cloud Key Management Service (Cloud KMS).
+package cloudkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"strings"
+
+	cloudkms "cloud.google.com/go/kms/apiv1"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms/uri"
+	"google.golang.org/api/option"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+)
+
+// Scheme is the scheme of the URIs of Cloud KMS keys, e.g.
+// cloudkms:projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>/cryptoKeyVersions/<version>.
+const Scheme = "cloudkms"
+
+// keyManagementClient is the subset of the Cloud KMS client used by the
+// Signer.
+type keyManagementClient interface {
+	GetPublicKey(ctx context.Context, req *kmspb.GetPublicKeyRequest) (*kmspb.PublicKey, error)
+	AsymmetricSign(ctx context.Context, req *kmspb.AsymmetricSignRequest) (*kmspb.AsymmetricSignResponse, error)
+}
+
+// client adapts a cloudkms.KeyManagementClient to the keyManagementClient
+// interface.
+type client struct {
+	*cloudkms.KeyManagementClient
+}
+
+func (c client) GetPublicKey(ctx context.Context, req *kmspb.GetPublicKeyRequest) (*kmspb.PublicKey, error) {
+	return c.KeyManagementClient.GetPublicKey(ctx, req)
+}
+
+func (c client) AsymmetricSign(ctx context.Context, req *kmspb.AsymmetricSignRequest) (*kmspb.AsymmetricSignResponse, error) {
+	return c.KeyManagementClient.AsymmetricSign(ctx, req)
+}
+
+// Signer implements crypto.Signer using an asymmetric key version in Cloud
+// KMS.
+type Signer struct {
+	client    keyManagementClient
+	name      string
+	algorithm string
+	publicKey crypto.PublicKey
+}
+
+// NewSigner creates a new Signer for the key version referenced by the given
+// URI. Credentials are loaded using the Application Default Credentials, the
+// URI option credentials-file can be used to load them from a file, e.g.
+// cloudkms:projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1?credentials-file=creds.json.
+func NewSigner(rawuri string) (*Signer, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != Scheme || !strings.Contains(u.Name, "/cryptoKeyVersions/") {
+		return nil, errors.Errorf("invalid Cloud KMS key version %s", rawuri)
+	}
+
+	var opts []option.ClientOption
+	if fn := u.Get("credentials-file"); fn != "" {
+		opts = append(opts, option.WithCredentialsFile(fn))
+	}
+	c, err := cloudkms.NewKeyManagementClient(context.Background(), opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating Cloud KMS client")
+	}
+	return newSigner(client{c}, u.Name)
+}
+
+func newSigner(c keyManagementClient, name string) (*Signer, error) {
+	resp, err := c.GetPublicKey(context.Background(), &kmspb.GetPublicKeyRequest{
+		Name: name,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting public key of %s", name)
+	}
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		return nil, errors.Errorf("error parsing public key of %s", name)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing public key of %s", name)
+	}
+	return &Signer{
+		client:    c,
+		name:      name,
+		algorithm: resp.Algorithm.String(),
+		publicKey: pub,
+	}, nil
+}
+
+// Public returns the public key of the signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs the given digest using the Cloud KMS key. The algorithm is fixed
+// by the key version, so the options must match it.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if err := s.validateOptions(opts); err != nil {
+		return nil, err
+	}
+
+	req := &kmspb.AsymmetricSignRequest{
+		Name:   s.name,
+		Digest: &kmspb.Digest{},
+	}
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		req.Digest.Digest = &kmspb.Digest_Sha256{Sha256: digest}
+	case crypto.SHA384:
+		req.Digest.Digest = &kmspb.Digest_Sha384{Sha384: digest}
+	case crypto.SHA512:
+		req.Digest.Digest = &kmspb.Digest_Sha512{Sha512: digest}
+	default:
+		return nil, errors.Errorf("unsupported hash function %v", opts.HashFunc())
+	}
+
+	resp, err := s.client.AsymmetricSign(context.Background(), req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error signing with %s", s.name)
+	}
+	return resp.Signature, nil
+}
+
+// validateOptions checks that the signing options are compatible with the
+// algorithm of the key version, e.g. EC_SIGN_P256_SHA256 or
+// RSA_SIGN_PSS_2048_SHA256.
+func (s *Signer) validateOptions(opts crypto.SignerOpts) error {
+	var hash string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		hash = "SHA256"
+	case crypto.SHA384:
+		hash = "SHA384"
+	case crypto.SHA512:
+		hash = "SHA512"
+	default:
+		return errors.Errorf("unsupported hash function %v", opts.HashFunc())
+	}
+	if !strings.HasSuffix(s.algorithm, "_"+hash) {
+		return errors.Errorf("hash function %s is not compatible with key algorithm %s", hash, s.algorithm)
+	}
+
+	_, isPSS := opts.(*rsa.PSSOptions)
+	if isPSS != strings.Contains(s.algorithm, "_PSS_") {
+		return errors.Errorf("signing options are not compatible with key algorithm %s", s.algorithm)
+	}
+	return nil
+}
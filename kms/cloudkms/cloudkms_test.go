@@ -0,0 +1,89 @@
+package cloudkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+)
+
+// mockClient implements keyManagementClient with a local key.
+type mockClient struct {
+	key       crypto.Signer
+	algorithm kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm
+}
+
+func (m *mockClient) GetPublicKey(ctx context.Context, req *kmspb.GetPublicKeyRequest) (*kmspb.PublicKey, error) {
+	b, err := x509.MarshalPKIXPublicKey(m.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &kmspb.PublicKey{
+		Pem:       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b})),
+		Algorithm: m.algorithm,
+	}, nil
+}
+
+func (m *mockClient) AsymmetricSign(ctx context.Context, req *kmspb.AsymmetricSignRequest) (*kmspb.AsymmetricSignResponse, error) {
+	sig, err := m.key.Sign(rand.Reader, req.Digest.GetSha256(), crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return &kmspb.AsymmetricSignResponse{Signature: sig}, nil
+}
+
+func TestSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := newSigner(&mockClient{
+		key:       key,
+		algorithm: kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256,
+	}, "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1")
+	require.NoError(t, err)
+	require.Equal(t, key.Public(), signer.Public())
+
+	digest := sha256.Sum256([]byte("message"))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	require.NotEmpty(t, sig)
+
+	_, err = signer.Sign(rand.Reader, digest[:], crypto.SHA384)
+	require.Error(t, err)
+}
+
+func TestValidateOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		opts      crypto.SignerOpts
+		err       bool
+	}{
+		{"ok EC", "EC_SIGN_P384_SHA384", crypto.SHA384, false},
+		{"ok PKCS1", "RSA_SIGN_PKCS1_2048_SHA256", crypto.SHA256, false},
+		{"ok PSS", "RSA_SIGN_PSS_4096_SHA512", &rsa.PSSOptions{Hash: crypto.SHA512}, false},
+		{"fail hash", "EC_SIGN_P256_SHA256", crypto.SHA512, true},
+		{"fail PSS", "RSA_SIGN_PKCS1_2048_SHA256", &rsa.PSSOptions{Hash: crypto.SHA256}, true},
+		{"fail PKCS1", "RSA_SIGN_PSS_2048_SHA256", crypto.SHA256, true},
+		{"fail SHA1", "RSA_SIGN_PSS_2048_SHA256", crypto.SHA1, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Signer{algorithm: tc.algorithm}
+			if tc.err {
+				require.Error(t, s.validateOptions(tc.opts))
+			} else {
+				require.NoError(t, s.validateOptions(tc.opts))
+			}
+		})
+	}
+}
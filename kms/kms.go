@@ -0,0 +1,171 @@
+// Package kms implements the access to private keys using key URIs. Each URI
+// scheme is handled by a Resolver, and the keys can be stored in files or
+// environment variables, or in key management systems and devices that never
+// expose the key material. Keys are referenced using URIs like
+// file:/path/to/key.pem, env:STEP_KEY, awskms:alias/my-key,
+// cloudkms:projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1
+// azurekms:my-vault/my-key, yubikey:9a, pkcs11:token=my-token;object=my-key,
+// agent:<fingerprint>, step-agent:<kid>, vault:transit/keys/my-key or
+// keychain:my-key that can be used anywhere a private key file is accepted.
+package kms
+
+import (
+	"crypto"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/pemutil"
+)
+
+// Resolver returns the keys referenced by the URIs of a scheme.
+type Resolver interface {
+	NewSigner(uri string) (crypto.Signer, error)
+}
+
+// KeyReader is implemented by the resolvers of schemes that reference the key
+// material, like files or environment variables, instead of a key that never
+// leaves a KMS or a device. The key material can be in PEM or JWK format, and it
+// can be encrypted. ReadKey returns nil if the given URI references a key that
+// can only be used through a signer.
+type KeyReader interface {
+	ReadKey(uri string) ([]byte, error)
+}
+
+// SignerFunc is an adapter to allow the use of ordinary functions as a
+// Resolver.
+type SignerFunc func(uri string) (crypto.Signer, error)
+
+// NewSigner calls f(uri).
+func (f SignerFunc) NewSigner(uri string) (crypto.Signer, error) {
+	return f(uri)
+}
+
+// KeyReaderFunc is an adapter to allow the use of ordinary functions as a
+// Resolver and KeyReader. The signers are created parsing the PEM key
+// returned by the function.
+type KeyReaderFunc func(uri string) ([]byte, error)
+
+// ReadKey calls f(uri).
+func (f KeyReaderFunc) ReadKey(uri string) ([]byte, error) {
+	return f(uri)
+}
+
+// NewSigner parses the PEM key returned by f(uri).
+func (f KeyReaderFunc) NewSigner(uri string) (crypto.Signer, error) {
+	b, err := f(uri)
+	if err != nil {
+		return nil, err
+	}
+	return parseSigner(uri, b)
+}
+
+// MessageSigner is implemented by signers that cannot sign a pre-computed
+// digest and need the full message instead, like the keys in an ssh-agent. The
+// message will be hashed by the signer using the hash function in opts.
+type MessageSigner interface {
+	crypto.Signer
+	SignMessage(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = make(map[string]Resolver)
+)
+
+// Register makes a resolver available for the given scheme. It panics if the
+// scheme is already registered or the resolver is nil.
+func Register(scheme string, r Resolver) {
+	scheme = strings.ToLower(scheme)
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	if r == nil {
+		panic("kms: Register resolver is nil")
+	}
+	if _, ok := resolvers[scheme]; ok {
+		panic("kms: Register called twice for scheme " + scheme)
+	}
+	resolvers[scheme] = r
+}
+
+// Schemes returns the sorted list of registered schemes.
+func Schemes() []string {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	schemes := make([]string, 0, len(resolvers))
+	for scheme := range resolvers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// lookup returns the resolver of the scheme of the given URI.
+func lookup(uri string) (Resolver, bool) {
+	scheme := Scheme(uri)
+	if scheme == "" {
+		return nil, false
+	}
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	r, ok := resolvers[scheme]
+	return r, ok
+}
+
+// Scheme returns the scheme of the given key URI, or an empty string if the
+// key is not a URI with a registered scheme.
+func Scheme(uri string) string {
+	i := strings.Index(uri, ":")
+	if i <= 0 {
+		return ""
+	}
+	scheme := strings.ToLower(uri[:i])
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	if _, ok := resolvers[scheme]; ok {
+		return scheme
+	}
+	return ""
+}
+
+// IsKMS returns true if the given key is a URI with a registered scheme.
+func IsKMS(uri string) bool {
+	return Scheme(uri) != ""
+}
+
+// NewSigner returns a crypto.Signer for the key referenced by the given URI.
+func NewSigner(uri string) (crypto.Signer, error) {
+	r, ok := lookup(uri)
+	if !ok {
+		return nil, errors.Errorf("unsupported key %s", uri)
+	}
+	return r.NewSigner(uri)
+}
+
+// ReadKey returns the key material referenced by the given URI. It returns
+// nil if the key can only be used through the signer returned by NewSigner.
+func ReadKey(uri string) ([]byte, error) {
+	r, ok := lookup(uri)
+	if !ok {
+		return nil, errors.Errorf("unsupported key %s", uri)
+	}
+	if kr, ok := r.(KeyReader); ok {
+		return kr.ReadKey(uri)
+	}
+	return nil, nil
+}
+
+// parseSigner parses the given PEM key and returns it as a crypto.Signer.
+func parseSigner(name string, b []byte) (crypto.Signer, error) {
+	key, err := pemutil.Parse(b, pemutil.WithFilename(name))
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("key %s is not a private key", name)
+	}
+	return signer, nil
+}
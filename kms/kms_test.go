@@ -0,0 +1,116 @@
+package kms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheme(t *testing.T) {
+	tests := []struct {
+		uri, scheme string
+	}{
+		{"file:/path/to/key.pem", "file"},
+		{"env:STEP_KEY", "env"},
+		{"awskms:alias/my-key", "awskms"},
+		{"AWSKMS:alias/my-key", "awskms"},
+		{"awskms:arn:aws:kms:us-east-1:123456789012:key/1234", "awskms"},
+		{"cloudkms:projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1", "cloudkms"},
+		{"azurekms:my-vault/my-key", "azurekms"},
+		{"yubikey:9a", "yubikey"},
+		{"yubikey:9c?serial=12345678", "yubikey"},
+		{"pkcs11:token=my-token;object=my-key?module-path=/usr/lib/softhsm/libsofthsm2.so", "pkcs11"},
+		{"agent:SHA256:ZNTOMF9r2Ww4Nx6s3QVv7dWBpnbEZGYtlkrhoFhBAbA", "agent"},
+		{"agent:joe@example.com", "agent"},
+		{"vault:transit/keys/my-key", "vault"},
+		{"keychain:my-key", "keychain"},
+		{"foo.key", ""},
+		{"C:\\keys\\foo.key", ""},
+		{"unknown:foo", ""},
+		{":foo", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.uri, func(t *testing.T) {
+			require.Equal(t, tc.scheme, Scheme(tc.uri))
+			require.Equal(t, tc.scheme != "", IsKMS(tc.uri))
+		})
+	}
+}
+
+type testResolver struct {
+	signer crypto.Signer
+}
+
+func (r testResolver) NewSigner(uri string) (crypto.Signer, error) {
+	return r.signer, nil
+}
+
+func TestRegister(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	Register("Test", testResolver{signer: key})
+	defer func() {
+		resolversMu.Lock()
+		delete(resolvers, "test")
+		resolversMu.Unlock()
+	}()
+
+	require.Contains(t, Schemes(), "test")
+	require.True(t, IsKMS("test:my-key"))
+	signer, err := NewSigner("TEST:my-key")
+	require.NoError(t, err)
+	require.Equal(t, key, signer)
+	b, err := ReadKey("test:my-key")
+	require.NoError(t, err)
+	require.Nil(t, b)
+
+	require.Panics(t, func() { Register("test", testResolver{}) })
+	require.Panics(t, func() { Register("other", nil) })
+}
+
+func TestFileAndEnv(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	block, err := pemutil.Serialize(key)
+	require.NoError(t, err)
+	b := pem.EncodeToMemory(block)
+
+	dir, err := ioutil.TempDir("", "kms")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(filename, b, 0600))
+
+	os.Setenv("STEP_TEST_KEY", string(b))
+	defer os.Unsetenv("STEP_TEST_KEY")
+
+	for _, uri := range []string{"file:" + filename, "file://" + filename, "env:STEP_TEST_KEY"} {
+		t.Run(uri, func(t *testing.T) {
+			got, err := ReadKey(uri)
+			require.NoError(t, err)
+			require.Equal(t, b, got)
+			signer, err := NewSigner(uri)
+			require.NoError(t, err)
+			require.Equal(t, key, signer)
+		})
+	}
+
+	for _, uri := range []string{"file:", "file:" + filepath.Join(dir, "missing.pem"), "env:", "env:STEP_TEST_MISSING_KEY", "foo:bar"} {
+		t.Run(uri, func(t *testing.T) {
+			_, err := ReadKey(uri)
+			require.Error(t, err)
+			_, err = NewSigner(uri)
+			require.Error(t, err)
+		})
+	}
+}
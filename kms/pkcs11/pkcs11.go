@@ -0,0 +1,134 @@
+// Package pkcs11 implements a crypto.Signer using a private key stored in a
+// PKCS #11 token, like an HSM or a smart card. The private keys never leave the
+// token.
+package pkcs11
+
+import (
+	"crypto"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ThalesIgnite/crypto11"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms/uri"
+	"github.com/smallstep/cli/ui"
+)
+
+// Scheme is the scheme of the PKCS #11 URIs defined in RFC 7512. The token is
+// selected using the token, serial or slot-id attributes, and the key using
+// the object or id attributes. The module is set using the module-path query
+// attribute, e.g.
+// pkcs11:token=my-token;object=my-key?module-path=/usr/lib/softhsm/libsofthsm2.so
+const Scheme = "pkcs11"
+
+// Signer implements crypto.Signer using a key in a PKCS #11 token.
+type Signer struct {
+	crypto.Signer
+	ctx *crypto11.Context
+}
+
+// NewSigner creates a new Signer for the key referenced by the given URI. If
+// the pin-value query attribute is not set the PIN will be prompted.
+func NewSigner(rawuri string) (*Signer, error) {
+	config, id, label, err := parseURI(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	if config.Pin == "" {
+		b, err := ui.PromptPassword("Please enter the PKCS #11 token PIN")
+		if err != nil {
+			return nil, err
+		}
+		config.Pin = string(b)
+	}
+
+	ctx, err := crypto11.Configure(config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening PKCS #11 module %s", config.Path)
+	}
+	signer, err := ctx.FindKeyPair(id, label)
+	if err != nil {
+		ctx.Close()
+		return nil, errors.Wrapf(err, "error finding key %s", rawuri)
+	}
+	if signer == nil {
+		ctx.Close()
+		return nil, errors.Errorf("key %s not found", rawuri)
+	}
+	return &Signer{
+		Signer: signer,
+		ctx:    ctx,
+	}, nil
+}
+
+// Close closes the session with the token.
+func (s *Signer) Close() error {
+	return errors.Wrap(s.ctx.Close(), "error closing PKCS #11 module")
+}
+
+// parseURI returns the configuration of the module, and the id and label of
+// the key referenced by the given URI.
+func parseURI(rawuri string) (config *crypto11.Config, id, label []byte, err error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if u.Scheme != Scheme {
+		return nil, nil, nil, errors.Errorf("invalid PKCS #11 key %s", rawuri)
+	}
+
+	config = new(crypto11.Config)
+	for _, attr := range strings.Split(u.Name, ";") {
+		if attr == "" {
+			continue
+		}
+		parts := strings.SplitN(attr, "=", 2)
+		if len(parts) != 2 {
+			return nil, nil, nil, errors.Errorf("invalid PKCS #11 key %s: invalid attribute %s", rawuri, attr)
+		}
+		value, err := url.PathUnescape(parts[1])
+		if err != nil {
+			return nil, nil, nil, errors.Errorf("invalid PKCS #11 key %s: invalid attribute %s", rawuri, attr)
+		}
+		switch parts[0] {
+		case "token":
+			config.TokenLabel = value
+		case "serial":
+			config.TokenSerial = value
+		case "slot-id":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, nil, nil, errors.Errorf("invalid PKCS #11 key %s: invalid slot-id %s", rawuri, value)
+			}
+			config.SlotNumber = &n
+		case "object":
+			label = []byte(value)
+		case "id":
+			id = []byte(value)
+		}
+	}
+
+	for k, v := range u.Options {
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			return nil, nil, nil, errors.Errorf("invalid PKCS #11 key %s: invalid attribute %s", rawuri, k)
+		}
+		switch k {
+		case "module-path":
+			config.Path = value
+		case "pin-value":
+			config.Pin = value
+		}
+	}
+
+	switch {
+	case config.Path == "":
+		return nil, nil, nil, errors.Errorf("invalid PKCS #11 key %s: module-path is required", rawuri)
+	case config.TokenLabel == "" && config.TokenSerial == "" && config.SlotNumber == nil:
+		return nil, nil, nil, errors.Errorf("invalid PKCS #11 key %s: token, serial or slot-id is required", rawuri)
+	case id == nil && label == nil:
+		return nil, nil, nil, errors.Errorf("invalid PKCS #11 key %s: object or id is required", rawuri)
+	}
+	return config, id, label, nil
+}
@@ -0,0 +1,49 @@
+package pkcs11
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseURI(t *testing.T) {
+	slot := 2
+	tests := []struct {
+		uri         string
+		path, token string
+		serial, pin string
+		slot        *int
+		id, label   []byte
+		err         bool
+	}{
+		{"pkcs11:token=my-token;object=my-key?module-path=/usr/lib/softhsm/libsofthsm2.so",
+			"/usr/lib/softhsm/libsofthsm2.so", "my-token", "", "", nil, nil, []byte("my-key"), false},
+		{"pkcs11:token=My%20Token;id=%01%02?module-path=/lib/p11.so&pin-value=1234",
+			"/lib/p11.so", "My Token", "", "1234", nil, []byte{1, 2}, nil, false},
+		{"pkcs11:serial=0123456789;slot-id=2;object=k;id=%ab?module-path=/lib/p11.so",
+			"/lib/p11.so", "", "0123456789", "", &slot, []byte{0xab}, []byte("k"), false},
+		{"pkcs11:token=my-token;object=my-key", "", "", "", "", nil, nil, nil, true},
+		{"pkcs11:object=my-key?module-path=/lib/p11.so", "", "", "", "", nil, nil, nil, true},
+		{"pkcs11:token=my-token?module-path=/lib/p11.so", "", "", "", "", nil, nil, nil, true},
+		{"pkcs11:slot-id=foo;object=k?module-path=/lib/p11.so", "", "", "", "", nil, nil, nil, true},
+		{"pkcs11:token;object=k?module-path=/lib/p11.so", "", "", "", "", nil, nil, nil, true},
+		{"yubikey:9a", "", "", "", "", nil, nil, nil, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.uri, func(t *testing.T) {
+			config, id, label, err := parseURI(tc.uri)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.path, config.Path)
+			require.Equal(t, tc.token, config.TokenLabel)
+			require.Equal(t, tc.serial, config.TokenSerial)
+			require.Equal(t, tc.pin, config.Pin)
+			require.Equal(t, tc.slot, config.SlotNumber)
+			require.Equal(t, tc.id, id)
+			require.Equal(t, tc.label, label)
+		})
+	}
+}
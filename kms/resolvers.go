@@ -0,0 +1,150 @@
+package kms
+
+import (
+	"crypto"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/credstore"
+	"github.com/smallstep/cli/kms/awskms"
+	"github.com/smallstep/cli/kms/azurekms"
+	"github.com/smallstep/cli/kms/cloudkms"
+	"github.com/smallstep/cli/kms/pkcs11"
+	"github.com/smallstep/cli/kms/sshagent"
+	"github.com/smallstep/cli/kms/stepagent"
+	"github.com/smallstep/cli/kms/uri"
+	"github.com/smallstep/cli/kms/vault"
+	"github.com/smallstep/cli/kms/yubikey"
+)
+
+const (
+	// FileScheme is the scheme of the URIs of the keys stored in files, e.g.
+	// file:/path/to/key.pem or file:///path/to/key.pem.
+	FileScheme = "file"
+	// EnvScheme is the scheme of the URIs of the keys stored in environment
+	// variables, e.g. env:STEP_KEY.
+	EnvScheme = "env"
+	// KeychainScheme is the scheme of the URIs of the private keys stored in
+	// the credential store of the operating system, e.g. keychain:my-key.
+	KeychainScheme = "keychain"
+)
+
+func init() {
+	Register(FileScheme, KeyReaderFunc(readFileKey))
+	Register(EnvScheme, KeyReaderFunc(readEnvKey))
+	Register(KeychainScheme, KeyReaderFunc(readKeychainKey))
+	Register(vault.Scheme, vaultResolver{})
+	Register(awskms.Scheme, SignerFunc(func(uri string) (crypto.Signer, error) {
+		return toSigner(awskms.NewSigner(uri))
+	}))
+	Register(cloudkms.Scheme, SignerFunc(func(uri string) (crypto.Signer, error) {
+		return toSigner(cloudkms.NewSigner(uri))
+	}))
+	Register(azurekms.Scheme, SignerFunc(func(uri string) (crypto.Signer, error) {
+		return toSigner(azurekms.NewSigner(uri))
+	}))
+	Register(yubikey.Scheme, SignerFunc(func(uri string) (crypto.Signer, error) {
+		return toSigner(yubikey.NewSigner(uri))
+	}))
+	Register(pkcs11.Scheme, SignerFunc(func(uri string) (crypto.Signer, error) {
+		return toSigner(pkcs11.NewSigner(uri))
+	}))
+	Register(sshagent.Scheme, SignerFunc(func(uri string) (crypto.Signer, error) {
+		return toSigner(sshagent.NewSigner(uri))
+	}))
+	Register(stepagent.Scheme, SignerFunc(func(uri string) (crypto.Signer, error) {
+		return toSigner(stepagent.NewSigner(uri))
+	}))
+}
+
+// toSigner avoids returning a non-nil interface holding a nil pointer when a
+// backend fails.
+func toSigner(signer crypto.Signer, err error) (crypto.Signer, error) {
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// readFileKey reads a key from the file in the given URI. Both
+// file:/path/to/key.pem and file:///path/to/key.pem are accepted.
+func readFileKey(rawuri string) ([]byte, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	filename := strings.TrimPrefix(u.Name, "//")
+	if filename == "" {
+		return nil, errors.Errorf("invalid key %s: the file name cannot be empty", rawuri)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", filename)
+	}
+	return b, nil
+}
+
+// readEnvKey reads a key from the environment variable in the given URI.
+func readEnvKey(rawuri string) ([]byte, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Name == "" {
+		return nil, errors.Errorf("invalid key %s: the variable name cannot be empty", rawuri)
+	}
+	value, ok := os.LookupEnv(u.Name)
+	if !ok || value == "" {
+		return nil, errors.Errorf("error reading %s: environment variable %s is not set", rawuri, u.Name)
+	}
+	return []byte(value), nil
+}
+
+// readKeychainKey reads a key from the credential store of the operating
+// system.
+func readKeychainKey(rawuri string) ([]byte, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	b, err := credstore.Get(u.Name)
+	if err != nil {
+		if err == credstore.ErrNotFound {
+			return nil, errors.Errorf("key %s not found in the credential store", rawuri)
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// vaultResolver resolves keys in the Transit secrets engine, that are only
+// available through a signer, and PEM keys stored in the KV secrets engine.
+type vaultResolver struct{}
+
+func (vaultResolver) NewSigner(rawuri string) (crypto.Signer, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	if vault.IsTransit(u) {
+		return toSigner(vault.NewSigner(rawuri))
+	}
+	b, err := vault.ReadSecret(u.Name, u.Get("field"))
+	if err != nil {
+		return nil, err
+	}
+	return parseSigner(rawuri, b)
+}
+
+func (vaultResolver) ReadKey(rawuri string) ([]byte, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	if vault.IsTransit(u) {
+		return nil, nil
+	}
+	return vault.ReadSecret(u.Name, u.Get("field"))
+}
@@ -0,0 +1,212 @@
+// Package sshagent implements a crypto.Signer using the keys loaded in an
+// ssh-agent. The private keys never leave the agent.
+package sshagent
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms/uri"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Scheme is the scheme of the URIs of ssh-agent keys. A key can be selected
+// using its SHA256 or MD5 fingerprint or its comment, e.g.
+// agent:SHA256:ZNTOMF9r2Ww4Nx6s3QVv7dWBpnbEZGYtlkrhoFhBAbA or
+// agent:joe@example.com.
+const Scheme = "agent"
+
+// Signer implements crypto.Signer using a key in an ssh-agent.
+type Signer struct {
+	client    agent.ExtendedAgent
+	conn      io.Closer
+	key       *agent.Key
+	publicKey crypto.PublicKey
+}
+
+// NewSigner creates a new Signer for the key referenced by the given URI. The
+// agent is located using the SSH_AUTH_SOCK environment variable.
+func NewSigner(rawuri string) (*Signer, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != Scheme || u.Name == "" {
+		return nil, errors.Errorf("invalid ssh-agent key %s", rawuri)
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("error connecting to ssh-agent: SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, errors.Wrap(err, "error connecting to ssh-agent")
+	}
+
+	s, err := newSigner(agent.NewClient(conn), u.Name)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+func newSigner(client agent.ExtendedAgent, name string) (*Signer, error) {
+	keys, err := client.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing ssh-agent keys")
+	}
+
+	for _, k := range keys {
+		if !matchKey(k, name) {
+			continue
+		}
+		pub, err := ssh.ParsePublicKey(k.Blob)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing ssh-agent key")
+		}
+		cpub, ok := pub.(ssh.CryptoPublicKey)
+		if !ok {
+			return nil, errors.Errorf("unsupported ssh-agent key type %s", k.Format)
+		}
+		return &Signer{
+			client:    client,
+			key:       k,
+			publicKey: cpub.CryptoPublicKey(),
+		}, nil
+	}
+
+	return nil, errors.Errorf("key %s not found in ssh-agent", name)
+}
+
+// matchKey returns true if the name is the fingerprint or the comment of the
+// given key.
+func matchKey(k *agent.Key, name string) bool {
+	switch {
+	case k.Comment == name:
+		return true
+	case strings.HasPrefix(name, "SHA256:"):
+		return ssh.FingerprintSHA256(k) == name
+	case strings.HasPrefix(name, "MD5:"):
+		return ssh.FingerprintLegacyMD5(k) == strings.TrimPrefix(name, "MD5:")
+	default:
+		return false
+	}
+}
+
+// Public returns the public key of the signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs the given data with the key in the ssh-agent. An ssh-agent does
+// not support signing pre-computed digests, so only Ed25519 keys, which sign
+// the full message, are supported. SignMessage must be used for other keys.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := s.publicKey.(ed25519.PublicKey); !ok || opts.HashFunc() != 0 {
+		return nil, errors.New("ssh-agent keys cannot sign a digest")
+	}
+	return s.SignMessage(rand, digest, opts)
+}
+
+// SignMessage signs the given message with the key in the ssh-agent. The hash
+// function in opts must match the one used by the agent: SHA-256, SHA-384 or
+// SHA-512 for ECDSA keys depending on the curve, SHA-1, SHA-256 or SHA-512 for
+// RSA keys, and none for Ed25519 keys. ECDSA signatures are returned in ASN.1
+// format.
+func (s *Signer) SignMessage(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var flags agent.SignatureFlags
+	switch k := s.publicKey.(type) {
+	case ed25519.PublicKey:
+		if opts.HashFunc() != 0 {
+			return nil, errors.Errorf("unsupported hash %s for Ed25519 keys", hashName(opts.HashFunc()))
+		}
+	case *ecdsa.PublicKey:
+		if h := ecdsaHash(k.Curve); opts.HashFunc() != h {
+			return nil, errors.Errorf("unsupported hash %s for %s keys: it must be %s",
+				hashName(opts.HashFunc()), k.Curve.Params().Name, hashName(h))
+		}
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, errors.New("RSA-PSS signatures are not supported by ssh-agent")
+		}
+		switch opts.HashFunc() {
+		case crypto.SHA1:
+		case crypto.SHA256:
+			flags = agent.SignatureFlagRsaSha256
+		case crypto.SHA512:
+			flags = agent.SignatureFlagRsaSha512
+		default:
+			return nil, errors.Errorf("unsupported hash %s for RSA keys", hashName(opts.HashFunc()))
+		}
+	default:
+		return nil, errors.Errorf("unsupported ssh-agent key type %s", s.key.Format)
+	}
+
+	sig, err := s.client.SignWithFlags(s.key, message, flags)
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing with ssh-agent")
+	}
+
+	// SSH encodes ECDSA signatures as two mpints.
+	if _, ok := s.publicKey.(*ecdsa.PublicKey); ok {
+		var esig struct {
+			R, S *big.Int
+		}
+		if err := ssh.Unmarshal(sig.Blob, &esig); err != nil {
+			return nil, errors.Wrap(err, "error parsing ssh-agent signature")
+		}
+		return asn1.Marshal(esig)
+	}
+	return sig.Blob, nil
+}
+
+// Close closes the connection with the ssh-agent.
+func (s *Signer) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return errors.Wrap(s.conn.Close(), "error closing ssh-agent connection")
+}
+
+// ecdsaHash returns the hash function used by SSH for the given curve.
+func ecdsaHash(curve elliptic.Curve) crypto.Hash {
+	switch curve.Params().BitSize {
+	case 384:
+		return crypto.SHA384
+	case 521:
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
+
+func hashName(h crypto.Hash) string {
+	switch h {
+	case 0:
+		return "none"
+	case crypto.SHA1:
+		return "SHA-1"
+	case crypto.SHA256:
+		return "SHA-256"
+	case crypto.SHA384:
+		return "SHA-384"
+	case crypto.SHA512:
+		return "SHA-512"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,103 @@
+package sshagent
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func newKeyring(t *testing.T) (agent.ExtendedAgent, map[string]crypto.Signer) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keys := map[string]crypto.Signer{
+		"ecdsa":   ecKey,
+		"rsa":     rsaKey,
+		"ed25519": edKey,
+	}
+	keyring := agent.NewKeyring().(agent.ExtendedAgent)
+	for comment, key := range keys {
+		require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key, Comment: comment}))
+	}
+	return keyring, keys
+}
+
+func TestNewSigner(t *testing.T) {
+	keyring, keys := newKeyring(t)
+
+	sshPub, err := ssh.NewPublicKey(keys["ecdsa"].Public())
+	require.NoError(t, err)
+
+	for _, name := range []string{"ecdsa", ssh.FingerprintSHA256(sshPub), "MD5:" + ssh.FingerprintLegacyMD5(sshPub)} {
+		s, err := newSigner(keyring, name)
+		require.NoError(t, err, name)
+		require.Equal(t, keys["ecdsa"].Public(), s.Public())
+	}
+
+	_, err = newSigner(keyring, "missing")
+	require.Error(t, err)
+	_, err = newSigner(keyring, "SHA256:missing")
+	require.Error(t, err)
+}
+
+func TestSigner_SignMessage(t *testing.T) {
+	keyring, keys := newKeyring(t)
+	message := []byte("the message")
+
+	// ECDSA P-384 uses SHA-384
+	s, err := newSigner(keyring, "ecdsa")
+	require.NoError(t, err)
+	_, err = s.SignMessage(rand.Reader, message, crypto.SHA256)
+	require.Error(t, err)
+	sig, err := s.SignMessage(rand.Reader, message, crypto.SHA384)
+	require.NoError(t, err)
+	var esig struct {
+		R, S *big.Int
+	}
+	_, err = asn1.Unmarshal(sig, &esig)
+	require.NoError(t, err)
+	digest := sha512.Sum384(message)
+	require.True(t, ecdsa.Verify(keys["ecdsa"].Public().(*ecdsa.PublicKey), digest[:], esig.R, esig.S))
+	_, err = s.Sign(rand.Reader, digest[:], crypto.SHA384)
+	require.Error(t, err)
+
+	// RSA
+	s, err = newSigner(keyring, "rsa")
+	require.NoError(t, err)
+	sig, err = s.SignMessage(rand.Reader, message, crypto.SHA256)
+	require.NoError(t, err)
+	sum := sha256.Sum256(message)
+	require.NoError(t, rsa.VerifyPKCS1v15(keys["rsa"].Public().(*rsa.PublicKey), crypto.SHA256, sum[:], sig))
+	_, err = s.SignMessage(rand.Reader, message, &rsa.PSSOptions{Hash: crypto.SHA256})
+	require.Error(t, err)
+
+	// Ed25519
+	s, err = newSigner(keyring, "ed25519")
+	require.NoError(t, err)
+	sig, err = s.Sign(rand.Reader, message, crypto.Hash(0))
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(keys["ed25519"].Public().(ed25519.PublicKey), message, sig))
+}
+
+func TestNewSignerInvalid(t *testing.T) {
+	for _, uri := range []string{"agent:", "yubikey:9a"} {
+		_, err := NewSigner(uri)
+		require.Error(t, err, uri)
+	}
+}
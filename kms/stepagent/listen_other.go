@@ -0,0 +1,12 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package stepagent
+
+import (
+	"net"
+)
+
+// Listen creates the unix socket of the agent in the given path.
+func Listen(socket string) (net.Listener, error) {
+	return net.Listen("unix", socket)
+}
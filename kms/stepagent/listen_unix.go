@@ -0,0 +1,18 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package stepagent
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// Listen creates the unix socket of the agent in the given path. The socket is
+// created with a restrictive umask, so it is never accessible by other users,
+// not even between its creation and a chmod.
+func Listen(socket string) (net.Listener, error) {
+	mask := unix.Umask(0177)
+	defer unix.Umask(mask)
+	return net.Listen("unix", socket)
+}
@@ -0,0 +1,16 @@
+// +build darwin freebsd
+
+package stepagent
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// peerUID returns the user id of the process connected to the socket.
+func peerUID(fd int) (int, error) {
+	cred, err := unix.GetsockoptXucred(fd, unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	if err != nil {
+		return -1, err
+	}
+	return int(cred.Uid), nil
+}
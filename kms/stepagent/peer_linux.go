@@ -0,0 +1,14 @@
+package stepagent
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// peerUID returns the user id of the process connected to the socket.
+func peerUID(fd int) (int, error) {
+	cred, err := unix.GetsockoptUcred(fd, unix.SOL_SOCKET, unix.SO_PEERCRED)
+	if err != nil {
+		return -1, err
+	}
+	return int(cred.Uid), nil
+}
@@ -0,0 +1,37 @@
+// +build darwin freebsd linux
+
+package stepagent
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// checkPeer returns an error if the process connected to the agent is not run
+// by the same user as the agent.
+func checkPeer(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return errors.Wrap(err, "error getting peer credentials")
+	}
+	var uid int
+	var uerr error
+	if err := raw.Control(func(fd uintptr) {
+		uid, uerr = peerUID(int(fd))
+	}); err != nil {
+		return errors.Wrap(err, "error getting peer credentials")
+	}
+	if uerr != nil {
+		return errors.Wrap(uerr, "error getting peer credentials")
+	}
+	if uid != os.Getuid() {
+		return errors.Errorf("connection from uid %d rejected", uid)
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+// Package stepagent implements the client and the server of the step agent,
+// started with 'step agent start'. The agent holds decrypted keys in memory
+// and signs with them on behalf of other step commands over a unix socket, so
+// the keys are decrypted only once. The private keys never leave the agent.
+package stepagent
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/pemutil"
+	"github.com/smallstep/cli/kms/uri"
+)
+
+// Scheme is the scheme of the URIs of the keys in the step agent. A key can
+// be selected using the name it was loaded with or its key id, e.g.
+// step-agent:/home/joe/.step/secrets/jwk.json or
+// step-agent:ZNTOMF9r2Ww4Nx6s3QVv7dWBpnbEZGYtlkrhoFhBAbA.
+const Scheme = "step-agent"
+
+// SocketEnv is the environment variable with the path of the agent socket.
+const SocketEnv = "STEP_AGENT_SOCK"
+
+// serviceName is the name of the RPC service of the agent.
+const serviceName = "Agent"
+
+// SocketPath returns the path of the agent socket, the value of
+// STEP_AGENT_SOCK or agent.sock in the step path.
+func SocketPath() string {
+	if socket := os.Getenv(SocketEnv); socket != "" {
+		return socket
+	}
+	return filepath.Join(config.StepPath(), "agent.sock")
+}
+
+// Key is the public information of a key in the agent.
+type Key struct {
+	// Name is the absolute path of the file the key was loaded from, or the
+	// key id of a provisioner key downloaded from the CA.
+	Name      string
+	KeyID     string
+	Algorithm string
+	Use       string
+	// PublicKey is the public key in PKIX, ASN.1 DER form.
+	PublicKey []byte
+}
+
+// matches returns true if the given name is the name or the key id of the
+// key.
+func (k *Key) matches(name string) bool {
+	return k.Name == name || (k.KeyID != "" && k.KeyID == name)
+}
+
+// SignRequest is the request to sign a digest with a key in the agent.
+type SignRequest struct {
+	Name   string
+	Digest []byte
+	Hash   crypto.Hash
+	// PSS and SaltLength are set to sign using RSA-PSS.
+	PSS        bool
+	SaltLength int
+}
+
+// Server is the step agent. It serves the keys added with Add to the clients
+// connected to the listener passed to Serve.
+type Server struct {
+	mu       sync.RWMutex
+	keys     []*Key
+	signers  map[string]crypto.Signer
+	listener net.Listener
+	closed   bool
+}
+
+// NewServer creates a new agent without keys.
+func NewServer() *Server {
+	return &Server{
+		signers: make(map[string]crypto.Signer),
+	}
+}
+
+// Add adds a key to the agent. The public key of k is set from the signer.
+func (s *Server) Add(k Key, signer crypto.Signer) error {
+	if k.Name == "" {
+		return errors.New("key name cannot be empty")
+	}
+	b, err := pemutil.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling public key of %s", k.Name)
+	}
+	k.PublicKey = b
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.signers[k.Name]; ok {
+		return errors.Errorf("key %s is already loaded", k.Name)
+	}
+	s.keys = append(s.keys, &k)
+	s.signers[k.Name] = signer
+	return nil
+}
+
+// Keys returns the keys in the agent.
+func (s *Server) Keys() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]Key, len(s.keys))
+	for i, k := range s.keys {
+		keys[i] = *k
+	}
+	return keys
+}
+
+// signer returns the signer of the key with the given name or key id.
+func (s *Server) signer(name string) (crypto.Signer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.matches(name) {
+			return s.signers[k.Name], nil
+		}
+	}
+	return nil, errors.Errorf("key %s not found in step agent", name)
+}
+
+// Serve accepts connections on the listener until Close is called. In the
+// platforms that support it, the connections from processes of other users
+// are closed.
+func (s *Server) Serve(l net.Listener) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(serviceName, &service{server: s}); err != nil {
+		return errors.Wrap(err, "error registering agent service")
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return l.Close()
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.RLock()
+			closed := s.closed
+			s.mu.RUnlock()
+			if closed {
+				return nil
+			}
+			return errors.Wrap(err, "error accepting connection")
+		}
+		if err := checkPeer(conn); err != nil {
+			conn.Close()
+			continue
+		}
+		go srv.ServeConn(conn)
+	}
+}
+
+// Close stops the agent.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.listener == nil {
+		s.closed = true
+		return nil
+	}
+	s.closed = true
+	return s.listener.Close()
+}
+
+// service implements the RPC methods of the agent.
+type service struct {
+	server *Server
+}
+
+// List returns the keys in the agent.
+func (s *service) List(_ struct{}, reply *[]Key) error {
+	*reply = s.server.Keys()
+	return nil
+}
+
+// Sign signs a digest with a key in the agent.
+func (s *service) Sign(req SignRequest, reply *[]byte) error {
+	signer, err := s.server.signer(req.Name)
+	if err != nil {
+		return err
+	}
+	var opts crypto.SignerOpts = req.Hash
+	if req.PSS {
+		opts = &rsa.PSSOptions{SaltLength: req.SaltLength, Hash: req.Hash}
+	}
+	sig, err := signer.Sign(rand.Reader, req.Digest, opts)
+	if err != nil {
+		return err
+	}
+	*reply = sig
+	return nil
+}
+
+// Stop stops the agent. The agent stops after replying.
+func (s *service) Stop(_ struct{}, _ *struct{}) error {
+	go s.server.Close()
+	return nil
+}
+
+// Client is a connection to the step agent.
+type Client struct {
+	client *rpc.Client
+}
+
+// Dial connects to the agent listening in the given socket.
+func Dial(socket string) (*Client, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, errors.Wrap(err, "error connecting to step agent")
+	}
+	return &Client{client: rpc.NewClient(conn)}, nil
+}
+
+// List returns the keys in the agent.
+func (c *Client) List() ([]Key, error) {
+	var keys []Key
+	if err := c.client.Call(serviceName+".List", struct{}{}, &keys); err != nil {
+		return nil, errors.Wrap(err, "error listing step agent keys")
+	}
+	return keys, nil
+}
+
+// Stop stops the agent.
+func (c *Client) Stop() error {
+	return errors.Wrap(c.client.Call(serviceName+".Stop", struct{}{}, &struct{}{}), "error stopping step agent")
+}
+
+// Signer returns a signer for the key with the given name or key id.
+func (c *Client) Signer(name string) (*Signer, error) {
+	keys, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		if !keys[i].matches(name) {
+			continue
+		}
+		pub, err := pemutil.ParsePKIXPublicKey(keys[i].PublicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing step agent key")
+		}
+		return &Signer{
+			client:    c,
+			key:       keys[i],
+			publicKey: pub,
+		}, nil
+	}
+	return nil, errors.Errorf("key %s not found in step agent", name)
+}
+
+// Close closes the connection with the agent.
+func (c *Client) Close() error {
+	return errors.Wrap(c.client.Close(), "error closing step agent connection")
+}
+
+// Signer implements crypto.Signer using a key in the step agent.
+type Signer struct {
+	client    *Client
+	key       Key
+	publicKey crypto.PublicKey
+}
+
+// NewSigner creates a new Signer for the key referenced by the given URI. The
+// agent is located using SocketPath.
+func NewSigner(rawuri string) (*Signer, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != Scheme || u.Name == "" {
+		return nil, errors.Errorf("invalid step agent key %s", rawuri)
+	}
+	c, err := Dial(SocketPath())
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.Signer(u.Name)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Lookup returns a signer for the key with the given name or key id if the
+// agent is running and has the key. It returns nil if the agent is not
+// running or doesn't have the key, so the caller can load the key itself.
+func Lookup(name string) *Signer {
+	socket := SocketPath()
+	if _, err := os.Stat(socket); err != nil {
+		return nil
+	}
+	c, err := Dial(socket)
+	if err != nil {
+		return nil
+	}
+	s, err := c.Signer(name)
+	if err != nil {
+		c.Close()
+		return nil
+	}
+	return s
+}
+
+// Key returns the public information of the key.
+func (s *Signer) Key() Key {
+	return s.key
+}
+
+// Public returns the public key of the signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs the given digest with the key in the agent.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := SignRequest{
+		Name:   s.key.Name,
+		Digest: digest,
+		Hash:   opts.HashFunc(),
+	}
+	if o, ok := opts.(*rsa.PSSOptions); ok {
+		req.PSS = true
+		req.SaltLength = o.SaltLength
+	}
+	var sig []byte
+	if err := s.client.client.Call(serviceName+".Sign", req, &sig); err != nil {
+		return nil, errors.Wrap(err, "error signing with step agent")
+	}
+	return sig, nil
+}
+
+// Close closes the connection with the agent.
+func (s *Signer) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,177 @@
+package stepagent
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+type agentTest struct {
+	socket string
+	keys   map[string]crypto.Signer
+	done   chan error
+	close  func()
+}
+
+func startServer(t *testing.T) *agentTest {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keys := map[string]crypto.Signer{
+		"/keys/ec.json": ecKey,
+		"/keys/rsa.pem": rsaKey,
+		"/keys/ed.json": edKey,
+	}
+	srv := NewServer()
+	require.NoError(t, srv.Add(Key{Name: "/keys/ec.json", KeyID: "ec-kid", Algorithm: "ES256"}, ecKey))
+	require.NoError(t, srv.Add(Key{Name: "/keys/rsa.pem", Algorithm: "PS256"}, rsaKey))
+	require.NoError(t, srv.Add(Key{Name: "/keys/ed.json", KeyID: "ed-kid", Algorithm: "EdDSA"}, edKey))
+	require.Error(t, srv.Add(Key{Name: "/keys/ec.json"}, ecKey))
+	require.Error(t, srv.Add(Key{}, ecKey))
+
+	dir, err := ioutil.TempDir("", "stepagent")
+	require.NoError(t, err)
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(l)
+	}()
+
+	return &agentTest{
+		socket: socket,
+		keys:   keys,
+		done:   done,
+		close: func() {
+			srv.Close()
+			os.RemoveAll(dir)
+		},
+	}
+}
+
+func verifyECDSA(pub *ecdsa.PublicKey, digest, sig []byte) bool {
+	var esig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(sig, &esig); err != nil {
+		return false
+	}
+	return ecdsa.Verify(pub, digest, esig.R, esig.S)
+}
+
+func TestClient_List(t *testing.T) {
+	at := startServer(t)
+	defer at.close()
+	c, err := Dial(at.socket)
+	require.NoError(t, err)
+	defer c.Close()
+
+	keys, err := c.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	require.Equal(t, "/keys/ec.json", keys[0].Name)
+	require.Equal(t, "ec-kid", keys[0].KeyID)
+	require.Equal(t, "ES256", keys[0].Algorithm)
+	require.NotEmpty(t, keys[0].PublicKey)
+}
+
+func TestClient_Signer(t *testing.T) {
+	at := startServer(t)
+	defer at.close()
+	c, err := Dial(at.socket)
+	require.NoError(t, err)
+	defer c.Close()
+
+	message := []byte("the message")
+	digest := sha256.Sum256(message)
+
+	// Select by name
+	s, err := c.Signer("/keys/ec.json")
+	require.NoError(t, err)
+	require.Equal(t, at.keys["/keys/ec.json"].Public(), s.Public())
+	sig, err := s.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	require.True(t, verifyECDSA(s.Public().(*ecdsa.PublicKey), digest[:], sig))
+
+	// Select by key id
+	s, err = c.Signer("ed-kid")
+	require.NoError(t, err)
+	sig, err = s.Sign(rand.Reader, message, crypto.Hash(0))
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(s.Public().(ed25519.PublicKey), message, sig))
+
+	// RSA-PSS
+	s, err = c.Signer("/keys/rsa.pem")
+	require.NoError(t, err)
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	sig, err = s.Sign(rand.Reader, digest[:], opts)
+	require.NoError(t, err)
+	require.NoError(t, rsa.VerifyPSS(s.Public().(*rsa.PublicKey), crypto.SHA256, digest[:], sig, opts))
+
+	_, err = c.Signer("missing")
+	require.Error(t, err)
+}
+
+func TestClient_Stop(t *testing.T) {
+	at := startServer(t)
+	defer at.close()
+	c, err := Dial(at.socket)
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.Stop())
+	select {
+	case err := <-at.done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("step agent did not stop")
+	}
+}
+
+func TestListen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stepagent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := Listen(socket)
+	require.NoError(t, err)
+	defer l.Close()
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(socket)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	}
+
+	// Connections from the same user are accepted
+	go func() {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, checkPeer(conn))
+}
@@ -0,0 +1,52 @@
+// Package uri implements the parsing of the key URIs used by the kms
+// packages.
+package uri
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// URI is a parsed key URI in the form scheme:name?key=value&key=value.
+type URI struct {
+	Scheme  string
+	Name    string
+	Options map[string]string
+}
+
+// Parse parses a key URI. The name of the key is not unescaped, so it can
+// contain any character but '?'.
+func Parse(s string) (*URI, error) {
+	i := strings.Index(s, ":")
+	if i <= 0 {
+		return nil, errors.Errorf("invalid key uri %s", s)
+	}
+
+	u := &URI{
+		Scheme:  strings.ToLower(s[:i]),
+		Name:    s[i+1:],
+		Options: make(map[string]string),
+	}
+	if i := strings.Index(u.Name, "?"); i >= 0 {
+		for _, kv := range strings.Split(u.Name[i+1:], "&") {
+			if kv == "" {
+				continue
+			}
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) == 2 {
+				u.Options[parts[0]] = parts[1]
+			} else {
+				u.Options[parts[0]] = ""
+			}
+		}
+		u.Name = u.Name[:i]
+	}
+	return u, nil
+}
+
+// Get returns the value of the given option, or an empty string if it's not
+// set.
+func (u *URI) Get(key string) string {
+	return u.Options[key]
+}
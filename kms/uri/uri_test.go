@@ -0,0 +1,36 @@
+package uri
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+		want *URI
+		err  bool
+	}{
+		{"ok", "awskms:alias/my-key", &URI{"awskms", "alias/my-key", map[string]string{}}, false},
+		{"ok options", "AWSKMS:alias/my-key?region=us-west-2&profile=prod", &URI{"awskms", "alias/my-key", map[string]string{
+			"region":  "us-west-2",
+			"profile": "prod",
+		}}, false},
+		{"ok arn", "awskms:arn:aws:kms:us-east-1:123456789012:key/1234", &URI{"awskms", "arn:aws:kms:us-east-1:123456789012:key/1234", map[string]string{}}, false},
+		{"ok flag", "cloudkms:foo?insecure", &URI{"cloudkms", "foo", map[string]string{"insecure": ""}}, false},
+		{"fail", "foo.key", nil, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.uri)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
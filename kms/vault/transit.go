@@ -0,0 +1,157 @@
+package vault
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms/uri"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Signer implements crypto.Signer using a key in the Transit secrets engine.
+type Signer struct {
+	client    logical
+	mount     string
+	name      string
+	publicKey crypto.PublicKey
+}
+
+// NewSigner creates a new Signer for the Transit key referenced by the given
+// URI, e.g. vault:transit/keys/my-key.
+func NewSigner(rawuri string) (*Signer, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != Scheme {
+		return nil, errors.Errorf("invalid Vault key %s", rawuri)
+	}
+	mount, name, ok := transitPath(u.Name)
+	if !ok {
+		return nil, errors.Errorf("invalid Vault key %s: the path must be in the form <mount>/keys/<name>", rawuri)
+	}
+
+	client, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return newSigner(client.Logical(), mount, name)
+}
+
+func newSigner(client logical, mount, name string) (*Signer, error) {
+	path := mount + "/keys/" + name
+	secret, err := client.Read(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s from Vault", path)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.Errorf("error reading %s from Vault: key not found", path)
+	}
+
+	version := fmt.Sprint(secret.Data["latest_version"])
+	keys, _ := secret.Data["keys"].(map[string]interface{})
+	key, _ := keys[version].(map[string]interface{})
+	publicKey, _ := key["public_key"].(string)
+	if publicKey == "" {
+		return nil, errors.Errorf("key %s cannot be used for signing", path)
+	}
+
+	pub, err := parsePublicKey(fmt.Sprint(secret.Data["type"]), publicKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing public key of %s", path)
+	}
+	return &Signer{
+		client:    client,
+		mount:     mount,
+		name:      name,
+		publicKey: pub,
+	}, nil
+}
+
+// parsePublicKey parses the public key returned by Vault. Ed25519 keys are
+// base64 encoded, and the rest of the keys use the PEM format.
+func parsePublicKey(typ, s string) (crypto.PublicKey, error) {
+	if typ == "ed25519" {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(b), nil
+	}
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("invalid PEM public key")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// Public returns the public key of the signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs the given digest using the Transit key. Ed25519 keys sign the
+// full message.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	data := map[string]interface{}{
+		"input": base64.StdEncoding.EncodeToString(digest),
+	}
+	if _, ok := s.publicKey.(ed25519.PublicKey); !ok {
+		alg, err := hashAlgorithm(opts.HashFunc())
+		if err != nil {
+			return nil, err
+		}
+		data["prehashed"] = true
+		data["hash_algorithm"] = alg
+		if _, ok := s.publicKey.(*rsa.PublicKey); ok {
+			if _, ok := opts.(*rsa.PSSOptions); ok {
+				data["signature_algorithm"] = "pss"
+			} else {
+				data["signature_algorithm"] = "pkcs1v15"
+			}
+		}
+	}
+
+	path := s.mount + "/sign/" + s.name
+	secret, err := s.client.Write(path, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error signing with %s", s.name)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.Errorf("error signing with %s: empty response", s.name)
+	}
+
+	// The signature has the format vault:v<version>:<base64-signature>.
+	sig, _ := secret.Data["signature"].(string)
+	parts := strings.Split(sig, ":")
+	if len(parts) != 3 {
+		return nil, errors.Errorf("error signing with %s: invalid signature", s.name)
+	}
+	return base64.StdEncoding.DecodeString(parts[2])
+}
+
+// hashAlgorithm returns the name of the hash algorithm used by Vault.
+func hashAlgorithm(h crypto.Hash) (string, error) {
+	switch h {
+	case crypto.SHA224:
+		return "sha2-224", nil
+	case crypto.SHA256:
+		return "sha2-256", nil
+	case crypto.SHA384:
+		return "sha2-384", nil
+	case crypto.SHA512:
+		return "sha2-512", nil
+	default:
+		return "", errors.Errorf("unsupported hash function %v", h)
+	}
+}
@@ -0,0 +1,65 @@
+package vault
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	b, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b})
+
+	client := &mockLogical{
+		secrets: map[string]*api.Secret{
+			"transit/keys/my-key": {Data: map[string]interface{}{
+				"type":           "ecdsa-p256",
+				"latest_version": json.Number("2"),
+				"keys": map[string]interface{}{
+					"1": map[string]interface{}{"public_key": "old"},
+					"2": map[string]interface{}{"public_key": string(publicKey)},
+				},
+			}},
+		},
+	}
+	client.write = func(path string, data map[string]interface{}) (*api.Secret, error) {
+		require.Equal(t, "transit/sign/my-key", path)
+		require.Equal(t, true, data["prehashed"])
+		require.Equal(t, "sha2-256", data["hash_algorithm"])
+		digest, err := base64.StdEncoding.DecodeString(data["input"].(string))
+		require.NoError(t, err)
+		sig, err := key.Sign(rand.Reader, digest, crypto.SHA256)
+		require.NoError(t, err)
+		return &api.Secret{Data: map[string]interface{}{
+			"signature": "vault:v2:" + base64.StdEncoding.EncodeToString(sig),
+		}}, nil
+	}
+
+	signer, err := newSigner(client, "transit", "my-key")
+	require.NoError(t, err)
+	require.Equal(t, key.Public(), signer.Public())
+
+	digest := sha256.Sum256([]byte("message"))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	require.NotEmpty(t, sig)
+
+	_, err = signer.Sign(rand.Reader, digest[:], crypto.SHA1)
+	require.Error(t, err)
+
+	_, err = newSigner(client, "transit", "missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,136 @@
+// Package vault implements the access to secrets and keys stored in HashiCorp
+// Vault. Secrets are read from and written to the KV secrets engine, and keys
+// in the Transit secrets engine can be used to sign without exposing the key
+// material.
+//
+// The address of the server and the token are configured using the standard
+// Vault environment variables, like VAULT_ADDR, VAULT_TOKEN or VAULT_CACERT.
+package vault
+
+import (
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms/uri"
+)
+
+// Scheme is the scheme of the URIs of Vault keys, e.g.
+// vault:transit/keys/my-key for a key in the Transit engine or
+// vault:secret/data/step/my-key?field=key for a PEM key stored in KV.
+const Scheme = "vault"
+
+// DefaultField is the name of the field used to read secrets when none is
+// specified.
+const DefaultField = "key"
+
+// NewClient returns a Vault client configured using the Vault environment
+// variables.
+func NewClient() (*api.Client, error) {
+	config := api.DefaultConfig()
+	if config.Error != nil {
+		return nil, errors.Wrap(config.Error, "error configuring Vault client")
+	}
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating Vault client")
+	}
+	if client.Token() == "" {
+		return nil, errors.New("error creating Vault client: VAULT_TOKEN is not set")
+	}
+	return client, nil
+}
+
+// IsTransit returns true if the given URI references a key in the Transit
+// secrets engine. Keys are considered to be in the Transit engine if the path
+// is in the form transit/keys/<name>, or if the URI option engine=transit is
+// used for a different mount point, e.g. vault:my-transit/keys/my-key?engine=transit.
+func IsTransit(u *uri.URI) bool {
+	switch u.Get("engine") {
+	case "transit":
+		return true
+	case "":
+		_, _, ok := transitPath(u.Name)
+		return ok && strings.HasPrefix(u.Name, "transit/")
+	default:
+		return false
+	}
+}
+
+// transitPath splits a path in the form <mount>/keys/<name>.
+func transitPath(path string) (mount, name string, ok bool) {
+	i := strings.LastIndex(path, "/keys/")
+	if i <= 0 || strings.Contains(path[i+6:], "/") || path[i+6:] == "" {
+		return "", "", false
+	}
+	return path[:i], path[i+6:], true
+}
+
+// isKVv2 returns true if the path is an API path of the version 2 of the KV
+// secrets engine, e.g. secret/data/my-secret.
+func isKVv2(path string) bool {
+	parts := strings.SplitN(path, "/", 3)
+	return len(parts) == 3 && parts[1] == "data"
+}
+
+// ReadSecret reads the given field of a secret stored in the KV secrets
+// engine. The path of secrets in the version 2 of the engine must include the
+// data prefix, e.g. secret/data/my-secret.
+func ReadSecret(path, field string) ([]byte, error) {
+	client, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return readSecret(client.Logical(), path, field)
+}
+
+// logical is the interface of the client used to read and write secrets.
+type logical interface {
+	Read(path string) (*api.Secret, error)
+	Write(path string, data map[string]interface{}) (*api.Secret, error)
+}
+
+func readSecret(client logical, path, field string) ([]byte, error) {
+	if field == "" {
+		field = DefaultField
+	}
+	secret, err := client.Read(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s from Vault", path)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.Errorf("error reading %s from Vault: secret not found", path)
+	}
+	data := secret.Data
+	if isKVv2(path) {
+		if data, _ = data["data"].(map[string]interface{}); data == nil {
+			return nil, errors.Errorf("error reading %s from Vault: secret not found", path)
+		}
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return nil, errors.Errorf("error reading %s from Vault: field '%s' not found", path, field)
+	}
+	return []byte(value), nil
+}
+
+// WriteSecret writes the given fields in a secret stored in the KV secrets
+// engine. The path of secrets in the version 2 of the engine must include the
+// data prefix, e.g. secret/data/my-secret.
+func WriteSecret(path string, data map[string]interface{}) error {
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+	return writeSecret(client.Logical(), path, data)
+}
+
+func writeSecret(client logical, path string, data map[string]interface{}) error {
+	if isKVv2(path) {
+		data = map[string]interface{}{"data": data}
+	}
+	if _, err := client.Write(path, data); err != nil {
+		return errors.Wrapf(err, "error writing %s to Vault", path)
+	}
+	return nil
+}
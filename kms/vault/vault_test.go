@@ -0,0 +1,105 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms/uri"
+	"github.com/stretchr/testify/require"
+)
+
+// mockLogical implements the logical interface.
+type mockLogical struct {
+	secrets map[string]*api.Secret
+	written map[string]map[string]interface{}
+	write   func(path string, data map[string]interface{}) (*api.Secret, error)
+}
+
+func (m *mockLogical) Read(path string) (*api.Secret, error) {
+	if path == "error" {
+		return nil, errors.New("read error")
+	}
+	return m.secrets[path], nil
+}
+
+func (m *mockLogical) Write(path string, data map[string]interface{}) (*api.Secret, error) {
+	if m.write != nil {
+		return m.write(path, data)
+	}
+	if m.written == nil {
+		m.written = make(map[string]map[string]interface{})
+	}
+	m.written[path] = data
+	return nil, nil
+}
+
+func TestIsTransit(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want bool
+	}{
+		{"vault:transit/keys/my-key", true},
+		{"vault:my-transit/keys/my-key?engine=transit", true},
+		{"vault:my-transit/keys/my-key", false},
+		{"vault:transit/keys/my-key?engine=kv", false},
+		{"vault:secret/data/my-key", false},
+		{"vault:transit/keys/", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.uri, func(t *testing.T) {
+			u, err := uri.Parse(tc.uri)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, IsTransit(u))
+		})
+	}
+}
+
+func TestReadSecret(t *testing.T) {
+	client := &mockLogical{secrets: map[string]*api.Secret{
+		"secret/v1": {Data: map[string]interface{}{"key": "v1-key", "password": "v1-pass"}},
+		"secret/data/v2": {Data: map[string]interface{}{
+			"data":     map[string]interface{}{"key": "v2-key"},
+			"metadata": map[string]interface{}{"version": 1},
+		}},
+	}}
+
+	tests := []struct {
+		path, field, want string
+		err               bool
+	}{
+		{"secret/v1", "", "v1-key", false},
+		{"secret/v1", "password", "v1-pass", false},
+		{"secret/data/v2", "key", "v2-key", false},
+		{"secret/v1", "missing", "", true},
+		{"secret/missing", "", "", true},
+		{"error", "", "", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path+"#"+tc.field, func(t *testing.T) {
+			got, err := readSecret(client, tc.path, tc.field)
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.want, string(got))
+			}
+		})
+	}
+}
+
+func TestWriteSecret(t *testing.T) {
+	client := &mockLogical{}
+	data := map[string]interface{}{"certificate": "crt"}
+
+	require.NoError(t, writeSecret(client, "secret/v1", data))
+	require.Equal(t, data, client.written["secret/v1"])
+
+	require.NoError(t, writeSecret(client, "secret/data/v2", data))
+	require.Equal(t, map[string]interface{}{"data": data}, client.written["secret/data/v2"])
+
+	client.write = func(string, map[string]interface{}) (*api.Secret, error) {
+		return nil, errors.New("write error")
+	}
+	require.Error(t, writeSecret(client, "secret/v1", data))
+}
@@ -0,0 +1,158 @@
+// Package yubikey implements a crypto.Signer using keys stored in the PIV
+// slots of a YubiKey.
+package yubikey
+
+import (
+	"crypto"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-piv/piv-go/piv"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/kms/uri"
+	"github.com/smallstep/cli/ui"
+)
+
+// Scheme is the scheme of the URIs of YubiKey keys, e.g. yubikey:9a or
+// yubikey:9c?serial=12345678.
+const Scheme = "yubikey"
+
+// slots maps the names of the PIV slots to the piv-go slots.
+var slots = map[string]piv.Slot{
+	"9a": piv.SlotAuthentication,
+	"9c": piv.SlotSignature,
+	"9d": piv.SlotKeyManagement,
+	"9e": piv.SlotCardAuthentication,
+}
+
+// ParseSlot returns the PIV slot for the given name. The supported slots are
+// 9a, 9c, 9d and 9e.
+func ParseSlot(name string) (piv.Slot, error) {
+	if slot, ok := slots[strings.ToLower(name)]; ok {
+		return slot, nil
+	}
+	return piv.Slot{}, errors.Errorf("unsupported PIV slot %s: valid slots are 9a, 9c, 9d and 9e", name)
+}
+
+// Open opens the YubiKey with the given serial number. If serial is empty, it
+// will open the first YubiKey available.
+func Open(serial string) (*piv.YubiKey, error) {
+	cards, err := piv.Cards()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing smart cards")
+	}
+
+	for _, card := range cards {
+		if !strings.Contains(strings.ToLower(card), "yubikey") {
+			continue
+		}
+		yk, err := piv.Open(card)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error opening %s", card)
+		}
+		if serial == "" {
+			return yk, nil
+		}
+		if n, err := yk.Serial(); err == nil && strconv.FormatUint(uint64(n), 10) == serial {
+			return yk, nil
+		}
+		yk.Close()
+	}
+
+	if serial == "" {
+		return nil, errors.New("no YubiKey found")
+	}
+	return nil, errors.Errorf("YubiKey with serial number %s not found", serial)
+}
+
+// Signer implements crypto.Signer using a key in a YubiKey PIV slot.
+type Signer struct {
+	yk     *piv.YubiKey
+	slot   string
+	signer crypto.Signer
+}
+
+// NewSigner creates a new Signer for the key referenced by the given URI. The
+// URI options serial and pin can be used to select the YubiKey and to set the
+// PIN, e.g. yubikey:9a?serial=12345678&pin=123456. If the PIN is required and
+// not present in the URI, it will be prompted.
+func NewSigner(rawuri string) (*Signer, error) {
+	u, err := uri.Parse(rawuri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != Scheme || u.Name == "" {
+		return nil, errors.Errorf("invalid YubiKey key %s", rawuri)
+	}
+	slot, err := ParseSlot(u.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	yk, err := Open(u.Get("serial"))
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := newSigner(yk, slot, u.Name, u.Get("pin"))
+	if err != nil {
+		yk.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSigner(yk *piv.YubiKey, slot piv.Slot, name, pin string) (*Signer, error) {
+	// The public key is read from the attestation certificate, it will be
+	// available even if there's no certificate in the slot.
+	crt, err := yk.Attest(slot)
+	if err != nil {
+		if crt, err = yk.Certificate(slot); err != nil {
+			return nil, errors.Wrapf(err, "error reading the public key in slot %s", name)
+		}
+	}
+
+	auth := piv.KeyAuth{PIN: pin}
+	if pin == "" {
+		auth.PINPrompt = func() (string, error) {
+			b, err := ui.PromptPassword("Please enter the YubiKey PIN")
+			return string(b), err
+		}
+	}
+
+	priv, err := yk.PrivateKey(slot, crt.PublicKey, auth)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading the private key in slot %s", name)
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("the key in slot %s cannot be used for signing", name)
+	}
+
+	return &Signer{
+		yk:     yk,
+		slot:   name,
+		signer: signer,
+	}, nil
+}
+
+// Public returns the public key of the signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+// Sign signs the given digest using the key in the PIV slot. Depending on the
+// touch policy of the key, the YubiKey might need to be touched.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	sig, err := s.signer.Sign(rand, digest, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error signing with slot %s", s.slot)
+	}
+	return sig, nil
+}
+
+// Close closes the connection with the YubiKey.
+func (s *Signer) Close() error {
+	return errors.Wrap(s.yk.Close(), "error closing YubiKey")
+}
@@ -0,0 +1,41 @@
+package yubikey
+
+import (
+	"testing"
+
+	"github.com/go-piv/piv-go/piv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSlot(t *testing.T) {
+	tests := []struct {
+		name string
+		want piv.Slot
+		err  bool
+	}{
+		{"9a", piv.SlotAuthentication, false},
+		{"9c", piv.SlotSignature, false},
+		{"9D", piv.SlotKeyManagement, false},
+		{"9e", piv.SlotCardAuthentication, false},
+		{"f9", piv.Slot{}, true},
+		{"", piv.Slot{}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseSlot(tc.name)
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewSignerInvalid(t *testing.T) {
+	for _, uri := range []string{"yubikey:", "yubikey:9f", "awskms:9a"} {
+		_, err := NewSigner(uri)
+		require.Error(t, err, uri)
+	}
+}
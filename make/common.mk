@@ -66,7 +66,10 @@ endif
 #########################################
 
 DATE    := $(shell date -u '+%Y-%m-%d %H:%M UTC')
-LDFLAGS := -ldflags='-w -X "main.Version=$(VERSION)" -X "main.BuildTime=$(DATE)"'
+# RELEASE_KEY is the base64-encoded DER public key that signs the releases,
+# used by step update install.
+RELEASE_KEY ?=
+LDFLAGS := -ldflags='-w -X "main.Version=$(VERSION)" -X "main.BuildTime=$(DATE)" -X "github.com/smallstep/cli/command/update.releaseKey=$(RELEASE_KEY)"'
 GOFLAGS := CGO_ENABLED=0
 
 build: $(PREFIX)bin/$(BINNAME)
@@ -84,6 +87,17 @@ simple:
 
 .PHONY: build simple
 
+#########################################
+# Man pages
+#########################################
+
+MANDIR?=$(PREFIX)man/
+
+man: $(PREFIX)bin/$(BINNAME)
+	$Q $(PREFIX)bin/$(BINNAME) help --man $(MANDIR)
+
+.PHONY: man
+
 #########################################
 # Go generate
 #########################################
@@ -0,0 +1,129 @@
+// Package netconfig configures how step connects to the CA, for networks where
+// the name of the CA does not resolve normally, like split-horizon DNS or NATed
+// lab environments. The connections can be restricted to IPv4 or IPv6, the
+// name of the CA can be resolved with a specific DNS server, and the TLS server
+// name can be set independently of the host in the CA URL.
+//
+// The options are set with the global flags -4, -6, --dns-server and
+// --tls-server-name, and they are applied to the transports of the CA clients
+// by trace.Transport.
+package netconfig
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// dialTimeout is the maximum time to wait for a connection.
+	dialTimeout = 30 * time.Second
+	// keepAlive is the interval of the TCP keep-alive probes.
+	keepAlive = 30 * time.Second
+)
+
+// Options are the options of the connections to the CA.
+type Options struct {
+	// Network restricts the connections to "tcp4" or "tcp6". Both are used
+	// if it is empty.
+	Network string
+	// DNSServer is the address, host[:port], of the DNS server used to
+	// resolve the CA. The system resolver is used if it is empty.
+	DNSServer string
+	// ServerName is the name used to verify the certificate of the CA and
+	// sent in the TLS SNI extension. By default the host in the URL is used.
+	ServerName string
+}
+
+// ErrInvalidDNSServer is the error returned by Enable if the address of the
+// DNS server is not valid.
+var ErrInvalidDNSServer = errors.New("invalid DNS server")
+
+var (
+	mu       sync.Mutex
+	options  *Options
+	resolver *net.Resolver
+)
+
+// Enable validates and sets the options used by the next connections. The
+// http.DefaultTransport is also configured, except the server name.
+func Enable(opts Options) error {
+	switch opts.Network {
+	case "", "tcp4", "tcp6":
+	default:
+		return errors.Errorf("unsupported network %s", opts.Network)
+	}
+
+	r := net.DefaultResolver
+	if opts.DNSServer != "" {
+		addr := opts.DNSServer
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		if host, port, err := net.SplitHostPort(addr); err != nil || host == "" || port == "" {
+			return errors.Wrapf(ErrInvalidDNSServer, "error parsing %s", opts.DNSServer)
+		}
+		r = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+	}
+
+	mu.Lock()
+	options = &opts
+	resolver = r
+	mu.Unlock()
+
+	if tr, ok := http.DefaultTransport.(*http.Transport); ok && (opts.Network != "" || opts.DNSServer != "") {
+		tr.DialContext = DialContext
+	}
+	return nil
+}
+
+// DialContext connects to the given address using the network and DNS server
+// in the options.
+func DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	mu.Lock()
+	opts, r := options, resolver
+	mu.Unlock()
+
+	d := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: keepAlive,
+	}
+	if opts != nil {
+		if opts.Network != "" && strings.HasPrefix(network, "tcp") {
+			network = opts.Network
+		}
+		if r != net.DefaultResolver {
+			d.Resolver = r
+		}
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// Apply configures the given transport with the options. The server name is
+// only set in transports that trust specific roots, the ones used with the CA,
+// and not in the ones that use the system roots.
+func Apply(tr *http.Transport) {
+	mu.Lock()
+	opts := options
+	mu.Unlock()
+	if opts == nil {
+		return
+	}
+	if opts.Network != "" || opts.DNSServer != "" {
+		tr.DialContext = DialContext
+	}
+	if opts.ServerName != "" && tr.TLSClientConfig != nil && tr.TLSClientConfig.RootCAs != nil {
+		tr.TLSClientConfig.ServerName = opts.ServerName
+	}
+}
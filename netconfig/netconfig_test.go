@@ -0,0 +1,88 @@
+package netconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// reset returns a function that restores the default options and
+// http.DefaultTransport.
+func reset() func() {
+	tr := http.DefaultTransport.(*http.Transport)
+	dial := tr.DialContext
+	return func() {
+		mu.Lock()
+		options, resolver = nil, nil
+		mu.Unlock()
+		tr.DialContext = dial
+	}
+}
+
+func TestEnable(t *testing.T) {
+	defer reset()()
+	require.NoError(t, Enable(Options{Network: "tcp4"}))
+	require.NoError(t, Enable(Options{Network: "tcp6", DNSServer: "10.0.0.2"}))
+	require.NoError(t, Enable(Options{DNSServer: "[fd00::2]:5353"}))
+	require.NoError(t, Enable(Options{ServerName: "ca.internal"}))
+	require.Error(t, Enable(Options{Network: "udp"}))
+	require.Error(t, Enable(Options{DNSServer: ":53"}))
+	require.Equal(t, ErrInvalidDNSServer, errors.Cause(Enable(Options{DNSServer: "10.0.0.2:"})))
+}
+
+func TestApply(t *testing.T) {
+	defer reset()()
+
+	// Without options the transport is not modified
+	tr := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: x509.NewCertPool()}}
+	Apply(tr)
+	require.Nil(t, tr.DialContext)
+	require.Equal(t, "", tr.TLSClientConfig.ServerName)
+
+	require.NoError(t, Enable(Options{ServerName: "ca.internal"}))
+	Apply(tr)
+	require.Nil(t, tr.DialContext)
+	require.Equal(t, "ca.internal", tr.TLSClientConfig.ServerName)
+
+	// The server name is not used with the system roots
+	system := &http.Transport{TLSClientConfig: &tls.Config{}}
+	Apply(system)
+	require.Equal(t, "", system.TLSClientConfig.ServerName)
+
+	require.NoError(t, Enable(Options{Network: "tcp4"}))
+	tr = &http.Transport{}
+	Apply(tr)
+	require.NotNil(t, tr.DialContext)
+	require.Nil(t, tr.TLSClientConfig)
+}
+
+func TestDialContext(t *testing.T) {
+	defer reset()()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	require.NoError(t, Enable(Options{Network: "tcp4"}))
+	conn, err := DialContext(context.Background(), "tcp", ln.Addr().String())
+	require.NoError(t, err)
+	conn.Close()
+
+	require.NoError(t, Enable(Options{Network: "tcp6"}))
+	_, err = DialContext(context.Background(), "tcp", ln.Addr().String())
+	require.Error(t, err)
+}
@@ -0,0 +1,158 @@
+package nssdb
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+)
+
+// PKCS #11 and NSS object classes.
+const (
+	ckoCertificate = 0x00000001
+	ckoNSSTrust    = 0xce534353
+)
+
+// PKCS #11 and NSS attribute types.
+const (
+	ckaClass              = 0x00000000
+	ckaToken              = 0x00000001
+	ckaPrivate            = 0x00000002
+	ckaLabel              = 0x00000003
+	ckaValue              = 0x00000011
+	ckaCertificateType    = 0x00000080
+	ckaIssuer             = 0x00000081
+	ckaSerialNumber       = 0x00000082
+	ckaSubject            = 0x00000101
+	ckaID                 = 0x00000102
+	ckaModifiable         = 0x00000170
+	ckaTrustServerAuth    = 0xce536358
+	ckaTrustClientAuth    = 0xce536359
+	ckaTrustCodeSigning   = 0xce53635a
+	ckaTrustEmailProtect  = 0xce53635b
+	ckaTrustStepUpApprove = 0xce536360
+	ckaCertSHA1Hash       = 0xce5363b4
+	ckaCertMD5Hash        = 0xce5363b5
+)
+
+// PKCS #11 certificate types and NSS trust values.
+const (
+	ckcX509                = 0x00000000
+	cktNSSTrustedDelegator = 0xce534352
+	cktNSSMustVerifyTrust  = 0xce534353
+)
+
+// attribute is a PKCS #11 attribute with its value encoded as it's stored in
+// the database.
+type attribute struct {
+	typ   uint32
+	value []byte
+}
+
+// column returns the name of the column of the attribute in the nssPublic
+// table.
+func (a attribute) column() string {
+	return fmt.Sprintf("a%x", a.typ)
+}
+
+// isAuthenticated returns true if the attribute value must be signed using
+// the key database.
+func (a attribute) isAuthenticated() bool {
+	switch a.typ {
+	case ckaCertSHA1Hash, ckaCertMD5Hash, ckaTrustServerAuth, ckaTrustClientAuth,
+		ckaTrustEmailProtect, ckaTrustCodeSigning, ckaTrustStepUpApprove:
+		return true
+	default:
+		return false
+	}
+}
+
+// ulong encodes a CK_ULONG as it's stored in the database, a 4 bytes big
+// endian integer.
+func ulong(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func boolean(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// serialNumber returns the DER encoding of the serial number of the
+// certificate.
+func serialNumber(crt *x509.Certificate) ([]byte, error) {
+	return asn1.Marshal(crt.SerialNumber)
+}
+
+// keyID returns the CKA_ID of the certificate, the subject key identifier or
+// the SHA-1 of the public key.
+func keyID(crt *x509.Certificate) []byte {
+	if len(crt.SubjectKeyId) > 0 {
+		return crt.SubjectKeyId
+	}
+	var spki struct {
+		Algorithm asn1.RawValue
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(crt.RawSubjectPublicKeyInfo, &spki); err != nil {
+		sum := sha1.Sum(crt.RawSubjectPublicKeyInfo)
+		return sum[:]
+	}
+	sum := sha1.Sum(spki.PublicKey.Bytes)
+	return sum[:]
+}
+
+// certificateAttributes returns the attributes of the certificate object.
+func certificateAttributes(crt *x509.Certificate, label string) ([]attribute, error) {
+	serial, err := serialNumber(crt)
+	if err != nil {
+		return nil, err
+	}
+	return []attribute{
+		{ckaClass, ulong(ckoCertificate)},
+		{ckaToken, boolean(true)},
+		{ckaPrivate, boolean(false)},
+		{ckaModifiable, boolean(true)},
+		{ckaLabel, []byte(label)},
+		{ckaCertificateType, ulong(ckcX509)},
+		{ckaSubject, crt.RawSubject},
+		{ckaIssuer, crt.RawIssuer},
+		{ckaSerialNumber, serial},
+		{ckaID, keyID(crt)},
+		{ckaValue, crt.Raw},
+	}, nil
+}
+
+// trustAttributes returns the attributes of the trust object of a root
+// certificate trusted to issue server certificates, the same trust set by
+// 'certutil -t C,,'.
+func trustAttributes(crt *x509.Certificate, label string) ([]attribute, error) {
+	serial, err := serialNumber(crt)
+	if err != nil {
+		return nil, err
+	}
+	sha1Hash := sha1.Sum(crt.Raw)
+	md5Hash := md5.Sum(crt.Raw)
+	return []attribute{
+		{ckaClass, ulong(ckoNSSTrust)},
+		{ckaToken, boolean(true)},
+		{ckaPrivate, boolean(false)},
+		{ckaModifiable, boolean(true)},
+		{ckaLabel, []byte(label)},
+		{ckaIssuer, crt.RawIssuer},
+		{ckaSerialNumber, serial},
+		{ckaCertSHA1Hash, sha1Hash[:]},
+		{ckaCertMD5Hash, md5Hash[:]},
+		{ckaTrustServerAuth, ulong(cktNSSTrustedDelegator)},
+		{ckaTrustClientAuth, ulong(cktNSSMustVerifyTrust)},
+		{ckaTrustEmailProtect, ulong(cktNSSMustVerifyTrust)},
+		{ckaTrustCodeSigning, ulong(cktNSSMustVerifyTrust)},
+		{ckaTrustStepUpApprove, boolean(false)},
+	}, nil
+}
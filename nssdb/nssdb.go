@@ -0,0 +1,251 @@
+// Package nssdb implements the installation of root certificates in the NSS
+// databases used by Firefox and by Chromium on Linux without the certutil
+// tool. Only the SQLite databases, cert9.db and key4.db, are supported, and
+// the key database must not be protected with a primary password.
+package nssdb
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// Register the sqlite3 driver.
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// Database file names.
+const (
+	CertDBFile = "cert9.db"
+	KeyDBFile  = "key4.db"
+)
+
+// objectIDMask is the mask applied to the random object ids, the same used by
+// NSS.
+const objectIDMask = 0x3fffffff
+
+// DB is an NSS database, formed by a certificate and a key database.
+type DB struct {
+	dir    string
+	certDB *sql.DB
+	keyDB  *sql.DB
+}
+
+// Open opens the NSS database in the given directory.
+func Open(dir string) (*DB, error) {
+	for _, name := range []string{CertDBFile, KeyDBFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return nil, errors.Wrapf(err, "error opening NSS database %s", dir)
+		}
+	}
+	certDB, err := sql.Open("sqlite3", filepath.Join(dir, CertDBFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening %s", filepath.Join(dir, CertDBFile))
+	}
+	keyDB, err := sql.Open("sqlite3", filepath.Join(dir, KeyDBFile))
+	if err != nil {
+		certDB.Close()
+		return nil, errors.Wrapf(err, "error opening %s", filepath.Join(dir, KeyDBFile))
+	}
+	return &DB{
+		dir:    dir,
+		certDB: certDB,
+		keyDB:  keyDB,
+	}, nil
+}
+
+// Dir returns the directory of the database.
+func (db *DB) Dir() string {
+	return db.dir
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	err := db.certDB.Close()
+	if e := db.keyDB.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Contains returns true if the certificate is in the database.
+func (db *DB) Contains(crt *x509.Certificate) (bool, error) {
+	ids, err := db.certificateIDs(crt)
+	if err != nil {
+		return false, err
+	}
+	return len(ids) > 0, nil
+}
+
+// Install adds the certificate to the database with the given label and
+// trusts it to issue server certificates. Nothing is done if the
+// certificate is already in the database.
+func (db *DB) Install(crt *x509.Certificate, label string) error {
+	ok, err := db.Contains(crt)
+	if err != nil || ok {
+		return err
+	}
+
+	passKey, err := db.passwordKey()
+	if err != nil {
+		return err
+	}
+	certAttrs, err := certificateAttributes(crt, label)
+	if err != nil {
+		return err
+	}
+	trustAttrs, err := trustAttributes(crt, label)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.certDB.Begin()
+	if err != nil {
+		return errors.Wrap(err, "error starting transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := insertObject(tx, certAttrs); err != nil {
+		return err
+	}
+	trustID, err := insertObject(tx, trustAttrs)
+	if err != nil {
+		return err
+	}
+
+	// The signatures of the authenticated attributes are stored in the key
+	// database.
+	for _, a := range trustAttrs {
+		if !a.isAuthenticated() {
+			continue
+		}
+		sig, err := signAttribute(passKey, trustID, a)
+		if err != nil {
+			return err
+		}
+		if _, err := db.keyDB.Exec("INSERT OR REPLACE INTO metaData (id, item1) VALUES (?, ?)", signatureID(trustID, a.typ), sig); err != nil {
+			return errors.Wrap(err, "error storing attribute signature")
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "error committing transaction")
+}
+
+// Uninstall removes the certificate and its trust from the database.
+func (db *DB) Uninstall(crt *x509.Certificate) error {
+	certIDs, err := db.certificateIDs(crt)
+	if err != nil {
+		return err
+	}
+	sum := sha1.Sum(crt.Raw)
+	trustIDs, err := db.objectIDs(attribute{ckaClass, ulong(ckoNSSTrust)}, attribute{ckaCertSHA1Hash, sum[:]})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range append(certIDs, trustIDs...) {
+		if _, err := db.certDB.Exec("DELETE FROM nssPublic WHERE id = ?", id); err != nil {
+			return errors.Wrap(err, "error deleting certificate")
+		}
+		if _, err := db.keyDB.Exec("DELETE FROM metaData WHERE id LIKE ?", fmt.Sprintf("sig_cert_%08x_%%", id)); err != nil {
+			return errors.Wrap(err, "error deleting attribute signatures")
+		}
+	}
+	return nil
+}
+
+func (db *DB) certificateIDs(crt *x509.Certificate) ([]uint32, error) {
+	return db.objectIDs(attribute{ckaClass, ulong(ckoCertificate)}, attribute{ckaValue, crt.Raw})
+}
+
+// objectIDs returns the ids of the objects with the given attributes.
+func (db *DB) objectIDs(attrs ...attribute) ([]uint32, error) {
+	where := make([]string, len(attrs))
+	args := make([]interface{}, len(attrs))
+	for i, a := range attrs {
+		where[i] = a.column() + " = ?"
+		args[i] = a.value
+	}
+	rows, err := db.certDB.Query("SELECT id FROM nssPublic WHERE "+strings.Join(where, " AND "), args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", filepath.Join(db.dir, CertDBFile))
+	}
+	defer rows.Close()
+
+	var ids []uint32
+	for rows.Next() {
+		var id uint32
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrapf(err, "error reading %s", filepath.Join(db.dir, CertDBFile))
+		}
+		ids = append(ids, id)
+	}
+	return ids, errors.Wrapf(rows.Err(), "error reading %s", filepath.Join(db.dir, CertDBFile))
+}
+
+// passwordKey returns the key derived from the global salt and the empty
+// password.
+func (db *DB) passwordKey() ([]byte, error) {
+	var globalSalt []byte
+	if err := db.keyDB.QueryRow("SELECT item1 FROM metaData WHERE id = 'password'").Scan(&globalSalt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.Errorf("error reading %s: the database is not initialized", filepath.Join(db.dir, KeyDBFile))
+		}
+		return nil, errors.Wrapf(err, "error reading %s", filepath.Join(db.dir, KeyDBFile))
+	}
+	return passwordKey(globalSalt, nil), nil
+}
+
+// insertObject inserts an object with a new random id in the nssPublic table.
+func insertObject(tx *sql.Tx, attrs []attribute) (uint32, error) {
+	id, err := newObjectID(tx)
+	if err != nil {
+		return 0, err
+	}
+	columns := []string{"id"}
+	values := []string{"?"}
+	args := []interface{}{id}
+	for _, a := range attrs {
+		columns = append(columns, a.column())
+		values = append(values, "?")
+		args = append(args, a.value)
+	}
+	query := fmt.Sprintf("INSERT INTO nssPublic (%s) VALUES (%s)", strings.Join(columns, ", "), strings.Join(values, ", "))
+	if _, err := tx.Exec(query, args...); err != nil {
+		return 0, errors.Wrap(err, "error inserting certificate")
+	}
+	return id, nil
+}
+
+// newObjectID returns a random object id not used in the nssPublic table.
+func newObjectID(tx *sql.Tx) (uint32, error) {
+	b := make([]byte, 4)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return 0, errors.Wrap(err, "error generating object id")
+		}
+		id := binary.BigEndian.Uint32(b) & objectIDMask
+		if id == 0 {
+			continue
+		}
+		var n int
+		if err := tx.QueryRow("SELECT count(*) FROM nssPublic WHERE id = ?", id).Scan(&n); err != nil {
+			return 0, errors.Wrap(err, "error generating object id")
+		}
+		if n == 0 {
+			return id, nil
+		}
+	}
+}
+
+// signatureID returns the id of the signature of an attribute in the metaData
+// table of the key database.
+func signatureID(objectID, typ uint32) string {
+	return fmt.Sprintf("sig_cert_%08x_%08x", objectID, typ)
+}
@@ -0,0 +1,81 @@
+package nssdb
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/asn1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func TestFindProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nssdb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, p := range []string{
+		"firefox/abc.default/cert9.db",
+		"firefox/def.dev/cert9.db",
+		"firefox/legacy/cert8.db",
+		"nssdb/cert9.db",
+	} {
+		filename := filepath.Join(dir, p)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0700))
+		require.NoError(t, ioutil.WriteFile(filename, nil, 0600))
+	}
+
+	got := findProfiles([]string{
+		filepath.Join(dir, "firefox", "*"),
+		filepath.Join(dir, "nssdb"),
+		filepath.Join(dir, "nssdb"),
+		filepath.Join(dir, "missing", "*"),
+	})
+	require.Equal(t, []string{
+		filepath.Join(dir, "firefox", "abc.default"),
+		filepath.Join(dir, "firefox", "def.dev"),
+		filepath.Join(dir, "nssdb"),
+	}, got)
+}
+
+func TestAttributeColumn(t *testing.T) {
+	require.Equal(t, "a0", attribute{typ: ckaClass}.column())
+	require.Equal(t, "a11", attribute{typ: ckaValue}.column())
+	require.Equal(t, "a102", attribute{typ: ckaID}.column())
+	require.Equal(t, "ace536358", attribute{typ: ckaTrustServerAuth}.column())
+	require.Equal(t, []byte{0xce, 0x53, 0x43, 0x53}, ulong(ckoNSSTrust))
+}
+
+func TestSignAttribute(t *testing.T) {
+	passKey := passwordKey([]byte("salt"), nil)
+	a := attribute{ckaTrustServerAuth, ulong(cktNSSTrustedDelegator)}
+	b, err := signAttribute(passKey, 1234, a)
+	require.NoError(t, err)
+
+	var cv cipherValue
+	_, err = asn1.Unmarshal(b, &cv)
+	require.NoError(t, err)
+	require.True(t, cv.Algorithm.Algorithm.Equal(oidPBMAC1))
+
+	var params pbmac1Params
+	_, err = asn1.Unmarshal(cv.Algorithm.Parameters.FullBytes, &params)
+	require.NoError(t, err)
+	require.True(t, params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2))
+	require.True(t, params.MessageAuthScheme.Algorithm.Equal(oidHMACWithSHA256))
+
+	var kdf pbkdf2Params
+	_, err = asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf)
+	require.NoError(t, err)
+	require.Equal(t, macIterations, kdf.IterationCount)
+	require.Equal(t, macKeySize, kdf.KeyLength)
+
+	mac := hmac.New(sha256.New, pbkdf2.Key(passKey, kdf.Salt, kdf.IterationCount, kdf.KeyLength, sha256.New))
+	mac.Write(ulong(1234))
+	mac.Write(ulong(a.typ))
+	mac.Write(a.value)
+	require.Equal(t, mac.Sum(nil), cv.Value)
+}
@@ -0,0 +1,65 @@
+package nssdb
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// profilePatterns returns the glob patterns of the directories with NSS
+// databases in the given home directory. It covers Firefox and Chromium
+// installed with the system package manager, snap, and flatpak.
+func profilePatterns(home string) []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{
+			filepath.Join(home, "Library", "Application Support", "Firefox", "Profiles", "*"),
+		}
+	case "windows":
+		return []string{
+			filepath.Join(os.Getenv("APPDATA"), "Mozilla", "Firefox", "Profiles", "*"),
+		}
+	default:
+		return []string{
+			// Firefox
+			filepath.Join(home, ".mozilla", "firefox", "*"),
+			filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox", "*"),
+			filepath.Join(home, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox", "*"),
+			// Chromium and Chrome shared database
+			filepath.Join(home, ".pki", "nssdb"),
+			filepath.Join(home, "snap", "chromium", "current", ".pki", "nssdb"),
+			filepath.Join(home, ".var", "app", "org.chromium.Chromium", ".pki", "nssdb"),
+			filepath.Join(home, ".var", "app", "com.google.Chrome", ".pki", "nssdb"),
+		}
+	}
+}
+
+// Profiles returns the directories with NSS databases of the current user.
+// Only the directories with a cert9.db file are returned.
+func Profiles() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return findProfiles(profilePatterns(home))
+}
+
+func findProfiles(patterns []string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, dir := range matches {
+			if seen[dir] {
+				continue
+			}
+			if fi, err := os.Stat(filepath.Join(dir, CertDBFile)); err == nil && fi.Mode().IsRegular() {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
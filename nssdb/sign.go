@@ -0,0 +1,102 @@
+package nssdb
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	macSaltSize   = 32
+	macKeySize    = 32
+	macIterations = 10000
+)
+
+var (
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidPBMAC1         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 14}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+)
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int
+	Prf            pkix.AlgorithmIdentifier
+}
+
+type pbmac1Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	MessageAuthScheme pkix.AlgorithmIdentifier
+}
+
+// cipherValue is the structure used by NSS to store encrypted values and
+// signatures.
+type cipherValue struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Value     []byte
+}
+
+// passwordKey returns the key used by NSS to derive the encryption and
+// signing keys, the SHA-1 of the global salt and the password.
+func passwordKey(globalSalt, password []byte) []byte {
+	h := sha1.New()
+	h.Write(globalSalt)
+	h.Write(password)
+	return h.Sum(nil)
+}
+
+// signAttribute returns the signature of an attribute value using a
+// PBMAC1 with PBKDF2 and HMAC-SHA256. The signature covers the object id, the
+// attribute type, and the value.
+func signAttribute(passKey []byte, objectID uint32, a attribute) ([]byte, error) {
+	salt := make([]byte, macSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "error generating salt")
+	}
+
+	hmacSHA256 := pkix.AlgorithmIdentifier{
+		Algorithm:  oidHMACWithSHA256,
+		Parameters: asn1.NullRawValue,
+	}
+	kdf, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: macIterations,
+		KeyLength:      macKeySize,
+		Prf:            hmacSHA256,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	params, err := asn1.Marshal(pbmac1Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBKDF2,
+			Parameters: asn1.RawValue{FullBytes: kdf},
+		},
+		MessageAuthScheme: hmacSHA256,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	key := pbkdf2.Key(passKey, salt, macIterations, macKeySize, sha256.New)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(ulong(objectID))
+	mac.Write(ulong(a.typ))
+	mac.Write(a.value)
+
+	b, err := asn1.Marshal(cipherValue{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBMAC1,
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		Value: mac.Sum(nil),
+	})
+	return b, errors.WithStack(err)
+}
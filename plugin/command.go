@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/exec"
+	"github.com/urfave/cli"
+)
+
+// Commands returns a command for each plugin in the PATH that does not have
+// the name of one of the given commands. If describe is true, the plugins are
+// run to get their usage, this should only be used to show the help.
+func Commands(builtin []cli.Command, describe bool) []cli.Command {
+	var plugins []Plugin
+	for _, p := range List() {
+		if !hasCommand(builtin, p.Name) {
+			plugins = append(plugins, p)
+		}
+	}
+
+	var infos map[string]*Info
+	if describe {
+		infos = DescribeAll(plugins)
+	}
+
+	cmds := make([]cli.Command, len(plugins))
+	for i, p := range plugins {
+		usage := "external plugin " + p.Path
+		if info, ok := infos[p.Name]; ok && info.Usage != "" {
+			usage = info.Usage
+		}
+		cmds[i] = cli.Command{
+			Name:      p.Name,
+			Usage:     usage,
+			UsageText: fmt.Sprintf("**step %s** [<arguments>...]", p.Name),
+			Description: fmt.Sprintf(`**step %s** is an external plugin, it runs <%s> with the given
+arguments. Run **step %s --help** to show the help of the plugin.`, p.Name, p.Path, p.Name),
+			SkipFlagParsing: true,
+			Action:          runAction(p),
+		}
+	}
+	return cmds
+}
+
+// runAction returns the action that runs the given plugin. The action does not
+// return if the plugin starts, step exits with the exit code of the plugin.
+func runAction(p Plugin) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+		c := &Context{
+			ProtocolVersion: ProtocolVersion,
+			Name:            p.Name,
+			StepVersion:     config.Version(),
+			StepPath:        config.StepPath(),
+			BasePath:        config.BasePath(),
+			Profile:         config.Profile(),
+			ConfigFile:      command.ConfigFile(ctx),
+			ErrorFormat:     os.Getenv(errs.ErrorFormatEnv),
+		}
+		if m, err := command.ReadConfigFile(c.ConfigFile); err == nil {
+			c.CAURL, _ = m["ca-url"].(string)
+			c.Root, _ = m["root"].(string)
+		}
+
+		env, err := c.Env()
+		if err != nil {
+			return err
+		}
+		_, wait, err := exec.Start(env, p.Path, ctx.Args()...)
+		if err != nil {
+			return err
+		}
+		wait()
+		return nil
+	}
+}
+
+func hasCommand(cmds []cli.Command, name string) bool {
+	for _, cmd := range cmds {
+		if cmd.HasName(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+// Package plugin implements the discovery and execution of the external
+// commands that extend step. A plugin is any executable in the PATH named
+// step-<name>, and it runs as "step <name>", with the rest of the arguments
+// passed unmodified.
+//
+// Plugins receive the environment of step, with STEPPATH and STEPPROFILE set
+// to the ones used by step, and the environment variable STEP_PLUGIN_CONTEXT
+// with a JSON Context. A plugin must follow these conventions:
+//
+//   - Results are written to the standard output, and messages and prompts to
+//     the standard error.
+//   - If STEPERRORFORMAT is "json", errors are written to the standard error
+//     as {"error":{"code":"<category>","exitCode":<n>,"message":"<message>"}}.
+//   - Exit codes follow the ones used by step: 1 for unknown errors, 3 for
+//     usage errors, 4 for I/O errors, 5 for crypto errors, 6 for network
+//     errors, and 7 for policy errors.
+//   - When it runs with the only argument --step-plugin-info it writes a JSON
+//     Info to the standard output and exits with 0. This is used by step help
+//     to describe the plugin.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Prefix is the prefix of the name of the plugin executables.
+const Prefix = "step-"
+
+// InfoFlag is the argument used to ask a plugin to describe itself.
+const InfoFlag = "--step-plugin-info"
+
+// ContextEnv is the name of the environment variable with the JSON Context
+// passed to a plugin.
+const ContextEnv = "STEP_PLUGIN_CONTEXT"
+
+// ProtocolVersion is the version of the conventions between step and the
+// plugins.
+const ProtocolVersion = 1
+
+// infoTimeout is the maximum time a plugin has to describe itself.
+const infoTimeout = 2 * time.Second
+
+// maxInfoSize is the maximum size of the description of a plugin.
+const maxInfoSize = 64 * 1024
+
+// Plugin is an executable that extends step.
+type Plugin struct {
+	Name string
+	Path string
+}
+
+// Info is the description of a plugin, written by the plugin when it runs
+// with InfoFlag.
+type Info struct {
+	Name            string `json:"name"`
+	Usage           string `json:"usage"`
+	Version         string `json:"version,omitempty"`
+	ProtocolVersion int    `json:"protocol-version"`
+}
+
+// Context is the information passed by step to a plugin in ContextEnv.
+type Context struct {
+	ProtocolVersion int    `json:"protocol-version"`
+	Name            string `json:"name"`
+	StepVersion     string `json:"step-version"`
+	StepPath        string `json:"step-path"`
+	BasePath        string `json:"base-path"`
+	Profile         string `json:"profile,omitempty"`
+	ConfigFile      string `json:"config-file"`
+	CAURL           string `json:"ca-url,omitempty"`
+	Root            string `json:"root,omitempty"`
+	ErrorFormat     string `json:"error-format,omitempty"`
+}
+
+// ReadContext returns the Context passed by step. It's meant to be used by
+// plugins written in Go.
+func ReadContext() (*Context, error) {
+	s := os.Getenv(ContextEnv)
+	if s == "" {
+		return nil, errors.Errorf("environment variable %s is not set, plugins must run using step", ContextEnv)
+	}
+	c := new(Context)
+	if err := json.Unmarshal([]byte(s), c); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", ContextEnv)
+	}
+	return c, nil
+}
+
+// Lookup returns the plugin with the given name in the PATH.
+func Lookup(name string) (*Plugin, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, errors.Errorf("invalid plugin name '%s'", name)
+	}
+	path, err := exec.LookPath(Prefix + name)
+	if err != nil {
+		return nil, errors.Errorf("plugin '%s' not found: %s%s is not in the PATH", name, Prefix, name)
+	}
+	return &Plugin{Name: name, Path: path}, nil
+}
+
+// List returns the plugins in the PATH sorted by name. If a plugin is in more
+// than one directory, the first one in the PATH is used, as a shell would do.
+func List() []Plugin {
+	seen := make(map[string]bool)
+	var plugins []Plugin
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := pluginName(e.Name())
+			if !ok || seen[name] {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, Plugin{Name: name, Path: path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// Describe runs the plugin with InfoFlag and returns its description. At most
+// maxInfoSize bytes of the output are read.
+func (p *Plugin) Describe() (*Info, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), infoTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.Path, InfoFlag)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error running %s %s", p.Path, InfoFlag)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "error running %s %s", p.Path, InfoFlag)
+	}
+	out, err := ioutil.ReadAll(io.LimitReader(stdout, maxInfoSize+1))
+	if err == nil && len(out) > maxInfoSize {
+		err = errors.New("output is too large")
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, errors.Wrapf(err, "error running %s %s", p.Path, InfoFlag)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, errors.Wrapf(err, "error running %s %s", p.Path, InfoFlag)
+	}
+	info := new(Info)
+	if err := json.Unmarshal(out, info); err != nil {
+		return nil, errors.Wrapf(err, "error parsing the output of %s %s", p.Path, InfoFlag)
+	}
+	return info, nil
+}
+
+// DescribeAll describes the given plugins concurrently. The plugins that fail
+// to describe themselves are not in the returned map.
+func DescribeAll(plugins []Plugin) map[string]*Info {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	infos := make(map[string]*Info)
+	for i := range plugins {
+		wg.Add(1)
+		go func(p *Plugin) {
+			defer wg.Done()
+			if info, err := p.Describe(); err == nil {
+				mu.Lock()
+				infos[p.Name] = info
+				mu.Unlock()
+			}
+		}(&plugins[i])
+	}
+	wg.Wait()
+	return infos
+}
+
+// Env returns the environment variables added to the environment of the
+// plugin with the given context.
+func (c *Context) Env() ([]string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling plugin context")
+	}
+	env := []string{
+		"STEPPATH=" + c.BasePath,
+		"STEPPROFILE=" + c.Profile,
+		ContextEnv + "=" + string(b),
+	}
+	return env, nil
+}
+
+// pluginName returns the name of the plugin for the given file name, and
+// false if the file is not a plugin.
+func pluginName(filename string) (string, bool) {
+	if !strings.HasPrefix(filename, Prefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(filename, Prefix)
+	if runtime.GOOS == "windows" {
+		ext := filepath.Ext(name)
+		if !strings.EqualFold(ext, ".exe") && !strings.EqualFold(ext, ".bat") && !strings.EqualFold(ext, ".cmd") {
+			return "", false
+		}
+		name = strings.TrimSuffix(name, ext)
+	}
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", false
+	}
+	return name, true
+}
+
+// isExecutable returns true if the given path is a regular file that can be
+// executed.
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	return fi.Mode()&0111 != 0
+}
@@ -0,0 +1,98 @@
+// +build !windows
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, filename, content string, perm os.FileMode) {
+	require.NoError(t, ioutil.WriteFile(filename, []byte(content), perm))
+}
+
+func TestPlugins(t *testing.T) {
+	dir1, err := ioutil.TempDir("", "plugin")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir1)
+	dir2, err := ioutil.TempDir("", "plugin")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir2)
+
+	writeFile(t, filepath.Join(dir1, "step-foo"), "#!/bin/sh\necho '{\"name\":\"foo\",\"usage\":\"do foo things\",\"protocol-version\":1}'\n", 0755)
+	writeFile(t, filepath.Join(dir2, "step-foo"), "#!/bin/sh\nexit 1\n", 0755)
+	writeFile(t, filepath.Join(dir2, "step-bar"), "#!/bin/sh\nexit 1\n", 0755)
+	writeFile(t, filepath.Join(dir2, "step-not-executable"), "", 0644)
+	writeFile(t, filepath.Join(dir2, "other"), "#!/bin/sh\n", 0755)
+
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir1+string(filepath.ListSeparator)+dir2)
+
+	plugins := List()
+	require.Equal(t, []Plugin{
+		{Name: "bar", Path: filepath.Join(dir2, "step-bar")},
+		{Name: "foo", Path: filepath.Join(dir1, "step-foo")},
+	}, plugins)
+
+	p, err := Lookup("foo")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir1, "step-foo"), p.Path)
+	_, err = Lookup("not-executable")
+	require.Error(t, err)
+	_, err = Lookup("../other")
+	require.Error(t, err)
+
+	info, err := p.Describe()
+	require.NoError(t, err)
+	require.Equal(t, &Info{Name: "foo", Usage: "do foo things", ProtocolVersion: 1}, info)
+
+	infos := DescribeAll(plugins)
+	require.Len(t, infos, 1)
+	require.Equal(t, info, infos["foo"])
+}
+
+func TestPlugin_DescribeTooLarge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The plugin writes forever, only maxInfoSize bytes are read.
+	writeFile(t, filepath.Join(dir, "step-yes"), "#!/bin/sh\nexec yes\n", 0755)
+	p := &Plugin{Name: "yes", Path: filepath.Join(dir, "step-yes")}
+	_, err = p.Describe()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "output is too large")
+}
+
+func TestReadContext(t *testing.T) {
+	value := os.Getenv(ContextEnv)
+	defer os.Setenv(ContextEnv, value)
+
+	os.Unsetenv(ContextEnv)
+	_, err := ReadContext()
+	require.Error(t, err)
+
+	c := &Context{
+		ProtocolVersion: ProtocolVersion,
+		Name:            "foo",
+		StepPath:        "/home/user/.step/profiles/test",
+		BasePath:        "/home/user/.step",
+		Profile:         "test",
+		CAURL:           "https://ca.example.com",
+	}
+	env, err := c.Env()
+	require.NoError(t, err)
+	require.Len(t, env, 3)
+	require.Equal(t, "STEPPATH=/home/user/.step", env[0])
+	require.Equal(t, "STEPPROFILE=test", env[1])
+
+	os.Setenv(ContextEnv, env[2][len(ContextEnv)+1:])
+	got, err := ReadContext()
+	require.NoError(t, err)
+	require.Equal(t, c, got)
+}
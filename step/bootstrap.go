@@ -0,0 +1,335 @@
+package step
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/config"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/trace"
+	"github.com/smallstep/cli/utils"
+)
+
+// downloadAttempts is the maximum number of attempts to download a file.
+const downloadAttempts = 5
+
+// BootstrapOptions are the options used to configure step to use a CA with
+// Bootstrap.
+type BootstrapOptions struct {
+	// CAURL is the url of the CA.
+	CAURL string
+	// Fingerprint is the SHA-256 fingerprint of the root certificate of the
+	// CA.
+	Fingerprint string
+	// RootFile and ConfigFile are the files where the root certificate and
+	// the configuration with the CA url and root are written. They default to
+	// the root certificate and the defaults.json in the step path.
+	RootFile   string
+	ConfigFile string
+	// Transport is used to download the root certificate. The root is
+	// verified using the fingerprint, so by default the transport does not
+	// verify the certificate of the CA.
+	Transport http.RoundTripper
+	// Force overwrites the root certificate and the configuration if they
+	// exist, if it's false Bootstrap fails without writing anything.
+	Force bool
+}
+
+// bootstrapConfig is the configuration written by Bootstrap.
+type bootstrapConfig struct {
+	CA   string `json:"ca-url"`
+	Root string `json:"root"`
+}
+
+// Bootstrap downloads the root certificate of a CA, verifies its fingerprint,
+// and writes the root and the configuration used by the step commands to
+// connect to the CA. It returns the root certificate.
+func Bootstrap(opts *BootstrapOptions) (*x509.Certificate, error) {
+	switch {
+	case opts.CAURL == "":
+		return nil, errors.New("error bootstrapping: CA url is required")
+	case opts.Fingerprint == "":
+		return nil, errors.New("error bootstrapping: fingerprint is required")
+	}
+	rootFile := opts.RootFile
+	if rootFile == "" {
+		rootFile = pki.GetRootCAPath()
+	}
+	configFile := opts.ConfigFile
+	if configFile == "" {
+		configFile = filepath.Join(config.StepPath(), "config", "defaults.json")
+	}
+	tr := opts.Transport
+	if tr == nil {
+		tr = trace.Transport(&http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		})
+	}
+
+	if !opts.Force {
+		for _, filename := range []string{rootFile, configFile} {
+			if _, err := os.Stat(filename); err == nil {
+				return nil, errors.Wrapf(utils.ErrFileExists, "error bootstrapping: cannot overwrite %s", filename)
+			}
+		}
+	}
+
+	// The root is validated before writing anything
+	root, err := DownloadRoot(tr, opts.CAURL, opts.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	// make sure to store the url with https
+	caURL, err := CompleteURL(opts.CAURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(rootFile), 0700); err != nil {
+		return nil, errs.FileError(err, rootFile)
+	}
+	if err := os.MkdirAll(filepath.Dir(configFile), 0700); err != nil {
+		return nil, errs.FileError(err, configFile)
+	}
+
+	rootPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: root.Raw,
+	})
+	if err := utils.WriteFileAtomic(rootFile, rootPEM, 0600, "", ""); err != nil {
+		return nil, err
+	}
+
+	b, err := json.MarshalIndent(bootstrapConfig{
+		CA:   caURL,
+		Root: rootFile,
+	}, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, "error marshaling %s", filepath.Base(configFile))
+	}
+	if err := utils.WriteFileAtomic(configFile, b, 0644, "", ""); err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+// DownloadRoot downloads the root certificate with the given fingerprint
+// from the CA. The response is stored in a file in the downloads directory of
+// the step path so an interrupted download can be resumed on the next attempt
+// or on the next run. The certificate is only returned if its fingerprint
+// matches.
+func DownloadRoot(tr http.RoundTripper, caURL, fingerprint string) (*x509.Certificate, error) {
+	rawurl, err := CompleteURL(caURL)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing url '%s'", rawurl)
+	}
+	fingerprint = strings.ToLower(strings.Replace(fingerprint, ":", "", -1))
+	u = u.ResolveReference(&url.URL{Path: "/root/" + fingerprint})
+
+	dir, err := downloadDir()
+	if err != nil {
+		return nil, err
+	}
+	partFile := filepath.Join(dir, "root-"+fingerprint+".part")
+	client := &http.Client{Transport: tr}
+	for i := 1; ; i++ {
+		retry, err := resumeDownload(client, u.String(), partFile)
+		if err == nil {
+			break
+		}
+		if !retry || i == downloadAttempts {
+			return nil, errors.Wrap(err, "error downloading root certificate")
+		}
+		time.Sleep(time.Duration(i) * time.Second)
+	}
+
+	b, err := ioutil.ReadFile(partFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "error downloading root certificate")
+	}
+	// A valid or invalid response must not be resumed again.
+	os.Remove(partFile)
+
+	var body struct {
+		RootPEM string `json:"ca"`
+	}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, errors.Wrap(err, "error parsing root certificate response")
+	}
+	block, _ := pem.Decode([]byte(body.RootPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("error parsing root certificate response: invalid PEM")
+	}
+	crt, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing root certificate")
+	}
+	if sum := x509util.Fingerprint(crt); sum != fingerprint {
+		return nil, errors.Errorf("root certificate fingerprint %s does not match the expected %s", sum, fingerprint)
+	}
+	return crt, nil
+}
+
+// downloadDir returns the directory where the partial downloads are stored,
+// creating it if necessary. The directory must only be accessible by the
+// current user.
+func downloadDir() (string, error) {
+	dir := filepath.Join(config.StepPath(), "downloads")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", errs.FileError(err, dir)
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", errs.FileError(err, dir)
+	}
+	if !fi.IsDir() {
+		return "", errors.Errorf("%s is not a directory", dir)
+	}
+	if err := checkOwner(dir, fi); err != nil {
+		return "", err
+	}
+	if fi.Mode().Perm() != 0700 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return "", errs.FileError(err, dir)
+		}
+	}
+	return dir, nil
+}
+
+// openPartFile opens or creates the file used to store a partial download. It
+// refuses symbolic links and files that are not regular files owned by the
+// current user.
+func openPartFile(filename string) (*os.File, error) {
+	if fi, err := os.Lstat(filename); err == nil && !fi.Mode().IsRegular() {
+		return nil, errors.Errorf("%s is not a regular file", filename)
+	}
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|openNoFollow, 0600)
+	if err != nil {
+		return nil, errs.FileError(err, filename)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errs.FileError(err, filename)
+	}
+	if !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, errors.Errorf("%s is not a regular file", filename)
+	}
+	if err := checkOwner(filename, fi); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// resumeDownload downloads the given url into filename, continuing from the
+// current size of the file if the server supports range requests. It returns
+// true if an error is temporary and the download can be retried.
+func resumeDownload(client *http.Client, rawurl, filename string) (bool, error) {
+	f, err := openPartFile(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server ignored the range, start from the beginning.
+		if err := f.Truncate(0); err != nil {
+			return false, errors.WithStack(err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return false, errors.WithStack(err)
+		}
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The file was already complete.
+		return false, nil
+	default:
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, errors.Errorf("%s: unexpected status %s", rawurl, resp.Status)
+	}
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return true, errors.WithStack(err)
+	}
+	return false, nil
+}
+
+// CompleteURL parses and validates the given URL. It supports general
+// URLs like https://ca.smallstep.com[:port][/path], and incomplete URLs like
+// ca.smallstep.com[:port][/path].
+func CompleteURL(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", errors.Wrapf(err, "error parsing url '%s'", rawurl)
+	}
+
+	// URLs are generally parsed as:
+	// [scheme:][//[userinfo@]host][/]path[?query][#fragment]
+	// But URLs that do not start with a slash after the scheme are interpreted as
+	// scheme:opaque[?query][#fragment]
+	if u.Opaque == "" {
+		if u.Scheme == "" {
+			u.Scheme = "https"
+		}
+		if u.Host == "" {
+			// rawurl looks like ca.smallstep.com or ca.smallstep.com/1.0/sign
+			if u.Path != "" {
+				parts := strings.SplitN(u.Path, "/", 2)
+				u.Host = parts[0]
+				if len(parts) == 2 {
+					u.Path = parts[1]
+				} else {
+					u.Path = ""
+				}
+				return CompleteURL(u.String())
+			}
+			return "", errors.Errorf("error parsing url '%s'", rawurl)
+		}
+		return u.String(), nil
+	}
+	// scheme:opaque[?query][#fragment]
+	// rawurl looks like ca.smallstep.com:443 or ca.smallstep.com:443/1.0/sign
+	return CompleteURL("https://" + rawurl)
+}
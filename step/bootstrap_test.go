@@ -0,0 +1,148 @@
+package step
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenPartFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links are not checked on Windows")
+	}
+	dir, err := ioutil.TempDir("", "step-download")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Regular files are created or opened
+	f, err := openPartFile(filepath.Join(dir, "root.part"))
+	require.NoError(t, err)
+	f.Close()
+	f, err = openPartFile(filepath.Join(dir, "root.part"))
+	require.NoError(t, err)
+	f.Close()
+
+	// Symbolic links are not followed
+	target := filepath.Join(dir, "target")
+	require.NoError(t, ioutil.WriteFile(target, []byte("keep"), 0600))
+	require.NoError(t, os.Symlink(target, filepath.Join(dir, "link.part")))
+	_, err = openPartFile(filepath.Join(dir, "link.part"))
+	require.Error(t, err)
+	require.NoError(t, os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "dangling.part")))
+	_, err = openPartFile(filepath.Join(dir, "dangling.part"))
+	require.Error(t, err)
+	_, err = os.Stat(filepath.Join(dir, "missing"))
+	require.True(t, os.IsNotExist(err))
+	b, err := ioutil.ReadFile(target)
+	require.NoError(t, err)
+	require.Equal(t, "keep", string(b))
+
+	// Directories are not regular files
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "dir.part"), 0700))
+	_, err = openPartFile(filepath.Join(dir, "dir.part"))
+	require.Error(t, err)
+}
+
+func TestResumeDownload(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "root", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "step-download")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Resume a partial download
+	filename := filepath.Join(dir, "root.part")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(content[:250]), 0600))
+	retry, err := resumeDownload(srv.Client(), srv.URL, filename)
+	require.NoError(t, err)
+	require.False(t, retry)
+	b, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, content, string(b))
+
+	// The file is already complete
+	retry, err = resumeDownload(srv.Client(), srv.URL, filename)
+	require.NoError(t, err)
+	require.False(t, retry)
+	b, err = ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, content, string(b))
+}
+
+func TestBootstrap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "step-bootstrap")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ca := newTestCA(t, dir)
+	defer ca.srv.Close()
+
+	fingerprint := x509util.Fingerprint(ca.cert)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/root/"+fingerprint, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"ca": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})),
+		})
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	rootFile := filepath.Join(dir, "certs", "root_ca.crt")
+	configFile := filepath.Join(dir, "config", "defaults.json")
+	opts := &BootstrapOptions{
+		CAURL:       srv.URL,
+		Fingerprint: fingerprint,
+		RootFile:    rootFile,
+		ConfigFile:  configFile,
+		Transport:   srv.Client().Transport,
+	}
+
+	root, err := Bootstrap(opts)
+	require.NoError(t, err)
+	require.Equal(t, ca.cert.Raw, root.Raw)
+	b, err := ioutil.ReadFile(rootFile)
+	require.NoError(t, err)
+	require.Equal(t, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), b)
+	b, err = ioutil.ReadFile(configFile)
+	require.NoError(t, err)
+	var cfg map[string]string
+	require.NoError(t, json.Unmarshal(b, &cfg))
+	require.Equal(t, map[string]string{"ca-url": srv.URL, "root": rootFile}, cfg)
+
+	// The files are not overwritten without Force
+	_, err = Bootstrap(opts)
+	require.Error(t, err)
+	require.Equal(t, utils.ErrFileExists, errors.Cause(err))
+	opts.Force = true
+	_, err = Bootstrap(opts)
+	require.NoError(t, err)
+
+	// Nothing is written if the fingerprint does not match
+	otherRoot := filepath.Join(dir, "other", "root_ca.crt")
+	_, err = Bootstrap(&BootstrapOptions{
+		CAURL:       srv.URL,
+		Fingerprint: x509util.Fingerprint(srv.Certificate()),
+		RootFile:    otherRoot,
+		ConfigFile:  filepath.Join(dir, "other", "defaults.json"),
+		Transport:   srv.Client().Transport,
+	})
+	require.Error(t, err)
+	_, err = os.Stat(filepath.Dir(otherRoot))
+	require.True(t, os.IsNotExist(err))
+}
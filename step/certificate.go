@@ -0,0 +1,270 @@
+package step
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli/crypto/keys"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/token"
+	"github.com/smallstep/cli/trace"
+)
+
+// CertificateOptions are the options used to issue a certificate with
+// IssueCertificate.
+type CertificateOptions struct {
+	// Token is the one-time token that authorizes the certificate.
+	Token string
+	// Subject is the common name of the certificate. For the tokens of the
+	// JWK and OIDC provisioners the subject of the token is used.
+	Subject string
+	// SANs are the subject alternative names, DNS names, IP addresses and
+	// URIs. The SANs in the token are always added.
+	SANs []string
+	// Key is the key of the certificate, a new key of the default type is
+	// generated if it's nil. It must implement crypto.Signer.
+	Key crypto.PrivateKey
+	// Extensions are added to the certificate request.
+	Extensions []pkix.Extension
+	// DisableCustomSANs does not add the subject to the default SANs of the
+	// tokens of the cloud provisioners.
+	DisableCustomSANs bool
+	// NotBefore and NotAfter are the validity of the certificate, the CA
+	// defaults are used if they are zero.
+	NotBefore api.TimeDuration
+	NotAfter  api.TimeDuration
+	// CAURL and Root are the url and the root certificate file of the CA. If
+	// the token has the fingerprint of the root, CAURL defaults to the
+	// audience of the token and Root is not used. Root defaults to the root
+	// certificate in the step path.
+	CAURL string
+	Root  string
+	// Client is used instead of the client of the CA in CAURL if it's set.
+	Client CAClient
+}
+
+// IssueCertificate creates a certificate request with the given options and
+// signs it with the CA.
+func IssueCertificate(opts *CertificateOptions) (*Certificate, error) {
+	req, pk, err := NewSignRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+	client := opts.Client
+	if client == nil {
+		if client, err = NewClient(opts.Token, opts.CAURL, opts.Root); err != nil {
+			return nil, err
+		}
+	}
+	req.NotBefore = opts.NotBefore
+	req.NotAfter = opts.NotAfter
+	resp, err := client.Sign(req)
+	if err != nil {
+		return nil, err
+	}
+	return newCertificate(resp, pk)
+}
+
+// SignCertificate signs the given request with the client and returns the
+// certificate.
+func SignCertificate(client CAClient, req *api.SignRequest) (*Certificate, error) {
+	resp, err := client.Sign(req)
+	if err != nil {
+		return nil, err
+	}
+	return newCertificate(resp, nil)
+}
+
+// NewClient returns the client of the CA used with the given token. If the
+// token has the fingerprint of the root, the url defaults to the audience of
+// the token and the root is verified using the fingerprint, if not the root
+// defaults to the root certificate in the step path.
+func NewClient(tok, caURL, root string) (*ca.Client, error) {
+	jwt, err := token.ParseInsecure(tok)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing token")
+	}
+
+	// Prepare client for bootstrap or provisioning tokens
+	var options []ca.ClientOption
+	if len(jwt.Payload.SHA) > 0 && len(jwt.Payload.Audience) > 0 && strings.HasPrefix(strings.ToLower(jwt.Payload.Audience[0]), "http") {
+		if len(caURL) == 0 {
+			caURL = jwt.Payload.Audience[0]
+		}
+		options = append(options, ca.WithRootSHA256(jwt.Payload.SHA))
+	} else {
+		if len(caURL) == 0 {
+			return nil, errors.New("error creating CA client: CA url is required")
+		}
+		if len(root) == 0 {
+			root = pki.GetRootCAPath()
+		}
+		options = append(options, WithRootFile(root))
+	}
+	return ca.NewClient(caURL, options...)
+}
+
+// WithRootFile returns a client option that trusts the certificates in the
+// given root file. The client uses a transport that adds the User-Agent and
+// request ID headers, and that logs the requests if tracing is enabled.
+func WithRootFile(root string) ca.ClientOption {
+	pool, err := x509util.ReadCertPool(root)
+	if err != nil {
+		// ca.NewClient will report the error
+		return ca.WithRootFile(root)
+	}
+	return ca.WithTransport(trace.Transport(&http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{
+			RootCAs:                  pool,
+			PreferServerCipherSuites: true,
+		},
+	}))
+}
+
+// NewSignRequest returns a simple but secure sign request for the token,
+// subject, SANs, key and extensions in the options, as well as the private
+// key used. If the key is nil a new private key will be generated.
+func NewSignRequest(opts *CertificateOptions) (*api.SignRequest, crypto.PrivateKey, error) {
+	jwt, err := token.ParseInsecure(opts.Token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pk := opts.Key
+	subject := opts.Subject
+	sigAlg := x509.UnknownSignatureAlgorithm
+	if pk == nil {
+		if pk, err = keys.GenerateDefaultKey(); err != nil {
+			return nil, nil, err
+		}
+		sigAlg = keys.DefaultSignatureAlgorithm
+	}
+
+	dnsNames, ips := SplitSANs(opts.SANs, jwt.Payload.SANs)
+	dnsNames, uris := SplitURIs(dnsNames)
+	dnsNames, sanEmails := x509util.SplitEmails(dnsNames)
+	var emails []string
+	if jwt.Payload.Email != "" {
+		emails = append(emails, jwt.Payload.Email)
+	}
+	for _, e := range sanEmails {
+		if e != jwt.Payload.Email {
+			emails = append(emails, e)
+		}
+	}
+
+	switch jwt.Payload.Type() {
+	case token.AWS:
+		doc := jwt.Payload.Amazon.InstanceIdentityDocument
+		if len(ips) == 0 && len(dnsNames) == 0 {
+			defaultSANs := []string{
+				doc.PrivateIP,
+				fmt.Sprintf("ip-%s.%s.compute.internal", strings.Replace(doc.PrivateIP, ".", "-", -1), doc.Region),
+			}
+			if !opts.DisableCustomSANs {
+				defaultSANs = append(defaultSANs, subject)
+			}
+			dnsNames, ips = SplitSANs(defaultSANs)
+		}
+	case token.GCP:
+		ce := jwt.Payload.Google.ComputeEngine
+		if len(ips) == 0 && len(dnsNames) == 0 {
+			defaultSANs := []string{
+				fmt.Sprintf("%s.c.%s.internal", ce.InstanceName, ce.ProjectID),
+				fmt.Sprintf("%s.%s.c.%s.internal", ce.InstanceName, ce.Zone, ce.ProjectID),
+			}
+			if !opts.DisableCustomSANs {
+				defaultSANs = append(defaultSANs, subject)
+			}
+			dnsNames, ips = SplitSANs(defaultSANs)
+		}
+	case token.Azure:
+		if len(ips) == 0 && len(dnsNames) == 0 {
+			defaultSANs := []string{
+				jwt.Payload.Azure.VirtualMachine,
+			}
+			if !opts.DisableCustomSANs {
+				defaultSANs = append(defaultSANs, subject)
+			}
+			dnsNames, ips = SplitSANs(defaultSANs)
+		}
+	default: // Use common name in the token
+		subject = jwt.Payload.Subject
+	}
+
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName: subject,
+		},
+		SignatureAlgorithm: sigAlg,
+		DNSNames:           dnsNames,
+		IPAddresses:        ips,
+		EmailAddresses:     emails,
+		URIs:               uris,
+		ExtraExtensions:    opts.Extensions,
+	}
+
+	signer, ok := pk.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.Errorf("unsupported private key type %T", pk)
+	}
+	csr, err := x509util.CreateCertificateRequest(rand.Reader, template, signer)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error creating certificate request")
+	}
+	cr, err := x509.ParseCertificateRequest(csr)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing certificate request")
+	}
+	if err := cr.CheckSignature(); err != nil {
+		return nil, nil, errors.Wrap(err, "error signing certificate request")
+	}
+	return &api.SignRequest{
+		CsrPEM: api.CertificateRequest{CertificateRequest: cr},
+		OTT:    opts.Token,
+	}, pk, nil
+}
+
+// SplitSANs unifies the SAN collections passed as arguments and returns a list
+// of DNS names and a list of IP addresses.
+func SplitSANs(args ...[]string) (dnsNames []string, ipAddresses []net.IP) {
+	m := make(map[string]bool)
+	var unique []string
+	for _, sans := range args {
+		for _, san := range sans {
+			if ok := m[san]; !ok {
+				m[san] = true
+				unique = append(unique, san)
+			}
+		}
+	}
+	return x509util.SplitSANs(unique)
+}
+
+// SplitURIs splits the SANs that are URIs, like SPIFFE IDs, from the rest.
+func SplitURIs(sans []string) (rest []string, uris []*url.URL) {
+	rest = []string{}
+	for _, san := range sans {
+		if strings.Contains(san, "://") {
+			if u, err := url.Parse(san); err == nil && u.Scheme != "" {
+				uris = append(uris, u)
+				continue
+			}
+		}
+		rest = append(rest, san)
+	}
+	return
+}
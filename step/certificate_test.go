@@ -0,0 +1,211 @@
+package step
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/cli/jose"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA is a CA that signs any request, and renews the client certificates
+// it issued.
+type testCA struct {
+	srv    *httptest.Server
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	serial int64
+	// rootFile is the root certificate of the TLS server.
+	rootFile string
+}
+
+func newTestCA(t *testing.T, dir string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	c := &testCA{cert: cert, key: key, serial: 1}
+	c.srv = httptest.NewUnstartedServer(http.HandlerFunc(c.serveHTTP))
+	c.srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	c.srv.StartTLS()
+
+	c.rootFile = filepath.Join(dir, "root_ca.crt")
+	require.NoError(t, ioutil.WriteFile(c.rootFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: c.srv.Certificate().Raw,
+	}), 0600))
+	return c
+}
+
+func (c *testCA) sign(tmpl *x509.Certificate) (*x509.Certificate, error) {
+	c.serial++
+	tmpl.SerialNumber = big.NewInt(c.serial)
+	tmpl.NotBefore = time.Now().Add(-time.Minute)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, c.cert, tmpl.PublicKey, c.key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func (c *testCA) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var crt *x509.Certificate
+	var err error
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/sign"):
+		var req api.SignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cr := req.CsrPEM.CertificateRequest
+		crt, err = c.sign(&x509.Certificate{
+			Subject:     cr.Subject,
+			DNSNames:    cr.DNSNames,
+			IPAddresses: cr.IPAddresses,
+			PublicKey:   cr.PublicKey,
+		})
+	case strings.HasSuffix(r.URL.Path, "/renew"):
+		if len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "missing client certificate", http.StatusUnauthorized)
+			return
+		}
+		old := r.TLS.PeerCertificates[0]
+		if err := old.CheckSignatureFrom(c.cert); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		crt, err = c.sign(&x509.Certificate{
+			Subject:     old.Subject,
+			DNSNames:    old.DNSNames,
+			IPAddresses: old.IPAddresses,
+			PublicKey:   old.PublicKey,
+		})
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.SignResponse{
+		ServerPEM: api.Certificate{Certificate: crt},
+		CaPEM:     api.Certificate{Certificate: c.cert},
+	})
+}
+
+func testToken(t *testing.T, aud string) string {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	require.NoError(t, err)
+	tok, err := SignJWT(&JWTOptions{
+		Key:      jwk,
+		Issuer:   "test",
+		Subject:  "test.example.com",
+		Audience: []string{aud},
+		Expiry:   time.Now().Add(time.Minute),
+		Payload:  map[string]interface{}{"sans": []string{"test.example.com", "127.0.0.1"}},
+	})
+	require.NoError(t, err)
+	return tok
+}
+
+func TestIssueCertificateAndRenew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "step-certificate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ca := newTestCA(t, dir)
+	defer ca.srv.Close()
+
+	// Sign
+	crt, err := IssueCertificate(&CertificateOptions{
+		Token: testToken(t, ca.srv.URL+"/1.0/sign"),
+		CAURL: ca.srv.URL,
+		Root:  ca.rootFile,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "test.example.com", crt.Leaf.Subject.CommonName)
+	require.Equal(t, []string{"test.example.com"}, crt.Leaf.DNSNames)
+	require.Len(t, crt.Leaf.IPAddresses, 1)
+	require.Equal(t, "127.0.0.1", crt.Leaf.IPAddresses[0].String())
+	require.Equal(t, ca.cert.Raw, crt.Intermediate.Raw)
+	require.NoError(t, crt.Leaf.CheckSignatureFrom(ca.cert))
+	key, ok := crt.PrivateKey.(*ecdsa.PrivateKey)
+	require.True(t, ok)
+	require.Equal(t, &key.PublicKey, crt.Leaf.PublicKey)
+
+	// Renew with the new certificate
+	renewed, err := Renew(&RenewOptions{
+		Certificate: tls.Certificate{
+			Certificate: [][]byte{crt.Leaf.Raw, crt.Intermediate.Raw},
+			PrivateKey:  crt.PrivateKey,
+			Leaf:        crt.Leaf,
+		},
+		CAURL: ca.srv.URL,
+		Root:  ca.rootFile,
+	})
+	require.NoError(t, err)
+	require.Equal(t, crt.Leaf.Subject, renewed.Leaf.Subject)
+	require.Equal(t, crt.Leaf.PublicKey, renewed.Leaf.PublicKey)
+	require.NotEqual(t, crt.Leaf.SerialNumber, renewed.Leaf.SerialNumber)
+	require.Equal(t, crt.PrivateKey, renewed.PrivateKey)
+
+	// Renew requires a certificate
+	_, err = Renew(&RenewOptions{
+		CAURL: ca.srv.URL,
+		Root:  ca.rootFile,
+	})
+	require.Error(t, err)
+}
+
+func TestIssueCertificateUntrustedCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "step-certificate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ca := newTestCA(t, dir)
+	defer ca.srv.Close()
+
+	// The TLS certificate of the CA is not signed by the root
+	root := filepath.Join(dir, "other_ca.crt")
+	require.NoError(t, ioutil.WriteFile(root, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: ca.cert.Raw,
+	}), 0600))
+	_, err = IssueCertificate(&CertificateOptions{
+		Token: testToken(t, ca.srv.URL+"/1.0/sign"),
+		CAURL: ca.srv.URL,
+		Root:  root,
+	})
+	require.Error(t, err)
+}
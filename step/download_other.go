@@ -0,0 +1,24 @@
+// +build !windows
+
+package step
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// openNoFollow is the flag used to open the partial downloads without
+// following symbolic links.
+const openNoFollow = syscall.O_NOFOLLOW
+
+// checkOwner returns an error if the given file is not owned by the current
+// user.
+func checkOwner(filename string, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || int(st.Uid) != os.Getuid() {
+		return errors.Errorf("%s is not owned by the current user", filename)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package step
+
+import (
+	"os"
+)
+
+// openNoFollow is not supported on Windows, the partial downloads are checked
+// with os.Lstat before opening them.
+const openNoFollow = 0
+
+// checkOwner is a no-op on Windows, the step path is in the profile of the
+// user and it is not shared with other users.
+func checkOwner(filename string, fi os.FileInfo) error {
+	return nil
+}
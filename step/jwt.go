@@ -0,0 +1,186 @@
+package step
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/keys"
+	"github.com/smallstep/cli/crypto/randutil"
+	"github.com/smallstep/cli/jose"
+)
+
+// JWTOptions are the options used to sign a JWT with SignJWT.
+type JWTOptions struct {
+	// Key is the private key used to sign the JWT. The signature algorithm is
+	// the algorithm of the key.
+	Key *jose.JSONWebKey
+	// Issuer, Subject and Audience are the "iss", "sub" and "aud" claims.
+	Issuer   string
+	Subject  string
+	Audience []string
+	// Expiry, NotBefore and IssuedAt are the "exp", "nbf" and "iat" claims.
+	// NotBefore and IssuedAt default to the current time, and a zero Expiry
+	// is omitted.
+	Expiry    time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	// ID is the "jti" claim. If RandomID is true and ID is empty a random one
+	// is generated.
+	ID       string
+	RandomID bool
+	// Payload contains other claims, they overwrite the ones above.
+	Payload map[string]interface{}
+	// Disclose is the list of claims to selectively disclose. If it's not
+	// empty, SignJWT returns an SD-JWT with the disclosures.
+	Disclose []string
+	// NoKeyID omits the "kid" header.
+	NoKeyID bool
+	// Deterministic uses deterministic nonces as defined in RFC 6979 with
+	// ECDSA keys. It requires Subtle.
+	Deterministic bool
+	// Subtle allows JWTs without the "iss", "sub", "aud" or "exp" claims, or
+	// with an "exp" in the past.
+	Subtle bool
+}
+
+// SignJWT signs a JWT with the given options and returns it in the compact
+// serialization.
+func SignJWT(opts *JWTOptions) (string, error) {
+	jwk := opts.Key
+	switch {
+	case jwk == nil:
+		return "", errors.New("error signing JWT: key is required")
+	case jwk.IsPublic():
+		return "", errors.New("cannot use a public key for signing")
+	case jwk.Use != "sig" && jwk.Use != "":
+		return "", errors.Errorf("invalid jwk use: found '%s', expecting 'sig' (signature)", jwk.Use)
+	case jwk.Algorithm == "":
+		return "", errors.New("error signing JWT: key algorithm is required")
+	}
+	if err := jose.ValidateJWK(jwk); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	c := &jose.Claims{
+		Issuer:    opts.Issuer,
+		Subject:   opts.Subject,
+		Audience:  opts.Audience,
+		NotBefore: jose.NewNumericDate(now),
+		IssuedAt:  jose.NewNumericDate(now),
+		ID:        opts.ID,
+	}
+	if !opts.Expiry.IsZero() {
+		c.Expiry = jose.NewNumericDate(opts.Expiry)
+	}
+	if !opts.NotBefore.IsZero() {
+		c.NotBefore = jose.NewNumericDate(opts.NotBefore)
+	}
+	if !opts.IssuedAt.IsZero() {
+		c.IssuedAt = jose.NewNumericDate(opts.IssuedAt)
+	}
+	if c.ID == "" && opts.RandomID {
+		var err error
+		if c.ID, err = randutil.Hex(40); err != nil {
+			return "", errors.Wrap(err, "error creating random jti")
+		}
+	}
+
+	// Validate recommended claims
+	if !opts.Subtle {
+		switch {
+		case len(c.Issuer) == 0:
+			return "", errors.New("error signing JWT: issuer is required")
+		case len(c.Audience) == 0:
+			return "", errors.New("error signing JWT: audience is required")
+		case len(c.Subject) == 0:
+			return "", errors.New("error signing JWT: subject is required")
+		case c.Expiry == nil:
+			return "", errors.New("error signing JWT: expiry is required")
+		case c.Expiry.Time().Before(now):
+			return "", errors.New("error signing JWT: expiry must be in the future")
+		}
+	}
+
+	so := new(jose.SignerOptions)
+	so.WithType("JWT")
+	if !opts.NoKeyID && jwk.KeyID != "" {
+		so.WithHeader("kid", jwk.KeyID)
+	}
+
+	key := jwk.Key
+	if opts.Deterministic {
+		if !opts.Subtle {
+			return "", errors.New("error signing JWT: deterministic signatures require subtle")
+		}
+		k, ok := jwk.Key.(*ecdsa.PrivateKey)
+		if !ok {
+			return "", errors.New("error signing JWT: deterministic signatures require an ECDSA key")
+		}
+		key = keys.NewDeterministicSigner(k)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.SignatureAlgorithm(jwk.Algorithm),
+		Key:       key,
+	}, so)
+	if err != nil {
+		return "", errors.Wrapf(err, "error creating JWT signer")
+	}
+
+	// Some implementations only accept "aud" as a string.
+	// Using claim overwriting for this special case.
+	aud := make(map[string]interface{})
+	if len(c.Audience) == 1 {
+		aud["aud"] = c.Audience[0]
+	}
+	payload := opts.Payload
+	if payload == nil {
+		payload = make(map[string]interface{})
+	}
+
+	if len(opts.Disclose) > 0 {
+		claims, err := mergeClaims(c, aud, payload)
+		if err != nil {
+			return "", err
+		}
+		disclosures, err := jose.MakeDisclosures(claims, opts.Disclose)
+		if err != nil {
+			return "", err
+		}
+		raw, err := jose.Signed(signer).Claims(claims).CompactSerialize()
+		if err != nil {
+			return "", errors.Wrapf(err, "error serializing JWT")
+		}
+		encoded := []string{raw}
+		for _, d := range disclosures {
+			encoded = append(encoded, d.Encoded)
+		}
+		return strings.Join(encoded, jose.SDSeparator) + jose.SDSeparator, nil
+	}
+
+	raw, err := jose.Signed(signer).Claims(c).Claims(aud).Claims(payload).CompactSerialize()
+	if err != nil {
+		return "", errors.Wrapf(err, "error serializing JWT")
+	}
+	return raw, nil
+}
+
+// mergeClaims merges the given claim sets into one map, the latter ones
+// overwriting the former ones, as the JWT builder does.
+func mergeClaims(claimSets ...interface{}) (map[string]interface{}, error) {
+	claims := make(map[string]interface{})
+	for _, v := range claimSets {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "error marshaling claims")
+		}
+		if err := json.Unmarshal(b, &claims); err != nil {
+			return nil, errors.Wrap(err, "error marshaling claims")
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,79 @@
+package step
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smallstep/cli/jose"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignJWT(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "the-kid", 0)
+	require.NoError(t, err)
+	pub := jwk.Public()
+	exp := time.Now().Add(5 * time.Minute)
+
+	tests := []struct {
+		name    string
+		opts    *JWTOptions
+		wantErr bool
+	}{
+		{"ok", &JWTOptions{Key: jwk, Issuer: "iss", Subject: "sub", Audience: []string{"aud"}, Expiry: exp, ID: "jti"}, false},
+		{"ok subtle", &JWTOptions{Key: jwk, Subtle: true}, false},
+		{"ok deterministic", &JWTOptions{Key: jwk, Subtle: true, Deterministic: true}, false},
+		{"fail no key", &JWTOptions{Issuer: "iss", Subject: "sub", Audience: []string{"aud"}, Expiry: exp}, true},
+		{"fail public key", &JWTOptions{Key: &pub, Issuer: "iss", Subject: "sub", Audience: []string{"aud"}, Expiry: exp}, true},
+		{"fail no issuer", &JWTOptions{Key: jwk, Subject: "sub", Audience: []string{"aud"}, Expiry: exp}, true},
+		{"fail no audience", &JWTOptions{Key: jwk, Issuer: "iss", Subject: "sub", Expiry: exp}, true},
+		{"fail no expiry", &JWTOptions{Key: jwk, Issuer: "iss", Subject: "sub", Audience: []string{"aud"}}, true},
+		{"fail expired", &JWTOptions{Key: jwk, Issuer: "iss", Subject: "sub", Audience: []string{"aud"}, Expiry: time.Now().Add(-time.Minute)}, true},
+		{"fail deterministic", &JWTOptions{Key: jwk, Issuer: "iss", Subject: "sub", Audience: []string{"aud"}, Expiry: exp, Deterministic: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := SignJWT(tt.opts)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tok, err := jose.ParseSigned(raw)
+			require.NoError(t, err)
+			var claims jose.Claims
+			require.NoError(t, tok.Claims(pub.Key, &claims))
+			require.Equal(t, tt.opts.Issuer, claims.Issuer)
+			require.Equal(t, tt.opts.Subject, claims.Subject)
+			require.Equal(t, tt.opts.ID, claims.ID)
+			require.Equal(t, "the-kid", tok.Headers[0].KeyID)
+		})
+	}
+}
+
+func TestSignJWTPayload(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "the-kid", 0)
+	require.NoError(t, err)
+
+	raw, err := SignJWT(&JWTOptions{
+		Key:      jwk,
+		Issuer:   "iss",
+		Subject:  "sub",
+		Audience: []string{"aud"},
+		Expiry:   time.Now().Add(time.Minute),
+		RandomID: true,
+		NoKeyID:  true,
+		Payload:  map[string]interface{}{"sub": "other", "sans": []string{"foo"}},
+	})
+	require.NoError(t, err)
+	tok, err := jose.ParseSigned(raw)
+	require.NoError(t, err)
+	var claims map[string]interface{}
+	require.NoError(t, tok.Claims(jwk.Public().Key, &claims))
+
+	// The payload overwrites the claims and a single audience is a string.
+	require.Equal(t, "other", claims["sub"])
+	require.Equal(t, "aud", claims["aud"])
+	require.Equal(t, []interface{}{"foo"}, claims["sans"])
+	require.Len(t, claims["jti"], 40)
+	require.Equal(t, "", tok.Headers[0].KeyID)
+}
@@ -0,0 +1,76 @@
+package step
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/ca"
+	"github.com/smallstep/cli/crypto/pki"
+	"github.com/smallstep/cli/crypto/x509util"
+	"github.com/smallstep/cli/trace"
+)
+
+// RenewOptions are the options used to renew a certificate with Renew.
+type RenewOptions struct {
+	// Certificate is the certificate to renew and its private key, it's
+	// used to authenticate with the CA.
+	Certificate tls.Certificate
+	// CAURL and Root are the url and the root certificate file of the CA.
+	// Root defaults to the root certificate in the step path.
+	CAURL string
+	Root  string
+	// Client and Transport are used instead of the client of the CA in CAURL
+	// and the transport created with RenewTransport if they are set.
+	Client    CAClient
+	Transport http.RoundTripper
+}
+
+// RenewTransport returns the transport used to renew the given certificate,
+// it authenticates with the certificate and trusts the certificates in the
+// given root file.
+func RenewTransport(cert tls.Certificate, root string) (*http.Transport, error) {
+	if len(cert.Certificate) == 0 {
+		return nil, errors.New("error loading certificate: certificate chain is empty")
+	}
+	if root == "" {
+		root = pki.GetRootCAPath()
+	}
+	rootCAs, err := x509util.ReadCertPool(root)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{
+		TLSClientConfig: &tls.Config{
+			Certificates:             []tls.Certificate{cert},
+			RootCAs:                  rootCAs,
+			PreferServerCipherSuites: true,
+		},
+	}, nil
+}
+
+// Renew renews a certificate using mTLS with the CA. The private key of the
+// new certificate is the one of the renewed certificate.
+func Renew(opts *RenewOptions) (*Certificate, error) {
+	tr := opts.Transport
+	if tr == nil {
+		t, err := RenewTransport(opts.Certificate, opts.Root)
+		if err != nil {
+			return nil, err
+		}
+		tr = trace.Transport(t)
+	}
+	client := opts.Client
+	if client == nil {
+		c, err := ca.NewClient(opts.CAURL, ca.WithTransport(tr))
+		if err != nil {
+			return nil, err
+		}
+		client = c
+	}
+	resp, err := client.Renew(tr)
+	if err != nil {
+		return nil, errors.Wrap(err, "error renewing certificate")
+	}
+	return newCertificate(resp, opts.Certificate.PrivateKey)
+}
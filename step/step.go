@@ -0,0 +1,60 @@
+// Package step exposes the core operations of the step commands as functions
+// that can be used by other Go programs: signing JWTs, issuing and renewing
+// certificates with a step CA, and bootstrapping the configuration of a CA.
+//
+// The functions take an options struct and never prompt, read from the
+// standard input, print to the standard output or exit, the commands of step
+// are thin wrappers around them that read the flags, prompt for the missing
+// values and print the results.
+package step
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/api"
+)
+
+// CAClient is the interface of the clients used to sign and renew
+// certificates. It's implemented by the client of the step CA, and by the
+// offline CA of the step commands.
+type CAClient interface {
+	Sign(req *api.SignRequest) (*api.SignResponse, error)
+	Renew(tr http.RoundTripper) (*api.SignResponse, error)
+}
+
+// Certificate is a certificate signed by the CA.
+type Certificate struct {
+	Leaf         *x509.Certificate
+	Intermediate *x509.Certificate
+	// PrivateKey is the private key of the certificate, it's nil if the key
+	// is not available, e.g. when a CSR is signed.
+	PrivateKey crypto.PrivateKey
+}
+
+// newCertificate returns the certificate in the given response of the CA.
+func newCertificate(resp *api.SignResponse, pk crypto.PrivateKey) (*Certificate, error) {
+	if resp.ServerPEM.Certificate == nil || resp.CaPEM.Certificate == nil {
+		return nil, errors.New("error parsing CA response: certificate is missing")
+	}
+	return &Certificate{
+		Leaf:         resp.ServerPEM.Certificate,
+		Intermediate: resp.CaPEM.Certificate,
+		PrivateKey:   pk,
+	}, nil
+}
+
+// PEM returns the leaf and the intermediate certificates in PEM format.
+func (c *Certificate) PEM() []byte {
+	b := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: c.Leaf.Raw,
+	})
+	return append(b, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: c.Intermediate.Raw,
+	})...)
+}
@@ -0,0 +1,40 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompleteURL(t *testing.T) {
+	tests := []struct {
+		rawurl  string
+		want    string
+		wantErr bool
+	}{
+		{"https://ca.smallstep.com", "https://ca.smallstep.com", false},
+		{"ca.smallstep.com", "https://ca.smallstep.com", false},
+		{"ca.smallstep.com:8443", "https://ca.smallstep.com:8443", false},
+		{"ca.smallstep.com/1.0/sign", "https://ca.smallstep.com/1.0/sign", false},
+		{"http://ca.smallstep.com", "http://ca.smallstep.com", false},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rawurl, func(t *testing.T) {
+			got, err := CompleteURL(tt.rawurl)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSplitURIs(t *testing.T) {
+	rest, uris := SplitURIs([]string{"foo.internal", "spiffe://example.org/foo", "10.0.0.1", "://bad"})
+	require.Equal(t, []string{"foo.internal", "10.0.0.1", "://bad"}, rest)
+	require.Len(t, uris, 1)
+	require.Equal(t, "spiffe://example.org/foo", uris[0].String())
+}
@@ -0,0 +1,91 @@
+// Package timing measures the duration of the operations that are usually
+// behind a slow command: key parsing, network calls and crypto operations.
+// The durations are printed when the global flag --debug is used, this makes
+// the reports of slow commands actionable.
+package timing
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Categories of the measured operations.
+const (
+	Keys    = "keys"
+	Network = "network"
+	Crypto  = "crypto"
+)
+
+var (
+	mu     sync.Mutex
+	writer io.Writer
+	start  = time.Now()
+	totals = make(map[string]time.Duration)
+	counts = make(map[string]int)
+)
+
+// Enable enables the timings and writes them to the given writer.
+func Enable(w io.Writer) {
+	mu.Lock()
+	writer = w
+	mu.Unlock()
+}
+
+// Enabled returns true if the timings are enabled.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return writer != nil
+}
+
+// Start starts measuring an operation of the given category and returns the
+// function that stops it. It's meant to be used as:
+//
+//   defer timing.Start(timing.Crypto, "generate EC P-256 key")()
+func Start(category, name string) func() {
+	if !Enabled() {
+		return func() {}
+	}
+	t := time.Now()
+	return func() {
+		d := time.Since(t)
+		mu.Lock()
+		defer mu.Unlock()
+		totals[category] += d
+		counts[category]++
+		if writer != nil {
+			fmt.Fprintf(writer, "timing: %s: %s: %s\n", category, name, round(d))
+		}
+	}
+}
+
+// Summary writes the total time spent on each category and the total time
+// since the start of the program. It does nothing if the timings are not
+// enabled.
+func Summary() {
+	mu.Lock()
+	defer mu.Unlock()
+	if writer == nil {
+		return
+	}
+	for _, category := range []string{Keys, Network, Crypto} {
+		if n := counts[category]; n > 0 {
+			fmt.Fprintf(writer, "timing: total %s: %s (%d operations)\n", category, round(totals[category]), n)
+		}
+	}
+	fmt.Fprintf(writer, "timing: total: %s\n", round(time.Since(start)))
+}
+
+// round rounds the duration to make it readable.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d > time.Second:
+		return d.Round(time.Millisecond)
+	case d > time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
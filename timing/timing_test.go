@@ -0,0 +1,44 @@
+package timing
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTiming(t *testing.T) {
+	// Disabled timings do nothing
+	Start(Crypto, "disabled")()
+	require.Empty(t, counts)
+
+	var buf bytes.Buffer
+	Enable(&buf)
+	defer Enable(nil)
+
+	stop := Start(Crypto, "generate EC key")
+	time.Sleep(2 * time.Millisecond)
+	stop()
+	Start(Network, "GET https://ca.smallstep.com/root")()
+	Start(Network, "POST https://ca.smallstep.com/sign")()
+	Summary()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 6)
+	require.True(t, strings.HasPrefix(lines[0], "timing: crypto: generate EC key: "))
+	require.True(t, strings.HasPrefix(lines[1], "timing: network: GET https://ca.smallstep.com/root: "))
+	require.True(t, strings.HasPrefix(lines[2], "timing: network: POST https://ca.smallstep.com/sign: "))
+	require.True(t, strings.HasPrefix(lines[3], "timing: total network: "))
+	require.True(t, strings.HasSuffix(lines[3], "(2 operations)"))
+	require.True(t, strings.HasPrefix(lines[4], "timing: total crypto: "))
+	require.True(t, strings.HasPrefix(lines[5], "timing: total: "))
+	require.True(t, totals[Crypto] >= 2*time.Millisecond)
+}
+
+func TestRound(t *testing.T) {
+	require.Equal(t, 1235*time.Millisecond, round(1234567890*time.Nanosecond))
+	require.Equal(t, 12350*time.Microsecond, round(12345678*time.Nanosecond))
+	require.Equal(t, 123*time.Microsecond, round(123456*time.Nanosecond))
+}
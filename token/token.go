@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/clock"
 	"github.com/smallstep/cli/crypto/keys"
 	"github.com/smallstep/cli/jose"
 )
@@ -61,6 +62,9 @@ func (c *Claims) SetHeader(key string, value interface{}) {
 
 // Sign creates a JWT with the claims and signs it with the given key.
 func (c *Claims) Sign(alg jose.SignatureAlgorithm, key interface{}) (string, error) {
+	if err := clock.Check(); err != nil {
+		return "", err
+	}
 	kid, err := GenerateKeyID(key)
 	if err != nil {
 		return "", err
@@ -0,0 +1,236 @@
+package trace
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// sensitiveHeaders are the headers whose values are not recorded.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// secretProperties are the JSON properties whose values are not recorded.
+// Unlike the trace logs, the names must match exactly, so public values like
+// the key and encryptedKey of the provisioners are recorded and replayed.
+var secretProperties = []string{
+	"ott", "token", "password", "secret", "jwt", "assertion",
+	"privateKey", "clientSecret", "client_secret",
+	"access_token", "id_token", "refresh_token",
+}
+
+// Exchange is an HTTP request and its response, as written by Record and read
+// by Replay. The secrets in the headers and JSON bodies are redacted, and the
+// query of the URL is not recorded. Other bodies are only recorded if raw
+// bodies are enabled, otherwise only their size and SHA-256 digest are.
+type Exchange struct {
+	Method          string          `json:"method"`
+	URL             string          `json:"url"`
+	RequestHeader   http.Header     `json:"requestHeader,omitempty"`
+	RequestBody     json.RawMessage `json:"requestBody,omitempty"`
+	RequestSize     int             `json:"requestSize,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	StatusCode      int             `json:"statusCode,omitempty"`
+	ResponseHeader  http.Header     `json:"responseHeader,omitempty"`
+	ResponseBody    json.RawMessage `json:"responseBody,omitempty"`
+	ResponseRawBody []byte          `json:"responseRawBody,omitempty"`
+	ResponseSize    int             `json:"responseSize,omitempty"`
+	ResponseSHA256  string          `json:"responseSHA256,omitempty"`
+}
+
+var (
+	recorder  *json.Encoder
+	recordRaw bool
+	replayer  *replay
+)
+
+// Record enables the recording of the HTTP exchanges to the given writer,
+// one JSON Exchange per line. The http.DefaultTransport is also wrapped, so
+// requests using the default client are recorded too. The response bodies
+// that are not JSON, and cannot be redacted, are only recorded if raw is true.
+func Record(w io.Writer, raw bool) {
+	mu.Lock()
+	recorder = json.NewEncoder(w)
+	recorder.SetEscapeHTML(false)
+	recordRaw = raw
+	mu.Unlock()
+	http.DefaultTransport = Transport(http.DefaultTransport)
+}
+
+// Replay enables the replay of the exchanges in the given reader, written by
+// Record. Requests are not sent, each one gets the response of the next
+// recorded exchange, and it fails if the method or URL of the request does
+// not match it.
+func Replay(r io.Reader) error {
+	var exchanges []*Exchange
+	dec := json.NewDecoder(r)
+	for {
+		ex := new(Exchange)
+		if err := dec.Decode(ex); err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "error reading recorded exchanges")
+		}
+		exchanges = append(exchanges, ex)
+	}
+	mu.Lock()
+	replayer = &replay{exchanges: exchanges}
+	mu.Unlock()
+	http.DefaultTransport = Transport(http.DefaultTransport)
+	return nil
+}
+
+func isRecording() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return recorder != nil
+}
+
+func getReplayer() *replay {
+	mu.Lock()
+	defer mu.Unlock()
+	return replayer
+}
+
+// record writes the exchange to the recorder.
+func record(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, err error) {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	ex := &Exchange{
+		Method:        req.Method,
+		URL:           u.String(),
+		RequestHeader: redactHeader(req.Header),
+	}
+	if ex.RequestBody = redactBody(req.Header.Get("Content-Type"), reqBody); ex.RequestBody == nil {
+		ex.RequestSize = len(reqBody)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		ex.Error = err.Error()
+	} else {
+		ex.StatusCode = resp.StatusCode
+		ex.ResponseHeader = redactHeader(resp.Header)
+		if ex.ResponseBody = redactBody(resp.Header.Get("Content-Type"), respBody); ex.ResponseBody == nil && len(respBody) > 0 {
+			if recordRaw {
+				ex.ResponseRawBody = respBody
+			} else {
+				sum := sha256.Sum256(respBody)
+				ex.ResponseSize = len(respBody)
+				ex.ResponseSHA256 = hex.EncodeToString(sum[:])
+			}
+		}
+	}
+	if recorder != nil {
+		recorder.Encode(ex)
+	}
+}
+
+// redactHeader returns a copy of the header with the sensitive values
+// replaced.
+func redactHeader(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	rh := make(http.Header, len(h))
+	for k, v := range h {
+		rh[k] = append([]string(nil), v...)
+	}
+	for _, k := range sensitiveHeaders {
+		if _, ok := rh[k]; ok {
+			rh.Set(k, redacted)
+		}
+	}
+	return rh
+}
+
+// redactBody returns a JSON body with the secret values redacted, or nil if
+// the body is empty or it is not JSON.
+func redactBody(contentType string, body []byte) json.RawMessage {
+	var v interface{}
+	if len(body) == 0 || !strings.Contains(contentType, "json") || json.Unmarshal(body, &v) != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(redact(v, isSecret)); err != nil {
+		return nil
+	}
+	return bytes.TrimSpace(buf.Bytes())
+}
+
+// replay returns the recorded responses in order.
+type replay struct {
+	exchanges []*Exchange
+	next      int
+}
+
+func (r *replay) roundTrip(req *http.Request) (*http.Response, error) {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+
+	mu.Lock()
+	if r.next >= len(r.exchanges) {
+		mu.Unlock()
+		return nil, errors.Errorf("error replaying %s %s: there are no more recorded exchanges", req.Method, u.String())
+	}
+	ex := r.exchanges[r.next]
+	r.next++
+	mu.Unlock()
+
+	if ex.Method != req.Method || ex.URL != u.String() {
+		return nil, errors.Errorf("error replaying %s %s: the recorded request is %s %s", req.Method, u.String(), ex.Method, ex.URL)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if ex.Error != "" {
+		return nil, errors.New(ex.Error)
+	}
+
+	body := []byte(ex.ResponseBody)
+	if body == nil {
+		body = ex.ResponseRawBody
+	}
+	if body == nil && ex.ResponseSize > 0 {
+		return nil, errors.Errorf("error replaying %s %s: the response body was not recorded, record it with --record-raw", req.Method, u.String())
+	}
+	// The body might be redacted, so the recorded length is not valid.
+	header := make(http.Header, len(ex.ResponseHeader))
+	for k, v := range ex.ResponseHeader {
+		header[k] = v
+	}
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", ex.StatusCode, http.StatusText(ex.StatusCode)),
+		StatusCode:    ex.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// isSecret returns true if the JSON property is one of the secretProperties.
+func isSecret(key string) bool {
+	for _, s := range secretProperties {
+		if strings.EqualFold(key, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+// Package trace implements the tracing of the HTTP requests that step sends
+// to the CA. Every request is sent with a User-Agent and a unique request ID
+// header, so the server logs can be correlated with the client failures.
+// Requests and responses are also logged if tracing is enabled with the global
+// flag --trace or the environment variable STEPDEBUG=1, this is used to
+// diagnose the errors returned by the CA. The requests and responses can also
+// be recorded to a file and replayed later, see Record and Replay.
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smallstep/cli/crypto/randutil"
+	"github.com/smallstep/cli/netconfig"
+	"github.com/smallstep/cli/timing"
+)
+
+// RequestIDHeader is the header with the unique ID of a request.
+const RequestIDHeader = "X-Request-Id"
+
+// maxBodySize is the maximum number of bytes of a body that are logged.
+const maxBodySize = 4096
+
+// redacted is the value used to replace the sensitive values in a body.
+const redacted = "<redacted>"
+
+// sensitiveKeys are the JSON properties whose values are not logged.
+var sensitiveKeys = []string{"ott", "token", "password", "secret", "key", "jwt", "assertion"}
+
+var (
+	mu              sync.Mutex
+	writer          io.Writer
+	userAgent       string
+	failedRequestID string
+)
+
+// Enable enables the tracing of HTTP requests to the given writer. The
+// http.DefaultTransport is also wrapped, so requests using the default client
+// are logged too.
+func Enable(w io.Writer) {
+	mu.Lock()
+	enabled := writer != nil
+	writer = w
+	mu.Unlock()
+	if !enabled {
+		http.DefaultTransport = Transport(http.DefaultTransport)
+	}
+}
+
+// Enabled returns true if the tracing of HTTP requests is enabled.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return writer != nil
+}
+
+// SetUserAgent sets the User-Agent header sent in the requests.
+func SetUserAgent(ua string) {
+	mu.Lock()
+	userAgent = ua
+	mu.Unlock()
+}
+
+// FailedRequestID returns the ID of the last request that failed, or that
+// returned an error status code. It returns an empty string if there are no
+// failed requests.
+func FailedRequestID() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return failedRequestID
+}
+
+// Transport returns an http.RoundTripper that adds the User-Agent and request
+// ID headers to the requests of the given one, and that logs the requests and
+// responses if tracing is enabled. If the given one is an *http.Transport, it
+// is also configured with the network options in the netconfig package.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	switch t := rt.(type) {
+	case *transport:
+		return rt
+	case *http.Transport:
+		netconfig.Apply(t)
+	}
+	return &transport{next: rt}
+}
+
+type transport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the original request.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+2)
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+	if ua := getUserAgent(); ua != "" {
+		r.Header.Set("User-Agent", ua)
+	}
+	requestID := r.Header.Get(RequestIDHeader)
+	if requestID == "" {
+		var err error
+		if requestID, err = randutil.Hex(32); err != nil {
+			return nil, err
+		}
+		r.Header.Set(RequestIDHeader, requestID)
+	}
+	req = r
+
+	// The query is not logged, it might have sensitive values
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	defer timing.Start(timing.Network, req.Method+" "+u.String())()
+
+	if r := getReplayer(); r != nil {
+		resp, err := r.roundTrip(req)
+		if err != nil || resp.StatusCode >= http.StatusBadRequest {
+			setFailedRequestID(requestID)
+		}
+		return resp, err
+	}
+
+	tracing, recording := Enabled(), isRecording()
+	if !tracing && !recording {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || resp.StatusCode >= http.StatusBadRequest {
+			setFailedRequestID(requestID)
+		}
+		return resp, err
+	}
+
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	var b strings.Builder
+	u = *req.URL
+	u.User = nil
+	u.RawQuery = redactQuery(u.RawQuery)
+	fmt.Fprintf(&b, "--> %s %s (request id %s)\n", req.Method, u.String(), requestID)
+	writeBody(&b, req.Header.Get("Content-Type"), reqBody)
+	if err != nil {
+		setFailedRequestID(requestID)
+		fmt.Fprintf(&b, "<-- error (%s): %v\n", elapsed, err)
+		if tracing {
+			write(b.String())
+		}
+		if recording {
+			record(req, reqBody, nil, nil, err)
+		}
+		return nil, err
+	}
+
+	respBody, rerr := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	if resp.StatusCode >= http.StatusBadRequest {
+		setFailedRequestID(requestID)
+	}
+	fmt.Fprintf(&b, "<-- %s (%s)\n", resp.Status, elapsed)
+	writeBody(&b, resp.Header.Get("Content-Type"), respBody)
+	if tracing {
+		write(b.String())
+	}
+	if recording && rerr == nil {
+		record(req, reqBody, resp, respBody, nil)
+	}
+	if rerr != nil {
+		return nil, rerr
+	}
+	return resp, nil
+}
+
+func getUserAgent() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return userAgent
+}
+
+func setFailedRequestID(id string) {
+	mu.Lock()
+	failedRequestID = id
+	mu.Unlock()
+}
+
+func write(s string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if writer != nil {
+		io.WriteString(writer, s)
+	}
+}
+
+// writeBody writes the body with the sensitive values redacted. Only JSON
+// bodies are written, for any other content only the size is written.
+func writeBody(b *strings.Builder, contentType string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	var v interface{}
+	if !strings.Contains(contentType, "json") || json.Unmarshal(body, &v) != nil {
+		fmt.Fprintf(b, "<%d bytes>\n", len(body))
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(Redact(v)); err != nil {
+		fmt.Fprintf(b, "<%d bytes>\n", len(body))
+		return
+	}
+	data := bytes.TrimSpace(buf.Bytes())
+	if len(data) > maxBodySize {
+		fmt.Fprintf(b, "%s... <%d bytes>\n", data[:maxBodySize], len(data))
+		return
+	}
+	fmt.Fprintf(b, "%s\n", data)
+}
+
+// redactQuery returns the given query with the values of the sensitive
+// parameters replaced.
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redacted
+	}
+	for k, v := range q {
+		if isSensitive(k) {
+			for i := range v {
+				v[i] = redacted
+			}
+		}
+	}
+	return q.Encode()
+}
+
+// Redact returns a copy of the given JSON value with the values of the
+// sensitive properties replaced.
+func Redact(v interface{}) interface{} {
+	return redact(v, isSensitive)
+}
+
+// redact returns a copy of the given JSON value with the values of the
+// properties matched by sensitive replaced.
+func redact(v interface{}, sensitive func(string) bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			if sensitive(k) {
+				m[k] = redacted
+			} else {
+				m[k] = redact(val, sensitive)
+			}
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = redact(val, sensitive)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/chzyer/readline"
+	"github.com/manifoldco/promptui"
+	"github.com/smallstep/cli/i18n"
+)
+
+// NoColorEnv defines the name of the environment variable that disables the
+// colors in the output, see https://no-color.org.
+const NoColorEnv = "NO_COLOR"
+
+var (
+	colorMu       sync.Mutex
+	colorDisabled bool
+	colorFuncs    template.FuncMap
+)
+
+func init() {
+	colorFuncs = make(template.FuncMap, len(promptui.FuncMap))
+	for k, v := range promptui.FuncMap {
+		colorFuncs[k] = v
+	}
+	updateColor()
+}
+
+// DisableColor disables the colors in all the output, it's used by the
+// --no-color flag.
+func DisableColor() {
+	colorMu.Lock()
+	colorDisabled = true
+	colorMu.Unlock()
+	updateColor()
+}
+
+// ColorEnabled returns true if the output written to w can be colored. Colors
+// are disabled using DisableColor, the NO_COLOR environment variable, with
+// TERM=dumb, or if w is a file that is not a terminal.
+func ColorEnabled(w io.Writer) bool {
+	colorMu.Lock()
+	disabled := colorDisabled
+	colorMu.Unlock()
+	if disabled || os.Getenv(NoColorEnv) != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if f, ok := w.(*os.File); ok {
+		return readline.IsTerminal(int(f.Fd()))
+	}
+	return true
+}
+
+// updateColor updates the icons and the template functions used in the
+// prompts and in Printf with the current color settings of the standard error.
+func updateColor() {
+	enabled := ColorEnabled(os.Stderr)
+	for k, v := range colorFuncs {
+		if enabled {
+			promptui.FuncMap[k] = v
+		} else {
+			promptui.FuncMap[k] = fmt.Sprint
+		}
+	}
+	style := func(attrs ...promptui.Attribute) func(interface{}) string {
+		if enabled {
+			return promptui.Styler(attrs...)
+		}
+		return func(v interface{}) string {
+			return fmt.Sprint(v)
+		}
+	}
+	IconInitial = style(promptui.FGBlue)("?")
+	IconGood = style(promptui.FGGreen)("✔")
+	IconWarn = style(promptui.FGYellow)("⚠")
+	IconBad = style(promptui.FGRed)("✗")
+	IconSelect = style(promptui.FGBold)("▸")
+}
+
+// colorize returns s with the given attributes if the output written to w can
+// be colored.
+func colorize(w io.Writer, s string, attrs ...promptui.Attribute) string {
+	if !ColorEnabled(w) {
+		return s
+	}
+	return promptui.Styler(attrs...)(s)
+}
+
+// Success returns s styled as the result of a successful operation when it's
+// written to w.
+func Success(w io.Writer, s string) string {
+	return colorize(w, s, promptui.FGGreen)
+}
+
+// Warning returns s styled as a warning when it's written to w.
+func Warning(w io.Writer, s string) string {
+	return colorize(w, s, promptui.FGYellow, promptui.FGBold)
+}
+
+// Failure returns s styled as an error when it's written to w.
+func Failure(w io.Writer, s string) string {
+	return colorize(w, s, promptui.FGRed, promptui.FGBold)
+}
+
+// Emphasis returns s styled as a label or a title when it's written to w.
+func Emphasis(w io.Writer, s string) string {
+	return colorize(w, s, promptui.FGBold)
+}
+
+// Warnf translates and formats the given message and prints it to os.Stderr
+// as a warning.
+func Warnf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "%s %s\n", Warning(os.Stderr, i18n.T("warning:")), i18n.Sprintf(format, args...))
+}
+
+// labelRe matches the labels in text outputs like the one of step certificate
+// inspect, a label starts with an uppercase letter and has at least one
+// lowercase letter, so hexadecimal strings or values like CA:TRUE don't match.
+var labelRe = regexp.MustCompile(`(?m)^(\s*)([A-Z][A-Za-z0-9 ./()-]*[a-z][A-Za-z0-9 ./()-]*:)`)
+
+// EmphasizeLabels returns the given text with the labels at the beginning of
+// the lines styled with Emphasis when it's written to w.
+func EmphasizeLabels(w io.Writer, text string) string {
+	if !ColorEnabled(w) {
+		return text
+	}
+	return labelRe.ReplaceAllStringFunc(text, func(s string) string {
+		label := strings.TrimLeft(s, " \t")
+		return s[:len(s)-len(label)] + promptui.Styler(promptui.FGBold)(label)
+	})
+}
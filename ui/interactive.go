@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+
+	"github.com/chzyer/readline"
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/errs"
+)
+
+// NonInteractiveEnv defines the name of the environment variable that can be
+// used to disable all the prompts.
+const NonInteractiveEnv = "STEPNOINTERACTIVE"
+
+// PromptTimeoutEnv defines the name of the environment variable that can be
+// used to set the maximum time to wait for a prompt.
+const PromptTimeoutEnv = "STEPPROMPTTIMEOUT"
+
+// PasswordFileEnv defines the name of the environment variable that can be
+// used to set the file with the password used by the password prompts in
+// non-interactive mode.
+const PasswordFileEnv = "STEPPASSWORDFILE"
+
+var (
+	nonInteractive bool
+	promptTimeout  time.Duration
+	passwordFile   string
+)
+
+// SetNonInteractive enables or disables the non-interactive mode. In
+// non-interactive mode all the prompts will fail unless a value has been
+// provided.
+func SetNonInteractive(b bool) {
+	nonInteractive = b
+}
+
+// IsNonInteractive returns true if the non-interactive mode has been enabled.
+func IsNonInteractive() bool {
+	return nonInteractive
+}
+
+// SetPromptTimeout sets the maximum time to wait for the user to complete a
+// prompt. A zero or negative duration disables the timeout.
+func SetPromptTimeout(d time.Duration) {
+	promptTimeout = d
+}
+
+// PromptTimeout returns the maximum time to wait for a prompt.
+func PromptTimeout() time.Duration {
+	return promptTimeout
+}
+
+// SetPasswordFile sets the file with the password used by the password
+// prompts in non-interactive mode. Without it, password prompts fail like any
+// other prompt.
+func SetPasswordFile(filename string) {
+	passwordFile = filename
+}
+
+// PasswordFile returns the file with the password used by the password prompts
+// in non-interactive mode.
+func PasswordFile() string {
+	return passwordFile
+}
+
+// nonInteractivePassword returns the password in the file set with
+// SetPasswordFile, trimmed at the right, or the non-interactive error if no
+// file has been set.
+func nonInteractivePassword(label string) ([]byte, error) {
+	if passwordFile == "" {
+		return nil, nonInteractiveError(label)
+	}
+	b, err := ioutil.ReadFile(passwordFile)
+	if err != nil {
+		return nil, errs.FileError(err, passwordFile)
+	}
+	return bytes.TrimRightFunc(b, unicode.IsSpace), nil
+}
+
+// nonInteractiveError returns the error used when a prompt is required in
+// non-interactive mode.
+func nonInteractiveError(label string) error {
+	return errs.Usage(errors.Errorf("cannot prompt for '%s' in non-interactive mode; use the command flags to provide the value",
+		strings.TrimRight(strings.TrimSpace(label), ":")))
+}
+
+// runWithTimeout runs the given prompt function, if a prompt timeout has been
+// set, it will return an error if the function does not finish on time.
+func runWithTimeout(label string, fn func() error) error {
+	if promptTimeout <= 0 {
+		return fn()
+	}
+
+	// Save the state of the terminal so we can restore it on timeouts.
+	var restore func()
+	fd := int(syscall.Stdin)
+	if readline.IsTerminal(fd) {
+		if state, err := readline.GetState(fd); err == nil {
+			restore = func() {
+				readline.Restore(fd, state)
+			}
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(promptTimeout):
+		if restore != nil {
+			restore()
+		}
+		os.Stderr.WriteString("\n")
+		return errs.Usage(errors.Errorf("timeout after %s waiting for '%s'",
+			promptTimeout, strings.TrimRight(strings.TrimSpace(label), ":")))
+	}
+}
@@ -9,6 +9,7 @@ type options struct {
 	defaultValue    string
 	value           string
 	allowEdit       bool
+	quiet           bool
 	printTemplate   string
 	promptTemplates *promptui.PromptTemplates
 	selectTemplates *promptui.SelectTemplates
@@ -77,6 +78,13 @@ func WithAllowEdit(b bool) Option {
 	}
 }
 
+// WithQuiet if true, disables the progress indicators.
+func WithQuiet(b bool) Option {
+	return func(o *options) {
+		o.quiet = b
+	}
+}
+
 // WithPrintTemplate sets the template to use on the print methods.
 func WithPrintTemplate(template string) Option {
 	return func(o *options) {
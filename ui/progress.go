@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/chzyer/readline"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/i18n"
+)
+
+// progressInterval is the minimum time between two renders of a progress
+// indicator.
+const progressInterval = 100 * time.Millisecond
+
+// spinnerFrames are the frames used to render a spinner.
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// progressEnabled returns true if the progress indicators can be displayed.
+// Progress indicators are suppressed if the standard output is not a terminal
+// or if JSON output has been requested.
+func progressEnabled(o *options) bool {
+	return !o.quiet && !errs.IsJSONFormat() && readline.IsTerminal(syscall.Stdout)
+}
+
+// clearLine clears the current line in os.Stderr.
+func clearLine() {
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
+
+// Spinner is a progress indicator for operations with an unknown duration.
+type Spinner struct {
+	message string
+	enabled bool
+	once    sync.Once
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewSpinner creates a new spinner with the given message. The spinner will
+// not be displayed if the standard output is not a terminal, if JSON output
+// has been requested or if the WithQuiet option is used.
+func NewSpinner(message string, opts ...Option) *Spinner {
+	o := new(options).apply(opts)
+	return &Spinner{
+		message: i18n.T(message),
+		enabled: progressEnabled(o),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start starts rendering the spinner in a new goroutine.
+func (s *Spinner) Start() *Spinner {
+	if !s.enabled {
+		close(s.done)
+		return s
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			fmt.Fprintf(os.Stderr, "\r%s %s", spinnerFrames[i%len(spinnerFrames)], s.message)
+			select {
+			case <-s.stop:
+				clearLine()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// Stop stops the spinner and clears the line. It is safe to call Stop more
+// than once.
+func (s *Spinner) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+// Progress is a progress indicator for operations with a known number of
+// steps or bytes.
+type Progress struct {
+	label   string
+	total   int64
+	current int64
+	enabled bool
+	last    time.Time
+	mu      sync.Mutex
+}
+
+// NewProgress creates a new progress indicator with the given label and total.
+// If the total is not known, a zero or a negative value can be used. The
+// progress will not be displayed if the standard output is not a terminal, if
+// JSON output has been requested or if the WithQuiet option is used.
+func NewProgress(label string, total int64, opts ...Option) *Progress {
+	o := new(options).apply(opts)
+	return &Progress{
+		label:   i18n.T(label),
+		total:   total,
+		enabled: progressEnabled(o),
+	}
+}
+
+// Add increments the progress by n and renders it.
+func (p *Progress) Add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current += n
+	if !p.enabled {
+		return
+	}
+	if now := time.Now(); now.Sub(p.last) >= progressInterval || p.current == p.total {
+		p.last = now
+		p.render()
+	}
+}
+
+// Done clears the progress indicator.
+func (p *Progress) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.enabled && !p.last.IsZero() {
+		clearLine()
+	}
+}
+
+// Reader returns an io.Reader that increments the progress with the bytes
+// read from r.
+func (p *Progress) Reader(r io.Reader) io.Reader {
+	return &progressReader{Reader: r, progress: p}
+}
+
+// render writes the progress to os.Stderr, it must be called with the lock.
+func (p *Progress) render() {
+	if p.total <= 0 {
+		fmt.Fprintf(os.Stderr, "\r%s %d", p.label, p.current)
+		return
+	}
+	const width = 30
+	current := p.current
+	if current > p.total {
+		current = p.total
+	}
+	n := int(int64(width) * current / p.total)
+	bar := strings.Repeat("=", n) + strings.Repeat(" ", width-n)
+	fmt.Fprintf(os.Stderr, "\r%s [%s] %3d%% (%d/%d)", p.label, bar, 100*current/p.total, current, p.total)
+}
+
+// progressReader is an io.Reader that updates a progress indicator.
+type progressReader struct {
+	io.Reader
+	progress *Progress
+}
+
+// Read implements the io.Reader interface.
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if n > 0 {
+		r.progress.Add(int64(n))
+	}
+	return n, err
+}
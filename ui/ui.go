@@ -5,11 +5,14 @@ import (
 	"os"
 	"syscall"
 	"text/template"
+	"unicode/utf8"
 
 	"github.com/chzyer/readline"
 	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	"github.com/smallstep/cli/crypto/randutil"
+	"github.com/smallstep/cli/crypto/securebuf"
+	"github.com/smallstep/cli/i18n"
 )
 
 // stderr implements an io.WriteCloser that skips the terminal bell character
@@ -35,9 +38,10 @@ func init() {
 	readline.Stdout = &stderr{}
 }
 
-// Printf uses templates to print the string formated to os.Stderr.
+// Printf uses templates to print the string formated to os.Stderr. The format
+// is translated using the message catalog of the current language.
 func Printf(format string, args ...interface{}) error {
-	text := fmt.Sprintf(format, args...)
+	text := i18n.Sprintf(format, args...)
 	t, err := template.New("Printf").Funcs(promptui.FuncMap).Parse(text)
 	if err != nil {
 		return errors.Wrap(err, "error parsing template")
@@ -77,7 +81,7 @@ func PrintSelected(name, value string, opts ...Option) error {
 	data := struct {
 		Name  string
 		Value string
-	}{name, value}
+	}{i18n.T(name), value}
 	if err := t.Execute(os.Stderr, data); err != nil {
 		return errors.Wrap(err, "error executing template")
 	}
@@ -97,6 +101,11 @@ func Prompt(label string, opts ...Option) (string, error) {
 		return o.getValue()
 	}
 
+	// Fail if prompts are disabled
+	if nonInteractive {
+		return "", nonInteractiveError(label)
+	}
+
 	// Prompt using the terminal
 	clean, err := preparePromptTerminal()
 	if err != nil {
@@ -105,13 +114,17 @@ func Prompt(label string, opts ...Option) (string, error) {
 	defer clean()
 
 	prompt := &promptui.Prompt{
-		Label:     label,
+		Label:     i18n.T(label),
 		Default:   o.defaultValue,
 		AllowEdit: o.allowEdit,
 		Validate:  o.validateFunc,
 		Templates: o.promptTemplates,
 	}
-	value, err := prompt.Run()
+	var value string
+	err = runWithTimeout(label, func() (err error) {
+		value, err = prompt.Run()
+		return
+	})
 	if err != nil {
 		return "", errors.Wrap(err, "error running prompt")
 	}
@@ -133,6 +146,20 @@ func PromptPassword(label string, opts ...Option) ([]byte, error) {
 		return o.getValueBytes()
 	}
 
+	// Use the password file or fail if prompts are disabled
+	if nonInteractive {
+		pass, err := nonInteractivePassword(label)
+		if err != nil {
+			return nil, err
+		}
+		if o.validateFunc != nil {
+			if err := o.validateFunc(string(pass)); err != nil {
+				return nil, err
+			}
+		}
+		return pass, nil
+	}
+
 	// Prompt using the terminal
 	clean, err := preparePromptTerminal()
 	if err != nil {
@@ -141,20 +168,117 @@ func PromptPassword(label string, opts ...Option) ([]byte, error) {
 	defer clean()
 
 	prompt := &promptui.Prompt{
-		Label:     label,
+		Label:     i18n.T(label),
 		Mask:      o.mask,
 		Default:   o.defaultValue,
 		AllowEdit: o.allowEdit,
 		Validate:  o.validateFunc,
 		Templates: o.promptTemplates,
 	}
-	pass, err := prompt.Run()
+	var pass string
+	err = runWithTimeout(label, func() (err error) {
+		pass, err = prompt.Run()
+		return
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "error reading password")
 	}
 	return []byte(pass), nil
 }
 
+// PromptPasswordBuffer is like PromptPassword but it returns the password in
+// a locked buffer that the caller must destroy after using it. The password is
+// read from the terminal directly into the buffer, so it is never copied to
+// regular memory. Values set with WithValue and prompts with a validation
+// function use PromptPassword.
+func PromptPasswordBuffer(label string, opts ...Option) (*securebuf.Buffer, error) {
+	o := &options{}
+	o.apply(opts)
+	if o.value != "" || o.validateFunc != nil || nonInteractive {
+		pass, err := PromptPassword(label, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return securebuf.FromBytes(pass), nil
+	}
+
+	tty := os.Stdin
+	if !readline.IsTerminal(int(tty.Fd())) {
+		f, err := os.Open("/dev/tty")
+		if err != nil {
+			return nil, errors.Wrap(err, "error allocating terminal")
+		}
+		defer f.Close()
+		tty = f
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: ", i18n.T(label))
+	var pass *securebuf.Buffer
+	err := runWithTimeout(label, func() (err error) {
+		pass, err = readPassword(tty)
+		os.Stderr.WriteString("\n")
+		return
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading password")
+	}
+	return pass, nil
+}
+
+// maxPasswordSize is the maximum size of a password read from the terminal.
+const maxPasswordSize = 1024
+
+// readPassword reads a line from the given terminal in raw mode, without
+// echoing it, into a locked buffer. Backspace deletes the last character and
+// ctrl-u the whole line.
+func readPassword(tty *os.File) (*securebuf.Buffer, error) {
+	fd := int(tty.Fd())
+	state, err := readline.MakeRaw(fd)
+	if err != nil {
+		return nil, errors.Wrap(err, "error making raw terminal")
+	}
+	defer readline.Restore(fd, state)
+
+	buf := securebuf.New(maxPasswordSize)
+	defer buf.Destroy()
+	b := buf.Bytes()
+
+	var c [1]byte
+	defer securebuf.Zero(c[:])
+	for n := 0; ; {
+		if _, err := tty.Read(c[:]); err != nil {
+			return nil, err
+		}
+		switch c[0] {
+		case readline.CharEnter, readline.CharCtrlJ:
+			return securebuf.FromBytes(b[:n]), nil
+		case readline.CharInterrupt:
+			return nil, promptui.ErrInterrupt
+		case readline.CharDelete:
+			if n == 0 {
+				return nil, promptui.ErrEOF
+			}
+		case readline.CharBackspace, readline.CharCtrlH:
+			for n > 0 {
+				n--
+				if utf8.RuneStart(b[n]) {
+					break
+				}
+			}
+			securebuf.Zero(b[n:])
+		case readline.CharCtrlU:
+			securebuf.Zero(b[:n])
+			n = 0
+		default:
+			if n == len(b) {
+				return nil, errors.Errorf("password is longer than %d bytes", maxPasswordSize)
+			}
+			b[n] = c[0]
+			n++
+		}
+	}
+}
+
 // PromptPasswordGenerate creaes a runs a promptui.Prompt with the given label.
 // This prompt will mask the key entries with \r. If the result password length
 // is 0, it will generate a new prompt with a generated password that can be
@@ -182,6 +306,11 @@ func Select(label string, items interface{}, opts ...Option) (int, string, error
 	}
 	o.apply(opts)
 
+	// Fail if prompts are disabled
+	if nonInteractive {
+		return 0, "", nonInteractiveError(label)
+	}
+
 	clean, err := prepareSelectTerminal()
 	if err != nil {
 		return 0, "", err
@@ -189,11 +318,16 @@ func Select(label string, items interface{}, opts ...Option) (int, string, error
 	defer clean()
 
 	prompt := &promptui.Select{
-		Label:     label,
+		Label:     i18n.T(label),
 		Items:     items,
 		Templates: o.selectTemplates,
 	}
-	n, s, err := prompt.Run()
+	var n int
+	var s string
+	err = runWithTimeout(label, func() (err error) {
+		n, s, err = prompt.Run()
+		return
+	})
 	if err != nil {
 		return 0, "", errors.Wrap(err, "error running prompt")
 	}
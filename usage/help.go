@@ -37,6 +37,10 @@ func HelpCommand() cli.Command {
 				Name:  "markdown",
 				Usage: "The export <directory> for Markdown docs.",
 			},
+			cli.StringFlag{
+				Name:  "man",
+				Usage: "The export <directory> for man pages.",
+			},
 			cli.BoolFlag{
 				Name:  "report",
 				Usage: "Writes a JSON report to the HTML docs directory.",
@@ -59,6 +63,10 @@ func helpAction(ctx *cli.Context) error {
 		return markdownHelpAction(ctx)
 	}
 
+	if ctx.IsSet("man") {
+		return manHelpAction(ctx)
+	}
+
 	args := ctx.Args()
 	if args.Present() {
 		last := len(args) - 1
@@ -0,0 +1,253 @@
+package usage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smallstep/cli/errs"
+	md "github.com/smallstep/cli/pkg/blackfriday"
+	"github.com/urfave/cli"
+)
+
+// manSection is the section of the manual used for the step commands.
+const manSection = "1"
+
+func manHelpAction(ctx *cli.Context) error {
+	dir := path.Clean(ctx.String("man"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errs.FileError(err, dir)
+	}
+
+	// app page
+	name := ctx.App.Name + "." + manSection
+	if err := writeManPage(path.Join(dir, name), ctx.App.Name, mdAppHelpTemplate, ctx.App); err != nil {
+		return err
+	}
+
+	// Subcommands
+	for _, cmd := range ctx.App.Commands {
+		if cmd.Hidden {
+			continue
+		}
+		cmd.HelpName = fmt.Sprintf("%s %s", ctx.App.HelpName, cmd.Name)
+		if err := manHelpCommand(ctx.App, cmd, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func manHelpCommand(app *cli.App, cmd cli.Command, dir string) error {
+	title := strings.Replace(cmd.HelpName, " ", "-", -1)
+	filename := path.Join(dir, title+"."+manSection)
+
+	if len(cmd.Subcommands) == 0 {
+		return writeManPage(filename, title, mdCommandHelpTemplate, cmd)
+	}
+
+	ctx := cli.NewContext(app, nil, nil)
+	ctx.App = createCliApp(ctx, cmd)
+	if err := writeManPage(filename, title, mdSubcommandHelpTemplate, ctx.App); err != nil {
+		return err
+	}
+
+	for _, sub := range cmd.Subcommands {
+		if sub.Hidden {
+			continue
+		}
+		sub.HelpName = fmt.Sprintf("%s %s", cmd.HelpName, sub.Name)
+		if err := manHelpCommand(app, sub, dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeManPage renders the help of the given command or app in the roff
+// format and writes it to filename.
+func writeManPage(filename, title, templ string, data interface{}) error {
+	w, err := os.Create(filename)
+	if err != nil {
+		return errs.FileError(err, filename)
+	}
+	b := helpPreprocessor(w, templ, data)
+	if _, err := w.Write(RenderMan(title, b)); err != nil {
+		w.Close()
+		return errs.FileError(err, filename)
+	}
+	return errs.FileError(w.Close(), filename)
+}
+
+// RenderMan renders the given markdown as a man page with the given title.
+func RenderMan(title string, b []byte) []byte {
+	out := md.Run(b, md.WithRenderer(&ManRenderer{Title: title}))
+	// Empty lines are rendered as vertical spaces in roff, they are removed
+	// except in the no-fill blocks, where they are part of the examples.
+	lines := bytes.Split(out, []byte("\n"))
+	result := make([][]byte, 0, len(lines))
+	var nofill bool
+	for _, l := range lines {
+		switch {
+		case bytes.Equal(l, []byte(".nf")):
+			nofill = true
+		case bytes.Equal(l, []byte(".fi")):
+			nofill = false
+		case !nofill && len(bytes.TrimSpace(l)) == 0:
+			continue
+		}
+		result = append(result, l)
+	}
+	return append(bytes.Join(result, []byte("\n")), '\n')
+}
+
+// ManRenderer implements a markdown renderer for blackfriday that generates
+// man pages in the roff format.
+type ManRenderer struct {
+	Title     string
+	listdepth int
+	ordered   []int
+}
+
+// manEscape escapes the characters with a special meaning in roff.
+func manEscape(b []byte) []byte {
+	b = bytes.Replace(b, []byte(`\`), []byte(`\e`), -1)
+	b = bytes.Replace(b, []byte("-"), []byte(`\-`), -1)
+	// Lines starting with a dot or an apostrophe are control lines.
+	lines := bytes.Split(b, []byte("\n"))
+	for i, l := range lines {
+		if len(l) > 0 && (l[0] == '.' || l[0] == '\'') {
+			lines[i] = append([]byte(`\&`), l...)
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// RenderNode implements blackfriday.Renderer interface.
+func (r *ManRenderer) RenderNode(w io.Writer, node *md.Node, entering bool) md.WalkStatus {
+	switch node.Type {
+	case md.Heading:
+		if entering {
+			if node.Level <= 2 {
+				fmt.Fprint(w, "\n.SH ")
+			} else {
+				fmt.Fprint(w, "\n.SS ")
+			}
+		} else {
+			fmt.Fprint(w, "\n")
+		}
+	case md.Paragraph:
+		if entering {
+			if node.Parent.Type != md.Item {
+				fmt.Fprint(w, "\n.PP\n")
+			}
+		} else {
+			fmt.Fprint(w, "\n")
+		}
+	case md.Text:
+		w.Write(manEscape(node.Literal))
+	case md.Strong:
+		if entering {
+			fmt.Fprint(w, `\fB`)
+		} else {
+			fmt.Fprint(w, `\fR`)
+		}
+	case md.Emph, md.Link:
+		if entering {
+			fmt.Fprint(w, `\fI`)
+		} else {
+			fmt.Fprint(w, `\fR`)
+		}
+	case md.Code:
+		fmt.Fprint(w, `\fI`)
+		w.Write(manEscape(node.Literal))
+		fmt.Fprint(w, `\fR`)
+	case md.CodeBlock:
+		fmt.Fprint(w, "\n.PP\n.RS\n.nf\n")
+		w.Write(manEscape(bytes.TrimRight(node.Literal, "\n")))
+		fmt.Fprint(w, "\n.fi\n.RE\n")
+	case md.Softbreak:
+		fmt.Fprint(w, "\n")
+	case md.Hardbreak:
+		fmt.Fprint(w, "\n.br\n")
+	case md.List:
+		if entering {
+			r.listdepth++
+			r.ordered = append(r.ordered, 0)
+			if r.listdepth > 1 {
+				fmt.Fprint(w, "\n.RS\n")
+			}
+		} else {
+			r.listdepth--
+			r.ordered = r.ordered[:len(r.ordered)-1]
+			if r.listdepth > 0 {
+				fmt.Fprint(w, "\n.RE\n")
+			}
+		}
+	case md.Item:
+		if !entering {
+			break
+		}
+		switch {
+		case node.ListFlags&md.ListTypeTerm != 0:
+			fmt.Fprint(w, "\n.TP\n")
+		case node.ListFlags&md.ListTypeDefinition != 0:
+			fmt.Fprint(w, "\n")
+		case node.ListFlags&md.ListTypeOrdered != 0:
+			r.ordered[len(r.ordered)-1]++
+			fmt.Fprintf(w, "\n.IP \"%d.\" 4\n", r.ordered[len(r.ordered)-1])
+		default:
+			fmt.Fprint(w, "\n.IP \\(bu 2\n")
+		}
+	case md.Table:
+		if entering {
+			fmt.Fprint(w, "\n.PP\n.nf\n")
+		} else {
+			fmt.Fprint(w, ".fi\n")
+		}
+	case md.TableRow:
+		if !entering {
+			fmt.Fprint(w, "\n")
+		}
+	case md.TableCell:
+		if !entering && node.Next != nil {
+			fmt.Fprint(w, "\t")
+		}
+	case md.HorizontalRule:
+		fmt.Fprint(w, "\n.PP\n")
+	case md.Document, md.TableHead, md.TableBody, md.BlockQuote, md.Del:
+	case md.HTMLBlock, md.HTMLSpan, md.Image:
+	default:
+		w.Write(manEscape(node.Literal))
+	}
+	return md.GoToNext
+}
+
+// RenderHeader implements blackfriday.Renderer interface.
+func (r *ManRenderer) RenderHeader(w io.Writer, ast *md.Node) {
+	fmt.Fprintf(w, ".TH %q %s %q %q %q\n", strings.ToUpper(r.Title), manSection,
+		manDate().Format("January 2006"), "step", "step manual")
+}
+
+// manDate returns the date used in the man pages. It will use the
+// SOURCE_DATE_EPOCH environment variable if available to allow reproducible
+// builds.
+func manDate() time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if sec, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC()
+		}
+	}
+	return time.Now().UTC()
+}
+
+// RenderFooter implements blackfriday.Renderer interface.
+func (r *ManRenderer) RenderFooter(w io.Writer, ast *md.Node) {
+	fmt.Fprint(w, "\n")
+}
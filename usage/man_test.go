@@ -0,0 +1,36 @@
+package usage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderMan(t *testing.T) {
+	src := "## NAME\n\n**step-foo** -- do foo\n\n" +
+		"## DESCRIPTION\n\nFirst paragraph.\n\nSecond paragraph.\n\n" +
+		"## EXAMPLES\n\nRun foo twice:\n```\n$ step foo\n\n$ step foo --bar\n```\n"
+	out := string(RenderMan("step-foo", []byte(src)))
+
+	require.True(t, strings.HasPrefix(out, `.TH "STEP-FOO" 1 `))
+	require.Contains(t, out, ".SH NAME\n")
+	require.Contains(t, out, ".PP\nFirst paragraph.\n.PP\nSecond paragraph.\n")
+	// The empty line in the example is kept.
+	require.Contains(t, out, ".nf\n$ step foo\n\n$ step foo \\-\\-bar\n.fi\n")
+
+	// The rest of the empty lines are removed.
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	var nofill bool
+	for i, l := range lines {
+		switch {
+		case l == ".nf":
+			nofill = true
+		case l == ".fi":
+			nofill = false
+		case !nofill:
+			require.NotEmpty(t, strings.TrimSpace(l), "line %d is empty", i+1)
+		}
+	}
+	require.False(t, nofill)
+}
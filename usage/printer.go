@@ -9,6 +9,7 @@ import (
 	"text/template"
 
 	md "github.com/smallstep/cli/pkg/blackfriday"
+	"github.com/smallstep/cli/ui"
 	"github.com/urfave/cli"
 )
 
@@ -19,6 +20,10 @@ var sectionRe = regexp.MustCompile(`(?m:^##)`)
 // HelpPrinter overwrites cli.HelpPrinter and prints the formatted help to the terminal.
 func HelpPrinter(w io.Writer, templ string, data interface{}) {
 	b := helpPreprocessor(w, templ, data)
+	if !ui.ColorEnabled(w) {
+		w.Write(stripColors(Render(b)))
+		return
+	}
 	w.Write(Render(b))
 }
 
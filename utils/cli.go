@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"strings"
+
+	"github.com/smallstep/cli/crypto/keys"
 	"github.com/smallstep/cli/errs"
 	"github.com/urfave/cli"
 )
@@ -61,7 +64,29 @@ func GetKeyDetailsFromCLI(ctx *cli.Context, insecure bool, ktyKey, curveKey, siz
 					curveKey, crv, "Ed25519")
 			}
 		default:
-			return kty, crv, size, errs.InvalidFlagValue(ctx, ktyKey, kty, "RSA, EC, OKP")
+			// Other registered key types are defined by a list of curves or
+			// parameter sets, and experimental ones require an opt-in.
+			t, ok := keys.Lookup(kty)
+			if !ok || len(t.Curves) == 0 {
+				return kty, crv, size, errs.InvalidFlagValue(ctx, ktyKey, kty, "RSA, EC, OKP")
+			}
+			if t.Experimental && !ctx.Bool("experimental") {
+				return kty, crv, size, errs.RequiredWithFlagValue(ctx, ktyKey, kty, "experimental")
+			}
+			if ctx.IsSet("size") {
+				return kty, crv, size, errs.IncompatibleFlagValue(ctx, sizeKey, ktyKey, kty)
+			}
+			if crv == "" {
+				crv = t.Curves[0]
+			}
+			var found bool
+			for _, c := range t.Curves {
+				found = found || c == crv
+			}
+			if !found {
+				return kty, crv, size, errs.IncompatibleFlagValueWithFlagValue(ctx, ktyKey, kty,
+					curveKey, crv, strings.Join(t.Curves, ", "))
+			}
 		}
 	} else {
 		if ctx.IsSet(curveKey) {
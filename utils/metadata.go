@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/command"
+	"github.com/smallstep/cli/config"
+	"github.com/urfave/cli"
+)
+
+// MetadataExt is the extension added to the name of a key or certificate to
+// get the name of its metadata file.
+const MetadataExt = ".meta.json"
+
+// Metadata describes the provenance and the intended use of a key or
+// certificate. It is written next to the file with the --metadata flag so the
+// purpose of a key can be known long after it was created.
+type Metadata struct {
+	Created      time.Time            `json:"created"`
+	Command      string               `json:"command"`
+	Version      string               `json:"version,omitempty"`
+	Hostname     string               `json:"hostname,omitempty"`
+	User         string               `json:"user,omitempty"`
+	IntendedUse  string               `json:"intendedUse,omitempty"`
+	Subject      string               `json:"subject,omitempty"`
+	Provisioner  string               `json:"provisioner,omitempty"`
+	Fingerprints MetadataFingerprints `json:"fingerprints"`
+	Files        []string             `json:"files,omitempty"`
+}
+
+// MetadataFingerprints are the hex encoded SHA-256 fingerprints of the
+// certificate and of the public key.
+type MetadataFingerprints struct {
+	Certificate string `json:"certificate,omitempty"`
+	PublicKey   string `json:"publicKey,omitempty"`
+}
+
+// NewMetadata returns the metadata of a file created by the current command.
+// The command line does not include the flags, their values might be secrets
+// like tokens or passwords.
+func NewMetadata(ctx *cli.Context) *Metadata {
+	m := &Metadata{
+		Created:     time.Now().UTC().Truncate(time.Second),
+		Command:     strings.Join(append([]string{ctx.App.HelpName, ctx.Command.Name}, ctx.Args()...), " "),
+		Version:     config.Version(),
+		IntendedUse: ctx.String("intended-use"),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		m.Hostname = hostname
+	}
+	if u, err := user.Current(); err == nil {
+		m.User = u.Username
+	}
+	return m
+}
+
+// SetCertificate sets the subject and the fingerprints of the given
+// certificate.
+func (m *Metadata) SetCertificate(crt *x509.Certificate) {
+	sum := sha256.Sum256(crt.Raw)
+	m.Subject = crt.Subject.CommonName
+	m.Fingerprints.Certificate = hex.EncodeToString(sum[:])
+	m.SetPublicKey(crt.PublicKey)
+}
+
+// SetPublicKey sets the fingerprint of the given public key, the SHA-256 of
+// its PKIX encoding.
+func (m *Metadata) SetPublicKey(pub interface{}) {
+	m.Fingerprints.PublicKey = PublicKeyFingerprint(pub)
+}
+
+// PublicKeyFingerprint returns the hex encoded SHA-256 of the PKIX encoding of
+// the public key, or an empty string if the key cannot be encoded.
+func PublicKeyFingerprint(pub interface{}) string {
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteMetadata writes the metadata of filename in its metadata file. The
+// file is replaced without prompting, it is always written together with
+// filename.
+func WriteMetadata(filename string, m *Metadata) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling metadata")
+	}
+	perm := os.FileMode(0600)
+	if mode := command.FileMode(); mode != "" {
+		if perm, err = ParseFileMode(mode); err != nil {
+			return err
+		}
+	}
+	owner, group := command.FileOwner()
+	return WriteFileAtomic(filename+MetadataExt, append(b, '\n'), perm, owner, group)
+}
+
+// ReadMetadata reads the metadata file of filename.
+func ReadMetadata(filename string) (*Metadata, error) {
+	b, err := ioutil.ReadFile(filename + MetadataExt)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Metadata)
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename+MetadataExt)
+	}
+	return m, nil
+}
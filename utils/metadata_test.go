@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metadata")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	filename := filepath.Join(dir, "foo.key")
+	md := &Metadata{
+		Created:     time.Now().UTC().Truncate(time.Second),
+		Command:     "step crypto keypair foo.pub foo.key",
+		IntendedUse: "release signing",
+		Files:       []string{"foo.pub", "foo.key"},
+	}
+	md.SetPublicKey(key.Public())
+	require.Len(t, md.Fingerprints.PublicKey, 64)
+	require.NoError(t, WriteMetadata(filename, md))
+
+	got, err := ReadMetadata(filename)
+	require.NoError(t, err)
+	require.Equal(t, md, got)
+
+	// Keys without metadata.
+	_, err = ReadMetadata(filepath.Join(dir, "bar.key"))
+	require.True(t, os.IsNotExist(err))
+
+	// Invalid metadata.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bad.key"+MetadataExt), []byte("{"), 0600))
+	_, err = ReadMetadata(filepath.Join(dir, "bad.key"))
+	require.Error(t, err)
+
+	// Unsupported keys do not have a fingerprint.
+	require.Equal(t, "", PublicKeyFingerprint("not a key"))
+}
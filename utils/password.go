@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/flags"
+	"github.com/smallstep/cli/kms/uri"
+	"github.com/smallstep/cli/kms/vault"
+	"github.com/urfave/cli"
+)
+
+// ReadPasswordFromEnv reads and returns the password from the environment
+// variable with the given name. The value will be trimmed at the right.
+func ReadPasswordFromEnv(name string) ([]byte, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, errs.Usage(errors.Errorf("environment variable %s is not set", name))
+	}
+	return []byte(strings.TrimRightFunc(value, unicode.IsSpace)), nil
+}
+
+// ReadPasswordFromFd reads and returns the password from the given file
+// descriptor. The contents will be trimmed at the right.
+func ReadPasswordFromFd(fd int) ([]byte, error) {
+	if fd < 0 {
+		return nil, errs.Usage(errors.Errorf("invalid file descriptor %d", fd))
+	}
+	f := os.NewFile(uintptr(fd), "/dev/fd/"+strconv.Itoa(fd))
+	if f == nil {
+		return nil, errs.Usage(errors.Errorf("invalid file descriptor %d", fd))
+	}
+	defer f.Close()
+	password, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, errs.IO(errors.Wrapf(err, "error reading file descriptor %d", fd))
+	}
+	return bytes.TrimRightFunc(password, unicode.IsSpace), nil
+}
+
+// ReadPasswordFromKeychain reads and returns the password stored with the
+// given item name in the keychain of the operating system. On macOS it uses
+// the login keychain, on other unix systems it uses the Secret Service API
+// through secret-tool.
+func ReadPasswordFromKeychain(item string) ([]byte, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", item, "-w")
+	case "linux", "freebsd", "netbsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", item)
+	default:
+		return nil, errs.Usage(errors.Errorf("keychain is not supported on %s", runtime.GOOS))
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errs.IO(errors.Wrapf(err, "error reading '%s' from the keychain", item))
+	}
+	password := bytes.TrimRightFunc(out, unicode.IsSpace)
+	if len(password) == 0 {
+		return nil, errs.IO(errors.Errorf("error reading '%s' from the keychain: item not found", item))
+	}
+	return password, nil
+}
+
+// ReadPasswordFromVault reads and returns the password stored in a secret of
+// the Vault KV secrets engine. The path can include the field with the
+// password, e.g. secret/data/step?field=password, by default the field
+// "password" is used.
+func ReadPasswordFromVault(path string) ([]byte, error) {
+	u, err := uri.Parse(vault.Scheme + ":" + path)
+	if err != nil {
+		return nil, errs.Usage(err)
+	}
+	field := u.Get("field")
+	if field == "" {
+		field = "password"
+	}
+	password, err := vault.ReadSecret(u.Name, field)
+	if err != nil {
+		return nil, errs.IO(err)
+	}
+	return bytes.TrimRightFunc(password, unicode.IsSpace), nil
+}
+
+// GetPasswordFlag returns the name of the flag used to provide a password. It
+// returns an empty string if none of them has been used, and an error if more
+// than one has been used.
+func GetPasswordFlag(ctx *cli.Context) (string, error) {
+	var name string
+	for _, f := range flags.PasswordNames {
+		if !isPasswordFlagSet(ctx, f) {
+			continue
+		}
+		if name != "" {
+			return "", errs.MutuallyExclusiveFlags(ctx, name, f)
+		}
+		name = f
+	}
+	return name, nil
+}
+
+// ReadPasswordFromCLI reads the password from the flag used to provide it:
+// --password-file, --password-env, --password-fd, --password-keychain, or
+// --password-vault. It returns nil if none of them has been used.
+func ReadPasswordFromCLI(ctx *cli.Context) ([]byte, error) {
+	name, err := GetPasswordFlag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "password-file":
+		return ReadPasswordFromFile(ctx.String(name))
+	case "password-env":
+		return ReadPasswordFromEnv(ctx.String(name))
+	case "password-fd":
+		return ReadPasswordFromFd(ctx.Int(name))
+	case "password-keychain":
+		return ReadPasswordFromKeychain(ctx.String(name))
+	case "password-vault":
+		return ReadPasswordFromVault(ctx.String(name))
+	default:
+		return nil, nil
+	}
+}
+
+// ReadStringPasswordFromCLI is like ReadPasswordFromCLI but it returns the
+// password as a string.
+func ReadStringPasswordFromCLI(ctx *cli.Context) (string, error) {
+	b, err := ReadPasswordFromCLI(ctx)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func isPasswordFlagSet(ctx *cli.Context, name string) bool {
+	if name == "password-fd" {
+		return ctx.IsSet(name)
+	}
+	return ctx.String(name) != ""
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPasswordFromEnv(t *testing.T) {
+	os.Setenv("STEP_TEST_PASSWORD", "my-password-on-env\n")
+	defer os.Unsetenv("STEP_TEST_PASSWORD")
+
+	b, err := ReadPasswordFromEnv("STEP_TEST_PASSWORD")
+	require.NoError(t, err)
+	require.Equal(t, []byte("my-password-on-env"), b)
+
+	_, err = ReadPasswordFromEnv("STEP_TEST_PASSWORD_NOT_SET")
+	require.Error(t, err)
+}
+
+func TestReadPasswordFromFd(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	_, err = w.Write([]byte("my-password-on-fd\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	b, err := ReadPasswordFromFd(int(r.Fd()))
+	require.NoError(t, err)
+	require.Equal(t, []byte("my-password-on-fd"), b)
+
+	_, err = ReadPasswordFromFd(-1)
+	require.Error(t, err)
+}
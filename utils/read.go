@@ -10,7 +10,9 @@ import (
 	"unicode"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/cli/crypto/securebuf"
 	"github.com/smallstep/cli/errs"
+	"github.com/smallstep/cli/fileurl"
 	"github.com/smallstep/cli/ui"
 )
 
@@ -59,6 +61,22 @@ func ReadPasswordFromFile(filename string) ([]byte, error) {
 	return password, nil
 }
 
+// ReadPasswordBufferFromFile is like ReadPasswordFromFile but it reads the
+// password into a locked buffer that the caller must destroy after using it.
+func ReadPasswordBufferFromFile(filename string) (*securebuf.Buffer, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errs.FileError(err, filename)
+	}
+	defer f.Close()
+	buf, err := securebuf.ReadAll(f)
+	if err != nil {
+		return nil, errs.FileError(err, filename)
+	}
+	defer buf.Destroy()
+	return securebuf.FromBytes(bytes.TrimRightFunc(buf.Bytes(), unicode.IsSpace)), nil
+}
+
 // ReadStringPasswordFromFile reads and returns the password from the given filename.
 // The contents of the file will be trimmed at the right.
 func ReadStringPasswordFromFile(filename string) (string, error) {
@@ -85,13 +103,14 @@ func ReadInput(prompt string) ([]byte, error) {
 }
 
 // ReadFile returns the contents of the file identified by name. It reads from
-// STDIN if name is a hyphen ("-").
+// STDIN if name is a hyphen ("-"), and from a URL if name is an https:// URL
+// or any other URL supported by the fileurl package.
 func ReadFile(name string) (b []byte, err error) {
 	if name == stdinFilename {
 		name = "/dev/stdin"
 		b, err = ioutil.ReadAll(stdin)
 	} else {
-		b, err = ioutil.ReadFile(name)
+		b, err = fileurl.ReadFile(name)
 	}
 	if err != nil {
 		return nil, errs.FileError(err, name)
@@ -157,6 +157,20 @@ func TestReadPasswordFromFile(t *testing.T) {
 	require.True(t, bytes.Equal([]byte("my-password-on-file"), b), "expected %s to equal %s", b, content)
 }
 
+func TestReadPasswordBufferFromFile(t *testing.T) {
+	content := []byte("my-password-on-file\n")
+	f, cleanup := newFile(t, content)
+	defer cleanup()
+
+	buf, err := ReadPasswordBufferFromFile(f.Name())
+	require.NoError(t, err)
+	defer buf.Destroy()
+	require.Equal(t, []byte("my-password-on-file"), buf.Bytes())
+
+	_, err = ReadPasswordBufferFromFile(f.Name() + ".missing")
+	require.Error(t, err)
+}
+
 func TestStringReadPasswordFromFile(t *testing.T) {
 	content := []byte("my-password-on-file\n")
 	f, cleanup := newFile(t, content)
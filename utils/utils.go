@@ -3,10 +3,13 @@ package utils
 import (
 	"fmt"
 	"os"
+
+	"github.com/smallstep/cli/errs"
 )
 
 // Fail prints out the error struct if STEPDEBUG is true otherwise it just
-// prints out the error message. Finally, it exits with an error code of 1.
+// prints out the error message. Finally, it exits with the error code
+// associated with the category of the error.
 func Fail(err error) {
 	if err != nil {
 		if os.Getenv("STEPDEBUG") == "1" {
@@ -14,6 +17,6 @@ func Fail(err error) {
 		} else {
 			fmt.Fprintln(os.Stderr, err)
 		}
-		os.Exit(1)
+		os.Exit(errs.ExitCode(err))
 	}
 }
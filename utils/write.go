@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -19,19 +22,40 @@ var (
 	ErrIsDir = errors.New("file is a directory")
 )
 
-// WriteFile wraps ioutil.WriteFile with a prompt to overwrite a file if
-// the file exists. It returns ErrFileExists if the user picks to not overwrite
-// the file. If force is set to true, the prompt will not be presented and the
-// file if exists will be overwritten.
+// WriteFile writes data to a file with a prompt to overwrite the file if it
+// exists. It returns ErrFileExists if the user picks to not overwrite the
+// file. If force is set to true, the prompt will not be presented and the file
+// if exists will be overwritten.
+//
+// The file is written atomically using WriteFileAtomic. The permissions and
+// owner of the file can be changed with the --mode, --owner and --group flags
+// of the current command.
 func WriteFile(filename string, data []byte, perm os.FileMode) error {
-	if command.IsForce() {
-		return ioutil.WriteFile(filename, data, perm)
+	if mode := command.FileMode(); mode != "" {
+		m, err := ParseFileMode(mode)
+		if err != nil {
+			return err
+		}
+		perm = m
 	}
+	owner, group := command.FileOwner()
 
+	if !command.IsForce() {
+		if err := ConfirmOverwrite(filename); err != nil {
+			return err
+		}
+	}
+	return WriteFileAtomic(filename, data, perm, owner, group)
+}
+
+// ConfirmOverwrite prompts the user to overwrite the given file if it exists.
+// It returns ErrFileExists if the user picks to not overwrite the file, and
+// an error if the file exists in non-interactive mode or is a directory.
+func ConfirmOverwrite(filename string) error {
 	st, err := os.Stat(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return ioutil.WriteFile(filename, data, perm)
+			return nil
 		}
 		return errors.Wrapf(err, "error reading information for %s", filename)
 	}
@@ -40,15 +64,133 @@ func WriteFile(filename string, data []byte, perm os.FileMode) error {
 		return ErrIsDir
 	}
 
+	if ui.IsNonInteractive() {
+		return errors.Wrapf(ErrFileExists, "cannot overwrite %s in non-interactive mode, use the '--force' flag", filename)
+	}
+
 	str, err := ui.Prompt(fmt.Sprintf("Would you like to overwrite %s [y/n]", filename), ui.WithValidateYesNo())
 	if err != nil {
 		return err
 	}
 	switch strings.ToLower(strings.TrimSpace(str)) {
 	case "y", "yes":
-	case "n", "no":
+		return nil
+	default:
 		return ErrFileExists
 	}
+}
+
+// ReplaceFile atomically replaces the contents of an existing file without
+// prompting. The file keeps its permissions unless the --mode flag of the
+// current command is used, the owner can be changed with the --owner and
+// --group flags.
+func ReplaceFile(filename string, data []byte) error {
+	st, err := os.Stat(filename)
+	if err != nil {
+		return errors.Wrapf(err, "error reading information for %s", filename)
+	}
+	if st.IsDir() {
+		return ErrIsDir
+	}
+	perm := st.Mode().Perm()
+	if mode := command.FileMode(); mode != "" {
+		if perm, err = ParseFileMode(mode); err != nil {
+			return err
+		}
+	}
+	owner, group := command.FileOwner()
+	return WriteFileAtomic(filename, data, perm, owner, group)
+}
+
+// WriteFileAtomic writes data to a temporary file in the same directory, sets
+// its permissions and owner, and renames it to filename. Readers will see
+// either the old or the new content, never a partial file, and the content
+// is never readable with more permissive permissions than perm. The owner and
+// group are optional. If filename is a symbolic link, the target of the link
+// is replaced.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode, owner, group string) error {
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+	uid, gid, err := LookupOwner(owner, group)
+	if err != nil {
+		return err
+	}
+
+	// The temporary file is created with 0600 permissions.
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "error creating %s", filename)
+	}
+	tmp := f.Name()
+	err = writeTempFile(f, data, perm, uid, gid)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = errors.Wrapf(cerr, "error writing %s", filename)
+	}
+	if err == nil {
+		if rerr := os.Rename(tmp, filename); rerr != nil {
+			err = errors.Wrapf(rerr, "error writing %s", filename)
+		}
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
 
-	return ioutil.WriteFile(filename, data, perm)
+func writeTempFile(f *os.File, data []byte, perm os.FileMode, uid, gid int) error {
+	if uid != -1 || gid != -1 {
+		if err := f.Chown(uid, gid); err != nil {
+			return errors.Wrapf(err, "error changing the owner of %s", f.Name())
+		}
+	}
+	if err := f.Chmod(perm); err != nil {
+		return errors.Wrapf(err, "error changing the permissions of %s", f.Name())
+	}
+	if _, err := f.Write(data); err != nil {
+		return errors.Wrapf(err, "error writing %s", f.Name())
+	}
+	if err := f.Sync(); err != nil {
+		return errors.Wrapf(err, "error writing %s", f.Name())
+	}
+	return nil
+}
+
+// ParseFileMode parses an octal file mode like 0644.
+func ParseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, errors.Errorf("invalid file mode %s", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// LookupOwner returns the uid and gid of the given user and group names or
+// ids. It returns -1 for the empty values.
+func LookupOwner(owner, group string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if owner != "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			if u, err = user.LookupId(owner); err != nil {
+				return 0, 0, errors.Errorf("unknown user %s", owner)
+			}
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, errors.Errorf("user %s is not supported", owner)
+		}
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return 0, 0, errors.Errorf("unknown group %s", group)
+			}
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, errors.Errorf("group %s is not supported", group)
+		}
+	}
+	return uid, gid, nil
 }
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "write")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(filename, []byte("old"), 0644))
+	require.NoError(t, WriteFileAtomic(filename, []byte("new"), 0600, "", ""))
+
+	b, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "new", string(b))
+	if runtime.GOOS != "windows" {
+		st, err := os.Stat(filename)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), st.Mode().Perm())
+	}
+
+	// Symbolic links are preserved.
+	if runtime.GOOS != "windows" {
+		link := filepath.Join(dir, "link.pem")
+		require.NoError(t, os.Symlink(filename, link))
+		require.NoError(t, WriteFileAtomic(link, []byte("linked"), 0600, "", ""))
+		st, err := os.Lstat(link)
+		require.NoError(t, err)
+		require.True(t, st.Mode()&os.ModeSymlink != 0)
+		b, err = ioutil.ReadFile(filename)
+		require.NoError(t, err)
+		require.Equal(t, "linked", string(b))
+	}
+
+	// No temporary files are left behind.
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	for _, fi := range files {
+		require.NotContains(t, fi.Name(), ".tmp")
+	}
+
+	// Errors are returned if the directory does not exist.
+	require.Error(t, WriteFileAtomic(filepath.Join(dir, "missing", "key.pem"), []byte("new"), 0600, "", ""))
+}
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"0600", 0600, false},
+		{"644", 0644, false},
+		{"0777", 0777, false},
+		{"1777", 0, true},
+		{"0800", 0, true},
+		{"rw", 0, true},
+		{"", 0, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.mode, func(t *testing.T) {
+			got, err := ParseFileMode(tc.mode)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestLookupOwner(t *testing.T) {
+	uid, gid, err := LookupOwner("", "")
+	require.NoError(t, err)
+	require.Equal(t, -1, uid)
+	require.Equal(t, -1, gid)
+
+	_, _, err = LookupOwner("step-unknown-user", "")
+	require.Error(t, err)
+	_, _, err = LookupOwner("", "step-unknown-group")
+	require.Error(t, err)
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	u, err := user.Current()
+	require.NoError(t, err)
+	uid, gid, err = LookupOwner(u.Username, "")
+	require.NoError(t, err)
+	require.NotEqual(t, -1, uid)
+	require.Equal(t, -1, gid)
+	uid2, _, err := LookupOwner(u.Uid, "")
+	require.NoError(t, err)
+	require.Equal(t, uid, uid2)
+}